@@ -23,7 +23,7 @@ type BfsURLParts struct {
 // isIPEndpointStyle checkes if URL's host is IP, in this case the storage account endpoint will be composed as:
 // http(s)://IP(:port)/storageaccount/share(||container||etc)/...
 func isIPEndpointStyle(url url.URL) bool {
-	return net.ParseIP(url.Host) != nil
+	return net.ParseIP(url.Hostname()) != nil // without the port, which the emulators' URLs normally have
 }
 
 // NewBfsURLParts parses a URL initializing BfsURLParts' fields. Any other
@@ -8,6 +8,7 @@ import (
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"io"
 	"net/http"
+	"time"
 )
 
 // A FileURL represents a URL to an Azure Storage file.
@@ -15,6 +16,8 @@ type FileURL struct {
 	fileClient     pathClient
 	fileSystemName string
 	path           string
+	// the lease that the file's appends, flushes and deletion are made under, if any
+	leaseID *string
 }
 
 // BlobFSHTTPHeaders represents the set of custom headers available for defining information about the content.
@@ -50,17 +53,102 @@ func (f FileURL) String() string {
 
 // WithPipeline creates a new FileURL object identical to the source but with the specified request policy pipeline.
 func (f FileURL) WithPipeline(p pipeline.Pipeline) FileURL {
-	return NewFileURL(f.fileClient.URL(), p)
+	n := NewFileURL(f.fileClient.URL(), p)
+	n.leaseID = f.leaseID
+	return n
+}
+
+// WithLeaseID creates a new FileURL object identical to the source, but whose appends, flushes and deletion
+// are made under the given lease, as they must be while the file is leased. An empty lease ID means no lease.
+func (f FileURL) WithLeaseID(leaseID string) FileURL {
+	f.leaseID = nil
+	if leaseID != "" {
+		f.leaseID = &leaseID
+	}
+	return f
+}
+
+// AcquireLease acquires a lease on the file, for the given number of seconds (or -1 for a lease that never expires).
+// For more information, see https://docs.microsoft.com/en-us/rest/api/storageservices/datalakestoragegen2/path/lease.
+func (f FileURL) AcquireLease(ctx context.Context, proposedID string, duration int32) (*PathLeaseResponse, error) {
+	return f.fileClient.Lease(ctx, PathLeaseActionAcquire, f.fileSystemName, f.path, &duration, nil, nil, &proposedID,
+		nil, nil, nil, nil, nil, nil, nil)
+}
+
+// RenewLease renews the lease on the file, so that it lasts for its whole duration again.
+func (f FileURL) RenewLease(ctx context.Context, leaseID string) (*PathLeaseResponse, error) {
+	return f.fileClient.Lease(ctx, PathLeaseActionRenew, f.fileSystemName, f.path, nil, nil, &leaseID, nil,
+		nil, nil, nil, nil, nil, nil, nil)
+}
+
+// ReleaseLease releases the lease on the file, so that anything can write to it, or lease it, again.
+func (f FileURL) ReleaseLease(ctx context.Context, leaseID string) (*PathLeaseResponse, error) {
+	return f.fileClient.Lease(ctx, PathLeaseActionRelease, f.fileSystemName, f.path, nil, nil, &leaseID, nil,
+		nil, nil, nil, nil, nil, nil, nil)
 }
 
 // Create creates a new file or replaces a file. Note that this method only initializes the file.
 // For more information, see https://docs.microsoft.com/en-us/rest/api/storageservices/create-file.
 func (f FileURL) Create(ctx context.Context, headers BlobFSHTTPHeaders) (*PathCreateResponse, error) {
+	return f.CreateWithConditions(ctx, headers, PathAccessConditions{})
+}
+
+// PathAccessConditions are the conditions that a path must meet for a create (or a rename onto it) to go ahead.
+// The zero value is no condition.
+type PathAccessConditions struct {
+	// "*" for a path that mustn't exist yet. Empty for no such condition
+	IfNoneMatch string
+	// the time that the path mustn't have been modified since. Zero for no such condition
+	IfUnmodifiedSince time.Time
+}
+
+func (c PathAccessConditions) pointers() (ifNoneMatch *string, ifUnmodifiedSince *string) {
+	if c.IfNoneMatch != "" {
+		ifNoneMatch = &c.IfNoneMatch
+	}
+	if !c.IfUnmodifiedSince.IsZero() {
+		s := c.IfUnmodifiedSince.UTC().Format(http.TimeFormat)
+		ifUnmodifiedSince = &s
+	}
+	return
+}
+
+// CreateWithConditions creates a new file or replaces a file, like Create, if the file meets the conditions.
+// If it doesn't, the create fails with PathAlreadyExists or ConditionNotMet.
+func (f FileURL) CreateWithConditions(ctx context.Context, headers BlobFSHTTPHeaders, conditions PathAccessConditions) (*PathCreateResponse, error) {
+	ifNoneMatch, ifUnmodifiedSince := conditions.pointers()
 	return f.fileClient.Create(ctx, f.fileSystemName, f.path, PathResourceFile,
 		nil, PathRenameModeNone, nil, nil, nil, nil,
 		&headers.CacheControl, &headers.ContentType, &headers.ContentEncoding, &headers.ContentLanguage, &headers.ContentDisposition,
 		nil, nil, nil, nil, nil, nil,
+		nil, ifNoneMatch, nil, ifUnmodifiedSince, nil,
+		nil, nil, nil, nil, nil,
+		nil)
+}
+
+// Rename moves the file to the path of the destination, which must be in the same file system, replacing the file that's there, if any.
+// The file's lease, if it's leased, is given with WithLeaseID. Once renamed, the file is only found at the destination.
+// For more information, see https://docs.microsoft.com/en-us/rest/api/storageservices/datalakestoragegen2/path/create.
+func (f FileURL) Rename(ctx context.Context, destination FileURL) (*PathCreateResponse, error) {
+	return f.RenameWithConditions(ctx, destination, PathAccessConditions{})
+}
+
+// RenameWithConditions moves the file to the path of the destination, like Rename, if what's at the destination meets the conditions.
+// If it doesn't, the rename fails with PathAlreadyExists or ConditionNotMet, and the file keeps its name.
+func (f FileURL) RenameWithConditions(ctx context.Context, destination FileURL, conditions PathAccessConditions) (*PathCreateResponse, error) {
+	source := f.URL()
+	ifNoneMatch, ifUnmodifiedSince := conditions.pointers()
+	renameSource := (&url.URL{Path: "/" + f.fileSystemName + "/" + f.path}).EscapedPath()
+	if source.RawQuery != "" {
+		// the source is authorized by its own SAS, if it has one
+		renameSource += "?" + source.RawQuery
+	}
+
+	return destination.fileClient.Create(ctx, destination.fileSystemName, destination.path, PathResourceNone,
+		nil, PathRenameModeNone, nil, nil, nil, nil,
 		nil, nil, nil, nil, nil,
+		&renameSource, destination.leaseID, f.leaseID, nil, nil, nil,
+		nil, ifNoneMatch, nil, ifUnmodifiedSince, nil,
 		nil, nil, nil, nil, nil,
 		nil)
 }
@@ -112,7 +200,7 @@ func (dr *DownloadResponse) Body(o RetryReaderOptions) io.ReadCloser {
 func (f FileURL) Delete(ctx context.Context) (*PathDeleteResponse, error) {
 	recursive := false
 	return f.fileClient.Delete(ctx, f.fileSystemName, f.path, &recursive,
-		nil, nil, nil, nil, nil, nil,
+		nil, f.leaseID, nil, nil, nil, nil,
 		nil, nil, nil)
 }
 
@@ -158,7 +246,7 @@ func (f FileURL) AppendData(ctx context.Context, offset int64, body io.ReadSeeke
 
 	// TransactionalContentMD5 isn't supported currently.
 	return f.fileClient.Update(ctx, PathUpdateActionAppend, f.fileSystemName, f.path, &offset,
-		nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, f.leaseID, nil, nil,
 		nil, nil, nil, nil, nil, nil, nil,
 		nil, nil, nil, nil, nil, nil, &overrideHttpVerb, body, nil, nil, nil)
 }
@@ -183,8 +271,50 @@ func (f FileURL) FlushData(ctx context.Context, fileSize int64, contentMd5 []byt
 
 	// TransactionalContentMD5 isn't supported currently.
 	return f.fileClient.Update(ctx, PathUpdateActionFlush, f.fileSystemName, f.path, &fileSize,
-		&retainUncommittedData, &closeFile, nil, nil,
+		&retainUncommittedData, &closeFile, nil, f.leaseID,
 		&headers.CacheControl, &headers.ContentType, &headers.ContentDisposition, &headers.ContentEncoding, &headers.ContentLanguage,
 		md5InBase64, nil, nil, nil, nil, nil, nil, nil,
 		nil, nil, &overrideHttpVerb, nil, nil, nil, nil)
 }
+
+// AppendAndFlushServiceVersion is the first service version in which an append can also flush the file
+const AppendAndFlushServiceVersion = "2023-08-03"
+
+// AppendDataAndClose writes the whole content of a file that was just created, and flushes and closes it in the same request,
+// which saves the round trip of a separate FlushData. The headers are set on the file as FlushData would set them.
+func (f FileURL) AppendDataAndClose(ctx context.Context, body io.ReadSeeker, headers BlobFSHTTPHeaders) (*PathUpdateResponse, error) {
+	if body == nil {
+		panic("body must not be nil")
+	}
+
+	count := validateSeekableStreamAt0AndGetCount(body)
+	if count == 0 {
+		panic("body must contain readable data whose size is > 0")
+	}
+
+	// See the todo in AppendData about PATCH
+	overrideHttpVerb := "PATCH"
+	var offset int64
+	closeFile := true
+
+	req, err := f.fileClient.updatePreparer(PathUpdateActionAppend, f.fileSystemName, f.path, &offset,
+		nil, &closeFile, nil, f.leaseID,
+		&headers.CacheControl, &headers.ContentType, &headers.ContentDisposition, &headers.ContentEncoding, &headers.ContentLanguage,
+		nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, &overrideHttpVerb, body, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the generated code predates the flush parameter of append, as well as the service version that has it
+	params := req.URL.Query()
+	params.Set("flush", "true")
+	req.URL.RawQuery = params.Encode()
+	req.Header.Set("x-ms-version", AppendAndFlushServiceVersion)
+
+	resp, err := f.fileClient.Pipeline().Do(ctx, responderPolicyFactory{responder: f.fileClient.updateResponder}, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*PathUpdateResponse), err
+}
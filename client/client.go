@@ -0,0 +1,308 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package client runs AzCopy transfers inside another program, rather than through the azcopy executable.
+// A transfer runs the same command, through the same code, as the command line would, and reports what
+// --output-type=json would have printed, as typed events.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/cmd"
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// Request is one transfer, given as the parts of the command that would run it on the command line
+type Request struct {
+	// Command is copy, sync or remove. It's copy if empty
+	Command     string
+	Source      string
+	Destination string // not given to remove
+
+	// SourceSAS and DestinationSAS are the SAS tokens of the source and the destination, if they're authorized with one.
+	// Like the SAS tokens in the URLs given on the command line, they're kept in memory only, and never written to the plan files of the job
+	SourceSAS      string
+	DestinationSAS string
+
+	// Flags are the other options of the command, by their names on the command line without the dashes, e.g. "recursive": "true".
+	// output-type can't be given, since the events are what --output-type=json prints
+	Flags map[string]string
+
+	// LogFolder and PlanFolder are where the logs and the plan files of the job go. If empty, they're those of AZCOPY_LOG_LOCATION
+	// and AZCOPY_JOB_PLAN_LOCATION, like on the command line, and if those aren't set either, folders of azcopy in the user's cache folder.
+	// The engine is started by the first transfer of the program, and keeps the plan folder and the other engine-wide options
+	// (e.g. cap-mbps) of that transfer
+	LogFolder  string
+	PlanFolder string
+}
+
+// Result is how a transfer ended
+type Result struct {
+	ExitCode common.ExitCode
+	// the last summary of the job, if it got as far as being started
+	Summary *common.ListSyncJobSummaryResponse
+	// nil if the transfer succeeded, i.e. if the exit code is Success
+	Err error
+}
+
+// StatusStream follows a transfer. Its events must be read until the channel is closed, since AzCopy waits for them to be taken
+// (only the progress events are dropped, rather than waited for, when they aren't taken fast enough)
+type StatusStream struct {
+	events      chan Event
+	done        chan struct{}
+	summary     *common.ListSyncJobSummaryResponse
+	lastFailure string
+	result      Result
+}
+
+// Events gives the events of the transfer, in the order they happened. The channel is closed when the transfer has ended
+func (s *StatusStream) Events() <-chan Event {
+	return s.events
+}
+
+// Wait waits for the transfer to end
+func (s *StatusStream) Wait() Result {
+	<-s.done
+	return s.result
+}
+
+// Done is closed when the transfer has ended
+func (s *StatusStream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *StatusStream) receive(jsonMessage string) {
+	event, err := parseEvent(jsonMessage)
+	if err != nil {
+		event = Event{Type: EventTypeInfo, Content: jsonMessage}
+	}
+
+	switch event.Type {
+	case EventTypeProgress, EventTypeEndOfJob:
+		if event.Summary != nil {
+			s.summary = event.Summary
+		}
+	case EventTypeError:
+		s.lastFailure = event.Content
+	}
+
+	if event.Type == EventTypeProgress {
+		select {
+		case s.events <- event:
+		default: // the next progress event supersedes it anyway
+		}
+		return
+	}
+	s.events <- event
+}
+
+func (s *StatusStream) finish(exitCode common.ExitCode) {
+	s.result = Result{ExitCode: exitCode, Summary: s.summary}
+	if exitCode != common.EExitCode.Success() {
+		switch {
+		case s.lastFailure != "":
+			s.result.Err = errors.New(s.lastFailure)
+		case s.summary != nil:
+			s.result.Err = fmt.Errorf("the job ended with status %s", s.summary.JobStatus)
+		default:
+			s.result.Err = fmt.Errorf("AzCopy ended with exit code %d", exitCode)
+		}
+	}
+
+	close(s.events)
+	close(s.done)
+}
+
+// the engine, the lifecycle manager and the flags of AzCopy are all global, so only one transfer can run at a time
+var transferLock = make(chan struct{}, 1)
+
+// dispatcher is the output sink of the lifecycle manager. It passes the output on to the stream of the transfer that is running,
+// and drops what comes when there's none, e.g. the notice of a newer version, which may come late
+type dispatcher struct {
+	lock    sync.Mutex
+	current *StatusStream
+}
+
+var theDispatcher = &dispatcher{}
+var installDispatcher sync.Once
+
+func (d *dispatcher) start(s *StatusStream) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.current = s
+}
+
+func (d *dispatcher) Output(jsonMessage string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.current != nil {
+		d.current.receive(jsonMessage)
+	}
+}
+
+func (d *dispatcher) Exit(code common.ExitCode) {
+	d.lock.Lock()
+	s := d.current
+	d.current = nil
+	d.lock.Unlock()
+
+	if s != nil {
+		s.finish(code)
+		<-transferLock
+	}
+}
+
+// Transfer starts the transfer, and returns the stream that follows it. If another transfer is running, it waits for that one to end first.
+// Cancelling the context cancels the transfer, as Ctrl-C would on the command line: it then ends with the job cancelled
+func Transfer(ctx context.Context, req Request) (*StatusStream, error) {
+	args, err := req.args()
+	if err != nil {
+		return nil, err
+	}
+	logFolder, planFolder, err := req.folders()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case transferLock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	installDispatcher.Do(func() { common.SetOutputSink(theDispatcher) })
+	common.ClearCancellationRequest()
+	common.GetLifecycleMgr().AllowReinitiateProgressReporting()
+
+	stream := &StatusStream{
+		events: make(chan Event, 1000),
+		done:   make(chan struct{}),
+	}
+	theDispatcher.start(stream)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			common.RequestCancellation()
+		case <-stream.done:
+		}
+	}()
+
+	// the handle limit belongs to the program, so the engine keeps to its floor of open files.
+	// The goroutine ends when the command has ended, since the lifecycle manager doesn't exit the process
+	go cmd.ExecuteArgs(logFolder, logFolder, planFolder, 0, args)
+
+	return stream, nil
+}
+
+// args are the arguments of the command line that would run the transfer
+func (r Request) args() ([]string, error) {
+	command := r.Command
+	if command == "" {
+		command = "copy"
+	}
+	if command != "copy" && command != "sync" && command != "remove" {
+		return nil, fmt.Errorf("unsupported command %q. The choices include: copy, sync, remove", r.Command)
+	}
+	if r.Source == "" {
+		return nil, errors.New("the source is required")
+	}
+	if command == "remove" && r.Destination != "" {
+		return nil, errors.New("remove takes no destination")
+	}
+	if command != "remove" && r.Destination == "" {
+		return nil, errors.New("the destination is required")
+	}
+
+	args := []string{command, withSAS(r.Source, r.SourceSAS)}
+	if r.Destination != "" {
+		args = append(args, withSAS(r.Destination, r.DestinationSAS))
+	}
+
+	flags := make(map[string]string, len(r.Flags))
+	names := make([]string, 0, len(r.Flags))
+	for name, value := range r.Flags {
+		name = strings.TrimLeft(name, "-")
+		if name == "output-type" {
+			return nil, errors.New("output-type can't be given, since the events are what --output-type=json prints")
+		}
+		flags[name] = value
+		names = append(names, name)
+	}
+	sort.Strings(names) // so that the command, which is logged, is the same from run to run
+
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("--%s=%s", name, flags[name]))
+	}
+	return append(args, "--output-type=json"), nil
+}
+
+// withSAS adds the SAS token to the query of the URL, which is where AzCopy takes it from (and then keeps it apart from the URL)
+func withSAS(resource, sas string) string {
+	sas = strings.TrimPrefix(sas, "?")
+	if sas == "" {
+		return resource
+	}
+	if strings.Contains(resource, "?") {
+		return resource + "&" + sas
+	}
+	return resource + "?" + sas
+}
+
+func (r Request) folders() (logFolder, planFolder string, err error) {
+	lcm := common.GetLifecycleMgr()
+
+	logFolder = r.LogFolder
+	if logFolder == "" {
+		logFolder = lcm.GetEnvironmentVariable(common.EEnvironmentVariable.LogLocation())
+	}
+	planFolder = r.PlanFolder
+	if planFolder == "" {
+		planFolder = lcm.GetEnvironmentVariable(common.EEnvironmentVariable.JobPlanLocation())
+	}
+
+	if logFolder == "" || planFolder == "" {
+		cacheFolder, err := os.UserCacheDir()
+		if err != nil {
+			return "", "", fmt.Errorf("no log or plan folder was given, and the user's cache folder is unknown: %s", err)
+		}
+		if logFolder == "" {
+			logFolder = filepath.Join(cacheFolder, "azcopy")
+		}
+		if planFolder == "" {
+			planFolder = filepath.Join(cacheFolder, "azcopy", "plans")
+		}
+	}
+
+	for _, folder := range []string{logFolder, planFolder} {
+		if err := os.MkdirAll(folder, os.ModeDir|os.ModePerm); err != nil {
+			return "", "", err
+		}
+	}
+	return logFolder, planFolder, nil
+}
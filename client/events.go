@@ -0,0 +1,90 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// the types of the events, which are the message types of the JSON output.
+// The events about one object (e.g. a file that was skipped because the destination was newer) have the name of the event as their type instead
+const (
+	EventTypeInit     = "Init"
+	EventTypeInfo     = "Info"
+	EventTypeProgress = "Progress"
+	EventTypeEndOfJob = "EndOfJob"
+	EventTypeError    = "Error"
+	EventTypePrompt   = "Prompt"
+)
+
+// Event is one message of the output of AzCopy, as --output-type=json prints it
+type Event struct {
+	Type string
+	Time time.Time
+	// the text of Info and Error, and the JSON of the others, which is parsed into Init or Summary when it's one of those
+	Content string
+
+	// only for Init
+	Init *common.InitMsgJsonTemplate
+	// only for Progress and EndOfJob, when they're about a job (the progress of sync while it's scanning isn't).
+	// The deletions and skipped files are those of sync only. Like in the JSON, the job ID isn't in it, but in Init
+	Summary *common.ListSyncJobSummaryResponse
+	// only for Prompt, which is always given the default answer, since there's no one to ask
+	PromptDetails *common.PromptDetails
+}
+
+// the JSON output template of common.lifecycleMgr
+type jsonMessage struct {
+	TimeStamp      time.Time
+	MessageType    string
+	MessageContent string
+	PromptDetails  common.PromptDetails
+}
+
+func parseEvent(line string) (Event, error) {
+	var m jsonMessage
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return Event{}, err
+	}
+
+	event := Event{Type: m.MessageType, Time: m.TimeStamp, Content: m.MessageContent}
+	switch m.MessageType {
+	case EventTypeInit:
+		var init common.InitMsgJsonTemplate
+		if json.Unmarshal([]byte(m.MessageContent), &init) == nil {
+			event.Init = &init
+		}
+	case EventTypeProgress, EventTypeEndOfJob:
+		// a summary always has a status, which tells it from the progress of a scan
+		var probe struct{ JobStatus *common.JobStatus }
+		var summary common.ListSyncJobSummaryResponse
+		if json.Unmarshal([]byte(m.MessageContent), &probe) == nil && probe.JobStatus != nil &&
+			json.Unmarshal([]byte(m.MessageContent), &summary) == nil {
+			event.Summary = &summary
+		}
+	case EventTypePrompt:
+		event.PromptDetails = &m.PromptDetails
+	}
+	return event, nil
+}
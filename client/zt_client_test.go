@@ -0,0 +1,139 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { chk.TestingT(t) }
+
+type clientSuite struct{}
+
+var _ = chk.Suite(&clientSuite{})
+
+func (s *clientSuite) TestRequestIsTheCommandLineThatWouldRunIt(c *chk.C) {
+	args, err := Request{
+		Source:         "/data",
+		Destination:    "https://account.blob.core.windows.net/container?comp=list",
+		DestinationSAS: "?sv=2019-02-02&sig=secret",
+		Flags:          map[string]string{"recursive": "true", "--overwrite": "false"},
+	}.args()
+	c.Assert(err, chk.IsNil)
+	c.Assert(args, chk.DeepEquals, []string{"copy", "/data",
+		"https://account.blob.core.windows.net/container?comp=list&sv=2019-02-02&sig=secret",
+		"--overwrite=false", "--recursive=true", "--output-type=json"})
+
+	args, err = Request{Command: "remove", Source: "https://account.blob.core.windows.net/container/dir", SourceSAS: "sig=secret"}.args()
+	c.Assert(err, chk.IsNil)
+	c.Assert(args, chk.DeepEquals, []string{"remove", "https://account.blob.core.windows.net/container/dir?sig=secret", "--output-type=json"})
+
+	for _, req := range []Request{
+		{Command: "list", Source: "/data", Destination: "/elsewhere"},
+		{Destination: "/elsewhere"},
+		{Source: "/data"},
+		{Command: "remove", Source: "/data", Destination: "/elsewhere"},
+		{Source: "/data", Destination: "/elsewhere", Flags: map[string]string{"output-type": "text"}},
+	} {
+		_, err = req.args()
+		c.Assert(err, chk.NotNil)
+	}
+}
+
+func (s *clientSuite) TestEventsAreParsedFromTheJSONOutput(c *chk.C) {
+	jobID := common.NewJobID()
+	summary := common.ListJobSummaryResponse{JobID: jobID, JobStatus: common.EJobStatus.Completed(), TotalTransfers: 2, TransfersCompleted: 2}
+
+	event, err := parseEvent(common.GetJsonStringFromTemplate(jsonMessage{
+		TimeStamp: time.Now(), MessageType: "EndOfJob", MessageContent: common.GetJsonStringFromTemplate(summary)}))
+	c.Assert(err, chk.IsNil)
+	c.Assert(event.Type, chk.Equals, EventTypeEndOfJob)
+	c.Assert(event.Summary, chk.NotNil)
+	c.Assert(event.Summary.JobStatus, chk.Equals, common.EJobStatus.Completed())
+	c.Assert(event.Summary.TransfersCompleted, chk.Equals, uint32(2))
+
+	// the progress of sync while it scans isn't about a job yet
+	event, err = parseEvent(`{"MessageType":"Progress","MessageContent":"{\"FilesScannedAtSource\":3}"}`)
+	c.Assert(err, chk.IsNil)
+	c.Assert(event.Type, chk.Equals, EventTypeProgress)
+	c.Assert(event.Summary, chk.IsNil)
+	c.Assert(event.Content, chk.Equals, `{"FilesScannedAtSource":3}`)
+
+	event, err = parseEvent(common.GetJsonStringFromTemplate(jsonMessage{MessageType: "Init",
+		MessageContent: common.GetJsonStringFromTemplate(common.InitMsgJsonTemplate{JobID: jobID.String(), LogFileLocation: "/logs/job.log"})}))
+	c.Assert(err, chk.IsNil)
+	c.Assert(event.Init, chk.NotNil)
+	c.Assert(event.Init.JobID, chk.Equals, jobID.String())
+
+	event, err = parseEvent(`{"MessageType":"Error","MessageContent":"failed to perform copy command"}`)
+	c.Assert(err, chk.IsNil)
+	c.Assert(event.Type, chk.Equals, EventTypeError)
+	c.Assert(event.Content, chk.Equals, "failed to perform copy command")
+
+	_, err = parseEvent("not JSON")
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *clientSuite) TestFailedTransfersEndWithoutEndingTheProcess(c *chk.C) {
+	folder, err := ioutil.TempDir("", "client")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(folder)
+
+	// there are no local to local copies, so each transfer fails before it does anything, but only after the engine has started
+	req := Request{
+		Source:      filepath.Join(folder, "missing"),
+		Destination: filepath.Join(folder, "elsewhere"),
+		Flags:       map[string]string{"recursive": "true"},
+		LogFolder:   folder,
+		PlanFolder:  filepath.Join(folder, "plans"),
+	}
+	for i := 0; i < 2; i++ {
+		stream, err := Transfer(context.Background(), req)
+		c.Assert(err, chk.IsNil)
+
+		sawError := false
+		for event := range stream.Events() {
+			if event.Type == EventTypeError {
+				sawError = true
+			}
+		}
+		result := stream.Wait()
+		c.Assert(sawError, chk.Equals, true)
+		c.Assert(result.ExitCode, chk.Equals, common.EExitCode.Error())
+		c.Assert(result.Err, chk.NotNil)
+	}
+
+	// a cancelled context gets no transfer started while another runs
+	transferLock <- struct{}{}
+	defer func() { <-transferLock }()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Transfer(ctx, req)
+	c.Assert(err, chk.Equals, context.Canceled)
+}
@@ -24,11 +24,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/spf13/cobra"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // represents the raw benchmark command input from the user
@@ -38,9 +41,16 @@ type rawBenchmarkCmdArgs struct {
 	// where are we uploading the benchmark data to?
 	dst string
 
+	// in S2S mode, the container that the auto-generated data is uploaded to, and then copied from
+	s2sSrc string
+
+	// what kind of transfer are we measuring?
+	mode string
+
 	// parameters controlling the auto-generated data
 	sizePerFile    string
 	fileCount      uint
+	sizeSpec       string // a mixture of file sizes, used instead of sizePerFile and fileCount
 	deleteTestData bool
 
 	// options from flags
@@ -88,10 +98,62 @@ func parseSizeString(s string, name string) (int64, error) {
 	return bytes, nil
 }
 
+// a group of identically-sized auto-generated files
+type benchmarkFileBucket struct {
+	fileCount    uint
+	bytesPerFile int64
+}
+
+// String gives the bucket in a form like that used in size specs. It's also used as a directory name
+func (b benchmarkFileBucket) String() string {
+	size := b.bytesPerFile
+	for _, unit := range []string{"B", "KiB", "MiB"} {
+		if size%1024 != 0 {
+			return fmt.Sprintf("%dx%d%s", b.fileCount, size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%dx%dGiB", b.fileCount, size)
+}
+
+// parseSizeSpec parses a list of count x size pairs, e.g. "100000x4KiB,5000x1MiB,50x2GiB", into buckets.
+// Sizes are as accepted by parseSizeString, optionally followed by "iB"
+func parseSizeSpec(spec string) ([]benchmarkFileBucket, error) {
+	message := common.SizeSpecParam + " must be a comma-separated list of pairs, each of which is a file count followed by 'x' and " + sizeStringDescription + ", optionally followed by iB. E.g. 100000x4KiB,50x2GiB"
+
+	buckets := make([]benchmarkFileBucket, 0)
+	for _, pair := range strings.Split(spec, ",") {
+		pieces := strings.Split(strings.ToLower(strings.TrimSpace(pair)), "x")
+		if len(pieces) != 2 {
+			return nil, errors.New(message)
+		}
+
+		count, err := strconv.ParseUint(pieces[0], 10, 32)
+		if err != nil || count == 0 {
+			return nil, errors.New(message)
+		}
+
+		bytesPerFile, err := parseSizeString(strings.TrimSuffix(pieces[1], "ib"), common.SizeSpecParam)
+		if err != nil || bytesPerFile <= 0 {
+			return nil, errors.New(message)
+		}
+		if bytesPerFile > maxBytesPerFile {
+			return nil, errors.New("file size too big")
+		}
+
+		buckets = append(buckets, benchmarkFileBucket{fileCount: uint(count), bytesPerFile: bytesPerFile})
+	}
+
+	return buckets, nil
+}
+
 // validates and transform raw input into cooked input
 // raw benchmark args cook into copyArgs, because the actual work
 // of a benchmark job is doing a copy. Benchmark just doesn't offer so many
-// choices in its raw args
+// choices in its raw args.
+// Each bucket of files (of which there is only one, unless a size spec was given) gets its own job, so that its throughput
+// can be measured separately. In download and S2S modes, each bucket also gets a preparation job, that uploads its test data.
+// All the jobs, and any cleanup, are chained together as followup jobs of the one that is returned.
 func (raw rawBenchmarkCmdArgs) cook() (cookedCopyCmdArgs, error) {
 
 	glcm.Info(common.BenchmarkPreviewNotice)
@@ -100,33 +162,145 @@ func (raw rawBenchmarkCmdArgs) cook() (cookedCopyCmdArgs, error) {
 	jobID := common.NewJobID()
 	virtualDir := "benchmark-" + jobID.String() // create unique directory name, so we won't overwrite anything
 
-	if raw.fileCount <= 0 {
-		return dummyCooked, errors.New(common.FileCountParam + " must be greater than zero")
+	var mode common.BenchMarkMode
+	if err := mode.Parse(raw.mode); err != nil {
+		return dummyCooked, fmt.Errorf("invalid %s '%s'. Valid modes are Upload, Download and S2S", common.BenchMarkModeParam, raw.mode)
 	}
 
-	bytesPerFile, err := parseSizeString(raw.sizePerFile, common.SizePerFileParam)
+	var buckets []benchmarkFileBucket
+	if raw.sizeSpec != "" {
+		var err error
+		buckets, err = parseSizeSpec(raw.sizeSpec)
+		if err != nil {
+			return dummyCooked, err
+		}
+	} else {
+		if raw.fileCount <= 0 {
+			return dummyCooked, errors.New(common.FileCountParam + " must be greater than zero")
+		}
+
+		bytesPerFile, err := parseSizeString(raw.sizePerFile, common.SizePerFileParam)
+		if err != nil {
+			return dummyCooked, err
+		}
+		if bytesPerFile <= 0 {
+			return dummyCooked, errors.New(common.SizePerFileParam + " must be greater than zero")
+		}
+
+		if bytesPerFile > maxBytesPerFile {
+			return dummyCooked, errors.New("file size too big")
+		}
+
+		buckets = []benchmarkFileBucket{{fileCount: raw.fileCount, bytesPerFile: bytesPerFile}}
+	}
+
+	// the generated data is always uploaded first. In S2S mode, it goes to the source of the later copy
+	uploadTarget := raw.dst
+	if mode == common.EBenchMarkMode.S2S() {
+		uploadTarget = raw.s2sSrc
+	}
+	uploadRoot, err := raw.appendVirtualDir(uploadTarget, virtualDir)
 	if err != nil {
 		return dummyCooked, err
 	}
-	if bytesPerFile <= 0 {
-		return dummyCooked, errors.New(common.SizePerFileParam + " must be greater than zero")
+	copyRoot := ""
+	if mode == common.EBenchMarkMode.S2S() {
+		copyRoot, err = raw.appendVirtualDir(raw.dst, virtualDir)
+		if err != nil {
+			return dummyCooked, err
+		}
+	}
+
+	run := &benchmarkRun{}
+	jobs := make([]*cookedCopyCmdArgs, 0)
+	for i, b := range buckets {
+		uploadDir, copyDir := uploadRoot, copyRoot
+		if len(buckets) > 1 {
+			// give each bucket its own directory, so that the names of the generated files don't collide
+			uploadDir, err = raw.appendVirtualDir(uploadTarget, virtualDir+"/"+b.String())
+			if err != nil {
+				return dummyCooked, err
+			}
+			if mode == common.EBenchMarkMode.S2S() {
+				copyDir, err = raw.appendVirtualDir(raw.dst, virtualDir+"/"+b.String())
+				if err != nil {
+					return dummyCooked, err
+				}
+			}
+		}
+
+		benchmark := &benchmarkJobInfo{mode: mode, bucket: b, run: run, isLast: i == len(buckets)-1}
+
+		id := common.NewJobID()
+		if i == 0 {
+			id = jobID // the first job's ID is the one that names the directory
+		}
+		upload, err := raw.cookUploadJob(id, uploadDir, b)
+		if err != nil {
+			return upload, err
+		}
+		jobs = append(jobs, &upload)
+
+		switch mode {
+		case common.EBenchMarkMode.Upload():
+			upload.benchmarkJob = benchmark
+
+		case common.EBenchMarkMode.Download():
+			download, err := raw.cookDownloadJob(uploadDir)
+			if err != nil {
+				return dummyCooked, err
+			}
+			download.benchmarkJob = benchmark
+			upload.isPreparationJob = true
+			jobs = append(jobs, &download)
+
+		case common.EBenchMarkMode.S2S():
+			s2s, err := raw.cookS2SJob(uploadDir, copyDir)
+			if err != nil {
+				return dummyCooked, err
+			}
+			s2s.benchmarkJob = benchmark
+			upload.isPreparationJob = true
+			jobs = append(jobs, &s2s)
+
+		default:
+			return dummyCooked, errors.New("unsupported benchmark mode") // should never make it this far, since Parse would have failed
+		}
 	}
 
-	if bytesPerFile > maxBytesPerFile {
-		return dummyCooked, errors.New("file size too big")
+	if raw.deleteTestData {
+		// set up automatic cleanup, of both copies of the data in the case of S2S
+		if copyRoot != "" {
+			cleanup, err := raw.createCleanupJobArgs(copyRoot, raw.logVerbosity)
+			if err != nil {
+				return dummyCooked, err
+			}
+			jobs = append(jobs, cleanup)
+		}
+		cleanup, err := raw.createCleanupJobArgs(uploadRoot, raw.logVerbosity)
+		if err != nil {
+			return dummyCooked, err
+		}
+		jobs = append(jobs, cleanup)
 	}
 
+	// chain the jobs together, so that each runs after the one before it
+	for i := 0; i < len(jobs)-1; i++ {
+		jobs[i].followupJobArgs = jobs[i+1]
+	}
+
+	return *jobs[0], nil
+}
+
+// defines a job that uploads the auto-generated data to the given virtual directory
+func (raw rawBenchmarkCmdArgs) cookUploadJob(jobID common.JobID, dst string, bucket benchmarkFileBucket) (cookedCopyCmdArgs, error) {
 	// transcribe everything to copy args
 	c := rawCopyCmdArgs{}
 	c.setMandatoryDefaults()
 
 	// src must be string, but needs to indicate that its for benchmark and encode what we want
-	c.src = benchmarkSourceHelper{}.ToUrl(raw.fileCount, bytesPerFile)
-
-	c.dst, err = raw.appendVirtualDir(raw.dst, virtualDir)
-	if err != nil {
-		return dummyCooked, err
-	}
+	c.src = benchmarkSourceHelper{}.ToUrl(bucket.fileCount, bucket.bytesPerFile)
+	c.dst = dst
 
 	c.recursive = true                                     // because source is directory-like, in which case recursive is required
 	c.internalOverrideStripTopDir = true                   // we don't want to append an extra strange name filled with meta characters at the destination
@@ -138,20 +312,45 @@ func (raw rawBenchmarkCmdArgs) cook() (cookedCopyCmdArgs, error) {
 	c.output = raw.output
 	c.logVerbosity = raw.logVerbosity
 
-	cooked, err := c.cookWithId(jobID)
-	if err != nil {
-		return cooked, err
-	}
+	return c.cookWithId(jobID)
+}
 
-	if raw.deleteTestData {
-		// set up automatic cleanup
-		cooked.followupJobArgs, err = raw.createCleanupJobArgs(cooked.destination, raw.logVerbosity)
-		if err != nil {
-			return dummyCooked, err
-		}
-	}
+// defines a job that downloads the given virtual directory, and discards what it downloads so that local disk
+// speed does not affect the results
+func (raw rawBenchmarkCmdArgs) cookDownloadJob(src string) (cookedCopyCmdArgs, error) {
+	c := rawCopyCmdArgs{}
+	c.setMandatoryDefaults()
 
-	return cooked, nil
+	c.src = src
+	c.discard = true
+
+	c.recursive = true
+	c.md5ValidationOption = common.EHashValidationOption.NoCheck().String() // hashing is not part of what we are measuring, and it would force sequential saving of chunks
+
+	c.blockSizeMB = raw.blockSizeMB
+	c.output = raw.output
+	c.logVerbosity = raw.logVerbosity
+
+	return c.cook()
+}
+
+// defines a job that copies the given virtual directory between containers, service-side
+func (raw rawBenchmarkCmdArgs) cookS2SJob(src, dst string) (cookedCopyCmdArgs, error) {
+	c := rawCopyCmdArgs{}
+	c.setMandatoryDefaults()
+
+	c.src = src
+	c.dst = dst
+
+	c.recursive = true
+	c.internalOverrideStripTopDir = true                   // the destination is already a virtual directory of its own
+	c.forceWrite = common.EOverwriteOption.True().String() // don't want the extra round trip (for overwrite check) when benchmarking
+
+	c.blockSizeMB = raw.blockSizeMB
+	c.output = raw.output
+	c.logVerbosity = raw.logVerbosity
+
+	return c.cook()
 }
 
 func (raw rawBenchmarkCmdArgs) appendVirtualDir(target, virtualDir string) (string, error) {
@@ -266,6 +465,145 @@ func (h benchmarkSourceHelper) FromUrl(s string) (fileCount uint, bytesPerFile i
 	return uint(fc), bpf, nil
 }
 
+// benchmarkJobInfo marks a job that a benchmark run measures, and remembers what is needed to report on it
+type benchmarkJobInfo struct {
+	mode   common.BenchMarkMode
+	bucket benchmarkFileBucket
+	run    *benchmarkRun // shared by all the measured jobs of the run
+	isLast bool          // is this the last measured job of the run?
+}
+
+// benchmarkRun accumulates the results of the measured jobs of one benchmark run, since there is one job per file size
+type benchmarkRun struct {
+	buckets []common.BenchmarkBucketResults
+
+	// totals over the buckets of small and large files
+	smallFileCount uint
+	smallSeconds   float64
+	bulkBytes      uint64
+	bulkSeconds    float64
+}
+
+// results gathers the benchmark-specific results of the measured job, which has just finished
+func (b *benchmarkJobInfo) results(cca *cookedCopyCmdArgs, summary common.ListJobSummaryResponse, duration time.Duration) *common.BenchmarkResults {
+	// when the block size is not specified, the STE picks one based on the file size, in this same way
+	blockSize := int64(cca.blockSize)
+	if blockSize == 0 {
+		blockSize = common.DefaultBlockBlobBlockSize
+		for ; b.bucket.bytesPerFile/blockSize > common.MaxNumberOfBlocksPerBlob; blockSize = 2 * blockSize {
+		}
+	}
+	if blockSize > common.MaxBlockBlobBlockSize {
+		blockSize = common.MaxBlockBlobBlockSize
+	}
+
+	seconds := duration.Seconds()
+	megabitsPerSec := float64(0)
+	filesPerSec := float64(0)
+	if seconds > 0 {
+		megabitsPerSec = 8 * float64(summary.TotalBytesTransferred) / float64(base10Mega) / seconds
+		filesPerSec = float64(summary.TransfersCompleted) / seconds
+	}
+
+	// the tuner may settle on a different value for each part of the run, so we record what was in use as each part finished
+	concurrency := 0
+	if ste.JobsAdmin != nil {
+		concurrency = ste.JobsAdmin.CurrentMainPoolSize()
+	}
+
+	result := &common.BenchmarkResults{
+		Mode:              b.mode,
+		BlockSizeBytes:    blockSize,
+		Concurrency:       concurrency,
+		MegabitsPerSecond: ste.ToFixed(megabitsPerSec, 2),
+		FilesPerSecond:    ste.ToFixed(filesPerSec, 2),
+	}
+
+	run := b.run
+	run.buckets = append(run.buckets, common.BenchmarkBucketResults{
+		FileCount:         b.bucket.fileCount,
+		BytesPerFile:      b.bucket.bytesPerFile,
+		BlockSizeBytes:    blockSize,
+		Concurrency:       concurrency,
+		MegabitsPerSecond: result.MegabitsPerSecond,
+		FilesPerSecond:    result.FilesPerSecond,
+		ElapsedSeconds:    ste.ToFixed(seconds, 2),
+	})
+	if b.bucket.bytesPerFile <= blockSize {
+		run.smallFileCount += uint(summary.TransfersCompleted)
+		run.smallSeconds += seconds
+	} else {
+		run.bulkBytes += summary.TotalBytesTransferred
+		run.bulkSeconds += seconds
+	}
+
+	if b.isLast && len(run.buckets) > 1 {
+		result.Buckets = run.buckets
+		if run.smallSeconds > 0 {
+			result.SmallFilesPerSecond = ste.ToFixed(float64(run.smallFileCount)/run.smallSeconds, 2)
+		}
+		if run.bulkSeconds > 0 {
+			result.BulkMegabitsPerSecond = ste.ToFixed(8*float64(run.bulkBytes)/float64(base10Mega)/run.bulkSeconds, 2)
+		}
+	}
+
+	return result
+}
+
+// formats the benchmark results, and the request stats that go with them, for human-readable output
+func formatBenchmarkResults(summary common.ListJobSummaryResponse) string {
+	r := summary.BenchmarkResults
+	if r == nil {
+		return ""
+	}
+
+	codes := make([]int, 0, len(summary.RequestCountsByStatus))
+	for code := range summary.RequestCountsByStatus {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	counts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		counts = append(counts, fmt.Sprintf("%d: %d", code, summary.RequestCountsByStatus[code]))
+	}
+
+	output := fmt.Sprintf(
+		`
+
+Benchmark mode: %v
+Block size (MiB): %v
+Concurrency: %v
+Achieved throughput (Mb/s): %v
+Files per second: %v
+Requests by status code: %s
+Retry rate: %.2f%%`,
+		r.Mode,
+		ste.ToFixed(float64(r.BlockSizeBytes)/(1024*1024), 4),
+		r.Concurrency,
+		r.MegabitsPerSecond,
+		r.FilesPerSecond,
+		strings.Join(counts, ", "),
+		summary.RetryPercentage)
+
+	if len(r.Buckets) > 0 {
+		var sb strings.Builder
+		sb.WriteString("\n\nResults by file size (each size was measured separately, so the request counts above are just for the last one):")
+		for _, b := range r.Buckets {
+			sb.WriteString(fmt.Sprintf("\n  %v: %v Mb/s, %v files/s, concurrency %v, block size (MiB) %v",
+				benchmarkFileBucket{fileCount: b.FileCount, bytesPerFile: b.BytesPerFile},
+				b.MegabitsPerSecond,
+				b.FilesPerSecond,
+				b.Concurrency,
+				ste.ToFixed(float64(b.BlockSizeBytes)/(1024*1024), 4)))
+		}
+		sb.WriteString(fmt.Sprintf("\nSmall files (single block) per second: %v", r.SmallFilesPerSecond))
+		sb.WriteString(fmt.Sprintf("\nBulk throughput for larger files (Mb/s): %v", r.BulkMegabitsPerSecond))
+		output += sb.String()
+	}
+
+	return output
+}
+
 var benchCmd *cobra.Command
 
 func init() {
@@ -273,7 +611,7 @@ func init() {
 
 	// benCmd represents the bench command
 	benchCmd = &cobra.Command{
-		Use:        "bench [destination]",
+		Use:        "bench [source] [destination]",
 		Aliases:    []string{"ben", "benchmark"},
 		SuggestFor: []string{"b", "bn"},
 		Short:      benchCmdShortDescription,
@@ -281,9 +619,21 @@ func init() {
 		Example:    benchCmdExample,
 		Args: func(cmd *cobra.Command, args []string) error {
 
-			// TODO: if/when we support benchmarking for S2S, note that the current code to set userAgent string in
-			//   jobPartMgr will need to be changed if we want it to still set the benchmarking suffix for S2S
-			if len(args) == 1 {
+			// TODO: note that the current code to set userAgent string in jobPartMgr does not set the benchmarking suffix
+			//   for the download and S2S modes, since neither end of those jobs is the benchmark data generator
+			var mode common.BenchMarkMode
+			if err := mode.Parse(raw.mode); err != nil {
+				return fmt.Errorf("invalid %s '%s'. Valid modes are Upload, Download and S2S", common.BenchMarkModeParam, raw.mode)
+			}
+
+			if raw.sizeSpec != "" && (cmd.Flags().Changed(common.FileCountParam) || cmd.Flags().Changed(common.SizePerFileParam)) {
+				return fmt.Errorf("%s cannot be combined with %s or %s", common.SizeSpecParam, common.FileCountParam, common.SizePerFileParam)
+			}
+
+			if mode == common.EBenchMarkMode.S2S() && len(args) == 2 {
+				raw.s2sSrc = args[0]
+				raw.dst = args[1]
+			} else if mode != common.EBenchMarkMode.S2S() && len(args) == 1 {
 				raw.dst = args[0]
 			} else {
 				return errors.New("wrong number of arguments, please refer to the help page on usage of this command")
@@ -310,8 +660,13 @@ func init() {
 	}
 	rootCmd.AddCommand(benchCmd)
 
+	benchCmd.PersistentFlags().StringVar(&raw.mode, common.BenchMarkModeParam, common.EBenchMarkMode.Upload().String(), "what to measure. Upload measures uploads of auto-generated data to the destination. "+
+		"Download uploads the auto-generated data to the destination, then measures downloads of it (discarding the downloaded data, so that disk is not used). "+
+		"S2S uploads the auto-generated data to the source, then measures service-side copies of it to the destination. Available modes: Upload, Download and S2S")
 	benchCmd.PersistentFlags().StringVar(&raw.sizePerFile, common.SizePerFileParam, "250M", "size of each auto-generated data file. Must be "+sizeStringDescription)
 	benchCmd.PersistentFlags().UintVar(&raw.fileCount, common.FileCountParam, common.FileCountDefault, "number of auto-generated data files to use")
+	benchCmd.PersistentFlags().StringVar(&raw.sizeSpec, common.SizeSpecParam, "", "a mixture of auto-generated files to use instead of "+common.FileCountParam+" and "+common.SizePerFileParam+". "+
+		"A comma-separated list of count x size pairs, e.g. 100000x4KiB,5000x1MiB,50x2GiB. Each size is measured separately, and the results are reported for each size as well as for small and large files overall")
 	benchCmd.PersistentFlags().BoolVar(&raw.deleteTestData, "delete-test-data", true, "if true, the benchmark data will be deleted at the end of the benchmark run.  Set it to false if you want to keep the data at the destination - e.g. to use it for manual tests outside benchmark mode")
 
 	benchCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0, "use this block size (specified in MiB). Default is automatically calculated based on file size. Decimal fractions are allowed - e.g. 0.25. Identical to the same-named parameter in the copy command")
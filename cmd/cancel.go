@@ -91,7 +91,10 @@ func init() {
 				glcm.Error("failed to perform copy command due to error " + err.Error())
 			}
 
-			glcm.Exit(nil, common.EExitCode.Success())
+			glcm.Exit(func(format common.OutputFormat) string {
+				return fmt.Sprintf("What the transfers in progress leave at their destinations can be removed, once the job is cancelled, "+
+					"with: azcopy jobs clean --job=%s --scrub-destination", cooked.jobID)
+			}, common.EExitCode.Success())
 		},
 		// hide features not relevant to BFS
 		// TODO remove after preview release.
@@ -0,0 +1,303 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+type rawCompareCmdArgs struct {
+	src       string
+	dst       string
+	recursive bool
+	// options from flags
+	include               string
+	exclude               string
+	excludePath           string
+	includePathPattern    string
+	excludePathPattern    string
+	includeFileAttributes string
+	excludeFileAttributes string
+
+	compareHash bool
+	reportFile  string
+}
+
+func (raw *rawCompareCmdArgs) parsePatterns(pattern string) (cookedPatterns []string) {
+	cookedPatterns = make([]string, 0)
+	rawPatterns := strings.Split(pattern, ";")
+	for _, pattern := range rawPatterns {
+
+		// skip the empty patterns
+		if len(pattern) != 0 {
+			cookedPatterns = append(cookedPatterns, pattern)
+		}
+	}
+
+	return
+}
+
+// cookLocation splits the SAS from a remote resource, or cleans a local path, and makes sure that it's something compare can list
+func (raw *rawCompareCmdArgs) cookLocation(resource string, which string) (cooked string, sas string, location common.Location, err error) {
+	location = inferArgumentLocation(resource)
+	switch location {
+	case common.ELocation.Local():
+		return common.ToExtendedPath(cleanLocalPath(resource)), "", location, nil
+	case common.ELocation.Blob(), common.ELocation.File():
+		cooked, sas, err = SplitAuthTokenFromResource(resource, location)
+		if err != nil {
+			return
+		}
+		var level LocationLevel
+		if level, err = determineLocationLevel(cooked, location, which == "source"); err != nil {
+			return "", "", location, err
+		}
+		if level == ELocationLevel.Service() {
+			return "", "", location, fmt.Errorf("service level URLs (%s) are not supported in compare", cooked)
+		}
+		return cooked, sas, location, nil
+	default:
+		return "", "", location, fmt.Errorf("the %s '%s' is not a local path, or a URL of Azure Blob or Azure Files, which are what compare supports",
+			which, common.URLStringExtension(resource).RedactSecretQueryParamForLogging())
+	}
+}
+
+// validates and transform raw input into cooked input
+func (raw *rawCompareCmdArgs) cook() (cookedCompareCmdArgs, error) {
+	cooked := cookedCompareCmdArgs{}
+
+	// the SDKs only find the account name in the path of an emulator's URL if its host is an IP address
+	raw.src = common.ReplaceLocalhostWithLoopbackIP(raw.src)
+	raw.dst = common.ReplaceLocalhostWithLoopbackIP(raw.dst)
+
+	var err error
+	cooked.source, cooked.sourceSAS, cooked.srcLocation, err = raw.cookLocation(raw.src, "source")
+	if err != nil {
+		return cooked, err
+	}
+	cooked.destination, cooked.destinationSAS, cooked.dstLocation, err = raw.cookLocation(raw.dst, "destination")
+	if err != nil {
+		return cooked, err
+	}
+
+	cooked.recursive = raw.recursive
+	cooked.compareHash = raw.compareHash
+
+	// parse the filter patterns
+	cooked.includePatterns = raw.parsePatterns(raw.include)
+	cooked.excludePatterns = raw.parsePatterns(raw.exclude)
+	cooked.excludePaths = raw.parsePatterns(raw.excludePath)
+	if cooked.includePathGlobs, err = normalizePathPatterns("include-path-pattern", raw.parsePatterns(raw.includePathPattern)); err != nil {
+		return cooked, err
+	}
+	if cooked.excludePathGlobs, err = normalizePathPatterns("exclude-path-pattern", raw.parsePatterns(raw.excludePathPattern)); err != nil {
+		return cooked, err
+	}
+
+	// parse the attribute filter patterns
+	cooked.includeFileAttributes = raw.parsePatterns(raw.includeFileAttributes)
+	cooked.excludeFileAttributes = raw.parsePatterns(raw.excludeFileAttributes)
+
+	if raw.reportFile != "" {
+		cooked.reportFile, err = filepath.Abs(raw.reportFile)
+		if err != nil {
+			return cooked, fmt.Errorf("invalid report-file %s: %s", raw.reportFile, err.Error())
+		}
+		f, err := os.Create(cooked.reportFile)
+		if err != nil {
+			return cooked, fmt.Errorf("cannot create the file %s passed with the report-file flag: %s", raw.reportFile, err.Error())
+		}
+		_ = f.Close()
+	}
+
+	return cooked, nil
+}
+
+type cookedCompareCmdArgs struct {
+	// NOTE: for the 64 bit atomic functions to work on a 32 bit system, we have to guarantee the right 64-bit alignment
+	// so the 64 bit integers are placed first in the struct to avoid future breaks
+	// refer to: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	// defines the number of files listed at the source and compared.
+	atomicSourceFilesScanned uint64
+	// defines the number of files listed at the destination and compared.
+	atomicDestinationFilesScanned uint64
+
+	source         string
+	sourceSAS      string
+	srcLocation    common.Location
+	destination    string
+	destinationSAS string
+	dstLocation    common.Location
+
+	// filters
+	recursive             bool
+	includePatterns       []string
+	excludePatterns       []string
+	excludePaths          []string
+	includePathGlobs      []string
+	excludePathGlobs      []string
+	includeFileAttributes []string
+	excludeFileAttributes []string
+
+	// whether files of the same size are compared by their MD5 hashes, rather than by their last modified times
+	compareHash bool
+	// absolute path of the file that the differences are written to, or empty if they are shown on the screen
+	reportFile string
+}
+
+// compareSummary holds the totals of a comparison
+type compareSummary struct {
+	FilesScannedAtSource      uint64
+	FilesScannedAtDestination uint64
+	FilesOnlyAtSource         uint64
+	FilesOnlyAtDestination    uint64
+	FilesThatDiffer           uint64
+	IdenticalFiles            uint64
+	ReportFile                string `json:",omitempty"`
+}
+
+func (s *compareSummary) differenceCount() uint64 {
+	return s.FilesOnlyAtSource + s.FilesOnlyAtDestination + s.FilesThatDiffer
+}
+
+func (s *compareSummary) output(format common.OutputFormat) string {
+	if format == common.EOutputFormat.Json() {
+		jsonOutput, err := json.Marshal(s)
+		common.PanicIfErr(err)
+		return string(jsonOutput)
+	}
+
+	reportFile := ""
+	if s.ReportFile != "" {
+		reportFile = "\nDifferences Written To: " + s.ReportFile
+	}
+	verdict := "The source and destination are the same."
+	if s.differenceCount() > 0 {
+		verdict = fmt.Sprintf("The source and destination have %v differences.", s.differenceCount())
+	}
+	return fmt.Sprintf(
+		`
+Compare Summary
+Files Scanned at Source: %v
+Files Scanned at Destination: %v
+Files Only at Source: %v
+Files Only at Destination: %v
+Files That Differ: %v
+Identical Files: %v%s
+
+%s
+`,
+		s.FilesScannedAtSource,
+		s.FilesScannedAtDestination,
+		s.FilesOnlyAtSource,
+		s.FilesOnlyAtDestination,
+		s.FilesThatDiffer,
+		s.IdenticalFiles,
+		reportFile,
+		verdict)
+}
+
+func (cca *cookedCompareCmdArgs) process() (err error) {
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+
+	// unlike sync, nothing is transferred, so each side only needs the credential that lists it
+	srcCredInfo, _, err := getCredentialInfoForLocation(ctx, cca.srcLocation, cca.source, cca.sourceSAS, true)
+	if err != nil {
+		return err
+	}
+	dstCredInfo, _, err := getCredentialInfoForLocation(ctx, cca.dstLocation, cca.destination, cca.destinationSAS, false)
+	if err != nil {
+		return err
+	}
+	if srcCredInfo.CredentialType == common.ECredentialType.OAuthToken() || dstCredInfo.CredentialType == common.ECredentialType.OAuthToken() {
+		glcm.Info("Using OAuth token for authentication.")
+	}
+
+	summary, err := cca.compare(ctx, srcCredInfo, dstCredInfo)
+	if err != nil {
+		return err
+	}
+
+	// a nonzero exit code lets scripts and pipelines tell that the trees are not the same
+	exitCode := common.EExitCode.Success()
+	if summary.differenceCount() > 0 {
+		exitCode = common.EExitCode.Error()
+	}
+	glcm.Exit(summary.output, exitCode)
+	return nil
+}
+
+func init() {
+	raw := rawCompareCmdArgs{}
+	// compareCmd represents the compare command
+	var compareCmd = &cobra.Command{
+		Use:     "compare",
+		Aliases: []string{"diff"},
+		Short:   compareCmdShortDescription,
+		Long:    compareCmdLongDescription,
+		Example: compareCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("2 arguments source and destination are required for this command. Number of commands passed %d", len(args))
+			}
+			raw.src = args[0]
+			raw.dst = args[1]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("error parsing the input given by the user. Failed with error " + err.Error())
+			}
+			err = cooked.process()
+			if err != nil {
+				glcm.Error("Cannot perform compare due to error: " + err.Error())
+			}
+		},
+	}
+
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", true, "True by default, look into sub-directories recursively when comparing directories. (default true).")
+	compareCmd.PersistentFlags().StringVar(&raw.include, "include-pattern", "", "Include only files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
+	compareCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
+	compareCmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when comparing the source against the destination. "+
+		"This option does not support wildcard characters (*). Checks relative path prefix(For example: myFolder;myFolder/subDirName/file.pdf).")
+	compareCmd.PersistentFlags().StringVar(&raw.includePathPattern, "include-path-pattern", "", "Include only the files whose relative path matches these patterns (For example: logs/2023/*;**/*.csv). "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Separate the patterns by using a ';'.")
+	compareCmd.PersistentFlags().StringVar(&raw.excludePathPattern, "exclude-path-pattern", "", "Exclude the files whose relative path matches these patterns (For example: **/temp/**;*.tmp). "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Exclusions win over inclusions.")
+	compareCmd.PersistentFlags().StringVar(&raw.includeFileAttributes, "include-attributes", "", "(Windows only) Include only files whose attributes match the attribute list. For example: A;S;R")
+	compareCmd.PersistentFlags().StringVar(&raw.excludeFileAttributes, "exclude-attributes", "", "(Windows only) Exclude files whose attributes match the attribute list. For example: A;S;R")
+	compareCmd.PersistentFlags().BoolVar(&raw.compareHash, "compare-hash", false, "Compare the MD5 hashes of files of the same size, rather than their last modified times. "+
+		"Local files are read to compute their hashes, and remote files without a Content-MD5 are reported as different, since they can't be checked.")
+	compareCmd.PersistentFlags().StringVar(&raw.reportFile, "report-file", "", "Write the differences to this file, rather than showing them on the screen. "+
+		"The file is CSV, unless its name ends in .json or .ndjson, in which case it has one JSON object per line.")
+}
@@ -0,0 +1,346 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/JeffreyRichter/enum/enum"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+var ECompareResult = CompareResult(0)
+
+// CompareResult is what compare found for a relative path
+type CompareResult uint8
+
+func (CompareResult) OnlyAtSource() CompareResult      { return CompareResult(0) }
+func (CompareResult) OnlyAtDestination() CompareResult { return CompareResult(1) }
+func (CompareResult) Different() CompareResult         { return CompareResult(2) }
+
+func (r CompareResult) String() string {
+	return enum.StringInt(r, reflect.TypeOf(r))
+}
+
+// the reasons why files at both sides are different
+const (
+	compareReasonSize             = "Size"
+	compareReasonLastModifiedTime = "LastModifiedTime" // the source is more recent, so sync would transfer it
+	compareReasonMD5              = "MD5"
+	compareReasonMD5Missing       = "MD5Missing" // one side has no hash, so they can't be compared
+)
+
+// compareRecord is one difference, i.e. a line of the report file
+type compareRecord struct {
+	Path                    string `json:"path"`
+	Result                  string `json:"result"`
+	Reason                  string `json:"reason,omitempty"`
+	SourceSize              *int64 `json:"sourceSize,omitempty"`
+	DestinationSize         *int64 `json:"destinationSize,omitempty"`
+	SourceLastModified      string `json:"sourceLastModified,omitempty"`
+	DestinationLastModified string `json:"destinationLastModified,omitempty"`
+}
+
+var compareCsvHeader = []string{"Path", "Result", "Reason", "SourceSize", "DestinationSize", "SourceLastModified", "DestinationLastModified"}
+
+func newCompareRecord(relativePath string, result CompareResult, reason string, src, dst *storedObject) compareRecord {
+	r := compareRecord{Path: relativePath, Result: result.String(), Reason: reason}
+	if src != nil {
+		r.SourceSize = &src.size
+		r.SourceLastModified = src.lastModifiedTime.UTC().Format(time.RFC3339)
+	}
+	if dst != nil {
+		r.DestinationSize = &dst.size
+		r.DestinationLastModified = dst.lastModifiedTime.UTC().Format(time.RFC3339)
+	}
+	return r
+}
+
+func (r compareRecord) csvFields() []string {
+	size := func(s *int64) string {
+		if s == nil {
+			return ""
+		}
+		return strconv.FormatInt(*s, 10)
+	}
+	return []string{r.Path, r.Result, r.Reason, size(r.SourceSize), size(r.DestinationSize), r.SourceLastModified, r.DestinationLastModified}
+}
+
+func (r compareRecord) String() string {
+	if r.Reason != "" {
+		return fmt.Sprintf("%s: %s (%s)", r.Result, r.Path, r.Reason)
+	}
+	return fmt.Sprintf("%s: %s", r.Result, r.Path)
+}
+
+// compareReporter writes each difference, as it's found, to the report file, or shows it on the screen if there is none.
+// Nothing is kept per difference, so the report can be as large as the trees.
+type compareReporter struct {
+	summary *compareSummary
+
+	f      *os.File
+	bw     *bufio.Writer
+	cw     *csv.Writer
+	enc    *json.Encoder
+	asJson bool
+}
+
+func newCompareReporter(reportFile string, summary *compareSummary) (*compareReporter, error) {
+	r := &compareReporter{summary: summary}
+	if reportFile == "" {
+		return r, nil
+	}
+
+	f, err := os.OpenFile(reportFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(reportFile))
+	r.f, r.bw, r.asJson = f, bufio.NewWriter(f), ext == ".json" || ext == ".ndjson"
+	r.cw, r.enc = csv.NewWriter(r.bw), json.NewEncoder(r.bw)
+	summary.ReportFile = reportFile
+	if !r.asJson {
+		err = r.cw.Write(compareCsvHeader)
+	}
+	return r, err
+}
+
+func (r *compareReporter) report(rec compareRecord) error {
+	switch rec.Result {
+	case ECompareResult.OnlyAtSource().String():
+		r.summary.FilesOnlyAtSource++
+	case ECompareResult.OnlyAtDestination().String():
+		r.summary.FilesOnlyAtDestination++
+	default:
+		r.summary.FilesThatDiffer++
+	}
+
+	switch {
+	case r.f == nil:
+		glcm.Info(rec.String())
+		return nil
+	case r.asJson:
+		return r.enc.Encode(rec) // adds the newline
+	default:
+		return r.cw.Write(rec.csvFields())
+	}
+}
+
+func (r *compareReporter) close() error {
+	if r.f == nil {
+		return nil
+	}
+	r.cw.Flush()
+	err := r.bw.Flush()
+	if closeErr := r.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// with the help of an objectIndexer containing the objects of one side, compareComparator reports the differences
+// of the objects at the other side, as the other side is enumerated. Which side is indexed is up to the enumerator.
+type compareComparator struct {
+	cca      *cookedCompareCmdArgs
+	index    *objectIndexer
+	reporter *compareReporter
+
+	// whether the source, rather than the destination, was enumerated first
+	indexedIsSource bool
+}
+
+func (f *compareComparator) processIfNecessary(object storedObject) error {
+	indexed, present := f.index.indexMap[object.relativePath]
+	if !present {
+		if f.indexedIsSource {
+			return f.reporter.report(newCompareRecord(object.relativePath, ECompareResult.OnlyAtDestination(), "", nil, &object))
+		}
+		return f.reporter.report(newCompareRecord(object.relativePath, ECompareResult.OnlyAtSource(), "", &object, nil))
+	}
+	// what is left in the index at the end is only at the indexed side
+	delete(f.index.indexMap, object.relativePath)
+
+	src, dst := object, indexed
+	if f.indexedIsSource {
+		src, dst = indexed, object
+	}
+	reason, err := f.cca.difference(src, dst)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		f.reporter.summary.IdenticalFiles++
+		return nil
+	}
+	return f.reporter.report(newCompareRecord(object.relativePath, ECompareResult.Different(), reason, &src, &dst))
+}
+
+// reportRemaining reports the objects that are still in the index once the other side has been enumerated
+func (f *compareComparator) reportRemaining(object storedObject) error {
+	if f.indexedIsSource {
+		return f.reporter.report(newCompareRecord(object.relativePath, ECompareResult.OnlyAtSource(), "", &object, nil))
+	}
+	return f.reporter.report(newCompareRecord(object.relativePath, ECompareResult.OnlyAtDestination(), "", nil, &object))
+}
+
+// difference returns why the files differ, or empty if they are the same. Without compare-hash, the rule is the one that sync uses,
+// i.e. files of the same size differ if the source is more recent, so that compare reports what sync would transfer.
+func (cca *cookedCompareCmdArgs) difference(src, dst storedObject) (string, error) {
+	if src.size != dst.size {
+		return compareReasonSize, nil
+	}
+	if !cca.compareHash {
+		if src.isMoreRecentThan(dst) {
+			return compareReasonLastModifiedTime, nil
+		}
+		return "", nil
+	}
+
+	srcMd5, err := cca.md5Of(src, cca.source, cca.srcLocation)
+	if err != nil {
+		return "", err
+	}
+	dstMd5, err := cca.md5Of(dst, cca.destination, cca.dstLocation)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(srcMd5) == 0 || len(dstMd5) == 0:
+		return compareReasonMD5Missing, nil
+	case !bytes.Equal(srcMd5, dstMd5):
+		return compareReasonMD5, nil
+	default:
+		return "", nil
+	}
+}
+
+// md5Of returns the Content-MD5 of a remote object, or for a local file, reads the file to work it out
+func (cca *cookedCompareCmdArgs) md5Of(object storedObject, root string, location common.Location) ([]byte, error) {
+	if location != common.ELocation.Local() {
+		return object.md5, nil
+	}
+
+	path := root
+	if object.relativePath != "" {
+		path = filepath.Join(root, filepath.FromSlash(object.relativePath))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// compare enumerates both sides with the enumerator of sync. Like sync, it holds the objects of the side
+// that is enumerated first in memory, and that's the local side, if any, since it's assumed to be faster to enumerate.
+func (cca *cookedCompareCmdArgs) compare(ctx context.Context, srcCredInfo, dstCredInfo common.CredentialInfo) (*compareSummary, error) {
+	src, err := appendSASIfNecessary(cca.source, cca.sourceSAS)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := appendSASIfNecessary(cca.destination, cca.destinationSAS)
+	if err != nil {
+		return nil, err
+	}
+
+	// GetProperties is enabled, so that the files of Azure Files have their hashes
+	sourceTraverser, err := initResourceTraverser(src, cca.srcLocation, &ctx, &srcCredInfo,
+		nil, nil, cca.recursive, true, func() {
+			atomic.AddUint64(&cca.atomicSourceFilesScanned, 1)
+		}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	destinationTraverser, err := initResourceTraverser(dst, cca.dstLocation, &ctx, &dstCredInfo,
+		nil, nil, cca.recursive, true, func() {
+			atomic.AddUint64(&cca.atomicDestinationFilesScanned, 1)
+		}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// verify that the traversers are targeting the same type of resources
+	if sourceTraverser.isDirectory(true) != destinationTraverser.isDirectory(true) {
+		return nil, errors.New("compare must happen between source and destination of the same type, e.g. either file <-> file, or directory/container <-> directory/container")
+	}
+
+	// set up the filters in the right order, as sync does
+	filters := buildIncludeFilters(cca.includePatterns)
+	if cca.srcLocation == common.ELocation.Local() {
+		filters = append(filters, buildAttrFilters(cca.includeFileAttributes, src, true)...)
+	}
+	filters = append(filters, buildExcludeFilters(cca.excludePatterns, false)...)
+	filters = append(filters, buildExcludeFilters(cca.excludePaths, true)...)
+	filters = append(filters, buildPathPatternFilters(cca.includePathGlobs, cca.excludePathGlobs)...)
+	if cca.srcLocation == common.ELocation.Local() {
+		filters = append(filters, buildAttrFilters(cca.excludeFileAttributes, src, false)...)
+	}
+
+	summary := &compareSummary{}
+	reporter, err := newCompareReporter(cca.reportFile, summary)
+	if err != nil {
+		return nil, err
+	}
+
+	indexer := newObjectIndexer()
+	comparator := &compareComparator{cca: cca, index: indexer, reporter: reporter,
+		indexedIsSource: cca.srcLocation == common.ELocation.Local()}
+	primary, secondary := destinationTraverser, sourceTraverser
+	if comparator.indexedIsSource {
+		primary, secondary = sourceTraverser, destinationTraverser
+	}
+
+	finalize := func() error {
+		return indexer.traverse(comparator.reportRemaining, nil)
+	}
+	err = newSyncEnumerator(primary, secondary, indexer, filters, comparator.processIfNecessary, finalize).enumerate()
+	if closeErr := reporter.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	summary.FilesScannedAtSource = atomic.LoadUint64(&cca.atomicSourceFilesScanned)
+	summary.FilesScannedAtDestination = atomic.LoadUint64(&cca.atomicDestinationFilesScanned)
+	return summary, nil
+}
@@ -21,7 +21,6 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
@@ -30,6 +29,7 @@ import (
 	"math"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -72,16 +72,28 @@ type rawCopyCmdArgs struct {
 	exclude               string
 	includePath           string // NOTE: This gets handled like list-of-files! It may LOOK like a bug, but it is not.
 	excludePath           string
+	includePathPattern    string
+	excludePathPattern    string
+	prunePattern          string
+	includeContainer      string
+	excludeContainer      string
+	maxDepth              int
 	includeFileAttributes string
 	excludeFileAttributes string
+	excludeDotfiles       bool
 	legacyInclude         string // used only for warnings
 	legacyExclude         string // used only for warnings
 
 	// filters from flags
 	listOfFilesToCopy string
+	// how the entries of the list of files are written, and whether a malformed one fails the job rather than being skipped
+	listOfFilesFormat string
+	listOfFilesStrict bool
 	recursive         bool
-	followSymlinks    bool
-	autoDecompress    bool
+	// whether a directory source goes under the destination as a subdirectory of its own name, rather than only its contents
+	asSubdir       bool
+	followSymlinks bool
+	autoDecompress bool
 	// forceWrite flag is used to define the User behavior
 	// to overwrite the existing blobs or not.
 	forceWrite string
@@ -100,6 +112,8 @@ type rawCopyCmdArgs struct {
 	md5ValidationOption      string
 	CheckLength              bool
 	deleteSnapshotsOption    string
+	// index tags to apply, only used by set-properties
+	blobTags string
 	// defines the type of the blob at the destination in case of upload / account to account copy
 	blobType      string
 	blockBlobTier string
@@ -124,6 +138,118 @@ type rawCopyCmdArgs struct {
 	s2sSourceChangeValidation bool
 	// specify how user wants to handle invalid metadata.
 	s2sInvalidMetadataHandleOption string
+	// specify what to do when the destination can't read the source.
+	s2sFallback string
+	// which destination block blobs of a S2S copy have their Content-MD5 computed after they're written
+	s2sComputeMD5 string
+	// the order in which the transfers are scheduled
+	transferOrder string
+	// when the downloaded files are flushed to stable storage, and every how many MB, if they're flushed as they're written
+	flushPolicy     string
+	flushIntervalMB uint32
+	// the snapshot of the source page blob that the destination already holds, if only the changes since it are to be copied
+	diffBaseSnapshot string
+	// whether to keep the blocks that an earlier upload of the same source staged, but didn't commit
+	reuseUncommittedBlocks bool
+	// whether to delete the source of each transfer once it has succeeded, which moves the files rather than copying them
+	deleteSourceAfterTransfer bool
+	// whether to skip the check that the permissions of the SASs allow what the job will do
+	ignoreSASValidation bool
+	// whether the features that the destination turns out not to support are disabled with a warning, rather than fail the job
+	bestEffortFeatures bool
+	// whether s2s-preserve-access-tier was given, rather than left to its default. Only then is the destination probed for access tiers
+	s2sPreserveAccessTierRequested bool
+	// whether to clear the archive attribute of each local file once it's uploaded
+	clearArchiveBit bool
+	// whether to leave a missing destination container, share or file system missing, rather than create it
+	noCreateDstContainer bool
+	// whether to break the leases of the destination blobs that can't be overwritten or removed because they're leased
+	breakLeaseOnOverwrite bool
+	// whether to lease each destination while it's written, so that nothing else can write to it at the same time
+	protectDestinationWithLease bool
+	// the condition that each write of a whole destination must meet, e.g. if-none-match=*
+	destinationCondition string
+	// whether to clear the ReadOnly attribute of the destination Azure files that have it, to overwrite them
+	forceIfReadOnly bool
+	// how long to retry the writes to the destination Azure files that something else has open over SMB
+	retryOnSharingViolation time.Duration
+	// how long the transfers to a destination Azure file share that's full wait for its quota to be raised
+	waitOnShareFull time.Duration
+	// what to do with the transfers whose source was deleted after it was enumerated
+	missingSourceHandling string
+	// what to do with the transfers whose destination blob is soft-deleted
+	softDeletedDestination string
+	// whether to keep the last modified time of each source in the metadata of its destination
+	preserveSourceLMTAsMetadata bool
+	// the suffix of the temporary names that ADLS Gen2 and Azure Files destinations are written under, or empty to write them under their own
+	tempNameSuffix string
+	// whether to skip the paths that can't be enumerated, rather than fail the job
+	continueOnEnumerationErrors bool
+	// what to do with the objects whose names the destination doesn't allow
+	invalidNameHandling string
+	// where to write the paths of the failed transfers, as a list of files, once the job is done
+	failedFilesOutput string
+	// whether to skip the local files that can't be read for lack of permission, rather than fail them
+	skipPermissionErrors bool
+	// whether to skip the local files that another process holds open, rather than fail them
+	skipLockedFiles bool
+	// whether to read the local source from a Volume Shadow Copy of its volume, on Windows
+	useVss bool
+	// the JSON file that gives uploaded files their own content headers and metadata
+	attributesManifest string
+	// where each object goes under the destination, e.g. {year}/{month}/{day}/{filename}, rather than at its relative path
+	destinationTemplate string
+	// the containers that the destination is sharded across, with their SAS, separated by ';'
+	destinationShards string
+	// the rules that set the content headers of the uploaded files that their patterns match, in the order they are matched
+	headerRules []string
+	// whether estimate-only lists the header rule that matched each file
+	printHeaderRules bool
+	// the job template whose flags are the defaults of this job, those of the command line overriding them
+	fromTemplate string
+
+	// where to write the timings of each transfer, if anywhere
+	metricsFile string
+	// where to serve the metrics of the job for Prometheus, if anywhere
+	metricsListen string
+
+	// when to abort the job, since too many of its transfers failed
+	failFastThreshold uint32
+	failFastRate      string
+
+	// what to run once the job reaches a terminal state, and whether to raise a desktop notification then
+	onCompleteExec string
+	notify         bool
+
+	// whether to only add up what would be transferred, without transferring it
+	estimateOnly bool
+	pricePerGB   float64
+	// the most storage transactions that the job may make, or zero if there's no limit
+	maxTransactions uint64
+	// what to do when the destination volume has less free space than a download needs
+	lowSpaceAction string
+	// the file that keeps what was copied, so that the next run skips what's unchanged, and whether the next run is to check everything anyway
+	enumerationCache string
+	noCacheTrust     bool
+
+	// whether to download without saving anything, e.g. to check the hashes or measure throughput
+	discard bool
+
+	// whether to list the soft-deleted blobs too, and undelete those that are copied
+	includeDeleted bool
+	// whether to only undelete the soft-deleted blobs, without copying anything
+	restoreInPlace bool
+	// whether to undelete a soft-deleted blob even though there's a live blob of the same name
+	preferDeletedVersion bool
+
+	// the window of the source file to download, if not all of it. A length of zero is up to the end of the file
+	offset int64
+	length int64
+
+	// where to write the checksum of each file that is transferred, if anywhere
+	generateChecksumFile string
+	// the checksum file to verify the downloaded files against, if any
+	verifyChecksumFile string
 
 	// internal override to enforce strip-top-dir
 	internalOverrideStripTopDir bool
@@ -227,8 +353,32 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 
 	cooked := cookedCopyCmdArgs{
 		jobID: jobId,
+		perf:  &jobPerformanceTracker{},
 	}
 
+	if raw.verifyChecksumFile != "" && raw.dst == "" {
+		// verifying a tree doesn't need a copy of it
+		raw.discard = true
+	}
+	if raw.restoreInPlace {
+		// the blobs are restored where they are, so there's nothing to copy them to
+		if raw.dst != "" {
+			return cooked, errors.New("a destination cannot be given with restore-in-place, since nothing is copied")
+		}
+		raw.dst = common.Dev_Null
+	}
+	if raw.discard {
+		// the data goes to the null device, which is handled as a destination that saves nothing
+		if raw.dst != "" && !strings.EqualFold(raw.dst, common.Dev_Null) {
+			return cooked, errors.New("a destination cannot be given with discard, since nothing is saved")
+		}
+		raw.dst = common.Dev_Null
+	}
+
+	// the SDKs only find the account name in the path of an emulator's URL if its host is an IP address
+	raw.src = common.ReplaceLocalhostWithLoopbackIP(raw.src)
+	raw.dst = common.ReplaceLocalhostWithLoopbackIP(raw.dst)
+
 	fromTo, err := validateFromTo(raw.src, raw.dst, raw.fromTo) // TODO: src/dst
 	if err != nil {
 		return cooked, err
@@ -241,6 +391,9 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 	}
 
 	cooked.fromTo = fromTo
+	if raw.discard && !cooked.fromTo.IsDownload() {
+		return cooked, errors.New("discard is only supported while downloading")
+	}
 
 	// Check if source has a trailing wildcard on a URL
 	if fromTo.From().IsRemote() {
@@ -256,6 +409,7 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 	}
 
 	cooked.recursive = raw.recursive
+	cooked.asSubdir = raw.asSubdir
 	cooked.followSymlinks = raw.followSymlinks
 
 	// copy&transform flags to type-safety
@@ -322,67 +476,46 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 	// warn on exclude unsupported wildcards here. Include have to be later, to cover list-of-files
 	raw.warnIfHasWildcard(excludeWarningOncer, "exclude-path", raw.excludePath)
 
+	var listOfFilesFormat common.ListOfFilesFormat
+	if raw.listOfFilesFormat != "" {
+		if err = listOfFilesFormat.Parse(raw.listOfFilesFormat); err != nil {
+			return cooked, fmt.Errorf("invalid value for list-of-files-format: %s. The choices include: text, ndjson", err.Error())
+		}
+	}
+	if raw.listOfFilesToCopy == listOfFilesStdin && (cooked.fromTo.From() == common.ELocation.Pipe() || cancelFromStdin) {
+		return cooked, errors.New("the list of files cannot be read from stdin when stdin is also the source, or carries the cancellation")
+	}
+
 	// unbuffered so this reads as we need it to rather than all at once in bulk
-	listChan := make(chan string)
-	var f *os.File
+	listChan := make(chan listOfFilesEntry)
+	var f io.ReadCloser
 
 	if raw.listOfFilesToCopy != "" {
-		f, err = os.Open(raw.listOfFilesToCopy)
+		f, err = openListOfFiles(raw.listOfFilesToCopy)
 
 		if err != nil {
 			return cooked, fmt.Errorf("cannot open %s file passed with the list-of-file flag", raw.listOfFilesToCopy)
 		}
 	}
 
-	// Prepare UTF-8 byte order marker
-	utf8BOM := string([]byte{0xEF, 0xBB, 0xBF})
-
 	go func() {
 		defer close(listChan)
 
-		addToChannel := func(v string, paramName string) {
+		addToChannel := func(entry listOfFilesEntry, paramName string) {
 			// empty strings should be ignored, otherwise the source root itself is selected
-			if len(v) > 0 {
-				raw.warnIfHasWildcard(includeWarningOncer, paramName, v)
-				listChan <- v
+			if len(entry.Source) > 0 {
+				raw.warnIfHasWildcard(includeWarningOncer, paramName, entry.Source)
+				listChan <- entry
 			}
 		}
 
 		if f != nil {
-			scanner := bufio.NewScanner(f)
-			checkBOM := false
-			headerLineNum := 0
-			firstLineIsCurlyBrace := false
-
-			for scanner.Scan() {
-				v := scanner.Text()
-
-				// Check if the UTF-8 BOM is on the first line and remove it if necessary.
-				// Note that the UTF-8 BOM can be present on the same line feed as the first line of actual data, so just use TrimPrefix.
-				// If the line feed were separate, the empty string would be skipped later.
-				if !checkBOM {
-					v = strings.TrimPrefix(v, utf8BOM)
-					checkBOM = true
-				}
-
-				// provide clear warning if user uses old (obsolete) format by mistake
-				if headerLineNum <= 1 {
-					cleanedLine := strings.Replace(strings.Replace(v, " ", "", -1), "\t", "", -1)
-					cleanedLine = strings.TrimSuffix(cleanedLine, "[") // don't care which line this is on, could be third line
-					if cleanedLine == "{" && headerLineNum == 0 {
-						firstLineIsCurlyBrace = true
-					} else {
-						const jsonStart = "{\"Files\":"
-						jsonStartNoBrace := strings.TrimPrefix(jsonStart, "{")
-						isJson := cleanedLine == jsonStart || firstLineIsCurlyBrace && cleanedLine == jsonStartNoBrace
-						if isJson {
-							glcm.Error("The format for list-of-files has changed. The old JSON format is no longer supported")
-						}
-					}
-					headerLineNum++
-				}
-
-				addToChannel(v, "list-of-files")
+			defer f.Close()
+			err := readListOfFiles(f, listOfFilesFormat, raw.listOfFilesStrict, func(entry listOfFilesEntry) { addToChannel(entry, "list-of-files") })
+			if _, malformed := err.(malformedListOfFilesLineError); malformed {
+				glcm.Error(fmt.Sprintf("Cannot read the list of files %s, since %s", raw.listOfFilesToCopy, err))
+			} else if err != nil {
+				glcm.Info(fmt.Sprintf("Failed to read %s, so the rest of the files it lists are left out: %s", raw.listOfFilesToCopy, err))
 			}
 		}
 
@@ -390,7 +523,7 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 		includePathList := raw.parsePatterns(raw.includePath)
 
 		for _, v := range includePathList {
-			addToChannel(v, "include-path")
+			addToChannel(listOfFilesEntry{Source: v}, "include-path")
 		}
 	}()
 
@@ -430,11 +563,8 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 	}
 	globalBlobFSMd5ValidationOption = cooked.md5ValidationOption // workaround, to avoid having to pass this all the way through the chain of methods in enumeration, just for one weird and (presumably) temporary workaround
 
+	// when the data is discarded, the length of what would have been saved is checked
 	cooked.CheckLength = raw.CheckLength
-	// length of devnull will be 0, thus this will always fail unless downloading an empty file
-	if cooked.destination == common.Dev_Null {
-		cooked.CheckLength = false
-	}
 
 	// if redirection is triggered, avoid printing any output
 	if cooked.isRedirection() {
@@ -562,6 +692,23 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 		if len(cooked.contentType) > 0 || len(cooked.contentEncoding) > 0 || len(cooked.contentLanguage) > 0 || len(cooked.contentDisposition) > 0 || len(cooked.cacheControl) > 0 || len(cooked.metadata) > 0 {
 			return cooked, fmt.Errorf("content-type, content-encoding, content-language, content-disposition, cache-control, or metadata is not supported while copying from service to service")
 		}
+	case common.EFromTo.BlobNone():
+		if cooked.blockBlobTier != common.EBlockBlobTier.None() || cooked.pageBlobTier != common.EPageBlobTier.None() {
+			cooked.propertiesToTransfer |= common.ESetPropertiesFlags.SetTier()
+		}
+		if len(cooked.metadata) > 0 {
+			cooked.propertiesToTransfer |= common.ESetPropertiesFlags.SetMetadata()
+		}
+		cooked.blobTags, err = common.ToCommonBlobTags(raw.blobTags)
+		if err != nil {
+			return cooked, err
+		}
+		if len(cooked.blobTags) > 0 && featureAvailable(ste.FeatureBlobTags) {
+			cooked.propertiesToTransfer |= common.ESetPropertiesFlags.SetBlobTags()
+		}
+		if cooked.propertiesToTransfer == common.ESetPropertiesFlags.None() {
+			return cooked, errors.New("at least one of block-blob-tier, page-blob-tier, metadata or blob-tags must be specified")
+		}
 	}
 	if err = validatePutMd5(cooked.putMd5, cooked.fromTo); err != nil {
 		return cooked, err
@@ -587,6 +734,8 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 	cooked.s2sPreserveProperties = raw.s2sPreserveProperties
 	cooked.s2sGetPropertiesInBackend = raw.s2sGetPropertiesInBackend
 	cooked.s2sPreserveAccessTier = raw.s2sPreserveAccessTier
+	cooked.s2sPreserveAccessTierRequested = raw.s2sPreserveAccessTierRequested
+	cooked.bestEffortFeatures = raw.bestEffortFeatures
 	cooked.s2sSourceChangeValidation = raw.s2sSourceChangeValidation
 
 	err = cooked.s2sInvalidMetadataHandleOption.Parse(raw.s2sInvalidMetadataHandleOption)
@@ -594,16 +743,269 @@ func (raw rawCopyCmdArgs) cookWithId(jobId common.JobID) (cookedCopyCmdArgs, err
 		return cooked, err
 	}
 
+	err = cooked.s2sFallback.Parse(raw.s2sFallback)
+	if err != nil {
+		return cooked, err
+	}
+	// the relay reads the source with the same clients that the job uses to get the properties of Azure sources
+	if cooked.s2sFallback != common.ES2SFallback.None() &&
+		!((cooked.fromTo.From() == common.ELocation.Blob() || cooked.fromTo.From() == common.ELocation.File()) && cooked.fromTo.To() == common.ELocation.Blob()) {
+		return cooked, fmt.Errorf("s2s-fallback is only supported while copying from Azure Blob or Azure File to Azure Blob")
+	}
+	if cooked.s2sComputeMD5, err = cookS2SComputeMD5(raw.s2sComputeMD5, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+
+	if err = cooked.transferOrder.Parse(raw.transferOrder); err != nil {
+		return cooked, fmt.Errorf("invalid transfer-order '%s': it must be largest-first, smallest-first or as-enumerated", raw.transferOrder)
+	}
+
+	if err = cooked.flushPolicy.Parse(raw.flushPolicy); err != nil {
+		return cooked, fmt.Errorf("invalid flush-policy '%s': it must be none, per-file or per-chunk", raw.flushPolicy)
+	}
+	if err = validateFlushPolicy(cooked.fromTo, cooked.flushPolicy, raw.flushIntervalMB); err != nil {
+		return cooked, err
+	}
+	cooked.flushIntervalBytes = int64(raw.flushIntervalMB) * 1024 * 1024
+
+	if raw.diffBaseSnapshot != "" {
+		if err = validateDiffBaseSnapshot(raw.diffBaseSnapshot, cooked); err != nil {
+			return cooked, err
+		}
+		cooked.diffBaseSnapshot = raw.diffBaseSnapshot
+	}
+
+	if raw.reuseUncommittedBlocks && cooked.fromTo.To() != common.ELocation.Blob() {
+		return cooked, fmt.Errorf("reuse-uncommitted-blocks is only supported while copying to Azure Blob")
+	}
+	cooked.reuseUncommittedBlocks = raw.reuseUncommittedBlocks
+
+	if err = cookDownloadRange(raw, &cooked); err != nil {
+		return cooked, err
+	}
+
+	if raw.deleteSourceAfterTransfer {
+		if err = validateDeleteSourceAfterTransfer(cooked); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.deleteSourceAfterTransfer = raw.deleteSourceAfterTransfer
+
+	if raw.clearArchiveBit {
+		if err = validateClearArchiveBit(cooked.fromTo, raw.useVss); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.clearArchiveBit = raw.clearArchiveBit
+	cooked.noCreateDstContainer = raw.noCreateDstContainer
+
+	if raw.breakLeaseOnOverwrite {
+		if err = validateBreakLeaseOnOverwrite(cooked.fromTo); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.breakLeaseOnOverwrite = raw.breakLeaseOnOverwrite
+
+	if raw.protectDestinationWithLease {
+		if err = validateProtectDestinationWithLease(cooked.fromTo, cooked.blobType); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.protectDestinationWithLease = raw.protectDestinationWithLease
+
+	if cooked.destinationCondition, err = common.ParseDestinationCondition(raw.destinationCondition); err != nil {
+		return cooked, fmt.Errorf("destination-condition: %s", err)
+	}
+	if cooked.destinationCondition.IsSet() {
+		if err = validateDestinationCondition(cooked.fromTo, cooked.protectDestinationWithLease); err != nil {
+			return cooked, err
+		}
+	}
+
+	if raw.forceIfReadOnly || raw.retryOnSharingViolation != 0 {
+		if err = validateAzureFileWriteRetries(cooked.fromTo, raw.retryOnSharingViolation); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.forceIfReadOnly = raw.forceIfReadOnly
+	cooked.retryOnSharingViolation = raw.retryOnSharingViolation
+
+	if raw.waitOnShareFull != 0 {
+		if err = validateWaitOnShareFull(cooked.fromTo, raw.waitOnShareFull); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.waitOnShareFull = raw.waitOnShareFull
+
+	if cooked.missingSourceHandling, err = cookMissingSourceHandling(raw.missingSourceHandling, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	if cooked.softDeletedDestination, err = cookSoftDeletedDestination(raw.softDeletedDestination, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	if err = validatePreserveSourceLMTAsMetadata(raw.preserveSourceLMTAsMetadata, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	cooked.preserveSourceLMTAsMetadata = raw.preserveSourceLMTAsMetadata
+
+	if err = validateTempNameSuffix(raw.tempNameSuffix); err != nil {
+		return cooked, err
+	}
+	if (cooked.fromTo.To() == common.ELocation.File() && raw.tempNameSuffix != "" && featureAvailable(ste.FeatureAzureFileRenameTemp)) ||
+		cooked.fromTo.To() == common.ELocation.BlobFS() {
+		cooked.tempNameSuffix = raw.tempNameSuffix
+	}
+
+	if raw.continueOnEnumerationErrors {
+		cooked.enumerationFailures = newEnumerationFailureTracker()
+	}
+	if cooked.invalidNames, err = cookInvalidNameHandling(raw.invalidNameHandling, cooked.fromTo, cooked.jobID); err != nil {
+		return cooked, err
+	}
+	if cooked.failedFilesOutput, err = cookFailedFilesOutput(raw.failedFilesOutput, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+
+	if err = validateSkipSourceErrors(raw.skipPermissionErrors, raw.skipLockedFiles, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	cooked.skipPermissionErrors = raw.skipPermissionErrors
+	cooked.skipLockedFiles = raw.skipLockedFiles
+
+	if raw.useVss {
+		if err = validateUseVss(cooked.fromTo); err != nil {
+			return cooked, err
+		}
+		cooked.shadowCopies = newShadowCopySet()
+	}
+
+	if raw.attributesManifest != "" {
+		if !cooked.fromTo.IsUpload() {
+			return cooked, fmt.Errorf("attributes-manifest is only supported while uploading local files")
+		}
+		if cooked.attributesManifest, err = loadAttributesManifest(raw.attributesManifest); err != nil {
+			return cooked, err
+		}
+	}
+
+	if raw.destinationTemplate != "" {
+		if cooked.destination == common.Dev_Null {
+			return cooked, fmt.Errorf("destination-template is not supported when the destination is %s", common.Dev_Null)
+		}
+		if cooked.destinationTemplate, err = parseDestinationTemplate(raw.destinationTemplate); err != nil {
+			return cooked, err
+		}
+	}
+
+	if raw.destinationShards != "" {
+		if cooked.fromTo.To() != common.ELocation.Blob() {
+			return cooked, fmt.Errorf("%s is only supported when the destination is Blob storage", destinationShardsFlag)
+		}
+		if cooked.destinationShards, err = parseDestinationShards(raw.destinationShards); err != nil {
+			return cooked, err
+		}
+	}
+
+	if cooked.headerRules, err = cookHeaderRules(raw.headerRules, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	if raw.printHeaderRules && (!raw.estimateOnly || len(cooked.headerRules) == 0) {
+		return cooked, fmt.Errorf("print-header-rules is only supported with estimate-only and header-rule")
+	}
+	cooked.printHeaderRules = raw.printHeaderRules
+
+	cooked.metricsFile, err = cookMetricsFile(raw.metricsFile)
+	if err != nil {
+		return cooked, err
+	}
+	if err = validateMetricsListen(raw.metricsListen); err != nil {
+		return cooked, err
+	}
+	cooked.metricsListen = raw.metricsListen
+
+	cooked.failFast, err = newFailFastPolicy(raw.failFastThreshold, raw.failFastRate)
+	if err != nil {
+		return cooked, err
+	}
+	cooked.completionHook = newCompletionHook(raw.onCompleteExec, raw.notify)
+
+	if err = cookChecksumFile(raw, &cooked); err != nil {
+		return cooked, err
+	}
+
+	if raw.pricePerGB < 0 {
+		return cooked, fmt.Errorf("price-per-gb cannot be negative")
+	}
+	if raw.estimateOnly {
+		if cooked.isRedirection() {
+			return cooked, fmt.Errorf("estimate-only is not supported while piping")
+		}
+		cooked.estimate = newTransferEstimate(raw.pricePerGB)
+	} else if raw.pricePerGB != 0 {
+		return cooked, fmt.Errorf("price-per-gb is only supported with estimate-only")
+	}
+	if raw.maxTransactions > 0 && cooked.isRedirection() {
+		return cooked, fmt.Errorf("max-transactions is not supported while piping")
+	}
+	cooked.transactions = newTransactionBudget(raw.maxTransactions, raw.estimateOnly, cooked.fromTo, cooked.blobType, cooked.blockSize)
+	if cooked.transactions != nil {
+		cooked.transactions.renames = cooked.tempNameSuffix != ""
+	}
+
+	var lowSpaceAction common.LowSpaceAction
+	if err = lowSpaceAction.Parse(raw.lowSpaceAction); err != nil {
+		return cooked, fmt.Errorf("invalid low-space-action '%s': it must be fail, warn or prompt", raw.lowSpaceAction)
+	}
+	cooked.lowSpace = newLowSpaceCheck(lowSpaceAction, cooked.fromTo, cooked.destination)
+	if err = cookEnumerationCache(raw, &cooked); err != nil {
+		return cooked, err
+	}
+
+	if err = cookIncludeDeleted(raw, &cooked); err != nil {
+		return cooked, err
+	}
+
 	// parse the filter patterns
 	cooked.includePatterns = raw.parsePatterns(raw.include)
 	cooked.excludePatterns = raw.parsePatterns(raw.exclude)
 	cooked.excludePathPatterns = raw.parsePatterns(raw.excludePath)
+	if cooked.includePathGlobs, err = normalizePathPatterns("include-path-pattern", raw.parsePatterns(raw.includePathPattern)); err != nil {
+		return cooked, err
+	}
+	if cooked.excludePathGlobs, err = normalizePathPatterns("exclude-path-pattern", raw.parsePatterns(raw.excludePathPattern)); err != nil {
+		return cooked, err
+	}
+
+	if raw.maxDepth < 0 {
+		return cooked, errors.New("max-depth cannot be negative")
+	}
+	cooked.traversalLimits = newTraversalLimits(raw.maxDepth, cooked.excludePathPatterns, raw.parsePatterns(raw.prunePattern), cooked.excludePathGlobs)
+	if cooked.containerFilter, err = newContainerNameFilter(raw.parsePatterns(raw.includeContainer), raw.parsePatterns(raw.excludeContainer)); err != nil {
+		return cooked, err
+	}
 
 	if (raw.includeFileAttributes != "" || raw.excludeFileAttributes != "") && fromTo.From() != common.ELocation.Local() {
 		return cooked, errors.New("cannot check file attributes on remote objects")
 	}
 	cooked.includeFileAttributes = raw.parsePatterns(raw.includeFileAttributes)
 	cooked.excludeFileAttributes = raw.parsePatterns(raw.excludeFileAttributes)
+	if err := validateFileAttributes("include-attributes", cooked.includeFileAttributes); err != nil {
+		return cooked, err
+	}
+	if err := validateFileAttributes("exclude-attributes", cooked.excludeFileAttributes); err != nil {
+		return cooked, err
+	}
+
+	cooked.excludeDotfiles = raw.excludeDotfiles
+	if len(cooked.includeFileAttributes) > 0 || len(cooked.excludeFileAttributes) > 0 || cooked.excludeDotfiles {
+		cooked.excludedFiles = newExcludedFileCounter()
+	}
+
+	if !raw.ignoreSASValidation {
+		if err = validateCopySASPermissions(cooked, raw.listOfFilesToCopy != ""); err != nil {
+			return cooked, err
+		}
+	}
 
 	return cooked, nil
 }
@@ -629,9 +1031,324 @@ func (raw *rawCopyCmdArgs) setMandatoryDefaults() {
 	raw.pageBlobTier = common.EPageBlobTier.None().String()
 	raw.md5ValidationOption = common.DefaultHashValidationOption.String()
 	raw.s2sInvalidMetadataHandleOption = common.DefaultInvalidMetadataHandleOption.String()
+	raw.s2sFallback = common.ES2SFallback.None().String()
+	raw.transferOrder = common.ETransferOrder.AsEnumerated().String()
+	raw.flushPolicy = common.EFlushPolicy.None().String()
+	raw.lowSpaceAction = common.ELowSpaceAction.Warn().String()
 	raw.forceWrite = common.EOverwriteOption.True().String()
 }
 
+// an incremental copy changes the destination in place, so it must be a page blob or a file that can be overwritten as it is
+func validateDiffBaseSnapshot(snapshot string, cooked cookedCopyCmdArgs) error {
+	if cooked.fromTo != common.EFromTo.BlobBlob() && cooked.fromTo != common.EFromTo.BlobLocal() {
+		return fmt.Errorf("diff-base-snapshot is only supported while copying from Azure Blob to Azure Blob or to local files")
+	}
+	if _, err := time.Parse(time.RFC3339Nano, snapshot); err != nil {
+		return fmt.Errorf("diff-base-snapshot must be the time of a snapshot, such as 2021-03-01T10:00:00.0000000Z: %s", err.Error())
+	}
+	if cooked.forceWrite != common.EOverwriteOption.True() {
+		return fmt.Errorf("diff-base-snapshot updates the existing destination, so it needs overwrite to be true")
+	}
+	if cooked.autoDecompress {
+		return fmt.Errorf("diff-base-snapshot cannot be used with decompress")
+	}
+	return nil
+}
+
+// cookDownloadRange checks the window of a ranged download. Whether the source is a single file, and whether the window starts
+// before its end, can't be known until the source is enumerated, so those are checked then
+func cookDownloadRange(raw rawCopyCmdArgs, cooked *cookedCopyCmdArgs) error {
+	if raw.offset == 0 && raw.length == 0 {
+		return nil
+	}
+	if raw.offset < 0 || raw.length < 0 {
+		return errors.New("offset and length cannot be negative")
+	}
+	if !cooked.fromTo.IsDownload() {
+		return errors.New("offset and length are only supported while downloading a single file")
+	}
+	if cooked.recursive || cooked.stripTopDir || cooked.listOfFilesChannel != nil {
+		return errors.New("offset and length are only supported while downloading a single file, so they cannot be used with recursive, wildcards, list-of-files or include-path")
+	}
+	if cooked.autoDecompress || cooked.diffBaseSnapshot != "" {
+		return errors.New("offset and length cannot be used with decompress or diff-base-snapshot")
+	}
+
+	cooked.rangedDownload = true
+	cooked.downloadOffset = raw.offset
+	cooked.downloadLength = raw.length
+	if cooked.md5ValidationOption != common.EHashValidationOption.NoCheck() {
+		glcm.Info("Only a range of the file is downloaded, so its MD5 hash, which is of the whole file, will not be checked")
+	}
+	return nil
+}
+
+// windowOf returns the number of bytes of a source file of the given size that a ranged download gets.
+// The window ends at the end of the file if that comes first
+func (cca *cookedCopyCmdArgs) windowOf(source string, size int64) (int64, error) {
+	if cca.downloadOffset > 0 && cca.downloadOffset >= size {
+		return 0, fmt.Errorf("the offset %d is not before the end of %s, which is %d bytes long", cca.downloadOffset, source, size)
+	}
+	window := size - cca.downloadOffset
+	if cca.downloadLength > 0 && cca.downloadLength < window {
+		window = cca.downloadLength
+	}
+	return window, nil
+}
+
+// the directions in which the source of a transfer can be deleted, which needs a pipeline to the source for service to service copies
+var deleteSourceFromTos = []common.FromTo{
+	common.EFromTo.LocalBlob(), common.EFromTo.LocalFile(), common.EFromTo.LocalBlobFS(),
+	common.EFromTo.BlobLocal(), common.EFromTo.FileLocal(), common.EFromTo.BlobFSLocal(),
+	common.EFromTo.BlobBlob(), common.EFromTo.BlobFile(), common.EFromTo.FileBlob(), common.EFromTo.FileFile(),
+}
+
+// validateDeleteSourceAfterTransfer makes sure that deleting the sources doesn't lose any data, so the whole of each source must
+// end up at a destination that keeps it
+func validateDeleteSourceAfterTransfer(cooked cookedCopyCmdArgs) error {
+	supported := false
+	for _, fromTo := range deleteSourceFromTos {
+		supported = supported || fromTo == cooked.fromTo
+	}
+	if !supported {
+		return fmt.Errorf("delete-source-after-transfer is not supported while copying from %s to %s", cooked.fromTo.From(), cooked.fromTo.To())
+	}
+	if strings.EqualFold(cooked.destination, common.Dev_Null) {
+		return errors.New("delete-source-after-transfer cannot be used when the destination discards the data")
+	}
+	if cooked.rangedDownload {
+		return errors.New("delete-source-after-transfer cannot be used with offset and length, since only part of the source is downloaded")
+	}
+	return nil
+}
+
+// cookHeaderRules parses the header rules, which only uploads support, and makes sure that they fit in the job's plan
+func cookHeaderRules(rawRules []string, fromTo common.FromTo) (common.HeaderRules, error) {
+	if len(rawRules) == 0 {
+		return nil, nil
+	}
+	if !fromTo.IsUpload() {
+		return nil, fmt.Errorf("header-rule is only supported while uploading local files")
+	}
+
+	rules := make(common.HeaderRules, 0, len(rawRules))
+	for _, raw := range rawRules {
+		rule, err := common.ParseHeaderRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules.String()) > ste.HeaderRulesMaxBytes {
+		return nil, fmt.Errorf("the header rules are too long, they can take up at most %d characters", ste.HeaderRulesMaxBytes)
+	}
+	return rules, nil
+}
+
+// validateSkipSourceErrors makes sure that the source files which may be skipped, rather than failed, are local ones,
+// since it's only the local file system that refuses to open a file for those reasons
+func validateSkipSourceErrors(skipPermissionErrors, skipLockedFiles bool, fromTo common.FromTo) error {
+	if fromTo.From() == common.ELocation.Local() {
+		return nil
+	}
+	if skipPermissionErrors {
+		return fmt.Errorf("skip-permission-errors is only supported while uploading local files")
+	}
+	if skipLockedFiles {
+		return fmt.Errorf("skip-locked-files is only supported while uploading local files")
+	}
+	return nil
+}
+
+// validateUseVss makes sure that the source is local, and that the process may take shadow copies of it
+func validateUseVss(fromTo common.FromTo) error {
+	if fromTo.From() != common.ELocation.Local() {
+		return fmt.Errorf("use-vss is only supported while uploading local files")
+	}
+	return checkShadowCopyPrivileges()
+}
+
+// cookIncludeDeleted sets up the undeleting of the soft-deleted blobs of the source, if they're to be included
+func cookIncludeDeleted(raw rawCopyCmdArgs, cooked *cookedCopyCmdArgs) error {
+	if !raw.includeDeleted && !raw.restoreInPlace {
+		if raw.preferDeletedVersion {
+			return errors.New("prefer-deleted-version is only supported with include-deleted or restore-in-place")
+		}
+		return nil
+	}
+
+	if cooked.fromTo.From() != common.ELocation.Blob() {
+		return errors.New("include-deleted and restore-in-place are only supported when the source is Azure Blob")
+	}
+	if cooked.estimate != nil {
+		return errors.New("include-deleted and restore-in-place cannot be used with estimate-only, since the blobs would be undeleted")
+	}
+	cooked.deletedBlobs = newDeletedBlobRestorer(raw.preferDeletedVersion, raw.restoreInPlace)
+	return nil
+}
+
+// validateBreakLeaseOnOverwrite makes sure that the blobs whose leases may be broken are the destination blobs, or the blobs to be removed
+func validateBreakLeaseOnOverwrite(fromTo common.FromTo) error {
+	if fromTo.To() != common.ELocation.Blob() && fromTo != common.EFromTo.BlobTrash() {
+		return fmt.Errorf("break-lease-on-overwrite is only supported when the destination is Blob Storage, or when removing blobs")
+	}
+	return nil
+}
+
+// validateProtectDestinationWithLease makes sure that the destinations can be leased while they're written,
+// which block blobs, page blobs and BlobFS files can
+func validateProtectDestinationWithLease(fromTo common.FromTo, blobType common.BlobType) error {
+	if fromTo.To() != common.ELocation.Blob() && fromTo.To() != common.ELocation.BlobFS() {
+		return fmt.Errorf("protect-destination-with-lease is only supported when the destination is Blob Storage or ADLS Gen2")
+	}
+	if blobType == common.EBlobType.AppendBlob() {
+		return fmt.Errorf("protect-destination-with-lease is not supported for append blobs")
+	}
+	return nil
+}
+
+// validateDestinationCondition makes sure that the writes of the destinations can carry the condition,
+// which those of blobs and BlobFS files can
+func validateDestinationCondition(fromTo common.FromTo, protectDestinationWithLease bool) error {
+	if fromTo.To() != common.ELocation.Blob() && fromTo.To() != common.ELocation.BlobFS() {
+		return fmt.Errorf("destination-condition is only supported when the destination is Blob Storage or ADLS Gen2")
+	}
+	if protectDestinationWithLease {
+		// the lease is taken on an empty destination that's created first, which would then never meet the condition
+		return fmt.Errorf("destination-condition cannot be used with protect-destination-with-lease")
+	}
+	return nil
+}
+
+// validateAzureFileWriteRetries makes sure that the destinations whose ReadOnly attribute may be cleared, or whose writes may be retried
+// for sharing violations, are Azure files, and that the window of the retries makes sense
+func validateAzureFileWriteRetries(fromTo common.FromTo, retryOnSharingViolation time.Duration) error {
+	if fromTo.To() != common.ELocation.File() {
+		return fmt.Errorf("force-if-read-only and retry-on-sharing-violation are only supported when the destination is Azure Files")
+	}
+	if retryOnSharingViolation < 0 {
+		return fmt.Errorf("retry-on-sharing-violation must not be negative")
+	}
+	return nil
+}
+
+// validateFlushPolicy makes sure that the files that are to be flushed are downloaded, and that only those that are flushed as they're written
+// are given an interval to be flushed at
+func validateFlushPolicy(fromTo common.FromTo, policy common.FlushPolicy, intervalMB uint32) error {
+	if policy != common.EFlushPolicy.None() && !fromTo.IsDownload() {
+		return fmt.Errorf("flush-policy is only supported for downloads")
+	}
+	if intervalMB != 0 && policy != common.EFlushPolicy.PerChunk() {
+		return fmt.Errorf("flush-interval-mb is only supported with flush-policy per-chunk")
+	}
+	return nil
+}
+
+// validateWaitOnShareFull makes sure that the destinations are Azure files, whose shares have quotas
+func validateWaitOnShareFull(fromTo common.FromTo, waitOnShareFull time.Duration) error {
+	if fromTo.To() != common.ELocation.File() {
+		return fmt.Errorf("wait-on-share-full is only supported when the destination is Azure Files")
+	}
+	if waitOnShareFull < 0 {
+		return fmt.Errorf("wait-on-share-full must not be negative")
+	}
+	return nil
+}
+
+// cookMissingSourceHandling parses what to do with the transfers whose source was deleted after it was enumerated,
+// which can only be skipped when the source is in Azure Storage, whose errors tell a deleted source apart
+func cookMissingSourceHandling(raw string, fromTo common.FromTo) (common.MissingSourceHandling, error) {
+	handling := common.EMissingSourceHandling.Fail()
+	if raw != "" {
+		if err := handling.Parse(raw); err != nil {
+			return handling, fmt.Errorf("invalid missing-source-handling '%s': it must be fail or skip", raw)
+		}
+	}
+	if handling == common.EMissingSourceHandling.Skip() {
+		switch fromTo.From() {
+		case common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS():
+		default:
+			return handling, fmt.Errorf("missing-source-handling=skip is only supported when the source is Azure Blob, Azure Files or ADLS Gen2")
+		}
+	}
+	return handling, nil
+}
+
+func cookSoftDeletedDestination(raw string, fromTo common.FromTo) (common.SoftDeletedDestinationHandling, error) {
+	handling := common.ESoftDeletedDestinationHandling.Ignore()
+	if raw == "" {
+		return handling, nil
+	}
+	if err := handling.Parse(raw); err != nil || handling == common.ESoftDeletedDestinationHandling.Ignore() {
+		return handling, fmt.Errorf("invalid soft-deleted-destination '%s': it must be overwrite, undelete-and-overwrite or skip", raw)
+	}
+	if fromTo.To() != common.ELocation.Blob() {
+		return handling, fmt.Errorf("soft-deleted-destination is only supported when the destination is Azure Blob")
+	}
+	return handling, nil
+}
+
+func cookS2SComputeMD5(raw string, fromTo common.FromTo) (common.S2SComputeMD5, error) {
+	option := common.ES2SComputeMD5.None()
+	if raw == "" {
+		return option, nil
+	}
+	if err := option.Parse(raw); err != nil || option == common.ES2SComputeMD5.None() {
+		return option, fmt.Errorf("invalid s2s-compute-md5 '%s': it must be missing or always", raw)
+	}
+	if !fromTo.IsS2S() || fromTo.To() != common.ELocation.Blob() {
+		return option, fmt.Errorf("s2s-compute-md5 is only supported while copying from a service to Azure Blob")
+	}
+	return option, nil
+}
+
+// validatePreserveSourceLMTAsMetadata makes sure that the destination takes metadata, which ADLS Gen2 files don't, as they're written
+func validatePreserveSourceLMTAsMetadata(preserve bool, fromTo common.FromTo) error {
+	if !preserve {
+		return nil
+	}
+	if to := fromTo.To(); to != common.ELocation.Blob() && to != common.ELocation.File() {
+		return fmt.Errorf("preserve-source-lmt-as-metadata is only supported when the destination is Azure Blob or Azure Files")
+	}
+	return nil
+}
+
+// validateTempNameSuffix makes sure that the temporary names are in the same directories as the destinations, and fit in the job plan
+func validateTempNameSuffix(suffix string) error {
+	if strings.ContainsAny(suffix, `/\`) {
+		return fmt.Errorf("temp-name-suffix cannot contain path separators")
+	}
+	if len(suffix) > ste.TempSuffixMaxBytes {
+		return fmt.Errorf("temp-name-suffix cannot be longer than %v bytes", ste.TempSuffixMaxBytes)
+	}
+	return nil
+}
+
+// featureAvailable tells whether the feature can be used with the service version that the requests are sent with,
+// and warns the user that it's turned off if it can't
+func featureAvailable(feature ste.VersionedFeature) bool {
+	if feature.Supported() {
+		return true
+	}
+	glcm.Info(feature.DisabledWarning())
+	return false
+}
+
+// validateClearArchiveBit makes sure that the files whose archive attribute is to be cleared are local Windows files,
+// and that they aren't read from a shadow copy, which can't be changed
+func validateClearArchiveBit(fromTo common.FromTo, useVss bool) error {
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("clear-archive-bit is only supported on Windows")
+	}
+	if !fromTo.IsUpload() {
+		return fmt.Errorf("clear-archive-bit is only supported while uploading local files")
+	}
+	if useVss {
+		return fmt.Errorf("clear-archive-bit cannot be used with use-vss, since the shadow copy cannot be changed")
+	}
+	return nil
+}
+
 func validatePutMd5(putMd5 bool, fromTo common.FromTo) error {
 	if putMd5 && !fromTo.IsUpload() {
 		return fmt.Errorf("put-md5 is set but the job is not an upload")
@@ -662,13 +1379,26 @@ type cookedCopyCmdArgs struct {
 	includePatterns       []string
 	excludePatterns       []string
 	excludePathPatterns   []string
+	includePathGlobs      []string // matched against the whole relative path, where ** spans directories
+	excludePathGlobs      []string
 	includeFileAttributes []string
 	excludeFileAttributes []string
+	excludeDotfiles       bool
+
+	// how far the enumeration of the source descends; nil if it goes all the way down
+	traversalLimits *traversalLimits
+	// which containers an account-level source looks into; nil if all of them
+	containerFilter *containerNameFilter
+	// what an account-level source scheduled from each container, for the summary; nil if the source isn't a whole account
+	containers *containerTally
+	// the files that the attribute and dotfile filters excluded; nil if there are no such filters
+	excludedFiles *excludedFileCounter
 
 	// filters from flags
-	listOfFilesChannel chan string // Channels are nullable.
+	listOfFilesChannel chan listOfFilesEntry // Channels are nullable.
 	recursive          bool
 	stripTopDir        bool
+	asSubdir           bool
 	followSymlinks     bool
 	forceWrite         common.OverwriteOption
 	autoDecompress     bool
@@ -689,6 +1419,8 @@ type cookedCopyCmdArgs struct {
 	noGuessMimeType          bool
 	preserveLastModifiedTime bool
 	deleteSnapshotsOption    common.DeleteSnapshotsOption
+	blobTags                 common.BlobTags
+	propertiesToTransfer     common.SetPropertiesFlags
 	putMd5                   bool
 	md5ValidationOption      common.HashValidationOption
 	CheckLength              bool
@@ -711,6 +1443,7 @@ type cookedCopyCmdArgs struct {
 
 	// used to calculate job summary
 	jobStartTime time.Time
+	perf         *jobPerformanceTracker // nil for the jobs that aren't cooked from the command line, such as the cleanup of a benchmark
 
 	// this flag is set by the enumerator
 	// it is useful to indicate whether we are simply waiting for the purpose of cancelling
@@ -727,11 +1460,120 @@ type cookedCopyCmdArgs struct {
 	// In such cases, use s2sPreserveAccessTier=false to bypass the access tier copy.
 	// For more details, please refer to https://docs.microsoft.com/en-us/azure/storage/blobs/storage-blob-storage-tiers
 	s2sPreserveAccessTier bool
+	// whether it was given explicitly, in which case the destination is probed for access tiers
+	s2sPreserveAccessTierRequested bool
+	// whether the requested features that the destination doesn't support are disabled, rather than fail the job
+	bestEffortFeatures bool
 	// whether user wants to check if source has changed after enumerating, the default value is true.
 	// For S2S copy, as source is a remote resource, validating whether source has changed need additional request costs.
 	s2sSourceChangeValidation bool
 	// specify how user wants to handle invalid metadata.
 	s2sInvalidMetadataHandleOption common.InvalidMetadataHandleOption
+	// specify what to do when the destination can't read the source.
+	s2sFallback common.S2SFallback
+	// which destination block blobs of a S2S copy have their Content-MD5 computed after they're written
+	s2sComputeMD5 common.S2SComputeMD5
+	// the order in which the transfers of each job part are scheduled, within the window of the scheduler
+	transferOrder common.TransferOrder
+	// when the downloaded files are flushed to stable storage, and, if they're flushed as they're written, every how many bytes (or each chunk, if zero)
+	flushPolicy        common.FlushPolicy
+	flushIntervalBytes int64
+	// if not empty, only the pages that changed since this snapshot of the source are copied
+	diffBaseSnapshot string
+	// whether block blob uploads keep the blocks that an earlier attempt staged
+	reuseUncommittedBlocks bool
+	// whether the source of each transfer is deleted once the transfer has succeeded
+	deleteSourceAfterTransfer bool
+	// whether the archive attribute of each local file is cleared once it's uploaded
+	clearArchiveBit bool
+	// whether a missing destination container, share or file system is left missing, so that the transfers into it fail
+	noCreateDstContainer bool
+	// whether the lease of a destination blob that's in the way of its overwrite, or of its removal, is broken
+	breakLeaseOnOverwrite bool
+	// whether each destination is leased while it's written, and its transfer fails if something else has it leased
+	protectDestinationWithLease bool
+	// the condition that each write of a whole destination must meet. A destination that doesn't meet it is skipped
+	destinationCondition common.DestinationCondition
+	// whether the ReadOnly attribute of a destination Azure file that's in the way of its overwrite is cleared, and restored afterwards
+	forceIfReadOnly bool
+	// how long the writes to a destination Azure file that something else has open over SMB are retried. Zero for not at all
+	retryOnSharingViolation time.Duration
+	// how long the transfers to a destination Azure file share that's full wait for its quota to be raised. Zero for not at all
+	waitOnShareFull time.Duration
+	// tells the user about the destination shares that the job finds full
+	fullShares fullSharesReport
+	// whether the transfers whose source was deleted after it was enumerated fail, or are skipped
+	missingSourceHandling common.MissingSourceHandling
+	// what is done with the transfers whose destination blob is soft-deleted
+	softDeletedDestination common.SoftDeletedDestinationHandling
+	// whether the last modified time of each source is kept in the metadata of its destination
+	preserveSourceLMTAsMetadata bool
+	// the suffix of the temporary names that the destinations are written under, before they're renamed to their own. Empty unless
+	// the destinations are ADLS Gen2 or Azure Files, and they're not written under their own names
+	tempNameSuffix string
+	// where the source paths that couldn't be enumerated are recorded. Nil unless the job should carry on past them
+	enumerationFailures *enumerationFailureTracker
+	// skips or renames the objects whose names the destination doesn't allow. Nil if the names are left as they are
+	invalidNames *invalidNameHandler
+	// where the paths of the failed transfers are written, as a list of files, once the job is done. Empty if they're not
+	failedFilesOutput string
+	// whether the local files that can't be read for lack of permission, or because another process holds them open,
+	// are skipped rather than failed
+	skipPermissionErrors bool
+	skipLockedFiles      bool
+	// the snapshots that the local source is read from. Nil unless it's read from shadow copies
+	shadowCopies *shadowCopySet
+	// the content headers and metadata of the uploaded files that have their own. Nil if they all have those of the job
+	attributesManifest *attributesManifest
+	// where each object goes under the destination, in place of its relative path. Nil if the objects keep their relative paths
+	destinationTemplate *destinationTemplate
+	// the containers that the transfers are spread across. Nil unless the destination is sharded
+	destinationShards *destinationShards
+	// the first of these that matches an uploaded file sets its content headers, and the matches are listed if printHeaderRules is set
+	headerRules      common.HeaderRules
+	printHeaderRules bool
+	// the flags of the job, kept next to its plan files for jobs export-template. Nil for the jobs that aren't cooked from the command line
+	template *common.JobTemplate
+	// the template that the flags were loaded from, if any
+	templateFile string
+	// if rangedDownload is set, only the window of the single source file that starts at downloadOffset is downloaded.
+	// A downloadLength of zero is up to the end of the file
+	rangedDownload bool
+	downloadOffset int64
+	downloadLength int64
+
+	// absolute path of the file for the timings of each transfer, or empty if they are not recorded
+	metricsFile string
+	// the address to serve the metrics at, or empty if they are not served
+	metricsListen string
+
+	// nil unless the job is aborted once too many of its transfers failed
+	failFast *failFastPolicy
+
+	// nil unless the job uses an OAuth token, which may expire before the job is done
+	credentialExpiry *credentialExpiryCheck
+
+	// nil unless something is to happen once the job reaches a terminal state
+	completionHook *completionHook
+
+	// absolute path of the checksum file, or empty if there is none. It's written, unless verifyChecksums is set
+	checksumFile    string
+	verifyChecksums bool
+
+	// non-nil if the enumeration only adds up what would be transferred, and no job is created
+	estimate *transferEstimate
+	// nil unless the storage transactions of the job are estimated, or capped
+	transactions *transactionBudget
+	// nil unless the job saves what it downloads, so that the free space of the destination volume matters
+	lowSpace *lowSpaceCheck
+	// nil unless the listing of the source is kept for the next run, which skips the files that are unchanged since this one
+	enumerationCache *enumerationCache
+
+	// what undeletes the soft-deleted blobs of the source before they're copied. Nil unless they're included
+	deletedBlobs *deletedBlobRestorer
+
+	// how the listing of the source is going: how often it was throttled, and how far it has got
+	listing *enumerationListing
 
 	// followup/cleanup properties are NOT available on resume, and so should not be used for jobs that may be resumed
 	// TODO: consider find a way to enforce that, or else to allow them to be preserved. Initially, they are just for benchmark jobs, so not a problem immediately because those jobs can't be resumed, by design.
@@ -739,6 +1581,10 @@ type cookedCopyCmdArgs struct {
 	priorJobExitCode  *common.ExitCode
 	isCleanupJob      bool // triggers abbreviated status reporting, since we don't want full reporting for cleanup jobs
 	cleanupJobMessage string
+	isPreparationJob  bool // like cleanup jobs, jobs that just prepare the data for a benchmark get abbreviated status reporting
+
+	// only set on the job that is measured by a benchmark run
+	benchmarkJob *benchmarkJobInfo
 }
 
 func (cca *cookedCopyCmdArgs) isRedirection() bool {
@@ -763,7 +1609,25 @@ func (cca *cookedCopyCmdArgs) process() error {
 		// if no error, the operation is now complete
 		glcm.Exit(nil, common.EExitCode.Success())
 	}
-	return cca.processCopyJobPartOrders()
+
+	err := cca.processCopyJobPartOrders()
+	if err == nil && cca.estimate != nil {
+		// the enumeration is done, and there's no job to wait for
+		cca.estimate.Transactions, cca.estimate.TransactionAssumptions = cca.transactions.prediction(), cca.transactions.assumptions()
+		glcm.Exit(cca.estimate.output, common.EExitCode.Success())
+	}
+	if err == nil && cca.deletedBlobs.restoresOnly() {
+		exitCode := common.EExitCode.Success()
+		if cca.deletedBlobs.undeleteFailed() > 0 {
+			exitCode = common.EExitCode.Error()
+		}
+		glcm.Exit(cca.deletedBlobs.output, exitCode)
+	}
+	if err == NothingScheduledError && cca.enumerationCache.skippedEverything() {
+		// every file is as the last run copied it, which is a success, not a lack of files to copy
+		glcm.Exit(cca.enumerationCache.upToDate(), common.EExitCode.Success())
+	}
+	return err
 }
 
 // TODO discuss with Jeff what features should be supported by redirection, such as metadata, content-type, etc.
@@ -778,7 +1642,7 @@ func (cca *cookedCopyCmdArgs) processRedirectionCopy() error {
 }
 
 func (cca *cookedCopyCmdArgs) processRedirectionDownload(blobUrl string) error {
-	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	ctx := withClientRequestIDPrefix(context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion), cca.jobID)
 
 	// step 0: check the Stdout before uploading
 	_, err := os.Stdout.Stat()
@@ -818,7 +1682,7 @@ func (cca *cookedCopyCmdArgs) processRedirectionDownload(blobUrl string) error {
 }
 
 func (cca *cookedCopyCmdArgs) processRedirectionUpload(blobUrl string, blockSize uint32) error {
-	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	ctx := withClientRequestIDPrefix(context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion), cca.jobID)
 
 	// if no block size is set, then use default value
 	if blockSize == 0 {
@@ -850,7 +1714,8 @@ func (cca *cookedCopyCmdArgs) processRedirectionUpload(blobUrl string, blockSize
 // handles the copy command
 // dispatches the job order (in parts) to the storage engine
 func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
-	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	cca.listing = newEnumerationListing()
+	ctx := withClientRequestIDPrefix(withEnumerationListing(context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion), cca.listing), cca.jobID)
 
 	// Note: credential info here is only used by remove at the moment.
 	// TODO: Get the entirety of remove into the new copyEnumeratorInit script so we can remove this
@@ -882,7 +1747,16 @@ func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 			return err
 		} else {
 			cca.credentialInfo.OAuthTokenInfo = *tokenInfo
+			warnIfOAuthTokenIsForOtherCloud(cca.credentialInfo.OAuthTokenInfo, cca.source, cca.destination)
 		}
+		// the user can only be asked to log in again at a terminal, which piped transfers don't have
+		interactive := azcopyOutputFormat == common.EOutputFormat.Text() &&
+			cca.fromTo.From() != common.ELocation.Pipe() && cca.fromTo.To() != common.ELocation.Pipe()
+		cca.credentialExpiry = newCredentialExpiryCheck(cca.credentialInfo, interactive)
+	}
+
+	if err = cca.preflightDestination(ctx); err != nil {
+		return err
 	}
 
 	// initialize the fields that are constant across all job part orders
@@ -910,14 +1784,20 @@ func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 			PutMd5:                   cca.putMd5,
 			MD5ValidationOption:      cca.md5ValidationOption,
 			DeleteSnapshotsOption:    cca.deleteSnapshotsOption,
+			HeaderRules:              cca.headerRules.String(),
 		},
 		// source sas is stripped from the source given by the user and it will not be stored in the part plan file.
 		SourceSAS: cca.sourceSAS,
 
 		// destination sas is stripped from the destination given by the user and it will not be stored in the part plan file.
-		DestinationSAS: cca.destinationSAS,
-		CommandString:  cca.commandString,
-		CredentialInfo: cca.credentialInfo,
+		DestinationSAS:        cca.destinationSAS,
+		CommandString:         cca.commandString,
+		ClientRequestIDPrefix: clientRequestIDPrefix,
+		CredentialInfo:        cca.credentialInfo,
+		MetricsFile:           cca.metricsFile,
+		// the checksum file too, is not saved in the plan file, so a resumed job neither writes nor verifies one
+		ChecksumFile:    cca.checksumFile,
+		VerifyChecksums: cca.verifyChecksums,
 	}
 
 	from := cca.fromTo.From()
@@ -931,6 +1811,18 @@ func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		return err
 	}
 
+	// the files are enumerated and read from the snapshot, so their timestamps and attributes are those of the snapshot too
+	if cca.shadowCopies != nil {
+		glcm.RegisterCloseFunc(cca.shadowCopies.deleteAll)
+		if cca.source, err = cca.shadowCopies.pathInSnapshot(cca.source); err != nil {
+			return err
+		}
+	}
+
+	if err = startMetricsEndpoint(cca.metricsListen); err != nil {
+		return err
+	}
+
 	jobPartOrder.SourceSAS = cca.sourceSAS
 	jobPartOrder.SourceRoot, err = GetResourceRoot(cca.source, from)
 
@@ -956,6 +1848,8 @@ func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		return err
 	}
 
+	cca.perf.enumerationStarted()
+
 	// depending on the source and destination type, we process the cp command differently
 	// Create enumerator and do enumerating
 	switch cca.fromTo {
@@ -993,6 +1887,14 @@ func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		// TODO merge with BlobTrash case
 		err = removeBfsResources(cca)
 
+	case common.EFromTo.BlobNone():
+		e, createErr := newSetPropertiesEnumerator(cca)
+		if createErr != nil {
+			return createErr
+		}
+
+		err = e.enumerate()
+
 	// TODO: Hide the File to Blob direction temporarily, as service support on-going.
 	// case common.EFromTo.FileBlob():
 	// 	e := copyFileToNEnumerator(jobPartOrder)
@@ -1002,7 +1904,7 @@ func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 	}
 
 	if err != nil {
-		if err == NothingToRemoveError || err == NothingScheduledError {
+		if err == NothingToRemoveError || err == NothingToSetPropertiesError || err == NothingScheduledError {
 			return err // don't wrap it with anything that uses the word "error"
 		} else {
 			return fmt.Errorf("cannot start job due to error: %s.\n", err)
@@ -1018,12 +1920,15 @@ func (cca *cookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 func (cca *cookedCopyCmdArgs) waitUntilJobCompletion(blocking bool) {
 	// print initial message to indicate that the job is starting
 	glcm.Init(common.GetStandardInitOutputBuilder(cca.jobID.String(),
-		fmt.Sprintf("%s%s%s.log",
-			azcopyLogPathFolder,
-			common.OS_PATH_SEPARATOR,
-			cca.jobID),
+		common.JobLogFilePath(azcopyLogPathFolder, cca.jobID),
 		cca.isCleanupJob,
-		cca.cleanupJobMessage))
+		cca.cleanupJobMessage,
+		ste.ClientRequestIDPrefix(cca.jobID, clientRequestIDPrefix)))
+	if !cca.isCleanupJob {
+		cca.recordJobTemplate()
+		startJobControl(cca.jobID)
+		metricsEndpointOfProcess.jobStarted(cca.jobID, nil, nil)
+	}
 
 	// initialize the times necessary to track progress
 	cca.jobStartTime = time.Now()
@@ -1098,25 +2003,97 @@ func (cca *cookedCopyCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) {
 	Rpc(common.ERpcCmd.ListJobSummary(), &cca.jobID, &summary)
 	summary.IsCleanupJob = cca.isCleanupJob // only FE knows this, so we can only set it here
 	cleanupStatusString := fmt.Sprintf("Cleanup %v/%v", summary.TransfersCompleted, summary.TotalTransfers)
+	if cca.isPreparationJob {
+		cleanupStatusString = fmt.Sprintf("Preparing benchmark data %v/%v", summary.TransfersCompleted, summary.TotalTransfers)
+	}
 
-	jobDone := summary.JobStatus.IsJobDone()
+	jobDone := summary.JobStatus.IsJobDone() || cca.credentialExpiry.pausedJobDone(summary)
+	cca.perf.sample(summary)
+	if !jobDone {
+		cca.credentialExpiry.check(cca.jobID, summary)
+		cca.failFast.check(cca.jobID, summary)
+		cca.transactions.check(cca.jobID, summary)
+		cca.lowSpace.check(cca.jobID, summary)
+		cca.fullShares.check(cca.jobID, summary, cca.waitOnShareFull)
+	}
 
 	// if json is not desired, and job is done, then we generate a special end message to conclude the job
 	duration := time.Now().Sub(cca.jobStartTime) // report the total run time of the job
 
 	if jobDone {
 		exitCode := cca.getSuccessExitCode()
-		if summary.TransfersFailed > 0 {
+		cca.failFast.reportAbort(&summary)
+		cca.transactions.reportAbort(&summary)
+		cca.lowSpace.reportAbort(&summary)
+		cca.credentialExpiry.reportPause(&summary)
+		reportEnumerationFailures(&summary, cca.enumerationFailures)
+		cca.invalidNames.summarize(&summary)
+		writeFailedFiles(&summary, cca.failedFilesOutput, cca.fromTo.From())
+		summary.UnmatchedAttributesManifestEntries = cca.attributesManifest.unmatched() // only FE knows this, so we can only set it here
+		summary.Containers = cca.containers.summarize(summary, cca.fromTo.From())       // only FE knows this, so we can only set it here
+		summary.EnumerationRetries = cca.listing.retries()                              // only FE knows this, so we can only set it here
+		cca.destinationShards.summarize(&summary, duration)
+		cca.enumerationCache.save(&summary)
+		if cca.flushPolicy != common.EFlushPolicy.None() { // only FE knows this, so we can only set it here
+			summary.FlushPolicy = cca.flushPolicy.String()
+			if cca.flushPolicy == common.EFlushPolicy.PerChunk() {
+				summary.FlushIntervalBytes = cca.flushIntervalBytes
+			}
+		}
+		if summary.TransfersFailed > 0 || summary.ChecksumEntriesNotFound > 0 || summary.PathsNotEnumerated > 0 ||
+			summary.JobStatus == common.EJobStatus.Paused() {
 			exitCode = common.EExitCode.Error()
 		}
 
+		if cca.benchmarkJob != nil {
+			summary.BenchmarkResults = cca.benchmarkJob.results(cca, summary, duration) // only FE knows this, so we can only set it here
+		}
+		summary.PerformanceReport = cca.perf.report(summary, duration)
+		if !cca.isCleanupJob && !cca.isPreparationJob {
+			recordJobHistory(summary, cca.commandString, cca.source, cca.destination, cca.jobStartTime)
+			cca.completionHook.run(cca.jobID, summary.JobStatus)
+		}
+
 		builder := func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
 				jsonOutput, err := json.Marshal(summary)
 				common.PanicIfErr(err)
 				return string(jsonOutput)
 			} else {
-				screenStats, logStats := formatExtraStats(cca.fromTo, summary.AverageIOPS, summary.AverageE2EMilliseconds, summary.NetworkErrorPercentage, summary.ServerBusyPercentage)
+				screenStats, logStats := formatExtraStats(cca.benchmarkJob != nil, summary.AverageIOPS, summary.AverageE2EMilliseconds, summary.NetworkErrorPercentage, summary.ServerBusyPercentage)
+				screenStats += formatBenchmarkResults(summary)
+				screenStats += formatTransferDurationPercentiles(summary)
+				screenStats += formatTransfersRelayedClientSide(summary)
+				screenStats += formatMD5Backfills(summary)
+				screenStats += formatSourceReadRetries(summary)
+				screenStats += formatSharingViolationRetries(summary)
+				screenStats += formatFullShares(summary)
+				screenStats += formatDestinationConditionSkips(summary)
+				screenStats += formatMissingSourceSkips(summary)
+				screenStats += formatSoftDeletedDestinations(summary)
+				screenStats += formatCredentialExpiry(summary)
+				screenStats += formatSourceDeletion(summary)
+				screenStats += formatFlushPolicy(summary)
+				screenStats += formatTransactions(summary)
+				screenStats += formatTransactionsPerSecond(summary)
+				screenStats += formatTransferClasses(summary)
+				screenStats += formatPartitionThrottling(summary)
+				screenStats += formatBusyDirectories(summary)
+				screenStats += formatPageBlobDiff(summary)
+				screenStats += formatChecksumEntriesNotFound(summary)
+				screenStats += formatContainerSummaries(summary)
+				screenStats += formatShardSummaries(summary)
+				screenStats += formatPathsNotEnumerated(summary)
+				screenStats += formatInvalidNames(summary)
+				screenStats += formatEnumerationRetries(summary)
+				screenStats += formatEnumerationCache(summary)
+				screenStats += formatFailuresByErrorCode(summary)
+				screenStats += formatFailedFilesOutput(summary)
+				screenStats += formatFailFastAbort(summary)
+				screenStats += formatExcludedFiles(cca.excludedFiles)
+				screenStats += formatBlobsUndeleted(cca.deletedBlobs)
+				screenStats += formatUnmatchedAttributesManifestEntries(summary)
+				screenStats += formatPerformanceReport(summary)
 
 				output := fmt.Sprintf(
 					`
@@ -1142,7 +2119,7 @@ Final Job Status: %v%s%s
 					formatPerfAdvice(summary.PerformanceAdvice))
 
 				// abbreviated output for cleanup jobs
-				if cca.isCleanupJob {
+				if cca.isCleanupJob || cca.isPreparationJob {
 					output = fmt.Sprintf("%s: %s)", cleanupStatusString, summary.JobStatus)
 				}
 
@@ -1183,13 +2160,13 @@ Final Job Status: %v%s%s
 			return string(jsonOutput)
 		} else {
 			// abbreviated output for cleanup jobs
-			if cca.isCleanupJob {
+			if cca.isCleanupJob || cca.isPreparationJob {
 				return cleanupStatusString
 			}
 
 			// if json is not needed, then we generate a message that goes nicely on the same line
 			// display a scanning keyword if the job is not completely ordered
-			var scanningString = " (scanning...)"
+			var scanningString = " (scanning..." + formatDirectoriesSkipped(cca.traversalLimits) + formatFilesExcludedByAttributes(cca.excludedFiles) + ")"
 			if summary.CompleteJobOrdered {
 				scanningString = ""
 			}
@@ -1200,17 +2177,21 @@ Final Job Status: %v%s%s
 				// As there would be case when no bits sent from local, e.g. service side copy, when throughput = 0, hide it.
 				throughputString = ""
 			}
+			if limits := formatJobLimits(summary); limits != "" {
+				throughputString = strings.TrimPrefix(throughputString+", "+limits, ", ")
+			}
 
 			// indicate whether constrained by disk or not
-			isBenchmark := cca.fromTo.From() == common.ELocation.Benchmark()
+			isBenchmark := cca.fromTo.From() == common.ELocation.Benchmark() || cca.benchmarkJob != nil
 			perfString, diskString := getPerfDisplayText(summary.PerfStrings, summary.PerfConstraint, duration, isBenchmark)
 
-			return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Pending, %v Skipped, %v Total%s, %s%s%s",
+			return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Pending, %v Skipped, %v Total%s, %s%s%s%s%s",
 				summary.PercentComplete,
 				summary.TransfersCompleted,
 				summary.TransfersFailed,
 				summary.TotalTransfers-(summary.TransfersCompleted+summary.TransfersFailed+summary.TransfersSkipped),
-				summary.TransfersSkipped, summary.TotalTransfers, scanningString, perfString, throughputString, diskString)
+				summary.TransfersSkipped, summary.TotalTransfers, scanningString, perfString, throughputString, diskString,
+				formatInFlightTransfers(summary.InFlightTransfers), formatHashBackfillsInProgress(summary))
 		}
 	})
 }
@@ -1244,7 +2225,238 @@ func formatPerfAdvice(advice []common.PerformanceAdvice) string {
 
 // format extra stats to include in the log.  If benchmarking, also output them on screen (but not to screen in normal
 // usage because too cluttered)
-func formatExtraStats(fromTo common.FromTo, avgIOPS int, avgE2EMilliseconds int, networkErrorPercent float32, serverBusyPercent float32) (screenStats, logStats string) {
+// cookMetricsFile makes the path absolute (since the file is written by the transfer engine) and checks that the file can be created
+func cookMetricsFile(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	metricsFile, err := filepath.Abs(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid metrics-file %s: %s", raw, err.Error())
+	}
+	f, err := os.Create(metricsFile)
+	if err != nil {
+		return "", fmt.Errorf("cannot create the file %s passed with the metrics-file flag: %s", raw, err.Error())
+	}
+	_ = f.Close()
+	return metricsFile, nil
+}
+
+// cookChecksumFile makes the path of the checksum file absolute, since the transfer engine uses it, and checks that the file can be created,
+// or for verification, read. The checksums are of the data that passes through this machine, so there are none for service to service copies.
+func cookChecksumFile(raw rawCopyCmdArgs, cooked *cookedCopyCmdArgs) error {
+	checksumFile, flagName := raw.generateChecksumFile, "generate-checksum-file"
+	if raw.verifyChecksumFile != "" {
+		if raw.generateChecksumFile != "" {
+			return errors.New("generate-checksum-file and verify-checksum-file cannot be used together")
+		}
+		checksumFile, flagName = raw.verifyChecksumFile, "verify-checksum-file"
+	}
+	if checksumFile == "" {
+		return nil
+	}
+
+	if raw.verifyChecksumFile != "" && !cooked.fromTo.IsDownload() {
+		return errors.New("verify-checksum-file is only supported while downloading. To verify local files against a checksum file, use sha256sum -c or md5sum -c")
+	}
+	if !cooked.fromTo.IsUpload() && !cooked.fromTo.IsDownload() {
+		return fmt.Errorf("%s is only supported while uploading or downloading, since the data of service to service copies does not pass through this machine", flagName)
+	}
+	if cooked.autoDecompress || cooked.diffBaseSnapshot != "" {
+		return fmt.Errorf("%s cannot be used with decompress or diff-base-snapshot", flagName)
+	}
+	if raw.verifyChecksumFile != "" && cooked.rangedDownload {
+		return errors.New("verify-checksum-file cannot be used with offset or length, since the checksums are of the whole files")
+	}
+
+	var err error
+	cooked.checksumFile, err = filepath.Abs(checksumFile)
+	if err != nil {
+		return fmt.Errorf("invalid %s %s: %s", flagName, checksumFile, err.Error())
+	}
+	if _, err = common.ChecksumFileHash(cooked.checksumFile); err != nil {
+		return err
+	}
+
+	if raw.verifyChecksumFile != "" {
+		// read it now, so that a file that can't be read fails the command, rather than every transfer
+		if _, err = common.ReadChecksumFile(cooked.checksumFile); err != nil {
+			return fmt.Errorf("cannot read the checksum file %s: %s", checksumFile, err.Error())
+		}
+		cooked.verifyChecksums = true
+		return nil
+	}
+
+	f, err := os.Create(cooked.checksumFile)
+	if err != nil {
+		return fmt.Errorf("cannot create the file %s passed with the generate-checksum-file flag: %s", checksumFile, err.Error())
+	}
+	_ = f.Close()
+	return nil
+}
+
+func formatChecksumEntriesNotFound(summary common.ListJobSummaryResponse) string {
+	if summary.ChecksumEntriesNotFound == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n%v files in the checksum file were not found, so they could not be verified", summary.ChecksumEntriesNotFound)
+}
+
+// formatTransferDurationPercentiles is only non-empty when the job recorded transfer metrics
+func formatTransferDurationPercentiles(summary common.ListJobSummaryResponse) string {
+	p := summary.TransferDurationPercentiles
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf(
+		`
+
+Transfer durations (ms) of %v transfers:
+P50: %v
+P95: %v
+P99: %v`,
+		p.TransferCount, p.P50Milliseconds, p.P95Milliseconds, p.P99Milliseconds)
+}
+
+func formatTransfersRelayedClientSide(summary common.ListJobSummaryResponse) string {
+	if summary.TransfersRelayedClientSide == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n%v transfers used client-side relay, since the destination could not read their source", summary.TransfersRelayedClientSide)
+}
+
+func formatMD5Backfills(summary common.ListJobSummaryResponse) string {
+	if summary.MD5sBackfilled == 0 && summary.BlobsWithoutMD5 == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nContent-MD5 Computed After Copy: %v\nBlobs Without Content-MD5: %v", summary.MD5sBackfilled, summary.BlobsWithoutMD5)
+}
+
+// formatHashBackfillsInProgress is the number of destinations whose Content-MD5 is being computed, as the progress line shows it
+func formatHashBackfillsInProgress(summary common.ListJobSummaryResponse) string {
+	if summary.HashBackfillsInProgress == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %v Hash Backfills", summary.HashBackfillsInProgress)
+}
+
+func formatSourceReadRetries(summary common.ListJobSummaryResponse) string {
+	if summary.SourceReadRetries == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nReads of the source were retried %v times, since its network share dropped. The log lists them by file", summary.SourceReadRetries)
+}
+
+func formatFlushPolicy(summary common.ListJobSummaryResponse) string {
+	switch summary.FlushPolicy {
+	case "":
+		return ""
+	case common.EFlushPolicy.PerChunk().String():
+		every := "each chunk"
+		if summary.FlushIntervalBytes > 0 {
+			every = fmt.Sprintf("every %v MB", summary.FlushIntervalBytes/(1024*1024))
+		}
+		return fmt.Sprintf("\n\nFlush policy: per-chunk. The downloaded files were flushed to disk %s as they were written, "+
+			"and once they were complete, before their transfers were reported successful", every)
+	default:
+		return "\n\nFlush policy: per-file. The downloaded files were flushed to disk once they were complete, before their transfers were reported successful"
+	}
+}
+
+func formatSharingViolationRetries(summary common.ListJobSummaryResponse) string {
+	if summary.FilesRetriedForSharingViolations == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n%v files had their writes retried, since something else had them open over SMB. The warnings in the log name them, so that what holds them open can be found", summary.FilesRetriedForSharingViolations)
+}
+
+func formatDestinationConditionSkips(summary common.ListJobSummaryResponse) string {
+	if summary.TransfersSkippedForDestinationCondition == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n%v of the skipped transfers were skipped because their destination did not meet the destination condition, and was left as it is",
+		summary.TransfersSkippedForDestinationCondition)
+}
+
+func formatMissingSourceSkips(summary common.ListJobSummaryResponse) string {
+	if summary.TransfersSkippedForMissingSource == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n%v of the skipped transfers were skipped because their source was deleted after it was enumerated",
+		summary.TransfersSkippedForMissingSource)
+}
+
+func formatSoftDeletedDestinations(summary common.ListJobSummaryResponse) string {
+	result := ""
+	if summary.SoftDeletedDestinationsOverwritten > 0 {
+		result += fmt.Sprintf("\nSoft-Deleted Destinations Overwritten: %v", summary.SoftDeletedDestinationsOverwritten)
+	}
+	if summary.SoftDeletedDestinationsUndeleted > 0 {
+		result += fmt.Sprintf("\nSoft-Deleted Destinations Undeleted and Overwritten: %v", summary.SoftDeletedDestinationsUndeleted)
+	}
+	if summary.TransfersSkippedForSoftDeletedDestination > 0 {
+		result += fmt.Sprintf("\nSoft-Deleted Destinations Skipped: %v", summary.TransfersSkippedForSoftDeletedDestination)
+	}
+	if result == "" {
+		return ""
+	}
+	return "\n" + result
+}
+
+func formatSourceDeletion(summary common.ListJobSummaryResponse) string {
+	if summary.SourcesDeleted == 0 && summary.SourcesRetained == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nSources Deleted: %v\nSources Retained: %v", summary.SourcesDeleted, summary.SourcesRetained)
+}
+
+func formatTransactionsPerSecond(summary common.ListJobSummaryResponse) string {
+	result := ""
+	if summary.TransactionsPerSecondCap != 0 {
+		result += fmt.Sprintf("\n\nAverage Transactions Per Second: %.1f (capped at %v)", summary.AverageTransactionsPerSecond, summary.TransactionsPerSecondCap)
+	}
+	if summary.MetadataTransactionsPerSecondCap != 0 {
+		result += fmt.Sprintf("\n\nAverage Metadata Transactions Per Second: %.1f (capped at %v)", summary.AverageMetadataTransactionsPerSecond, summary.MetadataTransactionsPerSecondCap)
+	}
+	return result
+}
+
+// formatTransferClasses shows the requests of the transfers that move no data apart from the others,
+// when there were any, since they are throttled separately
+func formatTransferClasses(summary common.ListJobSummaryResponse) string {
+	if summary.MetadataRequests == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nMetadata Requests: %v (%v throttled)\nData Requests: %v (%v throttled)",
+		summary.MetadataRequests, summary.MetadataRequestsThrottled, summary.DataRequests, summary.DataRequestsThrottled)
+}
+
+func formatPartitionThrottling(summary common.ListJobSummaryResponse) string {
+	if summary.PartitionThrottleEvents == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nPartition Throttle Events: %v\nChunks Deferred For Hot Partitions: %v", summary.PartitionThrottleEvents, summary.ChunksDeferredForHotPartitions)
+}
+
+func formatBusyDirectories(summary common.ListJobSummaryResponse) string {
+	if summary.TransfersDeferredForBusyDirectories == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nTransfers Deferred For Busy Directories: %v (the cap per directory is set with %s)",
+		summary.TransfersDeferredForBusyDirectories, common.EEnvironmentVariable.TransfersPerDirectory().Name)
+}
+
+func formatPageBlobDiff(summary common.ListJobSummaryResponse) string {
+	if summary.DiffLogicalBytes == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nChanged Bytes: %v (%s)\nLogical Size: %v (%s)",
+		summary.DiffChangedBytes, byteSizeToString(int64(summary.DiffChangedBytes)),
+		summary.DiffLogicalBytes, byteSizeToString(int64(summary.DiffLogicalBytes)))
+}
+
+func formatExtraStats(isBenchmark bool, avgIOPS int, avgE2EMilliseconds int, networkErrorPercent float32, serverBusyPercent float32) (screenStats, logStats string) {
 	logStats = fmt.Sprintf(
 		`
 
@@ -1255,7 +2467,7 @@ Network Errors: %.2f%%
 Server Busy: %.2f%%`,
 		avgIOPS, avgE2EMilliseconds, networkErrorPercent, serverBusyPercent)
 
-	if fromTo.From() == common.ELocation.Benchmark() {
+	if isBenchmark {
 		screenStats = logStats
 		logStats = "" // since will display in the screen stats, and they get logged too
 	}
@@ -1310,7 +2522,41 @@ func init() {
 		Long:       copyCmdLongDescription,
 		Example:    copyCmdExample,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 1 { // redirection
+			if raw.fromTemplate != "" {
+				t, err := loadJobTemplate(raw.fromTemplate, "copy", cmd.Flags())
+				if err != nil {
+					return err
+				}
+				// the source and destination of the template are used when none are given, e.g. when they are the same every night
+				if len(args) == 0 && t.Source != "" {
+					args = []string{t.Source}
+					if t.Destination != "" && raw.destinationShards == "" {
+						args = append(args, t.Destination)
+					}
+				}
+			}
+
+			if raw.destinationShards != "" {
+				if len(args) != 1 {
+					return errors.New("only the source is given when the destination is sharded, since the destination is the shards")
+				}
+				shards, err := parseDestinationShards(raw.destinationShards)
+				if err != nil {
+					return err
+				}
+				raw.src = args[0]
+				raw.dst = shards.first()
+				glcm.EnableInputWatcher()
+				if cancelFromStdin {
+					glcm.EnableCancelFromStdIn()
+				}
+			} else if len(args) == 1 && (raw.discard || raw.verifyChecksumFile != "" || raw.restoreInPlace) { // download, or restore, without a destination
+				raw.src = args[0]
+				glcm.EnableInputWatcher()
+				if cancelFromStdin {
+					glcm.EnableCancelFromStdIn()
+				}
+			} else if len(args) == 1 { // redirection
 				if stdinPipeIn, err := isStdinPipeIn(); stdinPipeIn == true {
 					raw.src = pipeLocation
 					raw.dst = args[0]
@@ -1337,6 +2583,7 @@ func init() {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
+			raw.s2sPreserveAccessTierRequested = cmd.Flags().Changed("s2s-preserve-access-tier")
 			cooked, err := raw.cook()
 			if err != nil {
 				glcm.Error("failed to parse user input due to error: " + err.Error())
@@ -1345,6 +2592,9 @@ func init() {
 			glcm.Info("Scanning...")
 
 			cooked.commandString = copyHandlerUtil{}.ConstructCommandStringFromArgs()
+			template := common.NewJobTemplate("copy", raw.src, raw.dst, jobTemplateFlags(cmd.Flags()))
+			cooked.template = &template
+			cooked.templateFile = raw.fromTemplate
 			err = cooked.process()
 			if err != nil {
 				glcm.Error("failed to perform copy command due to error: " + err.Error())
@@ -1362,13 +2612,37 @@ func init() {
 	cpCmd.PersistentFlags().StringVar(&raw.includePath, "include-path", "", "Include only these paths when copying. "+
 		"This option does not support wildcard characters (*). Checks relative path prefix (For example: myFolder;myFolder/subDirName/file.pdf).")
 	cpCmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when copying. "+ // Currently, only exclude-path is supported alongside account traversal.
-		"This option does not support wildcard characters (*). Checks relative path prefix(For example: myFolder;myFolder/subDirName/file.pdf). When used in combination with account traversal, paths do not include the container name.")
+		"This option does not support wildcard characters (*). Checks relative path prefix(For example: myFolder;myFolder/subDirName/file.pdf). When used in combination with account traversal, paths do not include the container name. "+
+		"The directories that are excluded are not enumerated at all.")
+	cpCmd.PersistentFlags().StringVar(&raw.includePathPattern, "include-path-pattern", "", "Include only the files whose relative path matches these patterns when copying (For example: logs/2023/*;**/*.csv). "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Separate the patterns by using a ';'.")
+	cpCmd.PersistentFlags().StringVar(&raw.excludePathPattern, "exclude-path-pattern", "", "Exclude the files whose relative path matches these patterns when copying (For example: **/temp/**;*.tmp). "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Exclusions win over inclusions. "+
+		"The directories matched by a pattern ending in '/**' are not enumerated at all.")
+	cpCmd.PersistentFlags().StringVar(&raw.prunePattern, "prune-pattern", "", "Do not enumerate the directories whose names match these patterns, wherever they are, nor anything under them (For example: node_modules;.git). "+
+		"This option supports wildcard characters (*). Separate the patterns by using a ';'.")
+	cpCmd.PersistentFlags().StringVar(&raw.includeContainer, "include-container", "", "When the source is a whole account, only copy the containers (or shares, or filesystems) whose names match these patterns (For example: logs-*;backup). "+
+		"This option supports wildcard characters (*). Separate the patterns by using a ';'.")
+	cpCmd.PersistentFlags().StringVar(&raw.excludeContainer, "exclude-container", "", "When the source is a whole account, do not copy the containers (or shares, or filesystems) whose names match these patterns, nor enumerate them. "+
+		"This option supports wildcard characters (*). Separate the patterns by using a ';'. Exclusions win over inclusions.")
+	cpCmd.PersistentFlags().IntVar(&raw.maxDepth, "max-depth", 0, "Only copy the files that are at most this many levels below the source, where the files directly in it are at level 1. "+
+		"The deeper directories are not enumerated. Applies to local, Blob, Azure Files and ADLS Gen2 sources. (default 0, which has no limit)")
 	// This flag is implemented only for Storage Explorer.
-	cpCmd.PersistentFlags().StringVar(&raw.listOfFilesToCopy, "list-of-files", "", "Defines the location of text file which has the list of only files to be copied.")
+	cpCmd.PersistentFlags().StringVar(&raw.listOfFilesToCopy, "list-of-files", "", "Defines the location of text file which has the list of only files to be copied. "+
+		"It has one path per line, relative to the source and not URL-encoded. A line that is a quoted string, with Go escapes, stands for the path it quotes. "+
+		"Use '-' to read it from stdin.")
+	cpCmd.PersistentFlags().StringVar(&raw.listOfFilesFormat, "list-of-files-format", "text", "The format of the list of files. Available options: text (the default), which has one path per line; "+
+		"and ndjson, in which each line is a JSON object, e.g. {\"source\": \"dir/a.txt\", \"destination\": \"renamed/a.txt\", \"metadata\": {\"key\": \"value\"}}. "+
+		"The source is the path relative to the source, the optional destination is the path relative to the destination that it goes to instead, "+
+		"and the optional metadata replaces what the entry would otherwise get. For a directory, they apply to everything under it.")
+	cpCmd.PersistentFlags().BoolVar(&raw.listOfFilesStrict, "list-of-files-strict", false, "Fail the job at the first malformed line of the list of files, rather than skip it. Either way, the line is reported with its number.")
 	cpCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude these files when copying. This option supports wildcard characters (*)")
 	cpCmd.PersistentFlags().StringVar(&raw.forceWrite, "overwrite", "true", "Overwrite the conflicting files and blobs at the destination if this flag is set to true. (default 'true') Possible values include 'true', 'false', 'prompt', and 'ifSourceNewer'.")
 	cpCmd.PersistentFlags().BoolVar(&raw.autoDecompress, "decompress", false, "Automatically decompress files when downloading, if their content-encoding indicates that they are compressed. The supported content-encoding values are 'gzip' and 'deflate'. File extensions of '.gz'/'.gzip' or '.zz' aren't necessary, but will be removed if present.")
 	cpCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", false, "Look into sub-directories recursively when uploading from local file system.")
+	cpCmd.PersistentFlags().BoolVar(&raw.asSubdir, "as-subdir", true, "Place a directory source under the destination as a subdirectory of its own name, e.g. copying X to Y makes Y/X (the default). "+
+		"If false, only the contents of the directory are placed in the destination, as a trailing /* on the source (e.g. X/*) does. "+
+		"How the source is placed is printed when the job starts, and in the estimate-only summary.")
 	cpCmd.PersistentFlags().StringVar(&raw.fromTo, "from-to", "", "Optionally specifies the source destination combination. For Example: LocalBlob, BlobLocal, LocalBlobFS.")
 	cpCmd.PersistentFlags().StringVar(&raw.excludeBlobType, "exclude-blob-type", "", "Optionally specifies the type of blob (BlockBlob/ PageBlob/ AppendBlob) to exclude when copying blobs from the container "+
 		"or the account. Use of this flag is not applicable for copying data from non azure-service to service. More than one blob should be separated by ';'. ")
@@ -1389,15 +2663,176 @@ func init() {
 	cpCmd.PersistentFlags().BoolVar(&raw.preserveLastModifiedTime, "preserve-last-modified-time", false, "Only available when destination is file system.")
 	cpCmd.PersistentFlags().BoolVar(&raw.putMd5, "put-md5", false, "Create an MD5 hash of each file, and save the hash as the Content-MD5 property of the destination blob or file. (By default the hash is NOT created.) Only available when uploading.")
 	cpCmd.PersistentFlags().StringVar(&raw.md5ValidationOption, "check-md5", common.DefaultHashValidationOption.String(), "Specifies how strictly MD5 hashes should be validated when downloading. Only available when downloading. Available options: NoCheck, LogOnly, FailIfDifferent, FailIfDifferentOrMissing. (default 'FailIfDifferent')")
-	cpCmd.PersistentFlags().StringVar(&raw.includeFileAttributes, "include-attributes", "", "(Windows only) Include files whose attributes match the attribute list. For example: A;S;R")
-	cpCmd.PersistentFlags().StringVar(&raw.excludeFileAttributes, "exclude-attributes", "", "(Windows only) Exclude files whose attributes match the attribute list. For example: A;S;R")
+	cpCmd.PersistentFlags().StringVar(&raw.includeFileAttributes, "include-attributes", "", "(Windows only) Include files whose attributes match the attribute list. "+
+		"The attributes are given by their letters or their names. For example: A;S;R, or Archive;System;ReadOnly")
+	cpCmd.PersistentFlags().StringVar(&raw.excludeFileAttributes, "exclude-attributes", "", "(Windows only) Exclude files whose attributes match the attribute list. "+
+		"The attributes are given by their letters or their names. For example: A;S;R, or Hidden;System;Temporary")
+	cpCmd.PersistentFlags().BoolVar(&raw.excludeDotfiles, "exclude-dotfiles", false, "Exclude the files whose names start with a dot, and everything in the directories whose names do (For example: .DS_Store, .git). "+
+		"The summary shows how many files the attribute filters and this one excluded.")
+	cpCmd.PersistentFlags().BoolVar(&raw.clearArchiveBit, "clear-archive-bit", false, "(Windows only) Clear the archive attribute of each local file once it is uploaded, "+
+		"so that a later copy with include-attributes=A only uploads the files that changed since. The attribute is kept on the files that changed while they were uploaded.")
+	cpCmd.PersistentFlags().BoolVar(&raw.noCreateDstContainer, "no-create-destination-container", false, "Don't create the destination container, share or file system if it doesn't exist, "+
+		"so that the transfers into it fail instead. By default it's created when the job starts, if the credential permits it.")
+	cpCmd.PersistentFlags().BoolVar(&raw.breakLeaseOnOverwrite, "break-lease-on-overwrite", false, "Break the lease of each destination blob that can't be overwritten because it's leased, "+
+		"and overwrite it. Each broken lease is recorded in the log. By default, such blobs fail.")
+	cpCmd.PersistentFlags().BoolVar(&raw.protectDestinationWithLease, "protect-destination-with-lease", false, "Lease each destination block blob, page blob or ADLS Gen2 file while it's written, "+
+		"so that nothing else can write to it at the same time. A destination that something else has leased fails with the status DestinationBusy.")
+	cpCmd.PersistentFlags().StringVar(&raw.destinationCondition, "destination-condition", "", "Only write each destination blob or ADLS Gen2 file if it meets this condition, "+
+		"so that jobs writing the same destinations at the same time don't silently replace each other's data. Use if-none-match=* to only create destinations that don't exist yet, "+
+		"or if-unmodified-since=<time> (RFC 3339, e.g. 2026-01-02T15:04:05Z) to only replace destinations that weren't modified since then. "+
+		"A destination that doesn't meet it is left as it is, and its transfer is skipped and counted separately in the summary.")
+	cpCmd.PersistentFlags().BoolVar(&raw.forceIfReadOnly, "force-if-read-only", false, "When overwriting a destination Azure file that has the ReadOnly attribute, "+
+		"clear the attribute to overwrite it, and restore it afterwards. By default, such files fail.")
+	cpCmd.PersistentFlags().DurationVar(&raw.retryOnSharingViolation, "retry-on-sharing-violation", 0, "For this long (e.g. 5m), retry the writes to a destination Azure file "+
+		"that something else, such as a backup agent, has open over SMB, before its transfer fails. The summary says how many files were retried. By default, they're not retried.")
+	cpCmd.PersistentFlags().DurationVar(&raw.waitOnShareFull, "wait-on-share-full", 0, "Once a destination Azure file share is found full, wait for this long (e.g. 30m) "+
+		"for its quota to be raised, before the transfers to it fail. By default, they fail straight away, with the status ShareFull, "+
+		"and resuming the job once the quota is raised transfers them, without copying the completed files again.")
+	cpCmd.PersistentFlags().StringVar(&raw.missingSourceHandling, "missing-source-handling", "fail", "What to do with the files whose source is deleted between when it's enumerated and when it's transferred, "+
+		"as happens when the source container is in use while the job runs. Available options: fail (the default), which fails their transfers; "+
+		"and skip, which skips them, with the status SkippedSourceDeleted, so that they don't make the job fail. They're counted separately in the summary. "+
+		"Only a 'not found' error with one of the codes for a deleted blob, file, container or share counts as a deleted source, so that an expired or "+
+		"insufficient SAS still fails. Only supported when the source is Azure Blob, Azure Files or ADLS Gen2.")
+	cpCmd.PersistentFlags().StringVar(&raw.softDeletedDestination, "soft-deleted-destination", "", "What to do with the destination blobs that don't exist, but are soft-deleted, "+
+		"which writing to either fails or creates a new blob next to the deleted one, depending on the settings of the account. Available options: overwrite, "+
+		"which writes them like the blobs that don't exist; undelete-and-overwrite, which undeletes them, along with their soft-deleted snapshots, before writing them; "+
+		"and skip, which skips them, with the status SkippedDestinationSoftDeleted. What's done is logged for each file, and counted in the summary. "+
+		"By default, destinations aren't checked for being soft-deleted. Checking takes a listing of the container for each destination that doesn't exist. "+
+		"Only supported when the destination is Azure Blob.")
+	cpCmd.PersistentFlags().BoolVar(&raw.preserveSourceLMTAsMetadata, "preserve-source-lmt-as-metadata", false, "Keep the last modified time of each source "+
+		"in the metadata of its destination, under the key "+common.PreservedLastModifiedTimeMetadataKey+" (in RFC3339, UTC), since the last modified time of "+
+		"a blob can't be set. A destination whose source already keeps that key gets the time of the source's own source. "+
+		"When a blob or file that keeps the key is downloaded, the time is what the local file's last modified time is set to, if it's preserved. "+
+		"Only supported when the destination is Azure Blob or Azure Files.")
+	cpCmd.PersistentFlags().StringVar(&raw.tempNameSuffix, "temp-name-suffix", ".azcopy-partial", "Write each destination ADLS Gen2 file or Azure file under its name with this suffix, "+
+		"and rename it once it's complete, so that nothing picks it up while it's partly written. A resumed job carries on with the temporary files it left. "+
+		"Set it to an empty string to write the files under their own names.")
 	cpCmd.PersistentFlags().BoolVar(&raw.CheckLength, "check-length", true, "Check the length of a file on the destination after the transfer. If there is a mismatch between source and destination, the transfer is marked as failed.")
 	cpCmd.PersistentFlags().BoolVar(&raw.s2sPreserveProperties, "s2s-preserve-properties", true, "Preserve full properties during service to service copy. "+
 		"For AWS S3 and Azure File non-single file source, the list operation doesn't return full properties of objects and files. To preserve full properties, AzCopy needs to send one additional request per object or file.")
 	cpCmd.PersistentFlags().BoolVar(&raw.s2sPreserveAccessTier, "s2s-preserve-access-tier", true, "Preserve access tier during service to service copy. "+
 		"Please refer to [Azure Blob storage: hot, cool, and archive access tiers](https://docs.microsoft.com/azure/storage/blobs/storage-blob-storage-tiers) to ensure destination storage account supports setting access tier. "+
-		"In the cases that setting access tier is not supported, please use s2sPreserveAccessTier=false to bypass copying access tier. (default true). ")
+		"In the cases that setting access tier is not supported, please use s2sPreserveAccessTier=false to bypass copying access tier. "+
+		"Blobs in the Archive tier fail, since they must be rehydrated before they can be read. "+
+		"Premium page blob tiers are not preserved when the destination is not a premium account. (default true). ")
 	cpCmd.PersistentFlags().BoolVar(&raw.s2sSourceChangeValidation, "s2s-detect-source-changed", false, "Check if source has changed after enumerating. ")
+	cpCmd.PersistentFlags().StringVar(&raw.metricsFile, "metrics-file", "", "Write the timings of each transfer (time in queue, time to first byte, duration, bytes, retries and worker) to this file as the transfers complete, "+
+		"and show the 50th, 95th and 99th percentiles of the transfer durations at the end of the job. The file is CSV, unless its name ends in .json or .ndjson, in which case it has one JSON object per line.")
+	cpCmd.PersistentFlags().StringVar(&raw.metricsListen, "metrics-listen", "", "Serve the metrics of the job for Prometheus to scrape, at /metrics on this address and port, e.g. :9090 or 127.0.0.1:9090. "+
+		"They include the bytes transferred, the transfers by final status, the throughput, the active chunk workers, the retries by HTTP status, the bandwidth cap and the progress of the enumeration. "+
+		"The metric names start with azcopy_, and no paths or URLs are ever in them. Off by default.")
+	cpCmd.PersistentFlags().Uint32Var(&raw.failFastThreshold, "fail-fast-threshold", 0, "Abort the job once more than this many transfers failed, e.g. because the SAS is wrong or the container doesn't exist. "+
+		"The transfers that were not done yet are not attempted, the job completes with errors, and the error that most transfers failed with is shown. Off by default.")
+	cpCmd.PersistentFlags().StringVar(&raw.failFastRate, "fail-fast-rate", "", "Abort the job once transfers fail faster than this, over the last minute: either a number of failures per minute, e.g. 100/min, "+
+		"or a percentage of the transfers that finished, e.g. 90% (only once at least 50 finished). Off by default.")
+	cpCmd.PersistentFlags().StringVar(&raw.onCompleteExec, "on-complete-exec", "", "Run this command once the job reaches a terminal state (completed, completed with errors, failed or cancelled), e.g. \"/path/script {jobid} {status}\". "+
+		"{jobid} and {status} are replaced with the ID of the job and its final status. The command runs in the shell of the OS, is killed after 5 minutes, "+
+		"and what it writes is put in the job log. A follow-up job doesn't run it, and neither does a later resume of the job, unless the resume is given it too.")
+	cpCmd.PersistentFlags().BoolVar(&raw.notify, "notify", false, "Raise a desktop notification once the job reaches a terminal state. On Linux it needs notify-send. Off by default.")
+	cpCmd.PersistentFlags().BoolVar(&raw.discard, "discard", false, "Download the source without saving it anywhere, e.g. to validate the MD5 hashes of the files or to measure read throughput. "+
+		"No destination is given. The data is hashed, length checked and counted just as in a real download, but no files or folders are created.")
+	cpCmd.PersistentFlags().StringVar(&raw.generateChecksumFile, "generate-checksum-file", "", "Write the checksum of each file that is transferred to this file, as the transfers complete, "+
+		"in the format of sha256sum (if the name ends in .sha256) or md5sum (if it ends in .md5), e.g. to verify the files later with those tools or with verify-checksum-file. "+
+		"The checksums are of the bytes that were read during the transfer. Only for uploads and downloads. The paths are relative to the destination.")
+	cpCmd.PersistentFlags().StringVar(&raw.verifyChecksumFile, "verify-checksum-file", "", "Download the source, and verify each file against its checksum in this file, which is in the format of sha256sum or md5sum, "+
+		"as written by generate-checksum-file. Unless a destination is given, nothing is saved. "+
+		"Files that are not in the checksum file, or whose checksum doesn't match, fail, and files in the checksum file that are not found are reported at the end.")
+	cpCmd.PersistentFlags().Int64Var(&raw.offset, "offset", 0, "Download only the part of a single file that starts this many bytes into it. "+
+		"The part is saved from the start of the local file, and its MD5 hash is not checked, since the stored hash is of the whole file.")
+	cpCmd.PersistentFlags().Int64Var(&raw.length, "length", 0, "Download only this many bytes of a single file, starting at offset. By default, the download goes up to the end of the file.")
+	cpCmd.PersistentFlags().BoolVar(&raw.estimateOnly, "estimate-only", false, "Only enumerate the source, applying all the filters, and print the number of files and bytes that would be transferred, "+
+		"without creating a job. Unlike a listing, the files are only counted up, so it works for any number of them.")
+	cpCmd.PersistentFlags().Float64Var(&raw.pricePerGB, "price-per-gb", 0, "Used with estimate-only, to also print the approximate egress cost of the transfer at this price per GB.")
+	cpCmd.PersistentFlags().Uint64Var(&raw.maxTransactions, "max-transactions", 0, "Stop once the job is predicted to make more than this many storage transactions, while the source is enumerated, "+
+		"or abort the job once its transfers made more than this many. The estimate-only summary shows the prediction, and what it assumes.")
+	cpCmd.PersistentFlags().StringVar(&raw.lowSpaceAction, "low-space-action", "warn", "What a download does when the local volume has less free space than the files need, "+
+		"keeping a margin of AZCOPY_LOW_SPACE_MARGIN_MB free: fail stops the job before it transfers them, warn goes ahead, and prompt asks. "+
+		"The space is checked as the source is enumerated, and again before each file is started: files are held back while there's no room for them, "+
+		"which is reported, asked about, or makes the job abort in the same way. (default 'warn')")
+	cpCmd.PersistentFlags().StringVar(&raw.enumerationCache, "enumeration-cache", "", "Keep the listing of the source that the job copied in this file (the size, last modified time and MD5 of each file, and its path at the destination), "+
+		"so that a later run of the same copy skips the files that are unchanged since, without checking the destination for them. The files that changed are checked and copied as usual. "+
+		"The file is replaced once the job is done, but only if every transfer succeeded: otherwise it's deleted, so that the next run checks every file.")
+	cpCmd.PersistentFlags().BoolVar(&raw.noCacheTrust, "no-cache-trust", false, "Used with enumeration-cache, to check every file at the destination, as if there were no cache, and then refresh it. "+
+		"Use it every so often, e.g. in a nightly run, to catch changes that were made at the destination, rather than through the copy.")
+	cpCmd.PersistentFlags().BoolVar(&raw.includeDeleted, "include-deleted", false, "Also copy the soft-deleted blobs of the source. Each of them that passes the filters is undeleted just before it's copied, "+
+		"so it's live again at the source afterwards. A blob that has both a live and a soft-deleted version is copied as it is live. The summary shows how many blobs were undeleted.")
+	cpCmd.PersistentFlags().BoolVar(&raw.restoreInPlace, "restore-in-place", false, "Only undelete the soft-deleted blobs of the source that pass the filters, without copying anything anywhere. "+
+		"No destination is given, and no job is created.")
+	cpCmd.PersistentFlags().BoolVar(&raw.preferDeletedVersion, "prefer-deleted-version", false, "Used with include-deleted or restore-in-place, to undelete the soft-deleted version of a blob "+
+		"even though there's a live blob of the same name, rather than leave it as it is.")
+	cpCmd.PersistentFlags().StringVar(&raw.s2sFallback, "s2s-fallback", "none", "Specifies what to do when the destination service cannot read the source of a service to service copy, "+
+		"e.g. because the source is behind a firewall or a private endpoint. Available options: none, client-side. "+
+		"With client-side, such transfers download the data to this machine and upload it from there instead. (default 'none').")
+	cpCmd.PersistentFlags().StringVar(&raw.s2sComputeMD5, "s2s-compute-md5", "", "Compute the Content-MD5 of the destination block blobs of a service to service copy "+
+		"by reading them back once they're written, and set it, since a blob that's copied in blocks is left without one unless its source has one. "+
+		"Available options: missing, for the blobs that would be left without one; and always, for every blob, which also checks those whose source has one. "+
+		"The blobs whose Content-MD5 is being computed show in the progress, and the summary counts those that got one, and those left without one. "+
+		"By default, the blobs left without one are only reported.")
+	cpCmd.PersistentFlags().StringVar(&raw.transferOrder, "transfer-order", "as-enumerated", "The order in which the files are transferred: largest-first, smallest-first or as-enumerated. "+
+		"With largest-first, the large files start early, and the small files fill the remaining capacity, rather than one large file being left for last. "+
+		"The order is approximate, since the source is enumerated as the job runs: the files are ordered in batches of up to 10000, as they're enumerated. (default 'as-enumerated')")
+	cpCmd.PersistentFlags().StringVar(&raw.flushPolicy, "flush-policy", "none", "When the downloaded files are flushed to disk (with fsync, or FlushFileBuffers on Windows): "+
+		"none leaves it to the OS, per-file flushes each file once it's complete, before its transfer is reported successful, "+
+		"and per-chunk also flushes it as it's written, every --flush-interval-mb. Flushing makes sure that the files survive a power cut "+
+		"once the job reports them done, but slows the downloads down, the more so the more often the files are flushed.")
+	cpCmd.PersistentFlags().Uint32Var(&raw.flushIntervalMB, "flush-interval-mb", 0, "With --flush-policy=per-chunk, flush each file every time this many MB more of it are written. "+
+		"By default, it's flushed after each chunk.")
+	cpCmd.PersistentFlags().StringVar(&raw.diffBaseSnapshot, "diff-base-snapshot", "", "Copy only the pages of a page blob that changed since this snapshot of it, e.g. for an incremental backup of a managed disk. "+
+		"The source is usually a newer snapshot, and the destination must already hold the content of the base snapshot, which is checked by its length and, where possible, its MD5 hash. "+
+		"Ranges that were cleared since the base snapshot are cleared at the destination too.")
+	cpCmd.PersistentFlags().BoolVar(&raw.reuseUncommittedBlocks, "reuse-uncommitted-blocks", false, "Keep the blocks that an earlier copy of the same files to the same block blobs staged, but never committed, "+
+		"rather than sending them again. That is always done when a job is resumed. Blocks are only kept if they are of the same version of the source file, and of the same block size. "+
+		"When this is set, the blocks that a failed or cancelled transfer staged are kept too, rather than deleted, and the service deletes them after a week if they are never committed.")
+	cpCmd.PersistentFlags().BoolVar(&raw.deleteSourceAfterTransfer, "delete-source-after-transfer", false, "Delete the source of each file once it is copied, which moves the files rather than copying them. "+
+		"A source is only deleted once its transfer succeeds, including the length check and the MD5 check as they are configured, and a source that failed or was skipped is never touched. "+
+		"Local files and blobs are kept if they changed after they were listed. Supported for uploads and downloads, and for copies between Azure Blob and Azure File.")
+	cpCmd.PersistentFlags().BoolVar(&raw.ignoreSASValidation, ignoreSASValidationFlag, false, "Don't check, before the enumeration, that the permissions of the SASs allow what the job will do, e.g. Read and List on the source and Write on the destination. "+
+		"Use it for SASs whose permissions azcopy can't tell from the token. By default, a job whose SAS lacks a permission fails before it starts.")
+	cpCmd.PersistentFlags().BoolVar(&raw.bestEffortFeatures, bestEffortFeaturesFlag, false, "Disable, with a warning, the requested features that the destination turns out not to support, "+
+		"e.g. block-blob-tier on a premium account, or blob-tags on an account with a hierarchical namespace, rather than fail the job before it starts. "+
+		"The destination is probed for them before the enumeration, and s2s-preserve-access-tier is only probed when it's given explicitly.")
+	cpCmd.PersistentFlags().BoolVar(&raw.continueOnEnumerationErrors, "continue-on-enumeration-errors", false, "Skip the directories, containers and files that cannot be enumerated, "+
+		"e.g. because access to them is denied, and carry on with the rest, rather than fail the job. Each of them is logged with its error, "+
+		"and the job then completes with errors. The summary shows how many there were, and the file that lists them.")
+	cpCmd.PersistentFlags().StringVar(&raw.invalidNameHandling, "invalid-name-handling", "fail", "What to do with the files whose names the destination does not allow, e.g. blobs with a backslash, a control character or a trailing dot, "+
+		"copied to Azure Files or to Windows. Available options: fail (the default), which leaves the names as they are, so that their transfers fail; "+
+		"skip, which leaves the files out when they are enumerated; and sanitize, which replaces each character that is not allowed, and each '%', "+
+		"of the parts of the path that have such characters, with '%' and its two hexadecimal digits (e.g. 'a:b.' becomes 'a%3Ab%2E'). "+
+		"The original names are mapped to the sanitized ones in a file next to the job's log, which the summary points to.")
+	cpCmd.PersistentFlags().StringVar(&raw.failedFilesOutput, "failed-files-output", "", "When the job completes with failures, write the paths of the files that failed, relative to the source, to this file, "+
+		"in the format that list-of-files takes, so that just those files can be copied again, e.g. with other flags. "+
+		"A path that cannot be written as a line by itself, e.g. because it has a line break or leading or trailing white space, is written as a quoted string, with Go escapes.")
+	cpCmd.PersistentFlags().BoolVar(&raw.skipPermissionErrors, "skip-permission-errors", false, "Skip the local files that cannot be opened or read because permission is denied, rather than fail them. "+
+		"Each of them is logged, counted as skipped rather than failed, and listed with the other skipped files.")
+	cpCmd.PersistentFlags().BoolVar(&raw.skipLockedFiles, "skip-locked-files", false, "Skip the local files that cannot be opened or read because another process has them open without sharing them, or has locked them, "+
+		"rather than fail them. That only happens on Windows. Each of them is logged, counted as skipped rather than failed, and listed with the other skipped files.")
+	cpCmd.PersistentFlags().BoolVar(&raw.useVss, "use-vss", false, "Read the local source from a Volume Shadow Copy of its volume, taken as the job starts, "+
+		"so that the files that other processes hold open or have locked are copied as they were then. Only supported on Windows, and AzCopy must run as an administrator. "+
+		"The shadow copy is deleted when AzCopy exits, so a job that didn't complete must be run again rather than resumed.")
+	cpCmd.PersistentFlags().StringVar(&raw.attributesManifest, "attributes-manifest", "", "Give uploaded files their own content headers and metadata, from a JSON file. "+
+		"Its keys are the paths of the files relative to the source, or patterns of them such as 'images/*.png', and when several keys match a file the longest one wins. "+
+		"Its values may set contentType, cacheControl, contentDisposition, contentEncoding, and metadata, which is an object of names and values. "+
+		"Those that are set replace the ones of the other flags, and metadata is not set on ADLS Gen2 files. The keys that matched no file are listed at the end of the job.")
+	cpCmd.PersistentFlags().StringVar(&raw.destinationTemplate, "destination-template", "", "Put each source under the destination at the path that this template expands to, "+
+		"rather than at its path relative to the source, e.g. \"{year}/{month}/{day}/{filename}\" or \"{ext}/{filename}\". "+
+		"The variables are {year}, {month}, {day} and {hour} of the last modification of the source (in UTC), {filename}, {basename} (without the extension), {ext}, "+
+		"{dir} (the directory of the source, relative to the source of the job) and {container} (when copying an account). "+
+		"Empty segments are dropped, and a warning is given when two sources expand to the same destination. Not supported by sync.")
+	cpCmd.PersistentFlags().StringVar(&raw.destinationShards, destinationShardsFlag, "", "Spread the files across these blob containers, rather than copy them to one destination, to get past the throughput limit of a single account. "+
+		"Give the URLs of the containers, with SAS tokens of the same permissions, separated by ';', in place of the destination. "+
+		"Each file goes to the container picked by the FNV-1a hash of its path relative to the destination, modulo the number of containers, "+
+		"and the container of each path is written to a manifest next to the job's log. A resumed job sends each file to the container that it was assigned, "+
+		"given the same containers with 'azcopy jobs resume --destination-shards'. The summary of the job shows the bytes and throughput of each container.")
+	cpCmd.PersistentFlags().StringArrayVar(&raw.headerRules, "header-rule", nil, "Set content headers on the uploaded files that a pattern matches, e.g. \"pattern=*.html;cacheControl=no-cache\". "+
+		"Besides the pattern, a rule may set contentType, cacheControl and contentEncoding, which replace those of the other flags, and the content type that was detected. "+
+		"A pattern without a slash matches the names of files, and one with a slash matches their paths relative to the source, and everything under the directories it matches, "+
+		"e.g. \"pattern=assets/*;cacheControl=max-age=31536000, immutable\". Give the flag once for each rule. The first rule that matches a file applies to it, and a resumed job applies the same rules. "+
+		"The headers of an attributes-manifest entry replace those of a rule.")
+	flagResets = append(flagResets, func() { raw.headerRules = nil })
+	cpCmd.PersistentFlags().BoolVar(&raw.printHeaderRules, "print-header-rules", false, "Used with estimate-only and header-rule, to list each file with the header rule that matches it.")
+	cpCmd.PersistentFlags().StringVar(&raw.fromTemplate, fromTemplateFlag, "", "Run the job with the flags of this job template, as written by 'azcopy jobs export-template'. "+
+		"The flags given on the command line override those of the template, and the source and destination of the template are used when none are given. "+
+		"The hash of the job's flags is written in its log, so that the job can be tied to the template.")
 	cpCmd.PersistentFlags().StringVar(&raw.s2sInvalidMetadataHandleOption, "s2s-handle-invalid-metadata", common.DefaultInvalidMetadataHandleOption.String(), "Specifies how invalid metadata keys are handled. Available options: ExcludeIfInvalid, FailIfInvalid, RenameIfInvalid. (default 'ExcludeIfInvalid').")
 
 	// s2sGetPropertiesInBackend is an optional flag for controlling whether S3 object's or Azure file's full properties are get during enumerating in frontend or
@@ -1424,4 +2859,7 @@ func init() {
 	// Hide the flush-threshold flag since it is implemented only for CI.
 	cpCmd.PersistentFlags().Uint32Var(&ste.ADLSFlushThreshold, "flush-threshold", 7500, "Adjust the number of blocks to flush at once on accounts that have a hierarchical namespace.")
 	cpCmd.PersistentFlags().MarkHidden("flush-threshold")
+
+	cpCmd.PersistentFlags().Int64Var(&ste.ADLSSmallFileThreshold, "small-file-threshold", ste.ADLSSmallFileThreshold, "Upload files that are smaller than this many bytes to accounts that have a hierarchical namespace "+
+		"with one request that both writes and flushes them, rather than with separate requests. Files whose MD5 is kept (--put-md5) always use separate requests. 0 turns this off.")
 }
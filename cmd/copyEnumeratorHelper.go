@@ -8,6 +8,7 @@ import (
 
 	"github.com/Azure/azure-storage-azcopy/azbfs"
 	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/azure-storage-file-go/azfile"
 
@@ -20,24 +21,19 @@ func addTransfer(e *common.CopyJobPartOrderRequest, transfer common.CopyTransfer
 	transfer.Source = strings.TrimPrefix(transfer.Source, e.SourceRoot)
 	transfer.Destination = strings.TrimPrefix(transfer.Destination, e.DestinationRoot)
 
+	// with a sharded destination, the transfer waits with the others of its shard, and they're dispatched in parts of their own
+	if cca.destinationShards != nil {
+		return cca.destinationShards.addTransfer(e, transfer, cca)
+	}
+
 	// dispatch the transfers once the number reaches NumOfFilesPerDispatchJobPart
 	// we do this so that in the case of large transfer, the transfer engine can get started
 	// while the frontend is still gathering more transfers
 	if len(e.Transfers) == NumOfFilesPerDispatchJobPart {
-		shuffleTransfers(e.Transfers)
-		resp := common.CopyJobPartOrderResponse{}
-
-		Rpc(common.ERpcCmd.CopyJobPartOrder(), (*common.CopyJobPartOrderRequest)(e), &resp)
-
-		if !resp.JobStarted {
-			return fmt.Errorf("copy job part order with JobId %s and part number %d failed because %s", e.JobID, e.PartNum, resp.ErrorMsg)
-		}
-		// if the current part order sent to engine is 0, then start fetching the Job Progress summary.
-		if e.PartNum == 0 {
-			cca.waitUntilJobCompletion(false)
+		e.ListingMarker = cca.listing.resumePoint()
+		if err := dispatchPart(e, cca); err != nil {
+			return err
 		}
-		e.Transfers = []common.CopyTransfer{}
-		e.PartNum++
 	}
 
 	// only append the transfer after we've checked and dispatched a part
@@ -47,6 +43,25 @@ func addTransfer(e *common.CopyJobPartOrderRequest, transfer common.CopyTransfer
 	return nil
 }
 
+// dispatchPart sends the transfers that have been gathered as a part of the job, and starts on the next part
+func dispatchPart(e *common.CopyJobPartOrderRequest, cca *cookedCopyCmdArgs) error {
+	shuffleTransfers(e.Transfers)
+	resp := common.CopyJobPartOrderResponse{}
+
+	Rpc(common.ERpcCmd.CopyJobPartOrder(), (*common.CopyJobPartOrderRequest)(e), &resp)
+
+	if !resp.JobStarted {
+		return fmt.Errorf("copy job part order with JobId %s and part number %d failed because %s", e.JobID, e.PartNum, resp.ErrorMsg)
+	}
+	// if the current part order sent to engine is 0, then start fetching the Job Progress summary.
+	if e.PartNum == 0 {
+		cca.waitUntilJobCompletion(false)
+	}
+	e.Transfers = []common.CopyTransfer{}
+	e.PartNum++
+	return nil
+}
+
 // this function shuffles the transfers before they are dispatched
 // this is done to avoid hitting the same partition continuously in an append only pattern
 // TODO this should probably be removed after the high throughput block blob feature is implemented on the service side
@@ -64,7 +79,8 @@ func dispatchFinalPart(e *common.CopyJobPartOrderRequest, cca *cookedCopyCmdArgs
 
 	if !resp.JobStarted {
 		// Output the log location and such
-		glcm.Init(common.GetStandardInitOutputBuilder(cca.jobID.String(), fmt.Sprintf("%s%s%s.log", azcopyLogPathFolder, common.OS_PATH_SEPARATOR, cca.jobID), cca.isCleanupJob, cca.cleanupJobMessage))
+		glcm.Init(common.GetStandardInitOutputBuilder(cca.jobID.String(), common.JobLogFilePath(azcopyLogPathFolder, cca.jobID), cca.isCleanupJob, cca.cleanupJobMessage,
+			ste.ClientRequestIDPrefix(cca.jobID, clientRequestIDPrefix)))
 
 		if resp.ErrorMsg == common.ECopyJobPartOrderErrorType.NoTransfersScheduledErr() {
 			return NothingScheduledError
@@ -75,6 +91,7 @@ func dispatchFinalPart(e *common.CopyJobPartOrderRequest, cca *cookedCopyCmdArgs
 
 	// set the flag on cca, to indicate the enumeration is done
 	cca.isEnumerationComplete = true
+	cca.perf.enumerationDone()
 
 	// if the current part order sent to engine is 0, then start fetching the Job Progress summary.
 	if e.PartNum == 0 {
@@ -183,7 +200,7 @@ func enumerateBlobsInContainer(ctx context.Context, containerURL azblob.Containe
 		for _, blobItem := range listContainerResp.Segment.BlobItems {
 			// If the blob represents a folder as per the conditions mentioned in the
 			// api doesBlobRepresentAFolder, then skip the blob.
-			if gCopyUtil.doesBlobRepresentAFolder(blobItem.Metadata) {
+			if gCopyUtil.doesBlobRepresentAFolder(*blobItem.Properties.ContentLength, blobItem.Metadata) {
 				continue
 			}
 
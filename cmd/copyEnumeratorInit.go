@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -14,6 +15,7 @@ import (
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/azure-storage-file-go/azfile"
 
+	"github.com/Azure/azure-storage-azcopy/azbfs"
 	"github.com/Azure/azure-storage-azcopy/common"
 	"github.com/Azure/azure-storage-azcopy/ste"
 )
@@ -42,12 +44,14 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 
 	if srcCredInfo, isPublic, err = getCredentialInfoForLocation(ctx, cca.fromTo.From(), cca.source, cca.sourceSAS, true); err != nil {
 		return nil, err
+	} else if useOAuthForS2SSourceIfPossible(&jobPartOrder.CredentialInfo, cca.fromTo, srcCredInfo) {
+		// nothing more to check, since the OAuth token is used for the source, which therefore needs no SAS
 		// If S2S and source takes OAuthToken as its cred type (OR) source takes anonymous as its cred type, but it's not public and there's no SAS
 	} else if cca.fromTo.From().IsRemote() && cca.fromTo.To().IsRemote() &&
 		(srcCredInfo.CredentialType == common.ECredentialType.OAuthToken() ||
 			(srcCredInfo.CredentialType == common.ECredentialType.Anonymous() && !isPublic && cca.sourceSAS == "")) {
 		// TODO: Generate a SAS token if it's blob -> *
-		return nil, errors.New("a SAS token (or S3 access key) is required as a part of the source in S2S transfers, unless the source is a public resource")
+		return nil, errors.New("a SAS token (or S3 access key) is required as a part of the source in S2S transfers, unless the source is a public resource, or a blob that is copied to a blob while logged in with azcopy login")
 	}
 
 	// Infer on download so that we get LMT and MD5 on files download
@@ -61,18 +65,46 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 	jobPartOrder.S2SSourceChangeValidation = cca.s2sSourceChangeValidation
 	jobPartOrder.DestLengthValidation = cca.CheckLength
 	jobPartOrder.S2SInvalidMetadataHandleOption = cca.s2sInvalidMetadataHandleOption
-
-	traverser, err = initResourceTraverser(src, cca.fromTo.From(), &ctx, &srcCredInfo, &cca.followSymlinks, cca.listOfFilesChannel, cca.recursive, getRemoteProperties, func() {})
+	jobPartOrder.S2SFallback = cca.s2sFallback
+	jobPartOrder.S2SComputeMD5 = cca.s2sComputeMD5
+	jobPartOrder.TransferOrder = cca.transferOrder
+	jobPartOrder.FlushPolicy = cca.flushPolicy
+	jobPartOrder.FlushIntervalBytes = cca.flushIntervalBytes
+	jobPartOrder.DiffBaseSnapshot = cca.diffBaseSnapshot
+	jobPartOrder.ReuseUncommittedBlocks = cca.reuseUncommittedBlocks
+	jobPartOrder.RangedDownload = cca.rangedDownload
+	jobPartOrder.DownloadOffset = cca.downloadOffset
+	jobPartOrder.DeleteSourceAfterTransfer = cca.deleteSourceAfterTransfer
+	jobPartOrder.ClearArchiveBit = cca.clearArchiveBit
+	jobPartOrder.BreakLeaseOnOverwrite = cca.breakLeaseOnOverwrite
+	jobPartOrder.ProtectDestinationWithLease = cca.protectDestinationWithLease
+	jobPartOrder.DestinationCondition = cca.destinationCondition
+	jobPartOrder.ForceIfReadOnly = cca.forceIfReadOnly
+	jobPartOrder.SharingViolationRetryWindow = cca.retryOnSharingViolation
+	jobPartOrder.ShareFullWaitWindow = cca.waitOnShareFull
+	jobPartOrder.MissingSourceHandling = cca.missingSourceHandling
+	jobPartOrder.SoftDeletedDestinationHandling = cca.softDeletedDestination
+	jobPartOrder.PreserveSourceLMTAsMetadata = cca.preserveSourceLMTAsMetadata
+	jobPartOrder.TempNameSuffix = cca.tempNameSuffix
+	jobPartOrder.SkipPermissionErrors = cca.skipPermissionErrors
+	jobPartOrder.SkipLockedFiles = cca.skipLockedFiles
+	jobPartOrder.PerFileAttributes = cca.attributesManifest != nil
+
+	traverser, err = initResourceTraverser(src, cca.fromTo.From(), &ctx, &srcCredInfo, &cca.followSymlinks, cca.listOfFilesChannel, cca.recursive, getRemoteProperties, func() {}, cca.enumerationFailures, cca.traversalLimits)
 
 	if err != nil {
 		return nil, err
 	}
+	cca.deletedBlobs.attachTo(traverser)
 
 	// Ensure we're only copying from a directory with a trailing wildcard or recursive.
 	isSourceDir := traverser.isDirectory(true)
 	if isSourceDir && !cca.recursive && !cca.stripTopDir {
 		return nil, errors.New("cannot use directory as source without --recursive or a trailing wildcard (/*)")
 	}
+	if isSourceDir && cca.rangedDownload {
+		return nil, errors.New("offset and length are only supported while downloading a single file")
+	}
 
 	// Check if the destination is a directory so we can correctly decide where our files land
 	isDestDir := cca.isDestDirectory(dst, &ctx)
@@ -99,6 +131,17 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		return nil, errors.New("cannot combine list-of-files or include-path with account traversal")
 	}
 
+	if cca.containerFilter != nil {
+		filterable, ok := traverser.(containerFilterable)
+		if !ok || srcLevel != ELocationLevel.Service() {
+			return nil, errors.New("include-container and exclude-container can only be used when the source is a whole Blob, Azure Files or ADLS Gen2 account")
+		}
+		filterable.setContainerFilter(cca.containerFilter)
+	}
+	if srcLevel == ELocationLevel.Service() {
+		cca.containers = newContainerTally()
+	}
+
 	if (srcLevel == ELocationLevel.Object() || cca.fromTo.From().IsLocal()) && dstLevel == ELocationLevel.Service() {
 		return nil, errors.New("cannot transfer individual files/folders to the root of a service. Add a container or directory to the destination URL")
 	}
@@ -108,6 +151,18 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		cca.stripTopDir = true
 	}
 
+	// a directory goes under the destination as a subdirectory of its own name, unless only its contents were asked for
+	if isSourceDir && !cca.asSubdir {
+		cca.stripTopDir = true
+	}
+	if isSourceDir && srcLevel != ELocationLevel.Service() && cca.listOfFilesChannel == nil && cca.destinationTemplate == nil {
+		if cca.estimate != nil {
+			cca.estimate.Layout = cca.describeLayout()
+		} else {
+			glcm.Info(cca.describeLayout())
+		}
+	}
+
 	// Create a S3 bucket resolver
 	// Giving it nothing to work with as new names will be added as we traverse.
 	var containerResolver = NewS3BucketNameToAzureResourcesResolver(nil)
@@ -124,10 +179,17 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 			return nil, err
 		}
 
-		// only create the destination container in S2S scenarios
-		if cca.fromTo.From().IsRemote() && dstContainerName != "" { // if the destination has a explicit container name
+		if dstContainerName != "" { // if the destination has a explicit container name
+			// The source container can only be carried over when there is exactly one of them
+			srcContainerName := ""
+			if cca.fromTo.From().IsRemote() && srcLevel != ELocationLevel.Service() {
+				if srcContainerName, err = GetContainerName(src, cca.fromTo.From()); err != nil {
+					return nil, err
+				}
+			}
+
 			// Attempt to create the container. If we fail, fail silently.
-			err = cca.createDstContainer(dstContainerName, dst, ctx, existingContainers)
+			err = cca.createDstContainer(dstContainerName, srcContainerName, dst, ctx, existingContainers)
 
 			// check against seenFailedContainers so we don't spam the job log with initialization failed errors
 			if _, ok := seenFailedContainers[dstContainerName]; err != nil && ste.JobsAdmin != nil && !ok {
@@ -157,7 +219,7 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 						continue
 					}
 
-					err = cca.createDstContainer(bucketName, dst, ctx, existingContainers)
+					err = cca.createDstContainer(bucketName, v, dst, ctx, existingContainers)
 
 					// if JobsAdmin is nil, we're probably in testing mode.
 					// As a result, container creation failures are expected as we don't give the SAS tokens adequate permissions.
@@ -181,7 +243,7 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 				resName, err := containerResolver.ResolveName(cName)
 
 				if err == nil {
-					err = cca.createDstContainer(resName, dst, ctx, existingContainers)
+					err = cca.createDstContainer(resName, cName, dst, ctx, existingContainers)
 
 					if _, ok := seenFailedContainers[dstContainerName]; err != nil && ste.JobsAdmin != nil && !ok {
 						logDstContainerCreateFailureOnce.Do(func() {
@@ -195,6 +257,9 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		}
 	}
 
+	// with overwrite disabled, the destination is listed once, rather than each transfer checking it
+	existing := cca.listExistingDestinations(ctx, dst, isSourceDir, srcLevel, dstLevel)
+
 	filters := cca.initModularFilters()
 	processor := func(object storedObject) error {
 		// Start by resolving the name and creating the container
@@ -228,17 +293,54 @@ func (cca *cookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		}
 
 		srcRelPath := cca.makeEscapedRelativePath(true, isDestDir, object)
-		dstRelPath := cca.makeEscapedRelativePath(false, isDestDir, object)
+		dstObject, keep, err := cca.invalidNames.rename(object.atDestination(), isDestDir)
+		if err != nil || !keep {
+			return err
+		}
+		dstRelPath := cca.makeEscapedRelativePath(false, isDestDir, dstObject)
+		cca.destinationTemplate.claim(dstRelPath, srcRelPath)
+		if cca.enumerationCache.skip(object, srcRelPath, dstRelPath) {
+			return nil
+		}
 
+		cca.attributesManifest.apply(&object)
 		transfer := object.ToNewCopyTransfer(
 			cca.autoDecompress && cca.fromTo.IsDownload(),
 			srcRelPath, dstRelPath,
 			cca.s2sPreserveAccessTier,
 		)
+		transfer.ExistsAtDestination = existing.contains(dstRelPath)
+
+		if cca.rangedDownload {
+			// the transfer is of the window, so that's what the chunks are planned for, and what the length check expects
+			if transfer.SourceSize, err = cca.windowOf(object.name, object.size); err != nil {
+				return err
+			}
+		}
 
+		if cca.printHeaderRules {
+			glcm.Info(formatHeaderRuleMatch(object, cca.headerRules))
+		}
+		if err = cca.transactions.add(transfer); err != nil {
+			return err
+		}
+		if cca.estimate != nil {
+			cca.estimate.add(transfer)
+			return nil
+		}
+		if err = cca.lowSpace.add(transfer); err != nil {
+			return err
+		}
+		cca.containers.add(object.containerName, transfer.SourceSize)
 		return addTransfer(&jobPartOrder, transfer, cca)
 	}
 	finalizer := func() error {
+		if cca.estimate != nil || cca.deletedBlobs.restoresOnly() {
+			return nil // no job is created when only estimating, or only restoring
+		}
+		if cca.destinationShards != nil {
+			return cca.destinationShards.dispatchFinalPart(&jobPartOrder, cca)
+		}
 		return dispatchFinalPart(&jobPartOrder, cca)
 	}
 
@@ -259,7 +361,7 @@ func (cca *cookedCopyCmdArgs) isDestDirectory(dst string, ctx *context.Context)
 		return false
 	}
 
-	rt, err := initResourceTraverser(dst, cca.fromTo.To(), ctx, &dstCredInfo, nil, nil, false, false, func() {})
+	rt, err := initResourceTraverser(dst, cca.fromTo.To(), ctx, &dstCredInfo, nil, nil, false, false, func() {}, nil, nil)
 
 	if err != nil {
 		return false
@@ -291,6 +393,8 @@ func (cca *cookedCopyCmdArgs) initModularFilters() []objectFilter {
 		}
 	}
 
+	filters = append(filters, buildPathPatternFilters(cca.includePathGlobs, cca.excludePathGlobs)...)
+
 	if len(cca.excludeBlobType) != 0 {
 		excludeSet := map[azblob.BlobType]bool{}
 
@@ -309,15 +413,37 @@ func (cca *cookedCopyCmdArgs) initModularFilters() []objectFilter {
 		filters = append(filters, buildAttrFilters(cca.excludeFileAttributes, cca.source, false)...)
 	}
 
-	return filters
+	if cca.excludeDotfiles {
+		filters = append(filters, &dotfileFilter{})
+	}
+
+	return cca.excludedFiles.countExclusions(filters)
 }
 
-func (cca *cookedCopyCmdArgs) createDstContainer(containerName, dstWithSAS string, ctx context.Context, existingContainers map[string]bool) (err error) {
+// createDstContainer creates the destination container (or share, or file system) if it doesn't exist yet.
+// When copying between blob accounts, a container created here takes on the metadata, public access level
+// and (if the destination credential permits setting them) the stored access policies of srcContainerName.
+// If the credential is not permitted to create it, e.g. it's a SAS of the container itself, the container is assumed to exist.
+func (cca *cookedCopyCmdArgs) createDstContainer(containerName, srcContainerName, dstWithSAS string, ctx context.Context, existingContainers map[string]bool) (err error) {
 	if _, ok := existingContainers[containerName]; ok {
 		return
 	}
 	existingContainers[containerName] = true
 
+	// estimating must not change anything at the destination, and the user may want a missing container to fail the transfers
+	if cca.estimate != nil || cca.noCreateDstContainer {
+		return nil
+	}
+
+	defer func() {
+		if isForbidden(err) {
+			if ste.JobsAdmin != nil {
+				ste.JobsAdmin.LogToJobLog(fmt.Sprintf("destination container %s is assumed to exist, as the credential is not permitted to create it: %s", containerName, err))
+			}
+			err = nil
+		}
+	}()
+
 	dstCredInfo := common.CredentialInfo{}
 
 	if dstCredInfo, _, err = getCredentialInfoForLocation(ctx, cca.fromTo.To(), cca.destination, cca.destinationSAS, false); err != nil {
@@ -334,6 +460,9 @@ func (cca *cookedCopyCmdArgs) createDstContainer(containerName, dstWithSAS strin
 	// TODO: Reduce code dupe somehow
 	switch cca.fromTo.To() {
 	case common.ELocation.Local():
+		if cca.destination == common.Dev_Null {
+			return nil // the data is discarded, so there are no folders to create
+		}
 		err = os.MkdirAll(common.GenerateFullPath(cca.destination, containerName), os.ModeDir|os.ModePerm)
 	case common.ELocation.Blob():
 		accountRoot, err := GetAccountRoot(dstWithSAS, cca.fromTo.To())
@@ -356,15 +485,38 @@ func (cca *cookedCopyCmdArgs) createDstContainer(containerName, dstWithSAS strin
 			return err // Container already exists, return gracefully
 		}
 
-		_, err = bcu.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+		// Set Container ACL is only permitted with the account's own credentials, never with a SAS
+		canSetAccessPolicy := dstCredInfo.CredentialType == common.ECredentialType.OAuthToken()
+		srcProps := cca.getSrcContainerProperties(ctx, srcContainerName, canSetAccessPolicy)
+
+		_, err = bcu.Create(ctx, srcProps.metadata, srcProps.publicAccess)
+
+		if stgErr, ok := err.(azblob.StorageError); ok && srcProps.publicAccess != azblob.PublicAccessNone &&
+			stgErr.ServiceCode() == serviceCodePublicAccessNotPermitted {
+			LogStdoutAndJobLog(fmt.Sprintf("public access level %s of container %s could not be applied to destination container %s, "+
+				"as public access is not permitted on the destination account; it was created as private instead", srcProps.publicAccess, srcContainerName, containerName))
+			srcProps.publicAccess = azblob.PublicAccessNone
+			_, err = bcu.Create(ctx, srcProps.metadata, srcProps.publicAccess)
+		}
 
 		if stgErr, ok := err.(azblob.StorageError); ok {
 			if stgErr.ServiceCode() != azblob.ServiceCodeContainerAlreadyExists {
 				return err
 			}
-		} else {
+			return nil // someone else created it, so its properties are not ours to set
+		} else if err != nil {
 			return err
 		}
+		LogStdoutAndJobLog(fmt.Sprintf("Created destination container %s", containerName))
+
+		if len(srcProps.accessPolicy) != 0 {
+			_, err = bcu.SetAccessPolicy(ctx, srcProps.publicAccess, srcProps.accessPolicy, azblob.ContainerAccessConditions{})
+
+			if err != nil {
+				LogStdoutAndJobLog(fmt.Sprintf("stored access policies of container %s could not be applied to destination container %s: %s", srcContainerName, containerName, err))
+				err = nil
+			}
+		}
 	case common.ELocation.File():
 		// Grab the account root and parse it as a URL
 		accountRoot, err := GetAccountRoot(dstWithSAS, cca.fromTo.To())
@@ -395,9 +547,42 @@ func (cca *cookedCopyCmdArgs) createDstContainer(containerName, dstWithSAS strin
 			if stgErr.ServiceCode() != azfile.ServiceCodeShareAlreadyExists {
 				return err
 			}
-		} else {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		LogStdoutAndJobLog(fmt.Sprintf("Created destination share %s", containerName))
+	case common.ELocation.BlobFS():
+		accountRoot, err := GetAccountRoot(dstWithSAS, cca.fromTo.To())
+
+		if err != nil {
+			return err
+		}
+
+		dstURL, err := url.Parse(accountRoot)
+
+		if err != nil {
+			return err
+		}
+
+		fsURL := azbfs.NewServiceURL(*dstURL, dstPipeline).NewFileSystemURL(containerName)
+		_, err = fsURL.GetProperties(ctx)
+
+		if err == nil {
+			return err
+		}
+
+		_, err = fsURL.Create(ctx)
+
+		if stgErr, ok := err.(azbfs.StorageError); ok {
+			if stgErr.ServiceCode() != azbfs.ServiceCodeFileSystemAlreadyExists {
+				return err
+			}
+			return nil
+		} else if err != nil {
 			return err
 		}
+		LogStdoutAndJobLog(fmt.Sprintf("Created destination file system %s", containerName))
 	default:
 		panic(fmt.Sprintf("cannot create a destination container at location %s.", cca.fromTo.To()))
 	}
@@ -405,6 +590,15 @@ func (cca *cookedCopyCmdArgs) createDstContainer(containerName, dstWithSAS strin
 	return
 }
 
+// isForbidden tells whether the request failed because the credential isn't permitted to make it.
+// The StorageErrors of blob, file and blobFS all carry the response they came with
+func isForbidden(err error) bool {
+	if respErr, ok := err.(interface{ Response() *http.Response }); ok && respErr.Response() != nil {
+		return respErr.Response().StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
 func (cca *cookedCopyCmdArgs) makeEscapedRelativePath(source bool, dstIsDir bool, object storedObject) (relativePath string) {
 	var pathEncodeRules = func(path string) string {
 		loc := common.ELocation.Unknown()
@@ -452,6 +646,15 @@ func (cca *cookedCopyCmdArgs) makeEscapedRelativePath(source bool, dstIsDir bool
 		return "" // ignore path encode rules
 	}
 
+	// the destination template decides where the object goes under the destination, in place of its relative path
+	if !source && cca.destinationTemplate != nil {
+		relativePath = "/" + cca.destinationTemplate.expand(object)
+		if object.dstContainerName != "" {
+			relativePath = "/" + object.dstContainerName + relativePath
+		}
+		return pathEncodeRules(relativePath)
+	}
+
 	// source is a EXACT path to the file.
 	if object.relativePath == "" {
 		// If we're finding an object from the source, it returns "" if it's already got it.
@@ -479,19 +682,115 @@ func (cca *cookedCopyCmdArgs) makeEscapedRelativePath(source bool, dstIsDir bool
 		// We ONLY need to do this adjustment to the destination.
 		// The source SAS has already been removed. No need to convert it to a URL or whatever.
 		// Save to a directory
-		rootDir := filepath.Base(cca.source)
+		relativePath = "/" + cca.sourceTopDirName() + relativePath
+	}
 
-		if cca.fromTo.From().IsRemote() {
-			ueRootDir, err := url.PathUnescape(rootDir)
+	return pathEncodeRules(relativePath)
+}
 
-			// Realistically, err should never not be nil here.
-			if err == nil {
-				rootDir = ueRootDir
-			}
+// sourceTopDirName is the name of the directory that the source is, which is what it's called at the destination, unless its top directory is stripped
+func (cca *cookedCopyCmdArgs) sourceTopDirName() string {
+	rootDir := filepath.Base(cca.source)
+
+	if cca.fromTo.From().IsRemote() {
+		ueRootDir, err := url.PathUnescape(rootDir)
+
+		// Realistically, err should never not be nil here.
+		if err == nil {
+			rootDir = ueRootDir
 		}
+	}
+	return rootDir
+}
 
-		relativePath = "/" + rootDir + relativePath
+// describeLayout tells where a directory source lands at the destination: its contents, when its top directory is stripped,
+// or the directory itself, as a subdirectory of the destination
+func (cca *cookedCopyCmdArgs) describeLayout() string {
+	if cca.stripTopDir {
+		return fmt.Sprintf("Copying the contents of %s into %s", cca.source, cca.destination)
 	}
 
-	return pathEncodeRules(relativePath)
+	separator := common.AZCOPY_PATH_SEPARATOR_STRING
+	if cca.fromTo.To().IsLocal() {
+		separator = common.OS_PATH_SEPARATOR
+	}
+	return fmt.Sprintf("Copying %s as %s", cca.source,
+		strings.TrimRight(cca.destination, `/\`)+separator+cca.sourceTopDirName())
+}
+
+// the service code returned when a container with public access is created in an account that disallows it
+const serviceCodePublicAccessNotPermitted azblob.ServiceCodeType = "PublicAccessNotPermitted"
+
+// srcContainerProperties holds what is carried over from a source container to the destination container created for it
+type srcContainerProperties struct {
+	metadata     azblob.Metadata
+	publicAccess azblob.PublicAccessType
+	accessPolicy []azblob.SignedIdentifier
+}
+
+// getSrcContainerProperties reads the properties of the source container that should be applied to its destination.
+// Anything that can't be read is reported against the container, and the destination simply goes without it.
+func (cca *cookedCopyCmdArgs) getSrcContainerProperties(ctx context.Context, srcContainerName string, withAccessPolicy bool) (props srcContainerProperties) {
+	props.metadata = azblob.Metadata{}
+	props.publicAccess = azblob.PublicAccessNone
+
+	if cca.fromTo != common.EFromTo.BlobBlob() || srcContainerName == "" {
+		return
+	}
+
+	reportFailure := func(what string, err error) {
+		LogStdoutAndJobLog(fmt.Sprintf("failed to read the %s of source container %s, so the destination container will be created without them: %s", what, srcContainerName, err))
+	}
+
+	srcCredInfo, _, err := getCredentialInfoForLocation(ctx, cca.fromTo.From(), cca.source, cca.sourceSAS, true)
+	if err != nil {
+		reportFailure("properties", err)
+		return
+	}
+
+	srcPipeline, err := initPipeline(ctx, cca.fromTo.From(), srcCredInfo)
+	if err != nil {
+		reportFailure("properties", err)
+		return
+	}
+
+	src, err := appendSASIfNecessary(cca.source, cca.sourceSAS)
+	if err != nil {
+		reportFailure("properties", err)
+		return
+	}
+
+	accountRoot, err := GetAccountRoot(src, cca.fromTo.From())
+	if err != nil {
+		reportFailure("properties", err)
+		return
+	}
+
+	srcURL, err := url.Parse(accountRoot)
+	if err != nil {
+		reportFailure("properties", err)
+		return
+	}
+
+	containerURL := azblob.NewServiceURL(*srcURL, srcPipeline).NewContainerURL(srcContainerName)
+	propResp, err := containerURL.GetProperties(ctx, azblob.LeaseAccessConditions{})
+	if err != nil {
+		reportFailure("properties", err)
+		return
+	}
+
+	props.metadata = propResp.NewMetadata()
+	props.publicAccess = propResp.BlobPublicAccess()
+
+	if withAccessPolicy {
+		policyResp, err := containerURL.GetAccessPolicy(ctx, azblob.LeaseAccessConditions{})
+		if err != nil {
+			reportFailure("stored access policies", err)
+			return
+		}
+
+		props.accessPolicy = policyResp.Items
+	}
+
+	return
 }
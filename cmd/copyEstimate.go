@@ -0,0 +1,167 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// the upper bounds (exclusive) of the size buckets of an estimate. Files that are larger than the last one go into a final bucket
+var estimateSizeBucketBounds = []int64{
+	1024 * 1024,             // 1 MiB
+	16 * 1024 * 1024,        // 16 MiB
+	256 * 1024 * 1024,       // 256 MiB
+	4 * 1024 * 1024 * 1024,  // 4 GiB
+	64 * 1024 * 1024 * 1024, // 64 GiB
+}
+
+// bytes per GB, in the sense that Azure bandwidth pricing uses it
+const bytesPerPricedGB = 1024 * 1024 * 1024
+
+// transferEstimate adds up what a copy would transfer, as the enumeration finds each file.
+// Nothing is kept per file, so it works for any number of files.
+type transferEstimate struct {
+	FileCount        uint64
+	TotalBytes       uint64
+	LargestFile      string `json:",omitempty"`
+	LargestFileBytes int64
+	SizeBuckets      []estimateSizeBucket
+
+	// where a directory source lands at the destination, and, to show it, where the first file goes
+	Layout             string `json:",omitempty"`
+	ExampleSource      string `json:",omitempty"`
+	ExampleDestination string `json:",omitempty"`
+
+	// only set if the user gave the price per GB
+	PricePerGB          float64 `json:",omitempty"`
+	EstimatedEgressCost float64 `json:",omitempty"`
+
+	// the storage transactions that the copy is predicted to make, and what the prediction takes for granted
+	Transactions           common.TransactionCounts
+	TransactionAssumptions []string
+}
+
+type estimateSizeBucket struct {
+	MinBytes   int64
+	MaxBytes   int64 `json:",omitempty"` // exclusive, and 0 for the last bucket, which has no upper bound
+	FileCount  uint64
+	TotalBytes uint64
+}
+
+func newTransferEstimate(pricePerGB float64) *transferEstimate {
+	e := &transferEstimate{PricePerGB: pricePerGB}
+	var min int64
+	for _, max := range estimateSizeBucketBounds {
+		e.SizeBuckets = append(e.SizeBuckets, estimateSizeBucket{MinBytes: min, MaxBytes: max})
+		min = max
+	}
+	e.SizeBuckets = append(e.SizeBuckets, estimateSizeBucket{MinBytes: min})
+	return e
+}
+
+func (e *transferEstimate) add(transfer common.CopyTransfer) {
+	e.FileCount++
+	e.TotalBytes += uint64(transfer.SourceSize)
+	if e.FileCount == 1 {
+		e.ExampleSource = strings.TrimPrefix(transfer.Source, common.AZCOPY_PATH_SEPARATOR_STRING)
+		e.ExampleDestination = strings.TrimPrefix(transfer.Destination, common.AZCOPY_PATH_SEPARATOR_STRING)
+	}
+	if e.FileCount == 1 || transfer.SourceSize > e.LargestFileBytes {
+		e.LargestFile = transfer.Source
+		e.LargestFileBytes = transfer.SourceSize
+	}
+
+	for i := range e.SizeBuckets {
+		b := &e.SizeBuckets[i]
+		if b.MaxBytes == 0 || transfer.SourceSize < b.MaxBytes {
+			b.FileCount++
+			b.TotalBytes += uint64(transfer.SourceSize)
+			break
+		}
+	}
+
+	if e.PricePerGB > 0 {
+		e.EstimatedEgressCost = float64(e.TotalBytes) / bytesPerPricedGB * e.PricePerGB
+	}
+}
+
+func (e *transferEstimate) output(format common.OutputFormat) string {
+	if format == common.EOutputFormat.Json() {
+		jsonOutput, err := json.Marshal(e)
+		common.PanicIfErr(err)
+		return string(jsonOutput)
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\nEstimate only, no job was created\nTotal Number Of Files: %v\nTotal Bytes: %v (%s)\n",
+		e.FileCount, e.TotalBytes, byteSizeToString(int64(e.TotalBytes))))
+	if e.FileCount > 0 {
+		sb.WriteString(fmt.Sprintf("Largest File: %s (%s)\n", e.LargestFile, byteSizeToString(e.LargestFileBytes)))
+	}
+	if e.Layout != "" {
+		sb.WriteString(e.Layout + "\n")
+		if e.FileCount > 0 {
+			sb.WriteString(fmt.Sprintf("  e.g. %s goes to %s under the destination\n", e.ExampleSource, e.ExampleDestination))
+		}
+	}
+
+	sb.WriteString("Files by size:\n")
+	for _, b := range e.SizeBuckets {
+		var sizeRange string
+		switch {
+		case b.MaxBytes == 0:
+			sizeRange = fmt.Sprintf("%s and larger", byteSizeToString(b.MinBytes))
+		case b.MinBytes == 0:
+			sizeRange = fmt.Sprintf("under %s", byteSizeToString(b.MaxBytes))
+		default:
+			sizeRange = fmt.Sprintf("%s to under %s", byteSizeToString(b.MinBytes), byteSizeToString(b.MaxBytes))
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %v files, %s\n", sizeRange, b.FileCount, byteSizeToString(int64(b.TotalBytes))))
+	}
+
+	sb.WriteString(fmt.Sprintf("Predicted Storage Transactions: %s\n", formatTransactionCounts(e.Transactions)))
+	for _, assumption := range e.TransactionAssumptions {
+		sb.WriteString(fmt.Sprintf("  assuming: %s\n", assumption))
+	}
+
+	if e.PricePerGB > 0 {
+		sb.WriteString(fmt.Sprintf("Approximate Egress Cost: %.2f (at %v per GB, not including the cost of transactions)\n", e.EstimatedEgressCost, e.PricePerGB))
+	}
+	return sb.String()
+}
+
+// formatHeaderRuleMatch describes which of the header rules applies to the file, for print-header-rules
+func formatHeaderRuleMatch(object storedObject, rules common.HeaderRules) string {
+	relativePath := object.relativePath
+	if relativePath == "" {
+		relativePath = object.name // the source is the file itself
+	}
+
+	rule, index, ok := rules.Match(relativePath)
+	if !ok {
+		return fmt.Sprintf("%s: no header rule", relativePath)
+	}
+	return fmt.Sprintf("%s: header rule %d (%s)", relativePath, index+1, rule)
+}
@@ -24,7 +24,6 @@ import (
 	"context"
 	"fmt"
 	"net/url"
-	"os"
 	"strings"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -101,8 +100,8 @@ func (util copyHandlerUtil) redactSigQueryParam(rawQuery string) (bool, string)
 // ConstructCommandStringFromArgs creates the user given commandString from the os Arguments
 // If any argument passed is an http Url and contains the signature, then the signature is redacted
 func (util copyHandlerUtil) ConstructCommandStringFromArgs() string {
-	// Get the os Args and strip away the first argument since it will be the path of Azcopy executable
-	args := os.Args[1:]
+	// the args of the command, which are those of the process without the path of the Azcopy executable, unless AzCopy runs inside another program
+	args := commandArgs
 	if len(args) == 0 {
 		return ""
 	}
@@ -186,12 +185,22 @@ func (util copyHandlerUtil) getContainerUrl(blobParts azblob.BlobURLParts) url.U
 
 // doesBlobRepresentAFolder verifies whether blob is valid or not.
 // Used to handle special scenarios or conditions.
-func (util copyHandlerUtil) doesBlobRepresentAFolder(metadata azblob.Metadata) bool {
+func (util copyHandlerUtil) doesBlobRepresentAFolder(size int64, metadata azblob.Metadata) bool {
 	// this condition is to handle the WASB V1 directory structure.
 	// HDFS driver creates a blob for the empty directories (let’s call it ‘myfolder’)
 	// and names all the blobs under ‘myfolder’ as such: ‘myfolder/myblob’
-	// The empty directory has meta-data 'hdi_isfolder = true'
-	return metadata["hdi_isfolder"] == "true"
+	// The empty directory has meta-data 'hdi_isfolder = true'.
+	// Only an empty blob can be such a marker: one with content is a file, whatever its metadata says,
+	// and an empty blob without the marker is an empty file, which is transferred like any other.
+	if size != 0 {
+		return false
+	}
+	for k, v := range metadata {
+		if strings.EqualFold(k, "hdi_isfolder") && strings.EqualFold(v, "true") {
+			return true
+		}
+	}
+	return false
 }
 
 func startsWith(s string, t string) bool {
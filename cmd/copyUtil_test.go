@@ -21,8 +21,10 @@
 package cmd
 
 import (
-	chk "gopkg.in/check.v1"
 	"net/url"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
 )
 
 type copyUtilTestSuite struct{}
@@ -79,3 +81,17 @@ func (s *copyUtilTestSuite) TestIPIsContainerOrBlob(c *chk.C) {
 	c.Assert(isContainerURL, chk.Equals, true) // URL endpoints do not contain the account in the path, making the container the first entry.
 	// The behaviour isn't too different from here.
 }
+
+func (s *copyUtilTestSuite) TestOnlyEmptyBlobsRepresentFolders(c *chk.C) {
+	util := copyHandlerUtil{}
+
+	c.Assert(util.doesBlobRepresentAFolder(0, azblob.Metadata{"hdi_isfolder": "true"}), chk.Equals, true)
+	c.Assert(util.doesBlobRepresentAFolder(0, azblob.Metadata{"Hdi_IsFolder": "True"}), chk.Equals, true)
+
+	// an empty blob without the marker is an empty file
+	c.Assert(util.doesBlobRepresentAFolder(0, azblob.Metadata{}), chk.Equals, false)
+	c.Assert(util.doesBlobRepresentAFolder(0, azblob.Metadata{"hdi_isfolder": "false"}), chk.Equals, false)
+
+	// and a blob with content is a file, whatever its metadata says
+	c.Assert(util.doesBlobRepresentAFolder(10, azblob.Metadata{"hdi_isfolder": "true"}), chk.Equals, false)
+}
@@ -79,6 +79,7 @@ func GetUserOAuthTokenManagerInstance() *common.UserOAuthTokenManager {
 // 3. If there is cached OAuth token, indicating using token credential.
 // 4. If there is OAuth token info passed from env var, indicating using token credential. (Note: this is only for testing)
 // 5. Otherwise use anonymous credential.
+// Emulators and other IP endpoint style services use shared key instead, if there is no SAS.
 // The implementaion logic follows above rule, and adjusts sequence to save web request(for verifying public resource).
 func getBlobCredentialType(ctx context.Context, blobResourceURL string, canBePublic bool, standaloneSAS bool) (common.CredentialType, bool, error) {
 	resourceURL, err := url.Parse(blobResourceURL)
@@ -94,18 +95,26 @@ func getBlobCredentialType(ctx context.Context, blobResourceURL string, canBePub
 		return common.ECredentialType.Anonymous(), false, nil
 	}
 
+	// Emulators, and other IP endpoint style services, can be authorized with a shared key,
+	// which is the one in ACCOUNT_NAME and ACCOUNT_KEY, or else the emulators' well-known one
+	if common.IsIPEndpointStyle(*resourceURL) && (sharedKeyEnvVarsExist() || common.IsEmulatorAccount(*resourceURL)) {
+		return common.ECredentialType.SharedKey(), false, nil
+	}
+
 	checkPublic := func() (isPublicResource bool) {
-		p := azblob.NewPipeline(
+		p := ste.NewBlobPipeline(
 			azblob.NewAnonymousCredential(),
-			azblob.PipelineOptions{
-				Retry: azblob.RetryOptions{
-					Policy:        azblob.RetryPolicyExponential,
-					MaxTries:      ste.UploadMaxTries,
-					TryTimeout:    ste.UploadTryTimeout,
-					RetryDelay:    ste.UploadRetryDelay,
-					MaxRetryDelay: ste.UploadMaxRetryDelay,
-				},
-			})
+			azblob.PipelineOptions{},
+			ste.XferRetryOptions{
+				Policy:        0,
+				MaxTries:      ste.UploadMaxTries,
+				TryTimeout:    ste.UploadTryTimeout,
+				RetryDelay:    ste.UploadRetryDelay,
+				MaxRetryDelay: ste.UploadMaxRetryDelay,
+			},
+			nil,
+			ste.NewAzcopyHTTPClient(frontEndMaxIdleConnectionsPerHost),
+			nil)
 
 		isContainer := copyHandlerUtil{}.urlIsContainerOrVirtualDirectory(resourceURL)
 		isPublicResource = false
@@ -162,7 +171,7 @@ func getBlobCredentialType(ctx context.Context, blobResourceURL string, canBePub
 // 1. Check if there is a SAS query appended to the URL
 // 2. If there is cached session OAuth token, indicating using token credential.
 // 3. If there is OAuth token info passed from env var, indicating using token credential. (Note: this is only for testing)
-// 4. Otherwise use shared key.
+// 4. Otherwise use shared key, if ACCOUNT_NAME and ACCOUNT_KEY are set or the resource is in the emulators' well-known account.
 func getBlobFSCredentialType(ctx context.Context, blobResourceURL string, standaloneSAS bool) (common.CredentialType, error) {
 	resourceURL, err := url.Parse(blobResourceURL)
 	if err != nil {
@@ -180,15 +189,47 @@ func getBlobFSCredentialType(ctx context.Context, blobResourceURL string, standa
 		return common.ECredentialType.OAuthToken(), nil
 	}
 
-	name := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AccountName())
-	key := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AccountKey())
-	if name != "" && key != "" { // TODO: To remove, use for internal testing, SharedKey should not be supported from commandline
+	if sharedKeyEnvVarsExist() || common.IsEmulatorAccount(*resourceURL) { // TODO: To remove, use for internal testing, SharedKey should not be supported from commandline
 		return common.ECredentialType.SharedKey(), nil
 	} else {
 		return common.ECredentialType.Unknown(), errors.New("OAuth token, SAS token, or shared key should be provided for Blob FS")
 	}
 }
 
+var warnOfOAuthTokenForOtherCloudOnce sync.Once
+
+// warnIfOAuthTokenIsForOtherCloud tells the user, once, if the token was issued by the Active Directory of a different cloud than the one
+// that any of the resources is in, since the storage service would reject it
+func warnIfOAuthTokenIsForOtherCloud(tokenInfo common.OAuthTokenInfo, resources ...string) {
+	if tokenInfo.Identity || tokenInfo.ActiveDirectoryEndpoint == "" {
+		return // managed identities get their tokens from the cloud that they are in
+	}
+
+	for _, resource := range resources {
+		u, err := url.Parse(resource)
+		if err != nil {
+			continue
+		}
+		endpoint, ok := common.ParseStorageEndpoint(*u)
+		if !ok || strings.EqualFold(strings.TrimSuffix(tokenInfo.ActiveDirectoryEndpoint, "/"), endpoint.Cloud.ActiveDirectoryEndpoint) {
+			continue
+		}
+
+		warnOfOAuthTokenForOtherCloudOnce.Do(func() {
+			glcm.Info(fmt.Sprintf("The storage account %s is in %s, which uses the Azure Active Directory endpoint %s, but you are logged in with %s. "+
+				"If authorization fails, log in again with 'azcopy login --aad-endpoint %s', or set %s.",
+				endpoint.AccountName, endpoint.Cloud.Name, endpoint.Cloud.ActiveDirectoryEndpoint, tokenInfo.ActiveDirectoryEndpoint,
+				endpoint.Cloud.ActiveDirectoryEndpoint, common.EEnvironmentVariable.AADAuthority().Name))
+		})
+		return
+	}
+}
+
+func sharedKeyEnvVarsExist() bool {
+	return glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AccountName()) != "" &&
+		glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AccountKey()) != ""
+}
+
 var announceOAuthTokenOnce sync.Once
 
 func oAuthTokenExists() (oauthTokenExists bool) {
@@ -293,12 +334,29 @@ func getCredentialInfoForLocation(ctx context.Context, location common.Location,
 			return credInfo, false, err
 		} else {
 			credInfo.OAuthTokenInfo = *tokenInfo
+			warnIfOAuthTokenIsForOtherCloud(credInfo.OAuthTokenInfo, resource)
 		}
 	}
 
 	return
 }
 
+// useOAuthForS2SSourceIfPossible lets a blob to blob transfer read a source that has no SAS with the user's OAuth token,
+// which the destination is given along with each request that reads the source. It returns false if that isn't possible.
+func useOAuthForS2SSourceIfPossible(credInfo *common.CredentialInfo, fromTo common.FromTo, srcCredInfo common.CredentialInfo) bool {
+	if fromTo != common.EFromTo.BlobBlob() || srcCredInfo.CredentialType != common.ECredentialType.OAuthToken() {
+		return false
+	}
+	if !featureAvailable(ste.FeatureCopySourceOAuth) {
+		return false // so the source needs a SAS after all
+	}
+
+	glcm.Info("The source has no SAS token, so the OAuth token is used to authorize reading it.")
+	credInfo.S2SSourceCredentialType = common.ECredentialType.OAuthToken()
+	credInfo.OAuthTokenInfo = srcCredInfo.OAuthTokenInfo
+	return true
+}
+
 // getCredentialType checks user provided info, and gets the proper credential type
 // for current command.
 // kept around for legacy compatibility at the moment
@@ -319,8 +377,8 @@ func getCredentialType(ctx context.Context, raw rawFromToInfo) (credentialType c
 		if credentialType, _, err = getBlobCredentialType(ctx, raw.destination, false, raw.destinationSAS != ""); err != nil {
 			return common.ECredentialType.Unknown(), err
 		}
-	case common.EFromTo.BlobTrash():
-		// For BlobTrash direction, use source as resource URL, and it should not be public access resource.
+	case common.EFromTo.BlobTrash(), common.EFromTo.BlobNone():
+		// For BlobTrash and BlobNone directions, use source as resource URL, and it should not be public access resource.
 		if credentialType, _, err = getBlobCredentialType(ctx, raw.source, false, raw.sourceSAS != ""); err != nil {
 			return common.ECredentialType.Unknown(), err
 		}
@@ -391,36 +449,42 @@ func createBlobFSPipeline(ctx context.Context, credInfo common.CredentialInfo) (
 		LogError: glcm.Info,
 	})
 
-	return azbfs.NewPipeline(
+	return ste.NewBlobFSPipeline(
 		credential,
 		azbfs.PipelineOptions{
-			Retry: azbfs.RetryOptions{
-				Policy:        azbfs.RetryPolicyExponential,
-				MaxTries:      ste.UploadMaxTries,
-				TryTimeout:    ste.UploadTryTimeout,
-				RetryDelay:    ste.UploadRetryDelay,
-				MaxRetryDelay: ste.UploadMaxRetryDelay,
-			},
 			Telemetry: azbfs.TelemetryOptions{
 				Value: glcm.AddUserAgentPrefix(common.UserAgent),
 			},
-		}), nil
+		},
+		ste.XferRetryOptions{
+			Policy:        0,
+			MaxTries:      ste.UploadMaxTries,
+			TryTimeout:    ste.UploadTryTimeout,
+			RetryDelay:    ste.UploadRetryDelay,
+			MaxRetryDelay: ste.UploadMaxRetryDelay,
+		},
+		nil,
+		ste.NewAzcopyHTTPClient(frontEndMaxIdleConnectionsPerHost),
+		nil), nil
 }
 
 // TODO note: ctx and credInfo are ignored at the moment because we only support SAS for Azure File
 func createFilePipeline(ctx context.Context, credInfo common.CredentialInfo) (pipeline.Pipeline, error) {
-	return azfile.NewPipeline(
+	return ste.NewFilePipeline(
 		azfile.NewAnonymousCredential(),
 		azfile.PipelineOptions{
-			Retry: azfile.RetryOptions{
-				Policy:        azfile.RetryPolicyExponential,
-				MaxTries:      ste.UploadMaxTries,
-				TryTimeout:    ste.UploadTryTimeout,
-				RetryDelay:    ste.UploadRetryDelay,
-				MaxRetryDelay: ste.UploadMaxRetryDelay,
-			},
 			Telemetry: azfile.TelemetryOptions{
 				Value: glcm.AddUserAgentPrefix(common.UserAgent),
 			},
-		}), nil
+		},
+		azfile.RetryOptions{
+			Policy:        azfile.RetryPolicyExponential,
+			MaxTries:      ste.UploadMaxTries,
+			TryTimeout:    ste.UploadTryTimeout,
+			RetryDelay:    ste.UploadRetryDelay,
+			MaxRetryDelay: ste.UploadMaxRetryDelay,
+		},
+		nil,
+		ste.NewAzcopyHTTPClient(frontEndMaxIdleConnectionsPerHost),
+		nil), nil
 }
@@ -23,7 +23,7 @@ Copies source data to a destination location. The supported directions are:
   - local <-> Azure Blob (SAS or OAuth authentication)
   - local <-> Azure Files (Share/directory SAS authentication)
   - local <-> ADLS Gen 2 (SAS, OAuth, or SharedKey authentication)
-  - Azure Blob (SAS or public) -> Azure Blob (SAS or OAuth authentication)
+  - Azure Blob (SAS, public or OAuth authentication) -> Azure Blob (SAS or OAuth authentication)
   - Azure Blob (SAS or public) -> Azure Files (SAS)
   - Azure Files (SAS) -> Azure Files (SAS)
   - Azure Files (SAS) -> Azure Blob (SAS or OAuth authentication)
@@ -87,6 +87,10 @@ Download a single file by using a SAS token and then piping the output to a file
   
   - azcopy cp "https://[account].blob.core.windows.net/[container]/[path/to/blob]?[SAS]" > "/path/to/file.txt"
 
+Download only 1 GiB of a single file, starting 100 GiB into it (e.g. one member of an uncompressed tar archive). The local file holds just those bytes:
+
+  - azcopy cp "https://[account].blob.core.windows.net/[container]/[path/to/blob]?[SAS]" "/path/to/file.part" --offset=107374182400 --length=1073741824
+
 Download an entire directory by using a SAS token:
   
   - azcopy cp "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" "/path/to/dir" --recursive=true
@@ -117,6 +121,10 @@ Copy a single blob to another blob by using a SAS token and an OAuth token. You
 
   - azcopy cp "https://[srcaccount].blob.core.windows.net/[container]/[path/to/blob]?[SAS]" "https://[destaccount].blob.core.windows.net/[container]/[path/to/blob]"
 
+Copy a single blob to another blob by using only an OAuth token. If you log into AzCopy by using the azcopy login command, neither account needs a SAS token. The logged-in identity must be allowed to read the source (e.g. with the Storage Blob Data Reader role) and to write the destination:
+
+  - azcopy cp "https://[srcaccount].blob.core.windows.net/[container]/[path/to/blob]" "https://[destaccount].blob.core.windows.net/[container]/[path/to/blob]"
+
 Copy one blob virtual directory to another by using a SAS token:
 
   - azcopy cp "https://[srcaccount].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" "https://[destaccount].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" --recursive=true
@@ -188,12 +196,62 @@ Note that you can customize the location where log and plan files are saved. See
 
 const removeJobsCmdExample = "  azcopy jobs rm e52247de-0323-b14d-4cc8-76e0be2e2d44"
 
+const exportTemplateJobsCmdShortDescription = "Write the flags of the given job to a job template, which other jobs can be run with"
+
+const exportTemplateJobsCmdLongDescription = `
+Write the flags of the given copy job, and its source and destination, to a job template in YAML. Run another job with them
+by giving the template to the --from-template flag of the copy command, where the flags of the command line override those of the template.
+
+The template has no credentials: the SAS of the source and destination are removed. It records the version of AzCopy that wrote it,
+and a version of its own layout, and newer templates are refused by older versions of AzCopy. The hash of its flags (flagSetHash) is
+written in the log of each job that runs with them.`
+
+const exportTemplateJobsCmdExample = `Export the template of a job, then run it against new data:
+
+  - azcopy jobs export-template e52247de-0323-b14d-4cc8-76e0be2e2d44 --out=job.yaml
+  - azcopy copy --from-template=job.yaml "/data/2026-10-15" "https://[account].blob.core.windows.net/[container]?[SAS]"`
+
 const cleanJobsCmdShortDescription = "Remove all log and plan files for all jobs"
 
 const cleanJobsCmdLongDescription = `
-Note that you can customize the location where log and plan files are saved. See the env command to learn more.`
+Note that you can customize the location where log and plan files are saved. See the env command to learn more.
+
+With --scrub-destination, removes instead what a job that was cancelled, or whose process died, left at its destination:
+the temporary and partial files of the transfers that it started but never completed.`
+
+const cleanJobsCmdExample = `  azcopy jobs clean --with-status=completed
+
+Remove the partial files that a cancelled job left at its destination:
+
+  - azcopy jobs clean --job=e52247de-0323-b14d-4cc8-76e0be2e2d44 --scrub-destination`
+
+const historyJobsCmdShortDescription = "Lists the jobs that finished, from the job history"
+
+const historyJobsCmdLongDescription = `
+Lists the jobs that finished, the job that ended last first, with their source and destination, when they started and ended,
+how many of their transfers completed, failed and were skipped, and the status they ended with.
+
+The history is only kept when the AZCOPY_JOB_HISTORY environment variable is set to true. It's a file in the AzCopy folder,
+with one line per job, and it's kept when the log and plan files of the jobs are removed. See the env command to learn more.`
 
-const cleanJobsCmdExample = "  azcopy jobs clean --with-status=completed"
+const historyJobsCmdExample = `List the jobs of the last 30 days that completed with errors:
+
+  - azcopy jobs history --since=30d --status=CompletedWithErrors`
+
+const setJobsCmdShortDescription = "Change the bandwidth cap and the concurrency of a running job"
+
+const setJobsCmdLongDescription = `
+Change the bandwidth cap and the concurrency of a job while it runs, e.g. to throttle a long job when business hours start, without cancelling and resuming it.
+The job is reached through its control endpoint, which it prints when it starts, and which only the user running the job can use.
+The changes take effect within a few seconds, are logged, and show in the progress line of the job. Setting the concurrency ends its automatic tuning.`
+
+const setJobsCmdExample = `Cap the bandwidth of a running job at 50 megabits per second, with 16 concurrent connections:
+
+  - azcopy jobs set e52247de-0323-b14d-4cc8-76e0be2e2d44 --cap-mbps=50 --concurrency=16
+
+Remove the bandwidth cap:
+
+  - azcopy jobs set e52247de-0323-b14d-4cc8-76e0be2e2d44 --cap-mbps=0`
 
 // ===================================== LIST COMMAND ===================================== //
 const listCmdShortDescription = "List the entities in a given resource"
@@ -309,6 +367,73 @@ Remove a single directory from a Blob Storage account that has a hierarchical na
    - azcopy rm "https://[account].dfs.core.windows.net/[container]/[path/to/directory]?[SAS]"
 `
 
+// ===================================== SET-PROPERTIES COMMAND ===================================== //
+const setPropertiesCmdShortDescription = "Change the access tier, metadata or index tags of existing blobs"
+
+const setPropertiesCmdLongDescription = `
+Change the access tier, metadata or index tags of one or more existing blobs, without moving any data.
+
+The properties are changed by a job, in the same way as a copy or remove, so the changes are made concurrently
+and the job can be listed and resumed. The include and exclude flags can be used to select which blobs are changed.
+
+If a requested property does not apply to a blob (for example, an access tier on an append blob) that blob is skipped,
+and the reason is recorded in the log file. The rest of the job continues.
+`
+
+const setPropertiesCmdExample = `
+Change the access tier of a single blob to cool:
+
+   - azcopy set-properties "https://[account].blob.core.windows.net/[container]/[path/to/blob]?[SAS]" --block-blob-tier=cool
+
+Change the access tier of every blob in a virtual directory to archive:
+
+   - azcopy set-properties "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" --block-blob-tier=archive --recursive=true
+
+Replace the metadata of all the jpg blobs in a container:
+
+   - azcopy set-properties "https://[account].blob.core.windows.net/[container]?[SAS]" --metadata="k1=v1;k2=v2" --include-pattern="*.jpg" --recursive=true
+
+Replace the index tags of a single blob:
+
+   - azcopy set-properties "https://[account].blob.core.windows.net/[container]/[path/to/blob]?[SAS]" --blob-tags="project=alpha;owner=finance"
+`
+
+// ===================================== COMPARE COMMAND ===================================== //
+const compareCmdShortDescription = "Report the differences between the source and the destination"
+
+const compareCmdLongDescription = `
+Compare lists the source and the destination in the same way that sync does, but rather than transferring anything, it reports:
+
+  - the files that are only at the source
+  - the files that are only at the destination
+  - the files that are at both, but differ in size, or (as sync decides what to transfer) are more recent at the source.
+    With --compare-hash, files of the same size are compared by their MD5 hashes instead.
+
+The supported pairs are those of sync, as well as local <-> local, and any pairing of local, Azure Blob and Azure Files.
+The exit code is nonzero if there are any differences, so that the command can be used, for example, to check a copy in a CI pipeline.
+The differences are shown as they're found, or written to --report-file, as CSV or JSON lines, and the totals are shown at the end.
+
+As with sync, the side that is listed first (the local side, if there is one, otherwise the destination) is held in memory while the other side is listed.
+`
+
+const compareCmdExample = `
+Compare a local directory to a virtual directory, after uploading it:
+
+   - azcopy compare "/path/to/dir" "https://[account].blob.core.windows.net/[container]/[path/to/virtual/dir]"
+
+Same as above, but compare the MD5 hashes of the files, and write the differences to a CSV file:
+
+   - azcopy compare "/path/to/dir" "https://[account].blob.core.windows.net/[container]/[path/to/virtual/dir]" --compare-hash --report-file=differences.csv
+
+Compare two containers, and show the totals as JSON:
+
+   - azcopy compare "https://[account].blob.core.windows.net/[container]?[SAS]" "https://[account].blob.core.windows.net/[container]?[SAS]" --output-type=json
+
+Compare two local directories:
+
+   - azcopy compare "/path/to/dir" "/path/to/other/dir"
+`
+
 // ===================================== SYNC COMMAND ===================================== //
 const syncCmdShortDescription = "Replicate source to the destination location"
 
@@ -318,6 +443,10 @@ The last modified times are used for comparison. The file is skipped if the last
   - local <-> Azure Blob (either SAS or OAuth authentication can be used)
   - Azure Blob <-> Azure Blob (Source must include a SAS or is publicly accessible; either SAS or OAuth authentication can be used for destination)
   - Azure File <-> Azure File (Source must include a SAS or is publicly accessible; SAS authentication should be used for destination)
+  - local -> ADLS Gen2, only with --append-only
+
+With --append-only, local files are uploaded as append blobs (or to ADLS Gen2), and a file that has only grown since it was last synced, such as a log file,
+only has its new bytes appended to its destination. A file that has shrunk, or whose destination doesn't end with the same bytes as that range of the file, is uploaded in full.
 
 The sync command differs from the copy command in several ways:
 
@@ -365,6 +494,10 @@ Sync an entire directory but exclude certain files from the scope (For example:
 
    - azcopy sync "/path/to/dir" "https://[account].blob.core.windows.net/[container]/[path/to/virtual/dir]" --exclude="foo*;*bar"
 
+Sync a directory of growing log files, appending only what was added to each file since the last sync:
+
+   - azcopy sync "/path/to/logs" "https://[account].blob.core.windows.net/[container]/[path/to/virtual/dir]" --append-only
+
 Sync a single blob:
 
    - azcopy sync "https://[account].blob.core.windows.net/[container]/[path/to/blob]?[SAS]" "https://[account].blob.core.windows.net/[container]/[path/to/blob]"
@@ -403,7 +536,7 @@ Runs a performance benchmark by uploading test data to a specified destination.
 
 The benchmark command runs the same upload process as 'copy', except that: 
 
-  - There's no source parameter.  The command requires only a destination URL. In the current release, this destination URL must refer to a blob container.
+  - There's no source parameter (except in S2S mode, see below).  The command requires only a destination URL. In the current release, this destination URL must refer to a blob container.
   
   - The payload is described by command line parameters, which control how many files are auto-generated and 
     how big they are. The generation process takes place entirely in memory. Disk is not used.
@@ -414,6 +547,21 @@ The benchmark command runs the same upload process as 'copy', except that:
   
   - By default, the transferred data is deleted at the end of the test run.
 
+By default, the benchmark measures uploads. Use --mode to measure something else:
+
+  - Download: the test data is uploaded to the destination, and then the benchmark measures downloads of it. The downloaded
+    data is discarded as it arrives, so the speed of local disk does not affect the results.
+
+  - S2S: the command takes two container URLs, a source and a destination. The test data is uploaded to the source, and then
+    the benchmark measures service-side copies of it to the destination.
+
+Real workloads usually have a mixture of file sizes. To benchmark a mixture, use --size-spec instead of --file-count and
+--size-per-file. Each size in the mixture is measured separately, and the results are reported for each size, as well as
+for small files (in files per second) and larger files (in Mb/s). The generated data is never held in memory all at once.
+
+At the end of the run, the benchmark reports the achieved throughput, the count of requests by HTTP status code, the retry rate,
+and the concurrency and block size used.
+
 Benchmark mode will automatically tune itself to the number of parallel TCP connections that gives 
 the maximum throughput. It will display that number at the end. To prevent auto-tuning, set the 
 AZCOPY_CONCURRENCY_VALUE environment variable to a specific number of connections. 
@@ -435,4 +583,16 @@ in the copy command). The purpose of --put-md5 when benchmarking is to test whet
 selected file count and size:
 
    - azcopy bench "https://[account].blob.core.windows.net/[container]?<SAS>" --file-count 50000 --size-per-file 8M --put-md5
+
+Run a benchmark test with a mixture of 100,000 files of 4 KiB, 5000 files of 1 MiB and 50 files of 2 GiB:
+
+   - azcopy bench "https://[account].blob.core.windows.net/[container]?<SAS>" --size-spec 100000x4KiB,5000x1MiB,50x2GiB
+
+Run a benchmark test that measures downloads of 100 files, each 1 GiB in size:
+
+   - azcopy bench "https://[account].blob.core.windows.net/[container]?<SAS>" --mode download --file-count 100 --size-per-file 1G
+
+Run a benchmark test that measures service-side copies between two containers:
+
+   - azcopy bench "https://[srcaccount].blob.core.windows.net/[container]?<SAS>" "https://[destaccount].blob.core.windows.net/[container]?<SAS>" --mode s2s
 `
@@ -0,0 +1,148 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// jobPerformanceTracker accumulates, over each refresh of the progress, what the end of job performance report needs but
+// no single summary has, such as the peak throughput and the average concurrency.
+// It keeps its own interval, so that it's unaffected by when (and whether) the progress is displayed.
+// A nil tracker does nothing, and reports nothing.
+type jobPerformanceTracker struct {
+	lock sync.Mutex
+
+	enumerationStartTime time.Time
+	enumerationEndTime   time.Time
+
+	lastSampleTime     time.Time
+	lastBytesOverWire  uint64
+	peakThroughputMbps float64
+
+	connectionSamples    int64
+	connectionSampledSum int64
+}
+
+func (t *jobPerformanceTracker) enumerationStarted() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.enumerationStartTime = time.Now()
+}
+
+func (t *jobPerformanceTracker) enumerationDone() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.enumerationEndTime = time.Now()
+}
+
+// sample must be called with each summary that is fetched while the job runs
+func (t *jobPerformanceTracker) sample(summary common.ListJobSummaryResponse) {
+	t.sampleAt(summary, time.Now())
+}
+
+func (t *jobPerformanceTracker) sampleAt(summary common.ListJobSummaryResponse, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.lastSampleTime.IsZero() {
+		if seconds := now.Sub(t.lastSampleTime).Seconds(); seconds > 0 && summary.BytesOverWire >= t.lastBytesOverWire {
+			mbps := float64(summary.BytesOverWire-t.lastBytesOverWire) * 8 / base10Mega / seconds
+			if mbps > t.peakThroughputMbps {
+				t.peakThroughputMbps = mbps
+			}
+		}
+	}
+	t.lastSampleTime = now
+	t.lastBytesOverWire = summary.BytesOverWire
+
+	t.connectionSamples++
+	t.connectionSampledSum += summary.ActiveConnections
+}
+
+// report builds the report of a job that is done, given its final summary (which must already have been sampled) and how long it ran
+func (t *jobPerformanceTracker) report(summary common.ListJobSummaryResponse, elapsed time.Duration) *common.JobPerformanceReport {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	r := &common.JobPerformanceReport{
+		ElapsedSeconds:        ste.ToFixed(elapsed.Seconds(), 1),
+		PeakThroughputMbps:    ste.ToFixed(t.peakThroughputMbps, 1),
+		TotalRetries:          summary.RetryCount,
+		ChunkIntegrityRetries: summary.ChunkIntegrityRetries,
+		ServerBusyCount:       summary.RequestCountsByStatus[http.StatusServiceUnavailable],
+
+		ServerDirectedWaits:       summary.ServerDirectedWaits,
+		ServerDirectedWaitSeconds: summary.ServerDirectedWaitSeconds,
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		r.AverageThroughputMbps = ste.ToFixed(float64(summary.BytesOverWire)*8/base10Mega/seconds, 1)
+	}
+	if t.connectionSamples > 0 {
+		r.AverageConcurrency = ste.ToFixed(float64(t.connectionSampledSum)/float64(t.connectionSamples), 1)
+	}
+	if !t.enumerationStartTime.IsZero() && !t.enumerationEndTime.IsZero() {
+		r.EnumerationSeconds = ste.ToFixed(t.enumerationEndTime.Sub(t.enumerationStartTime).Seconds(), 1)
+		r.AfterEnumerationSeconds = ste.ToFixed(t.lastSampleTime.Sub(t.enumerationEndTime).Seconds(), 1)
+	}
+	return r
+}
+
+func formatPerformanceReport(summary common.ListJobSummaryResponse) string {
+	r := summary.PerformanceReport
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf(
+		`
+
+Performance:
+Elapsed Time (Seconds): %v
+Time in Enumeration (Seconds): %v
+Time after Enumeration (Seconds): %v
+Average Throughput (Mb/s): %v
+Peak Throughput (Mb/s): %v
+Total Retries: %v
+Chunks Downloaded Again After MD5 Mismatch: %v
+Throttling Events (Server Busy): %v
+Waits Directed by the Service: %v (%v seconds)
+Average Concurrency: %v`,
+		r.ElapsedSeconds, r.EnumerationSeconds, r.AfterEnumerationSeconds, r.AverageThroughputMbps, r.PeakThroughputMbps,
+		r.TotalRetries, r.ChunkIntegrityRetries, r.ServerBusyCount, r.ServerDirectedWaits, r.ServerDirectedWaitSeconds, r.AverageConcurrency)
+}
@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -28,11 +29,15 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
 )
 
 func init() {
 	type JobsCleanReq struct {
-		withStatus string
+		withStatus       string
+		jobID            string
+		scrubDestination bool
+		destinationSAS   string
 	}
 
 	commandLineInput := JobsCleanReq{}
@@ -51,6 +56,31 @@ func init() {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
+			if commandLineInput.jobID != "" || commandLineInput.scrubDestination {
+				if commandLineInput.jobID == "" || !commandLineInput.scrubDestination {
+					glcm.Error("--job and --scrub-destination must be used together. To remove the log and plan files of a single job, use azcopy jobs rm")
+				}
+				jobID, err := common.ParseJobID(commandLineInput.jobID)
+				if err != nil {
+					glcm.Error("invalid jobId given " + commandLineInput.jobID)
+				}
+
+				scrubber, err := handleScrubJobDestinationCommand(jobID, commandLineInput.destinationSAS)
+				if err != nil {
+					glcm.Error(fmt.Sprintf("Failed to scrub the destination of job %s due to error: %s.", jobID, err))
+				}
+				exitCode := common.EExitCode.Success()
+				if scrubber.failed() > 0 {
+					exitCode = common.EExitCode.Error()
+				}
+				glcm.Exit(func(format common.OutputFormat) string {
+					return fmt.Sprintf("Removed what %v transfers left at their destinations, %v transfers left nothing, and %v could not be scrubbed, "+
+						"which the next scrub of the job will try again. They are listed in %s. "+
+						"The log and plan files of the job are kept; they can be removed with azcopy jobs rm.",
+						scrubber.removed(), scrubber.clean(), scrubber.failed(), scrubReportPath(jobID))
+				}, exitCode)
+			}
+
 			withStatus := common.EJobStatus
 			err := withStatus.Parse(commandLineInput.withStatus)
 			if err != nil {
@@ -79,6 +109,52 @@ func init() {
 	// NOTE: we have way more job status than we normally need, only show the most common ones
 	jobsCleanCmd.PersistentFlags().StringVar(&commandLineInput.withStatus, "with-status", "All",
 		"only remove the jobs with this status, available values: Cancelled, Completed, Failed, InProgress, All")
+	jobsCleanCmd.PersistentFlags().StringVar(&commandLineInput.jobID, "job", "", "The ID of the job whose destination is scrubbed. Must be used with scrub-destination.")
+	jobsCleanCmd.PersistentFlags().BoolVar(&commandLineInput.scrubDestination, "scrub-destination", false, "Rather than remove log and plan files, "+
+		"remove what the transfers of the job given by --job that were started but never completed left at their destinations, e.g. because the job was cancelled: "+
+		"the temporary files that ADLS Gen2 and Azure Files destinations are written under, and the partial ADLS Gen2 files, Azure files and local files "+
+		"that were written under their own names. A destination is only removed if it was modified after the job started. Blob destinations are left as they are, "+
+		"since their uncommitted blocks expire on their own. The job must not be in progress or paused. What was removed is listed in a file next to the job's log, "+
+		"and a scrub that is interrupted, or that fails to scrub some destinations, can be run again to carry on.")
+	jobsCleanCmd.PersistentFlags().StringVar(&commandLineInput.destinationSAS, "destination-sas", "", "The SAS token of the destination of the job whose destination is scrubbed.")
+}
+
+// handleScrubJobDestinationCommand scrubs the destinations of the job's transfers that were started but never completed
+func handleScrubJobDestinationCommand(jobID common.JobID, destinationSAS string) (*destinationScrubber, error) {
+	var job common.GetJobFromToResponse
+	Rpc(common.ERpcCmd.GetJobFromTo(), &common.GetJobFromToRequest{JobID: jobID}, &job)
+	if job.ErrorMsg != "" {
+		return nil, errors.New(job.ErrorMsg)
+	}
+	switch job.JobStatus {
+	case common.EJobStatus.InProgress(), common.EJobStatus.Paused():
+		// its transfers that are started may yet complete
+		return nil, fmt.Errorf("the job is %s. Cancel it first with azcopy cancel %s", job.JobStatus, jobID)
+	}
+
+	var transfers common.ListJobTransfersResponse
+	Rpc(common.ERpcCmd.ListJobTransfers(), common.ListJobTransfersRequest{JobID: jobID, OfStatus: common.ETransferStatus.All()}, &transfers)
+	if transfers.ErrorMsg != "" {
+		return nil, errors.New(transfers.ErrorMsg)
+	}
+
+	location := job.FromTo.To()
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	credentialInfo, _, err := getCredentialInfoForLocation(ctx, location, job.Destination, destinationSAS, false)
+	if err != nil {
+		return nil, err
+	}
+	p, err := initPipeline(ctx, location, credentialInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	scrubber, err := newDestinationScrubber(ctx, location, p, job.StartTime, job.TempNameSuffix, strings.TrimPrefix(destinationSAS, "?"), scrubReportPath(jobID))
+	if err != nil {
+		return nil, err
+	}
+	scrubber.scrubAll(transfers.Details)
+	return scrubber, scrubber.close()
 }
 
 func handleCleanJobsCommand(givenStatus common.JobStatus) error {
@@ -113,7 +189,7 @@ func handleCleanJobsCommand(givenStatus common.JobStatus) error {
 func blindDeleteAllJobFiles() (int, error) {
 	// get rid of the job plan files
 	numPlanFilesRemoved, err := removeFilesWithPredicate(azcopyJobPlanFolder, func(s string) bool {
-		if strings.Contains(s, ".steV") {
+		if strings.Contains(s, ".steV") || strings.HasSuffix(s, common.JobTemplateFileSuffix) {
 			return true
 		}
 		return false
@@ -0,0 +1,92 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+func init() {
+	var jobID common.JobID
+	var out string
+
+	jobsExportTemplateCmd := &cobra.Command{
+		Use:     "export-template [jobID]",
+		Short:   exportTemplateJobsCmdShortDescription,
+		Long:    exportTemplateJobsCmdLongDescription,
+		Example: exportTemplateJobsCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("export-template command requires the JobID")
+			}
+			var err error
+			if jobID, err = common.ParseJobID(args[0]); err != nil {
+				return errors.New("invalid jobId given " + args[0])
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			template, err := exportJobTemplate(jobID, out)
+			if err != nil {
+				glcm.Error(fmt.Sprintf("Failed to export the template of job %s due to error: %s.", jobID, err))
+			}
+
+			glcm.Exit(func(format common.OutputFormat) string {
+				if out != "" {
+					return fmt.Sprintf("Exported the template of job %s, whose flag set hash is %s, to %s.", jobID, template.FlagSetHash, out)
+				}
+				yamlOutput, err := yaml.Marshal(template)
+				common.PanicIfErr(err)
+				return string(yamlOutput)
+			}, common.EExitCode.Success())
+		},
+	}
+
+	jobsCmd.AddCommand(jobsExportTemplateCmd)
+
+	jobsExportTemplateCmd.PersistentFlags().StringVar(&out, "out", "", "The file to write the template to, e.g. job.yaml. If omitted, the template is written to the output.")
+}
+
+// exportJobTemplate returns the template that was kept when the job was started, and writes it to out, unless that's empty
+func exportJobTemplate(jobID common.JobID, out string) (common.JobTemplate, error) {
+	path := common.JobTemplatePath(azcopyJobPlanFolder, jobID)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return common.JobTemplate{}, errors.New("no template was kept for this job. Templates are kept for the copy jobs started by AzCopy " + common.AzcopyVersion + " and later")
+	}
+	template, err := common.ReadJobTemplate(path)
+	if err != nil || out == "" {
+		return template, err
+	}
+
+	exported, err := yaml.Marshal(template)
+	if err != nil {
+		return template, err
+	}
+	return template, ioutil.WriteFile(out, exported, 0644)
+}
@@ -0,0 +1,145 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+func init() {
+	var since, withStatus string
+
+	jobsHistoryCmd := &cobra.Command{
+		Use:     "history",
+		Short:   historyJobsCmdShortDescription,
+		Long:    historyJobsCmdLongDescription,
+		Example: historyJobsCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return errors.New("history command does not accept arguments")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			status := common.EJobStatus.All()
+			if err := status.Parse(withStatus); err != nil {
+				glcm.Error(fmt.Sprintf("Failed to parse --status due to error: %s.", err))
+			}
+			age, err := parseHistoryAge(since)
+			if err != nil {
+				glcm.Error(fmt.Sprintf("Failed to parse --since due to error: %s.", err))
+			}
+
+			entries, err := common.ReadJobHistory(common.JobHistoryPath(azcopyAppPathFolder))
+			if err != nil {
+				glcm.Error(fmt.Sprintf("Failed to read the job history due to error: %s.", err))
+			}
+			entries = filterJobHistory(entries, age, status, time.Now())
+
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					jsonOutput, err := json.Marshal(entries)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
+				}
+				return formatJobHistory(entries)
+			}, common.EExitCode.Success())
+		},
+	}
+
+	jobsCmd.AddCommand(jobsHistoryCmd)
+
+	jobsHistoryCmd.PersistentFlags().StringVar(&since, "since", "",
+		"only list the jobs that ended within this long, e.g. 30d or 12h. By default, all the jobs in the history are listed")
+	jobsHistoryCmd.PersistentFlags().StringVar(&withStatus, "status", "All",
+		"only list the jobs that ended with this status, e.g. Completed, CompletedWithErrors, Failed or Cancelled")
+}
+
+// parseHistoryAge parses how far back the history goes: a duration, such as 12h, or a number of days, such as 30d.
+// Zero, for an empty value, means the whole history
+func parseHistoryAge(since string) (time.Duration, error) {
+	if since == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(since, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(since, "d"), 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("'%s' is not a number of days", since)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(since)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("'%s' is neither a number of days, such as 30d, nor a duration, such as 12h", since)
+	}
+	return age, nil
+}
+
+// filterJobHistory keeps the entries that ended within age of now (or all of them, for a zero age) with the given status
+// (or any status, for All), and sorts them so that the job that ended last comes first
+func filterJobHistory(entries []common.JobHistoryEntry, age time.Duration, status common.JobStatus, now time.Time) []common.JobHistoryEntry {
+	kept := make([]common.JobHistoryEntry, 0, len(entries))
+	for _, e := range entries {
+		if age != 0 && now.Sub(e.EndTime) > age {
+			continue
+		}
+		if status != common.EJobStatus.All() && e.JobStatus != status {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].EndTime.After(kept[j].EndTime)
+	})
+	return kept
+}
+
+func formatJobHistory(entries []common.JobHistoryEntry) string {
+	if len(entries) == 0 {
+		return "No jobs in the history match."
+	}
+
+	var sb strings.Builder
+	for _, e := range entries {
+		sb.WriteString(fmt.Sprintf("JobId: %s\nSource: %s\nDestination: %s\nStart Time: %s\nEnd Time: %s\nStatus: %s\n"+
+			"Transfers: %v total, %v completed, %v failed, %v skipped\nBytes Transferred: %v\nCommand: %s\n\n",
+			e.JobID.String(),
+			e.Source,
+			e.Destination,
+			e.StartTime.Local().Format(time.RFC850),
+			e.EndTime.Local().Format(time.RFC850),
+			e.JobStatus,
+			e.TotalTransfers, e.TransfersCompleted, e.TransfersFailed, e.TransfersSkipped,
+			e.TotalBytesTransferred,
+			e.Command))
+	}
+	return sb.String()
+}
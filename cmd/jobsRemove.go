@@ -76,7 +76,7 @@ func init() {
 func handleRemoveSingleJob(jobID common.JobID) error {
 	// get rid of the job plan files
 	numPlanFileRemoved, err := removeFilesWithPredicate(azcopyJobPlanFolder, func(s string) bool {
-		if strings.Contains(s, jobID.String()) && strings.Contains(s, ".steV") {
+		if strings.Contains(s, jobID.String()) && (strings.Contains(s, ".steV") || strings.HasSuffix(s, common.JobTemplateFileSuffix)) {
 			return true
 		}
 		return false
@@ -49,6 +49,12 @@ type resumeJobController struct {
 
 	// used to calculate job summary
 	jobStartTime time.Time
+
+	// nil unless something is to happen once the resumed job reaches a terminal state
+	completionHook *completionHook
+
+	// what the x-ms-client-request-id of each request of the job starts with, which the job keeps from when it was started
+	clientRequestIDPrefix string
 }
 
 // wraps call to lifecycle manager to wait for the job to complete
@@ -56,7 +62,8 @@ type resumeJobController struct {
 // if blocking is specified to false, then another goroutine spawns and wait out the job
 func (cca *resumeJobController) waitUntilJobCompletion(blocking bool) {
 	// print initial message to indicate that the job is starting
-	glcm.Init(common.GetStandardInitOutputBuilder(cca.jobID.String(), fmt.Sprintf("%s%s%s.log", azcopyLogPathFolder, common.OS_PATH_SEPARATOR, cca.jobID), false, ""))
+	glcm.Init(common.GetStandardInitOutputBuilder(cca.jobID.String(), common.JobLogFilePath(azcopyLogPathFolder, cca.jobID), false, "", cca.clientRequestIDPrefix))
+	startJobControl(cca.jobID)
 
 	// initialize the times necessary to track progress
 	cca.jobStartTime = time.Now()
@@ -95,6 +102,7 @@ func (cca *resumeJobController) ReportProgressOrExit(lcm common.LifecycleMgr) {
 		if summary.TransfersFailed > 0 {
 			exitCode = common.EExitCode.Error()
 		}
+		cca.completionHook.run(cca.jobID, summary.JobStatus)
 
 		lcm.Exit(func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
@@ -147,16 +155,20 @@ func (cca *resumeJobController) ReportProgressOrExit(lcm common.LifecycleMgr) {
 				// As there would be case when no bits sent from local, e.g. service side copy, when throughput = 0, hide it.
 				throughputString = ""
 			}
+			if limits := formatJobLimits(summary); limits != "" {
+				throughputString = strings.TrimPrefix(throughputString+", "+limits, ", ")
+			}
 
 			// indicate whether constrained by disk or not
 			perfString, diskString := getPerfDisplayText(summary.PerfStrings, summary.PerfConstraint, duration, false)
 
-			return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Pending, %v Skipped, %v Total%s, %s%s%s",
+			return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Pending, %v Skipped, %v Total%s, %s%s%s%s%s",
 				summary.PercentComplete,
 				summary.TransfersCompleted,
 				summary.TransfersFailed,
 				summary.TotalTransfers-(summary.TransfersCompleted+summary.TransfersFailed+summary.TransfersSkipped),
-				summary.TransfersSkipped, summary.TotalTransfers, scanningString, perfString, throughputString, diskString)
+				summary.TransfersSkipped, summary.TotalTransfers, scanningString, perfString, throughputString, diskString,
+				formatInFlightTransfers(summary.InFlightTransfers), formatHashBackfillsInProgress(summary))
 		}
 	})
 }
@@ -198,6 +210,17 @@ func init() {
 	// oauth options
 	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.SourceSAS, "source-sas", "", "Source SAS token of the source for a given Job ID.")
 	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.DestinationSAS, "destination-sas", "", "destination SAS token of the destination for a given Job ID.")
+	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.destinationShards, destinationShardsFlag, "", "The containers that the destination of a sharded job was spread across, with their SAS tokens, separated by ';'. "+
+		"Each file is sent to the container that it was assigned when the job was started.")
+	resumeCmd.PersistentFlags().BoolVar(&resumeCmdArgs.verify, "resume-verify", false, "Before resuming, check the destination of each transfer that succeeded or failed, with a HEAD request, "+
+		"and correct its status by what's there: a transfer whose destination is gone, or is of the wrong length, is done again, "+
+		"and a failed transfer whose destination was written in full after the job started counts as done.")
+	resumeCmd.PersistentFlags().BoolVar(&resumeCmdArgs.verifyStrict, "resume-verify-strict", false, "Like resume-verify, but also compare the MD5 hashes of the destinations with those of the sources, "+
+		"when both are known. A failed transfer then only counts as done if they match.")
+	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.onCompleteExec, "on-complete-exec", "", "Run this command once the resumed job reaches a terminal state, e.g. \"/path/script {jobid} {status}\". "+
+		"{jobid} and {status} are replaced with the ID of the job and its final status. The command runs in the shell of the OS, is killed after 5 minutes, "+
+		"and what it writes is put in the job log. It's not carried over from the command that started the job.")
+	resumeCmd.PersistentFlags().BoolVar(&resumeCmdArgs.notify, "notify", false, "Raise a desktop notification once the resumed job reaches a terminal state. On Linux it needs notify-send. Off by default.")
 }
 
 type resumeCmdArgs struct {
@@ -207,6 +230,16 @@ type resumeCmdArgs struct {
 
 	SourceSAS      string
 	DestinationSAS string
+	// the containers of a sharded destination, with their SAS
+	destinationShards string
+
+	// whether to check the destinations of the finished transfers before resuming, and whether to compare their MD5 hashes too
+	verify       bool
+	verifyStrict bool
+
+	// what to run once the resumed job reaches a terminal state, and whether to raise a desktop notification then
+	onCompleteExec string
+	notify         bool
 }
 
 // processes the resume command,
@@ -251,6 +284,19 @@ func (rca resumeCmdArgs) process() error {
 		}
 	}
 
+	// the parts of a sharded job find the SAS of their shard by its URL. The first one stands for the destination SAS if there's none
+	var destinationShardSAS map[string]string
+	if rca.destinationShards != "" {
+		shards, err := parseDestinationShards(rca.destinationShards)
+		if err != nil {
+			return err
+		}
+		destinationShardSAS = shards.sasByRoot()
+		if rca.DestinationSAS == "" {
+			rca.DestinationSAS = shards.shards[0].sas
+		}
+	}
+
 	// Get fromTo info, so we can decide what's the proper credential type to use.
 	var getJobFromToResponse common.GetJobFromToResponse
 	Rpc(common.ERpcCmd.GetJobFromTo(),
@@ -267,7 +313,7 @@ func (rca resumeCmdArgs) process() error {
 		return errors.New("resuming benchmark jobs is not supported")
 	}
 
-	ctx := context.TODO()
+	ctx := ste.WithClientRequestIDPrefix(context.TODO(), getJobFromToResponse.ClientRequestIDPrefix)
 	// Initialize credential info.
 	credentialInfo := common.CredentialInfo{}
 	// TODO: Replace context with root context
@@ -293,25 +339,50 @@ func (rca resumeCmdArgs) process() error {
 		}
 	}
 
+	// A blob to blob job whose source has no SAS reads it with the OAuth token, as it did when it was started
+	if getJobFromToResponse.FromTo == common.EFromTo.BlobBlob() && rca.SourceSAS == "" {
+		srcCredInfo, _, err := getCredentialInfoForLocation(ctx, getJobFromToResponse.FromTo.From(), getJobFromToResponse.Source, rca.SourceSAS, true)
+		if err != nil {
+			return err
+		}
+		useOAuthForS2SSourceIfPossible(&credentialInfo, getJobFromToResponse.FromTo, srcCredInfo)
+	}
+
 	// Send resume job request.
 	var resumeJobResponse common.CancelPauseResumeResponse
 	Rpc(common.ERpcCmd.ResumeJob(),
 		&common.ResumeJobRequest{
-			JobID:           jobID,
-			SourceSAS:       rca.SourceSAS,
-			DestinationSAS:  rca.DestinationSAS,
-			CredentialInfo:  credentialInfo,
-			IncludeTransfer: includeTransfer,
-			ExcludeTransfer: excludeTransfer,
+			JobID:                jobID,
+			SourceSAS:            rca.SourceSAS,
+			DestinationSAS:       rca.DestinationSAS,
+			DestinationShardSAS:  destinationShardSAS,
+			CredentialInfo:       credentialInfo,
+			IncludeTransfer:      includeTransfer,
+			ExcludeTransfer:      excludeTransfer,
+			VerifyDestinations:   rca.verify || rca.verifyStrict,
+			VerifyDestinationMD5: rca.verifyStrict,
 		},
 		&resumeJobResponse)
 
 	if !resumeJobResponse.CancelledPauseResumed {
 		glcm.Error(resumeJobResponse.ErrorMsg)
 	}
+	if rca.verify || rca.verifyStrict {
+		glcm.Info(formatResumeVerification(resumeJobResponse))
+	}
 
-	controller := resumeJobController{jobID: jobID}
+	controller := resumeJobController{jobID: jobID, completionHook: newCompletionHook(rca.onCompleteExec, rca.notify),
+		clientRequestIDPrefix: getJobFromToResponse.ClientRequestIDPrefix}
 	controller.waitUntilJobCompletion(true)
 
 	return nil
 }
+
+func formatResumeVerification(response common.CancelPauseResumeResponse) string {
+	message := fmt.Sprintf("Verified the destinations: %v transfers that had failed were found done, and %v transfers that had succeeded are copied again",
+		response.TransfersFoundDone, response.TransfersToCopyAgain)
+	if response.DestinationsNotVerified > 0 {
+		message += fmt.Sprintf(". %v destinations could not be checked, and are listed in the log", response.DestinationsNotVerified)
+	}
+	return message
+}
@@ -0,0 +1,126 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/spf13/cobra"
+)
+
+type rawJobsSetCmdArgs struct {
+	jobID string
+	// the cap comes from the --cap-mbps flag of the root command, and is only changed if the flag is given
+	changeCapMbps bool
+	capMbps       uint32
+	concurrency   int
+}
+
+func (raw rawJobsSetCmdArgs) cook() (common.SetJobLimitsRequest, error) {
+	jobID, err := common.ParseJobID(raw.jobID)
+	if err != nil {
+		return common.SetJobLimitsRequest{}, fmt.Errorf("invalid jobId string passed: %q", raw.jobID)
+	}
+	if raw.concurrency < 0 {
+		return common.SetJobLimitsRequest{}, errors.New("the concurrency must be greater than zero")
+	}
+	if !raw.changeCapMbps && raw.concurrency == 0 {
+		return common.SetJobLimitsRequest{}, errors.New("nothing to set: specify --cap-mbps, --concurrency, or both")
+	}
+
+	return common.SetJobLimitsRequest{
+		JobID:         jobID,
+		ChangeCapMbps: raw.changeCapMbps,
+		CapMbps:       int64(raw.capMbps),
+		Concurrency:   raw.concurrency,
+	}, nil
+}
+
+func init() {
+	raw := rawJobsSetCmdArgs{}
+
+	jobsSetCmd := &cobra.Command{
+		Use:     "set [jobID]",
+		Short:   setJobsCmdShortDescription,
+		Long:    setJobsCmdLongDescription,
+		Example: setJobsCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("set job command requires the JobID")
+			}
+			raw.jobID = args[0]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			raw.changeCapMbps = cmd.Flags().Changed("cap-mbps")
+			raw.capMbps = cmdLineCapMegaBitsPerSecond
+
+			req, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+			}
+
+			resp, err := sendJobLimits(req)
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+			if !resp.LimitsSet {
+				glcm.Error(fmt.Sprintf("failed to set the limits of job %s: %s", req.JobID, resp.ErrorMsg))
+			}
+
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					jsonOutput, err := json.Marshal(resp)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
+				}
+				return fmt.Sprintf("Job %s now runs with %s and %d concurrent connections.", req.JobID, formatCapMbps(resp.CapMbps), resp.Concurrency)
+			}, common.EExitCode.Success())
+		},
+	}
+
+	jobsCmd.AddCommand(jobsSetCmd)
+
+	jobsSetCmd.Flags().IntVar(&raw.concurrency, "concurrency", 0, "The number of concurrent connections of the job. Setting it ends the automatic tuning of the concurrency. The bandwidth cap is set with cap-mbps, and removed with cap-mbps=0.")
+}
+
+func formatCapMbps(capMbps int64) string {
+	if capMbps == 0 {
+		return "no bandwidth cap"
+	}
+	return fmt.Sprintf("a bandwidth cap of %d Mb/s", capMbps)
+}
+
+// formatJobLimits is how the progress line shows the bandwidth cap, and the concurrency when it was set while the job runs
+func formatJobLimits(summary common.ListJobSummaryResponse) string {
+	limits := make([]string, 0, 2)
+	if summary.CapMbps > 0 {
+		limits = append(limits, fmt.Sprintf("Cap (Mb/s): %d", summary.CapMbps))
+	}
+	if summary.Concurrency > 0 {
+		limits = append(limits, fmt.Sprintf("Concurrency: %d", summary.Concurrency))
+	}
+	return strings.Join(limits, ", ")
+}
@@ -24,10 +24,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"encoding/json"
 
 	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
 	"github.com/spf13/cobra"
 )
 
@@ -119,7 +121,11 @@ func PrintJobTransfers(listTransfersResponse common.ListJobTransfersResponse) {
 		sb.WriteString("----------- Transfers for JobId " + listTransfersResponse.JobID.String() + " -----------\n")
 		for index := 0; index < len(listTransfersResponse.Details); index++ {
 			sb.WriteString("transfer--> source: " + listTransfersResponse.Details[index].Src + " destination: " +
-				listTransfersResponse.Details[index].Dst + " status " + listTransfersResponse.Details[index].TransferStatus.String() + "\n")
+				listTransfersResponse.Details[index].Dst + " status " + listTransfersResponse.Details[index].TransferStatus.String())
+			if id := listTransfersResponse.Details[index].ClientRequestID; id != "" {
+				sb.WriteString(" client request ID " + id)
+			}
+			sb.WriteString("\n")
 		}
 
 		return sb.String()
@@ -135,6 +141,9 @@ func PrintJobProgressSummary(summary common.ListJobSummaryResponse) {
 	// Reset the bytes over the wire counter
 	summary.BytesOverWire = 0
 
+	summary.LogDirectory = azcopyLogPathFolder
+	summary.LogFileLocation = common.JobLogFilePath(azcopyLogPathFolder, summary.JobID)
+
 	glcm.Exit(func(format common.OutputFormat) string {
 		if format == common.EOutputFormat.Json() {
 			jsonOutput, err := json.Marshal(summary) // see note below re % complete being approximate. We can't include "approx" in the JSON.
@@ -143,7 +152,7 @@ func PrintJobProgressSummary(summary common.ListJobSummaryResponse) {
 		}
 
 		return fmt.Sprintf(
-			"\nJob %s summary\nTotal Number Of Transfers: %v\nNumber of Transfers Completed: %v\nNumber of Transfers Failed: %v\nNumber of Transfers Skipped: %v\nPercent Complete (approx): %.1f\nFinal Job Status: %v\n",
+			"\nJob %s summary\nTotal Number Of Transfers: %v\nNumber of Transfers Completed: %v\nNumber of Transfers Failed: %v\nNumber of Transfers Skipped: %v\nPercent Complete (approx): %.1f\nFinal Job Status: %v\nLog Directory: %v\nCurrent Log File: %v\nClient Request ID Prefix: %v\n%s",
 			summary.JobID.String(),
 			summary.TotalTransfers,
 			summary.TransfersCompleted,
@@ -151,6 +160,47 @@ func PrintJobProgressSummary(summary common.ListJobSummaryResponse) {
 			summary.TransfersSkipped,
 			summary.PercentComplete, // noted as approx in the format string because won't include in-flight files if this Show command is run from a different process
 			summary.JobStatus,
+			summary.LogDirectory,
+			summary.LogFileLocation,
+			summary.ClientRequestIDPrefix,
+			formatInFlightTransferDetails(summary.InFlightTransfers),
 		)
 	}, common.EExitCode.Success())
 }
+
+// formatInFlightTransfers shows the progress of the largest files in flight, to go on the progress line of a job
+func formatInFlightTransfers(transfers []common.InFlightTransfer) string {
+	if len(transfers) == 0 {
+		return ""
+	}
+
+	progress := make([]string, 0, len(transfers))
+	for _, t := range transfers {
+		// just the name of the file, since the progress line has little room
+		name := t.Src[strings.LastIndexAny(t.Src, `/\`)+1:]
+		progress = append(progress, name+" "+formatInFlightProgress(t))
+	}
+	return ", In Flight: " + strings.Join(progress, "; ")
+}
+
+// formatInFlightTransferDetails lists the progress of the largest files in flight, one per line
+func formatInFlightTransferDetails(transfers []common.InFlightTransfer) string {
+	if len(transfers) == 0 {
+		return ""
+	}
+
+	b := strings.Builder{}
+	b.WriteString("In-Flight Transfers:\n")
+	for _, t := range transfers {
+		b.WriteString(fmt.Sprintf("  %s: %s\n", t.Src, formatInFlightProgress(t)))
+	}
+	return b.String()
+}
+
+func formatInFlightProgress(t common.InFlightTransfer) string {
+	progress := fmt.Sprintf("%.1f %% of %s", t.PercentComplete, byteSizeToString(int64(t.Size)))
+	if t.ThroughputMbps > 0 {
+		progress += fmt.Sprintf(" at %v Mb/s, %v left", ste.ToFixed(t.ThroughputMbps, 1), time.Duration(t.SecondsRemaining)*time.Second)
+	}
+	return progress
+}
@@ -118,10 +118,11 @@ func HandleListContainerCommand(source string, location common.Location) (err er
 			return err
 		} else {
 			credentialInfo.OAuthTokenInfo = *tokenInfo
+			warnIfOAuthTokenIsForOtherCloud(credentialInfo.OAuthTokenInfo, source)
 		}
 	}
 
-	traverser, err := initResourceTraverser(source, location, &ctx, &credentialInfo, nil, nil, true, false, func() {})
+	traverser, err := initResourceTraverser(source, location, &ctx, &credentialInfo, nil, nil, true, false, func() {}, nil, nil)
 
 	if err != nil {
 		return fmt.Errorf("failed to initialize traverser: %s", err.Error())
@@ -109,6 +109,7 @@ func (cookedArgs cookedMakeCmdArgs) process() (err error) {
 			return err
 		} else {
 			credentialInfo.OAuthTokenInfo = *tokenInfo
+			warnIfOAuthTokenIsForOtherCloud(credentialInfo.OAuthTokenInfo, cookedArgs.resourceURL.String())
 		}
 	}
 
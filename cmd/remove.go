@@ -90,6 +90,14 @@ func init() {
 	deleteCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
 	deleteCmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when removing. "+
 		"This option does not support wildcard characters (*). Checks relative path prefix. For example: myFolder;myFolder/subDirName/file.pdf")
+	deleteCmd.PersistentFlags().StringVar(&raw.includePathPattern, "include-path-pattern", "", "Include only the paths that match these patterns when removing. "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. For example: logs/2023/*;**/*.csv")
+	deleteCmd.PersistentFlags().StringVar(&raw.excludePathPattern, "exclude-path-pattern", "", "Exclude the paths that match these patterns when removing. "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Exclusions win over inclusions. For example: **/temp/**;*.tmp")
 	deleteCmd.PersistentFlags().StringVar(&raw.listOfFilesToCopy, "list-of-files", "", "Defines the location of a file which contains the list of files and directories to be deleted. The relative paths should be delimited by line breaks, and the paths should NOT be URL-encoded.")
+	deleteCmd.PersistentFlags().BoolVar(&raw.breakLeaseOnOverwrite, "break-lease-on-overwrite", false, "Break the lease of each blob that can't be removed because it's leased, "+
+		"and remove it. Each broken lease is recorded in the log. By default, such blobs fail.")
+	deleteCmd.PersistentFlags().BoolVar(&raw.ignoreSASValidation, ignoreSASValidationFlag, false, "Don't check, before the enumeration, that the SAS allows Delete, and List when the files are listed. "+
+		"Use it for SASs whose permissions azcopy can't tell from the token.")
 	deleteCmd.PersistentFlags().StringVar(&raw.deleteSnapshotsOption, "delete-snapshots", "", "By default, the delete operation fails if a blob has snapshots. Specify 'include' to remove the root blob and all its snapshots; alternatively specify 'only' to remove only the snapshots but keep the root blob.")
 }
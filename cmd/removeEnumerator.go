@@ -45,7 +45,7 @@ var NothingToRemoveError = errors.New("nothing found to remove")
 func newRemoveEnumerator(cca *cookedCopyCmdArgs) (enumerator *copyEnumerator, err error) {
 	var sourceTraverser resourceTraverser
 
-	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	ctx := withClientRequestIDPrefix(context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion), cca.jobID)
 	rawURL, err := url.Parse(cca.source)
 
 	if err != nil {
@@ -57,7 +57,7 @@ func newRemoveEnumerator(cca *cookedCopyCmdArgs) (enumerator *copyEnumerator, er
 	}
 
 	// Include-path is handled by ListOfFilesChannel.
-	sourceTraverser, err = initResourceTraverser(rawURL.String(), cca.fromTo.From(), &ctx, &cca.credentialInfo, nil, cca.listOfFilesChannel, cca.recursive, false, func() {})
+	sourceTraverser, err = initResourceTraverser(rawURL.String(), cca.fromTo.From(), &ctx, &cca.credentialInfo, nil, cca.listOfFilesChannel, cca.recursive, false, func() {}, nil, nil)
 
 	// report failure to create traverser
 	if err != nil {
@@ -72,6 +72,7 @@ func newRemoveEnumerator(cca *cookedCopyCmdArgs) (enumerator *copyEnumerator, er
 	// set up the filters in the right order
 	filters := append(includeFilters, excludeFilters...)
 	filters = append(filters, excludePathFilters...)
+	filters = append(filters, buildPathPatternFilters(cca.includePathGlobs, cca.excludePathGlobs)...)
 
 	finalize := func() error {
 		jobInitiated, err := transferScheduler.dispatchFinalPart()
@@ -183,8 +184,9 @@ func removeBfsResources(cca *cookedCopyCmdArgs) (err error) {
 	failedTransfers := make([]common.TransferDetail, 0)
 
 	// read from the list of files channel to find out what needs to be deleted.
-	childPath, ok := <-cca.listOfFilesChannel
-	for ; ok; childPath, ok = <-cca.listOfFilesChannel {
+	entry, ok := <-cca.listOfFilesChannel
+	for ; ok; entry, ok = <-cca.listOfFilesChannel {
+		childPath := entry.Source
 		// remove the child path
 		urlParts.DirectoryOrFilePath = common.GenerateFullPath(parentPath, childPath)
 		successMessage, err := removeSingleBfsResource(urlParts, p, ctx, cca.recursive)
@@ -27,18 +27,20 @@ import (
 // extract the right info from cooked arguments and instantiate a generic copy transfer processor from it
 func newRemoveTransferProcessor(cca *cookedCopyCmdArgs, numOfTransfersPerPart int) *copyTransferProcessor {
 	copyJobTemplate := &common.CopyJobPartOrderRequest{
-		JobID:         cca.jobID,
-		CommandString: cca.commandString,
-		FromTo:        cca.fromTo,
-		SourceRoot:    consolidatePathSeparators(cca.source),
+		JobID:                 cca.jobID,
+		CommandString:         cca.commandString,
+		ClientRequestIDPrefix: clientRequestIDPrefix,
+		FromTo:                cca.fromTo,
+		SourceRoot:            consolidatePathSeparators(cca.source),
 
 		// authentication related
 		CredentialInfo: cca.credentialInfo,
 		SourceSAS:      cca.sourceSAS,
 
 		// flags
-		LogLevel:       cca.logVerbosity,
-		BlobAttributes: common.BlobTransferAttributes{DeleteSnapshotsOption: cca.deleteSnapshotsOption},
+		LogLevel:              cca.logVerbosity,
+		BlobAttributes:        common.BlobTransferAttributes{DeleteSnapshotsOption: cca.deleteSnapshotsOption},
+		BreakLeaseOnOverwrite: cca.breakLeaseOnOverwrite,
 	}
 
 	reportFirstPart := func(jobStarted bool) {
@@ -23,8 +23,12 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -33,6 +37,7 @@ import (
 	"github.com/Azure/azure-storage-azcopy/ste"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var azcopyAppPathFolder string
@@ -43,6 +48,25 @@ var outputFormatRaw string
 var cancelFromStdin bool
 var azcopyOutputFormat common.OutputFormat
 var cmdLineCapMegaBitsPerSecond uint32
+var cmdLineCapTransactionsPerSecond uint32
+var cmdLineCapMetadataTransactionsPerSecond uint32
+var cmdLineLogFileMaxSizeMB uint32
+var cmdLineLogFileMaxRotated uint32
+var logTargetRaw string
+var syslogFacility string
+var trustedCAFile string
+var tlsMinVersion string
+var insecureSkipVerify bool
+var localAddress string
+var ipVersion string
+var schedulingRaw string
+var clientRequestIDPrefix string
+
+// the arguments of the command being run, without the name of the program. They are os.Args[1:], unless AzCopy runs inside another program
+var commandArgs = os.Args[1:]
+
+// the STE is started by the first command only, since it serves all the jobs of the process
+var steStarted bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -78,12 +102,63 @@ var rootCmd = &cobra.Command{
 		preferToAutoTuneGRs := cmd == benchCmd // TODO: do we have a better way to do this than making benchCmd global?
 		providePerformanceAdvice := cmd == benchCmd
 
-		// startup of the STE happens here, so that the startup can access the values of command line parameters that are defined for "root" command
-		concurrencySettings := ste.NewConcurrencySettings(azcopyMaxFileAndSocketHandles, preferToAutoTuneGRs)
-		err = ste.MainSTE(concurrencySettings, int64(cmdLineCapMegaBitsPerSecond), azcopyJobPlanFolder, azcopyLogPathFolder, providePerformanceAdvice)
+		var logFormat common.LogFormat
+		if err := logFormat.Parse(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.LogFormat())); err != nil {
+			return fmt.Errorf("invalid value for %s: %s. The choices include: text, json", common.EEnvironmentVariable.LogFormat().Name, err.Error())
+		}
+
+		if err := ste.ValidateRequestAPIVersion(ste.RequestAPIVersion); err != nil {
+			return fmt.Errorf("invalid value for %s: %s", common.EEnvironmentVariable.RequestApiVersion().Name, err.Error())
+		}
+
+		var scheduling common.ChunkScheduling
+		if err := scheduling.Parse(schedulingRaw); err != nil {
+			return fmt.Errorf("invalid value for --scheduling: %s. The choices include: throughput, fair", err.Error())
+		}
+
+		var logTarget common.LogTarget
+		if err := logTarget.Parse(logTargetRaw); err != nil {
+			return fmt.Errorf("invalid value for --log-target: %s. The choices include: file, syslog, eventlog", err.Error())
+		}
+		systemLogger, err := common.NewSystemLogger(logTarget, syslogFacility)
+		if err != nil {
+			return err
+		}
+
+		if err := setUpTLS(); err != nil {
+			return err
+		}
+
+		if err := setUpLocalAddr(); err != nil {
+			return err
+		}
+
+		if err := validateClientRequestIDPrefix(clientRequestIDPrefix); err != nil {
+			return err
+		}
+
+		// like the TLS options, the tuning of the connection pools must be set before the STE makes its clients
+		transportSettings, err := common.NewTransportSettings(glcm.GetEnvironmentVariable)
 		if err != nil {
 			return err
 		}
+		common.SetGlobalTransportSettings(transportSettings)
+
+		if err := common.LoadContentTypeMap(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ContentTypeMap())); err != nil {
+			return fmt.Errorf("invalid value for %s: %s", common.EEnvironmentVariable.ContentTypeMap().Name, err.Error())
+		}
+
+		// startup of the STE happens here, so that the startup can access the values of command line parameters that are defined for "root" command.
+		// When AzCopy runs inside another program, the settings of its first command hold for the later ones
+		if !steStarted {
+			concurrencySettings := ste.NewConcurrencySettings(azcopyMaxFileAndSocketHandles, preferToAutoTuneGRs)
+			err = ste.MainSTE(concurrencySettings, int64(cmdLineCapMegaBitsPerSecond), int64(cmdLineCapTransactionsPerSecond), int64(cmdLineCapMetadataTransactionsPerSecond), scheduling, azcopyJobPlanFolder, azcopyLogPathFolder,
+				common.NewLogRotationSettings(cmdLineLogFileMaxSizeMB, cmdLineLogFileMaxRotated), logFormat, systemLogger, providePerformanceAdvice)
+			if err != nil {
+				return err
+			}
+			steStarted = true
+		}
 
 		// spawn a routine to fetch and compare the local application's version against the latest version available
 		// if there's a newer version that can be used, then write the suggestion to stderr
@@ -102,11 +177,23 @@ var glcm = common.GetLifecycleMgr()
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(azsAppPathFolder, logPathFolder string, jobPlanFolder string, maxFileAndSocketHandles int) {
+	ExecuteArgs(azsAppPathFolder, logPathFolder, jobPlanFolder, maxFileAndSocketHandles, os.Args[1:])
+}
+
+// ExecuteArgs runs the command that args give, as they'd be on the command line without the name of the program.
+// Both the command line and the client package run their commands through it, so that a command does the same whichever runs it.
+// Like Execute, it ends with the lifecycle manager exiting, so a program that runs more than one command must set an output sink first.
+func ExecuteArgs(azsAppPathFolder, logPathFolder string, jobPlanFolder string, maxFileAndSocketHandles int, args []string) {
 	azcopyAppPathFolder = azsAppPathFolder
 	azcopyLogPathFolder = logPathFolder
 	azcopyJobPlanFolder = jobPlanFolder
 	azcopyMaxFileAndSocketHandles = maxFileAndSocketHandles
 
+	// the flags keep the values of the previous command of the process, if there was one
+	resetFlags(rootCmd)
+	commandArgs = args
+	rootCmd.SetArgs(args)
+
 	if err := rootCmd.Execute(); err != nil {
 		glcm.Error(err.Error())
 	} else {
@@ -127,6 +214,27 @@ func init() {
 	rootCmd.SetUsageTemplate(strings.Replace((&cobra.Command{}).UsageTemplate(), "Global Flags", "Flags Applying to All Commands", -1))
 
 	rootCmd.PersistentFlags().Uint32Var(&cmdLineCapMegaBitsPerSecond, "cap-mbps", 0, "Caps the transfer rate, in megabits per second. Moment-by-moment throughput might vary slightly from the cap. If this option is set to zero, or it is omitted, the throughput isn't capped.")
+	rootCmd.PersistentFlags().Uint32Var(&cmdLineCapTransactionsPerSecond, "cap-tps", 0, "Caps the number of requests to the storage service per second, across all transfers, e.g. to stay under the request rate limits of the account when there are many small files. "+
+		"Every request counts, including those that only create or get the properties of a file, and retries. When the service throttles the requests anyway, the cap is lowered for a while. "+
+		"It's independent of cap-mbps. If this option is set to zero, or it is omitted, the requests aren't capped.")
+	rootCmd.PersistentFlags().Uint32Var(&cmdLineCapMetadataTransactionsPerSecond, "cap-metadata-tps", 0, "Caps the number of requests per second of the transfers that move no data (deletes, and setting properties), on their own, "+
+		"so that a large job of them neither starves nor is starved by the transfers of data. When it's set, cap-tps no longer applies to those requests. "+
+		"If this option is set to zero, or it is omitted, they are capped by cap-tps, if it is set. Their number in flight is bounded by AZCOPY_CONCURRENT_METADATA_TRANSFERS.")
+	rootCmd.PersistentFlags().StringVar(&schedulingRaw, "scheduling", "throughput", "The order in which the chunks of the files in flight are transferred. The choices include: throughput (in the order they were scheduled, which keeps the most connections busy), "+
+		"fair (round-robin across the files, so that each gets some of its chunks transferred every round, e.g. so that small files land while one huge file is transferred). The default value is 'throughput'.")
+	rootCmd.PersistentFlags().Uint32Var(&cmdLineLogFileMaxSizeMB, "log-file-max-size-mb", 0, "Max size, in MB, of the job's log file. When it is reached, the log is renamed to <jobID>.1.log and a new one is started. If omitted, the value of AZCOPY_LOG_FILE_MAX_SIZE_MB is used, which defaults to 1024.")
+	rootCmd.PersistentFlags().Uint32Var(&cmdLineLogFileMaxRotated, "log-file-max-rotated", 0, "Max number of older log files to keep for a job, after which the oldest is deleted. If omitted, the value of AZCOPY_LOG_FILE_MAX_ROTATED is used, which defaults to 10.")
+	rootCmd.PersistentFlags().StringVar(&logTargetRaw, "log-target", "file", "Where, besides the job's log file, to send job start and completion events, and messages of warning level or above. The choices include: file (the job's log file only), syslog (Linux and macOS), eventlog (Windows). The detailed log of each transfer always goes to the log file only.")
+	rootCmd.PersistentFlags().StringVar(&syslogFacility, "syslog-facility", "user", "The syslog facility of the messages, when the log target is syslog. The choices include: user, daemon, local0 to local7.")
+	rootCmd.PersistentFlags().StringVar(&trustedCAFile, "trusted-ca-file", "", "Path of a PEM file of CA certificates to trust, in addition to those of the system, e.g. those of a TLS-intercepting proxy or of a private PKI. If omitted, the value of AZCOPY_CA_BUNDLE is used.")
+	rootCmd.PersistentFlags().StringVar(&tlsMinVersion, "tls-min-version", "", "The minimum TLS version of the connections. The choices include: 1.2, 1.3. If omitted, TLS 1.2 and later are accepted.")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "DANGEROUS: don't verify the certificates of the endpoints, which lets anyone on the network path read and change the data. Only for troubleshooting.")
+	rootCmd.PersistentFlags().StringVar(&localAddress, "local-address", "", "The IP address, of one of the network interfaces of this machine, to make the connections to the services from, e.g. that of an interface dedicated to storage traffic. "+
+		"It also decides whether IPv4 or IPv6 is used. If omitted, the value of AZCOPY_LOCAL_ADDRESS is used, and if that isn't set either, the routing picks the address.")
+	rootCmd.PersistentFlags().StringVar(&ipVersion, "ip-version", "auto", "The IP version of the connections to the services, when their names resolve to both. The choices include: 4, 6, auto (either).")
+	rootCmd.PersistentFlags().StringVar(&clientRequestIDPrefix, "client-request-id-prefix", "", "What the x-ms-client-request-id of each request of the job starts with, e.g. an ID of your own tracing. "+
+		"The rest of the header is unique to the request, and the failures of the transfers list it, so that support can find the failed request in the logs of the service. "+
+		"If omitted, it's the ID of the job. A resumed job keeps the prefix it was started with.")
 	rootCmd.PersistentFlags().StringVar(&outputFormatRaw, "output-type", "text", "Format of the command's output. The choices include: text, json. The default value is 'text'.")
 
 	// Note: this is due to Windows not supporting signals properly
@@ -136,6 +244,33 @@ func init() {
 	rootCmd.PersistentFlags().MarkHidden("cancel-from-stdin")
 }
 
+// flagResets clear what resetFlags can't, i.e. the variables of the flags whose values are lists
+var flagResets []func()
+
+// resetFlags puts the flags of the command, and of its subcommands, back to their defaults
+func resetFlags(c *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if !strings.HasSuffix(f.Value.Type(), "Array") && !strings.HasSuffix(f.Value.Type(), "Slice") {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	c.PersistentFlags().VisitAll(reset)
+	c.LocalFlags().VisitAll(reset)
+
+	for _, subcommand := range c.Commands() {
+		resetFlags(subcommand)
+	}
+	if c == rootCmd {
+		for _, f := range flagResets {
+			f()
+		}
+	}
+}
+
 // always spins up a new goroutine, because sometimes the aka.ms URL can't be reached (e.g. a constrained environment where
 // aka.ms is not resolvable to a reachable IP address). In such cases, this routine will run for ever, and the caller should
 // just give up on it.
@@ -207,3 +342,58 @@ func beginDetectNewVersion() chan struct{} {
 
 	return completionChannel
 }
+
+var clientRequestIDPrefixRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]*$`)
+
+// validateClientRequestIDPrefix makes sure that the prefix fits in the job plan, and can go in a header as it is
+func validateClientRequestIDPrefix(prefix string) error {
+	if len(prefix) > ste.ClientRequestIDPrefixMaxBytes {
+		return fmt.Errorf("client-request-id-prefix cannot be longer than %v characters", ste.ClientRequestIDPrefixMaxBytes)
+	}
+	if !clientRequestIDPrefixRegex.MatchString(prefix) {
+		return errors.New("client-request-id-prefix can only have letters, digits, and the characters . _ : -")
+	}
+	return nil
+}
+
+// withClientRequestIDPrefix returns a context whose requests have the x-ms-client-request-ids of the job, like those that the STE makes for it
+func withClientRequestIDPrefix(ctx context.Context, jobID common.JobID) context.Context {
+	return ste.WithClientRequestIDPrefix(ctx, ste.ClientRequestIDPrefix(jobID, clientRequestIDPrefix))
+}
+
+// setUpLocalAddr binds the connections of every HTTP client that AzCopy makes to the local address, and to the IP version.
+// Like setUpTLS, it must run before the STE starts
+func setUpLocalAddr() error {
+	address := localAddress
+	if address == "" {
+		address = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.LocalAddress())
+	}
+
+	addr, err := common.NewLocalAddr(address, ipVersion, net.InterfaceAddrs)
+	if err != nil {
+		return fmt.Errorf("invalid network options: %s", err.Error())
+	}
+	common.SetGlobalLocalAddr(addr)
+	return nil
+}
+
+// setUpTLS applies the TLS options to every HTTP client that AzCopy makes. It must run before the STE starts, since the STE makes its clients then.
+func setUpTLS() error {
+	caFile := trustedCAFile
+	if caFile == "" {
+		caFile = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CABundle())
+	}
+
+	// deliberately, there is no environment variable for this, so that it has to be given on each command line
+	if insecureSkipVerify {
+		glcm.Info("WARNING: --insecure-skip-verify is set, so the certificates of the endpoints are NOT verified. " +
+			"Anyone on the network path can read and change the data, and capture the credentials. Don't use it outside of troubleshooting.")
+	}
+
+	cfg, err := common.NewTLSConfig(caFile, tlsMinVersion, insecureSkipVerify)
+	if err != nil {
+		return fmt.Errorf("invalid TLS options: %s", err.Error())
+	}
+	common.SetGlobalTLSConfig(cfg)
+	return nil
+}
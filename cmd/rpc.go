@@ -53,7 +53,7 @@ func inprocSend(rpcCmd common.RpcCmd, requestData interface{}, responseData inte
 		*(responseData.(*common.ListJobTransfersResponse)) = ste.ListJobTransfers(requestData.(common.ListJobTransfersRequest))
 
 	case common.ERpcCmd.PauseJob():
-		responseData = ste.CancelPauseJobOrder(requestData.(common.JobID), common.EJobStatus.Paused())
+		*(responseData.(*common.CancelPauseResumeResponse)) = ste.CancelPauseJobOrder(requestData.(common.JobID), common.EJobStatus.Paused())
 
 	case common.ERpcCmd.CancelJob():
 		*(responseData.(*common.CancelPauseResumeResponse)) = ste.CancelPauseJobOrder(requestData.(common.JobID), common.EJobStatus.Cancelling())
@@ -64,6 +64,12 @@ func inprocSend(rpcCmd common.RpcCmd, requestData interface{}, responseData inte
 	case common.ERpcCmd.GetJobFromTo():
 		*(responseData.(*common.GetJobFromToResponse)) = ste.GetJobFromTo(*requestData.(*common.GetJobFromToRequest))
 
+	case common.ERpcCmd.SetJobLimits():
+		*(responseData.(*common.SetJobLimitsResponse)) = ste.SetJobLimits(*requestData.(*common.SetJobLimitsRequest))
+
+	case common.ERpcCmd.RenewCredential():
+		*(responseData.(*common.RenewCredentialResponse)) = ste.RenewCredential(*requestData.(*common.RenewCredentialRequest))
+
 	default:
 		panic(fmt.Errorf("Unrecognized RpcCmd: %q", rpcCmd.String()))
 	}
@@ -0,0 +1,103 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	raw := rawCopyCmdArgs{}
+	// the tiers are kept separately, because setMandatoryDefaults would otherwise overwrite them
+	blockBlobTier := ""
+	pageBlobTier := ""
+
+	// setPropertiesCmd represents the set-properties command
+	var setPropertiesCmd = &cobra.Command{
+		Use:     "set-properties [resourceURL]",
+		Aliases: []string{"set-props", "sp"},
+		Short:   setPropertiesCmdShortDescription,
+		Long:    setPropertiesCmdLongDescription,
+		Example: setPropertiesCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("set-properties command only takes 1 argument. Passed %d arguments", len(args))
+			}
+
+			// the resource whose properties are to be changed is set as the source
+			raw.src = args[0]
+
+			srcLocationType := inferArgumentLocation(raw.src)
+			if srcLocationType != common.ELocation.Blob() {
+				return fmt.Errorf("invalid source type %s to set properties on. azcopy supports setting the properties of blobs only", srcLocationType.String())
+			}
+			raw.fromTo = common.EFromTo.BlobNone().String()
+
+			raw.setMandatoryDefaults()
+			raw.blockBlobTier = blockBlobTier
+			raw.pageBlobTier = pageBlobTier
+
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			glcm.EnableInputWatcher()
+			if cancelFromStdin {
+				glcm.EnableCancelFromStdIn()
+			}
+
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+			}
+			cooked.commandString = copyHandlerUtil{}.ConstructCommandStringFromArgs()
+			err = cooked.process()
+			if err != nil {
+				glcm.Error("failed to perform set-properties command due to error: " + err.Error())
+			}
+
+			glcm.SurrenderControl()
+		},
+	}
+	rootCmd.AddCommand(setPropertiesCmd)
+
+	setPropertiesCmd.PersistentFlags().StringVar(&blockBlobTier, "block-blob-tier", "None", "Change the access tier of block blobs to this tier. Available tiers include: Hot, Cool and Archive.")
+	setPropertiesCmd.PersistentFlags().StringVar(&pageBlobTier, "page-blob-tier", "None", "Change the access tier of page blobs in premium accounts to this tier. Available tiers include: P4, P6, P10, P15, P20, P30, P40 and P50.")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.metadata, "metadata", "", "Replace the metadata of the blobs with these key-value pairs. For example: k1=v1;k2=v2")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.blobTags, "blob-tags", "", "Replace the index tags of the blobs with these key-value pairs. For example: k1=v1;k2=v2")
+	setPropertiesCmd.PersistentFlags().BoolVar(&raw.bestEffortFeatures, bestEffortFeaturesFlag, false, "Skip, with a warning, the requested properties that the account turns out not to support, "+
+		"e.g. blob-tags on an account with a hierarchical namespace, rather than fail before any blob is changed.")
+	setPropertiesCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", false, "Look into sub-directories recursively when setting properties of blobs in a virtual directory.")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.logVerbosity, "log-level", "INFO", "Define the log verbosity for the log file. Available levels include: INFO(all requests/responses), WARNING(slow responses), ERROR(only failed requests), and NONE(no output logs). (default 'INFO')")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.include, "include-pattern", "", "Include only blobs where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.includePath, "include-path", "", "Include only these paths when setting properties. "+
+		"This option does not support wildcard characters (*). Checks relative path prefix. For example: myFolder;myFolder/subDirName/file.pdf")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude blobs where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when setting properties. "+
+		"This option does not support wildcard characters (*). Checks relative path prefix. For example: myFolder;myFolder/subDirName/file.pdf")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.includePathPattern, "include-path-pattern", "", "Include only the paths that match these patterns when setting properties. "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. For example: logs/2023/*;**/*.csv")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.excludePathPattern, "exclude-path-pattern", "", "Exclude the paths that match these patterns when setting properties. "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Exclusions win over inclusions. For example: **/temp/**;*.tmp")
+	setPropertiesCmd.PersistentFlags().StringVar(&raw.listOfFilesToCopy, "list-of-files", "", "Defines the location of a file which contains the list of blobs and virtual directories whose properties are to be set. The relative paths should be delimited by line breaks, and the paths should NOT be URL-encoded.")
+}
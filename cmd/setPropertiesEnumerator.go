@@ -0,0 +1,82 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+var NothingToSetPropertiesError = errors.New("nothing found to set properties on")
+
+// provide an enumerator that lists the given blobs
+// and schedule set-properties transfers for them
+func newSetPropertiesEnumerator(cca *cookedCopyCmdArgs) (enumerator *copyEnumerator, err error) {
+	var sourceTraverser resourceTraverser
+
+	ctx := withClientRequestIDPrefix(context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion), cca.jobID)
+	rawURL, err := url.Parse(cca.source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cca.sourceSAS != "" {
+		copyHandlerUtil{}.appendQueryParamToUrl(rawURL, cca.sourceSAS)
+	}
+
+	// Include-path is handled by ListOfFilesChannel.
+	sourceTraverser, err = initResourceTraverser(rawURL.String(), cca.fromTo.From(), &ctx, &cca.credentialInfo, nil, cca.listOfFilesChannel, cca.recursive, false, func() {}, nil, nil)
+
+	// report failure to create traverser
+	if err != nil {
+		return nil, err
+	}
+
+	transferScheduler := newSetPropertiesTransferProcessor(cca, NumOfFilesPerDispatchJobPart)
+	includeFilters := buildIncludeFilters(cca.includePatterns)
+	excludeFilters := buildExcludeFilters(cca.excludePatterns, false)
+	excludePathFilters := buildExcludeFilters(cca.excludePathPatterns, true)
+
+	// set up the filters in the right order
+	filters := append(includeFilters, excludeFilters...)
+	filters = append(filters, excludePathFilters...)
+	filters = append(filters, buildPathPatternFilters(cca.includePathGlobs, cca.excludePathGlobs)...)
+
+	finalize := func() error {
+		_, err := transferScheduler.dispatchFinalPart()
+		if err != nil {
+			if err == NothingScheduledError {
+				// No log file needed. Logging begins as a part of awaiting job completion.
+				return NothingToSetPropertiesError
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	return newCopyEnumerator(sourceTraverser, filters, transferScheduler.scheduleCopyTransfer, finalize), nil
+}
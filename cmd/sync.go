@@ -43,11 +43,23 @@ type rawSyncCmdArgs struct {
 	// options from flags
 	blockSizeMB           float64
 	logVerbosity          string
+	metricsFile           string
+	metricsListen         string
+	failFastThreshold     uint32
+	failFastRate          string
+	onCompleteExec        string
+	notify                bool
 	include               string
 	exclude               string
 	excludePath           string
+	includePathPattern    string
+	excludePathPattern    string
+	prunePattern          string
+	maxDepth              int
 	includeFileAttributes string
 	excludeFileAttributes string
+	excludeDotfiles       bool
+	clearArchiveBit       bool
 	legacyInclude         string // for warning messages only
 	legacyExclude         string // for warning messages only
 
@@ -60,6 +72,45 @@ type rawSyncCmdArgs struct {
 	deleteDestination string
 
 	s2sPreserveAccessTier bool
+
+	// whether the files that have grown since they were last synced only have their new bytes appended
+	appendOnly bool
+
+	// whether the source overwrites the destination files that are newer than it
+	mirrorMode bool
+
+	// whether to skip the check that the permissions of the SASs allow what the sync will do
+	ignoreSASValidation bool
+
+	// whether the features that the destination turns out not to support are disabled with a warning, rather than fail the sync
+	bestEffortFeatures bool
+
+	// whether s2s-preserve-access-tier was given, rather than left to its default
+	s2sPreserveAccessTierRequested bool
+
+	// whether to skip the paths that can't be enumerated, rather than fail the sync
+	continueOnEnumerationErrors bool
+	// what to do with the objects whose names the destination doesn't allow
+	invalidNameHandling string
+	// what to do with the transfers whose source was deleted after it was enumerated
+	missingSourceHandling string
+	// what to do with the transfers whose destination blob is soft-deleted
+	softDeletedDestination string
+	// whether to keep the last modified time of each source in the metadata of its destination
+	preserveSourceLMTAsMetadata bool
+	// whether to compare the last modified times that the objects keep in their metadata, rather than their own
+	compareWithPreservedLMT bool
+
+	// whether to skip the local files that can't be read for lack of permission, or because another process holds them open
+	skipPermissionErrors bool
+	skipLockedFiles      bool
+
+	// whether to read the local source from a Volume Shadow Copy of its volume, on Windows
+	useVss bool
+
+	// whether to keep syncing the changes of the local source as they happen, and how long a changed file must be left alone before it's synced
+	watch              bool
+	watchSettleSeconds float64
 }
 
 func (raw *rawSyncCmdArgs) parsePatterns(pattern string) (cookedPatterns []string) {
@@ -92,7 +143,11 @@ func (raw *rawSyncCmdArgs) validateURLIsNotServiceLevel(url string, location com
 
 // validates and transform raw input into cooked input
 func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
-	cooked := cookedSyncCmdArgs{}
+	cooked := cookedSyncCmdArgs{perf: &jobPerformanceTracker{}}
+
+	// the SDKs only find the account name in the path of an emulator's URL if its host is an IP address
+	raw.src = common.ReplaceLocalhostWithLoopbackIP(raw.src)
+	raw.dst = common.ReplaceLocalhostWithLoopbackIP(raw.dst)
 
 	// this if statement ladder remains instead of being separated to help determine valid combinations for sync
 	// consider making a map of valid source/dest combos and consolidating this to generic source/dest setups, akin to the lower if statement
@@ -106,6 +161,10 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 	} else if cooked.fromTo == common.EFromTo.BlobLocal() {
 		cooked.source, cooked.sourceSAS, err = SplitAuthTokenFromResource(raw.src, cooked.fromTo.From())
 		common.PanicIfErr(err)
+	} else if cooked.fromTo == common.EFromTo.LocalBlobFS() && raw.appendOnly {
+		// ADLS Gen2 is only supported for append-only syncs, which append to its files rather than replacing them
+		cooked.destination, cooked.destinationSAS, err = SplitAuthTokenFromResource(raw.dst, cooked.fromTo.To())
+		common.PanicIfErr(err)
 	} else if cooked.fromTo == common.EFromTo.BlobBlob() || cooked.fromTo == common.EFromTo.FileFile() {
 		cooked.destination, cooked.destinationSAS, err = SplitAuthTokenFromResource(raw.dst, cooked.fromTo.To())
 		common.PanicIfErr(err)
@@ -166,16 +225,59 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 	cooked.includePatterns = raw.parsePatterns(raw.include)
 	cooked.excludePatterns = raw.parsePatterns(raw.exclude)
 	cooked.excludePaths = raw.parsePatterns(raw.excludePath)
+	if cooked.includePathGlobs, err = normalizePathPatterns("include-path-pattern", raw.parsePatterns(raw.includePathPattern)); err != nil {
+		return cooked, err
+	}
+	if cooked.excludePathGlobs, err = normalizePathPatterns("exclude-path-pattern", raw.parsePatterns(raw.excludePathPattern)); err != nil {
+		return cooked, err
+	}
+
+	if raw.maxDepth < 0 {
+		return cooked, fmt.Errorf("max-depth cannot be negative")
+	}
+	cooked.traversalLimits = newTraversalLimits(raw.maxDepth, cooked.excludePaths, raw.parsePatterns(raw.prunePattern), cooked.excludePathGlobs)
 
 	// parse the attribute filter patterns
 	cooked.includeFileAttributes = raw.parsePatterns(raw.includeFileAttributes)
 	cooked.excludeFileAttributes = raw.parsePatterns(raw.excludeFileAttributes)
+	if err = validateFileAttributes("include-attributes", cooked.includeFileAttributes); err != nil {
+		return cooked, err
+	}
+	if err = validateFileAttributes("exclude-attributes", cooked.excludeFileAttributes); err != nil {
+		return cooked, err
+	}
+	cooked.excludeDotfiles = raw.excludeDotfiles
+	if len(cooked.includeFileAttributes) > 0 || len(cooked.excludeFileAttributes) > 0 || cooked.excludeDotfiles {
+		cooked.excludedFiles = newExcludedFileCounter()
+	}
+
+	if raw.clearArchiveBit {
+		if err = validateClearArchiveBit(cooked.fromTo, raw.useVss); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.clearArchiveBit = raw.clearArchiveBit
 
 	err = cooked.logVerbosity.Parse(raw.logVerbosity)
 	if err != nil {
 		return cooked, err
 	}
 
+	cooked.metricsFile, err = cookMetricsFile(raw.metricsFile)
+	if err != nil {
+		return cooked, err
+	}
+	if err = validateMetricsListen(raw.metricsListen); err != nil {
+		return cooked, err
+	}
+	cooked.metricsListen = raw.metricsListen
+
+	cooked.failFast, err = newFailFastPolicy(raw.failFastThreshold, raw.failFastRate)
+	if err != nil {
+		return cooked, err
+	}
+	cooked.completionHook = newCompletionHook(raw.onCompleteExec, raw.notify)
+
 	cooked.putMd5 = raw.putMd5
 	if err = validatePutMd5(cooked.putMd5, cooked.fromTo); err != nil {
 		return cooked, err
@@ -191,11 +293,93 @@ func (raw *rawSyncCmdArgs) cook() (cookedSyncCmdArgs, error) {
 
 	if cooked.fromTo.IsS2S() {
 		cooked.preserveAccessTier = raw.s2sPreserveAccessTier
+		cooked.preserveAccessTierRequested = raw.s2sPreserveAccessTierRequested
+	}
+	cooked.bestEffortFeatures = raw.bestEffortFeatures
+
+	cooked.appendOnly = raw.appendOnly
+	if cooked.appendOnly {
+		if cooked.fromTo != common.EFromTo.LocalBlob() && cooked.fromTo != common.EFromTo.LocalBlobFS() {
+			return cooked, fmt.Errorf("append-only is only supported when syncing local files to append blobs in Azure Blob, or to ADLS Gen2")
+		}
+		if cooked.putMd5 {
+			return cooked, fmt.Errorf("put-md5 cannot be used with append-only, since only the new bytes of a file are read when it's appended to")
+		}
+	}
+
+	cooked.mirrorMode = raw.mirrorMode
+	if !cooked.mirrorMode {
+		cooked.destinationNewer = newDestinationNewerReporter()
+	}
+
+	if raw.continueOnEnumerationErrors {
+		cooked.sourceEnumerationFailures = newEnumerationFailureTracker()
+		cooked.destinationEnumerationFailures = newEnumerationFailureTracker()
+	}
+	if cooked.invalidNames, err = cookInvalidNameHandling(raw.invalidNameHandling, cooked.fromTo, cooked.jobID); err != nil {
+		return cooked, err
+	}
+	if cooked.missingSourceHandling, err = cookMissingSourceHandling(raw.missingSourceHandling, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	if cooked.softDeletedDestination, err = cookSoftDeletedDestination(raw.softDeletedDestination, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	if err = validatePreserveSourceLMTAsMetadata(raw.preserveSourceLMTAsMetadata, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	cooked.preserveSourceLMTAsMetadata = raw.preserveSourceLMTAsMetadata
+	cooked.compareWithPreservedLMT = raw.compareWithPreservedLMT
+
+	if err = validateSkipSourceErrors(raw.skipPermissionErrors, raw.skipLockedFiles, cooked.fromTo); err != nil {
+		return cooked, err
+	}
+	cooked.skipPermissionErrors = raw.skipPermissionErrors
+	cooked.skipLockedFiles = raw.skipLockedFiles
+
+	if raw.useVss {
+		if err = validateUseVss(cooked.fromTo); err != nil {
+			return cooked, err
+		}
+		cooked.shadowCopies = newShadowCopySet()
+	}
+
+	if raw.watch {
+		if err = validateWatch(raw, cooked); err != nil {
+			return cooked, err
+		}
+		cooked.watch = newSyncWatcher(time.Duration(raw.watchSettleSeconds * float64(time.Second)))
+	}
+
+	if !raw.ignoreSASValidation {
+		if err = validateSyncSASPermissions(cooked); err != nil {
+			return cooked, err
+		}
 	}
 
 	return cooked, nil
 }
 
+// validateWatch makes sure that the source can be watched, and that its changes can be synced without asking the user anything
+func validateWatch(raw *rawSyncCmdArgs, cooked cookedSyncCmdArgs) error {
+	if cooked.fromTo.From() != common.ELocation.Local() {
+		return fmt.Errorf("watch is only supported when syncing from a local directory")
+	}
+	if cooked.deleteDestination == common.EDeleteDestination.Prompt() {
+		return fmt.Errorf("watch cannot be used with delete-destination=prompt, since nobody may be there to answer")
+	}
+	if raw.useVss {
+		return fmt.Errorf("watch cannot be used with use-vss, since the shadow copy doesn't change")
+	}
+	if raw.watchSettleSeconds < 0 {
+		return fmt.Errorf("watch-settle-seconds cannot be negative")
+	}
+	if raw.maxDepth > 0 || raw.prunePattern != "" || raw.includePathPattern != "" || raw.excludePathPattern != "" {
+		return fmt.Errorf("watch cannot be used with max-depth, prune-pattern, include-path-pattern or exclude-path-pattern")
+	}
+	return nil
+}
+
 type cookedSyncCmdArgs struct {
 	// NOTE: for the 64 bit atomic functions to work on a 32 bit system, we have to guarantee the right 64-bit alignment
 	// so the 64 bit integers are placed first in the struct to avoid future breaks
@@ -227,14 +411,35 @@ type cookedSyncCmdArgs struct {
 	includePatterns       []string
 	excludePatterns       []string
 	excludePaths          []string
+	includePathGlobs      []string
+	excludePathGlobs      []string
 	includeFileAttributes []string
 	excludeFileAttributes []string
+	excludeDotfiles       bool
+	// how far the enumeration of both sides descends; nil if it goes all the way down
+	traversalLimits *traversalLimits
+	// the files at the source that the attribute and dotfile filters excluded; nil if there are no such filters
+	excludedFiles *excludedFileCounter
+
+	// whether the archive attribute of the local files that were uploaded is cleared, so that the next sync with include-attributes=A skips them
+	clearArchiveBit bool
 
 	// options
 	putMd5              bool
 	md5ValidationOption common.HashValidationOption
 	blockSize           uint32
 	logVerbosity        common.LogLevel
+	metricsFile         string
+	metricsListen       string
+	// nil unless the job is aborted once too many of its transfers failed
+	failFast *failFastPolicy
+
+	// nil unless the job uses an OAuth token, which may expire before the job is done
+	credentialExpiry *credentialExpiryCheck
+	// nil unless something is to happen once the job (or each round of a watched sync) reaches a terminal state
+	completionHook *completionHook
+	// tells the user about the destination shares that the job finds full
+	fullShares fullSharesReport
 
 	// commandString hold the user given command which is logged to the Job log file
 	commandString string
@@ -251,6 +456,7 @@ type cookedSyncCmdArgs struct {
 
 	// used to calculate job summary
 	jobStartTime time.Time
+	perf         *jobPerformanceTracker
 
 	// this flag is set by the enumerator
 	// it is useful to indicate whether we are simply waiting for the purpose of cancelling
@@ -263,6 +469,54 @@ type cookedSyncCmdArgs struct {
 	deleteDestination common.DeleteDestination
 
 	preserveAccessTier bool
+	// only when it's requested explicitly is the destination probed for access tiers
+	preserveAccessTierRequested bool
+
+	// whether the requested features that the destination doesn't support are disabled, rather than fail the sync
+	bestEffortFeatures bool
+
+	// whether the files that have grown, at the source, only have their new bytes appended to their destinations
+	appendOnly bool
+
+	// in mirror mode, the source wins, and overwrites the destination files that are newer than it.
+	// Otherwise those files are left as they are, and reported, which destinationNewer does. It's nil in mirror mode
+	mirrorMode       bool
+	destinationNewer *destinationNewerReporter
+
+	// where the paths that couldn't be enumerated on either side are recorded. Nil unless the sync should carry on past them.
+	// Nothing under a source path that couldn't be enumerated is ever deleted from the destination
+	sourceEnumerationFailures      *enumerationFailureTracker
+	destinationEnumerationFailures *enumerationFailureTracker
+
+	// skips or renames the source objects whose names the destination doesn't allow. Nil if the names are left as they are.
+	// A sanitized destination is compared with the source object that it was named for, so it's never deleted as extraneous
+	invalidNames *invalidNameHandler
+
+	// whether the transfers whose source was deleted after it was enumerated fail, or are skipped
+	missingSourceHandling common.MissingSourceHandling
+
+	// what is done with the transfers whose destination blob is soft-deleted
+	softDeletedDestination common.SoftDeletedDestinationHandling
+
+	// whether the last modified time of each source is kept in the metadata of its destination
+	preserveSourceLMTAsMetadata bool
+
+	// whether the objects are compared by the last modified times that they keep in their metadata, when they keep one
+	compareWithPreservedLMT bool
+
+	// how often the listing of either side was throttled
+	listing *enumerationListing
+
+	// whether the local files that can't be read for lack of permission, or because another process holds them open,
+	// are skipped rather than failed. They are then synced by the next sync that can read them
+	skipPermissionErrors bool
+	skipLockedFiles      bool
+
+	// the snapshots that the local source is read from. Nil unless it's read from shadow copies
+	shadowCopies *shadowCopySet
+
+	// what keeps the sync going once it's done, syncing the changes of the source as they happen. Nil unless the source is watched
+	watch *syncWatcher
 }
 
 func (cca *cookedSyncCmdArgs) incrementDeletionCount() {
@@ -298,7 +552,12 @@ func (cca *cookedSyncCmdArgs) scanningComplete() bool {
 // if blocking is specified to false, then another goroutine spawns and wait out the job
 func (cca *cookedSyncCmdArgs) waitUntilJobCompletion(blocking bool) {
 	// print initial message to indicate that the job is starting
-	glcm.Init(common.GetStandardInitOutputBuilder(cca.jobID.String(), fmt.Sprintf("%s%s%s.log", azcopyLogPathFolder, common.OS_PATH_SEPARATOR, cca.jobID), false, ""))
+	glcm.Init(common.GetStandardInitOutputBuilder(cca.jobID.String(), common.JobLogFilePath(azcopyLogPathFolder, cca.jobID), false, "",
+		ste.ClientRequestIDPrefix(cca.jobID, clientRequestIDPrefix)))
+	startJobControl(cca.jobID)
+	metricsEndpointOfProcess.jobStarted(cca.jobID, cca.firstPartOrdered, func() (source, destination uint64) {
+		return atomic.LoadUint64(&cca.atomicSourceFilesScanned), atomic.LoadUint64(&cca.atomicDestinationFilesScanned)
+	})
 
 	// initialize the times necessary to track progress
 	cca.jobStartTime = time.Now()
@@ -307,12 +566,32 @@ func (cca *cookedSyncCmdArgs) waitUntilJobCompletion(blocking bool) {
 
 	// hand over control to the lifecycle manager if blocking
 	if blocking {
-		glcm.InitiateProgressReporting(cca)
+		glcm.InitiateProgressReporting(cca.workController())
 		glcm.SurrenderControl()
 	} else {
 		// non-blocking, return after spawning a go routine to watch the job
-		glcm.InitiateProgressReporting(cca)
+		glcm.InitiateProgressReporting(cca.workController())
+	}
+}
+
+// workController is what reports the progress of the sync. When the source is watched, that's the watcher throughout,
+// since the progress reporting only starts once
+func (cca *cookedSyncCmdArgs) workController() common.WorkController {
+	if cca.watch != nil {
+		return cca.watch
+	}
+	return cca
+}
+
+// endRound ends the sync, which ends the process, unless the source is watched,
+// in which case the outcome is shown, and the watcher carries on with the changes that came in meanwhile
+func (cca *cookedSyncCmdArgs) endRound(lcm common.LifecycleMgr, builder common.OutputBuilder, exitCode common.ExitCode) {
+	if cca.watch == nil {
+		lcm.Exit(builder, exitCode)
+		return
 	}
+	lcm.Exit(builder, common.EExitCode.NoExit())
+	cca.watch.roundEnded(cca, exitCode)
 }
 
 func (cca *cookedSyncCmdArgs) Cancel(lcm common.LifecycleMgr) {
@@ -343,6 +622,8 @@ func (cca *cookedSyncCmdArgs) Cancel(lcm common.LifecycleMgr) {
 type scanningProgressJsonTemplate struct {
 	FilesScannedAtSource      uint64
 	FilesScannedAtDestination uint64
+	DirectoriesSkipped        uint64 `json:",omitempty"`
+	FilesExcludedByAttributes uint64 `json:",omitempty"`
 }
 
 func (cca *cookedSyncCmdArgs) reportScanningProgress(lcm common.LifecycleMgr, throughput float64) {
@@ -355,6 +636,8 @@ func (cca *cookedSyncCmdArgs) reportScanningProgress(lcm common.LifecycleMgr, th
 			jsonOutputTemplate := scanningProgressJsonTemplate{
 				FilesScannedAtSource:      srcScanned,
 				FilesScannedAtDestination: dstScanned,
+				DirectoriesSkipped:        cca.traversalLimits.directoriesSkipped(),
+				FilesExcludedByAttributes: cca.excludedFiles.count(),
 			}
 			outputString, err := json.Marshal(jsonOutputTemplate)
 			common.PanicIfErr(err)
@@ -366,8 +649,8 @@ func (cca *cookedSyncCmdArgs) reportScanningProgress(lcm common.LifecycleMgr, th
 		if cca.firstPartOrdered() {
 			throughputString = fmt.Sprintf(", 2-sec Throughput (Mb/s): %v", ste.ToFixed(throughput, 4))
 		}
-		return fmt.Sprintf("%v Files Scanned at Source, %v Files Scanned at Destination%s",
-			srcScanned, dstScanned, throughputString)
+		return fmt.Sprintf("%v Files Scanned at Source, %v Files Scanned at Destination%s%s%s",
+			srcScanned, dstScanned, formatDirectoriesSkipped(cca.traversalLimits), formatFilesExcludedByAttributes(cca.excludedFiles), throughputString)
 	})
 }
 
@@ -375,6 +658,7 @@ func (cca *cookedSyncCmdArgs) getJsonOfSyncJobSummary(summary common.ListJobSumm
 	wrapped := common.ListSyncJobSummaryResponse{ListJobSummaryResponse: summary}
 	wrapped.DeleteTotalTransfers = cca.getDeletionCount()
 	wrapped.DeleteTransfersCompleted = cca.getDeletionCount()
+	wrapped.FilesSkippedAsDestinationNewer = cca.destinationNewer.count()
 	jsonOutput, err := json.Marshal(wrapped)
 	common.PanicIfErr(err)
 	return string(jsonOutput)
@@ -389,7 +673,13 @@ func (cca *cookedSyncCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) {
 	// fetch a job status and compute throughput if the first part was dispatched
 	if cca.firstPartOrdered() {
 		Rpc(common.ERpcCmd.ListJobSummary(), &cca.jobID, &summary)
-		jobDone = summary.JobStatus.IsJobDone()
+		jobDone = summary.JobStatus.IsJobDone() || cca.credentialExpiry.pausedJobDone(summary)
+		cca.perf.sample(summary)
+		if !jobDone {
+			cca.credentialExpiry.check(cca.jobID, summary)
+			cca.failFast.check(cca.jobID, summary)
+			cca.fullShares.check(cca.jobID, summary, 0)
+		}
 
 		// compute the average throughput for the last time interval
 		bytesInMb := float64(float64(summary.BytesOverWire-cca.intervalBytesTransferred) * 8 / float64(base10Mega))
@@ -410,15 +700,46 @@ func (cca *cookedSyncCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) {
 
 	if jobDone {
 		exitCode := common.EExitCode.Success()
-		if summary.TransfersFailed > 0 {
+		cca.failFast.reportAbort(&summary)
+		cca.credentialExpiry.reportPause(&summary)
+		reportEnumerationFailures(&summary, cca.sourceEnumerationFailures, cca.destinationEnumerationFailures)
+		cca.invalidNames.summarize(&summary)
+		summary.EnumerationRetries = cca.listing.retries()
+		if summary.TransfersFailed > 0 || summary.PathsNotEnumerated > 0 || summary.JobStatus == common.EJobStatus.Paused() {
 			exitCode = common.EExitCode.Error()
 		}
+		summary.PerformanceReport = cca.perf.report(summary, duration)
+		recordJobHistory(summary, copyHandlerUtil{}.ConstructCommandStringFromArgs(), cca.source, cca.destination, cca.jobStartTime)
+		cca.completionHook.run(cca.jobID, summary.JobStatus)
 
-		lcm.Exit(func(format common.OutputFormat) string {
+		cca.endRound(lcm, func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
 				return cca.getJsonOfSyncJobSummary(summary)
 			}
-			screenStats, logStats := formatExtraStats(cca.fromTo, summary.AverageIOPS, summary.AverageE2EMilliseconds, summary.NetworkErrorPercentage, summary.ServerBusyPercentage)
+			screenStats, logStats := formatExtraStats(cca.fromTo.From() == common.ELocation.Benchmark(), summary.AverageIOPS, summary.AverageE2EMilliseconds, summary.NetworkErrorPercentage, summary.ServerBusyPercentage)
+			screenStats += formatTransferDurationPercentiles(summary)
+			screenStats += formatSourceReadRetries(summary)
+			screenStats += formatSharingViolationRetries(summary)
+			screenStats += formatFullShares(summary)
+			screenStats += formatMissingSourceSkips(summary)
+			screenStats += formatSoftDeletedDestinations(summary)
+			screenStats += formatCredentialExpiry(summary)
+			screenStats += formatPerformanceReport(summary)
+			if cca.appendOnly {
+				screenStats += formatAppendOnly(summary)
+			}
+			screenStats += formatTransactions(summary)
+			screenStats += formatTransactionsPerSecond(summary)
+			screenStats += formatTransferClasses(summary)
+			screenStats += formatPartitionThrottling(summary)
+			screenStats += formatBusyDirectories(summary)
+			screenStats += formatPathsNotEnumerated(summary)
+			screenStats += formatInvalidNames(summary)
+			screenStats += formatEnumerationRetries(summary)
+			screenStats += formatFailuresByErrorCode(summary)
+			screenStats += formatFailFastAbort(summary)
+			screenStats += formatExcludedFiles(cca.excludedFiles)
+			screenStats += formatDestinationNewer(cca.destinationNewer)
 
 			output := fmt.Sprintf(
 				`
@@ -455,6 +776,7 @@ Final Job Status: %v%s%s
 
 			return output
 		}, exitCode)
+		return
 	}
 
 	lcm.Progress(func(format common.OutputFormat) string {
@@ -465,17 +787,31 @@ Final Job Status: %v%s%s
 		// indicate whether constrained by disk or not
 		perfString, diskString := getPerfDisplayText(summary.PerfStrings, summary.PerfConstraint, duration, false)
 
-		return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Pending, %v Total%s, 2-sec Throughput (Mb/s): %v%s",
+		limitsString := formatJobLimits(summary)
+		if limitsString != "" {
+			limitsString = ", " + limitsString
+		}
+
+		return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Pending, %v Total%s, 2-sec Throughput (Mb/s): %v%s%s%s",
 			summary.PercentComplete,
 			summary.TransfersCompleted,
 			summary.TransfersFailed,
 			summary.TotalTransfers-summary.TransfersCompleted-summary.TransfersFailed,
-			summary.TotalTransfers, perfString, ste.ToFixed(throughput, 4), diskString)
+			summary.TotalTransfers, perfString, ste.ToFixed(throughput, 4), limitsString, diskString,
+			formatInFlightTransfers(summary.InFlightTransfers))
 	})
 }
 
+// formatAppendOnly compares what an append-only sync appended to what it had to upload in full
+func formatAppendOnly(summary common.ListJobSummaryResponse) string {
+	return fmt.Sprintf("\n\nBytes Appended: %v (%s)\nBytes Uploaded In Full: %v (%s)",
+		summary.BytesAppended, byteSizeToString(int64(summary.BytesAppended)),
+		summary.BytesUploadedInFull, byteSizeToString(int64(summary.BytesUploadedInFull)))
+}
+
 func (cca *cookedSyncCmdArgs) process() (err error) {
-	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	cca.listing = newEnumerationListing()
+	ctx := withClientRequestIDPrefix(withEnumerationListing(context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion), cca.listing), cca.jobID)
 
 	// verifies credential type and initializes credential info.
 	// For sync, only one side need credential.
@@ -504,7 +840,41 @@ func (cca *cookedSyncCmdArgs) process() (err error) {
 			return err
 		} else {
 			cca.credentialInfo.OAuthTokenInfo = *tokenInfo
+			warnIfOAuthTokenIsForOtherCloud(cca.credentialInfo.OAuthTokenInfo, cca.source, cca.destination)
 		}
+		cca.credentialExpiry = newCredentialExpiryCheck(cca.credentialInfo, azcopyOutputFormat == common.EOutputFormat.Text())
+	}
+
+	// Blob to blob syncs don't need a SAS on the source, since the destination can read the source with the user's OAuth token
+	if cca.fromTo == common.EFromTo.BlobBlob() && cca.sourceSAS == "" {
+		srcCredInfo, _, err := getCredentialInfoForLocation(ctx, cca.fromTo.From(), cca.source, cca.sourceSAS, true)
+		if err != nil {
+			return err
+		}
+		useOAuthForS2SSourceIfPossible(&cca.credentialInfo, cca.fromTo, srcCredInfo)
+	}
+
+	if err = cca.preflightDestination(ctx); err != nil {
+		return err
+	}
+
+	// watched from before it's enumerated, so that what changes meanwhile is synced next
+	if cca.watch != nil {
+		if err = cca.watch.start(cca); err != nil {
+			return err
+		}
+	}
+
+	// the source is compared to the destination as it is in the snapshot, since that's what is uploaded
+	if cca.shadowCopies != nil {
+		glcm.RegisterCloseFunc(cca.shadowCopies.deleteAll)
+		if cca.source, err = cca.shadowCopies.pathInSnapshot(cca.source); err != nil {
+			return err
+		}
+	}
+
+	if err = startMetricsEndpoint(cca.metricsListen); err != nil {
+		return err
 	}
 
 	enumerator, err := cca.initEnumerator(ctx)
@@ -516,6 +886,7 @@ func (cca *cookedSyncCmdArgs) process() (err error) {
 	cca.waitUntilJobCompletion(false)
 
 	// trigger the enumeration
+	cca.perf.enumerationStarted()
 	err = enumerator.enumerate()
 	if err != nil {
 		return err
@@ -546,6 +917,7 @@ func init() {
 				glcm.EnableCancelFromStdIn()
 			}
 
+			raw.s2sPreserveAccessTierRequested = cmd.Flags().Changed("s2s-preserve-access-tier")
 			cooked, err := raw.cook()
 			if err != nil {
 				glcm.Error("error parsing the input given by the user. Failed with error " + err.Error())
@@ -566,17 +938,97 @@ func init() {
 	syncCmd.PersistentFlags().StringVar(&raw.include, "include-pattern", "", "Include only files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
 	syncCmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
 	syncCmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when comparing the source against the destination. "+
-		"This option does not support wildcard characters (*). Checks relative path prefix(For example: myFolder;myFolder/subDirName/file.pdf).")
-	syncCmd.PersistentFlags().StringVar(&raw.includeFileAttributes, "include-attributes", "", "(Windows only) Include only files whose attributes match the attribute list. For example: A;S;R")
-	syncCmd.PersistentFlags().StringVar(&raw.excludeFileAttributes, "exclude-attributes", "", "(Windows only) Exclude files whose attributes match the attribute list. For example: A;S;R")
+		"This option does not support wildcard characters (*). Checks relative path prefix(For example: myFolder;myFolder/subDirName/file.pdf). "+
+		"The directories that are excluded are not enumerated at all, on either side.")
+	syncCmd.PersistentFlags().StringVar(&raw.includePathPattern, "include-path-pattern", "", "Include only the files whose relative path matches these patterns (For example: logs/2023/*;**/*.csv). "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Separate the patterns by using a ';'.")
+	syncCmd.PersistentFlags().StringVar(&raw.excludePathPattern, "exclude-path-pattern", "", "Exclude the files whose relative path matches these patterns, on either side (For example: **/temp/**;*.tmp). "+
+		"A '*' matches within one directory level, and a '**' matches any number of directories. Exclusions win over inclusions. "+
+		"The directories matched by a pattern ending in '/**' are not enumerated at all, on either side.")
+	syncCmd.PersistentFlags().StringVar(&raw.prunePattern, "prune-pattern", "", "Do not enumerate the directories whose names match these patterns, wherever they are, nor anything under them, on either side (For example: node_modules;.git). "+
+		"Nothing in them is copied or deleted. This option supports wildcard characters (*). Separate the patterns by using a ';'.")
+	syncCmd.PersistentFlags().IntVar(&raw.maxDepth, "max-depth", 0, "Only compare the files that are at most this many levels below the source and the destination, where the files directly in them are at level 1. "+
+		"The deeper directories are not enumerated on either side, so nothing in them is copied or deleted. (default 0, which has no limit)")
+	syncCmd.PersistentFlags().StringVar(&raw.includeFileAttributes, "include-attributes", "", "(Windows only) Include only files whose attributes match the attribute list. "+
+		"The attributes are given by their letters or their names. For example: A;S;R, or Archive;System;ReadOnly")
+	syncCmd.PersistentFlags().StringVar(&raw.excludeFileAttributes, "exclude-attributes", "", "(Windows only) Exclude files whose attributes match the attribute list. "+
+		"The attributes are given by their letters or their names. For example: A;S;R, or Hidden;System;Temporary")
+	syncCmd.PersistentFlags().BoolVar(&raw.excludeDotfiles, "exclude-dotfiles", false, "Exclude the files whose names start with a dot, and everything in the directories whose names do (For example: .DS_Store, .git). "+
+		"They are neither copied nor deleted. The summary shows how many files the attribute filters and this one excluded at the source.")
+	syncCmd.PersistentFlags().BoolVar(&raw.clearArchiveBit, "clear-archive-bit", false, "(Windows only) Clear the archive attribute of each local file once it is uploaded, "+
+		"so that a later sync with include-attributes=A only uploads the files that changed since. The attribute is kept on the files that changed while they were uploaded.")
 	syncCmd.PersistentFlags().StringVar(&raw.logVerbosity, "log-level", "INFO", "Define the log verbosity for the log file, available levels: INFO(all requests and responses), WARNING(slow responses), ERROR(only failed requests), and NONE(no output logs). (default INFO).")
+	syncCmd.PersistentFlags().StringVar(&raw.metricsFile, "metrics-file", "", "Write the timings of each transfer (time in queue, time to first byte, duration, bytes, retries and worker) to this file as the transfers complete, "+
+		"and show the 50th, 95th and 99th percentiles of the transfer durations at the end of the job. The file is CSV, unless its name ends in .json or .ndjson, in which case it has one JSON object per line.")
+	syncCmd.PersistentFlags().StringVar(&raw.metricsListen, "metrics-listen", "", "Serve the metrics of the job for Prometheus to scrape, at /metrics on this address and port, e.g. :9090 or 127.0.0.1:9090. "+
+		"They include the bytes transferred, the transfers by final status, the throughput, the active chunk workers, the retries by HTTP status, the bandwidth cap and the progress of the enumeration. "+
+		"The metric names start with azcopy_, and no paths or URLs are ever in them. Off by default.")
+	syncCmd.PersistentFlags().Uint32Var(&raw.failFastThreshold, "fail-fast-threshold", 0, "Abort the job once more than this many transfers failed, e.g. because the SAS is wrong or the container doesn't exist. "+
+		"The transfers that were not done yet are not attempted, the job completes with errors, and the error that most transfers failed with is shown. Off by default.")
+	syncCmd.PersistentFlags().StringVar(&raw.failFastRate, "fail-fast-rate", "", "Abort the job once transfers fail faster than this, over the last minute: either a number of failures per minute, e.g. 100/min, "+
+		"or a percentage of the transfers that finished, e.g. 90% (only once at least 50 finished). Off by default.")
+	syncCmd.PersistentFlags().StringVar(&raw.onCompleteExec, "on-complete-exec", "", "Run this command once the job reaches a terminal state (completed, completed with errors, failed or cancelled), e.g. \"/path/script {jobid} {status}\". "+
+		"{jobid} and {status} are replaced with the ID of the job and its final status. With --watch, it runs after each round. The command runs in the shell of the OS, is killed after 5 minutes, "+
+		"and what it writes is put in the job log. A follow-up job doesn't run it, and neither does a later resume of the job, unless the resume is given it too.")
+	syncCmd.PersistentFlags().BoolVar(&raw.notify, "notify", false, "Raise a desktop notification once the job reaches a terminal state. On Linux it needs notify-send. Off by default.")
 	syncCmd.PersistentFlags().StringVar(&raw.deleteDestination, "delete-destination", "false", "Defines whether to delete extra files from the destination that are not present at the source. Could be set to true, false, or prompt. "+
 		"If set to prompt, the user will be asked a question before scheduling files and blobs for deletion. (default 'false').")
+	syncCmd.PersistentFlags().BoolVar(&raw.ignoreSASValidation, ignoreSASValidationFlag, false, "Don't check, before the enumeration, that the permissions of the SASs allow what the sync will do, "+
+		"i.e. Read and List on the source, and Write and List on the destination, plus Delete with delete-destination. "+
+		"Use it for SASs whose permissions azcopy can't tell from the token. By default, a sync whose SAS lacks a permission fails before it starts.")
+	syncCmd.PersistentFlags().BoolVar(&raw.bestEffortFeatures, bestEffortFeaturesFlag, false, "Disable, with a warning, the requested features that the destination turns out not to support, rather than fail the sync before it starts. "+
+		"Only s2s-preserve-access-tier, when it's given explicitly, is probed for.")
 	syncCmd.PersistentFlags().BoolVar(&raw.putMd5, "put-md5", false, "Create an MD5 hash of each file, and save the hash as the Content-MD5 property of the destination blob or file. (By default the hash is NOT created.) Only available when uploading.")
 	syncCmd.PersistentFlags().StringVar(&raw.md5ValidationOption, "check-md5", common.DefaultHashValidationOption.String(), "Specifies how strictly MD5 hashes should be validated when downloading. This option is only available when downloading. Available values include: NoCheck, LogOnly, FailIfDifferent, FailIfDifferentOrMissing. (default 'FailIfDifferent').")
 	syncCmd.PersistentFlags().BoolVar(&raw.s2sPreserveAccessTier, "s2s-preserve-access-tier", true, "Preserve access tier during service to service copy. "+
 		"Please refer to [Azure Blob storage: hot, cool, and archive access tiers](https://docs.microsoft.com/azure/storage/blobs/storage-blob-storage-tiers) to ensure destination storage account supports setting access tier. "+
-		"In the cases that setting access tier is not supported, please use s2sPreserveAccessTier=false to bypass copying access tier. (default true). ")
+		"In the cases that setting access tier is not supported, please use s2sPreserveAccessTier=false to bypass copying access tier. "+
+		"Blobs in the Archive tier fail, since they must be rehydrated before they can be read. "+
+		"Premium page blob tiers are not preserved when the destination is not a premium account. (default true). ")
+	syncCmd.PersistentFlags().BoolVar(&raw.appendOnly, "append-only", false, "Upload local files as append blobs, or to ADLS Gen2, and only append the new bytes of a file that has grown since it was last synced, "+
+		"such as a log file, starting at the length of its destination. The end of the destination is first compared to the source, "+
+		"and files that have shrunk, or whose destination doesn't match the start of the source, are uploaded in full. "+
+		"The summary shows the bytes that were appended, and those that were uploaded in full. Cannot be used with put-md5.")
+	syncCmd.PersistentFlags().BoolVar(&raw.mirrorMode, "mirror-mode", false, "Make the destination a mirror of the source: overwrite the destination files whose last modified time differs from the source, "+
+		"including those that are newer at the destination. By default, those are not overwritten, but counted in the summary as skipped because they are newer at the destination, "+
+		"and each is reported, as a DestinationNewer message when the output type is json, since it usually means that the file was edited at the destination. "+
+		"Note that the last modified time of a blob is when it was uploaded, so the blobs that a sync uploaded are always newer than their local files.")
+	syncCmd.PersistentFlags().StringVar(&raw.invalidNameHandling, "invalid-name-handling", "fail", "What to do with the files whose names the destination does not allow, e.g. blobs with a backslash, a control character or a trailing dot, "+
+		"copied to Azure Files or to Windows. Available options: fail (the default), which leaves the names as they are, so that their transfers fail; "+
+		"skip, which leaves the files out when they are enumerated; and sanitize, which replaces each character that is not allowed, and each '%', "+
+		"of the parts of the path that have such characters, with '%' and its two hexadecimal digits (e.g. 'a:b.' becomes 'a%3Ab%2E'). "+
+		"The original names are mapped to the sanitized ones in a file next to the job's log, which the summary points to. A sanitized destination is synced with the source file of its original name, so it is not deleted as extraneous.")
+	syncCmd.PersistentFlags().StringVar(&raw.missingSourceHandling, "missing-source-handling", "fail", "What to do with the files whose source is deleted between when it's enumerated and when it's transferred, "+
+		"as happens when the source container is in use while the job runs. Available options: fail (the default), which fails their transfers; "+
+		"and skip, which skips them, with the status SkippedSourceDeleted, so that they don't make the job fail. They're counted separately in the summary. "+
+		"Only a 'not found' error with one of the codes for a deleted blob, file, container or share counts as a deleted source, so that an expired or "+
+		"insufficient SAS still fails. Only supported when the source is Azure Blob, Azure Files or ADLS Gen2.")
+	syncCmd.PersistentFlags().StringVar(&raw.softDeletedDestination, "soft-deleted-destination", "", "What to do with the destination blobs that don't exist, but are soft-deleted: "+
+		"overwrite, undelete-and-overwrite (which restores their soft-deleted snapshots too) or skip. What's done is logged for each file, and counted in the summary. "+
+		"By default, destinations aren't checked for being soft-deleted. Only supported when the destination is Azure Blob.")
+	syncCmd.PersistentFlags().BoolVar(&raw.preserveSourceLMTAsMetadata, "preserve-source-lmt-as-metadata", false, "Keep the last modified time of each source "+
+		"in the metadata of its destination, under the key "+common.PreservedLastModifiedTimeMetadataKey+" (in RFC3339, UTC), since the last modified time of "+
+		"a blob can't be set. Only supported when the destination is Azure Blob or Azure Files.")
+	syncCmd.PersistentFlags().BoolVar(&raw.compareWithPreservedLMT, "compare-with-preserved-lmt", false, "Compare the blobs and files that keep a last modified time "+
+		"in their metadata (see --preserve-source-lmt-as-metadata) by that time, rather than by their own, which is when they were written. "+
+		"It's what keeps the files that were uploaded with the time preserved from being copied again. The others are compared as usual.")
+	syncCmd.PersistentFlags().BoolVar(&raw.continueOnEnumerationErrors, "continue-on-enumeration-errors", false, "Skip the directories and files that cannot be enumerated, "+
+		"at the source or the destination, e.g. because access to them is denied, and carry on with the rest, rather than fail the sync. "+
+		"Nothing under a source directory that could not be enumerated is deleted from the destination. Each of them is logged with its error, "+
+		"and the job then completes with errors. The summary shows how many there were, and the file that lists them.")
+	syncCmd.PersistentFlags().BoolVar(&raw.skipPermissionErrors, "skip-permission-errors", false, "Skip the local files that cannot be opened or read because permission is denied, rather than fail them. "+
+		"Each of them is logged, counted as skipped rather than failed, and listed with the other skipped files.")
+	syncCmd.PersistentFlags().BoolVar(&raw.skipLockedFiles, "skip-locked-files", false, "Skip the local files that cannot be opened or read because another process has them open without sharing them, or has locked them, "+
+		"rather than fail them. That only happens on Windows. Each of them is logged, counted as skipped rather than failed, and listed with the other skipped files.")
+	syncCmd.PersistentFlags().BoolVar(&raw.useVss, "use-vss", false, "Read the local source from a Volume Shadow Copy of its volume, taken as the sync starts, "+
+		"so that the files that other processes hold open or have locked are synced as they were then. Only supported on Windows, and AzCopy must run as an administrator. "+
+		"The shadow copy is deleted when AzCopy exits.")
+	syncCmd.PersistentFlags().BoolVar(&raw.watch, "watch", false, "Keep running once the sync is done, and sync the files of the local source that are created or modified, "+
+		"and with delete-destination=true those that are deleted, as the OS notifies about them (with inotify on Linux, and ReadDirectoryChangesW on Windows). "+
+		"Each batch of changes is synced as a job of its own. The directories that are created or removed, and those whose notifications the OS dropped, are compared with the destination again. "+
+		"SIGTERM stops the watch once the transfers in progress are done, while Ctrl-C cancels them. In JSON output, a status line is written every 30 seconds while waiting for changes.")
+	syncCmd.PersistentFlags().Float64Var(&raw.watchSettleSeconds, "watch-settle-seconds", 2, "With watch, how long a changed file must be left alone before it's synced, "+
+		"so that a file that is being written is synced once, when it's done.")
 
 	// temp, to assist users with change in param names, by providing a clearer message when these obsolete ones are accidentally used
 	syncCmd.PersistentFlags().StringVar(&raw.legacyInclude, "include", "", "Legacy include param. DO NOT USE")
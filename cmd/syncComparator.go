@@ -32,10 +32,17 @@ type syncDestinationComparator struct {
 
 	// storing the source objects
 	sourceIndex *objectIndexer
+
+	// in mirror mode, the source wins: the destination objects that are newer than the source are overwritten too
+	mirrorMode bool
+
+	// told about the destination objects that are newer than the source, which are not overwritten unless in mirror mode
+	destinationNewer *destinationNewerReporter
 }
 
-func newSyncDestinationComparator(i *objectIndexer, copyScheduler, cleaner objectProcessor) *syncDestinationComparator {
-	return &syncDestinationComparator{sourceIndex: i, copyTransferScheduler: copyScheduler, destinationCleaner: cleaner}
+func newSyncDestinationComparator(i *objectIndexer, copyScheduler, cleaner objectProcessor, mirrorMode bool, destinationNewer *destinationNewerReporter) *syncDestinationComparator {
+	return &syncDestinationComparator{sourceIndex: i, copyTransferScheduler: copyScheduler, destinationCleaner: cleaner,
+		mirrorMode: mirrorMode, destinationNewer: destinationNewer}
 }
 
 // it will only schedule transfers for destination objects that are present in the indexer but stale compared to the entry in the map
@@ -50,7 +57,7 @@ func (f *syncDestinationComparator) processIfNecessary(destinationObject storedO
 	if present {
 		defer delete(f.sourceIndex.indexMap, destinationObject.relativePath)
 
-		if sourceObjectInMap.isMoreRecentThan(destinationObject) {
+		if needsSyncTransfer(sourceObjectInMap, destinationObject, f.mirrorMode, f.destinationNewer) {
 			err := f.copyTransferScheduler(sourceObjectInMap)
 			if err != nil {
 				return err
@@ -74,29 +81,35 @@ type syncSourceComparator struct {
 
 	// storing the destination objects
 	destinationIndex *objectIndexer
+
+	// in mirror mode, the source wins: the destination objects that are newer than the source are overwritten too
+	mirrorMode bool
+
+	// told about the destination objects that are newer than the source, which are not overwritten unless in mirror mode
+	destinationNewer *destinationNewerReporter
 }
 
-func newSyncSourceComparator(i *objectIndexer, copyScheduler objectProcessor) *syncSourceComparator {
-	return &syncSourceComparator{destinationIndex: i, copyTransferScheduler: copyScheduler}
+func newSyncSourceComparator(i *objectIndexer, copyScheduler objectProcessor, mirrorMode bool, destinationNewer *destinationNewerReporter) *syncSourceComparator {
+	return &syncSourceComparator{destinationIndex: i, copyTransferScheduler: copyScheduler, mirrorMode: mirrorMode, destinationNewer: destinationNewer}
 }
 
 // it will only transfer source items that are:
 //	1. not present in the map
-//  2. present but is more recent than the entry in the map
+//  2. present but is more recent than the entry in the map, or less recent in mirror mode
 // note: we remove the storedObject if it is present so that when we have finished
 // the index will contain all objects which exist at the destination but were NOT seen at the source
 func (f *syncSourceComparator) processIfNecessary(sourceObject storedObject) error {
-	destinationObjectInMap, present := f.destinationIndex.indexMap[sourceObject.relativePath]
+	destinationObjectInMap, present := f.destinationIndex.indexMap[sourceObject.destinationRelativePath()]
 
 	if present {
-		defer delete(f.destinationIndex.indexMap, sourceObject.relativePath)
+		defer delete(f.destinationIndex.indexMap, sourceObject.destinationRelativePath())
 
 		// if destination is stale, schedule source for transfer
-		if sourceObject.isMoreRecentThan(destinationObjectInMap) {
+		if needsSyncTransfer(sourceObject, destinationObjectInMap, f.mirrorMode, f.destinationNewer) {
 			return f.copyTransferScheduler(sourceObject)
 
 		} else {
-			// skip if source is more recent
+			// skip if destination is as recent, or more recent
 			return nil
 		}
 	}
@@ -104,3 +117,38 @@ func (f *syncSourceComparator) processIfNecessary(sourceObject storedObject) err
 	// if source does not exist at the destination, then schedule it for transfer
 	return f.copyTransferScheduler(sourceObject)
 }
+
+// needsSyncTransfer tells whether the source object should overwrite its destination, which it does when it's more recent.
+// A destination that's more recent than the source is only overwritten in mirror mode. Otherwise it's reported, since it's
+// how the destination diverges from the source.
+func needsSyncTransfer(source, destination storedObject, mirrorMode bool, destinationNewer *destinationNewerReporter) bool {
+	switch compareLastModifiedTimes(source.comparedLastModifiedTime(), destination.comparedLastModifiedTime()) {
+	case 1:
+		return true
+	case -1:
+		if mirrorMode {
+			return true
+		}
+		if destinationNewer != nil {
+			destinationNewer.report(source, destination)
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// preservedLMTTraverser has the objects that its traverser finds compared by the last modified times that they keep in their metadata,
+// when they keep one (--compare-with-preserved-lmt). Their own last modified times are left as they are, for the transfers to check the sources against.
+type preservedLMTTraverser struct {
+	resourceTraverser
+}
+
+func (t *preservedLMTTraverser) traverse(preprocessor objectMorpher, processor objectProcessor, filters []objectFilter) error {
+	return t.resourceTraverser.traverse(preprocessor, func(object storedObject) error {
+		if preservedTime, ok := object.Metadata.PreservedLastModifiedTime(); ok {
+			object.preservedLastModifiedTime = preservedTime
+		}
+		return processor(object)
+	}, filters)
+}
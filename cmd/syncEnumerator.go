@@ -42,17 +42,27 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		return nil, err
 	}
 
+	// a source without a SAS is listed with the same OAuth token that the destination is given to read it
+	srcCredInfo := cca.credentialInfo
+	if srcCredInfo.S2SSourceCredentialType == common.ECredentialType.OAuthToken() {
+		srcCredInfo.CredentialType = common.ECredentialType.OAuthToken()
+	}
+
 	// TODO: enable symlink support in a future release after evaluating the implications
 	// GetProperties is enabled by default as sync supports both upload and download.
 	// This property only supports Files and S3 at the moment, but provided that Files sync is coming soon, enable to avoid stepping on Files sync work
-	sourceTraverser, err := initResourceTraverser(src, cca.fromTo.From(), &ctx, &cca.credentialInfo,
+	sourceTraverser, err := initResourceTraverser(src, cca.fromTo.From(), &ctx, &srcCredInfo,
 		nil, nil, cca.recursive, true, func() {
 			atomic.AddUint64(&cca.atomicSourceFilesScanned, 1)
-		})
+		}, cca.sourceEnumerationFailures, cca.traversalLimits)
 
 	if err != nil {
 		return nil, err
 	}
+	if cca.excludedFiles != nil {
+		// the filters apply to both sides, but it's the source files that they exclude which are counted
+		sourceTraverser = &exclusionCountingTraverser{resourceTraverser: sourceTraverser, counter: cca.excludedFiles}
+	}
 
 	// TODO: enable symlink support in a future release after evaluating the implications
 	// GetProperties is enabled by default as sync supports both upload and download.
@@ -60,11 +70,16 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 	destinationTraverser, err := initResourceTraverser(dst, cca.fromTo.To(), &ctx, &cca.credentialInfo,
 		nil, nil, cca.recursive, true, func() {
 			atomic.AddUint64(&cca.atomicDestinationFilesScanned, 1)
-		})
+		}, cca.destinationEnumerationFailures, cca.traversalLimits)
 	if err != nil {
 		return nil, err
 	}
 
+	if cca.compareWithPreservedLMT {
+		sourceTraverser = &preservedLMTTraverser{resourceTraverser: sourceTraverser}
+		destinationTraverser = &preservedLMTTraverser{resourceTraverser: destinationTraverser}
+	}
+
 	// verify that the traversers are targeting the same type of resources
 	if sourceTraverser.isDirectory(true) != destinationTraverser.isDirectory(true) {
 		return nil, errors.New("sync must happen between source and destination of the same type, e.g. either file <-> file, or directory/container <-> directory/container")
@@ -72,22 +87,7 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 
 	transferScheduler := newSyncTransferProcessor(cca, NumOfFilesPerDispatchJobPart)
 
-	// set up the filters in the right order
-	// Note: includeFilters and includeAttrFilters are ANDed
-	// They must both pass to get the file included
-	// Same rule applies to excludeFilters and excludeAttrFilters
-	filters := buildIncludeFilters(cca.includePatterns)
-	if cca.fromTo.From() == common.ELocation.Local() {
-		includeAttrFilters := buildAttrFilters(cca.includeFileAttributes, src, true)
-		filters = append(filters, includeAttrFilters...)
-	}
-
-	filters = append(filters, buildExcludeFilters(cca.excludePatterns, false)...)
-	filters = append(filters, buildExcludeFilters(cca.excludePaths, true)...)
-	if cca.fromTo.From() == common.ELocation.Local() {
-		excludeAttrFilters := buildAttrFilters(cca.excludeFileAttributes, src, false)
-		filters = append(filters, excludeAttrFilters...)
-	}
+	filters := cca.buildFilters(src)
 
 	// set up the comparator so that the source/destination can be compared
 	indexer := newObjectIndexer()
@@ -95,7 +95,7 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 	var finalize func() error
 
 	switch cca.fromTo {
-	case common.EFromTo.LocalBlob():
+	case common.EFromTo.LocalBlob(), common.EFromTo.LocalBlobFS():
 		// upload implies transferring from a local disk to a remote resource
 		// in this scenario, the local disk (source) is scanned/indexed first
 		// then the destination is scanned and filtered based on what the destination contains
@@ -108,7 +108,9 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		// when uploading, we can delete remote objects immediately, because as we traverse the remote location
 		// we ALREADY have available a complete map of everything that exists locally
 		// so as soon as we see a remote destination object we can know whether it exists in the local source
-		comparator = newSyncDestinationComparator(indexer, transferScheduler.scheduleCopyTransfer, destinationCleaner.removeImmediately).processIfNecessary
+		// the source was fully traversed by the time the destination is, so it's known what part of it couldn't be enumerated
+		comparator = newSyncDestinationComparator(indexer, transferScheduler.scheduleCopyTransfer,
+			cca.sourceEnumerationFailures.skipNotEnumerated(destinationCleaner.removeImmediately), cca.mirrorMode, cca.destinationNewer).processIfNecessary
 		finalize = func() error {
 			// schedule every local file that doesn't exist at the destination
 			err = indexer.traverse(transferScheduler.scheduleCopyTransfer, filters)
@@ -131,7 +133,8 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 	default:
 		// in all other cases (download and S2S), the destination is scanned/indexed first
 		// then the source is scanned and filtered based on what the destination contains
-		comparator = newSyncSourceComparator(indexer, transferScheduler.scheduleCopyTransfer).processIfNecessary
+		// the source objects are compared with the destination objects of their sanitized names, if they have any
+		comparator = cca.invalidNames.forDestination(newSyncSourceComparator(indexer, transferScheduler.scheduleCopyTransfer, cca.mirrorMode, cca.destinationNewer).processIfNecessary)
 
 		finalize = func() error {
 			// remove the extra files at the destination that were not present at the source
@@ -149,7 +152,7 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 				deleteScheduler = newSyncLocalDeleteProcessor(cca).removeImmediately
 			}
 
-			err = indexer.traverse(deleteScheduler, nil)
+			err = indexer.traverse(cca.sourceEnumerationFailures.skipNotEnumerated(deleteScheduler), nil)
 			if err != nil {
 				return err
 			}
@@ -171,16 +174,53 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 	}
 }
 
+// buildFilters sets up the filters in the right order
+func (cca *cookedSyncCmdArgs) buildFilters(src string) []objectFilter {
+	// Note: includeFilters and includeAttrFilters are ANDed
+	// They must both pass to get the file included
+	// Same rule applies to excludeFilters and excludeAttrFilters
+	filters := buildIncludeFilters(cca.includePatterns)
+	if cca.fromTo.From() == common.ELocation.Local() {
+		includeAttrFilters := buildAttrFilters(cca.includeFileAttributes, src, true)
+		filters = append(filters, includeAttrFilters...)
+	}
+
+	filters = append(filters, buildExcludeFilters(cca.excludePatterns, false)...)
+	filters = append(filters, buildExcludeFilters(cca.excludePaths, true)...)
+	filters = append(filters, buildPathPatternFilters(cca.includePathGlobs, cca.excludePathGlobs)...)
+	if cca.fromTo.From() == common.ELocation.Local() {
+		excludeAttrFilters := buildAttrFilters(cca.excludeFileAttributes, src, false)
+		filters = append(filters, excludeAttrFilters...)
+	}
+	if cca.excludeDotfiles {
+		filters = append(filters, &dotfileFilter{})
+	}
+	return filters
+}
+
 func quitIfInSync(transferJobInitiated, anyDestinationFileDeleted bool, cca *cookedSyncCmdArgs) {
-	if !transferJobInitiated && !anyDestinationFileDeleted {
+	notEnumerated := cca.sourceEnumerationFailures.count() + cca.destinationEnumerationFailures.count()
+	if !transferJobInitiated && notEnumerated > 0 {
+		// what couldn't be enumerated may well be out of sync, so this is not a success
+		cca.reportScanningProgress(glcm, 0)
+		cca.endRound(glcm, func(format common.OutputFormat) string {
+			return fmt.Sprintf("Everything that could be enumerated is in sync, but %v paths could not be enumerated. They are listed in the log.", notEnumerated)
+		}, common.EExitCode.Error())
+	} else if !transferJobInitiated && cca.destinationNewer.count() > 0 {
+		// the files that are newer at the destination are not in sync, though it's not for sync to change them
+		cca.reportScanningProgress(glcm, 0)
+		cca.endRound(glcm, func(format common.OutputFormat) string {
+			return fmt.Sprintf("Nothing needed to be transferred, but %v files are newer at the destination than at the source, so they were not overwritten.", cca.destinationNewer.count())
+		}, common.EExitCode.Success())
+	} else if !transferJobInitiated && !anyDestinationFileDeleted {
 		cca.reportScanningProgress(glcm, 0)
-		glcm.Exit(func(format common.OutputFormat) string {
+		cca.endRound(glcm, func(format common.OutputFormat) string {
 			return "The source and destination are already in sync."
 		}, common.EExitCode.Success())
 	} else if !transferJobInitiated && anyDestinationFileDeleted {
 		// some files were deleted but no transfer scheduled
 		cca.reportScanningProgress(glcm, 0)
-		glcm.Exit(func(format common.OutputFormat) string {
+		cca.endRound(glcm, func(format common.OutputFormat) string {
 			return "The source and destination are now in sync."
 		}, common.EExitCode.Success())
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/Azure/azure-storage-file-go/azfile"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/azbfs"
 	"github.com/Azure/azure-storage-azcopy/common"
 	"github.com/Azure/azure-storage-azcopy/ste"
 	"github.com/Azure/azure-storage-blob-go/azblob"
@@ -38,11 +39,12 @@ import (
 // extract the right info from cooked arguments and instantiate a generic copy transfer processor from it
 func newSyncTransferProcessor(cca *cookedSyncCmdArgs, numOfTransfersPerPart int) *copyTransferProcessor {
 	copyJobTemplate := &common.CopyJobPartOrderRequest{
-		JobID:           cca.jobID,
-		CommandString:   cca.commandString,
-		FromTo:          cca.fromTo,
-		SourceRoot:      consolidatePathSeparators(cca.source),
-		DestinationRoot: consolidatePathSeparators(cca.destination),
+		JobID:                 cca.jobID,
+		CommandString:         cca.commandString,
+		ClientRequestIDPrefix: clientRequestIDPrefix,
+		FromTo:                cca.fromTo,
+		SourceRoot:            consolidatePathSeparators(cca.source),
+		DestinationRoot:       consolidatePathSeparators(cca.destination),
 
 		// authentication related
 		CredentialInfo: cca.credentialInfo,
@@ -57,14 +59,30 @@ func newSyncTransferProcessor(cca *cookedSyncCmdArgs, numOfTransfersPerPart int)
 			BlockSizeInBytes:         cca.blockSize},
 		ForceWrite:                     common.EOverwriteOption.True(), // once we decide to transfer for a sync operation, we overwrite the destination regardless
 		LogLevel:                       cca.logVerbosity,
+		MetricsFile:                    cca.metricsFile,
+		AppendOnly:                     cca.appendOnly,
+		SkipPermissionErrors:           cca.skipPermissionErrors,
+		SkipLockedFiles:                cca.skipLockedFiles,
+		MissingSourceHandling:          cca.missingSourceHandling,
+		SoftDeletedDestinationHandling: cca.softDeletedDestination,
+		PreserveSourceLMTAsMetadata:    cca.preserveSourceLMTAsMetadata,
+		ClearArchiveBit:                cca.clearArchiveBit,
 		S2SSourceChangeValidation:      true,
 		DestLengthValidation:           true,
 		S2SGetPropertiesInBackend:      true,
 		S2SInvalidMetadataHandleOption: common.EInvalidMetadataHandleOption.RenameIfInvalid(),
 	}
 
+	if cca.appendOnly && cca.fromTo.To() == common.ELocation.Blob() {
+		// append-only syncs keep appending to the same blobs, so they must be append blobs from the start
+		copyJobTemplate.BlobAttributes.BlobType = common.EBlobType.AppendBlob()
+	}
+
 	reportFirstPart := func(jobStarted bool) { cca.setFirstPartOrdered() } // for compatibility with the way sync has always worked, we don't check jobStarted here
-	reportFinalPart := func() { cca.isEnumerationComplete = true }
+	reportFinalPart := func() {
+		cca.isEnumerationComplete = true
+		cca.perf.enumerationDone()
+	}
 
 	shouldEncodeSource := cca.fromTo.From().IsRemote()
 	shouldEncodeDestination := cca.fromTo.To().IsRemote()
@@ -229,6 +247,12 @@ func (b *remoteResourceDeleter) delete(object storedObject) error {
 		fileURL := azfile.NewFileURL(fileURLParts.URL(), b.p)
 		_, err := fileURL.Delete(b.ctx)
 		return err
+	case common.ELocation.BlobFS():
+		bfsURLParts := azbfs.NewBfsURLParts(*b.rootURL)
+		bfsURLParts.DirectoryOrFilePath = path.Join(bfsURLParts.DirectoryOrFilePath, object.relativePath)
+		fileURL := azbfs.NewFileURL(bfsURLParts.URL(), b.p)
+		_, err := fileURL.Delete(b.ctx)
+		return err
 	default:
 		panic("not implemented, check your code")
 	}
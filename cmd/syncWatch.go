@@ -0,0 +1,552 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// how often the watcher writes its status while it waits for changes, in JSON output
+const watchHeartbeatInterval = 30 * time.Second
+
+// sourceWatcher reports the changes under the local source of a sync, through the OS notifications
+type sourceWatcher interface {
+	// addDirectory watches a directory of the source, which does nothing when the OS watches the whole tree
+	addDirectory(relativePath string) error
+	close()
+}
+
+// watchEvent tells that something changed at a path, relative to the source and using the azcopy path separator.
+// What changed is found by looking at the path, once it has settled
+type watchEvent struct {
+	relativePath string
+	// the OS dropped notifications, so everything under relativePath has to be compared with the destination again
+	overflow bool
+}
+
+// watchQueue holds the changes that haven't been synced yet, until they settle,
+// i.e. until their path has been left alone for the settle delay, so that a file that is being written is synced once
+type watchQueue struct {
+	settleDelay time.Duration
+	changes     map[string]time.Time // the time of the latest event of each changed path
+	rescans     map[string]time.Time // the subtrees that have to be compared with the destination again
+}
+
+func newWatchQueue(settleDelay time.Duration) *watchQueue {
+	return &watchQueue{settleDelay: settleDelay, changes: make(map[string]time.Time), rescans: make(map[string]time.Time)}
+}
+
+func (q *watchQueue) add(event watchEvent, now time.Time) {
+	if event.overflow {
+		q.rescans[event.relativePath] = now
+	} else {
+		q.changes[event.relativePath] = now
+	}
+}
+
+func (q *watchQueue) addRescan(relativePath string, now time.Time) {
+	q.rescans[relativePath] = now
+}
+
+func (q *watchQueue) pending() int {
+	return len(q.changes) + len(q.rescans)
+}
+
+// next takes the work that has settled: a subtree to rescan if there is one, which covers the changes under it, or else the changed paths
+func (q *watchQueue) next(now time.Time) (rescan string, isRescan bool, changes []string) {
+	settled := func(last time.Time) bool { return now.Sub(last) >= q.settleDelay }
+
+	for relativePath, last := range q.rescans {
+		// the ancestors go first, so that nothing is rescanned twice
+		if settled(last) && (!isRescan || len(relativePath) < len(rescan)) {
+			rescan, isRescan = relativePath, true
+		}
+	}
+	if isRescan {
+		for relativePath := range q.rescans {
+			if isUnderPath(relativePath, rescan) {
+				delete(q.rescans, relativePath)
+			}
+		}
+		for relativePath := range q.changes {
+			if isUnderPath(relativePath, rescan) {
+				delete(q.changes, relativePath)
+			}
+		}
+		return rescan, true, nil
+	}
+
+	for relativePath, last := range q.changes {
+		if settled(last) {
+			changes = append(changes, relativePath)
+			delete(q.changes, relativePath)
+		}
+	}
+	sort.Strings(changes)
+	return "", false, changes
+}
+
+// isUnderPath tells whether relativePath is root, or lies under it. Everything lies under the root of the source, ""
+func isUnderPath(relativePath, root string) bool {
+	return root == "" || relativePath == root || strings.HasPrefix(relativePath, root+common.AZCOPY_PATH_SEPARATOR_STRING)
+}
+
+// scopeExcludePaths makes the excluded paths of a sync relative to the subtree that is rescanned.
+// It tells whether the subtree is excluded as a whole
+func scopeExcludePaths(excludePaths []string, subtree string) (scoped []string, excluded bool) {
+	if subtree == "" {
+		return excludePaths, false
+	}
+
+	scoped = make([]string, 0)
+	for _, excludePath := range excludePaths {
+		excludePath = strings.Trim(excludePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+		if isUnderPath(subtree, excludePath) {
+			return nil, true
+		} else if isUnderPath(excludePath, subtree) {
+			scoped = append(scoped, strings.TrimPrefix(excludePath, subtree+common.AZCOPY_PATH_SEPARATOR_STRING))
+		}
+	}
+	return scoped, false
+}
+
+// syncWatcher keeps a sync going (--watch): once the first sync is done, it syncs the changes that the OS notifies about,
+// each batch of them in a sync of its own, called a round here. It's the work controller of the process throughout,
+// reporting the progress of the round that runs, and its own status in between
+type syncWatcher struct {
+	base          *cookedSyncCmdArgs // the first sync, which the rounds are made from
+	settleDelay   time.Duration
+	sourceWatcher sourceWatcher
+	events        chan watchEvent
+	wake          chan struct{} // tells the loop that a round ended
+	terminate     chan os.Signal
+
+	lock            sync.Mutex
+	queue           *watchQueue
+	dirs            map[string]bool    // the directories of the source, so that it's known that a path that went away was one
+	round           *cookedSyncCmdArgs // the sync that is running, nil while waiting for changes
+	stopping        bool
+	roundsCompleted uint32
+	exitCode        common.ExitCode // an error once any round had one
+	lastHeartbeat   time.Time
+}
+
+func newSyncWatcher(settleDelay time.Duration) *syncWatcher {
+	return &syncWatcher{
+		settleDelay: settleDelay,
+		events:      make(chan watchEvent, 1000),
+		wake:        make(chan struct{}, 1),
+		terminate:   make(chan os.Signal, 1),
+		queue:       newWatchQueue(settleDelay),
+		dirs:        make(map[string]bool),
+		exitCode:    common.EExitCode.Success(),
+	}
+}
+
+// start watches the source of the first sync, before it is enumerated, so that no change made during it is missed
+func (w *syncWatcher) start(first *cookedSyncCmdArgs) error {
+	info, err := os.Stat(first.source)
+	if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("watch requires the source to be a directory")
+	}
+
+	w.base = first
+	w.round = first
+	w.sourceWatcher, err = newSourceWatcher(first.source, first.recursive, w.events)
+	if err != nil {
+		return fmt.Errorf("cannot watch %s: %w", first.source, err)
+	}
+	if err = w.addDirectories(""); err != nil {
+		w.sourceWatcher.close()
+		return fmt.Errorf("cannot watch %s: %w", first.source, err)
+	}
+
+	signal.Notify(w.terminate, syscall.SIGTERM)
+	go w.loop()
+	return nil
+}
+
+// addDirectories watches the directories under relativePath (which is itself already watched), if the sync is recursive
+func (w *syncWatcher) addDirectories(relativePath string) error {
+	if !w.base.recursive {
+		return nil
+	}
+
+	root := common.GenerateFullPath(w.base.source, relativePath)
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // it's synced, or reported, by the round that compares it with the destination
+		}
+		if !info.IsDir() || filePath == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.base.source, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		w.dirs[rel] = true
+		return w.sourceWatcher.addDirectory(rel)
+	})
+}
+
+func (w *syncWatcher) loop() {
+	ticker := time.NewTicker(w.tickInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-w.events:
+			w.lock.Lock()
+			w.queue.add(event, time.Now())
+			w.lock.Unlock()
+		case <-w.terminate:
+			glcm.Info("Terminating: the watch stops once the transfers in progress are done.")
+			if w.stop() == nil {
+				w.exit(glcm)
+			}
+		case <-w.wake:
+			w.startNextRound()
+		case <-ticker.C:
+			w.startNextRound()
+		}
+	}
+}
+
+func (w *syncWatcher) tickInterval() time.Duration {
+	if interval := w.settleDelay / 4; interval > 100*time.Millisecond {
+		return interval
+	}
+	return 100 * time.Millisecond
+}
+
+// startNextRound syncs the work that has settled, unless a round is running
+func (w *syncWatcher) startNextRound() {
+	w.lock.Lock()
+	if w.round != nil || w.stopping {
+		w.lock.Unlock()
+		return
+	}
+	rescan, isRescan, changes := w.queue.next(time.Now())
+	w.lock.Unlock()
+
+	var round *cookedSyncCmdArgs
+	var run func() error
+	if isRescan {
+		round = w.base.newWatchRound(rescan)
+		if round == nil {
+			return // excluded
+		}
+		run = round.rescan
+	} else {
+		uploads, removals := w.classifyChanges(changes)
+		if len(uploads) == 0 && len(removals) == 0 {
+			return
+		}
+		round = w.base.newWatchRound("")
+		run = func() error { return round.syncChanges(uploads, removals) }
+	}
+
+	w.lock.Lock()
+	w.round = round
+	w.lock.Unlock()
+
+	go func() {
+		if err := run(); err != nil {
+			glcm.Info(fmt.Sprintf("Failed to sync the changes of %s: %s", round.source, err))
+			w.roundEnded(round, common.EExitCode.Error())
+		}
+	}()
+}
+
+// classifyChanges looks at what the changed paths are now. The files are uploaded, and the removed ones deleted from the destination
+// (if the sync deletes).
+// The directories that came or went are queued to be compared with the destination, since the notifications don't cover what's under them
+func (w *syncWatcher) classifyChanges(changes []string) (uploads []storedObject, removals []string) {
+	filters := w.base.buildFilters(w.base.source)
+	deletes := w.base.deleteDestination == common.EDeleteDestination.True()
+
+	for _, relativePath := range changes {
+		if !w.base.recursive && strings.Contains(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING) {
+			continue
+		}
+
+		info, err := os.Lstat(common.GenerateFullPath(w.base.source, relativePath))
+		switch {
+		case err == nil && info.IsDir():
+			if w.base.recursive && !w.dirs[relativePath] {
+				w.dirs[relativePath] = true
+				if err = w.sourceWatcher.addDirectory(relativePath); err == nil {
+					err = w.addDirectories(relativePath)
+				}
+				if err != nil {
+					glcm.Info(fmt.Sprintf("Cannot watch %s: %s", relativePath, err))
+				}
+				w.queueRescan(relativePath)
+			}
+		case err == nil && info.Mode().IsRegular():
+			object := newStoredObject(noPreProccessor, info.Name(), relativePath, info.ModTime(), info.Size(), nil, blobTypeNA, "")
+			if passedFilters(filters, object) {
+				uploads = append(uploads, object)
+			}
+		case err == nil:
+			// neither a file nor a directory, e.g. a symlink, which sync doesn't follow
+		case os.IsNotExist(err) && w.dirs[relativePath]:
+			for dir := range w.dirs {
+				if isUnderPath(dir, relativePath) {
+					delete(w.dirs, dir)
+				}
+			}
+			if deletes {
+				w.queueRescan(path.Dir(relativePath))
+			}
+		case os.IsNotExist(err):
+			object := storedObject{name: path.Base(relativePath), relativePath: relativePath}
+			if deletes && passedFilters(filters, object) {
+				removals = append(removals, relativePath)
+			}
+		default:
+			glcm.Info(fmt.Sprintf("Cannot sync the change of %s: %s", relativePath, err))
+		}
+	}
+	return uploads, removals
+}
+
+func (w *syncWatcher) queueRescan(relativePath string) {
+	if relativePath == "." {
+		relativePath = ""
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.queue.addRescan(relativePath, time.Time{}) // settled already
+}
+
+// roundEnded lets the next round start, or ends the process if the watch is stopping
+func (w *syncWatcher) roundEnded(round *cookedSyncCmdArgs, exitCode common.ExitCode) {
+	w.lock.Lock()
+	if w.round == round {
+		w.round = nil
+	}
+	w.roundsCompleted++
+	if exitCode == common.EExitCode.Error() {
+		w.exitCode = exitCode
+	}
+	w.lastHeartbeat = time.Time{} // reported straight away
+	stopping := w.stopping
+	w.lock.Unlock()
+
+	if stopping {
+		w.exit(glcm)
+		return
+	}
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop makes the watch end with the round that is running, which it returns, or straight away if there is none
+func (w *syncWatcher) stop() *cookedSyncCmdArgs {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.stopping = true
+	return w.round
+}
+
+func (w *syncWatcher) exit(lcm common.LifecycleMgr) {
+	w.sourceWatcher.close()
+
+	w.lock.Lock()
+	roundsCompleted, exitCode := w.roundsCompleted, w.exitCode
+	w.lock.Unlock()
+
+	lcm.Exit(func(format common.OutputFormat) string {
+		return fmt.Sprintf("Stopped watching %s, after %v syncs.", w.base.source, roundsCompleted)
+	}, exitCode)
+}
+
+func (w *syncWatcher) currentRound() *cookedSyncCmdArgs {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.round
+}
+
+// ReportProgressOrExit reports the progress of the round that runs, or else the status of the watch
+func (w *syncWatcher) ReportProgressOrExit(lcm common.LifecycleMgr) {
+	if round := w.currentRound(); round != nil {
+		round.ReportProgressOrExit(lcm)
+		return
+	}
+	w.reportHeartbeat(lcm)
+}
+
+// Cancel cancels the round that runs, and ends the watch with it
+func (w *syncWatcher) Cancel(lcm common.LifecycleMgr) {
+	if round := w.stop(); round != nil {
+		round.Cancel(lcm)
+	} else {
+		w.exit(lcm)
+	}
+}
+
+type syncWatchHeartbeat struct {
+	Watching        string
+	SyncsCompleted  uint32
+	PendingChanges  int
+	FailedSyncsSeen bool
+	Time            time.Time
+}
+
+func (w *syncWatcher) reportHeartbeat(lcm common.LifecycleMgr) {
+	w.lock.Lock()
+	heartbeat := syncWatchHeartbeat{
+		Watching:        w.base.source,
+		SyncsCompleted:  w.roundsCompleted,
+		PendingChanges:  w.queue.pending(),
+		FailedSyncsSeen: w.exitCode == common.EExitCode.Error(),
+		Time:            time.Now(),
+	}
+	// the text is a line that is rewritten in place, while each JSON message is a line of its own
+	due := azcopyOutputFormat != common.EOutputFormat.Json() || time.Since(w.lastHeartbeat) >= watchHeartbeatInterval
+	if due {
+		w.lastHeartbeat = heartbeat.Time
+	}
+	w.lock.Unlock()
+
+	if !due {
+		return
+	}
+	lcm.Progress(func(format common.OutputFormat) string {
+		if format == common.EOutputFormat.Json() {
+			jsonOutput, err := json.Marshal(heartbeat)
+			common.PanicIfErr(err)
+			return string(jsonOutput)
+		}
+		return fmt.Sprintf("Watching %s for changes: %v syncs done, %v changes pending", heartbeat.Watching, heartbeat.SyncsCompleted, heartbeat.PendingChanges)
+	})
+}
+
+// newWatchRound makes a sync of the changes under relativePath ("" for the whole source) from the first sync.
+// It's nil if the path is excluded
+func (cca *cookedSyncCmdArgs) newWatchRound(relativePath string) *cookedSyncCmdArgs {
+	round := *cca
+	round.atomicSourceFilesScanned = 0
+	round.atomicDestinationFilesScanned = 0
+	round.atomicScanningStatus = 0
+	round.atomicFirstPartOrdered = 0
+	round.atomicDeletionCount = 0
+	round.jobID = common.NewJobID()
+	round.perf = &jobPerformanceTracker{}
+	round.isEnumerationComplete = false
+	round.failFast = cca.failFast.newRound()
+	if cca.excludedFiles != nil {
+		round.excludedFiles = newExcludedFileCounter()
+	}
+	if cca.destinationNewer != nil {
+		round.destinationNewer = newDestinationNewerReporter()
+	}
+	if cca.sourceEnumerationFailures != nil {
+		round.sourceEnumerationFailures = newEnumerationFailureTracker()
+		round.destinationEnumerationFailures = newEnumerationFailureTracker()
+	}
+
+	if relativePath != "" {
+		var excluded bool
+		if round.excludePaths, excluded = scopeExcludePaths(cca.excludePaths, relativePath); excluded {
+			return nil
+		}
+		round.source = common.GenerateFullPath(cca.source, relativePath)
+
+		destinationURL, err := url.Parse(cca.destination)
+		common.PanicIfErr(err) // it was parsed by the first sync
+		destinationURL.Path = path.Join(destinationURL.Path, relativePath)
+		destinationURL.RawPath = ""
+		round.destination = destinationURL.String()
+	}
+	round.traversalLimits = newTraversalLimits(0, round.excludePaths, nil, nil) // relative to the root of the round
+	return &round
+}
+
+// rescan compares the source of the round with its destination, like the first sync
+func (cca *cookedSyncCmdArgs) rescan() error {
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	enumerator, err := cca.initEnumerator(ctx)
+	if err != nil {
+		return err
+	}
+
+	cca.waitUntilJobCompletion(false)
+	cca.perf.enumerationStarted()
+	return enumerator.enumerate()
+}
+
+// syncChanges uploads the files that changed, and deletes those that went away, without comparing them with the destination
+func (cca *cookedSyncCmdArgs) syncChanges(uploads []storedObject, removals []string) error {
+	cca.waitUntilJobCompletion(false)
+	cca.perf.enumerationStarted()
+
+	if len(removals) > 0 {
+		deleter, err := newSyncDeleteProcessor(cca)
+		if err != nil {
+			return err
+		}
+		for _, relativePath := range removals {
+			_ = deleter.removeImmediately(storedObject{name: path.Base(relativePath), relativePath: relativePath}) // the failures are logged
+		}
+	}
+
+	transferScheduler := newSyncTransferProcessor(cca, NumOfFilesPerDispatchJobPart)
+	for _, object := range uploads {
+		atomic.AddUint64(&cca.atomicSourceFilesScanned, 1)
+		if err := transferScheduler.scheduleCopyTransfer(object); err != nil {
+			return err
+		}
+	}
+
+	jobInitiated, err := transferScheduler.dispatchFinalPart()
+	if err != nil && err != NothingScheduledError {
+		return err
+	}
+
+	quitIfInSync(jobInitiated, cca.getDeletionCount() > 0, cca)
+	cca.setScanningComplete()
+	return nil
+}
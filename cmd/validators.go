@@ -46,7 +46,8 @@ func validateFromTo(src, dst string, userSpecifiedFromTo string) (common.FromTo,
 		return common.EFromTo.Unknown(), fmt.Errorf("invalid --from-to value specified: %q", userSpecifiedFromTo)
 	}
 	if inferredFromTo == common.EFromTo.Unknown() || inferredFromTo == userFromTo ||
-		userFromTo == common.EFromTo.BlobTrash() || userFromTo == common.EFromTo.FileTrash() || userFromTo == common.EFromTo.BlobFSTrash() {
+		userFromTo == common.EFromTo.BlobTrash() || userFromTo == common.EFromTo.FileTrash() || userFromTo == common.EFromTo.BlobFSTrash() ||
+		userFromTo == common.EFromTo.BlobNone() {
 		// We couldn't infer the FromTo or what we inferred matches what the user specified
 		// We'll accept what the user specified
 		return userFromTo, nil
@@ -119,6 +120,11 @@ func inferArgumentLocation(arg string) common.Location {
 		u, err := url.Parse(arg)
 		// NOTE: sometimes, a local path can also be parsed as a url. To avoid thinking it's a URL, check Scheme, Host, and Path
 		if err == nil && u.Scheme != "" && u.Host != "" {
+			// the endpoints of the known clouds, and of the one in AZCOPY_STORAGE_ENDPOINT_SUFFIX, say exactly what service they are
+			if endpoint, ok := common.ParseStorageEndpoint(*u); ok {
+				return endpoint.Service
+			}
+
 			// Is the argument a URL to blob storage?
 			switch host := strings.ToLower(u.Host); true {
 			// Azure Stack does not have the core.windows.net
@@ -135,6 +141,15 @@ func inferArgumentLocation(arg string) common.Location {
 			if common.IsS3URL(*u) {
 				return common.ELocation.S3()
 			}
+
+			// the host of an emulator doesn't say what service it is, but the port that Azurite serves blobs on does.
+			// For other ports, the user has to say with --from-to
+			if common.IsIPEndpointStyle(*u) {
+				if u.Port() == common.EmulatorBlobPort {
+					return common.ELocation.Blob()
+				}
+				return common.ELocation.Unknown()
+			}
 		}
 	}
 
@@ -0,0 +1,141 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// containerNameFilter picks the containers (or shares, or filesystems) that an account traversal looks into
+// (--include-container and --exclude-container). A nil filter picks them all.
+type containerNameFilter struct {
+	include []string
+	exclude []string
+}
+
+// newContainerNameFilter returns nil if there are no patterns, so that every container is picked
+func newContainerNameFilter(include, exclude []string) (*containerNameFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid container name pattern '%s': %s", pattern, err)
+		}
+	}
+	return &containerNameFilter{include: include, exclude: exclude}, nil
+}
+
+// picks tells whether the container is looked into. Exclusions win over inclusions
+func (f *containerNameFilter) picks(containerName string) bool {
+	if f == nil {
+		return true
+	}
+	for _, pattern := range f.exclude {
+		if ok, _ := containerNameMatchesPattern(containerName, pattern); ok {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if ok, _ := containerNameMatchesPattern(containerName, pattern); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// containerFilterable is an account traverser that can leave out whole containers, without listing what's in them
+type containerFilterable interface {
+	setContainerFilter(f *containerNameFilter)
+}
+
+// containerTally counts what an account-level source schedules from each of its containers, so that the summary of the job
+// can be grouped by container. A nil tally (for a source that isn't a whole account) counts nothing.
+type containerTally struct {
+	lock       sync.Mutex
+	containers map[string]*common.ContainerSummary
+}
+
+func newContainerTally() *containerTally {
+	return &containerTally{containers: make(map[string]*common.ContainerSummary)}
+}
+
+func (t *containerTally) add(containerName string, size int64) {
+	if t == nil || containerName == "" {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	c, ok := t.containers[containerName]
+	if !ok {
+		c = &common.ContainerSummary{Name: containerName}
+		t.containers[containerName] = c
+	}
+	c.Transfers++
+	c.Bytes += uint64(size)
+}
+
+// summarize groups what was scheduled by container, with the transfers of the finished job that failed, ordered by container name
+func (t *containerTally) summarize(summary common.ListJobSummaryResponse, source common.Location) []common.ContainerSummary {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	failed := make(map[string]uint32)
+	for _, f := range summary.FailedTransfers {
+		if name, err := GetContainerName(f.Src, source); err == nil {
+			failed[name]++
+		}
+	}
+
+	result := make([]common.ContainerSummary, 0, len(t.containers))
+	for name, c := range t.containers {
+		s := *c
+		s.TransfersFailed = failed[name]
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func formatContainerSummaries(summary common.ListJobSummaryResponse) string {
+	if len(summary.Containers) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(summary.Containers))
+	for _, c := range summary.Containers {
+		lines = append(lines, fmt.Sprintf("%s: %v transfers (%v failed), %s",
+			c.Name, c.Transfers, c.TransfersFailed, byteSizeToString(int64(c.Bytes))))
+	}
+	return "\n\nTransfers by Container:\n" + strings.Join(lines, "\n")
+}
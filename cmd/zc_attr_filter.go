@@ -0,0 +1,168 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// fileAttributeLetters maps the names of the Windows file attributes that include-attributes and exclude-attributes take
+// to the letters that they are otherwise given by, so that "Hidden;System" works as well as "H;S"
+var fileAttributeLetters = map[string]string{
+	"READONLY":          "R",
+	"ARCHIVE":           "A",
+	"SYSTEM":            "S",
+	"HIDDEN":            "H",
+	"COMPRESSED":        "C",
+	"NORMAL":            "N",
+	"ENCRYPTED":         "E",
+	"TEMPORARY":         "T",
+	"OFFLINE":           "O",
+	"NOTCONTENTINDEXED": "I",
+}
+
+// fileAttributeLetter returns the letter of the given file attribute, which may be given by its letter or by its name,
+// regardless of case. It returns "" for an attribute that doesn't exist.
+func fileAttributeLetter(attribute string) string {
+	attribute = strings.ToUpper(strings.TrimSpace(attribute))
+	if letter, ok := fileAttributeLetters[attribute]; ok {
+		return letter
+	}
+	for _, letter := range fileAttributeLetters {
+		if attribute == letter {
+			return letter
+		}
+	}
+	return ""
+}
+
+// validateFileAttributes makes sure that every attribute of an attribute filter exists,
+// since an attribute that is misspelt would otherwise match nothing, and silently let everything through
+func validateFileAttributes(flagName string, attributes []string) error {
+	for _, attribute := range attributes {
+		if fileAttributeLetter(attribute) == "" {
+			return fmt.Errorf("%s: '%s' is not a file attribute. The attributes are ReadOnly (R), Archive (A), System (S), Hidden (H), "+
+				"Compressed (C), Normal (N), Encrypted (E), Temporary (T), Offline (O) and NotContentIndexed (I)", flagName, attribute)
+		}
+	}
+	return nil
+}
+
+// dotfileFilter excludes the files that are hidden by the POSIX convention, i.e. whose name,
+// or the name of one of whose directories below the root of the enumeration, starts with a dot
+type dotfileFilter struct{}
+
+func (f *dotfileFilter) doesSupportThisOS() (msg string, supported bool) {
+	return "", true
+}
+
+func (f *dotfileFilter) doesPass(storedObject storedObject) bool {
+	if strings.HasPrefix(storedObject.name, ".") {
+		return false
+	}
+	for _, segment := range strings.Split(storedObject.relativePath, common.AZCOPY_PATH_SEPARATOR_STRING) {
+		if strings.HasPrefix(segment, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// excludedFileCounter counts the files that the attribute filters, and the dotfile filter, kept out of a job.
+// A nil counter is valid, and counts nothing.
+type excludedFileCounter struct {
+	atomicExcluded uint64
+}
+
+func newExcludedFileCounter() *excludedFileCounter {
+	return &excludedFileCounter{}
+}
+
+// countExclusions wraps the attribute and dotfile filters among the given ones, so that the files they exclude are counted.
+// The filters must only be used for one side of the job, since the files at the other side would be counted again.
+func (c *excludedFileCounter) countExclusions(filters []objectFilter) []objectFilter {
+	if c == nil {
+		return filters
+	}
+
+	counted := make([]objectFilter, len(filters))
+	for i, filter := range filters {
+		switch filter.(type) {
+		case *attrFilter, *dotfileFilter:
+			counted[i] = &countingFilter{objectFilter: filter, counter: c}
+		default:
+			counted[i] = filter
+		}
+	}
+	return counted
+}
+
+// count is the number of files that were excluded
+func (c *excludedFileCounter) count() uint64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.atomicExcluded)
+}
+
+type countingFilter struct {
+	objectFilter
+	counter *excludedFileCounter
+}
+
+func (f *countingFilter) doesPass(storedObject storedObject) bool {
+	if f.objectFilter.doesPass(storedObject) {
+		return true
+	}
+	atomic.AddUint64(&f.counter.atomicExcluded, 1)
+	return false
+}
+
+// exclusionCountingTraverser has the files that the filters exclude from what its traverser finds counted.
+// It's how sync counts the exclusions at its source only.
+type exclusionCountingTraverser struct {
+	resourceTraverser
+	counter *excludedFileCounter
+}
+
+func (t *exclusionCountingTraverser) traverse(preprocessor objectMorpher, processor objectProcessor, filters []objectFilter) error {
+	return t.resourceTraverser.traverse(preprocessor, processor, t.counter.countExclusions(filters))
+}
+
+// formatFilesExcludedByAttributes is the count of excluded files, as the scanning progress shows it
+func formatFilesExcludedByAttributes(c *excludedFileCounter) string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf(", %v Files Excluded by Attributes", c.count())
+}
+
+// formatExcludedFiles is the count of excluded files, as the summary of the job shows it
+func formatExcludedFiles(c *excludedFileCounter) string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n\nFiles Excluded by Attributes: %v", c.count())
+}
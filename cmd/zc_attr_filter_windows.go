@@ -22,7 +22,6 @@ package cmd
 
 import (
 	"fmt"
-	"strings"
 	"syscall"
 
 	"github.com/Azure/azure-storage-azcopy/common"
@@ -77,6 +76,7 @@ func buildAttrFilters(attributes []string, fullPath string, isIncludeFilter bool
 	// T = Temporary files
 	// O = Offline files
 	// I = Non-indexed files
+	// The attributes may be given by their names too (see fileAttributeLetters)
 	// Reference for File Attribute Constants:
 	// https://docs.microsoft.com/en-us/windows/win32/fileio/file-attribute-constants
 	fileAttributeMap := map[string]uint32{
@@ -93,7 +93,7 @@ func buildAttrFilters(attributes []string, fullPath string, isIncludeFilter bool
 	}
 
 	for _, attribute := range attributes {
-		fileAttributes |= fileAttributeMap[strings.ToUpper(attribute)]
+		fileAttributes |= fileAttributeMap[fileAttributeLetter(attribute)]
 	}
 
 	// Don't append the filter if there is no attributes given
@@ -0,0 +1,149 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// attributesManifestEntry is what the attributes manifest sets on the files that its key matches.
+// Those of its headers that are empty, and its metadata if there is none, are left as the job's flags set them
+type attributesManifestEntry struct {
+	ContentType        string            `json:"contentType"`
+	CacheControl       string            `json:"cacheControl"`
+	ContentDisposition string            `json:"contentDisposition"`
+	ContentEncoding    string            `json:"contentEncoding"`
+	Metadata           map[string]string `json:"metadata"`
+}
+
+// attributesManifest gives each uploaded file its own content headers and metadata (--attributes-manifest).
+// It's a JSON object, whose keys are the paths of the files relative to the source, or patterns of them,
+// and whose values are the entries that apply to them. When several keys match a file, the longest one wins.
+// A nil manifest is valid, and leaves every file as it is.
+type attributesManifest struct {
+	// the keys, longest first, so the first that matches a file is the one that applies to it
+	keys    []string
+	entries map[string]attributesManifestEntry
+
+	matchedLock sync.Mutex
+	matched     map[string]bool
+}
+
+func loadAttributesManifest(manifestPath string) (*attributesManifest, error) {
+	data, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rawEntries := make(map[string]attributesManifestEntry)
+	if err = json.Unmarshal(data, &rawEntries); err != nil {
+		return nil, fmt.Errorf("cannot parse the attributes manifest %s: %s", manifestPath, err)
+	}
+
+	m := &attributesManifest{
+		keys:    make([]string, 0, len(rawEntries)),
+		entries: make(map[string]attributesManifestEntry, len(rawEntries)),
+		matched: make(map[string]bool),
+	}
+	for rawKey, entry := range rawEntries {
+		key := strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(rawKey), "./"), common.AZCOPY_PATH_SEPARATOR_STRING)
+		if _, err = path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s in the attributes manifest %s: %s", rawKey, manifestPath, err)
+		}
+		m.keys = append(m.keys, key)
+		m.entries[key] = entry
+	}
+	sort.Slice(m.keys, func(i, j int) bool {
+		if len(m.keys[i]) != len(m.keys[j]) {
+			return len(m.keys[i]) > len(m.keys[j])
+		}
+		return m.keys[i] < m.keys[j] // so that keys of the same length always win in the same order
+	})
+	return m, nil
+}
+
+// apply sets the headers and metadata of the entry that matches the object (if one does) on it,
+// so that they are carried by its transfer
+func (m *attributesManifest) apply(object *storedObject) {
+	if m == nil {
+		return
+	}
+
+	relativePath := object.relativePath
+	if relativePath == "" {
+		relativePath = object.name // the source is the file itself
+	}
+
+	for _, key := range m.keys {
+		if matches, _ := path.Match(key, relativePath); !matches {
+			continue
+		}
+
+		entry := m.entries[key]
+		object.contentType = entry.ContentType
+		object.cacheControl = entry.CacheControl
+		object.contentDisposition = entry.ContentDisposition
+		object.contentEncoding = entry.ContentEncoding
+		if len(entry.Metadata) > 0 {
+			object.Metadata = common.Metadata(entry.Metadata)
+		}
+
+		m.matchedLock.Lock()
+		m.matched[key] = true
+		m.matchedLock.Unlock()
+		return
+	}
+}
+
+// unmatched lists the keys of the manifest that matched no file that was transferred
+func (m *attributesManifest) unmatched() []string {
+	if m == nil {
+		return nil
+	}
+
+	m.matchedLock.Lock()
+	defer m.matchedLock.Unlock()
+	result := make([]string, 0)
+	for _, key := range m.keys {
+		if !m.matched[key] {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func formatUnmatchedAttributesManifestEntries(summary common.ListJobSummaryResponse) string {
+	if len(summary.UnmatchedAttributesManifestEntries) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n%v entries of the attributes manifest matched no source file: %s",
+		len(summary.UnmatchedAttributesManifestEntries), strings.Join(summary.UnmatchedAttributesManifestEntries, ", "))
+}
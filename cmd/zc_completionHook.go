@@ -0,0 +1,144 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// how long the --on-complete-exec command may run before it's killed, so that it can't keep azcopy from exiting
+const completionCommandTimeout = 5 * time.Minute
+
+// the placeholders that are substituted in the --on-complete-exec command
+const (
+	completionJobIDPlaceholder  = "{jobid}"
+	completionStatusPlaceholder = "{status}"
+)
+
+// completionHook is what the user asked to happen once the job reaches a terminal state (--on-complete-exec and --notify),
+// so that a long transfer doesn't finish silently in a forgotten terminal.
+// It only concerns the job of the command it was given to: a follow-up job, or a later resume of the job, doesn't run it,
+// unless the resume was given the hook itself. A nil hook does nothing.
+type completionHook struct {
+	command string
+	notify  bool
+}
+
+// newCompletionHook returns nil when there's nothing to do on completion
+func newCompletionHook(command string, notify bool) *completionHook {
+	command = strings.TrimSpace(command)
+	if command == "" && !notify {
+		return nil
+	}
+	return &completionHook{command: command, notify: notify}
+}
+
+// expandCommand substitutes the placeholders of the command. The job ID and status never contain anything that the shell would interpret
+func (h *completionHook) expandCommand(jobID common.JobID, status common.JobStatus) string {
+	return strings.NewReplacer(
+		completionJobIDPlaceholder, jobID.String(),
+		completionStatusPlaceholder, status.String(),
+	).Replace(h.command)
+}
+
+// run runs the command and raises the notification. It must only be called once the job is done,
+// and before the process exits, which is why it waits for the command (up to completionCommandTimeout)
+func (h *completionHook) run(jobID common.JobID, status common.JobStatus) {
+	if h == nil || !status.IsJobDone() {
+		return
+	}
+
+	if h.notify {
+		if err := desktopNotify("AzCopy", fmt.Sprintf("Job %s finished: %s", jobID, status)); err != nil {
+			logCompletionHook(jobID, fmt.Sprintf("Failed to raise the notification of the job's completion: %s", err))
+		}
+	}
+
+	if h.command != "" {
+		command := h.expandCommand(jobID, status)
+		output, err := runCompletionCommand(command, completionCommandTimeout)
+		message := fmt.Sprintf("Ran the on-complete-exec command: %s", command)
+		if err != nil {
+			message = fmt.Sprintf("The on-complete-exec command failed: %s: %s", command, err)
+			glcm.Info(message)
+		}
+		if output != "" {
+			message += "\nIts output:\n" + output
+		}
+		logCompletionHook(jobID, message)
+	}
+}
+
+// runCompletionCommand runs the command with the shell of the OS, so that it can use pipes, redirections, and the like,
+// and returns what it wrote to stdout and stderr.
+// The output goes through a file rather than a pipe: a process that the command started in the background
+// would hold a pipe open, and keep us waiting for it well past the timeout
+func runCompletionCommand(command string, timeout time.Duration) (string, error) {
+	outputFile, err := ioutil.TempFile("", "azcopy-on-complete-exec")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(outputFile.Name())
+	defer outputFile.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	cmd.Stdout = outputFile
+	cmd.Stderr = outputFile
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("it was killed after running for %v", timeout)
+	}
+
+	output, readErr := ioutil.ReadFile(outputFile.Name())
+	if err == nil {
+		err = readErr
+	}
+	return strings.TrimSpace(string(output)), err
+}
+
+func logCompletionHook(jobID common.JobID, message string) {
+	if ste.JobsAdmin == nil {
+		return
+	}
+	if jobMan, exists := ste.JobsAdmin.JobMgr(jobID); exists {
+		jobMan.Log(pipeline.LogInfo, message)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// logInAgain gets a new OAuth token from the user, printing a fresh device code, and caches it like 'azcopy login' does. Tests replace it
+var logInAgain = func(tenantID, activeDirectoryEndpoint string) (*common.OAuthTokenInfo, error) {
+	return GetUserOAuthTokenManagerInstance().UserLogin(tenantID, activeDirectoryEndpoint, true)
+}
+
+// credentialExpiryCheck acts on the job holding back its transfers, since its OAuth token expired and couldn't be refreshed,
+// which happens to device code logins that outlive their refresh token. It's checked each time the progress of the job is reported.
+// When the user is there to log in again, they're asked to, and the job carries on with the new token. Otherwise, or if they
+// would rather not, the job is paused, to be resumed after 'azcopy login', rather than fail every transfer it has left.
+// A nil check never does anything.
+type credentialExpiryCheck struct {
+	tokenInfo   common.OAuthTokenInfo
+	interactive bool
+
+	// why the job was paused, or empty if it wasn't
+	reason string
+}
+
+// newCredentialExpiryCheck returns nil unless the job uses an OAuth token. The user is only asked to log in again if they're
+// at a terminal (interactive), and logged in with a device code in the first place
+func newCredentialExpiryCheck(credInfo common.CredentialInfo, interactive bool) *credentialExpiryCheck {
+	if credInfo.CredentialType != common.ECredentialType.OAuthToken() {
+		return nil
+	}
+	tokenInfo := credInfo.OAuthTokenInfo
+	deviceCodeLogin := !tokenInfo.Identity && !tokenInfo.ServicePrincipalName && tokenInfo.TokenRefreshSource == "" && tokenInfo.ClientID == ""
+	return &credentialExpiryCheck{tokenInfo: tokenInfo, interactive: interactive && deviceCodeLogin}
+}
+
+func (l *credentialExpiryCheck) check(jobID common.JobID, summary common.ListJobSummaryResponse) {
+	if l == nil || l.reason != "" || !summary.CredentialExpired {
+		return
+	}
+
+	problem := fmt.Sprintf("the OAuth token expired, and couldn't be refreshed (%s)", summary.CredentialExpiredError)
+	if l.interactive && l.renew(jobID, problem) {
+		return
+	}
+
+	l.reason = problem
+	LogStdoutAndJobLog(fmt.Sprintf("Pausing the job, since %s. Log in again with 'azcopy login', then resume it with 'azcopy jobs resume %s'.", problem, jobID))
+	var resp common.CancelPauseResumeResponse
+	Rpc(common.ERpcCmd.PauseJob(), jobID, &resp)
+	if !resp.CancelledPauseResumed {
+		glcm.Info("Failed to pause the job " + jobID.String() + ": " + resp.ErrorMsg)
+	}
+}
+
+// renew asks the user to log in again, and gives the job the new token. It returns false if the job didn't get one
+func (l *credentialExpiryCheck) renew(jobID common.JobID, problem string) bool {
+	answer := glcm.Prompt(fmt.Sprintf("The job is waiting, since %s. Do you want to log in again, so that it carries on? Otherwise it's paused, to be resumed later.", problem),
+		common.PromptDetails{
+			PromptType: common.EPromptType.LogInAgain(),
+			ResponseOptions: []common.ResponseOption{
+				common.EResponseOption.Yes(),
+				common.EResponseOption.No(),
+			},
+		})
+	if answer != common.EResponseOption.Yes() {
+		return false
+	}
+
+	tokenInfo, err := logInAgain(l.tokenInfo.Tenant, l.tokenInfo.ActiveDirectoryEndpoint)
+	if err != nil {
+		glcm.Info("Failed to log in again: " + err.Error())
+		return false
+	}
+
+	var resp common.RenewCredentialResponse
+	Rpc(common.ERpcCmd.RenewCredential(), &common.RenewCredentialRequest{JobID: jobID, OAuthTokenInfo: *tokenInfo}, &resp)
+	if !resp.Renewed {
+		glcm.Info("Failed to give the job the new OAuth token: " + resp.ErrorMsg)
+		return false
+	}
+	l.tokenInfo = *tokenInfo
+	LogStdoutAndJobLog("Logged in again. The job carries on with the new OAuth token.")
+	return true
+}
+
+// pausedJobDone tells whether the job was paused here, and has wound down, in which case the command is done with it
+func (l *credentialExpiryCheck) pausedJobDone(summary common.ListJobSummaryResponse) bool {
+	return l != nil && l.reason != "" && summary.JobStatus == common.EJobStatus.Paused() && summary.PausedJobDrained
+}
+
+// reportPause reflects in the summary of the finished job why it was paused (if it was)
+func (l *credentialExpiryCheck) reportPause(summary *common.ListJobSummaryResponse) {
+	if l == nil || l.reason == "" {
+		return
+	}
+	summary.CredentialExpiredError = l.reason
+}
+
+func formatCredentialExpiry(summary common.ListJobSummaryResponse) string {
+	if summary.JobStatus != common.EJobStatus.Paused() || summary.CredentialExpiredError == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nThe job was paused, since %s. Log in again with 'azcopy login', then resume it with 'azcopy jobs resume %s'",
+		summary.CredentialExpiredError, summary.JobID)
+}
@@ -0,0 +1,160 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// deletedBlobRestorer has the blob traversers list the soft-deleted blobs along with the live ones (--include-deleted),
+// and undeletes each soft-deleted blob that passes the filters before it's processed, so that it's then copied like any other.
+// A nil restorer is valid: soft-deleted blobs are then not listed, as they never were.
+type deletedBlobRestorer struct {
+	atomicUndeleted      uint64
+	atomicUndeleteFailed uint64
+
+	// whether a soft-deleted blob that has a live blob of the same name is undeleted, rather than ignored in favor of the live one
+	preferDeleted bool
+	// whether the soft-deleted blobs are only undeleted, with nothing copied anywhere (--restore-in-place)
+	restoreOnly bool
+}
+
+func newDeletedBlobRestorer(preferDeleted, restoreOnly bool) *deletedBlobRestorer {
+	return &deletedBlobRestorer{preferDeleted: preferDeleted, restoreOnly: restoreOnly}
+}
+
+// attachTo has the given traverser list the soft-deleted blobs, if it's a blob traverser. Other traversers are left as they are
+func (r *deletedBlobRestorer) attachTo(traverser resourceTraverser) {
+	if r == nil {
+		return
+	}
+
+	switch t := traverser.(type) {
+	case *blobTraverser:
+		t.deletedBlobs = r
+	case *blobAccountTraverser:
+		t.deletedBlobs = r
+	}
+}
+
+// listsDeleted tells whether the listings include the soft-deleted blobs
+func (r *deletedBlobRestorer) listsDeleted() bool {
+	return r != nil
+}
+
+// restoresOnly tells whether the blobs are only undeleted in place, in which case nothing is transferred
+func (r *deletedBlobRestorer) restoresOnly() bool {
+	return r != nil && r.restoreOnly
+}
+
+// newPicker makes what hands process one blob per name, out of listings that have the soft-deleted blobs in them
+func (r *deletedBlobRestorer) newPicker(process func(azblob.BlobItem) error) *deletedBlobPicker {
+	return &deletedBlobPicker{preferDeleted: r.preferDeleted, process: process}
+}
+
+// restore undeletes the blob, if it's a soft-deleted one, and tells whether it's then to be processed.
+// A blob that can't be undeleted is skipped, as it can't be read.
+func (r *deletedBlobRestorer) restore(ctx context.Context, blobURL azblob.BlobURL, deleted bool) (process bool) {
+	if deleted {
+		if _, err := blobURL.Undelete(ctx); err != nil {
+			atomic.AddUint64(&r.atomicUndeleteFailed, 1)
+			LogStdoutAndJobLog(fmt.Sprintf("Skipping %s, as it could not be undeleted: %s",
+				common.URLExtension{URL: blobURL.URL()}.RedactSecretQueryParamForLogging(), err))
+			return false
+		}
+		atomic.AddUint64(&r.atomicUndeleted, 1)
+	}
+	return !r.restoreOnly
+}
+
+func (r *deletedBlobRestorer) undeleted() uint64 {
+	return atomic.LoadUint64(&r.atomicUndeleted)
+}
+
+func (r *deletedBlobRestorer) undeleteFailed() uint64 {
+	return atomic.LoadUint64(&r.atomicUndeleteFailed)
+}
+
+// output is what a copy that only restores the blobs in place ends with
+func (r *deletedBlobRestorer) output(format common.OutputFormat) string {
+	if format == common.EOutputFormat.Json() {
+		jsonOutput, err := json.Marshal(struct {
+			BlobsUndeleted    uint64
+			BlobsNotUndeleted uint64
+		}{r.undeleted(), r.undeleteFailed()})
+		common.PanicIfErr(err)
+		return string(jsonOutput)
+	}
+	return fmt.Sprintf("\nRestore in place, no job was created\nBlobs Undeleted: %v\nBlobs That Could Not Be Undeleted: %v\n", r.undeleted(), r.undeleteFailed())
+}
+
+func formatBlobsUndeleted(r *deletedBlobRestorer) string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n\nBlobs Undeleted: %v\nBlobs That Could Not Be Undeleted: %v", r.undeleted(), r.undeleteFailed())
+}
+
+// deletedBlobPicker takes the blobs of a listing in its order, in which a live blob and a soft-deleted blob of the same name
+// are next to each other, and hands on one blob per name: the live one, unless the soft-deleted one is preferred.
+// It holds on to each blob until it has seen the next one, so flush must be called once the listing is over.
+type deletedBlobPicker struct {
+	preferDeleted bool
+	process       func(azblob.BlobItem) error
+	pending       *azblob.BlobItem
+}
+
+func (p *deletedBlobPicker) add(blobInfo azblob.BlobItem) error {
+	if blobInfo.Snapshot != "" {
+		return nil // snapshots are not copied
+	}
+
+	if p.pending != nil && p.pending.Name == blobInfo.Name {
+		chosen := *p.pending
+		if blobInfo.Deleted == p.preferDeleted {
+			chosen = blobInfo
+		}
+		p.pending = nil
+		return p.process(chosen)
+	}
+
+	if err := p.flush(); err != nil {
+		return err
+	}
+	p.pending = &blobInfo
+	return nil
+}
+
+func (p *deletedBlobPicker) flush() error {
+	if p.pending == nil {
+		return nil
+	}
+	blobInfo := *p.pending
+	p.pending = nil
+	return p.process(blobInfo)
+}
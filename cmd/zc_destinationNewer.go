@@ -0,0 +1,109 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// destinationNewerEventName is the JSON message type of the event that tells about a file that wasn't overwritten,
+// because it's newer at the destination than at the source
+const destinationNewerEventName = "DestinationNewer"
+
+// compareLastModifiedTimes tells whether the source object was modified after its destination (1), before it (-1), or at the same time (0).
+// The services only list their last modified times to the second, whereas local file systems keep fractions of seconds,
+// so the times are compared to the second when either of them has no fraction of a second.
+// Otherwise, a local file would always seem newer than the copy of it whose time was set from it, to the second.
+func compareLastModifiedTimes(source, destination time.Time) int {
+	if source.Nanosecond() == 0 || destination.Nanosecond() == 0 {
+		source, destination = source.Truncate(time.Second), destination.Truncate(time.Second)
+	}
+
+	switch {
+	case source.After(destination):
+		return 1
+	case source.Before(destination):
+		return -1
+	default:
+		return 0
+	}
+}
+
+// destinationNewerReporter counts the files that sync didn't overwrite because they are newer at the destination than at the source,
+// and tells about each of them. Files usually only become newer at the destination when someone edits them there,
+// so these are how the destination diverges from the source, unlike the files that are simply in sync.
+type destinationNewerReporter struct {
+	atomicCount uint64
+}
+
+type destinationNewerJsonTemplate struct {
+	Path                        string
+	SourceLastModifiedTime      time.Time
+	DestinationLastModifiedTime time.Time
+}
+
+func newDestinationNewerReporter() *destinationNewerReporter {
+	return &destinationNewerReporter{}
+}
+
+func (r *destinationNewerReporter) report(source, destination storedObject) {
+	atomic.AddUint64(&r.atomicCount, 1)
+
+	message := fmt.Sprintf("Not overwriting %s, since it is newer at the destination (%v) than at the source (%v)",
+		source.relativePath, destination.lastModifiedTime, source.lastModifiedTime)
+	glcm.Event(destinationNewerEventName, func(format common.OutputFormat) string {
+		if format == common.EOutputFormat.Json() {
+			jsonOutput, err := json.Marshal(destinationNewerJsonTemplate{
+				Path:                        source.relativePath,
+				SourceLastModifiedTime:      source.lastModifiedTime,
+				DestinationLastModifiedTime: destination.lastModifiedTime,
+			})
+			common.PanicIfErr(err)
+			return string(jsonOutput)
+		}
+		return message
+	})
+	if ste.JobsAdmin != nil {
+		ste.JobsAdmin.LogToJobLog(message)
+	}
+}
+
+// count is the number of files that weren't overwritten, because they are newer at the destination
+func (r *destinationNewerReporter) count() uint64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&r.atomicCount)
+}
+
+// formatDestinationNewer is the count of the files that weren't overwritten, as the summary of the job shows it
+func formatDestinationNewer(r *destinationNewerReporter) string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n\nFiles Skipped Because Newer at Destination: %v", r.count())
+}
@@ -0,0 +1,277 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-file-go/azfile"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// how many destinations are scrubbed at once
+const scrubParallelism = 32
+
+// the lines of the scrub report, after the destination and a colon
+const (
+	scrubbedRemoved = "removed"
+	scrubbedClean   = "nothing to remove"
+)
+
+// destinationScrubber removes what the transfers of a job that were started, but never completed, left at their destinations,
+// once the job was cancelled, or the process that ran it died: the temporary files that ADLS Gen2 and Azure Files destinations
+// are written under, and the destination files themselves when they weren't written under temporary names.
+// A destination is only removed if it was modified after the job started, so a file that the job never got to overwrite is kept.
+// Blob destinations are left as they are, since their uncommitted blocks expire on their own.
+//
+// Each destination that was dealt with is written to the report of the scrub, so that a scrub that's interrupted
+// can be run again, and carries on with the destinations that it hadn't got to.
+type destinationScrubber struct {
+	location       common.Location
+	p              pipeline.Pipeline
+	ctx            context.Context
+	jobStart       time.Time
+	tempNameSuffix string
+	// appended to the URLs of remote destinations, which the plan has without it
+	sas string
+
+	// the destinations that an earlier scrub of the job already dealt with
+	done map[string]bool
+
+	reportLock sync.Mutex
+	report     *os.File
+
+	atomicRemoved uint32
+	atomicClean   uint32
+	atomicFailed  uint32
+}
+
+// scrubReportPath is where the destinations that the scrub of a job dealt with are listed.
+// It's next to the job's log and named like it, so that it's removed with it.
+func scrubReportPath(jobID common.JobID) string {
+	return filepath.Join(azcopyLogPathFolder, jobID.String()+"-scrubbed.log")
+}
+
+// needsScrubbing tells whether a transfer of the given status may have left something at its destination.
+// Those that were skipped never touched it, and those that succeeded left what they were meant to.
+func needsScrubbing(status common.TransferStatus) bool {
+	switch status {
+	case common.ETransferStatus.Started(), common.ETransferStatus.Failed(), common.ETransferStatus.ShareFull():
+		return true
+	default:
+		return false
+	}
+}
+
+func newDestinationScrubber(ctx context.Context, location common.Location, p pipeline.Pipeline, jobStart time.Time, tempNameSuffix, sas string, reportPath string) (*destinationScrubber, error) {
+	s := &destinationScrubber{
+		location:       location,
+		p:              p,
+		ctx:            ctx,
+		jobStart:       jobStart,
+		tempNameSuffix: tempNameSuffix,
+		sas:            sas,
+		done:           make(map[string]bool),
+	}
+
+	// carry on from where an earlier scrub got to, if there was one
+	if f, err := os.Open(reportPath); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if i := strings.LastIndex(scanner.Text(), ": "); i > 0 {
+				s.done[scanner.Text()[:i]] = true
+			}
+		}
+		f.Close()
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var err error
+	s.report, err = os.OpenFile(reportPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// scrubAll scrubs the destinations of the given transfers that need it, scrubParallelism at a time
+func (s *destinationScrubber) scrubAll(transfers []common.TransferDetail) {
+	destinations := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < scrubParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for destination := range destinations {
+				s.scrubOne(destination)
+			}
+		}()
+	}
+
+	for _, transfer := range transfers {
+		if needsScrubbing(transfer.TransferStatus) && !s.done[transfer.Dst] {
+			destinations <- transfer.Dst
+		}
+	}
+	close(destinations)
+	wg.Wait()
+}
+
+func (s *destinationScrubber) scrubOne(destination string) {
+	removed, err := s.scrub(destination)
+	if err != nil {
+		// it's not written to the report, so that the next scrub tries it again
+		atomic.AddUint32(&s.atomicFailed, 1)
+		glcm.Info(fmt.Sprintf("Failed to scrub %s: %s", destination, err))
+		return
+	}
+
+	outcome := scrubbedClean
+	if removed {
+		outcome = scrubbedRemoved
+		atomic.AddUint32(&s.atomicRemoved, 1)
+		glcm.Info("Removed " + destination)
+	} else {
+		atomic.AddUint32(&s.atomicClean, 1)
+	}
+
+	s.reportLock.Lock()
+	defer s.reportLock.Unlock()
+	_, err = fmt.Fprintf(s.report, "%s: %s\n", destination, outcome)
+	common.PanicIfErr(err)
+}
+
+// scrub removes what the transfer left at the destination, and tells whether there was anything
+func (s *destinationScrubber) scrub(destination string) (bool, error) {
+	switch s.location {
+	case common.ELocation.Local():
+		return s.scrubLocal(destination)
+	case common.ELocation.File(), common.ELocation.BlobFS():
+		return s.scrubRemote(destination)
+	default:
+		// uncommitted blocks expire on their own, and a committed blob is complete
+		return false, nil
+	}
+}
+
+func (s *destinationScrubber) scrubLocal(destination string) (bool, error) {
+	info, err := os.Stat(destination)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.ModTime().Before(s.jobStart) {
+		return false, nil
+	}
+	return true, os.Remove(destination)
+}
+
+func (s *destinationScrubber) scrubRemote(destination string) (bool, error) {
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return false, err
+	}
+	if s.sas != "" {
+		copyHandlerUtil{}.appendQueryParamToUrl(destinationURL, s.sas)
+	}
+
+	if s.tempNameSuffix != "" {
+		// only the temporary name was written; the destination itself is only replaced once it's complete
+		destinationURL.Path += s.tempNameSuffix
+		if destinationURL.RawPath != "" {
+			destinationURL.RawPath += url.PathEscape(s.tempNameSuffix)
+		}
+		return s.deleteRemote(*destinationURL, false)
+	}
+	return s.deleteRemote(*destinationURL, true)
+}
+
+// deleteRemote deletes the file at u, if there is one. If onlyIfWrittenByJob, it's kept if it wasn't modified since the job started
+func (s *destinationScrubber) deleteRemote(u url.URL, onlyIfWrittenByJob bool) (bool, error) {
+	fileURL := azfile.NewFileURL(u, s.p)
+	bfsURL := azbfs.NewFileURL(u, s.p)
+
+	if onlyIfWrittenByJob {
+		var lastModified time.Time
+		var err error
+		if s.location == common.ELocation.File() {
+			var props *azfile.FileGetPropertiesResponse
+			if props, err = fileURL.GetProperties(s.ctx); err == nil {
+				lastModified = props.LastModified()
+			}
+		} else {
+			var props *azbfs.PathGetPropertiesResponse
+			if props, err = bfsURL.GetProperties(s.ctx); err == nil {
+				lastModified, err = time.Parse(time.RFC1123, props.LastModified())
+			}
+		}
+		if isNotFound(err) {
+			return false, nil // the transfer never got to create it
+		} else if err != nil {
+			return false, err
+		} else if lastModified.Before(s.jobStart) {
+			return false, nil
+		}
+	}
+
+	var err error
+	if s.location == common.ELocation.File() {
+		_, err = fileURL.Delete(s.ctx)
+	} else {
+		_, err = bfsURL.Delete(s.ctx)
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// isNotFound tells whether the error is the service's response that what was asked for doesn't exist
+func isNotFound(err error) bool {
+	respErr, ok := err.(interface{ Response() *http.Response })
+	return ok && respErr.Response() != nil && respErr.Response().StatusCode == http.StatusNotFound
+}
+
+func (s *destinationScrubber) close() error {
+	return s.report.Close()
+}
+
+func (s *destinationScrubber) removed() uint32 { return atomic.LoadUint32(&s.atomicRemoved) }
+func (s *destinationScrubber) clean() uint32   { return atomic.LoadUint32(&s.atomicClean) }
+func (s *destinationScrubber) failed() uint32  { return atomic.LoadUint32(&s.atomicFailed) }
@@ -0,0 +1,262 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// the flag that lists the containers that the destination is sharded across, for both copy and jobs resume
+const destinationShardsFlag = "destination-shards"
+
+// destinationShard is one of the containers that the destination of a job is sharded across
+type destinationShard struct {
+	// the URL of the container, without its SAS
+	root string
+	sas  string
+}
+
+// destinationShards spreads the transfers of a job across the containers of its destination (--destination-shards),
+// by the hash of their paths relative to the destination, so that a path always goes to the same container.
+// Each part of the job goes to a single shard, so the plan of the part records where its transfers went,
+// and a resumed job sends them to the same place. Which shard each path went to is also written to a manifest,
+// so that the files can be found later. A nil destinationShards is a destination that isn't sharded.
+type destinationShards struct {
+	shards []destinationShard
+	// the transfers of each shard that are waiting to be dispatched in a part of their own
+	pending [][]common.CopyTransfer
+
+	manifestPath string
+	manifest     *os.File
+	writer       *bufio.Writer
+}
+
+// parseDestinationShards takes the URLs of the containers, with their SAS, separated by ';'
+func parseDestinationShards(list string) (*destinationShards, error) {
+	s := &destinationShards{}
+	seen := make(map[string]bool)
+	for _, shardURL := range strings.Split(list, ";") {
+		if shardURL = strings.TrimSpace(shardURL); shardURL == "" {
+			continue
+		}
+		shard, err := parseDestinationShard(shardURL)
+		if err != nil {
+			return nil, err
+		}
+		if seen[shard.root] {
+			return nil, fmt.Errorf("the destination shard %s is given more than once", shard.root)
+		}
+		seen[shard.root] = true
+		s.shards = append(s.shards, shard)
+	}
+	if len(s.shards) < 2 {
+		return nil, errors.New("destination-shards needs at least two containers")
+	}
+	s.pending = make([][]common.CopyTransfer, len(s.shards))
+	return s, nil
+}
+
+func parseDestinationShard(shardURL string) (destinationShard, error) {
+	u, err := url.Parse(shardURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return destinationShard{}, errors.New("one of the destination shards is not a URL")
+	}
+	parts := azblob.NewBlobURLParts(*u)
+	if parts.ContainerName == "" || parts.BlobName != "" {
+		return destinationShard{}, fmt.Errorf("the destination shard %s://%s%s is not the URL of a blob container", u.Scheme, u.Host, u.Path)
+	}
+	root, sas, err := SplitAuthTokenFromResource(shardURL, common.ELocation.Blob())
+	if err != nil {
+		return destinationShard{}, err
+	}
+	return destinationShard{root: strings.TrimSuffix(root, common.AZCOPY_PATH_SEPARATOR_STRING), sas: sas}, nil
+}
+
+// first is the URL of the first shard, with its SAS, which stands for the destination of the job wherever there has to be one
+func (s *destinationShards) first() string {
+	return s.shardURL(0)
+}
+
+func (s *destinationShards) shardURL(i int) string {
+	if s.shards[i].sas == "" {
+		return s.shards[i].root
+	}
+	return s.shards[i].root + "?" + s.shards[i].sas
+}
+
+// sasByRoot maps the URL of each shard to its SAS, which is what a resumed job needs to find the SAS of each of its parts
+func (s *destinationShards) sasByRoot() map[string]string {
+	result := make(map[string]string, len(s.shards))
+	for _, shard := range s.shards {
+		result[shard.root] = shard.sas
+	}
+	return result
+}
+
+// shardOf picks the shard of a path relative to the destination: the FNV-1a hash of the path, modulo the number of shards
+func (s *destinationShards) shardOf(relativePath string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(relativePath))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// addTransfer holds the transfer with the others of its shard, and dispatches them in a part of their own once there are enough
+func (s *destinationShards) addTransfer(e *common.CopyJobPartOrderRequest, transfer common.CopyTransfer, cca *cookedCopyCmdArgs) error {
+	relativePath := strings.TrimPrefix(transfer.Destination, common.AZCOPY_PATH_SEPARATOR_STRING)
+	if unescaped, err := url.PathUnescape(relativePath); err == nil {
+		relativePath = unescaped
+	}
+	i := s.shardOf(relativePath)
+	if err := s.record(e.JobID, relativePath, i); err != nil {
+		return err
+	}
+
+	if len(s.pending[i]) == NumOfFilesPerDispatchJobPart {
+		if err := s.dispatch(e, i, cca); err != nil {
+			return err
+		}
+	}
+	s.pending[i] = append(s.pending[i], transfer)
+	return nil
+}
+
+// dispatch sends the transfers that the shard holds as a part of the job.
+// The part has no listing marker, since the other shards may still hold transfers from before the point that the listing reached
+func (s *destinationShards) dispatch(e *common.CopyJobPartOrderRequest, i int, cca *cookedCopyCmdArgs) error {
+	s.load(e, i)
+	return dispatchPart(e, cca)
+}
+
+func (s *destinationShards) load(e *common.CopyJobPartOrderRequest, i int) {
+	e.Transfers = s.pending[i]
+	e.DestinationRoot = s.shards[i].root
+	e.DestinationSAS = s.shards[i].sas
+	s.pending[i] = nil
+}
+
+// dispatchFinalPart sends what every shard still holds, the last of them as the final part of the job
+func (s *destinationShards) dispatchFinalPart(e *common.CopyJobPartOrderRequest, cca *cookedCopyCmdArgs) error {
+	if err := s.closeManifest(); err != nil {
+		return err
+	}
+
+	last := -1
+	for i := range s.pending {
+		if len(s.pending[i]) > 0 {
+			last = i
+		}
+	}
+	for i := 0; i < last; i++ {
+		if len(s.pending[i]) == 0 {
+			continue
+		}
+		if err := s.dispatch(e, i, cca); err != nil {
+			return err
+		}
+	}
+	if last >= 0 {
+		s.load(e, last)
+	} // otherwise the final part is empty, and tells whether anything was scheduled at all
+
+	return dispatchFinalPart(e, cca)
+}
+
+// destinationShardManifestPath is where the shard of each path of a job is written. It's next to the job's log,
+// but it isn't removed with it by jobs clean or jobs rm, since the files can't be found without it
+func destinationShardManifestPath(jobID common.JobID) string {
+	return filepath.Join(azcopyLogPathFolder, jobID.String()+"-shard-manifest.tsv")
+}
+
+// record writes the shard of the path to the manifest, which has a line for each path, with the URL of its shard after a tab
+func (s *destinationShards) record(jobID common.JobID, relativePath string, i int) error {
+	if s.manifest == nil {
+		s.manifestPath = destinationShardManifestPath(jobID)
+		f, err := os.Create(s.manifestPath)
+		if err != nil {
+			return fmt.Errorf("failed to create the manifest of the destination shards: %s", err)
+		}
+		s.manifest = f
+		s.writer = bufio.NewWriter(f)
+	}
+	if _, err := fmt.Fprintf(s.writer, "%s\t%s\n", relativePath, s.shards[i].root); err != nil {
+		return fmt.Errorf("failed to write the manifest of the destination shards: %s", err)
+	}
+	return nil
+}
+
+func (s *destinationShards) closeManifest() error {
+	if s.manifest == nil {
+		return nil
+	}
+	err := s.writer.Flush()
+	if closeErr := s.manifest.Close(); err == nil {
+		err = closeErr
+	}
+	s.manifest = nil
+	if err != nil {
+		return fmt.Errorf("failed to write the manifest of the destination shards: %s", err)
+	}
+	return nil
+}
+
+// summarize adds the throughput of each shard over the time that the job ran, and where the manifest is, to the summary of the finished job
+func (s *destinationShards) summarize(summary *common.ListJobSummaryResponse, elapsed time.Duration) {
+	if s == nil {
+		return
+	}
+	summary.ShardManifest = s.manifestPath
+	if elapsed <= 0 {
+		return
+	}
+	for i := range summary.Shards {
+		mbps := float64(summary.Shards[i].BytesTransferred) * 8 / base10Mega / elapsed.Seconds()
+		summary.Shards[i].ThroughputMbps = ste.ToFixed(mbps, 1)
+	}
+}
+
+func formatShardSummaries(summary common.ListJobSummaryResponse) string {
+	if len(summary.Shards) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(summary.Shards))
+	for _, s := range summary.Shards {
+		lines = append(lines, fmt.Sprintf("%s: %v transfers (%v failed), %s, %v Mb/s",
+			s.Destination, s.Transfers, s.TransfersFailed, byteSizeToString(int64(s.BytesTransferred)), s.ThroughputMbps))
+	}
+	result := "\n\nTransfers by Shard:\n" + strings.Join(lines, "\n")
+	if summary.ShardManifest != "" {
+		result += "\nThe shard of each path is listed in " + summary.ShardManifest
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// destinationTemplateVariables are what a destination template may refer to, and how each is taken from the source object.
+// The dates are those of the last modification of the source, in UTC
+var destinationTemplateVariables = map[string]func(object storedObject) string{
+	"year":  func(o storedObject) string { return fmt.Sprintf("%04d", o.lastModifiedTime.UTC().Year()) },
+	"month": func(o storedObject) string { return fmt.Sprintf("%02d", int(o.lastModifiedTime.UTC().Month())) },
+	"day":   func(o storedObject) string { return fmt.Sprintf("%02d", o.lastModifiedTime.UTC().Day()) },
+	"hour":  func(o storedObject) string { return fmt.Sprintf("%02d", o.lastModifiedTime.UTC().Hour()) },
+	// the name of the source, e.g. report.pdf
+	"filename": func(o storedObject) string { return o.name },
+	// the name without its extension, e.g. report
+	"basename": func(o storedObject) string { return strings.TrimSuffix(o.name, path.Ext(o.name)) },
+	// the extension without its dot, e.g. pdf. Empty if there's none
+	"ext": func(o storedObject) string { return strings.TrimPrefix(path.Ext(o.name), ".") },
+	// the directory of the source, relative to the root of the enumeration. Empty for the objects directly under it
+	"dir": func(o storedObject) string {
+		return path.Dir(strings.Replace(o.relativePath, common.OS_PATH_SEPARATOR, common.AZCOPY_PATH_SEPARATOR_STRING, -1))
+	},
+	// the container of the source, when the source is an account
+	"container": func(o storedObject) string { return o.containerName },
+}
+
+// destinationTemplate decides where each object goes under the destination (--destination-template), in place of its path relative
+// to the source, e.g. {year}/{month}/{day}/{filename} or {ext}/{filename}.
+// A nil template is valid, and leaves each object at its own relative path.
+type destinationTemplate struct {
+	// the literal text and the variables of the template, in order. The variables are the odd ones
+	parts []string
+
+	// which source each expanded destination was claimed by, so that two sources landing on the same destination are noticed
+	claimedLock sync.Mutex
+	claimed     map[string]string
+}
+
+// parseDestinationTemplate checks that every variable of the template is known, so that a mistyped one fails the job before it starts
+func parseDestinationTemplate(template string) (*destinationTemplate, error) {
+	t := &destinationTemplate{claimed: make(map[string]string)}
+	rest := template
+	for {
+		open := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if open < 0 {
+			if end >= 0 {
+				return nil, fmt.Errorf("the destination template %s has a } without a {", template)
+			}
+			t.parts = append(t.parts, rest)
+			break
+		}
+		if end < open {
+			return nil, fmt.Errorf("the destination template %s has a } without a {", template)
+		}
+
+		name := rest[open+1 : end]
+		if _, ok := destinationTemplateVariables[name]; !ok {
+			return nil, fmt.Errorf("the destination template %s refers to {%s}, which is unknown. The known variables are %s",
+				template, name, strings.Join(knownDestinationTemplateVariables(), ", "))
+		}
+		t.parts = append(t.parts, rest[:open], name)
+		rest = rest[end+1:]
+	}
+
+	for _, segment := range strings.Split(strings.Join(t.literals(), "/"), "/") {
+		if segment == ".." {
+			return nil, fmt.Errorf("the destination template %s cannot go up out of the destination with ..", template)
+		}
+	}
+	if len(t.parts) == 1 {
+		return nil, fmt.Errorf("the destination template %s has no variables, so every source would go to the same destination", template)
+	}
+	return t, nil
+}
+
+func knownDestinationTemplateVariables() []string {
+	names := make([]string, 0, len(destinationTemplateVariables))
+	for name := range destinationTemplateVariables {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *destinationTemplate) literals() []string {
+	literals := make([]string, 0, len(t.parts)/2+1)
+	for i := 0; i < len(t.parts); i += 2 {
+		literals = append(literals, t.parts[i])
+	}
+	return literals
+}
+
+// expand is the path of the object under the destination, using the azcopy path separator.
+// The empty segments, such as that of an {ext} of a file without an extension, are dropped
+func (t *destinationTemplate) expand(object storedObject) string {
+	b := strings.Builder{}
+	for i, part := range t.parts {
+		if i%2 == 0 {
+			b.WriteString(part)
+		} else {
+			b.WriteString(destinationTemplateVariables[part](object))
+		}
+	}
+
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(b.String(), common.AZCOPY_PATH_SEPARATOR_STRING) {
+		if segment != "" && segment != "." {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, common.AZCOPY_PATH_SEPARATOR_STRING)
+}
+
+// claim notes that the source goes to the (escaped) destination path, and warns if another source already went there,
+// since only one of them can be left at the destination
+func (t *destinationTemplate) claim(destination, source string) {
+	if t == nil {
+		return
+	}
+
+	t.claimedLock.Lock()
+	previous, taken := t.claimed[destination]
+	if !taken {
+		t.claimed[destination] = source
+	}
+	t.claimedLock.Unlock()
+
+	if taken && previous != source {
+		LogStdoutAndJobLog(fmt.Sprintf("Both %s and %s go to %s with the destination template, so only one of them will be left there",
+			unescapedForDisplay(previous), unescapedForDisplay(source), unescapedForDisplay(destination)))
+	}
+}
+
+func unescapedForDisplay(p string) string {
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = unescaped
+	}
+	return strings.TrimPrefix(p, common.AZCOPY_PATH_SEPARATOR_STRING)
+}
@@ -0,0 +1,272 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// enumerationCacheVersion is the version of the format of the enumeration cache. A cache of any other version is not used
+const enumerationCacheVersion = 1
+
+// enumerationCache lets a copy that's run again and again, e.g. by a CI pipeline, skip the files that it copied already (--enumeration-cache).
+// The cache is the listing of the source that the last run copied, along with where each file went at the destination.
+// A file that's listed just as it was, and goes to the same place, is skipped outright, without the destination being checked at all.
+// The rest are scheduled, and checked against the destination, as they'd be without the cache.
+// Once the job is done, the listing of this run replaces the cache, atomically, but only if every transfer succeeded.
+// Otherwise the cache is deleted, so that the next run checks every file. A nil cache never skips anything.
+type enumerationCache struct {
+	path string
+	// whether the files that are unchanged since the last run are skipped. Unless they are (--no-cache-trust), the cache is only refreshed
+	trusted bool
+	// what the last run copied, or nil if the cache didn't exist, or can't be used
+	previous map[string]enumerationCacheEntry
+	// what this run lists, keyed by the path relative to the source
+	current map[string]enumerationCacheEntry
+	skipped uint32
+
+	fromTo      common.FromTo
+	source      string
+	destination string
+}
+
+// enumerationCacheFile is how the cache is saved. It's only used by a job between the same source and destination
+type enumerationCacheFile struct {
+	Version     int
+	FromTo      string
+	Source      string
+	Destination string
+	// keyed by the path relative to the source
+	Entries map[string]enumerationCacheEntry
+}
+
+// enumerationCacheEntry is a file of the source, as it was listed, and the path it went to, relative to the destination
+type enumerationCacheEntry struct {
+	Size             int64
+	LastModifiedTime time.Time
+	MD5              []byte `json:",omitempty"`
+	Destination      string
+}
+
+// unchangedSince tells whether the entry is the file that the previous entry was, gone to the same place.
+// A file whose listing has neither a last modified time nor a hash is never taken to be unchanged, since there's no telling
+func (e enumerationCacheEntry) unchangedSince(previous enumerationCacheEntry) bool {
+	if e.LastModifiedTime.IsZero() && len(e.MD5) == 0 {
+		return false
+	}
+	return e.Size == previous.Size && e.LastModifiedTime.Equal(previous.LastModifiedTime) && bytes.Equal(e.MD5, previous.MD5) &&
+		e.Destination == previous.Destination
+}
+
+// cookEnumerationCache reads the cache that the job is to use, if any. A cache that can't be used isn't an error:
+// the job then checks every file, as if there were none, and replaces it
+func cookEnumerationCache(raw rawCopyCmdArgs, cooked *cookedCopyCmdArgs) error {
+	if raw.enumerationCache == "" {
+		if raw.noCacheTrust {
+			return errors.New("no-cache-trust is only supported with enumeration-cache")
+		}
+		return nil
+	}
+	if cooked.isRedirection() {
+		return errors.New("enumeration-cache is not supported while piping")
+	}
+	if cooked.destination == common.Dev_Null {
+		return errors.New("enumeration-cache is not supported when the files are discarded, since nothing is copied")
+	}
+
+	// the SAS tokens change from one run to the next, so they're no part of what the cache is for
+	source, _, err := SplitAuthTokenFromResource(cooked.source, cooked.fromTo.From())
+	if err != nil {
+		return err
+	}
+	destination, _, err := SplitAuthTokenFromResource(cooked.destination, cooked.fromTo.To())
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(raw.enumerationCache)
+	if err != nil {
+		return err
+	}
+
+	cache := &enumerationCache{
+		path:        path,
+		trusted:     !raw.noCacheTrust,
+		current:     make(map[string]enumerationCacheEntry),
+		fromTo:      cooked.fromTo,
+		source:      source,
+		destination: destination,
+	}
+	if err = cache.load(); err != nil {
+		glcm.Info(fmt.Sprintf("The enumeration cache %s is not used, since %s. Every file is checked at the destination.", path, err))
+	}
+	cooked.enumerationCache = cache
+	return nil
+}
+
+// load reads what the last run copied, if the cache is of a job between the same source and destination
+func (c *enumerationCache) load() error {
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var file enumerationCacheFile
+	if err = json.Unmarshal(b, &file); err != nil {
+		return fmt.Errorf("it's malformed: %s", err)
+	}
+	switch {
+	case file.Version != enumerationCacheVersion:
+		return fmt.Errorf("it's of version %d, rather than %d", file.Version, enumerationCacheVersion)
+	case file.FromTo != c.fromTo.String() || file.Source != c.source || file.Destination != c.destination:
+		return fmt.Errorf("it's of a copy from %s to %s", file.Source, file.Destination)
+	}
+	c.previous = file.Entries
+	return nil
+}
+
+// skip records the object in the listing of this run, and tells whether it's to be skipped,
+// since the last run copied it to the same place, as it is now
+func (c *enumerationCache) skip(object storedObject, srcRelPath, dstRelPath string) bool {
+	if c == nil {
+		return false
+	}
+
+	entry := enumerationCacheEntry{Size: object.size, LastModifiedTime: object.lastModifiedTime, MD5: object.md5, Destination: dstRelPath}
+	c.current[srcRelPath] = entry
+	previous, ok := c.previous[srcRelPath]
+	if !c.trusted || !ok || !entry.unchangedSince(previous) {
+		return false
+	}
+	c.skipped++
+	return true
+}
+
+// skippedEverything tells whether each file that was listed was skipped, which is why there's no job
+func (c *enumerationCache) skippedEverything() bool {
+	return c != nil && c.skipped > 0 && int(c.skipped) == len(c.current)
+}
+
+// enumerationCacheSucceeded tells whether the job did all it was to do, so that the listing it copied can be trusted by the next run.
+// Any failure may have left a file at the destination that's not what the source has, so it's not
+func enumerationCacheSucceeded(summary common.ListJobSummaryResponse) bool {
+	return (summary.JobStatus == common.EJobStatus.Completed() || summary.JobStatus == common.EJobStatus.CompletedWithSkipped()) &&
+		summary.TransfersFailed == 0 && summary.PathsNotEnumerated == 0 && summary.ChecksumEntriesNotFound == 0
+}
+
+// save replaces the cache with the listing of this run once the job is done, if the job succeeded,
+// or deletes it otherwise, so that the next run checks every file at the destination again
+func (c *enumerationCache) save(summary *common.ListJobSummaryResponse) {
+	if c == nil {
+		return
+	}
+
+	summary.TransfersSkippedByEnumerationCache = c.skipped
+	if !enumerationCacheSucceeded(*summary) {
+		summary.EnumerationCacheDiscarded = true
+		if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+			glcm.Info(fmt.Sprintf("Failed to delete the enumeration cache %s: %s", c.path, err))
+		}
+		return
+	}
+	if err := c.write(); err != nil {
+		glcm.Info(fmt.Sprintf("Failed to save the enumeration cache %s: %s", c.path, err))
+	}
+}
+
+// write saves the listing of this run as the cache. It's written to a temporary file that's then moved into place,
+// so that a run that's interrupted leaves either the old cache or the new one, never a part of it
+func (c *enumerationCache) write() error {
+	b, err := json.Marshal(enumerationCacheFile{
+		Version:     enumerationCacheVersion,
+		FromTo:      c.fromTo.String(),
+		Source:      c.source,
+		Destination: c.destination,
+		Entries:     c.current,
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(c.path)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(dir, filepath.Base(c.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name()) // fails harmlessly once it's moved
+
+	if _, err = temp.Write(b); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), c.path)
+}
+
+// upToDate refreshes the cache of a copy that created no job, since every file was skipped as unchanged, and returns its output.
+// The files that are gone from the source are gone from the listing of this run, so the cache is written all the same
+func (c *enumerationCache) upToDate() func(format common.OutputFormat) string {
+	if err := c.write(); err != nil {
+		glcm.Info(fmt.Sprintf("Failed to save the enumeration cache %s: %s", c.path, err))
+	}
+
+	return func(format common.OutputFormat) string {
+		if format == common.EOutputFormat.Json() {
+			jsonOutput, err := json.Marshal(common.ListJobSummaryResponse{
+				JobStatus:                          common.EJobStatus.Completed(),
+				TransfersSkippedByEnumerationCache: c.skipped,
+			})
+			common.PanicIfErr(err)
+			return string(jsonOutput)
+		}
+		return fmt.Sprintf("\nNo job was created, since all %v files are unchanged since the last run, according to the enumeration cache %s\n", c.skipped, c.path)
+	}
+}
+
+func formatEnumerationCache(summary common.ListJobSummaryResponse) string {
+	s := ""
+	if summary.TransfersSkippedByEnumerationCache > 0 {
+		s = fmt.Sprintf("\n\nFiles Skipped As Unchanged Since The Last Run (Enumeration Cache): %v", summary.TransfersSkippedByEnumerationCache)
+	}
+	if summary.EnumerationCacheDiscarded {
+		s += "\n\nThe enumeration cache was deleted, since the job did not succeed entirely. The next run checks every file at the destination."
+	}
+	return s
+}
@@ -0,0 +1,185 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// enumerationFailureTracker collects the paths that the traversers could not enumerate, when the user asked
+// for the enumeration to carry on past them (--continue-on-enumeration-errors).
+// A nil tracker is valid: it keeps nothing, and the first enumeration error aborts the enumeration, as it always has.
+type enumerationFailureTracker struct {
+	lock     sync.Mutex
+	failures []enumerationFailure
+}
+
+type enumerationFailure struct {
+	// what is shown to the user, e.g. the local path, or the URL without its signature
+	displayPath string
+	// relative to the root of the enumeration, using the azcopy path separator. Empty if nothing under the root could be enumerated
+	relativePath string
+	err          error
+}
+
+func newEnumerationFailureTracker() *enumerationFailureTracker {
+	return &enumerationFailureTracker{}
+}
+
+// record notes that nothing at or under relativePath could be enumerated.
+// It returns the error the traverser must stop with, which is nil if the traverser should keep walking the siblings of the path.
+func (t *enumerationFailureTracker) record(displayPath, relativePath string, err error) error {
+	if t == nil {
+		return err
+	}
+
+	LogStdoutAndJobLog(fmt.Sprintf("Skipping %s, as it could not be enumerated: %s", displayPath, err))
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.failures = append(t.failures, enumerationFailure{
+		displayPath:  displayPath,
+		relativePath: strings.Trim(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING),
+		err:          err,
+	})
+	return nil
+}
+
+// count is the number of paths that couldn't be enumerated
+func (t *enumerationFailureTracker) count() int {
+	if t == nil {
+		return 0
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.failures)
+}
+
+// wasNotEnumerated tells whether the object at relativePath lies in a part of the tree that couldn't be enumerated,
+// in which case nothing can be concluded from the object being absent.
+func (t *enumerationFailureTracker) wasNotEnumerated(relativePath string) bool {
+	if t == nil {
+		return false
+	}
+
+	relativePath = strings.Trim(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, f := range t.failures {
+		if f.relativePath == "" || relativePath == f.relativePath ||
+			strings.HasPrefix(relativePath, f.relativePath+common.AZCOPY_PATH_SEPARATOR_STRING) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipNotEnumerated wraps a processor of the objects that are missing from the enumerated side,
+// so that it never sees those which were only missing because their part of the tree couldn't be enumerated.
+// This is what keeps sync from deleting destination files whose source directory couldn't be read.
+func (t *enumerationFailureTracker) skipNotEnumerated(processor objectProcessor) objectProcessor {
+	if t == nil {
+		return processor
+	}
+
+	return func(object storedObject) error {
+		if t.wasNotEnumerated(object.relativePath) {
+			return nil
+		}
+		return processor(object)
+	}
+}
+
+// writeReport writes every path that couldn't be enumerated, one per line with its error, to the given file
+func (t *enumerationFailureTracker) writeReport(f *os.File) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, failure := range t.failures {
+		if _, err := fmt.Fprintf(f, "%s: %s\n", failure.displayPath, failure.err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enumerationFailuresReportPath is where the paths that a job couldn't enumerate are listed.
+// It's next to the job's log and named like it, so that it's cleaned up with it.
+func enumerationFailuresReportPath(jobID common.JobID) string {
+	return filepath.Join(azcopyLogPathFolder, jobID.String()+"-not-enumerated.log")
+}
+
+// reportEnumerationFailures writes the report of the paths that the trackers couldn't enumerate (if there are any),
+// and reflects them in the summary of the finished job, which then counts as completed with errors.
+func reportEnumerationFailures(summary *common.ListJobSummaryResponse, trackers ...*enumerationFailureTracker) {
+	total := 0
+	for _, t := range trackers {
+		total += t.count()
+	}
+	if total == 0 {
+		return
+	}
+
+	summary.PathsNotEnumerated = uint32(total)
+	switch summary.JobStatus {
+	case common.EJobStatus.Completed():
+		summary.JobStatus = common.EJobStatus.CompletedWithErrors()
+	case common.EJobStatus.CompletedWithSkipped():
+		summary.JobStatus = common.EJobStatus.CompletedWithErrorsAndSkipped()
+	}
+
+	reportPath := enumerationFailuresReportPath(summary.JobID)
+	f, err := os.Create(reportPath)
+	if err != nil {
+		glcm.Info(fmt.Sprintf("Failed to write the paths that could not be enumerated to %s: %s", reportPath, err))
+		return
+	}
+	defer f.Close()
+
+	for _, t := range trackers {
+		if t.count() == 0 {
+			continue
+		}
+		if err = t.writeReport(f); err != nil {
+			glcm.Info(fmt.Sprintf("Failed to write the paths that could not be enumerated to %s: %s", reportPath, err))
+			return
+		}
+	}
+	summary.PathsNotEnumeratedReport = reportPath
+}
+
+func formatPathsNotEnumerated(summary common.ListJobSummaryResponse) string {
+	if summary.PathsNotEnumerated == 0 {
+		return ""
+	}
+	if summary.PathsNotEnumeratedReport == "" {
+		return fmt.Sprintf("\n\n%v paths could not be enumerated, so nothing in them was transferred. They are listed in the log", summary.PathsNotEnumerated)
+	}
+	return fmt.Sprintf("\n\n%v paths could not be enumerated, so nothing in them was transferred. They are listed in %s",
+		summary.PathsNotEnumerated, summary.PathsNotEnumeratedReport)
+}
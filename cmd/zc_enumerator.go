@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -67,6 +68,8 @@ type storedObject struct {
 	// example: rootDir=/var/a/b/c/d/e/f.pdf fullPath=/var/a/b/c/d/e/f.pdf => relativePath=""
 	// in this case, since rootDir already points to the file, relatively speaking the path is nothing.
 	relativePath string
+	// the path relative to the destination, when it isn't relativePath, e.g. since the name was sanitized for the destination
+	dstRelativePath string
 	// container source, only included by account traversers.
 	containerName string
 	// destination container name. Included in the processor after resolving container names.
@@ -75,12 +78,41 @@ type storedObject struct {
 	blobAccessTier azblob.AccessTierType
 	// metadata, included in S2S transfers
 	Metadata common.Metadata
+	// the last modified time that the object keeps in its metadata, which sync compares instead of its own when asked to (--compare-with-preserved-lmt).
+	// Zero if it keeps none, or sync wasn't asked to
+	preservedLastModifiedTime time.Time
 }
 
 const (
 	blobTypeNA = azblob.BlobNone // some things, e.g. local files, aren't blobs so they don't have their own blob type so we use this "not applicable" constant
 )
 
+// destinationRelativePath is the path of the object relative to the destination
+func (s *storedObject) destinationRelativePath() string {
+	if s.dstRelativePath != "" {
+		return s.dstRelativePath
+	}
+	return s.relativePath
+}
+
+// comparedLastModifiedTime is the last modified time that sync compares the object by
+func (s *storedObject) comparedLastModifiedTime() time.Time {
+	if !s.preservedLastModifiedTime.IsZero() {
+		return s.preservedLastModifiedTime
+	}
+	return s.lastModifiedTime
+}
+
+// atDestination returns the object as it's named at the destination, with its path relative to the destination as its relative path
+func (s storedObject) atDestination() storedObject {
+	if s.dstRelativePath != "" {
+		s.relativePath = s.dstRelativePath
+		s.name = path.Base(s.dstRelativePath)
+		s.dstRelativePath = ""
+	}
+	return s
+}
+
 func (s *storedObject) isMoreRecentThan(storedObject2 storedObject) bool {
 	return s.lastModifiedTime.After(storedObject2.lastModifiedTime)
 }
@@ -191,7 +223,8 @@ const httpsRecommendedNotice = "NOTE: HTTP is in use for one or more location(s)
 var httpsRecommendationOnce sync.Once
 
 func recommendHttpsIfNecessary(url url.URL) {
-	if strings.EqualFold(url.Scheme, "http") {
+	// emulators that run on this machine are normally HTTP only, and nothing leaves the machine anyway
+	if strings.EqualFold(url.Scheme, "http") && !common.IsEmulatorURL(url) {
 		httpsRecommendationOnce.Do(func() {
 			glcm.Info(httpsRecommendedNotice)
 		})
@@ -202,7 +235,8 @@ func recommendHttpsIfNecessary(url url.URL) {
 // ctx, pipeline are only required for remote resources.
 // followSymlinks is only required for local resources (defaults to false)
 // errorOnDirWOutRecursive is used by copy.
-func initResourceTraverser(resource string, location common.Location, ctx *context.Context, credential *common.CredentialInfo, followSymlinks *bool, listofFilesChannel chan string, recursive, getProperties bool, incrementEnumerationCounter func()) (resourceTraverser, error) {
+// enumerationFailures is only given when the paths that can't be enumerated should be skipped rather than end the enumeration.
+func initResourceTraverser(resource string, location common.Location, ctx *context.Context, credential *common.CredentialInfo, followSymlinks *bool, listofFilesChannel chan listOfFilesEntry, recursive, getProperties bool, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) (resourceTraverser, error) {
 	var output resourceTraverser
 	var p *pipeline.Pipeline
 
@@ -247,7 +281,7 @@ func initResourceTraverser(resource string, location common.Location, ctx *conte
 			}
 		}
 
-		output = newListTraverser(resource, sas, location, credential, ctx, recursive, toFollow, getProperties, listofFilesChannel, incrementEnumerationCounter)
+		output = newListTraverser(resource, sas, location, credential, ctx, recursive, toFollow, getProperties, listofFilesChannel, incrementEnumerationCounter, enumerationFailures, limits)
 		return output, nil
 	}
 
@@ -264,18 +298,18 @@ func initResourceTraverser(resource string, location common.Location, ctx *conte
 				return nil, fmt.Errorf("failed to glob: %s", err)
 			}
 
-			globChan := make(chan string)
+			globChan := make(chan listOfFilesEntry)
 
 			go func() {
 				defer close(globChan)
 				for _, v := range matches {
-					globChan <- strings.TrimPrefix(v, basePath)
+					globChan <- listOfFilesEntry{Source: strings.TrimPrefix(v, basePath)}
 				}
 			}()
 
-			output = newListTraverser(cleanLocalPath(basePath), "", location, nil, nil, recursive, toFollow, getProperties, globChan, incrementEnumerationCounter)
+			output = newListTraverser(cleanLocalPath(basePath), "", location, nil, nil, recursive, toFollow, getProperties, globChan, incrementEnumerationCounter, enumerationFailures, limits)
 		} else {
-			output = newLocalTraverser(resource, recursive, toFollow, incrementEnumerationCounter)
+			output = newLocalTraverser(resource, recursive, toFollow, incrementEnumerationCounter, enumerationFailures, limits)
 		}
 	case common.ELocation.Benchmark():
 		ben, err := newBenchmarkTraverser(resource, incrementEnumerationCounter)
@@ -304,9 +338,9 @@ func initResourceTraverser(resource string, location common.Location, ctx *conte
 				return nil, errors.New(accountTraversalInherentlyRecursiveError)
 			}
 
-			output = newBlobAccountTraverser(resourceURL, *p, *ctx, incrementEnumerationCounter)
+			output = newBlobAccountTraverser(resourceURL, *p, *ctx, incrementEnumerationCounter, enumerationFailures, limits)
 		} else {
-			output = newBlobTraverser(resourceURL, *p, *ctx, recursive, incrementEnumerationCounter)
+			output = newBlobTraverser(resourceURL, *p, *ctx, recursive, incrementEnumerationCounter, enumerationFailures, limits)
 		}
 	case common.ELocation.File():
 		resourceURL, err := url.Parse(resource)
@@ -327,9 +361,9 @@ func initResourceTraverser(resource string, location common.Location, ctx *conte
 				return nil, errors.New(accountTraversalInherentlyRecursiveError)
 			}
 
-			output = newFileAccountTraverser(resourceURL, *p, *ctx, getProperties, incrementEnumerationCounter)
+			output = newFileAccountTraverser(resourceURL, *p, *ctx, getProperties, incrementEnumerationCounter, enumerationFailures, limits)
 		} else {
-			output = newFileTraverser(resourceURL, *p, *ctx, recursive, getProperties, incrementEnumerationCounter)
+			output = newFileTraverser(resourceURL, *p, *ctx, recursive, getProperties, incrementEnumerationCounter, enumerationFailures, limits)
 		}
 	case common.ELocation.BlobFS():
 		resourceURL, err := url.Parse(resource)
@@ -354,9 +388,9 @@ func initResourceTraverser(resource string, location common.Location, ctx *conte
 				return nil, errors.New(accountTraversalInherentlyRecursiveError)
 			}
 
-			output = newBlobFSAccountTraverser(resourceURL, *p, *ctx, incrementEnumerationCounter)
+			output = newBlobFSAccountTraverser(resourceURL, *p, *ctx, incrementEnumerationCounter, enumerationFailures, limits)
 		} else {
-			output = newBlobFSTraverser(resourceURL, *p, *ctx, recursive, incrementEnumerationCounter)
+			output = newBlobFSTraverser(resourceURL, *p, *ctx, recursive, incrementEnumerationCounter, enumerationFailures, limits)
 		}
 	case common.ELocation.S3():
 		resourceURL, err := url.Parse(resource)
@@ -0,0 +1,83 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// existingDestinations holds what the listing of a blob destination found there, so that, with overwrite disabled,
+// the transfers to the blobs that already exist are skipped in bulk, rather than each of them checking the destination
+// with a request of its own (which, for a container that was mostly copied already, is most of the job).
+// A nil index covers nothing: every transfer then checks the destination itself, as it always has.
+type existingDestinations struct {
+	index *objectIndexer
+}
+
+// listExistingDestinations lists everything under the destination, when the job is one that can use it.
+// If the destination can't be listed (e.g. the SAS doesn't allow it), the job goes on without the index.
+func (cca *cookedCopyCmdArgs) listExistingDestinations(ctx context.Context, dst string, isSourceDir bool, srcLevel, dstLevel LocationLevel) *existingDestinations {
+	// a single file gains nothing from a listing, and when either side is a whole account, or the destination is sharded,
+	// the destination is many containers
+	if cca.forceWrite != common.EOverwriteOption.False() || cca.fromTo.To() != common.ELocation.Blob() || cca.destinationShards != nil ||
+		!isSourceDir || srcLevel == ELocationLevel.Service() || dstLevel == ELocationLevel.Service() {
+		return nil
+	}
+
+	dstCredInfo, _, err := getCredentialInfoForLocation(ctx, cca.fromTo.To(), cca.destination, cca.destinationSAS, false)
+	if err != nil {
+		glcm.Info(fmt.Sprintf("Each file will be checked at the destination, since it could not be listed: %s", err))
+		return nil
+	}
+	traverser, err := initResourceTraverser(dst, cca.fromTo.To(), &ctx, &dstCredInfo, nil, nil, true, false, func() {}, nil, nil)
+	if err != nil {
+		glcm.Info(fmt.Sprintf("Each file will be checked at the destination, since it could not be listed: %s", err))
+		return nil
+	}
+
+	index := newObjectIndexer()
+	if err = traverser.traverse(noPreProccessor, index.store, nil); err != nil {
+		glcm.Info(fmt.Sprintf("Each file will be checked at the destination, since it could not be listed: %s", err))
+		return nil
+	}
+	cca.transactions.listedDestination(uint64(index.counter))
+	LogStdoutAndJobLog(fmt.Sprintf("Listed %v files at the destination. Those that are already there will be skipped.", index.counter))
+	return &existingDestinations{index: index}
+}
+
+// contains tells whether the listing found the destination of a transfer, given its escaped path relative to the destination
+func (e *existingDestinations) contains(dstRelPath string) bool {
+	if e == nil {
+		return false
+	}
+
+	relativePath, err := url.PathUnescape(strings.TrimPrefix(dstRelPath, common.AZCOPY_PATH_SEPARATOR_STRING))
+	if err != nil || relativePath == "" {
+		return false // it can't be looked up, so it's checked by its transfer
+	}
+	_, found := e.index.indexMap[relativePath]
+	return found
+}
@@ -0,0 +1,247 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// the sliding window over which --fail-fast-rate is measured
+const failFastWindow = time.Minute
+
+// how many storage error codes the summary of a job lists its failures under. The rest are counted together
+const errorCodesShown = 5
+
+// a percentage of failures says little about a handful of transfers, so it is only acted on
+// once at least this many transfers finished in the window
+const failFastMinimumSample = 50
+
+// failFastPolicy aborts a job once too many of its transfers fail (--fail-fast-threshold and --fail-fast-rate),
+// rather than let it fail every one of them, e.g. when the SAS is wrong or the container doesn't exist.
+// It's checked each time the progress of the job is reported. A nil policy never aborts anything.
+type failFastPolicy struct {
+	// abort once more than this many transfers failed in total. Zero if not set
+	threshold uint32
+	// abort once more than this many transfers failed in the window. Zero if not set
+	perMinute float64
+	// abort once more than this percentage of the transfers that finished in the window failed. Zero if not set
+	percent float64
+
+	samples            []failFastSample
+	firstFailuresShown int
+	// why the job was aborted, or empty if it wasn't
+	reason string
+}
+
+type failFastSample struct {
+	at       time.Time
+	failed   uint32
+	finished uint32
+}
+
+// newFailFastPolicy returns nil when neither limit is set
+func newFailFastPolicy(threshold uint32, rate string) (*failFastPolicy, error) {
+	perMinute, percent, err := parseFailFastRate(rate)
+	if err != nil {
+		return nil, err
+	}
+	if threshold == 0 && perMinute == 0 && percent == 0 {
+		return nil, nil
+	}
+	return &failFastPolicy{threshold: threshold, perMinute: perMinute, percent: percent}, nil
+}
+
+// parseFailFastRate accepts either failures per minute, e.g. 100/min, or a percentage of the finished transfers, e.g. 50%
+func parseFailFastRate(rate string) (perMinute float64, percent float64, err error) {
+	rate = strings.TrimSpace(rate)
+	switch {
+	case rate == "":
+		return 0, 0, nil
+	case strings.HasSuffix(rate, "%"):
+		percent, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(rate, "%")), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, 0, fmt.Errorf("invalid fail-fast-rate '%s': a percentage must be more than 0%% and at most 100%%", rate)
+		}
+	case strings.HasSuffix(rate, "/min"):
+		perMinute, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(rate, "/min")), 64)
+		if err != nil || perMinute <= 0 {
+			return 0, 0, fmt.Errorf("invalid fail-fast-rate '%s': the number of failures per minute must be more than 0", rate)
+		}
+	default:
+		return 0, 0, fmt.Errorf("invalid fail-fast-rate '%s': it must be a number of failures per minute, e.g. 100/min, "+
+			"or a percentage of the transfers, e.g. 50%%", rate)
+	}
+	return perMinute, percent, nil
+}
+
+// newRound returns a policy with the same limits, for another job
+func (p *failFastPolicy) newRound() *failFastPolicy {
+	if p == nil {
+		return nil
+	}
+	return &failFastPolicy{threshold: p.threshold, perMinute: p.perMinute, percent: p.percent}
+}
+
+// exceeded returns why the job must be aborted, given its latest summary, or an empty string if it can carry on
+func (p *failFastPolicy) exceeded(summary common.ListJobSummaryResponse, now time.Time) string {
+	if p == nil {
+		return ""
+	}
+
+	latest := failFastSample{at: now, failed: summary.TransfersFailed, finished: summary.TransfersFailed + summary.TransfersCompleted}
+	if len(p.samples) == 0 {
+		p.samples = append(p.samples, failFastSample{at: now})
+	}
+	p.samples = append(p.samples, latest)
+	// keep the latest sample that is at least as old as the window, as the start of the window
+	for len(p.samples) > 2 && !p.samples[1].at.After(now.Add(-failFastWindow)) {
+		p.samples = p.samples[1:]
+	}
+	start := p.samples[0]
+	failed, finished := latest.failed-start.failed, latest.finished-start.finished
+
+	switch {
+	case p.threshold > 0 && summary.TransfersFailed > p.threshold:
+		return fmt.Sprintf("%v transfers failed, which is more than the fail-fast threshold of %v", summary.TransfersFailed, p.threshold)
+	case p.perMinute > 0 && float64(failed) > p.perMinute:
+		return fmt.Sprintf("%v transfers failed within a minute, which is more than the fail-fast rate of %v per minute", failed, p.perMinute)
+	case p.percent > 0 && finished >= failFastMinimumSample && float64(failed)*100 > p.percent*float64(finished):
+		return fmt.Sprintf("%v of the %v transfers that finished within a minute failed, which is more than the fail-fast rate of %v%%",
+			failed, finished, p.percent)
+	}
+	return ""
+}
+
+// check shows the first failures of the job as they come in, so that their cause is obvious straight away,
+// and cancels the job once too many of its transfers failed
+func (p *failFastPolicy) check(jobID common.JobID, summary common.ListJobSummaryResponse) {
+	if p == nil || p.reason != "" {
+		return
+	}
+
+	for ; p.firstFailuresShown < len(summary.FirstTransferFailures); p.firstFailuresShown++ {
+		glcm.Info("Transfer failed: " + summary.FirstTransferFailures[p.firstFailuresShown])
+	}
+
+	reason := p.exceeded(summary, time.Now())
+	if reason == "" {
+		return
+	}
+	p.reason = reason
+
+	message := fmt.Sprintf("Aborting the job, since %s.", reason)
+	if summary.DominantTransferFailure != "" {
+		message += fmt.Sprintf(" Most of them (%v) failed with: %s", summary.DominantTransferFailureCount, summary.DominantTransferFailure)
+	}
+	LogStdoutAndJobLog(message)
+	if err := (cookedCancelCmdArgs{jobID: jobID}).process(); err != nil {
+		glcm.Info("Failed to abort the job " + jobID.String() + ": " + err.Error())
+	}
+}
+
+// reportAbort reflects in the summary of the finished job that it was aborted by the policy (if it was):
+// the transfers it didn't get to were not attempted, and the job counts as completed with errors, rather than cancelled.
+func (p *failFastPolicy) reportAbort(summary *common.ListJobSummaryResponse) {
+	if p == nil || p.reason == "" {
+		return
+	}
+	reportJobAborted(summary, p.reason)
+}
+
+// reportJobAborted reflects in the summary of the finished job that the front end aborted it, and why
+func reportJobAborted(summary *common.ListJobSummaryResponse, reason string) {
+	summary.FailFastReason = reason
+	finished := summary.TransfersCompleted + summary.TransfersFailed + summary.TransfersSkipped
+	if summary.TotalTransfers > finished {
+		summary.TransfersNotAttempted = summary.TotalTransfers - finished
+	}
+	if summary.JobStatus == common.EJobStatus.Cancelled() {
+		summary.JobStatus = common.EJobStatus.CompletedWithErrors()
+		if summary.TransfersSkipped > 0 {
+			summary.JobStatus = common.EJobStatus.CompletedWithErrorsAndSkipped()
+		}
+	}
+}
+
+func formatFailFastAbort(summary common.ListJobSummaryResponse) string {
+	if summary.FailFastReason == "" {
+		return ""
+	}
+
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("\n\nThe job was aborted, since %s.\nNumber of Transfers Not Attempted (job aborted): %v",
+		summary.FailFastReason, summary.TransfersNotAttempted))
+	if summary.DominantTransferFailure != "" {
+		b.WriteString(fmt.Sprintf("\nMost Frequent Error (%v transfers): %s", summary.DominantTransferFailureCount, summary.DominantTransferFailure))
+	}
+	if len(summary.FirstTransferFailures) > 0 {
+		b.WriteString("\nFirst Failures:")
+		for _, f := range summary.FirstTransferFailures {
+			b.WriteString("\n  " + f)
+		}
+	}
+	return b.String()
+}
+
+// formatFailuresByErrorCode lists the storage error codes that most of the transfers failed with, with one failed path for each,
+// so that the causes of the failures can be told apart without going through the log
+func formatFailuresByErrorCode(summary common.ListJobSummaryResponse) string {
+	if len(summary.FailuresByErrorCode) == 0 {
+		return ""
+	}
+
+	codes := make([]string, 0, len(summary.FailuresByErrorCode))
+	for code := range summary.FailuresByErrorCode {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, b := summary.FailuresByErrorCode[codes[i]], summary.FailuresByErrorCode[codes[j]]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return codes[i] < codes[j]
+	})
+
+	b := strings.Builder{}
+	b.WriteString("\n\nFailures by Error Code:")
+	for i, code := range codes {
+		if i == errorCodesShown {
+			others := uint32(0)
+			for _, c := range codes[i:] {
+				others += summary.FailuresByErrorCode[c].Count
+			}
+			b.WriteString(fmt.Sprintf("\n  %v more codes: %v", len(codes)-i, others))
+			break
+		}
+		failures := summary.FailuresByErrorCode[code]
+		if failures.Status != 0 {
+			code = fmt.Sprintf("%d %s", failures.Status, code)
+		}
+		b.WriteString(fmt.Sprintf("\n  %s: %v (e.g. %s)", code, failures.Count, failures.ExamplePath))
+	}
+	return b.String()
+}
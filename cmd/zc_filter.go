@@ -21,7 +21,9 @@
 package cmd
 
 import (
+	"fmt"
 	"path"
+	"runtime"
 	"strings"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
@@ -100,6 +102,108 @@ func buildExcludeFilters(patterns []string, targetPath bool) []objectFilter {
 	return filters
 }
 
+// pathPatternFilter matches the whole relative path of an object against patterns (--include-path-pattern and --exclude-path-pattern),
+// rather than only its name. A ** matches any number of directories, including none, and the other wildcards match within one name,
+// as they do in the name patterns. Like the include patterns, the include path patterns are ORed, and like the exclude patterns,
+// each exclude path pattern rejects what it matches, whatever the include filters say.
+type pathPatternFilter struct {
+	patterns []string
+	include  bool
+}
+
+func (f *pathPatternFilter) doesSupportThisOS() (msg string, supported bool) {
+	return "", true
+}
+
+func (f *pathPatternFilter) doesPass(storedObject storedObject) bool {
+	if len(f.patterns) == 0 {
+		return true
+	}
+
+	relativePath := storedObject.relativePath
+	if relativePath == "" {
+		relativePath = storedObject.name // the source is the file itself
+	}
+	if runtime.GOOS == "windows" {
+		relativePath = strings.ReplaceAll(relativePath, `\`, common.AZCOPY_PATH_SEPARATOR_STRING)
+	}
+	relativePath = strings.Trim(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+
+	for _, pattern := range f.patterns {
+		if matchPathPattern(pattern, relativePath) {
+			return f.include
+		}
+	}
+	return !f.include
+}
+
+// buildPathPatternFilters returns the filters of the include and exclude path patterns, which must have been normalized
+func buildPathPatternFilters(includePatterns, excludePatterns []string) []objectFilter {
+	filters := make([]objectFilter, 0)
+	if len(includePatterns) > 0 {
+		filters = append(filters, &pathPatternFilter{patterns: includePatterns, include: true})
+	}
+	if len(excludePatterns) > 0 {
+		filters = append(filters, &pathPatternFilter{patterns: excludePatterns})
+	}
+	return filters
+}
+
+// normalizePathPatterns checks the path patterns, and makes them use the azcopy path separator.
+// On Windows, that includes the backslashes, which elsewhere escape the wildcards.
+func normalizePathPatterns(flagName string, patterns []string) ([]string, error) {
+	normalized := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if runtime.GOOS == "windows" {
+			pattern = strings.ReplaceAll(pattern, `\`, common.AZCOPY_PATH_SEPARATOR_STRING)
+		}
+		pattern = strings.Trim(pattern, common.AZCOPY_PATH_SEPARATOR_STRING)
+		if pattern == "" {
+			continue
+		}
+		for _, segment := range strings.Split(pattern, common.AZCOPY_PATH_SEPARATOR_STRING) {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid %s '%s': %s", flagName, pattern, err)
+			}
+		}
+		normalized = append(normalized, pattern)
+	}
+	return normalized, nil
+}
+
+// matchPathPattern tells whether the relative path (using the azcopy path separator) matches the path pattern
+func matchPathPattern(pattern, relativePath string) bool {
+	return matchPathSegments(strings.Split(pattern, common.AZCOPY_PATH_SEPARATOR_STRING), strings.Split(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING))
+}
+
+func matchPathSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range segments {
+				if matchPathSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], segments[0]); err != nil || !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
 // design explanation:
 // include filters are different from the exclude ones, which work together in the "AND" manner
 // meaning and if an storedObject is rejected by any of the exclude filters, then it is rejected by all of them
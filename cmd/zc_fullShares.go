@@ -0,0 +1,87 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// fullSharesReport tells the user about each destination Azure file share that the job finds full, once,
+// as the progress of the job is reported. The zero value is ready to use
+type fullSharesReport struct {
+	told map[string]bool
+}
+
+// check tells the user about the shares that were found full since it was last called,
+// and what becomes of the transfers to them, given how long they wait for room (--wait-on-share-full)
+func (r *fullSharesReport) check(jobID common.JobID, summary common.ListJobSummaryResponse, wait time.Duration) {
+	for _, share := range summary.FullShares {
+		if r.told[share.Share] {
+			continue
+		}
+		if r.told == nil {
+			r.told = make(map[string]bool)
+		}
+		r.told[share.Share] = true
+
+		if wait > 0 {
+			LogStdoutAndJobLog(fmt.Sprintf("Share full: %s. The transfers to it wait for up to %v for its quota to be raised, "+
+				"or for room to be freed in it, before they fail.", share, wait))
+			continue
+		}
+		LogStdoutAndJobLog(fmt.Sprintf("Share full: %s. The transfers to it fail with the status ShareFull. Once its quota is raised, "+
+			"'azcopy jobs resume %s' transfers them, without copying the completed files again.", share, jobID))
+	}
+	for name := range r.told {
+		if !containsFullShare(summary.FullShares, name) {
+			delete(r.told, name) // it had room again, so the user is told if it fills up once more
+		}
+	}
+}
+
+func containsFullShare(shares []common.FullShare, name string) bool {
+	for _, s := range shares {
+		if s.Share == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatFullShares(summary common.ListJobSummaryResponse) string {
+	if len(summary.FullShares) == 0 {
+		return ""
+	}
+	described := make([]string, len(summary.FullShares))
+	for i, s := range summary.FullShares {
+		described[i] = s.String()
+	}
+	it, its := "it", "its quota is"
+	if len(described) > 1 {
+		it, its = "them", "their quotas are"
+	}
+	return fmt.Sprintf("\n\nShare full: %s. The transfers to %s that failed have the status ShareFull. Once %s raised, "+
+		"'azcopy jobs resume %s' transfers them, without copying the completed files again", strings.Join(described, "; "), it, its, summary.JobID)
+}
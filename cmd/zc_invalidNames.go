@@ -0,0 +1,232 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// invalidNameHandler deals with the objects whose names the destination doesn't allow (--invalid-name-handling),
+// e.g. blobs with a backslash, a control character or a trailing dot, copied to Azure Files or to Windows.
+// They're found when the job is enumerated, and either left out of it, or given a sanitized name at the destination.
+// A sanitized name has each character that isn't allowed, and each '%', of the parts of its path that had such characters,
+// replaced with '%' and its two hexadecimal digits, so that url.PathUnescape turns those parts back into the originals.
+// Since a part of a path that was already valid is left as it is, the pairs of names are also written to a mapping file.
+// A nil handler leaves every name as it is: the transfers of the names that the destination refuses then fail, as they always have.
+type invalidNameHandler struct {
+	handling common.InvalidNameHandling
+	jobID    common.JobID
+
+	// the characters that the destination doesn't allow in a name, besides the control characters,
+	// and those that a name can't end with
+	invalidChars         string
+	invalidTrailingChars string
+
+	lock        sync.Mutex
+	skipped     uint32
+	sanitized   uint32
+	mappingPath string
+	mapping     *os.File
+}
+
+// newInvalidNameHandler returns nil when the names are left as they are, or when the destination takes any name that the source may have
+func newInvalidNameHandler(handling common.InvalidNameHandling, fromTo common.FromTo, jobID common.JobID) *invalidNameHandler {
+	if handling == common.EInvalidNameHandling.Fail() {
+		return nil
+	}
+
+	h := &invalidNameHandler{handling: handling, jobID: jobID, invalidChars: `"\:|<>*?`}
+	switch {
+	case fromTo.To() == common.ELocation.File():
+		h.invalidTrailingChars = "."
+	case fromTo.To() == common.ELocation.Local() && runtime.GOOS == "windows":
+		h.invalidTrailingChars = ". "
+	default:
+		return nil
+	}
+	if fromTo.From() == common.ELocation.Local() && runtime.GOOS == "windows" {
+		// a backslash in the path of a local Windows source is a separator, not a part of a name
+		h.invalidChars = strings.Replace(h.invalidChars, `\`, "", 1)
+	}
+	return h
+}
+
+// cookInvalidNameHandling parses --invalid-name-handling, which is fail if it's not given
+func cookInvalidNameHandling(raw string, fromTo common.FromTo, jobID common.JobID) (*invalidNameHandler, error) {
+	handling := common.EInvalidNameHandling.Fail()
+	if raw != "" {
+		if err := handling.Parse(raw); err != nil {
+			return nil, fmt.Errorf("invalid invalid-name-handling '%s': it must be fail, skip or sanitize", raw)
+		}
+	}
+	return newInvalidNameHandler(handling, fromTo, jobID), nil
+}
+
+func (h *invalidNameHandler) isInvalid(r rune, last bool) bool {
+	return r < 0x20 || strings.ContainsRune(h.invalidChars, r) || (last && strings.ContainsRune(h.invalidTrailingChars, r))
+}
+
+// sanitize returns the part of a path as it's named at the destination, which is the part itself if it's valid there
+func (h *invalidNameHandler) sanitize(name string) string {
+	valid := true
+	for i, r := range name {
+		if h.isInvalid(r, i == len(name)-1) {
+			valid = false
+			break
+		}
+	}
+	if valid {
+		return name
+	}
+
+	var b strings.Builder
+	for i, r := range name {
+		if r == '%' || h.isInvalid(r, i == len(name)-1) {
+			fmt.Fprintf(&b, "%%%02X", r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// nameAtDestination returns the path, relative to the destination, that the object at relativePath goes to,
+// or false if the object is to be left out of the job, since the destination doesn't allow its name
+func (h *invalidNameHandler) nameAtDestination(relativePath string) (string, bool, error) {
+	parts := strings.Split(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+	for i := range parts {
+		parts[i] = h.sanitize(parts[i])
+	}
+	sanitized := strings.Join(parts, common.AZCOPY_PATH_SEPARATOR_STRING)
+	if sanitized == relativePath {
+		return relativePath, true, nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.handling == common.EInvalidNameHandling.Skip() {
+		h.skipped++
+		LogStdoutAndJobLog(fmt.Sprintf("Skipping %s, since its name is not allowed at the destination", relativePath))
+		return "", false, nil
+	}
+
+	if h.mapping == nil {
+		h.mappingPath = sanitizedNamesMappingPath(h.jobID)
+		f, err := os.Create(h.mappingPath)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create the mapping file of the sanitized names: %s", err)
+		}
+		h.mapping = f
+	}
+	if _, err := fmt.Fprintf(h.mapping, "%s\t%s\n", relativePath, sanitized); err != nil {
+		return "", false, fmt.Errorf("failed to write the mapping file of the sanitized names: %s", err)
+	}
+	h.sanitized++
+	return sanitized, true, nil
+}
+
+// rename returns the object with the name that it has at the destination, or false if it's to be left out of the job.
+// An object that is the source itself is only renamed when it's copied into a directory, which is when its name is used
+func (h *invalidNameHandler) rename(object storedObject, dstIsDir bool) (storedObject, bool, error) {
+	if h == nil || (object.relativePath == "" && !dstIsDir) {
+		return object, true, nil
+	}
+
+	if object.relativePath == "" {
+		name, keep, err := h.nameAtDestination(object.name)
+		object.name = name
+		return object, keep, err
+	}
+	relativePath, keep, err := h.nameAtDestination(object.relativePath)
+	if relativePath != object.relativePath {
+		object.relativePath = relativePath
+		object.name = path.Base(relativePath)
+	}
+	return object, keep, err
+}
+
+// forDestination wraps a processor of the source objects of a sync, so that each of them is compared with,
+// and copied to, the object of its sanitized name, and those that are skipped never reach it.
+// This is what keeps sync from deleting the sanitized destinations, as if nothing at the source had their names
+func (h *invalidNameHandler) forDestination(processor objectProcessor) objectProcessor {
+	if h == nil {
+		return processor
+	}
+
+	return func(object storedObject) error {
+		if object.relativePath == "" {
+			return processor(object) // the destination is named by the user
+		}
+		relativePath, keep, err := h.nameAtDestination(object.relativePath)
+		if err != nil || !keep {
+			return err
+		}
+		if relativePath != object.relativePath {
+			object.dstRelativePath = relativePath
+		}
+		return processor(object)
+	}
+}
+
+// sanitizedNamesMappingPath is where the sanitized names of a job are mapped to the original ones.
+// It's next to the job's log, but it isn't removed with it by jobs clean or jobs rm, since the original names can't always be told without it
+func sanitizedNamesMappingPath(jobID common.JobID) string {
+	return filepath.Join(azcopyLogPathFolder, jobID.String()+"-sanitized-names.tsv")
+}
+
+// summarize closes the mapping file, and reflects the names that were skipped or sanitized in the summary of the finished job
+func (h *invalidNameHandler) summarize(summary *common.ListJobSummaryResponse) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	summary.InvalidNamesSkipped = h.skipped
+	summary.NamesSanitized = h.sanitized
+	if h.mapping != nil {
+		if err := h.mapping.Close(); err != nil {
+			glcm.Info(fmt.Sprintf("Failed to write the mapping file of the sanitized names %s: %s", h.mappingPath, err))
+		}
+		h.mapping = nil
+	}
+	summary.SanitizedNamesMapping = h.mappingPath
+}
+
+func formatInvalidNames(summary common.ListJobSummaryResponse) string {
+	result := ""
+	if summary.InvalidNamesSkipped > 0 {
+		result += fmt.Sprintf("\n\n%v files were skipped, since their names are not allowed at the destination", summary.InvalidNamesSkipped)
+	}
+	if summary.NamesSanitized > 0 {
+		result += fmt.Sprintf("\n\n%v files were given sanitized names, since their names are not allowed at the destination. "+
+			"The original names are mapped to the sanitized ones in %s", summary.NamesSanitized, summary.SanitizedNamesMapping)
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// jobControlListener accepts the connections to the control endpoint of a job,
+// which is a unix socket, or a named pipe on Windows, that only the user running the job can open
+type jobControlListener interface {
+	accept() (io.ReadWriteCloser, error)
+	close() error
+}
+
+// jobControlServer lets 'jobs set' change the limits of a job while it runs, through the job's control endpoint.
+// Each connection carries one SetJobLimitsRequest, and gets one SetJobLimitsResponse back.
+type jobControlServer struct {
+	jobID    common.JobID
+	endpoint string
+	listener jobControlListener
+}
+
+// the control endpoint of the job that's running in this process, if any.
+// There's one at a time: when a follow-up job starts, the endpoint of the previous one is closed
+var currentJobControl struct {
+	lock       sync.Mutex
+	server     *jobControlServer
+	registered bool // whether the endpoint is closed on exit
+}
+
+// startJobControl opens the control endpoint of the job. It's a variable so that the tests, which only mock the jobs, don't open any
+var startJobControl = openJobControl
+
+// openJobControl opens the control endpoint of the job, and tells the user where it is.
+// Failing to open it doesn't stop the job, it just can't be controlled while it runs.
+func openJobControl(jobID common.JobID) {
+	currentJobControl.lock.Lock()
+	defer currentJobControl.lock.Unlock()
+
+	if s := currentJobControl.server; s != nil {
+		if s.jobID == jobID {
+			return // e.g. a resumed job, or a job whose progress reporting was restarted
+		}
+		s.close()
+		currentJobControl.server = nil
+	}
+	if !currentJobControl.registered {
+		glcm.RegisterCloseFunc(stopJobControl)
+		currentJobControl.registered = true
+	}
+
+	endpoint := jobControlEndpoint(jobID)
+	listener, err := listenJobControl(endpoint)
+	if err != nil {
+		glcm.Info(fmt.Sprintf("Could not open the control endpoint of the job, so its limits can't be changed while it runs: %s", err))
+		return
+	}
+
+	s := &jobControlServer{jobID: jobID, endpoint: endpoint, listener: listener}
+	currentJobControl.server = s
+	go s.serve()
+	glcm.Info(fmt.Sprintf("Control endpoint of the job (for 'azcopy jobs set'): %s", endpoint))
+}
+
+// stopJobControl closes the control endpoint, which removes it
+func stopJobControl() {
+	currentJobControl.lock.Lock()
+	defer currentJobControl.lock.Unlock()
+
+	if s := currentJobControl.server; s != nil {
+		s.close()
+		currentJobControl.server = nil
+	}
+}
+
+func (s *jobControlServer) serve() {
+	for {
+		conn, err := s.listener.accept()
+		if err != nil {
+			return // closed
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *jobControlServer) handle(conn io.ReadWriteCloser) {
+	defer conn.Close()
+
+	var req common.SetJobLimitsRequest
+	var resp common.SetJobLimitsResponse
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.ErrorMsg = "invalid request: " + err.Error()
+	} else if req.JobID != s.jobID {
+		resp.ErrorMsg = fmt.Sprintf("this is the control endpoint of job %s, not of job %s", s.jobID, req.JobID)
+	} else {
+		Rpc(common.ERpcCmd.SetJobLimits(), &req, &resp)
+	}
+	_ = json.NewEncoder(conn).Encode(resp) // nothing to do if the caller has gone
+}
+
+func (s *jobControlServer) close() {
+	if err := s.listener.close(); err != nil {
+		glcm.Info(fmt.Sprintf("Failed to remove the control endpoint %s: %s", s.endpoint, err))
+	}
+}
+
+// sendJobLimits sends the request to the control endpoint of the job, which must be running
+func sendJobLimits(req common.SetJobLimitsRequest) (common.SetJobLimitsResponse, error) {
+	var resp common.SetJobLimitsResponse
+
+	conn, err := dialJobControl(jobControlEndpoint(req.JobID))
+	if err != nil {
+		return resp, fmt.Errorf("cannot reach job %s, which must be running for its limits to be changed: %s", req.JobID, err)
+	}
+	defer conn.Close()
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("no valid response from job %s: %s", req.JobID, err)
+	}
+	return resp, nil
+}
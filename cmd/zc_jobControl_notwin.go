@@ -0,0 +1,82 @@
+//go:build !windows
+// +build !windows
+
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// jobControlEndpoint is the unix socket of the job. It's in a directory of its own that only the user can enter,
+// so that nobody else can connect to it, even in the moment between its creation and the change of its permissions
+func jobControlEndpoint(jobID common.JobID) string {
+	return filepath.Join(azcopyJobPlanFolder, jobID.String()+".control", "control.sock")
+}
+
+type unixJobControlListener struct {
+	listener *net.UnixListener
+	dir      string
+}
+
+func listenJobControl(endpoint string) (jobControlListener, error) {
+	dir := filepath.Dir(endpoint)
+	// a resumed job has the same ID, so there may be a leftover of an earlier process that didn't exit cleanly
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: endpoint, Net: "unix"})
+	if err == nil {
+		err = os.Chmod(endpoint, 0600)
+	}
+	if err != nil {
+		if listener != nil {
+			listener.Close()
+		}
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &unixJobControlListener{listener: listener, dir: dir}, nil
+}
+
+func (l *unixJobControlListener) accept() (io.ReadWriteCloser, error) {
+	return l.listener.Accept()
+}
+
+// close removes the socket too
+func (l *unixJobControlListener) close() error {
+	l.listener.Close()
+	return os.RemoveAll(l.dir)
+}
+
+func dialJobControl(endpoint string) (io.ReadWriteCloser, error) {
+	return net.Dial("unix", endpoint)
+}
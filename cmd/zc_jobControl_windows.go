@@ -0,0 +1,204 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+var (
+	jobControlKernel32   = syscall.NewLazyDLL("kernel32.dll")
+	procCreateNamedPipeW = jobControlKernel32.NewProc("CreateNamedPipeW")
+	procConnectNamedPipe = jobControlKernel32.NewProc("ConnectNamedPipe")
+
+	jobControlAdvapi32                                       = syscall.NewLazyDLL("advapi32.dll")
+	procConvertStringSecurityDescriptorToSecurityDescriptorW = jobControlAdvapi32.NewProc("ConvertStringSecurityDescriptorToSecurityDescriptorW")
+)
+
+const (
+	pipeAccessDuplex          = 0x3
+	fileFlagFirstPipeInstance = 0x80000
+	pipeRejectRemoteClients   = 0x8 // the mode is otherwise byte, blocking
+	pipeUnlimitedInstances    = 255
+	pipeBufferSize            = 4096
+	sddlRevision1             = 1
+
+	errorPipeBusy      syscall.Errno = 231 // ERROR_PIPE_BUSY: all the instances of the pipe are connected
+	errorPipeConnected syscall.Errno = 535 // ERROR_PIPE_CONNECTED: the client connected before ConnectNamedPipe was called
+)
+
+var errJobControlClosed = errors.New("the control endpoint is closed")
+
+// jobControlEndpoint is the named pipe of the job. Its security descriptor only lets the user running the job open it
+func jobControlEndpoint(jobID common.JobID) string {
+	return `\\.\pipe\azcopy-` + jobID.String()
+}
+
+// namedPipeJobControlListener creates an instance of the pipe for each client, since that's how named pipes work.
+// There's always one that waits for the next client
+type namedPipeJobControlListener struct {
+	name       string
+	attributes *syscall.SecurityAttributes
+	lock       sync.Mutex
+	closed     bool
+	next       syscall.Handle
+}
+
+func listenJobControl(endpoint string) (jobControlListener, error) {
+	attributes, err := ownerOnlySecurityAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &namedPipeJobControlListener{name: endpoint, attributes: attributes}
+	// creating the first instance fails if someone else already has a pipe by that name
+	l.next, err = l.createInstance(fileFlagFirstPipeInstance)
+	if err != nil {
+		_, _ = syscall.LocalFree(syscall.Handle(attributes.SecurityDescriptor))
+		return nil, err
+	}
+	return l, nil
+}
+
+// ownerOnlySecurityAttributes only grants access to the user, and to nobody else, not even the administrators
+func ownerOnlySecurityAttributes() (*syscall.SecurityAttributes, error) {
+	token, err := syscall.OpenCurrentProcessToken()
+	if err != nil {
+		return nil, err
+	}
+	defer token.Close()
+
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	sid, err := user.User.Sid.String()
+	if err != nil {
+		return nil, err
+	}
+	sddl, err := syscall.UTF16PtrFromString("D:P(A;;GA;;;" + sid + ")")
+	if err != nil {
+		return nil, err
+	}
+
+	var descriptor uintptr
+	r, _, err := procConvertStringSecurityDescriptorToSecurityDescriptorW.Call(uintptr(unsafe.Pointer(sddl)), sddlRevision1, uintptr(unsafe.Pointer(&descriptor)), 0)
+	if r == 0 {
+		return nil, err
+	}
+	return &syscall.SecurityAttributes{Length: uint32(unsafe.Sizeof(syscall.SecurityAttributes{})), SecurityDescriptor: descriptor}, nil
+}
+
+func (l *namedPipeJobControlListener) createInstance(flags uint32) (syscall.Handle, error) {
+	name, err := syscall.UTF16PtrFromString(l.name)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+
+	r, _, err := procCreateNamedPipeW.Call(uintptr(unsafe.Pointer(name)), uintptr(pipeAccessDuplex|flags), pipeRejectRemoteClients,
+		pipeUnlimitedInstances, pipeBufferSize, pipeBufferSize, 0, uintptr(unsafe.Pointer(l.attributes)))
+	if syscall.Handle(r) == syscall.InvalidHandle {
+		return syscall.InvalidHandle, err
+	}
+	return syscall.Handle(r), nil
+}
+
+func (l *namedPipeJobControlListener) accept() (io.ReadWriteCloser, error) {
+	l.lock.Lock()
+	handle, closed := l.next, l.closed
+	l.lock.Unlock()
+	if closed {
+		_ = syscall.CloseHandle(handle)
+		return nil, errJobControlClosed
+	}
+
+	r, _, err := procConnectNamedPipe.Call(uintptr(handle), 0)
+	if r == 0 && err != errorPipeConnected {
+		_ = syscall.CloseHandle(handle)
+		return nil, err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		// it was close that connected, to get us here
+		_ = syscall.CloseHandle(handle)
+		return nil, errJobControlClosed
+	}
+	if l.next, err = l.createInstance(0); err != nil {
+		_ = syscall.CloseHandle(handle)
+		return nil, err
+	}
+	return &namedPipeConnection{File: os.NewFile(uintptr(handle), l.name)}, nil
+}
+
+// close stops accept, by connecting to the instance that it waits on. The pipe goes away with its last instance
+func (l *namedPipeJobControlListener) close() error {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return nil
+	}
+	l.closed = true
+	_, _ = syscall.LocalFree(syscall.Handle(l.attributes.SecurityDescriptor))
+	l.lock.Unlock()
+
+	if conn, err := dialJobControl(l.name); err == nil {
+		_ = conn.Close()
+	}
+	return nil
+}
+
+type namedPipeConnection struct {
+	*os.File
+}
+
+// Close waits for the client to read the response, since it's lost when the pipe is closed before that
+func (c *namedPipeConnection) Close() error {
+	_ = syscall.FlushFileBuffers(syscall.Handle(c.Fd()))
+	return c.File.Close()
+}
+
+func dialJobControl(endpoint string) (io.ReadWriteCloser, error) {
+	name, err := syscall.UTF16PtrFromString(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_EXISTING, 0, 0)
+		if err == nil {
+			return os.NewFile(uintptr(handle), endpoint), nil
+		}
+		if err != errorPipeBusy || attempt == 10 {
+			return nil, err
+		}
+		time.Sleep(100 * time.Millisecond) // until the server has created the next instance
+	}
+}
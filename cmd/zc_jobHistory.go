@@ -0,0 +1,56 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// recordJobHistory adds the finished job to the job history, if the user asked for one to be kept (AZCOPY_JOB_HISTORY).
+// A history that can't be written doesn't fail the job
+func recordJobHistory(summary common.ListJobSummaryResponse, command, source, destination string, startTime time.Time) {
+	if !strings.EqualFold(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.JobHistory()), "true") {
+		return
+	}
+
+	entry := common.JobHistoryEntry{
+		JobID:                 summary.JobID,
+		Command:               strings.TrimSpace(command),
+		Source:                common.URLStringExtension(source).RedactSecretQueryParamForLogging(),
+		Destination:           common.URLStringExtension(destination).RedactSecretQueryParamForLogging(),
+		StartTime:             startTime.UTC(),
+		EndTime:               time.Now().UTC(),
+		TotalTransfers:        summary.TotalTransfers,
+		TransfersCompleted:    summary.TransfersCompleted,
+		TransfersFailed:       summary.TransfersFailed,
+		TransfersSkipped:      summary.TransfersSkipped,
+		TotalBytesTransferred: summary.TotalBytesTransferred,
+		JobStatus:             summary.JobStatus,
+	}
+	historyPath := common.JobHistoryPath(azcopyAppPathFolder)
+	if err := common.AppendJobHistory(historyPath, entry); err != nil {
+		glcm.Info(fmt.Sprintf("Failed to add the job to the job history in %s: %s", historyPath, err))
+	}
+}
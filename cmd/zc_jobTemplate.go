@@ -0,0 +1,122 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// the flag that names the template to load; it's never kept in a template itself
+const fromTemplateFlag = "from-template"
+
+// jobTemplateFlags returns the flags that were given to the command (on the command line, or by a template), by name
+func jobTemplateFlags(flags *pflag.FlagSet) map[string]common.JobTemplateFlag {
+	result := make(map[string]common.JobTemplateFlag)
+	flags.Visit(func(f *pflag.Flag) {
+		if f.Name == fromTemplateFlag {
+			return
+		}
+		if f.Value.Type() == "stringArray" {
+			values, _ := flags.GetStringArray(f.Name)
+			result[f.Name] = values
+			return
+		}
+		if f.Name == destinationShardsFlag {
+			result[f.Name] = common.JobTemplateFlag{withoutShardSAS(f.Value.String())}
+			return
+		}
+		result[f.Name] = common.JobTemplateFlag{f.Value.String()}
+	})
+	return result
+}
+
+// withoutShardSAS removes the SAS of each of the destination shards, since a template must not hold any credentials
+func withoutShardSAS(list string) string {
+	shards := strings.Split(list, ";")
+	for i, shard := range shards {
+		if root, _, err := SplitAuthTokenFromResource(strings.TrimSpace(shard), common.ELocation.Blob()); err == nil {
+			shards[i] = root
+		}
+	}
+	return strings.Join(shards, ";")
+}
+
+// loadJobTemplate reads the template, and sets the flags that it has, except those that were given on the command line, which win.
+// A template written by a newer version of AzCopy is refused, as it may have flags that mean something different here
+func loadJobTemplate(path, command string, flags *pflag.FlagSet) (common.JobTemplate, error) {
+	t, err := common.ReadJobTemplate(path)
+	if err != nil {
+		return t, err
+	}
+	if t.Command != command {
+		return t, fmt.Errorf("the job template %s is of a %s job, so it cannot be used with %s", path, t.Command, command)
+	}
+	written, err := NewVersion(t.AzCopyVersion)
+	if err != nil {
+		return t, fmt.Errorf("the job template %s has an invalid azcopyVersion %q", path, t.AzCopyVersion)
+	}
+	current, _ := NewVersion(common.AzcopyVersion)
+	if current != nil && written.NewerThan(*current) {
+		return t, fmt.Errorf("the job template %s was written by AzCopy %s, which is newer than this one (%s). Please use AzCopy %s or later",
+			path, t.AzCopyVersion, common.AzcopyVersion, t.AzCopyVersion)
+	}
+
+	for name, values := range t.Flags {
+		f := flags.Lookup(name)
+		if f == nil || name == fromTemplateFlag {
+			return t, fmt.Errorf("the job template %s has the flag --%s, which %s does not have", path, name, command)
+		}
+		if f.Changed {
+			continue
+		}
+		for _, v := range values {
+			if err = flags.Set(name, v); err != nil {
+				return t, fmt.Errorf("the job template %s has an invalid value for --%s: %w", path, name, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// recordJobTemplate keeps the template of the job next to its plan files, for jobs export-template,
+// and logs the hash of its flags, so that each run can be tied to the template it was started from
+func (cca *cookedCopyCmdArgs) recordJobTemplate() {
+	if cca.template == nil {
+		return
+	}
+
+	from := ""
+	if cca.templateFile != "" {
+		from = fmt.Sprintf(", loaded from the job template %s", cca.templateFile)
+	}
+	ste.JobsAdmin.LogToJobLog(fmt.Sprintf("Job flag set hash: %s%s", cca.template.FlagSetHash, from))
+
+	path := common.JobTemplatePath(azcopyJobPlanFolder, cca.jobID)
+	if err := common.WriteJobTemplate(path, *cca.template); err != nil {
+		ste.JobsAdmin.LogToJobLog(fmt.Sprintf("Failed to keep the job template in %s: %s", path, err))
+	}
+}
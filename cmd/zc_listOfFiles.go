@@ -0,0 +1,275 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// The list of files has one path per line, relative to the source, as it is, without any URL-encoding.
+// A path that can't be written that way, e.g. since it has a line break, leading or trailing white space (which editors and
+// conversions of the line endings tend to lose) or isn't valid UTF-8, is written quoted, with the escapes of a Go string literal.
+// So a line that starts with a double quote, and is a valid Go string literal, stands for the string it quotes.
+// In the NDJSON format, each line is instead a JSON object, which can also say where the entry goes and what its metadata is.
+
+// utf8BOM is the UTF-8 byte order marker that some editors put at the start of the files they save
+const utf8BOM = "\xEF\xBB\xBF"
+
+// listOfFilesStdin is the name of the list of files that tells it's read from stdin
+const listOfFilesStdin = "-"
+
+// maxListOfFilesLineLength is the longest line the list of files can have, which only an entry with a lot of metadata comes near
+const maxListOfFilesLineLength = 1024 * 1024
+
+// listOfFilesEntry is a path that the list of files, or include-path, selects.
+// When it's a directory, the destination and the metadata apply to everything under it
+type listOfFilesEntry struct {
+	// relative to the source
+	Source string `json:"source"`
+	// relative to the destination, if the entry doesn't go to its path relative to the source
+	Destination string `json:"destination,omitempty"`
+	// if not empty, the metadata of the entry at the destination, instead of what it would otherwise get
+	Metadata common.Metadata `json:"metadata,omitempty"`
+}
+
+// malformedListOfFilesLineError is why a line of the list of files doesn't stand for an entry
+type malformedListOfFilesLineError struct {
+	lineNumber int
+	err        error
+}
+
+func (e malformedListOfFilesLineError) Error() string {
+	return fmt.Sprintf("line %d is malformed: %s", e.lineNumber, e.err)
+}
+
+// openListOfFiles opens the list of files, which is stdin if its name is "-"
+func openListOfFiles(name string) (io.ReadCloser, error) {
+	if name == listOfFilesStdin {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+// readListOfFiles hands each entry of the list of files to add, in the order they're listed, as it reads them,
+// so that a list of any length can be streamed into the enumeration.
+// Malformed lines are logged and skipped, unless the list is strict, in which case the first ends the reading with its error
+func readListOfFiles(r io.Reader, format common.ListOfFilesFormat, strict bool, add func(entry listOfFilesEntry)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxListOfFilesLineLength)
+	checkBOM := false
+	headerLineNum := 0
+	firstLineIsCurlyBrace := false
+	lineNumber := 0
+
+	for scanner.Scan() {
+		v := scanner.Text()
+		lineNumber++
+
+		// Check if the UTF-8 BOM is on the first line and remove it if necessary.
+		// Note that the UTF-8 BOM can be present on the same line feed as the first line of actual data, so just use TrimPrefix.
+		// If the line feed were separate, the empty string would be skipped later.
+		if !checkBOM {
+			v = strings.TrimPrefix(v, utf8BOM)
+			checkBOM = true
+		}
+
+		if format == common.EListOfFilesFormat.NDJSON() {
+			if strings.TrimSpace(v) == "" {
+				continue
+			}
+			entry, err := parseListOfFilesJSONLine(v)
+			if err != nil {
+				malformed := malformedListOfFilesLineError{lineNumber: lineNumber, err: err}
+				if strict {
+					return malformed
+				}
+				glcm.Info(fmt.Sprintf("Skipping an entry of the list of files, since %s", malformed))
+				continue
+			}
+			add(entry)
+			continue
+		}
+
+		// provide clear warning if user uses old (obsolete) format by mistake
+		if headerLineNum <= 1 {
+			cleanedLine := strings.Replace(strings.Replace(v, " ", "", -1), "\t", "", -1)
+			cleanedLine = strings.TrimSuffix(cleanedLine, "[") // don't care which line this is on, could be third line
+			if cleanedLine == "{" && headerLineNum == 0 {
+				firstLineIsCurlyBrace = true
+			} else {
+				const jsonStart = "{\"Files\":"
+				jsonStartNoBrace := strings.TrimPrefix(jsonStart, "{")
+				isJson := cleanedLine == jsonStart || firstLineIsCurlyBrace && cleanedLine == jsonStartNoBrace
+				if isJson {
+					glcm.Error("The format for list-of-files has changed. The old JSON format is no longer supported")
+				}
+			}
+			headerLineNum++
+		}
+
+		add(listOfFilesEntry{Source: parseListOfFilesLine(v)})
+	}
+	return scanner.Err()
+}
+
+// parseListOfFilesJSONLine returns the entry of a line of the list of files in the NDJSON format
+func parseListOfFilesJSONLine(line string) (listOfFilesEntry, error) {
+	var entry listOfFilesEntry
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entry); err != nil {
+		return entry, err
+	}
+	if decoder.More() {
+		return entry, errors.New("it has more than one JSON value")
+	}
+	if entry.Source == "" {
+		return entry, errors.New("it has no source")
+	}
+	return entry, nil
+}
+
+// parseListOfFilesLine returns the path that the line of the list of files stands for
+func parseListOfFilesLine(line string) string {
+	if strings.HasPrefix(line, `"`) {
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			return unquoted
+		}
+	}
+	return line
+}
+
+// formatListOfFilesLine returns the line of the list of files that stands for the path
+func formatListOfFilesLine(path string) string {
+	if needsQuotingInListOfFiles(path) {
+		return strconv.Quote(path)
+	}
+	return path
+}
+
+func needsQuotingInListOfFiles(path string) bool {
+	if !utf8.ValidString(path) {
+		return true
+	}
+	// besides those that would be unquoted, and those that would lose their BOM, those that start with a curly brace
+	// are quoted so that they can't be mistaken for the old JSON format
+	if strings.HasPrefix(path, `"`) || strings.HasPrefix(path, utf8BOM) || strings.HasPrefix(path, "{") {
+		return true
+	}
+	first, _ := utf8.DecodeRuneInString(path)
+	last, _ := utf8.DecodeLastRuneInString(path)
+	if unicode.IsSpace(first) || unicode.IsSpace(last) {
+		return true
+	}
+	return strings.IndexFunc(path, func(r rune) bool { return !unicode.IsPrint(r) && r != ' ' }) >= 0
+}
+
+// cookFailedFilesOutput returns where the paths of the failed transfers are to be written, or "" if they're not.
+// The output is the user's, so unlike the reports of the job, it's wherever the user says
+func cookFailedFilesOutput(raw string, fromTo common.FromTo) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	if fromTo.From() == common.ELocation.Pipe() || fromTo.From() == common.ELocation.Benchmark() {
+		return "", fmt.Errorf("failed-files-output is not supported when the source is %s", fromTo.From())
+	}
+	return raw, nil
+}
+
+// failedFileRelativePath returns the path of the source of the failed transfer relative to the source of the job,
+// as the list of files takes it, or "" if the source is the file itself
+func failedFileRelativePath(transfer common.TransferDetail, source common.Location) string {
+	relativePath := strings.TrimPrefix(transfer.SrcRelative, common.AZCOPY_PATH_SEPARATOR_STRING)
+	if source.IsRemote() {
+		// the relative paths of remote sources are URL-encoded in the plan
+		if unescaped, err := url.PathUnescape(relativePath); err == nil {
+			relativePath = unescaped
+		}
+	}
+	return relativePath
+}
+
+// writeFailedFiles writes the paths of the job's failed transfers to the file, as a list of files that the job can be run again with,
+// and records in the summary of the finished job where they are
+func writeFailedFiles(summary *common.ListJobSummaryResponse, outputPath string, source common.Location) {
+	if outputPath == "" || summary.TransfersFailed == 0 {
+		return
+	}
+
+	request := common.ListJobTransfersRequest{JobID: summary.JobID, OfStatus: common.ETransferStatus.Failed()}
+	var resp common.ListJobTransfersResponse
+	Rpc(common.ERpcCmd.ListJobTransfers(), request, &resp)
+	if resp.ErrorMsg != "" {
+		glcm.Info(fmt.Sprintf("Failed to write the paths of the failed files to %s: %s", outputPath, resp.ErrorMsg))
+		return
+	}
+
+	if err := writeFailedFilesList(outputPath, resp.Details, source); err != nil {
+		glcm.Info(fmt.Sprintf("Failed to write the paths of the failed files to %s: %s", outputPath, err))
+		return
+	}
+	summary.FailedFilesOutput = outputPath
+}
+
+// writeFailedFilesList writes the paths of the sources of the transfers to the file, one per line of the list of files
+func writeFailedFilesList(outputPath string, transfers []common.TransferDetail, source common.Location) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, transfer := range transfers {
+		relativePath := failedFileRelativePath(transfer, source)
+		if relativePath == "" {
+			continue // the source is the file, so there's nothing to list
+		}
+		if _, err = w.WriteString(formatListOfFilesLine(relativePath) + "\n"); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func formatFailedFilesOutput(summary common.ListJobSummaryResponse) string {
+	if summary.FailedFilesOutput == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nThe paths of the failed files are in %s. To copy just them again, run the command again with --list-of-files=%s",
+		summary.FailedFilesOutput, summary.FailedFilesOutput)
+}
@@ -0,0 +1,156 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// enumerationListing follows the listing of the source while it's enumerated: how often the service throttled it,
+// and how far it had got, so that the parts of the job can record where it could continue from.
+// A nil enumerationListing follows nothing.
+type enumerationListing struct {
+	atomicRetries uint32
+
+	lock   sync.Mutex
+	marker string
+}
+
+type enumerationListingContextKey struct{}
+
+func newEnumerationListing() *enumerationListing {
+	return &enumerationListing{}
+}
+
+// withEnumerationListing returns a context in which the listing calls of the traversers report to l.
+// The ServerBusy responses to them are counted too, even those that the retry policy gets past.
+func withEnumerationListing(ctx context.Context, l *enumerationListing) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return ste.WithRetryNotification(context.WithValue(ctx, enumerationListingContextKey{}, l), l)
+}
+
+func enumerationListingFrom(ctx context.Context) *enumerationListing {
+	l, _ := ctx.Value(enumerationListingContextKey{}).(*enumerationListing)
+	return l
+}
+
+// RetryCallback counts a ServerBusy response to a listing call
+func (l *enumerationListing) RetryCallback() {
+	atomic.AddUint32(&l.atomicRetries, 1)
+}
+
+// retries is the number of listing calls that were retried, since the service throttled them
+func (l *enumerationListing) retries() uint32 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadUint32(&l.atomicRetries)
+}
+
+// reached records the continuation marker of the segment that the listing is about to ask for.
+// Everything before it has already been handed to the processor.
+func (l *enumerationListing) reached(marker string) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.marker = marker
+}
+
+// resumePoint is the marker that the listing could continue from, or empty if there's none that fits in the job plan
+func (l *enumerationListing) resumePoint() string {
+	if l == nil {
+		return ""
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.marker) > ste.ListingMarkerMaxBytes {
+		return "" // a cut marker is no use
+	}
+	return l.marker
+}
+
+// listingBackoffs are the waits before a listing call that the service still throttled, after all the tries of the retry policy,
+// is sent again from the same marker. Listing is what everything else waits for, so it's worth waiting for the service to cool down
+var listingBackoffs = []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+
+// listingBackoffWait is how listWithBackoff waits. Tests replace it, to not sit through the waits
+var listingBackoffWait = func(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isThrottledListing tells whether a listing call failed because the service is too busy to answer it
+func isThrottledListing(err error) bool {
+	withResponse, ok := err.(interface{ Response() *http.Response })
+	if !ok || withResponse.Response() == nil {
+		return false
+	}
+	switch withResponse.Response().StatusCode {
+	case http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// listWithBackoff makes a listing call (which lists one segment, from the marker that the traverser has reached),
+// and, if the service throttled it beyond what the retry policy gets past, backs off and makes it again,
+// so that the enumeration continues from where it got to, rather than giving up.
+func listWithBackoff(ctx context.Context, what string, list func() error) error {
+	err := list()
+	for _, wait := range listingBackoffs {
+		if err == nil || !isThrottledListing(err) {
+			return err
+		}
+
+		if l := enumerationListingFrom(ctx); l != nil {
+			atomic.AddUint32(&l.atomicRetries, 1)
+		}
+		LogStdoutAndJobLog(fmt.Sprintf("The listing of %s is throttled by the service. Waiting %v before it continues from where it got to", what, wait))
+		if waitErr := listingBackoffWait(ctx, wait); waitErr != nil {
+			return err
+		}
+		err = list()
+	}
+	return err
+}
+
+func formatEnumerationRetries(summary common.ListJobSummaryResponse) string {
+	if summary.EnumerationRetries == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nEnumeration Retries (Listing Throttled): %v", summary.EnumerationRetries)
+}
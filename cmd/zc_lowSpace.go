@@ -0,0 +1,161 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// freeDiskSpace is how the free space of the destination volume is measured. Tests replace it
+var freeDiskSpace = common.GetFreeDiskSpace
+
+// lowSpaceCheck compares what a download needs with the free space of the volume it saves to (--low-space-action).
+// While the source is enumerated, the bytes it found so far are compared with the space that was free when it started,
+// less the margin that the job leaves (AZCOPY_LOW_SPACE_MARGIN_MB). While the job runs, the job holds back new files once
+// the volume has no room for them, which is acted on each time the progress of the job is reported.
+// A nil check never does anything.
+type lowSpaceCheck struct {
+	action      common.LowSpaceAction
+	destination string
+	margin      uint64
+
+	measured bool
+	free     uint64
+	planned  uint64
+	// whether the user was already told (or asked) about the enumeration, and about the pause that's going on, if any
+	actedOnPlan  bool
+	actedOnPause bool
+	// why the job was aborted, or empty if it wasn't
+	reason string
+}
+
+// newLowSpaceCheck returns nil unless the job saves the files it downloads, and leaves a margin on the volume
+func newLowSpaceCheck(action common.LowSpaceAction, fromTo common.FromTo, destination string) *lowSpaceCheck {
+	margin := ste.LowSpaceMargin()
+	if !fromTo.IsDownload() || destination == common.Dev_Null || margin == 0 {
+		return nil
+	}
+	return &lowSpaceCheck{action: action, destination: destination, margin: margin}
+}
+
+// add adds the transfer to the bytes the job needs. The error it returns, once they're more than the volume can take
+// and the job isn't to go ahead, stops the enumeration
+func (l *lowSpaceCheck) add(transfer common.CopyTransfer) error {
+	if l == nil || l.actedOnPlan {
+		return nil
+	}
+
+	if !l.measured {
+		free, err := freeDiskSpace(l.destination)
+		if err != nil {
+			glcm.Info(fmt.Sprintf("Could not measure the free space on the volume of %s, so it's not checked: %s", l.destination, err))
+			l.actedOnPlan = true
+			return nil
+		}
+		l.measured, l.free = true, free
+	}
+
+	if !transfer.ExistsAtDestination {
+		l.planned += uint64(transfer.SourceSize)
+	}
+	if l.planned+l.margin <= l.free {
+		return nil
+	}
+
+	l.actedOnPlan = true
+	problem := fmt.Sprintf("the job needs at least %v MB, but the volume of %s has %v MB free, and %v MB of that is left to spare",
+		toMB(l.planned), l.destination, toMB(l.free), toMB(l.margin))
+	switch l.action {
+	case common.ELowSpaceAction.Fail():
+		return fmt.Errorf("%s. Free some space, or set --low-space-action to warn to go ahead anyway", problem)
+	case common.ELowSpaceAction.Prompt():
+		if !l.confirm(fmt.Sprintf("The destination is low on space: %s. The job will wait for room before it starts each file.", problem)) {
+			return fmt.Errorf("the job was stopped, since %s", problem)
+		}
+	default:
+		LogStdoutAndJobLog(fmt.Sprintf("Warning: %s. The job will wait for room before it starts each file.", problem))
+	}
+	return nil
+}
+
+// check acts on the job holding back new files, since the volume has no room for them.
+// It warns, asks whether to keep waiting, or aborts the job, once each time it happens
+func (l *lowSpaceCheck) check(jobID common.JobID, summary common.ListJobSummaryResponse) {
+	if l == nil || l.reason != "" {
+		return
+	}
+	if !summary.LowSpacePaused {
+		l.actedOnPause = false
+		return
+	}
+	if l.actedOnPause {
+		return
+	}
+	l.actedOnPause = true
+
+	problem := fmt.Sprintf("the volume of %s ran low on space", l.destination)
+	switch l.action {
+	case common.ELowSpaceAction.Fail():
+		l.reason = problem
+	case common.ELowSpaceAction.Prompt():
+		if !l.confirm(fmt.Sprintf("The job is waiting, since %s. It will carry on once there's room.", problem)) {
+			l.reason = problem
+		}
+	default:
+		LogStdoutAndJobLog(fmt.Sprintf("Warning: no more files are started while %s. The job will carry on once there's room.", problem))
+	}
+	if l.reason == "" {
+		return
+	}
+
+	LogStdoutAndJobLog(fmt.Sprintf("Aborting the job, since %s.", l.reason))
+	if err := (cookedCancelCmdArgs{jobID: jobID}).process(); err != nil {
+		glcm.Info("Failed to abort the job " + jobID.String() + ": " + err.Error())
+	}
+}
+
+func (l *lowSpaceCheck) confirm(message string) bool {
+	answer := glcm.Prompt(message+" Do you want to go ahead?",
+		common.PromptDetails{
+			PromptType:   common.EPromptType.LowSpace(),
+			PromptTarget: l.destination,
+			ResponseOptions: []common.ResponseOption{
+				common.EResponseOption.Yes(),
+				common.EResponseOption.No(),
+			},
+		})
+	return answer == common.EResponseOption.Yes()
+}
+
+// reportAbort reflects in the summary of the finished job that it was aborted for running low on space (if it was)
+func (l *lowSpaceCheck) reportAbort(summary *common.ListJobSummaryResponse) {
+	if l == nil || l.reason == "" || summary.FailFastReason != "" {
+		return
+	}
+	reportJobAborted(summary, l.reason)
+}
+
+func toMB(bytes uint64) uint64 {
+	return bytes / (1024 * 1024)
+}
@@ -0,0 +1,280 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// how often the throughput gauge is recomputed, the same interval as the throughput of the progress line
+const metricsThroughputInterval = 2 * time.Second
+
+// metricsEndpoint serves the metrics of the jobs that run in the process, in the Prometheus text format, at /metrics.
+// The counters cover all the jobs that the process ran, e.g. all the rounds of a watched sync, so that they never go down.
+// Nothing that is specific to the data, such as paths or URLs, is ever in the metrics; the labels only have fixed values and status codes.
+// A nil endpoint is valid: the metrics weren't asked for, and it does nothing.
+type metricsEndpoint struct {
+	engine   metricsEngine
+	server   *http.Server
+	listener net.Listener
+	done     chan struct{}
+
+	lock       sync.Mutex
+	current    *metricsJob
+	finished   metricsTotals // of the jobs before the current one
+	reported   uint64        // the bytes transferred that were last reported, which the counter never goes below
+	throughput float64       // in megabits per second
+}
+
+// metricsEngine is what the metrics read from the transfer engine, i.e. from ste.JobsAdmin
+type metricsEngine interface {
+	BytesOverWire() int64
+	CurrentMainPoolSize() int
+	MbpsCap() int64
+}
+
+// metricsJob is the job that's running in the process
+type metricsJob struct {
+	jobID common.JobID
+	// whether the job has been ordered far enough to have a summary. Nil if it always has one
+	ready func() bool
+	// what the job has enumerated so far on both sides. Nil unless the command counts it, as sync does
+	scanned func() (source, destination uint64)
+}
+
+// metricsTotals are the counters of the jobs that have finished
+type metricsTotals struct {
+	transfersCompleted uint64
+	transfersFailed    uint64
+	transfersSkipped   uint64
+	bytesTransferred   uint64
+	retryCount         int64
+	responsesByStatus  map[int]int64
+}
+
+// the metrics endpoint of the process, if the metrics were asked for
+var metricsEndpointOfProcess *metricsEndpoint
+
+func validateMetricsListen(address string) error {
+	if address == "" {
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(address); err != nil || port == "" {
+		return fmt.Errorf("invalid value %q for metrics-listen: it must be an address and port to listen on, e.g. :9090 or 127.0.0.1:9090", address)
+	}
+	return nil
+}
+
+// startMetricsEndpoint starts serving the metrics at the given address, unless it's empty, until the process exits.
+// It's only started once, even when several jobs run in the process
+func startMetricsEndpoint(address string) error {
+	if address == "" || metricsEndpointOfProcess != nil {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot serve the metrics at %s: %s", address, err)
+	}
+
+	m := newMetricsEndpoint(ste.JobsAdmin)
+	m.listener = listener
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.serveMetrics)
+	m.server = &http.Server{Handler: mux}
+	go func() { _ = m.server.Serve(listener) }()
+	go m.measureThroughput()
+
+	metricsEndpointOfProcess = m
+	glcm.RegisterCloseFunc(m.close)
+	glcm.Info(fmt.Sprintf("Serving the metrics of the job at http://%s/metrics", listener.Addr()))
+	return nil
+}
+
+func newMetricsEndpoint(engine metricsEngine) *metricsEndpoint {
+	return &metricsEndpoint{engine: engine, done: make(chan struct{}), finished: metricsTotals{responsesByStatus: map[int]int64{}}}
+}
+
+func (m *metricsEndpoint) close() {
+	close(m.done)
+	_ = m.server.Close()
+}
+
+// jobStarted makes the metrics follow the job that has just started, after adding up the counters of the one before it
+func (m *metricsEndpoint) jobStarted(jobID common.JobID, ready func() bool, scanned func() (source, destination uint64)) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.current != nil && m.current.jobID == jobID {
+		return
+	}
+	if summary, ok := m.current.summary(); ok {
+		m.finished.add(summary)
+	}
+	m.current = &metricsJob{jobID: jobID, ready: ready, scanned: scanned}
+}
+
+func (j *metricsJob) summary() (summary common.ListJobSummaryResponse, ok bool) {
+	if j == nil || (j.ready != nil && !j.ready()) {
+		return summary, false
+	}
+	Rpc(common.ERpcCmd.ListJobSummary(), &j.jobID, &summary)
+	return summary, summary.ErrorMsg == ""
+}
+
+func (t *metricsTotals) add(summary common.ListJobSummaryResponse) {
+	t.transfersCompleted += uint64(summary.TransfersCompleted)
+	t.transfersFailed += uint64(summary.TransfersFailed)
+	t.transfersSkipped += uint64(summary.TransfersSkipped)
+	t.bytesTransferred += summary.TotalBytesTransferred
+	t.retryCount += summary.RetryCount
+	for status, count := range summary.RequestCountsByStatus {
+		t.responsesByStatus[status] += count
+	}
+}
+
+func (m *metricsEndpoint) measureThroughput() {
+	ticker := time.NewTicker(metricsThroughputInterval)
+	defer ticker.Stop()
+
+	lastBytes := m.engine.BytesOverWire()
+	lastTime := time.Now()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			bytes := m.engine.BytesOverWire()
+			megabitsPerSec := 8 * float64(bytes-lastBytes) / now.Sub(lastTime).Seconds() / (1000 * 1000)
+			lastBytes, lastTime = bytes, now
+
+			m.lock.Lock()
+			m.throughput = megabitsPerSec
+			m.lock.Unlock()
+		}
+	}
+}
+
+func (m *metricsEndpoint) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.writeMetrics(w)
+}
+
+// writeMetrics writes the metrics in the Prometheus text format. Their names are stable, so don't change them
+func (m *metricsEndpoint) writeMetrics(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// the current job counts on top of the ones before it
+	totals := m.finished
+	totals.responsesByStatus = make(map[int]int64, len(m.finished.responsesByStatus))
+	for status, count := range m.finished.responsesByStatus {
+		totals.responsesByStatus[status] = count
+	}
+	summary, hasSummary := m.current.summary()
+	if hasSummary {
+		totals.add(summary)
+	}
+
+	// the bytes of a transfer in progress are taken back if it fails, but a counter must never go down
+	if totals.bytesTransferred < m.reported {
+		totals.bytesTransferred = m.reported
+	}
+	m.reported = totals.bytesTransferred
+
+	p := prometheusWriter{w: w}
+	p.metric("azcopy_bytes_transferred_total", "counter", "Bytes of the files that were transferred, or are being transferred, not counting retries.")
+	p.sample("azcopy_bytes_transferred_total", "", float64(totals.bytesTransferred))
+
+	p.metric("azcopy_transfers_total", "counter", "Transfers that have finished, by their final status.")
+	p.sample("azcopy_transfers_total", `status="completed"`, float64(totals.transfersCompleted))
+	p.sample("azcopy_transfers_total", `status="failed"`, float64(totals.transfersFailed))
+	p.sample("azcopy_transfers_total", `status="skipped"`, float64(totals.transfersSkipped))
+
+	p.metric("azcopy_throughput_megabits_per_second", "gauge", "Throughput over the last two seconds, in megabits per second.")
+	p.sample("azcopy_throughput_megabits_per_second", "", m.throughput)
+
+	p.metric("azcopy_active_chunk_workers", "gauge", "Chunk workers in the main pool, i.e. the concurrency of the transfers.")
+	p.sample("azcopy_active_chunk_workers", "", float64(m.engine.CurrentMainPoolSize()))
+
+	p.metric("azcopy_cap_megabits_per_second", "gauge", "Bandwidth cap, in megabits per second. Zero when there's no cap.")
+	p.sample("azcopy_cap_megabits_per_second", "", float64(m.engine.MbpsCap()))
+
+	// the outcomes that are retried are network errors and statuses 500 and 503. The network errors have no status
+	p.metric("azcopy_retries_total", "counter", "Requests whose outcome was retried, by HTTP status, or network_error when there was no response.")
+	internalErrors, unavailable := totals.responsesByStatus[http.StatusInternalServerError], totals.responsesByStatus[http.StatusServiceUnavailable]
+	p.sample("azcopy_retries_total", `status="500"`, float64(internalErrors))
+	p.sample("azcopy_retries_total", `status="503"`, float64(unavailable))
+	p.sample("azcopy_retries_total", `status="network_error"`, float64(totals.retryCount-internalErrors-unavailable))
+
+	p.metric("azcopy_http_responses_total", "counter", "Responses received from the services, by HTTP status, including those that were retried.")
+	statuses := make([]int, 0, len(totals.responsesByStatus))
+	for status := range totals.responsesByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		p.sample("azcopy_http_responses_total", `status="`+strconv.Itoa(status)+`"`, float64(totals.responsesByStatus[status]))
+	}
+
+	p.metric("azcopy_enumeration_complete", "gauge", "1 once the current job has enumerated all of its transfers, else 0.")
+	p.sample("azcopy_enumeration_complete", "", common.Iffloat64(hasSummary && summary.CompleteJobOrdered, 1, 0))
+
+	p.metric("azcopy_transfers_enumerated", "gauge", "Transfers that the current job has enumerated so far.")
+	p.sample("azcopy_transfers_enumerated", "", float64(summary.TotalTransfers))
+
+	if m.current != nil && m.current.scanned != nil {
+		source, destination := m.current.scanned()
+		p.metric("azcopy_objects_scanned", "gauge", "Files and objects that the current sync has scanned so far, by side.")
+		p.sample("azcopy_objects_scanned", `side="source"`, float64(source))
+		p.sample("azcopy_objects_scanned", `side="destination"`, float64(destination))
+	}
+}
+
+// prometheusWriter writes the text exposition format of Prometheus
+type prometheusWriter struct {
+	w io.Writer
+}
+
+func (p prometheusWriter) metric(name, metricType, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func (p prometheusWriter) sample(name, labels string, value float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	fmt.Fprintf(p.w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
@@ -0,0 +1,306 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+const bestEffortFeaturesFlag = "best-effort-features"
+
+// x-ms-is-hns-enabled is only returned by Get Account Information from this service version on
+const accountInfoServiceVersion = "2019-12-12"
+
+// requestedFeatures are the features of a job which only some destination accounts support.
+// Without a probe, they only fail once the transfers run, once per file.
+type requestedFeatures struct {
+	blockBlobTier      common.BlockBlobTier
+	pageBlobTier       common.PageBlobTier
+	preserveAccessTier bool
+	blobTags           bool
+}
+
+func (r requestedFeatures) any() bool {
+	return r.blockBlobTier != common.EBlockBlobTier.None() || r.pageBlobTier != common.EPageBlobTier.None() ||
+		r.preserveAccessTier || r.blobTags
+}
+
+// destinationAccount is what Get Account Information says about the account of the destination
+type destinationAccount struct {
+	skuName    string
+	kind       string
+	hnsEnabled bool
+}
+
+func (a destinationAccount) hasBlockBlobTiers() bool {
+	return !strings.HasPrefix(a.skuName, "Premium") && (a.kind == "StorageV2" || a.kind == "BlobStorage")
+}
+
+func (a destinationAccount) hasPageBlobTiers() bool {
+	return strings.HasPrefix(a.skuName, "Premium") && (a.kind == "StorageV2" || a.kind == "Storage")
+}
+
+// unsupportedFeature is a requested feature that the destination turned out not to support, named by its flag
+type unsupportedFeature struct {
+	flag   string
+	reason string
+}
+
+// the probes are variables, so that the tests can stand in for the service
+var (
+	getDestinationAccount = getAccountInformation
+	probeBlobTags         = setTagsOnScratchBlob
+)
+
+// probeDestinationFeatures asks the destination (given by its URL, with its SAS if there is one) whether it supports the requested features.
+// What cannot be probed, e.g. because the credential doesn't allow it, is assumed to be supported, which leaves it to fail at runtime as it always has.
+func probeDestinationFeatures(ctx context.Context, destination string, credInfo common.CredentialInfo, requested requestedFeatures, jobID common.JobID) ([]unsupportedFeature, error) {
+	if !requested.any() {
+		return nil, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return nil, err
+	}
+	p, err := createBlobPipeline(ctx, credInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var account *destinationAccount
+	if a, err := getDestinationAccount(ctx, p, *u); err != nil {
+		glcm.Info(fmt.Sprintf("Could not check which features the destination supports, so they will be tried anyway: %s", err))
+	} else {
+		account = &a
+	}
+
+	var tagsErr error
+	if requested.blobTags && (account == nil || !account.hnsEnabled) {
+		tagsErr = probeBlobTags(ctx, p, *u, jobID)
+	}
+
+	return findUnsupportedFeatures(requested, account, tagsErr), nil
+}
+
+// findUnsupportedFeatures compares the requested features to what the probes found. The account is nil if it couldn't be probed,
+// and tagsErr is the error of the test Set Tags request, if it was made and the service rejected it.
+func findUnsupportedFeatures(requested requestedFeatures, account *destinationAccount, tagsErr error) []unsupportedFeature {
+	unsupported := make([]unsupportedFeature, 0)
+
+	if account != nil {
+		accountDescription := fmt.Sprintf("the destination account (%s, %s)", account.kind, account.skuName)
+		if requested.blockBlobTier != common.EBlockBlobTier.None() && !account.hasBlockBlobTiers() {
+			unsupported = append(unsupported, unsupportedFeature{"block-blob-tier", accountDescription + " has no access tiers for block blobs"})
+		}
+		if requested.pageBlobTier != common.EPageBlobTier.None() && !account.hasPageBlobTiers() {
+			unsupported = append(unsupported, unsupportedFeature{"page-blob-tier", accountDescription + " is not a premium account for page blobs"})
+		}
+		if requested.preserveAccessTier && !account.hasBlockBlobTiers() && !account.hasPageBlobTiers() {
+			unsupported = append(unsupported, unsupportedFeature{"s2s-preserve-access-tier", accountDescription + " has no access tiers"})
+		}
+		if requested.blobTags && account.hnsEnabled {
+			unsupported = append(unsupported, unsupportedFeature{"blob-tags", accountDescription + " has a hierarchical namespace, which blob index tags are not supported with"})
+		}
+	}
+	if requested.blobTags && tagsErr != nil {
+		unsupported = append(unsupported, unsupportedFeature{"blob-tags", fmt.Sprintf("a test Set Tags request was rejected: %s", tagsErr)})
+	}
+
+	return unsupported
+}
+
+// checkUnsupportedFeatures fails the job when any requested feature isn't supported,
+// unless the user asked for best effort, in which case each of them is only warned about, and the caller must disable them
+func checkUnsupportedFeatures(unsupported []unsupportedFeature, bestEffort bool) error {
+	if len(unsupported) == 0 {
+		return nil
+	}
+
+	if bestEffort {
+		for _, f := range unsupported {
+			glcm.Info(fmt.Sprintf("Warning: --%s is disabled for this job, since %s", f.flag, f.reason))
+		}
+		return nil
+	}
+
+	problems := make([]string, len(unsupported))
+	for i, f := range unsupported {
+		problems[i] = fmt.Sprintf("--%s (%s)", f.flag, f.reason)
+	}
+	return fmt.Errorf("the destination does not support these requested features: %s. Use --%s to go ahead without them",
+		strings.Join(problems, "; "), bestEffortFeaturesFlag)
+}
+
+// preflightDestination probes the destination of a copy (or the blobs of a set-properties) for the requested features,
+// before anything is enumerated. The unsupported features are disabled when the user asked for best effort.
+func (cca *cookedCopyCmdArgs) preflightDestination(ctx context.Context) error {
+	target, sas := cca.destination, cca.destinationSAS
+	if cca.fromTo == common.EFromTo.BlobNone() {
+		target, sas = cca.source, cca.sourceSAS
+	} else if cca.fromTo.To() != common.ELocation.Blob() {
+		return nil
+	}
+
+	requested := requestedFeatures{
+		blockBlobTier:      cca.blockBlobTier,
+		pageBlobTier:       cca.pageBlobTier,
+		preserveAccessTier: cca.s2sPreserveAccessTier && cca.s2sPreserveAccessTierRequested && cca.fromTo.IsS2S(),
+		blobTags:           cca.propertiesToTransfer.ShouldTransferBlobTags(),
+	}
+	target, err := appendSASIfNecessary(target, sas)
+	if err != nil {
+		return err
+	}
+	unsupported, err := probeDestinationFeatures(ctx, target, cca.credentialInfo, requested, cca.jobID)
+	if err != nil {
+		return err
+	}
+	if err = checkUnsupportedFeatures(unsupported, cca.bestEffortFeatures); err != nil {
+		return err
+	}
+
+	for _, f := range unsupported {
+		switch f.flag {
+		case "block-blob-tier":
+			cca.blockBlobTier = common.EBlockBlobTier.None()
+		case "page-blob-tier":
+			cca.pageBlobTier = common.EPageBlobTier.None()
+		case "s2s-preserve-access-tier":
+			cca.s2sPreserveAccessTier = false
+		case "blob-tags":
+			cca.propertiesToTransfer &^= common.ESetPropertiesFlags.SetBlobTags()
+		}
+	}
+	if cca.fromTo == common.EFromTo.BlobNone() {
+		if cca.blockBlobTier == common.EBlockBlobTier.None() && cca.pageBlobTier == common.EPageBlobTier.None() {
+			cca.propertiesToTransfer &^= common.ESetPropertiesFlags.SetTier()
+		}
+		if cca.propertiesToTransfer == common.ESetPropertiesFlags.None() {
+			return errors.New("the destination supports none of the properties that were to be set")
+		}
+	}
+	return nil
+}
+
+// preflightDestination probes the destination of a sync for the requested features. Only the preservation of access tiers needs it.
+func (cca *cookedSyncCmdArgs) preflightDestination(ctx context.Context) error {
+	if cca.fromTo.To() != common.ELocation.Blob() {
+		return nil
+	}
+
+	requested := requestedFeatures{preserveAccessTier: cca.preserveAccessTier && cca.preserveAccessTierRequested && cca.fromTo.IsS2S()}
+	destination, err := appendSASIfNecessary(cca.destination, cca.destinationSAS)
+	if err != nil {
+		return err
+	}
+	unsupported, err := probeDestinationFeatures(ctx, destination, cca.credentialInfo, requested, cca.jobID)
+	if err != nil {
+		return err
+	}
+	if err = checkUnsupportedFeatures(unsupported, cca.bestEffortFeatures); err != nil {
+		return err
+	}
+	if len(unsupported) > 0 {
+		cca.preserveAccessTier = false
+	}
+	return nil
+}
+
+// getAccountInformation issues Get Account Information against the destination. The blob SDK we use doesn't expose it on its URLs.
+// With a SAS, it may be issued against the container or the blob of the SAS, so the path of the destination is kept.
+func getAccountInformation(ctx context.Context, p pipeline.Pipeline, u url.URL) (destinationAccount, error) {
+	req, err := pipeline.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return destinationAccount{}, pipeline.NewError(err, "failed to create request")
+	}
+	params := req.URL.Query()
+	params.Set("restype", "account")
+	params.Set("comp", "properties")
+	req.URL.RawQuery = params.Encode()
+
+	ctx = context.WithValue(ctx, ste.ServiceAPIVersionOverride, accountInfoServiceVersion)
+	resp, err := p.Do(ctx, accountInfoResponderFactory, req)
+	if err != nil {
+		return destinationAccount{}, err
+	}
+
+	header := resp.Response().Header
+	return destinationAccount{
+		skuName:    header.Get("x-ms-sku-name"),
+		kind:       header.Get("x-ms-account-kind"),
+		hnsEnabled: strings.EqualFold(header.Get("x-ms-is-hns-enabled"), "true"),
+	}, nil
+}
+
+var accountInfoResponderFactory = pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		resp, err := next.Do(ctx, request)
+		if err != nil {
+			return resp, err
+		}
+
+		r := resp.Response()
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+		if r.StatusCode != http.StatusOK {
+			return resp, fmt.Errorf("get account information failed: %s, service code %q", r.Status, r.Header.Get("x-ms-error-code"))
+		}
+		return resp, nil
+	}
+})
+
+// setTagsOnScratchBlob creates an empty blob in the container of the destination, sets a tag on it, and deletes it.
+// Only the rejection of the tag counts as tags being unsupported: if the scratch blob can't even be created, the probe is inconclusive.
+func setTagsOnScratchBlob(ctx context.Context, p pipeline.Pipeline, u url.URL, jobID common.JobID) error {
+	parts := azblob.NewBlobURLParts(u)
+	if parts.ContainerName == "" {
+		return nil
+	}
+	parts.BlobName = "azcopy-preflight-" + jobID.String()
+	parts.Snapshot = ""
+	blobURL := azblob.NewBlockBlobURL(parts.URL(), p)
+
+	if _, err := blobURL.Upload(ctx, bytes.NewReader(nil), azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}); err != nil {
+		glcm.Info(fmt.Sprintf("Could not check whether the destination supports blob index tags, so they will be tried anyway: %s", err))
+		return nil
+	}
+	defer func() {
+		if _, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{}); err != nil {
+			glcm.Info(fmt.Sprintf("Failed to delete the blob %s which was created to check whether the destination supports blob index tags: %s", parts.BlobName, err))
+		}
+	}()
+
+	return ste.SetBlobTags(ctx, p, blobURL.URL(), common.BlobTags{"azcopy-preflight": "true"})
+}
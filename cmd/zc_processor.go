@@ -81,7 +81,7 @@ func (s *copyTransferProcessor) scheduleCopyTransfer(storedObject storedObject)
 	s.copyJobTemplate.Transfers = append(s.copyJobTemplate.Transfers, storedObject.ToNewCopyTransfer(
 		false, // sync has no --decompress option
 		s.escapeIfNecessary(storedObject.relativePath, s.shouldEscapeSourceObjectName),
-		s.escapeIfNecessary(storedObject.relativePath, s.shouldEscapeDestinationObjectName),
+		s.escapeIfNecessary(storedObject.destinationRelativePath(), s.shouldEscapeDestinationObjectName),
 		s.preserveAccessTier,
 	))
 
@@ -0,0 +1,226 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+const ignoreSASValidationFlag = "ignore-sas-validation"
+
+// sasPermission is a permission of a SAS, with the letter that grants it in sp=
+type sasPermission struct {
+	letter byte
+	name   string
+}
+
+var (
+	sasRead   = sasPermission{'r', "Read"}
+	sasWrite  = sasPermission{'w', "Write"}
+	sasDelete = sasPermission{'d', "Delete"}
+	sasList   = sasPermission{'l', "List"}
+)
+
+// the resource types (srt=) of an account SAS
+const (
+	sasServiceResources   = 's'
+	sasContainerResources = 'c'
+	sasObjectResources    = 'o'
+)
+
+// sasRequirement is something that a job does at one end of its transfers, which the SAS of that end must allow
+type sasRequirement struct {
+	permission sasPermission
+	// which resource type an account SAS must allow for it, e.g. listing a container is an operation on the container
+	resourceType byte
+	// what it's needed for, as shown to the user
+	purpose string
+}
+
+// sasToken is what a SAS says about what it allows. Service SASs and user delegation SASs have a resource (sr=),
+// while account SASs have services (ss=) and resource types (srt=)
+type sasToken struct {
+	permissions   string
+	resourceTypes string
+	services      string
+}
+
+// parseSASToken returns false when there's nothing to validate: when the token isn't a SAS,
+// or when its permissions are in a stored access policy (si=) rather than in the token
+func parseSASToken(sas string) (sasToken, bool) {
+	values, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+	if err != nil || values.Get("sig") == "" || values.Get("sp") == "" {
+		return sasToken{}, false
+	}
+	return sasToken{
+		permissions:   values.Get("sp"),
+		resourceTypes: values.Get("srt"),
+		services:      values.Get("ss"),
+	}, true
+}
+
+// missing lists what the SAS lacks for the requirements, at the given end (source or destination) of the transfers
+func (t sasToken) missing(end string, location common.Location, requirements []sasRequirement) []string {
+	problems := make([]string, 0)
+
+	service, serviceName := byte('b'), "Blob"
+	if location == common.ELocation.File() {
+		service, serviceName = 'f', "File"
+	}
+	if t.services != "" && strings.IndexByte(t.services, service) == -1 {
+		problems = append(problems, fmt.Sprintf("the SAS of the %s is not for the %s service (ss=%s)", end, serviceName, t.services))
+	}
+
+	for _, r := range requirements {
+		if strings.IndexByte(t.permissions, r.permission.letter) == -1 {
+			problems = append(problems, fmt.Sprintf("the SAS of the %s lacks the %s permission (%c in sp=), which is needed %s",
+				end, r.permission.name, r.permission.letter, r.purpose))
+		} else if t.resourceTypes != "" && strings.IndexByte(t.resourceTypes, r.resourceType) == -1 {
+			problems = append(problems, fmt.Sprintf("the SAS of the %s doesn't allow %s on %s (%c in srt=), which is needed %s",
+				end, r.permission.name, sasResourceTypeName(r.resourceType), r.resourceType, r.purpose))
+		}
+	}
+	return problems
+}
+
+func sasResourceTypeName(resourceType byte) string {
+	switch resourceType {
+	case sasServiceResources:
+		return "the service"
+	case sasContainerResources:
+		return "containers"
+	default:
+		return "objects"
+	}
+}
+
+// sasValidation collects what the SASs of a job lack, so that the job fails before its enumeration, rather than in every one of its transfers
+type sasValidation struct {
+	problems []string
+}
+
+// check adds what the SAS lacks to the problems. A missing or unparsable SAS isn't checked, since the job may use another credential
+func (v *sasValidation) check(end string, location common.Location, sas string, requirements []sasRequirement) {
+	if sas == "" || len(requirements) == 0 {
+		return
+	}
+	if token, ok := parseSASToken(sas); ok {
+		v.problems = append(v.problems, token.missing(end, location, requirements)...)
+	}
+}
+
+func (v *sasValidation) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s. Give a SAS that allows this, or pass --%s if the SAS is of a kind that azcopy can't validate",
+		strings.Join(v.problems, "; "), ignoreSASValidationFlag)
+}
+
+// sourceListingRequirements is what listing a remote source needs, when it's certainly listed, which depends on its level:
+// an object may be a single blob or file, which is never listed
+func sourceListingRequirements(source string, location common.Location, knownDirectory bool) []sasRequirement {
+	level, err := determineLocationLevel(source, location, true)
+	if err != nil {
+		return nil
+	}
+
+	requirements := make([]sasRequirement, 0)
+	if level == ELocationLevel.Service() {
+		requirements = append(requirements, sasRequirement{sasList, sasServiceResources, "to list the containers of the source"})
+	}
+	if level != ELocationLevel.Object() || knownDirectory {
+		requirements = append(requirements, sasRequirement{sasList, sasContainerResources, "to list the source"})
+	}
+	return requirements
+}
+
+// validateCopySASPermissions checks that the SASs of a copy (or of a remove or a set-properties) allow what the job will do with them
+func validateCopySASPermissions(cooked cookedCopyCmdArgs, listOfFiles bool) error {
+	var v sasValidation
+	from, to := cooked.fromTo.From(), cooked.fromTo.To()
+
+	if from.IsRemote() {
+		_, sourceSAS, err := SplitAuthTokenFromResource(cooked.source, from)
+		if err != nil {
+			return nil // the source is validated, with a clearer error, when the job starts
+		}
+
+		requirements := make([]sasRequirement, 0)
+		switch cooked.fromTo {
+		case common.EFromTo.BlobTrash(), common.EFromTo.FileTrash(), common.EFromTo.BlobFSTrash():
+			requirements = append(requirements, sasRequirement{sasDelete, sasObjectResources, "to delete the files"})
+		case common.EFromTo.BlobNone():
+			// what setting the properties needs depends on the properties
+		default:
+			requirements = append(requirements, sasRequirement{sasRead, sasObjectResources, "to read the files"})
+			if cooked.deleteSourceAfterTransfer {
+				requirements = append(requirements, sasRequirement{sasDelete, sasObjectResources, "to delete the source files once they are transferred"})
+			}
+		}
+		if !listOfFiles && cooked.fromTo != common.EFromTo.BlobNone() {
+			requirements = append(requirements, sourceListingRequirements(cooked.source, from, cooked.stripTopDir)...)
+		}
+		v.check("source", from, sourceSAS, requirements)
+	}
+
+	if to.IsRemote() {
+		requirements := []sasRequirement{{sasWrite, sasObjectResources, "to write the files"}}
+		if cooked.forceWrite != common.EOverwriteOption.True() {
+			requirements = append(requirements, sasRequirement{sasRead, sasObjectResources, "to check whether the files exist at the destination, since overwrite is not true"})
+		}
+
+		if cooked.destinationShards != nil {
+			for _, shard := range cooked.destinationShards.shards {
+				v.check("destination shard "+shard.root, to, shard.sas, requirements)
+			}
+		} else if _, destinationSAS, err := SplitAuthTokenFromResource(cooked.destination, to); err == nil {
+			v.check("destination", to, destinationSAS, requirements)
+		}
+	}
+
+	return v.err()
+}
+
+// validateSyncSASPermissions checks that the SASs of a sync allow what it will do with them. Both ends of a sync are always listed
+func validateSyncSASPermissions(cooked cookedSyncCmdArgs) error {
+	var v sasValidation
+
+	v.check("source", cooked.fromTo.From(), cooked.sourceSAS, []sasRequirement{
+		{sasRead, sasObjectResources, "to read the files"},
+		{sasList, sasContainerResources, "to list the source"},
+	})
+
+	destinationRequirements := []sasRequirement{
+		{sasWrite, sasObjectResources, "to write the files"},
+		{sasList, sasContainerResources, "to list the destination, without which every file would be seen as missing from it, and copied again"},
+	}
+	if cooked.deleteDestination != common.EDeleteDestination.False() {
+		destinationRequirements = append(destinationRequirements, sasRequirement{sasDelete, sasObjectResources, "to delete the files that are not at the source, since delete-destination is set"})
+	}
+	v.check("destination", cooked.fromTo.To(), cooked.destinationSAS, destinationRequirements)
+
+	return v.err()
+}
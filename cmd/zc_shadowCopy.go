@@ -0,0 +1,96 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// shadowCopySet holds the Volume Shadow Copies (snapshots) that a job reads its local source from (--use-vss),
+// one per volume, so that files that other processes hold open or have locked can still be read.
+// The snapshots live until the process exits, when they are deleted.
+type shadowCopySet struct {
+	lock      sync.Mutex
+	snapshots map[string]shadowCopy // by volume, e.g. C:
+}
+
+type shadowCopy struct {
+	id string
+	// the device path of the snapshot, which is how its files are read, e.g. \\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3
+	devicePath string
+}
+
+func newShadowCopySet() *shadowCopySet {
+	return &shadowCopySet{snapshots: make(map[string]shadowCopy)}
+}
+
+// pathInSnapshot returns where localPath is found in the snapshot of its volume, taking the snapshot if it hasn't been yet
+func (s *shadowCopySet) pathInSnapshot(localPath string) (string, error) {
+	absPath, err := filepath.Abs(common.ToShortPath(localPath))
+	if err != nil {
+		return "", err
+	}
+
+	volume := filepath.VolumeName(absPath)
+	if volume == "" || strings.HasPrefix(volume, `\\`) {
+		return "", fmt.Errorf("cannot take a shadow copy of %s, since only the files of local volumes, such as C:, can be read from one", localPath)
+	}
+	volume = strings.ToUpper(volume)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snapshot, ok := s.snapshots[volume]
+	if !ok {
+		snapshot, err = createShadowCopy(volume + `\`)
+		if err != nil {
+			return "", fmt.Errorf("cannot take a shadow copy of %s: %w", volume, err)
+		}
+		s.snapshots[volume] = snapshot
+		glcm.Info(fmt.Sprintf("Reading the files of %s from its shadow copy %s", volume, snapshot.id))
+	}
+
+	return pathInShadowCopy(snapshot.devicePath, volume, absPath), nil
+}
+
+// deleteAll deletes the snapshots. Those that can't be deleted are left for the user to delete, e.g. with vssadmin
+func (s *shadowCopySet) deleteAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for volume, snapshot := range s.snapshots {
+		if err := deleteShadowCopy(snapshot.id); err != nil {
+			glcm.Info(fmt.Sprintf("Failed to delete the shadow copy %s of %s: %s", snapshot.id, volume, err))
+		}
+		delete(s.snapshots, volume)
+	}
+}
+
+// pathInShadowCopy maps absPath, which is on volume, to the same path in the snapshot of the volume at devicePath
+func pathInShadowCopy(devicePath, volume, absPath string) string {
+	rest := strings.TrimLeft(absPath[len(volume):], `\/`)
+	return strings.TrimRight(devicePath, `\`) + `\` + strings.ReplaceAll(rest, "/", `\`)
+}
@@ -0,0 +1,86 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const tokenElevation = 20 // the TokenElevation class of GetTokenInformation
+
+// checkShadowCopyPrivileges fails unless the process is elevated, since only administrators can take shadow copies
+func checkShadowCopyPrivileges() error {
+	token, err := syscall.OpenCurrentProcessToken()
+	if err != nil {
+		return err
+	}
+	defer token.Close()
+
+	var elevated uint32
+	var returnedLen uint32
+	err = syscall.GetTokenInformation(token, tokenElevation, (*byte)(unsafe.Pointer(&elevated)), uint32(unsafe.Sizeof(elevated)), &returnedLen)
+	if err != nil {
+		return err
+	}
+	if elevated == 0 {
+		return errors.New("use-vss requires AzCopy to run as an administrator (from an elevated prompt), since only administrators can take shadow copies")
+	}
+	return nil
+}
+
+// createShadowCopy takes a snapshot of volume (e.g. C:\) through WMI, which is what the Volume Shadow Copy Service
+// offers without a native requester
+func createShadowCopy(volume string) (shadowCopy, error) {
+	script := fmt.Sprintf(`$r = (Get-WmiObject -List Win32_ShadowCopy).Create('%s', 'ClientAccessible')
+if ($r.ReturnValue -ne 0) { throw "Win32_ShadowCopy.Create returned $($r.ReturnValue)" }
+$s = Get-WmiObject Win32_ShadowCopy -Filter "ID='$($r.ShadowID)'"
+Write-Output $s.ID
+Write-Output $s.DeviceObject`, volume)
+
+	output, err := runPowerShell(script)
+	if err != nil {
+		return shadowCopy{}, err
+	}
+
+	lines := strings.Fields(output)
+	if len(lines) != 2 {
+		return shadowCopy{}, fmt.Errorf("unexpected output from Win32_ShadowCopy: %s", output)
+	}
+	return shadowCopy{id: lines[0], devicePath: lines[1]}, nil
+}
+
+func deleteShadowCopy(id string) error {
+	_, err := runPowerShell(fmt.Sprintf(`Get-WmiObject Win32_ShadowCopy -Filter "ID='%s'" | ForEach-Object { $_.Delete() }`, id))
+	return err
+}
+
+func runPowerShell(script string) (string, error) {
+	output, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
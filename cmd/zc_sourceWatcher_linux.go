@@ -0,0 +1,135 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE | syscall.IN_DONT_FOLLOW
+
+// inotifyWatcher watches each directory of the source, since inotify doesn't watch a tree.
+// Its notifications share one queue, so when the queue overflows, it isn't known which directory lost them
+type inotifyWatcher struct {
+	root      string
+	recursive bool
+	fd        int
+	file      *os.File // the inotify instance, read through the poller, so that closing it ends the read
+	events    chan<- watchEvent
+
+	lock  sync.Mutex
+	paths map[int32]string // the relative path of each watched directory, by watch descriptor
+}
+
+func newSourceWatcher(root string, recursive bool, events chan<- watchEvent) (sourceWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &inotifyWatcher{
+		root:      root,
+		recursive: recursive,
+		fd:        fd,
+		file:      os.NewFile(uintptr(fd), "inotify"), // not its Fd(), which would make the reads block
+		events:    events,
+		paths:     make(map[int32]string),
+	}
+	if err = w.addDirectory(""); err != nil {
+		w.close()
+		return nil, err
+	}
+
+	go w.readEvents()
+	return w, nil
+}
+
+func (w *inotifyWatcher) addDirectory(relativePath string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, common.GenerateFullPath(w.root, relativePath), inotifyMask)
+	if err == syscall.ENOSPC {
+		return os.NewSyscallError("inotify_add_watch", errors.New("too many directories are watched, raise fs.inotify.max_user_watches"))
+	} else if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.paths[int32(wd)] = relativePath
+	return nil
+}
+
+func (w *inotifyWatcher) close() {
+	_ = w.file.Close()
+}
+
+func (w *inotifyWatcher) readEvents() {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buffer)
+		if err != nil {
+			return // closed
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			w.handle(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+func (w *inotifyWatcher) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		w.events <- watchEvent{overflow: true}
+		return
+	}
+
+	w.lock.Lock()
+	dir, watched := w.paths[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.paths, wd) // the directory was removed
+	}
+	w.lock.Unlock()
+
+	// the changes of a watched directory itself are reported by its parent
+	if !watched || name == "" {
+		return
+	}
+
+	relativePath := path.Join(dir, name)
+	if w.recursive && mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		// watched as soon as possible. What it got before that is found when it's compared with the destination
+		_ = w.addDirectory(relativePath)
+	}
+	w.events <- watchEvent{relativePath: relativePath}
+}
@@ -0,0 +1,119 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	readDirectoryChangesFilter = syscall.FILE_NOTIFY_CHANGE_FILE_NAME | syscall.FILE_NOTIFY_CHANGE_DIR_NAME | syscall.FILE_NOTIFY_CHANGE_ATTRIBUTES |
+		syscall.FILE_NOTIFY_CHANGE_SIZE | syscall.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+	errorNotifyEnumDir syscall.Errno = 1022 // ERROR_NOTIFY_ENUM_DIR: the changes didn't fit in the buffer, so they were dropped
+
+	closeWatcherKey = 1 // the completion key that tells the reading goroutine to stop
+)
+
+// readDirectoryChangesWatcher watches the whole tree of the source with one handle.
+// When its buffer overflows, it isn't known where the changes it dropped were
+type readDirectoryChangesWatcher struct {
+	recursive bool
+	handle    syscall.Handle
+	port      syscall.Handle // the completion port of handle
+	events    chan<- watchEvent
+}
+
+func newSourceWatcher(root string, recursive bool, events chan<- watchEvent) (sourceWatcher, error) {
+	rootPtr, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := syscall.CreateFile(rootPtr, syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil, syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := syscall.CreateIoCompletionPort(handle, 0, 0, 0)
+	if err != nil {
+		_ = syscall.CloseHandle(handle)
+		return nil, err
+	}
+
+	w := &readDirectoryChangesWatcher{recursive: recursive, handle: handle, port: port, events: events}
+	go w.readEvents()
+	return w, nil
+}
+
+// addDirectory does nothing, since the handle of the root watches the whole tree
+func (w *readDirectoryChangesWatcher) addDirectory(relativePath string) error {
+	return nil
+}
+
+func (w *readDirectoryChangesWatcher) close() {
+	_ = syscall.PostQueuedCompletionStatus(w.port, 0, closeWatcherKey, nil)
+}
+
+func (w *readDirectoryChangesWatcher) readEvents() {
+	defer func() {
+		_ = syscall.CancelIoEx(w.handle, nil)
+		_ = syscall.CloseHandle(w.handle)
+		_ = syscall.CloseHandle(w.port)
+	}()
+
+	buffer := make([]byte, 64*1024) // the most that a network share returns
+	overlapped := &syscall.Overlapped{}
+	for {
+		err := syscall.ReadDirectoryChanges(w.handle, &buffer[0], uint32(len(buffer)), w.recursive, readDirectoryChangesFilter, nil, overlapped, 0)
+		if err != nil {
+			return
+		}
+
+		var n uint32
+		var key uint32
+		var completed *syscall.Overlapped
+		err = syscall.GetQueuedCompletionStatus(w.port, &n, &key, &completed, syscall.INFINITE)
+		if key == closeWatcherKey {
+			return
+		} else if err == errorNotifyEnumDir || (err == nil && n == 0) {
+			w.events <- watchEvent{overflow: true}
+			continue
+		} else if err != nil {
+			return
+		}
+
+		for offset := uint32(0); ; {
+			info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buffer[offset]))
+			name := (*[syscall.MAX_LONG_PATH]uint16)(unsafe.Pointer(&info.FileName))[: info.FileNameLength/2 : info.FileNameLength/2]
+			w.events <- watchEvent{relativePath: strings.ReplaceAll(syscall.UTF16ToString(name), `\`, "/")}
+
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			offset += info.NextEntryOffset
+		}
+	}
+}
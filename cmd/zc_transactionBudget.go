@@ -0,0 +1,151 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+)
+
+// the most objects that one list request returns, which is what the listing of a remote source is predicted with
+const listPageSize = 5000
+
+// transactionBudget predicts the storage transactions of a copy as the enumeration finds its files, for --estimate-only,
+// and caps them (--max-transactions): the enumeration stops once the prediction goes over the cap, and the job is aborted
+// once the transactions that its transfers actually made do. A nil budget predicts nothing, and caps nothing.
+type transactionBudget struct {
+	// zero if not capped
+	max       uint64
+	fromTo    common.FromTo
+	blobType  common.BlobType
+	blockSize uint32
+	// whether each file is written under a temporary name, and renamed once it's complete
+	renames bool
+
+	files     uint64
+	predicted common.TransactionCounts
+	// how many files the listing of the destination found, if it was listed
+	destinationFiles uint64
+	// why the job was aborted, or empty if it wasn't
+	reason string
+}
+
+// newTransactionBudget returns nil when the transactions are neither capped nor estimated
+func newTransactionBudget(max uint64, estimate bool, fromTo common.FromTo, blobType common.BlobType, blockSize uint32) *transactionBudget {
+	if max == 0 && !estimate {
+		return nil
+	}
+	return &transactionBudget{max: max, fromTo: fromTo, blobType: blobType, blockSize: blockSize}
+}
+
+// add adds the transactions of the transfer to the prediction. The error it returns, once the prediction is over the cap, stops the enumeration
+func (b *transactionBudget) add(transfer common.CopyTransfer) error {
+	if b == nil {
+		return nil
+	}
+
+	b.files++
+	if transfer.ExistsAtDestination {
+		return nil // it's skipped without a request being made for it
+	}
+	b.predicted.Add(ste.PredictTransactions(b.fromTo, b.blobType, transfer.SourceSize, b.blockSize))
+	if b.renames {
+		b.predicted.Write++
+	}
+	if total := b.prediction().Total(); b.max > 0 && total > b.max {
+		return fmt.Errorf("the job would make at least %v storage transactions, which is more than the max-transactions of %v", total, b.max)
+	}
+	return nil
+}
+
+// prediction returns the transactions predicted so far, including the listing of a remote source
+func (b *transactionBudget) prediction() common.TransactionCounts {
+	prediction := b.predicted
+	if b.fromTo.From().IsRemote() {
+		prediction.List += (b.files + listPageSize - 1) / listPageSize
+	}
+	prediction.List += (b.destinationFiles + listPageSize - 1) / listPageSize
+	return prediction
+}
+
+// listedDestination adds the listing of the destination, which found the given number of files, to the prediction
+func (b *transactionBudget) listedDestination(files uint64) {
+	if b == nil {
+		return
+	}
+	b.destinationFiles = files
+}
+
+// assumptions says what the prediction takes for granted
+func (b *transactionBudget) assumptions() []string {
+	assumptions := ste.TransactionPredictionAssumptions(b.fromTo, b.blobType, b.blockSize)
+	if b.fromTo.From().IsRemote() {
+		assumptions = append([]string{fmt.Sprintf("The source is listed with one request per %v files, "+
+			"though it takes more when they are spread over many directories", listPageSize)}, assumptions...)
+	}
+	if b.destinationFiles > 0 {
+		assumptions = append(assumptions, fmt.Sprintf("The destination is listed with one request per %v files, "+
+			"and the files that are already there are skipped without any request", listPageSize))
+	}
+	if b.renames {
+		assumptions = append(assumptions, "Each file is written under a temporary name, and renamed with one more request once it's complete")
+	}
+	return assumptions
+}
+
+// check cancels the job once its transfers made more transactions than the cap
+func (b *transactionBudget) check(jobID common.JobID, summary common.ListJobSummaryResponse) {
+	if b == nil || b.max == 0 || b.reason != "" {
+		return
+	}
+
+	made := summary.Transactions.Total()
+	if made <= b.max {
+		return
+	}
+	b.reason = fmt.Sprintf("its transfers made %v storage transactions, which is more than the max-transactions of %v", made, b.max)
+
+	LogStdoutAndJobLog(fmt.Sprintf("Aborting the job, since %s.", b.reason))
+	if err := (cookedCancelCmdArgs{jobID: jobID}).process(); err != nil {
+		glcm.Info("Failed to abort the job " + jobID.String() + ": " + err.Error())
+	}
+}
+
+// reportAbort reflects in the summary of the finished job that it was aborted for making too many transactions (if it was)
+func (b *transactionBudget) reportAbort(summary *common.ListJobSummaryResponse) {
+	if b == nil || b.reason == "" || summary.FailFastReason != "" {
+		return
+	}
+	reportJobAborted(summary, b.reason)
+}
+
+func formatTransactionCounts(counts common.TransactionCounts) string {
+	return fmt.Sprintf("%v (List: %v, Read: %v, Write: %v, Other: %v)", counts.Total(), counts.List, counts.Read, counts.Write, counts.Other)
+}
+
+func formatTransactions(summary common.ListJobSummaryResponse) string {
+	if summary.Transactions.Total() == 0 {
+		return ""
+	}
+	return "\n\nStorage Transactions: " + formatTransactionCounts(summary.Transactions)
+}
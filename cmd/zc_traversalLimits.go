@@ -0,0 +1,151 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// traversalLimits bounds how far the traversers descend under the root of the enumeration:
+// how deep they go (--max-depth), and which directories they don't enter at all (--exclude-path, --prune-pattern,
+// and the --exclude-path-pattern patterns that end in /**),
+// so that nothing under those is listed only to be filtered out afterwards.
+// A nil traversalLimits doesn't bound anything.
+type traversalLimits struct {
+	// the deepest that a file can be, where the files directly under the root are at depth 1. Zero if there is no limit
+	maxDepth int
+	// relative paths that nothing is enumerated under, with the prefix semantics of --exclude-path
+	excludePaths []string
+	// patterns of the names of the directories that are not entered, wherever they are
+	prunePatterns []string
+	// path patterns of the directories that are not entered, which are the exclude path patterns that exclude everything under what they match
+	prunePathPatterns []string
+
+	atomicDirectoriesSkipped uint64
+}
+
+// newTraversalLimits returns nil when there is nothing to bound
+func newTraversalLimits(maxDepth int, excludePaths []string, prunePatterns []string, excludePathPatterns []string) *traversalLimits {
+	l := &traversalLimits{maxDepth: maxDepth}
+	for _, p := range excludePaths {
+		if p != "" {
+			l.excludePaths = append(l.excludePaths, p)
+		}
+	}
+	for _, p := range prunePatterns {
+		if p = strings.Trim(p, common.AZCOPY_PATH_SEPARATOR_STRING); p != "" {
+			l.prunePatterns = append(l.prunePatterns, p)
+		}
+	}
+	for _, p := range excludePathPatterns {
+		if strings.HasSuffix(p, common.AZCOPY_PATH_SEPARATOR_STRING+"**") {
+			l.prunePathPatterns = append(l.prunePathPatterns, strings.TrimSuffix(p, common.AZCOPY_PATH_SEPARATOR_STRING+"**"))
+		}
+	}
+	if l.maxDepth <= 0 && len(l.excludePaths) == 0 && len(l.prunePatterns) == 0 && len(l.prunePathPatterns) == 0 {
+		return nil
+	}
+	return l
+}
+
+// boundsDescent tells whether a traverser must walk the directories one by one, rather than list everything under the root at once
+func (l *traversalLimits) boundsDescent() bool {
+	return l != nil
+}
+
+func depthOf(relativePath string) int {
+	relativePath = strings.Trim(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+	if relativePath == "" {
+		return 0
+	}
+	return strings.Count(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING) + 1
+}
+
+// includesFileAt tells whether the file at relativePath (using the azcopy path separator) is within the depth limit
+func (l *traversalLimits) includesFileAt(relativePath string) bool {
+	return l == nil || l.maxDepth <= 0 || depthOf(relativePath) <= l.maxDepth
+}
+
+// entersDirectory tells whether the directory at relativePath (using the azcopy path separator) must be enumerated.
+// The directories that are not are counted as skipped.
+func (l *traversalLimits) entersDirectory(relativePath string) bool {
+	if l == nil {
+		return true
+	}
+
+	relativePath = strings.Trim(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+	if relativePath == "" {
+		return true // the root is always enumerated
+	}
+	if l.isPruned(relativePath) {
+		atomic.AddUint64(&l.atomicDirectoriesSkipped, 1)
+		return false
+	}
+	return true
+}
+
+func (l *traversalLimits) isPruned(relativePath string) bool {
+	// the files in a directory at the depth limit would be too deep
+	if l.maxDepth > 0 && depthOf(relativePath) >= l.maxDepth {
+		return true
+	}
+
+	// everything under the directory has a relative path that starts like this, so an exclude path that is a prefix of it excludes all of it
+	for _, p := range l.excludePaths {
+		if strings.HasPrefix(relativePath+common.AZCOPY_PATH_SEPARATOR_STRING, p) {
+			return true
+		}
+	}
+
+	name := relativePath[strings.LastIndex(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)+1:]
+	for _, pattern := range l.prunePatterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	for _, pattern := range l.prunePathPatterns {
+		if matchPathPattern(pattern, relativePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// directoriesSkipped is the number of directories that were not enumerated, since they were pruned or too deep
+func (l *traversalLimits) directoriesSkipped() uint64 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.atomicDirectoriesSkipped)
+}
+
+// formatDirectoriesSkipped is what the scan progress shows about the directories that were not enumerated
+func formatDirectoriesSkipped(l *traversalLimits) string {
+	if l == nil {
+		return ""
+	}
+	return fmt.Sprintf(", %v Directories Skipped", l.directoriesSkipped())
+}
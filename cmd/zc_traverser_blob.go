@@ -42,6 +42,22 @@ type blobTraverser struct {
 
 	// a generic function to notify that a new stored object has been enumerated
 	incrementEnumerationCounter func()
+
+	// where the paths that can't be enumerated are recorded; nil if they abort the enumeration
+	enumerationFailures *enumerationFailureTracker
+
+	// how far the traversal descends; nil if it goes all the way down
+	limits *traversalLimits
+
+	// what undeletes the soft-deleted blobs that are listed; nil if they aren't listed
+	deletedBlobs *deletedBlobRestorer
+
+	// the outcome of the request that tells whether rawURL is a single blob. It's kept, since both isDirectory and traverse need it,
+	// and a small blob would otherwise cost more in requests for its properties than in the request that downloads it
+	singleBlobChecked bool
+	singleBlobProps   *azblob.BlobGetPropertiesResponse
+	isSingleBlob      bool
+	singleBlobErr     error
 }
 
 func (t *blobTraverser) isDirectory(isSource bool) bool {
@@ -66,11 +82,19 @@ func (t *blobTraverser) isDirectory(isSource bool) bool {
 }
 
 func (t *blobTraverser) getPropertiesIfSingleBlob() (*azblob.BlobGetPropertiesResponse, bool, error) {
+	if !t.singleBlobChecked {
+		t.singleBlobProps, t.isSingleBlob, t.singleBlobErr = t.fetchPropertiesIfSingleBlob()
+		t.singleBlobChecked = true
+	}
+	return t.singleBlobProps, t.isSingleBlob, t.singleBlobErr
+}
+
+func (t *blobTraverser) fetchPropertiesIfSingleBlob() (*azblob.BlobGetPropertiesResponse, bool, error) {
 	blobURL := azblob.NewBlobURL(*t.rawURL, t.p)
 	blobProps, blobPropertiesErr := blobURL.GetProperties(t.ctx, azblob.BlobAccessConditions{})
 
 	// if there was no problem getting the properties, it means that we are looking at a single blob
-	if blobPropertiesErr == nil && !gCopyUtil.doesBlobRepresentAFolder(blobProps.NewMetadata()) {
+	if blobPropertiesErr == nil && !gCopyUtil.doesBlobRepresentAFolder(blobProps.ContentLength(), blobProps.NewMetadata()) {
 		return blobProps, true, blobPropertiesErr
 	}
 
@@ -142,67 +166,150 @@ func (t *blobTraverser) traverse(preprocessor objectMorpher, processor objectPro
 		searchPrefix += common.AZCOPY_PATH_SEPARATOR_STRING
 	}
 
+	// processBlob sends a listed blob to the processor, unless it represents a folder
+	processBlob := func(blobInfo azblob.BlobItem) error {
+		// if the blob represents a hdi folder, then skip it
+		if util.doesBlobRepresentAFolder(*blobInfo.Properties.ContentLength, blobInfo.Metadata) {
+			return nil
+		}
+
+		relativePath := strings.TrimPrefix(blobInfo.Name, searchPrefix)
+
+		// if recursive
+		if !t.recursive && strings.Contains(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING) {
+			return nil
+		}
+
+		storedObject := newStoredObject(
+			preprocessor,
+			getObjectNameOnly(blobInfo.Name),
+			relativePath,
+			blobInfo.Properties.LastModified,
+			*blobInfo.Properties.ContentLength,
+			blobInfo.Properties.ContentMD5,
+			blobInfo.Properties.BlobType,
+			blobUrlParts.ContainerName,
+		)
+
+		storedObject.contentDisposition = common.IffStringNotNil(blobInfo.Properties.ContentDisposition, "")
+		storedObject.cacheControl = common.IffStringNotNil(blobInfo.Properties.CacheControl, "")
+		storedObject.contentLanguage = common.IffStringNotNil(blobInfo.Properties.ContentLanguage, "")
+		storedObject.contentEncoding = common.IffStringNotNil(blobInfo.Properties.ContentEncoding, "")
+		storedObject.contentType = common.IffStringNotNil(blobInfo.Properties.ContentType, "")
+
+		storedObject.Metadata = common.FromAzBlobMetadataToCommonMetadata(blobInfo.Metadata)
+
+		storedObject.blobAccessTier = blobInfo.Properties.AccessTier
+
+		if t.incrementEnumerationCounter != nil {
+			t.incrementEnumerationCounter()
+		}
+
+		if t.deletedBlobs == nil {
+			return processIfPassedFilters(filters, storedObject, processor)
+		}
+		// a soft-deleted blob is only undeleted once it's known to pass the filters
+		if !passedFilters(filters, storedObject) || !t.deletedBlobs.restore(t.ctx, containerURL.NewBlobURL(blobInfo.Name), blobInfo.Deleted) {
+			return nil
+		}
+		return processor(storedObject)
+	}
+
+	if t.deletedBlobs.listsDeleted() {
+		// a blob may be listed both live and soft-deleted, but only one of them is processed
+		picker := t.deletedBlobs.newPicker(processBlob)
+		processBlob = picker.add
+		defer func() {
+			if err == nil {
+				err = picker.flush()
+			}
+		}()
+	}
+
+	if t.recursive && t.limits.boundsDescent() {
+		return t.traverseVirtualDirectories(containerURL, searchPrefix, processBlob)
+	}
+
+	listing := enumerationListingFrom(t.ctx)
 	for marker := (azblob.Marker{}); marker.NotDone(); {
+		if marker.Val != nil {
+			listing.reached(*marker.Val)
+		}
+
 		// look for all blobs that start with the prefix
 		// TODO optimize for the case where recursive is off
-		listBlob, err := containerURL.ListBlobsFlatSegment(t.ctx, marker,
-			azblob.ListBlobsSegmentOptions{Prefix: searchPrefix, Details: azblob.BlobListingDetails{Metadata: true}})
+		var listBlob *azblob.ListBlobsFlatSegmentResponse
+		err := listWithBackoff(t.ctx, common.URLExtension{URL: *t.rawURL}.RedactSecretQueryParamForLogging(), func() (err error) {
+			listBlob, err = containerURL.ListBlobsFlatSegment(t.ctx, marker,
+				azblob.ListBlobsSegmentOptions{Prefix: searchPrefix, Details: azblob.BlobListingDetails{Metadata: true, Deleted: t.deletedBlobs.listsDeleted()}})
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("cannot list blobs. Failed with error %s", err.Error())
+			// a flat listing can't skip over the part it failed on, so none of what's under the search prefix counts as enumerated
+			return t.enumerationFailures.record(common.URLExtension{URL: *t.rawURL}.RedactSecretQueryParamForLogging(), "",
+				fmt.Errorf("cannot list blobs. Failed with error %s", err.Error()))
 		}
 
 		// process the blobs returned in this result segment
 		for _, blobInfo := range listBlob.Segment.BlobItems {
-			// if the blob represents a hdi folder, then skip it
-			if util.doesBlobRepresentAFolder(blobInfo.Metadata) {
-				continue
+			processErr := processBlob(blobInfo)
+			if processErr != nil {
+				return processErr
 			}
+		}
+
+		marker = listBlob.NextMarker
+	}
 
-			relativePath := strings.TrimPrefix(blobInfo.Name, searchPrefix)
+	return
+}
 
-			// if recursive
-			if !t.recursive && strings.Contains(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING) {
-				continue
+// traverseVirtualDirectories lists the blobs one virtual directory at a time, rather than everything under the search prefix at once,
+// so that the virtual directories that are pruned or too deep are never listed
+func (t *blobTraverser) traverseVirtualDirectories(containerURL azblob.ContainerURL, searchPrefix string, processBlob func(azblob.BlobItem) error) error {
+	prefixes := []string{searchPrefix}
+	for len(prefixes) > 0 {
+		prefix := prefixes[len(prefixes)-1]
+		prefixes = prefixes[:len(prefixes)-1]
+
+		dirURL := containerURL.URL()
+		dirURL.Path = common.GenerateFullPath(dirURL.Path, prefix)
+		for marker := (azblob.Marker{}); marker.NotDone(); {
+			var listBlob *azblob.ListBlobsHierarchySegmentResponse
+			err := listWithBackoff(t.ctx, common.URLExtension{URL: dirURL}.RedactSecretQueryParamForLogging(), func() (err error) {
+				listBlob, err = containerURL.ListBlobsHierarchySegment(t.ctx, marker, common.AZCOPY_PATH_SEPARATOR_STRING,
+					azblob.ListBlobsSegmentOptions{Prefix: prefix, Details: azblob.BlobListingDetails{Metadata: true, Deleted: t.deletedBlobs.listsDeleted()}})
+				return err
+			})
+			if err != nil {
+				err = t.enumerationFailures.record(common.URLExtension{URL: dirURL}.RedactSecretQueryParamForLogging(),
+					strings.TrimPrefix(prefix, searchPrefix), fmt.Errorf("cannot list blobs. Failed with error %s", err.Error()))
+				if err != nil {
+					return err
+				}
+				break // skip the rest of this virtual directory, and go on with the ones that are left
 			}
 
-			storedObject := newStoredObject(
-				preprocessor,
-				getObjectNameOnly(blobInfo.Name),
-				relativePath,
-				blobInfo.Properties.LastModified,
-				*blobInfo.Properties.ContentLength,
-				blobInfo.Properties.ContentMD5,
-				blobInfo.Properties.BlobType,
-				blobUrlParts.ContainerName,
-			)
-
-			storedObject.contentDisposition = common.IffStringNotNil(blobInfo.Properties.ContentDisposition, "")
-			storedObject.cacheControl = common.IffStringNotNil(blobInfo.Properties.CacheControl, "")
-			storedObject.contentLanguage = common.IffStringNotNil(blobInfo.Properties.ContentLanguage, "")
-			storedObject.contentEncoding = common.IffStringNotNil(blobInfo.Properties.ContentEncoding, "")
-			storedObject.contentType = common.IffStringNotNil(blobInfo.Properties.ContentType, "")
-
-			storedObject.Metadata = common.FromAzBlobMetadataToCommonMetadata(blobInfo.Metadata)
-
-			storedObject.blobAccessTier = blobInfo.Properties.AccessTier
-
-			if t.incrementEnumerationCounter != nil {
-				t.incrementEnumerationCounter()
+			for _, blobInfo := range listBlob.Segment.BlobItems {
+				if err = processBlob(blobInfo); err != nil {
+					return err
+				}
 			}
 
-			processErr := processIfPassedFilters(filters, storedObject, processor)
-			if processErr != nil {
-				return processErr
+			for _, subdirectory := range listBlob.Segment.BlobPrefixes {
+				if t.limits.entersDirectory(strings.TrimPrefix(subdirectory.Name, searchPrefix)) {
+					prefixes = append(prefixes, subdirectory.Name)
+				}
 			}
-		}
 
-		marker = listBlob.NextMarker
+			marker = listBlob.NextMarker
+		}
 	}
 
-	return
+	return nil
 }
 
-func newBlobTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, recursive bool, incrementEnumerationCounter func()) (t *blobTraverser) {
-	t = &blobTraverser{rawURL: rawURL, p: p, ctx: ctx, recursive: recursive, incrementEnumerationCounter: incrementEnumerationCounter}
+func newBlobTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, recursive bool, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) (t *blobTraverser) {
+	t = &blobTraverser{rawURL: rawURL, p: p, ctx: ctx, recursive: recursive, incrementEnumerationCounter: incrementEnumerationCounter, enumerationFailures: enumerationFailures, limits: limits}
 	return
 }
@@ -39,12 +39,29 @@ type blobAccountTraverser struct {
 
 	// a generic function to notify that a new stored object has been enumerated
 	incrementEnumerationCounter func()
+
+	// where the paths that can't be enumerated are recorded; nil if they abort the enumeration
+	enumerationFailures *enumerationFailureTracker
+
+	// how far the traversal descends; nil if it goes all the way down
+	limits *traversalLimits
+
+	// which containers are looked into, besides those matching the pattern in the URL; nil if all of them are
+	containerFilter *containerNameFilter
+
+	// what undeletes the soft-deleted blobs that are listed; nil if they aren't listed
+	deletedBlobs *deletedBlobRestorer
 }
 
 func (t *blobAccountTraverser) isDirectory(isSource bool) bool {
 	return true // Returns true as account traversal is inherently folder-oriented and recursive.
 }
 
+func (t *blobAccountTraverser) setContainerFilter(f *containerNameFilter) {
+	t.containerFilter = f
+	t.cachedContainers = nil // in case they were listed without it
+}
+
 func (t *blobAccountTraverser) listContainers() ([]string, error) {
 	// a nil list also returns 0
 	if len(t.cachedContainers) == 0 {
@@ -70,6 +87,10 @@ func (t *blobAccountTraverser) listContainers() ([]string, error) {
 					}
 				}
 
+				if !t.containerFilter.picks(v.Name) {
+					continue
+				}
+
 				cList = append(cList, v.Name)
 			}
 
@@ -94,7 +115,8 @@ func (t *blobAccountTraverser) traverse(preprocessor objectMorpher, processor ob
 
 	for _, v := range cList {
 		containerURL := t.accountURL.NewContainerURL(v).URL()
-		containerTraverser := newBlobTraverser(&containerURL, t.p, t.ctx, true, t.incrementEnumerationCounter)
+		containerTraverser := newBlobTraverser(&containerURL, t.p, t.ctx, true, t.incrementEnumerationCounter, t.enumerationFailures, t.limits)
+		containerTraverser.deletedBlobs = t.deletedBlobs
 
 		preprocessorForThisChild := preprocessor.FollowedBy(newContainerDecorator(v))
 
@@ -109,7 +131,7 @@ func (t *blobAccountTraverser) traverse(preprocessor objectMorpher, processor ob
 	return nil
 }
 
-func newBlobAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, incrementEnumerationCounter func()) (t *blobAccountTraverser) {
+func newBlobAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) (t *blobAccountTraverser) {
 	bURLParts := azblob.NewBlobURLParts(*rawURL)
 	cPattern := bURLParts.ContainerName
 
@@ -118,7 +140,7 @@ func newBlobAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.C
 		bURLParts.ContainerName = ""
 	}
 
-	t = &blobAccountTraverser{p: p, ctx: ctx, incrementEnumerationCounter: incrementEnumerationCounter, accountURL: azblob.NewServiceURL(bURLParts.URL(), p), containerPattern: cPattern}
+	t = &blobAccountTraverser{p: p, ctx: ctx, incrementEnumerationCounter: incrementEnumerationCounter, enumerationFailures: enumerationFailures, limits: limits, accountURL: azblob.NewServiceURL(bURLParts.URL(), p), containerPattern: cPattern}
 
 	return
 }
@@ -41,15 +41,23 @@ type blobFSTraverser struct {
 
 	// Generic function to indicate that a new stored object has been enumerated
 	incrementEnumerationCounter func()
+
+	// where the paths that can't be enumerated are recorded; nil if they abort the enumeration
+	enumerationFailures *enumerationFailureTracker
+
+	// how far the traversal descends; nil if it goes all the way down
+	limits *traversalLimits
 }
 
-func newBlobFSTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, recursive bool, incrementEnumerationCounter func()) (t *blobFSTraverser) {
+func newBlobFSTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, recursive bool, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) (t *blobFSTraverser) {
 	t = &blobFSTraverser{
 		rawURL:                      rawURL,
 		p:                           p,
 		ctx:                         ctx,
 		recursive:                   recursive,
 		incrementEnumerationCounter: incrementEnumerationCounter,
+		enumerationFailures:         enumerationFailures,
+		limits:                      limits,
 	}
 	return
 }
@@ -123,46 +131,64 @@ func (t *blobFSTraverser) traverse(preprocessor objectMorpher, processor objectP
 		searchPrefix += common.AZCOPY_PATH_SEPARATOR_STRING
 	}
 
+	// processFile sends a listed file to the processor
+	processFile := func(directoryURL azbfs.DirectoryURL, v azbfs.Path) error {
+		storedObject := newStoredObject(
+			preprocessor,
+			getObjectNameOnly(*v.Name),
+			strings.TrimPrefix(*v.Name, searchPrefix),
+			v.LastModifiedTime(),
+			*v.ContentLength,
+			t.getContentMd5(t.ctx, directoryURL, v),
+			blobTypeNA,
+			bfsURLParts.FileSystemName,
+		)
+
+		/* TODO: Enable this code segment in the case we ever do BlobFS->Blob transfers.
+
+		I leave this here for the sake of feature parity in the future, and because it feels weird letting the other traversers have it but not this one.
+
+		pathProperties, err := dirUrl.NewFileURL(storedObject.relativePath).GetProperties(t.ctx)
+
+		if err == nil {
+			storedObject.contentDisposition = pathProperties.ContentDisposition()
+			storedObject.cacheControl = pathProperties.CacheControl()
+			storedObject.contentLanguage = pathProperties.ContentLanguage()
+			storedObject.contentEncoding = pathProperties.ContentEncoding()
+			storedObject.contentType = pathProperties.ContentType()
+		    storedObject.metadata ...
+		}*/
+
+		if t.incrementEnumerationCounter != nil {
+			t.incrementEnumerationCounter()
+		}
+
+		return processIfPassedFilters(filters, storedObject, processor)
+	}
+
+	if t.recursive && t.limits.boundsDescent() {
+		return t.traverseDirectories(dirUrl, searchPrefix, processFile)
+	}
+
+	listing := enumerationListingFrom(t.ctx)
 	for {
-		dlr, err := dirUrl.ListDirectorySegment(t.ctx, &marker, t.recursive)
+		listing.reached(marker)
+
+		var dlr *azbfs.DirectoryListResponse
+		err := listWithBackoff(t.ctx, common.URLExtension{URL: *t.rawURL}.RedactSecretQueryParamForLogging(), func() (err error) {
+			dlr, err = dirUrl.ListDirectorySegment(t.ctx, &marker, t.recursive)
+			return err
+		})
 
 		if err != nil {
-			return fmt.Errorf("could not list files. Failed with error %s", err.Error())
+			// the listing can't skip over the part it failed on, so none of what's under the directory counts as enumerated
+			return t.enumerationFailures.record(common.URLExtension{URL: *t.rawURL}.RedactSecretQueryParamForLogging(), "",
+				fmt.Errorf("could not list files. Failed with error %s", err.Error()))
 		}
 
 		for _, v := range dlr.Paths {
 			if v.IsDirectory == nil {
-				storedObject := newStoredObject(
-					preprocessor,
-					getObjectNameOnly(*v.Name),
-					strings.TrimPrefix(*v.Name, searchPrefix),
-					v.LastModifiedTime(),
-					*v.ContentLength,
-					t.getContentMd5(t.ctx, dirUrl, v),
-					blobTypeNA,
-					bfsURLParts.FileSystemName,
-				)
-
-				/* TODO: Enable this code segment in the case we ever do BlobFS->Blob transfers.
-
-				I leave this here for the sake of feature parity in the future, and because it feels weird letting the other traversers have it but not this one.
-
-				pathProperties, err := dirUrl.NewFileURL(storedObject.relativePath).GetProperties(t.ctx)
-
-				if err == nil {
-					storedObject.contentDisposition = pathProperties.ContentDisposition()
-					storedObject.cacheControl = pathProperties.CacheControl()
-					storedObject.contentLanguage = pathProperties.ContentLanguage()
-					storedObject.contentEncoding = pathProperties.ContentEncoding()
-					storedObject.contentType = pathProperties.ContentType()
-				    storedObject.metadata ...
-				}*/
-
-				if t.incrementEnumerationCounter != nil {
-					t.incrementEnumerationCounter()
-				}
-
-				err := processIfPassedFilters(filters, storedObject, processor)
+				err := processFile(dirUrl, v)
 				if err != nil {
 					return err
 				}
@@ -178,6 +204,51 @@ func (t *blobFSTraverser) traverse(preprocessor objectMorpher, processor objectP
 	return
 }
 
+// traverseDirectories lists the directories one at a time, rather than everything under the root at once,
+// so that the directories that are pruned or too deep are never listed
+func (t *blobFSTraverser) traverseDirectories(root azbfs.DirectoryURL, searchPrefix string, processFile func(azbfs.DirectoryURL, azbfs.Path) error) error {
+	fileSystemURL := root.FileSystemURL()
+	directories := []azbfs.DirectoryURL{root}
+	for len(directories) > 0 {
+		directoryURL := directories[len(directories)-1]
+		directories = directories[:len(directories)-1]
+
+		for marker := ""; ; {
+			var dlr *azbfs.DirectoryListResponse
+			err := listWithBackoff(t.ctx, common.URLExtension{URL: directoryURL.URL()}.RedactSecretQueryParamForLogging(), func() (err error) {
+				dlr, err = directoryURL.ListDirectorySegment(t.ctx, &marker, false)
+				return err
+			})
+			if err != nil {
+				err = t.enumerationFailures.record(common.URLExtension{URL: directoryURL.URL()}.RedactSecretQueryParamForLogging(),
+					strings.TrimPrefix(azbfs.NewBfsURLParts(directoryURL.URL()).DirectoryOrFilePath, searchPrefix),
+					fmt.Errorf("could not list files. Failed with error %s", err.Error()))
+				if err != nil {
+					return err
+				}
+				break // skip the rest of this directory, and go on with the ones that are left
+			}
+
+			for _, v := range dlr.Paths {
+				if v.IsDirectory == nil {
+					if err = processFile(directoryURL, v); err != nil {
+						return err
+					}
+				} else if *v.IsDirectory && t.limits.entersDirectory(strings.TrimPrefix(*v.Name, searchPrefix)) {
+					directories = append(directories, fileSystemURL.NewDirectoryURL(*v.Name))
+				}
+			}
+
+			marker = dlr.XMsContinuation()
+			if marker == "" { // do-while pattern
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
 // globalBlobFSMd5ValidationOption is an ugly workaround, to tweak performance of another ugly workaround (namely getContentMd5, below)
 var globalBlobFSMd5ValidationOption = common.EHashValidationOption.FailIfDifferentOrMissing() // default to strict, if not set
 
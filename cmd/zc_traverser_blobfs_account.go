@@ -42,12 +42,26 @@ type BlobFSAccountTraverser struct {
 
 	// a generic function to notify that a new stored object has been enumerated
 	incrementEnumerationCounter func()
+
+	// where the paths that can't be enumerated are recorded; nil if they abort the enumeration
+	enumerationFailures *enumerationFailureTracker
+
+	// how far the traversal descends; nil if it goes all the way down
+	limits *traversalLimits
+
+	// which filesystems are looked into, besides those matching the pattern in the URL; nil if all of them are
+	containerFilter *containerNameFilter
 }
 
 func (t *BlobFSAccountTraverser) isDirectory(isSource bool) bool {
 	return true // Returns true as account traversal is inherently folder-oriented and recursive.
 }
 
+func (t *BlobFSAccountTraverser) setContainerFilter(f *containerNameFilter) {
+	t.containerFilter = f
+	t.cachedFileSystems = nil // in case they were listed without it
+}
+
 func (t *BlobFSAccountTraverser) listContainers() ([]string, error) {
 	// a nil list also returns 0
 	if len(t.cachedFileSystems) == 0 {
@@ -83,6 +97,10 @@ func (t *BlobFSAccountTraverser) listContainers() ([]string, error) {
 					}
 				}
 
+				if !t.containerFilter.picks(fsName) {
+					continue
+				}
+
 				fsList = append(fsList, fsName)
 			}
 
@@ -105,7 +123,7 @@ func (t *BlobFSAccountTraverser) traverse(preprocessor objectMorpher, processor
 
 	for _, v := range fsList {
 		fileSystemURL := t.accountURL.NewFileSystemURL(v).URL()
-		fileSystemTraverser := newBlobFSTraverser(&fileSystemURL, t.p, t.ctx, true, t.incrementEnumerationCounter)
+		fileSystemTraverser := newBlobFSTraverser(&fileSystemURL, t.p, t.ctx, true, t.incrementEnumerationCounter, t.enumerationFailures, t.limits)
 
 		preprocessorForThisChild := preprocessor.FollowedBy(newContainerDecorator(v))
 
@@ -120,7 +138,7 @@ func (t *BlobFSAccountTraverser) traverse(preprocessor objectMorpher, processor
 	return nil
 }
 
-func newBlobFSAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, incrementEnumerationCounter func()) (t *BlobFSAccountTraverser) {
+func newBlobFSAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) (t *BlobFSAccountTraverser) {
 	bfsURLParts := azbfs.NewBfsURLParts(*rawURL)
 	fsPattern := bfsURLParts.FileSystemName
 
@@ -128,7 +146,7 @@ func newBlobFSAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context
 		bfsURLParts.FileSystemName = ""
 	}
 
-	t = &BlobFSAccountTraverser{p: p, ctx: ctx, incrementEnumerationCounter: incrementEnumerationCounter, accountURL: azbfs.NewServiceURL(bfsURLParts.URL(), p), fileSystemPattern: fsPattern}
+	t = &BlobFSAccountTraverser{p: p, ctx: ctx, incrementEnumerationCounter: incrementEnumerationCounter, enumerationFailures: enumerationFailures, limits: limits, accountURL: azbfs.NewServiceURL(bfsURLParts.URL(), p), fileSystemPattern: fsPattern}
 
 	return
 }
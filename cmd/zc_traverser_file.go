@@ -42,6 +42,12 @@ type fileTraverser struct {
 
 	// a generic function to notify that a new stored object has been enumerated
 	incrementEnumerationCounter func()
+
+	// where the paths that can't be enumerated are recorded; nil if they abort the enumeration
+	enumerationFailures *enumerationFailureTracker
+
+	// how far the traversal descends; nil if it goes all the way down
+	limits *traversalLimits
 }
 
 func (t *fileTraverser) isDirectory(bool) bool {
@@ -104,9 +110,18 @@ func (t *fileTraverser) traverse(preprocessor objectMorpher, processor objectPro
 	for currentDirURL, ok := dirStack.Pop(); ok; currentDirURL, ok = dirStack.Pop() {
 		// Perform list files and directories.
 		for marker := (azfile.Marker{}); marker.NotDone(); {
-			lResp, err := currentDirURL.ListFilesAndDirectoriesSegment(t.ctx, marker, azfile.ListFilesAndDirectoriesOptions{})
+			var lResp *azfile.ListFilesAndDirectoriesSegmentResponse
+			err := listWithBackoff(t.ctx, common.URLExtension{URL: currentDirURL.URL()}.RedactSecretQueryParamForLogging(), func() (err error) {
+				lResp, err = currentDirURL.ListFilesAndDirectoriesSegment(t.ctx, marker, azfile.ListFilesAndDirectoriesOptions{})
+				return err
+			})
 			if err != nil {
-				return fmt.Errorf("cannot list files due to reason %s", err)
+				err = t.enumerationFailures.record(common.URLExtension{URL: currentDirURL.URL()}.RedactSecretQueryParamForLogging(),
+					t.relativePathOf(currentDirURL.URL(), targetURLParts), fmt.Errorf("cannot list files due to reason %s", err))
+				if err != nil {
+					return err
+				}
+				break // skip the rest of this directory, and go on with the ones that are left on the stack
 			}
 
 			// Process the files returned in this segment.
@@ -114,9 +129,7 @@ func (t *fileTraverser) traverse(preprocessor objectMorpher, processor objectPro
 				f := currentDirURL.NewFileURL(fileInfo.Name)
 
 				// compute the relative path of the file with respect to the target directory
-				fileURLParts := azfile.NewFileURLParts(f.URL())
-				relativePath := strings.TrimPrefix(fileURLParts.DirectoryOrFilePath, targetURLParts.DirectoryOrFilePath)
-				relativePath = strings.TrimPrefix(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+				relativePath := t.relativePathOf(f.URL(), targetURLParts)
 
 				// We need to omit some properties if we don't get properties
 				// TODO: make it so we can (and must) call newStoredOBject here.
@@ -134,7 +147,10 @@ func (t *fileTraverser) traverse(preprocessor objectMorpher, processor objectPro
 				if t.getProperties {
 					fileProperties, err := f.GetProperties(t.ctx)
 					if err != nil {
-						return err
+						if err = t.enumerationFailures.record(common.URLExtension{URL: f.URL()}.RedactSecretQueryParamForLogging(), relativePath, err); err != nil {
+							return err
+						}
+						continue
 					}
 
 					// Leaving this on because it's free IO wise, and file->* is in the works
@@ -161,11 +177,13 @@ func (t *fileTraverser) traverse(preprocessor objectMorpher, processor objectPro
 				}
 			}
 
-			// If recursive is turned on, add sub directories.
+			// If recursive is turned on, add sub directories, unless they are pruned or too deep.
 			if t.recursive {
 				for _, dirInfo := range lResp.DirectoryItems {
 					d := currentDirURL.NewDirectoryURL(dirInfo.Name)
-					dirStack.Push(d)
+					if t.limits.entersDirectory(t.relativePathOf(d.URL(), targetURLParts)) {
+						dirStack.Push(d)
+					}
 				}
 			}
 			marker = lResp.NextMarker
@@ -175,7 +193,14 @@ func (t *fileTraverser) traverse(preprocessor objectMorpher, processor objectPro
 	return
 }
 
-func newFileTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, recursive, getProperties bool, incrementEnumerationCounter func()) (t *fileTraverser) {
-	t = &fileTraverser{rawURL: rawURL, p: p, ctx: ctx, recursive: recursive, getProperties: getProperties, incrementEnumerationCounter: incrementEnumerationCounter}
+// relativePathOf computes the path of a file or directory with respect to the target directory
+func (t *fileTraverser) relativePathOf(u url.URL, targetURLParts azfile.FileURLParts) string {
+	fileURLParts := azfile.NewFileURLParts(u)
+	relativePath := strings.TrimPrefix(fileURLParts.DirectoryOrFilePath, targetURLParts.DirectoryOrFilePath)
+	return strings.TrimPrefix(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
+}
+
+func newFileTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, recursive, getProperties bool, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) (t *fileTraverser) {
+	t = &fileTraverser{rawURL: rawURL, p: p, ctx: ctx, recursive: recursive, getProperties: getProperties, incrementEnumerationCounter: incrementEnumerationCounter, enumerationFailures: enumerationFailures, limits: limits}
 	return
 }
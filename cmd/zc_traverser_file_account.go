@@ -40,12 +40,26 @@ type fileAccountTraverser struct {
 
 	// a generic function to notify that a new stored object has been enumerated
 	incrementEnumerationCounter func()
+
+	// where the paths that can't be enumerated are recorded; nil if they abort the enumeration
+	enumerationFailures *enumerationFailureTracker
+
+	// how far the traversal descends; nil if it goes all the way down
+	limits *traversalLimits
+
+	// which shares are looked into, besides those matching the pattern in the URL; nil if all of them are
+	containerFilter *containerNameFilter
 }
 
 func (t *fileAccountTraverser) isDirectory(isSource bool) bool {
 	return true // Returns true as account traversal is inherently folder-oriented and recursive.
 }
 
+func (t *fileAccountTraverser) setContainerFilter(f *containerNameFilter) {
+	t.containerFilter = f
+	t.cachedShares = nil // in case they were listed without it
+}
+
 func (t *fileAccountTraverser) listContainers() ([]string, error) {
 	if len(t.cachedShares) == 0 {
 		marker := azfile.Marker{}
@@ -70,6 +84,10 @@ func (t *fileAccountTraverser) listContainers() ([]string, error) {
 					}
 				}
 
+				if !t.containerFilter.picks(v.Name) {
+					continue
+				}
+
 				shareList = append(shareList, v.Name)
 			}
 
@@ -93,7 +111,7 @@ func (t *fileAccountTraverser) traverse(preprocessor objectMorpher, processor ob
 
 	for _, v := range shareList {
 		shareURL := t.accountURL.NewShareURL(v).URL()
-		shareTraverser := newFileTraverser(&shareURL, t.p, t.ctx, true, t.getProperties, t.incrementEnumerationCounter)
+		shareTraverser := newFileTraverser(&shareURL, t.p, t.ctx, true, t.getProperties, t.incrementEnumerationCounter, t.enumerationFailures, t.limits)
 
 		preprocessorForThisChild := preprocessor.FollowedBy(newContainerDecorator(v))
 
@@ -108,7 +126,7 @@ func (t *fileAccountTraverser) traverse(preprocessor objectMorpher, processor ob
 	return nil
 }
 
-func newFileAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, getProperties bool, incrementEnumerationCounter func()) (t *fileAccountTraverser) {
+func newFileAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.Context, getProperties bool, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) (t *fileAccountTraverser) {
 	fURLparts := azfile.NewFileURLParts(*rawURL)
 	sPattern := fURLparts.ShareName
 
@@ -116,6 +134,6 @@ func newFileAccountTraverser(rawURL *url.URL, p pipeline.Pipeline, ctx context.C
 		fURLparts.ShareName = ""
 	}
 
-	t = &fileAccountTraverser{p: p, ctx: ctx, incrementEnumerationCounter: incrementEnumerationCounter, accountURL: azfile.NewServiceURL(fURLparts.URL(), p), sharePattern: sPattern, getProperties: getProperties}
+	t = &fileAccountTraverser{p: p, ctx: ctx, incrementEnumerationCounter: incrementEnumerationCounter, enumerationFailures: enumerationFailures, limits: limits, accountURL: azfile.NewServiceURL(fURLparts.URL(), p), sharePattern: sPattern, getProperties: getProperties}
 	return
 }
@@ -31,7 +31,7 @@ import (
 // a meta traverser that goes through a list of paths (potentially directory entities) and scans them one by one
 // behaves like a single traverser (basically a "traverser of traverser")
 type listTraverser struct {
-	listReader              chan string
+	listReader              chan listOfFilesEntry
 	recursive               bool
 	childTraverserGenerator childTraverserGenerator
 }
@@ -47,8 +47,9 @@ func (l *listTraverser) isDirectory(bool) bool {
 // Behavior demonstrated: https://play.golang.org/p/OYdvLmNWgwO
 func (l *listTraverser) traverse(preprocessor objectMorpher, processor objectProcessor, filters []objectFilter) (err error) {
 	// read a channel until it closes to get a list of objects
-	childPath, ok := <-l.listReader
-	for ; ok; childPath, ok = <-l.listReader {
+	entry, ok := <-l.listReader
+	for ; ok; entry, ok = <-l.listReader {
+		childPath := entry.Source
 
 		// fetch an appropriate traverser, and go through the child path, which could be
 		//   1. a single entity
@@ -73,7 +74,14 @@ func (l *listTraverser) traverse(preprocessor objectMorpher, processor objectPro
 		// case 2: child2 is a directory, and it has items under it such as child2/grandchild1
 		//         the relative path returned by the child traverser would be "grandchild1"
 		//         it should be "child2/grandchild1" instead
+		// the entry may also say where the child goes, and with what metadata, in which case the same goes for everything under it
 		childPreProcessor := func(object *storedObject) {
+			if entry.Destination != "" {
+				object.dstRelativePath = common.GenerateFullPath(entry.Destination, object.relativePath)
+			}
+			if len(entry.Metadata) > 0 {
+				object.Metadata = entry.Metadata
+			}
 			object.relativePath = common.GenerateFullPath(childPath, object.relativePath)
 		}
 		preProcessorForThisChild := preprocessor.FollowedBy(childPreProcessor)
@@ -88,7 +96,7 @@ func (l *listTraverser) traverse(preprocessor objectMorpher, processor objectPro
 }
 
 func newListTraverser(parent string, parentSAS string, parentType common.Location, credential *common.CredentialInfo, ctx *context.Context,
-	recursive, followSymlinks, getProperties bool, listChan chan string, incrementEnumerationCounter func()) resourceTraverser {
+	recursive, followSymlinks, getProperties bool, listChan chan listOfFilesEntry, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) resourceTraverser {
 	var traverserGenerator childTraverserGenerator
 
 	traverserGenerator = func(relativeChildPath string) (resourceTraverser, error) {
@@ -106,7 +114,7 @@ func newListTraverser(parent string, parentSAS string, parentType common.Locatio
 		}
 
 		// Construct a traverser that goes through the child
-		traverser, err := initResourceTraverser(source, parentType, ctx, credential, &followSymlinks, nil, recursive, getProperties, incrementEnumerationCounter)
+		traverser, err := initResourceTraverser(source, parentType, ctx, credential, &followSymlinks, nil, recursive, getProperties, incrementEnumerationCounter, enumerationFailures, limits)
 		if err != nil {
 			return nil, err
 		}
@@ -38,6 +38,12 @@ type localTraverser struct {
 
 	// a generic function to notify that a new stored object has been enumerated
 	incrementEnumerationCounter func()
+
+	// where the paths that can't be enumerated are recorded; nil if they abort the enumeration
+	enumerationFailures *enumerationFailureTracker
+
+	// how far the traversal descends; nil if it goes all the way down
+	limits *traversalLimits
 }
 
 func (t *localTraverser) isDirectory(bool) bool {
@@ -95,15 +101,15 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc) (err error) {
 		walkQueue = walkQueue[1:]
 
 		err = filepath.Walk(queueItem.fullPath, func(filePath string, fileInfo os.FileInfo, fileError error) error {
-			if fileError != nil {
-				glcm.Info(fmt.Sprintf("Accessing %s failed with error: %s", filePath, fileError))
-				return nil
-			}
-
 			computedRelativePath := strings.TrimPrefix(cleanLocalPath(filePath), cleanLocalPath(queueItem.fullPath))
 			computedRelativePath = cleanLocalPath(common.GenerateFullPath(queueItem.relativeBase, computedRelativePath))
 			computedRelativePath = strings.TrimPrefix(computedRelativePath, common.AZCOPY_PATH_SEPARATOR_STRING)
 
+			if fileError != nil {
+				// let walkFunc decide whether the path it couldn't access ends the walk
+				return walkFunc(common.GenerateFullPath(fullPath, computedRelativePath), fileInfo, fileError)
+			}
+
 			if fileInfo.Mode()&os.ModeSymlink != 0 {
 				result, err := filepath.EvalSymlinks(filePath)
 
@@ -143,10 +149,10 @@ func WalkWithSymlinks(fullPath string, walkFunc filepath.WalkFunc) (err error) {
 				}
 
 				if fileInfo.IsDir() {
-					// Add it to seen paths but ignore it otherwise.
+					// Add it to seen paths, and let walkFunc decide whether to walk it.
 					// This prevents walking it again if we've already seen the directory.
 					seenPaths[result] = true
-					return nil
+					return walkFunc(common.GenerateFullPath(fullPath, computedRelativePath), fileInfo, fileError)
 				}
 
 				if _, ok := seenPaths[result]; !ok {
@@ -192,16 +198,29 @@ func (t *localTraverser) traverse(preprocessor objectMorpher, processor objectPr
 	} else {
 		if t.recursive {
 			processFile := func(filePath string, fileInfo os.FileInfo, fileError error) error {
+				relPath := strings.TrimPrefix(strings.TrimPrefix(cleanLocalPath(filePath), cleanLocalPath(t.fullPath)), common.DeterminePathSeparator(t.fullPath))
+				azcopyRelPath := strings.ReplaceAll(relPath, common.DeterminePathSeparator(t.fullPath), common.AZCOPY_PATH_SEPARATOR_STRING)
+
 				if fileError != nil {
+					if t.enumerationFailures != nil {
+						// returning nil skips the unreadable directory, and the walk goes on to its siblings
+						return t.enumerationFailures.record(filePath, azcopyRelPath, fileError)
+					}
 					glcm.Info(fmt.Sprintf("Accessing %s failed with error: %s", filePath, fileError))
 					return nil
 				}
 
 				if fileInfo.IsDir() {
+					if !t.limits.entersDirectory(azcopyRelPath) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				if !t.limits.includesFileAt(azcopyRelPath) {
 					return nil
 				}
 
-				relPath := strings.TrimPrefix(strings.TrimPrefix(cleanLocalPath(filePath), cleanLocalPath(t.fullPath)), common.DeterminePathSeparator(t.fullPath))
 				if !t.followSymlinks && fileInfo.Mode()&os.ModeSymlink != 0 {
 					glcm.Info(fmt.Sprintf("Skipping over symlink at %s because --follow-symlinks is false", common.GenerateFullPath(t.fullPath, relPath)))
 					return nil
@@ -215,7 +234,7 @@ func (t *localTraverser) traverse(preprocessor objectMorpher, processor objectPr
 					newStoredObject(
 						preprocessor,
 						fileInfo.Name(),
-						strings.ReplaceAll(relPath, common.DeterminePathSeparator(t.fullPath), common.AZCOPY_PATH_SEPARATOR_STRING), // Consolidate relative paths to the azcopy path separator for sync
+						azcopyRelPath, // Consolidate relative paths to the azcopy path separator for sync
 						fileInfo.ModTime(),
 						fileInfo.Size(),
 						nil, // Local MD5s are taken in the STE
@@ -250,22 +269,21 @@ func (t *localTraverser) traverse(preprocessor objectMorpher, processor objectPr
 						// Evaluate the symlink
 						result, err := filepath.EvalSymlinks(symlinkPath)
 
-						if err != nil {
-							return err
+						if err == nil {
+							// Resolve the absolute file path of the symlink
+							result, err = filepath.Abs(result)
 						}
 
-						// Resolve the absolute file path of the symlink
-						result, err = filepath.Abs(result)
-
-						if err != nil {
-							return err
+						if err == nil {
+							// Replace the current FileInfo with
+							singleFile, err = os.Stat(result)
 						}
 
-						// Replace the current FileInfo with
-						singleFile, err = os.Stat(result)
-
 						if err != nil {
-							return err
+							if err = t.enumerationFailures.record(symlinkPath, relativePath, err); err != nil {
+								return err
+							}
+							continue
 						}
 					}
 				}
@@ -308,12 +326,14 @@ func consolidatePathSeparators(path string) string {
 	return strings.ReplaceAll(path, common.AZCOPY_PATH_SEPARATOR_STRING, pathSep)
 }
 
-func newLocalTraverser(fullPath string, recursive bool, followSymlinks bool, incrementEnumerationCounter func()) *localTraverser {
+func newLocalTraverser(fullPath string, recursive bool, followSymlinks bool, incrementEnumerationCounter func(), enumerationFailures *enumerationFailureTracker, limits *traversalLimits) *localTraverser {
 	traverser := localTraverser{
 		fullPath:                    cleanLocalPath(fullPath),
 		recursive:                   recursive,
 		followSymlinks:              followSymlinks,
-		incrementEnumerationCounter: incrementEnumerationCounter}
+		incrementEnumerationCounter: incrementEnumerationCounter,
+		enumerationFailures:         enumerationFailures,
+		limits:                      limits}
 	return &traverser
 }
 
@@ -0,0 +1,88 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type accountContainersSuite struct{}
+
+var _ = chk.Suite(&accountContainersSuite{})
+
+func (s *accountContainersSuite) TestContainerNameFilter(c *chk.C) {
+	f, err := newContainerNameFilter(nil, nil)
+	c.Assert(err, chk.IsNil)
+	c.Assert(f, chk.IsNil)
+	c.Assert(f.picks("anything"), chk.Equals, true)
+
+	f, err = newContainerNameFilter([]string{"logs-*", "backup"}, []string{"logs-old*"})
+	c.Assert(err, chk.IsNil)
+	c.Assert(f.picks("logs-2023"), chk.Equals, true)
+	c.Assert(f.picks("backup"), chk.Equals, true)
+	c.Assert(f.picks("logs-old-2019"), chk.Equals, false) // exclusions win
+	c.Assert(f.picks("images"), chk.Equals, false)
+
+	f, err = newContainerNameFilter(nil, []string{"$*"})
+	c.Assert(err, chk.IsNil)
+	c.Assert(f.picks("$logs"), chk.Equals, false)
+	c.Assert(f.picks("images"), chk.Equals, true)
+
+	_, err = newContainerNameFilter([]string{"[a-"}, nil)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *accountContainersSuite) TestSettingTheFilterDropsTheContainersListedWithoutIt(c *chk.C) {
+	t := &blobAccountTraverser{cachedContainers: []string{"a", "b"}}
+	var filterable containerFilterable = t
+	filterable.setContainerFilter(&containerNameFilter{include: []string{"a"}})
+	c.Assert(t.cachedContainers, chk.IsNil)
+
+	_, ok := interface{}(&fileAccountTraverser{}).(containerFilterable)
+	c.Assert(ok, chk.Equals, true)
+	_, ok = interface{}(&BlobFSAccountTraverser{}).(containerFilterable)
+	c.Assert(ok, chk.Equals, true)
+}
+
+func (s *accountContainersSuite) TestSummaryIsGroupedByContainer(c *chk.C) {
+	var nilTally *containerTally
+	nilTally.add("images", 10)
+	c.Assert(nilTally.summarize(common.ListJobSummaryResponse{}, common.ELocation.Blob()), chk.IsNil)
+
+	tally := newContainerTally()
+	tally.add("logs", 1024)
+	tally.add("images", 2048)
+	tally.add("images", 2048)
+
+	summary := common.ListJobSummaryResponse{FailedTransfers: []common.TransferDetail{
+		{Src: "https://account.blob.core.windows.net/images/cat.png", Dst: "/backup/images/cat.png"},
+	}}
+	summary.Containers = tally.summarize(summary, common.ELocation.Blob())
+	c.Assert(summary.Containers, chk.DeepEquals, []common.ContainerSummary{
+		{Name: "images", Transfers: 2, TransfersFailed: 1, Bytes: 4096},
+		{Name: "logs", Transfers: 1, Bytes: 1024},
+	})
+
+	c.Assert(formatContainerSummaries(summary), chk.Equals,
+		"\n\nTransfers by Container:\nimages: 2 transfers (1 failed), 4.00 KiB\nlogs: 1 transfers (0 failed), 1.00 KiB")
+	c.Assert(formatContainerSummaries(common.ListJobSummaryResponse{}), chk.Equals, "")
+}
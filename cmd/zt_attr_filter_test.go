@@ -0,0 +1,100 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"sort"
+
+	chk "gopkg.in/check.v1"
+)
+
+type attrFilterSuite struct{}
+
+var _ = chk.Suite(&attrFilterSuite{})
+
+func (s *attrFilterSuite) TestAttributesAreGivenByLetterOrName(c *chk.C) {
+	c.Assert(fileAttributeLetter("H"), chk.Equals, "H")
+	c.Assert(fileAttributeLetter("s"), chk.Equals, "S")
+	c.Assert(fileAttributeLetter("Hidden"), chk.Equals, "H")
+	c.Assert(fileAttributeLetter(" temporary "), chk.Equals, "T")
+	c.Assert(fileAttributeLetter("NotContentIndexed"), chk.Equals, "I")
+	c.Assert(fileAttributeLetter("Hiden"), chk.Equals, "")
+	c.Assert(fileAttributeLetter("X"), chk.Equals, "")
+
+	c.Assert(validateFileAttributes("exclude-attributes", []string{"Hidden", "System", "T"}), chk.IsNil)
+	c.Assert(validateFileAttributes("exclude-attributes", []string{"Hidden", "Sytem"}), chk.ErrorMatches, "exclude-attributes: 'Sytem' is not a file attribute.*")
+}
+
+func (s *attrFilterSuite) TestDotfileFilter(c *chk.C) {
+	filter := &dotfileFilter{}
+	c.Assert(filter.doesPass(storedObject{name: "file.txt", relativePath: "dir/file.txt"}), chk.Equals, true)
+	c.Assert(filter.doesPass(storedObject{name: "a.b", relativePath: "dir.d/a.b"}), chk.Equals, true)
+	c.Assert(filter.doesPass(storedObject{name: ".DS_Store", relativePath: "dir/.DS_Store"}), chk.Equals, false)
+	c.Assert(filter.doesPass(storedObject{name: "config", relativePath: ".git/config"}), chk.Equals, false)
+	c.Assert(filter.doesPass(storedObject{name: "HEAD", relativePath: "src/.git/refs/HEAD"}), chk.Equals, false)
+	c.Assert(filter.doesPass(storedObject{name: ".profile", relativePath: ""}), chk.Equals, false)
+}
+
+func (s *attrFilterSuite) TestOnlyTheAttributeAndDotfileExclusionsAreCounted(c *chk.C) {
+	var noCounter *excludedFileCounter
+	filters := []objectFilter{&excludeFilter{pattern: "*.tmp"}, &dotfileFilter{}}
+	c.Assert(noCounter.countExclusions(filters), chk.DeepEquals, filters)
+	c.Assert(formatFilesExcludedByAttributes(noCounter), chk.Equals, "")
+	c.Assert(formatExcludedFiles(noCounter), chk.Equals, "")
+
+	counter := newExcludedFileCounter()
+	counted := counter.countExclusions(filters)
+	for _, object := range []storedObject{
+		{name: "a.tmp", relativePath: "a.tmp"},
+		{name: ".b", relativePath: ".b"},
+		{name: "c", relativePath: ".d/c"},
+		{name: "e", relativePath: "e"},
+	} {
+		passedFilters(counted, object)
+	}
+	c.Assert(counter.count(), chk.Equals, uint64(2))
+	c.Assert(formatFilesExcludedByAttributes(counter), chk.Equals, ", 2 Files Excluded by Attributes")
+	c.Assert(formatExcludedFiles(counter), chk.Equals, "\n\nFiles Excluded by Attributes: 2")
+}
+
+func (s *attrFilterSuite) TestExclusionsAreCountedAtTheSourceOnly(c *chk.C) {
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	scenarioHelper{}.generateLocalFilesFromList(c, dir, []string{"top.txt", ".DS_Store", ".git/config", "src/main.go", "src/.env"})
+
+	counter := newExcludedFileCounter()
+	filters := []objectFilter{&dotfileFilter{}}
+	source := &exclusionCountingTraverser{resourceTraverser: newLocalTraverser(dir, true, false, func() {}, nil, nil), counter: counter}
+	destination := newLocalTraverser(dir, true, false, func() {}, nil, nil)
+
+	found := make([]string, 0)
+	for _, traverser := range []resourceTraverser{source, destination} {
+		c.Assert(traverser.traverse(noPreProccessor, func(object storedObject) error {
+			found = append(found, object.relativePath)
+			return nil
+		}, filters), chk.IsNil)
+	}
+
+	sort.Strings(found)
+	c.Assert(found, chk.DeepEquals, []string{"src/main.go", "src/main.go", "top.txt", "top.txt"})
+	c.Assert(counter.count(), chk.Equals, uint64(3))
+}
@@ -0,0 +1,125 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type attributesManifestSuite struct{}
+
+var _ = chk.Suite(&attributesManifestSuite{})
+
+func writeAttributesManifest(c *chk.C, content string) string {
+	f, err := ioutil.TempFile("", "attributesManifest*.json")
+	c.Assert(err, chk.IsNil)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	c.Assert(err, chk.IsNil)
+	return f.Name()
+}
+
+func (s *attributesManifestSuite) TestLongestMatchWins(c *chk.C) {
+	manifestPath := writeAttributesManifest(c, `{
+		"*.html": {"contentType": "text/html", "cacheControl": "no-cache"},
+		"static/*.css": {"cacheControl": "max-age=86400"},
+		"static/site.css": {"cacheControl": "max-age=60", "metadata": {"owner": "web"}},
+		"./downloads/*": {"contentDisposition": "attachment"},
+		"unused/*": {"contentType": "text/plain"}
+	}`)
+	defer os.Remove(manifestPath)
+
+	m, err := loadAttributesManifest(manifestPath)
+	c.Assert(err, chk.IsNil)
+
+	object := storedObject{name: "index.html", relativePath: "index.html"}
+	m.apply(&object)
+	c.Assert(object.contentType, chk.Equals, "text/html")
+	c.Assert(object.cacheControl, chk.Equals, "no-cache")
+
+	object = storedObject{name: "theme.css", relativePath: "static/theme.css"}
+	m.apply(&object)
+	c.Assert(object.cacheControl, chk.Equals, "max-age=86400")
+	c.Assert(object.Metadata, chk.IsNil)
+
+	object = storedObject{name: "site.css", relativePath: "static/site.css"}
+	m.apply(&object)
+	c.Assert(object.cacheControl, chk.Equals, "max-age=60")
+	c.Assert(object.Metadata, chk.DeepEquals, common.Metadata{"owner": "web"})
+
+	object = storedObject{name: "setup.exe", relativePath: "downloads/setup.exe"}
+	m.apply(&object)
+	c.Assert(object.contentDisposition, chk.Equals, "attachment")
+
+	// patterns don't match across directories, and files that nothing matches are left as they are
+	object = storedObject{name: "page.html", relativePath: "docs/page.html"}
+	m.apply(&object)
+	c.Assert(object.contentType, chk.Equals, "")
+
+	c.Assert(m.unmatched(), chk.DeepEquals, []string{"unused/*"})
+}
+
+func (s *attributesManifestSuite) TestSingleFileSourceMatchesByName(c *chk.C) {
+	manifestPath := writeAttributesManifest(c, `{"report.pdf": {"contentType": "application/pdf"}}`)
+	defer os.Remove(manifestPath)
+
+	m, err := loadAttributesManifest(manifestPath)
+	c.Assert(err, chk.IsNil)
+
+	object := storedObject{name: "report.pdf", relativePath: ""}
+	m.apply(&object)
+	c.Assert(object.contentType, chk.Equals, "application/pdf")
+	c.Assert(m.unmatched(), chk.HasLen, 0)
+}
+
+func (s *attributesManifestSuite) TestInvalidManifestsAreRejected(c *chk.C) {
+	for _, content := range []string{`not json`, `{"[": {"contentType": "text/plain"}}`, `{"a": {"metadata": "not an object"}}`} {
+		manifestPath := writeAttributesManifest(c, content)
+		_, err := loadAttributesManifest(manifestPath)
+		c.Assert(err, chk.NotNil)
+		os.Remove(manifestPath)
+	}
+
+	_, err := loadAttributesManifest(filepath.Join(os.TempDir(), "attributesManifest-missing.json"))
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *attributesManifestSuite) TestManifestIsOnlyForUploads(c *chk.C) {
+	manifestPath := writeAttributesManifest(c, `{"*": {"cacheControl": "no-cache"}}`)
+	defer os.Remove(manifestPath)
+
+	raw := getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.attributesManifest = manifestPath
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.attributesManifest, chk.NotNil)
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/blob")
+	raw.attributesManifest = manifestPath
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
@@ -0,0 +1,118 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-azcopy/ste"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+// The tests in this suite that need a storage account run against Azurite, on this machine, so they don't need the credentials or the CI
+// that the other tests do. Start Azurite (e.g. "azurite-blob --loose") before running them, or they are skipped.
+// AZURITE_BLOB_ENDPOINT overrides where it's expected to be.
+type azuriteSuite struct{}
+
+var _ = chk.Suite(&azuriteSuite{})
+
+const defaultAzuriteBlobEndpoint = "http://127.0.0.1:10000/devstoreaccount1"
+
+func getAzuriteServiceURL(c *chk.C) url.URL {
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		endpoint = defaultAzuriteBlobEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	c.Assert(err, chk.IsNil)
+
+	conn, err := net.DialTimeout("tcp", u.Host, time.Second)
+	if err != nil {
+		c.Skip("Azurite is not running at " + endpoint)
+	}
+	_ = conn.Close()
+	return *u
+}
+
+func (s *azuriteSuite) TestEmulatorURLsAreInferredAsBlob(c *chk.C) {
+	c.Assert(inferArgumentLocation("http://127.0.0.1:10000/devstoreaccount1/container"), chk.Equals, common.ELocation.Blob())
+	c.Assert(inferArgumentLocation(common.ReplaceLocalhostWithLoopbackIP("http://localhost:10000/devstoreaccount1/container/blob")),
+		chk.Equals, common.ELocation.Blob())
+
+	// an IP endpoint style URL on any other port may be any service
+	c.Assert(inferArgumentLocation("https://10.1.2.3:8443/account/share"), chk.Equals, common.ELocation.Unknown())
+}
+
+func (s *azuriteSuite) TestEmulatorURLPartsHaveAccountInPath(c *chk.C) {
+	raw := "http://127.0.0.1:10000/devstoreaccount1/container/dir/blob?sv=2019-02-02&sig=secret"
+
+	base, sas, err := SplitAuthTokenFromResource(raw, common.ELocation.Blob())
+	c.Assert(err, chk.IsNil)
+	c.Assert(base, chk.Equals, "http://127.0.0.1:10000/devstoreaccount1/container/dir/blob")
+	c.Assert(sas, chk.Not(chk.Equals), "")
+
+	container, err := GetContainerName(raw, common.ELocation.Blob())
+	c.Assert(err, chk.IsNil)
+	c.Assert(container, chk.Equals, "container")
+
+	root, err := GetAccountRoot(base, common.ELocation.Blob())
+	c.Assert(err, chk.IsNil)
+	c.Assert(root, chk.Equals, defaultAzuriteBlobEndpoint)
+
+	u, _ := url.Parse(raw)
+	c.Assert(copyHandlerUtil{}.urlIsContainerOrVirtualDirectory(u), chk.Equals, false)
+}
+
+func (s *azuriteSuite) TestSharedKeyAuthAndTraversalAgainstAzurite(c *chk.C) {
+	serviceURL := getAzuriteServiceURL(c)
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+
+	credentialType, _, err := getBlobCredentialType(ctx, serviceURL.String(), false, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(credentialType, chk.Equals, common.ECredentialType.SharedKey())
+	p, err := createBlobPipeline(ctx, common.CredentialInfo{CredentialType: credentialType})
+	c.Assert(err, chk.IsNil)
+
+	containerName := generateContainerName()
+	containerURL := azblob.NewServiceURL(serviceURL, p).NewContainerURL(containerName)
+	_, err = containerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+	c.Assert(err, chk.IsNil)
+	defer containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
+
+	for _, name := range []string{"a.txt", "dir/b.txt"} {
+		_, err = containerURL.NewBlockBlobURL(name).Upload(ctx, bytes.NewReader([]byte(name)), azblob.BlobHTTPHeaders{},
+			azblob.Metadata{}, azblob.BlobAccessConditions{})
+		c.Assert(err, chk.IsNil)
+	}
+
+	rawContainerURL := containerURL.URL()
+	traverser := newBlobTraverser(&rawContainerURL, p, ctx, true, func() {}, nil, nil)
+	processor := dummyProcessor{}
+	c.Assert(traverser.traverse(noPreProccessor, processor.process, nil), chk.IsNil)
+	c.Assert(len(processor.record), chk.Equals, 2)
+}
@@ -0,0 +1,134 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type compareSuite struct{}
+
+var _ = chk.Suite(&compareSuite{})
+
+// writeCompareFile writes a file with the given content and last modified time
+func writeCompareFile(c *chk.C, dir, name, content string, lmt time.Time) {
+	path := filepath.Join(dir, name)
+	c.Assert(os.MkdirAll(filepath.Dir(path), os.ModePerm), chk.IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), chk.IsNil)
+	c.Assert(os.Chtimes(path, lmt, lmt), chk.IsNil)
+}
+
+// generateCompareTrees returns two local directories that have one file of each kind of difference, and an identical one
+func generateCompareTrees(c *chk.C) (src, dst string) {
+	src, dst = scenarioHelper{}.generateLocalDirectory(c), scenarioHelper{}.generateLocalDirectory(c)
+	lmt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeCompareFile(c, src, "same.txt", "same", lmt)
+	writeCompareFile(c, dst, "same.txt", "same", lmt)
+	writeCompareFile(c, src, "dir/onlyAtSource.txt", "src", lmt)
+	writeCompareFile(c, dst, "onlyAtDestination.txt", "dst", lmt)
+	writeCompareFile(c, src, "size.txt", "longer", lmt)
+	writeCompareFile(c, dst, "size.txt", "short", lmt)
+	writeCompareFile(c, src, "content.txt", "aaaa", lmt) // same size and time, so only the hash tells them apart
+	writeCompareFile(c, dst, "content.txt", "bbbb", lmt)
+	writeCompareFile(c, src, "newer.txt", "cccc", lmt.Add(time.Minute))
+	writeCompareFile(c, dst, "newer.txt", "cccc", lmt)
+	return
+}
+
+func runCompare(c *chk.C, raw rawCompareCmdArgs) *compareSummary {
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	credInfo := common.CredentialInfo{CredentialType: common.ECredentialType.Anonymous()}
+	summary, err := cooked.compare(context.Background(), credInfo, credInfo)
+	c.Assert(err, chk.IsNil)
+	return summary
+}
+
+func (s *compareSuite) TestCompareLocalDirectoriesByLastModifiedTime(c *chk.C) {
+	src, dst := generateCompareTrees(c)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	report := filepath.Join(src, "..", filepath.Base(src)+".csv")
+	defer os.Remove(report)
+	summary := runCompare(c, rawCompareCmdArgs{src: src, dst: dst, recursive: true, reportFile: report})
+
+	c.Assert(summary.FilesScannedAtSource, chk.Equals, uint64(5))
+	c.Assert(summary.FilesScannedAtDestination, chk.Equals, uint64(5))
+	c.Assert(summary.FilesOnlyAtSource, chk.Equals, uint64(1))
+	c.Assert(summary.FilesOnlyAtDestination, chk.Equals, uint64(1))
+	c.Assert(summary.FilesThatDiffer, chk.Equals, uint64(2))
+	c.Assert(summary.IdenticalFiles, chk.Equals, uint64(2))
+
+	f, err := os.Open(report)
+	c.Assert(err, chk.IsNil)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	c.Assert(err, chk.IsNil)
+	c.Assert(rows[0], chk.DeepEquals, compareCsvHeader)
+
+	found := make(map[string][]string)
+	for _, row := range rows[1:] {
+		found[row[0]] = row
+	}
+	c.Assert(found, chk.HasLen, 4)
+	c.Assert(found["dir/onlyAtSource.txt"][1], chk.Equals, ECompareResult.OnlyAtSource().String())
+	c.Assert(found["dir/onlyAtSource.txt"][4], chk.Equals, "")
+	c.Assert(found["onlyAtDestination.txt"][1], chk.Equals, ECompareResult.OnlyAtDestination().String())
+	c.Assert(found["size.txt"][2], chk.Equals, compareReasonSize)
+	c.Assert(found["size.txt"][3:5], chk.DeepEquals, []string{"6", "5"})
+	c.Assert(found["newer.txt"][2], chk.Equals, compareReasonLastModifiedTime)
+}
+
+func (s *compareSuite) TestCompareLocalDirectoriesByHash(c *chk.C) {
+	src, dst := generateCompareTrees(c)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	summary := runCompare(c, rawCompareCmdArgs{src: src, dst: dst, recursive: true, compareHash: true})
+	c.Assert(summary.FilesThatDiffer, chk.Equals, uint64(2)) // size.txt and content.txt, but not newer.txt
+	c.Assert(summary.IdenticalFiles, chk.Equals, uint64(2))
+	c.Assert(summary.differenceCount(), chk.Equals, uint64(4))
+
+	// filters apply to both sides
+	summary = runCompare(c, rawCompareCmdArgs{src: src, dst: dst, recursive: true, compareHash: true, exclude: "content.txt;size.txt;onlyAt*"})
+	c.Assert(summary.differenceCount(), chk.Equals, uint64(0))
+	c.Assert(summary.IdenticalFiles, chk.Equals, uint64(2))
+}
+
+func (s *compareSuite) TestCompareRejectsUnsupportedLocations(c *chk.C) {
+	raw := rawCompareCmdArgs{src: "https://bucket.s3.amazonaws.com/object", dst: "/tmp/dst"}
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = rawCompareCmdArgs{src: "https://account.blob.core.windows.net/", dst: "/tmp/dst"}
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
@@ -0,0 +1,93 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type completionHookSuite struct{}
+
+var _ = chk.Suite(&completionHookSuite{})
+
+func (s *completionHookSuite) TestNoHookUnlessAsked(c *chk.C) {
+	c.Assert(newCompletionHook("", false), chk.IsNil)
+	c.Assert(newCompletionHook("  ", false), chk.IsNil)
+	c.Assert(newCompletionHook("", true), chk.NotNil)
+
+	// a nil hook does nothing
+	var hook *completionHook
+	hook.run(common.NewJobID(), common.EJobStatus.Completed())
+}
+
+func (s *completionHookSuite) TestCommandGetsTheJobIDAndStatus(c *chk.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("the command is run by sh")
+	}
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	dir, err := ioutil.TempDir("", "completionHook")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	outputFile := filepath.Join(dir, "status")
+
+	jobID := common.NewJobID()
+	hook := newCompletionHook("echo {jobid} {status} > "+outputFile, false)
+	c.Assert(hook.expandCommand(jobID, common.EJobStatus.CompletedWithErrors()), chk.Equals,
+		"echo "+jobID.String()+" CompletedWithErrors > "+outputFile)
+
+	// nothing runs until the job is done
+	hook.run(jobID, common.EJobStatus.InProgress())
+	_, err = os.Stat(outputFile)
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+
+	hook.run(jobID, common.EJobStatus.Cancelled())
+	written, err := ioutil.ReadFile(outputFile)
+	c.Assert(err, chk.IsNil)
+	c.Assert(strings.TrimSpace(string(written)), chk.Equals, jobID.String()+" Cancelled")
+}
+
+func (s *completionHookSuite) TestCommandOutputIsCapturedAndTimedOut(c *chk.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("the command is run by sh")
+	}
+
+	output, err := runCompletionCommand("echo out; echo err >&2", time.Minute)
+	c.Assert(err, chk.IsNil)
+	c.Assert(output, chk.Equals, "out\nerr")
+
+	_, err = runCompletionCommand("exit 3", time.Minute)
+	c.Assert(err, chk.NotNil)
+
+	start := time.Now()
+	_, err = runCompletionCommand("sleep 30", 100*time.Millisecond)
+	c.Assert(err, chk.ErrorMatches, "it was killed after running for .*")
+	c.Assert(time.Since(start) < 10*time.Second, chk.Equals, true)
+}
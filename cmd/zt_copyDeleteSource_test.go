@@ -0,0 +1,67 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type copyDeleteSourceSuite struct{}
+
+var _ = chk.Suite(&copyDeleteSourceSuite{})
+
+func (s *copyDeleteSourceSuite) TestDeleteSourceIsAcceptedForUploadsAndDownloads(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/blob")
+	raw.deleteSourceAfterTransfer = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.deleteSourceAfterTransfer, chk.Equals, true)
+
+	raw = getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container/blob")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.deleteSourceAfterTransfer = true
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.deleteSourceAfterTransfer, chk.Equals, true)
+}
+
+func (s *copyDeleteSourceSuite) TestDeleteSourceIsRejectedWhenDataWouldBeLost(c *chk.C) {
+	// the data is discarded
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", common.Dev_Null)
+	raw.deleteSourceAfterTransfer = true
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	// only part of the source is downloaded
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/blob")
+	raw.deleteSourceAfterTransfer = true
+	raw.offset = 100
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	// there is no way to delete the source
+	raw = getDefaultCopyRawInput("https://bucket.s3.amazonaws.com/object", "https://account.blob.core.windows.net/container/blob")
+	raw.fromTo = common.EFromTo.S3Blob().String()
+	raw.deleteSourceAfterTransfer = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
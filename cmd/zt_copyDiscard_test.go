@@ -0,0 +1,105 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type copyDiscardSuite struct{}
+
+var _ = chk.Suite(&copyDiscardSuite{})
+
+func (s *copyDiscardSuite) TestDiscardDownloadsToNullDevice(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container", "")
+	raw.recursive = true
+	raw.discard = true
+	raw.CheckLength = true
+
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination, chk.Equals, common.Dev_Null)
+	c.Assert(cooked.fromTo, chk.Equals, common.EFromTo.BlobLocal())
+	c.Assert(cooked.CheckLength, chk.Equals, true)
+}
+
+func (s *copyDiscardSuite) TestDiscardNeedsADownload(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container", "https://account.blob.core.windows.net/other")
+	raw.discard = true
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = getDefaultCopyRawInput("/tmp/source", "")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.discard = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *copyDiscardSuite) TestVerifyChecksumFileDiscardsTheDownload(c *chk.C) {
+	dir, err := ioutil.TempDir("", "verifyChecksumFile")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	checksumFile := filepath.Join(dir, "files.sha256")
+	c.Assert(ioutil.WriteFile(checksumFile, []byte{}, 0644), chk.IsNil)
+
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container", "")
+	raw.recursive = true
+	raw.verifyChecksumFile = checksumFile
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.destination, chk.Equals, common.Dev_Null)
+	c.Assert(cooked.checksumFile, chk.Equals, checksumFile)
+	c.Assert(cooked.verifyChecksums, chk.Equals, true)
+
+	// local trees are checked with sha256sum or md5sum
+	raw = getDefaultCopyRawInput(dir, "")
+	raw.verifyChecksumFile = checksumFile
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *copyDiscardSuite) TestGenerateChecksumFileNeedsTheDataToPassThrough(c *chk.C) {
+	dir, err := ioutil.TempDir("", "generateChecksumFile")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container", "https://account.blob.core.windows.net/other")
+	raw.generateChecksumFile = filepath.Join(dir, "files.sha256")
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container", dir)
+	raw.generateChecksumFile = filepath.Join(dir, "files.txt")
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw.generateChecksumFile = filepath.Join(dir, "files.md5")
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.checksumFile, chk.Equals, raw.generateChecksumFile)
+	c.Assert(cooked.verifyChecksums, chk.Equals, false)
+}
@@ -0,0 +1,134 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type copyEstimateSuite struct{}
+
+var _ = chk.Suite(&copyEstimateSuite{})
+
+func (s *copyEstimateSuite) TestEstimateAddsUpFilesBySize(c *chk.C) {
+	e := newTransferEstimate(0.1)
+	e.add(common.CopyTransfer{Source: "small", SourceSize: 10})
+	e.add(common.CopyTransfer{Source: "exactly1MiB", SourceSize: 1024 * 1024})
+	e.add(common.CopyTransfer{Source: "huge", SourceSize: 100 * 1024 * 1024 * 1024})
+	e.add(common.CopyTransfer{Source: "empty", SourceSize: 0})
+
+	c.Assert(e.FileCount, chk.Equals, uint64(4))
+	c.Assert(e.TotalBytes, chk.Equals, uint64(10+1024*1024+100*1024*1024*1024))
+	c.Assert(e.LargestFile, chk.Equals, "huge")
+
+	c.Assert(e.SizeBuckets[0].FileCount, chk.Equals, uint64(2)) // the upper bounds are exclusive
+	c.Assert(e.SizeBuckets[1].FileCount, chk.Equals, uint64(1))
+	last := e.SizeBuckets[len(e.SizeBuckets)-1]
+	c.Assert(last.MaxBytes, chk.Equals, int64(0))
+	c.Assert(last.FileCount, chk.Equals, uint64(1))
+
+	c.Assert(e.EstimatedEgressCost > 10 && e.EstimatedEgressCost < 10.1, chk.Equals, true)
+}
+
+func (s *copyEstimateSuite) TestEstimateJsonOutput(c *chk.C) {
+	e := newTransferEstimate(0)
+	e.add(common.CopyTransfer{Source: "a", SourceSize: 5})
+
+	var parsed map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(e.output(common.EOutputFormat.Json())), &parsed), chk.IsNil)
+	c.Assert(parsed["FileCount"], chk.Equals, float64(1))
+	c.Assert(parsed["TotalBytes"], chk.Equals, float64(5))
+	_, hasCost := parsed["EstimatedEgressCost"]
+	c.Assert(hasCost, chk.Equals, false) // no price was given
+}
+
+func (s *copyEstimateSuite) TestHeaderRulesAreOnlyPrintedWithEstimateOnly(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp/site", "https://account.blob.core.windows.net/$web")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.headerRules = []string{"pattern=*.html;cacheControl=no-cache", "pattern=assets/*;cacheControl=max-age=31536000, immutable"}
+	raw.printHeaderRules = true
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw.estimateOnly = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.headerRules, chk.HasLen, 2)
+
+	c.Assert(formatHeaderRuleMatch(storedObject{name: "logo.png", relativePath: "assets/logo.png"}, cooked.headerRules), chk.Equals,
+		"assets/logo.png: header rule 2 (pattern=assets/*;cacheControl=max-age=31536000, immutable)")
+	c.Assert(formatHeaderRuleMatch(storedObject{name: "robots.txt", relativePath: "robots.txt"}, cooked.headerRules), chk.Equals,
+		"robots.txt: no header rule")
+
+	// downloads have no use for them
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/blob")
+	raw.headerRules = []string{"pattern=*;cacheControl=no-cache"}
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *copyEstimateSuite) TestEstimateShowsWhereADirectoryLands(c *chk.C) {
+	e := newTransferEstimate(0)
+	e.add(common.CopyTransfer{Source: "/a.txt", Destination: "/photos/a.txt", SourceSize: 5})
+	c.Assert(e.output(common.EOutputFormat.Text()), chk.Not(chk.Matches), "(?s).*goes to.*")
+
+	e.Layout = "Copying /data/photos as https://account.blob.core.windows.net/container/photos"
+	c.Assert(e.output(common.EOutputFormat.Text()), chk.Matches,
+		"(?s).*Copying /data/photos as https://account.blob.core.windows.net/container/photos\n  e.g. a.txt goes to photos/a.txt under the destination\n.*")
+}
+
+func (s *copyEstimateSuite) TestLayoutTellsWhetherTheDirectoryOrItsContentsAreCopied(c *chk.C) {
+	raw := getDefaultCopyRawInput("/data/photos", "https://account.blob.core.windows.net/container/")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.asSubdir, chk.Equals, true) // unless asked otherwise
+	c.Assert(cooked.describeLayout(), chk.Equals, "Copying /data/photos as https://account.blob.core.windows.net/container/photos")
+
+	raw.asSubdir = false
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.asSubdir, chk.Equals, false)
+	cooked.stripTopDir = true // as the enumerator decides, once it finds the source is a directory
+	c.Assert(cooked.describeLayout(), chk.Equals, "Copying the contents of /data/photos into https://account.blob.core.windows.net/container/")
+	c.Assert(cooked.makeEscapedRelativePath(false, true, storedObject{name: "a.txt", relativePath: "a.txt"}), chk.Equals, "/a.txt")
+	cooked.stripTopDir = false
+	c.Assert(cooked.makeEscapedRelativePath(false, true, storedObject{name: "a.txt", relativePath: "a.txt"}), chk.Equals, "/photos/a.txt")
+
+	// the name of a remote directory is unescaped
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/my%20photos", "/tmp/out")
+	raw.fromTo = common.EFromTo.BlobLocal().String()
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.describeLayout(), chk.Equals, "Copying https://account.blob.core.windows.net/container/my%20photos as "+
+		"/tmp/out"+common.OS_PATH_SEPARATOR+"my photos")
+
+	// a trailing wildcard means the contents, whatever as-subdir is
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/photos/*", "/tmp/out")
+	raw.fromTo = common.EFromTo.BlobLocal().String()
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.stripTopDir, chk.Equals, true)
+	c.Assert(cooked.describeLayout(), chk.Equals, "Copying the contents of https://account.blob.core.windows.net/container/photos/ into /tmp/out")
+}
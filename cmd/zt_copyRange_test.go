@@ -0,0 +1,103 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type copyRangeSuite struct{}
+
+var _ = chk.Suite(&copyRangeSuite{})
+
+func (s *copyRangeSuite) TestRangeIsOnlyForSingleFileDownloads(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/part")
+	raw.offset = 100
+	raw.length = 10
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.rangedDownload, chk.Equals, true)
+	c.Assert(cooked.downloadOffset, chk.Equals, int64(100))
+	c.Assert(cooked.downloadLength, chk.Equals, int64(10))
+
+	raw.recursive = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/dir/*", "/tmp/part")
+	raw.offset = 100
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = getDefaultCopyRawInput("/tmp/source", "https://account.blob.core.windows.net/container/blob")
+	raw.fromTo = common.EFromTo.LocalBlob().String()
+	raw.offset = 100
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *copyRangeSuite) TestRangeIsValidated(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/part")
+	raw.offset = -1
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/part")
+	raw.length = 10
+	raw.autoDecompress = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	// without either flag, the whole file is downloaded as usual
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container/blob", "/tmp/part")
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.rangedDownload, chk.Equals, false)
+}
+
+func (s *copyRangeSuite) TestWindowIsClippedAtTheEndOfTheFile(c *chk.C) {
+	cca := cookedCopyCmdArgs{rangedDownload: true, downloadOffset: 100, downloadLength: 50}
+	window, err := cca.windowOf("blob", 1000)
+	c.Assert(err, chk.IsNil)
+	c.Assert(window, chk.Equals, int64(50))
+
+	window, err = cca.windowOf("blob", 120)
+	c.Assert(err, chk.IsNil)
+	c.Assert(window, chk.Equals, int64(20))
+
+	// no length is up to the end
+	cca.downloadLength = 0
+	window, err = cca.windowOf("blob", 1000)
+	c.Assert(err, chk.IsNil)
+	c.Assert(window, chk.Equals, int64(900))
+}
+
+func (s *copyRangeSuite) TestOffsetPastTheEndFailsWithTheSize(c *chk.C) {
+	cca := cookedCopyCmdArgs{rangedDownload: true, downloadOffset: 100}
+	_, err := cca.windowOf("blob", 100)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), chk.Matches, ".*100 bytes long.*")
+
+	_, err = cca.windowOf("blob", 42)
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), chk.Matches, ".*42 bytes long.*")
+}
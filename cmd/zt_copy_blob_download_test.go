@@ -171,7 +171,7 @@ func (s *cmdIntegrationSuite) TestDownloadAccount(c *chk.C) {
 
 	// Traverse the account ahead of time and determine the relative paths for testing.
 	relPaths := make([]string, 0) // Use a map for easy lookup
-	blobTraverser := newBlobAccountTraverser(&rawBSU, p, ctx, func() {})
+	blobTraverser := newBlobAccountTraverser(&rawBSU, p, ctx, func() {}, nil, nil)
 	processor := func(object storedObject) error {
 		// Append the container name to the relative path
 		relPath := "/" + object.containerName + "/" + object.relativePath
@@ -219,7 +219,7 @@ func (s *cmdIntegrationSuite) TestDownloadAccountWildcard(c *chk.C) {
 
 	// Traverse the account ahead of time and determine the relative paths for testing.
 	relPaths := make([]string, 0) // Use a map for easy lookup
-	blobTraverser := newBlobAccountTraverser(&rawBSU, p, ctx, func() {})
+	blobTraverser := newBlobAccountTraverser(&rawBSU, p, ctx, func() {}, nil, nil)
 	processor := func(object storedObject) error {
 		// Append the container name to the relative path
 		relPath := "/" + object.containerName + "/" + object.relativePath
@@ -71,6 +71,7 @@ func getDefaultRawCopyInput(src, dst string) rawCopyCmdArgs {
 		src:                            src,
 		dst:                            dst,
 		recursive:                      true,
+		asSubdir:                       true,
 		logVerbosity:                   defaultLogVerbosityForCopy,
 		output:                         defaultOutputFormatForCopy,
 		blobType:                       defaultBlobTypeForCopy,
@@ -82,6 +83,7 @@ func getDefaultRawCopyInput(src, dst string) rawCopyCmdArgs {
 		s2sPreserveProperties:          defaultS2SPreserveProperties,
 		s2sSourceChangeValidation:      defaultS2SSourceChangeValidation,
 		s2sInvalidMetadataHandleOption: defaultS2SInvalideMetadataHandleOption.String(),
+		s2sFallback:                    common.ES2SFallback.None().String(),
 		forceWrite:                     common.EOverwriteOption.True().String(),
 	}
 }
@@ -583,6 +585,41 @@ func (s *cmdIntegrationSuite) TestS2SCopyFromContainerToContainerPreserveBlobTie
 	})
 }
 
+// Copy from container to a container that doesn't exist yet, which takes on the source container's metadata.
+func (s *cmdIntegrationSuite) TestS2SCopyFromContainerToNewContainerPreservesContainerMetadata(c *chk.C) {
+	bsu := getBSU()
+
+	srcContainerURL, srcContainerName := createNewContainer(c, bsu)
+	defer deleteContainer(c, srcContainerURL)
+	c.Assert(srcContainerURL, chk.NotNil)
+
+	srcMetadata := azblob.Metadata{"project": "azcopy", "owner": "storage"}
+	_, err := srcContainerURL.SetMetadata(ctx, srcMetadata, azblob.ContainerAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	scenarioHelper{}.generateBlobsFromList(c, srcContainerURL, []string{"blob"}, blockBlobDefaultData)
+
+	// the destination is left for the copy to create
+	dstContainerURL, dstContainerName := getContainerURL(c, bsu)
+	defer deleteContainer(c, dstContainerURL)
+
+	// set up interceptor
+	mockedRPC := interceptor{}
+	Rpc = mockedRPC.intercept
+	mockedRPC.init()
+
+	rawSrcContainerURLWithSAS := scenarioHelper{}.getRawContainerURLWithSAS(c, srcContainerName)
+	rawDstContainerURLWithSAS := scenarioHelper{}.getRawContainerURLWithSAS(c, dstContainerName)
+	raw := getDefaultRawCopyInput(rawSrcContainerURLWithSAS.String(), rawDstContainerURLWithSAS.String())
+
+	runCopyAndVerify(c, raw, func(err error) {
+		c.Assert(err, chk.IsNil)
+
+		props, err := dstContainerURL.GetProperties(ctx, azblob.LeaseAccessConditions{})
+		c.Assert(err, chk.IsNil)
+		c.Assert(props.NewMetadata(), chk.DeepEquals, srcMetadata)
+	})
+}
+
 // Copy from container to container, and don't preserve blob tier.
 func (s *cmdIntegrationSuite) TestS2SCopyFromContainerToContainerNoPreserveBlobTier(c *chk.C) {
 	bsu := getBSU()
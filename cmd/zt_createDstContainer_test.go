@@ -0,0 +1,69 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type createDstContainerSuite struct{}
+
+var _ = chk.Suite(&createDstContainerSuite{})
+
+type responseErr struct{ statusCode int }
+
+func (e responseErr) Error() string { return http.StatusText(e.statusCode) }
+func (e responseErr) Response() *http.Response {
+	return &http.Response{StatusCode: e.statusCode}
+}
+
+func (s *createDstContainerSuite) TestForbiddenIsToldApartFromOtherFailures(c *chk.C) {
+	c.Assert(isForbidden(responseErr{http.StatusForbidden}), chk.Equals, true)
+	c.Assert(isForbidden(responseErr{http.StatusNotFound}), chk.Equals, false)
+	c.Assert(isForbidden(errors.New("dial tcp: connection refused")), chk.Equals, false)
+	c.Assert(isForbidden(nil), chk.Equals, false)
+}
+
+func (s *createDstContainerSuite) TestOptOutLeavesTheContainerAlone(c *chk.C) {
+	cca := cookedCopyCmdArgs{
+		fromTo:               common.EFromTo.LocalBlob(),
+		destination:          "https://account.blob.core.windows.net/missing",
+		noCreateDstContainer: true,
+	}
+
+	// it returns before any request is made, which would fail, as the account doesn't resolve
+	existing := make(map[string]bool)
+	c.Assert(cca.createDstContainer("missing", "", "https://account.invalid/missing", context.Background(), existing), chk.IsNil)
+	c.Assert(existing["missing"], chk.Equals, true)
+}
+
+func (s *createDstContainerSuite) TestNoCreateDestinationContainerFlag(c *chk.C) {
+	raw := getDefaultCopyRawInput("/tmp", "https://account.blob.core.windows.net/container")
+	raw.noCreateDstContainer = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.noCreateDstContainer, chk.Equals, true)
+}
@@ -0,0 +1,84 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type credentialExpirySuite struct{}
+
+var _ = chk.Suite(&credentialExpirySuite{})
+
+func (s *credentialExpirySuite) TestOnlyDeviceCodeLoginsAtATerminalAreAskedToLogInAgain(c *chk.C) {
+	c.Assert(newCredentialExpiryCheck(common.CredentialInfo{CredentialType: common.ECredentialType.Anonymous()}, true), chk.IsNil)
+
+	deviceCode := common.CredentialInfo{CredentialType: common.ECredentialType.OAuthToken(), OAuthTokenInfo: common.OAuthTokenInfo{Tenant: "tenant"}}
+	c.Assert(newCredentialExpiryCheck(deviceCode, true).interactive, chk.Equals, true)
+	c.Assert(newCredentialExpiryCheck(deviceCode, false).interactive, chk.Equals, false)
+
+	identity := deviceCode
+	identity.OAuthTokenInfo.Identity = true
+	c.Assert(newCredentialExpiryCheck(identity, true).interactive, chk.Equals, false)
+}
+
+func (s *credentialExpirySuite) TestJobIsPausedWhenTheTokenIsNotRenewed(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	paused := make([]common.JobID, 0)
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.PauseJob())
+		paused = append(paused, request.(common.JobID))
+		*(response.(*common.CancelPauseResumeResponse)) = common.CancelPauseResumeResponse{CancelledPauseResumed: true}
+	}
+	loggedIn := false
+	originalLogInAgain := logInAgain
+	defer func() { logInAgain = originalLogInAgain }()
+	logInAgain = func(tenantID, activeDirectoryEndpoint string) (*common.OAuthTokenInfo, error) {
+		loggedIn = true
+		return &common.OAuthTokenInfo{Tenant: tenantID}, nil
+	}
+
+	// the user, asked whether to log in again, doesn't say yes
+	check := newCredentialExpiryCheck(common.CredentialInfo{CredentialType: common.ECredentialType.OAuthToken()}, true)
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID(), JobStatus: common.EJobStatus.InProgress()}
+	check.check(summary.JobID, summary)
+	c.Assert(paused, chk.HasLen, 0)
+
+	summary.CredentialExpired, summary.CredentialExpiredError = true, "refresh token has expired"
+	check.check(summary.JobID, summary)
+	check.check(summary.JobID, summary) // only pauses once
+	c.Assert(loggedIn, chk.Equals, false)
+	c.Assert(paused, chk.DeepEquals, []common.JobID{summary.JobID})
+
+	// the command is done with the job once it has wound down
+	summary.CredentialExpired, summary.CredentialExpiredError, summary.JobStatus = false, "", common.EJobStatus.Paused()
+	c.Assert(check.pausedJobDone(summary), chk.Equals, false)
+	summary.PausedJobDrained = true
+	c.Assert(check.pausedJobDone(summary), chk.Equals, true)
+
+	check.reportPause(&summary)
+	c.Assert(formatCredentialExpiry(summary), chk.Matches, "(?s).*refresh token has expired.*azcopy jobs resume "+summary.JobID.String()+".*")
+}
@@ -0,0 +1,125 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type deletedBlobsSuite struct{}
+
+var _ = chk.Suite(&deletedBlobsSuite{})
+
+func (s *deletedBlobsSuite) pick(preferDeleted bool, listing []azblob.BlobItem) []azblob.BlobItem {
+	picked := make([]azblob.BlobItem, 0)
+	picker := newDeletedBlobRestorer(preferDeleted, false).newPicker(func(blobInfo azblob.BlobItem) error {
+		picked = append(picked, blobInfo)
+		return nil
+	})
+	for _, blobInfo := range listing {
+		if err := picker.add(blobInfo); err != nil {
+			panic(err)
+		}
+	}
+	if err := picker.flush(); err != nil {
+		panic(err)
+	}
+	return picked
+}
+
+func (s *deletedBlobsSuite) TestOneBlobIsPickedPerName(c *chk.C) {
+	listing := []azblob.BlobItem{
+		{Name: "a"},
+		{Name: "b", Deleted: true},
+		{Name: "c", Deleted: true},
+		{Name: "c"},
+		{Name: "d"},
+		{Name: "d", Snapshot: "2020-01-01T00:00:00.0000000Z"},
+		{Name: "e"},
+		{Name: "e", Deleted: true},
+	}
+
+	c.Assert(s.pick(false, listing), chk.DeepEquals, []azblob.BlobItem{
+		{Name: "a"}, {Name: "b", Deleted: true}, {Name: "c"}, {Name: "d"}, {Name: "e"},
+	})
+	c.Assert(s.pick(true, listing), chk.DeepEquals, []azblob.BlobItem{
+		{Name: "a"}, {Name: "b", Deleted: true}, {Name: "c", Deleted: true}, {Name: "d"}, {Name: "e", Deleted: true},
+	})
+	c.Assert(s.pick(false, nil), chk.HasLen, 0)
+}
+
+func (s *deletedBlobsSuite) TestNilRestorerListsNothingDeleted(c *chk.C) {
+	var restorer *deletedBlobRestorer
+	c.Assert(restorer.listsDeleted(), chk.Equals, false)
+	c.Assert(restorer.restoresOnly(), chk.Equals, false)
+	c.Assert(formatBlobsUndeleted(restorer), chk.Equals, "")
+
+	traverser := &blobTraverser{}
+	restorer.attachTo(traverser)
+	c.Assert(traverser.deletedBlobs, chk.IsNil)
+
+	restorer = newDeletedBlobRestorer(false, false)
+	restorer.attachTo(traverser)
+	c.Assert(traverser.deletedBlobs, chk.Equals, restorer)
+	c.Assert(formatBlobsUndeleted(restorer), chk.Equals, "\n\nBlobs Undeleted: 0\nBlobs That Could Not Be Undeleted: 0")
+}
+
+func (s *deletedBlobsSuite) TestCookIncludeDeleted(c *chk.C) {
+	raw := getDefaultCopyRawInput("https://account.blob.core.windows.net/container", "/tmp/restored")
+	raw.recursive = true
+	raw.includeDeleted = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.deletedBlobs.listsDeleted(), chk.Equals, true)
+	c.Assert(cooked.deletedBlobs.restoresOnly(), chk.Equals, false)
+
+	// restoring in place takes no destination
+	raw.dst = ""
+	raw.restoreInPlace = true
+	cooked, err = raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.deletedBlobs.restoresOnly(), chk.Equals, true)
+	c.Assert(cooked.destination, chk.Equals, common.Dev_Null)
+
+	raw.dst = "/tmp/restored"
+	_, err = raw.cook()
+	c.Assert(err, chk.ErrorMatches, "a destination cannot be given with restore-in-place.*")
+
+	// estimating mustn't change the source
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container", "/tmp/restored")
+	raw.includeDeleted = true
+	raw.estimateOnly = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = getDefaultCopyRawInput("/tmp/site", "https://account.blob.core.windows.net/container")
+	raw.includeDeleted = true
+	_, err = raw.cook()
+	c.Assert(err, chk.ErrorMatches, ".*only supported when the source is Azure Blob")
+
+	raw = getDefaultCopyRawInput("https://account.blob.core.windows.net/container", "/tmp/restored")
+	raw.preferDeletedVersion = true
+	_, err = raw.cook()
+	c.Assert(err, chk.ErrorMatches, "prefer-deleted-version is only supported with.*")
+}
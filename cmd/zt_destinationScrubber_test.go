@@ -0,0 +1,127 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type destinationScrubberSuite struct{}
+
+var _ = chk.Suite(&destinationScrubberSuite{})
+
+func (s *destinationScrubberSuite) TestOnlyTransfersThatWereNotCompletedAreScrubbed(c *chk.C) {
+	c.Assert(needsScrubbing(common.ETransferStatus.Started()), chk.Equals, true)
+	c.Assert(needsScrubbing(common.ETransferStatus.Failed()), chk.Equals, true)
+	c.Assert(needsScrubbing(common.ETransferStatus.ShareFull()), chk.Equals, true)
+
+	c.Assert(needsScrubbing(common.ETransferStatus.NotStarted()), chk.Equals, false)
+	c.Assert(needsScrubbing(common.ETransferStatus.Success()), chk.Equals, false)
+	c.Assert(needsScrubbing(common.ETransferStatus.SkippedFileAlreadyExists()), chk.Equals, false)
+	c.Assert(needsScrubbing(common.ETransferStatus.DestinationBusy()), chk.Equals, false)
+}
+
+func (s *destinationScrubberSuite) TestScrubRemovesWhatTheJobLeftAtLocalDestinations(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	scenarioHelper{}.generateLocalFilesFromList(c, dir, []string{"partial", "untouched", "done", "skipped"})
+	jobStart := time.Now().Add(-time.Minute)
+	c.Assert(os.Chtimes(filepath.Join(dir, "untouched"), jobStart.Add(-time.Hour), jobStart.Add(-time.Hour)), chk.IsNil)
+
+	transfers := []common.TransferDetail{
+		{Dst: filepath.Join(dir, "partial"), TransferStatus: common.ETransferStatus.Started()},
+		{Dst: filepath.Join(dir, "untouched"), TransferStatus: common.ETransferStatus.Failed()},
+		{Dst: filepath.Join(dir, "done"), TransferStatus: common.ETransferStatus.Success()},
+		{Dst: filepath.Join(dir, "skipped"), TransferStatus: common.ETransferStatus.SkippedFileAlreadyExists()},
+		{Dst: filepath.Join(dir, "never-created"), TransferStatus: common.ETransferStatus.Started()},
+	}
+
+	reportPath := filepath.Join(dir, "report.log")
+	scrubber, err := newDestinationScrubber(context.Background(), common.ELocation.Local(), nil, jobStart, "", "", reportPath)
+	c.Assert(err, chk.IsNil)
+	scrubber.scrubAll(transfers)
+	c.Assert(scrubber.close(), chk.IsNil)
+
+	c.Assert(scrubber.removed(), chk.Equals, uint32(1))
+	c.Assert(scrubber.clean(), chk.Equals, uint32(2))
+	c.Assert(scrubber.failed(), chk.Equals, uint32(0))
+
+	_, err = os.Stat(filepath.Join(dir, "partial"))
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+	for _, kept := range []string{"untouched", "done", "skipped"} {
+		_, err = os.Stat(filepath.Join(dir, kept))
+		c.Assert(err, chk.IsNil)
+	}
+
+	report, err := ioutil.ReadFile(reportPath)
+	c.Assert(err, chk.IsNil)
+	lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+	sort.Strings(lines)
+	c.Assert(lines, chk.DeepEquals, []string{
+		filepath.Join(dir, "never-created") + ": " + scrubbedClean,
+		filepath.Join(dir, "partial") + ": " + scrubbedRemoved,
+		filepath.Join(dir, "untouched") + ": " + scrubbedClean,
+	})
+}
+
+func (s *destinationScrubberSuite) TestScrubCarriesOnFromWhereTheLastOneGotTo(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	scenarioHelper{}.generateLocalFilesFromList(c, dir, []string{"first", "second"})
+	transfers := []common.TransferDetail{
+		{Dst: filepath.Join(dir, "first"), TransferStatus: common.ETransferStatus.Started()},
+		{Dst: filepath.Join(dir, "second"), TransferStatus: common.ETransferStatus.Started()},
+	}
+
+	// the first scrub got as far as the first file
+	reportPath := filepath.Join(dir, "report.log")
+	c.Assert(ioutil.WriteFile(reportPath, []byte(filepath.Join(dir, "first")+": "+scrubbedRemoved+"\n"), 0644), chk.IsNil)
+
+	scrubber, err := newDestinationScrubber(context.Background(), common.ELocation.Local(), nil, time.Now().Add(-time.Minute), "", "", reportPath)
+	c.Assert(err, chk.IsNil)
+	scrubber.scrubAll(transfers)
+	c.Assert(scrubber.close(), chk.IsNil)
+
+	c.Assert(scrubber.removed(), chk.Equals, uint32(1))
+	_, err = os.Stat(filepath.Join(dir, "first"))
+	c.Assert(err, chk.IsNil) // not looked at again
+	_, err = os.Stat(filepath.Join(dir, "second"))
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+
+	report, err := ioutil.ReadFile(reportPath)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(report), chk.Equals, filepath.Join(dir, "first")+": "+scrubbedRemoved+"\n"+filepath.Join(dir, "second")+": "+scrubbedRemoved+"\n")
+}
@@ -0,0 +1,156 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type destinationShardsSuite struct{}
+
+var _ = chk.Suite(&destinationShardsSuite{})
+
+const (
+	shardA = "https://accounta.blob.core.windows.net/data"
+	shardB = "https://accountb.blob.core.windows.net/data"
+	shardC = "https://accountc.blob.core.windows.net/data"
+)
+
+func (s *destinationShardsSuite) TestParseDestinationShards(c *chk.C) {
+	shards, err := parseDestinationShards(shardA + "?sv=2019-02-02&sig=a;" + shardB + "/?sig=b; ")
+	c.Assert(err, chk.IsNil)
+	c.Assert(shards.shards, chk.DeepEquals, []destinationShard{{root: shardA, sas: "sig=a&sv=2019-02-02"}, {root: shardB, sas: "sig=b"}})
+	c.Assert(shards.first(), chk.Equals, shardA+"?sig=a&sv=2019-02-02")
+	c.Assert(shards.sasByRoot(), chk.DeepEquals, map[string]string{shardA: "sig=a&sv=2019-02-02", shardB: "sig=b"})
+
+	for _, list := range []string{
+		shardA,                              // one container isn't sharded
+		shardA + ";" + shardA + "?sig=a",    // the same container twice
+		shardA + ";" + shardB + "/file.txt", // a blob
+		shardA + ";accountb/data",           // not a URL
+	} {
+		_, err = parseDestinationShards(list)
+		c.Assert(err, chk.NotNil, chk.Commentf(list))
+	}
+}
+
+func (s *destinationShardsSuite) TestShardOfIsTheHashOfThePath(c *chk.C) {
+	shards, err := parseDestinationShards(strings.Join([]string{shardA, shardB, shardC}, ";"))
+	c.Assert(err, chk.IsNil)
+
+	used := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		path := fmt.Sprintf("dir/file%d.txt", i)
+		h := fnv.New32a()
+		h.Write([]byte(path))
+		c.Assert(shards.shardOf(path), chk.Equals, int(h.Sum32()%3))
+		c.Assert(shards.shardOf(path), chk.Equals, shards.shardOf(path))
+		used[shards.shardOf(path)] = true
+	}
+	c.Assert(used, chk.HasLen, 3)
+}
+
+func (s *destinationShardsSuite) TestEachPartGoesToOneShard(c *chk.C) {
+	parts := make([]common.CopyJobPartOrderRequest, 0)
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.CopyJobPartOrder())
+		part := *request.(*common.CopyJobPartOrderRequest)
+		part.Transfers = append([]common.CopyTransfer{}, part.Transfers...)
+		parts = append(parts, part)
+		*(response.(*common.CopyJobPartOrderResponse)) = common.CopyJobPartOrderResponse{JobStarted: true}
+	}
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	defer func() { Rpc = mockedRPC.intercept }()
+
+	logDir, err := ioutil.TempDir("", "destinationShards")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(logDir)
+	originalLogDir := azcopyLogPathFolder
+	azcopyLogPathFolder = logDir
+	defer func() { azcopyLogPathFolder = originalLogDir }()
+
+	shards, err := parseDestinationShards(shardA + "?sig=a;" + shardB + "?sig=b;" + shardC + "?sig=c")
+	c.Assert(err, chk.IsNil)
+	cca := &cookedCopyCmdArgs{jobID: common.NewJobID(), destinationShards: shards}
+	order := common.CopyJobPartOrderRequest{JobID: cca.jobID, DestinationRoot: shardA}
+
+	paths := []string{"a.txt", "dir/b.txt", "dir/c%20d.txt", "e.txt", "f.txt", "g.txt"}
+	for _, p := range paths {
+		c.Assert(addTransfer(&order, common.CopyTransfer{Source: "/" + p, Destination: "/" + p}, cca), chk.IsNil)
+	}
+	c.Assert(parts, chk.HasLen, 0)
+	c.Assert(shards.dispatchFinalPart(&order, cca), chk.IsNil)
+
+	// each shard that got transfers has a part of its own, with its own SAS, and only the last is final
+	c.Assert(len(parts) > 1, chk.Equals, true)
+	dispatched := 0
+	for i, part := range parts {
+		c.Assert(part.PartNum, chk.Equals, common.PartNumber(i))
+		c.Assert(part.IsFinalPart, chk.Equals, i == len(parts)-1)
+		shard := shards.sasByRoot()
+		c.Assert(part.DestinationSAS, chk.Equals, shard[part.DestinationRoot])
+		for _, t := range part.Transfers {
+			relativePath := strings.Replace(strings.TrimPrefix(t.Destination, "/"), "%20", " ", 1)
+			c.Assert(shards.shards[shards.shardOf(relativePath)].root, chk.Equals, part.DestinationRoot)
+			dispatched++
+		}
+	}
+	c.Assert(dispatched, chk.Equals, len(paths))
+
+	// the manifest has the shard of each path, unescaped
+	manifest, err := ioutil.ReadFile(destinationShardManifestPath(cca.jobID))
+	c.Assert(err, chk.IsNil)
+	lines := strings.Split(strings.TrimSuffix(string(manifest), "\n"), "\n")
+	c.Assert(lines, chk.HasLen, len(paths))
+	c.Assert(lines[2], chk.Equals, "dir/c d.txt\t"+shards.shards[shards.shardOf("dir/c d.txt")].root)
+}
+
+func (s *destinationShardsSuite) TestSummaryShowsTheThroughputOfEachShard(c *chk.C) {
+	shards := &destinationShards{manifestPath: "/logs/job-shard-manifest.tsv"}
+	summary := common.ListJobSummaryResponse{Shards: []common.ShardSummary{
+		{Destination: shardA, Transfers: 10, BytesTransferred: 100 * base10Mega},
+		{Destination: shardB, Transfers: 5, TransfersFailed: 1, BytesTransferred: 25 * base10Mega},
+	}}
+	shards.summarize(&summary, 10*time.Second)
+	c.Assert(summary.Shards[0].ThroughputMbps, chk.Equals, float64(80))
+	c.Assert(summary.Shards[1].ThroughputMbps, chk.Equals, float64(20))
+	c.Assert(summary.ShardManifest, chk.Equals, "/logs/job-shard-manifest.tsv")
+
+	output := formatShardSummaries(summary)
+	c.Assert(strings.Contains(output, shardA+": 10 transfers (0 failed), 95.37 MiB, 80 Mb/s"), chk.Equals, true, chk.Commentf(output))
+	c.Assert(strings.Contains(output, shardB+": 5 transfers (1 failed), 23.84 MiB, 20 Mb/s"), chk.Equals, true, chk.Commentf(output))
+	c.Assert(strings.Contains(output, "/logs/job-shard-manifest.tsv"), chk.Equals, true)
+
+	// a job whose destination isn't sharded shows nothing
+	var notSharded *destinationShards
+	summary = common.ListJobSummaryResponse{}
+	notSharded.summarize(&summary, time.Second)
+	c.Assert(formatShardSummaries(summary), chk.Equals, "")
+}
@@ -0,0 +1,82 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type destinationTemplateSuite struct{}
+
+var _ = chk.Suite(&destinationTemplateSuite{})
+
+func (s *destinationTemplateSuite) TestTemplatesAreValidatedUpFront(c *chk.C) {
+	_, err := parseDestinationTemplate("{year}/{month}/{day}/{filename}")
+	c.Assert(err, chk.IsNil)
+
+	for _, bad := range []string{"{yaer}/{filename}", "{ext/{filename}", "ext}/{filename}", "archive", "../{filename}"} {
+		_, err = parseDestinationTemplate(bad)
+		c.Assert(err, chk.NotNil, chk.Commentf(bad))
+	}
+}
+
+func (s *destinationTemplateSuite) TestTemplateExpandsFromTheSource(c *chk.C) {
+	object := storedObject{name: "report.pdf", relativePath: "reports/q1/report.pdf", containerName: "finance",
+		lastModifiedTime: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)}
+
+	for template, expected := range map[string]string{
+		"{year}/{month}/{day}/{filename}":     "2026/03/04/report.pdf",
+		"{ext}/{filename}":                    "pdf/report.pdf",
+		"{container}/{dir}/{basename}-{hour}": "finance/reports/q1/report-05",
+	} {
+		t, err := parseDestinationTemplate(template)
+		c.Assert(err, chk.IsNil)
+		c.Assert(t.expand(object), chk.Equals, expected, chk.Commentf(template))
+	}
+
+	// the empty segments are dropped
+	t, _ := parseDestinationTemplate("{ext}/{dir}/{filename}")
+	c.Assert(t.expand(storedObject{name: "README", relativePath: "README"}), chk.Equals, "README")
+}
+
+func (s *destinationTemplateSuite) TestTemplateReplacesTheRelativePathOfTheDestination(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	t, err := parseDestinationTemplate("{year}/{filename}")
+	c.Assert(err, chk.IsNil)
+	cca := cookedCopyCmdArgs{fromTo: common.EFromTo.LocalBlob(), source: "/data", destinationTemplate: t}
+	object := storedObject{name: "a b.txt", relativePath: "sub/a b.txt", lastModifiedTime: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}
+
+	c.Assert(cca.makeEscapedRelativePath(true, true, object), chk.Equals, "/sub/a b.txt")
+	c.Assert(cca.makeEscapedRelativePath(false, true, object), chk.Equals, "/2026/a%20b.txt")
+
+	object.dstContainerName = "dst"
+	c.Assert(cca.makeEscapedRelativePath(false, true, object), chk.Equals, "/dst/2026/a%20b.txt")
+
+	// two sources that expand to the same destination are both claimed, and only warned about
+	t.claim("/2026/a%20b.txt", "/sub/a%20b.txt")
+	t.claim("/2026/a%20b.txt", "/other/a%20b.txt")
+	c.Assert(t.claimed["/2026/a%20b.txt"], chk.Equals, "/sub/a%20b.txt")
+}
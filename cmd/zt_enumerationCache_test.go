@@ -0,0 +1,155 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type enumerationCacheSuite struct{}
+
+var _ = chk.Suite(&enumerationCacheSuite{})
+
+// cookTestEnumerationCache cooks the cache of an upload from /data to a container, kept in the directory
+func cookTestEnumerationCache(c *chk.C, dir string, noCacheTrust bool) *enumerationCache {
+	raw := rawCopyCmdArgs{enumerationCache: filepath.Join(dir, "cache.json"), noCacheTrust: noCacheTrust}
+	cooked := cookedCopyCmdArgs{
+		source:      "/data",
+		destination: "https://account.blob.core.windows.net/container?sv=2019-02-02&sig=secret",
+		fromTo:      common.EFromTo.LocalBlob(),
+	}
+	c.Assert(cookEnumerationCache(raw, &cooked), chk.IsNil)
+	c.Assert(cooked.enumerationCache, chk.NotNil)
+	return cooked.enumerationCache
+}
+
+func (s *enumerationCacheSuite) TestUnchangedFilesAreSkippedOnTheNextRun(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	dir, err := ioutil.TempDir("", "enumerationCache")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	lmt := time.Date(2020, 3, 4, 5, 6, 7, 8, time.UTC)
+	a := storedObject{name: "a.txt", relativePath: "a.txt", size: 10, lastModifiedTime: lmt}
+	b := storedObject{name: "b.txt", relativePath: "dir/b.txt", size: 20, lastModifiedTime: lmt}
+
+	// there's no cache yet, so nothing is skipped
+	first := cookTestEnumerationCache(c, dir, false)
+	c.Assert(first.skip(a, "a.txt", "a.txt"), chk.Equals, false)
+	c.Assert(first.skip(b, "dir/b.txt", "dir/b.txt"), chk.Equals, false)
+	summary := common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Completed()}
+	first.save(&summary)
+	c.Assert(summary.EnumerationCacheDiscarded, chk.Equals, false)
+
+	// b changed, and a goes somewhere else, so only the unchanged file is skipped
+	second := cookTestEnumerationCache(c, dir, false)
+	c.Assert(second.skip(a, "a.txt", "a.txt"), chk.Equals, true)
+	b.size = 21
+	c.Assert(second.skip(b, "dir/b.txt", "dir/b.txt"), chk.Equals, false)
+	c.Assert(second.skip(a, "a.txt", "renamed/a.txt"), chk.Equals, false)
+	c.Assert(second.skippedEverything(), chk.Equals, false)
+
+	// without trusting the cache, nothing is skipped, but it's refreshed all the same
+	untrusted := cookTestEnumerationCache(c, dir, true)
+	c.Assert(untrusted.skip(a, "a.txt", "a.txt"), chk.Equals, false)
+	c.Assert(untrusted.skip(b, "dir/b.txt", "dir/b.txt"), chk.Equals, false)
+	summary = common.ListJobSummaryResponse{JobStatus: common.EJobStatus.CompletedWithSkipped()}
+	untrusted.save(&summary)
+
+	third := cookTestEnumerationCache(c, dir, false)
+	c.Assert(third.skip(a, "a.txt", "a.txt"), chk.Equals, true)
+	c.Assert(third.skip(b, "dir/b.txt", "dir/b.txt"), chk.Equals, true)
+	c.Assert(third.skippedEverything(), chk.Equals, true)
+
+	// a file whose listing can't tell whether it changed is never skipped
+	unknown := storedObject{name: "c.txt", relativePath: "c.txt", size: 5}
+	c.Assert(unknown.lastModifiedTime.IsZero(), chk.Equals, true)
+	third.previous["c.txt"] = enumerationCacheEntry{Size: 5, Destination: "c.txt"}
+	c.Assert(third.skip(unknown, "c.txt", "c.txt"), chk.Equals, false)
+
+	var nilCache *enumerationCache
+	c.Assert(nilCache.skip(a, "a.txt", "a.txt"), chk.Equals, false)
+	c.Assert(nilCache.skippedEverything(), chk.Equals, false)
+}
+
+func (s *enumerationCacheSuite) TestCacheIsDeletedWhenTheJobFails(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	dir, err := ioutil.TempDir("", "enumerationCache")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	a := storedObject{name: "a.txt", relativePath: "a.txt", size: 10, lastModifiedTime: time.Now()}
+	first := cookTestEnumerationCache(c, dir, false)
+	first.skip(a, "a.txt", "a.txt")
+	summary := common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Completed()}
+	first.save(&summary)
+
+	second := cookTestEnumerationCache(c, dir, false)
+	c.Assert(second.skip(a, "a.txt", "a.txt"), chk.Equals, true)
+	summary = common.ListJobSummaryResponse{JobStatus: common.EJobStatus.CompletedWithErrors(), TransfersFailed: 1}
+	second.save(&summary)
+	c.Assert(summary.EnumerationCacheDiscarded, chk.Equals, true)
+	c.Assert(summary.TransfersSkippedByEnumerationCache, chk.Equals, uint32(1))
+	_, err = os.Stat(second.path)
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+
+	third := cookTestEnumerationCache(c, dir, false)
+	c.Assert(third.skip(a, "a.txt", "a.txt"), chk.Equals, false)
+
+	c.Assert(enumerationCacheSucceeded(common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Cancelled()}), chk.Equals, false)
+	c.Assert(enumerationCacheSucceeded(common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Completed(), PathsNotEnumerated: 1}), chk.Equals, false)
+}
+
+func (s *enumerationCacheSuite) TestCacheOfAnotherCopyIsNotUsed(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	dir, err := ioutil.TempDir("", "enumerationCache")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	a := storedObject{name: "a.txt", relativePath: "a.txt", size: 10, lastModifiedTime: time.Now()}
+	first := cookTestEnumerationCache(c, dir, false)
+	first.skip(a, "a.txt", "a.txt")
+	c.Assert(first.write(), chk.IsNil)
+
+	// the SAS is no part of the destination that the cache is of
+	c.Assert(first.destination, chk.Equals, "https://account.blob.core.windows.net/container")
+
+	raw := rawCopyCmdArgs{enumerationCache: first.path}
+	cooked := cookedCopyCmdArgs{source: "/other", destination: "https://account.blob.core.windows.net/container", fromTo: common.EFromTo.LocalBlob()}
+	c.Assert(cookEnumerationCache(raw, &cooked), chk.IsNil)
+	c.Assert(cooked.enumerationCache.previous, chk.IsNil)
+	c.Assert(cooked.enumerationCache.skip(a, "a.txt", "a.txt"), chk.Equals, false)
+
+	c.Assert(ioutil.WriteFile(first.path, []byte("{not json"), 0666), chk.IsNil)
+	again := cookTestEnumerationCache(c, dir, false)
+	c.Assert(again.previous, chk.IsNil)
+
+	c.Assert(cookEnumerationCache(rawCopyCmdArgs{noCacheTrust: true}, &cookedCopyCmdArgs{}), chk.NotNil)
+}
@@ -0,0 +1,138 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type enumerationFailuresSuite struct{}
+
+var _ = chk.Suite(&enumerationFailuresSuite{})
+
+func (s *enumerationFailuresSuite) TestNilTrackerAbortsOnTheFirstError(c *chk.C) {
+	var tracker *enumerationFailureTracker
+	listErr := errors.New("access denied")
+
+	c.Assert(tracker.record("/data/private", "private", listErr), chk.Equals, listErr)
+	c.Assert(tracker.count(), chk.Equals, 0)
+	c.Assert(tracker.wasNotEnumerated("private/file"), chk.Equals, false)
+}
+
+func (s *enumerationFailuresSuite) TestTrackerCoversEverythingUnderTheFailedPath(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	tracker := newEnumerationFailureTracker()
+	c.Assert(tracker.record("/data/private", "private/", errors.New("access denied")), chk.IsNil)
+	c.Assert(tracker.count(), chk.Equals, 1)
+
+	c.Assert(tracker.wasNotEnumerated("private"), chk.Equals, true)
+	c.Assert(tracker.wasNotEnumerated("private/file"), chk.Equals, true)
+	c.Assert(tracker.wasNotEnumerated("private/sub/file"), chk.Equals, true)
+	c.Assert(tracker.wasNotEnumerated("privateer/file"), chk.Equals, false)
+	c.Assert(tracker.wasNotEnumerated("public/file"), chk.Equals, false)
+
+	// a failure at the root covers everything
+	c.Assert(tracker.record("https://account.blob.core.windows.net/container", "", errors.New("listing failed")), chk.IsNil)
+	c.Assert(tracker.wasNotEnumerated("public/file"), chk.Equals, true)
+}
+
+func (s *enumerationFailuresSuite) TestSkipNotEnumeratedKeepsDeletionsAwayFromFailedPaths(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	tracker := newEnumerationFailureTracker()
+	c.Assert(tracker.record("/data/private", "private", errors.New("access denied")), chk.IsNil)
+
+	deleted := make([]string, 0)
+	deleter := tracker.skipNotEnumerated(func(object storedObject) error {
+		deleted = append(deleted, object.relativePath)
+		return nil
+	})
+
+	for _, relativePath := range []string{"private/a", "public/b", "c"} {
+		c.Assert(deleter(storedObject{relativePath: relativePath}), chk.IsNil)
+	}
+	c.Assert(deleted, chk.DeepEquals, []string{"public/b", "c"})
+}
+
+func (s *enumerationFailuresSuite) TestReportMarksTheJobAsCompletedWithErrors(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	logDir, err := ioutil.TempDir("", "enumerationFailures")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(logDir)
+	originalLogDir := azcopyLogPathFolder
+	azcopyLogPathFolder = logDir
+	defer func() { azcopyLogPathFolder = originalLogDir }()
+
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID(), JobStatus: common.EJobStatus.Completed()}
+	reportEnumerationFailures(&summary, newEnumerationFailureTracker(), nil)
+	c.Assert(summary.JobStatus, chk.Equals, common.EJobStatus.Completed())
+	c.Assert(summary.PathsNotEnumerated, chk.Equals, uint32(0))
+
+	tracker := newEnumerationFailureTracker()
+	c.Assert(tracker.record("/data/private", "private", errors.New("access denied")), chk.IsNil)
+	reportEnumerationFailures(&summary, tracker, nil)
+	c.Assert(summary.JobStatus, chk.Equals, common.EJobStatus.CompletedWithErrors())
+	c.Assert(summary.PathsNotEnumerated, chk.Equals, uint32(1))
+	c.Assert(summary.PathsNotEnumeratedReport, chk.Equals, filepath.Join(logDir, summary.JobID.String()+"-not-enumerated.log"))
+
+	report, err := ioutil.ReadFile(summary.PathsNotEnumeratedReport)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(report), chk.Equals, "/data/private: access denied\n")
+}
+
+func (s *enumerationFailuresSuite) TestLocalTraverserSkipsBrokenSymlinks(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	scenarioHelper{}.generateLocalFilesFromList(c, dir, []string{"file"})
+	c.Assert(os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken")), chk.IsNil)
+
+	found := make([]string, 0)
+	processor := func(object storedObject) error {
+		found = append(found, object.relativePath)
+		return nil
+	}
+
+	// without a tracker, the broken link ends the enumeration
+	traverser := newLocalTraverser(dir, false, true, func() {}, nil, nil)
+	c.Assert(traverser.traverse(noPreProccessor, processor, nil), chk.NotNil)
+
+	found = found[:0]
+	tracker := newEnumerationFailureTracker()
+	traverser = newLocalTraverser(dir, false, true, func() {}, tracker, nil)
+	c.Assert(traverser.traverse(noPreProccessor, processor, nil), chk.IsNil)
+	c.Assert(found, chk.DeepEquals, []string{"file"})
+	c.Assert(tracker.count(), chk.Equals, 1)
+	c.Assert(tracker.wasNotEnumerated("broken"), chk.Equals, true)
+}
@@ -0,0 +1,81 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type existingDestinationsSuite struct{}
+
+var _ = chk.Suite(&existingDestinationsSuite{})
+
+func (s *existingDestinationsSuite) TestContainsLooksUpTheUnescapedRelativePath(c *chk.C) {
+	var none *existingDestinations
+	c.Assert(none.contains("/dir/file"), chk.Equals, false)
+
+	index := newObjectIndexer()
+	c.Assert(index.store(storedObject{name: "a b.txt", relativePath: "dir/a b.txt"}), chk.IsNil)
+	existing := &existingDestinations{index: index}
+
+	c.Assert(existing.contains("/dir/a%20b.txt"), chk.Equals, true)
+	c.Assert(existing.contains("dir/a%20b.txt"), chk.Equals, true)
+	c.Assert(existing.contains("/dir/other.txt"), chk.Equals, false)
+	c.Assert(existing.contains(""), chk.Equals, false)
+}
+
+func (s *existingDestinationsSuite) TestDestinationIsOnlyListedWhenItCanReplaceTheChecks(c *chk.C) {
+	cca := cookedCopyCmdArgs{
+		fromTo:     common.EFromTo.LocalFile(),
+		forceWrite: common.EOverwriteOption.False(),
+	}
+	ctx := context.Background()
+	dst := "https://account.file.core.windows.net/share"
+
+	// not a blob destination
+	c.Assert(cca.listExistingDestinations(ctx, dst, true, ELocationLevel.Container(), ELocationLevel.Container()), chk.IsNil)
+
+	cca.fromTo = common.EFromTo.LocalBlob()
+	dst = "https://account.blob.core.windows.net/container"
+	// a single file
+	c.Assert(cca.listExistingDestinations(ctx, dst, false, ELocationLevel.Object(), ELocationLevel.Container()), chk.IsNil)
+	// many containers
+	c.Assert(cca.listExistingDestinations(ctx, dst, true, ELocationLevel.Service(), ELocationLevel.Service()), chk.IsNil)
+
+	// overwriting, or deciding file by file
+	for _, option := range []common.OverwriteOption{common.EOverwriteOption.True(), common.EOverwriteOption.IfSourceNewer(), common.EOverwriteOption.Prompt()} {
+		cca.forceWrite = option
+		c.Assert(cca.listExistingDestinations(ctx, dst, true, ELocationLevel.Container(), ELocationLevel.Container()), chk.IsNil)
+	}
+}
+
+func (s *existingDestinationsSuite) TestSkippedTransfersAreNotPredicted(c *chk.C) {
+	b := newTransactionBudget(0, true, common.EFromTo.BlobBlob(), common.EBlobType.Detect(), 0)
+	b.listedDestination(5001)
+	c.Assert(b.add(common.CopyTransfer{SourceSize: 1024, ExistsAtDestination: true}), chk.IsNil)
+
+	// one list request for the source, and two for the destination
+	c.Assert(b.prediction(), chk.Equals, common.TransactionCounts{List: 3})
+	c.Assert(b.assumptions()[len(b.assumptions())-1], chk.Matches, "The destination is listed with one request per 5000 files.*")
+}
@@ -0,0 +1,159 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type failFastSuite struct{}
+
+var _ = chk.Suite(&failFastSuite{})
+
+func (s *failFastSuite) TestParseRate(c *chk.C) {
+	perMinute, percent, err := parseFailFastRate("100/min")
+	c.Assert(err, chk.IsNil)
+	c.Assert(perMinute, chk.Equals, float64(100))
+	c.Assert(percent, chk.Equals, float64(0))
+
+	perMinute, percent, err = parseFailFastRate("12.5%")
+	c.Assert(err, chk.IsNil)
+	c.Assert(perMinute, chk.Equals, float64(0))
+	c.Assert(percent, chk.Equals, 12.5)
+
+	for _, invalid := range []string{"100", "0/min", "-1/min", "150%", "0%", "lots/min"} {
+		_, _, err = parseFailFastRate(invalid)
+		c.Assert(err, chk.NotNil, chk.Commentf(invalid))
+	}
+
+	policy, err := newFailFastPolicy(0, "")
+	c.Assert(err, chk.IsNil)
+	c.Assert(policy, chk.IsNil)
+}
+
+func (s *failFastSuite) TestThreshold(c *chk.C) {
+	policy, err := newFailFastPolicy(10, "")
+	c.Assert(err, chk.IsNil)
+
+	now := time.Now()
+	c.Assert(policy.exceeded(common.ListJobSummaryResponse{TransfersFailed: 10, TransfersCompleted: 5}, now), chk.Equals, "")
+	c.Assert(policy.exceeded(common.ListJobSummaryResponse{TransfersFailed: 11}, now.Add(time.Hour)), chk.Not(chk.Equals), "")
+}
+
+func (s *failFastSuite) TestRatePerMinuteOnlyCountsTheLastMinute(c *chk.C) {
+	policy, err := newFailFastPolicy(0, "100/min")
+	c.Assert(err, chk.IsNil)
+
+	// 90 failures a minute, for three minutes
+	start := time.Now()
+	for i := 1; i <= 6; i++ {
+		summary := common.ListJobSummaryResponse{TransfersFailed: uint32(45 * i)}
+		c.Assert(policy.exceeded(summary, start.Add(time.Duration(i)*30*time.Second)), chk.Equals, "")
+	}
+
+	// then 120 more within 30 seconds
+	summary := common.ListJobSummaryResponse{TransfersFailed: 270 + 120}
+	c.Assert(policy.exceeded(summary, start.Add(210*time.Second)), chk.Not(chk.Equals), "")
+}
+
+func (s *failFastSuite) TestRatePercentWaitsForEnoughTransfers(c *chk.C) {
+	policy, err := newFailFastPolicy(0, "50%")
+	c.Assert(err, chk.IsNil)
+
+	now := time.Now()
+	c.Assert(policy.exceeded(common.ListJobSummaryResponse{TransfersFailed: 10}, now), chk.Equals, "")
+	c.Assert(policy.exceeded(common.ListJobSummaryResponse{TransfersFailed: 30, TransfersCompleted: 30}, now.Add(2*time.Second)), chk.Equals, "")
+	c.Assert(policy.exceeded(common.ListJobSummaryResponse{TransfersFailed: 60, TransfersCompleted: 30}, now.Add(4*time.Second)), chk.Not(chk.Equals), "")
+}
+
+func (s *failFastSuite) TestCheckAbortsTheJobAndTheSummaryShowsWhy(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	cancelled := make([]common.JobID, 0)
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.CancelJob())
+		cancelled = append(cancelled, request.(common.JobID))
+		*(response.(*common.CancelPauseResumeResponse)) = common.CancelPauseResumeResponse{CancelledPauseResumed: true}
+	}
+
+	jobID := common.NewJobID()
+	policy, err := newFailFastPolicy(2, "")
+	c.Assert(err, chk.IsNil)
+
+	summary := common.ListJobSummaryResponse{
+		JobID:                        jobID,
+		TotalTransfers:               10,
+		TransfersCompleted:           1,
+		TransfersFailed:              3,
+		FirstTransferFailures:        []string{"https://account.blob.core.windows.net/c/a: 404 The specified container does not exist"},
+		DominantTransferFailure:      "404 The specified container does not exist",
+		DominantTransferFailureCount: 3,
+	}
+	policy.check(jobID, summary)
+	policy.check(jobID, summary) // only aborts once
+	c.Assert(cancelled, chk.DeepEquals, []common.JobID{jobID})
+
+	summary.JobStatus = common.EJobStatus.Cancelled()
+	policy.reportAbort(&summary)
+	c.Assert(summary.JobStatus, chk.Equals, common.EJobStatus.CompletedWithErrors())
+	c.Assert(summary.TransfersNotAttempted, chk.Equals, uint32(6))
+
+	report := formatFailFastAbort(summary)
+	c.Assert(strings.Contains(report, "Number of Transfers Not Attempted (job aborted): 6"), chk.Equals, true)
+	c.Assert(strings.Contains(report, "Most Frequent Error (3 transfers): 404 The specified container does not exist"), chk.Equals, true)
+	c.Assert(strings.Contains(report, "\n  https://account.blob.core.windows.net/c/a: 404"), chk.Equals, true)
+
+	// a job that wasn't aborted is left alone
+	var noPolicy *failFastPolicy
+	untouched := common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Cancelled(), TotalTransfers: 10}
+	noPolicy.check(jobID, untouched)
+	noPolicy.reportAbort(&untouched)
+	c.Assert(untouched.JobStatus, chk.Equals, common.EJobStatus.Cancelled())
+	c.Assert(formatFailFastAbort(untouched), chk.Equals, "")
+}
+
+func (s *failFastSuite) TestFailuresByErrorCodeListsTheTopCodes(c *chk.C) {
+	c.Assert(formatFailuresByErrorCode(common.ListJobSummaryResponse{}), chk.Equals, "")
+
+	summary := common.ListJobSummaryResponse{FailuresByErrorCode: map[string]common.ErrorCodeFailures{
+		"AuthorizationPermissionMismatch": {Count: 3400, Status: 403, ExamplePath: "https://account.blob.core.windows.net/c/a"},
+		"BlobArchived":                    {Count: 21, Status: 409, ExamplePath: "https://account.blob.core.windows.net/c/b"},
+		common.NoStorageErrorCode:         {Count: 2, ExamplePath: "/data/c"},
+		"A":                               {Count: 1, ExamplePath: "/data/d"},
+		"B":                               {Count: 1, ExamplePath: "/data/e"},
+		"C":                               {Count: 1, ExamplePath: "/data/f"},
+		"D":                               {Count: 1, ExamplePath: "/data/g"},
+	}}
+	c.Assert(formatFailuresByErrorCode(summary), chk.Equals, "\n\nFailures by Error Code:"+
+		"\n  403 AuthorizationPermissionMismatch: 3400 (e.g. https://account.blob.core.windows.net/c/a)"+
+		"\n  409 BlobArchived: 21 (e.g. https://account.blob.core.windows.net/c/b)"+
+		"\n  (no error code): 2 (e.g. /data/c)"+
+		"\n  A: 1 (e.g. /data/d)"+
+		"\n  B: 1 (e.g. /data/e)"+
+		"\n  2 more codes: 2")
+}
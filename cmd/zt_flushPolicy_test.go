@@ -0,0 +1,52 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type flushPolicySuite struct{}
+
+var _ = chk.Suite(&flushPolicySuite{})
+
+func (s *flushPolicySuite) TestFlushPolicyIsOnlyForDownloads(c *chk.C) {
+	c.Assert(validateFlushPolicy(common.EFromTo.BlobLocal(), common.EFlushPolicy.PerFile(), 0), chk.IsNil)
+	c.Assert(validateFlushPolicy(common.EFromTo.BlobLocal(), common.EFlushPolicy.PerChunk(), 64), chk.IsNil)
+	c.Assert(validateFlushPolicy(common.EFromTo.LocalBlob(), common.EFlushPolicy.None(), 0), chk.IsNil)
+	c.Assert(validateFlushPolicy(common.EFromTo.LocalBlob(), common.EFlushPolicy.PerFile(), 0), chk.NotNil)
+	c.Assert(validateFlushPolicy(common.EFromTo.BlobLocal(), common.EFlushPolicy.PerFile(), 64), chk.NotNil)
+
+	var policy common.FlushPolicy
+	c.Assert(policy.Parse("per-chunk"), chk.IsNil)
+	c.Assert(policy, chk.Equals, common.EFlushPolicy.PerChunk())
+}
+
+func (s *flushPolicySuite) TestFlushPolicyIsInTheSummary(c *chk.C) {
+	c.Assert(formatFlushPolicy(common.ListJobSummaryResponse{}), chk.Equals, "")
+	c.Assert(strings.Contains(formatFlushPolicy(common.ListJobSummaryResponse{FlushPolicy: "PerFile"}), "Flush policy: per-file."), chk.Equals, true)
+	c.Assert(strings.Contains(formatFlushPolicy(common.ListJobSummaryResponse{FlushPolicy: "PerChunk"}), "each chunk"), chk.Equals, true)
+	c.Assert(strings.Contains(formatFlushPolicy(common.ListJobSummaryResponse{FlushPolicy: "PerChunk", FlushIntervalBytes: 64 * 1024 * 1024}),
+		"every 64 MB"), chk.Equals, true)
+}
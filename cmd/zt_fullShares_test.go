@@ -0,0 +1,70 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type fullSharesSuite struct{}
+
+var _ = chk.Suite(&fullSharesSuite{})
+
+func (s *fullSharesSuite) TestEachFullShareIsReportedOnceWhileItsFull(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	lcm := glcm.(*mockedLifecycleManager)
+
+	share := common.FullShare{Share: "https://acct.file.core.windows.net/share", UsedBytes: 100 * 1024 * 1024 * 1024, QuotaGiB: 100}
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID(), FullShares: []common.FullShare{share}}
+	report := fullSharesReport{}
+
+	report.check(summary.JobID, summary, 30*time.Minute)
+	c.Assert(lcm.logContainsText("Share full: https://acct.file.core.windows.net/share has used 100.0 of its 100 GiB quota. "+
+		"The transfers to it wait for up to 30m0s", time.Second), chk.Equals, true)
+	report.check(summary.JobID, summary, 30*time.Minute)
+	c.Assert(lcm.logContainsText("Share full", 10*time.Millisecond), chk.Equals, false)
+
+	// once it has room again, it's reported if it fills up once more
+	report.check(summary.JobID, common.ListJobSummaryResponse{JobID: summary.JobID}, 0)
+	report.check(summary.JobID, summary, 0)
+	c.Assert(lcm.logContainsText("'azcopy jobs resume "+summary.JobID.String()+"' transfers them", time.Second), chk.Equals, true)
+}
+
+func (s *fullSharesSuite) TestFullSharesAreInTheSummary(c *chk.C) {
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID()}
+	c.Assert(formatFullShares(summary), chk.Equals, "")
+
+	summary.FullShares = []common.FullShare{{Share: "https://acct.file.core.windows.net/share"}}
+	formatted := formatFullShares(summary)
+	c.Assert(strings.Contains(formatted, "Share full: https://acct.file.core.windows.net/share has reached its quota."), chk.Equals, true)
+	c.Assert(strings.Contains(formatted, "status ShareFull"), chk.Equals, true)
+}
+
+func (s *fullSharesSuite) TestWaitOnShareFullIsOnlyForAzureFiles(c *chk.C) {
+	c.Assert(validateWaitOnShareFull(common.EFromTo.LocalFile(), 30*time.Minute), chk.IsNil)
+	c.Assert(validateWaitOnShareFull(common.EFromTo.LocalBlob(), 30*time.Minute), chk.NotNil)
+	c.Assert(validateWaitOnShareFull(common.EFromTo.LocalFile(), -time.Minute), chk.NotNil)
+}
@@ -0,0 +1,45 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type inFlightTransfersSuite struct{}
+
+var _ = chk.Suite(&inFlightTransfersSuite{})
+
+func (s *inFlightTransfersSuite) TestInFlightTransfersAreShownWithTheirOwnProgress(c *chk.C) {
+	c.Assert(formatInFlightTransfers(nil), chk.Equals, "")
+	c.Assert(formatInFlightTransferDetails(nil), chk.Equals, "")
+
+	transfers := []common.InFlightTransfer{
+		{Src: "/disks/os.vhd", Size: 8 * 1024 * 1024 * 1024, PercentComplete: 25, ThroughputMbps: 171.8, SecondsRemaining: 300},
+		{Src: `C:\disks\data.vhd`, Size: 2 * 1024 * 1024 * 1024, PercentComplete: 0},
+	}
+	c.Assert(formatInFlightTransfers(transfers), chk.Equals,
+		", In Flight: os.vhd 25.0 % of 8.00 GiB at 171.8 Mb/s, 5m0s left; data.vhd 0.0 % of 2.00 GiB")
+	c.Assert(formatInFlightTransferDetails(transfers), chk.Equals, "In-Flight Transfers:\n"+
+		"  /disks/os.vhd: 25.0 % of 8.00 GiB at 171.8 Mb/s, 5m0s left\n"+
+		`  C:\disks\data.vhd: 0.0 % of 2.00 GiB`+"\n")
+}
@@ -67,6 +67,9 @@ func (i *interceptor) init() {
 	glcm = &mockedLifecycleManager{
 		log: make(chan string, 5000),
 	}
+
+	// the jobs are mocked, so there's nothing to control
+	startJobControl = func(common.JobID) {}
 }
 
 func (i *interceptor) reset() {
@@ -104,6 +107,9 @@ func (m *mockedLifecycleManager) Info(msg string) {
 	default:
 	}
 }
+func (m *mockedLifecycleManager) Event(name string, o common.OutputBuilder) {
+	m.Info(o(common.EOutputFormat.Text()))
+}
 func (*mockedLifecycleManager) Prompt(message string, details common.PromptDetails) common.ResponseOption {
 	return common.EResponseOption.Default()
 }
@@ -128,6 +134,8 @@ func (*mockedLifecycleManager) EnableCancelFromStdIn()              {}
 func (*mockedLifecycleManager) AddUserAgentPrefix(userAgent string) string {
 	return userAgent
 }
+func (*mockedLifecycleManager) RegisterCloseFunc(func()) {}
+func (*mockedLifecycleManager) CloseOnPanic()            {}
 
 type dummyProcessor struct {
 	record []storedObject
@@ -0,0 +1,165 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type invalidNamesSuite struct{}
+
+var _ = chk.Suite(&invalidNamesSuite{})
+
+func (s *invalidNamesSuite) TestOnlyDestinationsWithRulesGetAHandler(c *chk.C) {
+	h, err := cookInvalidNameHandling("", common.EFromTo.BlobFile(), common.NewJobID())
+	c.Assert(err, chk.IsNil)
+	c.Assert(h, chk.IsNil) // fail leaves the names as they are
+
+	h, err = cookInvalidNameHandling("sanitize", common.EFromTo.BlobBlob(), common.NewJobID())
+	c.Assert(err, chk.IsNil)
+	c.Assert(h, chk.IsNil) // a blob can have any name
+
+	h, err = cookInvalidNameHandling("Sanitize", common.EFromTo.BlobFile(), common.NewJobID())
+	c.Assert(err, chk.IsNil)
+	c.Assert(h, chk.NotNil)
+
+	_, err = cookInvalidNameHandling("rename", common.EFromTo.BlobFile(), common.NewJobID())
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *invalidNamesSuite) TestSanitizedNamesCanBeDecoded(c *chk.C) {
+	h := newInvalidNameHandler(common.EInvalidNameHandling.Sanitize(), common.EFromTo.BlobFile(), common.NewJobID())
+
+	for original, expected := range map[string]string{
+		"report.pdf":   "report.pdf",
+		"50%.txt":      "50%.txt", // a valid name keeps its '%'
+		`a\b`:          "a%5Cb",
+		"a:b.":         "a%3Ab%2E",
+		"x..":          "x.%2E",
+		"tab\there":    "tab%09here",
+		"50%:done":     "50%25%3Adone",
+		"what?<*>|\"x": "what%3F%3C%2A%3E%7C%22x",
+		"名前.":          "名前%2E",
+	} {
+		sanitized := h.sanitize(original)
+		c.Assert(sanitized, chk.Equals, expected, chk.Commentf(original))
+		if sanitized != original {
+			decoded, err := url.PathUnescape(sanitized)
+			c.Assert(err, chk.IsNil)
+			c.Assert(decoded, chk.Equals, original)
+		}
+	}
+}
+
+func (s *invalidNamesSuite) TestSkippedNamesAreLeftOut(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	h := newInvalidNameHandler(common.EInvalidNameHandling.Skip(), common.EFromTo.BlobFile(), common.NewJobID())
+	_, keep, err := h.rename(storedObject{name: "b.", relativePath: "a/b."}, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(keep, chk.Equals, false)
+
+	object, keep, err := h.rename(storedObject{name: "b", relativePath: "a/b"}, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(keep, chk.Equals, true)
+	c.Assert(object.relativePath, chk.Equals, "a/b")
+
+	summary := common.ListJobSummaryResponse{}
+	h.summarize(&summary)
+	c.Assert(summary.InvalidNamesSkipped, chk.Equals, uint32(1))
+	c.Assert(summary.SanitizedNamesMapping, chk.Equals, "")
+	c.Assert(strings.Contains(formatInvalidNames(summary), "1 files were skipped"), chk.Equals, true)
+}
+
+func (s *invalidNamesSuite) TestSanitizedNamesAreMapped(c *chk.C) {
+	logDir, err := ioutil.TempDir("", "invalidNames")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(logDir)
+	originalLogDir := azcopyLogPathFolder
+	azcopyLogPathFolder = logDir
+	defer func() { azcopyLogPathFolder = originalLogDir }()
+
+	h := newInvalidNameHandler(common.EInvalidNameHandling.Sanitize(), common.EFromTo.BlobFile(), common.NewJobID())
+	object, keep, err := h.rename(storedObject{name: "c:d", relativePath: "a./b/c:d"}, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(keep, chk.Equals, true)
+	c.Assert(object.relativePath, chk.Equals, "a%2E/b/c%3Ad")
+	c.Assert(object.name, chk.Equals, "c%3Ad")
+
+	// the source itself is only renamed when it goes into a directory
+	object, _, _ = h.rename(storedObject{name: "e?"}, false)
+	c.Assert(object.name, chk.Equals, "e?")
+	object, _, _ = h.rename(storedObject{name: "e?"}, true)
+	c.Assert(object.name, chk.Equals, "e%3F")
+
+	summary := common.ListJobSummaryResponse{}
+	h.summarize(&summary)
+	c.Assert(summary.NamesSanitized, chk.Equals, uint32(2))
+	c.Assert(summary.SanitizedNamesMapping, chk.Equals, sanitizedNamesMappingPath(h.jobID))
+	mapping, err := ioutil.ReadFile(summary.SanitizedNamesMapping)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(mapping), chk.Equals, "a./b/c:d\ta%2E/b/c%3Ad\ne?\te%3F\n")
+	c.Assert(strings.Contains(formatInvalidNames(summary), summary.SanitizedNamesMapping), chk.Equals, true)
+}
+
+func (s *invalidNamesSuite) TestSyncKeepsSanitizedDestinations(c *chk.C) {
+	logDir, err := ioutil.TempDir("", "invalidNames")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(logDir)
+	originalLogDir := azcopyLogPathFolder
+	azcopyLogPathFolder = logDir
+	defer func() { azcopyLogPathFolder = originalLogDir }()
+
+	synced := time.Now()
+	destinations := newObjectIndexer()
+	c.Assert(destinations.store(storedObject{name: "a%3Ab", relativePath: "dir/a%3Ab", lastModifiedTime: synced}), chk.IsNil)
+	c.Assert(destinations.store(storedObject{name: "stale", relativePath: "dir/stale", lastModifiedTime: synced}), chk.IsNil)
+
+	scheduled := make([]storedObject, 0)
+	h := newInvalidNameHandler(common.EInvalidNameHandling.Sanitize(), common.EFromTo.FileFile(), common.NewJobID())
+	comparator := h.forDestination(newSyncSourceComparator(destinations, func(object storedObject) error {
+		scheduled = append(scheduled, object)
+		return nil
+	}, false, nil).processIfNecessary)
+
+	// the unchanged source is matched with its sanitized destination, which isn't left over to be deleted
+	c.Assert(comparator(storedObject{name: "a:b", relativePath: "dir/a:b", lastModifiedTime: synced.Add(-time.Hour)}), chk.IsNil)
+	c.Assert(scheduled, chk.HasLen, 0)
+	_, leftOver := destinations.indexMap["dir/a%3Ab"]
+	c.Assert(leftOver, chk.Equals, false)
+	_, leftOver = destinations.indexMap["dir/stale"]
+	c.Assert(leftOver, chk.Equals, true)
+
+	// a new source goes to its sanitized name
+	c.Assert(comparator(storedObject{name: "c|d", relativePath: "dir/c|d"}), chk.IsNil)
+	c.Assert(scheduled, chk.HasLen, 1)
+	c.Assert(scheduled[0].relativePath, chk.Equals, "dir/c|d")
+	c.Assert(scheduled[0].destinationRelativePath(), chk.Equals, "dir/c%7Cd")
+	h.summarize(&common.ListJobSummaryResponse{})
+}
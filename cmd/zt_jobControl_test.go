@@ -0,0 +1,117 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type jobControlSuite struct{}
+
+var _ = chk.Suite(&jobControlSuite{})
+
+func (s *jobControlSuite) TestJobsSetRequiresSomethingToSet(c *chk.C) {
+	jobID := common.NewJobID()
+
+	_, err := rawJobsSetCmdArgs{jobID: "not-a-job"}.cook()
+	c.Assert(err, chk.NotNil)
+
+	_, err = rawJobsSetCmdArgs{jobID: jobID.String()}.cook()
+	c.Assert(err, chk.ErrorMatches, "nothing to set.*")
+
+	_, err = rawJobsSetCmdArgs{jobID: jobID.String(), concurrency: -1}.cook()
+	c.Assert(err, chk.NotNil)
+
+	// a cap of zero removes the cap, so it's only sent when it was given
+	req, err := rawJobsSetCmdArgs{jobID: jobID.String(), changeCapMbps: true}.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(req, chk.Equals, common.SetJobLimitsRequest{JobID: jobID, ChangeCapMbps: true})
+
+	req, err = rawJobsSetCmdArgs{jobID: jobID.String(), capMbps: 50, concurrency: 16}.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(req, chk.Equals, common.SetJobLimitsRequest{JobID: jobID, CapMbps: 50, Concurrency: 16})
+}
+
+func (s *jobControlSuite) TestControlEndpointPassesRequestsToTheJob(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	planDir, err := ioutil.TempDir("", "jobControl")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(planDir)
+	originalPlanDir := azcopyJobPlanFolder
+	azcopyJobPlanFolder = planDir
+	defer func() { azcopyJobPlanFolder = originalPlanDir }()
+
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	received := make(chan common.SetJobLimitsRequest, 1)
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.SetJobLimits())
+		req := *request.(*common.SetJobLimitsRequest)
+		received <- req
+		*(response.(*common.SetJobLimitsResponse)) = common.SetJobLimitsResponse{LimitsSet: true, CapMbps: req.CapMbps, Concurrency: req.Concurrency}
+	}
+
+	jobID := common.NewJobID()
+	openJobControl(jobID)
+	defer stopJobControl()
+
+	endpoint := jobControlEndpoint(jobID)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(endpoint)
+		c.Assert(err, chk.IsNil)
+		c.Assert(info.Mode().Perm(), chk.Equals, os.FileMode(0600))
+		info, err = os.Stat(filepath.Dir(endpoint))
+		c.Assert(err, chk.IsNil)
+		c.Assert(info.Mode().Perm(), chk.Equals, os.FileMode(0700))
+	}
+
+	resp, err := sendJobLimits(common.SetJobLimitsRequest{JobID: jobID, ChangeCapMbps: true, CapMbps: 50, Concurrency: 16})
+	c.Assert(err, chk.IsNil)
+	c.Assert(resp, chk.Equals, common.SetJobLimitsResponse{LimitsSet: true, CapMbps: 50, Concurrency: 16})
+	c.Assert(<-received, chk.Equals, common.SetJobLimitsRequest{JobID: jobID, ChangeCapMbps: true, CapMbps: 50, Concurrency: 16})
+
+	// other jobs aren't reachable through it
+	_, err = sendJobLimits(common.SetJobLimitsRequest{JobID: common.NewJobID(), Concurrency: 16})
+	c.Assert(err, chk.NotNil)
+
+	// the endpoint is removed when it's closed, e.g. on exit
+	stopJobControl()
+	if runtime.GOOS != "windows" {
+		_, err = os.Stat(filepath.Dir(endpoint))
+		c.Assert(os.IsNotExist(err), chk.Equals, true)
+	}
+	_, err = sendJobLimits(common.SetJobLimitsRequest{JobID: jobID, Concurrency: 16})
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *jobControlSuite) TestProgressLineShowsTheLimits(c *chk.C) {
+	c.Assert(formatJobLimits(common.ListJobSummaryResponse{}), chk.Equals, "")
+	c.Assert(formatJobLimits(common.ListJobSummaryResponse{CapMbps: 50}), chk.Equals, "Cap (Mb/s): 50")
+	c.Assert(formatJobLimits(common.ListJobSummaryResponse{CapMbps: 50, Concurrency: 16}), chk.Equals, "Cap (Mb/s): 50, Concurrency: 16")
+}
@@ -0,0 +1,99 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type jobHistorySuite struct{}
+
+var _ = chk.Suite(&jobHistorySuite{})
+
+func (s *jobHistorySuite) TestHistoryAge(c *chk.C) {
+	age, err := parseHistoryAge("")
+	c.Assert(err, chk.IsNil)
+	c.Assert(age, chk.Equals, time.Duration(0))
+
+	age, err = parseHistoryAge("30d")
+	c.Assert(err, chk.IsNil)
+	c.Assert(age, chk.Equals, 30*24*time.Hour)
+
+	age, err = parseHistoryAge("12h")
+	c.Assert(err, chk.IsNil)
+	c.Assert(age, chk.Equals, 12*time.Hour)
+
+	for _, bad := range []string{"d", "-1d", "-1h", "month"} {
+		_, err = parseHistoryAge(bad)
+		c.Assert(err, chk.NotNil)
+	}
+}
+
+func (s *jobHistorySuite) TestFilterKeepsRecentJobsWithTheStatusLatestFirst(c *chk.C) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := common.JobHistoryEntry{Source: "old", EndTime: now.Add(-40 * 24 * time.Hour), JobStatus: common.EJobStatus.CompletedWithErrors()}
+	earlier := common.JobHistoryEntry{Source: "earlier", EndTime: now.Add(-10 * 24 * time.Hour), JobStatus: common.EJobStatus.CompletedWithErrors()}
+	later := common.JobHistoryEntry{Source: "later", EndTime: now.Add(-time.Hour), JobStatus: common.EJobStatus.CompletedWithErrors()}
+	completed := common.JobHistoryEntry{Source: "completed", EndTime: now.Add(-2 * time.Hour), JobStatus: common.EJobStatus.Completed()}
+	entries := []common.JobHistoryEntry{old, earlier, completed, later}
+
+	c.Assert(filterJobHistory(entries, 30*24*time.Hour, common.EJobStatus.CompletedWithErrors(), now),
+		chk.DeepEquals, []common.JobHistoryEntry{later, earlier})
+	c.Assert(filterJobHistory(entries, 0, common.EJobStatus.All(), now),
+		chk.DeepEquals, []common.JobHistoryEntry{later, completed, earlier, old})
+}
+
+func (s *jobHistorySuite) TestJobIsOnlyRecordedWhenTheHistoryIsEnabled(c *chk.C) {
+	dir, err := ioutil.TempDir("", "jobHistory")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	originalAppPath := azcopyAppPathFolder
+	azcopyAppPathFolder = dir
+	defer func() { azcopyAppPathFolder = originalAppPath }()
+	defer os.Unsetenv(common.EEnvironmentVariable.JobHistory().Name)
+
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID(), TotalTransfers: 2, TransfersCompleted: 1, TransfersFailed: 1,
+		JobStatus: common.EJobStatus.CompletedWithErrors()}
+	start := time.Now().Add(-time.Minute)
+
+	recordJobHistory(summary, "copy /data https://account.blob.core.windows.net/c ", "/data", "https://account.blob.core.windows.net/c", start)
+	entries, err := common.ReadJobHistory(common.JobHistoryPath(dir))
+	c.Assert(err, chk.IsNil)
+	c.Assert(entries, chk.HasLen, 0)
+
+	os.Setenv(common.EEnvironmentVariable.JobHistory().Name, "true")
+	recordJobHistory(summary, "copy /data https://account.blob.core.windows.net/c?sig=REDACTED ", "/data",
+		"https://account.blob.core.windows.net/c?sv=2019&sig=secret", start)
+	entries, err = common.ReadJobHistory(common.JobHistoryPath(dir))
+	c.Assert(err, chk.IsNil)
+	c.Assert(entries, chk.HasLen, 1)
+	c.Assert(entries[0].JobID, chk.Equals, summary.JobID)
+	c.Assert(entries[0].Command, chk.Equals, "copy /data https://account.blob.core.windows.net/c?sig=REDACTED")
+	c.Assert(entries[0].Destination, chk.Equals, "https://account.blob.core.windows.net/c?sig=REDACTED&sv=2019")
+	c.Assert(entries[0].TransfersFailed, chk.Equals, uint32(1))
+	c.Assert(entries[0].JobStatus, chk.Equals, common.EJobStatus.CompletedWithErrors())
+	c.Assert(entries[0].EndTime.After(entries[0].StartTime), chk.Equals, true)
+}
@@ -0,0 +1,71 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type jobPerformanceReportSuite struct{}
+
+var _ = chk.Suite(&jobPerformanceReportSuite{})
+
+func (s *jobPerformanceReportSuite) TestReportAccumulatesOverRefreshes(c *chk.C) {
+	t := &jobPerformanceTracker{}
+	start := time.Now()
+	t.enumerationStartTime = start
+	t.enumerationEndTime = start.Add(3 * time.Second)
+
+	// 10 MB in the first 2 seconds (40 Mb/s), then 5 MB in the next 2 (20 Mb/s)
+	t.sampleAt(common.ListJobSummaryResponse{ActiveConnections: 4}, start)
+	t.sampleAt(common.ListJobSummaryResponse{BytesOverWire: 10 * base10Mega, ActiveConnections: 8}, start.Add(2*time.Second))
+	final := common.ListJobSummaryResponse{
+		BytesOverWire:         15 * base10Mega,
+		RetryCount:            7,
+		ChunkIntegrityRetries: 2,
+		RequestCountsByStatus: map[int]int64{http.StatusCreated: 100, http.StatusServiceUnavailable: 5},
+	}
+	t.sampleAt(final, start.Add(4*time.Second))
+
+	r := t.report(final, 4*time.Second)
+	c.Assert(r.ElapsedSeconds, chk.Equals, float64(4))
+	c.Assert(r.EnumerationSeconds, chk.Equals, float64(3))
+	c.Assert(r.AfterEnumerationSeconds, chk.Equals, float64(1))
+	c.Assert(r.PeakThroughputMbps, chk.Equals, float64(40)) // the peak is kept, rather than replaced by the latest interval
+	c.Assert(r.AverageThroughputMbps, chk.Equals, float64(30))
+	c.Assert(r.TotalRetries, chk.Equals, int64(7))
+	c.Assert(r.ChunkIntegrityRetries, chk.Equals, uint32(2)) // counted separately from the retries of requests
+	c.Assert(r.ServerBusyCount, chk.Equals, int64(5))
+	c.Assert(r.AverageConcurrency, chk.Equals, float64(4))
+}
+
+func (s *jobPerformanceReportSuite) TestNilTrackerReportsNothing(c *chk.C) {
+	var t *jobPerformanceTracker
+	t.enumerationStarted()
+	t.sample(common.ListJobSummaryResponse{})
+	r := t.report(common.ListJobSummaryResponse{}, time.Second)
+	c.Assert(r, chk.IsNil)
+	c.Assert(formatPerformanceReport(common.ListJobSummaryResponse{PerformanceReport: r}), chk.Equals, "")
+}
@@ -0,0 +1,101 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type jobTemplateSuite struct{}
+
+var _ = chk.Suite(&jobTemplateSuite{})
+
+// newJobTemplateTestFlags returns a few flags of the copy command, parsed from the command line
+func newJobTemplateTestFlags(c *chk.C, commandLine ...string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("copy", pflag.ContinueOnError)
+	flags.Bool("recursive", false, "")
+	flags.String("overwrite", "true", "")
+	flags.String("include-pattern", "", "")
+	flags.StringArray("header-rule", nil, "")
+	flags.String(fromTemplateFlag, "", "")
+	c.Assert(flags.Parse(commandLine), chk.IsNil)
+	return flags
+}
+
+func (s *jobTemplateSuite) TestCommandLineOverridesTheTemplate(c *chk.C) {
+	dir, err := ioutil.TempDir("", "jobTemplate")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "job.yaml")
+
+	exported := common.NewJobTemplate("copy", "/data", "https://acct.blob.core.windows.net/backup?sig=secret",
+		jobTemplateFlags(newJobTemplateTestFlags(c, "--recursive", "--overwrite=false", "--header-rule=pattern=*.html;cacheControl=no-cache",
+			"--header-rule=pattern=*.css;cacheControl=max-age=60", "--from-template=old.yaml")))
+	c.Assert(exported.Flags, chk.HasLen, 3) // the template that a job was loaded from is not part of its own template
+	c.Assert(common.WriteJobTemplate(path, exported), chk.IsNil)
+
+	flags := newJobTemplateTestFlags(c, "--overwrite=ifSourceNewer", "--from-template="+path)
+	loaded, err := loadJobTemplate(path, "copy", flags)
+	c.Assert(err, chk.IsNil)
+	c.Assert(loaded.Source, chk.Equals, "/data")
+	c.Assert(loaded.Destination, chk.Equals, "https://acct.blob.core.windows.net/backup")
+
+	recursive, _ := flags.GetBool("recursive")
+	overwrite, _ := flags.GetString("overwrite")
+	headerRules, _ := flags.GetStringArray("header-rule")
+	c.Assert(recursive, chk.Equals, true)
+	c.Assert(overwrite, chk.Equals, "ifSourceNewer")
+	c.Assert(headerRules, chk.DeepEquals, []string{"pattern=*.html;cacheControl=no-cache", "pattern=*.css;cacheControl=max-age=60"})
+
+	// the job's flag set differs from the template's only by the override
+	run := common.NewJobTemplate("copy", "/data", "", jobTemplateFlags(flags))
+	c.Assert(run.FlagSetHash, chk.Not(chk.Equals), exported.FlagSetHash)
+	run.Flags["overwrite"] = common.JobTemplateFlag{"false"}
+	c.Assert(run.ComputeFlagSetHash(), chk.Equals, exported.FlagSetHash)
+}
+
+func (s *jobTemplateSuite) TestTemplatesThatDontFitAreRefused(c *chk.C) {
+	dir, err := ioutil.TempDir("", "jobTemplate")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "job.yaml")
+
+	unknownFlag := common.NewJobTemplate("copy", "/data", "", map[string]common.JobTemplateFlag{"preserve-everything": {"true"}})
+	c.Assert(common.WriteJobTemplate(path, unknownFlag), chk.IsNil)
+	_, err = loadJobTemplate(path, "copy", newJobTemplateTestFlags(c))
+	c.Assert(err, chk.ErrorMatches, ".*has the flag --preserve-everything, which copy does not have")
+
+	newer := common.NewJobTemplate("copy", "/data", "", nil)
+	newer.AzCopyVersion = "99.0.0"
+	c.Assert(common.WriteJobTemplate(path, newer), chk.IsNil)
+	_, err = loadJobTemplate(path, "copy", newJobTemplateTestFlags(c))
+	c.Assert(err, chk.ErrorMatches, ".*was written by AzCopy 99.0.0, which is newer than this one.*")
+
+	_, err = loadJobTemplate(path, "sync", newJobTemplateTestFlags(c))
+	c.Assert(err, chk.ErrorMatches, ".*is of a copy job, so it cannot be used with sync")
+}
@@ -0,0 +1,244 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type listOfFilesSuite struct{}
+
+var _ = chk.Suite(&listOfFilesSuite{})
+
+// adversarialNames are paths that a plain line per path would mangle, or that would be mistaken for something else
+var adversarialNames = []string{
+	"plain.txt",
+	"dir with spaces/file name.txt",
+	"日本語/ファイル.txt",
+	"trailing space ",
+	"trailing tab\t",
+	" leading space",
+	"carriage return\r",
+	"line\nbreak",
+	`"quoted"`,
+	`"unterminated`,
+	"{",
+	`{"Files":`,
+	utf8BOM + "starts with a byte order marker",
+	"percent%20not-encoded",
+	"question?and#hash",
+	"not utf-8 \xff",
+}
+
+func readListOfFilesFrom(c *chk.C, content string) []string {
+	paths := make([]string, 0)
+	add := func(entry listOfFilesEntry) { paths = append(paths, entry.Source) }
+	c.Assert(readListOfFiles(strings.NewReader(content), common.EListOfFilesFormat.Text(), false, add), chk.IsNil)
+	return paths
+}
+
+func (s *listOfFilesSuite) TestPlainLinesAreReadAsTheyAre(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	// as lists are written by hand, with a byte order marker and Windows line endings
+	content := utf8BOM + "a.txt\r\ndir with spaces/b.txt\r\n日本語.txt\r\n\"unterminated\r\n"
+	c.Assert(readListOfFilesFrom(c, content), chk.DeepEquals, []string{"a.txt", "dir with spaces/b.txt", "日本語.txt", `"unterminated`})
+}
+
+func (s *listOfFilesSuite) TestOnlyPathsThatNeedItAreQuoted(c *chk.C) {
+	c.Assert(formatListOfFilesLine("dir with spaces/日本語.txt"), chk.Equals, "dir with spaces/日本語.txt")
+	c.Assert(formatListOfFilesLine("trailing space "), chk.Equals, `"trailing space "`)
+	c.Assert(formatListOfFilesLine("line\nbreak"), chk.Equals, `"line\nbreak"`)
+	c.Assert(formatListOfFilesLine(`"quoted"`), chk.Equals, `"\"quoted\""`)
+}
+
+func (s *listOfFilesSuite) TestAdversarialNamesRoundTrip(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	var sb strings.Builder
+	for _, name := range adversarialNames {
+		sb.WriteString(formatListOfFilesLine(name) + "\n")
+	}
+	c.Assert(readListOfFilesFrom(c, sb.String()), chk.DeepEquals, adversarialNames)
+
+	// converting the line endings loses nothing either
+	c.Assert(readListOfFilesFrom(c, strings.Replace(sb.String(), "\n", "\r\n", -1)), chk.DeepEquals, adversarialNames)
+}
+
+func (s *listOfFilesSuite) TestFailedFilesOutputCanBeReadAsAListOfFiles(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	dir, err := ioutil.TempDir("", "listOfFiles")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	// the failed transfers of a download, whose relative paths are URL-encoded in the plan, and of an upload, whose aren't
+	remote := make([]common.TransferDetail, 0)
+	local := make([]common.TransferDetail, 0)
+	for _, name := range adversarialNames {
+		segments := strings.Split(name, "/")
+		for i := range segments {
+			segments[i] = url.PathEscape(segments[i])
+		}
+		remote = append(remote, common.TransferDetail{SrcRelative: "/" + strings.Join(segments, "/"), TransferStatus: common.ETransferStatus.Failed()})
+		local = append(local, common.TransferDetail{SrcRelative: "/" + name, TransferStatus: common.ETransferStatus.Failed()})
+	}
+	// the source of a single file has nothing to list
+	remote = append(remote, common.TransferDetail{SrcRelative: "", TransferStatus: common.ETransferStatus.Failed()})
+
+	for source, transfers := range map[common.Location][]common.TransferDetail{common.ELocation.Blob(): remote, common.ELocation.Local(): local} {
+		outputPath := filepath.Join(dir, source.String()+".txt")
+		c.Assert(writeFailedFilesList(outputPath, transfers, source), chk.IsNil)
+
+		f, err := os.Open(outputPath)
+		c.Assert(err, chk.IsNil)
+		paths := make([]string, 0)
+		add := func(entry listOfFilesEntry) { paths = append(paths, entry.Source) }
+		c.Assert(readListOfFiles(f, common.EListOfFilesFormat.Text(), false, add), chk.IsNil)
+		f.Close()
+		c.Assert(paths, chk.DeepEquals, adversarialNames, chk.Commentf("source %s", source))
+	}
+}
+
+func (s *listOfFilesSuite) TestNDJSONEntriesCarryTheirDestinationAndMetadata(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	content := `{"source": "a.txt"}` + "\n" +
+		"\n" +
+		`{"source": "dir/日本語 b.txt", "destination": "renamed/b.txt", "metadata": {"owner": "finance"}}` + "\r\n" +
+		`{"source": "trailing space ", "metadata": {}}` + "\n"
+
+	entries := make([]listOfFilesEntry, 0)
+	err := readListOfFiles(strings.NewReader(content), common.EListOfFilesFormat.NDJSON(), true, func(entry listOfFilesEntry) { entries = append(entries, entry) })
+	c.Assert(err, chk.IsNil)
+	c.Assert(entries, chk.DeepEquals, []listOfFilesEntry{
+		{Source: "a.txt"},
+		{Source: "dir/日本語 b.txt", Destination: "renamed/b.txt", Metadata: common.Metadata{"owner": "finance"}},
+		{Source: "trailing space ", Metadata: common.Metadata{}},
+	})
+}
+
+func (s *listOfFilesSuite) TestMalformedLinesAreSkippedUnlessTheListIsStrict(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	content := strings.Join([]string{
+		`{"source": "a.txt"}`,
+		`{"source": "b.txt"`,
+		`{"destination": "c.txt"}`,
+		`{"source": "d.txt", "dest": "typo.txt"}`,
+		`{"source": "e.txt"} {"source": "f.txt"}`,
+		`"g.txt"`,
+		`{"source": "h.txt"}`,
+	}, "\n")
+
+	sources := make([]string, 0)
+	add := func(entry listOfFilesEntry) { sources = append(sources, entry.Source) }
+	c.Assert(readListOfFiles(strings.NewReader(content), common.EListOfFilesFormat.NDJSON(), false, add), chk.IsNil)
+	c.Assert(sources, chk.DeepEquals, []string{"a.txt", "h.txt"})
+
+	sources = sources[:0]
+	err := readListOfFiles(strings.NewReader(content), common.EListOfFilesFormat.NDJSON(), true, add)
+	malformed, ok := err.(malformedListOfFilesLineError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(malformed.lineNumber, chk.Equals, 2)
+	c.Assert(sources, chk.DeepEquals, []string{"a.txt"})
+}
+
+func (s *listOfFilesSuite) TestListOfFilesIsStreamedFromStdin(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	r, w, err := os.Pipe()
+	c.Assert(err, chk.IsNil)
+	originalStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = originalStdin }()
+
+	list, err := openListOfFiles(listOfFilesStdin)
+	c.Assert(err, chk.IsNil)
+	defer list.Close()
+
+	// the second entry is only written once the first has been read, which would never happen if the list was read in full first
+	firstRead := make(chan struct{})
+	go func() {
+		defer w.Close()
+		_, _ = w.WriteString("a.txt\n")
+		select {
+		case <-firstRead:
+			_, _ = w.WriteString("b.txt\n")
+		case <-time.After(10 * time.Second):
+		}
+	}()
+
+	sources := make([]string, 0)
+	err = readListOfFiles(list, common.EListOfFilesFormat.Text(), false, func(entry listOfFilesEntry) {
+		sources = append(sources, entry.Source)
+		if len(sources) == 1 {
+			close(firstRead)
+		}
+	})
+	c.Assert(err, chk.IsNil)
+	c.Assert(sources, chk.DeepEquals, []string{"a.txt", "b.txt"})
+}
+
+func (s *listOfFilesSuite) TestListTraverserAppliesTheEntriesToEverythingUnderThem(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	scenarioHelper{}.generateLocalFilesFromList(c, dir, []string{"a.txt", "sub/b.txt", "sub/c.txt"})
+
+	listChan := make(chan listOfFilesEntry)
+	go func() {
+		defer close(listChan)
+		listChan <- listOfFilesEntry{Source: "a.txt", Destination: "renamed.txt", Metadata: common.Metadata{"k": "v"}}
+		listChan <- listOfFilesEntry{Source: "sub", Destination: "elsewhere/sub"}
+	}()
+
+	found := make(map[string]storedObject)
+	traverser := newListTraverser(dir, "", common.ELocation.Local(), nil, nil, true, false, false, listChan, func() {}, nil, nil)
+	c.Assert(traverser.traverse(noPreProccessor, func(object storedObject) error {
+		found[object.relativePath] = object
+		return nil
+	}, nil), chk.IsNil)
+
+	c.Assert(found, chk.HasLen, 3)
+	c.Assert(found["a.txt"].atDestination().relativePath, chk.Equals, "renamed.txt")
+	c.Assert(found["a.txt"].atDestination().name, chk.Equals, "renamed.txt")
+	c.Assert(found["a.txt"].Metadata, chk.DeepEquals, common.Metadata{"k": "v"})
+	c.Assert(found["sub/b.txt"].atDestination().relativePath, chk.Equals, "elsewhere/sub/b.txt")
+	c.Assert(found["sub/c.txt"].atDestination().relativePath, chk.Equals, "elsewhere/sub/c.txt")
+	c.Assert(found["sub/c.txt"].Metadata, chk.HasLen, 0)
+}
@@ -0,0 +1,120 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type listingBackoffSuite struct{}
+
+var _ = chk.Suite(&listingBackoffSuite{})
+
+// listingError stands in for the StorageError of a listing call that failed
+type listingError struct {
+	status int
+}
+
+func (e listingError) Error() string { return http.StatusText(e.status) }
+
+func (e listingError) Response() *http.Response { return &http.Response{StatusCode: e.status} }
+
+// recordListingWaits replaces the waits of listWithBackoff with a record of them, until the returned func is called
+func recordListingWaits() (waits *[]time.Duration, restore func()) {
+	waits = &[]time.Duration{}
+	original := listingBackoffWait
+	listingBackoffWait = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	return waits, func() { listingBackoffWait = original }
+}
+
+func (s *listingBackoffSuite) TestThrottledListingContinuesFromTheSameMarker(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	waits, restore := recordListingWaits()
+	defer restore()
+
+	listing := newEnumerationListing()
+	ctx := withEnumerationListing(context.Background(), listing)
+
+	markersAskedFor := make([]string, 0)
+	outcomes := []error{listingError{http.StatusServiceUnavailable}, listingError{http.StatusInternalServerError}, nil}
+	marker := "segment-2"
+	err := listWithBackoff(ctx, "https://account.blob.core.windows.net/container", func() error {
+		markersAskedFor = append(markersAskedFor, marker)
+		outcome := outcomes[0]
+		outcomes = outcomes[1:]
+		return outcome
+	})
+
+	c.Assert(err, chk.IsNil)
+	c.Assert(markersAskedFor, chk.DeepEquals, []string{"segment-2", "segment-2", "segment-2"})
+	c.Assert(*waits, chk.DeepEquals, listingBackoffs[:2])
+	c.Assert(listing.retries(), chk.Equals, uint32(2))
+}
+
+func (s *listingBackoffSuite) TestListingOnlyBacksOffWhenThrottled(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	waits, restore := recordListingWaits()
+	defer restore()
+
+	notFound := listingError{http.StatusNotFound}
+	c.Assert(listWithBackoff(context.Background(), "x", func() error { return notFound }), chk.Equals, notFound)
+	other := errors.New("connection reset")
+	c.Assert(listWithBackoff(context.Background(), "x", func() error { return other }), chk.Equals, other)
+	c.Assert(*waits, chk.HasLen, 0)
+
+	// and gives up once it has waited as long as it will
+	busy := listingError{http.StatusTooManyRequests}
+	calls := 0
+	c.Assert(listWithBackoff(context.Background(), "x", func() error { calls++; return busy }), chk.Equals, busy)
+	c.Assert(calls, chk.Equals, len(listingBackoffs)+1)
+	c.Assert(*waits, chk.DeepEquals, listingBackoffs)
+}
+
+func (s *listingBackoffSuite) TestResumePoint(c *chk.C) {
+	var nilListing *enumerationListing
+	nilListing.reached("marker")
+	c.Assert(nilListing.resumePoint(), chk.Equals, "")
+	c.Assert(nilListing.retries(), chk.Equals, uint32(0))
+	c.Assert(enumerationListingFrom(context.Background()), chk.IsNil)
+
+	listing := newEnumerationListing()
+	c.Assert(listing.resumePoint(), chk.Equals, "")
+	listing.reached("2!96!MDAwMDQ2IWRpci9maWxl")
+	c.Assert(listing.resumePoint(), chk.Equals, "2!96!MDAwMDQ2IWRpci9maWxl")
+
+	// a marker that doesn't fit in the plan would be no use if it were cut
+	listing.reached(strings.Repeat("m", 2000))
+	c.Assert(listing.resumePoint(), chk.Equals, "")
+
+	listing.RetryCallback()
+	c.Assert(listing.retries(), chk.Equals, uint32(1))
+}
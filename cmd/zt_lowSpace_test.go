@@ -0,0 +1,106 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type lowSpaceSuite struct{}
+
+var _ = chk.Suite(&lowSpaceSuite{})
+
+const mb = 1024 * 1024
+
+// fakeVolume replaces the measurement of the free space with a volume that has this much free, until the returned func is called
+func fakeVolume(free uint64) (restore func()) {
+	original := freeDiskSpace
+	freeDiskSpace = func(path string) (uint64, error) { return free, nil }
+	return func() { freeDiskSpace = original }
+}
+
+func (s *lowSpaceSuite) TestEnumerationIsCheckedAgainstTheFreeSpace(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	defer fakeVolume(100 * mb)()
+
+	fail := &lowSpaceCheck{action: common.ELowSpaceAction.Fail(), destination: "/data", margin: 10 * mb}
+	c.Assert(fail.add(common.CopyTransfer{SourceSize: 60 * mb}), chk.IsNil)
+	// files that are already there are skipped, so they need nothing
+	c.Assert(fail.add(common.CopyTransfer{SourceSize: 60 * mb, ExistsAtDestination: true}), chk.IsNil)
+	c.Assert(fail.add(common.CopyTransfer{SourceSize: 35 * mb}), chk.NotNil)
+
+	warn := &lowSpaceCheck{action: common.ELowSpaceAction.Warn(), destination: "/data", margin: 10 * mb}
+	c.Assert(warn.add(common.CopyTransfer{SourceSize: 200 * mb}), chk.IsNil)
+	c.Assert(warn.add(common.CopyTransfer{SourceSize: 200 * mb}), chk.IsNil)
+
+	// the user doesn't say yes
+	prompt := &lowSpaceCheck{action: common.ELowSpaceAction.Prompt(), destination: "/data", margin: 10 * mb}
+	c.Assert(prompt.add(common.CopyTransfer{SourceSize: 200 * mb}), chk.NotNil)
+
+	var nilCheck *lowSpaceCheck
+	c.Assert(nilCheck.add(common.CopyTransfer{SourceSize: 200 * mb}), chk.IsNil)
+	c.Assert(newLowSpaceCheck(common.ELowSpaceAction.Fail(), common.EFromTo.LocalBlob(), "https://account.blob.core.windows.net/container"), chk.IsNil)
+	c.Assert(newLowSpaceCheck(common.ELowSpaceAction.Fail(), common.EFromTo.BlobLocal(), common.Dev_Null), chk.IsNil)
+}
+
+func (s *lowSpaceSuite) TestPausedJobIsAbortedWhenItMayNotGoAhead(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	cancelled := make([]common.JobID, 0)
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.CancelJob())
+		cancelled = append(cancelled, request.(common.JobID))
+		*(response.(*common.CancelPauseResumeResponse)) = common.CancelPauseResumeResponse{CancelledPauseResumed: true}
+	}
+
+	summary := common.ListJobSummaryResponse{JobID: common.NewJobID(), JobStatus: common.EJobStatus.InProgress()}
+	warn := &lowSpaceCheck{action: common.ELowSpaceAction.Warn(), destination: "/data", margin: 10 * mb}
+	fail := &lowSpaceCheck{action: common.ELowSpaceAction.Fail(), destination: "/data", margin: 10 * mb}
+	warn.check(summary.JobID, summary)
+	fail.check(summary.JobID, summary)
+	c.Assert(fail.reason, chk.Equals, "")
+
+	summary.LowSpacePaused = true
+	warn.check(summary.JobID, summary)
+	c.Assert(warn.actedOnPause, chk.Equals, true)
+	c.Assert(warn.reason, chk.Equals, "")
+	fail.check(summary.JobID, summary)
+	fail.check(summary.JobID, summary) // only aborts once
+	c.Assert(fail.reason, chk.Not(chk.Equals), "")
+	c.Assert(cancelled, chk.DeepEquals, []common.JobID{summary.JobID})
+
+	summary.JobStatus = common.EJobStatus.Cancelled()
+	summary.TotalTransfers, summary.TransfersCompleted = 10, 4
+	fail.reportAbort(&summary)
+	c.Assert(summary.FailFastReason, chk.Equals, fail.reason)
+	c.Assert(summary.TransfersNotAttempted, chk.Equals, uint32(6))
+	c.Assert(summary.JobStatus, chk.Equals, common.EJobStatus.CompletedWithErrors())
+
+	// a pause that ended is warned about again if there's another
+	summary.LowSpacePaused = false
+	warn.check(summary.JobID, summary)
+	c.Assert(warn.actedOnPause, chk.Equals, false)
+}
@@ -0,0 +1,129 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type metricsEndpointSuite struct{}
+
+var _ = chk.Suite(&metricsEndpointSuite{})
+
+type fakeMetricsEngine struct{}
+
+func (fakeMetricsEngine) BytesOverWire() int64     { return 0 }
+func (fakeMetricsEngine) CurrentMainPoolSize() int { return 32 }
+func (fakeMetricsEngine) MbpsCap() int64           { return 50 }
+
+func (s *metricsEndpointSuite) TestMetricsListenMustHaveAPort(c *chk.C) {
+	c.Assert(validateMetricsListen(""), chk.IsNil)
+	c.Assert(validateMetricsListen(":9090"), chk.IsNil)
+	c.Assert(validateMetricsListen("127.0.0.1:9090"), chk.IsNil)
+	c.Assert(validateMetricsListen("9090"), chk.NotNil)
+	c.Assert(validateMetricsListen("localhost"), chk.NotNil)
+}
+
+func (s *metricsEndpointSuite) TestNilEndpointIgnoresJobs(c *chk.C) {
+	var m *metricsEndpoint
+	m.jobStarted(common.NewJobID(), nil, nil)
+}
+
+func (s *metricsEndpointSuite) TestCountersAddUpAcrossJobs(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	originalRpc := Rpc
+	defer func() { Rpc = originalRpc }()
+
+	firstJob, secondJob := common.NewJobID(), common.NewJobID()
+	summaries := map[common.JobID]common.ListJobSummaryResponse{
+		firstJob: {
+			CompleteJobOrdered:    true,
+			TotalTransfers:        5,
+			TransfersCompleted:    3,
+			TransfersFailed:       1,
+			TransfersSkipped:      1,
+			TotalBytesTransferred: 1000,
+			RetryCount:            3,
+			RequestCountsByStatus: map[int]int64{201: 10, 503: 2},
+			FailedTransfers:       []common.TransferDetail{{Src: "/secret/path", Dst: "https://account.blob.core.windows.net/c/f?sig=secret"}},
+		},
+		secondJob: {
+			TotalTransfers:        2,
+			TransfersCompleted:    1,
+			TotalBytesTransferred: 500,
+			RequestCountsByStatus: map[int]int64{201: 1},
+		},
+	}
+	Rpc = func(cmd common.RpcCmd, request interface{}, response interface{}) {
+		c.Assert(cmd, chk.Equals, common.ERpcCmd.ListJobSummary())
+		*(response.(*common.ListJobSummaryResponse)) = summaries[*request.(*common.JobID)]
+	}
+
+	m := newMetricsEndpoint(fakeMetricsEngine{})
+	m.jobStarted(firstJob, nil, nil)
+	first := scrapeMetrics(c, m)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_bytes_transferred_total 1000\n.*`)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_transfers_total\{status="completed"\} 3\n.*`)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_retries_total\{status="503"\} 2\n.*`)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_retries_total\{status="network_error"\} 1\n.*`)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_http_responses_total\{status="201"\} 10\n.*`)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_active_chunk_workers 32\n.*`)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_cap_megabits_per_second 50\n.*`)
+	c.Assert(first, chk.Matches, `(?s).*\nazcopy_enumeration_complete 1\n.*`)
+	c.Assert(strings.Contains(first, "secret"), chk.Equals, false)
+	c.Assert(strings.Contains(first, "azcopy_objects_scanned"), chk.Equals, false)
+
+	// until the next job has a summary, only the one before it counts
+	ready := false
+	m.jobStarted(secondJob, func() bool { return ready }, func() (uint64, uint64) { return 7, 4 })
+	second := scrapeMetrics(c, m)
+	c.Assert(second, chk.Matches, `(?s).*\nazcopy_bytes_transferred_total 1000\n.*`)
+	c.Assert(second, chk.Matches, `(?s).*\nazcopy_enumeration_complete 0\n.*`)
+	c.Assert(second, chk.Matches, `(?s).*\nazcopy_objects_scanned\{side="source"\} 7\n.*`)
+
+	ready = true
+	third := scrapeMetrics(c, m)
+	c.Assert(third, chk.Matches, `(?s).*\nazcopy_bytes_transferred_total 1500\n.*`)
+	c.Assert(third, chk.Matches, `(?s).*\nazcopy_transfers_total\{status="completed"\} 4\n.*`)
+	c.Assert(third, chk.Matches, `(?s).*\nazcopy_http_responses_total\{status="201"\} 11\n.*`)
+	c.Assert(third, chk.Matches, `(?s).*\nazcopy_transfers_enumerated 2\n.*`)
+
+	// the bytes of a transfer that fails are taken back, but the counter doesn't go down
+	summaries[secondJob] = common.ListJobSummaryResponse{TotalBytesTransferred: 100}
+	c.Assert(scrapeMetrics(c, m), chk.Matches, `(?s).*\nazcopy_bytes_transferred_total 1500\n.*`)
+}
+
+func scrapeMetrics(c *chk.C, m *metricsEndpoint) string {
+	recorder := httptest.NewRecorder()
+	m.serveMetrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	c.Assert(recorder.Header().Get("Content-Type"), chk.Equals, "text/plain; version=0.0.4; charset=utf-8")
+
+	var b bytes.Buffer
+	b.WriteString("\n") // so that every sample follows a newline
+	b.Write(recorder.Body.Bytes())
+	return b.String()
+}
@@ -56,3 +56,25 @@ func (s *parseSizeSuite) TestParseSize(c *chk.C) {
 	c.Assert(err.Error(), chk.Equals, expectedError)
 
 }
+
+func (s *parseSizeSuite) TestParseSizeSpec(c *chk.C) {
+	buckets, err := parseSizeSpec("100000x4KiB,5000x1MiB, 50x2g")
+	c.Assert(err, chk.IsNil)
+	c.Assert(buckets, chk.DeepEquals, []benchmarkFileBucket{
+		{fileCount: 100000, bytesPerFile: 4 * 1024},
+		{fileCount: 5000, bytesPerFile: 1024 * 1024},
+		{fileCount: 50, bytesPerFile: 2 * 1024 * 1024 * 1024},
+	})
+
+	// the string form is suitable for use as a directory name, and can be parsed again
+	c.Assert(buckets[0].String(), chk.Equals, "100000x4KiB")
+	c.Assert(buckets[2].String(), chk.Equals, "50x2GiB")
+	reparsed, err := parseSizeSpec(buckets[1].String())
+	c.Assert(err, chk.IsNil)
+	c.Assert(reparsed[0], chk.Equals, buckets[1])
+
+	for _, invalid := range []string{"", "100", "0x4KiB", "x4KiB", "100x", "100x4KB", "100x4KiBx2", "-5x1M"} {
+		_, err = parseSizeSpec(invalid)
+		c.Assert(err, chk.NotNil, chk.Commentf("spec %q", invalid))
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	chk "gopkg.in/check.v1"
+)
+
+type pathPatternFilterSuite struct{}
+
+var _ = chk.Suite(&pathPatternFilterSuite{})
+
+func (s *pathPatternFilterSuite) TestMatchPathPattern(c *chk.C) {
+	c.Assert(matchPathPattern("**/temp/**", "temp/file"), chk.Equals, true)
+	c.Assert(matchPathPattern("**/temp/**", "a/b/temp/c/file"), chk.Equals, true)
+	c.Assert(matchPathPattern("**/temp/**", "a/temporary/file"), chk.Equals, false)
+
+	c.Assert(matchPathPattern("logs/2023/*", "logs/2023/app.log"), chk.Equals, true)
+	c.Assert(matchPathPattern("logs/2023/*", "logs/2023/01/app.log"), chk.Equals, false)
+	c.Assert(matchPathPattern("logs/2023/*", "archive/logs/2023/app.log"), chk.Equals, false)
+
+	c.Assert(matchPathPattern("**/*.csv", "report.csv"), chk.Equals, true)
+	c.Assert(matchPathPattern("**/*.csv", "a/b/report.csv"), chk.Equals, true)
+	c.Assert(matchPathPattern("a/**", "a"), chk.Equals, true)
+	c.Assert(matchPathPattern("a/**", "ab/file"), chk.Equals, false)
+}
+
+func (s *pathPatternFilterSuite) TestNormalizePathPatterns(c *chk.C) {
+	patterns, err := normalizePathPatterns("exclude-path-pattern", []string{"/logs/**/", "", "*.tmp"})
+	c.Assert(err, chk.IsNil)
+	c.Assert(patterns, chk.DeepEquals, []string{"logs/**", "*.tmp"})
+
+	_, err = normalizePathPatterns("exclude-path-pattern", []string{"logs/[a"})
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *pathPatternFilterSuite) TestExcludeWinsOverInclude(c *chk.C) {
+	filters := buildPathPatternFilters([]string{"**/*.csv"}, []string{"**/temp/**"})
+	passes := func(relativePath string) bool {
+		for _, f := range filters {
+			if !f.doesPass(storedObject{relativePath: relativePath}) {
+				return false
+			}
+		}
+		return true
+	}
+
+	c.Assert(passes("data/report.csv"), chk.Equals, true)
+	c.Assert(passes("data/temp/report.csv"), chk.Equals, false)
+	c.Assert(passes("data/report.txt"), chk.Equals, false)
+	c.Assert(buildPathPatternFilters(nil, nil), chk.HasLen, 0)
+}
+
+func (s *pathPatternFilterSuite) TestDirectoryExcludesAreNotEntered(c *chk.C) {
+	limits := newTraversalLimits(0, nil, nil, []string{"**/temp/**", "*.tmp"})
+	c.Assert(limits, chk.NotNil)
+
+	c.Assert(limits.entersDirectory("temp"), chk.Equals, false)
+	c.Assert(limits.entersDirectory("a/b/temp"), chk.Equals, false)
+	c.Assert(limits.entersDirectory("a/temporary"), chk.Equals, true)
+	c.Assert(limits.directoriesSkipped(), chk.Equals, uint64(2))
+
+	// a pattern that doesn't end in /** can't exclude everything under a directory
+	c.Assert(newTraversalLimits(0, nil, nil, []string{"*.tmp"}), chk.IsNil)
+}
@@ -0,0 +1,109 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type preflightSuite struct{}
+
+var _ = chk.Suite(&preflightSuite{})
+
+func (s *preflightSuite) TestFindUnsupportedFeatures(c *chk.C) {
+	tiers := requestedFeatures{blockBlobTier: common.EBlockBlobTier.Cool(), pageBlobTier: common.EPageBlobTier.P10()}
+
+	// nothing can be concluded about an account that couldn't be probed
+	c.Assert(findUnsupportedFeatures(tiers, nil, nil), chk.HasLen, 0)
+
+	standard := &destinationAccount{skuName: "Standard_LRS", kind: "StorageV2"}
+	unsupported := findUnsupportedFeatures(tiers, standard, nil)
+	c.Assert(unsupported, chk.HasLen, 1)
+	c.Assert(unsupported[0].flag, chk.Equals, "page-blob-tier")
+
+	premiumBlockBlobs := &destinationAccount{skuName: "Premium_LRS", kind: "BlockBlobStorage"}
+	c.Assert(findUnsupportedFeatures(tiers, premiumBlockBlobs, nil), chk.HasLen, 2)
+	c.Assert(findUnsupportedFeatures(requestedFeatures{preserveAccessTier: true}, premiumBlockBlobs, nil), chk.HasLen, 1)
+	c.Assert(findUnsupportedFeatures(requestedFeatures{preserveAccessTier: true}, standard, nil), chk.HasLen, 0)
+
+	tags := requestedFeatures{blobTags: true}
+	c.Assert(findUnsupportedFeatures(tags, standard, nil), chk.HasLen, 0)
+	c.Assert(findUnsupportedFeatures(tags, &destinationAccount{skuName: "Standard_LRS", kind: "StorageV2", hnsEnabled: true}, nil), chk.HasLen, 1)
+	unsupported = findUnsupportedFeatures(tags, nil, errors.New("FeatureNotSupported"))
+	c.Assert(unsupported, chk.HasLen, 1)
+	c.Assert(unsupported[0].reason, chk.Matches, ".*FeatureNotSupported.*")
+}
+
+func (s *preflightSuite) TestUnsupportedFeaturesFailTheJobUnlessBestEffort(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	c.Assert(checkUnsupportedFeatures(nil, false), chk.IsNil)
+
+	unsupported := []unsupportedFeature{{"block-blob-tier", "no tiers"}, {"blob-tags", "no tags"}}
+	c.Assert(checkUnsupportedFeatures(unsupported, false), chk.ErrorMatches,
+		"the destination does not support these requested features: --block-blob-tier \\(no tiers\\); --blob-tags \\(no tags\\). Use --best-effort-features .*")
+	c.Assert(checkUnsupportedFeatures(unsupported, true), chk.IsNil)
+}
+
+func (s *preflightSuite) TestBestEffortDisablesUnsupportedPropertiesJobWide(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	originalAccount, originalTags := getDestinationAccount, probeBlobTags
+	defer func() { getDestinationAccount, probeBlobTags = originalAccount, originalTags }()
+	getDestinationAccount = func(ctx context.Context, p pipeline.Pipeline, u url.URL) (destinationAccount, error) {
+		return destinationAccount{skuName: "Standard_LRS", kind: "StorageV2", hnsEnabled: true}, nil
+	}
+	probeBlobTags = func(ctx context.Context, p pipeline.Pipeline, u url.URL, jobID common.JobID) error {
+		c.Error("the tags of an account with a hierarchical namespace should not be probed")
+		return nil
+	}
+
+	newSetProperties := func(flags common.SetPropertiesFlags, bestEffort bool) *cookedCopyCmdArgs {
+		return &cookedCopyCmdArgs{
+			source:               "https://account.blob.core.windows.net/container/dir",
+			fromTo:               common.EFromTo.BlobNone(),
+			blockBlobTier:        common.EBlockBlobTier.Cool(),
+			pageBlobTier:         common.EPageBlobTier.None(),
+			propertiesToTransfer: flags,
+			bestEffortFeatures:   bestEffort,
+			jobID:                common.NewJobID(),
+		}
+	}
+
+	tierAndTags := common.ESetPropertiesFlags.SetTier() | common.ESetPropertiesFlags.SetBlobTags()
+	c.Assert(newSetProperties(tierAndTags, false).preflightDestination(context.TODO()), chk.ErrorMatches, ".*--blob-tags.*")
+
+	cca := newSetProperties(tierAndTags, true)
+	c.Assert(cca.preflightDestination(context.TODO()), chk.IsNil)
+	c.Assert(cca.propertiesToTransfer, chk.Equals, common.ESetPropertiesFlags.SetTier())
+	c.Assert(cca.blockBlobTier, chk.Equals, common.EBlockBlobTier.Cool())
+
+	// with nothing left to set, there's no job to run
+	c.Assert(newSetProperties(common.ESetPropertiesFlags.SetBlobTags(), true).preflightDestination(context.TODO()), chk.NotNil)
+}
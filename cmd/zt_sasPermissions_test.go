@@ -0,0 +1,129 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type sasPermissionsSuite struct{}
+
+var _ = chk.Suite(&sasPermissionsSuite{})
+
+const (
+	containerURL = "https://account.blob.core.windows.net/container"
+	// the signatures don't matter, since only the permissions are checked
+	serviceSASRead        = "sv=2019-02-02&sr=c&sp=r&se=2030-01-01T00%3A00%3A00Z&sig=c2ln"
+	serviceSASReadList    = "sv=2019-02-02&sr=c&sp=rl&se=2030-01-01T00%3A00%3A00Z&sig=c2ln"
+	userDelegationSASList = "sv=2019-02-02&sr=c&sp=wl&skoid=oid&sktid=tid&skt=2020-01-01T00%3A00%3A00Z&ske=2030-01-01T00%3A00%3A00Z&sks=b&skv=2019-02-02&sig=c2ln"
+	accountSASObjectsOnly = "sv=2019-02-02&ss=b&srt=o&sp=rwdl&se=2030-01-01T00%3A00%3A00Z&sig=c2ln"
+	accountSASFileOnly    = "sv=2019-02-02&ss=f&srt=sco&sp=rwdl&se=2030-01-01T00%3A00%3A00Z&sig=c2ln"
+)
+
+func (s *sasPermissionsSuite) TestParseSASToken(c *chk.C) {
+	token, ok := parseSASToken(userDelegationSASList)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(token, chk.Equals, sasToken{permissions: "wl"})
+
+	token, ok = parseSASToken("?" + accountSASObjectsOnly)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(token, chk.Equals, sasToken{permissions: "rwdl", resourceTypes: "o", services: "b"})
+
+	// the permissions of a stored access policy aren't in the token
+	_, ok = parseSASToken("sv=2019-02-02&sr=c&si=policy&sig=c2ln")
+	c.Assert(ok, chk.Equals, false)
+
+	// nor is a query without a signature a SAS
+	_, ok = parseSASToken("sp=r&comp=list")
+	c.Assert(ok, chk.Equals, false)
+}
+
+func (s *sasPermissionsSuite) TestCopyNeedsToListAndReadTheSourceAndWriteTheDestination(c *chk.C) {
+	download := cookedCopyCmdArgs{
+		fromTo:      common.EFromTo.BlobLocal(),
+		source:      containerURL + "?" + serviceSASRead,
+		destination: "/data",
+		forceWrite:  common.EOverwriteOption.True(),
+	}
+	err := validateCopySASPermissions(download, false)
+	c.Assert(err, chk.ErrorMatches, "the SAS of the source lacks the List permission .l in sp=., which is needed to list the source.*ignore-sas-validation.*")
+
+	// a list of files is fetched one by one
+	c.Assert(validateCopySASPermissions(download, true), chk.IsNil)
+
+	// and so is a blob, as far as can be known without asking the service
+	download.source = containerURL + "/dir/blob?" + serviceSASRead
+	c.Assert(validateCopySASPermissions(download, false), chk.IsNil)
+	download.stripTopDir = true // a trailing /*
+	c.Assert(validateCopySASPermissions(download, false), chk.NotNil)
+
+	download.source = containerURL + "?" + serviceSASReadList
+	c.Assert(validateCopySASPermissions(download, false), chk.IsNil)
+
+	upload := cookedCopyCmdArgs{
+		fromTo:      common.EFromTo.LocalBlob(),
+		source:      "/data",
+		destination: containerURL + "?" + serviceSASReadList,
+		forceWrite:  common.EOverwriteOption.True(),
+	}
+	c.Assert(validateCopySASPermissions(upload, false), chk.ErrorMatches, "the SAS of the destination lacks the Write permission .*")
+
+	// without overwrite, the destination is read to see whether the files are there
+	upload.destination = containerURL + "?" + userDelegationSASList
+	c.Assert(validateCopySASPermissions(upload, false), chk.IsNil)
+	upload.forceWrite = common.EOverwriteOption.False()
+	c.Assert(validateCopySASPermissions(upload, false), chk.ErrorMatches, "the SAS of the destination lacks the Read permission .*")
+
+	// no SAS, no check
+	upload.destination = containerURL
+	c.Assert(validateCopySASPermissions(upload, false), chk.IsNil)
+}
+
+func (s *sasPermissionsSuite) TestAccountSASNeedsTheServiceAndResourceTypes(c *chk.C) {
+	download := cookedCopyCmdArgs{
+		fromTo:      common.EFromTo.BlobLocal(),
+		source:      containerURL + "?" + accountSASObjectsOnly,
+		destination: "/data",
+		forceWrite:  common.EOverwriteOption.True(),
+	}
+	c.Assert(validateCopySASPermissions(download, false), chk.ErrorMatches, "the SAS of the source doesn't allow List on containers .c in srt=.*")
+
+	download.source = containerURL + "?" + accountSASFileOnly
+	c.Assert(validateCopySASPermissions(download, false), chk.ErrorMatches, "the SAS of the source is not for the Blob service .ss=f.*")
+}
+
+func (s *sasPermissionsSuite) TestSyncNeedsToListTheDestinationAndDeleteFromIt(c *chk.C) {
+	sync := cookedSyncCmdArgs{
+		fromTo:            common.EFromTo.LocalBlob(),
+		source:            "/data",
+		destination:       containerURL,
+		destinationSAS:    "sv=2019-02-02&sr=c&sp=w&sig=c2ln",
+		deleteDestination: common.EDeleteDestination.False(),
+	}
+	c.Assert(validateSyncSASPermissions(sync), chk.ErrorMatches, "the SAS of the destination lacks the List permission .*copied again.*")
+
+	sync.destinationSAS = userDelegationSASList
+	c.Assert(validateSyncSASPermissions(sync), chk.IsNil)
+
+	sync.deleteDestination = common.EDeleteDestination.True()
+	c.Assert(validateSyncSASPermissions(sync), chk.ErrorMatches, "the SAS of the destination lacks the Delete permission .*")
+}
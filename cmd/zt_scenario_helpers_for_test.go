@@ -689,7 +689,12 @@ func getDefaultCopyRawInput(src string, dst string) rawCopyCmdArgs {
 		pageBlobTier:                   common.EPageBlobTier.None().String(),
 		md5ValidationOption:            common.DefaultHashValidationOption.String(),
 		s2sInvalidMetadataHandleOption: defaultS2SInvalideMetadataHandleOption.String(),
+		s2sFallback:                    common.ES2SFallback.None().String(),
+		transferOrder:                  common.ETransferOrder.AsEnumerated().String(),
+		flushPolicy:                    common.EFlushPolicy.None().String(),
+		lowSpaceAction:                 common.ELowSpaceAction.Warn().String(),
 		forceWrite:                     common.EOverwriteOption.True().String(),
+		asSubdir:                       true,
 	}
 }
 
@@ -712,6 +717,11 @@ func getDefaultRemoveRawInput(src string) rawCopyCmdArgs {
 		pageBlobTier:                   common.EPageBlobTier.None().String(),
 		md5ValidationOption:            common.DefaultHashValidationOption.String(),
 		s2sInvalidMetadataHandleOption: defaultS2SInvalideMetadataHandleOption.String(),
+		s2sFallback:                    common.ES2SFallback.None().String(),
+		transferOrder:                  common.ETransferOrder.AsEnumerated().String(),
+		flushPolicy:                    common.EFlushPolicy.None().String(),
+		lowSpaceAction:                 common.ELowSpaceAction.Warn().String(),
 		forceWrite:                     common.EOverwriteOption.True().String(),
+		asSubdir:                       true,
 	}
 }
@@ -0,0 +1,51 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"runtime"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type shadowCopySuite struct{}
+
+var _ = chk.Suite(&shadowCopySuite{})
+
+func (s *shadowCopySuite) TestPathInShadowCopy(c *chk.C) {
+	devicePath := `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3`
+
+	c.Assert(pathInShadowCopy(devicePath, "C:", `C:\`), chk.Equals, devicePath+`\`)
+	c.Assert(pathInShadowCopy(devicePath, "C:", `C:\data`), chk.Equals, devicePath+`\data`)
+	c.Assert(pathInShadowCopy(devicePath+`\`, "C:", `C:\data\logs\app.log`), chk.Equals, devicePath+`\data\logs\app.log`)
+	c.Assert(pathInShadowCopy(devicePath, "C:", `C:/data/*`), chk.Equals, devicePath+`\data\*`)
+}
+
+func (s *shadowCopySuite) TestUseVssNeedsALocalSource(c *chk.C) {
+	err := validateUseVss(common.EFromTo.BlobLocal())
+	c.Assert(err, chk.NotNil)
+	c.Assert(err.Error(), chk.Equals, "use-vss is only supported while uploading local files")
+
+	if runtime.GOOS != "windows" {
+		c.Assert(validateUseVss(common.EFromTo.LocalBlob()), chk.ErrorMatches, "use-vss is only supported on Windows")
+	}
+}
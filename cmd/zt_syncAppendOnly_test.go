@@ -0,0 +1,72 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type syncAppendOnlySuite struct{}
+
+var _ = chk.Suite(&syncAppendOnlySuite{})
+
+func (s *syncAppendOnlySuite) TestAppendOnlyUploadsAppendBlobs(c *chk.C) {
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+
+	raw := getDefaultSyncRawInput(dir, "https://account.blob.core.windows.net/container")
+	raw.appendOnly = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.appendOnly, chk.Equals, true)
+
+	template := newSyncTransferProcessor(&cooked, NumOfFilesPerDispatchJobPart).copyJobTemplate
+	c.Assert(template.BlobAttributes.BlobType, chk.Equals, common.EBlobType.AppendBlob())
+	c.Assert(template.AppendOnly, chk.Equals, true)
+}
+
+func (s *syncAppendOnlySuite) TestAppendOnlyValidation(c *chk.C) {
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+
+	// ADLS Gen2 is only a destination of append-only syncs
+	raw := getDefaultSyncRawInput(dir, "https://account.dfs.core.windows.net/filesystem")
+	_, err := raw.cook()
+	c.Assert(err, chk.NotNil)
+	raw.appendOnly = true
+	cooked, err := raw.cook()
+	c.Assert(err, chk.IsNil)
+	c.Assert(cooked.fromTo, chk.Equals, common.EFromTo.LocalBlobFS())
+
+	raw = getDefaultSyncRawInput("https://account.blob.core.windows.net/container", dir)
+	raw.appendOnly = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+
+	raw = getDefaultSyncRawInput(dir, "https://account.blob.core.windows.net/container")
+	raw.appendOnly = true
+	raw.putMd5 = true
+	_, err = raw.cook()
+	c.Assert(err, chk.NotNil)
+}
@@ -0,0 +1,177 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type syncWatchSuite struct{}
+
+var _ = chk.Suite(&syncWatchSuite{})
+
+func (s *syncWatchSuite) TestChangesAreSyncedOnceTheySettle(c *chk.C) {
+	start := time.Now()
+	queue := newWatchQueue(2 * time.Second)
+
+	queue.add(watchEvent{relativePath: "log.txt"}, start)
+	queue.add(watchEvent{relativePath: "dir/a.txt"}, start)
+	queue.add(watchEvent{relativePath: "log.txt"}, start.Add(time.Second)) // still being written
+
+	_, isRescan, changes := queue.next(start.Add(time.Second))
+	c.Assert(isRescan, chk.Equals, false)
+	c.Assert(changes, chk.HasLen, 0)
+
+	_, _, changes = queue.next(start.Add(2 * time.Second))
+	c.Assert(changes, chk.DeepEquals, []string{"dir/a.txt"})
+	c.Assert(queue.pending(), chk.Equals, 1)
+
+	_, _, changes = queue.next(start.Add(3 * time.Second))
+	c.Assert(changes, chk.DeepEquals, []string{"log.txt"})
+	c.Assert(queue.pending(), chk.Equals, 0)
+}
+
+func (s *syncWatchSuite) TestRescansCoverTheChangesUnderThem(c *chk.C) {
+	start := time.Now()
+	queue := newWatchQueue(0)
+
+	queue.add(watchEvent{relativePath: "dir/a.txt"}, start)
+	queue.add(watchEvent{relativePath: "dirt.txt"}, start)
+	queue.add(watchEvent{relativePath: "dir/sub", overflow: true}, start)
+	queue.addRescan("dir", start)
+
+	rescan, isRescan, _ := queue.next(start)
+	c.Assert(isRescan, chk.Equals, true)
+	c.Assert(rescan, chk.Equals, "dir")
+
+	_, isRescan, changes := queue.next(start)
+	c.Assert(isRescan, chk.Equals, false)
+	c.Assert(changes, chk.DeepEquals, []string{"dirt.txt"})
+
+	// an overflow of the whole source covers everything
+	queue.add(watchEvent{relativePath: "x"}, start)
+	queue.add(watchEvent{overflow: true}, start)
+	rescan, isRescan, _ = queue.next(start)
+	c.Assert(isRescan, chk.Equals, true)
+	c.Assert(rescan, chk.Equals, "")
+	c.Assert(queue.pending(), chk.Equals, 0)
+}
+
+func (s *syncWatchSuite) TestExcludedPathsAreScopedToTheRescan(c *chk.C) {
+	scoped, excluded := scopeExcludePaths([]string{"dir/tmp", "other", "dir/sub/cache/"}, "dir/sub")
+	c.Assert(excluded, chk.Equals, false)
+	c.Assert(scoped, chk.DeepEquals, []string{"cache"})
+
+	_, excluded = scopeExcludePaths([]string{"dir"}, "dir/sub")
+	c.Assert(excluded, chk.Equals, true)
+
+	scoped, excluded = scopeExcludePaths([]string{"dir"}, "")
+	c.Assert(excluded, chk.Equals, false)
+	c.Assert(scoped, chk.DeepEquals, []string{"dir"})
+}
+
+func (s *syncWatchSuite) TestWatchNeedsALocalSourceAndNoPrompt(c *chk.C) {
+	raw := &rawSyncCmdArgs{watch: true}
+	c.Assert(validateWatch(raw, cookedSyncCmdArgs{fromTo: common.EFromTo.BlobLocal()}), chk.ErrorMatches, "watch is only supported .*")
+	c.Assert(validateWatch(raw, cookedSyncCmdArgs{fromTo: common.EFromTo.LocalBlob(), deleteDestination: common.EDeleteDestination.Prompt()}),
+		chk.ErrorMatches, "watch cannot be used with delete-destination=prompt.*")
+	c.Assert(validateWatch(raw, cookedSyncCmdArgs{fromTo: common.EFromTo.LocalBlob(), deleteDestination: common.EDeleteDestination.True()}), chk.IsNil)
+}
+
+func (s *syncWatchSuite) TestSourceWatcherReportsChanges(c *chk.C) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		c.Skip("the source can only be watched on Linux and Windows")
+	}
+
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	c.Assert(os.Mkdir(filepath.Join(dir, "sub"), 0777), chk.IsNil)
+
+	events := make(chan watchEvent, 100)
+	watcher, err := newSourceWatcher(dir, true, events)
+	c.Assert(err, chk.IsNil)
+	defer watcher.close()
+	c.Assert(watcher.addDirectory("sub"), chk.IsNil)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0666), chk.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0666), chk.IsNil)
+	c.Assert(os.Remove(filepath.Join(dir, "a.txt")), chk.IsNil)
+
+	seen := make(map[string]bool)
+	timeout := time.After(10 * time.Second)
+	for !seen["a.txt"] || !seen["sub/b.txt"] {
+		select {
+		case event := <-events:
+			seen[event.relativePath] = true
+		case <-timeout:
+			c.Fatalf("only saw %v", seen)
+		}
+	}
+}
+
+// dirRecordingWatcher only records the directories it's asked to watch
+type dirRecordingWatcher struct {
+	dirs []string
+}
+
+func (w *dirRecordingWatcher) addDirectory(relativePath string) error {
+	w.dirs = append(w.dirs, relativePath)
+	return nil
+}
+
+func (w *dirRecordingWatcher) close() {}
+
+func (s *syncWatchSuite) TestChangesAreClassifiedByWhatThePathIsNow(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	scenarioHelper{}.generateLocalFilesFromList(c, dir, []string{"kept.txt", "skipped.tmp", "new/nested/file.txt"})
+
+	sourceWatcher := &dirRecordingWatcher{}
+	w := newSyncWatcher(0)
+	w.base = &cookedSyncCmdArgs{source: dir, fromTo: common.EFromTo.LocalBlob(), recursive: true,
+		excludePatterns: []string{"*.tmp"}, deleteDestination: common.EDeleteDestination.True()}
+	w.sourceWatcher = sourceWatcher
+	w.dirs["gone"] = true
+	w.dirs["gone/sub"] = true
+
+	uploads, removals := w.classifyChanges([]string{"kept.txt", "skipped.tmp", "new", "deleted.txt", "gone"})
+
+	c.Assert(uploads, chk.HasLen, 1)
+	c.Assert(uploads[0].relativePath, chk.Equals, "kept.txt")
+	c.Assert(removals, chk.DeepEquals, []string{"deleted.txt"})
+
+	// the new directory is watched with what's under it, and compared with the destination, and so is the parent of the one that went away
+	c.Assert(sourceWatcher.dirs, chk.DeepEquals, []string{"new", "new/nested"})
+	c.Assert(w.dirs["gone"] || w.dirs["gone/sub"], chk.Equals, false)
+	rescan, _, _ := w.queue.next(time.Now())
+	c.Assert(rescan, chk.Equals, "")
+	c.Assert(w.queue.pending(), chk.Equals, 0)
+}
@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"github.com/Azure/azure-storage-azcopy/common"
 	chk "gopkg.in/check.v1"
 	"time"
 )
@@ -36,7 +37,7 @@ func (s *syncComparatorSuite) TestSyncSourceComparator(c *chk.C) {
 
 	// set up the indexer as well as the source comparator
 	indexer := newObjectIndexer()
-	sourceComparator := newSyncSourceComparator(indexer, dummyCopyScheduler.process)
+	sourceComparator := newSyncSourceComparator(indexer, dummyCopyScheduler.process, false, nil)
 
 	// create a sample destination object
 	sampleDestinationObject := storedObject{name: "test", relativePath: "/usr/test", lastModifiedTime: time.Now(), md5: destMD5}
@@ -89,7 +90,7 @@ func (s *syncComparatorSuite) TestSyncDestinationComparator(c *chk.C) {
 
 	// set up the indexer as well as the destination comparator
 	indexer := newObjectIndexer()
-	destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process)
+	destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process, false, nil)
 
 	// create a sample source object
 	sampleSourceObject := storedObject{name: "test", relativePath: "/usr/test", lastModifiedTime: time.Now(), md5: srcMD5}
@@ -137,3 +138,138 @@ func (s *syncComparatorSuite) TestSyncDestinationComparator(c *chk.C) {
 	c.Assert(dummyCopyScheduler.record[0].md5, chk.DeepEquals, srcMD5)
 	c.Assert(len(dummyCleaner.record), chk.Equals, 0)
 }
+
+func (s *syncComparatorSuite) TestCompareLastModifiedTimes(c *chk.C) {
+	local := time.Date(2020, 3, 4, 5, 6, 7, 700000000, time.UTC)
+
+	// equal timestamps are in sync
+	c.Assert(compareLastModifiedTimes(local, local), chk.Equals, 0)
+	c.Assert(compareLastModifiedTimes(local.Truncate(time.Second), local.Truncate(time.Second)), chk.Equals, 0)
+
+	// the service only has the second, so a local file within that second is neither newer nor older
+	c.Assert(compareLastModifiedTimes(local, local.Truncate(time.Second)), chk.Equals, 0)
+	c.Assert(compareLastModifiedTimes(local.Truncate(time.Second), local), chk.Equals, 0)
+	c.Assert(compareLastModifiedTimes(local.Add(time.Second), local.Truncate(time.Second)), chk.Equals, 1)
+	c.Assert(compareLastModifiedTimes(local.Truncate(time.Second), local.Add(time.Second)), chk.Equals, -1)
+
+	// when both sides have fractions of a second, they count
+	c.Assert(compareLastModifiedTimes(local, local.Add(-200*time.Millisecond)), chk.Equals, 1)
+	c.Assert(compareLastModifiedTimes(local.Add(-200*time.Millisecond), local), chk.Equals, -1)
+}
+
+func (s *syncComparatorSuite) TestSyncSourceComparatorReportsNewerDestinations(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	synced := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, mirrorMode := range []bool{false, true} {
+		dummyCopyScheduler := dummyProcessor{}
+		indexer := newObjectIndexer()
+		var destinationNewer *destinationNewerReporter
+		if !mirrorMode {
+			destinationNewer = newDestinationNewerReporter()
+		}
+		sourceComparator := newSyncSourceComparator(indexer, dummyCopyScheduler.process, mirrorMode, destinationNewer)
+
+		// in sync, to the second that the destination has
+		c.Assert(indexer.store(storedObject{name: "same", relativePath: "same", lastModifiedTime: synced}), chk.IsNil)
+		c.Assert(sourceComparator.processIfNecessary(storedObject{name: "same", relativePath: "same", lastModifiedTime: synced.Add(300 * time.Millisecond)}), chk.IsNil)
+
+		// edited at the destination
+		c.Assert(indexer.store(storedObject{name: "edited", relativePath: "edited", lastModifiedTime: synced.Add(time.Hour)}), chk.IsNil)
+		c.Assert(sourceComparator.processIfNecessary(storedObject{name: "edited", relativePath: "edited", lastModifiedTime: synced}), chk.IsNil)
+
+		if mirrorMode {
+			// the source wins
+			c.Assert(len(dummyCopyScheduler.record), chk.Equals, 1)
+			c.Assert(dummyCopyScheduler.record[0].relativePath, chk.Equals, "edited")
+		} else {
+			c.Assert(len(dummyCopyScheduler.record), chk.Equals, 0)
+			c.Assert(destinationNewer.count(), chk.Equals, uint64(1))
+			c.Assert(glcm.(*mockedLifecycleManager).logContainsText("Not overwriting edited", time.Second), chk.Equals, true)
+		}
+		c.Assert(len(indexer.indexMap), chk.Equals, 0)
+	}
+}
+
+func (s *syncComparatorSuite) TestSyncDestinationComparatorReportsNewerDestinations(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	local := time.Date(2020, 3, 4, 5, 6, 7, 700000000, time.UTC)
+	for _, mirrorMode := range []bool{false, true} {
+		dummyCopyScheduler := dummyProcessor{}
+		dummyCleaner := dummyProcessor{}
+		indexer := newObjectIndexer()
+		var destinationNewer *destinationNewerReporter
+		if !mirrorMode {
+			destinationNewer = newDestinationNewerReporter()
+		}
+		destinationComparator := newSyncDestinationComparator(indexer, dummyCopyScheduler.process, dummyCleaner.process, mirrorMode, destinationNewer)
+
+		// the local file has a fraction of a second that the service doesn't keep, so they're equal
+		c.Assert(indexer.store(storedObject{name: "same", relativePath: "same", lastModifiedTime: local}), chk.IsNil)
+		c.Assert(destinationComparator.processIfNecessary(storedObject{name: "same", relativePath: "same", lastModifiedTime: local.Truncate(time.Second)}), chk.IsNil)
+
+		// edited at the destination
+		c.Assert(indexer.store(storedObject{name: "edited", relativePath: "edited", lastModifiedTime: local}), chk.IsNil)
+		c.Assert(destinationComparator.processIfNecessary(storedObject{name: "edited", relativePath: "edited", lastModifiedTime: local.Add(time.Minute).Truncate(time.Second)}), chk.IsNil)
+
+		c.Assert(len(dummyCleaner.record), chk.Equals, 0)
+		if mirrorMode {
+			c.Assert(len(dummyCopyScheduler.record), chk.Equals, 1)
+			c.Assert(dummyCopyScheduler.record[0].relativePath, chk.Equals, "edited")
+		} else {
+			c.Assert(len(dummyCopyScheduler.record), chk.Equals, 0)
+			c.Assert(destinationNewer.count(), chk.Equals, uint64(1))
+		}
+	}
+}
+
+// listedTraverser finds the objects it's given
+type listedTraverser struct {
+	resourceTraverser
+	objects []storedObject
+}
+
+func (t *listedTraverser) traverse(_ objectMorpher, processor objectProcessor, _ []objectFilter) error {
+	for _, object := range t.objects {
+		if err := processor(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syncComparatorSuite) TestPreservedLastModifiedTimesAreCompared(c *chk.C) {
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	fileTime := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	uploaded := fileTime.Add(24 * time.Hour)
+	preserved := common.Metadata{}.WithPreservedLastModifiedTime(fileTime)
+
+	indexer := newObjectIndexer()
+	destination := &preservedLMTTraverser{resourceTraverser: &listedTraverser{objects: []storedObject{
+		{name: "unchanged", relativePath: "unchanged", lastModifiedTime: fileTime},
+		{name: "edited", relativePath: "edited", lastModifiedTime: fileTime},
+	}}}
+	c.Assert(destination.traverse(noPreProccessor, indexer.store, nil), chk.IsNil)
+
+	dummyCopyScheduler := dummyProcessor{}
+	destinationNewer := newDestinationNewerReporter()
+	sourceComparator := newSyncSourceComparator(indexer, dummyCopyScheduler.process, false, destinationNewer)
+	source := &preservedLMTTraverser{resourceTraverser: &listedTraverser{objects: []storedObject{
+		// uploaded a day after the file was last modified, but with its time preserved
+		{name: "unchanged", relativePath: "unchanged", lastModifiedTime: uploaded, Metadata: preserved},
+		// uploaded without the time, which the download compares as usual
+		{name: "edited", relativePath: "edited", lastModifiedTime: uploaded},
+	}}}
+	c.Assert(source.traverse(noPreProccessor, sourceComparator.processIfNecessary, nil), chk.IsNil)
+
+	c.Assert(len(dummyCopyScheduler.record), chk.Equals, 1)
+	c.Assert(dummyCopyScheduler.record[0].relativePath, chk.Equals, "edited")
+	// the transfer still checks the source against its own time
+	c.Assert(dummyCopyScheduler.record[0].lastModifiedTime, chk.Equals, uploaded)
+	c.Assert(destinationNewer.count(), chk.Equals, uint64(0))
+}
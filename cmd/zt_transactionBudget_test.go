@@ -0,0 +1,79 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type transactionBudgetSuite struct{}
+
+var _ = chk.Suite(&transactionBudgetSuite{})
+
+func (s *transactionBudgetSuite) TestNilBudgetPredictsAndCapsNothing(c *chk.C) {
+	c.Assert(newTransactionBudget(0, false, common.EFromTo.LocalBlob(), common.EBlobType.Detect(), 0), chk.IsNil)
+
+	var b *transactionBudget
+	c.Assert(b.add(common.CopyTransfer{SourceSize: 1024}), chk.IsNil)
+	b.check(common.NewJobID(), common.ListJobSummaryResponse{Transactions: common.TransactionCounts{Write: 100}})
+}
+
+func (s *transactionBudgetSuite) TestPredictionStopsTheEnumerationOnceOverTheCap(c *chk.C) {
+	b := newTransactionBudget(5, false, common.EFromTo.BlobLocal(), common.EBlobType.Detect(), 0)
+	for i := 0; i < 4; i++ {
+		c.Assert(b.add(common.CopyTransfer{SourceSize: 1024}), chk.IsNil)
+	}
+	// four reads, and one list request
+	c.Assert(b.prediction(), chk.Equals, common.TransactionCounts{List: 1, Read: 4})
+	c.Assert(b.add(common.CopyTransfer{SourceSize: 1024}), chk.ErrorMatches, ".*at least 6 storage transactions.*max-transactions of 5")
+	c.Assert(b.assumptions()[0], chk.Matches, "The source is listed with one request per 5000 files.*")
+}
+
+func (s *transactionBudgetSuite) TestAbortIsReportedInTheSummary(c *chk.C) {
+	b := newTransactionBudget(10, false, common.EFromTo.LocalBlob(), common.EBlobType.Detect(), 0)
+	summary := common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Cancelled(), TotalTransfers: 10, TransfersCompleted: 4}
+
+	// within the cap, the job isn't touched
+	b.check(common.NewJobID(), common.ListJobSummaryResponse{Transactions: common.TransactionCounts{Write: 10}})
+	b.reportAbort(&summary)
+	c.Assert(summary.FailFastReason, chk.Equals, "")
+
+	b.reason = "its transfers made 11 storage transactions, which is more than the max-transactions of 10"
+	b.reportAbort(&summary)
+	c.Assert(summary.FailFastReason, chk.Equals, b.reason)
+	c.Assert(summary.TransfersNotAttempted, chk.Equals, uint32(6))
+	c.Assert(summary.JobStatus, chk.Equals, common.EJobStatus.CompletedWithErrors())
+}
+
+func (s *transactionBudgetSuite) TestRenamesFromTemporaryNamesArePredicted(c *chk.C) {
+	b := newTransactionBudget(0, true, common.EFromTo.LocalFile(), common.EBlobType.Detect(), 0)
+	b.renames = true
+	c.Assert(b.add(common.CopyTransfer{SourceSize: 0}), chk.IsNil)
+	// the creation of the file, and its rename
+	c.Assert(b.prediction(), chk.Equals, common.TransactionCounts{Write: 2})
+	c.Assert(b.assumptions()[len(b.assumptions())-1], chk.Matches, "Each file is written under a temporary name.*")
+
+	c.Assert(validateTempNameSuffix(".azcopy-partial"), chk.IsNil)
+	c.Assert(validateTempNameSuffix(""), chk.IsNil)
+	c.Assert(validateTempNameSuffix("/partial"), chk.NotNil)
+	c.Assert(validateTempNameSuffix(`\partial`), chk.NotNil)
+}
@@ -0,0 +1,102 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	chk "gopkg.in/check.v1"
+)
+
+type traversalLimitsSuite struct{}
+
+var _ = chk.Suite(&traversalLimitsSuite{})
+
+func (s *traversalLimitsSuite) TestNothingToBound(c *chk.C) {
+	var limits *traversalLimits
+	c.Assert(newTraversalLimits(0, nil, []string{""}, nil), chk.IsNil)
+	c.Assert(limits.boundsDescent(), chk.Equals, false)
+	c.Assert(limits.entersDirectory("a/b/c"), chk.Equals, true)
+	c.Assert(limits.includesFileAt("a/b/c/d"), chk.Equals, true)
+	c.Assert(formatDirectoriesSkipped(limits), chk.Equals, "")
+}
+
+func (s *traversalLimitsSuite) TestMaxDepth(c *chk.C) {
+	limits := newTraversalLimits(2, nil, nil, nil)
+	c.Assert(limits.includesFileAt("file"), chk.Equals, true)
+	c.Assert(limits.includesFileAt("dir/file"), chk.Equals, true)
+	c.Assert(limits.includesFileAt("dir/sub/file"), chk.Equals, false)
+
+	c.Assert(limits.entersDirectory(""), chk.Equals, true)
+	c.Assert(limits.entersDirectory("dir"), chk.Equals, true)
+	c.Assert(limits.entersDirectory("dir/sub/"), chk.Equals, false)
+	c.Assert(limits.directoriesSkipped(), chk.Equals, uint64(1))
+}
+
+func (s *traversalLimitsSuite) TestExcludePathsAndPrunePatterns(c *chk.C) {
+	limits := newTraversalLimits(0, []string{"logs", "data/archive/"}, []string{"node_modules", ".*"}, nil)
+
+	c.Assert(limits.entersDirectory("logs"), chk.Equals, false)
+	c.Assert(limits.entersDirectory("logsmore"), chk.Equals, false) // --exclude-path checks the prefix, so nothing in it would be kept anyway
+	c.Assert(limits.entersDirectory("data"), chk.Equals, true)
+	c.Assert(limits.entersDirectory("data/archive"), chk.Equals, false)
+	c.Assert(limits.entersDirectory("data/archived"), chk.Equals, true)
+	c.Assert(limits.entersDirectory("src/node_modules"), chk.Equals, false)
+	c.Assert(limits.entersDirectory("src/.git"), chk.Equals, false)
+	c.Assert(limits.entersDirectory("src/lib"), chk.Equals, true)
+	c.Assert(formatDirectoriesSkipped(limits), chk.Equals, ", 5 Directories Skipped")
+}
+
+func (s *traversalLimitsSuite) TestLocalTraverserDoesNotDescendIntoSkippedDirectories(c *chk.C) {
+	dir := scenarioHelper{}.generateLocalDirectory(c)
+	defer os.RemoveAll(dir)
+	scenarioHelper{}.generateLocalFilesFromList(c, dir, []string{
+		"top.txt",
+		"src/main.go",
+		"src/node_modules/pkg/index.js",
+		"src/deep/deeper/file.go",
+		"logs/today.log",
+	})
+
+	for _, followSymlinks := range []bool{false, true} {
+		limits := newTraversalLimits(2, []string{"logs"}, []string{"node_modules"}, nil)
+		found := make([]string, 0)
+		traverser := newLocalTraverser(dir, true, followSymlinks, func() {}, nil, limits)
+		c.Assert(traverser.traverse(noPreProccessor, func(object storedObject) error {
+			found = append(found, object.relativePath)
+			return nil
+		}, nil), chk.IsNil)
+
+		sort.Strings(found)
+		c.Assert(found, chk.DeepEquals, []string{"src/main.go", "top.txt"})
+		c.Assert(limits.directoriesSkipped(), chk.Equals, uint64(3)) // logs, src/node_modules and src/deep
+	}
+
+	// without limits, everything is found
+	count := 0
+	c.Assert(newLocalTraverser(filepath.Join(dir, "src"), true, false, func() {}, nil, nil).traverse(noPreProccessor, func(storedObject) error {
+		count++
+		return nil
+	}, nil), chk.IsNil)
+	c.Assert(count, chk.Equals, 3)
+}
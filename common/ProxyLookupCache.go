@@ -23,6 +23,7 @@ package common
 import (
 	"errors"
 	"github.com/mattn/go-ieproxy"
+	"golang.org/x/net/http/httpproxy"
 	"net/http"
 	"net/url"
 	"strings"
@@ -57,6 +58,25 @@ func init() {
 			return v.url, v.err
 		}
 	}
+
+	// On Windows, the proxy may come from the system settings rather than the environment,
+	// but we still want NO_PROXY to let traffic to e.g. private endpoints go around it
+	if noProxy := httpproxy.FromEnvironment().NoProxy; noProxy != "" {
+		GlobalProxyLookup = withNoProxyBypass(GlobalProxyLookup, noProxy)
+	}
+}
+
+// withNoProxyBypass returns a lookup that gives no proxy for the hosts that match noProxy, which is in the format of NO_PROXY.
+// That is, a comma-separated list of host names, domain suffixes (.example.com), IP addresses and CIDR ranges (10.0.0.0/8), any of them with an optional port.
+func withNoProxyBypass(lookup ProxyLookupFunc, noProxy string) ProxyLookupFunc {
+	// the proxy URLs are placeholders, since the config is only used to tell whether a URL matches noProxy
+	bypassCheck := (&httpproxy.Config{HTTPProxy: "bypass-check", HTTPSProxy: "bypass-check", NoProxy: noProxy}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		if u, err := bypassCheck(req.URL); err == nil && u == nil {
+			return nil, nil
+		}
+		return lookup(req)
+	}
 }
 
 var ProxyLookupTimeoutError = errors.New("proxy lookup timed out")
@@ -0,0 +1,106 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumFileHash returns the hash that a checksum file holds, as its name says: SHA-256 for .sha256, and MD5 for .md5.
+// The files are in the format of sha256sum and md5sum, so that they can be checked with those tools too.
+func ChecksumFileHash(path string) (func() hash.Hash, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sha256":
+		return sha256.New, nil
+	case ".md5":
+		return md5.New, nil
+	default:
+		return nil, fmt.Errorf("the name of the checksum file %s must end in .sha256 or .md5, to say which hash it holds", path)
+	}
+}
+
+var checksumPathEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+var checksumPathUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r")
+
+// FormatChecksumLine returns the line of a checksum file for one file, without the newline.
+// As sha256sum does, a path with a backslash or a line break in it is escaped, and the line then starts with a backslash.
+func FormatChecksumLine(digest []byte, relativePath string) string {
+	escaped := checksumPathEscaper.Replace(relativePath)
+	if escaped != relativePath {
+		return "\\" + hex.EncodeToString(digest) + "  " + escaped
+	}
+	return hex.EncodeToString(digest) + "  " + relativePath
+}
+
+// ReadChecksumFile returns the digests in a checksum file, in hex, by relative path
+func ReadChecksumFile(path string) (map[string]string, error) {
+	newHash, err := ChecksumFileHash(path)
+	if err != nil {
+		return nil, err
+	}
+	digestLength := hex.EncodedLen(newHash().Size())
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	digests := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+
+		// the digest, a space, and then a space for text mode or a star for binary mode, before the path
+		if len(line) < digestLength+3 || line[digestLength] != ' ' || (line[digestLength+1] != ' ' && line[digestLength+1] != '*') {
+			return nil, fmt.Errorf("line %d of the checksum file %s is not a digest and a path", lineNumber, path)
+		}
+		digest := strings.ToLower(line[:digestLength])
+		if _, err := hex.DecodeString(digest); err != nil {
+			return nil, fmt.Errorf("line %d of the checksum file %s does not start with a digest in hex", lineNumber, path)
+		}
+		relativePath := line[digestLength+2:]
+		if escaped {
+			relativePath = checksumPathUnescaper.Replace(relativePath)
+		}
+		digests[relativePath] = digest
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
@@ -21,6 +21,7 @@
 package common
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"errors"
@@ -46,6 +47,14 @@ type ChunkedFileWriter interface {
 	// After the chunk is written to disk, its reserved memory byte allocation is automatically subtracted from the CacheLimiter.
 	EnqueueChunk(ctx context.Context, id ChunkID, chunkSize int64, chunkContents io.Reader, retryable bool) error
 
+	// EnqueueVerifiedChunk is like EnqueueChunk, except that, if expectedMD5 is not nil, the chunk is only accepted if its contents
+	// have that hash. If they don't, ChunkMD5Mismatch is returned, and the caller may fetch the chunk again and retry.
+	EnqueueVerifiedChunk(ctx context.Context, id ChunkID, chunkSize int64, chunkContents io.Reader, retryable bool, expectedMD5 []byte) error
+
+	// EnqueueUnchangedChunk leaves the chunk as it already is in the file, e.g. when only the changes since a snapshot are downloaded into
+	// a file that holds the snapshot. The file must be an io.Seeker, and the hash that Flush returns doesn't cover the chunk.
+	EnqueueUnchangedChunk(ctx context.Context, id ChunkID, chunkSize int64) error
+
 	// Flush will block until all the chunks have been written to disk.  err will be non-nil if and only in any chunk failed to write.
 	// Flush must be called exactly once, after all chunks have been enqueued with EnqueueChunk.
 	Flush(ctx context.Context) (md5HashOfFileAsWritten []byte, err error)
@@ -93,14 +102,51 @@ type chunkedFileWriter struct {
 	md5ValidationOption HashValidationOption
 
 	sourceMd5Exists bool
+
+	// where an earlier attempt left off, and how to tell the caller how far this one gets
+	resume ChunkedFileResume
+}
+
+// ChunkedFileResume lets a chunked file writer carry on from where an earlier attempt to write the same file stopped,
+// and tells the caller how far the file is durably saved, so that a later attempt can carry on from there
+type ChunkedFileResume struct {
+	// SavedLength is the length of the start of the file that is already saved. The file must be positioned at its end,
+	// and only the chunks after it are enqueued. SavedContent reads it, so that the hash of the whole file can be computed
+	SavedLength  int64
+	SavedContent io.Reader
+
+	// The file is synced to disk each time at least SyncInterval more bytes are written in order (if it's set, and the file can be synced),
+	// after which OnDurablySaved, if it's set, is called
+	SyncInterval   int64
+	OnDurablySaved func(savedLength int64)
+}
+
+type syncer interface {
+	Sync() error
 }
 
 type fileChunk struct {
 	id   ChunkID
 	data []byte
+
+	// for a chunk that is left as it is in the file, the length to skip over. Its data is nil
+	unchangedLength int64
+}
+
+func (c fileChunk) length() int64 {
+	if c.data == nil {
+		return c.unchangedLength
+	}
+	return int64(len(c.data))
 }
 
 func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, numChunks uint32, maxBodyRetries int, md5ValidationOption HashValidationOption, sourceMd5Exists bool) ChunkedFileWriter {
+	return NewResumableChunkedFileWriter(ctx, slicePool, cacheLimiter, chunkLogger, file, numChunks, maxBodyRetries, md5ValidationOption, sourceMd5Exists, ChunkedFileResume{})
+}
+
+// NewResumableChunkedFileWriter is like NewChunkedFileWriter, for a file that may already hold the start of its content, and whose progress
+// is recorded so that a later attempt can carry on from it. numChunks is the number of chunks that are still to be written
+func NewResumableChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, numChunks uint32, maxBodyRetries int, md5ValidationOption HashValidationOption, sourceMd5Exists bool, resume ChunkedFileResume) ChunkedFileWriter {
 	// Set max size for buffered channel. The upper limit here is believed to be generous, given worker routine drains it constantly.
 	// Use num chunks in file if lower than the upper limit, to prevent allocating RAM for lots of large channel buffers when dealing with
 	// very large numbers of very small files.
@@ -117,6 +163,7 @@ func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheL
 		maxRetryPerDownloadBody: maxBodyRetries,
 		md5ValidationOption:     md5ValidationOption,
 		sourceMd5Exists:         sourceMd5Exists,
+		resume:                  resume,
 	}
 	go w.workerRoutine(ctx)
 	return w
@@ -124,6 +171,8 @@ func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheL
 
 var ChunkWriterAlreadyFailed = errors.New("chunk Writer already failed")
 
+var ChunkMD5Mismatch = errors.New("the MD5 hash of the chunk, as received, does not match the hash that the service sent with it")
+
 const maxDesirableActiveChunks = 20 // TODO: can we find a sensible way to remove the hard-coded count threshold here?
 
 // Waits until we have enough RAM, within our pre-determined allocation, to accommodate the chunk.
@@ -144,6 +193,12 @@ func (w *chunkedFileWriter) WaitToScheduleChunk(ctx context.Context, id ChunkID,
 
 // Threadsafe method to enqueue a new chunk for processing
 func (w *chunkedFileWriter) EnqueueChunk(ctx context.Context, id ChunkID, chunkSize int64, chunkContents io.Reader, retryable bool) error {
+	return w.EnqueueVerifiedChunk(ctx, id, chunkSize, chunkContents, retryable, nil)
+}
+
+// Checks the hash of the chunk, if we have one, as soon as the chunk is read, so that a chunk that was corrupted in flight
+// can be fetched again on its own, rather than failing the whole file when its hash is checked at the end
+func (w *chunkedFileWriter) EnqueueVerifiedChunk(ctx context.Context, id ChunkID, chunkSize int64, chunkContents io.Reader, retryable bool, expectedMD5 []byte) error {
 
 	readDone := make(chan struct{})
 	if retryable {
@@ -165,6 +220,14 @@ func (w *chunkedFileWriter) EnqueueChunk(ctx context.Context, id ChunkID, chunkS
 		return err
 	}
 
+	if expectedMD5 != nil {
+		actualMD5 := md5.Sum(buffer)
+		if !bytes.Equal(actualMD5[:], expectedMD5) {
+			w.slicePool.ReturnSlice(buffer)
+			return ChunkMD5Mismatch
+		}
+	}
+
 	// count it (since we fully "have" it now - just haven't sorted and saved it yet)
 	atomic.AddInt32(&w.totalReceivedChunkCount, 1)
 	atomic.AddInt64(&w.totalChunkReceiveMilliseconds, time.Since(readStart).Nanoseconds()/(1000*1000))
@@ -184,6 +247,22 @@ func (w *chunkedFileWriter) EnqueueChunk(ctx context.Context, id ChunkID, chunkS
 	}
 }
 
+// EnqueueUnchangedChunk queues the chunk to be skipped over, in its turn, when the chunks are saved in order
+func (w *chunkedFileWriter) EnqueueUnchangedChunk(ctx context.Context, id ChunkID, chunkSize int64) error {
+	w.chunkLogger.LogChunkStatus(id, EWaitReason.Sorting())
+	select {
+	case err := <-w.failureError:
+		if err != nil {
+			return err
+		}
+		return ChunkWriterAlreadyFailed
+	case <-ctx.Done():
+		return ctx.Err()
+	case w.newUnorderedChunks <- fileChunk{id: id, unchangedLength: chunkSize}:
+		return nil
+	}
+}
+
 // Flush waits until all chunks have been flush to disk, then returns the MD5 has of the file's bytes-as-we-saved-them
 func (w *chunkedFileWriter) Flush(ctx context.Context) ([]byte, error) {
 	// let worker know that no more will be coming
@@ -213,12 +292,20 @@ func (w *chunkedFileWriter) MaxRetryPerDownloadBody() int {
 // resorting to the likes of SetFileValidData (https://docs.microsoft.com/en-us/windows/desktop/api/fileapi/nf-fileapi-setfilevaliddata)
 // and (b) we can compute MD5 hashes - which can only be computed when moving through the data sequentially
 func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
-	nextOffsetToSave := int64(0)
+	nextOffsetToSave := w.resume.SavedLength
+	lastDurableOffset := nextOffsetToSave
 	unsavedChunksByFileOffset := make(map[int64]fileChunk)
 	md5Hasher := md5.New()
 	if w.md5ValidationOption == EHashValidationOption.NoCheck() || !w.sourceMd5Exists {
 		// save CPU time by not even computing a hash, if we don't want to check it, or have nothing to check it against
 		md5Hasher = &nullHasher{}
+	} else if w.resume.SavedLength > 0 {
+		// the hash is of the whole file, so it starts with what the earlier attempt saved
+		if _, err := io.CopyN(md5Hasher, w.resume.SavedContent, w.resume.SavedLength); err != nil {
+			w.failureError <- err
+			close(w.failureError)
+			return
+		}
 	}
 
 	for {
@@ -247,6 +334,9 @@ func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
 		// Process all chunks that we can
 		w.setStatusForContiguousAvailableChunks(unsavedChunksByFileOffset, nextOffsetToSave, ctx) // update states of those that have all their prior ones already here
 		err := w.sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset, &nextOffsetToSave, md5Hasher, ctx)
+		if err == nil {
+			err = w.recordDurableProgress(nextOffsetToSave, &lastDurableOffset)
+		}
 		if err != nil {
 			w.failureError <- err
 			close(w.failureError) // must close because many goroutines may be calling the public methods, and all need to be able to tell there's been an error, even tho only one will get the actual error
@@ -270,8 +360,8 @@ func (w *chunkedFileWriter) sequentiallyProcessAvailableChunks(unsavedChunksByFi
 		if !exists {
 			return nil //its not there yet. That's OK.
 		}
-		delete(unsavedChunksByFileOffset, *nextOffsetToSave)      // remove it
-		*nextOffsetToSave += int64(len(nextChunkInSequence.data)) // update immediately so we won't forget!
+		delete(unsavedChunksByFileOffset, *nextOffsetToSave) // remove it
+		*nextOffsetToSave += nextChunkInSequence.length()    // update immediately so we won't forget!
 
 		// Save it (hashing exactly what we save)
 		err := w.saveOneChunk(nextChunkInSequence, md5Hasher)
@@ -281,6 +371,25 @@ func (w *chunkedFileWriter) sequentiallyProcessAvailableChunks(unsavedChunksByFi
 	}
 }
 
+// Syncs the file, and tells the caller how much of it is saved, once enough has been written since the last time
+func (w *chunkedFileWriter) recordDurableProgress(nextOffsetToSave int64, lastDurableOffset *int64) error {
+	if w.resume.SyncInterval <= 0 || nextOffsetToSave-*lastDurableOffset < w.resume.SyncInterval {
+		return nil
+	}
+	s, ok := w.file.(syncer)
+	if !ok {
+		return nil
+	}
+	if err := s.Sync(); err != nil {
+		return err
+	}
+	*lastDurableOffset = nextOffsetToSave
+	if w.resume.OnDurablySaved != nil {
+		w.resume.OnDurablySaved(nextOffsetToSave)
+	}
+	return nil
+}
+
 // Advances the status of chunks which are no longer waiting on missing predecessors, but are instead just waiting on
 // us to get around to (sequentially) saving them
 func (w *chunkedFileWriter) setStatusForContiguousAvailableChunks(unsavedChunksByFileOffset map[int64]fileChunk, nextOffsetToSave int64, ctx context.Context) {
@@ -296,7 +405,7 @@ func (w *chunkedFileWriter) setStatusForContiguousAvailableChunks(unsavedChunksB
 		if !exists {
 			return //its not there yet, so no need to touch anything AFTER it. THEY are still waiting for prior chunk
 		}
-		nextOffsetToSave += int64(len(nextChunkInSequence.data))
+		nextOffsetToSave += nextChunkInSequence.length()
 		w.chunkLogger.LogChunkStatus(nextChunkInSequence.id, EWaitReason.QueueToWrite()) // we WILL write this. Just may have to write others before it
 	}
 }
@@ -304,9 +413,11 @@ func (w *chunkedFileWriter) setStatusForContiguousAvailableChunks(unsavedChunksB
 // Saves one chunk to its destination
 func (w *chunkedFileWriter) saveOneChunk(chunk fileChunk, md5Hasher hash.Hash) error {
 	defer func() {
-		w.cacheLimiter.Remove(int64(len(chunk.data))) // remove this from the tally of scheduled-but-unsaved bytes
+		w.cacheLimiter.Remove(chunk.length()) // remove this from the tally of scheduled-but-unsaved bytes
 		atomic.AddInt32(&w.activeChunkCount, -1)
-		w.slicePool.ReturnSlice(chunk.data)
+		if chunk.data != nil {
+			w.slicePool.ReturnSlice(chunk.data)
+		}
 		w.chunkLogger.LogChunkStatus(chunk.id, EWaitReason.ChunkDone()) // this chunk is all finished
 	}()
 
@@ -314,6 +425,15 @@ func (w *chunkedFileWriter) saveOneChunk(chunk fileChunk, md5Hasher hash.Hash) e
 
 	w.chunkLogger.LogChunkStatus(chunk.id, EWaitReason.DiskIO())
 
+	if chunk.data == nil {
+		seeker, ok := w.file.(io.Seeker)
+		if !ok {
+			return errors.New("cannot leave a chunk unchanged, since the file does not support seeking")
+		}
+		_, err := seeker.Seek(chunk.unchangedLength, io.SeekCurrent)
+		return err
+	}
+
 	// in some cases, e.g. Storage Spaces in Azure VMs, chopping up the writes helps perf. TODO: look into the reasons why it helps
 	for i := 0; i < len(chunk.data); i += maxWriteSize {
 		slice := chunk.data[i:]
@@ -0,0 +1,129 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"strings"
+)
+
+// builtInContentTypes are the content types of the extensions that static websites commonly use, so that they get the same
+// content type whichever operating system uploads them, including the newer ones that the MIME databases of many systems lack
+var builtInContentTypes = map[string]string{
+	".apng":        "image/apng",
+	".avif":        "image/avif",
+	".css":         "text/css; charset=utf-8",
+	".gif":         "image/gif",
+	".htm":         "text/html; charset=utf-8",
+	".html":        "text/html; charset=utf-8",
+	".ico":         "image/x-icon",
+	".jpeg":        "image/jpeg",
+	".jpg":         "image/jpeg",
+	".js":          "application/javascript",
+	".json":        "application/json",
+	".jxl":         "image/jxl",
+	".map":         "application/json",
+	".md":          "text/markdown; charset=utf-8",
+	".mjs":         "application/javascript",
+	".mp3":         "audio/mpeg",
+	".mp4":         "video/mp4",
+	".otf":         "font/otf",
+	".pdf":         "application/pdf",
+	".png":         "image/png",
+	".svg":         "image/svg+xml",
+	".ttf":         "font/ttf",
+	".txt":         "text/plain; charset=utf-8",
+	".vtt":         "text/vtt",
+	".wasm":        "application/wasm",
+	".webm":        "video/webm",
+	".webmanifest": "application/manifest+json",
+	".webp":        "image/webp",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".xml":         "text/xml; charset=utf-8",
+}
+
+// customContentTypes are the user's own mappings, from the file that AZCOPY_CONTENT_TYPE_MAP names.
+// They are set once, when AzCopy starts, and take precedence over all the others
+var customContentTypes map[string]string
+
+// ReadContentTypeMap reads a JSON object whose names are file extensions (with or without the leading dot, in any case)
+// and whose values are the content types of the files that have them
+func ReadContentTypeMap(mapFile string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(mapFile)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]string)
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %s", mapFile, err)
+	}
+
+	contentTypes := make(map[string]string, len(raw))
+	for ext, contentType := range raw {
+		if _, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, fmt.Errorf("invalid content type %q for %s in %s: %s", contentType, ext, mapFile, err)
+		}
+		contentTypes[normalizeExtension(ext)] = contentType
+	}
+	return contentTypes, nil
+}
+
+// LoadContentTypeMap makes the mappings in mapFile take precedence over the built in ones, and those of the operating system.
+// Nothing changes if mapFile is empty
+func LoadContentTypeMap(mapFile string) error {
+	if mapFile == "" {
+		return nil
+	}
+
+	contentTypes, err := ReadContentTypeMap(mapFile)
+	if err != nil {
+		return err
+	}
+	customContentTypes = contentTypes
+	return nil
+}
+
+// ContentTypeByExtension returns the content type of the files with the given extension (e.g. ".wasm"), from the user's own mappings,
+// the built in ones or, failing those, the MIME database of the operating system. It's empty if none of them know the extension,
+// and the content type must then be sniffed from the content of the file
+func ContentTypeByExtension(ext string) string {
+	if ext == "" {
+		return ""
+	}
+
+	ext = normalizeExtension(ext)
+	if contentType, ok := customContentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType, ok := builtInContentTypes[ext]; ok {
+		return contentType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+func normalizeExtension(ext string) string {
+	return "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+}
@@ -50,6 +50,11 @@ type CredentialOpOptions struct {
 
 	// Used to cancel operations, if fatal error happend during operation.
 	Cancel context.CancelFunc
+
+	// TokenExpired is called once the OAuth token can no longer be refreshed, since its refresh token expired,
+	// or its access token expired while the refreshes failed. It blocks until there's a new token (e.g. the user logged in again),
+	// which it returns, or until there won't be one, in which case it returns nil and the refreshes go on being tried.
+	TokenExpired func(err error) *OAuthTokenInfo
 }
 
 // callerMessage formats caller message prefix.
@@ -99,13 +104,24 @@ func CreateBlobCredential(ctx context.Context, credInfo CredentialInfo, options
 		}
 
 		// Create TokenCredential with refresher.
+		refresher := newTokenRefresher(credInfo.OAuthTokenInfo, options)
 		return azblob.NewTokenCredential(
 			credInfo.OAuthTokenInfo.AccessToken,
 			func(credential azblob.TokenCredential) time.Duration {
-				return refreshBlobToken(ctx, credInfo.OAuthTokenInfo, credential, options)
+				return refresher.refresh(ctx, credential.SetToken)
 			})
 	}
 
+	if credInfo.CredentialType == ECredentialType.SharedKey() {
+		// only used with emulators and other IP endpoint style services, so the emulators' account is the default
+		name, key := SharedKeyAccount()
+		sharedKey, err := azblob.NewSharedKeyCredential(name, key)
+		if err != nil {
+			options.panicError(fmt.Errorf("invalid account key in ACCOUNT_KEY: %v", err))
+		}
+		return sharedKey
+	}
+
 	return credential
 }
 
@@ -135,25 +151,53 @@ func refreshPolicyHalfOfExpiryWithin(token *adal.Token, options CredentialOpOpti
 	return waitDuration
 }
 
-func refreshBlobToken(ctx context.Context, tokenInfo OAuthTokenInfo, tokenCredential azblob.TokenCredential, options CredentialOpOptions) time.Duration {
-	newToken, err := tokenInfo.Refresh(ctx)
+// tokenRefresher refreshes the OAuth token of a credential, whatever the service. It holds on to the token info,
+// since it's replaced when the token is renewed, once it can no longer be refreshed.
+// The credential calls it from one timer at a time, so it needs no lock
+type tokenRefresher struct {
+	tokenInfo OAuthTokenInfo
+	options   CredentialOpOptions
+}
+
+func newTokenRefresher(tokenInfo OAuthTokenInfo, options CredentialOpOptions) *tokenRefresher {
+	return &tokenRefresher{tokenInfo: tokenInfo, options: options}
+}
+
+// refresh gets a new token, and gives its access token to the credential with setToken.
+// It returns how long to wait before the next refresh
+func (r *tokenRefresher) refresh(ctx context.Context, setToken func(string)) time.Duration {
+	newToken, err := r.tokenInfo.Refresh(ctx)
 	if err != nil {
 		// Fail to get new token.
+		refreshTokenExpired := false
 		if _, ok := err.(adal.TokenRefreshError); ok && strings.Contains(err.Error(), "refresh token has expired") {
-			options.logError(fmt.Sprintf("failed to refresh token, OAuth refresh token has expired, please log in with azcopy login command again. (Error details: %v)", err))
+			refreshTokenExpired = true
+			r.options.logError(fmt.Sprintf("failed to refresh token, OAuth refresh token has expired, please log in with azcopy login command again. (Error details: %v)", err))
 		} else {
-			options.logError(fmt.Sprintf("failed to refresh token, please check error details and try to log in with azcopy login command again. (Error details: %v)", err))
+			r.options.logError(fmt.Sprintf("failed to refresh token, please check error details and try to log in with azcopy login command again. (Error details: %v)", err))
+		}
+
+		// past this point, every request would fail with 403, so it's better to wait for a new token
+		if (refreshTokenExpired || r.tokenInfo.IsExpired()) && r.options.TokenExpired != nil {
+			if renewed := r.options.TokenExpired(err); renewed != nil {
+				r.tokenInfo = *renewed
+				setToken(renewed.AccessToken)
+				r.options.logInfo(fmt.Sprintf("%v token renewed successfully", time.Now().UTC()))
+				return refreshPolicyHalfOfExpiryWithin(&(renewed.Token), r.options)
+			}
 		}
-		// Try to refresh again according to original token's info.
-		return refreshPolicyHalfOfExpiryWithin(&(tokenInfo.Token), options)
+
+		// Try to refresh again according to existing token's info.
+		return refreshPolicyHalfOfExpiryWithin(&(r.tokenInfo.Token), r.options)
 	}
 
-	// Token has been refreshed successfully.
-	tokenCredential.SetToken(newToken.AccessToken)
-	options.logInfo(fmt.Sprintf("%v token refreshed successfully", time.Now().UTC()))
+	// Token has been refreshed successfully. It's kept, so that whether it has expired is known when a later refresh fails
+	r.tokenInfo.Token = *newToken
+	setToken(newToken.AccessToken)
+	r.options.logInfo(fmt.Sprintf("%v token refreshed successfully", time.Now().UTC()))
 
 	// Calculate wait duration, and schedule next refresh.
-	return refreshPolicyHalfOfExpiryWithin(newToken, options)
+	return refreshPolicyHalfOfExpiryWithin(newToken, r.options)
 }
 
 // CreateBlobFSCredential creates BlobFS credential according to credential info.
@@ -167,20 +211,17 @@ func CreateBlobFSCredential(ctx context.Context, credInfo CredentialInfo, option
 		}
 
 		// Create TokenCredential with refresher.
+		refresher := newTokenRefresher(credInfo.OAuthTokenInfo, options)
 		cred = azbfs.NewTokenCredential(
 			credInfo.OAuthTokenInfo.AccessToken,
 			func(credential azbfs.TokenCredential) time.Duration {
-				return refreshBlobFSToken(ctx, credInfo.OAuthTokenInfo, credential, options)
+				return refresher.refresh(ctx, credential.SetToken)
 			})
 
 	case ECredentialType.SharedKey():
-		// Get the Account Name and Key variables from environment
-		name := lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountName())
-		key := lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountKey())
-		// If the ACCOUNT_NAME and ACCOUNT_KEY are not set in environment variables
-		if name == "" || key == "" {
-			options.panicError(errors.New("ACCOUNT_NAME and ACCOUNT_KEY environment variables must be set before creating the blobfs SharedKey credential"))
-		}
+		// Get the Account Name and Key variables from environment, or the emulators' account if they aren't set,
+		// since the FE only chooses shared key without them for the emulators' account
+		name, key := SharedKeyAccount()
 		// create the shared key credentials
 		cred = azbfs.NewSharedKeyCredential(name, key)
 
@@ -215,27 +256,6 @@ func CreateS3Credential(ctx context.Context, credInfo CredentialInfo, options Cr
 	panic("work around the compiling, logic wouldn't reach here")
 }
 
-func refreshBlobFSToken(ctx context.Context, tokenInfo OAuthTokenInfo, tokenCredential azbfs.TokenCredential, options CredentialOpOptions) time.Duration {
-	newToken, err := tokenInfo.Refresh(ctx)
-	if err != nil {
-		// Fail to get new token.
-		if _, ok := err.(adal.TokenRefreshError); ok && strings.Contains(err.Error(), "refresh token has expired") {
-			options.logError(fmt.Sprintf("failed to refresh token, OAuth refresh token has expired, please log in with azcopy login command again. (Error details: %v)", err))
-		} else {
-			options.logError(fmt.Sprintf("failed to refresh token, please check error details and try to log in with azcopy login command again. (Error details: %v)", err))
-		}
-		// Try to refresh again according to existing token's info.
-		return refreshPolicyHalfOfExpiryWithin(&(tokenInfo.Token), options)
-	}
-
-	// Token has been refreshed successfully.
-	tokenCredential.SetToken(newToken.AccessToken)
-	options.logInfo(fmt.Sprintf("%v token refreshed successfully", time.Now().UTC()))
-
-	// Calculate wait duration, and schedule next refresh.
-	return refreshPolicyHalfOfExpiryWithin(newToken, options)
-}
-
 // ==============================================================================================
 // S3 credential related factory methods
 // ==============================================================================================
@@ -0,0 +1,81 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DestinationCondition is the condition that each write of a whole destination must meet (--destination-condition),
+// so that jobs that write the same destinations at the same time don't silently replace what the other one wrote.
+// A destination that doesn't meet it is skipped. The zero value is no condition.
+type DestinationCondition struct {
+	// only write a destination that doesn't exist yet (if-none-match=*)
+	IfNoneMatch bool
+	// only replace a destination that wasn't modified since this time (if-unmodified-since=<time>). Zero for no such condition
+	IfUnmodifiedSince time.Time
+}
+
+// ParseDestinationCondition parses "if-none-match=*" or "if-unmodified-since=<time>", where the time is in RFC 3339 format
+// (e.g. 2026-01-02T15:04:05Z), or is a date (e.g. 2026-01-02, for midnight UTC). An empty string is no condition
+func ParseDestinationCondition(s string) (DestinationCondition, error) {
+	if s == "" {
+		return DestinationCondition{}, nil
+	}
+
+	name, value := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		name, value = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	switch strings.ToLower(name) {
+	case "if-none-match":
+		if value != "*" {
+			return DestinationCondition{}, fmt.Errorf("if-none-match only takes *, for destinations that don't exist yet")
+		}
+		return DestinationCondition{IfNoneMatch: true}, nil
+	case "if-unmodified-since":
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return DestinationCondition{IfUnmodifiedSince: t}, nil
+			}
+		}
+		return DestinationCondition{}, fmt.Errorf("'%s' is not a time in RFC 3339 format (e.g. 2026-01-02T15:04:05Z), nor a date (e.g. 2026-01-02)", value)
+	default:
+		return DestinationCondition{}, fmt.Errorf("'%s' is not a destination condition. Use if-none-match=* or if-unmodified-since=<time>", s)
+	}
+}
+
+func (c DestinationCondition) IsSet() bool {
+	return c.IfNoneMatch || !c.IfUnmodifiedSince.IsZero()
+}
+
+func (c DestinationCondition) String() string {
+	switch {
+	case c.IfNoneMatch:
+		return "if-none-match=*"
+	case !c.IfUnmodifiedSince.IsZero():
+		return "if-unmodified-since=" + c.IfUnmodifiedSince.UTC().Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+)
+
+// discardChunkedFileWriter is a ChunkedFileWriter that reads each chunk and then throws it away.
+// Unlike chunkedFileWriter, it has no need to put the chunks into sequential order, since it neither saves nor hashes them.
+// That means that nothing waits in RAM, and that no disk is involved, which is what we want when measuring
+// download throughput.
+type discardChunkedFileWriter struct {
+	chunkLogger ChunkStatusLogger
+
+	// where the chunks are thrown away to, e.g. something that counts them. Written to by many chunks at once
+	sink io.Writer
+
+	// controls body-read retries
+	maxRetryPerDownloadBody int
+}
+
+func NewDiscardChunkedFileWriter(chunkLogger ChunkStatusLogger, maxBodyRetries int) ChunkedFileWriter {
+	return NewDiscardChunkedFileWriterToSink(chunkLogger, ioutil.Discard, maxBodyRetries)
+}
+
+// NewDiscardChunkedFileWriterToSink is like NewDiscardChunkedFileWriter, but writes the chunks to the sink, as they arrive and in any order,
+// rather than throwing them away itself. The sink must be safe for concurrent use.
+func NewDiscardChunkedFileWriterToSink(chunkLogger ChunkStatusLogger, sink io.Writer, maxBodyRetries int) ChunkedFileWriter {
+	return &discardChunkedFileWriter{
+		chunkLogger:             chunkLogger,
+		sink:                    sink,
+		maxRetryPerDownloadBody: maxBodyRetries,
+	}
+}
+
+// WaitToScheduleChunk never needs to wait, because discarded chunks don't occupy any RAM after we've read them
+func (w *discardChunkedFileWriter) WaitToScheduleChunk(ctx context.Context, id ChunkID, chunkSize int64) error {
+	return ctx.Err()
+}
+
+// EnqueueChunk reads the whole of the chunk (since it's the reading that we are interested in) and discards it
+func (w *discardChunkedFileWriter) EnqueueChunk(ctx context.Context, id ChunkID, chunkSize int64, chunkContents io.Reader, retryable bool) error {
+	_, err := io.CopyN(w.sink, chunkContents, chunkSize)
+	if err != nil {
+		return err
+	}
+
+	w.chunkLogger.LogChunkStatus(id, EWaitReason.ChunkDone()) // this chunk is all finished
+	return ctx.Err()
+}
+
+// EnqueueVerifiedChunk doesn't verify anything, since the data is thrown away anyway
+func (w *discardChunkedFileWriter) EnqueueVerifiedChunk(ctx context.Context, id ChunkID, chunkSize int64, chunkContents io.Reader, retryable bool, expectedMD5 []byte) error {
+	return w.EnqueueChunk(ctx, id, chunkSize, chunkContents, retryable)
+}
+
+// EnqueueUnchangedChunk has nothing to do, since there is no file
+func (w *discardChunkedFileWriter) EnqueueUnchangedChunk(ctx context.Context, id ChunkID, chunkSize int64) error {
+	w.chunkLogger.LogChunkStatus(id, EWaitReason.ChunkDone())
+	return ctx.Err()
+}
+
+// Flush has nothing to wait for, and returns no hash since nothing was hashed
+func (w *discardChunkedFileWriter) Flush(ctx context.Context) ([]byte, error) {
+	return nil, ctx.Err()
+}
+
+func (w *discardChunkedFileWriter) MaxRetryPerDownloadBody() int {
+	return w.maxRetryPerDownloadBody
+}
@@ -0,0 +1,83 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// EmulatorAccountName and EmulatorAccountKey are the well-known account of the storage emulators, i.e. Azurite and the older Azure Storage Emulator.
+// The key is public, and is only accepted by the emulators.
+const EmulatorAccountName = "devstoreaccount1"
+const EmulatorAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+// EmulatorBlobPort is the port that Azurite serves blobs on, unless it's told otherwise
+const EmulatorBlobPort = "10000"
+
+// IsIPEndpointStyle tells whether the URL has the account name in its path rather than in its host, as the URLs of the emulators and of
+// some custom endpoints do, e.g. http://127.0.0.1:10000/devstoreaccount1/container. The SDKs take that to be the case when the host is an IP address.
+func IsIPEndpointStyle(u url.URL) bool {
+	return net.ParseIP(u.Hostname()) != nil
+}
+
+// IsEmulatorURL tells whether the URL is that of an emulator, or other IP endpoint style service, that runs on this machine
+func IsEmulatorURL(u url.URL) bool {
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// IPEndpointStyleAccountName returns the account name in the path of an IP endpoint style URL, or "" if it isn't one
+func IPEndpointStyleAccountName(u url.URL) string {
+	if !IsIPEndpointStyle(u) {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+}
+
+// ReplaceLocalhostWithLoopbackIP makes a URL whose host is localhost use 127.0.0.1 instead, so that the SDKs recognize it as IP endpoint style
+// and find the account name in its path. Anything that isn't such a URL is returned as it is.
+func ReplaceLocalhostWithLoopbackIP(resource string) string {
+	u, err := url.Parse(resource)
+	if err != nil || u.Scheme == "" || !strings.EqualFold(u.Hostname(), "localhost") {
+		return resource
+	}
+	u.Host = strings.Replace(strings.ToLower(u.Host), "localhost", "127.0.0.1", 1)
+	return u.String()
+}
+
+// SharedKeyAccount returns the account that shared key credentials authorize as: the one in ACCOUNT_NAME and ACCOUNT_KEY,
+// or, if those aren't set, the well-known account of the emulators
+func SharedKeyAccount() (name, key string) {
+	name = lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountName())
+	key = lcm.GetEnvironmentVariable(EEnvironmentVariable.AccountKey())
+	if name == "" || key == "" {
+		return EmulatorAccountName, EmulatorAccountKey
+	}
+	return name, key
+}
+
+// IsEmulatorAccount tells whether the URL is in the emulators' well-known account, which shared key credentials can authorize
+// without the user having to set ACCOUNT_NAME and ACCOUNT_KEY
+func IsEmulatorAccount(u url.URL) bool {
+	return IPEndpointStyleAccountName(u) == EmulatorAccountName
+}
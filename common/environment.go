@@ -35,19 +35,39 @@ type EnvironmentVariable struct {
 var VisibleEnvironmentVariables = []EnvironmentVariable{
 	EEnvironmentVariable.ConcurrencyValue(),
 	EEnvironmentVariable.TransferInitiationPoolSize(),
+	EEnvironmentVariable.MetadataTransferPoolSize(),
+	EEnvironmentVariable.TransfersPerDirectory(),
 	EEnvironmentVariable.LogLocation(),
+	EEnvironmentVariable.LogFileMaxSizeMB(),
+	EEnvironmentVariable.LogFileMaxRotated(),
+	EEnvironmentVariable.LogFormat(),
 	EEnvironmentVariable.JobPlanLocation(),
+	EEnvironmentVariable.JobHistory(),
+	EEnvironmentVariable.PlanEncryptionKey(),
 	EEnvironmentVariable.BufferGB(),
 	EEnvironmentVariable.AWSAccessKeyID(),
 	EEnvironmentVariable.AWSSecretAccessKey(),
 	EEnvironmentVariable.ShowPerfStates(),
 	EEnvironmentVariable.PacePageBlobs(),
 	EEnvironmentVariable.DefaultServiceApiVersion(),
+	EEnvironmentVariable.RequestApiVersion(),
+	EEnvironmentVariable.MaxRetryAfterSeconds(),
+	EEnvironmentVariable.LowSpaceMarginMB(),
 	EEnvironmentVariable.ClientSecret(),
 	EEnvironmentVariable.CertificatePassword(),
 	EEnvironmentVariable.AutoTuneToCpu(),
 	EEnvironmentVariable.CacheProxyLookup(),
 	EEnvironmentVariable.UserAgentPrefix(),
+	EEnvironmentVariable.StorageEndpointSuffix(),
+	EEnvironmentVariable.AADAuthority(),
+	EEnvironmentVariable.CABundle(),
+	EEnvironmentVariable.ContentTypeMap(),
+	EEnvironmentVariable.NetworkSourcePrefixes(),
+	EEnvironmentVariable.LocalAddress(),
+	EEnvironmentVariable.MaxIdleConns(),
+	EEnvironmentVariable.MaxConnsPerHost(),
+	EEnvironmentVariable.DisableHTTP2(),
+	EEnvironmentVariable.DNSCacheTTLSeconds(),
 }
 
 var EEnvironmentVariable = EnvironmentVariable{}
@@ -97,6 +117,22 @@ func (EnvironmentVariable) TransferInitiationPoolSize() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) MetadataTransferPoolSize() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name: "AZCOPY_CONCURRENT_METADATA_TRANSFERS",
+		Description: "Overrides the number of transfers that move no data, such as deletes and setting properties, that are in progress at any one time. " +
+			"They have workers of their own, so that they can't hold up the transfers that move data, nor be held up by them.",
+	}
+}
+
+func (EnvironmentVariable) TransfersPerDirectory() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name: "AZCOPY_CONCURRENT_FILES_PER_DIRECTORY",
+		Description: "Overrides the number of transfers to each directory of an Azure Files destination that are in progress at any one time (64 by default). " +
+			"Creating many files at once in one directory makes the service fail requests, so the transfers to a busy directory wait for a place. Set to 0 for no cap.",
+	}
+}
+
 func (EnvironmentVariable) OptimizeSparsePageBlobTransfers() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:         "AZCOPY_OPTIMIZE_SPARSE_PAGE_BLOB",
@@ -120,6 +156,30 @@ func (EnvironmentVariable) LogLocation() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) LogFileMaxSizeMB() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_LOG_FILE_MAX_SIZE_MB",
+		Description:  "Max size, in MB, of a job's log file. When the log reaches this size, it is renamed to <jobID>.1.log and a new one is started. Can be overridden by the flag --log-file-max-size-mb.",
+		DefaultValue: "1024",
+	}
+}
+
+func (EnvironmentVariable) LogFileMaxRotated() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_LOG_FILE_MAX_ROTATED",
+		Description:  "Max number of older log files (<jobID>.1.log, <jobID>.2.log etc.) to keep for a job. The oldest is deleted when there are more. Can be overridden by the flag --log-file-max-rotated.",
+		DefaultValue: "10",
+	}
+}
+
+func (EnvironmentVariable) LogFormat() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_LOG_FORMAT",
+		Description:  "Format of the job log files. Set to 'json' to write one JSON object per line (for ingestion into log analysis tools), instead of the default free text.",
+		DefaultValue: "text",
+	}
+}
+
 func (EnvironmentVariable) JobPlanLocation() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_JOB_PLAN_LOCATION",
@@ -127,6 +187,22 @@ func (EnvironmentVariable) JobPlanLocation() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) JobHistory() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_JOB_HISTORY",
+		DefaultValue: "false",
+		Description:  "Set to 'true' to keep a history of the jobs that finish, in " + JobHistoryFileName + " in the AzCopy folder, which 'azcopy jobs history' queries. Removing the files of jobs doesn't remove their history.",
+	}
+}
+
+func (EnvironmentVariable) PlanEncryptionKey() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_PLAN_ENCRYPTION_KEY",
+		Description: "A long random secret, e.g. 32 random bytes in base64, with which the paths, URLs and command of the job plan files are encrypted. Jobs that were created with it can only be listed, shown and resumed with it set to the same value.",
+		Hidden:      true,
+	}
+}
+
 func (EnvironmentVariable) BufferGB() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_BUFFER_GB",
@@ -205,9 +281,106 @@ func (EnvironmentVariable) DefaultServiceApiVersion() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) RequestApiVersion() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name: "AZCOPY_REQUEST_API_VERSION",
+		Description: "Sends every request to the Blob, File and ADLS Gen2 services with this service API version (e.g. 2019-02-02), " +
+			"for services such as Azure Stack Hub that reject the versions AzCopy uses. Features that need a newer version are turned off, with a warning.",
+	}
+}
+
+func (EnvironmentVariable) MaxRetryAfterSeconds() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_MAX_RETRY_AFTER_SECONDS",
+		DefaultValue: "60",
+		Description:  "The longest that a throttled request waits before it's retried, when the service says how long to wait (with Retry-After or x-ms-retry-after-ms). Longer waits are cut to this.",
+	}
+}
+
+func (EnvironmentVariable) LowSpaceMarginMB() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_LOW_SPACE_MARGIN_MB",
+		DefaultValue: "512",
+		Description:  "The free space, in MB, that downloads leave on the volume they save to. No more files are started while there's less than this to spare, and --low-space-action applies once the job needs more than the free space less this. Set to 0 to start files however low the volume is.",
+	}
+}
+
 func (EnvironmentVariable) UserAgentPrefix() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_USER_AGENT_PREFIX",
 		Description: "Add a prefix to the default AzCopy User Agent, which is used for telemetry purposes. A space is automatically inserted.",
 	}
 }
+
+func (EnvironmentVariable) StorageEndpointSuffix() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_STORAGE_ENDPOINT_SUFFIX",
+		Description: "The endpoint suffix of the storage accounts of a cloud other than the public, China, US Government and German Azure clouds, such as an Azure Stack, e.g. local.azurestack.external.",
+	}
+}
+
+func (EnvironmentVariable) AADAuthority() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_AAD_AUTHORITY",
+		DefaultValue: DefaultActiveDirectoryEndpoint,
+		Description:  "The Azure Active Directory endpoint to log in with, when the login command isn't given --aad-endpoint. Set it for an Azure Stack, or other cloud with its own Active Directory.",
+	}
+}
+
+func (EnvironmentVariable) CABundle() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_CA_BUNDLE",
+		Description: "The path of a PEM file of CA certificates to trust, besides those of the system, when the command isn't given --trusted-ca-file. Set it if a TLS-intercepting proxy or a private PKI signs the certificates of the endpoints.",
+	}
+}
+
+func (EnvironmentVariable) ContentTypeMap() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_CONTENT_TYPE_MAP",
+		Description: "The path of a JSON file that maps file extensions to the content types of the files that are uploaded with them, e.g. {\".wasm\": \"application/wasm\"}. Its mappings override the built in ones, and those of the operating system. It's not used when --content-type or --no-guess-mime-type is given.",
+	}
+}
+
+func (EnvironmentVariable) LocalAddress() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_LOCAL_ADDRESS",
+		Description: "The IP address, of one of the network interfaces of this machine, that the connections to the services are made from, when the command isn't given --local-address. Set it to send the traffic through a dedicated interface.",
+	}
+}
+
+func (EnvironmentVariable) MaxIdleConns() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_MAX_IDLE_CONNS",
+		Description: "How many idle connections to each host are kept open to be reused. By default, this number is that of the connections that work on transfers (see AZCOPY_CONCURRENCY_VALUE).",
+	}
+}
+
+func (EnvironmentVariable) MaxConnsPerHost() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_MAX_CONNS_PER_HOST",
+		Description: "How many connections to each host may be open at once, busy or idle. By default, there's no limit, so that there are as many as the requests in flight.",
+	}
+}
+
+func (EnvironmentVariable) DisableHTTP2() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_DISABLE_HTTP2",
+		DefaultValue: "false",
+		Description:  "Set to true to never use HTTP/2, e.g. when a middlebox on the path mishandles it and the connections stall.",
+	}
+}
+
+func (EnvironmentVariable) DNSCacheTTLSeconds() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:         "AZCOPY_DNS_CACHE_TTL_SECONDS",
+		DefaultValue: "30",
+		Description:  "How long the addresses that the hosts of the services resolve to are reused by new connections, before the hosts are looked up again. They're looked up sooner when the connections to them keep failing. Set to 0 to look the host up for each connection.",
+	}
+}
+
+func (EnvironmentVariable) NetworkSourcePrefixes() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_NETWORK_SOURCE_PREFIXES",
+		Description: "The paths under which local sources are on network file systems, separated by ';', e.g. the mount points of SMB or NFS shares, or mapped drives such as Z:\\. The reads of those sources, and of UNC paths on Windows, are retried when the share drops, after reopening the file.",
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"math"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -306,6 +307,51 @@ func (ll LogLevel) ToPipelineLogLevel() pipeline.LogLevel {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+var ELogFormat = LogFormat(0)
+
+// LogFormat defines how each line of the job log is written
+type LogFormat uint8
+
+func (LogFormat) Text() LogFormat { return LogFormat(0) } // free text, for people to read
+func (LogFormat) Json() LogFormat { return LogFormat(1) } // one JSON object per line, for ingestion into log analysis tools
+
+func (lf *LogFormat) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(lf), s, true)
+	if err == nil {
+		*lf = val.(LogFormat)
+	}
+	return err
+}
+
+func (lf LogFormat) String() string {
+	return enum.StringInt(lf, reflect.TypeOf(lf))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ELogTarget = LogTarget(0)
+
+// LogTarget defines where, in addition to the job's log file, job lifecycle events and warnings are sent
+type LogTarget uint8
+
+func (LogTarget) File() LogTarget     { return LogTarget(0) } // only the job's log file
+func (LogTarget) Syslog() LogTarget   { return LogTarget(1) } // also the syslog daemon, on Linux and macOS
+func (LogTarget) EventLog() LogTarget { return LogTarget(2) } // also the Windows Event Log
+
+func (lt *LogTarget) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(lt), s, true)
+	if err == nil {
+		*lt = val.(LogTarget)
+	}
+	return err
+}
+
+func (lt LogTarget) String() string {
+	return enum.StringInt(lt, reflect.TypeOf(lt))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 var EJobPriority = JobPriority(0)
 
 // JobPriority defines the transfer priorities supported by the Storage Transfer Engine's channels
@@ -406,6 +452,7 @@ func (Location) File() Location      { return Location(4) }
 func (Location) BlobFS() Location    { return Location(5) }
 func (Location) S3() Location        { return Location(6) }
 func (Location) Benchmark() Location { return Location(7) }
+func (Location) None() Location      { return Location(8) } // for operations, like setting properties, that have no destination
 
 func (l Location) String() string {
 	return enum.StringInt(uint32(l), reflect.TypeOf(l))
@@ -422,7 +469,7 @@ func (l Location) IsRemote() bool {
 	switch l {
 	case ELocation.BlobFS(), ELocation.Blob(), ELocation.File(), ELocation.S3():
 		return true
-	case ELocation.Local(), ELocation.Benchmark(), ELocation.Pipe(), ELocation.Unknown():
+	case ELocation.Local(), ELocation.Benchmark(), ELocation.Pipe(), ELocation.Unknown(), ELocation.None():
 		return false
 	default:
 		panic("unexpected location, please specify if it is remote")
@@ -430,7 +477,7 @@ func (l Location) IsRemote() bool {
 }
 
 func (l Location) IsLocal() bool {
-	if l == ELocation.Unknown() {
+	if l == ELocation.Unknown() || l == ELocation.None() {
 		return false
 	} else {
 		return !l.IsRemote()
@@ -460,6 +507,7 @@ func (FromTo) FileTrash() FromTo { return FromTo(fromToValue(ELocation.File(), E
 func (FromTo) BlobFSTrash() FromTo {
 	return FromTo(fromToValue(ELocation.BlobFS(), ELocation.Unknown()))
 }
+func (FromTo) BlobNone() FromTo    { return FromTo(fromToValue(ELocation.Blob(), ELocation.None())) }
 func (FromTo) LocalBlobFS() FromTo { return FromTo(fromToValue(ELocation.Local(), ELocation.BlobFS())) }
 func (FromTo) BlobFSLocal() FromTo { return FromTo(fromToValue(ELocation.BlobFS(), ELocation.Local())) }
 func (FromTo) BlobBlob() FromTo    { return FromTo(fromToValue(ELocation.Blob(), ELocation.Blob())) }
@@ -523,6 +571,12 @@ func (ft *FromTo) IsUpload() bool {
 	return ft.From().IsLocal() && ft.To().IsRemote()
 }
 
+// IsMetadataOnly tells whether the transfers move no data, but only change or delete what's already at the source,
+// as deletes and setting properties do
+func (ft *FromTo) IsMetadataOnly() bool {
+	return ft.To() == ELocation.Unknown() || ft.To() == ELocation.None()
+}
+
 // TODO: deletes are not covered by the above Is* routines
 
 var BenchmarkLmt = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -596,6 +650,34 @@ func (TransferStatus) SkippedFileAlreadyExists() TransferStatus { return Transfe
 
 func (TransferStatus) SkippedBlobHasSnapshots() TransferStatus { return TransferStatus(-4) }
 
+// Transfer was skipped because the requested properties cannot be applied to this type of blob (e.g. a tier on an append blob)
+func (TransferStatus) SkippedIncompatibleBlobType() TransferStatus { return TransferStatus(-5) }
+
+// Transfer was skipped because the local source file could not be opened for lack of permission
+func (TransferStatus) SkippedPermissionDenied() TransferStatus { return TransferStatus(-6) }
+
+// Transfer was skipped because the local source file was in use by another process
+func (TransferStatus) SkippedFileLocked() TransferStatus { return TransferStatus(-7) }
+
+// Transfer failed because something else holds a lease on the destination, and so is most likely writing to it too
+func (TransferStatus) DestinationBusy() TransferStatus { return TransferStatus(-8) }
+
+// Transfer was skipped because its destination didn't meet the condition that the writes must meet (--destination-condition),
+// e.g. it already existed, or something else modified it
+func (TransferStatus) SkippedDestinationConditionNotMet() TransferStatus { return TransferStatus(-9) }
+
+// Transfer failed because its destination Azure file share reached its quota, and it wasn't raised within the wait that the job allows
+// (--wait-on-share-full). Resuming the job once the quota is raised transfers it
+func (TransferStatus) ShareFull() TransferStatus { return TransferStatus(-10) }
+
+// Transfer was skipped because its source was deleted after it was enumerated, and the job was asked to skip such sources
+// (--missing-source-handling=skip)
+func (TransferStatus) SkippedSourceDeleted() TransferStatus { return TransferStatus(-11) }
+
+// Transfer was skipped because its destination blob was soft-deleted, and the job was asked to leave such blobs alone
+// (--soft-deleted-destination=skip)
+func (TransferStatus) SkippedDestinationSoftDeleted() TransferStatus { return TransferStatus(-12) }
+
 func (ts TransferStatus) ShouldTransfer() bool {
 	return ts == ETransferStatus.NotStarted() || ts == ETransferStatus.Started()
 }
@@ -690,6 +772,9 @@ func (PageBlobTier) P4() PageBlobTier   { return PageBlobTier(4) }
 func (PageBlobTier) P40() PageBlobTier  { return PageBlobTier(40) }
 func (PageBlobTier) P50() PageBlobTier  { return PageBlobTier(50) }
 func (PageBlobTier) P6() PageBlobTier   { return PageBlobTier(6) }
+func (PageBlobTier) P60() PageBlobTier  { return PageBlobTier(60) }
+func (PageBlobTier) P70() PageBlobTier  { return PageBlobTier(70) }
+func (PageBlobTier) P80() PageBlobTier  { return PageBlobTier(80) }
 
 func (pbt PageBlobTier) String() string {
 	return enum.StringInt(pbt, reflect.TypeOf(pbt))
@@ -840,6 +925,199 @@ func (i *InvalidMetadataHandleOption) UnmarshalJSON(b []byte) error {
 	return i.Parse(s)
 }
 
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ES2SFallback = S2SFallback(0)
+
+// S2SFallback defines what a S2S copy does when the destination service cannot read the source
+type S2SFallback uint8
+
+// None indicates that the transfer fails, as it always has.
+func (S2SFallback) None() S2SFallback { return S2SFallback(0) }
+
+// ClientSide indicates that the transfer switches to downloading the data to this machine, and uploading it from there.
+func (S2SFallback) ClientSide() S2SFallback { return S2SFallback(1) }
+
+func (f S2SFallback) String() string {
+	return enum.StringInt(f, reflect.TypeOf(f))
+}
+
+// Parse accepts the names of the values in flag style too, e.g. client-side
+func (f *S2SFallback) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(f), strings.Replace(s, "-", "", -1), true)
+	if err == nil {
+		*f = val.(S2SFallback)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ES2SComputeMD5 = S2SComputeMD5(0)
+
+// S2SComputeMD5 defines which destination block blobs of a S2S copy have their Content-MD5 computed by this machine, after they're written,
+// since Put Block From URL doesn't compute it, and the destination of a source without one is left without one
+type S2SComputeMD5 uint8
+
+// None indicates that no Content-MD5 is computed, as it always has been. The destinations left without one are only reported.
+func (S2SComputeMD5) None() S2SComputeMD5 { return S2SComputeMD5(0) }
+
+// Missing indicates that the Content-MD5 is computed for the destinations that are known to be left without one.
+func (S2SComputeMD5) Missing() S2SComputeMD5 { return S2SComputeMD5(1) }
+
+// Always indicates that the Content-MD5 is computed for every destination. One that the source has is checked against it.
+func (S2SComputeMD5) Always() S2SComputeMD5 { return S2SComputeMD5(2) }
+
+func (c S2SComputeMD5) String() string {
+	return enum.StringInt(c, reflect.TypeOf(c))
+}
+
+func (c *S2SComputeMD5) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(c), s, true)
+	if err == nil {
+		*c = val.(S2SComputeMD5)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ETransferOrder = TransferOrder(0)
+
+// TransferOrder defines the order in which the transfers of a job part are scheduled
+type TransferOrder uint8
+
+// AsEnumerated indicates that the transfers are scheduled in the order the source was enumerated in, as they always have been.
+func (TransferOrder) AsEnumerated() TransferOrder { return TransferOrder(0) }
+
+// LargestFirst indicates that the largest files are scheduled first, so that they don't leave a long tail at the end of the job.
+func (TransferOrder) LargestFirst() TransferOrder { return TransferOrder(1) }
+
+// SmallestFirst indicates that the smallest files are scheduled first.
+func (TransferOrder) SmallestFirst() TransferOrder { return TransferOrder(2) }
+
+func (o TransferOrder) String() string {
+	return enum.StringInt(o, reflect.TypeOf(o))
+}
+
+// Parse accepts the names of the values in flag style too, e.g. largest-first
+func (o *TransferOrder) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(o), strings.Replace(s, "-", "", -1), true)
+	if err == nil {
+		*o = val.(TransferOrder)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ELowSpaceAction = LowSpaceAction(0)
+
+// LowSpaceAction defines what a download does when the volume it saves to has less free space than the job needs
+type LowSpaceAction uint8
+
+// Warn indicates that the user is told, and the job goes ahead.
+func (LowSpaceAction) Warn() LowSpaceAction { return LowSpaceAction(0) }
+
+// Fail indicates that the job is stopped before it transfers what there's no room for.
+func (LowSpaceAction) Fail() LowSpaceAction { return LowSpaceAction(1) }
+
+// Prompt indicates that the user is asked whether the job should go ahead.
+func (LowSpaceAction) Prompt() LowSpaceAction { return LowSpaceAction(2) }
+
+func (a LowSpaceAction) String() string {
+	return enum.StringInt(a, reflect.TypeOf(a))
+}
+
+func (a *LowSpaceAction) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(a), s, true)
+	if err == nil {
+		*a = val.(LowSpaceAction)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var EInvalidNameHandling = InvalidNameHandling(0)
+
+// InvalidNameHandling defines what happens to the objects whose names the destination doesn't allow,
+// e.g. blobs with a backslash or a trailing dot, copied to Azure Files or to Windows
+type InvalidNameHandling uint8
+
+// Fail indicates that the names are left as they are, and the transfers of those that the destination refuses fail.
+func (InvalidNameHandling) Fail() InvalidNameHandling { return InvalidNameHandling(0) }
+
+// Skip indicates that the objects are left out of the job when it's enumerated.
+func (InvalidNameHandling) Skip() InvalidNameHandling { return InvalidNameHandling(1) }
+
+// Sanitize indicates that the characters the destination doesn't allow are percent-encoded, and the names recorded in a mapping file.
+func (InvalidNameHandling) Sanitize() InvalidNameHandling { return InvalidNameHandling(2) }
+
+func (h InvalidNameHandling) String() string {
+	return enum.StringInt(h, reflect.TypeOf(h))
+}
+
+func (h *InvalidNameHandling) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(h), s, true)
+	if err == nil {
+		*h = val.(InvalidNameHandling)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var EChunkScheduling = ChunkScheduling(0)
+
+// ChunkScheduling defines the order in which the chunk processors pick up the chunks of the transfers in flight
+type ChunkScheduling uint8
+
+// Throughput indicates that the chunks are processed in the order they were scheduled, which keeps the most connections busy,
+// but lets the chunks of a huge transfer hold up those of the transfers scheduled after it.
+func (ChunkScheduling) Throughput() ChunkScheduling { return ChunkScheduling(0) }
+
+// Fair indicates that the chunks are processed by weighted round-robin across the transfers in flight, so that each gets some of them processed every round.
+func (ChunkScheduling) Fair() ChunkScheduling { return ChunkScheduling(1) }
+
+func (s ChunkScheduling) String() string {
+	return enum.StringInt(s, reflect.TypeOf(s))
+}
+
+func (s *ChunkScheduling) Parse(str string) error {
+	val, err := enum.Parse(reflect.TypeOf(s), str, true)
+	if err == nil {
+		*s = val.(ChunkScheduling)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var EListOfFilesFormat = ListOfFilesFormat(0)
+
+// ListOfFilesFormat defines how the entries of the list of files are written
+type ListOfFilesFormat uint8
+
+// Text indicates that each line is the path of an entry, relative to the source.
+func (ListOfFilesFormat) Text() ListOfFilesFormat { return ListOfFilesFormat(0) }
+
+// NDJSON indicates that each line is a JSON object, with the path of the entry relative to the source,
+// and optionally its path relative to the destination, and its metadata.
+func (ListOfFilesFormat) NDJSON() ListOfFilesFormat { return ListOfFilesFormat(1) }
+
+func (f ListOfFilesFormat) String() string {
+	return enum.StringInt(f, reflect.TypeOf(f))
+}
+
+func (f *ListOfFilesFormat) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(f), s, true)
+	if err == nil {
+		*f = val.(ListOfFilesFormat)
+	}
+	return err
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 const (
 	DefaultBlockBlobBlockSize = 8 * 1024 * 1024
@@ -870,6 +1148,10 @@ type CopyTransfer struct {
 	// Properties for S2S blob copy
 	BlobType azblob.BlobType
 	BlobTier azblob.AccessTierType
+
+	// ExistsAtDestination is set when the listing of the destination found the entity already there,
+	// so that, with overwrite disabled, the transfer is skipped without the destination being checked again
+	ExistsAtDestination bool
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -920,6 +1202,36 @@ func UnMarshalToCommonMetadata(metadataString string) (Metadata, error) {
 	return result, nil
 }
 
+// PreservedLastModifiedTimeMetadataKey is the reserved metadata key that the last modified time of the source is kept under,
+// for the destinations whose own last modified time can't be set (--preserve-source-lmt-as-metadata)
+const PreservedLastModifiedTimeMetadataKey = "azcopy_source_lmt"
+
+// WithPreservedLastModifiedTime returns a copy of the metadata that keeps the given time under the reserved key, in RFC3339 and UTC.
+// A time that the metadata already keeps wins, since it's the time of the original source, from before it was copied here.
+func (m Metadata) WithPreservedLastModifiedTime(lastModifiedTime time.Time) Metadata {
+	if _, ok := m.PreservedLastModifiedTime(); ok {
+		return m
+	}
+	result := make(Metadata, len(m)+1)
+	for k, v := range m {
+		result[k] = v
+	}
+	result[PreservedLastModifiedTimeMetadataKey] = lastModifiedTime.UTC().Format(time.RFC3339Nano)
+	return result
+}
+
+// PreservedLastModifiedTime returns the time that the metadata keeps under the reserved key, if it keeps a valid one.
+// The key is matched regardless of case, as the service doesn't preserve it.
+func (m Metadata) PreservedLastModifiedTime() (time.Time, bool) {
+	for k, v := range m {
+		if strings.EqualFold(k, PreservedLastModifiedTimeMetadataKey) {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return t, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
 // isValidMetadataKey checks if the given string is a valid metadata key for Azure.
 // For Azure, metadata key must adhere to the naming rules for C# identifiers.
 // As testing, reserved keyworkds for C# identifiers are also valid metadata key. (e.g. this, int)
@@ -1030,6 +1342,59 @@ func (m Metadata) ConcatenatedKeys() string {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+// BlobTags is the set of index tags to be applied to a blob.
+type BlobTags map[string]string
+
+// ToString serializes the tags in the same key=value;key=value form that the user provides them in.
+// Keys are sorted, so that the result is stable.
+func (bt BlobTags) ToString() string {
+	keys := make([]string, 0, len(bt))
+	for k := range bt {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+bt[k])
+	}
+	return strings.Join(pairs, ";")
+}
+
+// ToCommonBlobTags parses a key=value;key=value string into BlobTags.
+func ToCommonBlobTags(blobTagsString string) (BlobTags, error) {
+	result := BlobTags{}
+	if blobTagsString == "" {
+		return result, nil
+	}
+
+	for _, keyAndValue := range strings.Split(blobTagsString, ";") { // key/value pairs are separated by ';'
+		kv := strings.SplitN(keyAndValue, "=", 2) // key/value are separated by '='
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid blob tag %q, tags must be given as key=value pairs separated by ';'", keyAndValue)
+		}
+		if len(kv[0]) > MaxBlobTagKeyLength || len(kv[1]) > MaxBlobTagValueLength {
+			return nil, fmt.Errorf("invalid blob tag %q, tag keys can be at most %d characters and values at most %d characters", keyAndValue, MaxBlobTagKeyLength, MaxBlobTagValueLength)
+		}
+		result[kv[0]] = kv[1]
+	}
+
+	if len(result) > MaxBlobTagsCount {
+		return nil, fmt.Errorf("a blob can have at most %d tags, but %d were given", MaxBlobTagsCount, len(result))
+	}
+
+	return result, nil
+}
+
+// limits the service places on the tags of a single blob
+const (
+	MaxBlobTagsCount      = 10
+	MaxBlobTagKeyLength   = 128
+	MaxBlobTagValueLength = 256
+)
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 // Common resource's HTTP headers stands for properties used in AzCopy.
 type ResourceHTTPHeaders struct {
 	ContentType        string
@@ -1146,7 +1511,60 @@ increasing read_ahead_kb to 8192 for the data disk.`
 
 const SizePerFileParam = "size-per-file"
 const FileCountParam = "file-count"
+const SizeSpecParam = "size-spec"
 const FileCountDefault = 100
+const BenchMarkModeParam = "mode"
+
+var EBenchMarkMode = BenchMarkMode(0)
+
+// BenchMarkMode says which kind of transfer is measured by the benchmark command
+type BenchMarkMode uint8
+
+func (BenchMarkMode) Upload() BenchMarkMode   { return BenchMarkMode(0) }
+func (BenchMarkMode) Download() BenchMarkMode { return BenchMarkMode(1) }
+func (BenchMarkMode) S2S() BenchMarkMode      { return BenchMarkMode(2) }
+
+func (bm *BenchMarkMode) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(bm), s, true)
+	if err == nil {
+		*bm = val.(BenchMarkMode)
+	}
+	return err
+}
+
+func (bm BenchMarkMode) String() string {
+	return enum.StringInt(bm, reflect.TypeOf(bm))
+}
+
+func (bm BenchMarkMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bm.String())
+}
+
+// BenchmarkResults describes the job that was measured in a benchmark run.
+// Only the front end knows that a job is a benchmark, so it is the front end that fills this in.
+type BenchmarkResults struct {
+	Mode              BenchMarkMode
+	BlockSizeBytes    int64
+	Concurrency       int
+	MegabitsPerSecond float64
+	FilesPerSecond    float64
+
+	// when the benchmark uses a mixture of file sizes, each size is measured by its own job.
+	// The last of those jobs reports on all of them here, and summarizes the small and large files separately
+	Buckets               []BenchmarkBucketResults `json:",omitempty"`
+	SmallFilesPerSecond   float64                  `json:",omitempty"` // for files that fit into a single block
+	BulkMegabitsPerSecond float64                  `json:",omitempty"` // for files that need more than one block
+}
+
+type BenchmarkBucketResults struct {
+	FileCount         uint
+	BytesPerFile      int64
+	BlockSizeBytes    int64
+	Concurrency       int
+	MegabitsPerSecond float64
+	FilesPerSecond    float64
+	ElapsedSeconds    float64
+}
 
 //////////////////////////////////////////////////////////////////////////////////////
 
@@ -1175,3 +1593,128 @@ func GetCompressionType(contentEncoding string) (CompressionType, error) {
 		return ECompressionType.Unsupported(), fmt.Errorf("encoding type '%s' is not recognised as a supported encoding type for auto-decompression", contentEncoding)
 	}
 }
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ESetPropertiesFlags = SetPropertiesFlags(0)
+
+// SetPropertiesFlags is a set of bit flags, saying which properties a set-properties job should change
+type SetPropertiesFlags uint32
+
+func (SetPropertiesFlags) None() SetPropertiesFlags        { return SetPropertiesFlags(0) }
+func (SetPropertiesFlags) SetTier() SetPropertiesFlags     { return SetPropertiesFlags(1) }
+func (SetPropertiesFlags) SetMetadata() SetPropertiesFlags { return SetPropertiesFlags(2) }
+func (SetPropertiesFlags) SetBlobTags() SetPropertiesFlags { return SetPropertiesFlags(4) }
+
+func (op SetPropertiesFlags) IsSet(flag SetPropertiesFlags) bool {
+	return op&flag == flag
+}
+
+func (op SetPropertiesFlags) ShouldTransferTier() bool {
+	return op.IsSet(ESetPropertiesFlags.SetTier())
+}
+
+func (op SetPropertiesFlags) ShouldTransferMetaData() bool {
+	return op.IsSet(ESetPropertiesFlags.SetMetadata())
+}
+
+func (op SetPropertiesFlags) ShouldTransferBlobTags() bool {
+	return op.IsSet(ESetPropertiesFlags.SetBlobTags())
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var EFlushPolicy = FlushPolicy(0)
+
+// FlushPolicy defines when the files that are downloaded are flushed to stable storage
+type FlushPolicy uint8
+
+// None indicates that the files are left to the OS to flush, as they always have been.
+func (FlushPolicy) None() FlushPolicy { return FlushPolicy(0) }
+
+// PerFile indicates that each file is flushed once it's written, before its transfer is reported successful.
+func (FlushPolicy) PerFile() FlushPolicy { return FlushPolicy(1) }
+
+// PerChunk indicates that each file is also flushed as it's written, each time a given number of bytes more are written.
+func (FlushPolicy) PerChunk() FlushPolicy { return FlushPolicy(2) }
+
+func (p FlushPolicy) String() string {
+	return enum.StringInt(p, reflect.TypeOf(p))
+}
+
+// Parse accepts the names of the values in flag style too, e.g. per-file
+func (p *FlushPolicy) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(p), strings.Replace(s, "-", "", -1), true)
+	if err == nil {
+		*p = val.(FlushPolicy)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var EMissingSourceHandling = MissingSourceHandling(0)
+
+// MissingSourceHandling defines what happens to the transfers whose source was deleted between the enumeration and the transfer,
+// as happens when the source container is in use while the job runs
+type MissingSourceHandling uint8
+
+// Fail indicates that the transfers fail, like those that fail for any other reason.
+func (MissingSourceHandling) Fail() MissingSourceHandling { return MissingSourceHandling(0) }
+
+// Skip indicates that the transfers are skipped, and so don't make the job fail.
+func (MissingSourceHandling) Skip() MissingSourceHandling { return MissingSourceHandling(1) }
+
+func (h MissingSourceHandling) String() string {
+	return enum.StringInt(h, reflect.TypeOf(h))
+}
+
+func (h *MissingSourceHandling) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(h), s, true)
+	if err == nil {
+		*h = val.(MissingSourceHandling)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ESoftDeletedDestinationHandling = SoftDeletedDestinationHandling(0)
+
+// SoftDeletedDestinationHandling defines what happens to the transfers whose destination blob doesn't exist, but is soft-deleted.
+// Writing to such a blob either fails, or creates a new blob next to the deleted one, depending on the settings of the account
+type SoftDeletedDestinationHandling uint8
+
+// Ignore indicates that destinations aren't checked for being soft-deleted, and are written as if they didn't exist at all.
+func (SoftDeletedDestinationHandling) Ignore() SoftDeletedDestinationHandling {
+	return SoftDeletedDestinationHandling(0)
+}
+
+// Overwrite indicates that the soft-deleted destinations are written over, like those that don't exist.
+func (SoftDeletedDestinationHandling) Overwrite() SoftDeletedDestinationHandling {
+	return SoftDeletedDestinationHandling(1)
+}
+
+// UndeleteAndOverwrite indicates that the soft-deleted destinations are undeleted first, which restores their soft-deleted snapshots,
+// and then written over.
+func (SoftDeletedDestinationHandling) UndeleteAndOverwrite() SoftDeletedDestinationHandling {
+	return SoftDeletedDestinationHandling(2)
+}
+
+// Skip indicates that the transfers to soft-deleted destinations are skipped, which leaves the destinations as they are.
+func (SoftDeletedDestinationHandling) Skip() SoftDeletedDestinationHandling {
+	return SoftDeletedDestinationHandling(3)
+}
+
+func (h SoftDeletedDestinationHandling) String() string {
+	return enum.StringInt(h, reflect.TypeOf(h))
+}
+
+// Parse accepts the names of the values in flag style too, e.g. undelete-and-overwrite
+func (h *SoftDeletedDestinationHandling) Parse(s string) error {
+	val, err := enum.Parse(reflect.TypeOf(h), strings.Replace(s, "-", "", -1), true)
+	if err == nil {
+		*h = val.(SoftDeletedDestinationHandling)
+	}
+	return err
+}
@@ -173,3 +173,34 @@ func (s *feSteModelsTestSuite) TestMetadataResolveInvalidKeyNegative(c *chk.C) {
 	_, err = mNegative3.ResolveInvalidKey()
 	c.Assert(err, chk.NotNil)
 }
+
+func (s *feSteModelsTestSuite) TestBlobTagsRoundTrip(c *chk.C) {
+	tags, err := common.ToCommonBlobTags("project=alpha;owner=finance;empty=")
+	c.Assert(err, chk.IsNil)
+	c.Assert(tags, chk.DeepEquals, common.BlobTags{"project": "alpha", "owner": "finance", "empty": ""})
+
+	// keys are sorted, so the serialized form is stable
+	c.Assert(tags.ToString(), chk.Equals, "empty=;owner=finance;project=alpha")
+
+	tags, err = common.ToCommonBlobTags("")
+	c.Assert(err, chk.IsNil)
+	c.Assert(tags, chk.HasLen, 0)
+}
+
+func (s *feSteModelsTestSuite) TestBlobTagsInvalid(c *chk.C) {
+	_, err := common.ToCommonBlobTags("novalue")
+	c.Assert(err, chk.NotNil)
+
+	_, err = common.ToCommonBlobTags("=value")
+	c.Assert(err, chk.NotNil)
+
+	_, err = common.ToCommonBlobTags("a=1;b=2;c=3;d=4;e=5;f=6;g=7;h=8;i=9;j=10;k=11")
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *feSteModelsTestSuite) TestSetPropertiesFlags(c *chk.C) {
+	flags := common.ESetPropertiesFlags.SetTier() | common.ESetPropertiesFlags.SetBlobTags()
+	c.Assert(flags.ShouldTransferTier(), chk.Equals, true)
+	c.Assert(flags.ShouldTransferMetaData(), chk.Equals, false)
+	c.Assert(flags.ShouldTransferBlobTags(), chk.Equals, true)
+}
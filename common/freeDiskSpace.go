@@ -0,0 +1,57 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// GetFreeDiskSpace returns the bytes that the current user can still write to the volume that holds path.
+// The path needn't exist yet, e.g. the destination of a download: the volume is that of the nearest directory above it that does.
+func GetFreeDiskSpace(path string) (uint64, error) {
+	dir, err := nearestExistingDirectory(path)
+	if err != nil {
+		return 0, err
+	}
+	return freeDiskSpaceOf(dir)
+}
+
+func nearestExistingDirectory(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if fi, err := os.Stat(path); err == nil {
+			if fi.IsDir() {
+				return path, nil
+			}
+			return filepath.Dir(path), nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", fmt.Errorf("no part of the path %s exists", path)
+		}
+		path = parent
+	}
+}
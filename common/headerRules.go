@@ -0,0 +1,162 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// HeaderRule sets content headers on the uploaded files that its pattern matches (--header-rule), instead of those of the job,
+// including the content type that was detected. Only the headers that it sets are changed
+type HeaderRule struct {
+	// a pattern without a slash, such as *.html, matches the names of the files, wherever they are.
+	// One with a slash, such as assets/*, matches their paths relative to the source, and everything under the directories that it matches
+	Pattern         string
+	ContentType     string
+	CacheControl    string
+	ContentEncoding string
+}
+
+// HeaderRules are the header rules of a job, in the order they were given, since the first that matches a file is the one that applies to it
+type HeaderRules []HeaderRule
+
+// ParseHeaderRule parses a rule of the form pattern=*.html;cacheControl=no-cache, whose fields may also include contentType and contentEncoding.
+// A part that doesn't start with the name of a field belongs to the value before it, so that content types can have parameters, e.g. text/html; charset=utf-8
+func ParseHeaderRule(raw string) (HeaderRule, error) {
+	if strings.ContainsAny(raw, "\r\n") {
+		return HeaderRule{}, fmt.Errorf("invalid header rule %q, it cannot contain line breaks", raw) // since the rules of a job are stored one per line
+	}
+
+	rule := HeaderRule{}
+	var lastValue *string
+	for _, part := range strings.Split(raw, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		value := rule.field(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 && value != nil {
+			if *value != "" {
+				return HeaderRule{}, fmt.Errorf("invalid header rule %q, %s is given more than once", raw, strings.TrimSpace(kv[0]))
+			}
+			*value = strings.TrimSpace(kv[1])
+			lastValue = value
+			continue
+		}
+
+		if lastValue == nil {
+			return HeaderRule{}, fmt.Errorf("invalid header rule %q, it must start with pattern=", raw)
+		}
+		*lastValue += ";" + part
+	}
+
+	if rule.Pattern == "" {
+		return HeaderRule{}, fmt.Errorf("invalid header rule %q, it has no pattern", raw)
+	}
+	if _, err := path.Match(rule.Pattern, ""); err != nil {
+		return HeaderRule{}, fmt.Errorf("invalid pattern in header rule %q: %s", raw, err)
+	}
+	if rule.ContentType == "" && rule.CacheControl == "" && rule.ContentEncoding == "" {
+		return HeaderRule{}, fmt.Errorf("invalid header rule %q, it must set at least one of contentType, cacheControl and contentEncoding", raw)
+	}
+	return rule, nil
+}
+
+// field returns the value of the field with the given name, or nil if there is no such field
+func (r *HeaderRule) field(name string) *string {
+	switch {
+	case strings.EqualFold(name, "pattern"):
+		return &r.Pattern
+	case strings.EqualFold(name, "contentType"):
+		return &r.ContentType
+	case strings.EqualFold(name, "cacheControl"):
+		return &r.CacheControl
+	case strings.EqualFold(name, "contentEncoding"):
+		return &r.ContentEncoding
+	default:
+		return nil
+	}
+}
+
+func (r HeaderRule) String() string {
+	s := "pattern=" + r.Pattern
+	if r.ContentType != "" {
+		s += ";contentType=" + r.ContentType
+	}
+	if r.CacheControl != "" {
+		s += ";cacheControl=" + r.CacheControl
+	}
+	if r.ContentEncoding != "" {
+		s += ";contentEncoding=" + r.ContentEncoding
+	}
+	return s
+}
+
+// Matches tells whether the rule applies to the file at relativePath, which is relative to the source and has forward slashes
+func (r HeaderRule) Matches(relativePath string) bool {
+	relativePath = strings.Trim(relativePath, "/")
+	if !strings.Contains(r.Pattern, "/") {
+		matched, _ := path.Match(r.Pattern, path.Base(relativePath))
+		return matched
+	}
+
+	pattern := strings.TrimPrefix(r.Pattern, "/")
+	for p := relativePath; p != "." && p != ""; p = path.Dir(p) {
+		if matched, _ := path.Match(pattern, p); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHeaderRules parses the rules that String wrote, one per line
+func ParseHeaderRules(s string) (HeaderRules, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	rules := make(HeaderRules, 0)
+	for _, line := range strings.Split(s, "\n") {
+		rule, err := ParseHeaderRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (rs HeaderRules) String() string {
+	lines := make([]string, len(rs))
+	for i, r := range rs {
+		lines[i] = r.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Match returns the first rule that applies to the file at relativePath, and its position among the rules
+func (rs HeaderRules) Match(relativePath string) (rule HeaderRule, index int, ok bool) {
+	for i, r := range rs {
+		if r.Matches(relativePath) {
+			return r, i, true
+		}
+	}
+	return HeaderRule{}, -1, false
+}
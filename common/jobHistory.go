@@ -0,0 +1,98 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// the file, in the AzCopy folder, that the history of the jobs is kept in.
+// Unlike the plan and log files, it's not per job, so removing the files of jobs leaves it alone
+const JobHistoryFileName = "job-history.ndjson"
+
+// JobHistoryEntry is what the job history keeps of a finished job: one JSON object per line of the history file
+type JobHistoryEntry struct {
+	JobID JobID
+	// the command line, and the roots of the source and the destination, with their SAS redacted
+	Command     string
+	Source      string
+	Destination string
+	StartTime   time.Time
+	EndTime     time.Time
+
+	TotalTransfers        uint32
+	TransfersCompleted    uint32
+	TransfersFailed       uint32
+	TransfersSkipped      uint32
+	TotalBytesTransferred uint64
+	JobStatus             JobStatus
+}
+
+func JobHistoryPath(azcopyAppPathFolder string) string {
+	return filepath.Join(azcopyAppPathFolder, JobHistoryFileName)
+}
+
+// AppendJobHistory adds the entry to the end of the history file, creating the file if needed.
+// The entry is written in a single write to a file opened for appending, so that the jobs that end at the same time,
+// in different processes, don't interleave their lines
+func AppendJobHistory(path string, entry JobHistoryEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadJobHistory returns the entries of the history file, oldest first. There's no history if the file doesn't exist.
+// Lines that can't be read, e.g. one that was cut short when the disk filled up, are skipped
+func ReadJobHistory(path string) ([]JobHistoryEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make([]JobHistoryEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // a long command line makes for a long line
+	for scanner.Scan() {
+		var entry JobHistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,180 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// JobTemplateSchemaVersion is the version of the layout of the job templates that this version of AzCopy writes.
+// Increment it whenever the layout changes in a way that the older versions couldn't read
+const JobTemplateSchemaVersion = 1
+
+// the suffix of the file, next to the plan files of a job, that keeps the template of the job
+const JobTemplateFileSuffix = ".template.yaml"
+
+// JobTemplate is the configuration of a job, without its credentials, that another job can be run with (copy --from-template).
+// Each flag is kept with the value given on the command line, so that the job it's loaded into cooks them the same way
+type JobTemplate struct {
+	SchemaVersion int    `yaml:"schemaVersion"`
+	AzCopyVersion string `yaml:"azcopyVersion"`
+	Command       string `yaml:"command"`
+	// the source and the destination, without their SAS
+	Source      string                     `yaml:"source,omitempty"`
+	Destination string                     `yaml:"destination,omitempty"`
+	Flags       map[string]JobTemplateFlag `yaml:"flags,omitempty"`
+	// the hash of the flags, which is also written in the log of each job that was run with them
+	FlagSetHash string `yaml:"flagSetHash"`
+}
+
+// JobTemplateFlag is the value of a flag, or the values of a flag that may be given more than once (e.g. --header-rule)
+type JobTemplateFlag []string
+
+// MarshalYAML writes a flag with a single value as that value, so that the template is easy to read and edit
+func (f JobTemplateFlag) MarshalYAML() (interface{}, error) {
+	if len(f) == 1 {
+		return f[0], nil
+	}
+	return []string(f), nil
+}
+
+func (f *JobTemplateFlag) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*f = JobTemplateFlag{single}
+		return nil
+	}
+	var values []string
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	*f = values
+	return nil
+}
+
+// NewJobTemplate returns the template of a job of the command, run against the source and destination with the flags
+func NewJobTemplate(command, source, destination string, flags map[string]JobTemplateFlag) JobTemplate {
+	t := JobTemplate{
+		SchemaVersion: JobTemplateSchemaVersion,
+		AzCopyVersion: AzcopyVersion,
+		Command:       command,
+		Source:        withoutSAS(source),
+		Destination:   withoutSAS(destination),
+		Flags:         make(map[string]JobTemplateFlag, len(flags)),
+	}
+	for name, values := range flags {
+		redacted := make(JobTemplateFlag, len(values))
+		for i, v := range values {
+			redacted[i] = withoutSAS(v)
+		}
+		t.Flags[name] = redacted
+	}
+	t.FlagSetHash = t.ComputeFlagSetHash()
+	return t
+}
+
+// ComputeFlagSetHash is the SHA-256 of the flags, sorted by name, which doesn't depend on the order that they were given in
+func (t JobTemplate) ComputeFlagSetHash() string {
+	names := make([]string, 0, len(t.Flags))
+	for name := range t.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		for _, v := range t.Flags[name] {
+			fmt.Fprintf(h, "%s=%s\n", name, v)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// withoutSAS removes the query string of a URL that carries a signature, since a template must not hold any credentials
+func withoutSAS(s string) string {
+	if !strings.HasPrefix(strings.ToLower(s), "http") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	for name := range u.Query() {
+		if strings.EqualFold(name, SigAzure) {
+			u.RawQuery = ""
+			break
+		}
+	}
+	return u.String()
+}
+
+func JobTemplatePath(jobPlanFolder string, jobID JobID) string {
+	return filepath.Join(jobPlanFolder, jobID.String()+JobTemplateFileSuffix)
+}
+
+func WriteJobTemplate(path string, t JobTemplate) error {
+	out, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
+
+// ReadJobTemplate reads a template, which must not be of a newer schema than this version of AzCopy knows.
+// The hash of its flags is computed afresh, since they may have been edited
+func ReadJobTemplate(path string) (JobTemplate, error) {
+	var t JobTemplate
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+
+	// the version is checked before anything else, since a newer template may well have fields that this version doesn't know
+	var version struct {
+		SchemaVersion int    `yaml:"schemaVersion"`
+		AzCopyVersion string `yaml:"azcopyVersion"`
+	}
+	if err = yaml.Unmarshal(in, &version); err != nil {
+		return t, fmt.Errorf("%s is not a job template: %w", path, err)
+	}
+	if version.SchemaVersion <= 0 {
+		return t, fmt.Errorf("%s is not a job template, as it has no schemaVersion", path)
+	}
+	if version.SchemaVersion > JobTemplateSchemaVersion {
+		return t, fmt.Errorf("the job template %s is of version %d, which is newer than this version of AzCopy (%s) can read. Please use AzCopy %s or later",
+			path, version.SchemaVersion, AzcopyVersion, version.AzCopyVersion)
+	}
+
+	if err = yaml.UnmarshalStrict(in, &t); err != nil {
+		return t, fmt.Errorf("%s is not a job template: %w", path, err)
+	}
+	t.FlagSetHash = t.ComputeFlagSetHash()
+	return t, nil
+}
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"runtime/pprof"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -46,6 +47,7 @@ type LifecycleMgr interface {
 	Progress(OutputBuilder)                                      // print on the same line over and over again, not allowed to float up
 	Exit(OutputBuilder, ExitCode)                                // indicates successful execution exit after printing, allow user to specify exit code
 	Info(string)                                                 // simple print, allowed to float up
+	Event(name string, o OutputBuilder)                          // tell about something that happened to one object, allowed to float up. Its JSON message type is the name
 	Error(string)                                                // indicates fatal error, exit after printing, exit code is always Failed (1)
 	Prompt(message string, details PromptDetails) ResponseOption // ask the user a question(after erasing the progress), then return the response
 	SurrenderControl()                                           // give up control, this should never return
@@ -57,6 +59,8 @@ type LifecycleMgr interface {
 	EnableInputWatcher()                                         // depending on the command, we may allow user to give input through Stdin
 	EnableCancelFromStdIn()                                      // allow user to send in `cancel` to stop the job
 	AddUserAgentPrefix(string) string                            // append the global user agent prefix, if applicable
+	RegisterCloseFunc(func())                                    // run the func before the process exits, however it exits
+	CloseOnPanic()                                               // deferred, runs the close funcs before a panic ends the process
 }
 
 func GetLifecycleMgr() LifecycleMgr {
@@ -74,6 +78,49 @@ type lifecycleMgr struct {
 	inputQueue           chan userInput // msgs from the user
 	allowWatchInput      bool           // accept user inputs and place then in the inputQueue
 	allowCancelFromStdIn bool           // allow user to send in 'cancel' from the stdin to stop the current job
+	closeFuncsLock       sync.Mutex
+	closeFuncs           []func()     // what must be cleaned up before the process exits, such as the snapshots that a job reads from
+	sink                 atomic.Value // holds a sinkHolder, whose sink is nil unless AzCopy runs inside another program
+}
+
+// OutputSink takes the place of stdout and of the process exit, when AzCopy runs inside another program (see the client package).
+// It is given each message as the JSON line that --output-type=json would have printed, whatever the output format
+type OutputSink interface {
+	Output(jsonMessage string)
+	Exit(code ExitCode) // instead of the process exiting, after the close funcs have run
+}
+
+type sinkHolder struct{ sink OutputSink }
+
+// SetOutputSink sends all the output to the sink, rather than to stdout, and stops the lifecycle manager from ever exiting the process.
+// The goroutine that would have waited for the exit (see SurrenderControl) ends instead. A nil sink restores the normal behavior
+func SetOutputSink(sink OutputSink) {
+	lcm.sink.Store(sinkHolder{sink: sink})
+}
+
+// RequestCancellation cancels the current job, as Ctrl-C would. It's how the job of an embedded AzCopy is cancelled,
+// since the signals belong to the program it's embedded in
+func RequestCancellation() {
+	select {
+	case lcm.cancelChannel <- os.Interrupt:
+	default: // a cancellation is already pending
+	}
+}
+
+// ClearCancellationRequest drops a cancellation that no job picked up, e.g. because the job had already failed,
+// so that it doesn't cancel the next job of an embedded AzCopy
+func ClearCancellationRequest() {
+	select {
+	case <-lcm.cancelChannel:
+	default:
+	}
+}
+
+func (lcm *lifecycleMgr) getSink() OutputSink {
+	if h, ok := lcm.sink.Load().(sinkHolder); ok {
+		return h.sink
+	}
+	return nil
 }
 
 type userInput struct {
@@ -217,6 +264,14 @@ func (lcm *lifecycleMgr) Info(msg string) {
 	}
 }
 
+func (lcm *lifecycleMgr) Event(name string, o OutputBuilder) {
+	lcm.msgQueue <- outputMessage{
+		msgContent: lcm.logSanitizer.SanitizeLogMessage(o(lcm.outputFormat)),
+		msgType:    eOutputMessageType.Event(),
+		eventName:  name,
+	}
+}
+
 func (lcm *lifecycleMgr) Prompt(message string, details PromptDetails) ResponseOption {
 	expectedInputChannel := make(chan string, 1)
 	lcm.msgQueue <- outputMessage{
@@ -293,6 +348,11 @@ func (lcm *lifecycleMgr) Exit(o OutputBuilder, applicationExitCode ExitCode) {
 
 // this is used by commands that wish to stall forever to wait for the operations to complete
 func (lcm *lifecycleMgr) SurrenderControl() {
+	if lcm.getSink() != nil {
+		// the process isn't going to exit under us, so don't leave the goroutine hanging
+		runtime.Goexit()
+	}
+
 	// stall forever
 	select {}
 }
@@ -303,6 +363,11 @@ func (lcm *lifecycleMgr) processOutputMessage() {
 	for {
 		msgToPrint := <-lcm.msgQueue
 
+		if sink := lcm.getSink(); sink != nil {
+			lcm.processSinkOutput(sink, msgToPrint)
+			continue
+		}
+
 		switch lcm.outputFormat {
 		case EOutputFormat.Json():
 			lcm.processJSONOutput(msgToPrint)
@@ -316,11 +381,28 @@ func (lcm *lifecycleMgr) processOutputMessage() {
 	}
 }
 
+func (lcm *lifecycleMgr) processSinkOutput(sink OutputSink, msgToOutput outputMessage) {
+	if msgToOutput.msgType == eOutputMessageType.Event() {
+		sink.Output(GetJsonStringFromTemplate(newJsonEventTemplate(msgToOutput.eventName, msgToOutput.msgContent)))
+	} else {
+		sink.Output(GetJsonStringFromTemplate(newJsonOutputTemplate(msgToOutput.msgType, msgToOutput.msgContent,
+			msgToOutput.promptDetails)))
+	}
+
+	if msgToOutput.shouldExitProcess() {
+		lcm.runCloseFuncs()
+		sink.Exit(msgToOutput.exitCode)
+	} else if msgToOutput.msgType == eOutputMessageType.Prompt() {
+		// there's no one to answer, so the default behavior applies
+		msgToOutput.inputChannel <- ""
+	}
+}
+
 func (lcm *lifecycleMgr) processNoneOutput(msgToOutput outputMessage) {
 	if msgToOutput.msgType == eOutputMessageType.Error() {
-		os.Exit(int(EExitCode.Error()))
+		lcm.exit(EExitCode.Error())
 	} else if msgToOutput.shouldExitProcess() {
-		os.Exit(int(msgToOutput.exitCode))
+		lcm.exit(msgToOutput.exitCode)
 	}
 
 	// ignore all other outputs
@@ -333,12 +415,16 @@ func (lcm *lifecycleMgr) processJSONOutput(msgToOutput outputMessage) {
 
 	// simply output the json message
 	// we assume the msgContent is already formatted correctly
-	fmt.Println(GetJsonStringFromTemplate(newJsonOutputTemplate(msgType, msgToOutput.msgContent,
-		msgToOutput.promptDetails)))
+	if msgType == eOutputMessageType.Event() {
+		fmt.Println(GetJsonStringFromTemplate(newJsonEventTemplate(msgToOutput.eventName, msgToOutput.msgContent)))
+	} else {
+		fmt.Println(GetJsonStringFromTemplate(newJsonOutputTemplate(msgType, msgToOutput.msgContent,
+			msgToOutput.promptDetails)))
+	}
 
 	// exit if needed
 	if msgToOutput.shouldExitProcess() {
-		os.Exit(int(msgToOutput.exitCode))
+		lcm.exit(msgToOutput.exitCode)
 	} else if msgType == eOutputMessageType.Prompt() {
 		// read the response to the prompt and send it back through the channel
 		msgToOutput.inputChannel <- lcm.getInputAfterTime(questionTime)
@@ -364,7 +450,7 @@ func (lcm *lifecycleMgr) processTextOutput(msgToOutput outputMessage) {
 			fmt.Println("\n" + msgToOutput.msgContent)
 		}
 		if msgToOutput.shouldExitProcess() {
-			os.Exit(int(msgToOutput.exitCode))
+			lcm.exit(msgToOutput.exitCode)
 		}
 
 	case eOutputMessageType.Progress():
@@ -377,7 +463,7 @@ func (lcm *lifecycleMgr) processTextOutput(msgToOutput outputMessage) {
 
 		lcm.progressCache = msgToOutput.msgContent
 
-	case eOutputMessageType.Init(), eOutputMessageType.Info():
+	case eOutputMessageType.Init(), eOutputMessageType.Info(), eOutputMessageType.Event():
 		if lcm.progressCache != "" { // a progress status is already on the last line
 			// print the info from the beginning on current line
 			fmt.Print("\r")
@@ -420,6 +506,40 @@ func (lcm *lifecycleMgr) processTextOutput(msgToOutput outputMessage) {
 	}
 }
 
+// exit runs the close funcs before the process exits, since os.Exit doesn't run deferred calls
+func (lcm *lifecycleMgr) exit(exitCode ExitCode) {
+	lcm.runCloseFuncs()
+	os.Exit(int(exitCode))
+}
+
+// RegisterCloseFunc adds f to what runs before the process exits, whether the job completed, failed or was cancelled.
+// The close funcs run once, most recently registered first
+func (lcm *lifecycleMgr) RegisterCloseFunc(f func()) {
+	lcm.closeFuncsLock.Lock()
+	defer lcm.closeFuncsLock.Unlock()
+	lcm.closeFuncs = append(lcm.closeFuncs, f)
+}
+
+// CloseOnPanic must be deferred (it recovers), so that the close funcs run before a panic in the deferring goroutine
+// ends the process. The panic then carries on
+func (lcm *lifecycleMgr) CloseOnPanic() {
+	if r := recover(); r != nil {
+		lcm.runCloseFuncs()
+		panic(r)
+	}
+}
+
+func (lcm *lifecycleMgr) runCloseFuncs() {
+	lcm.closeFuncsLock.Lock()
+	closeFuncs := lcm.closeFuncs
+	lcm.closeFuncs = nil
+	lcm.closeFuncsLock.Unlock()
+
+	for i := len(closeFuncs) - 1; i >= 0; i-- {
+		closeFuncs[i]()
+	}
+}
+
 // for the lifecycleMgr to babysit a job, it must be given a controller to get information about the job
 type WorkController interface {
 	Cancel(mgr LifecycleMgr)               // handle to cancel the work
@@ -441,8 +561,11 @@ func (lcm *lifecycleMgr) InitiateProgressReporting(jc WorkController) {
 	// this go routine never returns
 	// it will terminate the whole process eventually when the work is complete
 	go func() {
-		// cancelChannel will be notified when os receives os.Interrupt and os.Kill signals
-		signal.Notify(lcm.cancelChannel, os.Interrupt, os.Kill)
+		// cancelChannel will be notified when os receives os.Interrupt and os.Kill signals,
+		// unless AzCopy is embedded in another program, whose signals they are
+		if lcm.getSink() == nil {
+			signal.Notify(lcm.cancelChannel, os.Interrupt, os.Kill)
+		}
 
 		for {
 			select {
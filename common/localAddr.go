@@ -0,0 +1,98 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go"
+)
+
+// GlobalLocalAddr is what the connections of the HTTP clients that AzCopy makes are bound to, or nil to leave it to the routing.
+// It's set once, at startup, from the command line.
+// Since Go only dials the addresses of a host that are of the family of the local address, it also decides whether IPv4 or IPv6 is used.
+var GlobalLocalAddr *net.TCPAddr
+
+// NewLocalAddr returns what the connections are bound to, for the given options, or nil if they are the defaults.
+// localAddress is an IP address that must be assigned to one of the interfaces of the machine, which assigned lists,
+// and ipVersion is 4, 6 or auto (or empty, for auto). Without a local address, 4 and 6 bind to the unspecified address of their family,
+// which the routing picks the source address for, as it does when nothing is bound to
+func NewLocalAddr(localAddress string, ipVersion string, assigned func() ([]net.Addr, error)) (*net.TCPAddr, error) {
+	if ipVersion != "" && ipVersion != "auto" && ipVersion != "4" && ipVersion != "6" {
+		return nil, fmt.Errorf("invalid IP version '%s'. The choices include: 4, 6, auto", ipVersion)
+	}
+
+	if localAddress == "" {
+		switch ipVersion {
+		case "4":
+			return &net.TCPAddr{IP: net.IPv4zero}, nil
+		case "6":
+			return &net.TCPAddr{IP: net.IPv6unspecified}, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	ip := net.ParseIP(localAddress)
+	if ip == nil {
+		return nil, fmt.Errorf("'%s' is not an IP address", localAddress)
+	}
+	if isIPv4 := ip.To4() != nil; (ipVersion == "4" && !isIPv4) || (ipVersion == "6" && isIPv4) {
+		return nil, fmt.Errorf("the local address %s is not an IPv%s address", localAddress, ipVersion)
+	}
+
+	addrs, err := assigned()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the addresses of the network interfaces: %s", err.Error())
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return &net.TCPAddr{IP: ip}, nil
+		}
+	}
+	return nil, fmt.Errorf("the local address %s is not assigned to any network interface of this machine", localAddress)
+}
+
+// BindToLocalAddr makes the dialer bind its connections to GlobalLocalAddr, if it's set
+func BindToLocalAddr(d *net.Dialer) *net.Dialer {
+	if GlobalLocalAddr != nil {
+		d.LocalAddr = GlobalLocalAddr
+	}
+	return d
+}
+
+// SetGlobalLocalAddr makes addr what the connections of AzCopy's HTTP clients are bound to, including those of the libraries
+// that use http.DefaultTransport (such as the refreshing of OAuth tokens) and the S3 client
+func SetGlobalLocalAddr(addr *net.TCPAddr) {
+	GlobalLocalAddr = addr
+	if addr == nil {
+		return
+	}
+	for _, rt := range []http.RoundTripper{http.DefaultTransport, minio.DefaultTransport} {
+		if t, ok := rt.(*http.Transport); ok {
+			// as the dialers of those transports are made
+			t.DialContext = BindToLocalAddr(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, DualStack: true}).DialContext
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+)
+
+const defaultLogFileMaxSizeMB = 1024
+const defaultMaxRotatedLogFiles = 10
+
+// LogRotationSettings controls when a job log is rolled over, and how many of the old logs are kept
+type LogRotationSettings struct {
+	MaxFileSizeBytes int64
+	MaxRotatedFiles  int
+}
+
+// NewLogRotationSettings gets the rotation settings from the command line values, if they are set (i.e. non-zero),
+// otherwise from the environment variables AZCOPY_LOG_FILE_MAX_SIZE_MB and AZCOPY_LOG_FILE_MAX_ROTATED
+func NewLogRotationSettings(cmdLineMaxSizeMB uint32, cmdLineMaxRotatedFiles uint32) LogRotationSettings {
+	lcm := GetLifecycleMgr()
+	s := LogRotationSettings{
+		MaxFileSizeBytes: defaultLogFileMaxSizeMB * 1024 * 1024,
+		MaxRotatedFiles:  defaultMaxRotatedLogFiles,
+	}
+
+	if cmdLineMaxSizeMB > 0 {
+		s.MaxFileSizeBytes = int64(cmdLineMaxSizeMB) * 1024 * 1024
+	} else if v := lcm.GetEnvironmentVariable(EEnvironmentVariable.LogFileMaxSizeMB()); v != "" {
+		if mb, err := strconv.ParseInt(v, 10, 64); err == nil && mb > 0 {
+			s.MaxFileSizeBytes = mb * 1024 * 1024
+		} else {
+			lcm.Info(fmt.Sprintf("Ignoring invalid value '%s' for %s. The default of %d MB is used instead.",
+				v, EEnvironmentVariable.LogFileMaxSizeMB().Name, defaultLogFileMaxSizeMB))
+		}
+	}
+
+	if cmdLineMaxRotatedFiles > 0 {
+		s.MaxRotatedFiles = int(cmdLineMaxRotatedFiles)
+	} else if v := lcm.GetEnvironmentVariable(EEnvironmentVariable.LogFileMaxRotated()); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			s.MaxRotatedFiles = n
+		} else {
+			lcm.Info(fmt.Sprintf("Ignoring invalid value '%s' for %s. The default of %d files is used instead.",
+				v, EEnvironmentVariable.LogFileMaxRotated().Name, defaultMaxRotatedLogFiles))
+		}
+	}
+
+	return s
+}
+
+// JobLogFileName is the name of the log file that is currently being written for the given job.
+// Older content of the same job is found in <jobID>.1.log (the most recent), <jobID>.2.log etc.
+func JobLogFileName(jobID JobID) string {
+	return jobID.String() + ".log"
+}
+
+// JobLogFilePath is the full path of the log file that is currently being written for the given job
+func JobLogFilePath(logFileFolder string, jobID JobID) string {
+	return logFileFolder + OS_PATH_SEPARATOR + JobLogFileName(jobID)
+}
+
+func rotatedJobLogFileName(jobID JobID, generation int) string {
+	return fmt.Sprintf("%s.%d.log", jobID.String(), generation)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// rotatingLogFile is the sink of the job logger. It keeps track of how much has been written,
+// so that the logger can roll it over. It's not thread safe, and is only used from the logger's writer goroutine.
+type rotatingLogFile struct {
+	jobID    JobID
+	folder   string
+	settings LogRotationSettings
+	file     *os.File
+	size     int64
+}
+
+func newRotatingLogFile(jobID JobID, folder string, settings LogRotationSettings) *rotatingLogFile {
+	return &rotatingLogFile{jobID: jobID, folder: folder, settings: settings}
+}
+
+func (r *rotatingLogFile) open() error {
+	// append, since in the case of resume the log of the earlier run(s) is already there
+	file, err := os.OpenFile(path.Join(r.folder, JobLogFileName(r.jobID)), os.O_RDWR|os.O_CREATE|os.O_APPEND, DEFAULT_FILE_PERM)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingLogFile) Write(p []byte) (int, error) {
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingLogFile) needsRotation() bool {
+	return r.settings.MaxFileSizeBytes > 0 && r.size >= r.settings.MaxFileSizeBytes
+}
+
+// rotate closes the current file, shifts <jobID>.k.log to <jobID>.k+1.log (deleting any that would go beyond the
+// retention count), renames the current file to <jobID>.1.log and then starts a new, empty, current file.
+// Even if the shifting fails, the current file is reopened so that logging can continue.
+func (r *rotatingLogFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	shiftErr := r.shiftRotatedFiles()
+	if err := r.open(); err != nil {
+		return err
+	}
+	return shiftErr
+}
+
+func (r *rotatingLogFile) shiftRotatedFiles() error {
+	oldest := path.Join(r.folder, rotatedJobLogFileName(r.jobID, r.settings.MaxRotatedFiles))
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for g := r.settings.MaxRotatedFiles - 1; g >= 1; g-- {
+		from := path.Join(r.folder, rotatedJobLogFileName(r.jobID, g))
+		if err := os.Rename(from, path.Join(r.folder, rotatedJobLogFileName(r.jobID, g+1))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path.Join(r.folder, JobLogFileName(r.jobID)), path.Join(r.folder, rotatedJobLogFileName(r.jobID, 1)))
+}
+
+func (r *rotatingLogFile) close() error {
+	return r.file.Close()
+}
@@ -21,13 +21,15 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
-	"path"
+	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -48,6 +50,31 @@ type ILoggerResetable interface {
 	OpenLog()
 	MinimumLogLevel() pipeline.LogLevel
 	ILoggerCloser
+	IStructuredLogger
+}
+
+// IStructuredLogger is implemented by loggers that can keep the context of a message (e.g. which transfer it is about)
+// in fields of its own, when the log is written in a structured format
+type IStructuredLogger interface {
+	LogFormat() LogFormat
+	LogStructured(level pipeline.LogLevel, entry LogEntry)
+}
+
+// LogEntry is one line of a job log that is in the JSON format.
+// The logger fills in the timestamp, level and job ID; the other fields are optional, apart from the message.
+type LogEntry struct {
+	Timestamp     time.Time   `json:"timestamp"`
+	Level         string      `json:"level"`
+	JobID         string      `json:"jobID"`
+	PartNum       *PartNumber `json:"partNum,omitempty"`
+	TransferIndex *uint32     `json:"transferIndex,omitempty"`
+	Source        string      `json:"source,omitempty"`      // must already be redacted
+	Destination   string      `json:"destination,omitempty"` // must already be redacted
+	RequestID     string      `json:"requestID,omitempty"`
+	// the x-ms-client-request-id of the request, whose prefix correlates it with the job
+	ClientRequestID string `json:"clientRequestID,omitempty"`
+	Message         string `json:"message"`
+	ErrorCode       string `json:"errorCode,omitempty"`
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -107,14 +134,25 @@ type jobLogger struct {
 	// any message with severity higher than this will be ignored.
 	jobID             JobID
 	minimumLevelToLog pipeline.LogLevel // The maximum customer-desired log level for this job
-	file              *os.File          // The job's log file
+	file              *rotatingLogFile  // The job's log file, which is rolled over when it gets too big
 	logFileFolder     string            // The log file's parent folder, needed for opening the file at the right place
-	logger            *log.Logger       // The Job's logger
+	rotation          LogRotationSettings
+	logFormat         LogFormat
+	logger            *log.Logger // The Job's logger
 	appLogger         ILogger
+	systemLogger      ISystemLogger // nil unless warnings and errors are also sent to the OS log
 	sanitizer         pipeline.LogSanitizer
+
+	// Messages are handed to a single writer goroutine, so that the callers (which are usually transfer goroutines)
+	// never wait for file IO, and in particular never wait for a rotation of the log.
+	// The lock protects the channel against being closed while a message is being sent to it.
+	unsavedEntries chan string
+	writerDone     chan struct{}
+	closeLock      sync.RWMutex
+	closed         bool
 }
 
-func NewJobLogger(jobID JobID, minimumLevelToLog LogLevel, appLogger ILogger, logFileFolder string) ILoggerResetable {
+func NewJobLogger(jobID JobID, minimumLevelToLog LogLevel, appLogger ILogger, logFileFolder string, rotation LogRotationSettings, logFormat LogFormat, systemLogger ISystemLogger) ILoggerResetable {
 	if appLogger == nil {
 		panic("You must pass a appLogger when creating a JobLogger")
 	}
@@ -124,6 +162,9 @@ func NewJobLogger(jobID JobID, minimumLevelToLog LogLevel, appLogger ILogger, lo
 		appLogger:         appLogger, // Panics are recorded in the job log AND in the app log
 		minimumLevelToLog: minimumLevelToLog.ToPipelineLogLevel(),
 		logFileFolder:     logFileFolder,
+		rotation:          rotation,
+		logFormat:         logFormat,
+		systemLogger:      systemLogger,
 		sanitizer:         NewAzCopyLogSanitizer(),
 	}
 }
@@ -133,22 +174,63 @@ func (jl *jobLogger) OpenLog() {
 		return
 	}
 
-	file, err := os.OpenFile(path.Join(jl.logFileFolder, jl.jobID.String()+".log"),
-		os.O_RDWR|os.O_CREATE|os.O_APPEND, DEFAULT_FILE_PERM)
-	PanicIfErr(err)
+	// a resumed job opens its log again, so finish with the earlier writer first
+	jl.stopWriter("")
 
-	jl.file = file
+	jl.file = newRotatingLogFile(jl.jobID, jl.logFileFolder, jl.rotation)
+	PanicIfErr(jl.file.open())
 
 	flags := log.LstdFlags | log.LUTC
+	if jl.logFormat == ELogFormat.Json() {
+		flags = 0 // the timestamp is a field of the JSON object instead
+	}
+	jl.logger = log.New(jl.file, "", flags)
+	jl.logHeader()
+
+	jl.closeLock.Lock()
+	jl.unsavedEntries = make(chan string, 100000)
+	jl.writerDone = make(chan struct{})
+	jl.closed = false
+	jl.closeLock.Unlock()
+	go jl.main()
+}
+
+// logHeader is written at the start of every log file, including the ones that are started by a rotation,
+// so that each file can be understood on its own
+func (jl *jobLogger) logHeader() {
 	utcMessage := fmt.Sprintf("Log times are in UTC. Local time is " + time.Now().Format("2 Jan 2006 15:04:05"))
 
-	jl.logger = log.New(jl.file, "", flags)
 	// Log the Azcopy Version
-	jl.logger.Println("AzcopyVersion ", AzcopyVersion)
+	jl.writeLine("AzcopyVersion  " + AzcopyVersion)
 	// Log the OS Environment and OS Architecture
-	jl.logger.Println("OS-Environment ", runtime.GOOS)
-	jl.logger.Println("OS-Architecture ", runtime.GOARCH)
-	jl.logger.Println(utcMessage)
+	jl.writeLine("OS-Environment  " + runtime.GOOS)
+	jl.writeLine("OS-Architecture  " + runtime.GOARCH)
+	jl.writeLine(utcMessage)
+}
+
+// writeLine writes a message of the logger itself. It must only be called by the goroutine that owns the file.
+func (jl *jobLogger) writeLine(msg string) {
+	jl.logger.Println(jl.formatLine(pipeline.LogInfo, LogEntry{Message: msg}))
+}
+
+// main is the only goroutine that writes to the file, so it's also where the file is rotated
+func (jl *jobLogger) main() {
+	defer close(jl.writerDone)
+
+	for msg := range jl.unsavedEntries {
+		jl.logger.Println(msg)
+
+		if jl.file.needsRotation() {
+			jl.writeLine("Log file has reached its maximum size. Continuing in a new log file.")
+			if err := jl.file.rotate(); err != nil {
+				// keep going in whatever file we have, since losing the log would be worse than having a big one
+				jl.writeLine("Failed to rotate the log file: " + err.Error())
+				continue
+			}
+			jl.logHeader()
+			jl.writeLine(fmt.Sprintf("This log continues from %s", rotatedJobLogFileName(jl.jobID, 1)))
+		}
+	}
 }
 
 func (jl *jobLogger) MinimumLogLevel() pipeline.LogLevel {
@@ -167,31 +249,111 @@ func (jl *jobLogger) CloseLog() {
 		return
 	}
 
-	jl.logger.Println("Closing Log")
-	err := jl.file.Close()
+	jl.stopWriter("Closing Log")
+}
+
+// stopWriter lets the writer goroutine drain everything that was sent before the call, and then closes the file.
+// It does nothing if the log is not open.
+func (jl *jobLogger) stopWriter(finalMessage string) {
+	jl.closeLock.Lock()
+	if jl.closed || jl.unsavedEntries == nil {
+		jl.closeLock.Unlock()
+		return
+	}
+	jl.closed = true
+	close(jl.unsavedEntries)
+	jl.closeLock.Unlock()
+
+	<-jl.writerDone
+
+	if finalMessage != "" {
+		jl.writeLine(finalMessage)
+	}
+	err := jl.file.close()
 	PanicIfErr(err)
 }
 
-func (jl jobLogger) Log(loglevel pipeline.LogLevel, msg string) {
-	// If the logger for Job is not initialized i.e file is not open
-	// or logger instance is not initialized, then initialize it
+// enqueue hands the message to the writer goroutine. Messages that arrive after the log is closed are dropped.
+func (jl *jobLogger) enqueue(msg string) {
+	jl.closeLock.RLock()
+	defer jl.closeLock.RUnlock()
+
+	if jl.closed || jl.unsavedEntries == nil {
+		return
+	}
+	jl.unsavedEntries <- msg
+}
+
+func (jl *jobLogger) LogFormat() LogFormat {
+	return jl.logFormat
+}
 
+func (jl *jobLogger) Log(loglevel pipeline.LogLevel, msg string) {
+	jl.logToSystemLog(loglevel, msg)
+	if jl.ShouldLog(loglevel) {
+		// messages that come without any context, such as those of the request log policy, are still wrapped into
+		// an entry of their own in the JSON format (rather than written as multi-line text), and get the request ID if it's in there
+		entry := LogEntry{Message: msg}
+		if jl.logFormat == ELogFormat.Json() {
+			entry.RequestID = requestIDFromLogMessage(msg)
+		}
+		jl.enqueue(jl.formatLine(loglevel, entry))
+	}
+}
+
+// LogStructured logs the entry with all its fields in the JSON format, and just its message in the text format
+func (jl *jobLogger) LogStructured(loglevel pipeline.LogLevel, entry LogEntry) {
+	jl.logToSystemLog(loglevel, entry.Message)
+	if jl.ShouldLog(loglevel) {
+		jl.enqueue(jl.formatLine(loglevel, entry))
+	}
+}
+
+// logToSystemLog sends warnings and errors to the OS log too, whatever the level of the job log
+func (jl *jobLogger) logToSystemLog(loglevel pipeline.LogLevel, msg string) {
+	if jl.systemLogger != nil && loglevel != pipeline.LogNone && loglevel <= pipeline.LogWarning {
+		jl.systemLogger.Log(loglevel, fmt.Sprintf("Job %s: %s", jl.jobID.String(), msg))
+	}
+}
+
+// formatLine turns the entry into the line to be written, in the format of this log
+func (jl *jobLogger) formatLine(loglevel pipeline.LogLevel, entry LogEntry) string {
 	// ensure all secrets are redacted
-	msg = jl.sanitizer.SanitizeLogMessage(msg)
+	msg := jl.sanitizer.SanitizeLogMessage(entry.Message)
+
+	if jl.logFormat == ELogFormat.Json() {
+		entry.Timestamp = time.Now().UTC()
+		entry.Level = LogLevel(loglevel).String()
+		entry.JobID = jl.jobID.String()
+		entry.Message = msg
+		if b, err := json.Marshal(entry); err == nil {
+			return string(b)
+		}
+		// the entry only has simple fields, so that can't really happen, but if it does the message must still get logged
+	}
 
 	// Go, and therefore the sdk, defaults to \n for line endings, so if the platform has a different line ending,
 	// we should replace them to ensure readability on the given platform.
 	if lineEnding != "\n" {
 		msg = strings.Replace(msg, "\n", lineEnding, -1)
 	}
-	if jl.ShouldLog(loglevel) {
-		jl.logger.Println(msg)
+	return msg
+}
+
+// the request log policy writes the request ID header as part of the response
+var requestIDInLogMessageRegex = regexp.MustCompile(`X-Ms-Request-Id: \[?([0-9a-fA-F-]+)`)
+
+func requestIDFromLogMessage(msg string) string {
+	if m := requestIDInLogMessageRegex.FindStringSubmatch(msg); m != nil {
+		return m[1]
 	}
+	return ""
 }
 
-func (jl jobLogger) Panic(err error) {
-	jl.logger.Println(err)  // We do NOT panic here as the app would terminate; we just log it
-	jl.appLogger.Panic(err) // We panic here that it logs and the app terminates
+func (jl *jobLogger) Panic(err error) {
+	jl.logToSystemLog(pipeline.LogPanic, err.Error())
+	jl.enqueue(jl.formatLine(pipeline.LogPanic, LogEntry{Message: err.Error()})) // We do NOT panic here as the app would terminate; we just log it
+	jl.appLogger.Panic(err)                                                      // We panic here that it logs and the app terminates
 	// We should never reach this line of code!
 }
 
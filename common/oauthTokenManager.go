@@ -75,27 +75,30 @@ func NewUserOAuthTokenManagerInstance(credCacheOptions CredCacheOptions) *UserOA
 }
 
 func newAzcopyHTTPClient() *http.Client {
-	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: GlobalProxyLookup,
-			// We use Dial instead of DialContext as DialContext has been reported to cause slower performance.
-			Dial /*Context*/ : (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).Dial, /*Context*/
-			MaxIdleConns:           0, // No limit
-			MaxIdleConnsPerHost:    1000,
-			IdleConnTimeout:        180 * time.Second,
-			TLSHandshakeTimeout:    10 * time.Second,
-			ExpectContinueTimeout:  1 * time.Second,
-			DisableKeepAlives:      false,
-			DisableCompression:     true,
-			MaxResponseHeaderBytes: 0,
-			//ResponseHeaderTimeout:  time.Duration{},
-			//ExpectContinueTimeout:  time.Duration{},
-		},
-	}
+	dialer := BindToLocalAddr(&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	})
+	proxyTunnel := NewProxyTunnel(dialer.DialContext, 10*time.Second)
+	transport := &http.Transport{
+		Proxy: proxyTunnel.Proxy,
+		// We use Dial instead of DialContext as DialContext has been reported to cause slower performance.
+		Dial /*Context*/ :      dialer.Dial, /*Context*/
+		DialTLSContext:         proxyTunnel.DialTLSContext,
+		MaxIdleConns:           0, // No limit
+		MaxIdleConnsPerHost:    1000,
+		IdleConnTimeout:        180 * time.Second,
+		TLSHandshakeTimeout:    10 * time.Second,
+		ExpectContinueTimeout:  1 * time.Second,
+		DisableKeepAlives:      false,
+		DisableCompression:     true,
+		MaxResponseHeaderBytes: 0,
+		//ResponseHeaderTimeout:  time.Duration{},
+		//ExpectContinueTimeout:  time.Duration{},
+	}
+	GlobalTransportSettings.Apply(transport)
+	return &http.Client{Transport: transport}
 }
 
 // GetTokenInfo gets token info, it follows rule:
@@ -164,7 +167,7 @@ func secretLoginNoUOTM(tenantID, activeDirectoryEndpoint, secret, applicationID
 	}
 
 	if activeDirectoryEndpoint == "" {
-		activeDirectoryEndpoint = DefaultActiveDirectoryEndpoint
+		activeDirectoryEndpoint = DefaultActiveDirectoryEndpointForLogin()
 	}
 
 	if applicationID == "" {
@@ -269,7 +272,7 @@ func certLoginNoUOTM(tenantID, activeDirectoryEndpoint, certPath, certPass, appl
 	}
 
 	if activeDirectoryEndpoint == "" {
-		activeDirectoryEndpoint = DefaultActiveDirectoryEndpoint
+		activeDirectoryEndpoint = DefaultActiveDirectoryEndpointForLogin()
 	}
 
 	if applicationID == "" {
@@ -426,7 +429,7 @@ func (uotm *UserOAuthTokenManager) UserLogin(tenantID, activeDirectoryEndpoint s
 		tenantID = DefaultTenantID
 	}
 	if activeDirectoryEndpoint == "" {
-		activeDirectoryEndpoint = DefaultActiveDirectoryEndpoint
+		activeDirectoryEndpoint = DefaultActiveDirectoryEndpointForLogin()
 	}
 
 	// Init OAuth config
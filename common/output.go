@@ -2,6 +2,7 @@ package common
 
 import (
 	"encoding/json"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"time"
@@ -26,6 +27,7 @@ func (outputMessageType) EndOfJob() outputMessageType { return outputMessageType
 
 func (outputMessageType) Error() outputMessageType  { return outputMessageType(4) } // indicate fatal error, exit right after
 func (outputMessageType) Prompt() outputMessageType { return outputMessageType(5) } // ask the user a question after erasing the progress
+func (outputMessageType) Event() outputMessageType  { return outputMessageType(6) } // something that happened to one object, allowed to float up
 
 func (o outputMessageType) String() string {
 	return enum.StringInt(o, reflect.TypeOf(o))
@@ -38,6 +40,7 @@ type outputMessage struct {
 	exitCode      ExitCode      // only for when the application is meant to exit after printing (i.e. Error or Final)
 	inputChannel  chan<- string // support getting a response from the user
 	promptDetails PromptDetails
+	eventName     string // only for events, whose JSON message type it is
 }
 
 func (m outputMessage) shouldExitProcess() bool {
@@ -62,6 +65,8 @@ type PromptType string
 func (PromptType) Cancel() PromptType            { return PromptType("Cancel") }
 func (PromptType) Overwrite() PromptType         { return PromptType("Overwrite") }
 func (PromptType) DeleteDestination() PromptType { return PromptType("DeleteDestination") }
+func (PromptType) LowSpace() PromptType          { return PromptType("LowSpace") }
+func (PromptType) LogInAgain() PromptType        { return PromptType("LogInAgain") }
 
 // -------------------------------------- JSON templates -------------------------------------- //
 // used to help formatting of JSON outputs
@@ -86,19 +91,30 @@ func newJsonOutputTemplate(messageType outputMessageType, messageContent string,
 		MessageContent: messageContent, PromptDetails: promptDetails}
 }
 
+// newJsonEventTemplate is the template of an event, whose message type is the name of the event rather than "Event",
+// so that each kind of event can be told apart without looking into its content
+func newJsonEventTemplate(eventName string, messageContent string) *jsonOutputTemplate {
+	return &jsonOutputTemplate{TimeStamp: time.Now(), MessageType: eventName, MessageContent: messageContent}
+}
+
 type InitMsgJsonTemplate struct {
 	LogFileLocation string
+	LogDirectory    string
 	JobID           string
 	IsCleanupJob    bool
+	// what the x-ms-client-request-id of each request of the job starts with
+	ClientRequestIDPrefix string `json:",omitempty"`
 }
 
-func GetStandardInitOutputBuilder(jobID string, logFileLocation string, isCleanupJob bool, cleanupMessage string) OutputBuilder {
+func GetStandardInitOutputBuilder(jobID string, logFileLocation string, isCleanupJob bool, cleanupMessage string, clientRequestIDPrefix string) OutputBuilder {
 	return func(format OutputFormat) string {
 		if format == EOutputFormat.Json() {
 			return GetJsonStringFromTemplate(InitMsgJsonTemplate{
-				JobID:           jobID,
-				LogFileLocation: logFileLocation,
-				IsCleanupJob:    isCleanupJob,
+				JobID:                 jobID,
+				LogFileLocation:       logFileLocation,
+				LogDirectory:          filepath.Dir(logFileLocation),
+				IsCleanupJob:          isCleanupJob,
+				ClientRequestIDPrefix: clientRequestIDPrefix,
 			})
 		}
 
@@ -111,6 +127,11 @@ func GetStandardInitOutputBuilder(jobID string, logFileLocation string, isCleanu
 			sb.WriteString("\nJob " + jobID + " has started\n")
 			sb.WriteString("Log file is located at: " + logFileLocation)
 			sb.WriteString("\n")
+			sb.WriteString("Log directory is: " + filepath.Dir(logFileLocation) + " (if the log grows beyond its max size, older parts of it are moved to " + jobID + ".1.log, " + jobID + ".2.log etc.)")
+			sb.WriteString("\n")
+			if clientRequestIDPrefix != "" {
+				sb.WriteString("Client request ID prefix (for support requests): " + clientRequestIDPrefix + "\n")
+			}
 		}
 		return sb.String()
 	}
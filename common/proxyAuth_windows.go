@@ -0,0 +1,149 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"encoding/base64"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var dSecur32 = syscall.NewLazyDLL("secur32.dll")
+
+// Refer to https://docs.microsoft.com/en-us/windows/win32/api/sspi/nf-sspi-acquirecredentialshandlew for more details.
+var mAcquireCredentialsHandle = dSecur32.NewProc("AcquireCredentialsHandleW")
+
+// Refer to https://docs.microsoft.com/en-us/windows/win32/secauthn/initializesecuritycontext--general for more details.
+var mInitializeSecurityContext = dSecur32.NewProc("InitializeSecurityContextW")
+
+var mDeleteSecurityContext = dSecur32.NewProc("DeleteSecurityContext")
+var mFreeCredentialsHandle = dSecur32.NewProc("FreeCredentialsHandle")
+
+const (
+	secpkgCredOutbound = 0x2
+	securityNativeDrep = 0x10
+	secbufferVersion   = 0
+	secbufferToken     = 2
+	secEOK             = 0
+	secIContinueNeeded = 0x00090312
+	sspiMaxTokenSize   = 64 * 1024 // more than the largest cbMaxToken of Negotiate, which can carry a Kerberos ticket
+	iscReqConnection   = 0x800
+)
+
+type secHandle struct {
+	lower uintptr
+	upper uintptr
+}
+
+type secBuffer struct {
+	cbBuffer   uint32
+	bufferType uint32
+	pvBuffer   *byte
+}
+
+type secBufferDesc struct {
+	ulVersion uint32
+	cBuffers  uint32
+	pBuffers  *secBuffer
+}
+
+// sspiProxyAuthenticator does Negotiate or NTLM with the credentials of the current Windows user, through SSPI
+type sspiProxyAuthenticator struct {
+	pkg        string
+	target     string
+	credential secHandle
+	context    secHandle
+	hasContext bool
+}
+
+// newWindowsProxyAuthenticator returns an authenticator for Negotiate if the proxy offers it, or else NTLM, or nil if it offers neither
+func newWindowsProxyAuthenticator(challenges []string, proxyHost string) (proxyAuthenticator, error) {
+	for _, pkg := range []string{"Negotiate", "NTLM"} {
+		if _, ok := findProxyChallenge(challenges, pkg); !ok {
+			continue
+		}
+
+		a := &sspiProxyAuthenticator{pkg: pkg, target: "HTTP/" + proxyHost}
+		pkgName, err := syscall.UTF16PtrFromString(pkg)
+		if err != nil {
+			return nil, err
+		}
+		var expiry int64
+		if r, _, _ := mAcquireCredentialsHandle.Call(0, uintptr(unsafe.Pointer(pkgName)), secpkgCredOutbound, 0, 0, 0, 0,
+			uintptr(unsafe.Pointer(&a.credential)), uintptr(unsafe.Pointer(&expiry))); r != secEOK {
+			return nil, fmt.Errorf("cannot get the credentials of the current user for %s: %s", pkg, syscall.Errno(r).Error())
+		}
+		return a, nil
+	}
+	return nil, nil
+}
+
+func (a *sspiProxyAuthenticator) scheme() string {
+	return a.pkg
+}
+
+func (a *sspiProxyAuthenticator) respond(challengeToken string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(a.target)
+	if err != nil {
+		return "", err
+	}
+
+	var in *secBufferDesc
+	if challengeToken != "" {
+		token, err := base64.StdEncoding.DecodeString(challengeToken)
+		if err != nil || len(token) == 0 {
+			return "", fmt.Errorf("the %s challenge of the proxy is not valid", a.pkg)
+		}
+		in = &secBufferDesc{
+			ulVersion: secbufferVersion,
+			cBuffers:  1,
+			pBuffers:  &secBuffer{cbBuffer: uint32(len(token)), bufferType: secbufferToken, pvBuffer: &token[0]},
+		}
+	}
+
+	outToken := make([]byte, sspiMaxTokenSize)
+	outBuffer := secBuffer{cbBuffer: uint32(len(outToken)), bufferType: secbufferToken, pvBuffer: &outToken[0]}
+	out := secBufferDesc{ulVersion: secbufferVersion, cBuffers: 1, pBuffers: &outBuffer}
+
+	var existingContext uintptr
+	if a.hasContext {
+		existingContext = uintptr(unsafe.Pointer(&a.context))
+	}
+	var attributes uint32
+	var expiry int64
+	r, _, _ := mInitializeSecurityContext.Call(uintptr(unsafe.Pointer(&a.credential)), existingContext, uintptr(unsafe.Pointer(target)),
+		iscReqConnection, 0, securityNativeDrep, uintptr(unsafe.Pointer(in)), 0,
+		uintptr(unsafe.Pointer(&a.context)), uintptr(unsafe.Pointer(&out)), uintptr(unsafe.Pointer(&attributes)), uintptr(unsafe.Pointer(&expiry)))
+	if r != secEOK && r != secIContinueNeeded {
+		return "", fmt.Errorf("%s: %s", a.pkg, syscall.Errno(r).Error())
+	}
+	a.hasContext = true
+
+	return a.pkg + " " + base64.StdEncoding.EncodeToString(outToken[:outBuffer.cbBuffer]), nil
+}
+
+func (a *sspiProxyAuthenticator) close() {
+	if a.hasContext {
+		mDeleteSecurityContext.Call(uintptr(unsafe.Pointer(&a.context)))
+	}
+	mFreeCredentialsHandle.Call(uintptr(unsafe.Pointer(&a.credential)))
+}
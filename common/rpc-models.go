@@ -1,6 +1,7 @@
 package common
 
 import (
+	"fmt"
 	"reflect"
 	"time"
 
@@ -23,6 +24,8 @@ func (RpcCmd) CancelJob() RpcCmd          { return RpcCmd("Cancel") }
 func (RpcCmd) PauseJob() RpcCmd           { return RpcCmd("PauseJob") }
 func (RpcCmd) ResumeJob() RpcCmd          { return RpcCmd("ResumeJob") }
 func (RpcCmd) GetJobFromTo() RpcCmd       { return RpcCmd("GetJobFromTo") }
+func (RpcCmd) SetJobLimits() RpcCmd       { return RpcCmd("SetJobLimits") }
+func (RpcCmd) RenewCredential() RpcCmd    { return RpcCmd("RenewCredential") }
 
 func (c RpcCmd) String() string {
 	return enum.String(c, reflect.TypeOf(c))
@@ -61,11 +64,71 @@ type CopyJobPartOrderRequest struct {
 	// commandString hold the user given command which is logged to the Job log file
 	CommandString  string
 	CredentialInfo CredentialInfo
+	// if set, the timings of each transfer are written to this file. Like the credential, it's not saved in the plan file
+	MetricsFile string
+	// if set, the checksum of each file is written to this file, or, if VerifyChecksums is set, each file is verified against the checksum
+	// that this file has for it. Like the metrics file, it's not saved in the plan file
+	ChecksumFile    string
+	VerifyChecksums bool
 
 	S2SGetPropertiesInBackend      bool
 	S2SSourceChangeValidation      bool
 	DestLengthValidation           bool
 	S2SInvalidMetadataHandleOption InvalidMetadataHandleOption
+	S2SFallback                    S2SFallback
+	S2SComputeMD5                  S2SComputeMD5
+	// if set, only the pages of the source page blobs that changed since this snapshot are copied, into destinations that already hold it
+	DiffBaseSnapshot string
+	// if set, the blocks that an earlier attempt at uploading a block blob staged, but never committed, are kept rather than sent again
+	ReuseUncommittedBlocks bool
+	// if set, a local file that has only grown since it was uploaded to an append blob, or an ADLS Gen2 file, only has its new bytes appended
+	AppendOnly bool
+	// if set, only the window of the (single) source file that starts at DownloadOffset is downloaded. Its length is the size of the transfer
+	RangedDownload bool
+	DownloadOffset int64
+	// if set, the source of each transfer is deleted once the transfer has succeeded
+	DeleteSourceAfterTransfer bool
+	// if set, the archive attribute of each local source file is cleared once the file has been uploaded (Windows only)
+	ClearArchiveBit bool
+	// if set, local files that can't be opened for lack of permission, or (on Windows) because another process holds them open,
+	// are skipped rather than failed
+	SkipPermissionErrors bool
+	SkipLockedFiles      bool
+	// if set, each transfer's content headers and metadata are its own (from an attributes manifest),
+	// and those that are set replace the ones of the job at its destination
+	PerFileAttributes bool
+	// if set, the lease of a destination blob that can't be overwritten or removed because it's leased is broken,
+	// and the write (or the deletion) is done again
+	BreakLeaseOnOverwrite bool
+	// if set, each destination blob (or BlobFS file) is leased while it's written, so that nothing else can write to it at the same time
+	ProtectDestinationWithLease bool
+	// the condition that each write of a whole destination blob (or BlobFS file) must meet. The destinations that don't meet it are skipped
+	DestinationCondition DestinationCondition
+	// if set, a destination Azure file whose ReadOnly attribute is in the way of its overwrite has it cleared, and restored once it's written
+	ForceIfReadOnly bool
+	// how long the writes to a destination Azure file that something else has open over SMB are retried, before its transfer fails. Zero for not at all
+	SharingViolationRetryWindow time.Duration
+	// how long the transfers to an Azure file share that's full wait for its quota to be raised, before they fail. Zero for not at all
+	ShareFullWaitWindow time.Duration
+	// the suffix of the temporary names that ADLS Gen2 and Azure Files destinations are written under, before they're renamed to their own.
+	// Empty if they're written under their own names
+	TempNameSuffix string
+	// the order in which the transfers of each part of the job are scheduled
+	TransferOrder TransferOrder
+	// when the downloaded files are flushed to stable storage, and, if they're flushed as they're written, every how many bytes
+	FlushPolicy        FlushPolicy
+	FlushIntervalBytes int64
+	// the continuation marker of the listing of the source that the enumeration had reached when the part was ordered.
+	// Empty if the listing doesn't have one marker for where it is (e.g. it lists one directory at a time), or it's too long to keep
+	ListingMarker string
+	// what the x-ms-client-request-id of each request of the job starts with (--client-request-id-prefix). Empty for the ID of the job
+	ClientRequestIDPrefix string
+	// what happens to the transfers whose source was deleted after it was enumerated
+	MissingSourceHandling MissingSourceHandling
+	// what happens to the transfers whose destination blob is soft-deleted
+	SoftDeletedDestinationHandling SoftDeletedDestinationHandling
+	// whether the last modified time of each source is kept in the metadata of its destination (--preserve-source-lmt-as-metadata)
+	PreserveSourceLMTAsMetadata bool
 }
 
 // CredentialInfo contains essential credential info which need be transited between modules,
@@ -74,6 +137,10 @@ type CredentialInfo struct {
 	CredentialType   CredentialType
 	OAuthTokenInfo   OAuthTokenInfo
 	S3CredentialInfo S3CredentialInfo
+
+	// S2SSourceCredentialType is OAuthToken if the source of a blob to blob copy has no SAS, and is read by the
+	// destination with the OAuth token instead. In that case OAuthTokenInfo is set, whatever the CredentialType is.
+	S2SSourceCredentialType CredentialType
 }
 
 // S3CredentialInfo contains essential credential info which need to build up S3 client.
@@ -119,6 +186,9 @@ type BlobTransferAttributes struct {
 	MD5ValidationOption      HashValidationOption  // when downloading, how strictly should we validate MD5 hashes?
 	BlockSizeInBytes         uint32                // when uploading/downloading/copying, specify the size of each chunk
 	DeleteSnapshotsOption    DeleteSnapshotsOption // when deleting, specify what to do with the snapshots
+	BlobTagsString           string                // when setting properties, the index tags to apply to the blob
+	SetPropertiesFlags       SetPropertiesFlags    // when setting properties, specify which properties to change
+	HeaderRules              string                // when uploading, the header rules to apply to the files they match, one per line
 }
 
 type JobIDDetails struct {
@@ -139,6 +209,25 @@ type ListContainerResponse struct {
 	Blobs []string
 }
 
+// TransactionCounts counts storage REST operations by the class that the service bills them in
+type TransactionCounts struct {
+	List  uint64 // listing, and creating containers, shares and filesystems
+	Read  uint64
+	Write uint64
+	Other uint64 // e.g. getting properties, deleting, and leasing
+}
+
+func (c TransactionCounts) Total() uint64 {
+	return c.List + c.Read + c.Write + c.Other
+}
+
+func (c *TransactionCounts) Add(other TransactionCounts) {
+	c.List += other.List
+	c.Read += other.Read
+	c.Write += other.Write
+	c.Other += other.Other
+}
+
 // represents the JobProgressPercentage Summary response for list command when requested the Job Progress Summary for given JobId
 type ListJobSummaryResponse struct {
 	ErrorMsg  string
@@ -174,6 +263,9 @@ type ListJobSummaryResponse struct {
 	AverageE2EMilliseconds int
 	ServerBusyPercentage   float32
 	NetworkErrorPercentage float32
+	RetryPercentage        float32       // percentage of requests that got a retryable outcome (network error, 500 or 503)
+	RetryCount             int64         // number of requests that got a retryable outcome
+	RequestCountsByStatus  map[int]int64 // count of responses received, by HTTP status code
 
 	FailedTransfers  []TransferDetail
 	SkippedTransfers []TransferDetail
@@ -182,6 +274,243 @@ type ListJobSummaryResponse struct {
 
 	PerformanceAdvice []PerformanceAdvice
 	IsCleanupJob      bool
+	BenchmarkResults  *BenchmarkResults `json:",omitempty"` // only set for the job that is measured by a benchmark run
+
+	// the number of transfers that were relayed through this machine, since the destination could not read their source.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	TransfersRelayedClientSide uint32 `json:",omitempty"`
+
+	// the number of destination block blobs of a S2S copy whose Content-MD5 is being computed by this machine, after they were written (--s2s-compute-md5),
+	// the number that got one that way, and the number that were left without one.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	HashBackfillsInProgress uint32 `json:",omitempty"`
+	MD5sBackfilled          uint32 `json:",omitempty"`
+	BlobsWithoutMD5         uint32 `json:",omitempty"`
+
+	// when the job deletes the sources of the transfers that succeed, the number of finished transfers whose source was deleted,
+	// and the number whose source was kept, since the transfer failed or was skipped, or the source could not be deleted
+	SourcesDeleted  uint32 `json:",omitempty"`
+	SourcesRetained uint32 `json:",omitempty"`
+
+	// the number of downloaded chunks that were fetched again, since they didn't match the MD5 hash that the service sent with them.
+	// These are not included in RetryCount, which only counts the retries of requests.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	ChunkIntegrityRetries uint32 `json:",omitempty"`
+
+	// the number of throttled responses that said how long to wait before the request was retried (with Retry-After or x-ms-retry-after-ms),
+	// and the total of those waits, after they were capped. Those responses are also included in RetryCount.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	ServerDirectedWaits       int64   `json:",omitempty"`
+	ServerDirectedWaitSeconds float64 `json:",omitempty"`
+
+	// for an incremental copy of page blobs, from the snapshot given by --diff-base-snapshot: the bytes that changed since that snapshot,
+	// and the logical size of the blobs that they belong to.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	DiffChangedBytes uint64 `json:",omitempty"`
+	DiffLogicalBytes uint64 `json:",omitempty"`
+
+	// for an append-only upload: the bytes that were appended to the destinations that already held the start of their files,
+	// and the bytes of the files that had to be uploaded in full, e.g. because they were new, or had shrunk.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	BytesAppended       uint64 `json:",omitempty"`
+	BytesUploadedInFull uint64 `json:",omitempty"`
+
+	// when the transactions per second are capped with --cap-tps: the rate at which requests were sent, and the cap,
+	// which is lower than the one that was asked for while the service throttles the requests.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	AverageTransactionsPerSecond float64 `json:",omitempty"`
+	TransactionsPerSecondCap     int64   `json:",omitempty"`
+
+	// the same, for the transfers that move no data (deletes and setting properties), when they are capped on their own with --cap-metadata-tps.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	AverageMetadataTransactionsPerSecond float64 `json:",omitempty"`
+	MetadataTransactionsPerSecondCap     int64   `json:",omitempty"`
+
+	// the tries of the transfers that move no data and of the others, each with those of them that the service throttled (503 or 429).
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	MetadataRequests          int64 `json:",omitempty"`
+	MetadataRequestsThrottled int64 `json:",omitempty"`
+	DataRequests              int64 `json:",omitempty"`
+	DataRequestsThrottled     int64 `json:",omitempty"`
+
+	// whether the job is holding back the start of new downloads, since the volume they save to has less free space than they need
+	// (beyond AZCOPY_LOW_SPACE_MARGIN_MB). Will be false if read outside the process running the job (e.g. with 'jobs show' command)
+	LowSpacePaused bool `json:",omitempty"`
+
+	// whether the job is holding back new transfers and chunks, since its OAuth token expired and couldn't be refreshed,
+	// and the error that the last refresh failed with. It waits for the token to be renewed (RenewCredential), or for the job to be paused.
+	// Will be false if read outside the process running the job (e.g. with 'jobs show' command)
+	CredentialExpired      bool   `json:",omitempty"`
+	CredentialExpiredError string `json:",omitempty"`
+
+	// whether the job was paused, and has no transfers left in flight, so that it can be resumed.
+	// Will be false if read outside the process running the job (e.g. with 'jobs show' command)
+	PausedJobDrained bool `json:",omitempty"`
+
+	// the storage REST operations that the job's transfers made, including the retries, by the class that the service bills them in.
+	// The listing done by the enumeration isn't included. Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	Transactions TransactionCounts
+
+	// the ServerBusy responses that made (or kept) a storage partition hot, and the number of times a chunk was set aside
+	// because its partition was hot, so that the chunks of the other partitions could go first. They're counted for the whole process.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	PartitionThrottleEvents        uint64 `json:",omitempty"`
+	ChunksDeferredForHotPartitions uint64 `json:",omitempty"`
+
+	// the number of times a transfer to Azure Files was set aside, since its directory had as many transfers in flight as it may
+	// (AZCOPY_CONCURRENT_FILES_PER_DIRECTORY). It's counted for the whole process.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	TransfersDeferredForBusyDirectories uint64 `json:",omitempty"`
+
+	// the lookups of the hosts of the connections, the dials that used the addresses that were cached from an earlier lookup instead,
+	// and the times that cached addresses were forgotten because the dials to them kept failing. They're counted for the whole process.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	DNSResolutions        uint64 `json:",omitempty"`
+	DNSCacheHits          uint64 `json:",omitempty"`
+	DNSStaleAddressesSeen uint64 `json:",omitempty"`
+
+	// when the job verifies the files that it downloads against a checksum file, the number of files in it that were not downloaded.
+	// Only meaningful once the job is done, and zero if read outside the process running the job (e.g. with 'jobs show' command)
+	ChecksumEntriesNotFound uint32 `json:",omitempty"`
+
+	// the number of times that a local source on a network share or mount was reopened and read again, after the share dropped.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	SourceReadRetries uint32 `json:",omitempty"`
+
+	// the number of destination Azure files whose writes were retried, since something else had them open over SMB.
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	FilesRetriedForSharingViolations uint32 `json:",omitempty"`
+
+	// the destination Azure file shares that the job found full, and has kept its transfers away from since, ordered by their URL.
+	// Will be empty if read outside the process running the job (e.g. with 'jobs show' command)
+	FullShares []FullShare `json:",omitempty"`
+
+	// the source and the error of the first transfers that failed, and the error that most of the failed transfers failed with,
+	// without their paths. Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	FirstTransferFailures        []string `json:",omitempty"`
+	DominantTransferFailure      string   `json:",omitempty"`
+	DominantTransferFailureCount uint32   `json:",omitempty"`
+
+	// how many transfers failed with each storage error code (or with none, under NoStorageErrorCode), along with the path of one of them.
+	// Will be empty if read outside the process running the job (e.g. with 'jobs show' command)
+	FailuresByErrorCode map[string]ErrorCodeFailures `json:",omitempty"`
+
+	// the number of the skipped transfers whose destination didn't meet the condition that the job's writes must meet (--destination-condition).
+	// They are included in TransfersSkipped, and listed in SkippedTransfers
+	TransfersSkippedForDestinationCondition uint32 `json:",omitempty"`
+
+	// the number of the skipped transfers whose source was deleted after it was enumerated (--missing-source-handling=skip).
+	// They are included in TransfersSkipped, and listed in SkippedTransfers
+	TransfersSkippedForMissingSource uint32 `json:",omitempty"`
+
+	// the number of the transfers whose destination blob was soft-deleted (--soft-deleted-destination), by what was done about it.
+	// Those that were skipped are included in TransfersSkipped, and listed in SkippedTransfers
+	SoftDeletedDestinationsOverwritten        uint32 `json:",omitempty"`
+	SoftDeletedDestinationsUndeleted          uint32 `json:",omitempty"`
+	TransfersSkippedForSoftDeletedDestination uint32 `json:",omitempty"`
+
+	// when the job was aborted since too many of its transfers failed (--fail-fast-threshold or --fail-fast-rate),
+	// or since it made more transactions than it was allowed to (--max-transactions): why,
+	// and how many transfers were not attempted. Only set by the front end that ran the job, and only once it's done
+	FailFastReason        string `json:",omitempty"`
+	TransfersNotAttempted uint32 `json:",omitempty"`
+
+	// the current bandwidth cap, and the concurrency if it was set while the job runs (with 'jobs set' command).
+	// Will be zero if read outside the process running the job (e.g. with 'jobs show' command)
+	CapMbps     int64 `json:",omitempty"`
+	Concurrency int   `json:",omitempty"`
+
+	// what the x-ms-client-request-id of each request of the job starts with, to give to support, so that they can find the requests of the job
+	ClientRequestIDPrefix string `json:",omitempty"`
+
+	// when the job carried on past the paths it could not enumerate (--continue-on-enumeration-errors): how many there were,
+	// and the file that lists them. Only set by the front end that ran the job, and only once it's done
+	PathsNotEnumerated       uint32 `json:",omitempty"`
+	PathsNotEnumeratedReport string `json:",omitempty"`
+
+	// when the files of the job got their own headers and metadata from an attributes manifest, the keys of the manifest that matched none of them.
+	// Only set by the front end that ran the job, and only once it's done
+	UnmatchedAttributesManifestEntries []string `json:",omitempty"`
+
+	// when the source is a whole account, what was scheduled from each of its containers, and how much of it failed.
+	// Only set by the front end that ran the job, and only once it's done
+	Containers []ContainerSummary `json:",omitempty"`
+
+	// when the destination is sharded across containers (--destination-shards), what went to each of them, ordered by their URL,
+	// and the file that says which shard each path went to. The throughput of the shards and the manifest are only set by the front end
+	// that ran the job, and only once it's done
+	Shards        []ShardSummary `json:",omitempty"`
+	ShardManifest string         `json:",omitempty"`
+
+	// the objects whose names the destination doesn't allow (--invalid-name-handling): how many were left out of the job,
+	// how many were given sanitized names, and the file that maps the original names to the sanitized ones.
+	// Only set by the front end that ran the job, and only once it's done
+	InvalidNamesSkipped   uint32 `json:",omitempty"`
+	NamesSanitized        uint32 `json:",omitempty"`
+	SanitizedNamesMapping string `json:",omitempty"`
+
+	// the number of listing calls of the enumeration that were retried, since the service throttled them,
+	// which tells the pressure on the listing apart from the pressure on the transfers (RetryCount).
+	// Only set by the front end that ran the job, and only once it's done
+	EnumerationRetries uint32 `json:",omitempty"`
+
+	// when the job kept the listing it copied for the next run (--enumeration-cache): how many files were skipped outright,
+	// since they're unchanged since the last run, and whether the cache was deleted, since the job didn't succeed entirely.
+	// Only set by the front end that ran the job, and only once it's done
+	TransfersSkippedByEnumerationCache uint32 `json:",omitempty"`
+	EnumerationCacheDiscarded          bool   `json:",omitempty"`
+
+	// when the downloaded files were flushed to stable storage (--flush-policy), unless they were left to the OS to flush.
+	// Only set by the front end that ran the job, and only once it's done
+	FlushPolicy        string `json:",omitempty"`
+	FlushIntervalBytes int64  `json:",omitempty"`
+
+	// the file that the paths of the failed transfers were written to, as a list of files to run the job again with (--failed-files-output).
+	// Only set by the front end that ran the job, and only once it's done
+	FailedFilesOutput string `json:",omitempty"`
+
+	// the largest of the files that are in flight, if they're big enough for their own progress to be worth showing.
+	// Since their progress is kept in the job's plan files, they're also set when read by 'jobs show' command
+	InFlightTransfers []InFlightTransfer `json:",omitempty"`
+
+	// only set when the job recorded transfer metrics, and only once it's done
+	TransferDurationPercentiles *TransferDurationPercentiles `json:",omitempty"`
+
+	// measured by the front end over the life of the job, so it's only set by the copy and sync commands, and only once the job is done
+	PerformanceReport *JobPerformanceReport `json:",omitempty"`
+
+	// Where the job's log is. Only filled in by the 'jobs show' command
+	LogDirectory    string `json:",omitempty"`
+	LogFileLocation string `json:",omitempty"`
+}
+
+// TransferDurationPercentiles summarizes how long transfers took, from the time a worker picked them up until they were done
+type TransferDurationPercentiles struct {
+	TransferCount   int
+	P50Milliseconds int64
+	P95Milliseconds int64
+	P99Milliseconds int64
+}
+
+// JobPerformanceReport describes how efficiently a job ran
+type JobPerformanceReport struct {
+	ElapsedSeconds float64
+
+	// from the start of the enumeration until all of it had been ordered, and from then until the job was done.
+	// Transfers start while the enumeration is still running, so the time in enumeration includes some transferring too
+	EnumerationSeconds      float64
+	AfterEnumerationSeconds float64
+
+	AverageThroughputMbps float64
+	PeakThroughputMbps    float64 // the highest throughput over the intervals at which the progress was refreshed
+	TotalRetries          int64
+	ChunkIntegrityRetries uint32  // downloaded chunks that were fetched again, since they didn't match their MD5 hash. Not included in TotalRetries
+	ServerBusyCount       int64   // responses with status 503
+	AverageConcurrency    float64 // the average number of active connections, over the intervals at which the progress was refreshed
+
+	// throttled responses that said how long to wait before retrying, and the total of the waits
+	ServerDirectedWaits       int64
+	ServerDirectedWaitSeconds float64
 }
 
 // wraps the standard ListJobSummaryResponse with sync-specific stats
@@ -189,6 +518,8 @@ type ListSyncJobSummaryResponse struct {
 	ListJobSummaryResponse
 	DeleteTotalTransfers     uint32
 	DeleteTransfersCompleted uint32
+	// the files that were not overwritten because they are newer at the destination than at the source
+	FilesSkippedAsDestinationNewer uint64
 }
 
 type ListJobTransfersRequest struct {
@@ -197,25 +528,134 @@ type ListJobTransfersRequest struct {
 }
 
 type ResumeJobRequest struct {
-	JobID           JobID
-	SourceSAS       string
-	DestinationSAS  string
-	IncludeTransfer map[string]int
-	ExcludeTransfer map[string]int
-	CredentialInfo  CredentialInfo
+	JobID          JobID
+	SourceSAS      string
+	DestinationSAS string
+	// the SAS of each of the containers of a sharded destination, by its URL
+	DestinationShardSAS map[string]string
+	IncludeTransfer     map[string]int
+	ExcludeTransfer     map[string]int
+	CredentialInfo      CredentialInfo
+
+	// whether to check the destinations of the transfers that succeeded or failed, and correct their statuses by what's there,
+	// before the job is resumed (--resume-verify), and whether to compare the MD5 hashes too (--resume-verify-strict)
+	VerifyDestinations   bool
+	VerifyDestinationMD5 bool
+}
+
+// ContainerSummary is the part of a job that came from one container of the account that it copies
+type ContainerSummary struct {
+	Name            string
+	Transfers       uint32
+	TransfersFailed uint32
+	Bytes           uint64
+}
+
+// ShardSummary is the part of a job that went to one of the containers that its destination is sharded across
+type ShardSummary struct {
+	Destination      string
+	Transfers        uint32
+	TransfersFailed  uint32
+	BytesTransferred uint64
+	// the bytes transferred to the shard over the time that the job ran
+	ThroughputMbps float64 `json:",omitempty"`
+}
+
+// the code under which the failures that didn't come with a storage error code are counted
+const NoStorageErrorCode = "(no error code)"
+
+// ErrorCodeFailures is how many transfers failed with one storage error code
+type ErrorCodeFailures struct {
+	Count uint32
+	// the HTTP status of the responses that carried the code, if there were any
+	Status      int `json:",omitempty"`
+	ExamplePath string
+}
+
+// FullShare is a destination Azure file share that has reached its quota, as it was when it was last looked at
+type FullShare struct {
+	// without its SAS
+	Share     string
+	UsedBytes int64
+	// zero if the usage and the quota of the share couldn't be looked up
+	QuotaGiB int32
+	// since when the share is full
+	Since time.Time
+}
+
+func (s FullShare) String() string {
+	if s.QuotaGiB == 0 {
+		return fmt.Sprintf("%s has reached its quota", s.Share)
+	}
+	return fmt.Sprintf("%s has used %.1f of its %d GiB quota", s.Share, float64(s.UsedBytes)/(1024*1024*1024), s.QuotaGiB)
 }
 
 // represents the Details and details of a single transfer
 type TransferDetail struct {
-	Src            string
+	Src string
+	// the path of the source relative to the source of the job, as it's recorded in the plan (so URL-encoded if the source is remote)
+	SrcRelative    string `json:",omitempty"`
 	Dst            string
 	TransferStatus TransferStatus
 	ErrorCode      int32
+	// the x-ms-client-request-id of the request that the transfer failed with, if it failed with one and it's known,
+	// which matches it to the logs of the service. Only known to the process that ran the transfer
+	ClientRequestID string `json:",omitempty"`
+}
+
+// InFlightTransfer is the progress of a large file that is being transferred
+type InFlightTransfer struct {
+	Src             string
+	Dst             string
+	Size            uint64
+	BytesDone       uint64
+	PercentComplete float32
+	// the average rate since the transfer started, and how long it would take to finish at that rate.
+	// Zero while there isn't a rate to go by
+	ThroughputMbps   float64
+	SecondsRemaining uint64
+}
+
+// RenewCredentialRequest gives a job, whose OAuth token expired and couldn't be refreshed, the info of a new token,
+// e.g. once the user has logged in again
+type RenewCredentialRequest struct {
+	JobID          JobID
+	OAuthTokenInfo OAuthTokenInfo
+}
+
+type RenewCredentialResponse struct {
+	ErrorMsg string
+	Renewed  bool
 }
 
 type CancelPauseResumeResponse struct {
 	ErrorMsg              string
 	CancelledPauseResumed bool
+
+	// when a job was resumed with its destinations verified: how many transfers were found to be done after all,
+	// how many are copied again since their destination is gone or different, and how many destinations couldn't be checked
+	TransfersFoundDone      uint32 `json:",omitempty"`
+	TransfersToCopyAgain    uint32 `json:",omitempty"`
+	DestinationsNotVerified uint32 `json:",omitempty"`
+}
+
+// SetJobLimitsRequest changes the bandwidth cap and the concurrency of a running job.
+// It's sent by 'jobs set' to the control endpoint of the process running the job.
+type SetJobLimitsRequest struct {
+	JobID JobID
+	// the cap is only changed if ChangeCapMbps is set, in which case a CapMbps of zero removes it
+	ChangeCapMbps bool
+	CapMbps       int64
+	// zero leaves the concurrency as it is
+	Concurrency int
+}
+
+// SetJobLimitsResponse holds the limits that the job has after the request
+type SetJobLimitsResponse struct {
+	ErrorMsg    string
+	LimitsSet   bool
+	CapMbps     int64
+	Concurrency int
 }
 
 // represents the list of Details and details of number of transfers
@@ -236,4 +676,11 @@ type GetJobFromToResponse struct {
 	FromTo      FromTo
 	Source      string
 	Destination string
+	JobStatus   JobStatus
+	// when the first part of the job was ordered, before which nothing at the destination was written by the job
+	StartTime time.Time
+	// the suffix of the temporary names that the job's ADLS Gen2 and Azure Files destinations are written under, if any
+	TempNameSuffix string
+	// what the x-ms-client-request-id of each request of the job starts with
+	ClientRequestIDPrefix string
 }
@@ -261,7 +261,7 @@ func (cr *singleChunkReader) Seek(offset int64, whence int) (int64, error) {
 	case io.SeekCurrent:
 		newPosition += offset
 	case io.SeekEnd:
-		newPosition = cr.length - offset
+		newPosition = cr.length + offset
 	}
 
 	if newPosition < 0 {
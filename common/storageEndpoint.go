@@ -0,0 +1,100 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"net/url"
+	"strings"
+)
+
+// StorageCloud is an Azure cloud, as far as the endpoints of its storage accounts and of its Azure Active Directory are concerned
+type StorageCloud struct {
+	Name                    string
+	EndpointSuffix          string // e.g. core.windows.net, which comes after the account name and service in the host of an account's endpoints
+	ActiveDirectoryEndpoint string // the AAD authority to log in with, for tokens that the cloud's storage accepts
+}
+
+var KnownStorageClouds = []StorageCloud{
+	{Name: "AzurePublicCloud", EndpointSuffix: "core.windows.net", ActiveDirectoryEndpoint: DefaultActiveDirectoryEndpoint},
+	{Name: "AzureChinaCloud", EndpointSuffix: "core.chinacloudapi.cn", ActiveDirectoryEndpoint: "https://login.chinacloudapi.cn"},
+	{Name: "AzureUSGovernment", EndpointSuffix: "core.usgovcloudapi.net", ActiveDirectoryEndpoint: "https://login.microsoftonline.us"},
+	{Name: "AzureGermanCloud", EndpointSuffix: "core.cloudapi.de", ActiveDirectoryEndpoint: "https://login.microsoftonline.de"},
+}
+
+// the name of the cloud that AZCOPY_STORAGE_ENDPOINT_SUFFIX describes, e.g. an Azure Stack
+const CustomStorageCloudName = "Custom"
+
+// StorageClouds returns the clouds whose endpoints are recognized: the one that AZCOPY_STORAGE_ENDPOINT_SUFFIX and AZCOPY_AAD_AUTHORITY
+// describe, if the suffix is set, and then the known ones
+func StorageClouds() []StorageCloud {
+	suffix := strings.Trim(strings.ToLower(lcm.GetEnvironmentVariable(EEnvironmentVariable.StorageEndpointSuffix())), ".")
+	if suffix == "" {
+		return KnownStorageClouds
+	}
+	custom := StorageCloud{Name: CustomStorageCloudName, EndpointSuffix: suffix, ActiveDirectoryEndpoint: DefaultActiveDirectoryEndpointForLogin()}
+	return append([]StorageCloud{custom}, KnownStorageClouds...)
+}
+
+// StorageEndpoint is what the host of a storage account's endpoint tells about it
+type StorageEndpoint struct {
+	AccountName string
+	Service     Location // Blob, File or BlobFS
+	Cloud       StorageCloud
+}
+
+var storageEndpointServices = map[string]Location{
+	"blob": ELocation.Blob(),
+	"file": ELocation.File(),
+	"dfs":  ELocation.BlobFS(),
+}
+
+// ParseStorageEndpoint parses the host of the URL, if it's one of the endpoints of a storage account in a recognized cloud,
+// i.e. account.service.suffix, where service is blob, file or dfs. ok is false if it isn't.
+func ParseStorageEndpoint(u url.URL) (endpoint StorageEndpoint, ok bool) {
+	host := strings.ToLower(u.Hostname())
+	for _, cloud := range StorageClouds() {
+		accountAndService := strings.TrimSuffix(host, "."+cloud.EndpointSuffix)
+		if accountAndService == host {
+			continue
+		}
+
+		// the account name has no dots, so anything else isn't an account's endpoint
+		parts := strings.Split(accountAndService, ".")
+		if len(parts) != 2 || parts[0] == "" {
+			return StorageEndpoint{}, false
+		}
+		service, ok := storageEndpointServices[parts[1]]
+		if !ok {
+			return StorageEndpoint{}, false
+		}
+		return StorageEndpoint{AccountName: parts[0], Service: service, Cloud: cloud}, true
+	}
+	return StorageEndpoint{}, false
+}
+
+// DefaultActiveDirectoryEndpointForLogin returns the AAD authority to log in with when the user doesn't give one:
+// the one in AZCOPY_AAD_AUTHORITY, or else the public cloud's
+func DefaultActiveDirectoryEndpointForLogin() string {
+	if authority := lcm.GetEnvironmentVariable(EEnvironmentVariable.AADAuthority()); authority != "" {
+		return strings.TrimSuffix(authority, "/")
+	}
+	return DefaultActiveDirectoryEndpoint
+}
@@ -0,0 +1,81 @@
+// +build !windows
+
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"log/syslog"
+	"runtime"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"user":   syslog.LOG_USER,
+	"daemon": syslog.LOG_DAEMON,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func newSystemLogWriter(target LogTarget, syslogFacility string) (systemLogWriter, error) {
+	if target != ELogTarget.Syslog() {
+		return nil, fmt.Errorf("log target '%s' is not supported on %s", target, runtime.GOOS)
+	}
+
+	facility, ok := syslogFacilities[strings.ToLower(syslogFacility)]
+	if !ok {
+		return nil, errors.New("invalid syslog facility '" + syslogFacility + "'. The choices include: user, daemon, local0 to local7")
+	}
+
+	// connects to the local syslog daemon
+	w, err := syslog.New(facility|syslog.LOG_INFO, "azcopy")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to syslog: %s", err.Error())
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) write(level pipeline.LogLevel, msg string) error {
+	switch level {
+	case pipeline.LogPanic, pipeline.LogFatal:
+		return s.w.Crit(msg)
+	case pipeline.LogError:
+		return s.w.Err(msg)
+	case pipeline.LogWarning:
+		return s.w.Warning(msg)
+	default:
+		return s.w.Info(msg)
+	}
+}
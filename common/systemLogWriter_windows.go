@@ -0,0 +1,156 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+const eventLogSourceName = "AzCopy"
+
+// the source is registered to use the generic message file of Windows, which shows each event's string as-is
+const eventLogSourceKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\` + eventLogSourceName
+const eventLogMessageFile = `%SystemRoot%\System32\EventCreate.exe`
+
+// Refer to https://docs.microsoft.com/en-us/windows/win32/eventlog/event-types for more details.
+const (
+	eventLogErrorType       = 0x1
+	eventLogWarningType     = 0x2
+	eventLogInformationType = 0x4
+)
+
+// the message file supports IDs 1 to 1000, so one each per type allows filtering on them
+const (
+	eventIDInformation = 1
+	eventIDWarning     = 2
+	eventIDError       = 3
+)
+
+var dAdvapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+// Refer to https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-registereventsourcew for more details.
+var mRegisterEventSource = dAdvapi32.NewProc("RegisterEventSourceW")
+
+// Refer to https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-reporteventw for more details.
+var mReportEvent = dAdvapi32.NewProc("ReportEventW")
+
+// Refer to https://docs.microsoft.com/en-us/windows/win32/api/winreg/nf-winreg-regcreatekeyexw for more details.
+var mRegCreateKeyEx = dAdvapi32.NewProc("RegCreateKeyExW")
+
+// Refer to https://docs.microsoft.com/en-us/windows/win32/api/winreg/nf-winreg-regsetvalueexw for more details.
+var mRegSetValueEx = dAdvapi32.NewProc("RegSetValueExW")
+
+type eventLogWriter struct {
+	handle uintptr
+}
+
+func newSystemLogWriter(target LogTarget, syslogFacility string) (systemLogWriter, error) {
+	if target != ELogTarget.EventLog() {
+		return nil, fmt.Errorf("log target '%s' is not supported on %s", target, runtime.GOOS)
+	}
+
+	if err := ensureEventLogSourceRegistered(); err != nil {
+		// the events are still logged, but the Event Viewer shows them with a note that their description is missing
+		GetLifecycleMgr().Info(fmt.Sprintf("Could not register the event source '%s' (%s). "+
+			"Running AzCopy once as an administrator will register it.", eventLogSourceName, err.Error()))
+	}
+
+	name, err := syscall.UTF16PtrFromString(eventLogSourceName)
+	if err != nil {
+		return nil, err
+	}
+	h, _, err := mRegisterEventSource.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, fmt.Errorf("cannot open the event log: %s", err.Error())
+	}
+	return &eventLogWriter{handle: h}, nil
+}
+
+// ensureEventLogSourceRegistered creates the registry entry of the event source, unless it already exists.
+// That needs administrator rights, so it's normally done the first time that AzCopy is run as an administrator.
+func ensureEventLogSourceRegistered() error {
+	keyName, err := syscall.UTF16PtrFromString(eventLogSourceKey)
+	if err != nil {
+		return err
+	}
+
+	var existing syscall.Handle
+	if syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, keyName, 0, syscall.KEY_READ, &existing) == nil {
+		return syscall.RegCloseKey(existing)
+	}
+
+	var key syscall.Handle
+	var disposition uint32
+	if r, _, _ := mRegCreateKeyEx.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(keyName)), 0, 0, 0,
+		syscall.KEY_SET_VALUE, 0, uintptr(unsafe.Pointer(&key)), uintptr(unsafe.Pointer(&disposition))); r != 0 {
+		return syscall.Errno(r)
+	}
+	defer syscall.RegCloseKey(key)
+
+	messageFile, err := syscall.UTF16FromString(eventLogMessageFile)
+	if err != nil {
+		return err
+	}
+	if err := setRegistryValue(key, "EventMessageFile", syscall.REG_EXPAND_SZ,
+		(*byte)(unsafe.Pointer(&messageFile[0])), uint32(len(messageFile)*2)); err != nil {
+		return err
+	}
+	typesSupported := uint32(eventLogErrorType | eventLogWarningType | eventLogInformationType)
+	return setRegistryValue(key, "TypesSupported", syscall.REG_DWORD, (*byte)(unsafe.Pointer(&typesSupported)), 4)
+}
+
+func setRegistryValue(key syscall.Handle, name string, valueType uint32, data *byte, dataSize uint32) error {
+	valueName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if r, _, _ := mRegSetValueEx.Call(uintptr(key), uintptr(unsafe.Pointer(valueName)), 0, uintptr(valueType),
+		uintptr(unsafe.Pointer(data)), uintptr(dataSize)); r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func (e *eventLogWriter) write(level pipeline.LogLevel, msg string) error {
+	eventType, eventID := uint16(eventLogInformationType), uint32(eventIDInformation)
+	switch level {
+	case pipeline.LogPanic, pipeline.LogFatal, pipeline.LogError:
+		eventType, eventID = eventLogErrorType, eventIDError
+	case pipeline.LogWarning:
+		eventType, eventID = eventLogWarningType, eventIDWarning
+	}
+
+	s, err := syscall.UTF16PtrFromString(msg)
+	if err != nil {
+		return err
+	}
+	strs := []*uint16{s}
+	r, _, err := mReportEvent.Call(e.handle, uintptr(eventType), 0, uintptr(eventID), 0, 1, 0, uintptr(unsafe.Pointer(&strs[0])), 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ISystemLogger sends the few messages that matter to operators (job lifecycle events, warnings and errors)
+// to the logging facility of the OS, where the tooling of unattended runs can find them.
+// The detailed log of each job still goes to the job's log file.
+type ISystemLogger interface {
+	// Log never blocks. If the OS log is not keeping up, the message is dropped and counted instead.
+	Log(level pipeline.LogLevel, msg string)
+	// Flush waits, for at most the timeout, until all messages logged so far have been sent
+	Flush(timeout time.Duration)
+	DroppedCount() int64
+}
+
+// systemLogWriter is the OS specific part of the system logger
+type systemLogWriter interface {
+	write(level pipeline.LogLevel, msg string) error
+}
+
+// NewSystemLogger returns nil for the File target, since then there is nothing more to log to.
+// The facility is only used by syslog.
+func NewSystemLogger(target LogTarget, syslogFacility string) (ISystemLogger, error) {
+	if target == ELogTarget.File() {
+		return nil, nil
+	}
+
+	w, err := newSystemLogWriter(target, syslogFacility)
+	if err != nil {
+		return nil, err
+	}
+	return newSystemLogger(w), nil
+}
+
+type systemLogMessage struct {
+	level   pipeline.LogLevel
+	msg     string
+	flushed chan struct{} // if not nil, this is not a message, but a request to say when everything before it was sent
+}
+
+type systemLogger struct {
+	atomicDropped int64
+
+	writer      systemLogWriter
+	sanitizer   pipeline.LogSanitizer
+	unsentQueue chan systemLogMessage
+}
+
+func newSystemLogger(w systemLogWriter) *systemLogger {
+	sl := &systemLogger{
+		writer:      w,
+		sanitizer:   NewAzCopyLogSanitizer(),
+		unsentQueue: make(chan systemLogMessage, 1000), // small, since only a few messages are expected to come here
+	}
+	go sl.main()
+	return sl
+}
+
+func (sl *systemLogger) Log(level pipeline.LogLevel, msg string) {
+	// OS logs are line based, so multi-line messages, such as those of the request log policy, are joined up
+	lines := strings.Split(sl.sanitizer.SanitizeLogMessage(msg), "\n")
+	nonEmpty := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			nonEmpty = append(nonEmpty, l)
+		}
+	}
+
+	select {
+	case sl.unsentQueue <- systemLogMessage{level: level, msg: strings.Join(nonEmpty, " | ")}:
+	default:
+		atomic.AddInt64(&sl.atomicDropped, 1)
+	}
+}
+
+func (sl *systemLogger) Flush(timeout time.Duration) {
+	flushed := make(chan struct{})
+	deadline := time.After(timeout)
+
+	select {
+	case sl.unsentQueue <- systemLogMessage{flushed: flushed}:
+	case <-deadline:
+		return
+	}
+	select {
+	case <-flushed:
+	case <-deadline:
+	}
+}
+
+func (sl *systemLogger) DroppedCount() int64 {
+	return atomic.LoadInt64(&sl.atomicDropped)
+}
+
+func (sl *systemLogger) main() {
+	for m := range sl.unsentQueue {
+		if m.flushed != nil {
+			close(m.flushed)
+			continue
+		}
+		if err := sl.writer.write(m.level, m.msg); err != nil {
+			atomic.AddInt64(&sl.atomicDropped, 1)
+		}
+	}
+}
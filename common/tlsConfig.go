@@ -0,0 +1,89 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/minio/minio-go"
+)
+
+// GlobalTLSConfig is the TLS configuration of the connections of the HTTP clients that AzCopy makes, or nil for Go's defaults.
+// It's set once, at startup, from the command line.
+var GlobalTLSConfig *tls.Config
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig returns the TLS configuration for the given options, or nil if they are all the defaults.
+// trustedCAFile is the path of a PEM file whose certificates are trusted in addition to those of the system,
+// and minVersion is 1.2 or 1.3 (or empty for Go's default).
+func NewTLSConfig(trustedCAFile string, minVersion string, insecureSkipVerify bool) (*tls.Config, error) {
+	if trustedCAFile == "" && minVersion == "" && !insecureSkipVerify {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if minVersion != "" {
+		v, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS version '%s'. The choices include: 1.2, 1.3", minVersion)
+		}
+		cfg.MinVersion = v
+	}
+
+	if trustedCAFile != "" {
+		pem, err := ioutil.ReadFile(trustedCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the trusted CA file %s: %s", trustedCAFile, err.Error())
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil || roots == nil {
+			// e.g. on Windows before Go 1.18, where the system roots can't be listed
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("the trusted CA file %s does not contain any PEM encoded certificates", trustedCAFile)
+		}
+		cfg.RootCAs = roots
+	}
+	return cfg, nil
+}
+
+// SetGlobalTLSConfig makes cfg the TLS configuration of AzCopy's HTTP clients, including those of the libraries
+// that use http.DefaultTransport (such as the refreshing of OAuth tokens) and the S3 client
+func SetGlobalTLSConfig(cfg *tls.Config) {
+	GlobalTLSConfig = cfg
+	if cfg == nil {
+		return
+	}
+	for _, rt := range []http.RoundTripper{http.DefaultTransport, minio.DefaultTransport} {
+		if t, ok := rt.(*http.Transport); ok {
+			t.TLSClientConfig = cfg.Clone()
+		}
+	}
+}
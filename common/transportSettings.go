@@ -0,0 +1,108 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/minio/minio-go"
+)
+
+// TransportSettings are the tuning of the connection pools of the HTTP clients that AzCopy makes, from the environment.
+// The zero value leaves the transports as they're made
+type TransportSettings struct {
+	// how many idle connections are kept per host, in place of the number that's worked out from the concurrency. 0 to leave it
+	MaxIdleConnsPerHost int
+	// how many connections, idle or not, may be open to each host. 0 for no limit
+	MaxConnsPerHost int
+	DisableHTTP2    bool
+}
+
+// GlobalTransportSettings is the tuning of the transports of the HTTP clients that AzCopy makes.
+// It's set once, at startup, from the environment.
+var GlobalTransportSettings TransportSettings
+
+// NewTransportSettings reads the settings from AZCOPY_MAX_IDLE_CONNS, AZCOPY_MAX_CONNS_PER_HOST and AZCOPY_DISABLE_HTTP2,
+// so that a value that can't be used fails the command, rather than being ignored
+func NewTransportSettings(getenv func(EnvironmentVariable) string) (TransportSettings, error) {
+	s := TransportSettings{}
+	var err error
+	for _, setting := range []struct {
+		envVar EnvironmentVariable
+		value  *int
+	}{
+		{EEnvironmentVariable.MaxIdleConns(), &s.MaxIdleConnsPerHost},
+		{EEnvironmentVariable.MaxConnsPerHost(), &s.MaxConnsPerHost},
+	} {
+		if raw := getenv(setting.envVar); raw != "" {
+			if *setting.value, err = strconv.Atoi(raw); err != nil || *setting.value < 0 {
+				return s, fmt.Errorf("invalid value '%s' for %s. It must be a number of connections", raw, setting.envVar.Name)
+			}
+		}
+	}
+
+	if raw := getenv(EEnvironmentVariable.DisableHTTP2()); raw != "" {
+		if s.DisableHTTP2, err = strconv.ParseBool(raw); err != nil {
+			return s, fmt.Errorf("invalid value '%s' for %s. It must be true or false", raw, EEnvironmentVariable.DisableHTTP2().Name)
+		}
+	}
+	return s, nil
+}
+
+// Apply tunes the transport with the settings
+func (s TransportSettings) Apply(t *http.Transport) {
+	if s.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	t.MaxConnsPerHost = s.MaxConnsPerHost
+	if s.DisableHTTP2 {
+		// a non-nil, empty map is how HTTP/2 is turned off for a transport
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
+
+// SetGlobalTransportSettings makes s the tuning of AzCopy's HTTP clients, including those of the libraries
+// that use http.DefaultTransport (such as the refreshing of OAuth tokens) and the S3 client
+func SetGlobalTransportSettings(s TransportSettings) {
+	GlobalTransportSettings = s
+	for _, rt := range []http.RoundTripper{http.DefaultTransport, minio.DefaultTransport} {
+		if t, ok := rt.(*http.Transport); ok {
+			s.Apply(t)
+		}
+	}
+}
+
+// DescribeTransport is what the transport ends up using, for the log
+func DescribeTransport(t *http.Transport) string {
+	maxConns := "no limit"
+	if t.MaxConnsPerHost > 0 {
+		maxConns = strconv.Itoa(t.MaxConnsPerHost)
+	}
+	// as Go decides it: a transport with its own dialing or TLS configuration only uses HTTP/2 if it's forced to
+	http2 := t.TLSNextProto == nil && (t.ForceAttemptHTTP2 ||
+		(t.TLSClientConfig == nil && t.Dial == nil && t.DialContext == nil && t.DialTLS == nil && t.DialTLSContext == nil))
+	return fmt.Sprintf("Max idle connections per host: %d. Max connections per host: %s. HTTP/2: %t",
+		t.MaxIdleConnsPerHost, maxConns, http2)
+}
@@ -0,0 +1,84 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+)
+
+type checksumFileSuite struct{}
+
+var _ = chk.Suite(&checksumFileSuite{})
+
+func (s *checksumFileSuite) TestLinesCanBeReadBack(c *chk.C) {
+	dir, err := ioutil.TempDir("", "checksumFile")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	paths := []string{"a.txt", "dir/b c.txt", "back\\slash", "line\nbreak"}
+	digests := make(map[string]string)
+	var lines []string
+	for _, p := range paths {
+		digest := sha256.Sum256([]byte(p))
+		lines = append(lines, FormatChecksumLine(digest[:], p))
+		digests[p] = strings.TrimPrefix(strings.Fields(lines[len(lines)-1])[0], "\\")
+	}
+	c.Assert(strings.HasPrefix(lines[0], "\\"), chk.Equals, false)
+	c.Assert(strings.HasPrefix(lines[2], "\\"), chk.Equals, true)
+	c.Assert(strings.Contains(lines[3], "\n"), chk.Equals, false)
+
+	checksumFile := filepath.Join(dir, "files.sha256")
+	c.Assert(ioutil.WriteFile(checksumFile, []byte(strings.Join(lines, "\n")+"\n"), 0644), chk.IsNil)
+	read, err := ReadChecksumFile(checksumFile)
+	c.Assert(err, chk.IsNil)
+	c.Assert(read, chk.DeepEquals, digests)
+}
+
+func (s *checksumFileSuite) TestBinaryMarkerAndUpperCaseAreAccepted(c *chk.C) {
+	dir, err := ioutil.TempDir("", "checksumFile")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	checksumFile := filepath.Join(dir, "files.md5")
+	c.Assert(ioutil.WriteFile(checksumFile, []byte("D41D8CD98F00B204E9800998ECF8427E *empty\r\n"), 0644), chk.IsNil)
+	read, err := ReadChecksumFile(checksumFile)
+	c.Assert(err, chk.IsNil)
+	c.Assert(read, chk.DeepEquals, map[string]string{"empty": "d41d8cd98f00b204e9800998ecf8427e"})
+
+	c.Assert(ioutil.WriteFile(checksumFile, []byte("d41d8cd98f00b204e9800998ecf8427e\n"), 0644), chk.IsNil)
+	_, err = ReadChecksumFile(checksumFile)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *checksumFileSuite) TestNameSaysWhichHash(c *chk.C) {
+	newHash, err := ChecksumFileHash("files.SHA256")
+	c.Assert(err, chk.IsNil)
+	c.Assert(newHash().Size(), chk.Equals, sha256.Size)
+
+	_, err = ChecksumFileHash("files.txt")
+	c.Assert(err, chk.NotNil)
+}
@@ -0,0 +1,255 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	chk "gopkg.in/check.v1"
+)
+
+type chunkedFileWriterSuite struct{}
+
+var _ = chk.Suite(&chunkedFileWriterSuite{})
+
+func (s *chunkedFileWriterSuite) TestMismatchedChunkIsNotWrittenAndCanBeRetried(c *chk.C) {
+	ctx := context.Background()
+	file := &closeableBuffer{Buffer: &bytes.Buffer{}}
+	w := NewChunkedFileWriter(ctx, NewMultiSizeSlicePool(1024), NewCacheLimiter(1024), &countingChunkStatusLogger{}, file, 1, 5, EHashValidationOption.FailIfDifferent(), true)
+
+	good := []byte("0123456789")
+	goodMD5 := md5.Sum(good)
+	id := NewChunkID("f", 0, 10)
+	c.Assert(w.WaitToScheduleChunk(ctx, id, 10), chk.IsNil)
+
+	// as if a byte was corrupted in flight
+	err := w.EnqueueVerifiedChunk(ctx, id, 10, bytes.NewReader([]byte("0123456780")), false, goodMD5[:])
+	c.Assert(err, chk.Equals, ChunkMD5Mismatch)
+
+	c.Assert(w.EnqueueVerifiedChunk(ctx, id, 10, bytes.NewReader(good), false, goodMD5[:]), chk.IsNil)
+	fileMD5, err := w.Flush(ctx)
+	c.Assert(err, chk.IsNil)
+	c.Assert(fileMD5, chk.DeepEquals, goodMD5[:])
+	c.Assert(file.String(), chk.Equals, string(good))
+}
+
+func (s *chunkedFileWriterSuite) TestChunkWithoutHashIsNotVerified(c *chk.C) {
+	ctx := context.Background()
+	file := &closeableBuffer{Buffer: &bytes.Buffer{}}
+	w := NewChunkedFileWriter(ctx, NewMultiSizeSlicePool(1024), NewCacheLimiter(1024), &countingChunkStatusLogger{}, file, 1, 5, EHashValidationOption.NoCheck(), false)
+
+	id := NewChunkID("f", 0, 4)
+	c.Assert(w.WaitToScheduleChunk(ctx, id, 4), chk.IsNil)
+	c.Assert(w.EnqueueVerifiedChunk(ctx, id, 4, bytes.NewReader([]byte("abcd")), false, nil), chk.IsNil)
+	_, err := w.Flush(ctx)
+	c.Assert(err, chk.IsNil)
+	c.Assert(file.String(), chk.Equals, "abcd")
+}
+
+func (s *chunkedFileWriterSuite) TestUnchangedChunkIsLeftAsItIs(c *chk.C) {
+	ctx := context.Background()
+	f, err := ioutil.TempFile("", "chunkedFileWriter")
+	c.Assert(err, chk.IsNil)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("aaaabbbbcccc")
+	c.Assert(err, chk.IsNil)
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, chk.IsNil)
+
+	w := NewChunkedFileWriter(ctx, NewMultiSizeSlicePool(1024), NewCacheLimiter(1024), &countingChunkStatusLogger{}, f, 3, 5, EHashValidationOption.NoCheck(), false)
+	ids := []ChunkID{NewChunkID("f", 0, 4), NewChunkID("f", 4, 4), NewChunkID("f", 8, 4)}
+	for _, id := range ids {
+		c.Assert(w.WaitToScheduleChunk(ctx, id, 4), chk.IsNil)
+	}
+
+	// out of order, as they may arrive
+	c.Assert(w.EnqueueChunk(ctx, ids[2], 4, bytes.NewReader([]byte("ZZZZ")), false), chk.IsNil)
+	c.Assert(w.EnqueueUnchangedChunk(ctx, ids[1], 4), chk.IsNil)
+	c.Assert(w.EnqueueChunk(ctx, ids[0], 4, bytes.NewReader([]byte("XXXX")), false), chk.IsNil)
+	_, err = w.Flush(ctx)
+	c.Assert(err, chk.IsNil)
+	c.Assert(f.Close(), chk.IsNil)
+
+	content, err := ioutil.ReadFile(f.Name())
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(content), chk.Equals, "XXXXbbbbZZZZ")
+}
+
+func (s *chunkedFileWriterSuite) TestResumedFileIsHashedWholeAndItsProgressRecorded(c *chk.C) {
+	ctx := context.Background()
+	f, err := ioutil.TempFile("", "chunkedFileWriter")
+	c.Assert(err, chk.IsNil)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// as an earlier attempt left it: the first chunk saved, and the rest not yet written
+	_, err = f.WriteString("aaaa????????")
+	c.Assert(err, chk.IsNil)
+	_, err = f.Seek(4, io.SeekStart)
+	c.Assert(err, chk.IsNil)
+
+	var recorded []int64
+	resume := ChunkedFileResume{
+		SavedLength:    4,
+		SavedContent:   io.NewSectionReader(f, 0, 4),
+		SyncInterval:   4,
+		OnDurablySaved: func(savedLength int64) { recorded = append(recorded, savedLength) },
+	}
+	w := NewResumableChunkedFileWriter(ctx, NewMultiSizeSlicePool(1024), NewCacheLimiter(1024), &countingChunkStatusLogger{}, f, 2, 5, EHashValidationOption.FailIfDifferent(), true, resume)
+	ids := []ChunkID{NewChunkID("f", 4, 4), NewChunkID("f", 8, 4)}
+	for _, id := range ids {
+		c.Assert(w.WaitToScheduleChunk(ctx, id, 4), chk.IsNil)
+	}
+	c.Assert(w.EnqueueChunk(ctx, ids[0], 4, bytes.NewReader([]byte("bbbb")), false), chk.IsNil)
+	c.Assert(w.EnqueueChunk(ctx, ids[1], 4, bytes.NewReader([]byte("cccc")), false), chk.IsNil)
+	fileMD5, err := w.Flush(ctx)
+	c.Assert(err, chk.IsNil)
+
+	wholeMD5 := md5.Sum([]byte("aaaabbbbcccc"))
+	c.Assert(fileMD5, chk.DeepEquals, wholeMD5[:])
+	// the chunks may be saved together, if they arrive together, so it's only sure to have recorded that the whole file is saved
+	c.Assert(len(recorded) > 0, chk.Equals, true)
+	c.Assert(recorded[len(recorded)-1], chk.Equals, int64(12))
+
+	content, err := ioutil.ReadFile(f.Name())
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(content), chk.Equals, "aaaabbbbcccc")
+}
+
+func (s *chunkedFileWriterSuite) TestProgressIsOnlyRecordedOncePerInterval(c *chk.C) {
+	ctx := context.Background()
+	f, err := ioutil.TempFile("", "chunkedFileWriter")
+	c.Assert(err, chk.IsNil)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var recorded []int64
+	resume := ChunkedFileResume{SyncInterval: 8, OnDurablySaved: func(savedLength int64) { recorded = append(recorded, savedLength) }}
+	w := NewResumableChunkedFileWriter(ctx, NewMultiSizeSlicePool(1024), NewCacheLimiter(1024), &countingChunkStatusLogger{}, f, 3, 5, EHashValidationOption.NoCheck(), false, resume)
+	ids := []ChunkID{NewChunkID("f", 0, 4), NewChunkID("f", 4, 4), NewChunkID("f", 8, 4)}
+	for _, id := range ids {
+		c.Assert(w.WaitToScheduleChunk(ctx, id, 4), chk.IsNil)
+		c.Assert(w.EnqueueChunk(ctx, id, 4, bytes.NewReader([]byte("xxxx")), false), chk.IsNil)
+	}
+	_, err = w.Flush(ctx)
+	c.Assert(err, chk.IsNil)
+	// after 8 bytes, or after all 12 if they were saved together, but not again for the last 4 of them
+	c.Assert(len(recorded), chk.Equals, 1)
+	c.Assert(recorded[0] >= 8, chk.Equals, true)
+}
+
+// syncingBuffer counts the times it's synced
+type syncingBuffer struct {
+	closeableBuffer
+	syncs int
+}
+
+func (b *syncingBuffer) Sync() error {
+	b.syncs++
+	return nil
+}
+
+func (s *chunkedFileWriterSuite) TestFileIsSyncedAsItsWrittenWithoutRecordingProgress(c *chk.C) {
+	ctx := context.Background()
+	file := &syncingBuffer{closeableBuffer: closeableBuffer{Buffer: &bytes.Buffer{}}}
+	w := NewResumableChunkedFileWriter(ctx, NewMultiSizeSlicePool(1024), NewCacheLimiter(1024), &countingChunkStatusLogger{}, file, 2, 5,
+		EHashValidationOption.NoCheck(), false, ChunkedFileResume{SyncInterval: 1})
+	ids := []ChunkID{NewChunkID("f", 0, 4), NewChunkID("f", 4, 4)}
+	for _, id := range ids {
+		c.Assert(w.WaitToScheduleChunk(ctx, id, 4), chk.IsNil)
+		c.Assert(w.EnqueueChunk(ctx, id, 4, bytes.NewReader([]byte("xxxx")), false), chk.IsNil)
+	}
+	_, err := w.Flush(ctx)
+	c.Assert(err, chk.IsNil)
+	// once for each time that chunks were saved, which is once or twice, depending on whether they arrived together
+	c.Assert(file.syncs >= 1 && file.syncs <= 2, chk.Equals, true)
+	c.Assert(file.String(), chk.Equals, "xxxxxxxx")
+}
+
+func (s *chunkedFileWriterSuite) TestSyncToDisk(c *chk.C) {
+	dir, err := ioutil.TempDir("", "syncToDisk")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "backup.bak")
+	c.Assert(ioutil.WriteFile(path, []byte("data"), 0644), chk.IsNil)
+	c.Assert(SyncToDisk(path), chk.IsNil)
+	c.Assert(SyncToDisk(filepath.Join(dir, "missing")), chk.NotNil)
+}
+
+// BenchmarkFlushPolicy reports what downloading a 64 MiB file in 4 MiB chunks costs with each flush policy (per-chunk flushing after each chunk).
+// What it costs depends on the disk, so it's worth running on the kind of disk that the numbers are for
+func BenchmarkFlushPolicy(b *testing.B) {
+	const chunkSize, numChunks = 4 * 1024 * 1024, 16
+	content := bytes.Repeat([]byte{'x'}, chunkSize)
+	dir, err := ioutil.TempDir("", "flushPolicy")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, policy := range []FlushPolicy{EFlushPolicy.None(), EFlushPolicy.PerFile(), EFlushPolicy.PerChunk()} {
+		b.Run(policy.String(), func(b *testing.B) {
+			b.SetBytes(chunkSize * numChunks)
+			for i := 0; i < b.N; i++ {
+				path := filepath.Join(dir, "file")
+				f, err := os.Create(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				resume := ChunkedFileResume{}
+				if policy == EFlushPolicy.PerChunk() {
+					resume.SyncInterval = 1
+				}
+				ctx := context.Background()
+				w := NewResumableChunkedFileWriter(ctx, NewMultiSizeSlicePool(chunkSize), NewCacheLimiter(chunkSize*numChunks), &countingChunkStatusLogger{}, f,
+					numChunks, 5, EHashValidationOption.NoCheck(), false, resume)
+				for chunk := int64(0); chunk < numChunks; chunk++ {
+					id := NewChunkID(path, chunk*chunkSize, chunkSize)
+					if err = w.WaitToScheduleChunk(ctx, id, chunkSize); err == nil {
+						err = w.EnqueueChunk(ctx, id, chunkSize, bytes.NewReader(content), false)
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err = w.Flush(ctx); err != nil {
+					b.Fatal(err)
+				}
+				if err = f.Close(); err != nil {
+					b.Fatal(err)
+				}
+				if policy != EFlushPolicy.None() {
+					if err = SyncToDisk(path); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	chk "gopkg.in/check.v1"
+)
+
+type contentTypesSuite struct{}
+
+var _ = chk.Suite(&contentTypesSuite{})
+
+func (s *contentTypesSuite) TestBuiltInTypesCoverModernWebFiles(c *chk.C) {
+	c.Assert(ContentTypeByExtension(".wasm"), chk.Equals, "application/wasm")
+	c.Assert(ContentTypeByExtension(".webmanifest"), chk.Equals, "application/manifest+json")
+	c.Assert(ContentTypeByExtension(".AVIF"), chk.Equals, "image/avif")
+	c.Assert(ContentTypeByExtension(".Woff2"), chk.Equals, "font/woff2")
+
+	// files without an extension are sniffed
+	c.Assert(ContentTypeByExtension(""), chk.Equals, "")
+}
+
+func (s *contentTypesSuite) TestCustomMapTakesPrecedence(c *chk.C) {
+	dir, err := ioutil.TempDir("", "contentTypes")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	mapFile := filepath.Join(dir, "map.json")
+	c.Assert(ioutil.WriteFile(mapFile, []byte(`{"wasm": "application/octet-stream", ".Custom": "application/x-custom"}`), 0644), chk.IsNil)
+
+	defer func() { customContentTypes = nil }()
+	c.Assert(LoadContentTypeMap(mapFile), chk.IsNil)
+
+	c.Assert(ContentTypeByExtension(".wasm"), chk.Equals, "application/octet-stream")
+	c.Assert(ContentTypeByExtension(".custom"), chk.Equals, "application/x-custom")
+	c.Assert(ContentTypeByExtension(".CUSTOM"), chk.Equals, "application/x-custom")
+	c.Assert(ContentTypeByExtension(".webp"), chk.Equals, "image/webp")
+}
+
+func (s *contentTypesSuite) TestInvalidMapsAreRejected(c *chk.C) {
+	dir, err := ioutil.TempDir("", "contentTypes")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	for i, content := range []string{`not json`, `{".x": "not a type/"}`, `{".x": 1}`} {
+		mapFile := filepath.Join(dir, "map.json")
+		c.Assert(ioutil.WriteFile(mapFile, []byte(content), 0644), chk.IsNil)
+		_, err = ReadContentTypeMap(mapFile)
+		c.Assert(err, chk.NotNil, chk.Commentf("case %d", i))
+	}
+
+	c.Assert(LoadContentTypeMap(filepath.Join(dir, "missing.json")), chk.NotNil)
+	c.Assert(LoadContentTypeMap(""), chk.IsNil)
+}
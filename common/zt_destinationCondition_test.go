@@ -0,0 +1,58 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type destinationConditionSuite struct{}
+
+var _ = chk.Suite(&destinationConditionSuite{})
+
+func (s *destinationConditionSuite) TestConditionsAreParsed(c *chk.C) {
+	none, err := ParseDestinationCondition("")
+	c.Assert(err, chk.IsNil)
+	c.Assert(none.IsSet(), chk.Equals, false)
+
+	absent, err := ParseDestinationCondition("if-none-match=*")
+	c.Assert(err, chk.IsNil)
+	c.Assert(absent, chk.DeepEquals, DestinationCondition{IfNoneMatch: true})
+	c.Assert(absent.String(), chk.Equals, "if-none-match=*")
+
+	since, err := ParseDestinationCondition("If-Unmodified-Since=2026-01-02T15:04:05Z")
+	c.Assert(err, chk.IsNil)
+	c.Assert(since.IfUnmodifiedSince.Equal(time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)), chk.Equals, true)
+	c.Assert(since.String(), chk.Equals, "if-unmodified-since=2026-01-02T15:04:05Z")
+
+	day, err := ParseDestinationCondition("if-unmodified-since=2026-01-02")
+	c.Assert(err, chk.IsNil)
+	c.Assert(day.IfUnmodifiedSince.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)), chk.Equals, true)
+}
+
+func (s *destinationConditionSuite) TestBadConditionsAreRejected(c *chk.C) {
+	for _, bad := range []string{"if-none-match=etag", "if-none-match", "if-unmodified-since=yesterday", "if-match=*"} {
+		_, err := ParseDestinationCondition(bad)
+		c.Assert(err, chk.NotNil, chk.Commentf(bad))
+	}
+}
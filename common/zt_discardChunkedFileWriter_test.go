@@ -0,0 +1,85 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"bytes"
+	"context"
+
+	chk "gopkg.in/check.v1"
+)
+
+type discardChunkedFileWriterSuite struct{}
+
+var _ = chk.Suite(&discardChunkedFileWriterSuite{})
+
+type countingChunkStatusLogger struct {
+	doneCount int
+}
+
+func (l *countingChunkStatusLogger) LogChunkStatus(id ChunkID, reason WaitReason) {
+	if reason == EWaitReason.ChunkDone() {
+		l.doneCount++
+	}
+}
+
+func (l *countingChunkStatusLogger) IsWaitingOnFinalBodyReads() bool {
+	return false
+}
+
+func (s *discardChunkedFileWriterSuite) TestDiscardWriterReadsWholeChunksInAnyOrder(c *chk.C) {
+	logger := &countingChunkStatusLogger{}
+	w := NewDiscardChunkedFileWriter(logger, 5)
+	ctx := context.Background()
+
+	// enqueue the second chunk first, since nothing should wait for the chunks to be in order
+	second := bytes.NewReader(make([]byte, 10))
+	c.Assert(w.WaitToScheduleChunk(ctx, NewChunkID("f", 10, 10), 10), chk.IsNil)
+	c.Assert(w.EnqueueChunk(ctx, NewChunkID("f", 10, 10), 10, second, false), chk.IsNil)
+	c.Assert(second.Len(), chk.Equals, 0)
+
+	first := bytes.NewReader(make([]byte, 10))
+	c.Assert(w.EnqueueChunk(ctx, NewChunkID("f", 0, 10), 10, first, false), chk.IsNil)
+	c.Assert(first.Len(), chk.Equals, 0)
+
+	md5, err := w.Flush(ctx)
+	c.Assert(err, chk.IsNil)
+	c.Assert(md5, chk.IsNil)
+	c.Assert(logger.doneCount, chk.Equals, 2)
+	c.Assert(w.MaxRetryPerDownloadBody(), chk.Equals, 5)
+}
+
+func (s *discardChunkedFileWriterSuite) TestDiscardWriterFailsOnShortChunk(c *chk.C) {
+	w := NewDiscardChunkedFileWriter(&countingChunkStatusLogger{}, 5)
+
+	err := w.EnqueueChunk(context.Background(), NewChunkID("f", 0, 10), 10, bytes.NewReader(make([]byte, 4)), false)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *discardChunkedFileWriterSuite) TestDiscardWriterWritesChunksToSink(c *chk.C) {
+	sink := &bytes.Buffer{}
+	w := NewDiscardChunkedFileWriterToSink(&countingChunkStatusLogger{}, sink, 5)
+	ctx := context.Background()
+
+	c.Assert(w.EnqueueChunk(ctx, NewChunkID("f", 4, 4), 4, bytes.NewReader([]byte("efgh")), false), chk.IsNil)
+	c.Assert(w.EnqueueChunk(ctx, NewChunkID("f", 0, 4), 4, bytes.NewReader([]byte("abcd")), false), chk.IsNil)
+	c.Assert(sink.String(), chk.Equals, "efghabcd") // as they arrived
+}
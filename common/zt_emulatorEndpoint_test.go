@@ -0,0 +1,71 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"net/url"
+
+	chk "gopkg.in/check.v1"
+)
+
+type emulatorEndpointSuite struct{}
+
+var _ = chk.Suite(&emulatorEndpointSuite{})
+
+func mustParseURL(c *chk.C, raw string) url.URL {
+	u, err := url.Parse(raw)
+	c.Assert(err, chk.IsNil)
+	return *u
+}
+
+func (s *emulatorEndpointSuite) TestIPEndpointStyleDetection(c *chk.C) {
+	azurite := mustParseURL(c, "http://127.0.0.1:10000/devstoreaccount1/container/blob")
+	c.Assert(IsIPEndpointStyle(azurite), chk.Equals, true)
+	c.Assert(IsEmulatorURL(azurite), chk.Equals, true)
+	c.Assert(IPEndpointStyleAccountName(azurite), chk.Equals, EmulatorAccountName)
+	c.Assert(IsEmulatorAccount(azurite), chk.Equals, true)
+
+	remote := mustParseURL(c, "https://10.1.2.3/otheraccount/container")
+	c.Assert(IsIPEndpointStyle(remote), chk.Equals, true)
+	c.Assert(IsEmulatorURL(remote), chk.Equals, false)
+	c.Assert(IPEndpointStyleAccountName(remote), chk.Equals, "otheraccount")
+	c.Assert(IsEmulatorAccount(remote), chk.Equals, false)
+
+	ipv6 := mustParseURL(c, "http://[::1]:10000/devstoreaccount1")
+	c.Assert(IsEmulatorURL(ipv6), chk.Equals, true)
+	c.Assert(IPEndpointStyleAccountName(ipv6), chk.Equals, EmulatorAccountName)
+
+	public := mustParseURL(c, "https://account.blob.core.windows.net/devstoreaccount1/blob")
+	c.Assert(IsIPEndpointStyle(public), chk.Equals, false)
+	c.Assert(IPEndpointStyleAccountName(public), chk.Equals, "")
+	c.Assert(IsEmulatorAccount(public), chk.Equals, false)
+}
+
+func (s *emulatorEndpointSuite) TestLocalhostIsReplacedWithLoopbackIP(c *chk.C) {
+	c.Assert(ReplaceLocalhostWithLoopbackIP("http://localhost:10000/devstoreaccount1/container?sv=x"), chk.Equals,
+		"http://127.0.0.1:10000/devstoreaccount1/container?sv=x")
+	c.Assert(ReplaceLocalhostWithLoopbackIP("http://LocalHost/devstoreaccount1"), chk.Equals, "http://127.0.0.1/devstoreaccount1")
+
+	// anything else is left alone
+	for _, unchanged := range []string{"https://account.blob.core.windows.net/localhost", "/tmp/localhost/file", "localhost"} {
+		c.Assert(ReplaceLocalhostWithLoopbackIP(unchanged), chk.Equals, unchanged)
+	}
+}
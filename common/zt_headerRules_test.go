@@ -0,0 +1,92 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	chk "gopkg.in/check.v1"
+)
+
+type headerRulesSuite struct{}
+
+var _ = chk.Suite(&headerRulesSuite{})
+
+func (s *headerRulesSuite) TestParseHeaderRule(c *chk.C) {
+	rule, err := ParseHeaderRule("pattern=assets/*;cacheControl=max-age=31536000, immutable")
+	c.Assert(err, chk.IsNil)
+	c.Assert(rule, chk.DeepEquals, HeaderRule{Pattern: "assets/*", CacheControl: "max-age=31536000, immutable"})
+
+	// the parameters of a content type are part of its value
+	rule, err = ParseHeaderRule("pattern=*.html;contentType=text/html; charset=utf-8;cacheControl=no-cache")
+	c.Assert(err, chk.IsNil)
+	c.Assert(rule.ContentType, chk.Equals, "text/html; charset=utf-8")
+	c.Assert(rule.CacheControl, chk.Equals, "no-cache")
+
+	for _, invalid := range []string{
+		"",
+		"*.html;cacheControl=no-cache",    // no pattern
+		"pattern=*.html",                  // sets nothing
+		"pattern=[;cacheControl=no-cache", // bad pattern
+		"pattern=*.html;cacheControl=a;cacheControl=b",   // given twice
+		"pattern=*.html;cacheControl=no-cache\npattern=", // more than one line
+	} {
+		_, err = ParseHeaderRule(invalid)
+		c.Assert(err, chk.NotNil, chk.Commentf("%q", invalid))
+	}
+}
+
+func (s *headerRulesSuite) TestFirstMatchWins(c *chk.C) {
+	rules := HeaderRules{
+		{Pattern: "index.html", CacheControl: "no-cache"},
+		{Pattern: "assets/*", CacheControl: "max-age=31536000, immutable"},
+		{Pattern: "*.html", ContentType: "text/html; charset=utf-8"},
+	}
+
+	_, index, ok := rules.Match("index.html")
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(index, chk.Equals, 0)
+
+	_, index, _ = rules.Match("docs/index.html") // patterns without a slash match the name
+	c.Assert(index, chk.Equals, 0)
+
+	_, index, _ = rules.Match("assets/img/logo.png") // and those with one match everything under the directories they match
+	c.Assert(index, chk.Equals, 1)
+
+	_, index, _ = rules.Match("docs/page.html")
+	c.Assert(index, chk.Equals, 2)
+
+	_, _, ok = rules.Match("docs/assets/logo.png")
+	c.Assert(ok, chk.Equals, false)
+}
+
+func (s *headerRulesSuite) TestHeaderRulesSurviveTheirTextForm(c *chk.C) {
+	rules := HeaderRules{
+		{Pattern: "*.html", ContentType: "text/html; charset=utf-8", CacheControl: "no-cache"},
+		{Pattern: "*.js.gz", ContentType: "application/javascript", ContentEncoding: "gzip"},
+	}
+
+	parsed, err := ParseHeaderRules(rules.String())
+	c.Assert(err, chk.IsNil)
+	c.Assert(parsed, chk.DeepEquals, rules)
+
+	parsed, err = ParseHeaderRules("")
+	c.Assert(err, chk.IsNil)
+	c.Assert(parsed, chk.HasLen, 0)
+}
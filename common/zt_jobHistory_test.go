@@ -0,0 +1,64 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type jobHistorySuite struct{}
+
+var _ = chk.Suite(&jobHistorySuite{})
+
+func (s *jobHistorySuite) TestHistoryIsReadBackInOrderSkippingBrokenLines(c *chk.C) {
+	dir, err := ioutil.TempDir("", "jobHistory")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := JobHistoryPath(dir)
+
+	entries, err := ReadJobHistory(path)
+	c.Assert(err, chk.IsNil)
+	c.Assert(entries, chk.HasLen, 0)
+
+	first := JobHistoryEntry{JobID: NewJobID(), Source: "/data", Destination: "https://account.blob.core.windows.net/c",
+		EndTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), TransfersCompleted: 3, JobStatus: EJobStatus.Completed()}
+	second := JobHistoryEntry{JobID: NewJobID(), TransfersFailed: 1, JobStatus: EJobStatus.CompletedWithErrors()}
+	c.Assert(AppendJobHistory(path, first), chk.IsNil)
+
+	// a line that was cut short
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	c.Assert(err, chk.IsNil)
+	_, err = f.WriteString(`{"JobID":"` + "\n")
+	c.Assert(err, chk.IsNil)
+	f.Close()
+
+	c.Assert(AppendJobHistory(path, second), chk.IsNil)
+
+	entries, err = ReadJobHistory(path)
+	c.Assert(err, chk.IsNil)
+	c.Assert(entries, chk.DeepEquals, []JobHistoryEntry{first, second})
+	c.Assert(filepath.Base(path), chk.Equals, JobHistoryFileName)
+}
@@ -0,0 +1,90 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+)
+
+type jobTemplateSuite struct{}
+
+var _ = chk.Suite(&jobTemplateSuite{})
+
+func (s *jobTemplateSuite) TestTemplateIsReadBackWithoutCredentials(c *chk.C) {
+	dir, err := ioutil.TempDir("", "jobTemplate")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := JobTemplatePath(dir, NewJobID())
+
+	t := NewJobTemplate("copy", "/data/nightly", "https://acct.blob.core.windows.net/backup?sv=2020-02-10&sig=secret", map[string]JobTemplateFlag{
+		"recursive":     {"true"},
+		"header-rule":   {"pattern=*.html;cacheControl=no-cache", "pattern=*.css;cacheControl=max-age=60"},
+		"list-of-files": {"https://acct.blob.core.windows.net/lists/files.txt?sig=secret"},
+	})
+	c.Assert(WriteJobTemplate(path, t), chk.IsNil)
+
+	written, err := ioutil.ReadFile(path)
+	c.Assert(err, chk.IsNil)
+	c.Assert(strings.Contains(string(written), "secret"), chk.Equals, false)
+	c.Assert(strings.Contains(string(written), "recursive: \"true\""), chk.Equals, true) // a single value is written as a scalar
+
+	read, err := ReadJobTemplate(path)
+	c.Assert(err, chk.IsNil)
+	c.Assert(read, chk.DeepEquals, t)
+	c.Assert(read.Destination, chk.Equals, "https://acct.blob.core.windows.net/backup")
+	c.Assert(read.Flags["list-of-files"], chk.DeepEquals, JobTemplateFlag{"https://acct.blob.core.windows.net/lists/files.txt"})
+	c.Assert(read.SchemaVersion, chk.Equals, JobTemplateSchemaVersion)
+	c.Assert(read.AzCopyVersion, chk.Equals, AzcopyVersion)
+}
+
+func (s *jobTemplateSuite) TestFlagSetHashDependsOnlyOnTheFlags(c *chk.C) {
+	a := NewJobTemplate("copy", "/data/a", "", map[string]JobTemplateFlag{"recursive": {"true"}, "overwrite": {"false"}})
+	b := NewJobTemplate("copy", "/data/b", "", map[string]JobTemplateFlag{"overwrite": {"false"}, "recursive": {"true"}})
+	c.Assert(a.FlagSetHash, chk.Equals, b.FlagSetHash)
+	c.Assert(a.FlagSetHash, chk.HasLen, 64)
+
+	changed := NewJobTemplate("copy", "/data/a", "", map[string]JobTemplateFlag{"recursive": {"true"}, "overwrite": {"true"}})
+	c.Assert(changed.FlagSetHash, chk.Not(chk.Equals), a.FlagSetHash)
+}
+
+func (s *jobTemplateSuite) TestNewerOrUnknownTemplatesAreRefused(c *chk.C) {
+	dir, err := ioutil.TempDir("", "jobTemplate")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "job.yaml")
+
+	c.Assert(ioutil.WriteFile(path, []byte("schemaVersion: 99\nazcopyVersion: 99.0.0\ncommand: copy\nsomethingNew: true\n"), 0644), chk.IsNil)
+	_, err = ReadJobTemplate(path)
+	c.Assert(err, chk.ErrorMatches, ".*is of version 99, which is newer than this version of AzCopy.*")
+
+	c.Assert(ioutil.WriteFile(path, []byte("command: copy\nflags:\n  recursive: \"true\"\n"), 0644), chk.IsNil)
+	_, err = ReadJobTemplate(path)
+	c.Assert(err, chk.ErrorMatches, ".*has no schemaVersion")
+
+	c.Assert(ioutil.WriteFile(path, []byte("schemaVersion: 1\ncommand: copy\nunknown: field\n"), 0644), chk.IsNil)
+	_, err = ReadJobTemplate(path)
+	c.Assert(err, chk.ErrorMatches, "(?s).*is not a job template.*field unknown not found.*")
+}
@@ -0,0 +1,98 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type jsonLogFormatSuite struct{}
+
+var _ = chk.Suite(&jsonLogFormatSuite{})
+
+func (s *jsonLogFormatSuite) TestEveryLineIsOneJsonObject(c *chk.C) {
+	dir, err := ioutil.TempDir("", "azcopyjsonlog")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, ELogLevel.Info(), nullAppLogger{}, dir, LogRotationSettings{MaxFileSizeBytes: 1024 * 1024, MaxRotatedFiles: 1}, ELogFormat.Json(), nil)
+	logger.OpenLog()
+
+	// like the request log policy does, with a multi-line message that has a secret and a request ID in it
+	logger.Log(pipeline.LogError, "==> REQUEST/RESPONSE (Try=1/2ms, OpTime=2ms) -- RESPONSE STATUS CODE ERROR\n"+
+		"   PUT https://acct.blob.core.windows.net/c/b?sig=secretvalue&sv=2019-02-02\n"+
+		"   X-Ms-Request-Id: [0a1b2c3d-0000-1111-2222-333344445555]\n")
+
+	partNum := PartNumber(3)
+	transferIndex := uint32(7)
+	logger.LogStructured(pipeline.LogError, LogEntry{
+		PartNum:       &partNum,
+		TransferIndex: &transferIndex,
+		Source:        "https://acct.blob.core.windows.net/c/b",
+		Destination:   "/data/b",
+		Message:       "DOWNLOADFAILED: could not read",
+		ErrorCode:     "BlobNotFound",
+	})
+	logger.LogStructured(pipeline.LogDebug, LogEntry{Message: "not at the configured level"})
+	logger.CloseLog()
+
+	content, err := ioutil.ReadFile(path.Join(dir, JobLogFileName(jobID)))
+	c.Assert(err, chk.IsNil)
+
+	var entries []LogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var e LogEntry
+		c.Assert(json.Unmarshal([]byte(line), &e), chk.IsNil, chk.Commentf(line))
+		c.Assert(e.JobID, chk.Equals, jobID.String())
+		entries = append(entries, e)
+	}
+
+	var fromPolicy, fromTransfer *LogEntry
+	for i := range entries {
+		if strings.HasPrefix(entries[i].Message, "==> REQUEST/RESPONSE") {
+			fromPolicy = &entries[i]
+		} else if strings.HasPrefix(entries[i].Message, "DOWNLOADFAILED") {
+			fromTransfer = &entries[i]
+		}
+		c.Assert(entries[i].Message, chk.Not(chk.Equals), "not at the configured level")
+	}
+
+	c.Assert(fromPolicy, chk.NotNil)
+	c.Assert(fromPolicy.Level, chk.Equals, "ERR")
+	c.Assert(fromPolicy.RequestID, chk.Equals, "0a1b2c3d-0000-1111-2222-333344445555")
+	c.Assert(strings.Contains(fromPolicy.Message, "secretvalue"), chk.Equals, false)
+
+	c.Assert(fromTransfer, chk.NotNil)
+	c.Assert(*fromTransfer.PartNum, chk.Equals, partNum)
+	c.Assert(*fromTransfer.TransferIndex, chk.Equals, transferIndex)
+	c.Assert(fromTransfer.Destination, chk.Equals, "/data/b")
+	c.Assert(fromTransfer.ErrorCode, chk.Equals, "BlobNotFound")
+
+	c.Assert(entries[len(entries)-1].Message, chk.Equals, "Closing Log")
+}
@@ -0,0 +1,103 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"net"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+)
+
+type localAddrSuite struct{}
+
+var _ = chk.Suite(&localAddrSuite{})
+
+func assignedLoopback() ([]net.Addr, error) {
+	return []net.Addr{
+		&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+		&net.IPNet{IP: net.ParseIP("fd00::5"), Mask: net.CIDRMask(64, 128)},
+	}, nil
+}
+
+func (s *localAddrSuite) TestDefaultsBindToNothing(c *chk.C) {
+	for _, version := range []string{"", "auto"} {
+		addr, err := NewLocalAddr("", version, assignedLoopback)
+		c.Assert(err, chk.IsNil)
+		c.Assert(addr, chk.IsNil)
+	}
+
+	d := BindToLocalAddr(&net.Dialer{})
+	c.Assert(d.LocalAddr, chk.IsNil)
+}
+
+func (s *localAddrSuite) TestIPVersionBindsToItsFamily(c *chk.C) {
+	addr, err := NewLocalAddr("", "4", assignedLoopback)
+	c.Assert(err, chk.IsNil)
+	c.Assert(addr.IP.Equal(net.IPv4zero), chk.Equals, true)
+
+	addr, err = NewLocalAddr("", "6", assignedLoopback)
+	c.Assert(err, chk.IsNil)
+	c.Assert(addr.IP.Equal(net.IPv6unspecified), chk.Equals, true)
+
+	_, err = NewLocalAddr("", "5", assignedLoopback)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *localAddrSuite) TestLocalAddressMustBeAssigned(c *chk.C) {
+	addr, err := NewLocalAddr("fd00::5", "auto", assignedLoopback)
+	c.Assert(err, chk.IsNil)
+	c.Assert(addr.IP.String(), chk.Equals, "fd00::5")
+
+	_, err = NewLocalAddr("10.1.2.3", "", assignedLoopback)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "not assigned"), chk.Equals, true)
+
+	_, err = NewLocalAddr("127.0.0.1", "6", assignedLoopback)
+	c.Assert(err, chk.NotNil)
+	_, err = NewLocalAddr("not-an-ip", "", assignedLoopback)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *localAddrSuite) TestConnectionsComeFromTheLocalAddress(c *chk.C) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	c.Assert(err, chk.IsNil)
+	defer listener.Close()
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn.RemoteAddr()
+			conn.Close()
+		}
+	}()
+
+	addr, err := NewLocalAddr("127.0.0.1", "4", net.InterfaceAddrs)
+	c.Assert(err, chk.IsNil)
+	original := GlobalLocalAddr
+	GlobalLocalAddr = addr
+	defer func() { GlobalLocalAddr = original }()
+
+	conn, err := BindToLocalAddr(&net.Dialer{}).Dial("tcp", listener.Addr().String())
+	c.Assert(err, chk.IsNil)
+	defer conn.Close()
+	c.Assert(conn.LocalAddr().(*net.TCPAddr).IP.String(), chk.Equals, "127.0.0.1")
+	c.Assert((<-accepted).(*net.TCPAddr).IP.String(), chk.Equals, "127.0.0.1")
+}
@@ -0,0 +1,96 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type logRotationSuite struct{}
+
+var _ = chk.Suite(&logRotationSuite{})
+
+type nullAppLogger struct{}
+
+func (nullAppLogger) ShouldLog(level pipeline.LogLevel) bool  { return false }
+func (nullAppLogger) Log(level pipeline.LogLevel, msg string) {}
+func (nullAppLogger) Panic(err error)                         {}
+
+func (s *logRotationSuite) TestJobLogIsRotatedAndOldestDeleted(c *chk.C) {
+	dir, err := ioutil.TempDir("", "azcopylogrotation")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	jobID := NewJobID()
+	settings := LogRotationSettings{MaxFileSizeBytes: 2 * 1024, MaxRotatedFiles: 2}
+	logger := NewJobLogger(jobID, ELogLevel.Info(), nullAppLogger{}, dir, settings, ELogFormat.Text(), nil)
+	logger.OpenLog()
+
+	line := strings.Repeat("x", 100)
+	for i := 0; i < 200; i++ { // about 20 KB in total, so there are many more rotations than files kept
+		logger.Log(pipeline.LogInfo, line)
+	}
+	logger.CloseLog()
+
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, chk.IsNil)
+	names := make(map[string]bool)
+	for _, f := range files {
+		names[f.Name()] = true
+	}
+	c.Assert(names, chk.DeepEquals, map[string]bool{
+		JobLogFileName(jobID):           true,
+		rotatedJobLogFileName(jobID, 1): true,
+		rotatedJobLogFileName(jobID, 2): true,
+	})
+
+	// rotated files are self-describing, and don't grow far beyond the limit
+	for n := range names {
+		content, err := ioutil.ReadFile(path.Join(dir, n))
+		c.Assert(err, chk.IsNil)
+		c.Assert(strings.Contains(string(content), "AzcopyVersion"), chk.Equals, true)
+		c.Assert(len(content) < int(settings.MaxFileSizeBytes)+2*len(line)+200, chk.Equals, true)
+	}
+
+	current, err := ioutil.ReadFile(path.Join(dir, JobLogFileName(jobID)))
+	c.Assert(err, chk.IsNil)
+	c.Assert(strings.HasSuffix(strings.TrimSpace(string(current)), "Closing Log"), chk.Equals, true)
+}
+
+func (s *logRotationSuite) TestJobLogIgnoresMessagesAfterClose(c *chk.C) {
+	dir, err := ioutil.TempDir("", "azcopylogrotation")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	logger := NewJobLogger(NewJobID(), ELogLevel.Info(), nullAppLogger{}, dir, LogRotationSettings{MaxFileSizeBytes: 1024, MaxRotatedFiles: 1}, ELogFormat.Text(), nil)
+	logger.OpenLog()
+	logger.CloseLog()
+
+	// must neither panic nor block
+	logger.Log(pipeline.LogInfo, "late message")
+	logger.CloseLog()
+}
@@ -0,0 +1,65 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type preservedLastModifiedTimeSuite struct{}
+
+var _ = chk.Suite(&preservedLastModifiedTimeSuite{})
+
+func (s *preservedLastModifiedTimeSuite) TestTimeIsKeptInUTC(c *chk.C) {
+	local := time.Date(2020, 3, 4, 5, 6, 7, 800000000, time.FixedZone("UTC+2", 2*60*60))
+	source := Metadata{"owner": "finance"}
+
+	preserved := source.WithPreservedLastModifiedTime(local)
+	c.Assert(preserved, chk.DeepEquals, Metadata{"owner": "finance", PreservedLastModifiedTimeMetadataKey: "2020-03-04T03:06:07.8Z"})
+	c.Assert(source, chk.DeepEquals, Metadata{"owner": "finance"})
+
+	t, ok := preserved.PreservedLastModifiedTime()
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(t.Equal(local), chk.Equals, true)
+
+	// nil metadata, e.g. that of a local file, takes the key too
+	c.Assert(Metadata(nil).WithPreservedLastModifiedTime(local), chk.HasLen, 1)
+}
+
+func (s *preservedLastModifiedTimeSuite) TestTimeOfTheOriginalSourceWins(c *chk.C) {
+	original := Metadata{"Azcopy_Source_Lmt": "2020-03-04T05:06:07Z"}
+
+	copied := original.WithPreservedLastModifiedTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	t, ok := copied.PreservedLastModifiedTime()
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(t.Equal(time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)), chk.Equals, true)
+}
+
+func (s *preservedLastModifiedTimeSuite) TestMissingOrInvalidTimeIsNotPreserved(c *chk.C) {
+	_, ok := Metadata(nil).PreservedLastModifiedTime()
+	c.Assert(ok, chk.Equals, false)
+	_, ok = Metadata{"owner": "finance"}.PreservedLastModifiedTime()
+	c.Assert(ok, chk.Equals, false)
+	_, ok = Metadata{PreservedLastModifiedTimeMetadataKey: "yesterday"}.PreservedLastModifiedTime()
+	c.Assert(ok, chk.Equals, false)
+}
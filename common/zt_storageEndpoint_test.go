@@ -0,0 +1,89 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"os"
+
+	chk "gopkg.in/check.v1"
+)
+
+type storageEndpointSuite struct{}
+
+var _ = chk.Suite(&storageEndpointSuite{})
+
+func (s *storageEndpointSuite) TestEndpointsOfKnownAndCustomClouds(c *chk.C) {
+	os.Setenv(EEnvironmentVariable.StorageEndpointSuffix().Name, "local.azurestack.external")
+	os.Setenv(EEnvironmentVariable.AADAuthority().Name, "https://login.azurestack.contoso.com/")
+	defer os.Unsetenv(EEnvironmentVariable.StorageEndpointSuffix().Name)
+	defer os.Unsetenv(EEnvironmentVariable.AADAuthority().Name)
+
+	clouds := map[string]struct {
+		suffix    string
+		authority string
+	}{
+		"AzurePublicCloud":     {"core.windows.net", "https://login.microsoftonline.com"},
+		"AzureChinaCloud":      {"core.chinacloudapi.cn", "https://login.chinacloudapi.cn"},
+		"AzureUSGovernment":    {"core.usgovcloudapi.net", "https://login.microsoftonline.us"},
+		"AzureGermanCloud":     {"core.cloudapi.de", "https://login.microsoftonline.de"},
+		CustomStorageCloudName: {"local.azurestack.external", "https://login.azurestack.contoso.com"},
+	}
+	services := map[string]Location{"blob": ELocation.Blob(), "file": ELocation.File(), "dfs": ELocation.BlobFS()}
+
+	for name, cloud := range clouds {
+		for label, service := range services {
+			endpoint, ok := ParseStorageEndpoint(mustParseURL(c, "https://myaccount."+label+"."+cloud.suffix+":443/container/dir/file?sv=x"))
+			c.Assert(ok, chk.Equals, true, chk.Commentf("%s %s", name, label))
+			c.Assert(endpoint.AccountName, chk.Equals, "myaccount")
+			c.Assert(endpoint.Service, chk.Equals, service)
+			c.Assert(endpoint.Cloud.Name, chk.Equals, name)
+			c.Assert(endpoint.Cloud.ActiveDirectoryEndpoint, chk.Equals, cloud.authority)
+		}
+
+		// an account whose name looks like a service doesn't confuse it
+		endpoint, ok := ParseStorageEndpoint(mustParseURL(c, "https://blob.file."+cloud.suffix))
+		c.Assert(ok, chk.Equals, true)
+		c.Assert(endpoint.AccountName, chk.Equals, "blob")
+		c.Assert(endpoint.Service, chk.Equals, ELocation.File())
+	}
+}
+
+func (s *storageEndpointSuite) TestOtherHostsAreNotStorageEndpoints(c *chk.C) {
+	for _, raw := range []string{
+		"https://myaccount.queue.core.windows.net/q",
+		"https://a.b.blob.core.windows.net/container",
+		"https://blob.core.windows.net/container",
+		"https://myaccount.blob.local.azurestack.external/container", // since AZCOPY_STORAGE_ENDPOINT_SUFFIX isn't set
+		"https://s3.amazonaws.com/bucket",
+		"http://127.0.0.1:10000/devstoreaccount1/container",
+	} {
+		_, ok := ParseStorageEndpoint(mustParseURL(c, raw))
+		c.Assert(ok, chk.Equals, false, chk.Commentf(raw))
+	}
+}
+
+func (s *storageEndpointSuite) TestDefaultActiveDirectoryEndpointForLogin(c *chk.C) {
+	c.Assert(DefaultActiveDirectoryEndpointForLogin(), chk.Equals, DefaultActiveDirectoryEndpoint)
+
+	os.Setenv(EEnvironmentVariable.AADAuthority().Name, "https://login.chinacloudapi.cn")
+	defer os.Unsetenv(EEnvironmentVariable.AADAuthority().Name)
+	c.Assert(DefaultActiveDirectoryEndpointForLogin(), chk.Equals, "https://login.chinacloudapi.cn")
+}
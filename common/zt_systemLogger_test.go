@@ -0,0 +1,108 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type systemLoggerSuite struct{}
+
+var _ = chk.Suite(&systemLoggerSuite{})
+
+// recordingSystemLogWriter keeps what is written, and can be made to hang, like a syslog daemon that is not keeping up
+type recordingSystemLogWriter struct {
+	lock     sync.Mutex
+	messages []string
+	blocked  chan struct{}
+}
+
+func (w *recordingSystemLogWriter) write(level pipeline.LogLevel, msg string) error {
+	if w.blocked != nil {
+		<-w.blocked
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.messages = append(w.messages, LogLevel(level).String()+" "+msg)
+	return nil
+}
+
+func (w *recordingSystemLogWriter) written() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]string{}, w.messages...)
+}
+
+func (s *systemLoggerSuite) TestOnlyWarningsAndAboveAreForwardedFromJobLog(c *chk.C) {
+	dir, err := ioutil.TempDir("", "azcopysystemlog")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	w := &recordingSystemLogWriter{}
+	sl := newSystemLogger(w)
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, ELogLevel.Info(), nullAppLogger{}, dir, LogRotationSettings{MaxFileSizeBytes: 1024 * 1024, MaxRotatedFiles: 1}, ELogFormat.Text(), sl)
+	logger.OpenLog()
+
+	logger.Log(pipeline.LogInfo, "just info")
+	logger.Log(pipeline.LogWarning, "a warning")
+	logger.Log(pipeline.LogError, "an error on\n   PUT https://acct.blob.core.windows.net/c/b?sig=secretvalue\n")
+	logger.CloseLog()
+	sl.Flush(time.Minute)
+
+	c.Assert(w.written(), chk.DeepEquals, []string{
+		"WARN Job " + jobID.String() + ": a warning",
+		"ERR Job " + jobID.String() + ": an error on | PUT https://acct.blob.core.windows.net/c/b?sig=-REDACTED-",
+	})
+}
+
+func (s *systemLoggerSuite) TestSlowSystemLogDropsInsteadOfBlocking(c *chk.C) {
+	w := &recordingSystemLogWriter{blocked: make(chan struct{})}
+	sl := newSystemLogger(w)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*cap(sl.unsentQueue); i++ {
+			sl.Log(pipeline.LogWarning, "msg")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		c.Fatal("logging blocked")
+	}
+	c.Assert(sl.DroppedCount() >= int64(cap(sl.unsentQueue)-1), chk.Equals, true)
+
+	// a flush gives up, rather than waiting forever
+	sl.Flush(10 * time.Millisecond)
+
+	close(w.blocked)
+	sl.Flush(time.Minute)
+	c.Assert(strings.HasPrefix(w.written()[0], "WARN msg"), chk.Equals, true)
+}
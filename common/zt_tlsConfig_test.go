@@ -0,0 +1,106 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type tlsConfigSuite struct{}
+
+var _ = chk.Suite(&tlsConfigSuite{})
+
+func (s *tlsConfigSuite) TestDefaultsGiveNoConfig(c *chk.C) {
+	cfg, err := NewTLSConfig("", "", false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(cfg, chk.IsNil)
+}
+
+func (s *tlsConfigSuite) TestMinVersion(c *chk.C) {
+	cfg, err := NewTLSConfig("", "1.3", false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(cfg.MinVersion, chk.Equals, uint16(tls.VersionTLS13))
+
+	_, err = NewTLSConfig("", "1.1", false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "1.1"), chk.Equals, true)
+}
+
+func (s *tlsConfigSuite) TestInvalidBundleNamesTheFile(c *chk.C) {
+	dir, err := ioutil.TempDir("", "tlsConfigSuite")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	missing := filepath.Join(dir, "missing.pem")
+	_, err = NewTLSConfig(missing, "", false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), missing), chk.Equals, true)
+
+	notPEM := filepath.Join(dir, "notpem.pem")
+	c.Assert(ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600), chk.IsNil)
+	_, err = NewTLSConfig(notPEM, "", false)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), notPEM), chk.Equals, true)
+}
+
+func (s *tlsConfigSuite) TestTrustedBundleIsUsedByTheTunnel(c *chk.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tlsConfigSuite")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	bundle := filepath.Join(dir, "ca.pem")
+	c.Assert(ioutil.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600), chk.IsNil)
+
+	tunnel := &ProxyTunnel{
+		lookup:           func(req *http.Request) (*url.URL, error) { return nil, nil },
+		dial:             (&net.Dialer{}).DialContext,
+		handshakeTimeout: 10 * time.Second,
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: tunnel.Proxy, DialTLSContext: tunnel.DialTLSContext}}
+
+	// the test server's certificate isn't trusted by the system
+	_, err = client.Get(server.URL)
+	c.Assert(err, chk.NotNil)
+
+	cfg, err := NewTLSConfig(bundle, "", false)
+	c.Assert(err, chk.IsNil)
+	GlobalTLSConfig = cfg
+	defer func() { GlobalTLSConfig = nil }()
+
+	resp, err := client.Get(server.URL)
+	c.Assert(err, chk.IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, chk.Equals, http.StatusOK)
+}
@@ -38,6 +38,8 @@ import (
 var glcm = common.GetLifecycleMgr()
 
 func main() {
+	defer glcm.CloseOnPanic() // e.g. so that the snapshots a job reads from are deleted
+
 	pipeline.SetLogSanitizer(common.NewAzCopyLogSanitizer()) // make sure ForceLog logs get secrets redacted
 
 	rand.Seed(time.Now().UnixNano()) // make sure our random numbers actually are random (but remember, use crypto/rand for anything where strong/reliable randomness is required
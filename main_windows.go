@@ -21,13 +21,16 @@
 package main
 
 import (
+	"context"
 	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/Azure/azure-storage-azcopy/common"
 )
@@ -67,5 +70,12 @@ func GetAzCopyAppPath() string {
 
 func init() {
 	//Catch everything that uses http.DefaultTransport with ieproxy.GetProxyFunc()
-	http.DefaultTransport.(*http.Transport).Proxy = common.GlobalProxyLookup
+	//The tunnel answers the authentication challenges of the proxy, with the credentials of the current user if need be
+	//The dialer is made for each dial, since the local address it binds to is only known once the command line is parsed
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return common.BindToLocalAddr(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext(ctx, network, addr)
+	}
+	proxyTunnel := common.NewProxyTunnel(dial, 10*time.Second)
+	http.DefaultTransport.(*http.Transport).Proxy = proxyTunnel.Proxy
+	http.DefaultTransport.(*http.Transport).DialTLSContext = proxyTunnel.DialTLSContext
 }
@@ -30,6 +30,17 @@ type hasResponse interface {
 	Response() *http.Response
 }
 
+// ClientRequestID gets the x-ms-client-request-id that AzCopy gave the failed request, which matches it to the logs of the service.
+// Returns "" if there isn't one
+func (errex ErrorEx) ClientRequestID() string {
+	if respErr, ok := errex.error.(hasResponse); ok {
+		if r := respErr.Response(); r != nil && r.Request != nil {
+			return r.Request.Header.Get(clientRequestIDHeader)
+		}
+	}
+	return ""
+}
+
 // MSRequestID gets the request ID guid associated with the failed request.
 // Returns "" if there isn't one (either no request, or there is a request but it doesn't have the header)
 func (errex ErrorEx) MSRequestID() string {
@@ -1,11 +1,13 @@
 package ste
 
 import (
+	"crypto/aes"
 	"errors"
 	"reflect"
 	"unsafe"
 
 	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-storage-azcopy/common"
 	"github.com/Azure/azure-storage-blob-go/azblob"
@@ -14,12 +16,18 @@ import (
 // dataSchemaVersion defines the data schema version of JobPart order files supported by
 // current version of azcopy
 // To be Incremented every time when we release azcopy with changed dataSchema
-const DataSchemaVersion common.Version = 10
+const DataSchemaVersion common.Version = 38
 
 const (
-	CustomHeaderMaxBytes = 256
-	MetadataMaxBytes     = 1000 // If > 65536, then jobPartPlanBlobData's MetadataLength field's type must change
-	BlobTierMaxBytes     = 10
+	CustomHeaderMaxBytes          = 256
+	MetadataMaxBytes              = 1000 // If > 65536, then jobPartPlanBlobData's MetadataLength field's type must change
+	BlobTierMaxBytes              = 10
+	BlobTagsMaxBytes              = 4000 // enough for the service limit of 10 tags, with 128 character keys and 256 character values
+	HeaderRulesMaxBytes           = 4000 // the header rules of a job, one per line
+	SnapshotMaxBytes              = 64   // snapshots are timestamps, like 2019-01-01T00:00:00.0000000Z
+	TempSuffixMaxBytes            = 64
+	ListingMarkerMaxBytes         = 1024 // the continuation markers of the listings are opaque, but a blob's holds its name, which is at most 1024 characters
+	ClientRequestIDPrefixMaxBytes = 64
 )
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -66,6 +74,75 @@ type JobPartPlanHeader struct {
 	DestLengthValidation bool
 	// S2SInvalidMetadataHandleOption represents how user wants to handle invalid metadata.
 	S2SInvalidMetadataHandleOption common.InvalidMetadataHandleOption
+	// S2SFallback represents what user wants to do when the destination can't read the source of a S2S copy.
+	S2SFallback common.S2SFallback
+	// S2SComputeMD5 represents which destination block blobs of a S2S copy have their Content-MD5 computed by this machine, after they're written
+	S2SComputeMD5 common.S2SComputeMD5
+	// DiffBaseSnapshot is the snapshot of the source page blobs that the destinations already hold, if only the pages that changed since then are to be copied
+	DiffBaseSnapshotLength uint8
+	DiffBaseSnapshot       [SnapshotMaxBytes]byte
+	// ReuseUncommittedBlocks represents whether the blocks that were staged, but not committed, by an earlier upload of the same source are kept
+	ReuseUncommittedBlocks bool
+	// AppendOnly represents whether the uploads to append blobs and ADLS Gen2 files only append the bytes that the destination doesn't have yet
+	AppendOnly bool
+	// RangedDownload represents whether only the window of the source that starts at DownloadOffset is downloaded
+	RangedDownload bool
+	DownloadOffset int64
+	// DeleteSourceAfterTransfer represents whether the source of each transfer is deleted once the transfer has succeeded
+	DeleteSourceAfterTransfer bool
+	// ClearArchiveBit represents whether the archive attribute of each local source file is cleared once its upload has succeeded
+	ClearArchiveBit bool
+	// SkipPermissionErrors and SkipLockedFiles represent whether local source files that can't be opened,
+	// for lack of permission or because another process holds them open, are skipped rather than failed
+	SkipPermissionErrors bool
+	SkipLockedFiles      bool
+	// PerFileAttributes represents whether the content headers and metadata of each transfer are its own,
+	// in which case those that are set replace the ones in DstBlobData at its destination
+	PerFileAttributes bool
+	// BreakLeaseOnOverwrite represents whether the lease of a destination blob that is in the way of a write, or of its deletion, is broken
+	BreakLeaseOnOverwrite bool
+	// ProtectDestinationWithLease represents whether each destination blob, or BlobFS file, is leased while it's written
+	ProtectDestinationWithLease bool
+	// DestinationConditionIfNoneMatch and DestinationConditionIfUnmodifiedSince (in Unix nanoseconds, or zero) represent the condition that
+	// each write of a whole destination blob, or BlobFS file, must meet, for the destination not to be skipped
+	DestinationConditionIfNoneMatch       bool
+	DestinationConditionIfUnmodifiedSince int64
+	// ForceIfReadOnly represents whether a destination Azure file whose ReadOnly attribute is in the way of its overwrite has it cleared
+	ForceIfReadOnly bool
+	// SharingViolationRetryWindow represents how long the writes to a destination Azure file that something else has open over SMB are retried
+	SharingViolationRetryWindow time.Duration
+	// ShareFullWaitWindow represents how long the transfers to an Azure file share that's full wait for its quota to be raised
+	ShareFullWaitWindow time.Duration
+	// TempNameSuffix is the suffix of the temporary names that ADLS Gen2 and Azure Files destinations are written under,
+	// before they're renamed to their own. Empty if they're written under their own names
+	TempNameSuffixLength uint8
+	TempNameSuffix       [TempSuffixMaxBytes]byte
+	// TransferOrder represents the order in which the transfers of the part are scheduled
+	TransferOrder common.TransferOrder
+	// FlushPolicy represents when the downloaded files are flushed to stable storage, and FlushIntervalBytes,
+	// if they're flushed as they're written, every how many bytes
+	FlushPolicy        common.FlushPolicy
+	FlushIntervalBytes int64
+	// ListingMarker is the continuation marker of the listing of the source that the enumeration had reached when the part was ordered.
+	// The listing can continue from it without missing anything that isn't in this part or an earlier one. Empty if there's no such marker
+	ListingMarkerLength uint16
+	ListingMarker       [ListingMarkerMaxBytes]byte
+	// ClientRequestIDPrefix is what the x-ms-client-request-id of each request of the job starts with, so that the requests of the job
+	// can be told apart in the logs of the service. Empty if it's the ID of the job
+	ClientRequestIDPrefixLength uint8
+	ClientRequestIDPrefix       [ClientRequestIDPrefixMaxBytes]byte
+	// MissingSourceHandling represents what happens to the transfers whose source was deleted after it was enumerated
+	MissingSourceHandling common.MissingSourceHandling
+	// SoftDeletedDestinationHandling represents what happens to the transfers whose destination blob is soft-deleted
+	SoftDeletedDestinationHandling common.SoftDeletedDestinationHandling
+	// PreserveSourceLMTAsMetadata represents whether the last modified time of each source is kept in the metadata of its destination,
+	// under common.PreservedLastModifiedTimeMetadataKey
+	PreserveSourceLMTAsMetadata bool
+	// StringsEncrypted represents whether the roots, the command string and the strings of the transfers are encrypted,
+	// with the key of AZCOPY_PLAN_ENCRYPTION_KEY that PlanKeyCheck is the check value of, and the IV of this part
+	StringsEncrypted bool
+	PlanIV           [aes.BlockSize]byte
+	PlanKeyCheck     [planKeyCheckLength]byte
 
 	// Any fields below this comment are NOT constants; they may change over as the job part is processed.
 	// Care must be taken to read/write to these fields in a thread-safe way!
@@ -77,6 +154,9 @@ type JobPartPlanHeader struct {
 
 	// For delete operation specify what to do with snapshots
 	DeleteSnapshotsOption common.DeleteSnapshotsOption
+
+	// For set properties operation, specify which properties are to be changed
+	SetPropertiesFlags common.SetPropertiesFlags
 }
 
 // Status returns the job status stored in JobPartPlanHeader in thread-safe manner
@@ -101,48 +181,66 @@ func (jpph *JobPartPlanHeader) Transfer(transferIndex uint32) *JobPartPlanTransf
 	return (*JobPartPlanTransfer)(unsafe.Pointer((uintptr(unsafe.Pointer(jpph)) + unsafe.Sizeof(*jpph) + uintptr(jpph.CommandStringLength)) + (unsafe.Sizeof(JobPartPlanTransfer{}) * uintptr(transferIndex))))
 }
 
+// ListingMarkerReached returns the continuation marker of the listing of the source that had been reached when the part was ordered
+func (jpph *JobPartPlanHeader) ListingMarkerReached() string {
+	return jpph.readString(int64(unsafe.Offsetof(jpph.ListingMarker)), int(jpph.ListingMarkerLength))
+}
+
+// clientRequestIDPrefix returns the prefix of the x-ms-client-request-ids that the user gave, which is empty if they gave none
+func (jpph *JobPartPlanHeader) clientRequestIDPrefix() string {
+	return string(jpph.ClientRequestIDPrefix[:jpph.ClientRequestIDPrefixLength])
+}
+
+// DestinationRootString returns the root of the destinations of the part's transfers, which is the same for every part,
+// unless the destination of the job is sharded across containers
+func (jpph *JobPartPlanHeader) DestinationRootString() string {
+	return jpph.readString(int64(unsafe.Offsetof(jpph.DestinationRoot)), int(jpph.DestinationRootLength))
+}
+
 // CommandString returns the command string given by user when job was created
 func (jpph *JobPartPlanHeader) CommandString() string {
-	commandSlice := []byte{}
-	sh := (*reflect.SliceHeader)(unsafe.Pointer(&commandSlice))
-	sh.Data = uintptr(unsafe.Pointer(jpph)) + uintptr(unsafe.Sizeof(*jpph)) // Address of Job Part Plan + Command String Length
-	sh.Len = int(jpph.CommandStringLength)
-	sh.Cap = sh.Len
-	return string(commandSlice)
+	return jpph.readString(int64(unsafe.Sizeof(*jpph)), int(jpph.CommandStringLength)) // right after the Job Part Plan header
 }
 
 // TransferSrcDstDetail returns the source and destination string for a transfer at given transferIndex in JobPartOrder
 func (jpph *JobPartPlanHeader) TransferSrcDstStrings(transferIndex uint32) (source, destination string) {
-	srcRoot := string(jpph.SourceRoot[:jpph.SourceRootLength])
-	dstRoot := string(jpph.DestinationRoot[:jpph.DestinationRootLength])
+	srcRoot := jpph.readString(int64(unsafe.Offsetof(jpph.SourceRoot)), int(jpph.SourceRootLength))
+	dstRoot := jpph.DestinationRootString()
 
-	jppt := jpph.Transfer(transferIndex)
+	srcRelative, dstRelative := jpph.TransferSrcDstRelatives(transferIndex)
+	return common.GenerateFullPath(srcRoot, srcRelative), common.GenerateFullPath(dstRoot, dstRelative)
+}
 
-	srcSlice := []byte{}
-	sh := (*reflect.SliceHeader)(unsafe.Pointer(&srcSlice))
-	sh.Data = uintptr(unsafe.Pointer(jpph)) + uintptr(jppt.SrcOffset) // Address of Job Part Plan + this transfer's src string offset
-	sh.Len = int(jppt.SrcLength)
-	sh.Cap = sh.Len
-	srcRelative := string(srcSlice)
+// TransferSrcDstRelatives returns the paths of the source and the destination of the transfer, relative to their roots
+func (jpph *JobPartPlanHeader) TransferSrcDstRelatives(transferIndex uint32) (srcRelative, dstRelative string) {
+	jppt := jpph.Transfer(transferIndex)
 
-	dstSlice := []byte{}
-	sh = (*reflect.SliceHeader)(unsafe.Pointer(&dstSlice))
-	sh.Data = uintptr(unsafe.Pointer(jpph)) + uintptr(jppt.SrcOffset) + uintptr(jppt.SrcLength) // Address of Job Part Plan + this transfer's src string offset + length of this transfer's src string
-	sh.Len = int(jppt.DstLength)
-	sh.Cap = sh.Len
-	dstRelative := string(dstSlice)
+	srcRelative = jpph.getString(jppt.SrcOffset, jppt.SrcLength)
+	dstRelative = jpph.getString(jppt.SrcOffset+int64(jppt.SrcLength), jppt.DstLength) // the destination string follows this transfer's src string
 
-	return common.GenerateFullPath(srcRoot, srcRelative), common.GenerateFullPath(dstRoot, dstRelative)
+	return srcRelative, dstRelative
 }
 
 func (jpph *JobPartPlanHeader) getString(offset int64, length int16) string {
+	return jpph.readString(offset, int(length))
+}
+
+// readString returns the string at the given offset of the plan, decrypted if the plan's strings are encrypted
+func (jpph *JobPartPlanHeader) readString(offset int64, length int) string {
 	tempSlice := []byte{}
 	sh := (*reflect.SliceHeader)(unsafe.Pointer(&tempSlice))
 	sh.Data = uintptr(unsafe.Pointer(jpph)) + uintptr(offset) // Address of Job Part Plan + this string's offset
-	sh.Len = int(length)
+	sh.Len = length
 	sh.Cap = sh.Len
 
-	return string(tempSlice)
+	if !jpph.StringsEncrypted || length == 0 {
+		return string(tempSlice)
+	}
+
+	common.PanicIfErr(jpph.CheckPlanKey())
+	plain := make([]byte, length)
+	currentPlanKey().streamAt(jpph.PlanIV, offset).XORKeyStream(plain, tempSlice)
+	return string(plain)
 }
 
 // TransferSrcPropertiesAndMetadata returns the SrcHTTPHeaders, properties and metadata for a transfer at given transferIndex in JobPartOrder
@@ -253,6 +351,14 @@ type JobPartPlanDstBlob struct {
 	MetadataLength uint16
 	Metadata       [MetadataMaxBytes]byte
 
+	// Specifies the index tags to apply, when setting properties
+	BlobTagsLength uint16
+	BlobTags       [BlobTagsMaxBytes]byte
+
+	// Specifies the header rules of an upload, one per line, in the order in which they are matched
+	HeaderRulesLength uint16
+	HeaderRules       [HeaderRulesMaxBytes]byte
+
 	// Specifies the maximum size of block which determines the number of chunks and chunk size of a transfer
 	BlockSize uint32
 }
@@ -314,6 +420,27 @@ type JobPartPlanTransfer struct {
 	// atomicErrorCode has a default value (0) which means either there was no error or transfer failed because some non storageError.
 	// atomicErrorCode should not be directly accessed anywhere except by transferStatus and setTransferStatus
 	atomicErrorCode int32
+
+	// atomicSavedLength represents how much of the start of the destination file of a download is durably saved,
+	// and atomicSavedModTime the modification time (in nanoseconds) that the file had then, so that a resumed download can carry on from it.
+	// They should not be directly accessed anywhere except by SavedDownload and SetSavedDownload
+	atomicSavedLength  int64
+	atomicSavedModTime int64
+
+	// atomicSourceDeleted is 1 once the source of a transfer that succeeded is deleted, as the job's DeleteSourceAfterTransfer asks for.
+	// It should not be directly accessed anywhere except by SourceDeleted and SetSourceDeleted
+	atomicSourceDeleted uint32
+
+	// atomicSoftDeletedDestination is what was done about the destination of the transfer being soft-deleted, as the job's
+	// SoftDeletedDestinationHandling asks for. It's Ignore unless the destination was found soft-deleted, or when the transfer was skipped for it.
+	// It should not be directly accessed anywhere except by SoftDeletedDestination and SetSoftDeletedDestination
+	atomicSoftDeletedDestination uint32
+
+	// atomicBytesDone is how many bytes of the transfer were sent (or received) since atomicStartTime (in nanoseconds),
+	// when this attempt at it started. They're kept in the plan so that 'jobs show' can tell the progress of large files too.
+	// They should not be directly accessed anywhere except by StartProgress, AddBytesDone and Progress
+	atomicBytesDone int64
+	atomicStartTime int64
 }
 
 // TransferStatus returns the transfer's status
@@ -338,6 +465,61 @@ func (jppt *JobPartPlanTransfer) SetTransferStatus(status common.TransferStatus,
 	}
 }
 
+// SavedDownload returns how much of the destination file of a download is durably saved, and the modification time of the file then
+func (jppt *JobPartPlanTransfer) SavedDownload() (savedLength int64, modTime time.Time) {
+	savedLength = atomic.LoadInt64(&jppt.atomicSavedLength)
+	return savedLength, time.Unix(0, atomic.LoadInt64(&jppt.atomicSavedModTime))
+}
+
+// SetSavedDownload records how much of the destination file of a download is durably saved. A length of zero means
+// that a later attempt must start over
+func (jppt *JobPartPlanTransfer) SetSavedDownload(savedLength int64, modTime time.Time) {
+	// the time is stored first, so that a length is never read with the time of an earlier length
+	atomic.StoreInt64(&jppt.atomicSavedModTime, modTime.UnixNano())
+	atomic.StoreInt64(&jppt.atomicSavedLength, savedLength)
+}
+
+// StartProgress notes that an attempt at the transfer starts, with none of its bytes done yet
+func (jppt *JobPartPlanTransfer) StartProgress(now time.Time) {
+	atomic.StoreInt64(&jppt.atomicBytesDone, 0)
+	atomic.StoreInt64(&jppt.atomicStartTime, now.UnixNano())
+}
+
+// AddBytesDone adds the bytes of a chunk that was done to the progress of the transfer
+func (jppt *JobPartPlanTransfer) AddBytesDone(n int64) {
+	atomic.AddInt64(&jppt.atomicBytesDone, n)
+}
+
+// Progress returns the bytes that the current attempt at the transfer did, and when it started.
+// The start time is zero if the transfer was never started
+func (jppt *JobPartPlanTransfer) Progress() (bytesDone int64, started time.Time) {
+	startTime := atomic.LoadInt64(&jppt.atomicStartTime)
+	if startTime == 0 {
+		return 0, time.Time{}
+	}
+	return atomic.LoadInt64(&jppt.atomicBytesDone), time.Unix(0, startTime)
+}
+
+// SourceDeleted tells whether the source of the transfer was deleted after the transfer succeeded
+func (jppt *JobPartPlanTransfer) SourceDeleted() bool {
+	return atomic.LoadUint32(&jppt.atomicSourceDeleted) == 1
+}
+
+// SetSourceDeleted records that the source of the transfer was deleted
+func (jppt *JobPartPlanTransfer) SetSourceDeleted() {
+	atomic.StoreUint32(&jppt.atomicSourceDeleted, 1)
+}
+
+// SoftDeletedDestination tells what was done about the destination of the transfer being soft-deleted
+func (jppt *JobPartPlanTransfer) SoftDeletedDestination() common.SoftDeletedDestinationHandling {
+	return common.SoftDeletedDestinationHandling(atomic.LoadUint32(&jppt.atomicSoftDeletedDestination))
+}
+
+// SetSoftDeletedDestination records what was done about the destination of the transfer being soft-deleted
+func (jppt *JobPartPlanTransfer) SetSoftDeletedDestination(handling common.SoftDeletedDestinationHandling) {
+	atomic.StoreUint32(&jppt.atomicSoftDeletedDestination, uint32(handling))
+}
+
 // ErrorCode returns the transfer's errorCode.
 func (jppt *JobPartPlanTransfer) ErrorCode() int32 {
 	return atomic.LoadInt32(&jppt.atomicErrorCode)
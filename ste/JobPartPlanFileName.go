@@ -70,6 +70,11 @@ func (jpfn JobPartPlanFileName) Map() *JobPartPlanMMF {
 
 // createJobPartPlanFile creates the memory map JobPartPlanHeader using the given JobPartOrder and JobPartPlanBlobData
 func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
+	createJobPartPlanFile(jpfn.GetJobPartPlanPath(), order, currentPlanKey())
+}
+
+// createJobPartPlanFile writes the plan of the part at planPath, with its strings encrypted if there's a key
+func createJobPartPlanFile(planPath string, order common.CopyJobPartOrderRequest, key *planKey) {
 	// Validate that the passed-in strings can fit in their respective fields
 	if len(order.SourceRoot) > len(JobPartPlanHeader{}.SourceRoot) {
 		panic(fmt.Errorf("source root string is too large: %q", order.SourceRoot))
@@ -95,6 +100,12 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	if len(order.BlobAttributes.Metadata) > len(JobPartPlanDstBlob{}.Metadata) {
 		panic(fmt.Errorf("metadata string is too large: %q", order.BlobAttributes.Metadata))
 	}
+	if len(order.BlobAttributes.BlobTagsString) > len(JobPartPlanDstBlob{}.BlobTags) {
+		panic(fmt.Errorf("blob tags string is too large: %q", order.BlobAttributes.BlobTagsString))
+	}
+	if len(order.BlobAttributes.HeaderRules) > len(JobPartPlanDstBlob{}.HeaderRules) {
+		panic(fmt.Errorf("header rules are too large: %q", order.BlobAttributes.HeaderRules))
+	}
 
 	// This nested function writes a structure value to an io.Writer & returns the number of bytes written
 	writeValue := func(writer io.Writer, v interface{}) int64 {
@@ -120,9 +131,9 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 
 	// create the Job Part Plan file
 	//planPathname := planDir + "/" + string(jpfn)
-	file, err := os.Create(jpfn.GetJobPartPlanPath())
+	file, err := os.Create(planPath)
 	if err != nil {
-		panic(fmt.Errorf("couldn't create job part plan file %q: %v", jpfn, err))
+		panic(fmt.Errorf("couldn't create job part plan file %q: %v", planPath, err))
 	}
 	defer file.Close()
 
@@ -174,6 +185,8 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 			BlockBlobTier:            order.BlobAttributes.BlockBlobTier,
 			PageBlobTier:             order.BlobAttributes.PageBlobTier,
 			MetadataLength:           uint16(len(order.BlobAttributes.Metadata)),
+			BlobTagsLength:           uint16(len(order.BlobAttributes.BlobTagsString)),
+			HeaderRulesLength:        uint16(len(order.BlobAttributes.HeaderRules)),
 			BlockSize:                blockSize,
 		},
 		DstLocalData: JobPartPlanDstLocal{
@@ -181,12 +194,43 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 			MD5VerificationOption:    order.BlobAttributes.MD5ValidationOption, // here because it relates to downloads (file destination)
 		},
 		// For S2S copy, per JobPartPlan info
-		S2SGetPropertiesInBackend:      order.S2SGetPropertiesInBackend,
-		S2SSourceChangeValidation:      order.S2SSourceChangeValidation,
-		S2SInvalidMetadataHandleOption: order.S2SInvalidMetadataHandleOption,
-		DestLengthValidation:           order.DestLengthValidation,
-		atomicJobStatus:                common.EJobStatus.InProgress(), // We default to InProgress
-		DeleteSnapshotsOption:          order.BlobAttributes.DeleteSnapshotsOption,
+		S2SGetPropertiesInBackend:       order.S2SGetPropertiesInBackend,
+		S2SSourceChangeValidation:       order.S2SSourceChangeValidation,
+		S2SInvalidMetadataHandleOption:  order.S2SInvalidMetadataHandleOption,
+		S2SFallback:                     order.S2SFallback,
+		S2SComputeMD5:                   order.S2SComputeMD5,
+		DiffBaseSnapshotLength:          uint8(len(order.DiffBaseSnapshot)),
+		ReuseUncommittedBlocks:          order.ReuseUncommittedBlocks,
+		AppendOnly:                      order.AppendOnly,
+		RangedDownload:                  order.RangedDownload,
+		DownloadOffset:                  order.DownloadOffset,
+		DeleteSourceAfterTransfer:       order.DeleteSourceAfterTransfer,
+		ClearArchiveBit:                 order.ClearArchiveBit,
+		SkipPermissionErrors:            order.SkipPermissionErrors,
+		SkipLockedFiles:                 order.SkipLockedFiles,
+		PerFileAttributes:               order.PerFileAttributes,
+		BreakLeaseOnOverwrite:           order.BreakLeaseOnOverwrite,
+		ProtectDestinationWithLease:     order.ProtectDestinationWithLease,
+		DestinationConditionIfNoneMatch: order.DestinationCondition.IfNoneMatch,
+		ForceIfReadOnly:                 order.ForceIfReadOnly,
+		SharingViolationRetryWindow:     order.SharingViolationRetryWindow,
+		ShareFullWaitWindow:             order.ShareFullWaitWindow,
+		TempNameSuffixLength:            uint8(len(order.TempNameSuffix)),
+		TransferOrder:                   order.TransferOrder,
+		FlushPolicy:                     order.FlushPolicy,
+		FlushIntervalBytes:              order.FlushIntervalBytes,
+		ListingMarkerLength:             uint16(len(order.ListingMarker)),
+		ClientRequestIDPrefixLength:     uint8(len(order.ClientRequestIDPrefix)),
+		MissingSourceHandling:           order.MissingSourceHandling,
+		SoftDeletedDestinationHandling:  order.SoftDeletedDestinationHandling,
+		PreserveSourceLMTAsMetadata:     order.PreserveSourceLMTAsMetadata,
+		DestLengthValidation:            order.DestLengthValidation,
+		atomicJobStatus:                 common.EJobStatus.InProgress(), // We default to InProgress
+		DeleteSnapshotsOption:           order.BlobAttributes.DeleteSnapshotsOption,
+		SetPropertiesFlags:              order.BlobAttributes.SetPropertiesFlags,
+	}
+	if !order.DestinationCondition.IfUnmodifiedSince.IsZero() {
+		jpph.DestinationConditionIfUnmodifiedSince = order.DestinationCondition.IfUnmodifiedSince.UnixNano()
 	}
 
 	// Copy any strings into their respective fields
@@ -198,11 +242,37 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	copy(jpph.DstBlobData.ContentDisposition[:], order.BlobAttributes.ContentDisposition)
 	copy(jpph.DstBlobData.CacheControl[:], order.BlobAttributes.CacheControl)
 	copy(jpph.DstBlobData.Metadata[:], order.BlobAttributes.Metadata)
+	copy(jpph.DstBlobData.BlobTags[:], order.BlobAttributes.BlobTagsString)
+	copy(jpph.DstBlobData.HeaderRules[:], order.BlobAttributes.HeaderRules)
+	copy(jpph.DiffBaseSnapshot[:], order.DiffBaseSnapshot)
+	copy(jpph.TempNameSuffix[:], order.TempNameSuffix)
+	copy(jpph.ListingMarker[:], order.ListingMarker)
+	copy(jpph.ClientRequestIDPrefix[:], order.ClientRequestIDPrefix)
+
+	// The roots are encrypted in place, and the rest of the strings as they're written
+	if key != nil {
+		jpph.StringsEncrypted = true
+		jpph.PlanIV = newPlanIV()
+		jpph.PlanKeyCheck = key.keyCheck
+		sourceRoot := jpph.SourceRoot[:jpph.SourceRootLength]
+		key.streamAt(jpph.PlanIV, int64(unsafe.Offsetof(jpph.SourceRoot))).XORKeyStream(sourceRoot, sourceRoot)
+		destinationRoot := jpph.DestinationRoot[:jpph.DestinationRootLength]
+		key.streamAt(jpph.PlanIV, int64(unsafe.Offsetof(jpph.DestinationRoot))).XORKeyStream(destinationRoot, destinationRoot)
+		listingMarker := jpph.ListingMarker[:jpph.ListingMarkerLength]
+		key.streamAt(jpph.PlanIV, int64(unsafe.Offsetof(jpph.ListingMarker))).XORKeyStream(listingMarker, listingMarker)
+	}
+	newStringWriter := func(offset int64) *planStringWriter {
+		w := &planStringWriter{w: file}
+		if key != nil {
+			w.stream = key.streamAt(jpph.PlanIV, offset)
+		}
+		return w
+	}
 
 	eof += writeValue(file, &jpph)
 
 	// write the command string in the JobPart Plan file
-	bytesWritten, err := file.WriteString(order.CommandString)
+	bytesWritten, err := newStringWriter(eof).WriteString(order.CommandString)
 	if err != nil {
 		panic(err)
 	}
@@ -256,6 +326,10 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 			atomicTransferStatus: common.ETransferStatus.Started(), // Default
 			//ChunkNum:                getNumChunks(uint64(order.Transfers[t].SourceSize), uint64(data.BlockSize)),
 		}
+		if order.Transfers[t].ExistsAtDestination {
+			// the front end already knows it's there, so the transfer is skipped in bulk, without it being scheduled
+			jppt.atomicTransferStatus = common.ETransferStatus.SkippedFileAlreadyExists()
+		}
 		eof += writeValue(file, &jppt) // Write the transfer entry
 
 		// The NEXT transfer's src/dst string come after THIS transfer's src/dst strings
@@ -268,6 +342,7 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	}
 
 	// All the transfers were written; now write each transfer's src/dst strings
+	stringWriter := newStringWriter(eof)
 	for t := range order.Transfers {
 		// Sanity check: Verify that we are were we think we are and that no bug has occurred
 		if eof != srcDstStringsOffset[t] {
@@ -275,42 +350,42 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 		}
 
 		// Write the src & dst strings to the job part plan file
-		bytesWritten, err := file.WriteString(order.Transfers[t].Source)
+		bytesWritten, err := stringWriter.WriteString(order.Transfers[t].Source)
 		common.PanicIfErr(err)
 		eof += int64(bytesWritten)
 		// write the destination string in memory map file
-		bytesWritten, err = file.WriteString(order.Transfers[t].Destination)
+		bytesWritten, err = stringWriter.WriteString(order.Transfers[t].Destination)
 		common.PanicIfErr(err)
 		eof += int64(bytesWritten)
 
 		// For S2S copy (and, in the case of Content-MD5, always), write the src properties
 		if len(order.Transfers[t].ContentType) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers[t].ContentType)
+			bytesWritten, err = stringWriter.WriteString(order.Transfers[t].ContentType)
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
 		if len(order.Transfers[t].ContentEncoding) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers[t].ContentEncoding)
+			bytesWritten, err = stringWriter.WriteString(order.Transfers[t].ContentEncoding)
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
 		if len(order.Transfers[t].ContentLanguage) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers[t].ContentLanguage)
+			bytesWritten, err = stringWriter.WriteString(order.Transfers[t].ContentLanguage)
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
 		if len(order.Transfers[t].ContentDisposition) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers[t].ContentDisposition)
+			bytesWritten, err = stringWriter.WriteString(order.Transfers[t].ContentDisposition)
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
 		if len(order.Transfers[t].CacheControl) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers[t].CacheControl)
+			bytesWritten, err = stringWriter.WriteString(order.Transfers[t].CacheControl)
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
 		if order.Transfers[t].ContentMD5 != nil { // if non-nil but 0 len, will simply not be read by the consumer (since length is zero)
-			bytesWritten, err = file.WriteString(string(order.Transfers[t].ContentMD5))
+			bytesWritten, err = stringWriter.WriteString(string(order.Transfers[t].ContentMD5))
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
@@ -319,17 +394,17 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 			metadataStr, err := order.Transfers[t].Metadata.Marshal()
 			common.PanicIfErr(err)
 
-			bytesWritten, err = file.WriteString(metadataStr)
+			bytesWritten, err = stringWriter.WriteString(metadataStr)
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
 		if len(order.Transfers[t].BlobType) != 0 {
-			bytesWritten, err = file.WriteString(string(order.Transfers[t].BlobType))
+			bytesWritten, err = stringWriter.WriteString(string(order.Transfers[t].BlobType))
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
 		if len(order.Transfers[t].BlobTier) != 0 {
-			bytesWritten, err = file.WriteString(string(order.Transfers[t].BlobTier))
+			bytesWritten, err = stringWriter.WriteString(string(order.Transfers[t].BlobTier))
 			common.PanicIfErr(err)
 			eof += int64(bytesWritten)
 		}
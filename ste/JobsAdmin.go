@@ -22,6 +22,7 @@ package ste
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -78,7 +79,8 @@ var JobsAdmin interface {
 	/*ScheduleTransfer(jptm IJobPartTransferMgr)*/
 	ScheduleChunk(priority common.JobPriority, chunkFunc chunkFunc)
 
-	ResurrectJob(jobId common.JobID, sourceSAS string, destinationSAS string) bool
+	// ResurrectJob returns errNoJobPlans if the job has no plan files, and the reason its plans can't be read if they can't
+	ResurrectJob(jobId common.JobID, sourceSAS string, destinationSAS string, destinationShardSAS map[string]string) error
 
 	ResurrectJobParts()
 
@@ -103,11 +105,16 @@ var JobsAdmin interface {
 	common.ILoggerCloser
 
 	CurrentMainPoolSize() int
+	SetMainPoolSize(size int)
+	RequestedMainPoolSize() int
+
+	SetMbpsCap(mbps int64)
+	MbpsCap() int64
 
 	RequestTuneSlowly()
 }
 
-func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targetRateInMegaBitsPerSec int64, azcopyJobPlanFolder string, azcopyLogPathFolder string, providePerfAdvice bool) {
+func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targetRateInMegaBitsPerSec int64, capTransactionsPerSecond int64, capMetadataTransactionsPerSecond int64, scheduling common.ChunkScheduling, azcopyJobPlanFolder string, azcopyLogPathFolder string, logRotation common.LogRotationSettings, logFormat common.LogFormat, systemLogger common.ISystemLogger, providePerfAdvice bool) {
 	if JobsAdmin != nil {
 		panic("initJobsAdmin was already called once")
 	}
@@ -137,55 +144,82 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 	// Create normal & low transfer/chunk channels
 	normalTransferCh, normalChunkCh := make(chan IJobPartTransferMgr, channelSize), make(chan chunkFunc, channelSize)
 	lowTransferCh, lowChunkCh := make(chan IJobPartTransferMgr, channelSize), make(chan chunkFunc, channelSize)
+	// and the transfers that move no data wait in channels of their own, for workers of their own
+	normalMetadataTransferCh, lowMetadataTransferCh := make(chan IJobPartTransferMgr, channelSize), make(chan IJobPartTransferMgr, channelSize)
 
 	maxRamBytesToUse := getMaxRamForChunks()
 
-	// default to a pacer that doesn't actually control the rate
-	// (it just records total throughput, since for historical reasons we do that in the pacer)
-	var pacer pacerAdmin = newNullAutoPacer()
-	if targetRateInMegaBitsPerSec > 0 {
-		// use the "networking mega" (based on powers of 10, not powers of 2, since that's what mega means in networking context)
-		targetRateInBytesPerSec := targetRateInMegaBitsPerSec * 1000 * 1000 / 8
-		unusedExpectedCoarseRequestByteCount := uint32(0)
-		pacer = newTokenBucketPacer(targetRateInBytesPerSec, unusedExpectedCoarseRequestByteCount)
-		// Note: as at July 2019, we don't currently have a shutdown method/event on JobsAdmin where this pacer
-		// could be shut down. But, it's global anyway, so we just leave it running until application exit.
+	// the pacer doesn't control the rate unless there's a cap, but the cap can be set or changed while the job runs
+	// (it also records total throughput, since for historical reasons we do that in the pacer)
+	// Note: as at July 2019, we don't currently have a shutdown method/event on JobsAdmin where this pacer
+	// could be shut down. But, it's global anyway, so we just leave it running until application exit.
+	pacer := newAdjustableCapPacer(megabitsToBytesPerSecond(targetRateInMegaBitsPerSec))
+
+	// the requests of the transfers that move no data can be capped on their own, in which case the cap below doesn't apply to them
+	var metadataTransactionPacer *transactionPacer
+	if capMetadataTransactionsPerSecond > 0 {
+		metadataTransactionPacer = newTransactionPacer(capMetadataTransactionsPerSecond)
+	}
+
+	// the number of requests per second is capped separately, if at all, and the same goes for the shutdown of its pacer
+	var transactionPacer *transactionPacer
+	if capTransactionsPerSecond > 0 {
+		transactionPacer = newTransactionPacer(capTransactionsPerSecond)
+	}
+
+	// the chunks only go through the fair scheduler, rather than the chunk channels, when the scheduling is fair
+	var fairChunkScheduler *fairChunkScheduler
+	if scheduling == common.EChunkScheduling.Fair() {
+		fairChunkScheduler = newFairChunkScheduler(2 * channelSize)
 	}
 
 	ja := &jobsAdmin{
-		concurrency:             concurrency,
-		logger:                  common.NewAppLogger(pipeline.LogInfo, azcopyLogPathFolder),
-		jobIDToJobMgr:           newJobIDToJobMgr(),
-		logDir:                  azcopyLogPathFolder,
-		planDir:                 azcopyJobPlanFolder,
-		pacer:                   pacer,
-		slicePool:               common.NewMultiSizeSlicePool(common.MaxBlockBlobBlockSize),
-		cacheLimiter:            common.NewCacheLimiter(maxRamBytesToUse),
-		fileCountLimiter:        common.NewCacheLimiter(int64(concurrency.MaxOpenDownloadFiles)),
-		cpuMonitor:              cpuMon,
-		appCtx:                  appCtx,
-		commandLineMbpsCap:      targetRateInMegaBitsPerSec,
-		provideBenchmarkResults: providePerfAdvice,
+		concurrency:              concurrency,
+		logger:                   common.NewAppLogger(pipeline.LogInfo, azcopyLogPathFolder),
+		jobIDToJobMgr:            newJobIDToJobMgr(),
+		logDir:                   azcopyLogPathFolder,
+		logRotation:              logRotation,
+		logFormat:                logFormat,
+		systemLogger:             systemLogger,
+		planDir:                  azcopyJobPlanFolder,
+		pacer:                    pacer,
+		transactionPacer:         transactionPacer,
+		metadataTransactionPacer: metadataTransactionPacer,
+		partitionThrottle:        newPartitionThrottle(),
+		directoryLimiter:         newDirectoryLimiter(concurrency.TransfersPerDirectory.Value),
+		fairChunkScheduler:       fairChunkScheduler,
+		slicePool:                common.NewMultiSizeSlicePool(common.MaxBlockBlobBlockSize),
+		cacheLimiter:             common.NewCacheLimiter(maxRamBytesToUse),
+		fileCountLimiter:         common.NewCacheLimiter(int64(concurrency.MaxOpenDownloadFiles)),
+		cpuMonitor:               cpuMon,
+		appCtx:                   appCtx,
+		provideBenchmarkResults:  providePerfAdvice,
 		coordinatorChannels: CoordinatorChannels{
-			partsChannel:     partsCh,
-			normalTransferCh: normalTransferCh,
-			lowTransferCh:    lowTransferCh,
+			partsChannel:             partsCh,
+			normalTransferCh:         normalTransferCh,
+			lowTransferCh:            lowTransferCh,
+			normalMetadataTransferCh: normalMetadataTransferCh,
+			lowMetadataTransferCh:    lowMetadataTransferCh,
 		},
 		xferChannels: XferChannels{
-			partsChannel:     partsCh,
-			normalTransferCh: normalTransferCh,
-			lowTransferCh:    lowTransferCh,
-			normalChunckCh:   normalChunkCh,
-			lowChunkCh:       lowChunkCh,
+			partsChannel:             partsCh,
+			normalTransferCh:         normalTransferCh,
+			lowTransferCh:            lowTransferCh,
+			normalMetadataTransferCh: normalMetadataTransferCh,
+			lowMetadataTransferCh:    lowMetadataTransferCh,
+			normalChunckCh:           normalChunkCh,
+			lowChunkCh:               lowChunkCh,
 		},
 		poolSizingChannels: poolSizingChannels{ // all deliberately unbuffered, because pool sizer routine works in lock-step with these - processing them as they happen, never catching up on populated buffer later
 			entryNotificationCh: make(chan struct{}),
 			exitNotificationCh:  make(chan struct{}),
 			scalebackRequestCh:  make(chan struct{}),
 			requestSlowTuneCh:   make(chan struct{}),
+			resizeRequestCh:     make(chan struct{}, 1), // buffered, so that asking for a new size never waits for the pool sizer
 		},
 		workaroundJobLoggingChannel: make(chan string, 1000), // workaround to support logging from JobsAdmin
 	}
+	ja.atomicMbpsCap = targetRateInMegaBitsPerSec
 	// create new context with the defaultService api version set as value to serviceAPIVersionOverride in the app context.
 	ja.appCtx = context.WithValue(ja.appCtx, ServiceAPIVersionOverride, DefaultServiceApiVersion)
 
@@ -196,11 +230,15 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 	// the tuning results and, in the worst case, leads to "completion" of tuning before any traffic has been sent.
 	ja.concurrencyTuner = ja.createConcurrencyTuner()
 
+	ja.credentialExpiry = newCredentialExpiryGuard(ja.LogToJobLog)
 	JobsAdmin = ja
 
 	// Spin up slice pool pruner
 	go ja.slicePoolPruneLoop()
 
+	// and what schedules the chunks that were set aside for hot partitions again, once they cool down
+	go ja.partitionThrottle.releaseLoop()
+
 	// One routine constantly monitors the partsChannel.  It takes the JobPartManager from
 	// the Channel and schedules the transfers of that JobPart.
 	go ja.scheduleJobParts()
@@ -210,7 +248,14 @@ func initJobsAdmin(appCtx context.Context, concurrency ConcurrencySettings, targ
 	// (Not sure whether that can really happen, but this protects against it anyway.)
 	// Perhaps MORE importantly, doing this separately gives us more CONTROL over how we interact with the file system.
 	for cc := 0; cc < concurrency.TransferInitiationPoolSize.Value; cc++ {
-		go ja.transferProcessor(cc)
+		go ja.transferProcessor(cc, ja.xferChannels.normalTransferCh, ja.xferChannels.lowTransferCh)
+	}
+
+	// The transfers that move no data (deletes and setting properties) are done entirely by the workers that pick them up,
+	// one request after another, so a flood of them would keep the workers above from starting the transfers of data.
+	// They get a bounded pool of their own instead, numbered after the one above
+	for cc := 0; cc < concurrency.MetadataTransferPoolSize.Value; cc++ {
+		go ja.transferProcessor(concurrency.TransferInitiationPoolSize.Value+cc, ja.xferChannels.normalMetadataTransferCh, ja.xferChannels.lowMetadataTransferCh)
 	}
 }
 
@@ -343,7 +388,10 @@ func (ja *jobsAdmin) poolSizer(tuner ConcurrencyTuner) {
 	throughputMonitoringInterval := initialMonitoringInterval
 	slowTuneCh := ja.poolSizingChannels.requestSlowTuneCh
 
-	// get initial pool size
+	// get initial pool size, unless it was already fixed while the job was starting
+	if size := ja.RequestedMainPoolSize(); size > 0 {
+		tuner = &nullConcurrencyTuner{fixedValue: size}
+	}
 	targetConcurrency, reason := tuner.GetRecommendedConcurrency(-1, ja.cpuMonitor.CPUContentionExists())
 	logConcurrency(targetConcurrency, reason)
 
@@ -374,6 +422,11 @@ func (ja *jobsAdmin) poolSizer(tuner ConcurrencyTuner) {
 			// TODO: confirm we don't need this: expandedMonitoringInterval *= 2
 			throughputMonitoringInterval = expandedMonitoringInterval
 			slowTuneCh = nil // so we won't keep running this case at the expense of others)
+		case <-ja.poolSizingChannels.resizeRequestCh:
+			// the size was set while the job runs, which ends any tuning
+			targetConcurrency = ja.RequestedMainPoolSize()
+			tuner = &nullConcurrencyTuner{fixedValue: targetConcurrency}
+			logConcurrency(targetConcurrency, concurrencyReasonRequested)
 		case <-time.After(throughputMonitoringInterval):
 			if actualConcurrency == targetConcurrency { // scalebacks can take time. Don't want to do any tuning if actual is not yet aligned to target
 				bytesOnWire := ja.BytesOverWire()
@@ -422,6 +475,21 @@ func (ja *jobsAdmin) chunkProcessor(workerID int) {
 		case <-ja.poolSizingChannels.scalebackRequestCh:
 			return
 		default:
+			// while the OAuth token is expired, the chunks could only fail, so they wait for it to be renewed
+			if ja.credentialExpiry.expired() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			if ja.fairChunkScheduler != nil {
+				if chunkFunc, ok := ja.fairChunkScheduler.next(); ok {
+					chunkFunc(workerID)
+				} else {
+					time.Sleep(100 * time.Millisecond) // Sleep before looping around, as below
+				}
+				continue
+			}
+
 			select {
 			case chunkFunc := <-ja.xferChannels.normalChunckCh:
 				chunkFunc(workerID)
@@ -443,8 +511,12 @@ func (ja *jobsAdmin) chunkProcessor(workerID int) {
 
 // separate from the chunkProcessor, this dedicated worker that reads in and executes transfer initiation jobs
 // (which in turn schedule chunks that get picked up by chunkProcessor)
-func (ja *jobsAdmin) transferProcessor(workerID int) {
+func (ja *jobsAdmin) transferProcessor(workerID int, normalTransferCh, lowTransferCh <-chan IJobPartTransferMgr) {
 	startTransfer := func(jptm IJobPartTransferMgr) {
+		if !jptm.WasCanceled() && !ja.directoryLimiter.admit(jptm) {
+			return // its directory is busy, so it waits for a place there
+		}
+		jptm.recordPickedUp(workerID)
 		if jptm.WasCanceled() {
 			if jptm.ShouldLog(pipeline.LogInfo) {
 				jptm.Log(pipeline.LogInfo, fmt.Sprintf(" is not picked up worked %d because transfer was cancelled", workerID))
@@ -461,12 +533,17 @@ func (ja *jobsAdmin) transferProcessor(workerID int) {
 
 	for {
 		// No scaleback check here, because this routine runs only in a small number of goroutines, so no need to kill them off
+		if ja.credentialExpiry.expired() { // as above, for the transfers
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
 		select {
-		case jptm := <-ja.xferChannels.normalTransferCh:
+		case jptm := <-normalTransferCh:
 			startTransfer(jptm)
 		default:
 			select {
-			case jptm := <-ja.xferChannels.lowTransferCh:
+			case jptm := <-lowTransferCh:
 				startTransfer(jptm)
 			default:
 				time.Sleep(10 * time.Millisecond) // Sleep before looping around
@@ -483,40 +560,54 @@ type jobsAdmin struct {
 	atomicSuccessfulBytesInActiveFiles int64
 	atomicBytesTransferredWhileTuning  int64
 	atomicTuningEndSeconds             int64
+	atomicMbpsCap                      int64
 	atomicCurrentMainPoolSize          int32 // align 64 bit integers for 32 bit arch
+	atomicRequestedMainPoolSize        int32 // zero unless the size was set while the job runs
 	concurrency                        ConcurrencySettings
 	logger                             common.ILoggerCloser
 	jobIDToJobMgr                      jobIDToJobMgr // Thread-safe map from each JobID to its JobInfo
 	// Other global state can be stored in more fields here...
 	logDir                      string // Where log files are stored
+	logRotation                 common.LogRotationSettings
+	logFormat                   common.LogFormat
+	systemLogger                common.ISystemLogger
 	planDir                     string // Initialize to directory where Job Part Plans are stored
 	coordinatorChannels         CoordinatorChannels
 	xferChannels                XferChannels
 	poolSizingChannels          poolSizingChannels
 	appCtx                      context.Context
-	pacer                       pacerAdmin
+	pacer                       *adjustableCapPacer
+	transactionPacer            *transactionPacer // nil unless the transactions per second are capped
+	metadataTransactionPacer    *transactionPacer // nil unless those of the transfers that move no data are capped on their own
+	partitionThrottle           *partitionThrottle
+	directoryLimiter            *directoryLimiter // nil unless the transfers to each directory of Azure Files are capped
+	credentialExpiry            *credentialExpiryGuard
+	fairChunkScheduler          *fairChunkScheduler // nil unless the scheduling is fair
 	slicePool                   common.ByteSlicePooler
 	cacheLimiter                common.CacheLimiter
 	fileCountLimiter            common.CacheLimiter
 	workaroundJobLoggingChannel chan string
 	concurrencyTuner            ConcurrencyTuner
-	commandLineMbpsCap          int64
 	provideBenchmarkResults     bool
 	cpuMonitor                  common.CPUMonitor
 }
 
 type CoordinatorChannels struct {
-	partsChannel     chan<- IJobPartMgr         // Write Only
-	normalTransferCh chan<- IJobPartTransferMgr // Write-only
-	lowTransferCh    chan<- IJobPartTransferMgr // Write-only
+	partsChannel             chan<- IJobPartMgr         // Write Only
+	normalTransferCh         chan<- IJobPartTransferMgr // Write-only
+	lowTransferCh            chan<- IJobPartTransferMgr // Write-only
+	normalMetadataTransferCh chan<- IJobPartTransferMgr // Write-only
+	lowMetadataTransferCh    chan<- IJobPartTransferMgr // Write-only
 }
 
 type XferChannels struct {
-	partsChannel     <-chan IJobPartMgr         // Read only
-	normalTransferCh <-chan IJobPartTransferMgr // Read-only
-	lowTransferCh    <-chan IJobPartTransferMgr // Read-only
-	normalChunckCh   chan chunkFunc             // Read-write
-	lowChunkCh       chan chunkFunc             // Read-write
+	partsChannel             <-chan IJobPartMgr         // Read only
+	normalTransferCh         <-chan IJobPartTransferMgr // Read-only
+	lowTransferCh            <-chan IJobPartTransferMgr // Read-only
+	normalMetadataTransferCh <-chan IJobPartTransferMgr // Read-only
+	lowMetadataTransferCh    <-chan IJobPartTransferMgr // Read-only
+	normalChunckCh           chan chunkFunc             // Read-write
+	lowChunkCh               chan chunkFunc             // Read-write
 }
 
 type poolSizingChannels struct {
@@ -524,6 +615,7 @@ type poolSizingChannels struct {
 	exitNotificationCh  chan struct{}
 	scalebackRequestCh  chan struct{}
 	requestSlowTuneCh   chan struct{}
+	resizeRequestCh     chan struct{}
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -564,18 +656,23 @@ func (ja *jobsAdmin) JobMgrEnsureExists(jobID common.JobID,
 	return ja.jobIDToJobMgr.EnsureExists(jobID,
 		func() IJobMgr {
 			// Return existing or new IJobMgr to caller
-			return newJobMgr(ja.concurrency, ja.logger, jobID, ja.appCtx, ja.cpuMonitor, level, commandString, ja.logDir)
+			return newJobMgr(ja.concurrency, ja.logger, jobID, ja.appCtx, ja.cpuMonitor, level, commandString, ja.logDir, ja.logRotation, ja.logFormat, ja.systemLogger)
 		})
 }
 
 func (ja *jobsAdmin) ScheduleTransfer(priority common.JobPriority, jptm IJobPartTransferMgr) {
+	normalTransferCh, lowTransferCh := ja.coordinatorChannels.normalTransferCh, ja.coordinatorChannels.lowTransferCh
+	if fromTo := jptm.FromTo(); fromTo.IsMetadataOnly() {
+		normalTransferCh, lowTransferCh = ja.coordinatorChannels.normalMetadataTransferCh, ja.coordinatorChannels.lowMetadataTransferCh
+	}
+
 	switch priority { // priority determines which channel handles the job part's transfers
 	case common.EJobPriority.Normal():
 		//jptm.SetChunkChannel(ja.xferChannels.normalChunckCh)
-		ja.coordinatorChannels.normalTransferCh <- jptm
+		normalTransferCh <- jptm
 	case common.EJobPriority.Low():
 		//jptm.SetChunkChannel(ja.xferChannels.lowChunkCh)
-		ja.coordinatorChannels.lowTransferCh <- jptm
+		lowTransferCh <- jptm
 	default:
 		ja.Panic(fmt.Errorf("invalid priority: %q", priority))
 	}
@@ -608,7 +705,11 @@ func (ja *jobsAdmin) SuccessfulBytesInActiveFiles() uint64 {
 	return uint64(n)
 }
 
-func (ja *jobsAdmin) ResurrectJob(jobId common.JobID, sourceSAS string, destinationSAS string) bool {
+var errNoJobPlans = errors.New("the job has no plan files")
+
+// ResurrectJob adds the parts of the job to it from their plan files, with the SAS of their source and destination.
+// The parts of a job whose destination is sharded take the SAS of their shard from destinationShardSAS, keyed by its URL.
+func (ja *jobsAdmin) ResurrectJob(jobId common.JobID, sourceSAS string, destinationSAS string, destinationShardSAS map[string]string) error {
 	// Search the existing plan files for the PartPlans for the given jobId
 	// only the files which have JobId has prefix and DataSchemaVersion as Suffix
 	// are include in the result
@@ -624,7 +725,7 @@ func (ja *jobsAdmin) ResurrectJob(jobId common.JobID, sourceSAS string, destinat
 	}(jobId.String(), fmt.Sprintf(".steV%d", DataSchemaVersion))
 	// If no files with JobId exists then return false
 	if len(files) == 0 {
-		return false
+		return errNoJobPlans
 	}
 	// sort the JobPartPlan files with respect to Part Number
 	sort.Sort(sortPlanFiles{Files: files})
@@ -635,10 +736,18 @@ func (ja *jobsAdmin) ResurrectJob(jobId common.JobID, sourceSAS string, destinat
 			continue
 		}
 		mmf := planFile.Map()
+		if err = mmf.Plan().CheckPlanKey(); err != nil {
+			mmf.Unmap()
+			return err
+		}
+		partDestinationSAS := destinationSAS
+		if shardSAS, ok := destinationShardSAS[mmf.Plan().DestinationRootString()]; ok {
+			partDestinationSAS = shardSAS
+		}
 		jm := ja.JobMgrEnsureExists(jobID, mmf.Plan().LogLevel, "")
-		jm.AddJobPart(partNum, planFile, mmf, sourceSAS, destinationSAS, false)
+		jm.AddJobPart(partNum, planFile, mmf, sourceSAS, partDestinationSAS, false)
 	}
-	return true
+	return nil
 }
 
 // reconstructTheExistingJobParts reconstructs the in memory JobPartPlanInfo for existing memory map JobFile
@@ -717,6 +826,37 @@ func (ja *jobsAdmin) CurrentMainPoolSize() int {
 	return int(atomic.LoadInt32(&ja.atomicCurrentMainPoolSize))
 }
 
+// SetMainPoolSize fixes the size of the main pool, while the job runs. The pool sizer gets there within a few seconds
+func (ja *jobsAdmin) SetMainPoolSize(size int) {
+	atomic.StoreInt32(&ja.atomicRequestedMainPoolSize, int32(size))
+	select {
+	case ja.poolSizingChannels.resizeRequestCh <- struct{}{}:
+	default:
+		// the pool sizer hasn't yet picked up the previous request, and will see this size when it does
+	}
+}
+
+// RequestedMainPoolSize returns the size that was set while the job runs, or zero if it wasn't
+func (ja *jobsAdmin) RequestedMainPoolSize() int {
+	return int(atomic.LoadInt32(&ja.atomicRequestedMainPoolSize))
+}
+
+// SetMbpsCap changes the bandwidth cap of the running jobs. Zero removes the cap
+func (ja *jobsAdmin) SetMbpsCap(mbps int64) {
+	atomic.StoreInt64(&ja.atomicMbpsCap, mbps)
+	ja.pacer.setTargetBytesPerSecond(megabitsToBytesPerSecond(mbps))
+}
+
+// MbpsCap returns the current bandwidth cap, or zero if there is none
+func (ja *jobsAdmin) MbpsCap() int64 {
+	return atomic.LoadInt64(&ja.atomicMbpsCap)
+}
+
+// use the "networking mega" (based on powers of 10, not powers of 2, since that's what mega means in networking context)
+func megabitsToBytesPerSecond(mbps int64) int64 {
+	return mbps * 1000 * 1000 / 8
+}
+
 func (ja *jobsAdmin) slicePoolPruneLoop() {
 	// if something in the pool has been unused for this long, we probably don't need it
 	const pruneInterval = 5 * time.Second
@@ -0,0 +1,96 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// appendOnlyOverlapLength is how much of the end of the destination is compared to the same range of the source,
+// before an append-only upload trusts that the destination holds the start of the source, and only appends the rest
+const appendOnlyOverlapLength = 64 * 1024
+
+var errAppendOnlyOverlapDiffers = errors.New("the end of the destination is not the same as that range of the source")
+
+// appendOnlyFirstOffset returns the offset from which an append-only upload appends the source to a destination
+// that's dstLength long, or 0 if the source must be uploaded in full, because the destination is longer than the source,
+// or the end of the destination isn't the same as that range of the source (so the file was replaced, rather than appended to).
+// readDestination reads a range of the destination.
+func appendOnlyFirstOffset(jptm IJobPartTransferMgr, dstLength int64, readDestination func(offset int64, count int64) (io.ReadCloser, error)) int64 {
+	info := jptm.Info()
+	if dstLength <= 0 {
+		return 0
+	}
+	if dstLength > info.SourceSize {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo,
+			fmt.Sprintf("The destination is longer (%v bytes) than the source (%v bytes), so the source is uploaded in full", dstLength, info.SourceSize))
+		return 0
+	}
+
+	overlap := int64(appendOnlyOverlapLength)
+	if dstLength < overlap {
+		overlap = dstLength
+	}
+	err := compareOverlap(info.Source, dstLength-overlap, overlap, readDestination)
+	if err != nil {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+			"Cannot append to the destination, so the source is uploaded in full: "+err.Error())
+		return 0
+	}
+	return dstLength
+}
+
+// compareOverlap compares the hashes of a range of the local source and of the destination
+func compareOverlap(source string, offset int64, count int64, readDestination func(offset int64, count int64) (io.ReadCloser, error)) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	srcHash := md5.New()
+	if _, err = io.Copy(srcHash, io.NewSectionReader(f, offset, count)); err != nil {
+		return err
+	}
+
+	body, err := readDestination(offset, count)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	dstHash := md5.New()
+	if n, err := io.Copy(dstHash, body); err != nil {
+		return err
+	} else if n != count {
+		return errAppendOnlyOverlapDiffers
+	}
+
+	if !bytes.Equal(srcHash.Sum(nil), dstHash.Sum(nil)) {
+		return errAppendOnlyOverlapDiffers
+	}
+	return nil
+}
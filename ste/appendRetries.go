@@ -0,0 +1,151 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"io"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// Unlike the other chunk uploads, which say where their bytes go, and so can be sent again as they are, appends are not idempotent.
+// When the response to an append is lost, e.g. to a connection that was reset once the body was sent, the service may well have
+// saved the bytes. Its retry is then turned down, since the destination no longer ends where the append expects it to.
+// Rather than fail the transfer, or send the bytes again without a condition and append them twice, the destination is checked:
+// if it ends where the chunk does, with the bytes of the chunk, an earlier try of the append landed.
+// (The appends of BlobFS files are at an explicit position, and aren't visible until they're flushed, so sending one again
+// at the same position replaces what an earlier try staged there. Only the append that also flushes the file is checked.)
+// The pipeline closes the body of a request once it's done with it, which releases the buffer of a chunk, so the body is sent
+// without its Close, and closed once the check is done.
+
+// unclosedBody hides the Close of a request body from the pipeline
+type unclosedBody struct {
+	io.ReadSeeker
+}
+
+func closeBody(body io.ReadSeeker) {
+	if c, ok := body.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// chunkLanded tells whether the destination, given by its length and a reader of a range of it, ends at offset with the bytes of chunk
+func chunkLanded(chunk io.ReadSeeker, offset int64, destinationLength func() (int64, error), readRange func(offset, count int64) (io.ReadCloser, error)) bool {
+	count, err := chunk.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false
+	}
+	if length, err := destinationLength(); err != nil || length != offset+count {
+		return false
+	}
+
+	if _, err = chunk.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	sent := md5.New()
+	if _, err = io.Copy(sent, chunk); err != nil {
+		return false
+	}
+
+	body, err := readRange(offset, count)
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+	saved := md5.New()
+	if n, err := io.Copy(saved, body); err != nil || n != count {
+		return false
+	}
+	return bytes.Equal(sent.Sum(nil), saved.Sum(nil))
+}
+
+// appendBlock appends body to the append blob at offset, and closes body.
+// A retry that's turned down, since an earlier try of the same append landed, is a success
+func appendBlock(ctx context.Context, blobURL azblob.AppendBlobURL, body io.ReadSeeker, offset int64) error {
+	defer closeBody(body)
+	_, err := blobURL.AppendBlock(ctx, &unclosedBody{body},
+		azblob.AppendBlobAccessConditions{
+			AppendPositionAccessConditions: azblob.AppendPositionAccessConditions{IfAppendPositionEqual: offset},
+		}, nil)
+	if err == nil {
+		return nil
+	}
+	if stgErr, ok := err.(azblob.StorageError); !ok || stgErr.ServiceCode() != azblob.ServiceCodeAppendPositionConditionNotMet {
+		return err
+	}
+
+	landed := chunkLanded(body, offset,
+		func() (int64, error) {
+			props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{})
+			if err != nil {
+				return 0, err
+			}
+			return props.ContentLength(), nil
+		},
+		func(offset, count int64) (io.ReadCloser, error) {
+			get, err := blobURL.Download(ctx, offset, count, azblob.BlobAccessConditions{}, false)
+			if err != nil {
+				return nil, err
+			}
+			return get.Body(azblob.RetryReaderOptions{MaxRetryRequests: MaxRetryPerDownloadBody}), nil
+		})
+	if landed {
+		return nil
+	}
+	return err
+}
+
+// appendDataAndClose uploads body as the whole of the BlobFS file, flushes the file, and closes body.
+// A retry that fails, since an earlier try of it landed, is a success
+func appendDataAndClose(ctx context.Context, fileURL azbfs.FileURL, body io.ReadSeeker, headers azbfs.BlobFSHTTPHeaders) error {
+	defer closeBody(body)
+	_, err := fileURL.AppendDataAndClose(ctx, &unclosedBody{body}, headers)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(azbfs.StorageError); !ok {
+		return err
+	}
+
+	landed := chunkLanded(body, 0,
+		func() (int64, error) {
+			props, err := fileURL.GetProperties(ctx)
+			if err != nil {
+				return 0, err
+			}
+			return props.ContentLength(), nil
+		},
+		func(offset, count int64) (io.ReadCloser, error) {
+			get, err := fileURL.Download(ctx, offset, count)
+			if err != nil {
+				return nil, err
+			}
+			return get.Body(azbfs.RetryReaderOptions{MaxRetryRequests: MaxRetryPerDownloadBody}), nil
+		})
+	if landed {
+		return nil
+	}
+	return err
+}
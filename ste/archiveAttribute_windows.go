@@ -0,0 +1,53 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+const fileAttributeArchive = 0x20 // FILE_ATTRIBUTE_ARCHIVE
+
+// clearArchiveAttribute clears the archive attribute of a local file, unless the file was modified after lmt
+// (when lmt is known), since Windows sets the attribute again whenever the file is written
+func clearArchiveAttribute(path string, lmt time.Time) error {
+	if fi, err := os.Stat(path); err != nil {
+		return err
+	} else if lmt.After(time.Unix(0, 0)) && !fi.ModTime().Equal(lmt) {
+		return fmt.Errorf("the file was modified after it was enumerated")
+	}
+
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attributes, err := syscall.GetFileAttributes(p)
+	if err != nil {
+		return err
+	}
+	if attributes&fileAttributeArchive == 0 {
+		return nil
+	}
+	return syscall.SetFileAttributes(p, attributes&^fileAttributeArchive)
+}
@@ -0,0 +1,128 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Block IDs
+//
+// The IDs of the blocks that azcopy stages are part of its interface, since an upload that is done again, by this version or
+// by a later one, or another tool, needs to tell which of the blocks it finds staged it can keep. Their format is versioned,
+// and every version is 36 bytes long before it's base64 encoded (48 bytes after), because the service rejects a block whose
+// ID is not as long as those of the blocks already staged for the blob. The formats are:
+//
+//	version 2 (current): "azc2" + source identity (12 hex digits) + block index (8 hex digits) + block size (8 hex digits) + "0000"
+//	version 1:           "azcp" + source identity (12 hex digits) + offset of the block (12 hex digits) + block size (8 hex digits)
+//	version 0:           a random UUID, e.g. "c5d0e0a4-5c5a-4b7e-9f4a-3c2b1a0f9e8d", which says nothing about the block
+//
+// The source identity is a hash of the source's location, size and last modified time (see blockSourceIdentity), so it's the same
+// for every attempt at the transfer of the same version of the source, whichever job makes it. The block size is in bytes.
+// The last four digits of version 2 are reserved, and always zero for now. Hex digits are lower case.
+type blockIDFormat uint8
+
+const (
+	blockIDFormatRandom  blockIDFormat = 0
+	blockIDFormatOffset  blockIDFormat = 1
+	blockIDFormatIndexed blockIDFormat = 2
+)
+
+const (
+	blockIDPrefix         = "azc"
+	blockIDLength         = 36
+	sourceIdentityBytes   = 6
+	blockIDReservedDigits = "0000"
+
+	// the version 1 format has no version digit: the p of its prefix stands in for one
+	offsetBlockIDPrefix = "azcp"
+)
+
+var randomBlockIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// blockIDInfo is what a block ID says about its block. Only the format is known for version 0
+type blockIDInfo struct {
+	format         blockIDFormat
+	sourceIdentity string
+	offset         int64
+	blockSize      int64
+}
+
+// formatBlockID returns the encoded ID, in the current format, of the block at the index in the source with the identity
+func formatBlockID(sourceIdentity string, blockIndex uint32, blockSize uint32) string {
+	blockID := fmt.Sprintf("%s%d%s%08x%08x%s", blockIDPrefix, blockIDFormatIndexed, sourceIdentity, blockIndex, blockSize, blockIDReservedDigits)
+	return base64.StdEncoding.EncodeToString([]byte(blockID))
+}
+
+// parseBlockID tells what an encoded block ID says, if it's in one of the formats that azcopy has staged blocks with
+func parseBlockID(encodedBlockID string) (info blockIDInfo, ok bool) {
+	raw, err := base64.StdEncoding.DecodeString(encodedBlockID)
+	if err != nil || len(raw) != blockIDLength {
+		return blockIDInfo{}, false
+	}
+	blockID := string(raw)
+	hexField := func(start, end int) (int64, bool) {
+		v, err := strconv.ParseUint(blockID[start:end], 16, 64)
+		return int64(v), err == nil
+	}
+
+	switch {
+	case randomBlockIDRegex.MatchString(blockID):
+		return blockIDInfo{format: blockIDFormatRandom}, true
+	case blockID[:4] == offsetBlockIDPrefix:
+		info = blockIDInfo{format: blockIDFormatOffset, sourceIdentity: blockID[4:16]}
+		offset, offsetOK := hexField(16, 28)
+		blockSize, sizeOK := hexField(28, 36)
+		if !offsetOK || !sizeOK || blockSize == 0 {
+			return blockIDInfo{}, false
+		}
+		info.offset, info.blockSize = offset, blockSize
+		return info, true
+	case blockID[:4] == fmt.Sprintf("%s%d", blockIDPrefix, blockIDFormatIndexed):
+		info = blockIDInfo{format: blockIDFormatIndexed, sourceIdentity: blockID[4:16]}
+		blockIndex, indexOK := hexField(16, 24)
+		blockSize, sizeOK := hexField(24, 32)
+		if !indexOK || !sizeOK || blockSize == 0 || blockID[32:] != blockIDReservedDigits {
+			return blockIDInfo{}, false
+		}
+		info.offset, info.blockSize = blockIndex*blockSize, blockSize
+		return info, true
+	}
+	return blockIDInfo{}, false
+}
+
+// blockIDFor returns the ID of the block that starts at the given offset in the source
+func (s *blockBlobSenderBase) blockIDFor(offset int64) string {
+	return formatBlockID(s.sourceIdentity, uint32(offset/int64(s.chunkSize)), s.chunkSize)
+}
+
+// parseBlockID returns the offset and block size that the ID of a block says, if it's the ID of a block of this version of the source.
+// Blocks staged by earlier versions of azcopy, in the version 1 format, count too. Those with random IDs don't, since their IDs don't say what they hold
+func (s *blockBlobSenderBase) parseBlockID(encodedBlockID string) (offset int64, blockSize int64, ok bool) {
+	info, ok := parseBlockID(encodedBlockID)
+	if !ok || info.format == blockIDFormatRandom || info.sourceIdentity != s.sourceIdentity {
+		return 0, 0, false
+	}
+	return info.offset, info.blockSize, true
+}
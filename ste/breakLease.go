@@ -0,0 +1,157 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// leaseBreakingPipeline is the pipeline of a job that breaks the leases of the destination blobs that are in its way (--break-lease-on-overwrite).
+// When a write to a blob, or its deletion, fails because the blob is leased, the lease is broken at once, and the request is sent again.
+// It wraps the whole pipeline, so that the request that is sent again is retried like any other, and that it works for every kind of write.
+type leaseBreakingPipeline struct {
+	pipeline.Pipeline
+	log func(level pipeline.LogLevel, msg string)
+}
+
+func newLeaseBreakingPipeline(p pipeline.Pipeline, log func(level pipeline.LogLevel, msg string)) pipeline.Pipeline {
+	return leaseBreakingPipeline{Pipeline: p, log: log}
+}
+
+func (p leaseBreakingPipeline) Do(ctx context.Context, methodFactory pipeline.Factory, request pipeline.Request) (pipeline.Response, error) {
+	resp, err := p.Pipeline.Do(ctx, methodFactory, request)
+	if err == nil || !isLeaseInTheWay(request, err) {
+		return resp, err
+	}
+
+	blobURL := blobURLWithoutOperation(*request.URL)
+	displayURL := ownLeaseKey(blobURL)
+	if ownLeases.holds(blobURL) {
+		p.log(pipeline.LogWarning, fmt.Sprintf("Not breaking the lease of %s, since this job holds it", displayURL))
+		return resp, err
+	}
+	if breakErr := breakLeaseNow(ctx, p.Pipeline, blobURL); breakErr != nil {
+		p.log(pipeline.LogError, fmt.Sprintf("Failed to break the lease of %s: %s", displayURL, breakErr))
+		return resp, err
+	}
+	p.log(pipeline.LogWarning, fmt.Sprintf("Broke the lease of %s, since it was in the way of a %s", displayURL, request.Method))
+
+	if rewindErr := request.RewindBody(); rewindErr != nil {
+		return resp, err
+	}
+	return p.Pipeline.Do(ctx, methodFactory, request)
+}
+
+// breakLeaseNow breaks the lease of the blob, with a break period of zero, so that the blob can be written right away.
+// The version of the blob SDK we use never sends the break period, so we issue the Break Lease request ourselves
+func breakLeaseNow(ctx context.Context, p pipeline.Pipeline, blobURL url.URL) error {
+	req, err := pipeline.NewRequest(http.MethodPut, blobURL, nil)
+	if err != nil {
+		return pipeline.NewError(err, "failed to create request")
+	}
+	params := req.URL.Query()
+	params.Set("comp", "lease")
+	req.URL.RawQuery = params.Encode()
+	req.Header.Set("x-ms-lease-action", "break")
+	req.Header.Set("x-ms-lease-break-period", "0")
+
+	_, err = p.Do(ctx, newRawBlobResponderFactory(http.StatusAccepted), req)
+	return err
+}
+
+// isLeaseInTheWay tells whether the request is a write, or a deletion, that failed because the blob is leased
+func isLeaseInTheWay(request pipeline.Request, err error) bool {
+	if request.Method != http.MethodPut && request.Method != http.MethodDelete {
+		return false
+	}
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	return serviceCode == string(azblob.ServiceCodeLeaseIDMissing)
+}
+
+// blobURLWithoutOperation is the URL of the blob that a request is for, with its SAS (if any), but without the parameters of the operation
+func blobURLWithoutOperation(u url.URL) url.URL {
+	parts := azblob.NewBlobURLParts(u)
+	parts.Snapshot = ""
+	parts.UnparsedParams = ""
+	return parts.URL()
+}
+
+// ownLeaseTracker remembers the leases that this process took, so that they're never broken as if they were in the way of a write.
+// Whatever takes a lease must add it here, and remove it once it's released, as destinationLease does
+type ownLeaseTracker struct {
+	lock     sync.Mutex
+	leaseIDs map[string]string // by the URL of the blob, without its query
+}
+
+var ownLeases = &ownLeaseTracker{leaseIDs: make(map[string]string)}
+
+func ownLeaseKey(blobURL url.URL) string {
+	blobURL.RawQuery = ""
+	return blobURL.String()
+}
+
+func (t *ownLeaseTracker) add(blobURL url.URL, leaseID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.leaseIDs[ownLeaseKey(blobURL)] = leaseID
+}
+
+func (t *ownLeaseTracker) remove(blobURL url.URL) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.leaseIDs, ownLeaseKey(blobURL))
+}
+
+func (t *ownLeaseTracker) holds(blobURL url.URL) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	_, ok := t.leaseIDs[ownLeaseKey(blobURL)]
+	return ok
+}
+
+var leasedBlobLogGLCM sync.Once
+
+// explainLeasedBlob returns what to tell the user about an error that says that a blob is leased, or "" if the error says something else.
+// Unless the job breaks the leases already, the user is told once how to get past them.
+func explainLeasedBlob(jptm IJobPartTransferMgr, err error) string {
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	if serviceCode != string(azblob.ServiceCodeLeaseIDMissing) {
+		return ""
+	}
+	if jptm.BreakLeaseOnOverwrite() {
+		return "The blob is leased, and its lease could not be broken"
+	}
+
+	leasedBlobLogGLCM.Do(func() {
+		common.GetLifecycleMgr().Info("One or more blobs could not be overwritten or removed, because they are leased. " +
+			"To break their leases, and overwrite or remove them anyway, use --break-lease-on-overwrite")
+	})
+	return "The blob is leased, so it can't be overwritten or removed. To break its lease, use --break-lease-on-overwrite"
+}
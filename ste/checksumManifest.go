@@ -0,0 +1,179 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// checksumRelativePath returns the path of the file in the checksum file: its path in the tree that verification downloads (its source),
+// or otherwise its path in the destination, so that the checksum file of a download can be checked with sha256sum in the destination folder.
+// Either way, it's relative to the root of the tree, not URL encoded, and with forward slashes.
+func checksumRelativePath(jptm *jobPartTransferMgr, verifying bool) string {
+	plan := jptm.jobPartMgr.Plan()
+	srcRelative, dstRelative := plan.TransferSrcDstRelatives(jptm.transferIndex)
+	src, dst := plan.TransferSrcDstStrings(jptm.transferIndex)
+	fromTo := jptm.FromTo()
+	relative, full, location := dstRelative, dst, fromTo.To()
+	if verifying {
+		relative, full, location = srcRelative, src, fromTo.From()
+	}
+
+	if location.IsRemote() {
+		if relative == "" {
+			// the root is the file itself
+			relative = path.Base(strings.Split(full, "?")[0])
+		}
+		if unescaped, err := url.PathUnescape(relative); err == nil {
+			relative = unescaped
+		}
+	} else {
+		if relative == "" {
+			relative = filepath.Base(full)
+		}
+		relative = filepath.ToSlash(relative)
+	}
+	return strings.TrimPrefix(relative, "/")
+}
+
+// checksumManifest is the checksum file of a job. Either the job writes the digest of each file that it transfers to the file,
+// as the transfers complete, or (when the job downloads a tree to check it) it verifies each file that it downloads against
+// the digest that the file has for it. The digests are those of the bytes that the transfers read, so no file is read twice.
+type checksumManifest struct {
+	newHash func() hash.Hash
+
+	// when the digests are written
+	unsavedLines chan string
+	flushDone    chan struct{}
+
+	// when they are verified, the expected digests in hex by relative path, and the paths that were downloaded
+	expected    map[string]string
+	foundLock   sync.Mutex
+	found       map[string]struct{}
+	atomicFound uint32
+}
+
+func newChecksumManifestWriter(checksumFile string) (*checksumManifest, error) {
+	newHash, err := common.ChecksumFileHash(checksumFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(checksumFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &checksumManifest{
+		newHash:      newHash,
+		unsavedLines: make(chan string, 100000),
+		flushDone:    make(chan struct{}),
+	}
+	go m.main(f)
+	return m, nil
+}
+
+func loadChecksumManifest(checksumFile string) (*checksumManifest, error) {
+	newHash, err := common.ChecksumFileHash(checksumFile)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := common.ReadChecksumFile(checksumFile)
+	if err != nil {
+		return nil, err
+	}
+	return &checksumManifest{
+		newHash:  newHash,
+		expected: expected,
+		found:    make(map[string]struct{}),
+	}, nil
+}
+
+func (m *checksumManifest) isVerifying() bool {
+	return m.expected != nil
+}
+
+// record adds the line for a file that was transferred
+func (m *checksumManifest) record(relativePath string, digest []byte) {
+	m.unsavedLines <- common.FormatChecksumLine(digest, relativePath)
+}
+
+// verify checks the digest of a file that was downloaded against the one that the checksum file has for it
+func (m *checksumManifest) verify(relativePath string, digest []byte) error {
+	expected, ok := m.expected[relativePath]
+	if !ok {
+		return fmt.Errorf("%s is not in the checksum file", relativePath)
+	}
+
+	m.foundLock.Lock()
+	if _, seen := m.found[relativePath]; !seen {
+		m.found[relativePath] = struct{}{}
+		atomic.AddUint32(&m.atomicFound, 1)
+	}
+	m.foundLock.Unlock()
+
+	if actual := hex.EncodeToString(digest); actual != expected {
+		return fmt.Errorf("the checksum of %s is %s, but the checksum file has %s", relativePath, actual, expected)
+	}
+	return nil
+}
+
+// entriesNotFound is the number of files in the checksum file that haven't been downloaded (yet)
+func (m *checksumManifest) entriesNotFound() uint32 {
+	if !m.isVerifying() {
+		return 0
+	}
+	return uint32(len(m.expected)) - atomic.LoadUint32(&m.atomicFound)
+}
+
+// Flush returns after everything that has been recorded so far is saved in the file
+func (m *checksumManifest) Flush() {
+	if m.isVerifying() {
+		return
+	}
+	m.unsavedLines <- "" // tell writer that it must flush, then wait until it has done so
+	<-m.flushDone
+}
+
+func (m *checksumManifest) main(f *os.File) {
+	defer func() { _ = f.Close() }()
+
+	bw := bufio.NewWriter(f)
+	for line := range m.unsavedLines {
+		if line == "" {
+			_ = bw.Flush()
+			m.flushDone <- struct{}{}
+			continue
+		}
+		_, _ = bw.WriteString(line + "\n")
+	}
+}
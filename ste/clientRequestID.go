@@ -0,0 +1,62 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+const clientRequestIDHeader = "x-ms-client-request-id"
+
+var clientRequestIDPrefixContextKey = contextKey{"clientRequestIDPrefix"}
+
+// ClientRequestIDPrefix is what the x-ms-client-request-id of each request of a job starts with:
+// the prefix that the user gave (--client-request-id-prefix), or else the ID of the job
+func ClientRequestIDPrefix(jobID common.JobID, userPrefix string) string {
+	if userPrefix != "" {
+		return userPrefix
+	}
+	return jobID.String()
+}
+
+// WithClientRequestIDPrefix returns a context whose requests get an x-ms-client-request-id that starts with the prefix
+func WithClientRequestIDPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, clientRequestIDPrefixContextKey, prefix)
+}
+
+// newClientRequestIDPolicyFactory gives each request whose context has a prefix an x-ms-client-request-id made of the prefix
+// and of a suffix of the request's own, which correlates the requests of a job in the logs of the service.
+// It comes before the unique request ID policy of the SDK, which leaves an ID that's already set alone, and before the retries,
+// so all the tries of a request have the same ID
+func newClientRequestIDPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if prefix, ok := ctx.Value(clientRequestIDPrefixContextKey).(string); ok && prefix != "" &&
+				request.Header.Get(clientRequestIDHeader) == "" {
+				request.Header.Set(clientRequestIDHeader, prefix+"-"+common.NewUUID().String())
+			}
+			return next.Do(ctx, request)
+		}
+	})
+}
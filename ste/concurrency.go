@@ -103,6 +103,14 @@ type ConcurrencySettings struct {
 	// (i.e. creates chunkfuncs)
 	TransferInitiationPoolSize *ConfiguredInt
 
+	// MetadataTransferPoolSize is the size of the goroutine pool that runs the transfers that move no data,
+	// such as deletes and setting properties. They're apart from the others, so that a flood of them can't hold up the transfers of data
+	MetadataTransferPoolSize *ConfiguredInt
+
+	// TransfersPerDirectory is the max number of transfers to each directory of an Azure Files destination that are in flight at once,
+	// or zero for no cap. Creating many files at once in one directory contends for the directory's metadata
+	TransfersPerDirectory *ConfiguredInt
+
 	// MaxIdleConnections is the max number of idle TCP connections to keep open
 	MaxIdleConnections int
 
@@ -125,6 +133,8 @@ func (c ConcurrencySettings) AutoTuneMainPool() bool {
 }
 
 const defaultTransferInitiationPoolSize = 64
+const defaultMetadataTransferPoolSize = 32
+const defaultTransfersPerDirectory = 64
 const concurrentFilesFloor = 32
 
 // NewConcurrencySettings gets concurrency settings by referring to the
@@ -138,6 +148,8 @@ func NewConcurrencySettings(maxFileAndSocketHandles int, requestAutoTuneGRs bool
 		InitialMainPoolSize:        initialMainPoolSize,
 		MaxMainPoolSize:            maxMainPoolSize,
 		TransferInitiationPoolSize: getTransferInitiationPoolSize(),
+		MetadataTransferPoolSize:   getMetadataTransferPoolSize(),
+		TransfersPerDirectory:      getTransfersPerDirectory(),
 		MaxOpenDownloadFiles:       getMaxOpenPayloadFiles(maxFileAndSocketHandles, maxMainPoolSize.Value),
 		CheckCpuWhenTuning:          getCheckCpuUsageWhenTuning(),
 	}
@@ -218,6 +230,26 @@ func getTransferInitiationPoolSize() *ConfiguredInt {
 	return &ConfiguredInt{defaultTransferInitiationPoolSize, false, envVar.Name, "hard-coded default"}
 }
 
+func getMetadataTransferPoolSize() *ConfiguredInt {
+	envVar := common.EEnvironmentVariable.MetadataTransferPoolSize()
+
+	if c := tryNewConfiguredInt(envVar); c != nil {
+		return c
+	}
+
+	return &ConfiguredInt{defaultMetadataTransferPoolSize, false, envVar.Name, "hard-coded default"}
+}
+
+func getTransfersPerDirectory() *ConfiguredInt {
+	envVar := common.EEnvironmentVariable.TransfersPerDirectory()
+
+	if c := tryNewConfiguredInt(envVar); c != nil {
+		return c
+	}
+
+	return &ConfiguredInt{defaultTransfersPerDirectory, false, envVar.Name, "hard-coded default"}
+}
+
 func getCheckCpuUsageWhenTuning() *ConfiguredBool {
 	envVar := common.EEnvironmentVariable.AutoTuneToCpu()
 	if c := tryNewConfiguredBool(envVar); c != nil {
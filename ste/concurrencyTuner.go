@@ -24,6 +24,7 @@ import (
 	"github.com/Azure/azure-storage-azcopy/common"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type ConcurrencyTuner interface {
@@ -39,6 +40,9 @@ type ConcurrencyTuner interface {
 
 	// recordRetry informs the concurrencyTuner that a retry has happened
 	recordRetry()
+
+	// recordServerDirectedWait informs the concurrencyTuner that the service throttled a request, and said how long to wait before retrying it
+	recordServerDirectedWait(wait time.Duration)
 }
 
 type nullConcurrencyTuner struct {
@@ -61,9 +65,14 @@ func (n *nullConcurrencyTuner) recordRetry() {
 	// noop
 }
 
+func (n *nullConcurrencyTuner) recordServerDirectedWait(wait time.Duration) {
+	// noop
+}
+
 type autoConcurrencyTuner struct {
-	atomicRetryCount int64
-	observations     chan struct {
+	atomicRetryCount                  int64
+	atomicLongestServerDirectedWaitNs int64 // the longest wait the service asked for, since the tuner last looked
+	observations                      chan struct {
 		mbps      int
 		isHighCpu bool
 	}
@@ -126,16 +135,32 @@ func (t *autoConcurrencyTuner) recordRetry() {
 	atomic.AddInt64(&t.atomicRetryCount, 1)
 }
 
+func (t *autoConcurrencyTuner) recordServerDirectedWait(wait time.Duration) {
+	for {
+		longest := atomic.LoadInt64(&t.atomicLongestServerDirectedWaitNs)
+		if int64(wait) <= longest || atomic.CompareAndSwapInt64(&t.atomicLongestServerDirectedWaitNs, longest, int64(wait)) {
+			return
+		}
+	}
+}
+
+// takeLongestServerDirectedWait returns the longest wait that the service asked for since it was last called
+func (t *autoConcurrencyTuner) takeLongestServerDirectedWait() time.Duration {
+	return time.Duration(atomic.SwapInt64(&t.atomicLongestServerDirectedWaitNs, 0))
+}
+
 const (
 	concurrencyReasonNone          = ""
 	concurrencyReasonTunerDisabled = "tuner disabled" // used as the final (non-finished) state for null tuner
 	concurrencyReasonInitial       = "initial starting point"
 	concurrencyReasonSeeking       = "seeking optimum"
 	concurrencyReasonBackoff       = "backing off"
+	concurrencyReasonThrottled     = "backing off, since the service asked us to wait"
 	concurrencyReasonHitMax        = "hit max concurrency limit"
 	concurrencyReasonHighCpu       = "at optimum, but may be limited by CPU"
 	concurrencyReasonAtOptimum     = "at optimum"
 	concurrencyReasonFinished      = "tuning already finished (or never started)"
+	concurrencyReasonRequested     = "set while the job runs"
 )
 
 func (t *autoConcurrencyTuner) worker() {
@@ -189,6 +214,10 @@ func (t *autoConcurrencyTuner) worker() {
 			everSawHighCpu = true // this doesn't stop us probing higher concurrency, since sometimes that works even when CPU looks high, but it does change the way we report the result
 		}
 
+		// When the service says how long to wait before retrying, it's telling us, more plainly than the speed can, that
+		// there are more workers than it will take. So we back off, even if the speed went up
+		wasThrottled := t.takeLongestServerDirectedWait() > 0
+
 		if t.isBenchmarking {
 			// Be a little more aggressive if we are tuning for benchmarking purposes (as opposed to day to day use)
 
@@ -203,7 +232,7 @@ func (t *autoConcurrencyTuner) worker() {
 		}
 
 		// decide what to do based on the measurement
-		if lastSpeed > desiredNewSpeed || probeHigherRegardless {
+		if (lastSpeed > desiredNewSpeed || probeHigherRegardless) && !wasThrottled {
 			// Our concurrency change gave the hoped-for speed increase, so loop around and see if another increase will also work,
 			// unless already at max
 			if atMax {
@@ -235,7 +264,11 @@ func (t *autoConcurrencyTuner) worker() {
 			if multiplier < minMulitplier {
 				break // no point in tuning any more
 			} else {
-				lastReason = t.setConcurrency(concurrency, concurrencyReasonBackoff)
+				backoffReason := concurrencyReasonBackoff
+				if wasThrottled {
+					backoffReason = concurrencyReasonThrottled
+				}
+				lastReason = t.setConcurrency(concurrency, backoffReason)
 				lastSpeed, _ = t.getCurrentSpeed() // must re-measure immediately after backing off
 			}
 		}
@@ -0,0 +1,91 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// copySourceAuthServiceVersion is the first service version in which Put Block From URL, Append Block From URL
+// and Put Page From URL accept a bearer token for reading the source
+const copySourceAuthServiceVersion = "2020-10-02"
+
+const copySourceAuthorizationHeader = "x-ms-copy-source-authorization"
+
+type copySourceTokenKey struct{}
+
+// withCopySourceAuthorization returns a context that makes the copy source authorization policy send the OAuth token of the
+// source along with the request, if the source of the S2S copy is authorized that way (rather than with a SAS).
+// The context must be the one that is used for the request to the destination that reads from the source.
+func withCopySourceAuthorization(ctx context.Context, jptm IJobPartTransferMgr) context.Context {
+	credential := jptm.S2SSourceTokenCredential()
+	if credential == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, ServiceAPIVersionOverride, copySourceAuthServiceVersion)
+	return context.WithValue(ctx, copySourceTokenKey{}, credential)
+}
+
+// NewCopySourceAuthorizationPolicyFactory creates a factory that adds the copy source authorization header. The token
+// is read from the credential on each try, so that the refreshes that the credential does as the job runs are picked up.
+func NewCopySourceAuthorizationPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if credential, ok := ctx.Value(copySourceTokenKey{}).(azblob.TokenCredential); ok {
+				request.Header.Set(copySourceAuthorizationHeader, "Bearer "+credential.Token())
+			}
+			return next.Do(ctx, request)
+		}
+	})
+}
+
+var copySourceAuthFailureLogGLCM sync.Once
+
+// explainCopySourceAuthFailure tells the user, once, what to do when a copy that reads its source with an OAuth token fails
+// because the destination can't do that (e.g. an older version of the service, or an emulator) or because the source rejects the token
+func explainCopySourceAuthFailure(jptm IJobPartTransferMgr, err error) {
+	if jptm.S2SSourceTokenCredential() == nil {
+		return
+	}
+
+	serviceCode, status, _ := ErrorEx{err}.ErrorCodeAndString()
+	var msg string
+	switch {
+	case serviceCode == string(azblob.ServiceCodeInvalidHeaderValue) || serviceCode == string(azblob.ServiceCodeUnsupportedHeader):
+		msg = "The destination does not support authorizing the source of the copy with an OAuth token. " +
+			"It needs service version " + copySourceAuthServiceVersion + " or later. Add a SAS token to the source URL instead."
+	case serviceCode == string(azblob.ServiceCodeCannotVerifyCopySource) && (status == http.StatusUnauthorized || status == http.StatusForbidden):
+		msg = "The source of the copy did not accept the OAuth token. Make sure that the logged-in identity has a role, " +
+			"such as Storage Blob Data Reader, that allows it to read the source, or add a SAS token to the source URL instead."
+	default:
+		return
+	}
+	copySourceAuthFailureLogGLCM.Do(func() {
+		common.GetLifecycleMgr().Info(msg)
+	})
+}
@@ -0,0 +1,151 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// credentialExpiryGuard holds back new transfers and chunks once the OAuth token of the job expired and couldn't be refreshed,
+// so that a job that outlives its login waits for a new token, rather than failing each of its remaining transfers with 403.
+// The refreshers of all the credentials of the job wait here for the same new token, which the front end gives,
+// once the user has logged in again (RenewCredential). If there won't be one, the job is paused, which releases them, to be resumed
+// after the user has logged in again.
+type credentialExpiryGuard struct {
+	log func(msg string)
+
+	lock sync.Mutex
+	// what the refreshers wait for, while the token is expired
+	renewal *credentialRenewal
+	err     error
+	// set once the token was given up on, after which nothing waits for it any more, so that the job can wind down
+	gaveUp bool
+
+	atomicExpired int32
+}
+
+// credentialRenewal is the end of one expiry of the token: its done channel is closed once the token is renewed, or given up on
+type credentialRenewal struct {
+	done      chan struct{}
+	tokenInfo *common.OAuthTokenInfo // nil if it was given up on
+}
+
+func newCredentialExpiryGuard(log func(msg string)) *credentialExpiryGuard {
+	return &credentialExpiryGuard{log: log}
+}
+
+func currentCredentialExpiryGuard() *credentialExpiryGuard {
+	if ja, ok := JobsAdmin.(*jobsAdmin); ok {
+		return ja.credentialExpiry
+	}
+	return nil
+}
+
+// waitForRenewal is called by a refresher whose token expired and couldn't be refreshed, with the error it failed with.
+// It holds back new transfers and chunks, and blocks until the token is renewed, returning the new one, or given up on, returning nil
+func (g *credentialExpiryGuard) waitForRenewal(err error) *common.OAuthTokenInfo {
+	if g == nil {
+		return nil
+	}
+
+	g.lock.Lock()
+	if g.gaveUp {
+		g.lock.Unlock()
+		return nil
+	}
+	if g.renewal == nil {
+		g.renewal, g.err = &credentialRenewal{done: make(chan struct{})}, err
+		atomic.StoreInt32(&g.atomicExpired, 1)
+		g.log(fmt.Sprintf("Holding back new transfers, since the OAuth token expired and couldn't be refreshed: %s. "+
+			"They'll start once the token is renewed, e.g. by logging in again.", err))
+	}
+	renewal := g.renewal
+	g.lock.Unlock()
+
+	<-renewal.done
+	return renewal.tokenInfo
+}
+
+// renew gives the refreshers that wait the new token, and lets the new transfers and chunks through again.
+// It returns false if nothing was waiting for one
+func (g *credentialExpiryGuard) renew(tokenInfo common.OAuthTokenInfo) bool {
+	return g.release(&tokenInfo, "Carrying on with new transfers, since the OAuth token was renewed")
+}
+
+// giveUp releases the refreshers that wait, without a new token, since the job is paused (or cancelled) instead.
+// The new transfers and chunks are let through, to be dropped as the job winds down, and nothing is held back from then on
+func (g *credentialExpiryGuard) giveUp() bool {
+	if g == nil {
+		return false
+	}
+	g.lock.Lock()
+	g.gaveUp = true
+	g.lock.Unlock()
+	return g.release(nil, "Gave up waiting for the OAuth token to be renewed")
+}
+
+func (g *credentialExpiryGuard) release(tokenInfo *common.OAuthTokenInfo, msg string) bool {
+	if g == nil {
+		return false
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.renewal == nil {
+		return false
+	}
+	g.renewal.tokenInfo = tokenInfo
+	close(g.renewal.done)
+	g.renewal = nil
+	atomic.StoreInt32(&g.atomicExpired, 0)
+	g.log(msg)
+	return true
+}
+
+// expired tells whether new transfers and chunks are held back, waiting for the token to be renewed
+func (g *credentialExpiryGuard) expired() bool {
+	return g != nil && atomic.LoadInt32(&g.atomicExpired) == 1
+}
+
+// lastError is the error that the refresh, which found that the token expired, failed with
+func (g *credentialExpiryGuard) lastError() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.err
+}
+
+// RenewCredential gives a job, whose OAuth token expired and couldn't be refreshed, the info of a new token
+func RenewCredential(req common.RenewCredentialRequest) common.RenewCredentialResponse {
+	if _, found := JobsAdmin.JobMgr(req.JobID); !found {
+		return common.RenewCredentialResponse{ErrorMsg: fmt.Sprintf("job %s is not running in this process", req.JobID)}
+	}
+	if req.OAuthTokenInfo.IsEmpty() {
+		return common.RenewCredentialResponse{ErrorMsg: "the new OAuth token is empty"}
+	}
+	if !currentCredentialExpiryGuard().renew(req.OAuthTokenInfo) {
+		return common.RenewCredentialResponse{ErrorMsg: fmt.Sprintf("job %s isn't waiting for its OAuth token to be renewed", req.JobID)}
+	}
+	return common.RenewCredentialResponse{Renewed: true}
+}
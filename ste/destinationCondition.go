@@ -0,0 +1,78 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// The condition of --destination-condition goes on the request that writes the whole destination, or that makes it visible:
+// Put Blob or Put Block List for block blobs, the creation of page and append blobs, and the creation of BlobFS files,
+// or their rename to their final name when they're written under a temporary one.
+// A destination that doesn't meet it is skipped, and left as it is.
+
+// BlobFS turns down the create (or the rename) of a path that exists with PathAlreadyExists, when it's only made if there's no path yet
+const serviceCodePathAlreadyExists = "PathAlreadyExists"
+
+// destinationWriteConditions are the access conditions of a write of the whole destination blob:
+// the lease on it, if there's one, and the condition that the job's writes must meet, if there's one
+func destinationWriteConditions(jptm IJobPartTransferMgr, lease *destinationLease) azblob.BlobAccessConditions {
+	ac := lease.blobAccessConditions()
+	c := jptm.DestinationCondition()
+	ac.ModifiedAccessConditions.IfUnmodifiedSince = c.IfUnmodifiedSince
+	if c.IfNoneMatch {
+		ac.ModifiedAccessConditions.IfNoneMatch = azblob.ETagAny
+	}
+	return ac
+}
+
+// destinationPathConditions are the conditions that the job's writes of a whole BlobFS file must meet, if there are any
+func destinationPathConditions(jptm IJobPartTransferMgr) azbfs.PathAccessConditions {
+	c := jptm.DestinationCondition()
+	pc := azbfs.PathAccessConditions{IfUnmodifiedSince: c.IfUnmodifiedSince}
+	if c.IfNoneMatch {
+		pc.IfNoneMatch = "*"
+	}
+	return pc
+}
+
+// isDestinationConditionNotMet tells whether a write failed because its destination didn't meet the condition of the job
+func isDestinationConditionNotMet(jptm IJobPartTransferMgr, err error) bool {
+	if err == nil || !jptm.DestinationCondition().IsSet() {
+		return false
+	}
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	switch serviceCode {
+	case string(azblob.ServiceCodeConditionNotMet), string(azblob.ServiceCodeBlobAlreadyExists), serviceCodePathAlreadyExists:
+		return true
+	default:
+		return false
+	}
+}
+
+// skippedForDestinationCondition tells whether the transfer was skipped because its destination didn't meet the condition of the job.
+// Such a destination was written by something else, so it mustn't be deleted when the transfer is cleaned up
+func skippedForDestinationCondition(jptm IJobPartTransferMgr) bool {
+	return jptm.TransferStatusIgnoringCancellation() == common.ETransferStatus.SkippedDestinationConditionNotMet()
+}
@@ -0,0 +1,201 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// The lease on a destination that is written with --protect-destination-with-lease is a short one, which is renewed
+// well before it expires, so that a destination that AzCopy stops writing to without releasing its lease (e.g. because
+// it was killed) can be written again a minute later.
+const (
+	destinationLeaseDuration        = int32(60)
+	destinationLeaseRenewalInterval = 20 * time.Second
+	destinationLeaseReleaseTimeout  = 30 * time.Second
+)
+
+// leaseOperations are the lease operations of the kind of destination that is leased
+type leaseOperations interface {
+	acquireLease(ctx context.Context, proposedID string, duration int32) error
+	renewLease(ctx context.Context, leaseID string) error
+	releaseLease(ctx context.Context, leaseID string) error
+}
+
+type blobLeaseOperations struct {
+	blobURL azblob.BlobURL
+}
+
+func (o blobLeaseOperations) acquireLease(ctx context.Context, proposedID string, duration int32) error {
+	_, err := o.blobURL.AcquireLease(ctx, proposedID, duration, azblob.ModifiedAccessConditions{})
+	return err
+}
+
+func (o blobLeaseOperations) renewLease(ctx context.Context, leaseID string) error {
+	_, err := o.blobURL.RenewLease(ctx, leaseID, azblob.ModifiedAccessConditions{})
+	return err
+}
+
+func (o blobLeaseOperations) releaseLease(ctx context.Context, leaseID string) error {
+	_, err := o.blobURL.ReleaseLease(ctx, leaseID, azblob.ModifiedAccessConditions{})
+	return err
+}
+
+type blobFSLeaseOperations struct {
+	fileURL azbfs.FileURL
+}
+
+func (o blobFSLeaseOperations) acquireLease(ctx context.Context, proposedID string, duration int32) error {
+	_, err := o.fileURL.AcquireLease(ctx, proposedID, duration)
+	return err
+}
+
+func (o blobFSLeaseOperations) renewLease(ctx context.Context, leaseID string) error {
+	_, err := o.fileURL.RenewLease(ctx, leaseID)
+	return err
+}
+
+func (o blobFSLeaseOperations) releaseLease(ctx context.Context, leaseID string) error {
+	_, err := o.fileURL.ReleaseLease(ctx, leaseID)
+	return err
+}
+
+// destinationLease is the lease that a sender holds on its destination while it writes to it, when the job protects its destinations
+// with leases (--protect-destination-with-lease), so that nothing else can write to the destination at the same time.
+// It's renewed in the background until it's released. A nil destinationLease is valid, and stands for no lease.
+type destinationLease struct {
+	jptm IJobPartTransferMgr
+	ops  leaseOperations
+	url  url.URL
+	id   string
+
+	releaseOnce sync.Once
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// acquireDestinationLease leases the destination, which must exist already, and starts to renew the lease.
+// The lease is recorded as one of this process's own, so that it's never broken to make way for a write.
+func acquireDestinationLease(jptm IJobPartTransferMgr, ops leaseOperations, destination url.URL) (*destinationLease, error) {
+	id := common.NewUUID().String()
+	if err := ops.acquireLease(jptm.Context(), id, destinationLeaseDuration); err != nil {
+		return nil, err
+	}
+	ownLeases.add(destination, id)
+
+	l := &destinationLease{
+		jptm: jptm,
+		ops:  ops,
+		url:  destination,
+		id:   id,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go l.renewUntilReleased(destinationLeaseRenewalInterval)
+	return l, nil
+}
+
+// leaseID is the ID of the lease, or "" if there's none
+func (l *destinationLease) leaseID() string {
+	if l == nil {
+		return ""
+	}
+	return l.id
+}
+
+func (l *destinationLease) leaseAccessConditions() azblob.LeaseAccessConditions {
+	return azblob.LeaseAccessConditions{LeaseID: l.leaseID()}
+}
+
+func (l *destinationLease) blobAccessConditions() azblob.BlobAccessConditions {
+	return azblob.BlobAccessConditions{LeaseAccessConditions: l.leaseAccessConditions()}
+}
+
+func (l *destinationLease) pageBlobAccessConditions() azblob.PageBlobAccessConditions {
+	return azblob.PageBlobAccessConditions{LeaseAccessConditions: l.leaseAccessConditions()}
+}
+
+func (l *destinationLease) renewUntilReleased(interval time.Duration) {
+	defer close(l.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.ops.renewLease(l.jptm.Context(), l.id); err != nil {
+				if l.jptm.WasCanceled() {
+					return // the transfer is over already, and the lease is about to be released
+				}
+				// without the lease, something else may be writing to the destination, so what's written can't be trusted
+				l.jptm.FailActiveSend("Renewing the lease on the destination", err)
+				return
+			}
+		}
+	}
+}
+
+// release stops the renewal of the lease, and releases it. It may be called more than once
+func (l *destinationLease) release() {
+	if l == nil {
+		return
+	}
+	l.releaseOnce.Do(func() {
+		close(l.stop)
+		<-l.done
+
+		// the transfer's context may be cancelled already, but the lease must be released anyway
+		ctx, cancel := context.WithTimeout(context.Background(), destinationLeaseReleaseTimeout)
+		defer cancel()
+		if err := l.ops.releaseLease(ctx, l.id); err != nil {
+			l.jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+				fmt.Sprintf("Failed to release the lease on the destination, which will expire within %d seconds: %s", destinationLeaseDuration, err))
+		}
+		ownLeases.remove(l.url)
+	})
+}
+
+// isDestinationBusy tells whether an error says that something else holds a lease on the destination
+func isDestinationBusy(err error) bool {
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	return serviceCode == string(azblob.ServiceCodeLeaseAlreadyPresent) || serviceCode == string(azblob.ServiceCodeLeaseIDMissing)
+}
+
+// failDestinationLease fails a transfer whose destination couldn't be leased, or created so that it could be leased.
+// If that's because something else has it leased, the transfer fails with the status that says that its destination is busy
+func failDestinationLease(jptm IJobPartTransferMgr, where string, err error) {
+	if isDestinationBusy(err) {
+		jptm.FailActiveSendWithStatus(where+", which something else holds a lease on", err, common.ETransferStatus.DestinationBusy())
+		return
+	}
+	jptm.FailActiveSend(where, err)
+}
@@ -0,0 +1,152 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/url"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// directoryLimiter caps the number of transfers in flight to each directory of an Azure Files destination.
+// Creating many files at once in one directory contends for the directory's metadata, which the service answers with
+// a flood of 500s, so a hot directory is gently serialized, while the transfers to other directories proceed at full speed.
+// The transfers to a directory that's at the cap are set aside when they're picked up, and each one that ends there
+// hands its place to the next one set aside, which is scheduled again.
+type directoryLimiter struct {
+	atomicSetAside uint64
+
+	limit    int
+	lock     sync.Mutex
+	inFlight map[string]int
+	// the directory of each transfer that holds a place in one
+	admitted map[IJobPartTransferMgr]string
+	waiting  map[string][]IJobPartTransferMgr
+}
+
+// newDirectoryLimiter returns nil, for no cap, unless the limit is positive
+func newDirectoryLimiter(limit int) *directoryLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &directoryLimiter{
+		limit:    limit,
+		inFlight: make(map[string]int),
+		admitted: make(map[IJobPartTransferMgr]string),
+		waiting:  make(map[string][]IJobPartTransferMgr),
+	}
+}
+
+// limitedDirectoryOf returns the directory of the destination that the transfer is counted in, or "" if it's not limited
+func limitedDirectoryOf(jptm IJobPartTransferMgr) string {
+	if fromTo := jptm.FromTo(); fromTo.To() != common.ELocation.File() {
+		return ""
+	}
+	u, err := url.Parse(jptm.Info().Destination)
+	if err != nil {
+		return ""
+	}
+	return u.Host + path.Dir(u.Path)
+}
+
+// admit tells whether the transfer can start. If its directory is at the cap, the transfer is set aside,
+// until it's given a place by one that ends
+func (l *directoryLimiter) admit(jptm IJobPartTransferMgr) bool {
+	if l == nil {
+		return true
+	}
+	dir := limitedDirectoryOf(jptm)
+	if dir == "" {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.admitted[jptm]; ok {
+		return true // it was handed its place while it was set aside
+	}
+	if l.inFlight[dir] < l.limit {
+		l.inFlight[dir]++
+		l.admitted[jptm] = dir
+		return true
+	}
+	l.waiting[dir] = append(l.waiting[dir], jptm)
+	atomic.AddUint64(&l.atomicSetAside, 1)
+	return false
+}
+
+// release gives up the place of the transfer, which has ended, to the next transfer set aside for its directory, if there's one,
+// which is then scheduled again with reschedule
+func (l *directoryLimiter) release(jptm IJobPartTransferMgr, reschedule func(IJobPartTransferMgr)) {
+	if l == nil {
+		return
+	}
+
+	l.lock.Lock()
+	dir, ok := l.admitted[jptm]
+	if !ok {
+		l.lock.Unlock()
+		return
+	}
+	delete(l.admitted, jptm)
+
+	var next IJobPartTransferMgr
+	if waiting := l.waiting[dir]; len(waiting) > 0 {
+		next = waiting[0]
+		if len(waiting) == 1 {
+			delete(l.waiting, dir)
+		} else {
+			l.waiting[dir] = waiting[1:]
+		}
+		l.admitted[next] = dir
+	} else if l.inFlight[dir]--; l.inFlight[dir] == 0 {
+		delete(l.inFlight, dir)
+	}
+	l.lock.Unlock()
+
+	if next != nil {
+		// not on this goroutine, since the transfer channels may be full
+		go reschedule(next)
+	}
+}
+
+// setAside is the number of times that a transfer was set aside because its directory was at the cap
+func (l *directoryLimiter) setAside() uint64 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.atomicSetAside)
+}
+
+// currentDirectoryLimiter returns the cap of the transfers per directory of the process, or nil if there's none, or no engine, e.g. in tests
+func currentDirectoryLimiter() *directoryLimiter {
+	if ja, ok := JobsAdmin.(*jobsAdmin); ok {
+		return ja.directoryLimiter
+	}
+	return nil
+}
+
+func rescheduleTransfer(jptm IJobPartTransferMgr) {
+	jptm.RescheduleTransfer()
+}
@@ -0,0 +1,161 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+const defaultLowSpaceMarginMB = 512
+
+// how long the free space that was measured is relied on, before the volume is looked at again
+const diskSpaceRecheckInterval = 5 * time.Second
+
+var (
+	lowSpaceMargin      uint64
+	lowSpaceMarginOncer sync.Once
+)
+
+// freeDiskSpace is how the free space of the destination volume is measured. Tests replace it, to fill the volume without filling it
+var freeDiskSpace = common.GetFreeDiskSpace
+
+// LowSpaceMargin is the free space, in bytes, that downloads leave on the volume they save to. It's set by AZCOPY_LOW_SPACE_MARGIN_MB
+func LowSpaceMargin() uint64 {
+	lowSpaceMarginOncer.Do(func() {
+		lowSpaceMargin = defaultLowSpaceMarginMB * 1024 * 1024
+		raw := common.GetLifecycleMgr().GetEnvironmentVariable(common.EEnvironmentVariable.LowSpaceMarginMB())
+		if mb, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			lowSpaceMargin = mb * 1024 * 1024
+		}
+	})
+	return lowSpaceMargin
+}
+
+// diskSpaceGuard holds back the start of new downloads while the volume they save to is low on space,
+// so that a job that fills the volume pauses, rather than failing each of its remaining transfers with ENOSPC.
+// A download is let through once the volume has room for the rest of its file, and the margin, beyond the files
+// that were let through before it. Since a file is created at its full size, what it needs is only taken from the space
+// that's measured once it's created; until then, it's set aside here. Files that are decompressed or resumed grow as they're
+// written instead, which the measurements see as it happens. A nil guard, or one with no margin, lets everything through.
+type diskSpaceGuard struct {
+	margin uint64
+	log    func(msg string)
+
+	lock       sync.Mutex
+	measured   uint64
+	measuredAt time.Time
+	// the space that the downloads that were let through need, and don't have yet
+	pending uint64
+	// the space that the downloads that were let through took since the volume was measured
+	takenSinceMeasured uint64
+	// set once the free space couldn't be measured, after which nothing is held back
+	unmeasurable bool
+
+	atomicPaused int32
+}
+
+func newDiskSpaceGuard(margin uint64, log func(msg string)) *diskSpaceGuard {
+	return &diskSpaceGuard{margin: margin, log: log}
+}
+
+// waitForSpace blocks until the volume that holds path has room for size more bytes, beyond the margin, or until ctx is done.
+// Once it returns nil, the download must call created, with the same size, when it has created its file (or failed to).
+// If the free space can't be measured, nothing is held back.
+func (g *diskSpaceGuard) waitForSpace(ctx context.Context, path string, size int64) error {
+	if g == nil || g.margin == 0 || size <= 0 {
+		return nil
+	}
+
+	for !g.tryTake(path, uint64(size)) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(diskSpaceRecheckInterval):
+		}
+	}
+	return nil
+}
+
+// tryTake sets size aside for a download, if there's room for it
+func (g *diskSpaceGuard) tryTake(path string, size uint64) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.unmeasurable {
+		return true
+	}
+	// while paused, the volume is looked at each time, since it's the space that others free that lets the job carry on
+	if g.paused() || time.Since(g.measuredAt) >= diskSpaceRecheckInterval {
+		free, err := freeDiskSpace(path)
+		if err != nil {
+			g.log(fmt.Sprintf("Could not measure the free space on the volume of %s, so downloads won't wait for it: %s", path, err))
+			g.unmeasurable = true
+			return true
+		}
+		g.measured, g.measuredAt, g.takenSinceMeasured = free, time.Now(), 0
+	}
+
+	needed := g.pending + g.takenSinceMeasured + size + g.margin
+	if g.measured < needed {
+		if atomic.CompareAndSwapInt32(&g.atomicPaused, 0, 1) {
+			g.log(fmt.Sprintf("Holding back new downloads, since the destination volume has %v MB free, "+
+				"and the files in flight, the next file and the margin of %v MB need %v MB. They'll start once there's room.",
+				toMB(g.measured), toMB(g.margin), toMB(needed)))
+		}
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&g.atomicPaused, 1, 0) {
+		g.log(fmt.Sprintf("Carrying on with new downloads, since the destination volume has %v MB free", toMB(g.measured)))
+	}
+	g.pending += size
+	return true
+}
+
+func toMB(bytes uint64) uint64 {
+	return bytes / (1024 * 1024)
+}
+
+// created tells the guard that a download it let through has created its file, so that the file's space is taken
+func (g *diskSpaceGuard) created(size int64) {
+	if g == nil || g.margin == 0 || size <= 0 {
+		return
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.unmeasurable {
+		return
+	}
+	g.pending -= uint64(size)
+	g.takenSinceMeasured += uint64(size)
+}
+
+// paused tells whether new downloads are being held back
+func (g *diskSpaceGuard) paused() bool {
+	return g != nil && atomic.LoadInt32(&g.atomicPaused) == 1
+}
@@ -0,0 +1,208 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+const defaultDNSCacheTTL = 30 * time.Second
+
+// after this many dials in a row fail to every cached address of a host, the addresses are taken to be stale
+const dnsCacheFailuresBeforeReresolve = 2
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// dnsCache keeps what the hosts of the connections resolved to, for a while (AZCOPY_DNS_CACHE_TTL_SECONDS), so that the many
+// connections of a job don't each look the host up. Yet the addresses aren't kept for the whole job, since the addresses of
+// endpoints, e.g. those behind Private Link, can change while it runs. When the dials keep failing to the cached addresses,
+// they're forgotten before their time, so that the next dial looks the host up again, and the pooled connections are retired.
+// A nil cache is valid, and leaves every dial to look the host up.
+type dnsCache struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+	now    func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]*dnsCacheEntry
+
+	atomicResolutions uint64
+	atomicHits        uint64
+	atomicRetired     uint64
+}
+
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+	// the dials in a row that failed to every one of the addresses
+	failures int
+}
+
+func newDNSCache(ttl time.Duration, lookup func(ctx context.Context, host string) ([]net.IPAddr, error)) *dnsCache {
+	return &dnsCache{ttl: ttl, lookup: lookup, now: time.Now, entries: make(map[string]*dnsCacheEntry)}
+}
+
+var (
+	dnsCacheOfProcess *dnsCache
+	dnsCacheOncer     sync.Once
+)
+
+// sharedDNSCache is the cache of the HTTP clients of the process, or nil if AZCOPY_DNS_CACHE_TTL_SECONDS is 0
+func sharedDNSCache() *dnsCache {
+	dnsCacheOncer.Do(func() {
+		ttl := defaultDNSCacheTTL
+		raw := common.GetLifecycleMgr().GetEnvironmentVariable(common.EEnvironmentVariable.DNSCacheTTLSeconds())
+		if seconds, err := strconv.ParseFloat(raw, 64); err == nil && seconds >= 0 {
+			ttl = time.Duration(seconds * float64(time.Second))
+		}
+		if ttl > 0 {
+			dnsCacheOfProcess = newDNSCache(ttl, net.DefaultResolver.LookupIPAddr)
+		}
+	})
+	return dnsCacheOfProcess
+}
+
+// resolve returns the addresses of the host, and whether they came from the cache
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]net.IPAddr, bool, error) {
+	c.lock.Lock()
+	if e, ok := c.entries[host]; ok && c.now().Before(e.expires) {
+		c.lock.Unlock()
+		atomic.AddUint64(&c.atomicHits, 1)
+		return e.addrs, true, nil
+	}
+	c.lock.Unlock()
+
+	// concurrent dials of a host that isn't cached may each look it up, which is no worse than having no cache
+	addrs, err := c.lookup(ctx, host)
+	atomic.AddUint64(&c.atomicResolutions, 1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[host] = &dnsCacheEntry{addrs: addrs, expires: c.now().Add(c.ttl)}
+	return addrs, false, nil
+}
+
+// connected notes that a dial to one of the cached addresses of the host worked
+func (c *dnsCache) connected(host string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.entries[host]; ok {
+		e.failures = 0
+	}
+}
+
+// connectFailed notes that a dial failed to every cached address of the host,
+// and tells whether the addresses were forgotten as a result, since they look stale
+func (c *dnsCache) connectFailed(host string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[host]
+	if !ok {
+		return false
+	}
+	e.failures++
+	if e.failures < dnsCacheFailuresBeforeReresolve {
+		return false
+	}
+	delete(c.entries, host)
+	atomic.AddUint64(&c.atomicRetired, 1)
+	return true
+}
+
+// dialContext wraps the dial, so that it dials the cached addresses of the host. When the cached addresses are forgotten,
+// since they look stale, retire is called, to close the pooled connections, which may be to those addresses
+func (c *dnsCache) dialContext(dial dialFunc, retire func()) dialFunc {
+	if c == nil {
+		return dial
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, address)
+		}
+
+		addrs, cached, err := c.resolve(ctx, host)
+		if err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+
+		var firstErr error
+		for _, a := range addrs {
+			if !dialableFamily(network, a.IP) {
+				continue
+			}
+			conn, err := dial(ctx, network, net.JoinHostPort(a.String(), port))
+			if err == nil {
+				c.connected(host)
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if firstErr == nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+		}
+
+		if cached && ctx.Err() == nil && c.connectFailed(host) && retire != nil {
+			retire()
+		}
+		return nil, firstErr
+	}
+}
+
+// dialableFamily tells whether the address is of the family that the network, and the local address the connections are bound to, allow
+func dialableFamily(network string, ip net.IP) bool {
+	isIPv4 := ip.To4() != nil
+	if local := common.GlobalLocalAddr; local != nil && (local.IP.To4() != nil) != isIPv4 {
+		return false
+	}
+	switch network {
+	case "tcp4":
+		return isIPv4
+	case "tcp6":
+		return !isIPv4
+	default:
+		return true
+	}
+}
+
+// stats are the lookups of hosts, the dials that used cached addresses instead, and the times that cached addresses were forgotten as stale
+func (c *dnsCache) stats() (resolutions, hits, retired uint64) {
+	if c == nil {
+		return 0, 0, 0
+	}
+	return atomic.LoadUint64(&c.atomicResolutions), atomic.LoadUint64(&c.atomicHits), atomic.LoadUint64(&c.atomicRetired)
+}
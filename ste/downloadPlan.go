@@ -0,0 +1,96 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// singleGetThreshold is the size up to which a file is downloaded by one GET of the whole of it, whatever the block size.
+// Splitting such a file into ranges only adds requests, each of which costs more than the bytes it saves from waiting on the others.
+const singleGetThreshold = int64(common.DefaultBlockBlobBlockSize)
+
+// coalesceFileSizeLimit is the size up to which a file is counted as mid-size, and so may have its chunks coalesced.
+// The chunks of larger files are left as they are, since it's their number that keeps the connections busy.
+const coalesceFileSizeLimit = 8 * singleGetThreshold
+
+// downloadPlan is how a file is split into the requests that download it
+type downloadPlan struct {
+	// the size of each chunk but the last, each of which is one request
+	chunkSize int64
+	numChunks uint32
+	// whether the file is downloaded by one GET of the whole of it, rather than by ranges
+	wholeFile bool
+}
+
+// planDownload decides how the part of the file after savedLength is split into chunks.
+//   - A small file, if the downloader can do that, is downloaded by one GET of the whole of it, which has no range.
+//   - Adjacent chunks of a mid-size file are coalesced into chunks of up to the default block size (or of up to the largest range
+//     that the service sends the MD5 hash of, if the chunks are verified), if the job has enough files to keep the connections busy
+//     without splitting each of them finely. The coalesced chunks are a multiple of the block size, so that resuming still lines up.
+//   - Otherwise the chunks are of the block size.
+func planDownload(fileSize, savedLength, blockSize int64, canGetWholeFile, canCoalesce, verifiesChunks bool) downloadPlan {
+	if canGetWholeFile && savedLength == 0 && fileSize <= singleGetThreshold {
+		return downloadPlan{chunkSize: fileSize, numChunks: 1, wholeFile: true}
+	}
+
+	chunkSize := blockSize
+	if canCoalesce && fileSize <= coalesceFileSizeLimit {
+		maxChunkSize := singleGetThreshold
+		if verifiesChunks {
+			maxChunkSize = maxRangeGetContentMD5Bytes
+		}
+		if blockSize < maxChunkSize {
+			chunkSize = maxChunkSize / blockSize * blockSize
+		}
+	}
+
+	remaining := fileSize - savedLength
+	numChunks := uint32(remaining / chunkSize)
+	if remaining%chunkSize != 0 {
+		numChunks++
+	}
+	return downloadPlan{chunkSize: chunkSize, numChunks: numChunks}
+}
+
+// wholeFileDownloader is implemented by the downloaders that can download a small file with one GET of the whole of it,
+// which checks that the file didn't change since it was enumerated by the properties in the response, rather than by a condition on the request
+type wholeFileDownloader interface {
+	downloadWholeFile()
+}
+
+// canGetWholeFile tells whether the transfer can download its file with one GET of the whole of it.
+// Page blobs can't, since their empty ranges are skipped, nor can a download of part of the source.
+func canGetWholeFile(jptm IJobPartTransferMgr, dl downloader) bool {
+	if _, ok := dl.(wholeFileDownloader); !ok {
+		return false
+	}
+	_, ranged := jptm.DownloadRange()
+	return !ranged && jptm.DiffBaseSnapshot() == "" && jptm.Info().SrcBlobType != azblob.BlobPageBlob
+}
+
+// canCoalesceChunks tells whether the transfer's chunks may be coalesced. They may not for page blobs, whose empty
+// (or unchanged) ranges are skipped chunk by chunk, nor when the job has so few files that it takes many chunks of each to keep the connections busy
+func canCoalesceChunks(jptm IJobPartTransferMgr) bool {
+	return jptm.DiffBaseSnapshot() == "" && jptm.Info().SrcBlobType != azblob.BlobPageBlob && !jptm.JobHasLowFileCount()
+}
@@ -0,0 +1,109 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// downloadResumeSyncInterval is how much of a download is written between the times that its file is synced, and its progress recorded
+// in the plan file. It bounds both the cost of the syncs, and how much a resumed download gets again. Smaller files are never recorded
+const downloadResumeSyncInterval = 256 * 1024 * 1024
+
+// canResumeDownload tells whether a later attempt at the download could carry on from what this one saves. It can't if nothing is saved,
+// if what is saved is not what is read (decompression), or if the file is changed in place (incremental downloads).
+// Nor when the checksum of the file is computed, since that's computed as the file is written, and can't be picked up again
+func canResumeDownload(jptm IJobPartTransferMgr, info TransferInfo) bool {
+	return !strings.EqualFold(info.Destination, common.Dev_Null) && !jptm.ShouldDecompress() && jptm.DiffBaseSnapshot() == "" && jptm.ChecksumHasher() == nil
+}
+
+// savedLengthToResumeFrom returns how much of the destination file, that an earlier attempt at the download saved, this one keeps.
+// That's nothing, and the reason is logged, if the file is not as that attempt left it. It's never past the start of the last chunk,
+// so that there's always a chunk to download, and the epilogue runs as usual when it's done.
+func savedLengthToResumeFrom(jptm IJobPartTransferMgr, info TransferInfo, fileSize int64, chunkSize int64) int64 {
+	savedLength, modTime := jptm.SavedDownload()
+	if savedLength <= 0 {
+		return 0
+	}
+
+	var reason string
+	fi, err := os.Stat(info.Destination)
+	switch {
+	case err != nil:
+		reason = "it could not be found: " + err.Error()
+	case fi.Size() != fileSize:
+		reason = fmt.Sprintf("its size is %d bytes, rather than %d", fi.Size(), fileSize)
+	case fi.ModTime().Before(modTime):
+		reason = "it is older than it was then, so it was replaced"
+	}
+	if reason != "" {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The download starts over, rather than carrying on from the part of the file that an earlier attempt saved, because "+reason)
+		jptm.SetSavedDownload(0, time.Time{})
+		return 0
+	}
+
+	if lastChunkStart := (fileSize - 1) / chunkSize * chunkSize; savedLength > lastChunkStart {
+		savedLength = lastChunkStart
+	}
+	if savedLength == 0 {
+		// the file is a single chunk, so it's all downloaded again, and what it held then isn't kept
+		jptm.SetSavedDownload(0, time.Time{})
+		return 0
+	}
+	jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Carrying on from the first %d bytes of the file, which an earlier attempt saved", savedLength))
+	return savedLength
+}
+
+// openDestinationForResume opens the file that an earlier attempt saved the start of, positioned at the end of that
+func openDestinationForResume(destination string, savedLength int64) (*os.File, error) {
+	f, err := os.OpenFile(destination, os.O_RDWR, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(savedLength, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// newChunkedFileResume describes, to the chunked file writer, where the download carries on from, and records its progress in the plan file
+func newChunkedFileResume(jptm IJobPartTransferMgr, file *os.File, savedLength int64) common.ChunkedFileResume {
+	return common.ChunkedFileResume{
+		SavedLength:  savedLength,
+		SavedContent: io.NewSectionReader(file, 0, savedLength),
+		SyncInterval: downloadResumeSyncInterval,
+		OnDurablySaved: func(savedLength int64) {
+			// the modification time lets a later attempt tell whether the file was replaced since
+			if fi, err := file.Stat(); err == nil {
+				jptm.SetSavedDownload(savedLength, fi.ModTime())
+			}
+		},
+	}
+}
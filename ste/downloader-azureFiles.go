@@ -57,30 +57,40 @@ func (bd *azureFilesDownloader) GenerateDownloadFunc(jptm IJobPartTransferMgr, s
 		// wait until we get the headers back... but we have not yet read its whole body.
 		// The Download method encapsulates any retries that may be necessary to get to the point of receiving response headers.
 		jptm.LogChunkStatus(id, common.EWaitReason.HeaderResponse())
-		get, err := srcFileURL.Download(jptm.Context(), id.OffsetInFile(), length, false)
-		if err != nil {
-			jptm.FailActiveDownload("Downloading response body", err) // cancel entire transfer because this chunk has failed
-			return
-		}
+		getRangeMD5 := shouldGetRangeMD5(jptm, length)
+		for attempt := 0; ; attempt++ {
+			get, err := srcFileURL.Download(jptm.Context(), offsetInSource(jptm, id), length, getRangeMD5)
+			if err != nil {
+				jptm.FailActiveDownload("Downloading response body", err) // cancel entire transfer because this chunk has failed
+				return
+			}
 
-		// Verify that the file has not been changed via a client side LMT check
-		getLocation := get.LastModified().Location()
-		if !get.LastModified().Equal(jptm.LastModifiedTime().In(getLocation)) {
-			jptm.FailActiveDownload("Azure File modified during transfer",
-				errors.New("Azure File modified during transfer"))
-		}
+			// Verify that the file has not been changed via a client side LMT check
+			getLocation := get.LastModified().Location()
+			if !get.LastModified().Equal(jptm.LastModifiedTime().In(getLocation)) {
+				jptm.FailActiveDownload("Azure File modified during transfer",
+					errors.New("Azure File modified during transfer"))
+			}
 
-		// step 2: Enqueue the response body to be written out to disk
-		// The retryReader encapsulates any retries that may be necessary while downloading the body
-		jptm.LogChunkStatus(id, common.EWaitReason.Body())
-		retryReader := get.Body(azfile.RetryReaderOptions{
-			MaxRetryRequests: MaxRetryPerDownloadBody,
-			NotifyFailedRead: common.NewReadLogFunc(jptm, u),
-		})
-		defer retryReader.Close()
-		err = destWriter.EnqueueChunk(jptm.Context(), id, length, newPacedResponseBody(jptm.Context(), retryReader, pacer), true)
-		if err != nil {
-			jptm.FailActiveDownload("Enqueuing chunk", err)
+			// step 2: Enqueue the response body to be written out to disk
+			// The retryReader encapsulates any retries that may be necessary while downloading the body
+			jptm.LogChunkStatus(id, common.EWaitReason.Body())
+			retryReader := get.Body(azfile.RetryReaderOptions{
+				MaxRetryRequests: MaxRetryPerDownloadBody,
+				NotifyFailedRead: common.NewReadLogFunc(jptm, u),
+			})
+			var expectedMD5 []byte
+			if getRangeMD5 {
+				expectedMD5 = get.ContentMD5() // otherwise this may be the hash of the whole file, rather than of the range
+			}
+			err = destWriter.EnqueueVerifiedChunk(jptm.Context(), id, length, newPacedResponseBody(jptm.Context(), retryReader, pacer), true, expectedMD5)
+			retryReader.Close()
+			if shouldRetryChunkAfterMD5Mismatch(jptm, id, attempt, err) {
+				continue
+			}
+			if err != nil {
+				jptm.FailActiveDownload("Enqueuing chunk", err)
+			}
 			return
 		}
 	})
@@ -22,7 +22,9 @@ package ste
 
 import (
 	"context"
+	"errors"
 	"net/url"
+	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-azcopy/common"
@@ -38,6 +40,12 @@ type blobDownloader struct {
 
 	// used to avoid downloading zero ranges of page blobs
 	pageRangeOptimizer *pageRangeOptimizer
+
+	// the ranges that changed since the base snapshot, if only those are downloaded
+	diff *pageBlobDiff
+
+	// whether the blob is small enough to be downloaded by one GET of the whole of it
+	wholeFile bool
 }
 
 func newBlobDownloader() downloader {
@@ -53,6 +61,10 @@ func (bd *blobDownloader) Prologue(jptm IJobPartTransferMgr, srcPipeline pipelin
 		// See comments in uploader-pageBlob for the reasons, since the same reasons apply are are explained there
 		bd.filePacer = newPageBlobAutoPacer(pageBlobInitialBytesPerSecond, jptm.Info().BlockSize, false, jptm.(common.ILogger))
 
+		if _, ranged := jptm.DownloadRange(); bd.diff != nil || ranged {
+			// the diff already tells which ranges to download, and the page ranges of a window would be at the wrong offsets in the file
+			return
+		}
 		u, _ := url.Parse(jptm.Info().Source)
 		bd.pageRangeOptimizer = newPageRangeOptimizer(azblob.NewPageBlobURL(*u, srcPipeline),
 			context.WithValue(jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion))
@@ -60,6 +72,23 @@ func (bd *blobDownloader) Prologue(jptm IJobPartTransferMgr, srcPipeline pipelin
 	}
 }
 
+func (bd *blobDownloader) prepareDiff(jptm IJobPartTransferMgr, srcPipeline pipeline.Pipeline) (*pageBlobDiff, error) {
+	if jptm.Info().SrcBlobType != azblob.BlobPageBlob {
+		return nil, errNotAPageBlob
+	}
+	u, _ := url.Parse(jptm.Info().Source)
+	diff, err := getPageBlobDiff(jptm.Context(), azblob.NewPageBlobURL(*u, srcPipeline), jptm.DiffBaseSnapshot())
+	if err != nil {
+		return nil, err
+	}
+	bd.diff = diff
+	return diff, nil
+}
+
+func (bd *blobDownloader) downloadWholeFile() {
+	bd.wholeFile = true
+}
+
 func (bd *blobDownloader) Epilogue() {
 	_ = bd.filePacer.Close()
 }
@@ -68,6 +97,15 @@ func (bd *blobDownloader) Epilogue() {
 func (bd *blobDownloader) GenerateDownloadFunc(jptm IJobPartTransferMgr, srcPipeline pipeline.Pipeline, destWriter common.ChunkedFileWriter, id common.ChunkID, length int64, pacer pacer) chunkFunc {
 	return createDownloadChunkFunc(jptm, id, func() {
 
+		// If nothing in the range changed since the base snapshot, the file already holds the right data
+		if bd.diff != nil && bd.diff.isUnchangedIn(id.OffsetInFile(), length) {
+			err := destWriter.EnqueueUnchangedChunk(jptm.Context(), id, length)
+			if err != nil {
+				jptm.FailActiveDownload("Enqueuing chunk", err)
+			}
+			return
+		}
+
 		// If the range does not contain any data, write out empty data to disk without performing download
 		if bd.pageRangeOptimizer != nil && !bd.pageRangeOptimizer.doesRangeContainData(
 			azblob.PageRange{Start: id.OffsetInFile(), End: id.OffsetInFile() + length - 1}) {
@@ -109,33 +147,70 @@ func (bd *blobDownloader) GenerateDownloadFunc(jptm IJobPartTransferMgr, srcPipe
 			accessConditions = azblob.BlobAccessConditions{}
 		}
 
-		// At this point we create an HTTP(S) request for the desired portion of the blob, and
-		// wait until we get the headers back... but we have not yet read its whole body.
-		// The Download method encapsulates any retries that may be necessary to get to the point of receiving response headers.
-		jptm.LogChunkStatus(id, common.EWaitReason.HeaderResponse())
-		enrichedContext := withRetryNotification(jptm.Context(), bd.filePacer)
-		get, err := srcBlobURL.Download(enrichedContext, id.OffsetInFile(), length, accessConditions, false)
-		if err != nil {
-			jptm.FailActiveDownload("Downloading response body", err) // cancel entire transfer because this chunk has failed
-			return
-		}
+		getRangeMD5 := shouldGetRangeMD5(jptm, length)
+		for attempt := 0; ; attempt++ {
+			// At this point we create an HTTP(S) request for the desired portion of the blob, and
+			// wait until we get the headers back... but we have not yet read its whole body.
+			// The Download method encapsulates any retries that may be necessary to get to the point of receiving response headers.
+			jptm.LogChunkStatus(id, common.EWaitReason.HeaderResponse())
+			enrichedContext := withRetryNotification(jptm.Context(), bd.filePacer)
+			var get *azblob.DownloadResponse
+			var err error
+			if bd.wholeFile {
+				get, err = getWholeBlob(enrichedContext, jptm, srcBlobURL, length, getRangeMD5, !isNewStyleImpExp && !isOldStyleDiskExport)
+			} else {
+				get, err = srcBlobURL.Download(enrichedContext, offsetInSource(jptm, id), length, accessConditions, getRangeMD5)
+			}
+			if err != nil {
+				jptm.FailActiveDownload("Downloading response body", err) // cancel entire transfer because this chunk has failed
+				return
+			}
 
-		// Enqueue the response body to be written out to disk
-		// The retryReader encapsulates any retries that may be necessary while downloading the body
-		jptm.LogChunkStatus(id, common.EWaitReason.Body())
-		retryReader := get.Body(azblob.RetryReaderOptions{
-			MaxRetryRequests: destWriter.MaxRetryPerDownloadBody(),
-			NotifyFailedRead: common.NewReadLogFunc(jptm, u),
-		})
-		defer retryReader.Close()
-		err = destWriter.EnqueueChunk(jptm.Context(), id, length, newPacedResponseBody(jptm.Context(), retryReader, pacer), true)
-		if err != nil {
-			jptm.FailActiveDownload("Enqueuing chunk", err)
+			// Enqueue the response body to be written out to disk
+			// The retryReader encapsulates any retries that may be necessary while downloading the body
+			jptm.LogChunkStatus(id, common.EWaitReason.Body())
+			retryReader := get.Body(azblob.RetryReaderOptions{
+				MaxRetryRequests: destWriter.MaxRetryPerDownloadBody(),
+				NotifyFailedRead: common.NewReadLogFunc(jptm, u),
+			})
+			var expectedMD5 []byte
+			if getRangeMD5 {
+				expectedMD5 = get.ContentMD5() // otherwise this may be the hash of the whole file, rather than of the range
+			}
+			err = destWriter.EnqueueVerifiedChunk(jptm.Context(), id, length, newPacedResponseBody(jptm.Context(), retryReader, pacer), true, expectedMD5)
+			retryReader.Close()
+			if shouldRetryChunkAfterMD5Mismatch(jptm, id, attempt, err) {
+				continue
+			}
+			if err != nil {
+				jptm.FailActiveDownload("Enqueuing chunk", err)
+			}
 			return
 		}
 	})
 }
 
+// getWholeBlob downloads the whole of a small blob with one GET, which has no condition. Nor does it have a range,
+// unless one is needed to have the service send the MD5 hash of the data, so that it can be verified as soon as it arrives.
+// It's the properties in the response that show whether the blob changed since it was enumerated, so no request is needed to get them first.
+func getWholeBlob(ctx context.Context, jptm IJobPartTransferMgr, blobURL azblob.BlobURL, length int64, getMD5, checkLmt bool) (*azblob.DownloadResponse, error) {
+	count := int64(azblob.CountToEnd)
+	if getMD5 {
+		count = length
+	}
+	get, err := blobURL.Download(ctx, 0, count, azblob.BlobAccessConditions{}, getMD5)
+	if err != nil {
+		return nil, err
+	}
+
+	lmt := jptm.LastModifiedTime()
+	if get.ContentLength() != length || (checkLmt && lmt.After(time.Unix(0, 0)) && !get.LastModified().Equal(lmt)) {
+		_ = get.Response().Body.Close()
+		return nil, errors.New("the source was modified after it was enumerated")
+	}
+	return get, nil
+}
+
 type dummyReader struct{}
 
 func (dummyReader) Read(p []byte) (n int, err error) {
@@ -51,3 +51,10 @@ func createDownloadChunkFunc(jptm IJobPartTransferMgr, id common.ChunkID, body f
 	// by the ChunkedFileWriter. (The ChunkedFileWriter will set the status to done at that time.)
 	return createChunkFunc(false, jptm, id, body)
 }
+
+// offsetInSource returns where the chunk starts in the source. That's where it starts in the file, unless only a window
+// of the source is downloaded, in which case the file holds the window and the chunk starts that much further into the source
+func offsetInSource(jptm IJobPartTransferMgr, id common.ChunkID) int64 {
+	offset, _ := jptm.DownloadRange()
+	return offset + id.OffsetInFile()
+}
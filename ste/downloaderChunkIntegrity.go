@@ -0,0 +1,54 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// the largest range for which the service will send the MD5 hash of the range
+const maxRangeGetContentMD5Bytes = 4 * 1024 * 1024
+
+// shouldGetRangeMD5 tells whether to ask the service for the MD5 hash of a chunk's range, so that the chunk can be verified as soon as
+// it arrives. The service only sends it for ranges of up to 4 MiB. It can send a CRC64 for larger ranges, but the SDKs we use have no
+// way to ask for that, so larger chunks are only verified as part of the whole file.
+func shouldGetRangeMD5(jptm IJobPartTransferMgr, length int64) bool {
+	return length <= maxRangeGetContentMD5Bytes && jptm.MD5ValidationOption() != common.EHashValidationOption.NoCheck()
+}
+
+// shouldRetryChunkAfterMD5Mismatch tells whether a chunk, whose attempt'th download (counting from zero) got the given error
+// when it was enqueued, should be downloaded again. That is the case if the chunk didn't match its MD5 hash, and it hasn't already
+// been retried as many times as we retry the download of a body.
+func shouldRetryChunkAfterMD5Mismatch(jptm IJobPartTransferMgr, id common.ChunkID, attempt int, err error) bool {
+	if err != common.ChunkMD5Mismatch || attempt >= MaxRetryPerDownloadBody {
+		return false
+	}
+
+	jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+		fmt.Sprintf("The chunk at offset %d did not match the MD5 hash that was sent with it, so it will be downloaded again (retry %d of %d)",
+			id.OffsetInFile(), attempt+1, MaxRetryPerDownloadBody))
+	jptm.ReportChunkIntegrityRetry()
+	return true
+}
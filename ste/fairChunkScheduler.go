@@ -0,0 +1,161 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"math/bits"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// fairMaxWeight is the most chunks that one transfer gets processed in each round, however many chunks it has
+const fairMaxWeight = 8
+
+// fairChunkScheduler hands the chunks to the chunk processors by weighted round-robin across the transfers that have chunks waiting,
+// rather than in the order in which they were scheduled, so that the chunks of one huge transfer can't keep those of the other
+// transfers waiting for hours. In each round, every transfer with chunks waiting gets up to its weight of them processed.
+// As with the channels, the chunks of the transfers of normal priority all go before those of low priority.
+type fairChunkScheduler struct {
+	lock   sync.Mutex
+	normal fairChunkRing
+	low    fairChunkRing
+
+	// bounds the number of chunks waiting, as the capacity of the channels does when the scheduling isn't fair
+	slots chan struct{}
+}
+
+// fairChunkRing holds the chunks of the transfers of one priority
+type fairChunkRing struct {
+	queues map[interface{}]*fairTransferQueue
+	// the transfers with chunks waiting, in their order in the round
+	active []*fairTransferQueue
+	// the position in active of the transfer whose turn it is
+	next int
+}
+
+type fairTransferQueue struct {
+	transfer interface{}
+	weight   int
+	chunks   []chunkFunc
+	// how many chunks the transfer has had processed in its current turn
+	taken int
+}
+
+func newFairChunkScheduler(capacity int) *fairChunkScheduler {
+	return &fairChunkScheduler{
+		normal: fairChunkRing{queues: make(map[interface{}]*fairTransferQueue)},
+		low:    fairChunkRing{queues: make(map[interface{}]*fairTransferQueue)},
+		slots:  make(chan struct{}, capacity),
+	}
+}
+
+// fairWeightOf is the number of chunks that a transfer of numChunks chunks gets processed in each round.
+// It grows with the log of the number of chunks, so that big transfers still move faster than small ones, up to fairMaxWeight
+func fairWeightOf(numChunks uint32) int {
+	weight := bits.Len32(numChunks)
+	if weight < 1 {
+		weight = 1
+	}
+	if weight > fairMaxWeight {
+		weight = fairMaxWeight
+	}
+	return weight
+}
+
+// schedule queues the chunk behind the other chunks of the transfer. It waits while the scheduler is full
+func (s *fairChunkScheduler) schedule(priority common.JobPriority, transfer interface{}, weight int, chunk chunkFunc) {
+	var ring *fairChunkRing
+	switch priority {
+	case common.EJobPriority.Normal():
+		ring = &s.normal
+	case common.EJobPriority.Low():
+		ring = &s.low
+	default:
+		panic("invalid priority: " + priority.String())
+	}
+
+	s.slots <- struct{}{}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ring.add(transfer, weight, chunk)
+}
+
+// next takes the next chunk to process, if there's one waiting
+func (s *fairChunkScheduler) next() (chunkFunc, bool) {
+	s.lock.Lock()
+	chunk, ok := s.normal.take()
+	if !ok {
+		chunk, ok = s.low.take()
+	}
+	s.lock.Unlock()
+
+	if ok {
+		<-s.slots
+	}
+	return chunk, ok
+}
+
+func (r *fairChunkRing) add(transfer interface{}, weight int, chunk chunkFunc) {
+	q, ok := r.queues[transfer]
+	if !ok {
+		// the transfer joins at the end of the round, so it waits for its turn like the others
+		q = &fairTransferQueue{transfer: transfer, weight: weight}
+		r.queues[transfer] = q
+		r.active = append(r.active, q)
+	}
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (r *fairChunkRing) take() (chunkFunc, bool) {
+	if len(r.active) == 0 {
+		return nil, false
+	}
+	if r.next >= len(r.active) {
+		r.next = 0
+	}
+
+	q := r.active[r.next]
+	chunk := q.chunks[0]
+	q.chunks[0] = nil // so that the chunk can be collected once it's processed
+	q.chunks = q.chunks[1:]
+	q.taken++
+
+	switch {
+	case len(q.chunks) == 0:
+		// the transfer has nothing else waiting, so it leaves the round, and the turn passes to the one after it
+		delete(r.queues, q.transfer)
+		r.active = append(r.active[:r.next], r.active[r.next+1:]...)
+	case q.taken >= q.weight:
+		q.taken = 0
+		r.next++
+	}
+	return chunk, true
+}
+
+// currentFairChunkScheduler returns the fair scheduler of the process, or nil if the scheduling isn't fair, or there's no engine, e.g. in tests
+func currentFairChunkScheduler() *fairChunkScheduler {
+	if ja, ok := JobsAdmin.(*jobsAdmin); ok {
+		return ja.fairChunkScheduler
+	}
+	return nil
+}
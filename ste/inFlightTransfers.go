@@ -0,0 +1,91 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// InFlightTransferThreshold is the size from which a file's own progress is shown while it's in flight,
+// since a job of a few such files can otherwise show none of them done for hours
+const InFlightTransferThreshold = 1024 * 1024 * 1024
+
+// maxInFlightTransfersShown is how many of the largest in-flight files have their progress shown
+const maxInFlightTransfersShown = 3
+
+// largestInFlight picks the largest of the transfers that are in flight, as the summary of a job looks at each of its transfers
+type largestInFlight struct {
+	transfers []inFlightTransfer
+}
+
+type inFlightTransfer struct {
+	plan      *JobPartPlanHeader
+	index     uint32
+	size      int64
+	bytesDone int64
+	started   time.Time
+}
+
+// consider keeps the transfer, if it's in flight and among the largest so far
+func (l *largestInFlight) consider(plan *JobPartPlanHeader, index uint32, jppt *JobPartPlanTransfer) {
+	if jppt.SourceSize < InFlightTransferThreshold || jppt.TransferStatus() != common.ETransferStatus.Started() {
+		return
+	}
+	bytesDone, started := jppt.Progress()
+	if started.IsZero() {
+		return // it hasn't been picked up yet
+	}
+
+	l.transfers = append(l.transfers, inFlightTransfer{plan: plan, index: index, size: jppt.SourceSize, bytesDone: bytesDone, started: started})
+	sort.SliceStable(l.transfers, func(i, j int) bool { return l.transfers[i].size > l.transfers[j].size })
+	if len(l.transfers) > maxInFlightTransfersShown {
+		l.transfers = l.transfers[:maxInFlightTransfersShown]
+	}
+}
+
+// progress returns the progress of the transfers that were kept, the largest first
+func (l *largestInFlight) progress(now time.Time) []common.InFlightTransfer {
+	if len(l.transfers) == 0 {
+		return nil
+	}
+
+	result := make([]common.InFlightTransfer, 0, len(l.transfers))
+	for _, t := range l.transfers {
+		src, dst := t.plan.TransferSrcDstStrings(t.index)
+		p := common.InFlightTransfer{
+			Src:             src,
+			Dst:             dst,
+			Size:            uint64(t.size),
+			BytesDone:       uint64(t.bytesDone),
+			PercentComplete: 100 * float32(t.bytesDone) / float32(t.size),
+		}
+		if elapsed := now.Sub(t.started).Seconds(); elapsed > 0 && t.bytesDone > 0 {
+			bytesPerSecond := float64(t.bytesDone) / elapsed
+			p.ThroughputMbps = bytesPerSecond * 8 / (1000 * 1000)
+			p.SecondsRemaining = uint64(float64(t.size-t.bytesDone) / bytesPerSecond)
+		}
+		result = append(result, p)
+	}
+	return result
+}
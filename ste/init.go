@@ -27,6 +27,8 @@ import (
 	"io/ioutil"
 	"math"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -50,9 +52,9 @@ func ToFixed(num float64, precision int) float64 {
 }
 
 // MainSTE initializes the Storage Transfer Engine
-func MainSTE(concurrency ConcurrencySettings, targetRateInMegaBitsPerSec int64, azcopyJobPlanFolder, azcopyLogPathFolder string, providePerfAdvice bool) error {
+func MainSTE(concurrency ConcurrencySettings, targetRateInMegaBitsPerSec int64, capTransactionsPerSecond int64, capMetadataTransactionsPerSecond int64, scheduling common.ChunkScheduling, azcopyJobPlanFolder, azcopyLogPathFolder string, logRotation common.LogRotationSettings, logFormat common.LogFormat, systemLogger common.ISystemLogger, providePerfAdvice bool) error {
 	// Initialize the JobsAdmin, resurrect Job plan files
-	initJobsAdmin(steCtx, concurrency, targetRateInMegaBitsPerSec, azcopyJobPlanFolder, azcopyLogPathFolder, providePerfAdvice)
+	initJobsAdmin(steCtx, concurrency, targetRateInMegaBitsPerSec, capTransactionsPerSecond, capMetadataTransactionsPerSecond, scheduling, azcopyJobPlanFolder, azcopyLogPathFolder, logRotation, logFormat, systemLogger, providePerfAdvice)
 	// No need to read the existing JobPartPlan files since Azcopy is running in process
 	//JobsAdmin.ResurrectJobParts()
 	// TODO: We may want to list listen first and terminate if there is already an instance listening
@@ -150,8 +152,14 @@ func ExecuteNewCopyJobPartOrder(order common.CopyJobPartOrderRequest) common.Cop
 	// Get credential info from RPC request order, and set in InMemoryTransitJobState.
 	jpm.setInMemoryTransitJobState(
 		InMemoryTransitJobState{
-			credentialInfo: order.CredentialInfo,
+			credentialInfo:  order.CredentialInfo,
+			metricsFile:     order.MetricsFile,
+			checksumFile:    order.ChecksumFile,
+			verifyChecksums: order.VerifyChecksums,
 		})
+	if order.PartNum == 0 {
+		jpm.reportJobStartToSystemLog(false)
+	}
 	// Supply no plan MMF because we don't have one, and AddJobPart will create one on its own.
 	jpm.AddJobPart(order.PartNum, jppfn, nil, order.SourceSAS, order.DestinationSAS, true) // Add this part to the Job and schedule its transfers
 	return common.CopyJobPartOrderResponse{JobStarted: true}
@@ -169,10 +177,10 @@ func CancelPauseJobOrder(jobID common.JobID, desiredJobStatus common.JobStatus)
 	if !found {
 		// If the Job is not found, search for Job Plan files in the existing plan file
 		// and resurrect the job
-		if !JobsAdmin.ResurrectJob(jobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING) {
+		if err := JobsAdmin.ResurrectJob(jobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING, nil); err != nil {
 			return common.CancelPauseResumeResponse{
 				CancelledPauseResumed: false,
-				ErrorMsg:              fmt.Sprintf("no active job with JobId %s exists", jobID.String()),
+				ErrorMsg:              resurrectionErrorMsg(err, fmt.Sprintf("no active job with JobId %s exists", jobID.String())),
 			}
 		}
 		jm, _ = JobsAdmin.JobMgr(jobID)
@@ -224,6 +232,8 @@ func CancelPauseJobOrder(jobID common.JobID, desiredJobStatus common.JobStatus)
 		if jm.ShouldLog(pipeline.LogInfo) {
 			jm.Log(pipeline.LogInfo, msg)
 		}
+		// the refreshers that wait for the token to be renewed let go, and so do the new transfers, which are then dropped
+		currentCredentialExpiryGuard().giveUp()
 		jm.Cancel() // Stop all inflight-chunks/transfer for this job (this includes all parts)
 		jr = common.CancelPauseResumeResponse{
 			CancelledPauseResumed: true,
@@ -233,6 +243,52 @@ func CancelPauseJobOrder(jobID common.JobID, desiredJobStatus common.JobStatus)
 	return jr
 }
 
+// SetJobLimits changes the bandwidth cap and the concurrency of a job that's running in this process.
+// Both apply to everything the process transfers, which, in practice, is that one job.
+func SetJobLimits(req common.SetJobLimitsRequest) common.SetJobLimitsResponse {
+	jm, found := JobsAdmin.JobMgr(req.JobID)
+	if !found {
+		return common.SetJobLimitsResponse{ErrorMsg: fmt.Sprintf("job %s is not running in this process", req.JobID)}
+	}
+	if req.ChangeCapMbps && req.CapMbps < 0 {
+		return common.SetJobLimitsResponse{ErrorMsg: fmt.Sprintf("invalid bandwidth cap %d", req.CapMbps)}
+	}
+	if req.Concurrency < 0 {
+		return common.SetJobLimitsResponse{ErrorMsg: fmt.Sprintf("invalid concurrency %d", req.Concurrency)}
+	}
+
+	var changes []string
+	if req.ChangeCapMbps {
+		JobsAdmin.SetMbpsCap(req.CapMbps)
+		changes = append(changes, common.IffString(req.CapMbps == 0, "bandwidth cap removed",
+			fmt.Sprintf("bandwidth cap set to %d Mb/s", req.CapMbps)))
+	}
+	if req.Concurrency > 0 {
+		JobsAdmin.SetMainPoolSize(req.Concurrency)
+		changes = append(changes, fmt.Sprintf("concurrency set to %d", req.Concurrency))
+	}
+	if len(changes) > 0 {
+		msg := "Job limits changed while running: " + strings.Join(changes, ", ")
+		jm.Log(pipeline.LogInfo, msg)
+		common.GetLifecycleMgr().Info(msg)
+	}
+
+	concurrency := JobsAdmin.RequestedMainPoolSize()
+	if concurrency == 0 {
+		concurrency = JobsAdmin.CurrentMainPoolSize()
+	}
+	return common.SetJobLimitsResponse{LimitsSet: true, CapMbps: JobsAdmin.MbpsCap(), Concurrency: concurrency}
+}
+
+// resurrectionErrorMsg is the message of a job that couldn't be resurrected; notFoundMsg if it has no plan files,
+// or the reason its plan files can't be read, e.g. that they're encrypted with a key that isn't set
+func resurrectionErrorMsg(err error, notFoundMsg string) string {
+	if err == errNoJobPlans {
+		return notFoundMsg
+	}
+	return err.Error()
+}
+
 func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeResponse {
 	// Strip '?' if present as first character of the source sas / destination sas
 	if len(req.SourceSAS) > 0 && req.SourceSAS[0] == '?' {
@@ -243,10 +299,10 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 	}
 	// Always search the plan files in Azcopy folder,
 	// and resurrect the Job with provided credentials, to ensure SAS and etc get updated.
-	if !JobsAdmin.ResurrectJob(req.JobID, req.SourceSAS, req.DestinationSAS) {
+	if err := JobsAdmin.ResurrectJob(req.JobID, req.SourceSAS, req.DestinationSAS, req.DestinationShardSAS); err != nil {
 		return common.CancelPauseResumeResponse{
 			CancelledPauseResumed: false,
-			ErrorMsg:              fmt.Sprintf("no job with JobId %v exists", req.JobID),
+			ErrorMsg:              resurrectionErrorMsg(err, fmt.Sprintf("no job with JobId %v exists", req.JobID)),
 		}
 	}
 	// If the job manager was not found, then Job was resurrected
@@ -254,8 +310,9 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 	jm, _ := JobsAdmin.JobMgr(req.JobID)
 
 	// Check whether Job has been completely ordered or not
-	completeJobOrdered := func(jm IJobMgr) bool {
-		// completeJobOrdered determines whether final part for job with JobId has been ordered or not.
+	completeJobOrdered := func(jm IJobMgr) (complete bool, listingMarker string) {
+		// completeJobOrdered determines whether final part for job with JobId has been ordered or not,
+		// and how far the listing of the source had got when the last part was ordered
 		completeJobOrdered := false
 		for p := PartNumber(0); true; p++ {
 			jpm, found := jm.JobPartMgr(p)
@@ -263,14 +320,19 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 				break
 			}
 			completeJobOrdered = completeJobOrdered || jpm.Plan().IsFinalPart
+			listingMarker = jpm.Plan().ListingMarkerReached()
 		}
-		return completeJobOrdered
+		return completeJobOrdered, listingMarker
 	}
 	// If the job has not been ordered completely, then job cannot be resumed
-	if !completeJobOrdered(jm) {
+	if complete, listingMarker := completeJobOrdered(jm); !complete {
+		errorMsg := fmt.Sprintf("cannot resume job with JobId %s . It hasn't been ordered completely", req.JobID)
+		if listingMarker != "" {
+			errorMsg += fmt.Sprintf(". The listing of its source had reached continuation marker %s", listingMarker)
+		}
 		return common.CancelPauseResumeResponse{
 			CancelledPauseResumed: false,
-			ErrorMsg:              fmt.Sprintf("cannot resume job with JobId %s . It hasn't been ordered completely", req.JobID),
+			ErrorMsg:              errorMsg,
 		}
 	}
 
@@ -296,7 +358,8 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 		case common.EFromTo.BlobLocal(),
 			common.EFromTo.FileLocal(),
 			common.EFromTo.BlobTrash(),
-			common.EFromTo.FileTrash():
+			common.EFromTo.FileTrash(),
+			common.EFromTo.BlobNone():
 			if len(req.SourceSAS) == 0 {
 				errorMsg = "The source-sas switch must be provided to resume the job"
 			}
@@ -338,6 +401,7 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 		jm.setInMemoryTransitJobState(
 			InMemoryTransitJobState{
 				credentialInfo: req.CredentialInfo,
+				resumed:        true,
 			})
 
 		jpp0.SetJobStatus(common.EJobStatus.InProgress())
@@ -346,6 +410,12 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 			jm.Log(pipeline.LogInfo, fmt.Sprintf("JobID=%v resumed", req.JobID))
 		}
 
+		// the statuses of the transfers are corrected before the failed ones are reset, so that it's still known which failed
+		if req.VerifyDestinations {
+			jr.TransfersFoundDone, jr.TransfersToCopyAgain, jr.DestinationsNotVerified =
+				verifyResumedTransfers(steCtx, jm, req.CredentialInfo, req.VerifyDestinationMD5)
+		}
+
 		// Iterate through all transfer of the Job Parts and reset the transfer status
 		jm.(*jobMgr).jobPartMgrs.Iterate(true, func(partNum common.PartNumber, jpm IJobPartMgr) {
 			jpp := jpm.Plan()
@@ -362,12 +432,11 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 			}
 		})
 
+		jm.reportJobStartToSystemLog(true)
 		jm.ResumeTransfers(steCtx) // Reschedule all job part's transfers
 		//}()
-		jr = common.CancelPauseResumeResponse{
-			CancelledPauseResumed: true,
-			ErrorMsg:              "",
-		}
+		jr.CancelledPauseResumed = true
+		jr.ErrorMsg = ""
 	}
 	return jr
 }
@@ -390,9 +459,9 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		// Job with JobId does not exists
 		// Search the plan files in Azcopy folder
 		// and resurrect the Job
-		if !JobsAdmin.ResurrectJob(jobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING) {
+		if err := JobsAdmin.ResurrectJob(jobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING, nil); err != nil {
 			return common.ListJobSummaryResponse{
-				ErrorMsg: fmt.Sprintf("no job with JobId %v exists", jobID),
+				ErrorMsg: resurrectionErrorMsg(err, fmt.Sprintf("no job with JobId %v exists", jobID)),
 			}
 		}
 		// If the job manager was not found, then Job was resurrected
@@ -418,42 +487,77 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		panic(fmt.Errorf("error getting the 0th part of Job %s", jobID))
 	}
 	part0PlanStatus := part0.Plan().JobStatus()
+	js.ClientRequestIDPrefix = ClientRequestIDPrefix(jobID, part0.Plan().clientRequestIDPrefix())
 
 	// Now iterate and count things up
+	inFlight := largestInFlight{}
+	shards := shardTally{}
 	jm.(*jobMgr).jobPartMgrs.Iterate(true, func(partNum common.PartNumber, jpm IJobPartMgr) {
 		jpp := jpm.Plan()
 		js.CompleteJobOrdered = js.CompleteJobOrdered || jpp.IsFinalPart
 		js.TotalTransfers += jpp.NumTransfers
+		shard := shards.of(jpp.DestinationRootString())
+		shard.Transfers += jpp.NumTransfers
 
 		// Iterate through this job part's transfers
 		for t := uint32(0); t < jpp.NumTransfers; t++ {
 			// transferHeader represents the memory map transfer header of transfer at index position for given job and part number
 			jppt := jpp.Transfer(t)
 			js.TotalBytesEnumerated += uint64(jppt.SourceSize)
+			if jpp.DeleteSourceAfterTransfer {
+				countSourceDeletion(&js, jppt)
+			}
+			switch jppt.SoftDeletedDestination() {
+			case common.ESoftDeletedDestinationHandling.Overwrite():
+				js.SoftDeletedDestinationsOverwritten++
+			case common.ESoftDeletedDestinationHandling.UndeleteAndOverwrite():
+				js.SoftDeletedDestinationsUndeleted++
+			}
 			// check for all completed transfer to calculate the progress percentage at the end
 			switch jppt.TransferStatus() {
 			case common.ETransferStatus.NotStarted(),
 				common.ETransferStatus.Started():
 				js.TotalBytesExpected += uint64(jppt.SourceSize)
+				inFlight.consider(jpp, t, jppt)
 			case common.ETransferStatus.Success():
 				js.TransfersCompleted++
 				js.TotalBytesTransferred += uint64(jppt.SourceSize)
 				js.TotalBytesExpected += uint64(jppt.SourceSize)
+				shard.BytesTransferred += uint64(jppt.SourceSize)
 			case common.ETransferStatus.Failed(),
-				common.ETransferStatus.BlobTierFailure():
+				common.ETransferStatus.BlobTierFailure(),
+				common.ETransferStatus.DestinationBusy(),
+				common.ETransferStatus.ShareFull():
 				js.TransfersFailed++
+				shard.TransfersFailed++
 				// getting the source and destination for failed transfer at position - index
 				src, dst := jpp.TransferSrcDstStrings(t)
 				// appending to list of failed transfer
 				js.FailedTransfers = append(js.FailedTransfers,
 					common.TransferDetail{
-						Src:            src,
-						Dst:            dst,
-						TransferStatus: common.ETransferStatus.Failed(),
-						ErrorCode:      jppt.ErrorCode()}) // TODO: Optimize
+						Src:             src,
+						Dst:             dst,
+						TransferStatus:  common.ETransferStatus.Failed(),
+						ErrorCode:       jppt.ErrorCode(),
+						ClientRequestID: jm.FailedRequestClientID(jpp.PartNum, t)}) // TODO: Optimize
 			case common.ETransferStatus.SkippedFileAlreadyExists(),
-				common.ETransferStatus.SkippedBlobHasSnapshots():
+				common.ETransferStatus.SkippedBlobHasSnapshots(),
+				common.ETransferStatus.SkippedIncompatibleBlobType(),
+				common.ETransferStatus.SkippedPermissionDenied(),
+				common.ETransferStatus.SkippedFileLocked(),
+				common.ETransferStatus.SkippedDestinationConditionNotMet(),
+				common.ETransferStatus.SkippedSourceDeleted(),
+				common.ETransferStatus.SkippedDestinationSoftDeleted():
 				js.TransfersSkipped++
+				if jppt.TransferStatus() == common.ETransferStatus.SkippedDestinationConditionNotMet() {
+					js.TransfersSkippedForDestinationCondition++
+				}
+				if jppt.TransferStatus() == common.ETransferStatus.SkippedSourceDeleted() {
+					js.TransfersSkippedForMissingSource++
+				}
+				if jppt.TransferStatus() == common.ETransferStatus.SkippedDestinationSoftDeleted() {
+					js.TransfersSkippedForSoftDeletedDestination++
+				}
 				// getting the source and destination for skipped transfer at position - index
 				src, dst := jpp.TransferSrcDstStrings(t)
 				js.SkippedTransfers = append(js.SkippedTransfers,
@@ -466,6 +570,9 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		}
 	})
 
+	js.InFlightTransfers = inFlight.progress(js.Timestamp)
+	js.Shards = shards.summaries()
+
 	// Add on byte count from files in flight, to get a more accurate running total
 	js.TotalBytesTransferred += JobsAdmin.SuccessfulBytesInActiveFiles()
 	if js.TotalBytesExpected == 0 {
@@ -488,6 +595,38 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 	js.ActiveConnections = jm.ActiveConnections()
 
 	js.PerfStrings, js.PerfConstraint = jm.GetPerfInfo()
+	js.TransfersRelayedClientSide = jm.TransfersRelayedClientSide()
+	js.HashBackfillsInProgress, js.MD5sBackfilled, js.BlobsWithoutMD5 = jm.HashBackfills()
+	js.ChunkIntegrityRetries = jm.ChunkIntegrityRetries()
+	js.SourceReadRetries = jm.SourceReadRetries()
+	js.FilesRetriedForSharingViolations = jm.FilesRetriedForSharingViolations()
+	js.FirstTransferFailures, js.DominantTransferFailure, js.DominantTransferFailureCount = jm.TransferFailures()
+	js.FailuresByErrorCode = jm.FailuresByErrorCode()
+	js.CapMbps = JobsAdmin.MbpsCap()
+	js.Concurrency = JobsAdmin.RequestedMainPoolSize()
+	js.DiffChangedBytes, js.DiffLogicalBytes = jm.PageBlobDiffBytes()
+	js.BytesAppended, js.BytesUploadedInFull = jm.AppendOnlyBytes()
+	js.ChecksumEntriesNotFound = jm.ChecksumEntriesNotFound()
+	js.LowSpacePaused = jm.getDiskSpaceGuard().paused()
+	if g := currentCredentialExpiryGuard(); g.expired() {
+		js.CredentialExpired, js.CredentialExpiredError = true, g.lastError().Error()
+	}
+	js.PausedJobDrained = part0PlanStatus == common.EJobStatus.Paused() && jm.(*jobMgr).partsDrained()
+	js.FullShares = jm.getShareQuotaGuard().fullShares()
+	if tp := JobsAdmin.(*jobsAdmin).transactionPacer; tp != nil {
+		js.AverageTransactionsPerSecond = tp.averageTransactionsPerSecond()
+		js.TransactionsPerSecondCap = tp.currentCap()
+	}
+	if tp := JobsAdmin.(*jobsAdmin).metadataTransactionPacer; tp != nil {
+		js.AverageMetadataTransactionsPerSecond = tp.averageTransactionsPerSecond()
+		js.MetadataTransactionsPerSecondCap = tp.currentCap()
+	}
+	if pt := currentPartitionThrottle(); pt != nil {
+		js.PartitionThrottleEvents = pt.throttleEvents()
+		js.ChunksDeferredForHotPartitions = pt.deferredChunks()
+	}
+	js.TransfersDeferredForBusyDirectories = currentDirectoryLimiter().setAside()
+	js.DNSResolutions, js.DNSCacheHits, js.DNSStaleAddressesSeen = sharedDNSCache().stats()
 
 	pipeStats := jm.PipelineNetworkStats()
 	if pipeStats != nil {
@@ -495,6 +634,14 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		js.AverageE2EMilliseconds = pipeStats.AverageE2EMilliseconds()
 		js.NetworkErrorPercentage = pipeStats.NetworkErrorPercentage()
 		js.ServerBusyPercentage = pipeStats.TotalServerBusyPercentage()
+		js.RetryPercentage = pipeStats.RetryPercentage()
+		js.RetryCount = pipeStats.RetryCount()
+		waits, waited := pipeStats.ServerDirectedWaits()
+		js.ServerDirectedWaits = waits
+		js.ServerDirectedWaitSeconds = ToFixed(waited.Seconds(), 1)
+		js.RequestCountsByStatus = pipeStats.StatusCodeCounts()
+		js.Transactions = pipeStats.TransactionCounts()
+		js.MetadataRequests, js.MetadataRequestsThrottled, js.DataRequests, js.DataRequestsThrottled = pipeStats.RequestsByTransferClass()
 	}
 
 	// If the status is cancelled, then no need to check for completerJobOrdered
@@ -503,6 +650,9 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 	if part0PlanStatus == common.EJobStatus.Cancelled() {
 		js.JobStatus = part0PlanStatus
 		js.PerformanceAdvice = jm.TryGetPerformanceAdvice(js.TotalBytesExpected, js.TotalTransfers-js.TransfersSkipped)
+		if m := jm.getTransferMetricsRecorder(); m != nil {
+			js.TransferDurationPercentiles = m.DurationPercentiles()
+		}
 		return js
 	}
 	// Job is completed if Job order is complete AND ALL transfers are completed/failed
@@ -516,12 +666,53 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 			js.TransfersCompleted > 0)
 
 		js.PerformanceAdvice = jm.TryGetPerformanceAdvice(js.TotalBytesExpected, js.TotalTransfers-js.TransfersSkipped)
-
+		if m := jm.getTransferMetricsRecorder(); m != nil {
+			js.TransferDurationPercentiles = m.DurationPercentiles()
+		}
 	}
 
 	return js
 }
 
+// countSourceDeletion counts a finished transfer of a job that deletes its sources. The count is taken from the plan file,
+// and so it is neither lost nor counted twice when the job is resumed
+func countSourceDeletion(js *common.ListJobSummaryResponse, jppt *JobPartPlanTransfer) {
+	switch status := jppt.TransferStatus(); {
+	case jppt.SourceDeleted():
+		js.SourcesDeleted++
+	case status == common.ETransferStatus.NotStarted() || status == common.ETransferStatus.Started():
+		// not finished yet
+	default:
+		js.SourcesRetained++
+	}
+}
+
+// shardTally adds up what went to each of the roots of the destinations of a job's parts,
+// which only differ when the destination of the job is sharded across containers
+type shardTally map[string]*common.ShardSummary
+
+func (t shardTally) of(destinationRoot string) *common.ShardSummary {
+	s, ok := t[destinationRoot]
+	if !ok {
+		s = &common.ShardSummary{Destination: destinationRoot}
+		t[destinationRoot] = s
+	}
+	return s
+}
+
+// summaries returns the shards ordered by their URL, or nil if all the parts went to the same destination
+func (t shardTally) summaries() []common.ShardSummary {
+	if len(t) < 2 {
+		return nil
+	}
+	result := make([]common.ShardSummary, 0, len(t))
+	for _, s := range t {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Destination < result[j].Destination })
+	return result
+}
+
 // ListJobTransfers api returns the list of transfer with specific status for given jobId in http response
 func ListJobTransfers(r common.ListJobTransfersRequest) common.ListJobTransfersResponse {
 	// getJobPartInfoReferenceFromMap gives the JobPartPlanInfo Pointer for given JobId and partNumber
@@ -530,9 +721,9 @@ func ListJobTransfers(r common.ListJobTransfersRequest) common.ListJobTransfersR
 		// Job with JobId does not exists
 		// Search the plan files in Azcopy folder
 		// and resurrect the Job
-		if !JobsAdmin.ResurrectJob(r.JobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING) {
+		if err := JobsAdmin.ResurrectJob(r.JobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING, nil); err != nil {
 			return common.ListJobTransfersResponse{
-				ErrorMsg: fmt.Sprintf("no job with JobId %v exists", r.JobID),
+				ErrorMsg: resurrectionErrorMsg(err, fmt.Sprintf("no job with JobId %v exists", r.JobID)),
 			}
 		}
 		// If the job manager was not found, then Job was resurrected
@@ -571,8 +762,10 @@ func ListJobTransfers(r common.ListJobTransfersRequest) common.ListJobTransfersR
 			}
 			// getting source and destination of a transfer at index index for given jobId and part number.
 			src, dst := jpp.TransferSrcDstStrings(t)
+			srcRelative, _ := jpp.TransferSrcDstRelatives(t)
 			ljt.Details = append(ljt.Details,
-				common.TransferDetail{Src: src, Dst: dst, TransferStatus: transferEntry.TransferStatus(), ErrorCode: transferEntry.ErrorCode()})
+				common.TransferDetail{Src: src, SrcRelative: srcRelative, Dst: dst, TransferStatus: transferEntry.TransferStatus(), ErrorCode: transferEntry.ErrorCode(),
+					ClientRequestID: jm.FailedRequestClientID(jpp.PartNum, t)})
 		}
 	}
 	return ljt
@@ -597,8 +790,15 @@ func ListJobs() common.ListJobsResponse {
 		if !found {
 			continue
 		}
+		// the jobs whose plans can't be decrypted are still listed, with the reason in place of their command
+		commandString := ""
+		if err := jpm.Plan().CheckPlanKey(); err != nil {
+			commandString = err.Error()
+		} else {
+			commandString = jpm.Plan().CommandString()
+		}
 		listJobResponse.JobIDDetails = append(listJobResponse.JobIDDetails,
-			common.JobIDDetails{JobId: jobId, CommandString: jpm.Plan().CommandString(),
+			common.JobIDDetails{JobId: jobId, CommandString: commandString,
 				StartTime: jpm.Plan().StartTime, JobStatus: jpm.Plan().JobStatus()})
 
 		// Close the job part managers and the log.
@@ -616,9 +816,9 @@ func GetJobFromTo(r common.GetJobFromToRequest) common.GetJobFromToResponse {
 	if !found {
 		// Job with JobId does not exists.
 		// Search the plan files in Azcopy folder and resurrect the Job.
-		if !JobsAdmin.ResurrectJob(r.JobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING) {
+		if err := JobsAdmin.ResurrectJob(r.JobID, EMPTY_SAS_STRING, EMPTY_SAS_STRING, nil); err != nil {
 			return common.GetJobFromToResponse{
-				ErrorMsg: fmt.Sprintf("no job with JobID %v exists", r.JobID),
+				ErrorMsg: resurrectionErrorMsg(err, fmt.Sprintf("no job with JobID %v exists", r.JobID)),
 			}
 		}
 		jm, _ = JobsAdmin.JobMgr(r.JobID)
@@ -640,10 +840,15 @@ func GetJobFromTo(r common.GetJobFromToRequest) common.GetJobFromToResponse {
 		}
 	}
 
+	plan := jp0.Plan()
 	return common.GetJobFromToResponse{
-		ErrorMsg:    "",
-		FromTo:      jp0.Plan().FromTo,
-		Source:      source,
-		Destination: destination,
+		ErrorMsg:              "",
+		FromTo:                plan.FromTo,
+		Source:                source,
+		Destination:           destination,
+		JobStatus:             plan.JobStatus(),
+		StartTime:             time.Unix(0, plan.StartTime),
+		TempNameSuffix:        string(plan.TempNameSuffix[:plan.TempNameSuffixLength]),
+		ClientRequestIDPrefix: ClientRequestIDPrefix(r.JobID, plan.clientRequestIDPrefix()),
 	}
 }
@@ -0,0 +1,127 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+var s2sMissingMD5LogStdout sync.Once
+
+// md5Backfill is what's done about the Content-MD5 of a destination block blob of a S2S copy, once it's written
+type md5Backfill uint8
+
+const (
+	// the destination has a Content-MD5, that of the source, or one that the service computed since it wrote the blob in one request
+	md5BackfillNotNeeded md5Backfill = iota
+	// the destination is left without a Content-MD5, since it was committed from blocks that were put from URL, and the source has none
+	md5BackfillSkipped
+	// the Content-MD5 of the destination is computed here (--s2s-compute-md5)
+	md5BackfillNeeded
+)
+
+// md5BackfillFor tells what's done about the Content-MD5 of a destination. Put Block List doesn't compute one, so a destination
+// committed from blocks only has one if the source had one to give it. Put Blob (From URL) does compute one.
+func md5BackfillFor(option common.S2SComputeMD5, committedBlockList bool, sourceMD5 []byte) md5Backfill {
+	missing := committedBlockList && len(sourceMD5) == 0
+	switch {
+	case option == common.ES2SComputeMD5.Always(),
+		option == common.ES2SComputeMD5.Missing() && missing:
+		return md5BackfillNeeded
+	case missing:
+		return md5BackfillSkipped
+	default:
+		return md5BackfillNotNeeded
+	}
+}
+
+// backfillMD5 gives the destination of a S2S copy the Content-MD5 that it would otherwise be left without, if the job asks for it,
+// by reading back what was written. When the source has a Content-MD5, the destination is checked against it instead.
+// A destination that can't get its Content-MD5 is only reported, since its data was copied all the same.
+func (s *blockBlobSenderBase) backfillMD5(committedBlockList bool) {
+	jptm := s.jptm
+	sourceMD5 := s.headersToApply.ContentMD5
+	if s.tierSetOnCreate && s.destBlobTier == azblob.AccessTierArchive {
+		// it was written in one request, which gave it a Content-MD5, and it can't be read back
+		return
+	}
+
+	switch md5BackfillFor(jptm.S2SComputeMD5(), committedBlockList, sourceMD5) {
+	case md5BackfillNotNeeded:
+		return
+	case md5BackfillSkipped:
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The destination has no Content-MD5, since the source has none, and the blob was copied in blocks")
+		jptm.ReportBlobWithoutMD5()
+		s2sMissingMD5LogStdout.Do(func() {
+			common.GetLifecycleMgr().Info("One or more destination blobs have no Content-MD5, since their source has none. " +
+				"Use --s2s-compute-md5=missing to have it computed after they're copied.")
+		})
+		return
+	}
+
+	jptm.ReportHashBackfillStarted()
+	defer jptm.ReportHashBackfillEnded()
+
+	hash, err := s.hashDestination()
+	if err == nil && len(sourceMD5) > 0 {
+		// the destination already has the Content-MD5 of the source, which it must match
+		if !bytes.Equal(hash, sourceMD5) {
+			jptm.FailActiveSend("Checking the Content-MD5 of the destination", errors.New("the MD5 of the destination is not the Content-MD5 of the source"))
+		}
+		return
+	}
+	if err == nil {
+		headers := s.headersToApply
+		headers.ContentMD5 = hash
+		_, err = s.destBlockBlobURL.SetHTTPHeaders(jptm.Context(), headers, s.lease.blobAccessConditions())
+	}
+	if err != nil {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The destination is left without a Content-MD5, since computing it failed: "+err.Error())
+		jptm.ReportBlobWithoutMD5()
+		return
+	}
+	jptm.ReportMD5Backfilled()
+}
+
+// hashDestination computes the MD5 of the destination blob, as it was written
+func (s *blockBlobSenderBase) hashDestination() ([]byte, error) {
+	get, err := s.destBlockBlobURL.Download(s.jptm.Context(), 0, azblob.CountToEnd, s.lease.blobAccessConditions(), false)
+	if err != nil {
+		return nil, err
+	}
+	body := get.Body(azblob.RetryReaderOptions{MaxRetryRequests: MaxRetryPerDownloadBody})
+	defer body.Close()
+
+	hasher := md5.New()
+	if _, err = io.Copy(hasher, body); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
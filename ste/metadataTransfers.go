@@ -0,0 +1,75 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+var metadataOnlyTransfersContextKey = contextKey{"metadataOnlyTransfers"}
+
+// withMetadataOnlyTransfers marks the context of transfers that move no data, e.g. deletes and setting properties,
+// so that their requests are paced (--cap-metadata-tps) and counted apart from those of the transfers of data
+func withMetadataOnlyTransfers(ctx context.Context) context.Context {
+	return context.WithValue(ctx, metadataOnlyTransfersContextKey, true)
+}
+
+func isMetadataOnlyTransfer(ctx context.Context) bool {
+	metadataOnly, _ := ctx.Value(metadataOnlyTransfersContextKey).(bool)
+	return metadataOnly
+}
+
+// transferClassStats counts the requests of one class of transfers, and those of them that the service throttled
+type transferClassStats struct {
+	atomicRequests          int64
+	atomicThrottledRequests int64
+}
+
+func (c *transferClassStats) record(statusCode int) {
+	atomic.AddInt64(&c.atomicRequests, 1)
+	if statusCode == http.StatusServiceUnavailable || statusCode == http.StatusTooManyRequests {
+		atomic.AddInt64(&c.atomicThrottledRequests, 1)
+	}
+}
+
+func (c *transferClassStats) counts() (requests, throttled int64) {
+	return atomic.LoadInt64(&c.atomicRequests), atomic.LoadInt64(&c.atomicThrottledRequests)
+}
+
+// recordClassTry counts a try, over the whole job, against the class of the transfer that made it
+func (s *pipelineNetworkStats) recordClassTry(ctx context.Context, statusCode int) {
+	if isMetadataOnlyTransfer(ctx) {
+		s.metadataRequests.record(statusCode)
+	} else {
+		s.dataRequests.record(statusCode)
+	}
+}
+
+// RequestsByTransferClass returns the number of tries, over the whole job, of the transfers that move no data and of the others,
+// each with the number of them that the service throttled
+func (s *pipelineNetworkStats) RequestsByTransferClass() (metadata, metadataThrottled, data, dataThrottled int64) {
+	s.nocopy.Check()
+	metadata, metadataThrottled = s.metadataRequests.counts()
+	data, dataThrottled = s.dataRequests.counts()
+	return
+}
@@ -44,7 +44,11 @@ type PartNumber = common.PartNumber
 // i.e. different jobs could have different OAuth tokens requested from FE, and these jobs can run at same time in STE.
 // This can be optimized if FE would no more be another module vs STE module.
 type InMemoryTransitJobState struct {
-	credentialInfo common.CredentialInfo
+	credentialInfo  common.CredentialInfo
+	metricsFile     string
+	checksumFile    string
+	verifyChecksums bool
+	resumed         bool // whether the job was resumed, rather than started, in this process
 }
 
 type IJobMgr interface {
@@ -75,27 +79,61 @@ type IJobMgr interface {
 	//Close()
 	getInMemoryTransitJobState() InMemoryTransitJobState      // get in memory transit job state saved in this job.
 	setInMemoryTransitJobState(state InMemoryTransitJobState) // set in memory transit job state saved in this job.
+	getTransferMetricsRecorder() *transferMetricsRecorder     // nil unless the job was asked to record transfer metrics
+	getChecksumManifest() *checksumManifest                   // nil unless the job was asked to write or verify a checksum file
+	ChecksumEntriesNotFound() uint32
+	reportJobStartToSystemLog(resumed bool)
+	reportTransferRelayedClientSide()
+	TransfersRelayedClientSide() uint32
+	reportChunkIntegrityRetry()
+	ChunkIntegrityRetries() uint32
+	reportSourceReadRetry()
+	SourceReadRetries() uint32
+	reportSharingViolationRetry()
+	FilesRetriedForSharingViolations() uint32
+	reportHashBackfillStarted()
+	reportHashBackfillEnded()
+	reportMD5Backfilled()
+	reportBlobWithoutMD5()
+	HashBackfills() (inProgress, backfilled, withoutMD5 uint32)
+	reportTransferFailure(source, destination, errorMsg, serviceCode string, status int)
+	reportFailedRequest(partNum common.PartNumber, transferIndex uint32, clientRequestID string)
+	FailedRequestClientID(partNum common.PartNumber, transferIndex uint32) string
+	TransferFailures() (first []string, dominant string, dominantCount uint32)
+	FailuresByErrorCode() map[string]common.ErrorCodeFailures
+	reportPageBlobDiff(changedBytes int64, logicalBytes int64)
+	PageBlobDiffBytes() (changedBytes uint64, logicalBytes uint64)
+	reportAppendOnly(appendedBytes int64, uploadedInFullBytes int64)
+	AppendOnlyBytes() (appendedBytes uint64, uploadedInFullBytes uint64)
 	ChunkStatusLogger() common.ChunkStatusLogger
 	HttpClient() *http.Client
 	PipelineNetworkStats() *pipelineNetworkStats
 	getOverwritePrompter() *overwritePrompter
+	getDiskSpaceGuard() *diskSpaceGuard
+	getShareQuotaGuard() *shareQuotaGuard
 	common.ILoggerCloser
+	common.IStructuredLogger
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-func newJobMgr(concurrency ConcurrencySettings, appLogger common.ILogger, jobID common.JobID, appCtx context.Context, cpuMon common.CPUMonitor, level common.LogLevel, commandString string, logFileFolder string) IJobMgr {
+func newJobMgr(concurrency ConcurrencySettings, appLogger common.ILogger, jobID common.JobID, appCtx context.Context, cpuMon common.CPUMonitor, level common.LogLevel, commandString string, logFileFolder string, logRotation common.LogRotationSettings, logFormat common.LogFormat, systemLogger common.ISystemLogger) IJobMgr {
 	// atomicAllTransfersScheduled is set to 1 since this api is also called when new job part is ordered.
 	enableChunkLogOutput := level.ToPipelineLogLevel() == pipeline.LogDebug
 	jm := jobMgr{jobID: jobID, jobPartMgrs: newJobPartToJobPartMgr(), include: map[string]int{}, exclude: map[string]int{},
 		httpClient:                    NewAzcopyHTTPClient(concurrency.MaxIdleConnections),
-		logger:                        common.NewJobLogger(jobID, level, appLogger, logFileFolder),
+		logger:                        common.NewJobLogger(jobID, level, appLogger, logFileFolder, logRotation, logFormat, systemLogger),
+		systemLogger:                  systemLogger,
+		logFileFolder:                 logFileFolder,
 		chunkStatusLogger:             common.NewChunkStatusLogger(jobID, cpuMon, logFileFolder, enableChunkLogOutput),
 		concurrency:                   concurrency,
 		overwritePrompter:             newOverwritePrompter(),
 		pipelineNetworkStats:          newPipelineNetworkStats(JobsAdmin.(*jobsAdmin).concurrencyTuner), // let the stats coordinate with the concurrency tuner
 		exclusiveDestinationMapHolder: &atomic.Value{},
+		failures:                      newTransferFailures(),
 		/*Other fields remain zero-value until this job is scheduled */}
+	jm.diskSpace = newDiskSpaceGuard(LowSpaceMargin(), func(msg string) { jm.Log(pipeline.LogWarning, msg) })
+	jm.shareQuota = newShareQuotaGuard(func(msg string) { jm.Log(pipeline.LogWarning, msg) })
 	jm.reset(appCtx, commandString)
 	jm.logJobsAdminMessages()
 	return &jm
@@ -105,6 +143,14 @@ func (jm *jobMgr) getOverwritePrompter() *overwritePrompter {
 	return jm.overwritePrompter
 }
 
+func (jm *jobMgr) getDiskSpaceGuard() *diskSpaceGuard {
+	return jm.diskSpace
+}
+
+func (jm *jobMgr) getShareQuotaGuard() *shareQuotaGuard {
+	return jm.shareQuota
+}
+
 func (jm *jobMgr) reset(appCtx context.Context, commandString string) IJobMgr {
 	jm.logger.OpenLog()
 	// log the user given command to the job log file.
@@ -143,8 +189,25 @@ func (jm *jobMgr) logConcurrencyParameters() {
 	jm.logger.Log(pipeline.LogInfo, fmt.Sprintf("Max concurrent transfer initiation routines: %d (%s)",
 		jm.concurrency.TransferInitiationPoolSize.Value,
 		jm.concurrency.TransferInitiationPoolSize.GetDescription()))
+	jm.logger.Log(pipeline.LogInfo, fmt.Sprintf("Max concurrent metadata-only transfers: %d (%s)",
+		jm.concurrency.MetadataTransferPoolSize.Value,
+		jm.concurrency.MetadataTransferPoolSize.GetDescription()))
+	jm.logger.Log(pipeline.LogInfo, fmt.Sprintf("Max concurrent transfers per Azure Files directory: %d (%s)",
+		jm.concurrency.TransfersPerDirectory.Value,
+		jm.concurrency.TransfersPerDirectory.GetDescription()))
 	jm.logger.Log(pipeline.LogInfo, fmt.Sprintf("Max open files when downloading: %d (auto-computed)",
 		jm.concurrency.MaxOpenDownloadFiles))
+	if t, ok := jm.httpClient.Transport.(*http.Transport); ok {
+		jm.logger.Log(pipeline.LogInfo, common.DescribeTransport(t))
+	}
+	if c := sharedDNSCache(); c != nil {
+		jm.logger.Log(pipeline.LogInfo, fmt.Sprintf("Addresses of hosts are cached for %v", c.ttl))
+	} else {
+		jm.logger.Log(pipeline.LogInfo, "Addresses of hosts are not cached")
+	}
+	if common.GlobalLocalAddr != nil {
+		jm.logger.Log(pipeline.LogInfo, fmt.Sprintf("Connections are bound to the local address %s", common.GlobalLocalAddr.IP))
+	}
 }
 
 // jobMgr represents the runtime information for a Job
@@ -157,10 +220,29 @@ type jobMgr struct {
 	// atomicCurrentConcurrentConnections defines the number of active goroutines performing the transfer / executing the chunk func
 	// TODO: added for debugging purpose. remove later
 	atomicCurrentConcurrentConnections int64
+	// the bytes that changed since the base snapshot, and the logical size, of the page blobs that are copied incrementally
+	atomicDiffChangedBytes uint64
+	atomicDiffLogicalBytes uint64
+	// the bytes that append-only uploads appended, and those of the files that they had to upload in full
+	atomicAppendedBytes       uint64
+	atomicUploadedInFullBytes uint64
 	// atomicAllTransfersScheduled defines whether all job parts have been iterated and resumed or not
 	atomicAllTransfersScheduled     int32
 	atomicFinalPartOrderedIndicator int32
 	atomicTransferDirection         common.TransferDirection
+	// the number of transfers that switched to the client-side relay, since the destination could not read their source
+	atomicTransfersRelayedClientSide uint32
+	// the number of chunks that were fetched again, since their contents didn't match the MD5 hash that the service sent with them
+	atomicChunkIntegrityRetries uint32
+	// the number of times that a network source was reopened and read again, since the share dropped
+	atomicSourceReadRetries uint32
+	// the number of transfers whose destination Azure file was written again, since something else had it open over SMB
+	atomicFilesRetriedForSharingViolations uint32
+	// the number of destination block blobs whose Content-MD5 is being computed after they were written, the number that got one that way,
+	// and the number that were left without one
+	atomicHashBackfillsInProgress uint32
+	atomicMD5sBackfilled          uint32
+	atomicBlobsWithoutMD5         uint32
 
 	concurrency          ConcurrencySettings
 	logger               common.ILoggerResetable
@@ -189,6 +271,24 @@ type jobMgr struct {
 
 	// only a single instance of the prompter is needed for all transfers
 	overwritePrompter *overwritePrompter
+
+	// holds back the downloads of the job while the volume they save to is low on space
+	diskSpace *diskSpaceGuard
+	// keeps the transfers of the job away from the Azure file shares that it found full
+	shareQuota *shareQuotaGuard
+
+	// nil unless the job was asked to record transfer metrics
+	transferMetrics *transferMetricsRecorder
+
+	// nil unless the job was asked to write or verify a checksum file
+	checksums *checksumManifest
+
+	// why the transfers of the job failed
+	failures *transferFailures
+
+	// nil unless job lifecycle events are sent to the OS log
+	systemLogger  common.ISystemLogger
+	logFileFolder string
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -226,6 +326,100 @@ func (jm *jobMgr) ActiveConnections() int64 {
 	return atomic.LoadInt64(&jm.atomicCurrentConcurrentConnections)
 }
 
+func (jm *jobMgr) reportTransferRelayedClientSide() {
+	atomic.AddUint32(&jm.atomicTransfersRelayedClientSide, 1)
+}
+
+func (jm *jobMgr) TransfersRelayedClientSide() uint32 {
+	return atomic.LoadUint32(&jm.atomicTransfersRelayedClientSide)
+}
+
+func (jm *jobMgr) reportChunkIntegrityRetry() {
+	atomic.AddUint32(&jm.atomicChunkIntegrityRetries, 1)
+}
+
+func (jm *jobMgr) ChunkIntegrityRetries() uint32 {
+	return atomic.LoadUint32(&jm.atomicChunkIntegrityRetries)
+}
+
+func (jm *jobMgr) reportSourceReadRetry() {
+	atomic.AddUint32(&jm.atomicSourceReadRetries, 1)
+}
+
+func (jm *jobMgr) SourceReadRetries() uint32 {
+	return atomic.LoadUint32(&jm.atomicSourceReadRetries)
+}
+
+func (jm *jobMgr) reportSharingViolationRetry() {
+	atomic.AddUint32(&jm.atomicFilesRetriedForSharingViolations, 1)
+}
+
+func (jm *jobMgr) FilesRetriedForSharingViolations() uint32 {
+	return atomic.LoadUint32(&jm.atomicFilesRetriedForSharingViolations)
+}
+
+func (jm *jobMgr) reportHashBackfillStarted() {
+	atomic.AddUint32(&jm.atomicHashBackfillsInProgress, 1)
+}
+
+func (jm *jobMgr) reportHashBackfillEnded() {
+	atomic.AddUint32(&jm.atomicHashBackfillsInProgress, ^uint32(0))
+}
+
+func (jm *jobMgr) reportMD5Backfilled() {
+	atomic.AddUint32(&jm.atomicMD5sBackfilled, 1)
+}
+
+func (jm *jobMgr) reportBlobWithoutMD5() {
+	atomic.AddUint32(&jm.atomicBlobsWithoutMD5, 1)
+}
+
+func (jm *jobMgr) HashBackfills() (inProgress, backfilled, withoutMD5 uint32) {
+	return atomic.LoadUint32(&jm.atomicHashBackfillsInProgress), atomic.LoadUint32(&jm.atomicMD5sBackfilled), atomic.LoadUint32(&jm.atomicBlobsWithoutMD5)
+}
+
+func (jm *jobMgr) reportTransferFailure(source, destination, errorMsg, serviceCode string, status int) {
+	jm.failures.record(source, destination, errorMsg, serviceCode, status)
+}
+
+func (jm *jobMgr) reportFailedRequest(partNum common.PartNumber, transferIndex uint32, clientRequestID string) {
+	jm.failures.recordClientRequestID(partNum, transferIndex, clientRequestID)
+}
+
+// FailedRequestClientID returns the x-ms-client-request-id of the request that the transfer failed with, if it's known
+func (jm *jobMgr) FailedRequestClientID(partNum common.PartNumber, transferIndex uint32) string {
+	return jm.failures.clientRequestID(partNum, transferIndex)
+}
+
+// TransferFailures returns the first failures of the job, and the kind of error that most of its transfers failed with
+func (jm *jobMgr) TransferFailures() (first []string, dominant string, dominantCount uint32) {
+	dominant, dominantCount = jm.failures.dominant()
+	return jm.failures.firstFailures(), dominant, dominantCount
+}
+
+// FailuresByErrorCode returns how many of the job's transfers failed with each storage error code
+func (jm *jobMgr) FailuresByErrorCode() map[string]common.ErrorCodeFailures {
+	return jm.failures.byErrorCode()
+}
+
+func (jm *jobMgr) reportPageBlobDiff(changedBytes int64, logicalBytes int64) {
+	atomic.AddUint64(&jm.atomicDiffChangedBytes, uint64(changedBytes))
+	atomic.AddUint64(&jm.atomicDiffLogicalBytes, uint64(logicalBytes))
+}
+
+func (jm *jobMgr) PageBlobDiffBytes() (changedBytes uint64, logicalBytes uint64) {
+	return atomic.LoadUint64(&jm.atomicDiffChangedBytes), atomic.LoadUint64(&jm.atomicDiffLogicalBytes)
+}
+
+func (jm *jobMgr) reportAppendOnly(appendedBytes int64, uploadedInFullBytes int64) {
+	atomic.AddUint64(&jm.atomicAppendedBytes, uint64(appendedBytes))
+	atomic.AddUint64(&jm.atomicUploadedInFullBytes, uint64(uploadedInFullBytes))
+}
+
+func (jm *jobMgr) AppendOnlyBytes() (appendedBytes uint64, uploadedInFullBytes uint64) {
+	return atomic.LoadUint64(&jm.atomicAppendedBytes), atomic.LoadUint64(&jm.atomicUploadedInFullBytes)
+}
+
 // GetPerfStrings returns strings that may be logged for performance diagnostic purposes
 // The number and content of strings may change as we enhance our perf diagnostics
 func (jm *jobMgr) GetPerfInfo() (displayStrings []string, constraint common.PerfConstraint) {
@@ -297,7 +491,7 @@ func (jm *jobMgr) TryGetPerformanceAdvice(bytesInJob uint64, filesInJob uint32)
 	}
 
 	dir := jm.atomicTransferDirection.AtomicLoad()
-	a := NewPerformanceAdvisor(jm.pipelineNetworkStats, ja.commandLineMbpsCap, int64(megabitsPerSec), finalReason, finalConcurrency, dir, averageBytesPerFile)
+	a := NewPerformanceAdvisor(jm.pipelineNetworkStats, ja.MbpsCap(), int64(megabitsPerSec), finalReason, finalConcurrency, dir, averageBytesPerFile)
 	return a.GetAdvice()
 }
 
@@ -459,6 +653,14 @@ func (jm *jobMgr) ReportJobPartDone() uint32 {
 		jm.Log(pipeline.LogInfo, fmt.Sprintf("%s %s successfully completed, cancelled or paused", partDescription, jm.jobID.String()))
 	}
 
+	// save the metrics before the job is marked as done, since the app may exit as soon as the front end sees that
+	if jm.transferMetrics != nil {
+		jm.transferMetrics.Flush()
+	}
+	if jm.checksums != nil {
+		jm.checksums.Flush()
+	}
+
 	switch jobStatus {
 	case common.EJobStatus.Cancelling():
 		part0Plan.SetJobStatus(common.EJobStatus.Cancelled())
@@ -468,12 +670,76 @@ func (jm *jobMgr) ReportJobPartDone() uint32 {
 	case common.EJobStatus.InProgress():
 		part0Plan.SetJobStatus((common.EJobStatus).Completed())
 	}
+	if jm.systemLogger != nil && (jobStatus == common.EJobStatus.Cancelling() || jobStatus == common.EJobStatus.InProgress()) {
+		jm.reportJobEndToSystemLog(jobStatus == common.EJobStatus.Cancelling())
+	}
 
 	jm.chunkStatusLogger.FlushLog() // TODO: remove once we sort out what will be calling CloseLog (currently nothing)
 
 	return partsDone
 }
 
+// partsDrained tells whether every part of the job, including the final one, has no transfers left in flight
+func (jm *jobMgr) partsDrained() bool {
+	return atomic.LoadInt32(&jm.atomicFinalPartOrderedIndicator) == 1 && atomic.LoadUint32(&jm.partsDone) == jm.jobPartMgrs.Count()
+}
+
+// reportJobStartToSystemLog lets the OS log know that the job has started, if job lifecycle events are sent there
+func (jm *jobMgr) reportJobStartToSystemLog(resumed bool) {
+	if jm.systemLogger == nil {
+		return
+	}
+	jm.systemLogger.Log(pipeline.LogInfo, fmt.Sprintf("Job %s %s. Its log file is %s",
+		jm.jobID.String(), common.IffString(resumed, "resumed", "started"), common.JobLogFilePath(jm.logFileFolder, jm.jobID)))
+}
+
+// reportJobEndToSystemLog sends the final status of the job, and its transfer counts, to the OS log.
+// Since the app may exit as soon as the job is done, it then waits a little for the OS log to catch up.
+func (jm *jobMgr) reportJobEndToSystemLog(cancelled bool) {
+	var completed, failed, skipped uint32
+	jm.jobPartMgrs.Iterate(true, func(partNum common.PartNumber, jpm IJobPartMgr) {
+		jpp := jpm.Plan()
+		for t := uint32(0); t < jpp.NumTransfers; t++ {
+			switch jpp.Transfer(t).TransferStatus() {
+			case common.ETransferStatus.Success():
+				completed++
+			case common.ETransferStatus.Failed(),
+				common.ETransferStatus.BlobTierFailure(),
+				common.ETransferStatus.DestinationBusy(),
+				common.ETransferStatus.ShareFull():
+				failed++
+			case common.ETransferStatus.SkippedFileAlreadyExists(),
+				common.ETransferStatus.SkippedBlobHasSnapshots(),
+				common.ETransferStatus.SkippedIncompatibleBlobType(),
+				common.ETransferStatus.SkippedPermissionDenied(),
+				common.ETransferStatus.SkippedFileLocked(),
+				common.ETransferStatus.SkippedDestinationConditionNotMet(),
+				common.ETransferStatus.SkippedSourceDeleted(),
+				common.ETransferStatus.SkippedDestinationSoftDeleted():
+				skipped++
+			}
+		}
+	})
+
+	status, level := common.EJobStatus.Cancelled(), pipeline.LogWarning
+	if !cancelled {
+		status = status.EnhanceJobStatusInfo(skipped > 0, failed > 0, completed > 0)
+		level = pipeline.LogInfo
+		if failed > 0 {
+			level = pipeline.LogError
+		}
+	}
+
+	msg := fmt.Sprintf("Job %s finished with status %s. Transfers completed: %d, failed: %d, skipped: %d",
+		jm.jobID.String(), status, completed, failed, skipped)
+	if dropped := jm.systemLogger.DroppedCount(); dropped > 0 {
+		msg += fmt.Sprintf(". %d earlier messages were not sent to the OS log, because it was not keeping up", dropped)
+		jm.Log(pipeline.LogWarning, fmt.Sprintf("%d messages were not sent to the OS log, because it was not keeping up", dropped))
+	}
+	jm.systemLogger.Log(level, msg)
+	jm.systemLogger.Flush(5 * time.Second)
+}
+
 func (jm *jobMgr) getInMemoryTransitJobState() InMemoryTransitJobState {
 	return jm.inMemoryTransitJobState
 }
@@ -482,12 +748,60 @@ func (jm *jobMgr) getInMemoryTransitJobState() InMemoryTransitJobState {
 // And the state should no more be changed inside STE module.
 func (jm *jobMgr) setInMemoryTransitJobState(state InMemoryTransitJobState) {
 	jm.inMemoryTransitJobState = state
+
+	// every part of the job is ordered with the same metrics file, so only the first one creates the recorder
+	if state.metricsFile != "" && jm.transferMetrics == nil {
+		recorder, err := newTransferMetricsRecorder(state.metricsFile)
+		if err != nil {
+			jm.Log(pipeline.LogError, fmt.Sprintf("Transfer metrics will not be recorded, because the metrics file could not be created: %s", err.Error()))
+			common.GetLifecycleMgr().Info("Transfer metrics will not be recorded, because the metrics file could not be created: " + err.Error())
+		} else {
+			jm.transferMetrics = recorder
+		}
+	}
+
+	// the same goes for the checksum file
+	if state.checksumFile != "" && jm.checksums == nil {
+		var checksums *checksumManifest
+		var err error
+		if state.verifyChecksums {
+			checksums, err = loadChecksumManifest(state.checksumFile)
+		} else {
+			checksums, err = newChecksumManifestWriter(state.checksumFile)
+		}
+		if err != nil {
+			// the front end has already checked the file, so this is unlikely, but the files must not look verified if it happens
+			jm.Log(pipeline.LogError, fmt.Sprintf("Cannot use the checksum file %s: %s", state.checksumFile, err.Error()))
+			common.GetLifecycleMgr().Error(fmt.Sprintf("Cannot use the checksum file %s: %s", state.checksumFile, err.Error()))
+			return
+		}
+		jm.checksums = checksums
+	}
+}
+
+func (jm *jobMgr) getTransferMetricsRecorder() *transferMetricsRecorder {
+	return jm.transferMetrics
+}
+
+func (jm *jobMgr) getChecksumManifest() *checksumManifest {
+	return jm.checksums
+}
+
+func (jm *jobMgr) ChecksumEntriesNotFound() uint32 {
+	if jm.checksums == nil {
+		return 0
+	}
+	return jm.checksums.entriesNotFound()
 }
 
 func (jm *jobMgr) Context() context.Context                { return jm.ctx }
 func (jm *jobMgr) Cancel()                                 { jm.cancel() }
 func (jm *jobMgr) ShouldLog(level pipeline.LogLevel) bool  { return jm.logger.ShouldLog(level) }
 func (jm *jobMgr) Log(level pipeline.LogLevel, msg string) { jm.logger.Log(level, msg) }
+func (jm *jobMgr) LogFormat() common.LogFormat             { return jm.logger.LogFormat() }
+func (jm *jobMgr) LogStructured(level pipeline.LogLevel, entry common.LogEntry) {
+	jm.logger.LogStructured(level, entry)
+}
 func (jm *jobMgr) PipelineLogInfo() pipeline.LogOptions {
 	return pipeline.LogOptions{
 		Log:       jm.Log,
@@ -3,7 +3,6 @@ package ste
 import (
 	"context"
 	"fmt"
-	"mime"
 	"net"
 	"net/http"
 	"path/filepath"
@@ -28,7 +27,18 @@ type IJobPartMgr interface {
 	StartJobXfer(jptm IJobPartTransferMgr)
 	ReportTransferDone() uint32
 	GetOverwriteOption() common.OverwriteOption
+	S2SFallback() common.S2SFallback
+	DiffBaseSnapshot() string
+	ReuseUncommittedBlocks() bool
+	AppendOnly() bool
+	WasResumed() bool
+	DownloadRange() (offset int64, ranged bool)
+	DeleteSourceAfterTransfer() bool
+	ClearArchiveBit() bool
+	SkipPermissionErrors() bool
+	SkipLockedFiles() bool
 	AutoDecompress() bool
+	Priority() common.JobPriority
 	ScheduleChunks(chunkFunc chunkFunc)
 	RescheduleTransfer(jptm IJobPartTransferMgr)
 	BlobTypeOverride() common.BlobType
@@ -47,8 +57,12 @@ type IJobPartMgr interface {
 	ExclusiveDestinationMap() *common.ExclusiveStringMap
 	ChunkStatusLogger() common.ChunkStatusLogger
 	common.ILogger
+	common.IStructuredLogger
 	SourceProviderPipeline() pipeline.Pipeline
+	S2SSourceTokenCredential() azblob.TokenCredential
 	getOverwritePrompter() *overwritePrompter
+	getDiskSpaceGuard() *diskSpaceGuard
+	getShareQuotaGuard() *shareQuotaGuard
 }
 
 type serviceAPIVersionOverride struct{}
@@ -82,26 +96,33 @@ func NewVersionPolicyFactory() pipeline.Factory {
 // number of available network sockets on resource-constrained Linux systems. (E.g. when
 // 'ulimit -Hn' is low).
 func NewAzcopyHTTPClient(maxIdleConns int) *http.Client {
-	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: common.GlobalProxyLookup,
-			DialContext: newDialRateLimiter(&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).DialContext,
-			MaxIdleConns:           0, // No limit
-			MaxIdleConnsPerHost:    maxIdleConns,
-			IdleConnTimeout:        180 * time.Second,
-			TLSHandshakeTimeout:    10 * time.Second,
-			ExpectContinueTimeout:  1 * time.Second,
-			DisableKeepAlives:      false,
-			DisableCompression:     true, // must disable the auto-decompression of gzipped files, and just download the gzipped version. See https://github.com/Azure/azure-storage-azcopy/issues/374
-			MaxResponseHeaderBytes: 0,
-			//ResponseHeaderTimeout:  time.Duration{},
-			//ExpectContinueTimeout:  time.Duration{},
-		},
+	var transport *http.Transport
+	// the hosts are looked up through the cache. When it forgets the addresses of a host as stale, the pooled connections go too
+	dialContext := sharedDNSCache().dialContext(newDialRateLimiter(common.BindToLocalAddr(&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	})).DialContext, func() { transport.CloseIdleConnections() })
+	// the tunnel does the CONNECT to the proxy itself, so that it can answer the proxy's authentication challenges
+	proxyTunnel := common.NewProxyTunnel(dialContext, 10*time.Second)
+	transport = &http.Transport{
+		Proxy:                  proxyTunnel.Proxy,
+		DialContext:            dialContext,
+		DialTLSContext:         proxyTunnel.DialTLSContext,
+		MaxIdleConns:           0, // No limit
+		MaxIdleConnsPerHost:    maxIdleConns,
+		IdleConnTimeout:        180 * time.Second,
+		TLSHandshakeTimeout:    10 * time.Second,
+		ExpectContinueTimeout:  1 * time.Second,
+		DisableKeepAlives:      false,
+		DisableCompression:     true, // must disable the auto-decompression of gzipped files, and just download the gzipped version. See https://github.com/Azure/azure-storage-azcopy/issues/374
+		MaxResponseHeaderBytes: 0,
+		//ResponseHeaderTimeout:  time.Duration{},
+		//ExpectContinueTimeout:  time.Duration{},
 	}
+	// the tuning from the environment, e.g. of the idle connections kept per host, replaces the defaults
+	common.GlobalTransportSettings.Apply(transport)
+	return &http.Client{Transport: transport}
 }
 
 // Prevents too many dials happening at once, because we've observed that that increases the thread
@@ -152,13 +173,18 @@ func NewBlobPipeline(c azblob.Credential, o azblob.PipelineOptions, r XferRetryO
 	// Closest to API goes first; closest to the wire goes last
 	f := []pipeline.Factory{
 		azblob.NewTelemetryPolicyFactory(o.Telemetry),
+		newClientRequestIDPolicyFactory(), // before the SDK's, which only sets an ID if there's none
 		azblob.NewUniqueRequestIDPolicyFactory(),
 		NewBlobXferRetryPolicyFactory(r),    // actually retry the operation
 		newRetryNotificationPolicyFactory(), // record that a retry status was returned
 		c,
-		pipeline.MethodFactoryMarker(), // indicates at what stage in the pipeline the method factory is invoked
+		pipeline.MethodFactoryMarker(),     // indicates at what stage in the pipeline the method factory is invoked
+		newTransactionPacerPolicyFactory(), // before the logging, so that the time a request waits for its turn isn't taken for slowness
+		newPartitionThrottlePolicyFactory(currentPartitionThrottle()),
 		//NewPacerPolicyFactory(p),
 		NewVersionPolicyFactory(),
+		NewCopySourceAuthorizationPolicyFactory(),
+		newRequestAPIVersionPolicyFactory(), // after anything that sets the version, so that the pinned one wins
 		NewRequestLogPolicyFactory(RequestLogOptions{LogWarningIfTryOverThreshold: o.RequestLog.LogWarningIfTryOverThreshold}),
 		newXferStatsPolicyFactory(statsAcc),
 	}
@@ -174,6 +200,7 @@ func NewBlobFSPipeline(c azbfs.Credential, o azbfs.PipelineOptions, r XferRetryO
 	// Closest to API goes first; closest to the wire goes last
 	f := []pipeline.Factory{
 		azbfs.NewTelemetryPolicyFactory(o.Telemetry),
+		newClientRequestIDPolicyFactory(), // before the SDK's, which only sets an ID if there's none
 		azbfs.NewUniqueRequestIDPolicyFactory(),
 		NewBFSXferRetryPolicyFactory(r),     // actually retry the operation
 		newRetryNotificationPolicyFactory(), // record that a retry status was returned
@@ -182,7 +209,10 @@ func NewBlobFSPipeline(c azbfs.Credential, o azbfs.PipelineOptions, r XferRetryO
 	f = append(f, c)
 
 	f = append(f,
-		pipeline.MethodFactoryMarker(), // indicates at what stage in the pipeline the method factory is invoked
+		pipeline.MethodFactoryMarker(),     // indicates at what stage in the pipeline the method factory is invoked
+		newTransactionPacerPolicyFactory(), // before the logging, so that the time a request waits for its turn isn't taken for slowness
+		newPartitionThrottlePolicyFactory(currentPartitionThrottle()),
+		newRequestAPIVersionPolicyFactory(), // after anything that sets the version, so that the pinned one wins
 		NewRequestLogPolicyFactory(RequestLogOptions{LogWarningIfTryOverThreshold: o.RequestLog.LogWarningIfTryOverThreshold}),
 		newXferStatsPolicyFactory(statsAcc))
 
@@ -197,12 +227,17 @@ func NewFilePipeline(c azfile.Credential, o azfile.PipelineOptions, r azfile.Ret
 	// Closest to API goes first; closest to the wire goes last
 	f := []pipeline.Factory{
 		azfile.NewTelemetryPolicyFactory(o.Telemetry),
+		newClientRequestIDPolicyFactory(), // before the SDK's, which only sets an ID if there's none
 		azfile.NewUniqueRequestIDPolicyFactory(),
 		azfile.NewRetryPolicyFactory(r),     // actually retry the operation
+		newRetryAfterPolicyFactory(),        // wait as long as the service asked, since the SDK's retry policy doesn't
 		newRetryNotificationPolicyFactory(), // record that a retry status was returned
 		c,
-		pipeline.MethodFactoryMarker(), // indicates at what stage in the pipeline the method factory is invoked
+		pipeline.MethodFactoryMarker(),     // indicates at what stage in the pipeline the method factory is invoked
+		newTransactionPacerPolicyFactory(), // before the logging, so that the time a request waits for its turn isn't taken for slowness
+		newPartitionThrottlePolicyFactory(currentPartitionThrottle()),
 		NewVersionPolicyFactory(),
+		newRequestAPIVersionPolicyFactory(), // after anything that sets the version, so that the pinned one wins
 		NewRequestLogPolicyFactory(RequestLogOptions{LogWarningIfTryOverThreshold: o.RequestLog.LogWarningIfTryOverThreshold}),
 		newXferStatsPolicyFactory(statsAcc),
 	}
@@ -244,6 +279,12 @@ type jobPartMgr struct {
 	blobMetadata azblob.Metadata
 	fileMetadata azfile.Metadata
 
+	// Additional data shared by all of this Job Part's transfers; initialized when this jobPartMgr is created
+	blobTags common.BlobTags
+
+	// the header rules of an upload, which set the content headers of the files they match
+	headerRules common.HeaderRules
+
 	blobTypeOverride common.BlobType // User specified blob type
 
 	preserveLastModifiedTime bool
@@ -264,6 +305,9 @@ type jobPartMgr struct {
 
 	sourceProviderPipeline pipeline.Pipeline
 
+	// nil unless the source of the S2S copy is read with the user's OAuth token, rather than with a SAS
+	s2sSourceTokenCredential azblob.TokenCredential
+
 	// used defensively to protect double init
 	atomicPipelinesInitedIndicator uint32
 
@@ -277,6 +321,14 @@ func (jpm *jobPartMgr) getOverwritePrompter() *overwritePrompter {
 	return jpm.jobMgr.getOverwritePrompter()
 }
 
+func (jpm *jobPartMgr) getDiskSpaceGuard() *diskSpaceGuard {
+	return jpm.jobMgr.getDiskSpaceGuard()
+}
+
+func (jpm *jobPartMgr) getShareQuotaGuard() *shareQuotaGuard {
+	return jpm.jobMgr.getShareQuotaGuard()
+}
+
 func (jpm *jobPartMgr) Plan() *JobPartPlanHeader { return jpm.planMMF.Plan() }
 
 // ScheduleTransfers schedules this job part's transfers. It is called when a new job part is ordered & is also called to resume a paused Job
@@ -285,6 +337,12 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 	// partplan file is opened and mapped when job part is added
 	//jpm.planMMF = jpm.filename.Map() // Open the job part plan file & memory-map it in
 	plan := jpm.planMMF.Plan()
+	// every request of the part, and of its transfers, is correlated with the job by its x-ms-client-request-id
+	jobCtx = WithClientRequestIDPrefix(jobCtx, ClientRequestIDPrefix(plan.JobID, plan.clientRequestIDPrefix()))
+	// the requests of the transfers that move no data are paced, and counted, apart from the others
+	if plan.FromTo.IsMetadataOnly() {
+		jobCtx = withMetadataOnlyTransfers(jobCtx)
+	}
 	// get the list of include / exclude transfers
 	includeTransfer, excludeTransfer := jpm.jobMgr.IncludeExclude()
 	// *** Open the job part: process any job part plan-setting used by all transfers ***
@@ -334,6 +392,12 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 		}
 	}
 
+	// For this job part, split the blob tags string apart too. It has already been validated by the front end.
+	jpm.blobTags, _ = common.ToCommonBlobTags(string(dstData.BlobTags[:dstData.BlobTagsLength]))
+
+	// the header rules too, which the front end has validated as well
+	jpm.headerRules, _ = common.ParseHeaderRules(string(dstData.HeaderRules[:dstData.HeaderRulesLength]))
+
 	jpm.preserveLastModifiedTime = plan.DstLocalData.PreserveLastModifiedTime
 
 	jpm.blobTypeOverride = plan.DstBlobData.BlobType
@@ -343,7 +407,21 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 
 	jpm.createPipelines(jobCtx) // pipeline is created per job part manager
 
+	// the transfers are scheduled in the order that the job asks for, as far as the window allows
+	ordered := newTransferOrderBuffer(plan.TransferOrder, transferOrderWindow, func(jptm IJobPartTransferMgr) {
+		JobsAdmin.(*jobsAdmin).ScheduleTransfer(jpm.priority, jptm)
+
+		// This sets the atomic variable atomicAllTransfersScheduled to 1
+		// atomicAllTransfersScheduled variables is used in case of resume job
+		// Since iterating the JobParts and scheduling transfer is independent
+		// a variable is required which defines whether last part is resumed or not
+		if plan.IsFinalPart {
+			jpm.jobMgr.ConfirmAllTransfersScheduled()
+		}
+	})
+
 	// *** Schedule this job part's transfers ***
+	skippedAsListed := 0
 	for t := uint32(0); t < plan.NumTransfers; t++ {
 		jppt := plan.Transfer(t)
 		ts := jppt.TransferStatus()
@@ -352,6 +430,14 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 			continue
 		}
 
+		// The front end found this one at the destination when it listed it, so there's no need to check it again.
+		// (When resuming, it's checked again like any other skipped transfer, in case the destination has changed since.)
+		if ts == common.ETransferStatus.SkippedFileAlreadyExists() && !jpm.WasResumed() {
+			skippedAsListed++
+			jpm.ReportTransferDone()
+			continue
+		}
+
 		// If the list of transfer to be included is passed
 		// then check current transfer exists in the list of included transfer
 		// If it doesn't exists, skip the transfer
@@ -387,6 +473,13 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 
 		// Each transfer gets its own context (so any chunk can cancel the whole transfer) based off the job's context
 		transferCtx, transferCancel := context.WithCancel(jobCtx)
+
+		var metrics *transferMetrics
+		if recorder := jpm.jobMgr.getTransferMetricsRecorder(); recorder != nil {
+			metrics = &transferMetrics{recorder: recorder, queuedAt: time.Now()}
+			transferCtx = withTransferMetrics(transferCtx, metrics) // so that the retries of its requests can be counted
+		}
+
 		// Initialize a job part transfer manager
 		jptm := &jobPartTransferMgr{
 			jobPartMgr:          jpm,
@@ -394,6 +487,7 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 			transferIndex:       t,
 			ctx:                 transferCtx,
 			cancel:              transferCancel,
+			metrics:             metrics,
 			//TODO: insert the factory func interface in jptm.
 			// numChunks will be set by the transfer's prologue method
 		}
@@ -401,18 +495,20 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 			jpm.Log(pipeline.LogInfo, fmt.Sprintf("scheduling JobID=%v, Part#=%d, Transfer#=%d, priority=%v", plan.JobID, plan.PartNum, t, plan.Priority))
 		}
 
-		JobsAdmin.(*jobsAdmin).ScheduleTransfer(jpm.priority, jptm)
+		ordered.add(jptm, jppt.SourceSize)
+	}
+	ordered.flush()
 
-		// This sets the atomic variable atomicAllTransfersScheduled to 1
-		// atomicAllTransfersScheduled variables is used in case of resume job
-		// Since iterating the JobParts and scheduling transfer is independent
-		// a variable is required which defines whether last part is resumed or not
-		if plan.IsFinalPart {
-			jpm.jobMgr.ConfirmAllTransfersScheduled()
-		}
+	if skippedAsListed > 0 {
+		jpm.Log(pipeline.LogInfo, fmt.Sprintf("JobID=%v, Part#=%d, skipped %d transfers that the listing of the destination found already there",
+			plan.JobID, plan.PartNum, skippedAsListed))
 	}
 }
 
+func (jpm *jobPartMgr) Priority() common.JobPriority {
+	return jpm.priority
+}
+
 func (jpm *jobPartMgr) ScheduleChunks(chunkFunc chunkFunc) {
 	JobsAdmin.ScheduleChunk(jpm.priority, chunkFunc)
 }
@@ -442,6 +538,8 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 		Panic:    jpm.Panic,
 		CallerID: fmt.Sprintf("JobID=%v, Part#=%d", jpm.Plan().JobID, jpm.Plan().PartNum),
 		Cancel:   jpm.jobMgr.Cancel,
+		// the refreshers of every part wait for the same new token, once the token expired
+		TokenExpired: currentCredentialExpiryGuard().waitForRenewal,
 	}
 	// TODO: Consider to remove XferRetryPolicy and Options?
 	xferRetryOption := XferRetryOptions{
@@ -455,8 +553,18 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 
 	// Create source info provider's pipeline for S2S copy.
 	if fromTo == common.EFromTo.BlobBlob() || fromTo == common.EFromTo.BlobFile() {
+		var sourceCredential azblob.Credential = azblob.NewAnonymousCredential()
+		if fromTo == common.EFromTo.BlobBlob() && credInfo.S2SSourceCredentialType == common.ECredentialType.OAuthToken() {
+			// the same credential authorizes what the source info provider reads, and what the destination reads
+			sourceCredential = common.CreateBlobCredential(ctx, common.CredentialInfo{
+				CredentialType: common.ECredentialType.OAuthToken(),
+				OAuthTokenInfo: credInfo.OAuthTokenInfo,
+			}, credOption)
+			jpm.s2sSourceTokenCredential = sourceCredential.(azblob.TokenCredential)
+			jpm.Log(pipeline.LogInfo, fmt.Sprintf("JobID=%v, source credential type: %v", jpm.Plan().JobID, credInfo.S2SSourceCredentialType))
+		}
 		jpm.sourceProviderPipeline = NewBlobPipeline(
-			azblob.NewAnonymousCredential(),
+			sourceCredential,
 			azblob.PipelineOptions{
 				Log: jpm.jobMgr.PipelineLogInfo(),
 				Telemetry: azblob.TelemetryOptions{
@@ -491,7 +599,7 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 
 	// Create pipeline for data transfer.
 	switch fromTo {
-	case common.EFromTo.BlobTrash(), common.EFromTo.BlobLocal(), common.EFromTo.LocalBlob(), common.EFromTo.BenchmarkBlob(),
+	case common.EFromTo.BlobTrash(), common.EFromTo.BlobNone(), common.EFromTo.BlobLocal(), common.EFromTo.LocalBlob(), common.EFromTo.BenchmarkBlob(),
 		common.EFromTo.BlobBlob(), common.EFromTo.FileBlob(), common.EFromTo.S3Blob():
 		credential := common.CreateBlobCredential(ctx, credInfo, credOption)
 		jpm.Log(pipeline.LogInfo, fmt.Sprintf("JobID=%v, credential type: %v", jpm.Plan().JobID, credInfo.CredentialType))
@@ -507,6 +615,9 @@ func (jpm *jobPartMgr) createPipelines(ctx context.Context) {
 			jpm.pacer,
 			jpm.jobMgr.HttpClient(),
 			jpm.jobMgr.PipelineNetworkStats())
+		if jpm.Plan().BreakLeaseOnOverwrite {
+			jpm.pipeline = newLeaseBreakingPipeline(jpm.pipeline, jpm.Log)
+		}
 	// Create pipeline for Azure BlobFS.
 	case common.EFromTo.BlobFSLocal(), common.EFromTo.LocalBlobFS(), common.EFromTo.BenchmarkBlobFS():
 		credential := common.CreateBlobFSCredential(ctx, credInfo, credOption)
@@ -574,6 +685,50 @@ func (jpm *jobPartMgr) GetOverwriteOption() common.OverwriteOption {
 	return jpm.Plan().ForceWrite
 }
 
+func (jpm *jobPartMgr) S2SFallback() common.S2SFallback {
+	return jpm.Plan().S2SFallback
+}
+
+func (jpm *jobPartMgr) DiffBaseSnapshot() string {
+	plan := jpm.Plan()
+	return string(plan.DiffBaseSnapshot[:plan.DiffBaseSnapshotLength])
+}
+
+// ReuseUncommittedBlocks is true if the user asked for it, and always when the job is resumed, since then the blocks that the job staged before are still there
+func (jpm *jobPartMgr) ReuseUncommittedBlocks() bool {
+	return jpm.Plan().ReuseUncommittedBlocks || jpm.jobMgr.getInMemoryTransitJobState().resumed
+}
+
+func (jpm *jobPartMgr) AppendOnly() bool {
+	return jpm.Plan().AppendOnly
+}
+
+// WasResumed tells whether the job was resumed in this process, in which case what it left at its destinations may be picked up again
+func (jpm *jobPartMgr) WasResumed() bool {
+	return jpm.jobMgr.getInMemoryTransitJobState().resumed
+}
+
+func (jpm *jobPartMgr) DownloadRange() (offset int64, ranged bool) {
+	plan := jpm.Plan()
+	return plan.DownloadOffset, plan.RangedDownload
+}
+
+func (jpm *jobPartMgr) DeleteSourceAfterTransfer() bool {
+	return jpm.Plan().DeleteSourceAfterTransfer
+}
+
+func (jpm *jobPartMgr) ClearArchiveBit() bool {
+	return jpm.Plan().ClearArchiveBit
+}
+
+func (jpm *jobPartMgr) SkipPermissionErrors() bool {
+	return jpm.Plan().SkipPermissionErrors
+}
+
+func (jpm *jobPartMgr) SkipLockedFiles() bool {
+	return jpm.Plan().SkipLockedFiles
+}
+
 func (jpm *jobPartMgr) AutoDecompress() bool {
 	return jpm.Plan().AutoDecompress
 }
@@ -601,7 +756,7 @@ func (jpm *jobPartMgr) bfsDstData(fullFilePath string, dataFileToXfer []byte) (h
 }
 
 func (jpm *jobPartMgr) inferContentType(fullFilePath string, dataFileToXfer []byte) string {
-	if guessedType := mime.TypeByExtension(filepath.Ext(fullFilePath)); guessedType != "" {
+	if guessedType := common.ContentTypeByExtension(filepath.Ext(fullFilePath)); guessedType != "" {
 		return guessedType
 	}
 
@@ -632,6 +787,14 @@ func (jpm *jobPartMgr) deleteSnapshotsOption() common.DeleteSnapshotsOption {
 	return jpm.Plan().DeleteSnapshotsOption
 }
 
+func (jpm *jobPartMgr) setPropertiesFlags() common.SetPropertiesFlags {
+	return jpm.Plan().SetPropertiesFlags
+}
+
+func (jpm *jobPartMgr) BlobTags() common.BlobTags {
+	return jpm.blobTags
+}
+
 // Call Done when a transfer has completed its epilog; this method returns the number of transfers completed so far
 func (jpm *jobPartMgr) ReportTransferDone() (transfersDone uint32) {
 	transfersDone = atomic.AddUint32(&jpm.atomicTransfersDone, 1)
@@ -653,6 +816,8 @@ func (jpm *jobPartMgr) Close() {
 	jpm.blobMetadata = azblob.Metadata{}
 	jpm.fileHTTPHeaders = azfile.FileHTTPHeaders{}
 	jpm.fileMetadata = azfile.Metadata{}
+	jpm.blobTags = common.BlobTags{}
+	jpm.headerRules = nil
 	jpm.blobFSHTTPHeaders = azbfs.BlobFSHTTPHeaders{}
 	jpm.preserveLastModifiedTime = false
 	// TODO: Delete file?
@@ -675,7 +840,11 @@ func (jpm *jobPartMgr) ReleaseAConnection() {
 
 func (jpm *jobPartMgr) ShouldLog(level pipeline.LogLevel) bool  { return jpm.jobMgr.ShouldLog(level) }
 func (jpm *jobPartMgr) Log(level pipeline.LogLevel, msg string) { jpm.jobMgr.Log(level, msg) }
+func (jpm *jobPartMgr) LogFormat() common.LogFormat             { return jpm.jobMgr.LogFormat() }
 func (jpm *jobPartMgr) Panic(err error)                         { jpm.jobMgr.Panic(err) }
+func (jpm *jobPartMgr) LogStructured(level pipeline.LogLevel, entry common.LogEntry) {
+	jpm.jobMgr.LogStructured(level, entry)
+}
 func (jpm *jobPartMgr) ChunkStatusLogger() common.ChunkStatusLogger {
 	return jpm.jobMgr.ChunkStatusLogger()
 }
@@ -684,6 +853,10 @@ func (jpm *jobPartMgr) SourceProviderPipeline() pipeline.Pipeline {
 	return jpm.sourceProviderPipeline
 }
 
+func (jpm *jobPartMgr) S2SSourceTokenCredential() azblob.TokenCredential {
+	return jpm.s2sSourceTokenCredential
+}
+
 // TODO: Can we delete this method?
 // numberOfTransfersDone returns the numberOfTransfersDone_doNotUse of JobPartPlanInfo
 // instance in thread safe manner
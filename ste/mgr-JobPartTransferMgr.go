@@ -3,13 +3,14 @@ package ste
 import (
 	"context"
 	"fmt"
+	"hash"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"net/url"
-
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-azcopy/azbfs"
 	"github.com/Azure/azure-storage-azcopy/common"
@@ -38,7 +39,48 @@ type IJobPartTransferMgr interface {
 	UnlockDestination()
 	HoldsDestinationLock() bool
 	StartJobXfer()
+	recordPickedUp(workerID int)
 	GetOverwriteOption() common.OverwriteOption
+	S2SFallback() common.S2SFallback
+	ReportRelayedClientSide()
+	ReportChunkIntegrityRetry()
+	ReportSourceReadRetry()
+	ReportSharingViolationRetry()
+	S2SComputeMD5() common.S2SComputeMD5
+	ReportHashBackfillStarted()
+	ReportHashBackfillEnded()
+	ReportMD5Backfilled()
+	ReportBlobWithoutMD5()
+	DiffBaseSnapshot() string
+	ReuseUncommittedBlocks() bool
+	ReportPageBlobDiff(changedBytes int64, logicalBytes int64)
+	AppendOnly() bool
+	DownloadRange() (offset int64, ranged bool)
+	SavedDownload() (savedLength int64, modTime time.Time)
+	SetSavedDownload(savedLength int64, modTime time.Time)
+	DeleteSourceAfterTransfer() bool
+	ClearArchiveBit() bool
+	BreakLeaseOnOverwrite() bool
+	ProtectDestinationWithLease() bool
+	DestinationCondition() common.DestinationCondition
+	ForceIfReadOnly() bool
+	SharingViolationRetryWindow() time.Duration
+	ShareFullWaitWindow() time.Duration
+	FlushPolicy() (policy common.FlushPolicy, intervalBytes int64)
+	MissingSourceHandling() common.MissingSourceHandling
+	SoftDeletedDestinationHandling() common.SoftDeletedDestinationHandling
+	SetSoftDeletedDestination(handling common.SoftDeletedDestinationHandling)
+	PreserveSourceLMTAsMetadata() bool
+	TempNameSuffix() string
+	WasResumed() bool
+	SkipPermissionErrors() bool
+	SkipLockedFiles() bool
+	SetSourceDeleted()
+	ReportAppendOnly(appendedBytes int64, uploadedInFullBytes int64)
+	ChecksumHasher() hash.Hash
+	SetChecksum(digest []byte)
+	VerifyChecksum() error
+	RecordChecksum()
 	ShouldDecompress() bool
 	GetSourceCompressionType() (common.CompressionType, error)
 	ReportChunkDone(id common.ChunkID) (lastChunk bool, chunksDone uint32)
@@ -61,6 +103,7 @@ type IJobPartTransferMgr interface {
 	// TODO: added for debugging purpose. remove later
 	ReleaseAConnection()
 	SourceProviderPipeline() pipeline.Pipeline
+	S2SSourceTokenCredential() azblob.TokenCredential // nil unless the source of the S2S copy is read with the user's OAuth token
 	FailActiveUpload(where string, err error)
 	FailActiveDownload(where string, err error)
 	FailActiveUploadWithStatus(where string, err error, failureStatus common.TransferStatus)
@@ -81,8 +124,12 @@ type IJobPartTransferMgr interface {
 	ChunkStatusLogger() common.ChunkStatusLogger
 	LogAtLevelForCurrentTransfer(level pipeline.LogLevel, msg string)
 	GetOverwritePrompter() *overwritePrompter
+	GetDiskSpaceGuard() *diskSpaceGuard
+	GetShareQuotaGuard() *shareQuotaGuard
 	common.ILogger
 	DeleteSnapshotsOption() common.DeleteSnapshotsOption
+	SetPropertiesFlags() common.SetPropertiesFlags
+	BlobTags() common.BlobTags
 }
 
 type TransferInfo struct {
@@ -150,6 +197,16 @@ type jobPartTransferMgr struct {
 
 	actionAfterLastChunk func()
 
+	// nil unless the job is recording transfer metrics
+	metrics *transferMetrics
+
+	// the checksum of the bytes that the transfer read, if the job writes or verifies a checksum file
+	checksum []byte
+
+	// see partitionBucket
+	partitionBucketOnce  sync.Once
+	partitionBucketValue string
+
 	/*
 		@Parteek removed 3/23 morning, as jeff ad equivalent
 		// transfer chunks are put into this channel and execution engine takes chunk out of this channel.
@@ -160,18 +217,271 @@ func (jptm *jobPartTransferMgr) GetOverwritePrompter() *overwritePrompter {
 	return jptm.jobPartMgr.getOverwritePrompter()
 }
 
+func (jptm *jobPartTransferMgr) GetDiskSpaceGuard() *diskSpaceGuard {
+	return jptm.jobPartMgr.getDiskSpaceGuard()
+}
+
+func (jptm *jobPartTransferMgr) GetShareQuotaGuard() *shareQuotaGuard {
+	return jptm.jobPartMgr.getShareQuotaGuard()
+}
+
 func (jptm *jobPartTransferMgr) FromTo() common.FromTo {
 	return jptm.jobPartMgr.Plan().FromTo
 }
 
 func (jptm *jobPartTransferMgr) StartJobXfer() {
+	jptm.jobPartPlanTransfer.StartProgress(time.Now())
 	jptm.jobPartMgr.StartJobXfer(jptm)
 }
 
+// recordPickedUp notes which transfer initiation worker picked up the transfer, if the job is recording transfer metrics
+func (jptm *jobPartTransferMgr) recordPickedUp(workerID int) {
+	if jptm.metrics != nil {
+		jptm.metrics.recordStart(workerID)
+	}
+}
+
 func (jptm *jobPartTransferMgr) GetOverwriteOption() common.OverwriteOption {
 	return jptm.jobPartMgr.GetOverwriteOption()
 }
 
+func (jptm *jobPartTransferMgr) S2SFallback() common.S2SFallback {
+	return jptm.jobPartMgr.S2SFallback()
+}
+
+// ReportRelayedClientSide counts the transfer in the job's number of transfers that use the client-side relay
+func (jptm *jobPartTransferMgr) ReportRelayedClientSide() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportTransferRelayedClientSide()
+}
+
+// ReportChunkIntegrityRetry counts a chunk that is fetched again, since it didn't match its MD5 hash, in the job's number of such retries
+func (jptm *jobPartTransferMgr) ReportChunkIntegrityRetry() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportChunkIntegrityRetry()
+}
+
+// ReportSourceReadRetry counts a read of a network source that is retried, since the share dropped, in the job's number of such retries
+func (jptm *jobPartTransferMgr) ReportSourceReadRetry() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportSourceReadRetry()
+}
+
+// ReportSharingViolationRetry counts the transfer in the job's number of those whose destination was retried, since something else had it open over SMB
+func (jptm *jobPartTransferMgr) ReportSharingViolationRetry() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportSharingViolationRetry()
+}
+
+// S2SComputeMD5 is which destination block blobs of the S2S copy have their Content-MD5 computed by this machine, after they're written
+func (jptm *jobPartTransferMgr) S2SComputeMD5() common.S2SComputeMD5 {
+	return jptm.jobPartMgr.Plan().S2SComputeMD5
+}
+
+// ReportHashBackfillStarted counts the transfer in the job's number of destinations whose Content-MD5 is being computed,
+// until ReportHashBackfillEnded is called
+func (jptm *jobPartTransferMgr) ReportHashBackfillStarted() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportHashBackfillStarted()
+}
+
+func (jptm *jobPartTransferMgr) ReportHashBackfillEnded() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportHashBackfillEnded()
+}
+
+// ReportMD5Backfilled counts the transfer in the job's number of destinations that got their Content-MD5 computed by this machine
+func (jptm *jobPartTransferMgr) ReportMD5Backfilled() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportMD5Backfilled()
+}
+
+// ReportBlobWithoutMD5 counts the transfer in the job's number of destinations that were left without a Content-MD5
+func (jptm *jobPartTransferMgr) ReportBlobWithoutMD5() {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportBlobWithoutMD5()
+}
+
+// DiffBaseSnapshot returns the snapshot of the source page blob that the destination already holds, or "" unless only the changes since it are copied
+func (jptm *jobPartTransferMgr) DiffBaseSnapshot() string {
+	return jptm.jobPartMgr.DiffBaseSnapshot()
+}
+
+// ReuseUncommittedBlocks tells whether the block blob senders should keep the blocks that an earlier attempt at the transfer staged
+func (jptm *jobPartTransferMgr) ReuseUncommittedBlocks() bool {
+	return jptm.jobPartMgr.ReuseUncommittedBlocks()
+}
+
+// AppendOnly tells whether the uploads to append blobs and ADLS Gen2 files only append what the destination doesn't have yet
+func (jptm *jobPartTransferMgr) AppendOnly() bool {
+	return jptm.jobPartMgr.AppendOnly()
+}
+
+// ReportAppendOnly adds the bytes of a successful append-only upload to the job's totals
+func (jptm *jobPartTransferMgr) ReportAppendOnly(appendedBytes int64, uploadedInFullBytes int64) {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportAppendOnly(appendedBytes, uploadedInFullBytes)
+}
+
+// DownloadRange tells whether only a window of the source is downloaded, and the offset in the source at which it starts.
+// The window is written from the start of the destination file, and its length is the size of the transfer.
+func (jptm *jobPartTransferMgr) DownloadRange() (offset int64, ranged bool) {
+	return jptm.jobPartMgr.DownloadRange()
+}
+
+// SavedDownload returns how much of the destination file an earlier attempt at the download durably saved, and the modification time of the file then
+func (jptm *jobPartTransferMgr) SavedDownload() (savedLength int64, modTime time.Time) {
+	return jptm.jobPartPlanTransfer.SavedDownload()
+}
+
+// SetSavedDownload records, in the plan file, how much of the destination file is durably saved, so that resuming the job can carry on from there
+func (jptm *jobPartTransferMgr) SetSavedDownload(savedLength int64, modTime time.Time) {
+	jptm.jobPartPlanTransfer.SetSavedDownload(savedLength, modTime)
+}
+
+// DeleteSourceAfterTransfer tells whether the source is to be deleted once the transfer has succeeded
+func (jptm *jobPartTransferMgr) DeleteSourceAfterTransfer() bool {
+	return jptm.jobPartMgr.DeleteSourceAfterTransfer()
+}
+
+// BreakLeaseOnOverwrite tells whether the lease of a destination blob that's in the way of a write, or of its deletion, is broken
+func (jptm *jobPartTransferMgr) BreakLeaseOnOverwrite() bool {
+	return jptm.jobPartMgr.Plan().BreakLeaseOnOverwrite
+}
+
+// ProtectDestinationWithLease tells whether the destination is leased while it's written, so that nothing else can write to it at the same time
+func (jptm *jobPartTransferMgr) ProtectDestinationWithLease() bool {
+	return jptm.jobPartMgr.Plan().ProtectDestinationWithLease
+}
+
+// DestinationCondition is the condition that each write of the whole destination must meet, for the transfer not to be skipped
+func (jptm *jobPartTransferMgr) DestinationCondition() common.DestinationCondition {
+	plan := jptm.jobPartMgr.Plan()
+	c := common.DestinationCondition{IfNoneMatch: plan.DestinationConditionIfNoneMatch}
+	if plan.DestinationConditionIfUnmodifiedSince != 0 {
+		c.IfUnmodifiedSince = time.Unix(0, plan.DestinationConditionIfUnmodifiedSince).UTC()
+	}
+	return c
+}
+
+// ForceIfReadOnly tells whether the ReadOnly attribute of a destination Azure file that's in the way of its overwrite is cleared, and restored afterwards
+func (jptm *jobPartTransferMgr) ForceIfReadOnly() bool {
+	return jptm.jobPartMgr.Plan().ForceIfReadOnly
+}
+
+// SharingViolationRetryWindow is how long the writes to a destination Azure file that something else has open over SMB are retried
+func (jptm *jobPartTransferMgr) SharingViolationRetryWindow() time.Duration {
+	return jptm.jobPartMgr.Plan().SharingViolationRetryWindow
+}
+
+// FlushPolicy is when the downloaded file is flushed to stable storage, and, if it's flushed as it's written, every how many bytes
+func (jptm *jobPartTransferMgr) FlushPolicy() (policy common.FlushPolicy, intervalBytes int64) {
+	plan := jptm.jobPartMgr.Plan()
+	return plan.FlushPolicy, plan.FlushIntervalBytes
+}
+
+// MissingSourceHandling is what happens to the transfer if its source was deleted after it was enumerated
+func (jptm *jobPartTransferMgr) MissingSourceHandling() common.MissingSourceHandling {
+	return jptm.jobPartMgr.Plan().MissingSourceHandling
+}
+
+// SoftDeletedDestinationHandling is what happens to the transfer if its destination blob is soft-deleted
+func (jptm *jobPartTransferMgr) SoftDeletedDestinationHandling() common.SoftDeletedDestinationHandling {
+	return jptm.jobPartMgr.Plan().SoftDeletedDestinationHandling
+}
+
+// SetSoftDeletedDestination records, in the plan file, what was done about the destination being soft-deleted,
+// so that the job's summary counts it, even after the job is resumed
+func (jptm *jobPartTransferMgr) SetSoftDeletedDestination(handling common.SoftDeletedDestinationHandling) {
+	jptm.jobPartPlanTransfer.SetSoftDeletedDestination(handling)
+}
+
+// PreserveSourceLMTAsMetadata tells whether the last modified time of the source is kept in the metadata of the destination
+func (jptm *jobPartTransferMgr) PreserveSourceLMTAsMetadata() bool {
+	return jptm.jobPartMgr.Plan().PreserveSourceLMTAsMetadata
+}
+
+// ShareFullWaitWindow is how long the transfers to an Azure file share that's full wait for its quota to be raised, before they fail
+func (jptm *jobPartTransferMgr) ShareFullWaitWindow() time.Duration {
+	return jptm.jobPartMgr.Plan().ShareFullWaitWindow
+}
+
+// TempNameSuffix returns the suffix of the temporary name that an ADLS Gen2 or Azure Files destination is written under,
+// before it's renamed to its own, or "" if it's written under its own name
+func (jptm *jobPartTransferMgr) TempNameSuffix() string {
+	plan := jptm.jobPartMgr.Plan()
+	return string(plan.TempNameSuffix[:plan.TempNameSuffixLength])
+}
+
+// WasResumed tells whether the job was resumed in this process, rather than started
+func (jptm *jobPartTransferMgr) WasResumed() bool {
+	return jptm.jobPartMgr.WasResumed()
+}
+
+// ClearArchiveBit tells whether the archive attribute of the local source is to be cleared once the upload has succeeded
+func (jptm *jobPartTransferMgr) ClearArchiveBit() bool {
+	return jptm.jobPartMgr.ClearArchiveBit()
+}
+
+// SkipPermissionErrors tells whether a local source that can't be read for lack of permission is skipped, rather than failed
+func (jptm *jobPartTransferMgr) SkipPermissionErrors() bool {
+	return jptm.jobPartMgr.SkipPermissionErrors()
+}
+
+// SkipLockedFiles tells whether a local source that another process holds open is skipped, rather than failed
+func (jptm *jobPartTransferMgr) SkipLockedFiles() bool {
+	return jptm.jobPartMgr.SkipLockedFiles()
+}
+
+// SetSourceDeleted records, in the plan file, that the source was deleted, so that the job's summary counts it, even after the job is resumed
+func (jptm *jobPartTransferMgr) SetSourceDeleted() {
+	jptm.jobPartPlanTransfer.SetSourceDeleted()
+}
+
+// ChecksumHasher returns a new hash for the checksum of the file, or nil unless the job writes or verifies a checksum file
+func (jptm *jobPartTransferMgr) ChecksumHasher() hash.Hash {
+	if m := jptm.checksumManifest(); m != nil {
+		return m.newHash()
+	}
+	return nil
+}
+
+// SetChecksum saves the checksum of the bytes that the transfer read, for VerifyChecksum and RecordChecksum
+func (jptm *jobPartTransferMgr) SetChecksum(digest []byte) {
+	jptm.checksum = digest
+}
+
+// VerifyChecksum checks the checksum of a downloaded file against the one in the checksum file, if the job verifies one
+func (jptm *jobPartTransferMgr) VerifyChecksum() error {
+	m := jptm.checksumManifest()
+	if m == nil || !m.isVerifying() {
+		return nil
+	}
+	return m.verify(checksumRelativePath(jptm, true), jptm.checksumOrEmpty(m))
+}
+
+// RecordChecksum adds the checksum of the file to the checksum file, if the job writes one. Only called once the transfer succeeded
+func (jptm *jobPartTransferMgr) RecordChecksum() {
+	m := jptm.checksumManifest()
+	if m == nil || m.isVerifying() {
+		return
+	}
+	digest := jptm.checksumOrEmpty(m)
+	if digest == nil {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The file is not in the checksum file, since not all of it could be hashed")
+		return
+	}
+	m.record(checksumRelativePath(jptm, false), digest)
+}
+
+// checksumOrEmpty returns the checksum that was set, or the one of no data for an empty file, which has no chunks to hash
+func (jptm *jobPartTransferMgr) checksumOrEmpty(m *checksumManifest) []byte {
+	if jptm.checksum == nil && jptm.Info().SourceSize == 0 {
+		return m.newHash().Sum(nil)
+	}
+	return jptm.checksum
+}
+
+func (jptm *jobPartTransferMgr) checksumManifest() *checksumManifest {
+	return jptm.jobPartMgr.(*jobPartMgr).jobMgr.getChecksumManifest()
+}
+
+// ReportPageBlobDiff adds the changed bytes, and the logical size, of a page blob that is copied incrementally to the job's totals
+func (jptm *jobPartTransferMgr) ReportPageBlobDiff(changedBytes int64, logicalBytes int64) {
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportPageBlobDiff(changedBytes, logicalBytes)
+}
+
 func (jptm *jobPartTransferMgr) ShouldDecompress() bool {
 	if jptm.jobPartMgr.AutoDecompress() {
 		ct, _ := jptm.GetSourceCompressionType()
@@ -230,19 +540,8 @@ func (jptm *jobPartTransferMgr) Info() TransferInfo {
 	}
 
 	sourceSize := plan.Transfer(jptm.transferIndex).SourceSize
-	var blockSize = dstBlobData.BlockSize
-	// If the blockSize is 0, then User didn't provide any blockSize
-	// We need to set the blockSize in such way that number of blocks per blob
-	// does not exceeds 50000 (max number of block per blob)
-	if blockSize == 0 {
-		blockSize = uint32(common.DefaultBlockBlobBlockSize)
-		for ; uint32(sourceSize/int64(blockSize)) > common.MaxNumberOfBlocksPerBlob; blockSize = 2 * blockSize {
-		}
-	}
-	blockSize = common.Iffuint32(blockSize > common.MaxBlockBlobBlockSize, common.MaxBlockBlobBlockSize, blockSize)
-
 	return TransferInfo{
-		BlockSize:                      blockSize,
+		BlockSize:                      effectiveBlockSize(dstBlobData.BlockSize, sourceSize),
 		Source:                         src,
 		SourceSize:                     sourceSize,
 		Destination:                    dst,
@@ -259,6 +558,19 @@ func (jptm *jobPartTransferMgr) Info() TransferInfo {
 	}
 }
 
+// effectiveBlockSize returns the block size that a file of the given size is transferred with, given the one the user asked for, if any
+func effectiveBlockSize(blockSize uint32, sourceSize int64) uint32 {
+	// If the blockSize is 0, then User didn't provide any blockSize
+	// We need to set the blockSize in such way that number of blocks per blob
+	// does not exceeds 50000 (max number of block per blob)
+	if blockSize == 0 {
+		blockSize = uint32(common.DefaultBlockBlobBlockSize)
+		for ; uint32(sourceSize/int64(blockSize)) > common.MaxNumberOfBlocksPerBlob; blockSize = 2 * blockSize {
+		}
+	}
+	return common.Iffuint32(blockSize > common.MaxBlockBlobBlockSize, common.MaxBlockBlobBlockSize, blockSize)
+}
+
 func (jptm *jobPartTransferMgr) Context() context.Context {
 	return jptm.ctx
 }
@@ -347,20 +659,101 @@ func (jptm *jobPartTransferMgr) RescheduleTransfer() {
 	jptm.jobPartMgr.RescheduleTransfer(jptm)
 }
 
+// ScheduleChunks schedules the chunk, which is set aside if it's picked up while the partition of the transfer is throttling
 func (jptm *jobPartTransferMgr) ScheduleChunks(chunkFunc chunkFunc) {
+	jptm.scheduleChunk(currentPartitionThrottle().deferWhileHot(jptm.partitionBucket(), chunkFunc, jptm.scheduleChunk))
+}
+
+// scheduleChunk queues the chunk behind the other chunks of the transfer if the scheduling is fair, and behind those of all the transfers otherwise
+func (jptm *jobPartTransferMgr) scheduleChunk(chunkFunc chunkFunc) {
+	if s := currentFairChunkScheduler(); s != nil {
+		s.schedule(jptm.jobPartMgr.Priority(), jptm, fairWeightOf(jptm.numChunks), chunkFunc)
+		return
+	}
 	jptm.jobPartMgr.ScheduleChunks(chunkFunc)
 }
 
+// partitionBucket is the bucket of the partition that the transfer writes to (or reads from, when it downloads), or "" if it's local
+func (jptm *jobPartTransferMgr) partitionBucket() string {
+	jptm.partitionBucketOnce.Do(func() {
+		fromTo := jptm.FromTo()
+		src, dst := jptm.jobPartMgr.Plan().TransferSrcDstStrings(jptm.transferIndex)
+		remote := ""
+		if fromTo.To().IsRemote() {
+			remote = dst
+		} else if fromTo.From().IsRemote() {
+			remote = src
+		}
+		if u, err := url.Parse(remote); remote != "" && err == nil {
+			jptm.partitionBucketValue = partitionBucketOf(u)
+		}
+	})
+	return jptm.partitionBucketValue
+}
+
 func (jptm *jobPartTransferMgr) BlobDstData(dataFileToXfer []byte) (headers azblob.BlobHTTPHeaders, metadata azblob.Metadata) {
-	return jptm.jobPartMgr.(*jobPartMgr).blobDstData(jptm.Info().Source, dataFileToXfer)
+	headers, metadata = jptm.jobPartMgr.(*jobPartMgr).blobDstData(jptm.Info().Source, dataFileToXfer)
+	own, ownMetadata := jptm.ownHeadersAndMetadata()
+	overrideIfSet(&headers.ContentType, own.ContentType)
+	overrideIfSet(&headers.ContentEncoding, own.ContentEncoding)
+	overrideIfSet(&headers.ContentDisposition, own.ContentDisposition)
+	overrideIfSet(&headers.CacheControl, own.CacheControl)
+	if len(ownMetadata) > 0 {
+		metadata = ownMetadata.ToAzBlobMetadata()
+	}
+	return
 }
 
 func (jptm *jobPartTransferMgr) FileDstData(dataFileToXfer []byte) (headers azfile.FileHTTPHeaders, metadata azfile.Metadata) {
-	return jptm.jobPartMgr.(*jobPartMgr).fileDstData(jptm.Info().Source, dataFileToXfer)
+	headers, metadata = jptm.jobPartMgr.(*jobPartMgr).fileDstData(jptm.Info().Source, dataFileToXfer)
+	own, ownMetadata := jptm.ownHeadersAndMetadata()
+	overrideIfSet(&headers.ContentType, own.ContentType)
+	overrideIfSet(&headers.ContentEncoding, own.ContentEncoding)
+	overrideIfSet(&headers.ContentDisposition, own.ContentDisposition)
+	overrideIfSet(&headers.CacheControl, own.CacheControl)
+	if len(ownMetadata) > 0 {
+		metadata = ownMetadata.ToAzFileMetadata()
+	}
+	return
 }
 
 func (jptm *jobPartTransferMgr) BfsDstData(dataFileToXfer []byte) (headers azbfs.BlobFSHTTPHeaders) {
-	return jptm.jobPartMgr.(*jobPartMgr).bfsDstData(jptm.Info().Source, dataFileToXfer)
+	headers = jptm.jobPartMgr.(*jobPartMgr).bfsDstData(jptm.Info().Source, dataFileToXfer)
+	own, _ := jptm.ownHeadersAndMetadata()
+	overrideIfSet(&headers.ContentType, own.ContentType)
+	overrideIfSet(&headers.ContentEncoding, own.ContentEncoding)
+	overrideIfSet(&headers.ContentDisposition, own.ContentDisposition)
+	overrideIfSet(&headers.CacheControl, own.CacheControl)
+	return
+}
+
+// ownHeadersAndMetadata returns the content headers and metadata of the transfer's destination that replace those of the job,
+// including the content type that was detected, where they are set. They are those of the first header rule that matches the transfer,
+// and then those that the transfer carries itself, when the job has them per file. For an upload, they are the only ones that the plan has for the transfer
+func (jptm *jobPartTransferMgr) ownHeadersAndMetadata() (headers common.ResourceHTTPHeaders, metadata common.Metadata) {
+	if rules := jptm.jobPartMgr.(*jobPartMgr).headerRules; len(rules) > 0 {
+		if rule, _, ok := rules.Match(checksumRelativePath(jptm, true)); ok { // the path of the source, relative to the source
+			headers.ContentType = rule.ContentType
+			headers.CacheControl = rule.CacheControl
+			headers.ContentEncoding = rule.ContentEncoding
+		}
+	}
+
+	if jptm.jobPartMgr.Plan().PerFileAttributes {
+		info := jptm.Info()
+		overrideIfSet(&headers.ContentType, info.SrcHTTPHeaders.ContentType)
+		overrideIfSet(&headers.ContentEncoding, info.SrcHTTPHeaders.ContentEncoding)
+		overrideIfSet(&headers.ContentDisposition, info.SrcHTTPHeaders.ContentDisposition)
+		overrideIfSet(&headers.CacheControl, info.SrcHTTPHeaders.CacheControl)
+		metadata = info.SrcMetadata
+	}
+	return
+}
+
+func overrideIfSet(value *string, override string) {
+	if override != "" {
+		*value = override
+	}
 }
 
 // TODO refactor into something like jptm.IsLastModifiedTimeEqual() so that there is NO LastModifiedTime method and people therefore CAN'T do it wrong due to time zone
@@ -390,6 +783,14 @@ func (jptm *jobPartTransferMgr) DeleteSnapshotsOption() common.DeleteSnapshotsOp
 	return jptm.jobPartMgr.(*jobPartMgr).deleteSnapshotsOption()
 }
 
+func (jptm *jobPartTransferMgr) SetPropertiesFlags() common.SetPropertiesFlags {
+	return jptm.jobPartMgr.(*jobPartMgr).setPropertiesFlags()
+}
+
+func (jptm *jobPartTransferMgr) BlobTags() common.BlobTags {
+	return jptm.jobPartMgr.(*jobPartMgr).BlobTags()
+}
+
 func (jptm *jobPartTransferMgr) BlobTypeOverride() common.BlobType {
 	return jptm.jobPartMgr.BlobTypeOverride()
 }
@@ -430,6 +831,7 @@ func (jptm *jobPartTransferMgr) ReportChunkDone(id common.ChunkID) (lastChunk bo
 	if jptm.IsLive() {
 		atomic.AddInt64(&jptm.atomicSuccessfulBytes, id.Length())
 		JobsAdmin.AddSuccessfulBytesInActiveFiles(id.Length())
+		jptm.jobPartPlanTransfer.AddBytesDone(id.Length())
 	}
 
 	// Do our actual processing
@@ -536,6 +938,9 @@ func (jptm *jobPartTransferMgr) ShouldLog(level pipeline.LogLevel) bool {
 }
 
 func (jptm *jobPartTransferMgr) LogChunkStatus(id common.ChunkID, reason common.WaitReason) {
+	if jptm.metrics != nil {
+		jptm.metrics.recordChunkStatus(reason)
+	}
 	jptm.jobPartMgr.ChunkStatusLogger().LogChunkStatus(id, reason)
 }
 
@@ -623,6 +1028,26 @@ func (jptm *jobPartTransferMgr) failActiveTransfer(typ transferErrorCode, descri
 	//  consider redesign the lifecycle management in ste
 	if !jptm.WasCanceled() {
 		jptm.Cancel()
+		if isDestinationConditionNotMet(jptm, err) {
+			// not a failure: the user asked for destinations like this one to be left as they are
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+				fmt.Sprintf("Skipped, as the destination does not meet the condition %s. When %s", jptm.DestinationCondition(), descriptionOfWhereErrorOccurred))
+			jptm.SetStatus(common.ETransferStatus.SkippedDestinationConditionNotMet())
+			return
+		}
+		if skipsMissingSource(jptm, err) {
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+				fmt.Sprintf("Skipped, as the source was deleted after it was enumerated. When %s", descriptionOfWhereErrorOccurred))
+			jptm.SetStatus(common.ETransferStatus.SkippedSourceDeleted())
+			return
+		}
+		if skipsDestinationBeingDeleted(jptm, err) {
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+				fmt.Sprintf("Skipped, as the destination is being deleted. When %s", descriptionOfWhereErrorOccurred))
+			jptm.SetSoftDeletedDestination(common.ESoftDeletedDestinationHandling.Skip())
+			jptm.SetStatus(common.ETransferStatus.SkippedDestinationSoftDeleted())
+			return
+		}
 		serviceCode, status, msg := ErrorEx{err}.ErrorCodeAndString()
 
 		if serviceCode == common.CPK_ERROR_SERVICE_CODE {
@@ -632,9 +1057,18 @@ func (jptm *jobPartTransferMgr) failActiveTransfer(typ transferErrorCode, descri
 			})
 		}
 
+		if explanation := explainLeasedBlob(jptm, err); explanation != "" {
+			msg = explanation + ". " + msg
+		}
+
 		requestID := ErrorEx{err}.MSRequestID()
+		clientRequestID := ErrorEx{err}.ClientRequestID()
 		fullMsg := fmt.Sprintf("%s. When %s. X-Ms-Request-Id: %s\n", msg, descriptionOfWhereErrorOccurred, requestID) // trailing \n to separate it better from any later, unrelated, log lines
-		jptm.logTransferError(typ, jptm.Info().Source, jptm.Info().Destination, fullMsg, status)
+		if clientRequestID != "" {
+			fullMsg = fmt.Sprintf("%s. When %s. X-Ms-Request-Id: %s. X-Ms-Client-Request-Id: %s\n", msg, descriptionOfWhereErrorOccurred, requestID, clientRequestID)
+			jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportFailedRequest(jptm.jobPartMgr.Plan().PartNum, jptm.transferIndex, clientRequestID)
+		}
+		jptm.logTransferError(typ, jptm.Info().Source, jptm.Info().Destination, fullMsg, serviceCode, status)
 		jptm.SetStatus(failureStatus)
 		jptm.SetErrorCode(int32(status)) // TODO: what are the rules about when this needs to be set, and doesn't need to be (e.g. for earlier failures)?
 		// If the status code was 403, it means there was an authentication error and we exit.
@@ -667,8 +1101,29 @@ func (jptm *jobPartTransferMgr) PipelineLogInfo() pipeline.LogOptions {
 }
 
 func (jptm *jobPartTransferMgr) Log(level pipeline.LogLevel, msg string) {
+	jptm.logWithFields(level, msg, common.LogEntry{})
+}
+
+// logWithFields logs the message, and in the JSON log format also records the given error code and request ID (if any)
+// along with the details of the transfer, each in its own field
+func (jptm *jobPartTransferMgr) logWithFields(level pipeline.LogLevel, msg string, fields common.LogEntry) {
 	plan := jptm.jobPartMgr.Plan()
-	jptm.jobPartMgr.Log(level, fmt.Sprintf("%s: [P#%d-T#%d] ", common.LogLevel(level), plan.PartNum, jptm.transferIndex)+msg)
+	if jptm.jobPartMgr.LogFormat() != common.ELogFormat.Json() {
+		jptm.jobPartMgr.Log(level, fmt.Sprintf("%s: [P#%d-T#%d] ", common.LogLevel(level), plan.PartNum, jptm.transferIndex)+msg)
+		return
+	}
+
+	if !jptm.ShouldLog(level) {
+		return // don't spend time on the fields of an entry that won't be written
+	}
+	partNum, transferIndex := plan.PartNum, jptm.transferIndex
+	src, dst := plan.TransferSrcDstStrings(jptm.transferIndex)
+	fields.PartNum = &partNum
+	fields.TransferIndex = &transferIndex
+	fields.Source = common.URLStringExtension(src).RedactSecretQueryParamForLogging()
+	fields.Destination = common.URLStringExtension(dst).RedactSecretQueryParamForLogging()
+	fields.Message = msg
+	jptm.jobPartMgr.LogStructured(level, fields)
 }
 
 func (jptm *jobPartTransferMgr) ErrorCodeAndString(err error) (int, string) {
@@ -701,23 +1156,26 @@ func (jptm *jobPartTransferMgr) LogAtLevelForCurrentTransfer(level pipeline.LogL
 	jptm.Log(level, fullMsg)
 }
 
-func (jptm *jobPartTransferMgr) logTransferError(errorCode transferErrorCode, source, destination, errorMsg string, status int) {
+// logTransferError logs the failure of the transfer, and counts it for the job. The service code is the one of the storage error
+// that the transfer failed with, if it's known
+func (jptm *jobPartTransferMgr) logTransferError(errorCode transferErrorCode, source, destination, errorMsg, serviceCode string, status int) {
 	// order of log elements here is mirrored, in subset, in LogForCurrentTransfer
 	msg := fmt.Sprintf("%v: ", errorCode) + common.URLStringExtension(source).RedactSecretQueryParamForLogging() +
 		fmt.Sprintf(" : %03d : %s\n   Dst: ", status, errorMsg) + common.URLStringExtension(destination).RedactSecretQueryParamForLogging()
-	jptm.Log(pipeline.LogError, msg)
+	jptm.logWithFields(pipeline.LogError, msg, common.LogEntry{ErrorCode: string(errorCode)})
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.reportTransferFailure(source, destination, errorMsg, serviceCode, status)
 }
 
 func (jptm *jobPartTransferMgr) LogUploadError(source, destination, errorMsg string, status int) {
-	jptm.logTransferError(transferErrorCodeUploadFailed, source, destination, errorMsg, status)
+	jptm.logTransferError(transferErrorCodeUploadFailed, source, destination, errorMsg, "", status)
 }
 
 func (jptm *jobPartTransferMgr) LogDownloadError(source, destination, errorMsg string, status int) {
-	jptm.logTransferError(transferErrorCodeDownloadFailed, source, destination, errorMsg, status)
+	jptm.logTransferError(transferErrorCodeDownloadFailed, source, destination, errorMsg, "", status)
 }
 
 func (jptm *jobPartTransferMgr) LogS2SCopyError(source, destination, errorMsg string, status int) {
-	jptm.logTransferError(transferErrorCodeCopyFailed, source, destination, errorMsg, status)
+	jptm.logTransferError(transferErrorCodeCopyFailed, source, destination, errorMsg, "", status)
 }
 
 // TODO: Log*Error need be further refactored with a seperate workitem.
@@ -734,10 +1192,12 @@ func (jptm *jobPartTransferMgr) LogSendError(source, destination, errorMsg strin
 }
 
 func (jptm *jobPartTransferMgr) LogError(resource, context string, err error) {
-	_, status, msg := ErrorEx{err}.ErrorCodeAndString()
+	serviceCode, status, msg := ErrorEx{err}.ErrorCodeAndString()
 	MSRequestID := ErrorEx{err}.MSRequestID()
-	jptm.Log(pipeline.LogError,
-		fmt.Sprintf("%s: %d: %s-%s. X-Ms-Request-Id:%s\n", common.URLStringExtension(resource).RedactSecretQueryParamForLogging(), status, context, msg, MSRequestID))
+	clientRequestID := ErrorEx{err}.ClientRequestID()
+	jptm.logWithFields(pipeline.LogError,
+		fmt.Sprintf("%s: %d: %s-%s. X-Ms-Request-Id:%s X-Ms-Client-Request-Id:%s\n", common.URLStringExtension(resource).RedactSecretQueryParamForLogging(), status, context, msg, MSRequestID, clientRequestID),
+		common.LogEntry{ErrorCode: serviceCode, RequestID: MSRequestID, ClientRequestID: clientRequestID})
 }
 
 func (jptm *jobPartTransferMgr) LogTransferStart(source, destination, description string) {
@@ -775,9 +1235,18 @@ func (jptm *jobPartTransferMgr) ReportTransferDone() uint32 {
 		panic("cannot report the same transfer done twice")
 	}
 
+	if jptm.metrics != nil {
+		jptm.metrics.recorder.record(jptm)
+	}
+	currentDirectoryLimiter().release(jptm, rescheduleTransfer)
+
 	return jptm.jobPartMgr.ReportTransferDone()
 }
 
 func (jptm *jobPartTransferMgr) SourceProviderPipeline() pipeline.Pipeline {
 	return jptm.jobPartMgr.SourceProviderPipeline()
 }
+
+func (jptm *jobPartTransferMgr) S2SSourceTokenCredential() azblob.TokenCredential {
+	return jptm.jobPartMgr.S2SSourceTokenCredential()
+}
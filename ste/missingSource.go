@@ -0,0 +1,111 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// When the destination can't read the source of a server-side copy, it fails with CannotVerifyCopySource,
+// the status of the read, and, from recent versions of the service, the code that the source gave in this header
+const copySourceErrorCodeHeader = "x-ms-copy-source-error-code"
+
+// sourceGoneErrors are the codes of the errors that each kind of source gives once it has been deleted, each with its message.
+// Only they are taken for the source having been deleted: other 404s aren't, since a request that's not allowed to read the source
+// can get one too, e.g. a request without a valid SAS gets ResourceNotFound from a private Blob Storage container.
+// That's a failure, which the user must find out about
+var sourceGoneErrors = map[common.Location]map[string]string{
+	common.ELocation.Blob(): {
+		string(azblob.ServiceCodeBlobNotFound):      "The specified blob does not exist.",
+		string(azblob.ServiceCodeContainerNotFound): "The specified container does not exist.",
+	},
+	common.ELocation.File(): {
+		string(azfile.ServiceCodeResourceNotFound): "The specified resource does not exist.",
+		string(azfile.ServiceCodeShareNotFound):    "The specified share does not exist.",
+		string(azfile.ServiceCodeParentNotFound):   "The specified parent path does not exist.",
+	},
+	common.ELocation.BlobFS(): {
+		"PathNotFound":       "The specified path does not exist.",
+		"FilesystemNotFound": "The specified filesystem does not exist.",
+	},
+}
+
+// isSourceGone tells whether a request failed because the source, of the given kind, no longer exists.
+// That's so only for a 404 with one of the codes that the source gives once it's been deleted, whether it's the source that
+// gave it, or the destination of a server-side copy that relayed it
+func isSourceGone(source common.Location, err error) bool {
+	codes, ok := sourceGoneErrors[source]
+	if err == nil || !ok {
+		return false
+	}
+	serviceCode, status, _ := ErrorEx{err}.ErrorCodeAndString()
+	if status != http.StatusNotFound {
+		return false
+	}
+	if serviceCode != string(azblob.ServiceCodeCannotVerifyCopySource) {
+		_, gone := codes[serviceCode]
+		return gone
+	}
+
+	if respErr, ok := err.(hasResponse); ok && respErr.Response() != nil {
+		if code := respErr.Response().Header.Get(copySourceErrorCodeHeader); code != "" {
+			_, gone := codes[code]
+			return gone
+		}
+	}
+	// older versions of the service only pass on the message of the source
+	for _, msg := range codes {
+		if strings.Contains(err.Error(), msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipsMissingSource tells whether the transfer failed because its source was deleted after it was enumerated,
+// and the job skips such transfers rather than fails them (--missing-source-handling=skip)
+func skipsMissingSource(jptm IJobPartTransferMgr, err error) bool {
+	if err == nil {
+		return false
+	}
+	fromTo := jptm.FromTo()
+	return jptm.MissingSourceHandling() == common.EMissingSourceHandling.Skip() && isSourceGone(fromTo.From(), err)
+}
+
+// skipIfSourceGone ends the transfer as skipped, if it failed because its source was deleted after it was enumerated,
+// and the job skips such transfers. It tells whether it did.
+// It's for the failures before any chunk is scheduled; failActiveTransfer does the same for those after
+func skipIfSourceGone(jptm IJobPartTransferMgr, err error) bool {
+	if !skipsMissingSource(jptm, err) {
+		return false
+	}
+	jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Skipped, as the source was deleted after it was enumerated-"+err.Error())
+	jptm.SetStatus(common.ETransferStatus.SkippedSourceDeleted())
+	jptm.ReportTransferDone()
+	return true
+}
@@ -0,0 +1,176 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// the number of times that a read of a network source is retried, after the first attempt, before its transfer fails
+const maxNetworkSourceReadRetries = 5
+
+// the wait before the first retry, which doubles for each retry after it, up to networkSourceRetryMaxDelay.
+// A var so that tests needn't wait
+var networkSourceRetryBaseDelay = 2 * time.Second
+
+const networkSourceRetryMaxDelay = 30 * time.Second
+
+var networkSourcePrefixesOnce sync.Once
+var networkSourcePrefixList []string
+
+// networkSourcePrefixes are the paths under which the local sources are on network file systems, from AZCOPY_NETWORK_SOURCE_PREFIXES
+func networkSourcePrefixes() []string {
+	networkSourcePrefixesOnce.Do(func() {
+		networkSourcePrefixList = parseNetworkSourcePrefixes(
+			common.GetLifecycleMgr().GetEnvironmentVariable(common.EEnvironmentVariable.NetworkSourcePrefixes()))
+	})
+	return networkSourcePrefixList
+}
+
+func parseNetworkSourcePrefixes(value string) []string {
+	prefixes := make([]string, 0)
+	for _, prefix := range strings.Split(value, ";") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// isNetworkSourcePath tells whether the local source is read over the network, so that its reads are worth retrying.
+// UNC paths always are, and so are the paths under any of the given prefixes, e.g. the mount points of SMB or NFS shares
+func isNetworkSourcePath(sourcePath string, prefixes []string) bool {
+	if runtime.GOOS == "windows" {
+		if strings.HasPrefix(sourcePath, common.EXTENDED_UNC_PATH_PREFIX) {
+			return true
+		}
+		if strings.HasPrefix(sourcePath, `\\`) && !strings.HasPrefix(sourcePath, common.EXTENDED_PATH_PREFIX) && !strings.HasPrefix(sourcePath, `\\.\`) {
+			return true
+		}
+	}
+
+	sourcePath = common.ToShortPath(sourcePath)
+	for _, prefix := range prefixes {
+		if runtime.GOOS == "windows" {
+			if strings.HasPrefix(strings.ToLower(sourcePath), strings.ToLower(prefix)) {
+				return true
+			}
+		} else if strings.HasPrefix(sourcePath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// networkSourceFile is a local source on a network share or mount, whose reads are retried when the share drops:
+// the file is closed, and once the share may be back, reopened and read again at the same offset.
+// Its reads are serialized, which costs nothing, since the chunks of a file are read one after the other
+type networkSourceFile struct {
+	jptm IJobPartTransferMgr
+	open common.ChunkReaderSourceFactory
+
+	lock sync.Mutex
+	file common.CloseableReaderAt // nil while the file has to be reopened
+}
+
+// retryingNetworkReads wraps the opener of a network source, so that the files it opens retry their reads
+func retryingNetworkReads(jptm IJobPartTransferMgr, open common.ChunkReaderSourceFactory) common.ChunkReaderSourceFactory {
+	return func() (common.CloseableReaderAt, error) {
+		f := &networkSourceFile{jptm: jptm, open: open}
+
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		if err := f.withRetries(func(common.CloseableReaderAt) error { return nil }); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+}
+
+func (f *networkSourceFile) ReadAt(p []byte, off int64) (n int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	err = f.withRetries(func(file common.CloseableReaderAt) error {
+		n, err = file.ReadAt(p, off)
+		return err
+	})
+	return n, err
+}
+
+func (f *networkSourceFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// withRetries (re)opens the file if it has to, and runs the operation on it,
+// retrying both, with backoff, for as long as they fail with network errors and there are retries left
+func (f *networkSourceFile) withRetries(operation func(file common.CloseableReaderAt) error) error {
+	delay := networkSourceRetryBaseDelay
+	for retry := 1; ; retry++ {
+		var err error
+		if f.file == nil {
+			var file common.CloseableReaderAt
+			if file, err = f.open(); err == nil { // not assigned on failure, since a failed os.Open returns a nil *os.File, which isn't a nil interface
+				f.file = file
+			}
+		}
+		if err == nil {
+			err = operation(f.file)
+		}
+		if err == nil || !isNetworkReadError(err) || retry > maxNetworkSourceReadRetries {
+			return err
+		}
+
+		if f.file != nil {
+			_ = f.file.Close() // the handle is of no use once the share has dropped
+			f.file = nil
+		}
+		f.jptm.ReportSourceReadRetry()
+		f.jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+			fmt.Sprintf("Reading the source failed (%s), so it will be reopened and read again in %v (retry %d of %d)",
+				err, delay, retry, maxNetworkSourceReadRetries))
+
+		select {
+		case <-time.After(delay):
+		case <-f.jptm.Context().Done():
+			return err
+		}
+		if delay *= 2; delay > networkSourceRetryMaxDelay {
+			delay = networkSourceRetryMaxDelay
+		}
+	}
+}
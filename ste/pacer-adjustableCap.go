@@ -0,0 +1,106 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// adjustableCapPacer is the application-wide pacer. It doesn't control the rate until a cap is set,
+// either from the command line or, while the job runs, through the job's control endpoint.
+// Like the nullAutoPacer, it always records the total throughput.
+type adjustableCapPacer struct {
+	atomicGrandTotal int64
+	lock             sync.Mutex   // serializes changes of the cap
+	bucket           atomic.Value // *tokenBucketPacer, created the first time a cap is set, and kept after that since it's cheap when idle
+}
+
+func newAdjustableCapPacer(bytesPerSecond int64) *adjustableCapPacer {
+	p := &adjustableCapPacer{}
+	p.setTargetBytesPerSecond(bytesPerSecond)
+	return p
+}
+
+// cappingBucket returns the bucket that paces the traffic, or nil if the traffic isn't capped right now
+func (p *adjustableCapPacer) cappingBucket() *tokenBucketPacer {
+	b, _ := p.bucket.Load().(*tokenBucketPacer)
+	if b == nil || b.targetBytesPerSecond() <= 0 {
+		return nil
+	}
+	return b
+}
+
+// setTargetBytesPerSecond changes the cap. Zero removes it
+func (p *adjustableCapPacer) setTargetBytesPerSecond(bytesPerSecond int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if b, _ := p.bucket.Load().(*tokenBucketPacer); b != nil {
+		b.setTargetBytesPerSecond(bytesPerSecond)
+		return
+	}
+	if bytesPerSecond > 0 {
+		unusedExpectedCoarseRequestByteCount := uint32(0)
+		p.bucket.Store(newTokenBucketPacer(bytesPerSecond, unusedExpectedCoarseRequestByteCount))
+	}
+}
+
+func (p *adjustableCapPacer) targetBytesPerSecond() int64 {
+	if b := p.cappingBucket(); b != nil {
+		return b.targetBytesPerSecond()
+	}
+	return 0
+}
+
+func (p *adjustableCapPacer) RequestTrafficAllocation(ctx context.Context, byteCount int64) error {
+	if b := p.cappingBucket(); b != nil {
+		if err := b.RequestTrafficAllocation(ctx, byteCount); err != nil {
+			return err
+		}
+	}
+	atomic.AddInt64(&p.atomicGrandTotal, byteCount)
+	return nil
+}
+
+func (p *adjustableCapPacer) UndoRequest(byteCount int64) {
+	if b := p.cappingBucket(); b != nil {
+		b.UndoRequest(byteCount)
+	}
+	if byteCount > 0 {
+		atomic.AddInt64(&p.atomicGrandTotal, -byteCount)
+	}
+}
+
+func (p *adjustableCapPacer) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if b, _ := p.bucket.Load().(*tokenBucketPacer); b != nil {
+		return b.Close()
+	}
+	return nil
+}
+
+func (p *adjustableCapPacer) GetTotalTraffic() int64 {
+	return atomic.LoadInt64(&p.atomicGrandTotal)
+}
@@ -0,0 +1,193 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+const (
+	// transactionTokens is the number of tokens that each transaction takes from the bucket. The bucket is refilled
+	// ten times a second, so with one token per transaction, a cap below ten per second would never release a whole one
+	transactionTokens = 1000
+
+	// the cap is lowered, by a fifth, at most this often while the service is throttling...
+	transactionCapBackoffInterval = time.Second
+	// ...and raised again, by a tenth, each time this long goes by without throttling, until it's back to what the user asked for
+	transactionCapRecoveryInterval = 10 * time.Second
+)
+
+// transactionPacer caps the number of REST operations per second, across all the transfers of all jobs, so that many small
+// files don't go over the request rate that the account, or its partitions, can take. It's separate from the pacer that caps
+// the bytes per second. When the service throttles the requests anyway, it lowers the cap, and it raises it again
+// when the throttling stops, but never above the cap that the user asked for.
+type transactionPacer struct {
+	atomicGrantedCount       int64
+	atomicFirstGrantNanos    int64
+	atomicLastBackoffNanos   int64
+	atomicLastThrottledNanos int64
+	atomicCurrentCap         int64
+	userCap                  int64
+	minCap                   int64
+	bucket                   *tokenBucketPacer
+	done                     chan struct{}
+}
+
+func newTransactionPacer(capTransactionsPerSecond int64) *transactionPacer {
+	minCap := capTransactionsPerSecond / 10
+	if minCap < 1 {
+		minCap = 1
+	}
+	t := &transactionPacer{
+		atomicCurrentCap: capTransactionsPerSecond,
+		userCap:          capTransactionsPerSecond,
+		minCap:           minCap,
+		bucket:           newTokenBucketPacer(capTransactionsPerSecond*transactionTokens, transactionTokens),
+		done:             make(chan struct{}),
+	}
+	go t.recoveryLoop()
+	return t
+}
+
+// waitForTransaction blocks until the caller may send a request
+func (t *transactionPacer) waitForTransaction(ctx context.Context) error {
+	if err := t.bucket.RequestTrafficAllocation(ctx, transactionTokens); err != nil {
+		return err
+	}
+	if atomic.AddInt64(&t.atomicGrantedCount, 1) == 1 {
+		atomic.StoreInt64(&t.atomicFirstGrantNanos, time.Now().UnixNano())
+	}
+	return nil
+}
+
+// recordThrottled lowers the cap, if it wasn't lowered in the last second, since the service says that it gets too many requests
+func (t *transactionPacer) recordThrottled(now time.Time) {
+	atomic.StoreInt64(&t.atomicLastThrottledNanos, now.UnixNano())
+	last := atomic.LoadInt64(&t.atomicLastBackoffNanos)
+	if now.UnixNano()-last < int64(transactionCapBackoffInterval) || !atomic.CompareAndSwapInt64(&t.atomicLastBackoffNanos, last, now.UnixNano()) {
+		return
+	}
+
+	lowered := t.currentCap() * 4 / 5
+	if lowered < t.minCap {
+		lowered = t.minCap
+	}
+	t.setCap(lowered)
+}
+
+// recover raises the cap again, towards what the user asked for, if there was no throttling in the last interval
+func (t *transactionPacer) recover(now time.Time) {
+	current := t.currentCap()
+	if current >= t.userCap || now.UnixNano()-atomic.LoadInt64(&t.atomicLastThrottledNanos) < int64(transactionCapRecoveryInterval) {
+		return
+	}
+
+	raised := current + current/10
+	if raised == current {
+		raised++
+	}
+	if raised > t.userCap {
+		raised = t.userCap
+	}
+	t.setCap(raised)
+}
+
+func (t *transactionPacer) recoveryLoop() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case now := <-time.After(transactionCapRecoveryInterval):
+			t.recover(now)
+		}
+	}
+}
+
+func (t *transactionPacer) currentCap() int64 {
+	return atomic.LoadInt64(&t.atomicCurrentCap)
+}
+
+func (t *transactionPacer) setCap(transactionsPerSecond int64) {
+	atomic.StoreInt64(&t.atomicCurrentCap, transactionsPerSecond)
+	t.bucket.setTargetBytesPerSecond(transactionsPerSecond * transactionTokens)
+}
+
+// averageTransactionsPerSecond returns the rate at which requests were sent, since the first of them
+func (t *transactionPacer) averageTransactionsPerSecond() float64 {
+	first := atomic.LoadInt64(&t.atomicFirstGrantNanos)
+	if first == 0 {
+		return 0
+	}
+	elapsed := time.Since(time.Unix(0, first)).Seconds()
+	if elapsed < 1 {
+		elapsed = 1 // don't report a huge rate for the first burst
+	}
+	return float64(atomic.LoadInt64(&t.atomicGrantedCount)) / elapsed
+}
+
+func (t *transactionPacer) Close() error {
+	close(t.done)
+	return t.bucket.Close()
+}
+
+// newTransactionPacerPolicyFactory makes each try of each request wait for the transaction pacer, if the user capped the transactions per second.
+// Since it's below the retry policy, the retries count as transactions too, as they do for the service.
+func newTransactionPacerPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			t := currentTransactionPacer(ctx)
+			if t == nil {
+				return next.Do(ctx, request)
+			}
+			if err := t.waitForTransaction(ctx); err != nil {
+				return nil, err
+			}
+
+			resp, err := next.Do(ctx, request)
+			if resp != nil {
+				// when the service is busy because of the bandwidth, rather than the request rate, sending fewer requests doesn't help
+				if rr := resp.Response(); rr != nil && rr.StatusCode == http.StatusServiceUnavailable &&
+					!strings.Contains(transparentlyReadBody(rr), "gress is over the account limit") {
+					t.recordThrottled(time.Now())
+				}
+			}
+			return resp, err
+		}
+	})
+}
+
+// currentTransactionPacer returns the pacer of the transactions per second of the request, or nil if they are not capped.
+// The requests of the transfers that move no data have a pacer of their own, if their transactions are capped on their own
+func currentTransactionPacer(ctx context.Context) *transactionPacer {
+	if ja, ok := JobsAdmin.(*jobsAdmin); ok {
+		if ja.metadataTransactionPacer != nil && isMetadataOnlyTransfer(ctx) {
+			return ja.metadataTransactionPacer
+		}
+		return ja.transactionPacer
+	}
+	return nil
+}
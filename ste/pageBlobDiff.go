@@ -0,0 +1,132 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// pageBlobDiff holds the ranges of a page blob that changed since a base snapshot, for an incremental copy into a destination
+// that already holds the base snapshot. The changed ranges are copied, and the cleared ones are cleared at the destination.
+type pageBlobDiff struct {
+	baseSnapshot string
+	baseLength   int64
+	baseMD5      []byte // nil if the base snapshot has none
+	changed      []azblob.PageRange
+	cleared      []azblob.ClearRange
+}
+
+var errNotAPageBlob = errors.New("only page blobs can be copied incrementally from a base snapshot")
+
+// getPageBlobDiff gets the ranges of the source that differ from its base snapshot. The source may itself be a snapshot.
+func getPageBlobDiff(ctx context.Context, source azblob.PageBlobURL, baseSnapshot string) (*pageBlobDiff, error) {
+	ctx = context.WithValue(ctx, ServiceAPIVersionOverride, azblob.ServiceVersion)
+
+	props, err := source.WithSnapshot(baseSnapshot).GetProperties(ctx, azblob.BlobAccessConditions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the properties of the base snapshot %s: %s", baseSnapshot, err.Error())
+	}
+	if props.BlobType() != azblob.BlobPageBlob {
+		return nil, errNotAPageBlob
+	}
+
+	list, err := source.GetPageRangesDiff(ctx, 0, 0, baseSnapshot, azblob.BlobAccessConditions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the page ranges that changed since the base snapshot %s: %s", baseSnapshot, err.Error())
+	}
+
+	return &pageBlobDiff{
+		baseSnapshot: baseSnapshot,
+		baseLength:   props.ContentLength(),
+		baseMD5:      props.ContentMD5(),
+		changed:      list.PageRange,
+		cleared:      list.ClearRange,
+	}, nil
+}
+
+// validateDestination checks that the destination holds the content of the base snapshot, as far as its length and,
+// if both of them have one, its MD5 hash tell
+func (d *pageBlobDiff) validateDestination(destLength int64, destMD5 []byte) error {
+	if destLength != d.baseLength {
+		return fmt.Errorf("the destination is %d bytes long, but the base snapshot %s is %d bytes long, so the destination does not hold the base snapshot. "+
+			"Copy the base snapshot to it first, or copy the whole blob without --diff-base-snapshot", destLength, d.baseSnapshot, d.baseLength)
+	}
+	if len(destMD5) > 0 && len(d.baseMD5) > 0 && !bytes.Equal(destMD5, d.baseMD5) {
+		return fmt.Errorf("the MD5 hash of the destination is not that of the base snapshot %s, so the destination does not hold the base snapshot", d.baseSnapshot)
+	}
+	return nil
+}
+
+// changedRangesIn returns the parts of the changed ranges that fall within the given range
+func (d *pageBlobDiff) changedRangesIn(offset int64, count int64) []azblob.PageRange {
+	end := offset + count - 1
+	var ranges []azblob.PageRange
+	// the list is sorted by position
+	for _, r := range d.changed {
+		if r.Start > end {
+			break
+		}
+		if r.End < offset {
+			continue
+		}
+		ranges = append(ranges, azblob.PageRange{Start: maxInt64(r.Start, offset), End: minInt64(r.End, end)})
+	}
+	return ranges
+}
+
+// isUnchangedIn tells whether nothing in the given range, including the clearing of pages, changed since the base snapshot
+func (d *pageBlobDiff) isUnchangedIn(offset int64, count int64) bool {
+	end := offset + count - 1
+	for _, r := range d.cleared {
+		if r.Start <= end && r.End >= offset {
+			return false
+		}
+	}
+	return len(d.changedRangesIn(offset, count)) == 0
+}
+
+// changedBytes is the number of bytes in the changed ranges, which is what needs to be copied
+func (d *pageBlobDiff) changedBytes() int64 {
+	var n int64
+	for _, r := range d.changed {
+		n += r.End - r.Start + 1
+	}
+	return n
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
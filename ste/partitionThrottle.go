@@ -0,0 +1,240 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+const (
+	// partitionPrefixLength is how many characters of the name of a blob or file, after its container, tell which bucket it's in.
+	// The service partitions the names of a container by ranges, so names that share a prefix, such as those that start
+	// with a timestamp, tend to land in the same partition
+	partitionPrefixLength = 8
+
+	// a bucket is hot for this long after its first ServerBusy...
+	partitionBaseCooldown = 2 * time.Second
+	// ...and twice as long each time it's still busy after cooling down, up to this long
+	partitionMaxCooldown = 30 * time.Second
+
+	// how often the chunks of the buckets that cooled down are scheduled again
+	partitionReleaseInterval = 250 * time.Millisecond
+)
+
+// partitionThrottle keeps the chunks of transfers whose partition is throttling away from the chunk processors,
+// so that the other transfers keep the processors busy while the partition recovers, rather than everything backing off at once.
+// The partition of a transfer is approximated by a bucket: the account, the container and the start of the name of its remote side.
+// A bucket is hot for a while after a ServerBusy (503) response to a request in it, and the chunks of its transfers that
+// are picked up while it's hot are set aside until it cools down, when they're scheduled again.
+type partitionThrottle struct {
+	atomicThrottleEvents uint64
+	atomicDeferredChunks uint64
+
+	lock    sync.Mutex
+	buckets map[string]*partitionBucket
+	done    chan struct{}
+	now     func() time.Time // so that the tests needn't wait for the buckets to cool down
+}
+
+type partitionBucket struct {
+	hotUntil time.Time
+	// the number of times the bucket got hot since it last answered a request, which sets how long it stays hot
+	consecutiveBusy uint
+	deferred        []deferredChunk
+}
+
+type deferredChunk struct {
+	chunk    chunkFunc
+	schedule func(chunkFunc)
+}
+
+func newPartitionThrottle() *partitionThrottle {
+	return &partitionThrottle{buckets: make(map[string]*partitionBucket), done: make(chan struct{}), now: time.Now}
+}
+
+// partitionBucketOf returns the bucket of the blob, file or directory at the URL
+func partitionBucketOf(u *url.URL) string {
+	path := strings.TrimPrefix(u.Path, "/")
+	container, name := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		container, name = path[:i], path[i+1:]
+	}
+	if len(name) > partitionPrefixLength {
+		name = name[:partitionPrefixLength]
+	}
+	return u.Host + "/" + container + "/" + name
+}
+
+// recordResponse makes the bucket hot if the service said it's busy, and resets the cooldown once it answers a request again
+func (t *partitionThrottle) recordResponse(bucket string, statusCode int, now time.Time) {
+	if bucket == "" {
+		return
+	}
+	busy := statusCode == http.StatusServiceUnavailable
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	b, ok := t.buckets[bucket]
+	if !ok {
+		if !busy {
+			return // nothing to remember about the buckets that never throttled
+		}
+		b = &partitionBucket{}
+		t.buckets[bucket] = b
+	}
+
+	if !busy {
+		b.consecutiveBusy = 0
+		return
+	}
+
+	atomic.AddUint64(&t.atomicThrottleEvents, 1)
+	if now.Before(b.hotUntil) {
+		return // the requests that were in flight when it got hot don't make it any hotter
+	}
+
+	// it's still busy after cooling down, so it's given longer this time
+	cooldown := partitionBaseCooldown << b.consecutiveBusy
+	if cooldown >= partitionMaxCooldown {
+		cooldown = partitionMaxCooldown
+	} else {
+		b.consecutiveBusy++
+	}
+	b.hotUntil = now.Add(cooldown)
+}
+
+// deferIfHot sets the chunk aside, to be scheduled again with schedule once its bucket cools down, if the bucket is hot
+func (t *partitionThrottle) deferIfHot(bucket string, chunk chunkFunc, schedule func(chunkFunc), now time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	b, ok := t.buckets[bucket]
+	if !ok || !now.Before(b.hotUntil) {
+		return false
+	}
+	b.deferred = append(b.deferred, deferredChunk{chunk: chunk, schedule: schedule})
+	atomic.AddUint64(&t.atomicDeferredChunks, 1)
+	return true
+}
+
+// releaseCooled returns the chunks that were set aside for the buckets that are no longer hot
+func (t *partitionThrottle) releaseCooled(now time.Time) []deferredChunk {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var released []deferredChunk
+	for name, b := range t.buckets {
+		if now.Before(b.hotUntil) {
+			continue
+		}
+		released = append(released, b.deferred...)
+		b.deferred = nil
+		if b.consecutiveBusy == 0 {
+			delete(t.buckets, name) // it has recovered, so there's nothing more to remember about it
+		}
+	}
+	return released
+}
+
+func (t *partitionThrottle) releaseLoop() {
+	ticker := time.NewTicker(partitionReleaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			for _, d := range t.releaseCooled(t.now()) {
+				d.schedule(d.chunk)
+			}
+		}
+	}
+}
+
+// deferWhileHot wraps the chunk of a transfer in the bucket, so that it's set aside rather than run if it's picked up while the bucket is hot.
+// When it's scheduled again, it checks again, since the bucket may have become hot again in the meantime
+func (t *partitionThrottle) deferWhileHot(bucket string, chunk chunkFunc, schedule func(chunkFunc)) chunkFunc {
+	if t == nil || bucket == "" {
+		return chunk
+	}
+
+	var wrapped chunkFunc
+	wrapped = func(workerID int) {
+		if t.deferIfHot(bucket, wrapped, schedule, t.now()) {
+			return
+		}
+		chunk(workerID)
+	}
+	return wrapped
+}
+
+// throttleEvents is the number of ServerBusy responses that made, or kept, a bucket hot
+func (t *partitionThrottle) throttleEvents() uint64 {
+	if t == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&t.atomicThrottleEvents)
+}
+
+// deferredChunks is the number of times that a chunk was set aside because its bucket was hot
+func (t *partitionThrottle) deferredChunks() uint64 {
+	if t == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&t.atomicDeferredChunks)
+}
+
+func (t *partitionThrottle) Close() {
+	close(t.done)
+}
+
+// newPartitionThrottlePolicyFactory records the outcome of each try of each request in the bucket of its URL, if there's a throttle.
+// Since it's below the retry policy, each ServerBusy counts, even those that a retry got past.
+func newPartitionThrottlePolicyFactory(t *partitionThrottle) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			resp, err := next.Do(ctx, request)
+			if t != nil && resp != nil {
+				if rr := resp.Response(); rr != nil {
+					t.recordResponse(partitionBucketOf(request.URL), rr.StatusCode, t.now())
+				}
+			}
+			return resp, err
+		}
+	})
+}
+
+// currentPartitionThrottle returns the throttle of the partitions of the process, or nil if there's no engine, e.g. in tests
+func currentPartitionThrottle() *partitionThrottle {
+	if ja, ok := JobsAdmin.(*jobsAdmin); ok {
+		return ja.partitionThrottle
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// planKey encrypts the strings of the job part plans, i.e. the roots, the command and the paths and properties of the transfers,
+// so that the plan files don't give away the names and locations of what a job moves to whoever can read them.
+//
+// The strings are encrypted with AES-256 in counter mode, and the counter is derived from the offset of each byte in the plan file.
+// That way a plan is encrypted as one stream when it's written, and any string of the memory mapped plan can still be decrypted on its own.
+// Each part has its own random IV, which is kept in its header along with a check value of the key, so that a missing or wrong key is told apart from a corrupt plan.
+type planKey struct {
+	block    cipher.Block
+	keyCheck [planKeyCheckLength]byte
+}
+
+const planKeyCheckLength = 16
+
+var errPlanKeyRequired = errors.New("encrypted plan, key required: set " + common.EEnvironmentVariable.PlanEncryptionKey().Name +
+	" to the key that the job was created with")
+var errPlanKeyMismatch = errors.New("encrypted plan, and " + common.EEnvironmentVariable.PlanEncryptionKey().Name +
+	" is not the key that the job was created with")
+
+// newPlanKey derives the key from the secret. The secret is expected to be long and random, e.g. 32 random bytes in base64, rather than a password
+func newPlanKey(secret string) *planKey {
+	if secret == "" {
+		return nil
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	common.PanicIfErr(err)
+
+	k := &planKey{block: block}
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte("azcopy job part plan key check"))
+	copy(k.keyCheck[:], mac.Sum(nil))
+	return k
+}
+
+var planKeyOnce sync.Once
+var currentPlanKeyValue *planKey
+
+// currentPlanKey is the key from AZCOPY_PLAN_ENCRYPTION_KEY, or nil if the plans aren't to be encrypted
+func currentPlanKey() *planKey {
+	planKeyOnce.Do(func() {
+		currentPlanKeyValue = newPlanKey(common.GetLifecycleMgr().GetEnvironmentVariable(common.EEnvironmentVariable.PlanEncryptionKey()))
+	})
+	return currentPlanKeyValue
+}
+
+func newPlanIV() (iv [aes.BlockSize]byte) {
+	_, err := io.ReadFull(rand.Reader, iv[:])
+	common.PanicIfErr(err)
+	return iv
+}
+
+// streamAt returns the key stream of the plan with the given IV, from the given offset in the plan file onwards
+func (k *planKey) streamAt(iv [aes.BlockSize]byte, offset int64) cipher.Stream {
+	// the counter block of the offset is the IV plus the number of blocks before it, with the carry from the low half of the IV to the high half
+	counter := iv
+	low := binary.BigEndian.Uint64(counter[8:])
+	blocks := uint64(offset) / aes.BlockSize
+	binary.BigEndian.PutUint64(counter[8:], low+blocks)
+	if low+blocks < low {
+		binary.BigEndian.PutUint64(counter[:8], binary.BigEndian.Uint64(counter[:8])+1)
+	}
+
+	stream := cipher.NewCTR(k.block, counter[:])
+	if skip := offset % aes.BlockSize; skip != 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	return stream
+}
+
+// CheckPlanKey returns the error that stops the strings of the plan from being read, if it's encrypted with a key other than the current one
+func (jpph *JobPartPlanHeader) CheckPlanKey() error {
+	return jpph.checkPlanKey(currentPlanKey())
+}
+
+func (jpph *JobPartPlanHeader) checkPlanKey(key *planKey) error {
+	if !jpph.StringsEncrypted {
+		return nil
+	}
+	if key == nil {
+		return errPlanKeyRequired
+	}
+	if !bytes.Equal(jpph.PlanKeyCheck[:], key.keyCheck[:]) {
+		return errPlanKeyMismatch
+	}
+	return nil
+}
+
+// planStringWriter writes the strings of a plan, encrypting them if the plan is encrypted.
+// It reuses its buffer, so that writing the strings of a part with many transfers takes no more allocations than writing them in plain text does
+type planStringWriter struct {
+	w      io.Writer
+	stream cipher.Stream
+	buf    []byte
+}
+
+func (w *planStringWriter) WriteString(s string) (int, error) {
+	if w.stream == nil {
+		return io.WriteString(w.w, s)
+	}
+
+	if cap(w.buf) < len(s) {
+		w.buf = make([]byte, len(s))
+	}
+	buf := w.buf[:len(s)]
+	copy(buf, s)
+	w.stream.XORKeyStream(buf, buf)
+	return w.w.Write(buf)
+}
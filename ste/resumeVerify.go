@@ -0,0 +1,363 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// the number of destinations that are checked at once, when a job is resumed with --resume-verify
+const resumeVerifyConcurrency = 32
+
+// a failed transfer only counts as done if its destination was written after the job started,
+// give or take this much, since the clock of the service needn't agree with ours
+const resumeVerifyClockSkew = 5 * time.Minute
+
+// destinationState is what a HEAD request (or, for a local destination, a stat) found at the destination of a transfer
+type destinationState struct {
+	exists       bool
+	length       int64
+	lastModified time.Time
+	// nil if the destination has no MD5 hash, or it wasn't asked for
+	md5 []byte
+}
+
+// destinationChecker finds out the state of one destination. It's only asked for the MD5 hash of a local destination when it's needed,
+// since that takes reading the file
+type destinationChecker func(ctx context.Context, destination string, wantMD5 bool) (destinationState, error)
+
+// resumedTransfer is a transfer, in a terminal state, whose destination is checked before the job is resumed
+type resumedTransfer struct {
+	plan        *JobPartPlanHeader
+	index       uint32
+	source      string
+	destination string
+	jobStart    time.Time
+}
+
+// verifyResumedTransfers checks the destination of each transfer that the plan says succeeded or failed, and corrects the plan where it's wrong:
+// a transfer whose destination is gone, or is of the wrong length, is done again, and a failed transfer whose destination was written in full
+// (by the job) after all counts as done. With strict, the MD5 hashes are compared too, when both sides have one, and a failed transfer
+// only counts as done if they match. It returns how many statuses were corrected each way, and how many destinations couldn't be checked.
+func verifyResumedTransfers(ctx context.Context, jm IJobMgr, credInfo common.CredentialInfo, strict bool) (toSuccess, toRetry, notVerified uint32) {
+	jpm0, found := jm.JobPartMgr(0)
+	if !found {
+		return 0, 0, 0
+	}
+	fromTo := jpm0.Plan().FromTo
+	srcSAS, dstSAS := jpm0.(*jobPartMgr).SAS()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	check := newDestinationChecker(ctx, jm, fromTo, credInfo)
+	if check == nil {
+		return 0, 0, 0 // nothing is written anywhere, e.g. when deleting
+	}
+
+	transfers := make([]resumedTransfer, 0)
+	jm.(*jobMgr).jobPartMgrs.Iterate(true, func(partNum common.PartNumber, jpm IJobPartMgr) {
+		plan := jpm.Plan()
+		for t := uint32(0); t < plan.NumTransfers; t++ {
+			status := plan.Transfer(t).TransferStatus()
+			if status != common.ETransferStatus.Success() && status > common.ETransferStatus.Failed() {
+				continue
+			}
+			src, dst := plan.TransferSrcDstStrings(t)
+			transfers = append(transfers, resumedTransfer{
+				plan:        plan,
+				index:       t,
+				source:      appendSAS(src, srcSAS),
+				destination: appendSAS(dst, dstSAS),
+				jobStart:    time.Unix(0, plan.StartTime),
+			})
+		}
+	})
+
+	var checked uint32
+	showProgress := func() {
+		progress := resumeVerifyProgress{
+			Checked:           atomic.LoadUint32(&checked),
+			Total:             uint32(len(transfers)),
+			CorrectedToDone:   atomic.LoadUint32(&toSuccess),
+			CorrectedToRetry:  atomic.LoadUint32(&toRetry),
+			DestinationErrors: atomic.LoadUint32(&notVerified),
+		}
+		common.GetLifecycleMgr().Progress(progress.output)
+	}
+
+	work := make(chan resumedTransfer)
+	wg := sync.WaitGroup{}
+	for i := 0; i < resumeVerifyConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range work {
+				switch verifyResumedTransfer(ctx, jm, t, check, strict) {
+				case common.ETransferStatus.Success():
+					atomic.AddUint32(&toSuccess, 1)
+				case common.ETransferStatus.Started():
+					atomic.AddUint32(&toRetry, 1)
+				case common.ETransferStatus.Failed():
+					atomic.AddUint32(&notVerified, 1)
+				}
+				atomic.AddUint32(&checked, 1)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Second):
+				showProgress()
+			}
+		}
+	}()
+	for _, t := range transfers {
+		work <- t
+	}
+	close(work)
+	wg.Wait()
+	close(done)
+	showProgress()
+
+	return toSuccess, toRetry, notVerified
+}
+
+// verifyResumedTransfer checks the destination of the transfer, and corrects its status if that's wrong.
+// It returns the status it set, NotStarted if it left the status as it was, or Failed if the destination couldn't be checked.
+func verifyResumedTransfer(ctx context.Context, jm IJobMgr, t resumedTransfer, check destinationChecker, strict bool) common.TransferStatus {
+	jppt := t.plan.Transfer(t.index)
+	status := jppt.TransferStatus()
+
+	var expectedMD5 []byte
+	if strict {
+		expectedMD5 = expectedSourceMD5(t)
+	}
+	state, err := check(ctx, t.destination, expectedMD5 != nil)
+	if err != nil {
+		jm.Log(pipeline.LogWarning, fmt.Sprintf("Could not verify the destination %s before resuming: %s", common.URLStringExtension(t.destination).RedactSecretQueryParamForLogging(), err))
+		return common.ETransferStatus.Failed()
+	}
+
+	corrected := correctedTransferStatus(status, jppt.SourceSize, !t.plan.AutoDecompress, expectedMD5, state, t.jobStart, strict)
+	if corrected == status {
+		return common.ETransferStatus.NotStarted()
+	}
+
+	jppt.SetTransferStatus(corrected, true)
+	jppt.SetErrorCode(0, true)
+	jm.Log(pipeline.LogInfo, fmt.Sprintf("Corrected the status of %s from %v to %v, by its destination", common.URLStringExtension(t.destination).RedactSecretQueryParamForLogging(), status, corrected))
+	return corrected
+}
+
+// correctedTransferStatus returns what the status of a transfer should be, given what is at its destination.
+// The length is only compared if checkLength is set, since it needn't be that of the source, e.g. when it's decompressed.
+func correctedTransferStatus(status common.TransferStatus, sourceSize int64, checkLength bool, expectedMD5 []byte, state destinationState, jobStart time.Time, strict bool) common.TransferStatus {
+	lengthMatches := !checkLength || state.length == sourceSize
+	md5Known := expectedMD5 != nil && state.md5 != nil
+	md5Matches := !md5Known || bytes.Equal(expectedMD5, state.md5)
+
+	if status == common.ETransferStatus.Success() {
+		if !state.exists || !lengthMatches || !md5Matches {
+			return common.ETransferStatus.Started() // copy it again
+		}
+		return status
+	}
+
+	// a failed transfer. Its destination may have been written in full, and only the reporting of that failed
+	writtenByJob := state.exists && !state.lastModified.Before(jobStart.Add(-resumeVerifyClockSkew))
+	if writtenByJob && lengthMatches && md5Matches && (!strict || md5Known) {
+		return common.ETransferStatus.Success()
+	}
+	return status
+}
+
+// expectedSourceMD5 returns the MD5 hash that the destination of the transfer must have: the source's, if the plan has it,
+// or the hash of a local source. Nil if it isn't known
+func expectedSourceMD5(t resumedTransfer) []byte {
+	h, _, _, _, _, _, _, _ := t.plan.TransferSrcPropertiesAndMetadata(t.index)
+	if len(h.ContentMD5) > 0 {
+		return h.ContentMD5
+	}
+	if t.plan.FromTo.From() == common.ELocation.Local() {
+		if hash, err := localFileMD5(t.source); err == nil {
+			return hash
+		}
+	}
+	return nil
+}
+
+func localFileMD5(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// appendSAS adds the SAS, which is kept out of the plan, back to a remote URL
+func appendSAS(resource string, sas string) string {
+	if sas == "" {
+		return resource
+	}
+	u, err := url.Parse(resource)
+	if err != nil {
+		return resource
+	}
+	if len(u.RawQuery) > 0 {
+		u.RawQuery += "&" + sas
+	} else {
+		u.RawQuery = sas
+	}
+	return u.String()
+}
+
+// newDestinationChecker returns what checks the destinations of the job, with HEAD requests (or stats, if they're local),
+// or nil if the job doesn't write anything anywhere
+func newDestinationChecker(ctx context.Context, jm IJobMgr, fromTo common.FromTo, credInfo common.CredentialInfo) destinationChecker {
+	credOption := common.CredentialOpOptions{
+		LogInfo:  func(str string) { jm.Log(pipeline.LogInfo, str) },
+		LogError: func(str string) { jm.Log(pipeline.LogError, str) },
+		Panic:    jm.Panic,
+		CallerID: fmt.Sprintf("JobID=%v, verifying destinations", jm.(*jobMgr).jobID),
+		Cancel:   jm.Cancel,
+	}
+	retryOptions := XferRetryOptions{MaxTries: UploadMaxTries, TryTimeout: UploadTryTimeout, RetryDelay: UploadRetryDelay, MaxRetryDelay: UploadMaxRetryDelay}
+
+	switch fromTo.To() {
+	case common.ELocation.Local():
+		return checkLocalDestination
+	case common.ELocation.Blob():
+		p := NewBlobPipeline(common.CreateBlobCredential(ctx, credInfo, credOption), azblob.PipelineOptions{Log: jm.PipelineLogInfo()},
+			retryOptions, nil, jm.HttpClient(), nil)
+		return func(ctx context.Context, destination string, _ bool) (destinationState, error) {
+			u, err := url.Parse(destination)
+			if err != nil {
+				return destinationState{}, err
+			}
+			props, err := azblob.NewBlobURL(*u, p).GetProperties(ctx, azblob.BlobAccessConditions{})
+			if stgErr, ok := err.(azblob.StorageError); ok && stgErr.Response() != nil && stgErr.Response().StatusCode == 404 {
+				return destinationState{}, nil
+			} else if err != nil {
+				return destinationState{}, err
+			}
+			return destinationState{exists: true, length: props.ContentLength(), lastModified: props.LastModified(), md5: props.ContentMD5()}, nil
+		}
+	case common.ELocation.File():
+		p := NewFilePipeline(azfile.NewAnonymousCredential(), azfile.PipelineOptions{Log: jm.PipelineLogInfo()},
+			azfile.RetryOptions{Policy: azfile.RetryPolicyExponential, MaxTries: UploadMaxTries, TryTimeout: UploadTryTimeout, RetryDelay: UploadRetryDelay, MaxRetryDelay: UploadMaxRetryDelay},
+			nil, jm.HttpClient(), nil)
+		return func(ctx context.Context, destination string, _ bool) (destinationState, error) {
+			u, err := url.Parse(destination)
+			if err != nil {
+				return destinationState{}, err
+			}
+			props, err := azfile.NewFileURL(*u, p).GetProperties(ctx)
+			if stgErr, ok := err.(azfile.StorageError); ok && stgErr.Response() != nil && stgErr.Response().StatusCode == 404 {
+				return destinationState{}, nil
+			} else if err != nil {
+				return destinationState{}, err
+			}
+			return destinationState{exists: true, length: props.ContentLength(), lastModified: props.LastModified(), md5: props.ContentMD5()}, nil
+		}
+	case common.ELocation.BlobFS():
+		p := NewBlobFSPipeline(common.CreateBlobFSCredential(ctx, credInfo, credOption), azbfs.PipelineOptions{Log: jm.PipelineLogInfo()},
+			retryOptions, nil, jm.HttpClient(), nil)
+		return func(ctx context.Context, destination string, _ bool) (destinationState, error) {
+			u, err := url.Parse(destination)
+			if err != nil {
+				return destinationState{}, err
+			}
+			props, err := azbfs.NewFileURL(*u, p).GetProperties(ctx)
+			if stgErr, ok := err.(azbfs.StorageError); ok && stgErr.Response() != nil && stgErr.Response().StatusCode == 404 {
+				return destinationState{}, nil
+			} else if err != nil {
+				return destinationState{}, err
+			}
+			lastModified, _ := time.Parse(time.RFC1123, props.LastModified())
+			return destinationState{exists: true, length: props.ContentLength(), lastModified: lastModified, md5: props.ContentMD5()}, nil
+		}
+	default:
+		return nil
+	}
+}
+
+func checkLocalDestination(_ context.Context, destination string, wantMD5 bool) (destinationState, error) {
+	info, err := os.Stat(destination)
+	if os.IsNotExist(err) {
+		return destinationState{}, nil
+	} else if err != nil {
+		return destinationState{}, err
+	}
+
+	state := destinationState{exists: true, length: info.Size(), lastModified: info.ModTime()}
+	if wantMD5 {
+		if state.md5, err = localFileMD5(destination); err != nil {
+			return destinationState{}, err
+		}
+	}
+	return state, nil
+}
+
+// resumeVerifyProgress is the progress line that is shown while the destinations are checked
+type resumeVerifyProgress struct {
+	Checked           uint32
+	Total             uint32
+	CorrectedToDone   uint32
+	CorrectedToRetry  uint32
+	DestinationErrors uint32
+}
+
+func (p resumeVerifyProgress) output(format common.OutputFormat) string {
+	if format == common.EOutputFormat.Json() {
+		jsonOutput, err := json.Marshal(p)
+		common.PanicIfErr(err)
+		return string(jsonOutput)
+	}
+	return fmt.Sprintf("Verifying destinations: %v of %v checked, %v found done, %v to be copied again, %v could not be checked",
+		p.Checked, p.Total, p.CorrectedToDone, p.CorrectedToRetry, p.DestinationErrors)
+}
@@ -0,0 +1,111 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+const defaultMaxRetryAfter = 60 * time.Second
+
+var (
+	maxRetryAfter      time.Duration
+	maxRetryAfterOncer sync.Once
+)
+
+// sleepBeforeRetry is how the retry policies wait. Tests replace it, to see the waits without sitting through them
+var sleepBeforeRetry = time.Sleep
+
+// getMaxRetryAfter is the longest that we wait when the service says how long to wait, so that a service that asks
+// for a very long wait can't stall the job. It's set by AZCOPY_MAX_RETRY_AFTER_SECONDS
+func getMaxRetryAfter() time.Duration {
+	maxRetryAfterOncer.Do(func() {
+		maxRetryAfter = defaultMaxRetryAfter
+		raw := common.GetLifecycleMgr().GetEnvironmentVariable(common.EEnvironmentVariable.MaxRetryAfterSeconds())
+		if seconds, err := strconv.ParseFloat(raw, 64); err == nil && seconds >= 0 {
+			maxRetryAfter = time.Duration(seconds * float64(time.Second))
+		}
+	})
+	return maxRetryAfter
+}
+
+// serverDirectedDelay returns how long the service asked us to wait before retrying a throttled request (status 503 or 429),
+// or zero if it didn't say. The milliseconds of x-ms-retry-after-ms are preferred over the standard Retry-After,
+// which may hold either seconds or an HTTP date. The result is capped at getMaxRetryAfter().
+func serverDirectedDelay(resp *http.Response) time.Duration {
+	if resp == nil || (resp.StatusCode != http.StatusServiceUnavailable && resp.StatusCode != http.StatusTooManyRequests) {
+		return 0
+	}
+
+	delay := time.Duration(0)
+	if ms, err := strconv.ParseInt(resp.Header.Get("x-ms-retry-after-ms"), 10, 64); err == nil && ms > 0 {
+		delay = time.Duration(ms) * time.Millisecond
+	} else if raw := resp.Header.Get("Retry-After"); raw != "" {
+		if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil && seconds > 0 {
+			delay = time.Duration(seconds) * time.Second
+		} else if at, err := http.ParseTime(raw); err == nil {
+			delay = time.Until(at)
+		}
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+	if max := getMaxRetryAfter(); delay > max {
+		return max
+	}
+	return delay
+}
+
+// serverDirectedDelayOf finds the delay that the service asked for in the outcome of a try, which may only be in the error
+// (e.g. a StorageError) when the response itself wasn't returned
+func serverDirectedDelayOf(response pipeline.Response, err error) time.Duration {
+	if response != nil && response.Response() != nil {
+		return serverDirectedDelay(response.Response())
+	}
+	if withResponse, ok := err.(interface{ Response() *http.Response }); ok {
+		return serverDirectedDelay(withResponse.Response())
+	}
+	return 0
+}
+
+// newRetryAfterPolicyFactory creates a factory for the file pipeline, whose retry policy comes from the SDK and knows nothing
+// of the waits that the service asks for. It goes right after that policy, and waits out the delay that the service asked for
+// before handing a throttled response back to it, which then adds its own (exponential) delay before retrying.
+func newRetryAfterPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			response, err := next.Do(ctx, request)
+			if delay := serverDirectedDelayOf(response, err); delay > 0 && ctx.Err() == nil {
+				sleepBeforeRetry(delay)
+			}
+			return response, err
+		}
+	})
+}
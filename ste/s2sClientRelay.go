@@ -0,0 +1,120 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// clientRelay sends the chunks of a S2S copy through this machine, by downloading them from the source and uploading them
+// to the destination. It's used, if the user opts in with --s2s-fallback=client-side, by the transfers whose destination
+// can't read the source itself, e.g. because the source is behind a firewall or a private endpoint that only this machine can reach.
+// Each transfer makes that decision on its own, the first time the destination fails to read the source.
+type clientRelay struct {
+	jptm   IJobPartTransferMgr
+	srcURL url.URL
+	inUse  int32 // accessed atomically
+}
+
+// newClientRelay returns nil if the transfer can't fall back to the client-side relay,
+// either because the user didn't ask for it, or because we have no way to read the source here (e.g. S3 sources)
+func newClientRelay(jptm IJobPartTransferMgr, srcURL url.URL) *clientRelay {
+	if jptm.S2SFallback() != common.ES2SFallback.ClientSide() || jptm.SourceProviderPipeline() == nil {
+		return nil
+	}
+	return &clientRelay{jptm: jptm, srcURL: srcURL}
+}
+
+// isInUse tells whether the transfer has already switched to the relay, in which case its chunks don't try the server-side copy first
+func (r *clientRelay) isInUse() bool {
+	return r != nil && atomic.LoadInt32(&r.inUse) == 1
+}
+
+// shouldTakeOver tells whether the error of a server-side copy means that the destination can't read the source,
+// and so the chunk (and all the remaining chunks of the transfer) should be sent through the relay instead
+func (r *clientRelay) shouldTakeOver(err error) bool {
+	if r == nil {
+		return false
+	}
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	if serviceCode != string(azblob.ServiceCodeCannotVerifyCopySource) {
+		return false
+	}
+
+	if atomic.CompareAndSwapInt32(&r.inUse, 0, 1) {
+		r.jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+			"The destination could not read the source, so the data of this transfer is relayed through this machine instead. The error was: "+err.Error())
+		r.jptm.ReportRelayedClientSide()
+	}
+	return true
+}
+
+// send downloads a range of the source, and passes it to upload.
+// The number of bytes that are held in memory at a time is limited by the job's cache limiter, as it is for downloads.
+func (r *clientRelay) send(offset int64, count int64, upload func(body io.ReadSeeker) error) error {
+	ctx := r.jptm.Context()
+	// the range is uploaded as soon as it's here, so nothing waits behind it, and the relaxed limit is fine
+	if err := r.jptm.CacheLimiter().WaitUntilAdd(ctx, count, func() bool { return true }); err != nil {
+		return err
+	}
+	defer r.jptm.CacheLimiter().Remove(count)
+
+	buffer := r.jptm.SlicePool().RentSlice(uint32(count))
+	defer r.jptm.SlicePool().ReturnSlice(buffer)
+
+	body, err := r.download(ctx, offset, count)
+	if err != nil {
+		return err
+	}
+	_, err = io.ReadFull(body, buffer)
+	body.Close()
+	if err != nil {
+		return err
+	}
+
+	return upload(bytes.NewReader(buffer))
+}
+
+func (r *clientRelay) download(ctx context.Context, offset int64, count int64) (io.ReadCloser, error) {
+	if fromTo := r.jptm.FromTo(); fromTo.From() == common.ELocation.File() {
+		get, err := azfile.NewFileURL(r.srcURL, r.jptm.SourceProviderPipeline()).Download(ctx, offset, count, false)
+		if err != nil {
+			return nil, err
+		}
+		return get.Body(azfile.RetryReaderOptions{MaxRetryRequests: MaxRetryPerDownloadBody}), nil
+	}
+
+	get, err := azblob.NewBlobURL(r.srcURL, r.jptm.SourceProviderPipeline()).Download(ctx, offset, count, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, err
+	}
+	return get.Body(azblob.RetryReaderOptions{MaxRetryRequests: MaxRetryPerDownloadBody}), nil
+}
@@ -22,6 +22,7 @@ package ste
 
 import (
 	"context"
+	"io"
 	"net/url"
 	"time"
 
@@ -46,6 +47,12 @@ type appendBlobSenderBase struct {
 	metadataToApply azblob.Metadata
 
 	soleChunkFuncSemaphore *semaphore.Weighted
+
+	// where the chunks start, which is only past 0 when an append-only upload appends to what the destination already has
+	firstOffset int64
+
+	// so that a soft-deleted destination can be told apart from one that doesn't exist
+	softDeletableBlob
 }
 
 type appendBlockFunc = func()
@@ -62,9 +69,6 @@ func newAppendBlobSenderBase(jptm IJobPartTransferMgr, destination string, p pip
 		common.MaxAppendBlobBlockSize,
 		chunkSize)
 
-	srcSize := transferInfo.SourceSize
-	numChunks := getNumChunks(srcSize, chunkSize)
-
 	destURL, err := url.Parse(destination)
 	if err != nil {
 		return nil, err
@@ -72,6 +76,14 @@ func newAppendBlobSenderBase(jptm IJobPartTransferMgr, destination string, p pip
 
 	destAppendBlobURL := azblob.NewAppendBlobURL(*destURL, p)
 
+	var firstOffset int64
+	if jptm.AppendOnly() && srcInfoProvider.IsLocal() {
+		firstOffset = appendBlobFirstOffset(jptm, destAppendBlobURL)
+	}
+
+	srcSize := transferInfo.SourceSize
+	numChunks := getNumChunks(srcSize-firstOffset, chunkSize)
+
 	props, err := srcInfoProvider.Properties()
 	if err != nil {
 		return nil, err
@@ -84,8 +96,30 @@ func newAppendBlobSenderBase(jptm IJobPartTransferMgr, destination string, p pip
 		numChunks:              numChunks,
 		pacer:                  pacer,
 		headersToApply:         props.SrcHTTPHeaders.ToAzBlobHTTPHeaders(),
-		metadataToApply:        props.SrcMetadata.ToAzBlobMetadata(),
-		soleChunkFuncSemaphore: semaphore.NewWeighted(1)}, nil
+		metadataToApply:        destinationMetadata(jptm, props.SrcMetadata).ToAzBlobMetadata(),
+		soleChunkFuncSemaphore: semaphore.NewWeighted(1),
+		firstOffset:            firstOffset,
+		softDeletableBlob:      newSoftDeletableBlob(jptm.Context(), *destURL, p)}, nil
+}
+
+// appendBlobFirstOffset returns where an append-only upload starts appending, which is the end of the destination,
+// if it is an append blob that holds the start of the source
+func appendBlobFirstOffset(jptm IJobPartTransferMgr, destAppendBlobURL azblob.AppendBlobURL) int64 {
+	props, err := destAppendBlobURL.GetProperties(jptm.Context(), azblob.BlobAccessConditions{})
+	if err != nil || props.BlobType() != azblob.BlobAppendBlob {
+		return 0 // most likely, it doesn't exist yet, and if we can't read it, uploading it in full reports why
+	}
+	return appendOnlyFirstOffset(jptm, props.ContentLength(), func(offset int64, count int64) (io.ReadCloser, error) {
+		get, err := destAppendBlobURL.Download(jptm.Context(), offset, count, azblob.BlobAccessConditions{}, false)
+		if err != nil {
+			return nil, err
+		}
+		return get.Body(azblob.RetryReaderOptions{MaxRetryRequests: MaxRetryPerDownloadBody}), nil
+	})
+}
+
+func (s *appendBlobSenderBase) FirstOffset() int64 {
+	return s.firstOffset
 }
 
 func (s *appendBlobSenderBase) ChunkSize() uint32 {
@@ -120,8 +154,8 @@ func (s *appendBlobSenderBase) generateAppendBlockToRemoteFunc(id common.ChunkID
 
 		jptm := s.jptm
 
-		if jptm.Info().SourceSize == 0 {
-			// nothing to do, since this is a dummy chunk in a zero-size file, and the prologue will have done all the real work
+		if jptm.Info().SourceSize == s.firstOffset {
+			// nothing to do, since this is a dummy chunk in a zero-size file (or one that the destination already has all of), and the prologue will have done all the real work
 			return
 		}
 
@@ -137,7 +171,16 @@ func (s *appendBlobSenderBase) Prologue(ps common.PrologueState) (destinationMod
 	}
 
 	destinationModified = true
-	_, err := s.destAppendBlobURL.Create(s.jptm.Context(), s.headersToApply, s.metadataToApply, azblob.BlobAccessConditions{})
+	if s.firstOffset > 0 {
+		// the blob is appended to, rather than created. Its headers are set again, if only to remove the MD5 hash, which won't match once it has grown
+		_, err := s.destAppendBlobURL.SetHTTPHeaders(s.jptm.Context(), s.headersToApply, azblob.BlobAccessConditions{})
+		if err != nil {
+			s.jptm.FailActiveSend("Setting headers before appending", err)
+		}
+		return
+	}
+
+	_, err := s.destAppendBlobURL.Create(s.jptm.Context(), s.headersToApply, s.metadataToApply, destinationWriteConditions(s.jptm, nil))
 	if err != nil {
 		s.jptm.FailActiveSend("Creating blob", err)
 		return
@@ -152,7 +195,13 @@ func (s *appendBlobSenderBase) Epilogue() {
 func (s *appendBlobSenderBase) Cleanup() {
 	jptm := s.jptm
 	// Cleanup
-	if jptm.IsDeadInflight() {
+	if jptm.IsDeadInflight() && s.firstOffset > 0 {
+		// the blob held the start of the source before we appended to it, so it's kept. What was appended
+		// is the same as that range of the source, so the next append-only upload will carry on from where this one stopped
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The append-only upload did not complete, so the destination only holds part of the source")
+	} else if jptm.IsDeadInflight() && skippedForDestinationCondition(jptm) {
+		// the blob is what something else wrote, so it's left as it is
+	} else if jptm.IsDeadInflight() {
 		// There is a possibility that some uncommitted blocks will be there
 		// Delete the uncommitted blobs
 		// TODO: particularly, given that this is an APPEND blob, do we really need to delete it?  But if we don't delete it,
@@ -22,6 +22,7 @@ package ste
 
 import (
 	"context"
+	"io"
 	"net/url"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -33,6 +34,7 @@ type urlToAppendBlobCopier struct {
 	appendBlobSenderBase
 
 	srcURL url.URL
+	relay  *clientRelay // nil unless the transfer may fall back to relaying the data through this machine
 }
 
 func newURLToAppendBlobCopier(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, srcInfoProvider IRemoteSourceInfoProvider) (s2sCopier, error) {
@@ -48,7 +50,8 @@ func newURLToAppendBlobCopier(jptm IJobPartTransferMgr, destination string, p pi
 
 	return &urlToAppendBlobCopier{
 		appendBlobSenderBase: *senderBase,
-		srcURL:               *srcURL}, nil
+		srcURL:               *srcURL,
+		relay:                newClientRelay(jptm, *srcURL)}, nil
 }
 
 // Returns a chunk-func for blob copies
@@ -56,18 +59,36 @@ func (c *urlToAppendBlobCopier) GenerateCopyFunc(id common.ChunkID, blockIndex i
 	appendBlockFromURL := func() {
 		c.jptm.LogChunkStatus(id, common.EWaitReason.S2SCopyOnWire())
 
-		// Set the latest service version from sdk as service version in the context, to use AppendBlockFromURL API.
-		ctxWithLatestServiceVersion := context.WithValue(c.jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion)
+		// Set the latest service version from sdk as service version in the context, to use AppendBlockFromURL API
+		// (or a later one, if the source is read with an OAuth token)
+		ctxWithLatestServiceVersion := withCopySourceAuthorization(
+			context.WithValue(c.jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion), c.jptm)
 
 		if err := c.pacer.RequestTrafficAllocation(c.jptm.Context(), adjustedChunkSize); err != nil {
 			c.jptm.FailActiveUpload("Pacing block", err)
 		}
-		_, err := c.destAppendBlobURL.AppendBlockFromURL(ctxWithLatestServiceVersion, c.srcURL, id.OffsetInFile(), adjustedChunkSize,
-			azblob.AppendBlobAccessConditions{
-				AppendPositionAccessConditions: azblob.AppendPositionAccessConditions{IfAppendPositionEqual: id.OffsetInFile()},
-			}, azblob.ModifiedAccessConditions{}, nil)
+		accessConditions := azblob.AppendBlobAccessConditions{
+			AppendPositionAccessConditions: azblob.AppendPositionAccessConditions{IfAppendPositionEqual: id.OffsetInFile()},
+		}
+		if !c.relay.isInUse() {
+			_, err := c.destAppendBlobURL.AppendBlockFromURL(ctxWithLatestServiceVersion, c.srcURL, id.OffsetInFile(), adjustedChunkSize,
+				accessConditions, azblob.ModifiedAccessConditions{}, nil)
+			if err == nil {
+				return
+			}
+			if !c.relay.shouldTakeOver(err) {
+				explainCopySourceAuthFailure(c.jptm, err)
+				c.jptm.FailActiveS2SCopy("Appending block from URL", err)
+				return
+			}
+		}
+
+		// the destination can't read the source, so the block goes through this machine
+		err := c.relay.send(id.OffsetInFile(), adjustedChunkSize, func(body io.ReadSeeker) error {
+			return appendBlock(c.jptm.Context(), c.destAppendBlobURL, body, id.OffsetInFile())
+		})
 		if err != nil {
-			c.jptm.FailActiveS2SCopy("Appending block from URL", err)
+			c.jptm.FailActiveS2SCopy("Appending block relayed through the client", err)
 			return
 		}
 	}
@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -35,8 +36,10 @@ import (
 )
 
 type azureFileSenderBase struct {
-	jptm      IJobPartTransferMgr
+	jptm IJobPartTransferMgr
+	// what's written, which is the destination, or the temporary name it's written under, until it's renamed to finalURL
 	fileURL   azfile.FileURL
+	finalURL  azfile.FileURL
 	chunkSize uint32
 	numChunks uint32
 	pipeline  pipeline.Pipeline
@@ -48,6 +51,17 @@ type azureFileSenderBase struct {
 	// the properties of the local file
 	headersToApply  azfile.FileHTTPHeaders
 	metadataToApply azfile.Metadata
+
+	// the properties of the destination, as they were when it was checked for (if it was), so that they're not fetched again
+	// if its ReadOnly attribute has to be cleared. They are only of use until the file is created
+	destinationProps *azfile.FileGetPropertiesResponse
+	// the attributes that the destination had before its ReadOnly attribute was cleared to overwrite it, if it was, so that they're restored
+	attributesToRestore string
+	readOnlyLock        *sync.Mutex
+	// counts the transfer once in the job's number of those whose writes were retried for sharing violations
+	sharingViolationReported *sync.Once
+	// whether fileURL is a temporary name, which the file is renamed from once it's complete
+	temporaryName bool
 }
 
 func newAzureFileSenderBase(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, sip ISourceInfoProvider) (*azureFileSenderBase, error) {
@@ -83,16 +97,28 @@ func newAzureFileSenderBase(jptm IJobPartTransferMgr, destination string, p pipe
 		return nil, err
 	}
 
+	// a temporary file that a resumed job finds is its own, and is simply written again, since Azure files have their full size once created
+	fileURL := azfile.NewFileURL(*destURL, p)
+	finalURL := fileURL
+	suffix := jptm.TempNameSuffix()
+	if suffix != "" {
+		fileURL = azfile.NewFileURL(temporaryDestinationURL(*destURL, suffix), p)
+	}
+
 	return &azureFileSenderBase{
-		jptm:            jptm,
-		fileURL:         azfile.NewFileURL(*destURL, p),
-		chunkSize:       chunkSize,
-		numChunks:       numChunks,
-		pipeline:        p,
-		pacer:           pacer,
-		ctx:             ctx,
-		headersToApply:  props.SrcHTTPHeaders.ToAzFileHTTPHeaders(),
-		metadataToApply: props.SrcMetadata.ToAzFileMetadata(),
+		jptm:                     jptm,
+		fileURL:                  fileURL,
+		finalURL:                 finalURL,
+		temporaryName:            suffix != "",
+		chunkSize:                chunkSize,
+		numChunks:                numChunks,
+		pipeline:                 p,
+		pacer:                    pacer,
+		ctx:                      ctx,
+		headersToApply:           props.SrcHTTPHeaders.ToAzFileHTTPHeaders(),
+		metadataToApply:          destinationMetadata(jptm, props.SrcMetadata).ToAzFileMetadata(),
+		readOnlyLock:             &sync.Mutex{},
+		sharingViolationReported: &sync.Once{},
 	}, nil
 }
 
@@ -105,7 +131,11 @@ func (u *azureFileSenderBase) NumChunks() uint32 {
 }
 
 func (u *azureFileSenderBase) RemoteFileExists() (bool, time.Time, error) {
-	return remoteObjectExists(u.fileURL.GetProperties(u.ctx))
+	props, err := u.finalURL.GetProperties(u.ctx)
+	if err == nil {
+		u.destinationProps = props
+	}
+	return remoteObjectExists(props, err)
 }
 
 func (u *azureFileSenderBase) Prologue(state common.PrologueState) (destinationModified bool) {
@@ -114,6 +144,12 @@ func (u *azureFileSenderBase) Prologue(state common.PrologueState) (destinationM
 
 	destinationModified = true
 
+	// once the share is found full, the files that are yet to start wait for room with the others, or fail without trying
+	if err := jptm.GetShareQuotaGuard().waitWhileFull(jptm.Context(), u.pipeline, u.fileURL.URL(), jptm.ShareFullWaitWindow()); err != nil {
+		u.failWrite("Creating file", err, jptm.FailActiveUpload)
+		return
+	}
+
 	// Create the parent directories of the file. Note share must be existed, as the files are listed from share or directory.
 	err := AzureFileParentDirCreator{}.CreateParentDirToRoot(u.ctx, u.fileURL, u.pipeline)
 	if err != nil {
@@ -128,15 +164,40 @@ func (u *azureFileSenderBase) Prologue(state common.PrologueState) (destinationM
 	}
 
 	// Create Azure file with the source size
-	_, err = u.fileURL.Create(u.ctx, info.SourceSize, u.headersToApply, u.metadataToApply)
+	err = u.writeWithRetries(func() error {
+		_, err := u.fileURL.Create(u.ctx, info.SourceSize, u.headersToApply, u.metadataToApply)
+		return err
+	})
+	u.destinationProps = nil // they're those of the file that was replaced
 	if err != nil {
-		jptm.FailActiveUpload("Creating file", err)
+		u.failWrite("Creating file", err, jptm.FailActiveUpload)
 		return
 	}
 
 	return
 }
 
+// RenameToFinalName renames the file from the temporary name it was written under, if it was, replacing what has the final name.
+// Whatever is in the way of the rename is in the way of the final name, and is dealt with as it would be if the file were written there
+func (u *azureFileSenderBase) RenameToFinalName() {
+	if !u.temporaryName {
+		return
+	}
+
+	temporaryURL := u.fileURL
+	u.fileURL = u.finalURL
+	err := u.writeWithRetries(func() error {
+		return renameAzureFile(u.ctx, u.pipeline, temporaryURL.URL(), u.finalURL.URL())
+	})
+	if err != nil {
+		u.fileURL = temporaryURL // so that Cleanup deletes it
+		u.failWrite("Renaming file to its final name", err, u.jptm.FailActiveSend)
+		return
+	}
+	u.temporaryName = false
+	u.restoreReadOnlyAttribute()
+}
+
 func (u *azureFileSenderBase) Cleanup() {
 	jptm := u.jptm
 
@@ -22,6 +22,7 @@ package ste
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-azcopy/common"
@@ -68,9 +69,15 @@ func (u *azureFileUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int
 		// upload the byte range represented by this chunk
 		jptm.LogChunkStatus(id, common.EWaitReason.Body())
 		body := newPacedRequestBody(u.ctx, reader, u.pacer)
-		_, err := u.fileURL.UploadRange(u.ctx, id.OffsetInFile(), body, nil)
+		err := u.writeWithRetries(func() error {
+			if _, err := body.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			_, err := u.fileURL.UploadRange(u.ctx, id.OffsetInFile(), body, nil)
+			return err
+		})
 		if err != nil {
-			jptm.FailActiveUpload("Uploading range", err)
+			u.failWrite("Uploading range", err, jptm.FailActiveUpload)
 			return
 		}
 	})
@@ -79,17 +86,26 @@ func (u *azureFileUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int
 func (u *azureFileUploader) Epilogue() {
 	jptm := u.jptm
 
-	// set content MD5 (only way to do this is to re-PUT all the headers, this time with the MD5 included)
+	// set content MD5 (only way to do this is to re-PUT all the headers, this time with the MD5 included).
+	// A file whose ReadOnly attribute was cleared gets it in the same request that gives the attribute back
 	if jptm.IsLive() {
 		tryPutMd5Hash(jptm, u.md5Channel, func(md5Hash []byte) error {
 			if len(md5Hash) == 0 {
 				return nil
 			}
 
-			epilogueHeaders := u.headersToApply
-			epilogueHeaders.ContentMD5 = md5Hash
-			_, err := u.fileURL.SetHTTPHeaders(u.ctx, epilogueHeaders)
-			return err
+			u.headersToApply.ContentMD5 = md5Hash
+			if restored, err := u.restoreReadOnlyAttribute(); restored {
+				return err
+			}
+			return u.writeWithRetries(func() error {
+				_, err := u.fileURL.SetHTTPHeaders(u.ctx, u.headersToApply)
+				return err
+			})
 		})
 	}
+
+	if jptm.IsLive() {
+		u.restoreReadOnlyAttribute()
+	}
 }
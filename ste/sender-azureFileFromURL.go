@@ -64,13 +64,20 @@ func (u *urlToAzureFileCopier) GenerateCopyFunc(id common.ChunkID, blockIndex in
 		if err := u.pacer.RequestTrafficAllocation(u.jptm.Context(), adjustedChunkSize); err != nil {
 			u.jptm.FailActiveUpload("Pacing block (global level)", err)
 		}
-		_, err := u.fileURL.UploadRangeFromURL(
-			u.ctx, u.srcURL, id.OffsetInFile(), id.OffsetInFile(), adjustedChunkSize)
+		err := u.writeWithRetries(func() error {
+			_, err := u.fileURL.UploadRangeFromURL(
+				u.ctx, u.srcURL, id.OffsetInFile(), id.OffsetInFile(), adjustedChunkSize)
+			return err
+		})
 		if err != nil {
-			u.jptm.FailActiveS2SCopy("Uploading range from URL", err)
+			u.failWrite("Uploading range from URL", err, u.jptm.FailActiveS2SCopy)
 			return
 		}
 	})
 }
 
-func (u *urlToAzureFileCopier) Epilogue() {}
+func (u *urlToAzureFileCopier) Epilogue() {
+	if u.jptm.IsLive() {
+		u.restoreReadOnlyAttribute()
+	}
+}
@@ -21,8 +21,10 @@
 package ste
 
 import (
+	"bytes"
 	"context"
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/url"
@@ -53,6 +55,20 @@ type blockBlobSenderBase struct {
 
 	atomicPutListIndicator int32
 	muBlockIDs             *sync.Mutex
+
+	// identifies the source, as it was when the transfer started, in the block IDs
+	sourceIdentity string
+	// the IDs of the blocks that an earlier attempt staged, and which need not be sent again, by block index. Only read once the prologue is done
+	reusableBlockIDs map[int32]string
+
+	// set when the blob was created with its tier, so that the epilogue needn't set it
+	tierSetOnCreate bool
+
+	// the lease on the destination while it's written, if the job protects its destinations with leases
+	lease *destinationLease
+
+	// so that a soft-deleted destination can be told apart from one that doesn't exist
+	softDeletableBlob
 }
 
 func newBlockBlobSenderBase(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, srcInfoProvider ISourceInfoProvider, inferredAccessTierType azblob.AccessTierType) (*blockBlobSenderBase, error) {
@@ -87,16 +103,33 @@ func newBlockBlobSenderBase(jptm IJobPartTransferMgr, destination string, p pipe
 	}
 
 	return &blockBlobSenderBase{
-		jptm:             jptm,
-		destBlockBlobURL: destBlockBlobURL,
-		chunkSize:        chunkSize,
-		numChunks:        numChunks,
-		pacer:            pacer,
-		blockIDs:         make([]string, numChunks),
-		headersToApply:   props.SrcHTTPHeaders.ToAzBlobHTTPHeaders(),
-		metadataToApply:  props.SrcMetadata.ToAzBlobMetadata(),
-		destBlobTier:     destBlobTier,
-		muBlockIDs:       &sync.Mutex{}}, nil
+		jptm:              jptm,
+		destBlockBlobURL:  destBlockBlobURL,
+		chunkSize:         chunkSize,
+		numChunks:         numChunks,
+		pacer:             pacer,
+		blockIDs:          make([]string, numChunks),
+		headersToApply:    props.SrcHTTPHeaders.ToAzBlobHTTPHeaders(),
+		metadataToApply:   destinationMetadata(jptm, props.SrcMetadata).ToAzBlobMetadata(),
+		destBlobTier:      destBlobTier,
+		muBlockIDs:        &sync.Mutex{},
+		sourceIdentity:    blockSourceIdentity(jptm),
+		softDeletableBlob: newSoftDeletableBlob(jptm.Context(), *destURL, p)}, nil
+}
+
+// blockSourceIdentity is a short hash of the source's location, size and last modified time, so that the blocks that were
+// staged for one version of a source are never taken for those of another. A SAS that the source URL has can change between
+// attempts, so it's not part of it.
+func blockSourceIdentity(jptm IJobPartTransferMgr) string {
+	source := jptm.Info().Source
+	if fromTo := jptm.FromTo(); fromTo.From().IsRemote() {
+		if u, err := url.Parse(source); err == nil {
+			u.RawQuery = ""
+			source = u.String()
+		}
+	}
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d\n%d", source, jptm.Info().SourceSize, jptm.LastModifiedTime().UnixNano())))
+	return hex.EncodeToString(h[:sourceIdentityBytes])
 }
 
 func (s *blockBlobSenderBase) ChunkSize() uint32 {
@@ -117,11 +150,90 @@ func (s *blockBlobSenderBase) Prologue(ps common.PrologueState) (destinationModi
 		// about the file type at this time than what we had before
 		s.headersToApply.ContentType = ps.GetInferredContentType(s.jptm)
 	}
-	return false
+	if s.jptm.ProtectDestinationWithLease() {
+		destinationModified = s.leaseDestination()
+		if !s.jptm.IsLive() {
+			return
+		}
+	}
+	if s.jptm.ReuseUncommittedBlocks() && s.numChunks > 1 {
+		s.findReusableBlocks()
+	}
+	return
+}
+
+// leaseDestination leases the destination blob, so that nothing else can write to it while it's uploaded.
+// Only a blob that exists can be leased, so one that doesn't exist yet is created empty first, which drops any blocks
+// that an earlier attempt staged on it.
+func (s *blockBlobSenderBase) leaseDestination() (destinationModified bool) {
+	jptm := s.jptm
+	ops := blobLeaseOperations{blobURL: s.destBlockBlobURL.BlobURL}
+
+	lease, err := acquireDestinationLease(jptm, ops, s.destBlockBlobURL.URL())
+	if stgErr, ok := err.(azblob.StorageError); ok && stgErr.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+		destinationModified = true
+		if _, err = s.destBlockBlobURL.Upload(jptm.Context(), bytes.NewReader(nil), azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}); err != nil {
+			failDestinationLease(jptm, "Creating the blob to lease it", err)
+			return
+		}
+		lease, err = acquireDestinationLease(jptm, ops, s.destBlockBlobURL.URL())
+	}
+	if err != nil {
+		failDestinationLease(jptm, "Leasing the blob", err)
+		return
+	}
+
+	s.lease = lease
+	return
+}
+
+// findReusableBlocks looks for the blocks that an earlier attempt at this transfer staged, but didn't commit. The service keeps
+// such blocks for a week, so a large upload that stopped part way through doesn't have to start again from the beginning.
+// Each block is only kept if its ID says that it holds the range of this version of the source that it would be staged for now,
+// and if it has the length of that range. (The service doesn't tell the hash of an uncommitted block, so the source's
+// identity in the ID, which covers its last modified time, is what shows that the content is the same.)
+func (s *blockBlobSenderBase) findReusableBlocks() {
+	jptm := s.jptm
+	blockList, err := s.destBlockBlobURL.GetBlockList(jptm.Context(), azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+	if err != nil {
+		// most likely, there is no blob yet. Either way, everything is staged as usual
+		return
+	}
+
+	srcSize := jptm.Info().SourceSize
+	reusable := make(map[int32]string)
+	for _, block := range blockList.UncommittedBlocks {
+		offset, blockSize, ok := s.parseBlockID(block.Name)
+		if !ok {
+			continue // staged by something else, or for another version of the source
+		}
+		if blockSize != int64(s.chunkSize) {
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, fmt.Sprintf("The blocks that were staged before are %d bytes long, but this attempt uses blocks of %d bytes, "+
+				"so none of them can be kept, and the whole blob is uploaded again. To keep them, use the same block size as before", blockSize, s.chunkSize))
+			return
+		}
+		if offset%blockSize != 0 || offset >= srcSize {
+			continue
+		}
+		expectedLength := blockSize
+		if offset+blockSize > srcSize {
+			expectedLength = srcSize - offset
+		}
+		if int64(block.Size) != expectedLength {
+			continue
+		}
+		reusable[int32(offset/blockSize)] = block.Name
+	}
+
+	if len(reusable) > 0 {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Keeping %d of the %d blocks, since they were staged by an earlier attempt", len(reusable), s.numChunks))
+	}
+	s.reusableBlockIDs = reusable
 }
 
 func (s *blockBlobSenderBase) Epilogue() {
 	jptm := s.jptm
+	defer s.lease.release()
 
 	s.muBlockIDs.Lock()
 	blockIDs := s.blockIDs
@@ -138,19 +250,25 @@ func (s *blockBlobSenderBase) Epilogue() {
 		jptm.Log(pipeline.LogDebug, fmt.Sprintf("Conclude Transfer with BlockList %s", blockIDs))
 
 		// commit the blocks.
-		if _, err := s.destBlockBlobURL.CommitBlockList(jptm.Context(), blockIDs, s.headersToApply, s.metadataToApply, azblob.BlobAccessConditions{}); err != nil {
+		if _, err := s.destBlockBlobURL.CommitBlockList(jptm.Context(), blockIDs, s.headersToApply, s.metadataToApply, destinationWriteConditions(jptm, s.lease)); err != nil {
 			jptm.FailActiveSend("Committing block list", err)
 			return
 		}
 	}
 
+	// the destination of a S2S copy may be left without a Content-MD5, which is computed here if the job asks for it.
+	// That's done before the tier is set, since an archived blob can't be read back
+	if fromTo := jptm.FromTo(); jptm.IsLive() && fromTo.IsS2S() {
+		s.backfillMD5(shouldPutBlockList == putListNeeded)
+	}
+
 	// Set tier
 	// GPv2 or Blob Storage is supported, GPv1 is not supported, can only set to blob without snapshot in active status.
 	// https://docs.microsoft.com/en-us/azure/storage/blobs/storage-blob-storage-tiers
-	if jptm.IsLive() && s.destBlobTier != azblob.AccessTierNone {
+	if jptm.IsLive() && s.destBlobTier != azblob.AccessTierNone && !s.tierSetOnCreate {
 		// Set the latest service version from sdk as service version in the context.
 		ctxWithLatestServiceVersion := context.WithValue(jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion)
-		_, err := s.destBlockBlobURL.SetTier(ctxWithLatestServiceVersion, s.destBlobTier, azblob.LeaseAccessConditions{})
+		_, err := s.destBlockBlobURL.SetTier(ctxWithLatestServiceVersion, s.destBlobTier, s.lease.leaseAccessConditions())
 		if err != nil {
 			if s.jptm.Info().S2SSrcBlobTier != azblob.AccessTierNone {
 				s.jptm.LogTransferInfo(pipeline.LogError, s.jptm.Info().Source, s.jptm.Info().Destination, "Failed to replicate blob tier at destination. Try transferring with the flag --s2s-preserve-access-tier=false")
@@ -170,7 +288,12 @@ func (s *blockBlobSenderBase) Cleanup() {
 	jptm := s.jptm
 
 	// Cleanup
-	if jptm.IsDeadInflight() {
+	if jptm.IsDeadInflight() && skippedForDestinationCondition(jptm) {
+		// the blob is what something else wrote, so it's left as it is
+	} else if jptm.IsDeadInflight() && jptm.ReuseUncommittedBlocks() {
+		// the blocks that were staged are kept, so that the next attempt need not send them again
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Keeping the blocks that were staged, so that they can be reused when the transfer is done again")
+	} else if jptm.IsDeadInflight() {
 		// there is a possibility that some uncommitted blocks will be there
 		// Delete the uncommitted blobs
 		deletionContext, cancelFn := context.WithTimeout(context.Background(), 30*time.Second)
@@ -204,7 +327,8 @@ func (s *blockBlobSenderBase) setBlockID(index int32, value string) {
 	s.blockIDs[index] = value
 }
 
-func (s *blockBlobSenderBase) generateEncodedBlockID() string {
-	blockID := common.NewUUID().String()
-	return base64.StdEncoding.EncodeToString([]byte(blockID))
+// isStagedAlready tells whether an earlier attempt staged the block, so that it only needs to be added to the block list
+func (s *blockBlobSenderBase) isStagedAlready(blockIndex int32) bool {
+	_, ok := s.reusableBlockIDs[blockIndex]
+	return ok
 }
@@ -66,15 +66,18 @@ func (u *blockBlobUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int
 func (u *blockBlobUploader) generatePutBlock(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader) chunkFunc {
 	return createSendToRemoteChunkFunc(u.jptm, id, func() {
 		// step 1: generate block ID
-		encodedBlockID := u.generateEncodedBlockID()
+		encodedBlockID := u.blockIDFor(id.OffsetInFile())
 
 		// step 2: save the block ID into the list of block IDs
 		u.setBlockID(blockIndex, encodedBlockID)
+		if u.isStagedAlready(blockIndex) {
+			return
+		}
 
 		// step 3: put block to remote
 		u.jptm.LogChunkStatus(id, common.EWaitReason.Body())
 		body := newPacedRequestBody(u.jptm.Context(), reader, u.pacer)
-		_, err := u.destBlockBlobURL.StageBlock(u.jptm.Context(), encodedBlockID, body, azblob.LeaseAccessConditions{}, nil)
+		_, err := u.destBlockBlobURL.StageBlock(u.jptm.Context(), encodedBlockID, body, u.lease.leaseAccessConditions(), nil)
 		if err != nil {
 			u.jptm.FailActiveUpload("Staging block", err)
 			return
@@ -92,7 +95,7 @@ func (u *blockBlobUploader) generatePutWholeBlob(id common.ChunkID, blockIndex i
 		jptm.LogChunkStatus(id, common.EWaitReason.Body())
 		var err error
 		if jptm.Info().SourceSize == 0 {
-			_, err = u.destBlockBlobURL.Upload(jptm.Context(), bytes.NewReader(nil), u.headersToApply, u.metadataToApply, azblob.BlobAccessConditions{})
+			_, err = u.destBlockBlobURL.Upload(jptm.Context(), bytes.NewReader(nil), u.headersToApply, u.metadataToApply, destinationWriteConditions(jptm, u.lease))
 		} else {
 			// File with content
 
@@ -106,7 +109,7 @@ func (u *blockBlobUploader) generatePutWholeBlob(id common.ChunkID, blockIndex i
 
 			// Upload the file
 			body := newPacedRequestBody(jptm.Context(), reader, u.pacer)
-			_, err = u.destBlockBlobURL.Upload(jptm.Context(), body, u.headersToApply, u.metadataToApply, azblob.BlobAccessConditions{})
+			_, err = u.destBlockBlobURL.Upload(jptm.Context(), body, u.headersToApply, u.metadataToApply, destinationWriteConditions(jptm, u.lease))
 		}
 
 		// if the put blob is a failure, update the transfer status to failed
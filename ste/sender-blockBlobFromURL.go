@@ -23,7 +23,18 @@ package ste
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/azblob"
@@ -34,7 +45,9 @@ import (
 type urlToBlockBlobCopier struct {
 	blockBlobSenderBase
 
-	srcURL url.URL
+	srcURL   url.URL
+	relay    *clientRelay // nil unless the transfer may fall back to relaying the data through this machine
+	pipeline pipeline.Pipeline
 }
 
 func newURLToBlockBlobCopier(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, srcInfoProvider IRemoteSourceInfoProvider) (s2sCopier, error) {
@@ -59,7 +72,9 @@ func newURLToBlockBlobCopier(jptm IJobPartTransferMgr, destination string, p pip
 
 	return &urlToBlockBlobCopier{
 		blockBlobSenderBase: *senderBase,
-		srcURL:              *srcURL}, nil
+		srcURL:              *srcURL,
+		relay:               newClientRelay(jptm, *srcURL),
+		pipeline:            p}, nil
 }
 
 // Returns a chunk-func for blob copies
@@ -69,6 +84,11 @@ func (c *urlToBlockBlobCopier) GenerateCopyFunc(id common.ChunkID, blockIndex in
 		return c.generateCreateEmptyBlob(id)
 	}
 
+	if chunkIsWholeFile && adjustedChunkSize <= putBlobFromURLThreshold && c.mayPutBlobFromURL() {
+		setPutListNeed(&c.atomicPutListIndicator, putListNotNeeded)
+		return c.generatePutBlobFromURL(id, blockIndex, adjustedChunkSize)
+	}
+
 	setPutListNeed(&c.atomicPutListIndicator, putListNeeded)
 	return c.generatePutBlockFromURL(id, blockIndex, adjustedChunkSize)
 }
@@ -96,7 +116,7 @@ func (c *urlToBlockBlobCopier) generateCreateEmptyBlob(id common.ChunkID) chunkF
 
 		jptm.LogChunkStatus(id, common.EWaitReason.S2SCopyOnWire())
 		// Create blob and finish.
-		if _, err := c.destBlockBlobURL.Upload(c.jptm.Context(), bytes.NewReader(nil), c.headersToApply, c.metadataToApply, azblob.BlobAccessConditions{}); err != nil {
+		if _, err := c.destBlockBlobURL.Upload(c.jptm.Context(), bytes.NewReader(nil), c.headersToApply, c.metadataToApply, destinationWriteConditions(jptm, c.lease)); err != nil {
 			jptm.FailActiveSend("Creating empty blob", err)
 			return
 		}
@@ -125,26 +145,100 @@ func (c *urlToBlockBlobCopier) generateCreateEmptyBlob(id common.ChunkID) chunkF
 func (c *urlToBlockBlobCopier) generatePutBlockFromURL(id common.ChunkID, blockIndex int32, adjustedChunkSize int64) chunkFunc {
 	return createSendToRemoteChunkFunc(c.jptm, id, func() {
 		// step 1: generate block ID
-		encodedBlockID := c.generateEncodedBlockID()
+		encodedBlockID := c.blockIDFor(id.OffsetInFile())
 
 		// step 2: save the block ID into the list of block IDs
 		c.setBlockID(blockIndex, encodedBlockID)
+		if c.isStagedAlready(blockIndex) {
+			return
+		}
 
 		// step 3: put block to remote
 		c.jptm.LogChunkStatus(id, common.EWaitReason.S2SCopyOnWire())
-
-		// Set the latest service version from sdk as service version in the context, to use StageBlockFromURL API
-		ctxWithLatestServiceVersion := context.WithValue(c.jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion)
-
 		if err := c.pacer.RequestTrafficAllocation(c.jptm.Context(), adjustedChunkSize); err != nil {
 			c.jptm.FailActiveUpload("Pacing block", err)
 		}
+		c.stageBlockFromURL(id, encodedBlockID, adjustedChunkSize)
+	})
+}
+
+// stageBlockFromURL stages the block, and fails the transfer if it can't. It returns whether the block was staged
+func (c *urlToBlockBlobCopier) stageBlockFromURL(id common.ChunkID, encodedBlockID string, adjustedChunkSize int64) bool {
+	// Set the latest service version from sdk as service version in the context, to use StageBlockFromURL API
+	// (or a later one, if the source is read with an OAuth token)
+	ctxWithLatestServiceVersion := withCopySourceAuthorization(
+		context.WithValue(c.jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion), c.jptm)
+
+	if !c.relay.isInUse() {
 		_, err := c.destBlockBlobURL.StageBlockFromURL(ctxWithLatestServiceVersion, encodedBlockID, c.srcURL,
-			id.OffsetInFile(), adjustedChunkSize, azblob.LeaseAccessConditions{}, azblob.ModifiedAccessConditions{})
-		if err != nil {
+			id.OffsetInFile(), adjustedChunkSize, c.lease.leaseAccessConditions(), azblob.ModifiedAccessConditions{})
+		if err == nil {
+			return true
+		}
+		if !c.relay.shouldTakeOver(err) {
+			explainCopySourceAuthFailure(c.jptm, err)
 			c.jptm.FailActiveSend("Staging block from URL", err)
+			return false
+		}
+	}
+
+	// the destination can't read the source, so the block goes through this machine
+	err := c.relay.send(id.OffsetInFile(), adjustedChunkSize, func(body io.ReadSeeker) error {
+		_, err := c.destBlockBlobURL.StageBlock(c.jptm.Context(), encodedBlockID, body, c.lease.leaseAccessConditions(), nil)
+		return err
+	})
+	if err != nil {
+		c.jptm.FailActiveSend("Staging block relayed through the client", err)
+		return false
+	}
+	return true
+}
+
+// mayPutBlobFromURL tells whether a blob that's small enough can be copied with one Put Blob From URL,
+// rather than a Put Block From URL and a Put Block List. It can't be when the writes must meet a destination condition,
+// which the Put Block List carries
+func (c *urlToBlockBlobCopier) mayPutBlobFromURL() bool {
+	return !c.relay.isInUse() && atomic.LoadInt32(&putBlobFromURLUnsupported) == 0 && ServiceVersionSupports(putBlobFromURLServiceVersion) &&
+		!c.jptm.DestinationCondition().IsSet()
+}
+
+// generatePutBlobFromURL generates a func to copy the whole of a small blob, with its properties, in one request.
+// If the destination doesn't know Put Blob From URL, or can't read the source, the blob is copied as one block instead,
+// which is committed right away, since there's no other chunk to wait for.
+func (c *urlToBlockBlobCopier) generatePutBlobFromURL(id common.ChunkID, blockIndex int32, adjustedChunkSize int64) chunkFunc {
+	return createSendToRemoteChunkFunc(c.jptm, id, func() {
+		jptm := c.jptm
+
+		jptm.LogChunkStatus(id, common.EWaitReason.S2SCopyOnWire())
+		if err := c.pacer.RequestTrafficAllocation(jptm.Context(), adjustedChunkSize); err != nil {
+			jptm.FailActiveUpload("Pacing block", err)
+		}
+
+		ctx := withCopySourceAuthorization(context.WithValue(jptm.Context(), ServiceAPIVersionOverride, putBlobFromURLServiceVersion), jptm)
+		err := putBlobFromURL(ctx, c.pipeline, c.destBlockBlobURL.URL(), c.srcURL, c.headersToApply, c.metadataToApply, c.destBlobTier, jptm.BlobTags(), c.lease.leaseID())
+		if err == nil {
+			c.tierSetOnCreate = true
+			return
+		}
+		if isPutBlobFromURLUnsupported(err) {
+			if atomic.CompareAndSwapInt32(&putBlobFromURLUnsupported, 0, 1) {
+				jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+					"The destination does not support Put Blob From URL, so small blobs are copied as one block from now on. The error was: "+err.Error())
+			}
+		} else if !c.relay.shouldTakeOver(err) {
+			explainCopySourceAuthFailure(jptm, err)
+			jptm.FailActiveSend("Putting blob from URL", err)
 			return
 		}
+
+		encodedBlockID := c.blockIDFor(id.OffsetInFile())
+		c.setBlockID(blockIndex, encodedBlockID)
+		if !c.stageBlockFromURL(id, encodedBlockID, adjustedChunkSize) {
+			return
+		}
+		if _, err := c.destBlockBlobURL.CommitBlockList(jptm.Context(), []string{encodedBlockID}, c.headersToApply, c.metadataToApply, destinationWriteConditions(jptm, c.lease)); err != nil {
+			jptm.FailActiveSend("Committing block list", err)
+		}
 	})
 }
 
@@ -158,3 +252,136 @@ func (c *urlToBlockBlobCopier) GetDestinationLength() (int64, error) {
 
 	return properties.ContentLength(), nil
 }
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Put Blob From URL was introduced in this service version, which is newer than the one our blob SDK targets
+const putBlobFromURLServiceVersion = "2020-04-08"
+
+// putBlobFromURLThreshold is the size up to which a block blob is copied with one Put Blob From URL, whatever the block size
+const putBlobFromURLThreshold = int64(common.DefaultBlockBlobBlockSize)
+
+// set once a destination rejects Put Blob From URL, so that the other transfers don't try it too
+var putBlobFromURLUnsupported int32
+
+// putBlobFromURL creates (or replaces) the block blob at destURL with the content of srcURL, and the given properties, in one request.
+// The leaseID is that of the lease on the destination, if any.
+// The version of the blob SDK we use does not support it, so we issue the request ourselves
+func putBlobFromURL(ctx context.Context, p pipeline.Pipeline, destURL url.URL, srcURL url.URL, headers azblob.BlobHTTPHeaders,
+	metadata azblob.Metadata, tier azblob.AccessTierType, tags common.BlobTags, leaseID string) error {
+	req, err := pipeline.NewRequest(http.MethodPut, destURL, nil)
+	if err != nil {
+		return pipeline.NewError(err, "failed to create request")
+	}
+	req.Header.Set("x-ms-blob-type", string(azblob.BlobBlockBlob))
+	req.Header.Set("x-ms-copy-source", srcURL.String())
+	req.Header.Set("Content-Length", "0")
+
+	setIfNotEmpty := func(key, value string) {
+		if value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	setIfNotEmpty("x-ms-blob-content-type", headers.ContentType)
+	setIfNotEmpty("x-ms-blob-content-encoding", headers.ContentEncoding)
+	setIfNotEmpty("x-ms-blob-content-language", headers.ContentLanguage)
+	setIfNotEmpty("x-ms-blob-content-disposition", headers.ContentDisposition)
+	setIfNotEmpty("x-ms-blob-cache-control", headers.CacheControl)
+	if len(headers.ContentMD5) > 0 {
+		req.Header.Set("x-ms-blob-content-md5", base64.StdEncoding.EncodeToString(headers.ContentMD5))
+	}
+	for k, v := range metadata {
+		req.Header.Set("x-ms-meta-"+k, v)
+	}
+	setIfNotEmpty("x-ms-access-tier", string(tier))
+	if len(tags) > 0 {
+		req.Header.Set("x-ms-tags", encodeBlobTagsHeader(tags))
+	}
+	setIfNotEmpty("x-ms-lease-id", leaseID)
+
+	// the version policy in our pipeline overwrites x-ms-version from the context, so the newer version must be set there by the caller
+	resp, err := p.Do(ctx, newRawBlobResponderFactory(http.StatusCreated), req)
+	if err != nil {
+		return err
+	}
+	return checkPutBlobFromURLResponse(resp.Response().Header, headers.ContentMD5)
+}
+
+// encodeBlobTagsHeader encodes the tags as the x-ms-tags header wants them, which is like a query string, in the order of their keys
+func encodeBlobTagsHeader(tags common.BlobTags) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(tags[k]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// checkPutBlobFromURLResponse checks the hashes that the service computed of what it wrote, when it returns them.
+// The CRC64 can only be checked for its form, since nothing else gives the CRC64 of the source,
+// but the MD5 must be that of the source, when the source has one
+func checkPutBlobFromURLResponse(header http.Header, sourceMD5 []byte) error {
+	if crc := header.Get("x-ms-content-crc64"); crc != "" {
+		if b, err := base64.StdEncoding.DecodeString(crc); err != nil || len(b) != 8 {
+			return fmt.Errorf("the service returned an invalid CRC64 of the blob it wrote: %q", crc)
+		}
+	}
+	if md5Header := header.Get("Content-MD5"); md5Header != "" && len(sourceMD5) == md5.Size {
+		written, err := base64.StdEncoding.DecodeString(md5Header)
+		if err != nil || !bytes.Equal(written, sourceMD5) {
+			return errors.New("the MD5 of the blob that the service wrote is not that of the source")
+		}
+	}
+	return nil
+}
+
+// isPutBlobFromURLUnsupported tells whether the error says that the destination doesn't know Put Blob From URL,
+// e.g. because it's an emulator, or a version of the service that predates it, rather than that the copy failed
+func isPutBlobFromURLUnsupported(err error) bool {
+	serviceCode, status, _ := ErrorEx{err}.ErrorCodeAndString()
+	switch status {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	case http.StatusBadRequest:
+		switch azblob.ServiceCodeType(serviceCode) {
+		case azblob.ServiceCodeInvalidHeaderValue, azblob.ServiceCodeUnsupportedHeader, azblob.ServiceCodeInvalidQueryParameterValue,
+			azblob.ServiceCodeMissingRequiredHeader, azblob.ServiceCodeUnsupportedHTTPVerb:
+			return true
+		}
+	}
+	return false
+}
+
+// newRawBlobResponderFactory is the responder of the blob requests that we issue ourselves, which succeed with the given status
+func newRawBlobResponderFactory(successStatus int) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			resp, err := next.Do(ctx, request)
+			if err != nil {
+				return resp, err
+			}
+
+			r := resp.Response()
+			defer r.Body.Close()
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return resp, err
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(b)) // so that the callers that want what it says can still read it
+			if r.StatusCode != successStatus {
+				// it's a storage error like those of the SDK, so that what the rest of the engine does with those (e.g. the relay) works for it too
+				responseErr := azblob.NewResponseError(nil, r, r.Status)
+				if len(b) > 0 {
+					_ = xml.Unmarshal(b, &responseErr)
+				}
+				return resp, responseErr
+			}
+			return resp, nil
+		}
+	})
+}
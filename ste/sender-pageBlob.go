@@ -24,8 +24,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -53,6 +55,12 @@ type pageBlobSenderBase struct {
 	// Using a automatic pacer here lets us find the right rate for this particular page blob, at which
 	// we won't be trying to move the faster than the Service wants us to.
 	filePacer autopacer
+
+	// the lease on the destination while it's written, if the job protects its destinations with leases
+	lease *destinationLease
+
+	// so that a soft-deleted destination can be told apart from one that doesn't exist
+	softDeletableBlob
 }
 
 const (
@@ -103,16 +111,17 @@ func newPageBlobSenderBase(jptm IJobPartTransferMgr, destination string, p pipel
 	}
 
 	s := &pageBlobSenderBase{
-		jptm:            jptm,
-		destPageBlobURL: destPageBlobURL,
-		srcSize:         srcSize,
-		chunkSize:       chunkSize,
-		numChunks:       numChunks,
-		pacer:           pacer,
-		headersToApply:  props.SrcHTTPHeaders.ToAzBlobHTTPHeaders(),
-		metadataToApply: props.SrcMetadata.ToAzBlobMetadata(),
-		destBlobTier:    destBlobTier,
-		filePacer:       newNullAutoPacer(), // defer creation of real one to Prologue
+		jptm:              jptm,
+		destPageBlobURL:   destPageBlobURL,
+		srcSize:           srcSize,
+		chunkSize:         chunkSize,
+		numChunks:         numChunks,
+		pacer:             pacer,
+		headersToApply:    props.SrcHTTPHeaders.ToAzBlobHTTPHeaders(),
+		metadataToApply:   destinationMetadata(jptm, props.SrcMetadata).ToAzBlobMetadata(),
+		destBlobTier:      destBlobTier,
+		filePacer:         newNullAutoPacer(), // defer creation of real one to Prologue
+		softDeletableBlob: newSoftDeletableBlob(jptm.Context(), *destURL, p),
 	}
 
 	if s.isInManagedDiskImportExportAccount() && jptm.ShouldPutMd5() {
@@ -158,6 +167,15 @@ func (s *pageBlobSenderBase) Prologue(ps common.PrologueState) (destinationModif
 	// so we know that the pacer will be closed.  // TODO: consider re-factor xfer-anyToRemote so that epilogue is always called if uploader is constructed, and move this to constructor
 	s.filePacer = newPageBlobAutoPacer(pageBlobInitialBytesPerSecond, s.ChunkSize(), false, s.jptm.(common.ILogger))
 
+	if s.jptm.DiffBaseSnapshot() != "" {
+		// The destination already holds the base snapshot, and is updated in place, so it must not be (re)created.
+		// The copier checks that it really holds the base snapshot. That includes its size, so no check is needed here for managed disks
+		if s.jptm.ProtectDestinationWithLease() {
+			s.leaseDestination()
+		}
+		return false
+	}
+
 	if s.isInManagedDiskImportExportAccount() {
 		// Target will already exist (and CANNOT be created through the REST API, because
 		// managed-disk import-export accounts have restricted API surface)
@@ -207,8 +225,16 @@ func (s *pageBlobSenderBase) Prologue(ps common.PrologueState) (destinationModif
 		0,
 		s.headersToApply,
 		s.metadataToApply,
-		azblob.BlobAccessConditions{}); err != nil {
-		s.jptm.FailActiveSend("Creating blob", err)
+		destinationWriteConditions(s.jptm, nil)); err != nil {
+		if s.jptm.ProtectDestinationWithLease() {
+			failDestinationLease(s.jptm, "Creating blob", err)
+		} else {
+			s.jptm.FailActiveSend("Creating blob", err)
+		}
+		return
+	}
+
+	if s.jptm.ProtectDestinationWithLease() && !s.leaseDestination() {
 		return
 	}
 
@@ -220,7 +246,16 @@ func (s *pageBlobSenderBase) Prologue(ps common.PrologueState) (destinationModif
 		if err := blockBlobTier.Parse(string(s.destBlobTier)); err != nil { // i.e it's not block blob tier
 			// Set the latest service version from sdk as service version in the context.
 			ctxWithLatestServiceVersion := context.WithValue(s.jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion)
-			if _, err := s.destPageBlobURL.SetTier(ctxWithLatestServiceVersion, s.destBlobTier, azblob.LeaseAccessConditions{}); err != nil {
+			if _, err := s.destPageBlobURL.SetTier(ctxWithLatestServiceVersion, s.destBlobTier, s.lease.leaseAccessConditions()); err != nil {
+				if s.isPremiumTierUnsupportedByDestination(err) {
+					// the premium tiers of page blobs only exist in premium accounts, so the blob is left with the default tier of the destination
+					s.jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+						fmt.Sprintf("The destination does not support the page blob tier %s of the source, so it was not preserved", s.destBlobTier))
+					s2sPremiumPageBlobTierSkipLogStdout.Do(func() {
+						common.GetLifecycleMgr().Info("The premium tiers of one or more page blobs were not preserved, since the destination is not a premium account.")
+					})
+					return
+				}
 				if s.jptm.Info().S2SSrcBlobTier != azblob.AccessTierNone {
 					s.jptm.LogTransferInfo(pipeline.LogError, s.jptm.Info().Source, s.jptm.Info().Destination, "Failed to replicate blob tier at destination. Try transferring with the flag --s2s-preserve-access-tier=false")
 					s2sAccessTierFailureLogStdout.Do(func() {
@@ -238,8 +273,20 @@ func (s *pageBlobSenderBase) Prologue(ps common.PrologueState) (destinationModif
 	return
 }
 
+// leaseDestination leases the destination blob, so that nothing else can write to it while it's written. It returns whether it's leased
+func (s *pageBlobSenderBase) leaseDestination() bool {
+	lease, err := acquireDestinationLease(s.jptm, blobLeaseOperations{blobURL: s.destPageBlobURL.BlobURL}, s.destPageBlobURL.URL())
+	if err != nil {
+		failDestinationLease(s.jptm, "Leasing the blob", err)
+		return false
+	}
+	s.lease = lease
+	return true
+}
+
 func (s *pageBlobSenderBase) Epilogue() {
 	_ = s.filePacer.Close() // release resources
+	s.lease.release()
 }
 
 func (s *pageBlobSenderBase) Cleanup() {
@@ -247,8 +294,13 @@ func (s *pageBlobSenderBase) Cleanup() {
 
 	// Cleanup
 	if jptm.IsDeadInflight() {
-		if s.isInManagedDiskImportExportAccount() {
+		if skippedForDestinationCondition(jptm) {
+			// the blob is what something else wrote, so it's left as it is
+		} else if s.isInManagedDiskImportExportAccount() {
 			// no deletion is possible. User just has to upload it again.
+		} else if jptm.DiffBaseSnapshot() != "" {
+			// it held the base snapshot before this transfer, so it's kept. Copying the same changes again completes it
+			jptm.Log(pipeline.LogWarning, "The destination was partly updated with the changes since the base snapshot. Run the same copy again to complete it")
 		} else {
 			deletionContext, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancelFunc()
@@ -259,3 +311,21 @@ func (s *pageBlobSenderBase) Cleanup() {
 		}
 	}
 }
+
+// This sync.Once is present to ensure we output information about not preserving premium page blob tiers to stdout once
+var s2sPremiumPageBlobTierSkipLogStdout sync.Once
+
+// isPremiumTierUnsupportedByDestination tells whether setting the tier failed because a premium tier
+// that was preserved from the source can't be used at the destination, e.g. because that's a standard account.
+// A tier that the user asked for explicitly still fails the transfer.
+func (s *pageBlobSenderBase) isPremiumTierUnsupportedByDestination(err error) bool {
+	if _, pageBlobTierOverride := s.jptm.BlobTiers(); pageBlobTierOverride != common.EPageBlobTier.None() {
+		return false
+	}
+	var pageBlobTier common.PageBlobTier
+	if s.jptm.Info().S2SSrcBlobTier != s.destBlobTier || pageBlobTier.Parse(string(s.destBlobTier)) != nil {
+		return false
+	}
+	_, status, _ := ErrorEx{err}.ErrorCodeAndString()
+	return status == http.StatusBadRequest || status == http.StatusConflict
+}
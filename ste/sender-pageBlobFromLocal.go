@@ -79,7 +79,7 @@ func (u *pageBlobUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int3
 		jptm.LogChunkStatus(id, common.EWaitReason.Body())
 		body := newPacedRequestBody(jptm.Context(), reader, u.pacer)
 		enrichedContext := withRetryNotification(jptm.Context(), u.filePacer)
-		_, err := u.destPageBlobURL.UploadPages(enrichedContext, id.OffsetInFile(), body, azblob.PageBlobAccessConditions{}, nil)
+		_, err := u.destPageBlobURL.UploadPages(enrichedContext, id.OffsetInFile(), body, u.lease.pageBlobAccessConditions(), nil)
 		if err != nil {
 			jptm.FailActiveUpload("Uploading page", err)
 			return
@@ -95,7 +95,7 @@ func (u *pageBlobUploader) Epilogue() {
 		tryPutMd5Hash(jptm, u.md5Channel, func(md5Hash []byte) error {
 			epilogueHeaders := u.headersToApply
 			epilogueHeaders.ContentMD5 = md5Hash
-			_, err := u.destPageBlobURL.SetHTTPHeaders(jptm.Context(), epilogueHeaders, azblob.BlobAccessConditions{})
+			_, err := u.destPageBlobURL.SetHTTPHeaders(jptm.Context(), epilogueHeaders, u.lease.blobAccessConditions())
 			return err
 		})
 	}
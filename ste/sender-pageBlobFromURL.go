@@ -22,6 +22,8 @@ package ste
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/url"
 	"strings"
 
@@ -36,6 +38,9 @@ type urlToPageBlobCopier struct {
 
 	srcURL             url.URL
 	pageRangeOptimizer *pageRangeOptimizer // nil if src is not a page blob
+	relay              *clientRelay        // nil unless the transfer may fall back to relaying the data through this machine
+	srcPageBlobURL     azblob.PageBlobURL  // only used for incremental copies from a base snapshot
+	diff               *pageBlobDiff       // set in the prologue of an incremental copy, to the changes since the base snapshot
 }
 
 func newURLToPageBlobCopier(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, srcInfoProvider IRemoteSourceInfoProvider) (s2sCopier, error) {
@@ -46,16 +51,28 @@ func newURLToPageBlobCopier(jptm IJobPartTransferMgr, destination string, p pipe
 
 	destBlobTier := azblob.AccessTierNone
 	var pageRangeOptimizer *pageRangeOptimizer
+	var srcPageBlobURL azblob.PageBlobURL
+	isPageBlobSource := false
 	if blobSrcInfoProvider, ok := srcInfoProvider.(IBlobSourceInfoProvider); ok {
 		if blobSrcInfoProvider.BlobType() == azblob.BlobPageBlob {
+			isPageBlobSource = true
 			// if the source is page blob, preserve source's blob tier.
 			destBlobTier = blobSrcInfoProvider.BlobTier()
 
 			// capture the necessary info so that we can perform optimizations later
-			pageRangeOptimizer = newPageRangeOptimizer(azblob.NewPageBlobURL(*srcURL, p),
+			// (a source without a SAS has to be read with the credential of its own, rather than that of the destination)
+			srcPipeline := p
+			if jptm.S2SSourceTokenCredential() != nil {
+				srcPipeline = jptm.SourceProviderPipeline()
+			}
+			srcPageBlobURL = azblob.NewPageBlobURL(*srcURL, srcPipeline)
+			pageRangeOptimizer = newPageRangeOptimizer(srcPageBlobURL,
 				context.WithValue(jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion))
 		}
 	}
+	if jptm.DiffBaseSnapshot() != "" && !isPageBlobSource {
+		return nil, errNotAPageBlob
+	}
 
 	senderBase, err := newPageBlobSenderBase(jptm, destination, p, pacer, srcInfoProvider, destBlobTier)
 	if err != nil {
@@ -65,12 +82,21 @@ func newURLToPageBlobCopier(jptm IJobPartTransferMgr, destination string, p pipe
 	return &urlToPageBlobCopier{
 		pageBlobSenderBase: *senderBase,
 		srcURL:             *srcURL,
-		pageRangeOptimizer: pageRangeOptimizer}, nil
+		pageRangeOptimizer: pageRangeOptimizer,
+		relay:              newClientRelay(jptm, *srcURL),
+		srcPageBlobURL:     srcPageBlobURL}, nil
 }
 
 func (c *urlToPageBlobCopier) Prologue(ps common.PrologueState) (destinationModified bool) {
 	destinationModified = c.pageBlobSenderBase.Prologue(ps)
 
+	if c.jptm.DiffBaseSnapshot() != "" {
+		if !c.jptm.IsLive() {
+			return // e.g. the destination couldn't be leased
+		}
+		return c.prologueForDiff()
+	}
+
 	if c.pageRangeOptimizer != nil {
 		c.pageRangeOptimizer.fetchPages()
 	}
@@ -78,6 +104,49 @@ func (c *urlToPageBlobCopier) Prologue(ps common.PrologueState) (destinationModi
 	return
 }
 
+// prologueForDiff gets the changes since the base snapshot, and checks that the destination holds that snapshot, before
+// it brings the destination to the size of the source and clears the pages that were cleared since the base snapshot.
+// The chunks then copy the changed pages.
+func (c *urlToPageBlobCopier) prologueForDiff() (destinationModified bool) {
+	diff, err := getPageBlobDiff(c.jptm.Context(), c.srcPageBlobURL, c.jptm.DiffBaseSnapshot())
+	if err != nil {
+		c.jptm.FailActiveS2SCopy("Getting the changes since the base snapshot", err)
+		return false
+	}
+
+	props, err := c.destPageBlobURL.GetProperties(c.jptm.Context(), azblob.BlobAccessConditions{})
+	if err != nil {
+		c.jptm.FailActiveS2SCopy("Checking that the destination holds the base snapshot", err)
+		return false
+	}
+	if err := diff.validateDestination(props.ContentLength(), props.ContentMD5()); err != nil {
+		c.jptm.FailActiveS2SCopy("Checking that the destination holds the base snapshot", err)
+		return false
+	}
+
+	if c.srcSize != props.ContentLength() {
+		if _, err := c.destPageBlobURL.Resize(c.jptm.Context(), c.srcSize, c.lease.blobAccessConditions()); err != nil {
+			c.jptm.FailActiveS2SCopy("Resizing the destination", err)
+			return true
+		}
+	}
+	for _, r := range diff.cleared {
+		if r.Start >= c.srcSize {
+			continue // gone with the resize
+		}
+		if _, err := c.destPageBlobURL.ClearPages(c.jptm.Context(), r.Start, minInt64(r.End, c.srcSize-1)-r.Start+1, c.lease.pageBlobAccessConditions()); err != nil {
+			c.jptm.FailActiveS2SCopy("Clearing the pages that were cleared since the base snapshot", err)
+			return true
+		}
+	}
+
+	c.diff = diff
+	c.jptm.ReportPageBlobDiff(diff.changedBytes(), c.srcSize)
+	c.jptm.Log(pipeline.LogInfo, fmt.Sprintf("Copying the %d bytes that changed since the base snapshot %s (%d ranges), and clearing %d ranges",
+		diff.changedBytes(), diff.baseSnapshot, len(diff.changed), len(diff.cleared)))
+	return true
+}
+
 // Returns a chunk-func for blob copies
 func (c *urlToPageBlobCopier) GenerateCopyFunc(id common.ChunkID, blockIndex int32, adjustedChunkSize int64, chunkIsWholeFile bool) chunkFunc {
 
@@ -93,6 +162,14 @@ func (c *urlToPageBlobCopier) GenerateCopyFunc(id common.ChunkID, blockIndex int
 			return
 		}
 
+		// for an incremental copy, only the pages that changed since the base snapshot are copied
+		ranges := []azblob.PageRange{{Start: id.OffsetInFile(), End: id.OffsetInFile() + adjustedChunkSize - 1}}
+		if c.diff != nil {
+			if ranges = c.diff.changedRangesIn(id.OffsetInFile(), adjustedChunkSize); len(ranges) == 0 {
+				return
+			}
+		}
+
 		// control rate of sending (since page blobs can effectively have per-blob throughput limits)
 		// Note that this level of control here is specific to the individual page blob, and is additional
 		// to the application-wide pacing that we do with c.pacer
@@ -101,10 +178,11 @@ func (c *urlToPageBlobCopier) GenerateCopyFunc(id common.ChunkID, blockIndex int
 			c.jptm.FailActiveUpload("Pacing block (file level)", err)
 		}
 
-		// set the latest service version from sdk as service version in the context, to use UploadPagesFromURL API.
+		// set the latest service version from sdk as service version in the context, to use UploadPagesFromURL API
+		// (or a later one, if the source is read with an OAuth token).
 		// AND enrich the context for 503 (ServerBusy) detection
 		enrichedContext := withRetryNotification(
-			context.WithValue(c.jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion),
+			withCopySourceAuthorization(context.WithValue(c.jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion), c.jptm),
 			c.filePacer)
 
 		// upload the page (including application of global pacing. We don't have a separate wait reason for global pacing
@@ -113,14 +191,41 @@ func (c *urlToPageBlobCopier) GenerateCopyFunc(id common.ChunkID, blockIndex int
 		if err := c.pacer.RequestTrafficAllocation(c.jptm.Context(), adjustedChunkSize); err != nil {
 			c.jptm.FailActiveUpload("Pacing block (global level)", err)
 		}
+		for _, r := range ranges {
+			if !c.copyRange(enrichedContext, r.Start, r.End-r.Start+1) {
+				return
+			}
+		}
+	})
+}
+
+// copyRange copies the range from the source, and returns false if that failed the transfer
+func (c *urlToPageBlobCopier) copyRange(enrichedContext context.Context, offset int64, count int64) bool {
+	if !c.relay.isInUse() {
 		_, err := c.destPageBlobURL.UploadPagesFromURL(
-			enrichedContext, c.srcURL, id.OffsetInFile(), id.OffsetInFile(), adjustedChunkSize, nil,
-			azblob.PageBlobAccessConditions{}, azblob.ModifiedAccessConditions{})
-		if err != nil {
+			enrichedContext, c.srcURL, offset, offset, count, nil,
+			c.lease.pageBlobAccessConditions(), azblob.ModifiedAccessConditions{})
+		if err == nil {
+			return true
+		}
+		if !c.relay.shouldTakeOver(err) {
+			explainCopySourceAuthFailure(c.jptm, err)
 			c.jptm.FailActiveS2SCopy("Uploading page from URL", err)
-			return
+			return false
 		}
+	}
+
+	// the destination can't read the source, so the page goes through this machine
+	err := c.relay.send(offset, count, func(body io.ReadSeeker) error {
+		_, err := c.destPageBlobURL.UploadPages(withRetryNotification(c.jptm.Context(), c.filePacer), offset, body,
+			c.lease.pageBlobAccessConditions(), nil)
+		return err
 	})
+	if err != nil {
+		c.jptm.FailActiveS2SCopy("Uploading page relayed through the client", err)
+		return false
+	}
+	return true
 }
 
 // GetDestinationLength gets the destination length.
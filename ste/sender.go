@@ -66,6 +66,28 @@ type ISenderBase interface {
 	GetDestinationLength() (int64, error)
 }
 
+// tailSender is implemented by the senders that may only send the end of the source, since the destination already has the rest of it,
+// as is the case for the append-only uploads of files that have grown since they were last uploaded
+type tailSender interface {
+	// FirstOffset is where the first chunk starts. The chunks before it are not sent, nor counted in NumChunks
+	FirstOffset() int64
+}
+
+// firstOffsetOf returns where the first chunk that the sender sends starts
+func firstOffsetOf(s ISenderBase) int64 {
+	if ts, ok := s.(tailSender); ok {
+		return ts.FirstOffset()
+	}
+	return 0
+}
+
+// temporaryNameSender is implemented by the senders that may write the destination under a temporary name,
+// so that nothing picks it up before it's complete
+type temporaryNameSender interface {
+	// RenameToFinalName gives the destination its own name, once it's written in full and checked. It fails the transfer if it can't
+	RenameToFinalName()
+}
+
 type senderFactory func(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, sip ISourceInfoProvider) (ISenderBase, error)
 
 /////////////////////////////////////////////////////////////////////////////////////////////////
@@ -119,6 +141,15 @@ var errNoHash = errors.New("no hash computed")
 
 /////////////////////////////////////////////////////////////////////////////////////////////////
 
+// destinationMetadata is the metadata that the sender gives the destination: that of the source, with the last modified time
+// of the source kept in it when the job asks for it (--preserve-source-lmt-as-metadata), as the destination can't have it set natively
+func destinationMetadata(jptm IJobPartTransferMgr, srcMetadata common.Metadata) common.Metadata {
+	if !jptm.PreserveSourceLMTAsMetadata() {
+		return srcMetadata
+	}
+	return srcMetadata.WithPreservedLastModifiedTime(jptm.LastModifiedTime())
+}
+
 func getNumChunks(fileSize int64, chunkSize uint32) uint32 {
 	numChunks := uint32(1) // we always map zero-size source files to ONE (empty) chunk
 	if fileSize > 0 {
@@ -0,0 +1,91 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// RequestAPIVersion is the service version that every request to the blob, file and ADLS Gen2 services is sent with,
+// if the user pinned one (AZCOPY_REQUEST_API_VERSION), for a service such as Azure Stack Hub that rejects the versions that azcopy uses.
+// Unlike DefaultServiceApiVersion, it also wins over the newer versions that some features ask for, which is why
+// those features are turned off when it's older than they need. Empty if no version is pinned.
+var RequestAPIVersion = common.GetLifecycleMgr().GetEnvironmentVariable(common.EEnvironmentVariable.RequestApiVersion())
+
+var serviceVersionFormat = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// ValidateRequestAPIVersion makes sure that a pinned service version looks like one, since versions are compared as strings
+func ValidateRequestAPIVersion(version string) error {
+	if version != "" && !serviceVersionFormat.MatchString(version) {
+		return errors.New("the service version must be a date, such as 2019-02-02")
+	}
+	return nil
+}
+
+// ServiceVersionSupports tells whether the requests can use what the service introduced in the given version
+func ServiceVersionSupports(minVersion string) bool {
+	// the versions are dates in the form YYYY-MM-DD, so they sort as strings do
+	return RequestAPIVersion == "" || RequestAPIVersion >= minVersion
+}
+
+// VersionedFeature is something that the user asked for, which needs a newer service version than the requests are sent with by default.
+// The features that azcopy only uses to go faster (e.g. Put Blob From URL) aren't listed, since they simply aren't used on older versions.
+type VersionedFeature struct {
+	Name       string
+	MinVersion string
+}
+
+var (
+	FeatureBlobTags            = VersionedFeature{Name: "Setting blob index tags", MinVersion: blobTagsServiceVersion}
+	FeatureCopySourceOAuth     = VersionedFeature{Name: "Reading the source of a copy with an OAuth token", MinVersion: copySourceAuthServiceVersion}
+	FeatureAzureFileRenameTemp = VersionedFeature{Name: "Writing Azure Files under a temporary name", MinVersion: azureFileRenameServiceVersion}
+)
+
+// Supported tells whether the feature can be used with the service version that the requests are sent with
+func (f VersionedFeature) Supported() bool {
+	return ServiceVersionSupports(f.MinVersion)
+}
+
+// DisabledWarning explains to the user why the feature is turned off
+func (f VersionedFeature) DisabledWarning() string {
+	return fmt.Sprintf("%s is turned off, since it needs service version %s or later, and %s pins the requests to version %s.",
+		f.Name, f.MinVersion, common.EEnvironmentVariable.RequestApiVersion().Name, RequestAPIVersion)
+}
+
+// newRequestAPIVersionPolicyFactory creates a factory that sends the request with the pinned service version (if there is one),
+// whatever version the SDK, or the context, set. It must therefore come after any policy that sets the version.
+func newRequestAPIVersionPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if RequestAPIVersion != "" {
+				request.Header.Set("x-ms-version", RequestAPIVersion)
+			}
+			return next.Do(ctx, request)
+		}
+	})
+}
@@ -0,0 +1,253 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// the wait between the looks at the usage and the quota of a share that's full, while its transfers wait for room.
+// A var so that tests needn't wait
+var shareQuotaRecheckInterval = 30 * time.Second
+
+// the error code of the writes to a share that has reached its quota. The file SDK has no constant for it
+const serviceCodeShareSizeLimitReached = "ShareSizeLimitReached"
+
+const bytesPerGiB = 1024 * 1024 * 1024
+
+// shareUsage is how the bytes that a share holds, and its quota, are looked up. Tests replace it
+var shareUsage = getShareUsage
+
+// isShareFull tells whether a write to an Azure file failed because its share is full,
+// or whether its transfer was kept from starting for that reason
+func isShareFull(err error) bool {
+	if _, ok := err.(shareFullError); ok {
+		return true
+	}
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	return serviceCode == serviceCodeShareSizeLimitReached
+}
+
+// shareFullError is what a transfer fails with when its share is full, and got no room within the wait that the job allows
+type shareFullError struct {
+	share common.FullShare
+}
+
+func (e shareFullError) Error() string {
+	return "share full: " + e.share.String()
+}
+
+// shareQuotaGuard keeps the transfers of a job away from the Azure file shares that the job found full, so that they don't each
+// find it out in turn. Once a write finds its share full, the usage and the quota of the share are looked up and logged, and the
+// transfers to it that carry on, or start, from then on wait for its quota to be raised (or for room to be freed), for as long as
+// the job allows since the share was found full (--wait-on-share-full), or fail with the status that says the share is full.
+// The transfers that completed are left as they are, so that resuming the job once the quota is raised only transfers the rest.
+// A nil guard lets everything through.
+type shareQuotaGuard struct {
+	log func(msg string)
+
+	lock sync.Mutex
+	// by the URL of the share, without its SAS
+	full map[string]*fullShare
+}
+
+type fullShare struct {
+	common.FullShare
+	checkedAt time.Time
+}
+
+func newShareQuotaGuard(log func(msg string)) *shareQuotaGuard {
+	return &shareQuotaGuard{log: log, full: make(map[string]*fullShare)}
+}
+
+// shareOf returns the URL of the share of an Azure file, without its SAS (which is how the share is known to the guard), and with it
+func shareOf(fileURL url.URL) (name string, shareURL url.URL) {
+	parts := azfile.NewFileURLParts(fileURL)
+	parts.DirectoryOrFilePath = ""
+	shareURL = parts.URL()
+	parts.SAS = azfile.SASQueryParameters{}
+	bare := parts.URL()
+	return bare.String(), shareURL
+}
+
+// markFull notes that a write to the Azure file at fileURL found its share full, unless that's already known
+func (g *shareQuotaGuard) markFull(ctx context.Context, p pipeline.Pipeline, fileURL url.URL) {
+	if g == nil {
+		return
+	}
+
+	name, shareURL := shareOf(fileURL)
+	g.lock.Lock()
+	_, known := g.full[name]
+	g.lock.Unlock()
+	if known {
+		return
+	}
+
+	// looked up outside the lock, so that the transfers to the other shares aren't held up by it. If another write marks the share
+	// meanwhile, the first to get the lock back wins
+	s := &fullShare{FullShare: common.FullShare{Share: name, Since: time.Now()}, checkedAt: time.Now()}
+	used, quota, err := shareUsage(ctx, p, shareURL)
+	if err == nil {
+		s.UsedBytes, s.QuotaGiB = used, quota
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if _, known = g.full[name]; known {
+		return
+	}
+	g.full[name] = s
+	if err != nil {
+		g.log(fmt.Sprintf("Share full: %s has reached its quota. Its usage and quota could not be looked up: %s", name, err))
+		return
+	}
+	g.log("Share full: " + s.String() + ". No more transfers to it are started until its quota is raised.")
+}
+
+// waitWhileFull blocks while the share of the Azure file at fileURL is full, for up to window since it was found full.
+// It returns nil once the share has room (or if it was never found full), and a shareFullError if it still hasn't by then
+func (g *shareQuotaGuard) waitWhileFull(ctx context.Context, p pipeline.Pipeline, fileURL url.URL, window time.Duration) error {
+	if g == nil {
+		return nil
+	}
+
+	name, shareURL := shareOf(fileURL)
+	for {
+		g.lock.Lock()
+		s, full := g.full[name]
+		var snapshot common.FullShare
+		if full {
+			snapshot = s.FullShare
+		}
+		g.lock.Unlock()
+		if !full {
+			return nil
+		}
+
+		remaining := snapshot.Since.Add(window).Sub(time.Now())
+		if remaining <= 0 {
+			return shareFullError{share: snapshot}
+		}
+		wait := shareQuotaRecheckInterval
+		if remaining < wait {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		g.recheck(ctx, p, name, shareURL)
+	}
+}
+
+// recheck looks at the usage and the quota of a full share again, unless another transfer just did,
+// and lets the transfers to it carry on if its quota was raised, or room was freed in it
+func (g *shareQuotaGuard) recheck(ctx context.Context, p pipeline.Pipeline, name string, shareURL url.URL) {
+	g.lock.Lock()
+	s, full := g.full[name]
+	if !full || time.Since(s.checkedAt) < shareQuotaRecheckInterval {
+		g.lock.Unlock()
+		return
+	}
+	s.checkedAt = time.Now()
+	g.lock.Unlock()
+
+	used, quota, err := shareUsage(ctx, p, shareURL)
+	if err != nil {
+		return // it's looked at again on the next recheck
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if quota <= s.QuotaGiB && used >= s.UsedBytes {
+		s.UsedBytes, s.QuotaGiB = used, quota
+		return
+	}
+	delete(g.full, name)
+	g.log(fmt.Sprintf("Carrying on with the transfers to %s, which has used %.1f of its %d GiB quota now",
+		name, float64(used)/bytesPerGiB, quota))
+}
+
+// fullShares lists the shares that are full, ordered by their URL
+func (g *shareQuotaGuard) fullShares() []common.FullShare {
+	if g == nil {
+		return nil
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if len(g.full) == 0 {
+		return nil
+	}
+	shares := make([]common.FullShare, 0, len(g.full))
+	for _, s := range g.full {
+		shares = append(shares, s.FullShare)
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Share < shares[j].Share })
+	return shares
+}
+
+// getShareUsage looks up the bytes that a share holds, and its quota in GiB. The version of the file SDK we use reads the usage
+// into an int32, which the shares of 2 GiB or more overflow, so we issue the request for it ourselves
+func getShareUsage(ctx context.Context, p pipeline.Pipeline, shareURL url.URL) (usedBytes int64, quotaGiB int32, err error) {
+	props, err := azfile.NewShareURL(shareURL, p).GetProperties(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	req, err := pipeline.NewRequest(http.MethodGet, shareURL, nil)
+	if err != nil {
+		return 0, 0, pipeline.NewError(err, "failed to create request")
+	}
+	params := req.URL.Query()
+	params.Set("restype", "share")
+	params.Set("comp", "stats")
+	req.URL.RawQuery = params.Encode()
+	resp, err := p.Do(ctx, newRawBlobResponderFactory(http.StatusOK), req)
+	if err != nil {
+		return 0, 0, err
+	}
+	body, err := ioutil.ReadAll(resp.Response().Body)
+	if err != nil {
+		return 0, 0, err
+	}
+	var stats struct {
+		ShareUsageBytes int64 `xml:"ShareUsageBytes"`
+	}
+	if err = xml.Unmarshal(body, &stats); err != nil {
+		return 0, 0, err
+	}
+	return stats.ShareUsageBytes, props.Quota(), nil
+}
@@ -0,0 +1,212 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// the wait before the first retry of a write that failed with a sharing violation, which doubles for each retry after it,
+// up to sharingViolationRetryMaxDelay. A var so that tests needn't wait
+var sharingViolationRetryBaseDelay = time.Second
+
+const sharingViolationRetryMaxDelay = 30 * time.Second
+
+// the attribute of Azure files that keeps them from being overwritten, as the service names it
+const readOnlyFileAttribute = "ReadOnly"
+
+// isSharingViolation tells whether a write to an Azure file failed because something else has the file open over SMB, without sharing it
+func isSharingViolation(err error) bool {
+	serviceCode, status, _ := ErrorEx{err}.ErrorCodeAndString()
+	return status == http.StatusConflict && serviceCode == string(azfile.ServiceCodeSharingViolation)
+}
+
+// isReadOnlyAttributeInTheWay tells whether a write to an Azure file failed because the file has the ReadOnly attribute
+func isReadOnlyAttributeInTheWay(err error) bool {
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	return serviceCode == string(azfile.ServiceCodeReadOnlyAttribute)
+}
+
+// writeWithRetries does a write to the destination file, and gets what's in its way out of it, as far as the job allows:
+// the ReadOnly attribute is cleared, when the job forces the overwrite of read-only files (--force-if-read-only), and the write is retried
+// with backoff, when the job retries on sharing violations (--retry-on-sharing-violation), for as long as something else has the file
+// open over SMB, until the window is over. A write that finds the share full marks it so, and is retried once its quota is raised,
+// if that happens within the wait that the job allows (--wait-on-share-full). The writes of one file may be retried concurrently, by its chunks.
+func (u *azureFileSenderBase) writeWithRetries(write func() error) error {
+	jptm := u.jptm
+	window := jptm.SharingViolationRetryWindow()
+	var deadline time.Time
+	delay := sharingViolationRetryBaseDelay
+
+	for {
+		err := write()
+		switch {
+		case err == nil:
+			return nil
+		case isReadOnlyAttributeInTheWay(err) && jptm.ForceIfReadOnly():
+			if clearErr := u.clearReadOnlyAttribute(); clearErr != nil {
+				jptm.LogAtLevelForCurrentTransfer(pipeline.LogError, fmt.Sprintf("Failed to clear the ReadOnly attribute of the destination: %s", clearErr))
+				return err
+			}
+			continue
+		case isShareFull(err):
+			guard := jptm.GetShareQuotaGuard()
+			guard.markFull(u.ctx, u.pipeline, u.fileURL.URL())
+			if guard == nil || guard.waitWhileFull(jptm.Context(), u.pipeline, u.fileURL.URL(), jptm.ShareFullWaitWindow()) != nil {
+				return err
+			}
+			continue
+		case !isSharingViolation(err) || window <= 0:
+			return err
+		}
+
+		if deadline.IsZero() {
+			deadline = time.Now().Add(window)
+			u.sharingViolationReported.Do(jptm.ReportSharingViolationRetry)
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+			fmt.Sprintf("Something else has the destination open over SMB, so the write will be retried in %v", delay))
+
+		select {
+		case <-time.After(delay):
+		case <-jptm.Context().Done():
+			return err
+		}
+		if delay *= 2; delay > sharingViolationRetryMaxDelay {
+			delay = sharingViolationRetryMaxDelay
+		}
+	}
+}
+
+// failWrite fails the transfer, whose write to the destination failed, as fail does, unless it failed because the share is full,
+// in which case the transfer fails with the status that says so, for the job to be resumed once the quota is raised
+func (u *azureFileSenderBase) failWrite(where string, err error, fail func(where string, err error)) {
+	if isShareFull(err) {
+		u.jptm.FailActiveSendWithStatus(where+", since the share is full", err, common.ETransferStatus.ShareFull())
+		return
+	}
+	fail(where, err)
+}
+
+// clearReadOnlyAttribute clears the ReadOnly attribute of the destination file, and remembers the attributes it had, so that they can be
+// restored once it's written. Only the first call does anything, since the chunks of a file may all find the attribute in their way.
+// The properties of the file are those that the check for its existence fetched, if it was checked for
+func (u *azureFileSenderBase) clearReadOnlyAttribute() error {
+	u.readOnlyLock.Lock()
+	defer u.readOnlyLock.Unlock()
+	if u.attributesToRestore != "" {
+		return nil
+	}
+
+	props := u.destinationProps
+	if props == nil || u.fileURL.String() != u.finalURL.String() {
+		var err error
+		if props, err = u.fileURL.GetProperties(u.ctx); err != nil {
+			return err
+		}
+	}
+	attributes := props.FileAttributes()
+	if err := setAzureFileAttributes(u.ctx, u.pipeline, u.fileURL.URL(), withoutReadOnlyAttribute(attributes), props.NewHTTPHeaders()); err != nil {
+		return err
+	}
+	u.jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Cleared the ReadOnly attribute of the destination, so that it can be overwritten. It's restored once it's written")
+	u.attributesToRestore = attributes
+	return nil
+}
+
+// restoreReadOnlyAttribute gives the destination file back the attributes that it had when its ReadOnly attribute was cleared, if it was.
+// The file has the headers it was created with (and its MD5 hash, once that's set), so they're sent again as they are, without being fetched.
+// It tells whether there were attributes to restore
+func (u *azureFileSenderBase) restoreReadOnlyAttribute() (restored bool, err error) {
+	u.readOnlyLock.Lock()
+	defer u.readOnlyLock.Unlock()
+	if u.attributesToRestore == "" {
+		return false, nil
+	}
+
+	err = setAzureFileAttributes(u.ctx, u.pipeline, u.fileURL.URL(), u.attributesToRestore, u.headersToApply)
+	if err != nil {
+		u.jptm.LogAtLevelForCurrentTransfer(pipeline.LogError, fmt.Sprintf("Failed to restore the ReadOnly attribute of the destination, which was cleared to overwrite it: %s", err))
+		return true, err
+	}
+	u.attributesToRestore = ""
+	return true, nil
+}
+
+// withoutReadOnlyAttribute removes ReadOnly from attributes as the service lists them, e.g. "ReadOnly | Archive"
+func withoutReadOnlyAttribute(attributes string) string {
+	kept := make([]string, 0)
+	for _, a := range strings.Split(attributes, "|") {
+		if a = strings.TrimSpace(a); a != "" && !strings.EqualFold(a, readOnlyFileAttribute) && !strings.EqualFold(a, "None") {
+			kept = append(kept, a)
+		}
+	}
+	if len(kept) == 0 {
+		return "None"
+	}
+	return strings.Join(kept, " | ")
+}
+
+// setAzureFileAttributes sets the SMB attributes of an Azure file. Setting the properties of a file clears the content headers that
+// aren't sent, so those that the file is to have are sent with them. The version of the file SDK we use can't set the attributes,
+// so we issue the request ourselves
+func setAzureFileAttributes(ctx context.Context, p pipeline.Pipeline, fileURL url.URL, attributes string, headers azfile.FileHTTPHeaders) error {
+	req, err := pipeline.NewRequest(http.MethodPut, fileURL, nil)
+	if err != nil {
+		return pipeline.NewError(err, "failed to create request")
+	}
+	params := req.URL.Query()
+	params.Set("comp", "properties")
+	req.URL.RawQuery = params.Encode()
+	req.Header.Set("x-ms-file-attributes", attributes)
+	req.Header.Set("x-ms-file-creation-time", "preserve")
+	req.Header.Set("x-ms-file-last-write-time", "preserve")
+	req.Header.Set("x-ms-file-permission", "preserve")
+
+	setIfNotEmpty := func(key, value string) {
+		if value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	setIfNotEmpty("x-ms-content-type", headers.ContentType)
+	setIfNotEmpty("x-ms-content-encoding", headers.ContentEncoding)
+	setIfNotEmpty("x-ms-content-language", headers.ContentLanguage)
+	setIfNotEmpty("x-ms-cache-control", headers.CacheControl)
+	setIfNotEmpty("x-ms-content-disposition", headers.ContentDisposition)
+	if len(headers.ContentMD5) > 0 {
+		req.Header.Set("x-ms-content-md5", base64.StdEncoding.EncodeToString(headers.ContentMD5))
+	}
+
+	_, err = p.Do(ctx, newRawBlobResponderFactory(http.StatusOK), req)
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// the blob SDK we use has no constant for the error of a write to a blob that's being deleted
+const blobBeingDeletedServiceCode = "BlobBeingDeleted"
+
+// softDeletableDestination is a sender whose destination can be soft-deleted, which only blobs can.
+// A soft-deleted blob doesn't exist as far as RemoteFileExists is concerned, but it's still there
+type softDeletableDestination interface {
+	RemoteFileSoftDeleted() (bool, error)
+	UndeleteRemoteFile() error
+}
+
+// softDeletableBlob is what the senders to blobs embed, to be softDeletableDestinations
+type softDeletableBlob struct {
+	ctx          context.Context
+	blobURL      azblob.BlobURL
+	containerURL azblob.ContainerURL
+}
+
+func newSoftDeletableBlob(ctx context.Context, destURL url.URL, p pipeline.Pipeline) softDeletableBlob {
+	parts := azblob.NewBlobURLParts(destURL)
+	parts.BlobName = ""
+	parts.Snapshot = ""
+	return softDeletableBlob{
+		ctx:          ctx,
+		blobURL:      azblob.NewBlobURL(destURL, p),
+		containerURL: azblob.NewContainerURL(parts.URL(), p),
+	}
+}
+
+// RemoteFileSoftDeleted tells whether the blob, which is known not to exist, is soft-deleted. Get Blob Properties can't tell,
+// so the container is listed with the deleted blobs included, from the name of the blob on. The blob itself comes first, if it's there
+func (b softDeletableBlob) RemoteFileSoftDeleted() (bool, error) {
+	name := azblob.NewBlobURLParts(b.blobURL.URL()).BlobName
+	resp, err := b.containerURL.ListBlobsFlatSegment(b.ctx, azblob.Marker{}, azblob.ListBlobsSegmentOptions{
+		Details:    azblob.BlobListingDetails{Deleted: true},
+		Prefix:     name,
+		MaxResults: 1,
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, item := range resp.Segment.BlobItems {
+		if item.Name == name && item.Deleted {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UndeleteRemoteFile restores the soft-deleted blob, along with its soft-deleted snapshots
+func (b softDeletableBlob) UndeleteRemoteFile() error {
+	_, err := b.blobURL.Undelete(b.ctx)
+	return err
+}
+
+// handleSoftDeletedDestination does what the job asks for with the destination of the transfer, if it's soft-deleted.
+// It's called once the destination is known not to exist, and tells whether it ended the transfer, because it's to be skipped, or because it failed
+func handleSoftDeletedDestination(jptm IJobPartTransferMgr, s ISenderBase) (transferDone bool) {
+	handling := jptm.SoftDeletedDestinationHandling()
+	d, ok := s.(softDeletableDestination)
+	if handling == common.ESoftDeletedDestinationHandling.Ignore() || !ok {
+		return false
+	}
+
+	info := jptm.Info()
+	softDeleted, err := d.RemoteFileSoftDeleted()
+	if err != nil {
+		jptm.LogSendError(info.Source, info.Destination, "Could not check whether the destination is soft-deleted. "+err.Error(), 0)
+		jptm.SetStatus(common.ETransferStatus.Failed())
+		jptm.ReportTransferDone()
+		return true
+	}
+	if !softDeleted {
+		return false
+	}
+
+	switch handling {
+	case common.ESoftDeletedDestinationHandling.Skip():
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Destination is soft-deleted, so will be skipped")
+		jptm.SetSoftDeletedDestination(handling)
+		jptm.SetStatus(common.ETransferStatus.SkippedDestinationSoftDeleted())
+		jptm.ReportTransferDone()
+		return true
+	case common.ESoftDeletedDestinationHandling.UndeleteAndOverwrite():
+		if err = d.UndeleteRemoteFile(); err != nil {
+			jptm.LogSendError(info.Source, info.Destination, "Could not undelete the soft-deleted destination. "+err.Error(), 0)
+			jptm.SetStatus(common.ETransferStatus.Failed())
+			jptm.ReportTransferDone()
+			return true
+		}
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Destination was soft-deleted, so it was undeleted, and will be overwritten")
+	default:
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Destination is soft-deleted, and will be overwritten")
+	}
+	jptm.SetSoftDeletedDestination(handling)
+	return false
+}
+
+// skipsDestinationBeingDeleted tells whether a write failed because the destination blob is being deleted,
+// and the job skips such transfers rather than fails them (--soft-deleted-destination=skip).
+// That's for the blobs that were deleted after they were checked
+func skipsDestinationBeingDeleted(jptm IJobPartTransferMgr, err error) bool {
+	if err == nil || jptm.SoftDeletedDestinationHandling() != common.ESoftDeletedDestinationHandling.Skip() {
+		return false
+	}
+	serviceCode, status, _ := ErrorEx{err}.ErrorCodeAndString()
+	return status == http.StatusConflict && serviceCode == blobBeingDeletedServiceCode
+}
@@ -0,0 +1,64 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// skippedStatusForSourceError tells whether a local source that couldn't be opened or read is one that the job skips,
+// rather than fails, and if so, the status that records why it was skipped.
+// A nil error is never skipped.
+func skippedStatusForSourceError(jptm IJobPartTransferMgr, err error) (common.TransferStatus, bool) {
+	switch {
+	case err == nil:
+		return common.ETransferStatus.Failed(), false
+	case jptm.SkipPermissionErrors() && isPermissionError(err):
+		return common.ETransferStatus.SkippedPermissionDenied(), true
+	case jptm.SkipLockedFiles() && isFileLockedError(err):
+		return common.ETransferStatus.SkippedFileLocked(), true
+	default:
+		return common.ETransferStatus.Failed(), false
+	}
+}
+
+// isPermissionError covers EACCES and EPERM, and ERROR_ACCESS_DENIED on Windows
+func isPermissionError(err error) bool {
+	return errors.Is(err, os.ErrPermission)
+}
+
+// isNetworkReadError tells whether the file is on a network share or mount that dropped, and may come back
+func isNetworkReadError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	for _, e := range networkReadErrors {
+		if errno == e {
+			return true
+		}
+	}
+	return false
+}
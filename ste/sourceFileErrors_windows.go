@@ -0,0 +1,48 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32 // ERROR_SHARING_VIOLATION: another process opened the file without sharing it
+	errorLockViolation    syscall.Errno = 33 // ERROR_LOCK_VIOLATION: another process locked the part of the file that was read
+)
+
+// the errors of a file on a network share that may go away once the share is reconnected
+var networkReadErrors = []syscall.Errno{
+	53,   // ERROR_BAD_NETPATH
+	55,   // ERROR_DEV_NOT_EXIST
+	59,   // ERROR_UNEXP_NET_ERR
+	64,   // ERROR_NETNAME_DELETED: the connection to the share was lost
+	67,   // ERROR_BAD_NET_NAME
+	121,  // ERROR_SEM_TIMEOUT
+	1231, // ERROR_NETWORK_UNREACHABLE
+}
+
+// isFileLockedError tells whether the file is in use by another process
+func isFileLockedError(err error) bool {
+	var errno syscall.Errno
+	return errors.As(err, &errno) && (errno == errorSharingViolation || errno == errorLockViolation)
+}
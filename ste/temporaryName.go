@@ -0,0 +1,59 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// the first service version in which Azure Files can rename files
+const azureFileRenameServiceVersion = "2021-04-10"
+
+// temporaryDestinationURL returns the URL of the temporary name that a destination is written under, which is its own name with the suffix
+func temporaryDestinationURL(destination url.URL, suffix string) url.URL {
+	destination.Path += suffix
+	if destination.RawPath != "" {
+		destination.RawPath += url.PathEscape(suffix)
+	}
+	return destination
+}
+
+// renameAzureFile moves the Azure file at source to destination, in the same share, replacing the file that's there, if any.
+// The version of the file SDK we use can't rename files, so we issue the request ourselves
+func renameAzureFile(ctx context.Context, p pipeline.Pipeline, source url.URL, destination url.URL) error {
+	req, err := pipeline.NewRequest(http.MethodPut, destination, nil)
+	if err != nil {
+		return pipeline.NewError(err, "failed to create request")
+	}
+	params := req.URL.Query()
+	params.Set("comp", "rename")
+	req.URL.RawQuery = params.Encode()
+	req.Header.Set("x-ms-file-rename-source", source.String())
+	req.Header.Set("x-ms-file-rename-replace-if-exists", "true")
+
+	ctx = context.WithValue(ctx, ServiceAPIVersionOverride, azureFileRenameServiceVersion)
+	_, err = p.Do(ctx, newRawBlobResponderFactory(http.StatusOK), req)
+	return err
+}
@@ -0,0 +1,174 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type transactionClass int
+
+const (
+	transactionClassList transactionClass = iota
+	transactionClassRead
+	transactionClassWrite
+	transactionClassOther
+)
+
+// classifyTransaction puts a request in the class that the service bills it in, roughly: listing, and creating containers (which is billed
+// with it), reading data, writing data (including metadata and properties), and everything else, e.g. getting properties, deleting and leasing
+func classifyTransaction(request *http.Request) transactionClass {
+	query := request.URL.Query()
+	restype, resource := query.Get("restype"), query.Get("resource")
+	switch {
+	case query.Get("comp") == "list" || resource == "account" || (request.Method == http.MethodGet && resource == "filesystem"):
+		return transactionClassList
+	case request.Method == http.MethodPut && (restype == "container" || restype == "share" || resource == "filesystem"):
+		return transactionClassList
+	case query.Get("comp") == "lease" || request.Method == http.MethodHead || request.Method == http.MethodDelete:
+		return transactionClassOther
+	case request.Method == http.MethodGet:
+		return transactionClassRead
+	case request.Method == http.MethodPut || request.Method == http.MethodPatch || request.Method == http.MethodPost:
+		return transactionClassWrite
+	default:
+		return transactionClassOther
+	}
+}
+
+// recordTransaction counts a try of a request, in its class. Like the service, it counts the retries too
+func (s *pipelineNetworkStats) recordTransaction(request *http.Request) {
+	switch classifyTransaction(request) {
+	case transactionClassList:
+		atomic.AddUint64(&s.atomicListCount, 1)
+	case transactionClassRead:
+		atomic.AddUint64(&s.atomicReadCount, 1)
+	case transactionClassWrite:
+		atomic.AddUint64(&s.atomicWriteCount, 1)
+	default:
+		atomic.AddUint64(&s.atomicOtherCount, 1)
+	}
+}
+
+// TransactionCounts returns the number of requests, by class, over the whole job
+func (s *pipelineNetworkStats) TransactionCounts() common.TransactionCounts {
+	s.nocopy.Check()
+	return common.TransactionCounts{
+		List:  atomic.LoadUint64(&s.atomicListCount),
+		Read:  atomic.LoadUint64(&s.atomicReadCount),
+		Write: atomic.LoadUint64(&s.atomicWriteCount),
+		Other: atomic.LoadUint64(&s.atomicOtherCount),
+	}
+}
+
+// PredictTransactions returns the requests that the transfer of one file of the given size makes, by the request pattern of the sender
+// (or downloader) that it gets, e.g. the create, the appends and the flush of a file in an account that has a hierarchical namespace.
+// It takes for granted what TransactionPredictionAssumptions says.
+func PredictTransactions(fromTo common.FromTo, blobType common.BlobType, fileSize int64, blockSize uint32) (c common.TransactionCounts) {
+	blockSize = effectiveBlockSize(blockSize, fileSize)
+	chunks := uint64(getNumChunks(fileSize, blockSize))
+	nonEmptyChunks := chunks
+	if fileSize == 0 {
+		nonEmptyChunks = 0
+	}
+
+	switch fromTo.To() {
+	case common.ELocation.Local(), common.ELocation.Pipe():
+		switch {
+		case fileSize == 0:
+			// the empty file is created without asking the service for anything
+		case fileSize <= singleGetThreshold:
+			c.Read = 1
+		default:
+			c.Read = chunks
+		}
+	case common.ELocation.Blob():
+		switch blobType {
+		case common.EBlobType.PageBlob(), common.EBlobType.AppendBlob():
+			c.Write = 1 + nonEmptyChunks // the create, and the pages or blocks
+		default:
+			// one Put Blob (From URL) for a file in one block, or one Put Block (From URL) for each block, and the Put Block List
+			if chunks == 1 && (!fromTo.IsS2S() || fileSize <= putBlobFromURLThreshold) {
+				c.Write = 1
+			} else {
+				c.Write = chunks + 1
+			}
+		}
+	case common.ELocation.File():
+		c.Write = 1 + nonEmptyChunks // the create, and the ranges
+	case common.ELocation.BlobFS():
+		switch {
+		case fileSize == 0, isSmallBlobFSFile(fileSize, uint32(chunks), 0, false):
+			c.Write = 2 // the create, and the flush (which appends the data of a small file too)
+		default:
+			flushes := (chunks + uint64(ADLSFlushThreshold) - 1) / uint64(ADLSFlushThreshold)
+			c.Write = 1 + chunks + flushes
+		}
+	default:
+		c.Other = 1 // e.g. a deletion
+	}
+	return c
+}
+
+// TransactionPredictionAssumptions says what the predictions of PredictTransactions for the given transfers take for granted
+func TransactionPredictionAssumptions(fromTo common.FromTo, blobType common.BlobType, blockSize uint32) []string {
+	assumptions := make([]string, 0)
+	if blockSize == 0 {
+		assumptions = append(assumptions, fmt.Sprintf("Files are split into blocks of %s, doubled for files that would otherwise have more than %v blocks",
+			formatMiB(uint32(common.DefaultBlockBlobBlockSize)), common.MaxNumberOfBlocksPerBlob))
+	} else {
+		assumptions = append(assumptions, fmt.Sprintf("Files are split into blocks of %s", formatMiB(effectiveBlockSize(blockSize, 0))))
+	}
+
+	switch fromTo.To() {
+	case common.ELocation.Local(), common.ELocation.Pipe():
+		assumptions = append(assumptions, fmt.Sprintf("Files of up to %s are downloaded with one request, and larger files with one request per block",
+			formatMiB(uint32(singleGetThreshold))))
+	case common.ELocation.Blob():
+		switch {
+		case blobType == common.EBlobType.PageBlob() || blobType == common.EBlobType.AppendBlob():
+			assumptions = append(assumptions, "Each blob is created, and then written with one request per block")
+		case fromTo.IsS2S():
+			assumptions = append(assumptions, fmt.Sprintf("Files of up to %s in one block are copied with one request, and larger files with one request per block, "+
+				"and one to commit the blocks. Source blobs are taken to be block blobs, unless the blob type is given", formatMiB(uint32(putBlobFromURLThreshold))))
+		default:
+			assumptions = append(assumptions, "Files of one block are uploaded with one request, and larger files with one request per block, and one to commit the blocks")
+		}
+	case common.ELocation.File():
+		assumptions = append(assumptions, "Each file is created, and then written with one request per block")
+	case common.ELocation.BlobFS():
+		assumptions = append(assumptions, fmt.Sprintf("Each file is created, appended to with one request per block, and flushed once per %v blocks. "+
+			"Files smaller than %s in one block are appended to and flushed with one request", ADLSFlushThreshold, formatMiB(uint32(ADLSSmallFileThreshold))))
+	}
+
+	if fromTo.IsS2S() {
+		assumptions = append(assumptions, "The reads of the sources that the service makes to copy them are not counted")
+	}
+	return append(assumptions, "Every request succeeds the first time, no destination exists yet, and no MD5 hashes are kept")
+}
+
+func formatMiB(bytes uint32) string {
+	return fmt.Sprintf("%.4g MiB", float64(bytes)/(1024*1024))
+}
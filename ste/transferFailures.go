@@ -0,0 +1,182 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// how many failures are kept word for word, so that the user can see the root cause without digging through the log
+const firstTransferFailuresKept = 5
+
+// how many different kinds of failure are counted separately. Any further kinds are counted together,
+// so that a job whose failures all differ can't grow the counts without bound
+const transferFailureKindsCounted = 1000
+
+const otherTransferFailures = "other errors"
+
+// transferFailures keeps what is needed to tell why the transfers of a job fail:
+// the text of its first few failures, how many of its transfers failed with each kind of error,
+// how many failed with each storage error code, and the x-ms-client-request-id of the request that each failed with
+type transferFailures struct {
+	lock             sync.Mutex
+	first            []string
+	kinds            map[string]uint32
+	codes            map[string]common.ErrorCodeFailures
+	clientRequestIDs map[transferRef]string
+}
+
+type transferRef struct {
+	partNum       common.PartNumber
+	transferIndex uint32
+}
+
+func newTransferFailures() *transferFailures {
+	return &transferFailures{kinds: make(map[string]uint32), codes: make(map[string]common.ErrorCodeFailures),
+		clientRequestIDs: make(map[transferRef]string)}
+}
+
+// recordClientRequestID keeps the x-ms-client-request-id of the request that the transfer failed with
+func (f *transferFailures) recordClientRequestID(partNum common.PartNumber, transferIndex uint32, clientRequestID string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.clientRequestIDs[transferRef{partNum, transferIndex}] = clientRequestID
+}
+
+// clientRequestID returns the x-ms-client-request-id of the request that the transfer failed with, or "" if it isn't known
+func (f *transferFailures) clientRequestID(partNum common.PartNumber, transferIndex uint32) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.clientRequestIDs[transferRef{partNum, transferIndex}]
+}
+
+// record counts the failure of a transfer. The service code is that of the storage error it failed with, if it's known;
+// if it isn't, it's taken from the error message, which holds it when the message is that of a storage error
+func (f *transferFailures) record(source, destination, errorMsg, serviceCode string, status int) {
+	kind := transferFailureKind(source, destination, errorMsg, status)
+	if serviceCode == "" {
+		serviceCode = serviceCodeInMessage(errorMsg)
+	}
+	if serviceCode == "" {
+		serviceCode = common.NoStorageErrorCode
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.first) < firstTransferFailuresKept {
+		f.first = append(f.first, common.URLStringExtension(source).RedactSecretQueryParamForLogging()+": "+kind)
+	}
+	if _, counted := f.kinds[kind]; !counted && len(f.kinds) >= transferFailureKindsCounted {
+		kind = otherTransferFailures
+	}
+	f.kinds[kind]++
+
+	code, counted := f.codes[serviceCode]
+	if !counted {
+		if len(f.codes) >= transferFailureKindsCounted {
+			serviceCode = otherTransferFailures
+			code = f.codes[serviceCode]
+		}
+		if code.ExamplePath == "" {
+			code.ExamplePath = common.URLStringExtension(source).RedactSecretQueryParamForLogging()
+		}
+	}
+	code.Count++
+	if code.Status == 0 {
+		code.Status = status
+	}
+	f.codes[serviceCode] = code
+}
+
+// firstFailures returns the source and the error of the first transfers that failed, in the order they failed
+func (f *transferFailures) firstFailures() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.first...)
+}
+
+// dominant returns the kind of error that most transfers failed with, and how many did. It's empty if none failed
+func (f *transferFailures) dominant() (kind string, count uint32) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for k, n := range f.kinds {
+		if n > count || (n == count && k < kind) { // ties go the same way each time
+			kind, count = k, n
+		}
+	}
+	return kind, count
+}
+
+// byErrorCode returns how many transfers failed with each storage error code, with an example of each
+func (f *transferFailures) byErrorCode() map[string]common.ErrorCodeFailures {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.codes) == 0 {
+		return nil
+	}
+	codes := make(map[string]common.ErrorCodeFailures, len(f.codes))
+	for k, v := range f.codes {
+		codes[k] = v
+	}
+	return codes
+}
+
+// the text of a storage error includes its service code, e.g. "===== RESPONSE ERROR (ServiceCode=BlobArchived) ====="
+var serviceCodeRegex = regexp.MustCompile(`ServiceCode=(\w+)`)
+
+func serviceCodeInMessage(errorMsg string) string {
+	if m := serviceCodeRegex.FindStringSubmatch(errorMsg); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// transferFailureKind reduces the error of a failed transfer to what it has in common with the failures of other transfers
+// for the same reason: the paths of the transfer and the request ID are taken out of it.
+func transferFailureKind(source, destination, errorMsg string, status int) string {
+	kind := errorMsg
+	if i := strings.Index(kind, "X-Ms-Request-Id:"); i >= 0 {
+		kind = kind[:i]
+	}
+	if i := strings.IndexAny(kind, "\r\n"); i >= 0 {
+		kind = kind[:i]
+	}
+	for _, p := range []struct{ path, placeholder string }{
+		{common.URLStringExtension(source).RedactSecretQueryParamForLogging(), "<source>"},
+		{source, "<source>"},
+		{common.URLStringExtension(destination).RedactSecretQueryParamForLogging(), "<destination>"},
+		{destination, "<destination>"},
+	} {
+		if p.path != "" {
+			kind = strings.Replace(kind, p.path, p.placeholder, -1)
+		}
+	}
+	kind = strings.TrimRight(strings.TrimSpace(kind), ".")
+	if status != 0 && !strings.HasPrefix(kind, fmt.Sprintf("%d ", status)) {
+		kind = fmt.Sprintf("%d %s", status, kind)
+	}
+	return kind
+}
@@ -0,0 +1,108 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"container/heap"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// transferOrderWindow is the most transfers of a job part that are held back, to be scheduled in the order that the job asks for,
+// which is as many as the front end puts in a part.
+// The order is approximate: the source is enumerated as a stream, and each part is scheduled as soon as the front end dispatches it,
+// so rather than sorting the whole job, the transfers of each part go through a bounded priority buffer, and the best of them
+// is scheduled whenever it's full. A transfer is thus only ever scheduled ahead of those that were enumerated at most this many
+// transfers before it, and never ahead of those of an earlier part, which may well have started already.
+const transferOrderWindow = 10000
+
+// transferOrderBuffer schedules the transfers given to it in the order of the job, within the window
+type transferOrderBuffer struct {
+	order    common.TransferOrder
+	window   int
+	schedule func(jptm IJobPartTransferMgr)
+	held     heldTransfers
+}
+
+func newTransferOrderBuffer(order common.TransferOrder, window int, schedule func(jptm IJobPartTransferMgr)) *transferOrderBuffer {
+	return &transferOrderBuffer{order: order, window: window, schedule: schedule, held: heldTransfers{order: order}}
+}
+
+// add schedules the transfer, or holds it back until a better one can't turn up anymore
+func (b *transferOrderBuffer) add(jptm IJobPartTransferMgr, size int64) {
+	if b.order == common.ETransferOrder.AsEnumerated() {
+		b.schedule(jptm)
+		return
+	}
+
+	heap.Push(&b.held, heldTransfer{jptm: jptm, size: size, sequence: b.held.added})
+	b.held.added++
+	if b.held.Len() > b.window {
+		b.schedule(heap.Pop(&b.held).(heldTransfer).jptm)
+	}
+}
+
+// flush schedules the transfers that are held back, at the end of the job part
+func (b *transferOrderBuffer) flush() {
+	for b.held.Len() > 0 {
+		b.schedule(heap.Pop(&b.held).(heldTransfer).jptm)
+	}
+}
+
+type heldTransfer struct {
+	jptm IJobPartTransferMgr
+	size int64
+	// transfers of the same size keep the order they were enumerated in
+	sequence uint64
+}
+
+// heldTransfers is a heap of the held transfers, with the one to schedule first at the top
+type heldTransfers struct {
+	order     common.TransferOrder
+	transfers []heldTransfer
+	added     uint64
+}
+
+func (h *heldTransfers) Len() int { return len(h.transfers) }
+
+func (h *heldTransfers) Less(i, j int) bool {
+	a, b := h.transfers[i], h.transfers[j]
+	if a.size != b.size {
+		if h.order == common.ETransferOrder.SmallestFirst() {
+			return a.size < b.size
+		}
+		return a.size > b.size
+	}
+	return a.sequence < b.sequence
+}
+
+func (h *heldTransfers) Swap(i, j int) {
+	h.transfers[i], h.transfers[j] = h.transfers[j], h.transfers[i]
+}
+
+func (h *heldTransfers) Push(x interface{}) { h.transfers = append(h.transfers, x.(heldTransfer)) }
+
+func (h *heldTransfers) Pop() interface{} {
+	last := h.transfers[len(h.transfers)-1]
+	h.transfers[len(h.transfers)-1] = heldTransfer{} // so that the transfer manager isn't kept alive by the slice
+	h.transfers = h.transfers[:len(h.transfers)-1]
+	return last
+}
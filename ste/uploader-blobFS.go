@@ -23,6 +23,7 @@ package ste
 import (
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"net/url"
 	"os"
@@ -35,8 +36,10 @@ import (
 )
 
 type blobFSUploader struct {
-	jptm                IJobPartTransferMgr
+	jptm IJobPartTransferMgr
+	// what's written, which is the destination, or the temporary name it's written under, until it's renamed to finalURL
 	fileURL             azbfs.FileURL
+	finalURL            azbfs.FileURL
 	chunkSize           uint32
 	numChunks           uint32
 	pipeline            pipeline.Pipeline
@@ -44,6 +47,18 @@ type blobFSUploader struct {
 	md5Channel          chan []byte
 	creationTimeHeaders *azbfs.BlobFSHTTPHeaders
 	flushThreshold      int64
+
+	// where the chunks start, which is only past 0 when an append-only upload appends to what the destination already has
+	firstOffset int64
+
+	// whether the only chunk flushes and closes the file too, so that the epilogue has nothing to flush
+	flushedWithData bool
+
+	// the lease on the destination while it's written, if the job protects its destinations with leases
+	lease *destinationLease
+
+	// whether fileURL is a temporary name, which the file is renamed from once it's complete
+	temporaryName bool
 }
 
 func newBlobFSUploader(jptm IJobPartTransferMgr, destination string, p pipeline.Pipeline, pacer pacer, sip ISourceInfoProvider) (ISenderBase, error) {
@@ -106,21 +121,71 @@ func newBlobFSUploader(jptm IJobPartTransferMgr, destination string, p pipeline.
 		*/
 	}
 
+	fileURL := azbfs.NewFileURL(*destURL, p)
+	finalURL := fileURL
+	var firstOffset int64
+	temporaryName := false
+	if jptm.AppendOnly() {
+		firstOffset = blobFSFirstOffset(jptm, fileURL) // which appends to the destination itself, so it's never written under a temporary name
+	} else if suffix := jptm.TempNameSuffix(); suffix != "" {
+		fileURL = azbfs.NewFileURL(temporaryDestinationURL(*destURL, suffix), p)
+		temporaryName = true
+		if jptm.WasResumed() {
+			// the temporary file that the job left, if it did, holds what it flushed of the source before it stopped, which is carried on from
+			firstOffset = blobFSFirstOffset(jptm, fileURL)
+			if firstOffset > 0 {
+				jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo,
+					fmt.Sprintf("The temporary file %s already holds the first %v bytes of the source, so the upload carries on from there", fileURL.String(), firstOffset))
+			}
+		}
+	}
+
 	// compute chunk size and number of chunks
 	chunkSize := info.BlockSize
-	numChunks := getNumChunks(info.SourceSize, chunkSize)
+	numChunks := getNumChunks(info.SourceSize-firstOffset, chunkSize)
 
 	return &blobFSUploader{
-		jptm:       jptm,
-		fileURL:    azbfs.NewFileURL(*destURL, p),
-		chunkSize:  chunkSize,
-		numChunks:  numChunks,
-		pipeline:   p,
-		pacer:      pacer,
-		md5Channel: newMd5Channel(),
+		jptm:            jptm,
+		fileURL:         fileURL,
+		finalURL:        finalURL,
+		temporaryName:   temporaryName,
+		chunkSize:       chunkSize,
+		numChunks:       numChunks,
+		pipeline:        p,
+		pacer:           pacer,
+		md5Channel:      newMd5Channel(),
+		firstOffset:     firstOffset,
+		flushedWithData: isSmallBlobFSFile(info.SourceSize, numChunks, firstOffset, jptm.ShouldPutMd5()),
 	}, nil
 }
 
+// isSmallBlobFSFile tells whether a file is small enough to be appended, flushed and closed in one request.
+// The MD5 of the whole file is only known after the chunk is read, and is set by a separate flush, so files whose MD5 is kept don't qualify.
+func isSmallBlobFSFile(sourceSize int64, numChunks uint32, firstOffset int64, putMd5 bool) bool {
+	return sourceSize > 0 && sourceSize < ADLSSmallFileThreshold && numChunks == 1 && firstOffset == 0 && !putMd5 &&
+		ServiceVersionSupports(azbfs.AppendAndFlushServiceVersion)
+}
+
+// blobFSFirstOffset returns where an append-only upload starts appending, which is the end of the destination,
+// if it is a file that holds the start of the source
+func blobFSFirstOffset(jptm IJobPartTransferMgr, fileURL azbfs.FileURL) int64 {
+	props, err := fileURL.GetProperties(jptm.Context())
+	if err != nil || props.XMsResourceType() != "file" {
+		return 0 // most likely, it doesn't exist yet, and if we can't read it, uploading it in full reports why
+	}
+	return appendOnlyFirstOffset(jptm, props.ContentLength(), func(offset int64, count int64) (io.ReadCloser, error) {
+		get, err := fileURL.Download(jptm.Context(), offset, count)
+		if err != nil {
+			return nil, err
+		}
+		return get.Body(azbfs.RetryReaderOptions{MaxRetryRequests: MaxRetryPerDownloadBody}), nil
+	})
+}
+
+func (u *blobFSUploader) FirstOffset() int64 {
+	return u.firstOffset
+}
+
 func (u *blobFSUploader) ChunkSize() uint32 {
 	return u.chunkSize
 }
@@ -158,7 +223,7 @@ func newBlobFSLastModifiedTimeProvider(props *azbfs.PathGetPropertiesResponse) b
 }
 
 func (u *blobFSUploader) RemoteFileExists() (bool, time.Time, error) {
-	props, err := u.fileURL.GetProperties(u.jptm.Context())
+	props, err := u.finalURL.GetProperties(u.jptm.Context())
 	return remoteObjectExists(newBlobFSLastModifiedTimeProvider(props), err)
 }
 
@@ -169,30 +234,68 @@ func (u *blobFSUploader) Prologue(state common.PrologueState) (destinationModifi
 
 	h := jptm.BfsDstData(state.LeadingBytes)
 	u.creationTimeHeaders = &h
-	// Create file with the source size
 	destinationModified = true
-	_, err := u.fileURL.Create(u.jptm.Context(), h) // note that "create" actually calls "create path"
+	if u.firstOffset > 0 {
+		// the file is appended to, rather than created, and the headers are set when it's flushed
+		u.leaseDestination()
+		return
+	}
+
+	// Create file with the source size. Under a temporary name, the destination condition is met by the rename instead
+	conditions := destinationPathConditions(jptm)
+	if u.temporaryName {
+		conditions = azbfs.PathAccessConditions{}
+	}
+	_, err := u.fileURL.CreateWithConditions(u.jptm.Context(), h, conditions) // note that "create" actually calls "create path"
 	if err != nil {
-		u.jptm.FailActiveUpload("Creating file", err)
+		if jptm.ProtectDestinationWithLease() {
+			failDestinationLease(jptm, "Creating file", err)
+		} else {
+			u.jptm.FailActiveUpload("Creating file", err)
+		}
 		return
 	}
+	u.leaseDestination()
 	return
 }
 
+// leaseDestination leases the destination file, if the job protects its destinations with leases, so that nothing else can write to it
+// while it's uploaded. The appends, the flushes and the deletion of the file are then made under the lease
+func (u *blobFSUploader) leaseDestination() {
+	if !u.jptm.ProtectDestinationWithLease() {
+		return
+	}
+	lease, err := acquireDestinationLease(u.jptm, blobFSLeaseOperations{fileURL: u.fileURL}, u.fileURL.URL())
+	if err != nil {
+		failDestinationLease(u.jptm, "Leasing file", err)
+		return
+	}
+	u.lease = lease
+	u.fileURL = u.fileURL.WithLeaseID(lease.leaseID())
+}
+
 func (u *blobFSUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader, chunkIsWholeFile bool) chunkFunc {
 
 	return createSendToRemoteChunkFunc(u.jptm, id, func() {
 		jptm := u.jptm
 
-		if jptm.Info().SourceSize == 0 {
-			// nothing to do, since this is a dummy chunk in a zero-size file, and the prologue will have done all the real work
+		if jptm.Info().SourceSize == u.firstOffset {
+			// nothing to do, since this is a dummy chunk in a zero-size file (or one that the destination already has all of), and the prologue will have done all the real work
 			return
 		}
 
 		// upload the byte range represented by this chunk
 		jptm.LogChunkStatus(id, common.EWaitReason.Body())
 		body := newPacedRequestBody(jptm.Context(), reader, u.pacer)
-		_, err := u.fileURL.AppendData(jptm.Context(), id.OffsetInFile(), body) // note: AppendData is really UpdatePath with "append" action
+		if u.flushedWithData {
+			err := appendDataAndClose(jptm.Context(), u.fileURL, body, *u.creationTimeHeaders)
+			if err != nil {
+				jptm.FailActiveUpload("Uploading and flushing file", err)
+			}
+			return
+		}
+		// AppendData is really UpdatePath with "append" action. Its position is explicit, so a retry replaces what an earlier try staged
+		_, err := u.fileURL.AppendData(jptm.Context(), id.OffsetInFile(), body)
 		if err != nil {
 			jptm.FailActiveUpload("Uploading range", err)
 			return
@@ -202,14 +305,20 @@ func (u *blobFSUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int32,
 
 func (u *blobFSUploader) Epilogue() {
 	jptm := u.jptm
+	ss := jptm.Info().SourceSize
+	defer u.releaseLease()
+
+	if u.flushedWithData || (ss == 0 && u.firstOffset == 0 && !jptm.ShouldPutMd5()) {
+		// nothing to flush, since the only chunk flushed the file, or the file is empty, and so complete with its headers once it's created
+		return
+	}
 
 	// flush
 	if jptm.IsLive() {
-		ss := jptm.Info().SourceSize
 		md5Hash, ok := <-u.md5Channel
 		if ok {
-			// Flush incrementally to avoid timeouts on a full flush
-			for i := int64(math.Min(float64(ss), float64(u.flushThreshold))); ; i = int64(math.Min(float64(ss), float64(i+u.flushThreshold))) {
+			// Flush incrementally to avoid timeouts on a full flush. What the file held before an append-only upload is flushed already
+			for i := int64(math.Min(float64(ss), float64(u.firstOffset+u.flushThreshold))); ; i = int64(math.Min(float64(ss), float64(i+u.flushThreshold))) {
 				// Close only at the end of the file, keep all uncommitted data before then.
 				_, err := u.fileURL.FlushData(jptm.Context(), i, md5Hash, *u.creationTimeHeaders, i != ss, i == ss)
 				if err != nil {
@@ -227,11 +336,40 @@ func (u *blobFSUploader) Epilogue() {
 	}
 }
 
+// releaseLease releases the lease on the destination file, if there is one. Whatever is done to the file after that, such as its deletion, is done without the lease
+func (u *blobFSUploader) releaseLease() {
+	if u.lease == nil {
+		return
+	}
+	u.lease.release()
+	u.fileURL = u.fileURL.WithLeaseID("")
+}
+
+// RenameToFinalName renames the file from the temporary name it was written under, if it was, replacing what has the final name
+func (u *blobFSUploader) RenameToFinalName() {
+	if !u.temporaryName {
+		return
+	}
+
+	_, err := u.fileURL.RenameWithConditions(u.jptm.Context(), u.finalURL, destinationPathConditions(u.jptm))
+	if err != nil {
+		u.jptm.FailActiveUpload("Renaming file to its final name", err)
+		return
+	}
+	u.fileURL = u.finalURL
+	u.temporaryName = false
+}
+
 func (u *blobFSUploader) Cleanup() {
 	jptm := u.jptm
 
-	// Cleanup if status is now failed
-	if jptm.IsDeadInflight() {
+	// Cleanup if status is now failed. A temporary file is always deleted, even one that a resumed job carried on with
+	if jptm.IsDeadInflight() && skippedForDestinationCondition(jptm) && !u.temporaryName {
+		// the file is what something else wrote, so it's left as it is
+	} else if jptm.IsDeadInflight() && u.firstOffset > 0 && !u.temporaryName {
+		// the file held the start of the source before we appended to it, so it's kept
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The append-only upload did not complete, so the destination only holds part of the source")
+	} else if jptm.IsDeadInflight() {
 		// transfer was either failed or cancelled
 		// the file created in share needs to be deleted, since it's
 		// contents will be at an unknown stage of partial completeness
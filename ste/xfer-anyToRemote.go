@@ -31,11 +31,18 @@ import (
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
 )
 
 // This sync.Once is present to ensure we output information about a S2S access tier preservation failure to stdout once
 var s2sAccessTierFailureLogStdout sync.Once
 
+// This sync.Once is present to ensure we output information about skipping the archived blobs of a S2S copy to stdout once
+var s2sArchivedSourceLogStdout sync.Once
+
+const archivedSourceMsg = "The source blob is in the Archive tier, so it cannot be read until it has been rehydrated. " +
+	"Change its tier to Hot or Cool, wait for the rehydration to finish, and then run the job again"
+
 // anyToRemote handles all kinds of sender operations - both uploads from local files, and S2S copies
 func anyToRemote(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, senderFactory senderFactory, sipf sourceInfoProviderFactory) {
 
@@ -50,15 +57,31 @@ func anyToRemote(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, sen
 
 	// step 2a. Create sender
 	srcInfoProvider, err := sipf(jptm)
-	if err != nil {
+	if skipIfSourceGone(jptm, err) {
+		return
+	} else if err != nil {
 		jptm.LogSendError(info.Source, info.Destination, err.Error(), 0)
 		jptm.SetStatus(common.ETransferStatus.Failed())
 		jptm.ReportTransferDone()
 		return
 	}
 
+	// an archived blob can't be read by the service either, so it's better to tell the user how to fix that, than to have the copy fail with a cryptic error
+	if blobSrcInfoProvider, ok := srcInfoProvider.(IBlobSourceInfoProvider); ok && blobSrcInfoProvider.BlobTier() == azblob.AccessTierArchive {
+		jptm.LogSendError(info.Source, info.Destination, archivedSourceMsg, 0)
+		s2sArchivedSourceLogStdout.Do(func() {
+			common.GetLifecycleMgr().Info("One or more source blobs are in the Archive tier, and will fail until they have been rehydrated to the Hot or Cool tier. " +
+				"Please check the log file for the list of them.")
+		})
+		jptm.SetStatus(common.ETransferStatus.Failed())
+		jptm.ReportTransferDone()
+		return
+	}
+
 	s, err := senderFactory(jptm, info.Destination, p, pacer, srcInfoProvider)
-	if err != nil {
+	if skipIfSourceGone(jptm, err) {
+		return
+	} else if err != nil {
 		jptm.LogSendError(info.Source, info.Destination, err.Error(), 0)
 		jptm.SetStatus(common.ETransferStatus.Failed())
 		jptm.ReportTransferDone()
@@ -76,8 +99,10 @@ func anyToRemote(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, sen
 	// step 3: check overwrite option
 	// if the force Write flags is set to false or prompt
 	// then check the file exists at the remote location
-	// if it does, react accordingly
-	if jptm.GetOverwriteOption() != common.EOverwriteOption.True() {
+	// if it does, react accordingly.
+	// A destination that doesn't exist may still be soft-deleted, which the job may want to know about too
+	if jptm.GetOverwriteOption() != common.EOverwriteOption.True() ||
+		jptm.SoftDeletedDestinationHandling() != common.ESoftDeletedDestinationHandling.Ignore() {
 		exists, dstLmt, existenceErr := s.RemoteFileExists()
 		if existenceErr != nil {
 			jptm.LogSendError(info.Source, info.Destination, "Could not check destination file existence. "+existenceErr.Error(), 0)
@@ -85,7 +110,11 @@ func anyToRemote(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, sen
 			jptm.ReportTransferDone()
 			return
 		}
-		if exists {
+		if !exists {
+			if handleSoftDeletedDestination(jptm, s) {
+				return
+			}
+		} else if jptm.GetOverwriteOption() != common.EOverwriteOption.True() {
 			shouldOverwrite := false
 
 			// if necessary, prompt to confirm user's intent
@@ -116,8 +145,16 @@ func anyToRemote(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, sen
 	srcFile := (common.CloseableReaderAt)(nil)
 	if srcInfoProvider.IsLocal() {
 		sourceFileFactory = srcInfoProvider.(ILocalSourceInfoProvider).OpenSourceFile // all local providers must implement this interface
+		if isNetworkSourcePath(info.Source, networkSourcePrefixes()) {
+			sourceFileFactory = retryingNetworkReads(jptm, sourceFileFactory) // so that a share that drops and comes back doesn't fail the transfer
+		}
 		srcFile, err = sourceFileFactory()
-		if err != nil {
+		if status, skip := skippedStatusForSourceError(jptm, err); skip {
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "Couldn't open source, so it will be skipped-"+err.Error())
+			jptm.SetStatus(status)
+			jptm.ReportTransferDone()
+			return
+		} else if err != nil {
 			jptm.LogSendError(info.Source, info.Destination, "Couldn't open source-"+err.Error(), 0)
 			jptm.SetStatus(common.ETransferStatus.Failed())
 			jptm.ReportTransferDone()
@@ -132,7 +169,9 @@ func anyToRemote(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, sen
 	if copier, isS2SCopier := s.(s2sCopier); srcInfoProvider.IsLocal() ||
 		(isS2SCopier && info.S2SSourceChangeValidation && srcSize > int64(copier.ChunkSize())) {
 		lmt, err := srcInfoProvider.GetLastModifiedTime()
-		if err != nil {
+		if skipIfSourceGone(jptm, err) {
+			return
+		} else if err != nil {
 			jptm.LogSendError(info.Source, info.Destination, "Couldn't get source's last modified time-"+err.Error(), 0)
 			jptm.SetStatus(common.ETransferStatus.Failed())
 			jptm.ReportTransferDone()
@@ -205,6 +244,7 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 	} else {
 		md5Hasher = common.NewNullHasher()
 	}
+	checksumHasher := jptm.ChecksumHasher() // nil unless the job writes a checksum file
 	safeToUseHash := true
 
 	if srcInfoProvider.IsLocal() {
@@ -212,8 +252,15 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 		defer close(md5Channel)
 	}
 
+	// the chunks start here, rather than at 0, if the destination already has the start of the source
+	firstOffset := firstOffsetOf(s)
+
+	// a source that can't be read from its very first chunk may be one that the job skips rather than fails.
+	// If so, every chunk that then fails to read ends the transfer in the same way, whichever of them runs first
+	readFailureStatus := common.ETransferStatus.Failed()
+
 	chunkIDCount := int32(0)
-	for startIndex := int64(0); startIndex < srcSize || isDummyChunkInEmptyFile(startIndex, srcSize); startIndex += int64(chunkSize) {
+	for startIndex := firstOffset; startIndex < srcSize || isDummyChunkInEmptyFile(startIndex, firstOffset, srcSize); startIndex += int64(chunkSize) {
 
 		adjustedChunkSize := int64(chunkSize)
 
@@ -233,9 +280,19 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 
 				// Wait until we have enough RAM, and when we do, prefetch the data for this chunk.
 				prefetchErr = chunkReader.BlockingPrefetch(srcFile, false)
+				if status, skip := skippedStatusForSourceError(jptm, prefetchErr); skip && startIndex == firstOffset {
+					readFailureStatus = status
+				}
 				if prefetchErr == nil {
 					chunkReader.WriteBufferTo(md5Hasher)
 					ps = chunkReader.GetPrologueState()
+					if checksumHasher != nil {
+						chunkReader.WriteBufferTo(checksumHasher)
+						// set before the last chunk is scheduled, so that it's there by the time the epilogue runs
+						if startIndex+adjustedChunkSize >= srcSize && safeToUseHash {
+							jptm.SetChecksum(checksumHasher.Sum(nil))
+						}
+					}
 				} else {
 					safeToUseHash = false // because we've missed a chunk
 				}
@@ -243,7 +300,7 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 		}
 
 		// If this is the the very first chunk, do special init steps
-		if startIndex == 0 {
+		if startIndex == firstOffset {
 			// Run prologue before first chunk is scheduled.
 			// If file is not local, we'll get no leading bytes, but we still run the prologue in case
 			// there's other initialization to do in the sender.
@@ -266,7 +323,8 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 				}
 				// Our jptm logic currently requires us to schedule every chunk, even if we know there's an error,
 				// so we schedule a func that will just fail with the given error
-				cf = createSendToRemoteChunkFunc(jptm, id, func() { jptm.FailActiveSend("chunk data read", prefetchErr) })
+				readErr, failureStatus := prefetchErr, readFailureStatus
+				cf = createSendToRemoteChunkFunc(jptm, id, func() { jptm.FailActiveSendWithStatus("chunk data read", readErr, failureStatus) })
 			}
 		} else {
 			cf = s.(s2sCopier).GenerateCopyFunc(id, chunkIDCount, adjustedChunkSize, isWholeFile)
@@ -304,8 +362,10 @@ func createPopulatedChunkReader(jptm IJobPartTransferMgr, sourceFileFactory comm
 	return chunkReader
 }
 
-func isDummyChunkInEmptyFile(startIndex int64, fileSize int64) bool {
-	return startIndex == 0 && fileSize == 0
+// isDummyChunkInEmptyFile tells whether the chunk is the one that is scheduled when there is nothing to send,
+// either because the file is empty, or because the destination already has all of it
+func isDummyChunkInEmptyFile(startIndex int64, firstOffset int64, fileSize int64) bool {
+	return startIndex == firstOffset && fileSize == firstOffset
 }
 
 // Complete epilogue. Handles both success and failure.
@@ -345,6 +405,10 @@ func epilogueWithCleanupSendToRemote(jptm IJobPartTransferMgr, s ISenderBase, si
 		}
 	}
 
+	if tns, ok := s.(temporaryNameSender); ok && jptm.IsLive() {
+		tns.RenameToFinalName()
+	}
+
 	if jptm.HoldsDestinationLock() { // TODO consider add test of jptm.IsDeadInflight here, so we can remove that from inside all the cleanup methods
 		s.Cleanup() // Perform jptm cleanup, if THIS jptm has the lock on the destination
 	}
@@ -368,6 +432,14 @@ func epilogueWithCleanupSendToRemote(jptm IJobPartTransferMgr, s ISenderBase, si
 		// and we know the transfer didn't fail (because just checked its status above and made sure the context was not canceled),
 		// so it must have succeeded. So make sure its not left "in progress" state
 		jptm.SetStatus(common.ETransferStatus.Success())
+		jptm.RecordChecksum()
+		if jptm.AppendOnly() {
+			if firstOffset := firstOffsetOf(s); firstOffset > 0 {
+				jptm.ReportAppendOnly(info.SourceSize-firstOffset, 0)
+			} else {
+				jptm.ReportAppendOnly(0, info.SourceSize)
+			}
+		}
 
 		// Final logging
 		if jptm.ShouldLog(pipeline.LogInfo) { // TODO: question: can we remove these ShouldLogs?  Aren't they inside Log?
@@ -380,6 +452,8 @@ func epilogueWithCleanupSendToRemote(jptm IJobPartTransferMgr, s ISenderBase, si
 				panic("invalid state: epilogueWithCleanupSendToRemote should be used by COPY and UPLOAD")
 			}
 		}
+		clearArchiveBitAfterUpload(jptm)
+		deleteSourceAfterTransfer(jptm, jptm.SourceProviderPipeline())
 		if jptm.ShouldLog(pipeline.LogDebug) {
 			jptm.Log(pipeline.LogDebug, "Finalizing Transfer")
 		}
@@ -0,0 +1,53 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// clearArchiveBitAfterUpload clears the archive attribute of the local source of an upload that has just succeeded, if the job asks for that,
+// so that the next job which only includes the files with the attribute (i.e. those that changed since) leaves it out.
+// The attribute of a file that was modified after it was enumerated is kept, since what changed wasn't uploaded.
+// Failing to clear it doesn't fail the transfer, which only means that the file is uploaded again next time.
+func clearArchiveBitAfterUpload(jptm IJobPartTransferMgr) {
+	if !jptm.ClearArchiveBit() {
+		return
+	}
+	if fromTo := jptm.FromTo(); fromTo.From() != common.ELocation.Local() {
+		return
+	}
+
+	source := jptm.Info().Source
+	if err := clearArchiveAttribute(source, jptm.LastModifiedTime()); err != nil {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The archive attribute of the source was kept, since it could not be cleared: "+err.Error())
+		return
+	}
+
+	if jptm.ShouldLog(pipeline.LogDebug) {
+		jptm.Log(pipeline.LogDebug, fmt.Sprintf("ARCHIVE ATTRIBUTE CLEARED: %s", strings.Split(source, "?")[0]))
+	}
+}
@@ -33,6 +33,9 @@ func DeleteBlobPrologue(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pac
 	// Internal function is created to avoid redundancy of the above steps from several places in the api.
 	transferDone := func(status common.TransferStatus, err error) {
 		if status == common.ETransferStatus.Failed() {
+			if explanation := explainLeasedBlob(jptm, err); explanation != "" {
+				jptm.Log(pipeline.LogError, fmt.Sprintf("%s: %s", explanation, strings.Split(info.Source, "?")[0]))
+			}
 			jptm.LogError(info.Source, "DELETE ERROR ", err)
 		} else if status == common.ETransferStatus.SkippedBlobHasSnapshots() {
 			explainedSkippedRemoveOnce.Do(func() {
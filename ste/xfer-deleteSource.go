@@ -0,0 +1,93 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// deleteSourceAfterTransfer deletes the source of a transfer that has just succeeded, if the job asks for that.
+// It must only be called once the transfer's status is Success, so that the source of a transfer that failed, or was skipped, is never touched.
+// A source that can't be deleted is kept, and the transfer still counts as successful, since its destination is complete.
+// srcPipeline is the one that reads the source, and is not used for local sources.
+func deleteSourceAfterTransfer(jptm IJobPartTransferMgr, srcPipeline pipeline.Pipeline) {
+	if !jptm.DeleteSourceAfterTransfer() {
+		return
+	}
+
+	source := jptm.Info().Source
+	fromTo := jptm.FromTo()
+	if err := deleteSource(jptm.Context(), fromTo.From(), source, jptm.LastModifiedTime(), srcPipeline); err != nil {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning, "The source was kept, since it could not be deleted: "+err.Error())
+		return
+	}
+
+	jptm.SetSourceDeleted()
+	if jptm.ShouldLog(pipeline.LogInfo) {
+		jptm.Log(pipeline.LogInfo, fmt.Sprintf("SOURCE DELETED: %s", strings.Split(source, "?")[0]))
+	}
+}
+
+// deleteSource deletes a local file, a blob or an Azure file. Local files and blobs are only deleted if they weren't modified
+// since the time that they had when they were enumerated, so that changes made to them while they were copied aren't lost
+func deleteSource(ctx context.Context, from common.Location, source string, lmt time.Time, p pipeline.Pipeline) error {
+	hasLmt := lmt.After(time.Unix(0, 0))
+	if from == common.ELocation.Local() {
+		if fi, err := os.Stat(source); err != nil {
+			return err
+		} else if hasLmt && !fi.ModTime().Equal(lmt) {
+			return fmt.Errorf("the file was modified after it was enumerated")
+		}
+		return os.Remove(source)
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return err
+	}
+	switch from {
+	case common.ELocation.Blob():
+		var ac azblob.BlobAccessConditions
+		if hasLmt {
+			ac.ModifiedAccessConditions.IfUnmodifiedSince = lmt
+		}
+		_, err = azblob.NewBlobURL(*u, p).Delete(ctx, azblob.DeleteSnapshotsOptionNone, ac)
+	case common.ELocation.File():
+		_, err = azfile.NewFileURL(*u, p).Delete(ctx)
+	case common.ELocation.BlobFS():
+		_, err = azbfs.NewFileURL(*u, p).Delete(ctx)
+	default:
+		err = fmt.Errorf("deleting a source in %s is not supported", from)
+	}
+	return err
+}
@@ -21,11 +21,15 @@
 package ste
 
 import (
+	"crypto/md5"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-azcopy/common"
@@ -49,10 +53,18 @@ func remoteToLocal(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, d
 		jptm.ReportTransferDone()
 		return
 	}
+	// if an earlier attempt at the download saved part of the file, this one carries on from there
+	// (and the file is then the download's own, rather than one that might be overwritten)
+	savedLength := int64(0)
+	if canResumeDownload(jptm, info) {
+		savedLength = savedLengthToResumeFrom(jptm, info, fileSize, downloadChunkSize)
+	}
+
 	// if the force Write flags is set to false or prompt
 	// then check the file exists at the remote location
 	// if it does, react accordingly
-	if jptm.GetOverwriteOption() != common.EOverwriteOption.True() {
+	// (there's nothing to overwrite when the data is discarded, even though the null device exists)
+	if savedLength == 0 && jptm.GetOverwriteOption() != common.EOverwriteOption.True() && !strings.EqualFold(info.Destination, common.Dev_Null) {
 		dstProps, err := os.Stat(info.Destination)
 		if err == nil {
 			// if the error is nil, then file exists locally
@@ -95,7 +107,7 @@ func remoteToLocal(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, d
 				jptm.SetStatus(common.ETransferStatus.Failed())
 			}
 		}
-		epilogueWithCleanupDownload(jptm, dl, nil, nil) // need standard epilogue, rather than a quick exit, so we can preserve modification dates
+		epilogueWithCleanupDownload(jptm, dl, p, nil, nil) // need standard epilogue, rather than a quick exit, so we can preserve modification dates
 		return
 	}
 
@@ -114,11 +126,23 @@ func remoteToLocal(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, d
 		jptm.LogDownloadError(info.Source, info.Destination, "File Creation Error "+err.Error(), 0)
 		jptm.SetStatus(common.ETransferStatus.Failed())
 		// use standard epilogue for consistency, but force release of file count (without an actual file) if necessary
-		epilogueWithCleanupDownload(jptm, dl, nil, nil)
+		epilogueWithCleanupDownload(jptm, dl, p, nil, nil)
 	}
+	// hold the file back while the destination volume has no room for it (before it takes up one of the file handles)
+	diskSpace := jptm.GetDiskSpaceGuard()
+	spaceNeeded := fileSize - savedLength
+	if strings.EqualFold(info.Destination, common.Dev_Null) {
+		spaceNeeded = 0
+	}
+	if err := diskSpace.waitForSpace(jptm.Context(), info.Destination, spaceNeeded); err != nil {
+		failFileCreation(err)
+		return
+	}
+
 	// block until we can safely use a file handle
 	err := jptm.WaitUntilLockDestination(jptm.Context())
 	if err != nil {
+		diskSpace.created(spaceNeeded)
 		failFileCreation(err)
 		return
 	}
@@ -126,21 +150,34 @@ func remoteToLocal(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, d
 	var dstFile io.WriteCloser
 	if strings.EqualFold(info.Destination, common.Dev_Null) {
 		// the user wants to discard the downloaded data
-		dstFile = devNullWriter{}
+		dstFile = &devNullWriter{}
 	} else {
 		// Normal scenario, create the destination file as expected
 		// Use pseudo chunk id to alow our usual state tracking mechanism to keep count of how many
 		// file creations are running at any given instant, for perf diagnostics
 		pseudoId := common.NewPseudoChunkIDForWholeFile(info.Source)
 		jptm.LogChunkStatus(pseudoId, common.EWaitReason.CreateLocalFile())
-		dstFile, err = createDestinationFile(jptm, info.Destination, fileSize, writeThrough)
+		if jptm.DiffBaseSnapshot() != "" {
+			// only the changes are downloaded, into the file that already holds the base snapshot
+			dstFile, err = openDestinationForDiff(jptm, dl, p, info.Destination, fileSize)
+		} else if savedLength > 0 {
+			dstFile, err = openDestinationForResume(info.Destination, savedLength)
+		} else {
+			dstFile, err = createDestinationFile(jptm, info.Destination, fileSize, writeThrough)
+		}
 		jptm.LogChunkStatus(pseudoId, common.EWaitReason.ChunkDone()) // normal setting to done doesn't apply to these pseudo ids
+		diskSpace.created(spaceNeeded)
 		if err != nil {
 			failFileCreation(err)
 			return
 		}
 	}
 
+	// the checksum, if the job needs one, is of the bytes as they are saved, so that it's the checksum of the file
+	if hasher := jptm.ChecksumHasher(); hasher != nil {
+		dstFile = &checksumWriter{WriteCloser: dstFile, hash: hasher}
+	}
+
 	// TODO: Question: do we need to Stat the file, to check its size, after explicitly making it with the desired size?
 	// That was what the old xfer-blobToLocal code used to do
 	// I've commented it out to be more concise, but we'll put it back if someone knows why it needs to be here
@@ -158,34 +195,64 @@ func remoteToLocal(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, d
 			return
 		}*/
 
-	// step 5a: compute num chunks
-	numChunks := uint32(0)
-	if rem := fileSize % downloadChunkSize; rem == 0 {
-		numChunks = uint32(fileSize / downloadChunkSize)
-	} else {
-		numChunks = uint32(fileSize/downloadChunkSize + 1)
+	// step 5a: plan the chunks (of what's still to be downloaded)
+	plan := planDownload(fileSize, savedLength, downloadChunkSize, canGetWholeFile(jptm, dl), canCoalesceChunks(jptm),
+		jptm.MD5ValidationOption() != common.EHashValidationOption.NoCheck())
+	if plan.wholeFile {
+		dl.(wholeFileDownloader).downloadWholeFile()
 	}
+	numChunks := plan.numChunks
+	downloadChunkSize = plan.chunkSize
 
 	// step 5b: create destination writer
 	chunkLogger := jptm.ChunkStatusLogger()
-	sourceMd5Exists := len(info.SrcHTTPHeaders.ContentMD5) > 0
-	dstWriter := common.NewChunkedFileWriter(
-		jptm.Context(),
-		jptm.SlicePool(),
-		jptm.CacheLimiter(),
-		chunkLogger,
-		dstFile,
-		numChunks,
-		MaxRetryPerDownloadBody,
-		jptm.MD5ValidationOption(),
-		sourceMd5Exists)
+	// the chunks that are left unchanged in an incremental download are not hashed, so there's no hash of the whole file to check.
+	// Nor is there for a ranged download, since the stored hash is of the whole source, not of the window.
+	_, ranged := jptm.DownloadRange()
+	sourceMd5Exists := len(info.SrcHTTPHeaders.ContentMD5) > 0 && jptm.DiffBaseSnapshot() == "" && !ranged
+	if ranged && len(info.SrcHTTPHeaders.ContentMD5) > 0 {
+		jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Only a range of the source is downloaded, so its MD5 hash, which is of the whole source, is not checked")
+	}
+	var dstWriter common.ChunkedFileWriter
+	if strings.EqualFold(info.Destination, common.Dev_Null) &&
+		(jptm.MD5ValidationOption() == common.EHashValidationOption.NoCheck() || !sourceMd5Exists) && jptm.ChecksumHasher() == nil {
+		// there's nothing to save, and nothing to hash, so there's no point in waiting for the chunks to be put in order
+		// (but the data is still counted, for the length check)
+		dstWriter = common.NewDiscardChunkedFileWriterToSink(chunkLogger, dstFile, MaxRetryPerDownloadBody)
+	} else {
+		// the progress of the download is recorded, so that, if the job is resumed, it can carry on from what's saved
+		resume := common.ChunkedFileResume{}
+		if f, ok := dstFile.(*os.File); ok && canResumeDownload(jptm, info) {
+			resume = newChunkedFileResume(jptm, f, savedLength)
+		}
+		// the job may also want the file synced as it's written, each time it has the given number of bytes more (or each chunk)
+		if policy, interval := jptm.FlushPolicy(); policy == common.EFlushPolicy.PerChunk() {
+			if interval <= 0 {
+				interval = 1
+			}
+			if resume.SyncInterval == 0 || interval < resume.SyncInterval {
+				resume.SyncInterval = interval
+			}
+		}
+		dstWriter = common.NewResumableChunkedFileWriter(
+			jptm.Context(),
+			jptm.SlicePool(),
+			jptm.CacheLimiter(),
+			chunkLogger,
+			dstFile,
+			numChunks,
+			MaxRetryPerDownloadBody,
+			jptm.MD5ValidationOption(),
+			sourceMd5Exists,
+			resume)
+	}
 
 	// step 5c: run prologue in downloader (here it can, for example, create things that will require cleanup in the epilogue)
 	dl.Prologue(jptm, p)
 
 	// step 5d: tell jptm what to expect, and how to clean up at the end
 	jptm.SetNumberOfChunks(numChunks)
-	jptm.SetActionAfterLastChunk(func() { epilogueWithCleanupDownload(jptm, dl, dstFile, dstWriter) })
+	jptm.SetActionAfterLastChunk(func() { epilogueWithCleanupDownload(jptm, dl, p, dstFile, dstWriter) })
 
 	// step 6: go through the blob range and schedule download chunk jobs
 	// TODO: currently, the epilogue will only run if the number of completed chunks = numChunks.
@@ -197,7 +264,7 @@ func remoteToLocal(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer, d
 	// eventually reach numChunks, since we have no better short-term alternative.
 
 	chunkCount := uint32(0)
-	for startIndex := int64(0); startIndex < fileSize; startIndex += downloadChunkSize {
+	for startIndex := savedLength; startIndex < fileSize; startIndex += downloadChunkSize {
 		adjustedChunkSize := downloadChunkSize
 
 		// compute exact size of the chunk
@@ -260,8 +327,61 @@ func createDestinationFile(jptm IJobPartTransferMgr, destination string, size in
 	return dstFile, nil
 }
 
+// diffDownloader is implemented by the downloaders that can download only the ranges that changed since a base snapshot
+type diffDownloader interface {
+	// prepareDiff gets the ranges that changed, so that the download funcs skip over the others
+	prepareDiff(jptm IJobPartTransferMgr, srcPipeline pipeline.Pipeline) (*pageBlobDiff, error)
+}
+
+// openDestinationForDiff opens the existing destination file of an incremental download, once it has checked that the file
+// holds the base snapshot. The file is resized to the size of the source, but nothing in it is changed until the chunks are saved.
+func openDestinationForDiff(jptm IJobPartTransferMgr, dl downloader, srcPipeline pipeline.Pipeline, destination string, size int64) (io.WriteCloser, error) {
+	dd, ok := dl.(diffDownloader)
+	if !ok {
+		return nil, errNotAPageBlob
+	}
+	diff, err := dd.prepareDiff(jptm, srcPipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(destination)
+	if err != nil {
+		return nil, fmt.Errorf("the destination must already hold the base snapshot %s: %s", diff.baseSnapshot, err.Error())
+	}
+
+	f, err := os.OpenFile(destination, os.O_RDWR, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return nil, err
+	}
+
+	var localMD5 []byte
+	if len(diff.baseMD5) > 0 {
+		// only worth reading the whole file if there is something to compare it to
+		h := md5.New()
+		if _, err = io.Copy(h, f); err == nil {
+			localMD5 = h.Sum(nil)
+			_, err = f.Seek(0, io.SeekStart)
+		}
+	}
+	if err == nil {
+		err = diff.validateDestination(fi.Size(), localMD5)
+	}
+	if err == nil && fi.Size() != size {
+		err = f.Truncate(size)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	jptm.ReportPageBlobDiff(diff.changedBytes(), size)
+	jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Downloading the %d bytes that changed since the base snapshot %s", diff.changedBytes(), diff.baseSnapshot))
+	return f, nil
+}
+
 // complete epilogue. Handles both success and failure
-func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, activeDstFile io.WriteCloser, cw common.ChunkedFileWriter) {
+func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, srcPipeline pipeline.Pipeline, activeDstFile io.WriteCloser, cw common.ChunkedFileWriter) {
 	info := jptm.Info()
 
 	// allow our usual state tracking mechanism to keep count of how many epilogues are running at any given instant, for perf diagnostics
@@ -282,9 +402,14 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 			jptm.FailActiveDownload("Closing file", closeErr)
 			jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Error closing file: "+closeErr.Error()) // log this way so that this line will be logged even if transfer is already failed
 		}
+		if cw, ok := activeDstFile.(*checksumWriter); ok {
+			jptm.SetChecksum(cw.hash.Sum(nil))
+			activeDstFile = cw.WriteCloser
+		}
 
 		// Check MD5 (but only if file was fully flushed and saved - else no point and may not have actualAsSaved hash anyway)
-		if jptm.IsLive() {
+		// An incremental download doesn't hash the whole file, so it can't be checked. Nor can a ranged download, which only has part of it.
+		if _, ranged := jptm.DownloadRange(); jptm.IsLive() && jptm.DiffBaseSnapshot() == "" && !ranged {
 			comparison := md5Comparer{
 				expected:         info.SrcHTTPHeaders.ContentMD5, // the MD5 that came back from Service when we enumerated the source
 				actualAsSaved:    md5OfFileAsWritten,
@@ -295,13 +420,22 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 				jptm.FailActiveDownload("Checking MD5 hash", err)
 			}
 		}
+	} else if info.SourceSize == 0 && jptm.IsLive() {
+		if err := checkEmptyFileMD5(jptm, info.SrcHTTPHeaders.ContentMD5); err != nil {
+			jptm.FailActiveDownload("Checking MD5 hash", err)
+		}
 	}
 
 	if dl != nil {
 		dl.Epilogue() // it can release resources here
 
-		// check length if enabled (except for dev null and decompression case, where that's impossible)
-		if jptm.IsLive() && info.DestLengthValidation && info.Destination != common.Dev_Null && !jptm.ShouldDecompress() {
+		// check length if enabled (except for the decompression case, where that's impossible).
+		// When the data was discarded, it's the length of what would have been saved that's checked.
+		if discarded, ok := activeDstFile.(*devNullWriter); ok && jptm.IsLive() && info.DestLengthValidation && !jptm.ShouldDecompress() {
+			if discarded.bytesWritten() != info.SourceSize {
+				jptm.FailActiveDownload("Download length check", errors.New("length of the downloaded data did not match source length"))
+			}
+		} else if jptm.IsLive() && info.DestLengthValidation && info.Destination != common.Dev_Null && !jptm.ShouldDecompress() {
 			fi, err := os.Stat(info.Destination)
 
 			if err != nil {
@@ -314,13 +448,23 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		}
 	}
 
-	// Preserve modified time
 	if jptm.IsLive() {
+		if err := jptm.VerifyChecksum(); err != nil {
+			jptm.FailActiveDownload("Verifying checksum", err)
+		}
+	}
+
+	// Preserve modified time (unless the data was discarded, in which case we must not touch the null device)
+	if jptm.IsLive() && !strings.EqualFold(info.Destination, common.Dev_Null) {
 		// TODO: the old version of this code did NOT consider it an error to be unable to set the modification date/time
 		// TODO: ...So I have preserved that behavior here.
 		// TODO: question: But is that correct?
 		lastModifiedTime, preserveLastModifiedTime := jptm.PreserveLastModifiedTime()
 		if preserveLastModifiedTime {
+			// the source may keep the time of the file that it was uploaded from (--preserve-source-lmt-as-metadata), which is the one to restore
+			if preservedTime, ok := info.SrcMetadata.PreservedLastModifiedTime(); ok {
+				lastModifiedTime = preservedTime
+			}
 			err := os.Chtimes(jptm.Info().Destination, lastModifiedTime, lastModifiedTime)
 			if err != nil {
 				jptm.LogError(info.Destination, "Changing Modified Time ", err)
@@ -331,6 +475,13 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		}
 	}
 
+	// make sure the file is on stable storage before its transfer is reported successful, if the job asks for it (--flush-policy)
+	if policy, _ := jptm.FlushPolicy(); policy != common.EFlushPolicy.None() && jptm.IsLive() && !strings.EqualFold(info.Destination, common.Dev_Null) {
+		if err := common.SyncToDisk(info.Destination); err != nil {
+			jptm.FailActiveDownload("Flushing file to disk", err)
+		}
+	}
+
 	// note that we do not really know whether the context was canceled because of an error, or because the user asked for it
 	// if was an intentional cancel, the status is still "in progress", so we are still counting it as pending
 	// we leave these transfer status alone
@@ -342,10 +493,18 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		if jptm.ShouldLog(pipeline.LogDebug) {
 			jptm.Log(pipeline.LogDebug, " Finalizing Transfer Cancellation/Failure")
 		}
-		if jptm.IsDeadInflight() && jptm.HoldsDestinationLock() {
+		if jptm.IsDeadInflight() && jptm.HoldsDestinationLock() && jptm.DiffBaseSnapshot() != "" {
+			// the file held the base snapshot before, and is worth keeping, since running the copy again only downloads the changes
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogWarning,
+				"The incremental download did not complete, so the destination file holds neither snapshot. Run the same copy again to complete it")
+		} else if savedLength, _ := jptm.SavedDownload(); jptm.IsDeadInflight() && jptm.HoldsDestinationLock() && savedLength > 0 && jptm.TransferStatusIgnoringCancellation() >= 0 {
+			// the job was cancelled, rather than the transfer failing, so the part of the file that's saved is kept for when the job is resumed
+			jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, fmt.Sprintf("Keeping the first %d bytes of the incomplete destination file, so that resuming the job only downloads the rest", savedLength))
+		} else if jptm.IsDeadInflight() && jptm.HoldsDestinationLock() {
 			jptm.LogAtLevelForCurrentTransfer(pipeline.LogInfo, "Deleting incomplete destination file")
 
-			// the file created locally should be deleted
+			// the file created locally should be deleted, and with it what the plan file says is saved of it
+			jptm.SetSavedDownload(0, time.Time{})
 			tryDeleteFile(info, jptm)
 		}
 	} else {
@@ -357,11 +516,13 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		// and we know the transfer didn't fail (because just checked its status above),
 		// so it must have succeeded. So make sure its not left "in progress" state
 		jptm.SetStatus(common.ETransferStatus.Success())
+		jptm.RecordChecksum()
 
 		// Final logging
 		if jptm.ShouldLog(pipeline.LogInfo) { // TODO: question: can we remove these ShouldLogs?  Aren't they inside Log?
 			jptm.Log(pipeline.LogInfo, fmt.Sprintf("DOWNLOADSUCCESSFUL: %s", info.Destination))
 		}
+		deleteSourceAfterTransfer(jptm, srcPipeline)
 		if jptm.ShouldLog(pipeline.LogDebug) {
 			jptm.Log(pipeline.LogDebug, "Finalizing Transfer")
 		}
@@ -408,13 +569,55 @@ func tryDeleteFile(info TransferInfo, jptm IJobPartTransferMgr) {
 }
 
 // conforms to io.Writer and io.Closer
-// does absolutely nothing to discard the given data
-type devNullWriter struct{}
+// does absolutely nothing to discard the given data, except count it. Safe for concurrent use
+type devNullWriter struct {
+	written int64 // accessed atomically
+}
 
-func (devNullWriter) Write(p []byte) (n int, err error) {
+func (w *devNullWriter) Write(p []byte) (n int, err error) {
+	atomic.AddInt64(&w.written, int64(len(p)))
 	return len(p), nil
 }
 
-func (devNullWriter) Close() error {
+func (w *devNullWriter) Close() error {
 	return nil
 }
+
+func (w *devNullWriter) bytesWritten() int64 {
+	return atomic.LoadInt64(&w.written)
+}
+
+// checksumWriter hashes what is written to the file. Since the chunks are saved in order, that's the checksum of the file
+type checksumWriter struct {
+	io.WriteCloser
+	hash hash.Hash
+}
+
+func (w *checksumWriter) Write(p []byte) (n int, err error) {
+	n, err = w.WriteCloser.Write(p)
+	_, _ = w.hash.Write(p[:n])
+	return n, err
+}
+
+// Sync syncs the file that the checksum is of, if it can be synced, so that the job can have it synced as it's written
+func (w *checksumWriter) Sync() error {
+	if s, ok := w.WriteCloser.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// checkEmptyFileMD5 checks the MD5 stored against an empty source, which must be the MD5 of no data at all.
+// There is nothing in an empty file to corrupt, so a missing MD5 is never an error, whatever the validation option.
+func checkEmptyFileMD5(jptm IJobPartTransferMgr, expected []byte) error {
+	if len(expected) == 0 {
+		return nil
+	}
+	md5OfNothing := md5.Sum(nil)
+	comparison := md5Comparer{
+		expected:         expected,
+		actualAsSaved:    md5OfNothing[:],
+		validationOption: jptm.MD5ValidationOption(),
+		logger:           jptm}
+	return comparison.Check()
+}
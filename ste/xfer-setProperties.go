@@ -0,0 +1,215 @@
+package ste
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// blob index tags were introduced in this service version, which is newer than the one our blob SDK targets
+const blobTagsServiceVersion = "2019-12-12"
+
+var explainedSkippedSetPropertiesOnce sync.Once
+
+// SetPropertiesPrologue changes the tier, metadata and/or tags of an existing blob, without moving any data
+func SetPropertiesPrologue(jptm IJobPartTransferMgr, p pipeline.Pipeline, pacer pacer) {
+
+	info := jptm.Info()
+	// Get the source blob url of the blob whose properties are to be set
+	u, _ := url.Parse(info.Source)
+
+	srcBlobURL := azblob.NewBlobURL(*u, p)
+
+	// If the transfer was cancelled, then report the transfer as done
+	if jptm.WasCanceled() {
+		jptm.ReportTransferDone()
+		return
+	}
+
+	// Internal function which checks the transfer status and logs the msg respectively.
+	// Sets the transfer status and Report Transfer as Done.
+	transferDone := func(status common.TransferStatus, err error, reason string) {
+		if status == common.ETransferStatus.Failed() {
+			jptm.LogError(info.Source, "SET-PROPERTIES ERROR ", err)
+		} else if status == common.ETransferStatus.SkippedIncompatibleBlobType() {
+			explainedSkippedSetPropertiesOnce.Do(func() {
+				common.GetLifecycleMgr().Info("Blobs whose type does not support the requested properties are skipped. Check the log file for details.")
+			})
+
+			// log at error level so that it's clear why the transfer was skipped even when the log level is set to error
+			jptm.Log(pipeline.LogError, fmt.Sprintf("SET-PROPERTIES SKIPPED(%s): %s", reason, strings.Split(info.Source, "?")[0]))
+		} else {
+			jptm.Log(pipeline.LogInfo, fmt.Sprintf("SET-PROPERTIES SUCCESSFUL: %s", strings.Split(info.Source, "?")[0]))
+		}
+
+		jptm.SetStatus(status)
+		jptm.ReportTransferDone()
+	}
+
+	flags := jptm.SetPropertiesFlags()
+
+	// work out the tier up front, so that a blob whose type can't take the tier is skipped before anything about it is changed
+	var tier azblob.AccessTierType
+	if flags.ShouldTransferTier() {
+		var reason string
+		tier, reason = setPropertiesTierFor(jptm, info.SrcBlobType)
+		if reason != "" {
+			transferDone(common.ETransferStatus.SkippedIncompatibleBlobType(), nil, reason)
+			return
+		}
+	}
+
+	handleErr := func(err error) {
+		// If the status code was 403, it means there was an authentication error and we exit.
+		// User can resume the job if completely ordered with a new sas.
+		if resp, ok := err.(hasResponse); ok && resp.Response() != nil && resp.Response().StatusCode == http.StatusForbidden {
+			errMsg := fmt.Sprintf("Authentication Failed. The SAS is not correct or expired or does not have the correct permission %s", err.Error())
+			jptm.Log(pipeline.LogError, errMsg)
+			common.GetLifecycleMgr().Error(errMsg)
+		}
+
+		transferDone(common.ETransferStatus.Failed(), err, "")
+	}
+
+	if flags.ShouldTransferTier() {
+		ctxWithLatestServiceVersion := context.WithValue(jptm.Context(), ServiceAPIVersionOverride, azblob.ServiceVersion)
+		if _, err := srcBlobURL.SetTier(ctxWithLatestServiceVersion, tier, azblob.LeaseAccessConditions{}); err != nil {
+			handleErr(err)
+			return
+		}
+	}
+
+	if flags.ShouldTransferMetaData() {
+		_, metadata := jptm.BlobDstData(nil)
+		if _, err := srcBlobURL.SetMetadata(jptm.Context(), metadata, azblob.BlobAccessConditions{}); err != nil {
+			handleErr(err)
+			return
+		}
+	}
+
+	if flags.ShouldTransferBlobTags() {
+		if err := SetBlobTags(jptm.Context(), p, srcBlobURL.URL(), jptm.BlobTags()); err != nil {
+			handleErr(err)
+			return
+		}
+	}
+
+	transferDone(common.ETransferStatus.Success(), nil, "")
+}
+
+// setPropertiesTierFor returns the tier to apply to a blob of the given type,
+// or a non-empty reason if the requested tier cannot be applied to that type of blob
+func setPropertiesTierFor(jptm IJobPartTransferMgr, blobType azblob.BlobType) (tier azblob.AccessTierType, skipReason string) {
+	blockBlobTier, pageBlobTier := jptm.BlobTiers()
+
+	switch blobType {
+	case azblob.BlobBlockBlob:
+		if blockBlobTier == common.EBlockBlobTier.None() {
+			return "", "only a page blob tier was requested, but this is a block blob"
+		}
+		return blockBlobTier.ToAccessTierType(), ""
+	case azblob.BlobPageBlob:
+		if pageBlobTier == common.EPageBlobTier.None() {
+			return "", "only a block blob tier was requested, but this is a page blob"
+		}
+		return pageBlobTier.ToAccessTierType(), ""
+	case azblob.BlobAppendBlob:
+		return "", "append blobs do not support access tiers"
+	default:
+		return "", fmt.Sprintf("cannot set the tier of a blob of unknown type %q", blobType)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// the version of the blob SDK we use does not support blob index tags, so we issue the Set Blob Tags request ourselves
+type blobTagsBody struct {
+	XMLName xml.Name     `xml:"Tags"`
+	TagSet  []blobTagXML `xml:"TagSet>Tag"`
+}
+
+type blobTagXML struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// blobTagsResponseError is returned when the service rejects a Set Blob Tags request
+type blobTagsResponseError struct {
+	response *http.Response
+}
+
+func (e blobTagsResponseError) Error() string {
+	return fmt.Sprintf("set blob tags failed: %s, service code %q", e.response.Status, e.response.Header.Get("x-ms-error-code"))
+}
+
+func (e blobTagsResponseError) Response() *http.Response {
+	return e.response
+}
+
+// SetBlobTags replaces the index tags of the blob. It is also used to probe whether a destination supports tags at all
+func SetBlobTags(ctx context.Context, p pipeline.Pipeline, blobURL url.URL, tags common.BlobTags) error {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	body := blobTagsBody{TagSet: make([]blobTagXML, 0, len(keys))}
+	for _, k := range keys {
+		body.TagSet = append(body.TagSet, blobTagXML{Key: k, Value: tags[k]})
+	}
+	b, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := pipeline.NewRequest(http.MethodPut, blobURL, bytes.NewReader(b))
+	if err != nil {
+		return pipeline.NewError(err, "failed to create request")
+	}
+	params := req.URL.Query()
+	params.Set("comp", "tags")
+	req.URL.RawQuery = params.Encode()
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Content-Length", strconv.Itoa(len(b)))
+
+	// the version policy in our pipeline overwrites x-ms-version from the context, so the newer version must be set there
+	ctx = context.WithValue(ctx, ServiceAPIVersionOverride, blobTagsServiceVersion)
+	_, err = p.Do(ctx, blobTagsResponderFactory, req)
+	return err
+}
+
+var blobTagsResponderFactory = pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		resp, err := next.Do(ctx, request)
+		if err != nil {
+			return resp, err
+		}
+
+		r := resp.Response()
+		if r.StatusCode != http.StatusNoContent {
+			// buffer the body, so that the error can still be inspected after the connection is released
+			errBody, _ := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = ioutil.NopCloser(bytes.NewReader(errBody))
+			return resp, blobTagsResponseError{response: r}
+		}
+
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+		return resp, nil
+	}
+})
@@ -40,6 +40,10 @@ const UploadMaxRetryDelay = time.Second * 60
 
 var ADLSFlushThreshold uint32 = 7500 // The # of blocks to flush at a time-- Implemented only for CI.
 
+// Files in a single chunk that are smaller than this many bytes are written to accounts that have a hierarchical namespace
+// with one request that appends, flushes and closes them, rather than with separate ones. 0 turns that off.
+var ADLSSmallFileThreshold int64 = 4 * 1024 * 1024
+
 // download related
 const MaxRetryPerDownloadBody = 5
 
@@ -153,6 +157,8 @@ func computeJobXfer(fromTo common.FromTo, blobType common.BlobType) newJobXfer {
 		return DeleteBlobPrologue
 	case fromTo == common.EFromTo.FileTrash():
 		return DeleteFilePrologue
+	case fromTo == common.EFromTo.BlobNone():
+		return SetPropertiesPrologue
 	default:
 		if fromTo.IsDownload() {
 			return parameterizeDownload(remoteToLocal, getDownloader(fromTo.From()))
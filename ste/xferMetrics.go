@@ -0,0 +1,233 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+// transferMetrics holds the timings of a single transfer. It's only created for jobs that were asked to
+// record metrics, so that the transfers of all other jobs pay nothing more than a nil check.
+type transferMetrics struct {
+	recorder  *transferMetricsRecorder
+	queuedAt  time.Time // when the transfer was scheduled
+	startedAt time.Time // when a transfer initiation worker picked it up
+	workerID  int
+
+	atomicFirstByteAt int64 // UnixNano of when the first chunk got to the wire; zero if none did
+	atomicRetries     int32
+}
+
+var transferMetricsContextKey = contextKey{"transferMetrics"}
+
+// withTransferMetrics returns a context from which the stats policy can find the transfer whose retries it should count
+func withTransferMetrics(ctx context.Context, m *transferMetrics) context.Context {
+	return context.WithValue(ctx, transferMetricsContextKey, m)
+}
+
+// recordTransferRetry counts a retry against the transfer that the request belongs to, if it is recording metrics
+func recordTransferRetry(ctx context.Context) {
+	if m, ok := ctx.Value(transferMetricsContextKey).(*transferMetrics); ok {
+		atomic.AddInt32(&m.atomicRetries, 1)
+	}
+}
+
+func (m *transferMetrics) recordStart(workerID int) {
+	m.startedAt = time.Now()
+	m.workerID = workerID
+}
+
+func (m *transferMetrics) recordChunkStatus(reason common.WaitReason) {
+	if reason == common.EWaitReason.Body() || reason == common.EWaitReason.S2SCopyOnWire() {
+		atomic.CompareAndSwapInt64(&m.atomicFirstByteAt, 0, time.Now().UnixNano())
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// transferMetricsRecord is one line of the metrics file
+type transferMetricsRecord struct {
+	PartNum       common.PartNumber `json:"partNum"`
+	TransferIndex uint32            `json:"transferIndex"`
+	Source        string            `json:"source"`
+	Destination   string            `json:"destination"`
+	Status        string            `json:"status"`
+	Bytes         int64             `json:"bytes"`
+	QueueMs       int64             `json:"queueMs"`
+	FirstByteMs   int64             `json:"firstByteMs"` // -1 if no data was sent or received, e.g. for an empty file
+	DurationMs    int64             `json:"durationMs"`
+	Retries       int32             `json:"retries"`
+	Worker        int               `json:"worker"`
+}
+
+var transferMetricsCsvHeader = []string{"PartNum", "TransferIndex", "Source", "Destination", "Status", "Bytes", "QueueMs", "FirstByteMs", "DurationMs", "Retries", "Worker"}
+
+func (r transferMetricsRecord) csvFields() []string {
+	return []string{
+		strconv.FormatUint(uint64(r.PartNum), 10),
+		strconv.FormatUint(uint64(r.TransferIndex), 10),
+		r.Source,
+		r.Destination,
+		r.Status,
+		strconv.FormatInt(r.Bytes, 10),
+		strconv.FormatInt(r.QueueMs, 10),
+		strconv.FormatInt(r.FirstByteMs, 10),
+		strconv.FormatInt(r.DurationMs, 10),
+		strconv.FormatInt(int64(r.Retries), 10),
+		strconv.Itoa(r.Worker),
+	}
+}
+
+// transferMetricsRecorder writes the metrics of each transfer of a job, as it completes, to the metrics file.
+// The file is CSV, unless its name ends in .json or .ndjson, in which case it has one JSON object per line.
+// The durations are also kept in memory, so that percentiles can be reported at the end of the job.
+type transferMetricsRecorder struct {
+	unsavedRecords chan *transferMetricsRecord
+	flushDone      chan struct{}
+
+	durationsLock sync.Mutex
+	durationsMs   []int64
+}
+
+func newTransferMetricsRecorder(metricsFile string) (*transferMetricsRecorder, error) {
+	f, err := os.OpenFile(metricsFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(metricsFile))
+	r := &transferMetricsRecorder{
+		unsavedRecords: make(chan *transferMetricsRecord, 100000),
+		flushDone:      make(chan struct{}),
+	}
+	go r.main(f, ext == ".json" || ext == ".ndjson")
+	return r, nil
+}
+
+// record is called when the transfer is done
+func (r *transferMetricsRecorder) record(jptm *jobPartTransferMgr) {
+	m := jptm.metrics
+	now := time.Now()
+	plan := jptm.jobPartMgr.Plan()
+	src, dst := plan.TransferSrcDstStrings(jptm.transferIndex)
+
+	startedAt := m.startedAt
+	if startedAt.IsZero() {
+		startedAt = now // cancelled before any worker picked it up, so it spent all its time in the queue
+	}
+	firstByteMs := int64(-1)
+	if fb := atomic.LoadInt64(&m.atomicFirstByteAt); fb != 0 {
+		firstByteMs = time.Unix(0, fb).Sub(startedAt).Milliseconds()
+	}
+
+	rec := &transferMetricsRecord{
+		PartNum:       plan.PartNum,
+		TransferIndex: jptm.transferIndex,
+		Source:        common.URLStringExtension(src).RedactSecretQueryParamForLogging(),
+		Destination:   common.URLStringExtension(dst).RedactSecretQueryParamForLogging(),
+		Status:        jptm.jobPartPlanTransfer.TransferStatus().String(),
+		Bytes:         jptm.jobPartPlanTransfer.SourceSize,
+		QueueMs:       startedAt.Sub(m.queuedAt).Milliseconds(),
+		FirstByteMs:   firstByteMs,
+		DurationMs:    now.Sub(startedAt).Milliseconds(),
+		Retries:       atomic.LoadInt32(&m.atomicRetries),
+		Worker:        m.workerID,
+	}
+
+	r.durationsLock.Lock()
+	r.durationsMs = append(r.durationsMs, rec.DurationMs)
+	r.durationsLock.Unlock()
+
+	r.unsavedRecords <- rec
+}
+
+// Flush returns after everything that has been recorded so far is saved in the file
+func (r *transferMetricsRecorder) Flush() {
+	r.unsavedRecords <- nil // tell writer that it must flush, then wait until it has done so
+	<-r.flushDone
+}
+
+func (r *transferMetricsRecorder) main(f *os.File, asJson bool) {
+	defer func() { _ = f.Close() }()
+
+	bw := bufio.NewWriter(f)
+	cw := csv.NewWriter(bw)
+	if !asJson {
+		_ = cw.Write(transferMetricsCsvHeader)
+	}
+	enc := json.NewEncoder(bw)
+
+	for rec := range r.unsavedRecords {
+		if rec == nil {
+			cw.Flush()
+			_ = bw.Flush()
+			r.flushDone <- struct{}{}
+			continue
+		}
+		if asJson {
+			_ = enc.Encode(rec) // adds the newline
+		} else {
+			_ = cw.Write(rec.csvFields())
+		}
+	}
+}
+
+// DurationPercentiles returns the p50, p95 and p99 of the duration of all transfers recorded so far
+func (r *transferMetricsRecorder) DurationPercentiles() *common.TransferDurationPercentiles {
+	r.durationsLock.Lock()
+	sorted := make([]int64, len(r.durationsMs))
+	copy(sorted, r.durationsMs)
+	r.durationsLock.Unlock()
+
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &common.TransferDurationPercentiles{
+		TransferCount:   len(sorted),
+		P50Milliseconds: percentileOfSorted(sorted, 50),
+		P95Milliseconds: percentileOfSorted(sorted, 95),
+		P99Milliseconds: percentileOfSorted(sorted, 99),
+	}
+}
+
+// percentileOfSorted uses the nearest-rank method, so the result is always one of the actual values
+func percentileOfSorted(sorted []int64, percentile int) int64 {
+	rank := (percentile*len(sorted) + 99) / 100 // ceiling of percentile/100 * n
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
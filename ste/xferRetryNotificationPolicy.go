@@ -39,6 +39,12 @@ func withRetryNotification(ctx context.Context, r retryNotificationReceiver) con
 	return context.WithValue(ctx, retryNotifyContextKey, r)
 }
 
+// WithRetryNotification returns a context in which the ServerBusy (503) responses of the requests are notified to r.
+// It lets the front end hear of the retries of the requests that it sends itself, such as those of the enumeration
+func WithRetryNotification(ctx context.Context, r interface{ RetryCallback() }) context.Context {
+	return withRetryNotification(ctx, r)
+}
+
 type contextKey struct {
 	name string
 }
@@ -145,7 +145,8 @@ func NewBFSXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
 		return func(ctx context.Context, request pipeline.Request) (response pipeline.Response, err error) {
 			// Before each try, we'll select either the primary or secondary URL.
-			primaryTry := int32(0) // This indicates how many tries we've attempted against the primary DC
+			primaryTry := int32(0)          // This indicates how many tries we've attempted against the primary DC
+			serverDelay := time.Duration(0) // how long the service asked us to wait, when it throttled the previous try
 
 			// We only consider retrying against a secondary if we have a read request (GET/HEAD) AND this policy has a Secondary URL it can use
 			considerSecondary := (request.Method == http.MethodGet || request.Method == http.MethodHead) && o.retryReadsFromSecondaryHost() != ""
@@ -166,13 +167,16 @@ func NewBFSXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 				if tryingPrimary {
 					primaryTry++
 					delay := o.calcDelay(primaryTry)
+					if serverDelay > delay {
+						delay = serverDelay // the service knows best how long it needs
+					}
 					logf("Primary try=%d, Delay=%v\n", primaryTry, delay)
-					time.Sleep(delay) // The 1st try returns 0 delay
+					sleepBeforeRetry(delay) // The 1st try returns 0 delay
 				} else {
 					// For casts and rounding - be careful, as per https://github.com/golang/go/issues/20757
 					delay := time.Duration(float32(time.Second) * (rand.Float32()/2 + 0.8))
 					logf("Secondary try=%d, Delay=%v\n", try-primaryTry, delay)
-					time.Sleep(delay) // Delay with some jitter before trying secondary
+					sleepBeforeRetry(delay) // Delay with some jitter before trying secondary
 				}
 
 				// Clone the original request to ensure that each try starts with the original (unmutated) request.
@@ -279,6 +283,9 @@ func NewBFSXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 					}
 					break // Don't retry
 				}
+				if tryingPrimary {
+					serverDelay = serverDirectedDelayOf(response, err) // the secondary's throttling says nothing of the primary's
+				}
 				if response.Response() != nil {
 					// If we're going to retry and we got a previous response, then flush its body to avoid leaking its TCP connection
 					io.Copy(ioutil.Discard, response.Response().Body)
@@ -310,7 +317,8 @@ func NewBlobXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
 		return func(ctx context.Context, request pipeline.Request) (response pipeline.Response, err error) {
 			// Before each try, we'll select either the primary or secondary URL.
-			primaryTry := int32(0) // This indicates how many tries we've attempted against the primary DC
+			primaryTry := int32(0)          // This indicates how many tries we've attempted against the primary DC
+			serverDelay := time.Duration(0) // how long the service asked us to wait, when it throttled the previous try
 
 			// We only consider retrying against a secondary if we have a read request (GET/HEAD) AND this policy has a Secondary URL it can use
 			considerSecondary := (request.Method == http.MethodGet || request.Method == http.MethodHead) && o.retryReadsFromSecondaryHost() != ""
@@ -335,13 +343,16 @@ func NewBlobXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 				if tryingPrimary {
 					primaryTry++
 					delay := o.calcDelay(primaryTry)
+					if serverDelay > delay {
+						delay = serverDelay // the service knows best how long it needs
+					}
 					logf("Primary try=%d, Delay=%f s\n", primaryTry, delay.Seconds())
-					time.Sleep(delay) // The 1st try returns 0 delay
+					sleepBeforeRetry(delay) // The 1st try returns 0 delay
 				} else {
 					// For casts and rounding - be careful, as per https://github.com/golang/go/issues/20757
 					delay := time.Duration(float32(time.Second) * (rand.Float32()/2 + 0.8))
 					logf("Secondary try=%d, Delay=%f s\n", try-primaryTry, delay.Seconds())
-					time.Sleep(delay) // Delay with some jitter before trying secondary
+					sleepBeforeRetry(delay) // Delay with some jitter before trying secondary
 				}
 
 				// Clone the original request to ensure that each try starts with the original (unmutated) request.
@@ -449,6 +460,9 @@ func NewBlobXferRetryPolicyFactory(o XferRetryOptions) pipeline.Factory {
 					}
 					break // Don't retry
 				}
+				if tryingPrimary {
+					serverDelay = serverDirectedDelayOf(response, err) // the secondary's throttling says nothing of the primary's
+				}
 				if response.Response() != nil {
 					// If we're going to retry and we got a previous response, then flush its body to avoid leaking its TCP connection
 					io.Copy(ioutil.Discard, response.Response().Body)
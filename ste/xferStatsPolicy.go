@@ -28,6 +28,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -40,12 +41,27 @@ type pipelineNetworkStats struct {
 	atomic503CountUnknown      int64 // counts 503's when we don't know the reason
 	atomicE2ETotalMilliseconds int64 // should this be nanoseconds?  Not really needed, given typical minimum operation lengths that we observe
 	atomicStartSeconds         int64
-	nocopy                     common.NoCopy
-	tunerInterface             ConcurrencyTuner
+
+	// unlike the counts above, these cover the whole job, and not just the time since we started gathering stats
+	atomicAllTimeTryCount          int64
+	atomicAllTimeRetryableTryCount int64
+	atomicListCount                uint64
+	atomicReadCount                uint64
+	atomicWriteCount               uint64
+	atomicOtherCount               uint64
+	atomicServerDirectedWaitCount  int64 // throttled responses that said how long to wait before retrying
+	atomicServerDirectedWaitNanos  int64
+	metadataRequests               transferClassStats // tries of the transfers that move no data
+	dataRequests                   transferClassStats // tries of all the other transfers
+	statusCodeCounts               map[int]int64
+	statusCodeCountsLock           sync.Mutex
+
+	nocopy         common.NoCopy
+	tunerInterface ConcurrencyTuner
 }
 
 func newPipelineNetworkStats(tunerInterface ConcurrencyTuner) *pipelineNetworkStats {
-	s := &pipelineNetworkStats{tunerInterface: tunerInterface}
+	s := &pipelineNetworkStats{tunerInterface: tunerInterface, statusCodeCounts: make(map[int]int64)}
 	tunerWillCallUs := tunerInterface.RequestCallbackWhenStable(s.start) // we want to start gather stats after the tuner has reached a stable value. No point in gathering them earlier
 	if !tunerWillCallUs {
 		// assume tuner is inactive, and start ourselves now
@@ -149,6 +165,68 @@ func (s *pipelineNetworkStats) OtherServerBusyPercentage() float32 {
 	}
 }
 
+// recordTry counts every try (including tries that are retries of earlier ones) for the whole job.
+// Returns true if the outcome of the try is one that gets retried
+func (s *pipelineNetworkStats) recordTry(statusCode int, isNetworkError bool) (retryable bool) {
+	atomic.AddInt64(&s.atomicAllTimeTryCount, 1)
+	retryable = isNetworkError || statusCode == http.StatusInternalServerError || statusCode == http.StatusServiceUnavailable
+	if retryable {
+		atomic.AddInt64(&s.atomicAllTimeRetryableTryCount, 1)
+	}
+
+	if statusCode != 0 {
+		s.statusCodeCountsLock.Lock()
+		s.statusCodeCounts[statusCode]++
+		s.statusCodeCountsLock.Unlock()
+	}
+	return retryable
+}
+
+// recordServerDirectedWait counts a throttled response that said how long to wait before retrying, for the whole job,
+// and passes the wait on to the tuner, which takes it as a sign that there are more workers than the service can take
+func (s *pipelineNetworkStats) recordServerDirectedWait(wait time.Duration) {
+	atomic.AddInt64(&s.atomicServerDirectedWaitCount, 1)
+	atomic.AddInt64(&s.atomicServerDirectedWaitNanos, int64(wait))
+	s.tunerInterface.recordServerDirectedWait(wait)
+}
+
+// ServerDirectedWaits returns the number of times, over the whole job, that the service said how long to wait before retrying,
+// and the total of those waits
+func (s *pipelineNetworkStats) ServerDirectedWaits() (count int64, total time.Duration) {
+	s.nocopy.Check()
+	return atomic.LoadInt64(&s.atomicServerDirectedWaitCount), time.Duration(atomic.LoadInt64(&s.atomicServerDirectedWaitNanos))
+}
+
+// RetryPercentage returns the percentage of all tries, over the whole job, that got an outcome which our retry policies retry
+func (s *pipelineNetworkStats) RetryPercentage() float32 {
+	s.nocopy.Check()
+	tries := float32(atomic.LoadInt64(&s.atomicAllTimeTryCount))
+	if tries > 0 {
+		return 100 * float32(atomic.LoadInt64(&s.atomicAllTimeRetryableTryCount)) / tries
+	} else {
+		return 0
+	}
+}
+
+// RetryCount returns the number of tries, over the whole job, that got an outcome which our retry policies retry
+func (s *pipelineNetworkStats) RetryCount() int64 {
+	s.nocopy.Check()
+	return atomic.LoadInt64(&s.atomicAllTimeRetryableTryCount)
+}
+
+// StatusCodeCounts returns a copy of the count of responses, by HTTP status code, over the whole job
+func (s *pipelineNetworkStats) StatusCodeCounts() map[int]int64 {
+	s.nocopy.Check()
+	s.statusCodeCountsLock.Lock()
+	defer s.statusCodeCountsLock.Unlock()
+
+	result := make(map[int]int64, len(s.statusCodeCounts))
+	for code, count := range s.statusCodeCounts {
+		result[code] = count
+	}
+	return result
+}
+
 func (s *pipelineNetworkStats) AverageE2EMilliseconds() int {
 	s.nocopy.Check()
 	ops := atomic.LoadInt64(&s.atomicOperationCount)
@@ -171,6 +249,18 @@ func (p *xferStatsPolicy) Do(ctx context.Context, request pipeline.Request) (pip
 	resp, err := p.next.Do(ctx, request)
 
 	if p.stats != nil {
+		statusCode := 0
+		if resp != nil && resp.Response() != nil {
+			statusCode = resp.Response().StatusCode
+		}
+		if p.stats.recordTry(statusCode, err != nil && statusCode == 0 && !isContextCancelledError(err)) {
+			recordTransferRetry(ctx) // only does anything if the job is recording transfer metrics
+		}
+		p.stats.recordClassTry(ctx, statusCode)
+		if err == nil || !isContextCancelledError(err) {
+			p.stats.recordTransaction(request.Request)
+		}
+
 		if p.stats.IsStarted() {
 			atomic.AddInt64(&p.stats.atomicOperationCount, 1)
 			atomic.AddInt64(&p.stats.atomicE2ETotalMilliseconds, int64(time.Since(start).Seconds()*1000))
@@ -181,6 +271,10 @@ func (p *xferStatsPolicy) Do(ctx context.Context, request pipeline.Request) (pip
 			}
 		}
 
+		if wait := serverDirectedDelayOf(resp, err); wait > 0 {
+			p.stats.recordServerDirectedWait(wait)
+		}
+
 		// always look at retries, even if not started, because concurrency tuner needs to know about them
 		if resp != nil {
 			// TODO should we also count status 500?  It is mentioned here as timeout:https://docs.microsoft.com/en-us/azure/storage/common/storage-scalability-targets
@@ -0,0 +1,59 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type adjustableCapPacerSuite struct{}
+
+var _ = chk.Suite(&adjustableCapPacerSuite{})
+
+func (s *adjustableCapPacerSuite) TestCapCanBeSetChangedAndRemoved(c *chk.C) {
+	p := newAdjustableCapPacer(0)
+	defer p.Close()
+
+	// uncapped, nothing waits, but the traffic is still counted
+	c.Assert(p.RequestTrafficAllocation(context.Background(), 100*1000*1000), chk.IsNil)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(100*1000*1000))
+	c.Assert(p.targetBytesPerSecond(), chk.Equals, int64(0))
+
+	p.setTargetBytesPerSecond(megabitsToBytesPerSecond(8))
+	c.Assert(p.targetBytesPerSecond(), chk.Equals, int64(1000*1000))
+
+	// far more than a second's worth can't be had at once
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	c.Assert(p.RequestTrafficAllocation(ctx, 10*1000*1000), chk.NotNil)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(100*1000*1000))
+
+	// without the cap, it can
+	p.setTargetBytesPerSecond(0)
+	c.Assert(p.RequestTrafficAllocation(context.Background(), 10*1000*1000), chk.IsNil)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(110*1000*1000))
+
+	p.UndoRequest(10 * 1000 * 1000)
+	c.Assert(p.GetTotalTraffic(), chk.Equals, int64(100*1000*1000))
+}
@@ -0,0 +1,66 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	chk "gopkg.in/check.v1"
+)
+
+type appendOnlySuite struct{}
+
+var _ = chk.Suite(&appendOnlySuite{})
+
+func (s *appendOnlySuite) TestOverlapIsComparedToTheSource(c *chk.C) {
+	dir, err := ioutil.TempDir("", "appendOnly")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "growing.log")
+	c.Assert(ioutil.WriteFile(source, []byte("first line\nsecond line\n"), 0644), chk.IsNil)
+
+	destination := []byte("first line\n")
+	readDestination := func(offset int64, count int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(destination[offset : offset+count])), nil
+	}
+	c.Assert(compareOverlap(source, 6, 5, readDestination), chk.IsNil)
+
+	// the file was replaced, rather than appended to
+	destination = []byte("first LINE\n")
+	c.Assert(compareOverlap(source, 6, 5, readDestination), chk.Equals, errAppendOnlyOverlapDiffers)
+
+	// the destination returned less than it was asked for
+	short := func(offset int64, count int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(destination[offset : offset+count-1])), nil
+	}
+	c.Assert(compareOverlap(source, 6, 5, short), chk.Equals, errAppendOnlyOverlapDiffers)
+}
+
+func (s *appendOnlySuite) TestOneChunkIsScheduledWhenThereIsNothingToAppend(c *chk.C) {
+	c.Assert(isDummyChunkInEmptyFile(0, 0, 0), chk.Equals, true)
+	c.Assert(isDummyChunkInEmptyFile(100, 100, 100), chk.Equals, true)
+	c.Assert(isDummyChunkInEmptyFile(0, 0, 100), chk.Equals, false)
+	c.Assert(isDummyChunkInEmptyFile(100, 50, 100), chk.Equals, false) // past the last chunk of the tail
+}
@@ -0,0 +1,260 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type appendRetriesSuite struct{}
+
+var _ = chk.Suite(&appendRetriesSuite{})
+
+// faultyService is a destination that keeps the body of each PUT or PATCH in content, and can be told to reset the connection
+// of the next requests: either part way through their body, before anything was kept, or once the body was kept, before the response
+type faultyService struct {
+	lock    sync.Mutex
+	content []byte
+	// how many of the next writes are cut off, and how
+	cutMidBody      int
+	cutAfterLanding int
+	writes          int
+}
+
+func (f *faultyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Content-Length", strconv.Itoa(len(f.content)))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		rangeHeader := r.Header.Get("x-ms-range")
+		if rangeHeader == "" {
+			rangeHeader = r.Header.Get("Range")
+		}
+		var start, end int
+		fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end)
+		if end >= len(f.content) {
+			end = len(f.content) - 1
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(f.content[start : end+1])
+	default:
+		f.write(w, r)
+	}
+}
+
+func (f *faultyService) write(w http.ResponseWriter, r *http.Request) {
+	f.writes++
+	if f.cutMidBody > 0 {
+		f.cutMidBody--
+		io.CopyN(ioutil.Discard, r.Body, r.ContentLength/2)
+		f.resetConnection(w)
+		return
+	}
+
+	// an append must start where the destination ends (the BlobFS append that flushes is at position zero)
+	expected := strconv.Itoa(len(f.content))
+	if pos := r.Header.Get("x-ms-blob-condition-appendpos"); pos != "" && pos != expected {
+		f.fail(w, http.StatusPreconditionFailed, "AppendPositionConditionNotMet")
+		return
+	}
+	if pos := r.URL.Query().Get("position"); pos != "" && pos != expected {
+		f.fail(w, http.StatusBadRequest, "InvalidFlushPosition")
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if r.URL.Query().Get("comp") == "block" {
+		f.content = body // a staged block takes the place of the one with the same ID
+	} else {
+		f.content = append(f.content, body...)
+	}
+	if f.cutAfterLanding > 0 {
+		f.cutAfterLanding--
+		f.resetConnection(w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *faultyService) fail(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	if strings.HasPrefix(code, "InvalidFlush") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error":{"code":"%s","message":"%s"}}`, code, code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func (f *faultyService) resetConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func (f *faultyService) md5() [md5.Size]byte {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return md5.Sum(f.content)
+}
+
+// retryingPipeline sends to the test server, through the retry policy that the transfers use
+func retryingPipeline(retryPolicy func(XferRetryOptions) pipeline.Factory) pipeline.Pipeline {
+	return pipeline.NewPipeline([]pipeline.Factory{
+		retryPolicy(XferRetryOptions{Policy: RetryPolicyExponential, MaxTries: 4, TryTimeout: 10 * time.Second, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond}),
+		pipeline.MethodFactoryMarker(),
+	}, pipeline.Options{})
+}
+
+type testChunkSource struct{ *bytes.Reader }
+
+func (testChunkSource) Close() error { return nil }
+
+type quietChunkLogger struct{}
+
+func (quietChunkLogger) LogChunkStatus(id common.ChunkID, reason common.WaitReason) {}
+func (quietChunkLogger) IsWaitingOnFinalBodyReads() bool                            { return false }
+func (quietChunkLogger) ShouldLog(level pipeline.LogLevel) bool                     { return false }
+func (quietChunkLogger) Log(level pipeline.LogLevel, msg string)                    {}
+func (quietChunkLogger) Panic(err error)                                            { panic(err) }
+
+// chunkOf returns the paced body of the chunk of data at offset, as the senders send it
+func chunkOf(data []byte, offset int64, length int64) io.ReadSeeker {
+	source := func() (common.CloseableReaderAt, error) { return testChunkSource{bytes.NewReader(data)}, nil }
+	chunk := common.NewSingleChunkReader(context.Background(), source, common.NewChunkID("source", offset, length), length,
+		quietChunkLogger{}, quietChunkLogger{}, common.NewMultiSizeSlicePool(1024*1024), common.NewCacheLimiter(1024*1024))
+	return newPacedRequestBody(context.Background(), chunk, newNullAutoPacer())
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func (s *appendRetriesSuite) TestChunkCutOffMidBodyIsSentAgainFromItsStart(c *chk.C) {
+	_, restore := recordSleeps()
+	defer restore()
+	service := &faultyService{cutMidBody: 2}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	data := testData(300 * 1024)
+	body := chunkOf(data, 100*1024, 100*1024)
+	u, _ := url.Parse(server.URL + "/container/blob")
+	blobURL := azblob.NewBlockBlobURL(*u, retryingPipeline(NewBlobXferRetryPolicyFactory))
+	_, err := blobURL.StageBlock(context.Background(), "YmxvY2s=", body, azblob.LeaseAccessConditions{}, nil)
+
+	c.Assert(err, chk.IsNil)
+	c.Assert(service.writes, chk.Equals, 3)
+	c.Assert(service.md5(), chk.Equals, md5.Sum(data[100*1024:200*1024]))
+}
+
+func (s *appendRetriesSuite) TestAppendThatLandedIsNotAppendedAgain(c *chk.C) {
+	_, restore := recordSleeps()
+	defer restore()
+	data := testData(200 * 1024)
+	service := &faultyService{content: append([]byte{}, data[:100*1024]...), cutAfterLanding: 1}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	body := chunkOf(data, 100*1024, 100*1024)
+	u, _ := url.Parse(server.URL + "/container/blob")
+	blobURL := azblob.NewAppendBlobURL(*u, retryingPipeline(NewBlobXferRetryPolicyFactory))
+	err := appendBlock(context.Background(), blobURL, body, 100*1024)
+
+	c.Assert(err, chk.IsNil)
+	c.Assert(service.writes, chk.Equals, 2)
+	c.Assert(service.md5(), chk.Equals, md5.Sum(data))
+
+	// but a destination that doesn't end with the chunk is still an error
+	other := testData(50 * 1024)
+	body = chunkOf(other, 0, 50*1024)
+	err = appendBlock(context.Background(), blobURL, body, 100*1024)
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), "AppendPositionConditionNotMet"), chk.Equals, true)
+	c.Assert(service.md5(), chk.Equals, md5.Sum(data))
+}
+
+func (s *appendRetriesSuite) TestBlobFSFlushingAppendThatLandedIsASuccess(c *chk.C) {
+	_, restore := recordSleeps()
+	defer restore()
+	service := &faultyService{cutMidBody: 1, cutAfterLanding: 1}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	data := testData(100 * 1024)
+	body := chunkOf(data, 0, int64(len(data)))
+	u, _ := url.Parse(server.URL + "/filesystem/file")
+	fileURL := azbfs.NewFileURL(*u, retryingPipeline(NewBFSXferRetryPolicyFactory))
+	err := appendDataAndClose(context.Background(), fileURL, body, azbfs.BlobFSHTTPHeaders{})
+
+	c.Assert(err, chk.IsNil)
+	c.Assert(service.writes, chk.Equals, 3)
+	c.Assert(service.md5(), chk.Equals, md5.Sum(data))
+}
+
+func (s *appendRetriesSuite) TestChunkSeeksFromItsEnd(c *chk.C) {
+	body := chunkOf(testData(1024), 512, 256)
+
+	position, err := body.Seek(0, io.SeekEnd)
+	c.Assert(err, chk.IsNil)
+	c.Assert(position, chk.Equals, int64(256))
+
+	position, err = body.Seek(-16, io.SeekEnd)
+	c.Assert(err, chk.IsNil)
+	c.Assert(position, chk.Equals, int64(240))
+	tail, err := ioutil.ReadAll(body)
+	c.Assert(err, chk.IsNil)
+	c.Assert(tail, chk.DeepEquals, testData(1024)[752:768])
+}
@@ -0,0 +1,71 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+)
+
+type blobFSSmallFileSuite struct{}
+
+var _ = chk.Suite(&blobFSSmallFileSuite{})
+
+func (s *blobFSSmallFileSuite) TestOnlySmallWholeFilesAreFlushedWithData(c *chk.C) {
+	c.Assert(isSmallBlobFSFile(1024, 1, 0, false), chk.Equals, true)
+
+	c.Assert(isSmallBlobFSFile(0, 1, 0, false), chk.Equals, false)                      // there's no data to append
+	c.Assert(isSmallBlobFSFile(ADLSSmallFileThreshold, 1, 0, false), chk.Equals, false) // not below the threshold
+	c.Assert(isSmallBlobFSFile(1024, 2, 0, false), chk.Equals, false)                   // more than one chunk
+	c.Assert(isSmallBlobFSFile(1024, 1, 512, false), chk.Equals, false)                 // append-only
+	c.Assert(isSmallBlobFSFile(1024, 1, 0, true), chk.Equals, false)                    // the MD5 is set by the flush
+}
+
+func (s *blobFSSmallFileSuite) TestAppendDataAndCloseFlushesInTheSameRequest(c *chk.C) {
+	var captured *http.Request
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			captured = request.Request
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	u, _ := url.Parse("https://account.dfs.core.windows.net/fs/dir/file")
+	fileURL := azbfs.NewFileURL(*u, p)
+	_, err := fileURL.AppendDataAndClose(context.Background(), bytes.NewReader([]byte("small")), azbfs.BlobFSHTTPHeaders{ContentType: "text/plain"})
+	c.Assert(err, chk.IsNil)
+
+	query := captured.URL.Query()
+	c.Assert(query.Get("action"), chk.Equals, "append")
+	c.Assert(query.Get("position"), chk.Equals, "0")
+	c.Assert(query.Get("flush"), chk.Equals, "true")
+	c.Assert(query.Get("close"), chk.Equals, "true")
+	c.Assert(captured.Header.Get("x-ms-content-type"), chk.Equals, "text/plain")
+	c.Assert(captured.Header.Get("x-ms-version") > azbfs.ServiceVersion, chk.Equals, true)
+}
@@ -0,0 +1,126 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"encoding/base64"
+	"math"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type blockIDSuite struct{}
+
+var _ = chk.Suite(&blockIDSuite{})
+
+func (s *blockIDSuite) TestBlockIDSaysItsRange(c *chk.C) {
+	sender := &blockBlobSenderBase{chunkSize: 8 * 1024 * 1024, sourceIdentity: "0123456789ab"}
+
+	id := sender.blockIDFor(3 * 8 * 1024 * 1024)
+	raw, err := base64.StdEncoding.DecodeString(id)
+	c.Assert(err, chk.IsNil)
+	c.Assert(raw, chk.HasLen, blockIDLength)
+
+	offset, blockSize, ok := sender.parseBlockID(id)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(offset, chk.Equals, int64(3*8*1024*1024))
+	c.Assert(blockSize, chk.Equals, int64(8*1024*1024))
+}
+
+func (s *blockIDSuite) TestBlockIDOfAnotherSourceIsNotRecognized(c *chk.C) {
+	earlier := &blockBlobSenderBase{chunkSize: 1024, sourceIdentity: "0123456789ab"}
+	now := &blockBlobSenderBase{chunkSize: 1024, sourceIdentity: "ba9876543210"}
+
+	_, _, ok := now.parseBlockID(earlier.blockIDFor(0))
+	c.Assert(ok, chk.Equals, false)
+
+	// as staged by earlier versions, which used random IDs
+	_, _, ok = now.parseBlockID(base64.StdEncoding.EncodeToString([]byte("c5d0e0a4-5c5a-4b7e-9f4a-3c2b1a0f9e8d")))
+	c.Assert(ok, chk.Equals, false)
+}
+
+func (s *blockIDSuite) TestBlockIDsOfEarlierFormatsAreParsed(c *chk.C) {
+	sender := &blockBlobSenderBase{chunkSize: 4 * 1024 * 1024, sourceIdentity: "0123456789ab"}
+
+	// version 1 said the offset of the block, rather than its index
+	v1 := base64.StdEncoding.EncodeToString([]byte("azcp0123456789ab00000140000000400000"))
+	info, ok := parseBlockID(v1)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(info.format, chk.Equals, blockIDFormatOffset)
+	offset, blockSize, ok := sender.parseBlockID(v1)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(offset, chk.Equals, int64(5*4*1024*1024))
+	c.Assert(blockSize, chk.Equals, int64(4*1024*1024))
+
+	// version 0 is recognized, but its blocks can't be kept, since their IDs don't say what they hold
+	v0 := base64.StdEncoding.EncodeToString([]byte("c5d0e0a4-5c5a-4b7e-9f4a-3c2b1a0f9e8d"))
+	info, ok = parseBlockID(v0)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(info.format, chk.Equals, blockIDFormatRandom)
+
+	// the IDs of all the formats are as long as each other, so that blocks of one can be staged next to those of another
+	current, _ := base64.StdEncoding.DecodeString(sender.blockIDFor(0))
+	for _, id := range []string{v0, v1} {
+		raw, _ := base64.StdEncoding.DecodeString(id)
+		c.Assert(raw, chk.HasLen, len(current))
+	}
+}
+
+func (s *blockIDSuite) TestCurrentBlockIDFormatIsStable(c *chk.C) {
+	// other tools rely on this, so it must not change without a new version
+	id := formatBlockID("0123456789ab", 5, 4*1024*1024)
+	raw, err := base64.StdEncoding.DecodeString(id)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(raw), chk.Equals, "azc20123456789ab00000005004000000000")
+
+	// and the reserved digits must be zero
+	_, ok := parseBlockID(base64.StdEncoding.EncodeToString([]byte("azc20123456789ab00000005004000000001")))
+	c.Assert(ok, chk.Equals, false)
+	_, ok = parseBlockID(base64.StdEncoding.EncodeToString([]byte("azc90123456789ab00000005004000000000")))
+	c.Assert(ok, chk.Equals, false)
+}
+
+func (s *blockIDSuite) TestBlockIDsAreOfConstantLengthUpToTheMaximumBlockCount(c *chk.C) {
+	const maxBlockIDBytes = 64 // what the service allows, before encoding
+
+	check := func(blockIndex uint32, blockSize uint32) {
+		id := formatBlockID("0123456789ab", blockIndex, blockSize)
+		raw, err := base64.StdEncoding.DecodeString(id)
+		c.Assert(err, chk.IsNil)
+		c.Assert(raw, chk.HasLen, blockIDLength)
+		c.Assert(len(raw) <= maxBlockIDBytes, chk.Equals, true)
+		c.Assert(id, chk.HasLen, base64.StdEncoding.EncodedLen(blockIDLength))
+
+		info, ok := parseBlockID(id)
+		c.Assert(ok, chk.Equals, true)
+		c.Assert(info.format, chk.Equals, blockIDFormatIndexed)
+		c.Assert(info.offset, chk.Equals, int64(blockIndex)*int64(blockSize))
+		c.Assert(info.blockSize, chk.Equals, int64(blockSize))
+	}
+
+	for blockIndex := uint32(0); blockIndex < common.MaxNumberOfBlocksPerBlob; blockIndex++ {
+		check(blockIndex, common.MaxBlockBlobBlockSize)
+	}
+	check(0, 1)
+	check(math.MaxUint32, math.MaxUint32)
+}
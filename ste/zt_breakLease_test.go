@@ -0,0 +1,119 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type breakLeaseSuite struct{}
+
+var _ = chk.Suite(&breakLeaseSuite{})
+
+// leasedBlobService answers like a service with one blob, which is leased until its lease is broken
+type leasedBlobService struct {
+	leased   bool
+	requests []string // the method and query of each request
+	bodies   []string // the bodies of the writes that succeeded
+}
+
+func (l *leasedBlobService) pipeline() pipeline.Pipeline {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			l.requests = append(l.requests, request.Method+" "+request.URL.RawQuery)
+			status, header := http.StatusCreated, http.Header{}
+			switch {
+			case request.URL.Query().Get("comp") == "lease":
+				if request.Header.Get("x-ms-lease-action") == "break" && request.Header.Get("x-ms-lease-break-period") == "0" {
+					l.leased = false
+				}
+				status = http.StatusAccepted
+			case l.leased:
+				status = http.StatusPreconditionFailed
+				header.Set("x-ms-error-code", string(azblob.ServiceCodeLeaseIDMissing))
+			case request.Method == http.MethodDelete:
+				status = http.StatusAccepted
+			default:
+				body, _ := ioutil.ReadAll(request.Body)
+				l.bodies = append(l.bodies, string(body))
+			}
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: status, Status: http.StatusText(status), Header: header,
+				Body: ioutil.NopCloser(strings.NewReader("")), Request: request.Request}), nil
+		}
+	})
+	return pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+}
+
+func (s *breakLeaseSuite) TestLeaseIsBrokenAndTheWriteDoneAgain(c *chk.C) {
+	service := &leasedBlobService{leased: true}
+	logs := make([]string, 0)
+	p := newLeaseBreakingPipeline(service.pipeline(), func(level pipeline.LogLevel, msg string) { logs = append(logs, msg) })
+
+	u, _ := url.Parse("https://acct.blob.core.windows.net/cont/leased.txt?sig=secret")
+	_, err := azblob.NewBlockBlobURL(*u, p).Upload(context.Background(), strings.NewReader("data"), azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	// the break keeps the SAS of the write, and the write is sent again with its whole body
+	c.Assert(service.requests, chk.DeepEquals, []string{"PUT sig=secret", "PUT comp=lease&sig=secret", "PUT sig=secret"})
+	c.Assert(service.bodies, chk.DeepEquals, []string{"data"})
+	c.Assert(logs, chk.HasLen, 1)
+	c.Assert(strings.HasPrefix(logs[0], "Broke the lease of https://acct.blob.core.windows.net/cont/leased.txt,"), chk.Equals, true)
+	c.Assert(strings.Contains(logs[0], "secret"), chk.Equals, false)
+
+	// removals too
+	service.leased, service.requests = true, nil
+	_, err = azblob.NewBlobURL(*u, p).Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(service.requests, chk.DeepEquals, []string{"DELETE sig=secret", "PUT comp=lease&sig=secret", "DELETE sig=secret"})
+}
+
+func (s *breakLeaseSuite) TestLeasesAreOnlyBrokenWhenAskedAndNotOurs(c *chk.C) {
+	u, _ := url.Parse("https://acct.blob.core.windows.net/cont/leased.txt?sig=secret")
+	upload := func(p pipeline.Pipeline) error {
+		_, err := azblob.NewBlockBlobURL(*u, p).Upload(context.Background(), strings.NewReader("data"), azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+		return err
+	}
+
+	// without the lease breaking pipeline, the write just fails
+	service := &leasedBlobService{leased: true}
+	err := upload(service.pipeline())
+	c.Assert(err, chk.NotNil)
+	c.Assert(service.requests, chk.HasLen, 1)
+
+	// and the leases that this process holds are never broken
+	ownLeases.add(*u, "our-lease")
+	defer ownLeases.remove(*u)
+	service = &leasedBlobService{leased: true}
+	err = upload(newLeaseBreakingPipeline(service.pipeline(), func(pipeline.LogLevel, string) {}))
+	c.Assert(err, chk.NotNil)
+	serviceCode, _, _ := ErrorEx{err}.ErrorCodeAndString()
+	c.Assert(serviceCode, chk.Equals, string(azblob.ServiceCodeLeaseIDMissing))
+	c.Assert(service.requests, chk.HasLen, 1)
+	c.Assert(service.leased, chk.Equals, true)
+}
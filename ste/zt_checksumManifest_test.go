@@ -0,0 +1,64 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type checksumManifestSuite struct{}
+
+var _ = chk.Suite(&checksumManifestSuite{})
+
+func (s *checksumManifestSuite) TestRecordedChecksumsAreVerified(c *chk.C) {
+	dir, err := ioutil.TempDir("", "checksumManifest")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	checksumFile := filepath.Join(dir, "files.sha256")
+
+	first, second := sha256.Sum256([]byte("first")), sha256.Sum256([]byte("second"))
+	writer, err := newChecksumManifestWriter(checksumFile)
+	c.Assert(err, chk.IsNil)
+	writer.record("a/first", first[:])
+	writer.record("second", second[:])
+	writer.Flush()
+
+	read, err := common.ReadChecksumFile(checksumFile)
+	c.Assert(err, chk.IsNil)
+	c.Assert(read, chk.HasLen, 2)
+
+	verifier, err := loadChecksumManifest(checksumFile)
+	c.Assert(err, chk.IsNil)
+	c.Assert(verifier.isVerifying(), chk.Equals, true)
+	c.Assert(verifier.entriesNotFound(), chk.Equals, uint32(2))
+
+	c.Assert(verifier.verify("a/first", first[:]), chk.IsNil)
+	c.Assert(verifier.verify("second", first[:]), chk.NotNil) // mismatch, but it was found
+	c.Assert(verifier.verify("third", first[:]), chk.NotNil)  // not in the file
+	c.Assert(verifier.verify("a/first", first[:]), chk.IsNil) // counted once
+	c.Assert(verifier.entriesNotFound(), chk.Equals, uint32(0))
+}
@@ -0,0 +1,90 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type clientRequestIDSuite struct{}
+
+var _ = chk.Suite(&clientRequestIDSuite{})
+
+// sendAndCaptureClientRequestID sends a request through the client request ID policy, and returns the ID that reached the wire
+func sendAndCaptureClientRequestID(c *chk.C, ctx context.Context, existing string) string {
+	var captured string
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			captured = request.Header.Get(clientRequestIDHeader)
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{newClientRequestIDPolicyFactory()}, pipeline.Options{HTTPSender: sender})
+
+	u, _ := url.Parse("https://account.blob.core.windows.net/c/b")
+	req, err := pipeline.NewRequest(http.MethodGet, *u, nil)
+	c.Assert(err, chk.IsNil)
+	if existing != "" {
+		req.Header.Set(clientRequestIDHeader, existing)
+	}
+	_, err = p.Do(ctx, nil, req)
+	c.Assert(err, chk.IsNil)
+	return captured
+}
+
+func (s *clientRequestIDSuite) TestPrefixDefaultsToTheJobID(c *chk.C) {
+	jobID := common.NewJobID()
+	c.Assert(ClientRequestIDPrefix(jobID, ""), chk.Equals, jobID.String())
+	c.Assert(ClientRequestIDPrefix(jobID, "nightly-backup"), chk.Equals, "nightly-backup")
+}
+
+func (s *clientRequestIDSuite) TestEachRequestGetsItsOwnIDUnderThePrefix(c *chk.C) {
+	ctx := WithClientRequestIDPrefix(context.Background(), "nightly-backup")
+
+	first := sendAndCaptureClientRequestID(c, ctx, "")
+	second := sendAndCaptureClientRequestID(c, ctx, "")
+	c.Assert(strings.HasPrefix(first, "nightly-backup-"), chk.Equals, true)
+	c.Assert(strings.HasPrefix(second, "nightly-backup-"), chk.Equals, true)
+	c.Assert(first, chk.Not(chk.Equals), second)
+}
+
+func (s *clientRequestIDSuite) TestIDIsLeftAloneWithoutPrefixOrWhenAlreadySet(c *chk.C) {
+	c.Assert(sendAndCaptureClientRequestID(c, context.Background(), ""), chk.Equals, "")
+
+	ctx := WithClientRequestIDPrefix(context.Background(), "nightly-backup")
+	c.Assert(sendAndCaptureClientRequestID(c, ctx, "chosen-by-caller"), chk.Equals, "chosen-by-caller")
+}
+
+func (s *clientRequestIDSuite) TestFailuresKeepTheIDOfTheirRequest(c *chk.C) {
+	failures := newTransferFailures()
+	failures.recordClientRequestID(1, 7, "nightly-backup-1234")
+
+	c.Assert(failures.clientRequestID(1, 7), chk.Equals, "nightly-backup-1234")
+	c.Assert(failures.clientRequestID(0, 7), chk.Equals, "")
+	c.Assert(failures.clientRequestID(1, 8), chk.Equals, "")
+}
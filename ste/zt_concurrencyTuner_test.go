@@ -23,6 +23,7 @@ package ste
 import (
 	chk "gopkg.in/check.v1"
 	"math"
+	"time"
 )
 
 type concurrencyTunerSuite struct{}
@@ -191,3 +192,26 @@ func (s *concurrencyTunerSuite) runTest(c *chk.C, steps []tunerStep, maxConcurre
 		observedHighCpu = x.highCpuObserved
 	}
 }
+
+func (s *concurrencyTunerSuite) TestConcurrencyTuner_BacksOffWhenTheServiceAsksToWait(c *chk.C) {
+	t := NewAutoConcurrencyTuner(4, s.noMax(), false)
+
+	conc, reason := t.GetRecommendedConcurrency(-1, false)
+	c.Assert(conc, chk.Equals, 4)
+	c.Assert(reason, chk.Equals, concurrencyReasonInitial)
+
+	conc, reason = t.GetRecommendedConcurrency(400, false)
+	c.Assert(conc, chk.Equals, 16)
+	c.Assert(reason, chk.Equals, concurrencyReasonSeeking)
+
+	// the speed went up, which would usually have it seek higher, but the service asked for a wait
+	t.recordServerDirectedWait(2 * time.Second)
+	conc, reason = t.GetRecommendedConcurrency(1000, false)
+	c.Assert(conc, chk.Equals, 4)
+	c.Assert(reason, chk.Equals, concurrencyReasonThrottled)
+
+	// the signal is only acted on once
+	conc, reason = t.GetRecommendedConcurrency(400, false)
+	c.Assert(conc, chk.Equals, 8)
+	c.Assert(reason, chk.Equals, concurrencyReasonSeeking)
+}
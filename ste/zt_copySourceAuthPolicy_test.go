@@ -0,0 +1,70 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type copySourceAuthPolicySuite struct{}
+
+var _ = chk.Suite(&copySourceAuthPolicySuite{})
+
+// sendAndCaptureHeaders sends a request through the copy source authorization policy, and returns the headers that reached the wire
+func sendAndCaptureHeaders(c *chk.C, ctx context.Context) http.Header {
+	var captured http.Header
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			captured = request.Header
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{NewCopySourceAuthorizationPolicyFactory()}, pipeline.Options{HTTPSender: sender})
+
+	u, _ := url.Parse("https://dest.blob.core.windows.net/c/b?comp=block")
+	req, err := pipeline.NewRequest(http.MethodPut, *u, nil)
+	c.Assert(err, chk.IsNil)
+	_, err = p.Do(ctx, nil, req)
+	c.Assert(err, chk.IsNil)
+	return captured
+}
+
+func (s *copySourceAuthPolicySuite) TestHeaderIsOnlyAddedForOAuthSource(c *chk.C) {
+	headers := sendAndCaptureHeaders(c, context.Background())
+	c.Assert(headers.Get(copySourceAuthorizationHeader), chk.Equals, "")
+}
+
+func (s *copySourceAuthPolicySuite) TestHeaderHasCurrentToken(c *chk.C) {
+	credential := azblob.NewTokenCredential("first", nil)
+	ctx := context.WithValue(context.Background(), copySourceTokenKey{}, credential)
+
+	c.Assert(sendAndCaptureHeaders(c, ctx).Get(copySourceAuthorizationHeader), chk.Equals, "Bearer first")
+
+	// as happens when the token is refreshed while the job runs
+	credential.SetToken("second")
+	c.Assert(sendAndCaptureHeaders(c, ctx).Get(copySourceAuthorizationHeader), chk.Equals, "Bearer second")
+}
@@ -0,0 +1,83 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type credentialExpirySuite struct{}
+
+var _ = chk.Suite(&credentialExpirySuite{})
+
+func (s *credentialExpirySuite) TestRefreshersWaitForTheSameNewToken(c *chk.C) {
+	g := newCredentialExpiryGuard(func(string) {})
+	c.Assert(g.expired(), chk.Equals, false)
+	c.Assert(g.renew(common.OAuthTokenInfo{Tenant: "nothing waits"}), chk.Equals, false)
+
+	var wg sync.WaitGroup
+	renewed := make([]*common.OAuthTokenInfo, 3)
+	for i := range renewed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			renewed[i] = g.waitForRenewal(errors.New("refresh token has expired"))
+		}(i)
+	}
+	for !g.expired() {
+		runtime.Gosched()
+	}
+	c.Assert(g.lastError(), chk.ErrorMatches, "refresh token has expired")
+
+	c.Assert(g.renew(common.OAuthTokenInfo{Tenant: "new"}), chk.Equals, true)
+	wg.Wait()
+	c.Assert(g.expired(), chk.Equals, false)
+	for _, tokenInfo := range renewed {
+		c.Assert(tokenInfo, chk.NotNil)
+		c.Assert(tokenInfo.Tenant, chk.Equals, "new")
+	}
+}
+
+func (s *credentialExpirySuite) TestNothingWaitsOnceTheTokenIsGivenUpOn(c *chk.C) {
+	g := newCredentialExpiryGuard(func(string) {})
+
+	done := make(chan *common.OAuthTokenInfo)
+	go func() { done <- g.waitForRenewal(errors.New("expired")) }()
+	for !g.expired() {
+		runtime.Gosched()
+	}
+	c.Assert(g.giveUp(), chk.Equals, true)
+	c.Assert(<-done, chk.IsNil)
+
+	// the refreshers that try again don't hold the job back any more, so it can wind down
+	c.Assert(g.waitForRenewal(errors.New("expired")), chk.IsNil)
+	c.Assert(g.expired(), chk.Equals, false)
+
+	var nilGuard *credentialExpiryGuard
+	c.Assert(nilGuard.waitForRenewal(errors.New("expired")), chk.IsNil)
+	c.Assert(nilGuard.expired(), chk.Equals, false)
+}
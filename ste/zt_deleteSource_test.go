@@ -0,0 +1,78 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type deleteSourceSuite struct{}
+
+var _ = chk.Suite(&deleteSourceSuite{})
+
+func (s *deleteSourceSuite) TestLocalSourceIsOnlyDeletedIfUnchanged(c *chk.C) {
+	dir, err := ioutil.TempDir("", "deleteSource")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "file")
+	c.Assert(ioutil.WriteFile(source, []byte("data"), 0644), chk.IsNil)
+	fi, err := os.Stat(source)
+	c.Assert(err, chk.IsNil)
+
+	// as if it was written to after it was enumerated
+	c.Assert(deleteSource(context.Background(), common.ELocation.Local(), source, fi.ModTime().Add(-time.Minute), nil), chk.NotNil)
+	_, err = os.Stat(source)
+	c.Assert(err, chk.IsNil)
+
+	c.Assert(deleteSource(context.Background(), common.ELocation.Local(), source, fi.ModTime(), nil), chk.IsNil)
+	_, err = os.Stat(source)
+	c.Assert(os.IsNotExist(err), chk.Equals, true)
+}
+
+func (s *deleteSourceSuite) TestSourceDeletionIsCountedFromThePlan(c *chk.C) {
+	var js common.ListJobSummaryResponse
+	transfer := func(status common.TransferStatus, deleted bool) *JobPartPlanTransfer {
+		jppt := &JobPartPlanTransfer{}
+		jppt.SetTransferStatus(status, true)
+		if deleted {
+			jppt.SetSourceDeleted()
+		}
+		return jppt
+	}
+
+	countSourceDeletion(&js, transfer(common.ETransferStatus.Success(), true))
+	countSourceDeletion(&js, transfer(common.ETransferStatus.Success(), false)) // the source couldn't be deleted
+	countSourceDeletion(&js, transfer(common.ETransferStatus.Failed(), false))
+	countSourceDeletion(&js, transfer(common.ETransferStatus.SkippedFileAlreadyExists(), false))
+	countSourceDeletion(&js, transfer(common.ETransferStatus.Started(), false)) // not finished, so not counted yet
+
+	c.Assert(js.SourcesDeleted, chk.Equals, uint32(1))
+	c.Assert(js.SourcesRetained, chk.Equals, uint32(3))
+}
@@ -0,0 +1,100 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type destinationConditionSuite struct{}
+
+var _ = chk.Suite(&destinationConditionSuite{})
+
+// conditionTestTransferMgr is a transfer of a job with a destination condition
+type conditionTestTransferMgr struct {
+	IJobPartTransferMgr
+	condition common.DestinationCondition
+}
+
+func (t *conditionTestTransferMgr) DestinationCondition() common.DestinationCondition {
+	return t.condition
+}
+
+// respondWithErrorCode is a pipeline that fails every request with the error code, and keeps the requests
+func respondWithErrorCode(requests *[]*http.Request, status int, code azblob.ServiceCodeType) pipeline.Pipeline {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			*requests = append(*requests, request.Request)
+			header := http.Header{}
+			header.Set("x-ms-error-code", string(code))
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: status, Status: http.StatusText(status), Header: header,
+				Body: ioutil.NopCloser(strings.NewReader("")), Request: request.Request}), nil
+		}
+	})
+	return pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+}
+
+func (s *destinationConditionSuite) TestExistingDestinationIsNotReplaced(c *chk.C) {
+	requests := make([]*http.Request, 0)
+	p := respondWithErrorCode(&requests, http.StatusConflict, azblob.ServiceCodeBlobAlreadyExists)
+	u, _ := url.Parse("https://acct.blob.core.windows.net/cont/shared.txt")
+	jptm := &conditionTestTransferMgr{condition: common.DestinationCondition{IfNoneMatch: true}}
+
+	_, err := azblob.NewBlockBlobURL(*u, p).Upload(context.Background(), strings.NewReader("data"), azblob.BlobHTTPHeaders{}, azblob.Metadata{},
+		destinationWriteConditions(jptm, nil))
+	c.Assert(requests, chk.HasLen, 1)
+	c.Assert(requests[0].Header.Get("If-None-Match"), chk.Equals, "*")
+	c.Assert(isDestinationConditionNotMet(jptm, err), chk.Equals, true)
+
+	// without a condition, the same error is a failure
+	c.Assert(isDestinationConditionNotMet(&conditionTestTransferMgr{}, err), chk.Equals, false)
+	c.Assert(isDestinationConditionNotMet(jptm, errors.New("connection reset")), chk.Equals, false)
+}
+
+func (s *destinationConditionSuite) TestModifiedDestinationIsNotReplaced(c *chk.C) {
+	requests := make([]*http.Request, 0)
+	p := respondWithErrorCode(&requests, http.StatusPreconditionFailed, azblob.ServiceCodeConditionNotMet)
+	u, _ := url.Parse("https://acct.blob.core.windows.net/cont/shared.txt")
+	since, _ := common.ParseDestinationCondition("if-unmodified-since=2026-01-02T15:04:05Z")
+	jptm := &conditionTestTransferMgr{condition: since}
+
+	_, err := azblob.NewBlockBlobURL(*u, p).CommitBlockList(context.Background(), []string{}, azblob.BlobHTTPHeaders{}, azblob.Metadata{},
+		destinationWriteConditions(jptm, nil))
+	c.Assert(requests, chk.HasLen, 1)
+	c.Assert(requests[0].Header.Get("If-Unmodified-Since"), chk.Equals, "Fri, 02 Jan 2026 15:04:05 GMT")
+	c.Assert(requests[0].Header.Get("If-None-Match"), chk.Equals, "")
+	c.Assert(isDestinationConditionNotMet(jptm, err), chk.Equals, true)
+
+	pathConditions := destinationPathConditions(jptm)
+	c.Assert(pathConditions.IfNoneMatch, chk.Equals, "")
+	c.Assert(pathConditions.IfUnmodifiedSince.Equal(since.IfUnmodifiedSince), chk.Equals, true)
+}
@@ -0,0 +1,156 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type destinationLeaseSuite struct{}
+
+var _ = chk.Suite(&destinationLeaseSuite{})
+
+// leaseTestTransferMgr records how the transfer whose destination is leased fails
+type leaseTestTransferMgr struct {
+	IJobPartTransferMgr
+	lock          sync.Mutex
+	failedWith    common.TransferStatus
+	failureReason string
+}
+
+func (t *leaseTestTransferMgr) Context() context.Context                                         { return context.Background() }
+func (t *leaseTestTransferMgr) WasCanceled() bool                                                { return false }
+func (t *leaseTestTransferMgr) LogAtLevelForCurrentTransfer(level pipeline.LogLevel, msg string) {}
+func (t *leaseTestTransferMgr) FailActiveSend(where string, err error) {
+	t.FailActiveSendWithStatus(where, err, common.ETransferStatus.Failed())
+}
+func (t *leaseTestTransferMgr) FailActiveSendWithStatus(where string, err error, failureStatus common.TransferStatus) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.failedWith = failureStatus
+	t.failureReason = where
+}
+
+// countingLeaseOperations counts the lease operations, which succeed unless they're told to fail
+type countingLeaseOperations struct {
+	lock     sync.Mutex
+	renewErr error
+	renewals int
+	released []string
+}
+
+func (o *countingLeaseOperations) acquireLease(ctx context.Context, proposedID string, duration int32) error {
+	return nil
+}
+
+func (o *countingLeaseOperations) renewLease(ctx context.Context, leaseID string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.renewals++
+	return o.renewErr
+}
+
+func (o *countingLeaseOperations) releaseLease(ctx context.Context, leaseID string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.released = append(o.released, leaseID)
+	return nil
+}
+
+func (s *destinationLeaseSuite) TestLeaseIsUsedUntilItsReleased(c *chk.C) {
+	var none *destinationLease
+	c.Assert(none.leaseID(), chk.Equals, "")
+	c.Assert(none.blobAccessConditions(), chk.DeepEquals, azblob.BlobAccessConditions{})
+	none.release()
+
+	jptm := &leaseTestTransferMgr{}
+	ops := &countingLeaseOperations{}
+	u, _ := url.Parse("https://acct.blob.core.windows.net/cont/big.vhd?sig=secret")
+	lease, err := acquireDestinationLease(jptm, ops, *u)
+	c.Assert(err, chk.IsNil)
+
+	id := lease.leaseID()
+	c.Assert(id, chk.Not(chk.Equals), "")
+	c.Assert(lease.leaseAccessConditions().LeaseID, chk.Equals, id)
+	c.Assert(lease.pageBlobAccessConditions().LeaseID, chk.Equals, id)
+	c.Assert(ownLeases.holds(*u), chk.Equals, true) // so that --break-lease-on-overwrite never breaks it
+
+	lease.release()
+	lease.release()
+	c.Assert(ops.released, chk.DeepEquals, []string{id})
+	c.Assert(ownLeases.holds(*u), chk.Equals, false)
+	c.Assert(jptm.failedWith, chk.Equals, common.ETransferStatus.NotStarted())
+}
+
+func (s *destinationLeaseSuite) TestLosingTheLeaseFailsTheTransfer(c *chk.C) {
+	jptm := &leaseTestTransferMgr{}
+	ops := &countingLeaseOperations{renewErr: errors.New("lease expired")}
+	lease := &destinationLease{jptm: jptm, ops: ops, id: "id", stop: make(chan struct{}), done: make(chan struct{})}
+
+	go lease.renewUntilReleased(time.Millisecond)
+	<-lease.done // it stops renewing once the renewal fails
+	c.Assert(ops.renewals, chk.Equals, 1)
+	c.Assert(jptm.failedWith, chk.Equals, common.ETransferStatus.Failed())
+	c.Assert(jptm.failureReason, chk.Equals, "Renewing the lease on the destination")
+}
+
+func (s *destinationLeaseSuite) TestLeasedDestinationIsBusy(c *chk.C) {
+	requests := make([]*http.Request, 0)
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			requests = append(requests, request.Request)
+			header := http.Header{}
+			header.Set("x-ms-error-code", string(azblob.ServiceCodeLeaseAlreadyPresent))
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusConflict, Status: http.StatusText(http.StatusConflict), Header: header,
+				Body: ioutil.NopCloser(strings.NewReader("")), Request: request.Request}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	jptm := &leaseTestTransferMgr{}
+	u, _ := url.Parse("https://acct.blob.core.windows.net/cont/busy.txt")
+	lease, err := acquireDestinationLease(jptm, blobLeaseOperations{blobURL: azblob.NewBlobURL(*u, p)}, *u)
+	c.Assert(lease, chk.IsNil)
+	c.Assert(ownLeases.holds(*u), chk.Equals, false)
+
+	c.Assert(requests, chk.HasLen, 1)
+	c.Assert(requests[0].URL.Query().Get("comp"), chk.Equals, "lease")
+	c.Assert(requests[0].Header.Get("x-ms-lease-action"), chk.Equals, "acquire")
+	c.Assert(requests[0].Header.Get("x-ms-lease-duration"), chk.Equals, "60")
+
+	failDestinationLease(jptm, "Leasing the blob", err)
+	c.Assert(jptm.failedWith, chk.Equals, common.ETransferStatus.DestinationBusy())
+
+	failDestinationLease(jptm, "Leasing the blob", errors.New("connection reset"))
+	c.Assert(jptm.failedWith, chk.Equals, common.ETransferStatus.Failed())
+}
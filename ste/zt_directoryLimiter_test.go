@@ -0,0 +1,105 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type directoryLimiterSuite struct{}
+
+var _ = chk.Suite(&directoryLimiterSuite{})
+
+// directoryLimiterTestTransferMgr is a transfer to the given destination
+type directoryLimiterTestTransferMgr struct {
+	IJobPartTransferMgr
+	fromTo      common.FromTo
+	destination string
+}
+
+func (t *directoryLimiterTestTransferMgr) FromTo() common.FromTo { return t.fromTo }
+func (t *directoryLimiterTestTransferMgr) Info() TransferInfo {
+	return TransferInfo{Destination: t.destination}
+}
+
+func newDirectoryLimiterTestTransfer(destination string) *directoryLimiterTestTransferMgr {
+	return &directoryLimiterTestTransferMgr{fromTo: common.EFromTo.LocalFile(), destination: destination}
+}
+
+func (s *directoryLimiterSuite) TestOnlyAzureFilesDestinationsAreLimitedByDirectory(c *chk.C) {
+	c.Assert(limitedDirectoryOf(newDirectoryLimiterTestTransfer("https://acct.file.core.windows.net/share/dir/a.txt?sig=x")),
+		chk.Equals, "acct.file.core.windows.net/share/dir")
+	c.Assert(limitedDirectoryOf(newDirectoryLimiterTestTransfer("https://acct.file.core.windows.net/share/a.txt")),
+		chk.Equals, "acct.file.core.windows.net/share")
+
+	upload := &directoryLimiterTestTransferMgr{fromTo: common.EFromTo.LocalBlob(), destination: "https://acct.blob.core.windows.net/cont/dir/a.txt"}
+	c.Assert(limitedDirectoryOf(upload), chk.Equals, "")
+
+	// no cap at all
+	c.Assert(newDirectoryLimiter(0), chk.IsNil)
+	c.Assert(newDirectoryLimiter(0).admit(newDirectoryLimiterTestTransfer("https://acct.file.core.windows.net/share/a.txt")), chk.Equals, true)
+}
+
+func (s *directoryLimiterSuite) TestBusyDirectoryHandsItsPlacesOnInTurn(c *chk.C) {
+	l := newDirectoryLimiter(2)
+	rescheduled := make(chan IJobPartTransferMgr, 10)
+	reschedule := func(jptm IJobPartTransferMgr) { rescheduled <- jptm }
+
+	hot := make([]IJobPartTransferMgr, 4)
+	for i := range hot {
+		hot[i] = newDirectoryLimiterTestTransfer("https://acct.file.core.windows.net/share/hot/" + string(rune('a'+i)))
+	}
+	c.Assert(l.admit(hot[0]), chk.Equals, true)
+	c.Assert(l.admit(hot[1]), chk.Equals, true)
+	c.Assert(l.admit(hot[2]), chk.Equals, false)
+	c.Assert(l.admit(hot[3]), chk.Equals, false)
+	c.Assert(l.setAside(), chk.Equals, uint64(2))
+
+	// the other directories aren't held up
+	c.Assert(l.admit(newDirectoryLimiterTestTransfer("https://acct.file.core.windows.net/share/cold/a")), chk.Equals, true)
+
+	// each transfer that ends hands its place to the next one set aside, in order
+	l.release(hot[0], reschedule)
+	select {
+	case next := <-rescheduled:
+		c.Assert(next, chk.Equals, hot[2])
+	case <-time.After(5 * time.Second):
+		c.Fatal("the transfer that was set aside was not scheduled again")
+	}
+	c.Assert(l.admit(hot[2]), chk.Equals, true)
+	c.Assert(l.inFlight["acct.file.core.windows.net/share/hot"], chk.Equals, 2)
+
+	// a transfer that never held a place gives nothing up
+	l.release(newDirectoryLimiterTestTransfer("https://acct.file.core.windows.net/share/hot/z"), reschedule)
+	c.Assert(l.inFlight["acct.file.core.windows.net/share/hot"], chk.Equals, 2)
+
+	l.release(hot[1], reschedule)
+	c.Assert(<-rescheduled, chk.Equals, hot[3])
+	l.release(hot[2], reschedule)
+	l.release(hot[3], reschedule)
+	c.Assert(l.inFlight, chk.HasLen, 1) // only the cold one is left
+	c.Assert(l.waiting, chk.HasLen, 0)
+	c.Assert(len(rescheduled), chk.Equals, 0)
+}
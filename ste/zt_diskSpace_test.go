@@ -0,0 +1,94 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+
+	chk "gopkg.in/check.v1"
+)
+
+type diskSpaceSuite struct{}
+
+var _ = chk.Suite(&diskSpaceSuite{})
+
+const mb = 1024 * 1024
+
+// fakeVolume replaces the measurement of the free space with the given volume, until the returned func is called
+func fakeVolume(free *uint64, err error) (restore func()) {
+	original := freeDiskSpace
+	freeDiskSpace = func(path string) (uint64, error) { return *free, err }
+	return func() { freeDiskSpace = original }
+}
+
+func (s *diskSpaceSuite) TestDownloadsAreHeldBackUntilThereIsRoom(c *chk.C) {
+	free := uint64(100 * mb)
+	defer fakeVolume(&free, nil)()
+	logged := make([]string, 0)
+	g := newDiskSpaceGuard(10*mb, func(msg string) { logged = append(logged, msg) })
+
+	c.Assert(g.tryTake("/data/a", 50*mb), chk.Equals, true)
+	// the first file isn't created yet, so its space is still set aside
+	c.Assert(g.tryTake("/data/b", 50*mb), chk.Equals, false)
+	c.Assert(g.paused(), chk.Equals, true)
+	c.Assert(logged, chk.HasLen, 1)
+
+	// once created, the file's space is what the volume has lost
+	g.created(50 * mb)
+	free = 50 * mb
+	c.Assert(g.tryTake("/data/b", 30*mb), chk.Equals, true)
+	c.Assert(g.paused(), chk.Equals, false)
+	c.Assert(logged, chk.HasLen, 2)
+	c.Assert(g.tryTake("/data/c", 15*mb), chk.Equals, false)
+
+	// and what others free lets the job carry on
+	free = 200 * mb
+	c.Assert(g.tryTake("/data/c", 15*mb), chk.Equals, true)
+}
+
+func (s *diskSpaceSuite) TestWaitingEndsWithTheTransfer(c *chk.C) {
+	free := uint64(0)
+	defer fakeVolume(&free, nil)()
+	g := newDiskSpaceGuard(mb, func(string) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(g.waitForSpace(ctx, "/data/a", mb), chk.Equals, context.Canceled)
+}
+
+func (s *diskSpaceSuite) TestNothingIsHeldBackWhenTheSpaceIsUnknown(c *chk.C) {
+	free := uint64(0)
+	defer fakeVolume(&free, errors.New("not supported"))()
+	logged := make([]string, 0)
+	g := newDiskSpaceGuard(mb, func(msg string) { logged = append(logged, msg) })
+
+	c.Assert(g.waitForSpace(context.Background(), "/data/a", mb), chk.IsNil)
+	c.Assert(g.waitForSpace(context.Background(), "/data/b", mb), chk.IsNil)
+	c.Assert(logged, chk.HasLen, 1)
+	g.created(mb)
+
+	// and without a margin, the volume isn't even looked at
+	var nilGuard *diskSpaceGuard
+	c.Assert(nilGuard.waitForSpace(context.Background(), "/data/a", mb), chk.IsNil)
+	c.Assert(nilGuard.paused(), chk.Equals, false)
+	c.Assert(newDiskSpaceGuard(0, nil).waitForSpace(context.Background(), "/data/a", mb), chk.IsNil)
+}
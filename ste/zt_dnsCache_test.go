@@ -0,0 +1,144 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type dnsCacheSuite struct{}
+
+var _ = chk.Suite(&dnsCacheSuite{})
+
+// fakeResolver answers the lookups with the addresses it's given, and counts them
+type fakeResolver struct {
+	lock    sync.Mutex
+	addrs   []string
+	lookups int
+}
+
+func (r *fakeResolver) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lookups++
+	result := make([]net.IPAddr, 0, len(r.addrs))
+	for _, a := range r.addrs {
+		result = append(result, net.IPAddr{IP: net.ParseIP(a)})
+	}
+	return result, nil
+}
+
+// fakeDialer connects to the addresses it's told are up, and records every address it's asked to dial
+type fakeDialer struct {
+	up     map[string]bool
+	dialed []string
+}
+
+func (d *fakeDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, address)
+	if d.up[address] {
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	return nil, errors.New("connection refused")
+}
+
+func (s *dnsCacheSuite) TestAddressesAreReusedUntilTheyExpire(c *chk.C) {
+	resolver := &fakeResolver{addrs: []string{"10.0.0.1"}}
+	cache := newDNSCache(time.Minute, resolver.lookup)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	dialer := &fakeDialer{up: map[string]bool{"10.0.0.1:443": true}}
+	dial := cache.dialContext(dialer.dial, nil)
+
+	for i := 0; i < 3; i++ {
+		conn, err := dial(context.Background(), "tcp", "acct.blob.core.windows.net:443")
+		c.Assert(err, chk.IsNil)
+		conn.Close()
+	}
+	c.Assert(resolver.lookups, chk.Equals, 1)
+
+	now = now.Add(2 * time.Minute)
+	_, err := dial(context.Background(), "tcp", "acct.blob.core.windows.net:443")
+	c.Assert(err, chk.IsNil)
+	c.Assert(resolver.lookups, chk.Equals, 2)
+
+	resolutions, hits, retired := cache.stats()
+	c.Assert(resolutions, chk.Equals, uint64(2))
+	c.Assert(hits, chk.Equals, uint64(2))
+	c.Assert(retired, chk.Equals, uint64(0))
+	c.Assert(dialer.dialed, chk.DeepEquals, []string{"10.0.0.1:443", "10.0.0.1:443", "10.0.0.1:443", "10.0.0.1:443"})
+
+	// addresses aren't looked up
+	_, err = dial(context.Background(), "tcp", "127.0.0.1:10000")
+	c.Assert(err, chk.NotNil)
+	c.Assert(resolver.lookups, chk.Equals, 2)
+}
+
+func (s *dnsCacheSuite) TestStaleAddressesAreLookedUpAgain(c *chk.C) {
+	resolver := &fakeResolver{addrs: []string{"10.0.0.1"}}
+	cache := newDNSCache(time.Hour, resolver.lookup)
+	dialer := &fakeDialer{up: map[string]bool{"10.0.0.1:443": true}}
+	retirements := 0
+	dial := cache.dialContext(dialer.dial, func() { retirements++ })
+
+	_, err := dial(context.Background(), "tcp", "acct.blob.core.windows.net:443")
+	c.Assert(err, chk.IsNil)
+
+	// the endpoint moves
+	resolver.addrs = []string{"10.0.0.2"}
+	dialer.up = map[string]bool{"10.0.0.2:443": true}
+
+	_, err = dial(context.Background(), "tcp", "acct.blob.core.windows.net:443")
+	c.Assert(err, chk.NotNil)
+	c.Assert(retirements, chk.Equals, 0) // one failure could be a blip
+	_, err = dial(context.Background(), "tcp", "acct.blob.core.windows.net:443")
+	c.Assert(err, chk.NotNil)
+	c.Assert(retirements, chk.Equals, 1)
+
+	_, err = dial(context.Background(), "tcp", "acct.blob.core.windows.net:443")
+	c.Assert(err, chk.IsNil)
+	c.Assert(resolver.lookups, chk.Equals, 2)
+	_, _, retired := cache.stats()
+	c.Assert(retired, chk.Equals, uint64(1))
+}
+
+func (s *dnsCacheSuite) TestOnlyAddressesOfTheNetworkAreDialed(c *chk.C) {
+	resolver := &fakeResolver{addrs: []string{"fd00::1", "10.0.0.1"}}
+	cache := newDNSCache(time.Hour, resolver.lookup)
+	dialer := &fakeDialer{up: map[string]bool{"10.0.0.1:443": true}}
+
+	_, err := cache.dialContext(dialer.dial, nil)(context.Background(), "tcp4", "acct.blob.core.windows.net:443")
+	c.Assert(err, chk.IsNil)
+	c.Assert(dialer.dialed, chk.DeepEquals, []string{"10.0.0.1:443"})
+
+	var none *dnsCache
+	c.Assert(none.dialContext(dialer.dial, nil), chk.NotNil)
+	resolutions, _, _ := none.stats()
+	c.Assert(resolutions, chk.Equals, uint64(0))
+}
@@ -0,0 +1,114 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type downloadPlanSuite struct{}
+
+var _ = chk.Suite(&downloadPlanSuite{})
+
+const mib = 1024 * 1024
+
+func (s *downloadPlanSuite) TestSmallFilesAreOneGetWhateverTheBlockSize(c *chk.C) {
+	plan := planDownload(6*mib, 0, 1*mib, true, false, false)
+	c.Assert(plan, chk.Equals, downloadPlan{chunkSize: 6 * mib, numChunks: 1, wholeFile: true}) // rather than 6 ranges
+	c.Assert(planDownload(singleGetThreshold, 0, 4*mib, true, false, false).numChunks, chk.Equals, uint32(1))
+
+	// not when the downloader can't, nor when part of the file is already saved
+	c.Assert(planDownload(6*mib, 0, 1*mib, false, false, false), chk.Equals, downloadPlan{chunkSize: 1 * mib, numChunks: 6})
+	c.Assert(planDownload(6*mib, 2*mib, 1*mib, true, false, false), chk.Equals, downloadPlan{chunkSize: 1 * mib, numChunks: 4})
+}
+
+func (s *downloadPlanSuite) TestChunksOfMidSizeFilesAreCoalesced(c *chk.C) {
+	c.Assert(planDownload(20*mib, 0, 1*mib, true, true, false), chk.Equals, downloadPlan{chunkSize: 8 * mib, numChunks: 3}) // rather than 20
+	c.Assert(planDownload(20*mib, 0, 1*mib, true, true, true), chk.Equals, downloadPlan{chunkSize: 4 * mib, numChunks: 5})  // still verified chunk by chunk
+	c.Assert(planDownload(20*mib, 0, 3*mib, true, true, false), chk.Equals, downloadPlan{chunkSize: 6 * mib, numChunks: 4}) // a multiple of the block size
+	c.Assert(planDownload(20*mib, 4*mib, 1*mib, true, true, false), chk.Equals, downloadPlan{chunkSize: 8 * mib, numChunks: 2})
+
+	// not when the job needs the chunks to keep the connections busy, nor for large files, nor when the blocks are large already
+	c.Assert(planDownload(20*mib, 0, 1*mib, true, false, false).numChunks, chk.Equals, uint32(20))
+	c.Assert(planDownload(100*mib, 0, 1*mib, true, true, false).numChunks, chk.Equals, uint32(100))
+	c.Assert(planDownload(20*mib, 0, 8*mib, true, true, false), chk.Equals, downloadPlan{chunkSize: 8 * mib, numChunks: 3})
+}
+
+// lmtTransferMgr only knows the last modified time of its source
+type lmtTransferMgr struct {
+	IJobPartTransferMgr
+	lmt time.Time
+}
+
+func (t lmtTransferMgr) LastModifiedTime() time.Time { return t.lmt }
+
+func (s *downloadPlanSuite) TestWholeBlobIsOneUnconditionalGet(c *chk.C) {
+	lmt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	requests := make([]*http.Request, 0)
+	responseLmt := lmt
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			requests = append(requests, request.Request)
+			header := http.Header{}
+			header.Set("Content-Length", strconv.Itoa(len("small blob")))
+			header.Set("Last-Modified", responseLmt.Format(http.TimeFormat))
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Header: header,
+				Body: ioutil.NopCloser(strings.NewReader("small blob"))}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+	u, _ := url.Parse("https://account.blob.core.windows.net/container/blob")
+	blobURL := azblob.NewBlobURL(*u, p)
+
+	get, err := getWholeBlob(context.Background(), lmtTransferMgr{lmt: lmt}, blobURL, int64(len("small blob")), false, true)
+	c.Assert(err, chk.IsNil)
+	body, err := ioutil.ReadAll(get.Body(azblob.RetryReaderOptions{}))
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(body), chk.Equals, "small blob")
+
+	c.Assert(requests, chk.HasLen, 1)
+	c.Assert(requests[0].Method, chk.Equals, http.MethodGet)
+	c.Assert(requests[0].Header.Get("x-ms-range"), chk.Equals, "")
+	c.Assert(requests[0].Header.Get("If-Unmodified-Since"), chk.Equals, "")
+
+	// the properties in the response show that the blob changed
+	responseLmt = lmt.Add(time.Minute)
+	_, err = getWholeBlob(context.Background(), lmtTransferMgr{lmt: lmt}, blobURL, int64(len("small blob")), false, true)
+	c.Assert(err, chk.ErrorMatches, "the source was modified after it was enumerated")
+	_, err = getWholeBlob(context.Background(), lmtTransferMgr{lmt: lmt}, blobURL, 5, false, false)
+	c.Assert(err, chk.ErrorMatches, "the source was modified after it was enumerated")
+
+	// the range is only there to get the MD5 hash of it
+	_, err = getWholeBlob(context.Background(), lmtTransferMgr{}, blobURL, int64(len("small blob")), true, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(requests[len(requests)-1].Header.Get("x-ms-range"), chk.Equals, "bytes=0-9")
+	c.Assert(requests[len(requests)-1].Header.Get("x-ms-range-get-content-md5"), chk.Equals, "true")
+}
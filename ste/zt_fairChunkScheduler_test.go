@@ -0,0 +1,148 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type fairChunkSchedulerSuite struct{}
+
+var _ = chk.Suite(&fairChunkSchedulerSuite{})
+
+// drainFairChunkScheduler processes every chunk waiting, in the order that the scheduler hands them out
+func drainFairChunkScheduler(s *fairChunkScheduler) {
+	for {
+		chunk, ok := s.next()
+		if !ok {
+			return
+		}
+		chunk(0)
+	}
+}
+
+func (s *fairChunkSchedulerSuite) TestWeightsGrowWithTheLogOfTheChunks(c *chk.C) {
+	c.Assert(fairWeightOf(0), chk.Equals, 1)
+	c.Assert(fairWeightOf(1), chk.Equals, 1)
+	c.Assert(fairWeightOf(4), chk.Equals, 3)
+	c.Assert(fairWeightOf(100), chk.Equals, 7)
+	c.Assert(fairWeightOf(1000000), chk.Equals, fairMaxWeight)
+}
+
+func (s *fairChunkSchedulerSuite) TestEachTransferGetsItsWeightOfChunksPerRound(c *chk.C) {
+	scheduler := newFairChunkScheduler(100)
+	order := make([]string, 0)
+	schedule := func(priority common.JobPriority, transfer string, weight int, count int) {
+		for i := 0; i < count; i++ {
+			scheduler.schedule(priority, transfer, weight, func(int) { order = append(order, transfer) })
+		}
+	}
+
+	schedule(common.EJobPriority.Low(), "low", 1, 2)
+	schedule(common.EJobPriority.Normal(), "a", 2, 5)
+	schedule(common.EJobPriority.Normal(), "b", 1, 2)
+	schedule(common.EJobPriority.Normal(), "c", 1, 1)
+	drainFairChunkScheduler(scheduler)
+
+	// the transfers that run out of chunks leave the round, and the transfers of low priority wait for those of normal priority
+	c.Assert(order, chk.DeepEquals, []string{"a", "a", "b", "c", "a", "a", "b", "a", "low", "low"})
+	c.Assert(len(scheduler.slots), chk.Equals, 0)
+}
+
+func (s *fairChunkSchedulerSuite) TestTransfersThatComeBackWaitForTheirTurn(c *chk.C) {
+	scheduler := newFairChunkScheduler(100)
+	order := make([]string, 0)
+	schedule := func(transfer string) {
+		scheduler.schedule(common.EJobPriority.Normal(), transfer, 1, func(int) { order = append(order, transfer) })
+	}
+
+	schedule("a")
+	schedule("b")
+	schedule("b")
+	chunk, ok := scheduler.next()
+	c.Assert(ok, chk.Equals, true)
+	chunk(0)
+
+	// a has no chunks waiting any more, so when it schedules another, it goes after b
+	schedule("a")
+	drainFairChunkScheduler(scheduler)
+	c.Assert(order, chk.DeepEquals, []string{"a", "b", "a", "b"})
+}
+
+func (s *fairChunkSchedulerSuite) TestSmallFilesCompletePromptlyAlongsideAGiantOne(c *chk.C) {
+	const giantChunks = 2000
+	const smallFiles = 50
+	const workers = 4
+
+	scheduler := newFairChunkScheduler(giantChunks + smallFiles)
+
+	var lock sync.Mutex
+	processed := 0
+	giantProcessedWhenSmallDone := make(map[string]int)
+	giantProcessed := 0
+
+	// the giant file has all of its chunks queued before any of the small files is scheduled, as it would without fair scheduling
+	for i := 0; i < giantChunks; i++ {
+		scheduler.schedule(common.EJobPriority.Normal(), "giant", fairWeightOf(giantChunks), func(int) {
+			lock.Lock()
+			defer lock.Unlock()
+			processed++
+			giantProcessed++
+		})
+	}
+	for i := 0; i < smallFiles; i++ {
+		name := fmt.Sprintf("small%d", i)
+		scheduler.schedule(common.EJobPriority.Normal(), name, fairWeightOf(1), func(int) {
+			lock.Lock()
+			defer lock.Unlock()
+			processed++
+			giantProcessedWhenSmallDone[name] = giantProcessed
+		})
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for {
+				chunk, ok := scheduler.next()
+				if !ok {
+					return
+				}
+				chunk(workerID)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	c.Assert(processed, chk.Equals, giantChunks+smallFiles)
+	c.Assert(giantProcessedWhenSmallDone, chk.HasLen, smallFiles)
+	for name, giantDone := range giantProcessedWhenSmallDone {
+		// every small file lands in the first round, in which the giant one gets no more than its weight of chunks processed
+		// (give or take those that the other workers had taken)
+		c.Assert(giantDone <= fairMaxWeight+workers, chk.Equals, true, chk.Commentf("%s waited for %d chunks of the giant file", name, giantDone))
+	}
+}
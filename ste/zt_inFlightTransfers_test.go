@@ -0,0 +1,122 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type inFlightTransfersSuite struct{}
+
+var _ = chk.Suite(&inFlightTransfersSuite{})
+
+const gib = int64(1024 * 1024 * 1024)
+
+// writeSizedPlan writes a plan of transfers of the given sizes, to and from files named after their index
+func (s *inFlightTransfersSuite) writeSizedPlan(c *chk.C, sizes ...int64) (dir string, mmf *JobPartPlanMMF) {
+	dir, err := ioutil.TempDir("", "inFlightTransfers")
+	c.Assert(err, chk.IsNil)
+	planPath := filepath.Join(dir, "plan.steV1")
+
+	transfers := make([]common.CopyTransfer, 0, len(sizes))
+	for i, size := range sizes {
+		name := "/" + string(rune('a'+i)) + ".vhd"
+		transfers = append(transfers, common.CopyTransfer{Source: name, Destination: name, LastModifiedTime: time.Now(), SourceSize: size})
+	}
+	createJobPartPlanFile(planPath, common.CopyJobPartOrderRequest{
+		JobID:           common.NewJobID(),
+		FromTo:          common.EFromTo.LocalBlob(),
+		SourceRoot:      "/disks",
+		DestinationRoot: "https://account.blob.core.windows.net/disks",
+		Transfers:       transfers,
+	}, nil)
+
+	file, err := os.OpenFile(planPath, os.O_RDWR, 0644) // writable, since the tests play the part of the transfers
+	c.Assert(err, chk.IsNil)
+	defer file.Close()
+	info, err := file.Stat()
+	c.Assert(err, chk.IsNil)
+	m, err := common.NewMMF(file, true, 0, info.Size())
+	c.Assert(err, chk.IsNil)
+	return dir, (*JobPartPlanMMF)(m)
+}
+
+func (s *inFlightTransfersSuite) TestOnlyTheLargestStartedTransfersAreKept(c *chk.C) {
+	dir, mmf := s.writeSizedPlan(c, 2*gib, 1024, 8*gib, 4*gib, 3*gib, 5*gib, gib)
+	defer os.RemoveAll(dir)
+	defer mmf.Unmap()
+	plan := mmf.Plan()
+
+	now := time.Now()
+	for t := uint32(0); t < plan.NumTransfers; t++ {
+		if t != 5 { // the second largest hasn't been picked up yet
+			plan.Transfer(t).StartProgress(now)
+		}
+	}
+	plan.Transfer(3).SetTransferStatus(common.ETransferStatus.Success(), false) // and this one is done
+
+	inFlight := largestInFlight{}
+	for t := uint32(0); t < plan.NumTransfers; t++ {
+		inFlight.consider(plan, t, plan.Transfer(t))
+	}
+
+	progress := inFlight.progress(now)
+	c.Assert(progress, chk.HasLen, maxInFlightTransfersShown)
+	// only the three largest of those in flight, and the small one wouldn't count anyway
+	c.Assert(progress[0].Src, chk.Equals, "/disks/c.vhd")
+	c.Assert(progress[0].Dst, chk.Equals, "https://account.blob.core.windows.net/disks/c.vhd")
+	c.Assert(progress[1].Src, chk.Equals, "/disks/e.vhd")
+	c.Assert(progress[2].Src, chk.Equals, "/disks/a.vhd")
+}
+
+func (s *inFlightTransfersSuite) TestProgressIsReckonedFromTheChunksDone(c *chk.C) {
+	dir, mmf := s.writeSizedPlan(c, 8*gib)
+	defer os.RemoveAll(dir)
+	defer mmf.Unmap()
+	jppt := mmf.Plan().Transfer(0)
+
+	started := time.Now()
+	jppt.StartProgress(started)
+	for i := 0; i < 2; i++ {
+		jppt.AddBytesDone(gib)
+	}
+
+	inFlight := largestInFlight{}
+	inFlight.consider(mmf.Plan(), 0, jppt)
+	progress := inFlight.progress(started.Add(100 * time.Second))
+	c.Assert(progress, chk.HasLen, 1)
+	c.Assert(progress[0].BytesDone, chk.Equals, uint64(2*gib))
+	c.Assert(progress[0].PercentComplete, chk.Equals, float32(25))
+	c.Assert(int(progress[0].ThroughputMbps), chk.Equals, int(2*gib*8/100/1000/1000))
+	c.Assert(progress[0].SecondsRemaining, chk.Equals, uint64(300))
+
+	// a new attempt starts from nothing
+	jppt.StartProgress(started)
+	done, _ := jppt.Progress()
+	c.Assert(done, chk.Equals, int64(0))
+}
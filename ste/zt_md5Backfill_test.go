@@ -0,0 +1,138 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type md5BackfillSuite struct{}
+
+var _ = chk.Suite(&md5BackfillSuite{})
+
+// md5BackfillTestTransferMgr is a S2S transfer of a job that computes the given Content-MD5s
+type md5BackfillTestTransferMgr struct {
+	IJobPartTransferMgr
+	option     common.S2SComputeMD5
+	inProgress int
+	backfilled int
+	withoutMD5 int
+	failed     bool
+}
+
+func (t *md5BackfillTestTransferMgr) S2SComputeMD5() common.S2SComputeMD5                    { return t.option }
+func (t *md5BackfillTestTransferMgr) ReportHashBackfillStarted()                             { t.inProgress++ }
+func (t *md5BackfillTestTransferMgr) ReportHashBackfillEnded()                               { t.inProgress-- }
+func (t *md5BackfillTestTransferMgr) ReportMD5Backfilled()                                   { t.backfilled++ }
+func (t *md5BackfillTestTransferMgr) ReportBlobWithoutMD5()                                  { t.withoutMD5++ }
+func (t *md5BackfillTestTransferMgr) FailActiveSend(where string, err error)                 { t.failed = true }
+func (t *md5BackfillTestTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string) {}
+func (t *md5BackfillTestTransferMgr) Context() context.Context                               { return context.Background() }
+
+func (s *md5BackfillSuite) TestOnlyBlobsCommittedFromBlocksAreLeftWithoutMD5(c *chk.C) {
+	sourceMD5 := []byte{1, 2, 3}
+
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.None(), true, nil), chk.Equals, md5BackfillSkipped)
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.None(), true, sourceMD5), chk.Equals, md5BackfillNotNeeded)
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.None(), false, nil), chk.Equals, md5BackfillNotNeeded)
+
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.Missing(), true, nil), chk.Equals, md5BackfillNeeded)
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.Missing(), true, sourceMD5), chk.Equals, md5BackfillNotNeeded)
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.Missing(), false, nil), chk.Equals, md5BackfillNotNeeded)
+
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.Always(), true, sourceMD5), chk.Equals, md5BackfillNeeded)
+	c.Assert(md5BackfillFor(common.ES2SComputeMD5.Always(), false, nil), chk.Equals, md5BackfillNeeded)
+}
+
+// md5BackfillTestDestination is a block blob with the given content, whose Content-MD5 is recorded when it's set
+func md5BackfillTestDestination(content string, setMD5 *string) azblob.BlockBlobURL {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			header := http.Header{}
+			body := ""
+			if request.Method == http.MethodGet {
+				body = content
+				header.Set("Content-Length", strconv.Itoa(len(content)))
+			} else {
+				*setMD5 = request.Header.Get("x-ms-blob-content-md5")
+			}
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Status: "OK", Header: header,
+				Body: ioutil.NopCloser(strings.NewReader(body)), Request: request.Request}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+	u, _ := url.Parse("https://dest.blob.core.windows.net/cont/file")
+	return azblob.NewBlockBlobURL(*u, p)
+}
+
+func (s *md5BackfillSuite) TestMD5IsComputedFromTheDestination(c *chk.C) {
+	setMD5 := ""
+	jptm := &md5BackfillTestTransferMgr{option: common.ES2SComputeMD5.Missing()}
+	sender := &blockBlobSenderBase{jptm: jptm, destBlockBlobURL: md5BackfillTestDestination("hello", &setMD5),
+		headersToApply: azblob.BlobHTTPHeaders{ContentType: "text/plain"}}
+
+	sender.backfillMD5(true)
+	hash := md5.Sum([]byte("hello"))
+	c.Assert(setMD5, chk.Equals, base64.StdEncoding.EncodeToString(hash[:]))
+	c.Assert(jptm.backfilled, chk.Equals, 1)
+	c.Assert(jptm.withoutMD5, chk.Equals, 0)
+	c.Assert(jptm.inProgress, chk.Equals, 0)
+
+	// without the option, the blob is only reported
+	setMD5 = ""
+	jptm = &md5BackfillTestTransferMgr{option: common.ES2SComputeMD5.None()}
+	sender.jptm = jptm
+	sender.backfillMD5(true)
+	c.Assert(setMD5, chk.Equals, "")
+	c.Assert(jptm.backfilled, chk.Equals, 0)
+	c.Assert(jptm.withoutMD5, chk.Equals, 1)
+}
+
+func (s *md5BackfillSuite) TestDestinationMustMatchTheMD5OfTheSource(c *chk.C) {
+	setMD5 := ""
+	hash := md5.Sum([]byte("hello"))
+
+	jptm := &md5BackfillTestTransferMgr{option: common.ES2SComputeMD5.Always()}
+	sender := &blockBlobSenderBase{jptm: jptm, destBlockBlobURL: md5BackfillTestDestination("hello", &setMD5),
+		headersToApply: azblob.BlobHTTPHeaders{ContentMD5: hash[:]}}
+	sender.backfillMD5(true)
+	c.Assert(jptm.failed, chk.Equals, false)
+	c.Assert(setMD5, chk.Equals, "") // it already has it
+	c.Assert(jptm.backfilled, chk.Equals, 0)
+
+	sender.destBlockBlobURL = md5BackfillTestDestination("hellO", &setMD5)
+	sender.backfillMD5(true)
+	c.Assert(jptm.failed, chk.Equals, true)
+	c.Assert(jptm.inProgress, chk.Equals, 0)
+}
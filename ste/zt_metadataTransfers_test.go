@@ -0,0 +1,111 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type metadataTransfersSuite struct{}
+
+var _ = chk.Suite(&metadataTransfersSuite{})
+
+// fromToTestTransferMgr is a transfer that goes from and to the given locations, and nothing else
+type fromToTestTransferMgr struct {
+	IJobPartTransferMgr
+	fromTo common.FromTo
+}
+
+func (t *fromToTestTransferMgr) FromTo() common.FromTo {
+	return t.fromTo
+}
+
+func (s *metadataTransfersSuite) TestMetadataOnlyTransfersAreScheduledApart(c *chk.C) {
+	normalCh, lowCh := make(chan IJobPartTransferMgr, 4), make(chan IJobPartTransferMgr, 4)
+	normalMetadataCh, lowMetadataCh := make(chan IJobPartTransferMgr, 4), make(chan IJobPartTransferMgr, 4)
+	ja := &jobsAdmin{coordinatorChannels: CoordinatorChannels{
+		normalTransferCh:         normalCh,
+		lowTransferCh:            lowCh,
+		normalMetadataTransferCh: normalMetadataCh,
+		lowMetadataTransferCh:    lowMetadataCh,
+	}}
+
+	upload := &fromToTestTransferMgr{fromTo: common.EFromTo.LocalBlob()}
+	deletion := &fromToTestTransferMgr{fromTo: common.EFromTo.BlobTrash()}
+	setProperties := &fromToTestTransferMgr{fromTo: common.EFromTo.BlobNone()}
+
+	ja.ScheduleTransfer(common.EJobPriority.Normal(), upload)
+	ja.ScheduleTransfer(common.EJobPriority.Normal(), deletion)
+	ja.ScheduleTransfer(common.EJobPriority.Low(), setProperties)
+
+	c.Assert(len(normalCh), chk.Equals, 1)
+	c.Assert(<-normalCh, chk.Equals, upload)
+	c.Assert(len(normalMetadataCh), chk.Equals, 1)
+	c.Assert(<-normalMetadataCh, chk.Equals, deletion)
+	c.Assert(len(lowMetadataCh), chk.Equals, 1)
+	c.Assert(<-lowMetadataCh, chk.Equals, setProperties)
+	c.Assert(len(lowCh), chk.Equals, 0)
+}
+
+func (s *metadataTransfersSuite) TestMetadataOnlyTransfersUseTheirOwnPacerWhenThereIsOne(c *chk.C) {
+	originalJobsAdmin := JobsAdmin
+	defer func() { JobsAdmin = originalJobsAdmin }()
+
+	dataPacer := newTransactionPacer(100)
+	defer dataPacer.Close()
+	ja := &jobsAdmin{transactionPacer: dataPacer}
+	JobsAdmin = ja
+
+	metadataCtx := withMetadataOnlyTransfers(context.Background())
+
+	// without a cap of their own, the metadata-only transfers share the one of the others
+	c.Assert(currentTransactionPacer(context.Background()), chk.Equals, dataPacer)
+	c.Assert(currentTransactionPacer(metadataCtx), chk.Equals, dataPacer)
+
+	metadataPacer := newTransactionPacer(10)
+	defer metadataPacer.Close()
+	ja.metadataTransactionPacer = metadataPacer
+	c.Assert(currentTransactionPacer(context.Background()), chk.Equals, dataPacer)
+	c.Assert(currentTransactionPacer(metadataCtx), chk.Equals, metadataPacer)
+	c.Assert(currentTransactionPacer(context.WithValue(metadataCtx, contextKey{"other"}, 1)), chk.Equals, metadataPacer)
+}
+
+func (s *metadataTransfersSuite) TestRequestsAreCountedByTransferClass(c *chk.C) {
+	stats := newPipelineNetworkStats(&nullConcurrencyTuner{})
+	metadataCtx := withMetadataOnlyTransfers(context.Background())
+
+	stats.recordClassTry(metadataCtx, http.StatusAccepted)
+	stats.recordClassTry(metadataCtx, http.StatusServiceUnavailable)
+	stats.recordClassTry(metadataCtx, http.StatusTooManyRequests)
+	stats.recordClassTry(context.Background(), http.StatusCreated)
+	stats.recordClassTry(context.Background(), http.StatusInternalServerError)
+
+	metadata, metadataThrottled, data, dataThrottled := stats.RequestsByTransferClass()
+	c.Assert(metadata, chk.Equals, int64(3))
+	c.Assert(metadataThrottled, chk.Equals, int64(2))
+	c.Assert(data, chk.Equals, int64(2))
+	c.Assert(dataThrottled, chk.Equals, int64(0))
+}
@@ -0,0 +1,115 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type missingSourceSuite struct{}
+
+var _ = chk.Suite(&missingSourceSuite{})
+
+// missingSourceTestTransferMgr is a transfer from Blob Storage, of a job that does the given thing with missing sources
+type missingSourceTestTransferMgr struct {
+	IJobPartTransferMgr
+	handling common.MissingSourceHandling
+}
+
+func (t *missingSourceTestTransferMgr) MissingSourceHandling() common.MissingSourceHandling {
+	return t.handling
+}
+
+func (t *missingSourceTestTransferMgr) FromTo() common.FromTo {
+	return common.EFromTo.BlobBlob()
+}
+
+// errorFromService gets the error of a request that the service fails with the status, the error code and the message,
+// along with any extra headers
+func errorFromService(c *chk.C, status int, code string, message string, extraHeaders map[string]string) error {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			header := http.Header{}
+			header.Set("x-ms-error-code", code)
+			for k, v := range extraHeaders {
+				header.Set(k, v)
+			}
+			body := "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>" + code + "</Code><Message>" + message + "</Message></Error>"
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: status, Status: http.StatusText(status), Header: header,
+				Body: ioutil.NopCloser(strings.NewReader(body)), Request: request.Request}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	u, _ := url.Parse("https://dest.blob.core.windows.net/cont/blob")
+	src, _ := url.Parse("https://src.blob.core.windows.net/cont/blob")
+	_, err := azblob.NewBlockBlobURL(*u, p).StageBlockFromURL(context.Background(), "AAAA", *src, 0, 4, azblob.LeaseAccessConditions{}, azblob.ModifiedAccessConditions{})
+	c.Assert(err, chk.NotNil)
+	return err
+}
+
+func (s *missingSourceSuite) TestDeletedSourceIsOnlySkippedWhenAskedFor(c *chk.C) {
+	err := errorFromService(c, http.StatusNotFound, "BlobNotFound", "The specified blob does not exist.", nil)
+
+	c.Assert(skipsMissingSource(&missingSourceTestTransferMgr{handling: common.EMissingSourceHandling.Skip()}, err), chk.Equals, true)
+	c.Assert(skipsMissingSource(&missingSourceTestTransferMgr{handling: common.EMissingSourceHandling.Fail()}, err), chk.Equals, false)
+	c.Assert(skipsMissingSource(&missingSourceTestTransferMgr{handling: common.EMissingSourceHandling.Skip()}, errors.New("connection reset")), chk.Equals, false)
+}
+
+func (s *missingSourceSuite) TestOnlyTheCodesOfADeletedSourceCount(c *chk.C) {
+	blob := common.ELocation.Blob()
+	c.Assert(isSourceGone(blob, errorFromService(c, http.StatusNotFound, "ContainerNotFound", "The specified container does not exist.", nil)), chk.Equals, true)
+
+	// an unauthorized read of a private container gets a 404 too, but it's a failure
+	c.Assert(isSourceGone(blob, errorFromService(c, http.StatusNotFound, "ResourceNotFound", "The specified resource does not exist.", nil)), chk.Equals, false)
+	c.Assert(isSourceGone(blob, errorFromService(c, http.StatusForbidden, "AuthenticationFailed", "Signature not valid in the specified time frame", nil)), chk.Equals, false)
+
+	// whereas it's what Azure Files gives for a deleted file
+	c.Assert(isSourceGone(common.ELocation.File(), errorFromService(c, http.StatusNotFound, "ResourceNotFound", "The specified resource does not exist.", nil)), chk.Equals, true)
+}
+
+func (s *missingSourceSuite) TestCopySourceErrorsAreJudgedByWhatTheSourceSaid(c *chk.C) {
+	blob := common.ELocation.Blob()
+
+	// from the header, when the service gives it
+	gone := errorFromService(c, http.StatusNotFound, "CannotVerifyCopySource", "The specified blob does not exist.",
+		map[string]string{copySourceErrorCodeHeader: "BlobNotFound"})
+	c.Assert(isSourceGone(blob, gone), chk.Equals, true)
+	expiredSAS := errorFromService(c, http.StatusNotFound, "CannotVerifyCopySource", "The specified resource does not exist.",
+		map[string]string{copySourceErrorCodeHeader: "ResourceNotFound"})
+	c.Assert(isSourceGone(blob, expiredSAS), chk.Equals, false)
+
+	// and otherwise from the message
+	c.Assert(isSourceGone(blob, errorFromService(c, http.StatusNotFound, "CannotVerifyCopySource", "The specified blob does not exist.", nil)), chk.Equals, true)
+	c.Assert(isSourceGone(blob, errorFromService(c, http.StatusNotFound, "CannotVerifyCopySource", "The specified resource does not exist.", nil)), chk.Equals, false)
+	c.Assert(isSourceGone(blob, errorFromService(c, http.StatusForbidden, "CannotVerifyCopySource", "Server failed to authenticate the request.", nil)), chk.Equals, false)
+}
@@ -0,0 +1,154 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type networkSourceFileSuite struct{}
+
+var _ = chk.Suite(&networkSourceFileSuite{})
+
+// retryCountingTransferMgr only counts and logs the retries of its reads
+type retryCountingTransferMgr struct {
+	IJobPartTransferMgr
+	ctx     context.Context
+	retries int
+}
+
+func (t *retryCountingTransferMgr) Context() context.Context                               { return t.ctx }
+func (t *retryCountingTransferMgr) ReportSourceReadRetry()                                 { t.retries++ }
+func (t *retryCountingTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string) {}
+
+// flakyFile fails its reads with the given error, until its share comes back
+type flakyFile struct {
+	share *flakyShare
+}
+
+type flakyShare struct {
+	failuresLeft int
+	readErr      error
+	opens        int
+	closes       int
+}
+
+func (s *flakyShare) open() (common.CloseableReaderAt, error) {
+	s.opens++
+	return &flakyFile{share: s}, nil
+}
+
+func (f *flakyFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.share.failuresLeft > 0 {
+		f.share.failuresLeft--
+		return 0, f.share.readErr
+	}
+	for i := range p {
+		p[i] = byte(off) + byte(i)
+	}
+	return len(p), nil
+}
+
+func (f *flakyFile) Close() error {
+	f.share.closes++
+	return nil
+}
+
+func (s *networkSourceFileSuite) SetUpTest(c *chk.C) {
+	networkSourceRetryBaseDelay = 0
+}
+
+func (s *networkSourceFileSuite) TearDownTest(c *chk.C) {
+	networkSourceRetryBaseDelay = 2 * time.Second
+}
+
+func (s *networkSourceFileSuite) TestReadsAreRetriedAfterReopeningTheFile(c *chk.C) {
+	jptm := &retryCountingTransferMgr{ctx: context.Background()}
+	share := &flakyShare{failuresLeft: 2, readErr: &os.PathError{Op: "read", Path: "/mnt/nas/file", Err: syscall.EIO}}
+	if runtime.GOOS == "windows" {
+		share.readErr = &os.PathError{Op: "read", Path: `\\nas\share\file`, Err: syscall.Errno(64)}
+	}
+
+	file, err := retryingNetworkReads(jptm, share.open)()
+	c.Assert(err, chk.IsNil)
+
+	buffer := make([]byte, 4)
+	n, err := file.ReadAt(buffer, 10)
+	c.Assert(err, chk.IsNil)
+	c.Assert(n, chk.Equals, 4)
+	c.Assert(buffer, chk.DeepEquals, []byte{10, 11, 12, 13})
+	c.Assert(jptm.retries, chk.Equals, 2)
+	c.Assert(share.opens, chk.Equals, 3)
+	c.Assert(share.closes, chk.Equals, 2)
+
+	c.Assert(file.Close(), chk.IsNil)
+	c.Assert(share.closes, chk.Equals, 3)
+}
+
+func (s *networkSourceFileSuite) TestReadsFailOnceTheRetriesRunOut(c *chk.C) {
+	jptm := &retryCountingTransferMgr{ctx: context.Background()}
+	share := &flakyShare{failuresLeft: 100, readErr: networkReadErrors[0]}
+
+	file, err := retryingNetworkReads(jptm, share.open)()
+	c.Assert(err, chk.IsNil)
+	_, err = file.ReadAt(make([]byte, 4), 0)
+	c.Assert(err, chk.Equals, share.readErr)
+	c.Assert(jptm.retries, chk.Equals, maxNetworkSourceReadRetries)
+}
+
+func (s *networkSourceFileSuite) TestOtherErrorsAreNotRetried(c *chk.C) {
+	jptm := &retryCountingTransferMgr{ctx: context.Background()}
+	share := &flakyShare{failuresLeft: 1, readErr: errors.New("bad sector")}
+
+	file, err := retryingNetworkReads(jptm, share.open)()
+	c.Assert(err, chk.IsNil)
+	_, err = file.ReadAt(make([]byte, 4), 0)
+	c.Assert(err, chk.Equals, share.readErr)
+	c.Assert(jptm.retries, chk.Equals, 0)
+	c.Assert(share.opens, chk.Equals, 1)
+}
+
+func (s *networkSourceFileSuite) TestNetworkSourcePaths(c *chk.C) {
+	prefixes := parseNetworkSourcePrefixes(" /mnt/nas/ ; ;/media/share")
+	c.Assert(prefixes, chk.DeepEquals, []string{"/mnt/nas/", "/media/share"})
+
+	c.Assert(isNetworkSourcePath("/mnt/nas/dir/file", prefixes), chk.Equals, true)
+	c.Assert(isNetworkSourcePath("/media/share/file", prefixes), chk.Equals, true)
+	c.Assert(isNetworkSourcePath("/home/user/file", prefixes), chk.Equals, false)
+	c.Assert(isNetworkSourcePath("/mnt/nas/file", nil), chk.Equals, false)
+
+	if runtime.GOOS == "windows" {
+		c.Assert(isNetworkSourcePath(`\\?\UNC\nas\share\file`, nil), chk.Equals, true)
+		c.Assert(isNetworkSourcePath(`\\nas\share\file`, nil), chk.Equals, true)
+		c.Assert(isNetworkSourcePath(`\\?\C:\data\file`, nil), chk.Equals, false)
+		c.Assert(isNetworkSourcePath(`\\?\z:\data\file`, []string{`Z:\`}), chk.Equals, true)
+	}
+}
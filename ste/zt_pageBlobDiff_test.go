@@ -0,0 +1,67 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type pageBlobDiffSuite struct{}
+
+var _ = chk.Suite(&pageBlobDiffSuite{})
+
+func newTestPageBlobDiff() *pageBlobDiff {
+	return &pageBlobDiff{
+		baseSnapshot: "2021-03-01T10:00:00.0000000Z",
+		baseLength:   4096,
+		baseMD5:      []byte{1, 2, 3},
+		changed:      []azblob.PageRange{{Start: 0, End: 511}, {Start: 1024, End: 2047}},
+		cleared:      []azblob.ClearRange{{Start: 3072, End: 3583}},
+	}
+}
+
+func (s *pageBlobDiffSuite) TestChangedRangesAreClippedToTheChunk(c *chk.C) {
+	d := newTestPageBlobDiff()
+
+	c.Assert(d.changedRangesIn(0, 1536), chk.DeepEquals, []azblob.PageRange{{Start: 0, End: 511}, {Start: 1024, End: 1535}})
+	c.Assert(d.changedRangesIn(1536, 1024), chk.DeepEquals, []azblob.PageRange{{Start: 1536, End: 2047}})
+	c.Assert(d.changedRangesIn(512, 512), chk.HasLen, 0)
+	c.Assert(d.changedBytes(), chk.Equals, int64(1536))
+}
+
+func (s *pageBlobDiffSuite) TestClearedRangesAreChanges(c *chk.C) {
+	d := newTestPageBlobDiff()
+
+	c.Assert(d.isUnchangedIn(512, 512), chk.Equals, true)
+	c.Assert(d.isUnchangedIn(2048, 1024), chk.Equals, true)
+	c.Assert(d.isUnchangedIn(3072, 1024), chk.Equals, false)
+	c.Assert(d.isUnchangedIn(0, 4096), chk.Equals, false)
+}
+
+func (s *pageBlobDiffSuite) TestDestinationMustHoldTheBaseSnapshot(c *chk.C) {
+	d := newTestPageBlobDiff()
+
+	c.Assert(d.validateDestination(4096, []byte{1, 2, 3}), chk.IsNil)
+	c.Assert(d.validateDestination(4096, nil), chk.IsNil) // no hash to compare
+	c.Assert(d.validateDestination(8192, []byte{1, 2, 3}), chk.NotNil)
+	c.Assert(d.validateDestination(4096, []byte{3, 2, 1}), chk.NotNil)
+}
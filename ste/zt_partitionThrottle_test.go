@@ -0,0 +1,151 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type partitionThrottleSuite struct{}
+
+var _ = chk.Suite(&partitionThrottleSuite{})
+
+func (s *partitionThrottleSuite) TestBucketsAreTheStartOfTheNames(c *chk.C) {
+	bucketOf := func(rawURL string) string {
+		u, err := url.Parse(rawURL)
+		c.Assert(err, chk.IsNil)
+		return partitionBucketOf(u)
+	}
+
+	c.Assert(bucketOf("https://acct.blob.core.windows.net/cont/2020-05-01/a.txt?sig=x"), chk.Equals, "acct.blob.core.windows.net/cont/2020-05-")
+	c.Assert(bucketOf("https://acct.blob.core.windows.net/cont/2020-05-01/b.txt"), chk.Equals, bucketOf("https://acct.blob.core.windows.net/cont/2020-05-02/c.txt"))
+	c.Assert(bucketOf("https://acct.blob.core.windows.net/cont/a.txt"), chk.Equals, "acct.blob.core.windows.net/cont/a.txt")
+	c.Assert(bucketOf("https://acct.blob.core.windows.net/cont"), chk.Equals, "acct.blob.core.windows.net/cont/")
+	c.Assert(bucketOf("https://acct.blob.core.windows.net/other/2020-05-01/a.txt"), chk.Not(chk.Equals), bucketOf("https://acct.blob.core.windows.net/cont/2020-05-01/a.txt"))
+}
+
+func (s *partitionThrottleSuite) TestCooldownGrowsWhileThePartitionStaysBusy(c *chk.C) {
+	t := newPartitionThrottle()
+	start := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	bucket := "acct/cont/hot"
+	isHot := func(at time.Time) bool {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		b, ok := t.buckets[bucket]
+		return ok && at.Before(b.hotUntil)
+	}
+
+	t.recordResponse(bucket, http.StatusCreated, start)
+	c.Assert(t.buckets, chk.HasLen, 0)
+
+	t.recordResponse(bucket, http.StatusServiceUnavailable, start)
+	c.Assert(isHot(start.Add(partitionBaseCooldown-time.Millisecond)), chk.Equals, true)
+	c.Assert(isHot(start.Add(partitionBaseCooldown)), chk.Equals, false)
+
+	// the responses to the requests that were already in flight count, but don't make it hot for longer
+	t.recordResponse(bucket, http.StatusServiceUnavailable, start.Add(time.Second))
+	c.Assert(isHot(start.Add(partitionBaseCooldown)), chk.Equals, false)
+	c.Assert(t.throttleEvents(), chk.Equals, uint64(2))
+
+	// still busy after cooling down, so it's hot for twice as long
+	again := start.Add(3 * time.Second)
+	t.recordResponse(bucket, http.StatusServiceUnavailable, again)
+	c.Assert(isHot(again.Add(2*partitionBaseCooldown-time.Millisecond)), chk.Equals, true)
+	c.Assert(isHot(again.Add(2*partitionBaseCooldown)), chk.Equals, false)
+
+	// once it answers again, it's forgotten as soon as it cools down
+	t.recordResponse(bucket, http.StatusCreated, again.Add(time.Second))
+	c.Assert(t.releaseCooled(again.Add(time.Second)), chk.HasLen, 0)
+	c.Assert(t.buckets, chk.HasLen, 1)
+	t.releaseCooled(again.Add(2 * partitionBaseCooldown))
+	c.Assert(t.buckets, chk.HasLen, 0)
+}
+
+func (s *partitionThrottleSuite) TestOtherPartitionsKeepMovingWhileOneIsThrottled(c *chk.C) {
+	t := newPartitionThrottle()
+	clock := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	t.now = func() time.Time { return clock }
+	hotRecovered := false
+
+	// the service is busy for the names that start with "hot", until it recovers
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			status := http.StatusCreated
+			if strings.HasPrefix(request.URL.Path, "/cont/hot") && !hotRecovered {
+				status = http.StatusServiceUnavailable
+			}
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: status, Header: http.Header{}}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), newPartitionThrottlePolicyFactory(t)}, pipeline.Options{HTTPSender: sender})
+
+	// a chunk processor that runs the chunks in the order they're scheduled
+	queue := make([]chunkFunc, 0)
+	schedule := func(chunk chunkFunc) { queue = append(queue, chunk) }
+	runQueue := func() {
+		for len(queue) > 0 {
+			chunk := queue[0]
+			queue = queue[1:]
+			chunk(0)
+		}
+	}
+
+	written := make([]string, 0)
+	names := []string{"hot-2020/a", "hot-2020/b", "cold-one/a", "hot-2020/c", "cold-two/a", "cold-one/b"}
+	for _, name := range names {
+		name := name
+		u, _ := url.Parse("https://acct.blob.core.windows.net/cont/" + name)
+		chunk := func(int) {
+			request, err := pipeline.NewRequest(http.MethodPut, *u, nil)
+			c.Assert(err, chk.IsNil)
+			resp, err := p.Do(context.Background(), nil, request)
+			c.Assert(err, chk.IsNil)
+			if resp.Response().StatusCode == http.StatusCreated {
+				written = append(written, name)
+			}
+		}
+		schedule(t.deferWhileHot(partitionBucketOf(u), chunk, schedule))
+	}
+
+	// the first chunk of the hot partition got a ServerBusy, and the rest of them were set aside for the others
+	runQueue()
+	c.Assert(written, chk.DeepEquals, []string{"cold-one/a", "cold-two/a", "cold-one/b"})
+	c.Assert(t.throttleEvents(), chk.Equals, uint64(1))
+	c.Assert(t.deferredChunks(), chk.Equals, uint64(2))
+	clock = clock.Add(partitionBaseCooldown - time.Millisecond)
+	c.Assert(t.releaseCooled(clock), chk.HasLen, 0) // still hot
+
+	// they're scheduled again once it cools down
+	hotRecovered = true
+	clock = clock.Add(time.Millisecond)
+	for _, d := range t.releaseCooled(clock) {
+		d.schedule(d.chunk)
+	}
+	runQueue()
+	c.Assert(written, chk.DeepEquals, []string{"cold-one/a", "cold-two/a", "cold-one/b", "hot-2020/b", "hot-2020/c"})
+}
@@ -0,0 +1,150 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bytes"
+	"crypto/aes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type planEncryptionSuite struct{}
+
+var _ = chk.Suite(&planEncryptionSuite{})
+
+// usePlanKey makes key the current key, and returns what restores the one before it
+func usePlanKey(key *planKey) (restore func()) {
+	planKeyOnce.Do(func() {})
+	previous := currentPlanKeyValue
+	currentPlanKeyValue = key
+	return func() { currentPlanKeyValue = previous }
+}
+
+func (s *planEncryptionSuite) writePlan(c *chk.C, key *planKey) (planPath string, mmf *JobPartPlanMMF) {
+	dir, err := ioutil.TempDir("", "planEncryption")
+	c.Assert(err, chk.IsNil)
+	planPath = filepath.Join(dir, "plan.steV1")
+
+	createJobPartPlanFile(planPath, common.CopyJobPartOrderRequest{
+		JobID:           common.NewJobID(),
+		FromTo:          common.EFromTo.LocalBlob(),
+		CommandString:   "copy /home/user/payroll https://account.blob.core.windows.net/container",
+		SourceRoot:      "/home/user/payroll",
+		DestinationRoot: "https://account.blob.core.windows.net/container",
+		ListingMarker:   "/account/container/payroll/salaries-2021.csv",
+		Transfers: []common.CopyTransfer{
+			{Source: "/salaries-2020.csv", Destination: "/salaries-2020.csv", LastModifiedTime: time.Now(), SourceSize: 10, ContentType: "text/csv"},
+			{Source: "/bonuses.csv", Destination: "/bonuses.csv", LastModifiedTime: time.Now(), SourceSize: 20,
+				Metadata: common.Metadata{"owner": "finance"}},
+		},
+	}, key)
+
+	file, err := os.Open(planPath)
+	c.Assert(err, chk.IsNil)
+	defer file.Close()
+	info, err := file.Stat()
+	c.Assert(err, chk.IsNil)
+	m, err := common.NewMMF(file, false, 0, info.Size())
+	c.Assert(err, chk.IsNil)
+	return planPath, (*JobPartPlanMMF)(m)
+}
+
+func (s *planEncryptionSuite) assertPlanStrings(c *chk.C, plan *JobPartPlanHeader) {
+	c.Assert(plan.CommandString(), chk.Equals, "copy /home/user/payroll https://account.blob.core.windows.net/container")
+	c.Assert(plan.ListingMarkerReached(), chk.Equals, "/account/container/payroll/salaries-2021.csv")
+
+	src, dst := plan.TransferSrcDstStrings(0)
+	c.Assert(src, chk.Equals, "/home/user/payroll/salaries-2020.csv")
+	c.Assert(dst, chk.Equals, "https://account.blob.core.windows.net/container/salaries-2020.csv")
+	h, _, _, _, _, _, _, _ := plan.TransferSrcPropertiesAndMetadata(0)
+	c.Assert(h.ContentType, chk.Equals, "text/csv")
+
+	src, _ = plan.TransferSrcDstStrings(1)
+	c.Assert(src, chk.Equals, "/home/user/payroll/bonuses.csv")
+	_, metadata, _, _, _, _, _, _ := plan.TransferSrcPropertiesAndMetadata(1)
+	c.Assert(metadata, chk.DeepEquals, common.Metadata{"owner": "finance"})
+}
+
+func (s *planEncryptionSuite) TestPlainPlansAreReadAsBefore(c *chk.C) {
+	defer usePlanKey(nil)()
+
+	planPath, mmf := s.writePlan(c, nil)
+	defer os.RemoveAll(filepath.Dir(planPath))
+	defer mmf.Unmap()
+
+	c.Assert(mmf.Plan().StringsEncrypted, chk.Equals, false)
+	c.Assert(mmf.Plan().CheckPlanKey(), chk.IsNil)
+	s.assertPlanStrings(c, mmf.Plan())
+
+	// and the key is no use to them, nor needed
+	defer usePlanKey(newPlanKey("secret"))()
+	c.Assert(mmf.Plan().CheckPlanKey(), chk.IsNil)
+	s.assertPlanStrings(c, mmf.Plan())
+}
+
+func (s *planEncryptionSuite) TestEncryptedPlansAreOnlyReadWithTheirKey(c *chk.C) {
+	key := newPlanKey("secret")
+	defer usePlanKey(key)()
+
+	planPath, mmf := s.writePlan(c, key)
+	defer os.RemoveAll(filepath.Dir(planPath))
+	defer mmf.Unmap()
+
+	raw, err := ioutil.ReadFile(planPath)
+	c.Assert(err, chk.IsNil)
+	for _, secret := range []string{"payroll", "account.blob", "salaries", "bonuses", "text/csv", "finance"} {
+		c.Assert(bytes.Contains(raw, []byte(secret)), chk.Equals, false, chk.Commentf(secret))
+	}
+
+	c.Assert(mmf.Plan().StringsEncrypted, chk.Equals, true)
+	c.Assert(mmf.Plan().CheckPlanKey(), chk.IsNil)
+	s.assertPlanStrings(c, mmf.Plan())
+
+	usePlanKey(nil)
+	c.Assert(mmf.Plan().CheckPlanKey(), chk.Equals, errPlanKeyRequired)
+	c.Assert(strings.HasPrefix(errPlanKeyRequired.Error(), "encrypted plan, key required"), chk.Equals, true)
+	c.Assert(func() { mmf.Plan().CommandString() }, chk.PanicMatches, "encrypted plan, key required.*")
+
+	usePlanKey(newPlanKey("another secret"))
+	c.Assert(mmf.Plan().CheckPlanKey(), chk.Equals, errPlanKeyMismatch)
+}
+
+func (s *planEncryptionSuite) TestKeyStreamCanStartAtAnyOffset(c *chk.C) {
+	key := newPlanKey("secret")
+	// an IV whose low half is about to wrap around, so that the counter carries into the high half
+	iv := [aes.BlockSize]byte{0, 0, 0, 0, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}
+
+	whole := make([]byte, 100)
+	key.streamAt(iv, 0).XORKeyStream(whole, whole)
+
+	for _, offset := range []int64{1, 15, 16, 17, 33, 64, 99} {
+		part := make([]byte, 100-offset)
+		key.streamAt(iv, offset).XORKeyStream(part, part)
+		c.Assert(part, chk.DeepEquals, whole[offset:], chk.Commentf("offset %d", offset))
+	}
+}
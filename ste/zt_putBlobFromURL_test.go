@@ -0,0 +1,141 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	chk "gopkg.in/check.v1"
+)
+
+type putBlobFromURLSuite struct{}
+
+var _ = chk.Suite(&putBlobFromURLSuite{})
+
+// newPutBlobFromURLPipeline returns a pipeline that answers every request with the given status, error code and headers, and records the requests
+func newPutBlobFromURLPipeline(requests *[]*http.Request, status int, errorCode string, header http.Header) pipeline.Pipeline {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			*requests = append(*requests, request.Request)
+			respHeader := http.Header{}
+			for k, v := range header {
+				respHeader[k] = v
+			}
+			if errorCode != "" {
+				respHeader.Set("x-ms-error-code", errorCode)
+			}
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: status, Status: http.StatusText(status), Header: respHeader,
+				Body: ioutil.NopCloser(strings.NewReader("")), Request: request.Request}), nil
+		}
+	})
+	return pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+}
+
+func (s *putBlobFromURLSuite) TestPutBlobFromURLCarriesThePropertiesInOneRequest(c *chk.C) {
+	requests := make([]*http.Request, 0)
+	crc := base64.StdEncoding.EncodeToString([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	p := newPutBlobFromURLPipeline(&requests, http.StatusCreated, "", http.Header{"X-Ms-Content-Crc64": []string{crc}})
+
+	destURL, _ := url.Parse("https://dst.blob.core.windows.net/cont/small.txt")
+	srcURL, _ := url.Parse("https://src.blob.core.windows.net/cont/small.txt?sig=secret")
+	headers := azblob.BlobHTTPHeaders{ContentType: "text/plain", CacheControl: "no-cache"}
+	err := putBlobFromURL(context.Background(), p, *destURL, *srcURL, headers, azblob.Metadata{"owner": "ops"},
+		azblob.AccessTierCool, common.BlobTags{"b": "2", "a": "1 1"}, "")
+	c.Assert(err, chk.IsNil)
+
+	c.Assert(requests, chk.HasLen, 1) // rather than a Put Block From URL and a Put Block List
+	req := requests[0]
+	c.Assert(req.Method, chk.Equals, http.MethodPut)
+	c.Assert(req.URL.String(), chk.Equals, destURL.String())
+	c.Assert(req.Header.Get("x-ms-copy-source"), chk.Equals, srcURL.String())
+	c.Assert(req.Header.Get("x-ms-blob-type"), chk.Equals, "BlockBlob")
+	c.Assert(req.Header.Get("x-ms-blob-content-type"), chk.Equals, "text/plain")
+	c.Assert(req.Header.Get("x-ms-blob-cache-control"), chk.Equals, "no-cache")
+	c.Assert(req.Header.Get("x-ms-blob-content-md5"), chk.Equals, "")
+	c.Assert(req.Header.Get("x-ms-meta-owner"), chk.Equals, "ops")
+	c.Assert(req.Header.Get("x-ms-access-tier"), chk.Equals, "Cool")
+	c.Assert(req.Header.Get("x-ms-tags"), chk.Equals, "a=1+1&b=2")
+}
+
+func (s *putBlobFromURLSuite) TestHashesThatTheServiceReturnsAreChecked(c *chk.C) {
+	content := md5.Sum([]byte("content"))
+	other := md5.Sum([]byte("other"))
+	header := func(crc string, contentMD5 []byte) http.Header {
+		h := http.Header{}
+		if crc != "" {
+			h.Set("x-ms-content-crc64", crc)
+		}
+		if contentMD5 != nil {
+			h.Set("Content-MD5", base64.StdEncoding.EncodeToString(contentMD5))
+		}
+		return h
+	}
+	crc := base64.StdEncoding.EncodeToString([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	c.Assert(checkPutBlobFromURLResponse(header("", nil), content[:]), chk.IsNil)
+	c.Assert(checkPutBlobFromURLResponse(header(crc, content[:]), content[:]), chk.IsNil)
+	c.Assert(checkPutBlobFromURLResponse(header(crc, content[:]), nil), chk.IsNil) // nothing to compare with
+	c.Assert(checkPutBlobFromURLResponse(header("AQID", nil), nil), chk.NotNil)
+	c.Assert(checkPutBlobFromURLResponse(header(crc, other[:]), content[:]), chk.NotNil)
+}
+
+func (s *putBlobFromURLSuite) TestUnsupportedPutBlobFromURLIsToldFromFailedCopies(c *chk.C) {
+	destURL, _ := url.Parse("https://dst.blob.core.windows.net/cont/small.txt")
+	srcURL, _ := url.Parse("https://src.blob.core.windows.net/cont/small.txt")
+	putWith := func(status int, errorCode string) error {
+		requests := make([]*http.Request, 0)
+		p := newPutBlobFromURLPipeline(&requests, status, errorCode, nil)
+		return putBlobFromURL(context.Background(), p, *destURL, *srcURL, azblob.BlobHTTPHeaders{}, nil, azblob.AccessTierNone, nil, "")
+	}
+
+	// the blob is then copied as a block
+	err := putWith(http.StatusBadRequest, string(azblob.ServiceCodeInvalidHeaderValue))
+	c.Assert(isPutBlobFromURLUnsupported(err), chk.Equals, true)
+	c.Assert(isPutBlobFromURLUnsupported(putWith(http.StatusNotImplemented, "")), chk.Equals, true)
+
+	// and the errors that the SDK's would be are still recognised, e.g. by the relay
+	serviceCode, status, _ := ErrorEx{err}.ErrorCodeAndString()
+	c.Assert(serviceCode, chk.Equals, string(azblob.ServiceCodeInvalidHeaderValue))
+	c.Assert(status, chk.Equals, http.StatusBadRequest)
+
+	// but the copy has failed for these
+	for _, failure := range []struct {
+		status int
+		code   string
+	}{
+		{http.StatusForbidden, string(azblob.ServiceCodeAuthenticationFailed)},
+		{http.StatusNotFound, string(azblob.ServiceCodeBlobNotFound)},
+		{http.StatusConflict, string(azblob.ServiceCodeLeaseIDMissing)},
+	} {
+		err := putWith(failure.status, failure.code)
+		c.Assert(err, chk.NotNil)
+		c.Assert(isPutBlobFromURLUnsupported(err), chk.Equals, false)
+	}
+}
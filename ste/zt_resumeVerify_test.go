@@ -0,0 +1,99 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"crypto/md5"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type resumeVerifySuite struct{}
+
+var _ = chk.Suite(&resumeVerifySuite{})
+
+func (s *resumeVerifySuite) TestSucceededTransfersAreCopiedAgainIfTheirDestinationIsWrong(c *chk.C) {
+	success := common.ETransferStatus.Success()
+	started := common.ETransferStatus.Started()
+	jobStart := time.Now().Add(-time.Hour)
+	written := destinationState{exists: true, length: 10, lastModified: time.Now(), md5: []byte{1}}
+
+	c.Assert(correctedTransferStatus(success, 10, true, nil, written, jobStart, false), chk.Equals, success)
+	c.Assert(correctedTransferStatus(success, 10, true, nil, destinationState{}, jobStart, false), chk.Equals, started)
+	c.Assert(correctedTransferStatus(success, 11, true, nil, written, jobStart, false), chk.Equals, started)
+
+	// the length of a decompressed download isn't that of the source
+	c.Assert(correctedTransferStatus(success, 11, false, nil, written, jobStart, false), chk.Equals, success)
+
+	// the hashes are only compared when both are known
+	c.Assert(correctedTransferStatus(success, 10, true, []byte{2}, written, jobStart, true), chk.Equals, started)
+	c.Assert(correctedTransferStatus(success, 10, true, []byte{2}, destinationState{exists: true, length: 10}, jobStart, true), chk.Equals, success)
+}
+
+func (s *resumeVerifySuite) TestFailedTransfersAreDoneIfTheJobWroteTheirDestination(c *chk.C) {
+	failed := common.ETransferStatus.Failed()
+	success := common.ETransferStatus.Success()
+	jobStart := time.Now().Add(-time.Hour)
+	written := destinationState{exists: true, length: 10, lastModified: time.Now(), md5: []byte{1}}
+
+	c.Assert(correctedTransferStatus(failed, 10, true, nil, written, jobStart, false), chk.Equals, success)
+	c.Assert(correctedTransferStatus(failed, 10, true, nil, destinationState{}, jobStart, false), chk.Equals, failed)
+	c.Assert(correctedTransferStatus(failed, 9, true, nil, written, jobStart, false), chk.Equals, failed)
+
+	// what was there before the job started isn't its work
+	before := written
+	before.lastModified = jobStart.Add(-time.Hour)
+	c.Assert(correctedTransferStatus(failed, 10, true, nil, before, jobStart, false), chk.Equals, failed)
+
+	// strictly, the hashes must be known, and match
+	c.Assert(correctedTransferStatus(failed, 10, true, nil, written, jobStart, true), chk.Equals, failed)
+	c.Assert(correctedTransferStatus(failed, 10, true, []byte{2}, written, jobStart, true), chk.Equals, failed)
+	c.Assert(correctedTransferStatus(failed, 10, true, []byte{1}, written, jobStart, true), chk.Equals, success)
+}
+
+func (s *resumeVerifySuite) TestLocalDestinationsAreStatted(c *chk.C) {
+	dir, err := ioutil.TempDir("", "resumeVerify")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	c.Assert(ioutil.WriteFile(path, []byte("content"), 0666), chk.IsNil)
+
+	state, err := checkLocalDestination(context.Background(), path, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(state.exists, chk.Equals, true)
+	c.Assert(state.length, chk.Equals, int64(7))
+	c.Assert(state.md5, chk.IsNil)
+
+	state, err = checkLocalDestination(context.Background(), path, true)
+	c.Assert(err, chk.IsNil)
+	hash := md5.Sum([]byte("content"))
+	c.Assert(state.md5, chk.DeepEquals, hash[:])
+
+	state, err = checkLocalDestination(context.Background(), filepath.Join(dir, "missing"), false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(state.exists, chk.Equals, false)
+}
@@ -0,0 +1,156 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type retryAfterSuite struct{}
+
+var _ = chk.Suite(&retryAfterSuite{})
+
+// throttledError is what the scripted sender returns with a throttled response, in place of the StorageError of the real pipeline
+type throttledError struct{}
+
+func (throttledError) Error() string   { return "server busy" }
+func (throttledError) Timeout() bool   { return false }
+func (throttledError) Temporary() bool { return true }
+
+func throttledResponse(status int, header, value string) *http.Response {
+	r := &http.Response{StatusCode: status, Header: http.Header{}, Body: http.NoBody}
+	r.Header.Set(header, value)
+	return r
+}
+
+// scriptedSender returns the given responses in turn, with an error for each that isn't a success
+func scriptedSender(responses ...*http.Response) pipeline.Factory {
+	next := 0
+	return pipeline.FactoryFunc(func(_ pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			r := responses[next]
+			next++
+			if r.StatusCode >= 300 {
+				return pipeline.NewHTTPResponse(r), throttledError{}
+			}
+			return pipeline.NewHTTPResponse(r), nil
+		}
+	})
+}
+
+// recordSleeps replaces the waits of the retry policies with a record of them, until the returned func is called
+func recordSleeps() (sleeps *[]time.Duration, restore func()) {
+	sleeps = &[]time.Duration{}
+	original := sleepBeforeRetry
+	sleepBeforeRetry = func(d time.Duration) { *sleeps = append(*sleeps, d) }
+	return sleeps, func() { sleepBeforeRetry = original }
+}
+
+func sendThrough(c *chk.C, factories []pipeline.Factory, sender pipeline.Factory) {
+	p := pipeline.NewPipeline(factories, pipeline.Options{HTTPSender: sender})
+	u, _ := url.Parse("https://account.blob.core.windows.net/c/b")
+	req, err := pipeline.NewRequest(http.MethodPut, *u, nil)
+	c.Assert(err, chk.IsNil)
+	_, _ = p.Do(context.Background(), nil, req)
+}
+
+func (s *retryAfterSuite) TestServerDirectedDelayReadsTheHeaders(c *chk.C) {
+	c.Assert(serverDirectedDelay(throttledResponse(http.StatusServiceUnavailable, "x-ms-retry-after-ms", "1500")), chk.Equals, 1500*time.Millisecond)
+	c.Assert(serverDirectedDelay(throttledResponse(http.StatusTooManyRequests, "Retry-After", "3")), chk.Equals, 3*time.Second)
+	c.Assert(serverDirectedDelay(throttledResponse(http.StatusServiceUnavailable, "Retry-After", "soon")), chk.Equals, time.Duration(0))
+
+	// the milliseconds are preferred, since they are more precise
+	r := throttledResponse(http.StatusServiceUnavailable, "Retry-After", "3")
+	r.Header.Set("x-ms-retry-after-ms", "250")
+	c.Assert(serverDirectedDelay(r), chk.Equals, 250*time.Millisecond)
+
+	// an HTTP date is taken as the time to wait until
+	at := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	delay := serverDirectedDelay(throttledResponse(http.StatusServiceUnavailable, "Retry-After", at))
+	c.Assert(delay > 8*time.Second && delay <= 10*time.Second, chk.Equals, true)
+
+	// only throttling responses are waited for
+	c.Assert(serverDirectedDelay(throttledResponse(http.StatusInternalServerError, "Retry-After", "3")), chk.Equals, time.Duration(0))
+
+	// long waits are capped
+	getMaxRetryAfter()
+	original := maxRetryAfter
+	maxRetryAfter = 5 * time.Second
+	defer func() { maxRetryAfter = original }()
+	c.Assert(serverDirectedDelay(throttledResponse(http.StatusServiceUnavailable, "Retry-After", "3600")), chk.Equals, 5*time.Second)
+}
+
+func (s *retryAfterSuite) TestRetryPoliciesWaitAsLongAsTheServiceAsks(c *chk.C) {
+	o := XferRetryOptions{Policy: RetryPolicyExponential, MaxTries: 4, RetryDelay: time.Millisecond, MaxRetryDelay: 10 * time.Millisecond}
+
+	for _, retryPolicy := range []pipeline.Factory{NewBlobXferRetryPolicyFactory(o), NewBFSXferRetryPolicyFactory(o)} {
+		sleeps, restore := recordSleeps()
+		sendThrough(c, []pipeline.Factory{retryPolicy}, scriptedSender(
+			throttledResponse(http.StatusServiceUnavailable, "x-ms-retry-after-ms", "1500"),
+			throttledResponse(http.StatusTooManyRequests, "Retry-After", "2"),
+			throttledResponse(http.StatusServiceUnavailable, "x-ms-other", ""), // says nothing, so the policy's own delay is used
+			&http.Response{StatusCode: http.StatusCreated, Header: http.Header{}, Body: http.NoBody}))
+		restore()
+
+		c.Assert(*sleeps, chk.HasLen, 4)
+		c.Assert((*sleeps)[0], chk.Equals, time.Duration(0)) // the first try doesn't wait
+		c.Assert((*sleeps)[1], chk.Equals, 1500*time.Millisecond)
+		c.Assert((*sleeps)[2], chk.Equals, 2*time.Second)
+		c.Assert((*sleeps)[3] <= o.MaxRetryDelay, chk.Equals, true)
+	}
+}
+
+func (s *retryAfterSuite) TestFilePipelineWaitsAfterTheThrottledResponse(c *chk.C) {
+	sleeps, restore := recordSleeps()
+	defer restore()
+
+	sendThrough(c, []pipeline.Factory{newRetryAfterPolicyFactory()}, scriptedSender(
+		throttledResponse(http.StatusServiceUnavailable, "Retry-After", "4")))
+	sendThrough(c, []pipeline.Factory{newRetryAfterPolicyFactory()}, scriptedSender(
+		&http.Response{StatusCode: http.StatusCreated, Header: http.Header{}, Body: http.NoBody}))
+
+	c.Assert(*sleeps, chk.DeepEquals, []time.Duration{4 * time.Second})
+}
+
+func (s *retryAfterSuite) TestServerDirectedWaitsAreCountedAndPassedToTheTuner(c *chk.C) {
+	tuner := NewAutoConcurrencyTuner(4, 64, false).(*autoConcurrencyTuner)
+	stats := newPipelineNetworkStats(tuner)
+
+	for _, r := range []*http.Response{
+		throttledResponse(http.StatusServiceUnavailable, "x-ms-retry-after-ms", "300"),
+		throttledResponse(http.StatusServiceUnavailable, "Retry-After", "2"),
+		throttledResponse(http.StatusServiceUnavailable, "x-ms-other", ""), // a plain ServerBusy isn't a server-directed wait
+	} {
+		sendThrough(c, []pipeline.Factory{newXferStatsPolicyFactory(stats)}, scriptedSender(r))
+	}
+
+	count, total := stats.ServerDirectedWaits()
+	c.Assert(count, chk.Equals, int64(2))
+	c.Assert(total, chk.Equals, 2300*time.Millisecond)
+	c.Assert(tuner.takeLongestServerDirectedWait(), chk.Equals, 2*time.Second)
+	c.Assert(tuner.takeLongestServerDirectedWait(), chk.Equals, time.Duration(0)) // until the service asks again
+}
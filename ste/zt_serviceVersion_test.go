@@ -0,0 +1,90 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+)
+
+type serviceVersionSuite struct{}
+
+var _ = chk.Suite(&serviceVersionSuite{})
+
+// pinRequestAPIVersion pins the requests to the version, and returns what unpins them
+func pinRequestAPIVersion(version string) (restore func()) {
+	previous := RequestAPIVersion
+	RequestAPIVersion = version
+	return func() { RequestAPIVersion = previous }
+}
+
+func (s *serviceVersionSuite) TestPinnedVersionWinsOverTheVersionsThatFeaturesAskFor(c *chk.C) {
+	var sent string
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			sent = request.Header.Get("x-ms-version")
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{NewVersionPolicyFactory(), newRequestAPIVersionPolicyFactory()}, pipeline.Options{HTTPSender: sender})
+	u, _ := url.Parse("https://account.blob.core.windows.net/container/blob")
+	ctx := context.WithValue(context.Background(), ServiceAPIVersionOverride, blobTagsServiceVersion)
+
+	do := func() string {
+		req, err := pipeline.NewRequest(http.MethodHead, *u, nil)
+		c.Assert(err, chk.IsNil)
+		_, err = p.Do(ctx, nil, req)
+		c.Assert(err, chk.IsNil)
+		return sent
+	}
+
+	c.Assert(do(), chk.Equals, blobTagsServiceVersion)
+
+	defer pinRequestAPIVersion("2017-11-09")()
+	c.Assert(do(), chk.Equals, "2017-11-09")
+}
+
+func (s *serviceVersionSuite) TestFeaturesAreOnlySupportedFromTheirVersion(c *chk.C) {
+	c.Assert(ServiceVersionSupports(azbfs.AppendAndFlushServiceVersion), chk.Equals, true)
+	c.Assert(FeatureBlobTags.Supported(), chk.Equals, true)
+
+	defer pinRequestAPIVersion("2019-12-12")()
+	c.Assert(FeatureBlobTags.Supported(), chk.Equals, true)
+	c.Assert(FeatureCopySourceOAuth.Supported(), chk.Equals, false)
+	c.Assert(FeatureCopySourceOAuth.DisabledWarning(), chk.Equals, "Reading the source of a copy with an OAuth token is turned off, "+
+		"since it needs service version 2020-10-02 or later, and AZCOPY_REQUEST_API_VERSION pins the requests to version 2019-12-12.")
+
+	// and what's only used to go faster is simply not used
+	c.Assert(isSmallBlobFSFile(1024, 1, 0, false), chk.Equals, false)
+}
+
+func (s *serviceVersionSuite) TestPinnedVersionMustBeADate(c *chk.C) {
+	c.Assert(ValidateRequestAPIVersion(""), chk.IsNil)
+	c.Assert(ValidateRequestAPIVersion("2019-02-02"), chk.IsNil)
+	c.Assert(ValidateRequestAPIVersion("2019-2-2"), chk.NotNil)
+	c.Assert(ValidateRequestAPIVersion("latest"), chk.NotNil)
+}
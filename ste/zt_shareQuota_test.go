@@ -0,0 +1,168 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+)
+
+type shareQuotaSuite struct{}
+
+var _ = chk.Suite(&shareQuotaSuite{})
+
+// fakeShareUsage answers the lookups of the usage of a share with the given quotas in turn, the last one from then on
+type fakeShareUsage struct {
+	usedBytes int64
+	quotas    []int32
+	lookups   int
+}
+
+func (f *fakeShareUsage) lookUp(ctx context.Context, p pipeline.Pipeline, shareURL url.URL) (int64, int32, error) {
+	f.lookups++
+	if len(f.quotas) == 0 {
+		return 0, 0, errors.New("forbidden")
+	}
+	quota := f.quotas[0]
+	if len(f.quotas) > 1 {
+		f.quotas = f.quotas[1:]
+	}
+	return f.usedBytes, quota, nil
+}
+
+func useFakeShareUsage(usage *fakeShareUsage) func() {
+	original, originalInterval := shareUsage, shareQuotaRecheckInterval
+	shareUsage, shareQuotaRecheckInterval = usage.lookUp, time.Millisecond
+	return func() { shareUsage, shareQuotaRecheckInterval = original, originalInterval }
+}
+
+func mustParseURL(c *chk.C, raw string) url.URL {
+	u, err := url.Parse(raw)
+	c.Assert(err, chk.IsNil)
+	return *u
+}
+
+func (s *shareQuotaSuite) TestSharesAreKnownByTheirURLWithoutSAS(c *chk.C) {
+	name, shareURL := shareOf(mustParseURL(c, "https://acct.file.core.windows.net/share/dir/file.txt?sv=2019-02-02&sig=secret"))
+	c.Assert(name, chk.Equals, "https://acct.file.core.windows.net/share")
+	c.Assert(shareURL.Path, chk.Equals, "/share")
+	c.Assert(shareURL.Query().Get("sig"), chk.Equals, "secret")
+}
+
+func (s *shareQuotaSuite) TestFullShareFailsTheTransfersToItWithoutAWait(c *chk.C) {
+	usage := &fakeShareUsage{usedBytes: 100 * bytesPerGiB, quotas: []int32{100}}
+	defer useFakeShareUsage(usage)()
+	logged := make([]string, 0)
+	guard := newShareQuotaGuard(func(msg string) { logged = append(logged, msg) })
+	file := mustParseURL(c, "https://acct.file.core.windows.net/share/dir/file.txt")
+	otherShare := mustParseURL(c, "https://acct.file.core.windows.net/other/file.txt")
+
+	c.Assert(guard.waitWhileFull(context.Background(), nil, file, 0), chk.IsNil)
+
+	// the share is looked up and logged once, however many writes find it full
+	guard.markFull(context.Background(), nil, file)
+	guard.markFull(context.Background(), nil, mustParseURL(c, "https://acct.file.core.windows.net/share/another.txt"))
+	c.Assert(usage.lookups, chk.Equals, 1)
+	c.Assert(logged, chk.HasLen, 1)
+	c.Assert(strings.HasPrefix(logged[0], "Share full: https://acct.file.core.windows.net/share has used 100.0 of its 100 GiB quota."), chk.Equals, true)
+
+	err := guard.waitWhileFull(context.Background(), nil, file, 0)
+	c.Assert(isShareFull(err), chk.Equals, true)
+	c.Assert(guard.waitWhileFull(context.Background(), nil, otherShare, 0), chk.IsNil)
+
+	shares := guard.fullShares()
+	c.Assert(shares, chk.HasLen, 1)
+	c.Assert(shares[0].Share, chk.Equals, "https://acct.file.core.windows.net/share")
+	c.Assert(shares[0].QuotaGiB, chk.Equals, int32(100))
+
+	var nilGuard *shareQuotaGuard
+	nilGuard.markFull(context.Background(), nil, file)
+	c.Assert(nilGuard.waitWhileFull(context.Background(), nil, file, 0), chk.IsNil)
+	c.Assert(nilGuard.fullShares(), chk.IsNil)
+}
+
+func (s *shareQuotaSuite) TestTransfersCarryOnOnceTheQuotaIsRaised(c *chk.C) {
+	usage := &fakeShareUsage{usedBytes: 100 * bytesPerGiB, quotas: []int32{100, 100, 200}}
+	defer useFakeShareUsage(usage)()
+	logged := make([]string, 0)
+	guard := newShareQuotaGuard(func(msg string) { logged = append(logged, msg) })
+	file := mustParseURL(c, "https://acct.file.core.windows.net/share/file.txt")
+
+	guard.markFull(context.Background(), nil, file)
+	c.Assert(guard.waitWhileFull(context.Background(), nil, file, time.Minute), chk.IsNil)
+	c.Assert(usage.lookups, chk.Equals, 3)
+	c.Assert(guard.fullShares(), chk.HasLen, 0)
+	c.Assert(logged[len(logged)-1], chk.Equals, "Carrying on with the transfers to https://acct.file.core.windows.net/share, which has used 100.0 of its 200 GiB quota now")
+
+	// without a raise, the transfers wait until the window is over
+	usage.quotas = []int32{200}
+	guard.markFull(context.Background(), nil, file)
+	c.Assert(isShareFull(guard.waitWhileFull(context.Background(), nil, file, 20*time.Millisecond)), chk.Equals, true)
+}
+
+func (s *shareQuotaSuite) TestWriteThatFindsTheShareFullWaitsForRoom(c *chk.C) {
+	usage := &fakeShareUsage{usedBytes: 100 * bytesPerGiB, quotas: []int32{100, 200}}
+	defer useFakeShareUsage(usage)()
+
+	// without a wait, the write fails, and the share is marked full
+	jptm := &azureFileWriteRetriesTransferMgr{shareQuota: newShareQuotaGuard(func(string) {})}
+	sender := newSenderForWriteRetries(jptm, &lockedAzureFileService{attributes: "Archive", fullForWrites: 1})
+	c.Assert(isShareFull(sender.writeWithRetries(createFile(sender))), chk.Equals, true)
+	c.Assert(jptm.shareQuota.fullShares(), chk.HasLen, 1)
+
+	// with a wait, the write is retried once the quota is raised
+	usage.quotas = []int32{100, 200}
+	jptm = &azureFileWriteRetriesTransferMgr{shareQuota: newShareQuotaGuard(func(string) {}), shareFullWait: time.Minute}
+	service := &lockedAzureFileService{attributes: "Archive", fullForWrites: 1}
+	sender = newSenderForWriteRetries(jptm, service)
+	c.Assert(sender.writeWithRetries(createFile(sender)), chk.IsNil)
+	c.Assert(service.fullForWrites, chk.Equals, 0)
+	c.Assert(jptm.shareQuota.fullShares(), chk.HasLen, 0)
+}
+
+func (s *shareQuotaSuite) TestUsageOfLargeSharesIsLookedUp(c *chk.C) {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			header, body := http.Header{}, ""
+			if request.URL.Query().Get("comp") == "stats" {
+				body = "<?xml version=\"1.0\" encoding=\"utf-8\"?><ShareStats><ShareUsageBytes>5368709120</ShareUsageBytes></ShareStats>"
+			} else {
+				header.Set("x-ms-share-quota", "5")
+			}
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Status: http.StatusText(http.StatusOK), Header: header,
+				Body: ioutil.NopCloser(strings.NewReader(body)), Request: request.Request}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	used, quota, err := getShareUsage(context.Background(), p, mustParseURL(c, "https://acct.file.core.windows.net/share"))
+	c.Assert(err, chk.IsNil)
+	c.Assert(used, chk.Equals, int64(5*bytesPerGiB))
+	c.Assert(quota, chk.Equals, int32(5))
+}
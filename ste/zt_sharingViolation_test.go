@@ -0,0 +1,235 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package ste
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-file-go/azfile"
+	chk "gopkg.in/check.v1"
+)
+
+type sharingViolationSuite struct{}
+
+var _ = chk.Suite(&sharingViolationSuite{})
+
+// azureFileWriteRetriesTransferMgr answers how the writes to the destination are retried, and counts the retried files
+type azureFileWriteRetriesTransferMgr struct {
+	IJobPartTransferMgr
+	window          time.Duration
+	forceIfReadOnly bool
+	retriedFiles    int
+	shareQuota      *shareQuotaGuard
+	shareFullWait   time.Duration
+}
+
+func (t *azureFileWriteRetriesTransferMgr) Context() context.Context { return context.Background() }
+func (t *azureFileWriteRetriesTransferMgr) SharingViolationRetryWindow() time.Duration {
+	return t.window
+}
+func (t *azureFileWriteRetriesTransferMgr) ForceIfReadOnly() bool        { return t.forceIfReadOnly }
+func (t *azureFileWriteRetriesTransferMgr) ReportSharingViolationRetry() { t.retriedFiles++ }
+func (t *azureFileWriteRetriesTransferMgr) GetShareQuotaGuard() *shareQuotaGuard {
+	return t.shareQuota
+}
+func (t *azureFileWriteRetriesTransferMgr) ShareFullWaitWindow() time.Duration {
+	return t.shareFullWait
+}
+func (t *azureFileWriteRetriesTransferMgr) LogAtLevelForCurrentTransfer(level pipeline.LogLevel, msg string) {
+}
+
+// lockedAzureFileService answers like a share with one file, which has the given attributes, and which something else
+// has open for the given number of writes, in a share that's full for the given number of writes
+type lockedAzureFileService struct {
+	attributes      string
+	openForWrites   int
+	fullForWrites   int
+	setAttributes   []string
+	setContentTypes []string
+	setContentMD5s  []string
+	renamedFrom     []string
+	// the number of times the properties of the file were fetched
+	propertiesFetched int
+}
+
+func (l *lockedAzureFileService) pipeline() pipeline.Pipeline {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			status, header := http.StatusCreated, http.Header{}
+			switch {
+			case request.Method == http.MethodHead:
+				l.propertiesFetched++
+				status = http.StatusOK
+				header.Set("x-ms-file-attributes", l.attributes)
+				header.Set("Content-Type", "text/plain")
+			case request.URL.Query().Get("comp") == "properties":
+				status = http.StatusOK
+				l.attributes = request.Header.Get("x-ms-file-attributes")
+				l.setAttributes = append(l.setAttributes, l.attributes)
+				l.setContentTypes = append(l.setContentTypes, request.Header.Get("x-ms-content-type"))
+				l.setContentMD5s = append(l.setContentMD5s, request.Header.Get("x-ms-content-md5"))
+			case strings.Contains(l.attributes, readOnlyFileAttribute):
+				status = http.StatusConflict
+				header.Set("x-ms-error-code", string(azfile.ServiceCodeReadOnlyAttribute))
+			case l.fullForWrites > 0:
+				l.fullForWrites--
+				status = http.StatusRequestEntityTooLarge
+				header.Set("x-ms-error-code", serviceCodeShareSizeLimitReached)
+			case l.openForWrites > 0:
+				l.openForWrites--
+				status = http.StatusConflict
+				header.Set("x-ms-error-code", string(azfile.ServiceCodeSharingViolation))
+			case request.URL.Query().Get("comp") == "rename":
+				status = http.StatusOK
+				l.renamedFrom = append(l.renamedFrom, request.Header.Get("x-ms-file-rename-source"))
+			}
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: status, Status: http.StatusText(status), Header: header,
+				Body: ioutil.NopCloser(strings.NewReader("")), Request: request.Request}), nil
+		}
+	})
+	return pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+}
+
+func newSenderForWriteRetries(jptm IJobPartTransferMgr, service *lockedAzureFileService) *azureFileSenderBase {
+	u, _ := url.Parse("https://acct.file.core.windows.net/share/dir/report.xlsx")
+	p := service.pipeline()
+	return &azureFileSenderBase{
+		jptm:                     jptm,
+		fileURL:                  azfile.NewFileURL(*u, p),
+		finalURL:                 azfile.NewFileURL(*u, p),
+		headersToApply:           azfile.FileHTTPHeaders{ContentType: "text/plain"},
+		pipeline:                 p,
+		ctx:                      context.Background(),
+		readOnlyLock:             &sync.Mutex{},
+		sharingViolationReported: &sync.Once{},
+	}
+}
+
+// createFile is the write that the tests retry
+func createFile(sender *azureFileSenderBase) func() error {
+	return func() error {
+		_, err := sender.fileURL.Create(sender.ctx, 10, azfile.FileHTTPHeaders{}, nil)
+		return err
+	}
+}
+
+func (s *sharingViolationSuite) TestSharingViolationsAreRetriedWithinTheWindow(c *chk.C) {
+	defer func(d time.Duration) { sharingViolationRetryBaseDelay = d }(sharingViolationRetryBaseDelay)
+	sharingViolationRetryBaseDelay = time.Millisecond
+
+	// without a window, the first sharing violation fails the write
+	jptm := &azureFileWriteRetriesTransferMgr{}
+	sender := newSenderForWriteRetries(jptm, &lockedAzureFileService{attributes: "Archive", openForWrites: 1})
+	err := sender.writeWithRetries(createFile(sender))
+	c.Assert(isSharingViolation(err), chk.Equals, true)
+	c.Assert(jptm.retriedFiles, chk.Equals, 0)
+
+	// within a window, the write is retried until the file is let go of, and the file is counted once
+	jptm = &azureFileWriteRetriesTransferMgr{window: time.Minute}
+	service := &lockedAzureFileService{attributes: "Archive", openForWrites: 3}
+	sender = newSenderForWriteRetries(jptm, service)
+	c.Assert(sender.writeWithRetries(createFile(sender)), chk.IsNil)
+	c.Assert(service.openForWrites, chk.Equals, 0)
+	c.Assert(jptm.retriedFiles, chk.Equals, 1)
+}
+
+func (s *sharingViolationSuite) TestReadOnlyAttributeIsClearedAndRestored(c *chk.C) {
+	// unless the job forces it, the attribute is left alone
+	service := &lockedAzureFileService{attributes: "ReadOnly | Archive"}
+	sender := newSenderForWriteRetries(&azureFileWriteRetriesTransferMgr{}, service)
+	c.Assert(isReadOnlyAttributeInTheWay(sender.writeWithRetries(createFile(sender))), chk.Equals, true)
+	c.Assert(service.setAttributes, chk.HasLen, 0)
+
+	service = &lockedAzureFileService{attributes: "ReadOnly | Archive"}
+	sender = newSenderForWriteRetries(&azureFileWriteRetriesTransferMgr{forceIfReadOnly: true}, service)
+	c.Assert(sender.writeWithRetries(createFile(sender)), chk.IsNil)
+	c.Assert(service.setAttributes, chk.DeepEquals, []string{"Archive"})
+
+	restored, err := sender.restoreReadOnlyAttribute()
+	c.Assert(restored, chk.Equals, true)
+	c.Assert(err, chk.IsNil)
+	restored, _ = sender.restoreReadOnlyAttribute() // only once
+	c.Assert(restored, chk.Equals, false)
+	c.Assert(service.setAttributes, chk.DeepEquals, []string{"Archive", "ReadOnly | Archive"})
+	c.Assert(service.setContentTypes, chk.DeepEquals, []string{"text/plain", "text/plain"}) // kept, since setting the attributes would clear it
+}
+
+func (s *sharingViolationSuite) TestReadOnlyAttributeIsHandledWithoutFetchingThePropertiesAgain(c *chk.C) {
+	service := &lockedAzureFileService{attributes: "ReadOnly | Archive"}
+	sender := newSenderForWriteRetries(&azureFileWriteRetriesTransferMgr{forceIfReadOnly: true}, service)
+
+	// the check for the destination fetches its properties, which clearing the attribute then uses
+	exists, _, err := sender.RemoteFileExists()
+	c.Assert(err, chk.IsNil)
+	c.Assert(exists, chk.Equals, true)
+	c.Assert(sender.writeWithRetries(createFile(sender)), chk.IsNil)
+	c.Assert(service.propertiesFetched, chk.Equals, 1)
+
+	// the attribute is restored with the headers the file was written with, including its MD5 hash, in a single request
+	sender.headersToApply.ContentMD5 = []byte{0xde, 0xad, 0xbe, 0xef}
+	restored, err := sender.restoreReadOnlyAttribute()
+	c.Assert(restored, chk.Equals, true)
+	c.Assert(err, chk.IsNil)
+	c.Assert(service.propertiesFetched, chk.Equals, 1)
+	c.Assert(service.setAttributes, chk.DeepEquals, []string{"Archive", "ReadOnly | Archive"})
+	c.Assert(service.setContentMD5s, chk.DeepEquals, []string{"", "3q2+7w=="})
+}
+
+func (s *sharingViolationSuite) TestReadOnlyIsRemovedFromTheAttributes(c *chk.C) {
+	c.Assert(withoutReadOnlyAttribute("ReadOnly | Archive"), chk.Equals, "Archive")
+	c.Assert(withoutReadOnlyAttribute("Hidden|ReadOnly|System"), chk.Equals, "Hidden | System")
+	c.Assert(withoutReadOnlyAttribute("ReadOnly"), chk.Equals, "None")
+	c.Assert(withoutReadOnlyAttribute("None"), chk.Equals, "None")
+}
+
+// azureFileSenderTransferMgr is a transfer of the given size and block size to Azure Files
+type azureFileSenderTransferMgr struct {
+	azureFileWriteRetriesTransferMgr
+	info TransferInfo
+}
+
+func (t *azureFileSenderTransferMgr) Info() TransferInfo                { return t.info }
+func (t *azureFileSenderTransferMgr) ShouldLog(pipeline.LogLevel) bool  { return false }
+func (t *azureFileSenderTransferMgr) TempNameSuffix() string            { return "" }
+func (t *azureFileSenderTransferMgr) PreserveSourceLMTAsMetadata() bool { return false }
+
+func (s *sharingViolationSuite) TestRangesAreAtMost4MiBWhateverTheBlockSize(c *chk.C) {
+	const mib = 1024 * 1024
+	for _, blockSize := range []uint32{mib, 4 * mib, 8 * mib, 100 * mib} {
+		jptm := &azureFileSenderTransferMgr{info: TransferInfo{BlockSize: blockSize, SourceSize: 10 * mib}}
+		sender, err := newAzureFileSenderBase(jptm, "https://acct.file.core.windows.net/share/big.bin",
+			(&lockedAzureFileService{}).pipeline(), nil, benchmarkSourceInfoProvider{jptm})
+		c.Assert(err, chk.IsNil)
+
+		expectedChunkSize := blockSize
+		if expectedChunkSize > 4*mib {
+			expectedChunkSize = 4 * mib
+		}
+		c.Assert(sender.ChunkSize(), chk.Equals, expectedChunkSize)
+		c.Assert(sender.NumChunks(), chk.Equals, uint32((10*mib+expectedChunkSize-1)/expectedChunkSize))
+	}
+}
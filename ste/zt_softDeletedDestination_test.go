@@ -0,0 +1,158 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type softDeletedDestinationSuite struct{}
+
+var _ = chk.Suite(&softDeletedDestinationSuite{})
+
+// softDeletedTestTransferMgr is a transfer of a job that does the given thing with soft-deleted destinations
+type softDeletedTestTransferMgr struct {
+	IJobPartTransferMgr
+	handling common.SoftDeletedDestinationHandling
+	recorded common.SoftDeletedDestinationHandling
+	status   common.TransferStatus
+	done     bool
+}
+
+func (t *softDeletedTestTransferMgr) SoftDeletedDestinationHandling() common.SoftDeletedDestinationHandling {
+	return t.handling
+}
+
+func (t *softDeletedTestTransferMgr) SetSoftDeletedDestination(handling common.SoftDeletedDestinationHandling) {
+	t.recorded = handling
+}
+
+func (t *softDeletedTestTransferMgr) Info() TransferInfo {
+	return TransferInfo{Source: "/data/file", Destination: "https://dest.blob.core.windows.net/cont/file"}
+}
+
+func (t *softDeletedTestTransferMgr) SetStatus(status common.TransferStatus)                   { t.status = status }
+func (t *softDeletedTestTransferMgr) ReportTransferDone() uint32                               { t.done = true; return 0 }
+func (t *softDeletedTestTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string)   {}
+func (t *softDeletedTestTransferMgr) LogSendError(source, destination, errorMsg string, s int) {}
+
+// softDeletedTestSender is a sender to a destination that's soft-deleted, or not
+type softDeletedTestSender struct {
+	ISenderBase
+	softDeleted bool
+	checkErr    error
+	undeleted   bool
+}
+
+func (s *softDeletedTestSender) RemoteFileSoftDeleted() (bool, error) {
+	return s.softDeleted, s.checkErr
+}
+
+func (s *softDeletedTestSender) UndeleteRemoteFile() error {
+	s.undeleted = true
+	return nil
+}
+
+func (s *softDeletedDestinationSuite) TestEachHandlingOfASoftDeletedDestination(c *chk.C) {
+	// nothing is checked unless the job asks for it
+	jptm := &softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.Ignore()}
+	sender := &softDeletedTestSender{softDeleted: true}
+	c.Assert(handleSoftDeletedDestination(jptm, sender), chk.Equals, false)
+	c.Assert(jptm.recorded, chk.Equals, common.ESoftDeletedDestinationHandling.Ignore())
+
+	// nor does anything happen to a destination that isn't soft-deleted
+	jptm = &softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.Skip()}
+	c.Assert(handleSoftDeletedDestination(jptm, &softDeletedTestSender{}), chk.Equals, false)
+	c.Assert(jptm.done, chk.Equals, false)
+
+	c.Assert(handleSoftDeletedDestination(jptm, sender), chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.SkippedDestinationSoftDeleted())
+	c.Assert(jptm.recorded, chk.Equals, common.ESoftDeletedDestinationHandling.Skip())
+	c.Assert(jptm.done, chk.Equals, true)
+
+	jptm = &softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.UndeleteAndOverwrite()}
+	c.Assert(handleSoftDeletedDestination(jptm, sender), chk.Equals, false)
+	c.Assert(sender.undeleted, chk.Equals, true)
+	c.Assert(jptm.recorded, chk.Equals, common.ESoftDeletedDestinationHandling.UndeleteAndOverwrite())
+
+	sender = &softDeletedTestSender{softDeleted: true}
+	jptm = &softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.Overwrite()}
+	c.Assert(handleSoftDeletedDestination(jptm, sender), chk.Equals, false)
+	c.Assert(sender.undeleted, chk.Equals, false)
+	c.Assert(jptm.recorded, chk.Equals, common.ESoftDeletedDestinationHandling.Overwrite())
+
+	// a destination that can't be checked fails the transfer
+	jptm = &softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.Overwrite()}
+	c.Assert(handleSoftDeletedDestination(jptm, &softDeletedTestSender{checkErr: errors.New("forbidden")}), chk.Equals, true)
+	c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Failed())
+}
+
+func (s *softDeletedDestinationSuite) TestSoftDeletedBlobIsFoundByListingTheDeletedBlobs(c *chk.C) {
+	listing := ""
+	var query url.Values
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			query = request.URL.Query()
+			body := `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ServiceEndpoint="https://dest.blob.core.windows.net/" ContainerName="cont">` +
+				`<Blobs>` + listing + `</Blobs><NextMarker /></EnumerationResults>`
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Status: "OK", Header: http.Header{},
+				Body: ioutil.NopCloser(strings.NewReader(body)), Request: request.Request}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+	u, _ := url.Parse("https://dest.blob.core.windows.net/cont/dir/file")
+	blob := newSoftDeletableBlob(context.Background(), *u, p)
+
+	softDeleted, err := blob.RemoteFileSoftDeleted()
+	c.Assert(err, chk.IsNil)
+	c.Assert(softDeleted, chk.Equals, false)
+	c.Assert(query.Get("include"), chk.Equals, "deleted")
+	c.Assert(query.Get("prefix"), chk.Equals, "dir/file")
+
+	// a deleted blob whose name only starts with that of the destination doesn't count
+	listing = `<Blob><Name>dir/file2</Name><Deleted>true</Deleted><Properties /></Blob>`
+	softDeleted, err = blob.RemoteFileSoftDeleted()
+	c.Assert(err, chk.IsNil)
+	c.Assert(softDeleted, chk.Equals, false)
+
+	listing = `<Blob><Name>dir/file</Name><Deleted>true</Deleted><Properties /></Blob>`
+	softDeleted, err = blob.RemoteFileSoftDeleted()
+	c.Assert(err, chk.IsNil)
+	c.Assert(softDeleted, chk.Equals, true)
+}
+
+func (s *softDeletedDestinationSuite) TestWritesToBlobsBeingDeletedAreOnlySkippedWhenAskedFor(c *chk.C) {
+	err := errorFromService(c, http.StatusConflict, blobBeingDeletedServiceCode, "The specified blob is being deleted.", nil)
+
+	c.Assert(skipsDestinationBeingDeleted(&softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.Skip()}, err), chk.Equals, true)
+	c.Assert(skipsDestinationBeingDeleted(&softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.Overwrite()}, err), chk.Equals, false)
+	c.Assert(skipsDestinationBeingDeleted(&softDeletedTestTransferMgr{handling: common.ESoftDeletedDestinationHandling.Skip()}, errors.New("connection reset")), chk.Equals, false)
+}
@@ -0,0 +1,72 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type sourceFileErrorsSuite struct{}
+
+var _ = chk.Suite(&sourceFileErrorsSuite{})
+
+// skipFlagsTransferMgr only answers whether source errors may be skipped
+type skipFlagsTransferMgr struct {
+	IJobPartTransferMgr
+	skipPermissionErrors bool
+	skipLockedFiles      bool
+}
+
+func (t skipFlagsTransferMgr) SkipPermissionErrors() bool { return t.skipPermissionErrors }
+func (t skipFlagsTransferMgr) SkipLockedFiles() bool      { return t.skipLockedFiles }
+
+func (s *sourceFileErrorsSuite) TestPermissionErrorsAreOnlySkippedWhenAskedFor(c *chk.C) {
+	openErr := &os.PathError{Op: "open", Path: "/data/private", Err: syscall.EACCES}
+
+	status, skip := skippedStatusForSourceError(skipFlagsTransferMgr{}, openErr)
+	c.Assert(skip, chk.Equals, false)
+	c.Assert(status, chk.Equals, common.ETransferStatus.Failed())
+
+	status, skip = skippedStatusForSourceError(skipFlagsTransferMgr{skipPermissionErrors: true}, openErr)
+	c.Assert(skip, chk.Equals, true)
+	c.Assert(status, chk.Equals, common.ETransferStatus.SkippedPermissionDenied())
+}
+
+func (s *sourceFileErrorsSuite) TestOtherErrorsAreNeverSkipped(c *chk.C) {
+	jptm := skipFlagsTransferMgr{skipPermissionErrors: true, skipLockedFiles: true}
+
+	_, skip := skippedStatusForSourceError(jptm, nil)
+	c.Assert(skip, chk.Equals, false)
+
+	_, skip = skippedStatusForSourceError(jptm, errors.New("disk failure"))
+	c.Assert(skip, chk.Equals, false)
+
+	_, err := os.Open(filepath.Join(os.TempDir(), "sourceFileErrors-missing"))
+	_, skip = skippedStatusForSourceError(jptm, err)
+	c.Assert(skip, chk.Equals, false)
+}
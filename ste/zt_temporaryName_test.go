@@ -0,0 +1,77 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/url"
+
+	"github.com/Azure/azure-storage-file-go/azfile"
+	chk "gopkg.in/check.v1"
+)
+
+type temporaryNameSuite struct{}
+
+var _ = chk.Suite(&temporaryNameSuite{})
+
+func (s *temporaryNameSuite) TestTemporaryNameIsTheNameWithTheSuffix(c *chk.C) {
+	u, _ := url.Parse("https://acct.file.core.windows.net/share/dir/report.xlsx?sv=2020-02-10&sig=abc")
+	temporary := temporaryDestinationURL(*u, ".azcopy-partial")
+	c.Assert(temporary.String(), chk.Equals, "https://acct.file.core.windows.net/share/dir/report.xlsx.azcopy-partial?sv=2020-02-10&sig=abc")
+	c.Assert(u.Path, chk.Equals, "/share/dir/report.xlsx")
+
+	// a name that's escaped in its own way stays escaped that way
+	u, _ = url.Parse("https://acct.dfs.core.windows.net/fs/a%2Bb.csv")
+	temporary = temporaryDestinationURL(*u, ".tmp")
+	c.Assert(temporary.String(), chk.Equals, "https://acct.dfs.core.windows.net/fs/a%2Bb.csv.tmp")
+	c.Assert(temporary.Path, chk.Equals, "/fs/a+b.csv.tmp")
+}
+
+func (s *temporaryNameSuite) TestAzureFileIsRenamedToItsFinalName(c *chk.C) {
+	service := &lockedAzureFileService{attributes: "Archive"}
+	sender := newSenderForWriteRetries(&azureFileWriteRetriesTransferMgr{}, service)
+	sender.RenameToFinalName() // written under its own name, so there's nothing to rename
+	c.Assert(service.renamedFrom, chk.HasLen, 0)
+
+	sender = newSenderWithTemporaryName(&azureFileWriteRetriesTransferMgr{}, service)
+	sender.RenameToFinalName()
+	c.Assert(service.renamedFrom, chk.DeepEquals, []string{"https://acct.file.core.windows.net/share/dir/report.xlsx.azcopy-partial"})
+	c.Assert(sender.fileURL.String(), chk.Equals, "https://acct.file.core.windows.net/share/dir/report.xlsx")
+	c.Assert(sender.temporaryName, chk.Equals, false)
+}
+
+func (s *temporaryNameSuite) TestReadOnlyFinalNameIsOverwrittenWhenForced(c *chk.C) {
+	service := &lockedAzureFileService{attributes: "ReadOnly | Archive"}
+	sender := newSenderWithTemporaryName(&azureFileWriteRetriesTransferMgr{forceIfReadOnly: true}, service)
+	sender.RenameToFinalName()
+
+	c.Assert(service.renamedFrom, chk.HasLen, 1)
+	c.Assert(service.setAttributes, chk.DeepEquals, []string{"Archive", "ReadOnly | Archive"}) // cleared, then restored on the renamed file
+	c.Assert(sender.temporaryName, chk.Equals, false)
+}
+
+// newSenderWithTemporaryName returns a sender that has written the file under a temporary name
+func newSenderWithTemporaryName(jptm IJobPartTransferMgr, service *lockedAzureFileService) *azureFileSenderBase {
+	sender := newSenderForWriteRetries(jptm, service)
+	sender.finalURL = sender.fileURL
+	sender.fileURL = azfile.NewFileURL(temporaryDestinationURL(sender.finalURL.URL(), ".azcopy-partial"), sender.pipeline)
+	sender.temporaryName = true
+	return sender
+}
@@ -0,0 +1,105 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type transactionCountsSuite struct{}
+
+var _ = chk.Suite(&transactionCountsSuite{})
+
+func (s *transactionCountsSuite) TestRequestsAreCountedByClass(c *chk.C) {
+	stats := &pipelineNetworkStats{statusCodeCounts: make(map[int]int64)}
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), newXferStatsPolicyFactory(stats)}, pipeline.Options{
+		HTTPSender: pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+			return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+				return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Request: request.Request}), nil
+			}
+		}),
+	})
+
+	for _, r := range []struct {
+		method string
+		url    string
+	}{
+		{http.MethodGet, "https://account.blob.core.windows.net/container?restype=container&comp=list"},
+		{http.MethodGet, "https://account.dfs.core.windows.net/filesystem?resource=filesystem&recursive=true"},
+		{http.MethodPut, "https://account.file.core.windows.net/share?restype=share"},
+		{http.MethodGet, "https://account.blob.core.windows.net/container/blob"},
+		{http.MethodPut, "https://account.blob.core.windows.net/container/blob?comp=block&blockid=AAAA"},
+		{http.MethodPut, "https://account.dfs.core.windows.net/filesystem/file?resource=file"},
+		{http.MethodPatch, "https://account.dfs.core.windows.net/filesystem/file?action=append&position=0"},
+		{http.MethodPatch, "https://account.dfs.core.windows.net/filesystem/file?action=flush&position=10"},
+		{http.MethodHead, "https://account.blob.core.windows.net/container/blob"},
+		{http.MethodPut, "https://account.blob.core.windows.net/container/blob?comp=lease"},
+		{http.MethodDelete, "https://account.blob.core.windows.net/container/blob"},
+	} {
+		u, _ := url.Parse(r.url)
+		request, err := pipeline.NewRequest(r.method, *u, nil)
+		c.Assert(err, chk.IsNil)
+		_, err = p.Do(context.Background(), nil, request)
+		c.Assert(err, chk.IsNil)
+	}
+
+	c.Assert(stats.TransactionCounts(), chk.Equals, common.TransactionCounts{List: 3, Read: 1, Write: 4, Other: 3})
+}
+
+func (s *transactionCountsSuite) TestPredictionFollowsTheRequestPatternOfTheSender(c *chk.C) {
+	const mib = 1024 * 1024
+	detect := common.EBlobType.Detect()
+
+	// downloads: one request for a small file, and one per block for a larger one
+	c.Assert(PredictTransactions(common.EFromTo.BlobLocal(), detect, 0, 0), chk.Equals, common.TransactionCounts{})
+	c.Assert(PredictTransactions(common.EFromTo.BlobLocal(), detect, 5*mib, 4*mib), chk.Equals, common.TransactionCounts{Read: 1})
+	c.Assert(PredictTransactions(common.EFromTo.BlobLocal(), detect, 20*mib, 4*mib), chk.Equals, common.TransactionCounts{Read: 5})
+
+	// block blobs: one Put Blob for a file in one block, or the blocks and the Put Block List
+	c.Assert(PredictTransactions(common.EFromTo.LocalBlob(), detect, 5*mib, 0), chk.Equals, common.TransactionCounts{Write: 1})
+	c.Assert(PredictTransactions(common.EFromTo.LocalBlob(), detect, 20*mib, 0), chk.Equals, common.TransactionCounts{Write: 4})
+	c.Assert(PredictTransactions(common.EFromTo.BlobBlob(), detect, 9*mib, 16*mib), chk.Equals, common.TransactionCounts{Write: 2})
+	c.Assert(PredictTransactions(common.EFromTo.LocalBlob(), common.EBlobType.PageBlob(), 16*mib, 4*mib), chk.Equals, common.TransactionCounts{Write: 5})
+
+	// BlobFS: the create, the appends and the flush, or the create and one flush that appends too, for a small file
+	c.Assert(PredictTransactions(common.EFromTo.LocalBlobFS(), detect, 20*mib, 0), chk.Equals, common.TransactionCounts{Write: 5})
+	c.Assert(PredictTransactions(common.EFromTo.LocalBlobFS(), detect, mib, 0), chk.Equals, common.TransactionCounts{Write: 2})
+
+	// Azure Files: the create and the ranges
+	c.Assert(PredictTransactions(common.EFromTo.LocalFile(), detect, 0, 0), chk.Equals, common.TransactionCounts{Write: 1})
+	c.Assert(PredictTransactions(common.EFromTo.LocalFile(), detect, 20*mib, 0), chk.Equals, common.TransactionCounts{Write: 4})
+}
+
+func (s *transactionCountsSuite) TestAssumptionsStateTheBlockSize(c *chk.C) {
+	assumptions := TransactionPredictionAssumptions(common.EFromTo.LocalBlobFS(), common.EBlobType.Detect(), 4*1024*1024)
+	c.Assert(assumptions[0], chk.Equals, "Files are split into blocks of 4 MiB")
+	c.Assert(len(assumptions), chk.Equals, 3)
+
+	assumptions = TransactionPredictionAssumptions(common.EFromTo.BlobBlob(), common.EBlobType.Detect(), 0)
+	c.Assert(assumptions[0], chk.Equals, "Files are split into blocks of 8 MiB, doubled for files that would otherwise have more than 50000 blocks")
+	c.Assert(len(assumptions), chk.Equals, 4)
+}
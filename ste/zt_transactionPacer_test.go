@@ -0,0 +1,95 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"time"
+
+	chk "gopkg.in/check.v1"
+)
+
+type transactionPacerSuite struct{}
+
+var _ = chk.Suite(&transactionPacerSuite{})
+
+func (s *transactionPacerSuite) TestThrottlingLowersTheCapAtMostOncePerInterval(c *chk.C) {
+	t := newTransactionPacer(100)
+	defer t.Close()
+
+	now := time.Now()
+	t.recordThrottled(now)
+	c.Assert(t.currentCap(), chk.Equals, int64(80))
+	c.Assert(t.bucket.targetBytesPerSecond(), chk.Equals, int64(80*transactionTokens))
+
+	// the other requests that were throttled at about the same time don't lower it again
+	t.recordThrottled(now.Add(transactionCapBackoffInterval / 2))
+	c.Assert(t.currentCap(), chk.Equals, int64(80))
+
+	t.recordThrottled(now.Add(transactionCapBackoffInterval))
+	c.Assert(t.currentCap(), chk.Equals, int64(64))
+}
+
+func (s *transactionPacerSuite) TestCapIsNeverLoweredBelowATenth(c *chk.C) {
+	t := newTransactionPacer(100)
+	defer t.Close()
+
+	now := time.Now()
+	for i := 0; i < 50; i++ {
+		t.recordThrottled(now.Add(time.Duration(i) * transactionCapBackoffInterval))
+	}
+	c.Assert(t.currentCap(), chk.Equals, int64(10))
+}
+
+func (s *transactionPacerSuite) TestCapRecoversOnlyWithoutThrottling(c *chk.C) {
+	t := newTransactionPacer(100)
+	defer t.Close()
+
+	now := time.Now()
+	t.recordThrottled(now)
+	c.Assert(t.currentCap(), chk.Equals, int64(80))
+
+	// still throttled recently
+	t.recover(now.Add(transactionCapRecoveryInterval / 2))
+	c.Assert(t.currentCap(), chk.Equals, int64(80))
+
+	t.recover(now.Add(transactionCapRecoveryInterval))
+	c.Assert(t.currentCap(), chk.Equals, int64(88))
+	t.recover(now.Add(2 * transactionCapRecoveryInterval))
+	c.Assert(t.currentCap(), chk.Equals, int64(96))
+
+	// but never above what the user asked for
+	t.recover(now.Add(3 * transactionCapRecoveryInterval))
+	c.Assert(t.currentCap(), chk.Equals, int64(100))
+	t.recover(now.Add(4 * transactionCapRecoveryInterval))
+	c.Assert(t.currentCap(), chk.Equals, int64(100))
+}
+
+func (s *transactionPacerSuite) TestTransactionsAreCounted(c *chk.C) {
+	t := newTransactionPacer(1000) // the bucket starts with a quarter of a second's worth, so these don't wait
+	defer t.Close()
+
+	c.Assert(t.averageTransactionsPerSecond(), chk.Equals, float64(0))
+	for i := 0; i < 10; i++ {
+		c.Assert(t.waitForTransaction(context.Background()), chk.IsNil)
+	}
+	c.Assert(t.averageTransactionsPerSecond(), chk.Equals, float64(10))
+}
@@ -0,0 +1,84 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type transferFailuresSuite struct{}
+
+var _ = chk.Suite(&transferFailuresSuite{})
+
+func (s *transferFailuresSuite) TestKindLeavesOutThePathsAndTheRequestID(c *chk.C) {
+	source := "/data/dir/file.txt"
+	destination := "https://account.blob.core.windows.net/container/dir/file.txt?sv=2019&sig=secret"
+
+	kind := transferFailureKind(source, destination,
+		"404 The specified container does not exist.. When Staging block. X-Ms-Request-Id: 1234\n", 404)
+	c.Assert(kind, chk.Equals, "404 The specified container does not exist.. When Staging block")
+
+	kind = transferFailureKind(source, destination, "Couldn't open source-open /data/dir/file.txt: permission denied", 0)
+	c.Assert(kind, chk.Equals, "Couldn't open source-open <source>: permission denied")
+
+	kind = transferFailureKind(source, destination, "Directory creation error Conflict", 409)
+	c.Assert(kind, chk.Equals, "409 Directory creation error Conflict")
+}
+
+func (s *transferFailuresSuite) TestFirstFailuresAndDominantKind(c *chk.C) {
+	f := newTransferFailures()
+	kind, count := f.dominant()
+	c.Assert(kind, chk.Equals, "")
+	c.Assert(count, chk.Equals, uint32(0))
+
+	f.record("https://account.blob.core.windows.net/c/a?sv=1&sig=secret", "/tmp/a", "File Creation Error disk full", "", 0)
+	for _, name := range []string{"b", "c", "d", "e", "f", "g"} {
+		f.record("https://account.blob.core.windows.net/c/"+name, "/tmp/"+name, "403 This request is not authorized to perform this operation. When Reading. X-Ms-Request-Id: "+name, "AuthorizationPermissionMismatch", 403)
+	}
+
+	first := f.firstFailures()
+	c.Assert(first, chk.HasLen, firstTransferFailuresKept)
+	c.Assert(first[0], chk.Equals, "https://account.blob.core.windows.net/c/a?sig=REDACTED&sv=1: File Creation Error disk full")
+	c.Assert(first[1], chk.Equals, "https://account.blob.core.windows.net/c/b: 403 This request is not authorized to perform this operation. When Reading")
+
+	kind, count = f.dominant()
+	c.Assert(kind, chk.Equals, "403 This request is not authorized to perform this operation. When Reading")
+	c.Assert(count, chk.Equals, uint32(6))
+}
+
+func (s *transferFailuresSuite) TestFailuresByErrorCode(c *chk.C) {
+	f := newTransferFailures()
+	c.Assert(f.byErrorCode(), chk.IsNil)
+
+	f.record("https://account.blob.core.windows.net/c/a?sv=1&sig=secret", "/tmp/a", "403 This request is not authorized", "AuthorizationPermissionMismatch", 403)
+	f.record("https://account.blob.core.windows.net/c/b", "/tmp/b", "403 This request is not authorized", "AuthorizationPermissionMismatch", 403)
+	// the code of an error that was only logged as text is found in the text
+	f.record("/data/c", "https://account.blob.core.windows.net/c/c", "-> github.com/Azure/azure-storage-blob-go/azblob.newStorageError\n"+
+		"===== RESPONSE ERROR (ServiceCode=BlobArchived) =====\nDescription=This operation is not permitted on an archived blob.", "", 0)
+	f.record("/data/d", "https://account.blob.core.windows.net/c/d", "Couldn't open source-open /data/d: permission denied", "", 0)
+
+	c.Assert(f.byErrorCode(), chk.DeepEquals, map[string]common.ErrorCodeFailures{
+		"AuthorizationPermissionMismatch": {Count: 2, Status: 403, ExamplePath: "https://account.blob.core.windows.net/c/a?sig=REDACTED&sv=1"},
+		"BlobArchived":                    {Count: 1, ExamplePath: "/data/c"},
+		common.NoStorageErrorCode:         {Count: 1, ExamplePath: "/data/d"},
+	})
+}
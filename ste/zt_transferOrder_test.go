@@ -0,0 +1,135 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type transferOrderSuite struct{}
+
+var _ = chk.Suite(&transferOrderSuite{})
+
+// sizedTransfer stands in for the transfer of a file of the given size
+type sizedTransfer struct {
+	IJobPartTransferMgr
+	size int64
+}
+
+// scheduledSizes returns the sizes of the files in the order they're scheduled in
+func scheduledSizes(sizes []int64, order common.TransferOrder, window int) []int64 {
+	scheduled := make([]int64, 0, len(sizes))
+	b := newTransferOrderBuffer(order, window, func(jptm IJobPartTransferMgr) {
+		scheduled = append(scheduled, jptm.(sizedTransfer).size)
+	})
+	for _, size := range sizes {
+		b.add(sizedTransfer{size: size}, size)
+	}
+	b.flush()
+	return scheduled
+}
+
+// simulatedWallClock is how long the workers take to transfer the files, in the order they're scheduled in,
+// when each file takes as long as its size, and the next file goes to the worker that's free first
+func simulatedWallClock(sizes []int64, order common.TransferOrder, workers int) int64 {
+	busyUntil := make([]int64, workers)
+	b := newTransferOrderBuffer(order, transferOrderWindow, func(jptm IJobPartTransferMgr) {
+		free := 0
+		for w := range busyUntil {
+			if busyUntil[w] < busyUntil[free] {
+				free = w
+			}
+		}
+		busyUntil[free] += jptm.(sizedTransfer).size
+	})
+	for _, size := range sizes {
+		b.add(sizedTransfer{size: size}, size)
+	}
+	b.flush()
+
+	var wallClock int64
+	for _, t := range busyUntil {
+		if t > wallClock {
+			wallClock = t
+		}
+	}
+	return wallClock
+}
+
+// mixedSizes is a dataset of many small files, with a few large ones spread among them
+func mixedSizes() []int64 {
+	r := rand.New(rand.NewSource(1))
+	sizes := make([]int64, 0, 3000)
+	for i := 0; i < 3000; i++ {
+		size := 1 + r.Int63n(10)
+		if i%200 == 199 {
+			size = 600
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+func (s *transferOrderSuite) TestTransfersAreScheduledInOrderWithinTheWindow(c *chk.C) {
+	sizes := []int64{1, 5, 2, 8, 3}
+	c.Assert(scheduledSizes(sizes, common.ETransferOrder.AsEnumerated(), 3), chk.DeepEquals, sizes)
+	// the 8 can only be compared with what's held when it comes, so the 5 goes before the 3 that comes after it
+	c.Assert(scheduledSizes(sizes, common.ETransferOrder.LargestFirst(), 3), chk.DeepEquals, []int64{8, 5, 3, 2, 1})
+	c.Assert(scheduledSizes(sizes, common.ETransferOrder.SmallestFirst(), 3), chk.DeepEquals, []int64{1, 2, 3, 5, 8})
+	c.Assert(scheduledSizes([]int64{9, 1, 2, 3}, common.ETransferOrder.LargestFirst(), 1), chk.DeepEquals, []int64{9, 2, 3, 1})
+
+	// a window as large as the part sorts it all
+	c.Assert(scheduledSizes([]int64{2, 7, 2, 9, 1}, common.ETransferOrder.LargestFirst(), 10), chk.DeepEquals, []int64{9, 7, 2, 2, 1})
+}
+
+func (s *transferOrderSuite) TestTransferOrderIsParsedInFlagStyle(c *chk.C) {
+	var order common.TransferOrder
+	c.Assert(order.Parse("largest-first"), chk.IsNil)
+	c.Assert(order, chk.Equals, common.ETransferOrder.LargestFirst())
+	c.Assert(order.Parse("as-enumerated"), chk.IsNil)
+	c.Assert(order, chk.Equals, common.ETransferOrder.AsEnumerated())
+	c.Assert(order.Parse("random"), chk.NotNil)
+}
+
+func (s *transferOrderSuite) TestLargestFirstShortensTheTail(c *chk.C) {
+	sizes := mixedSizes()
+	asEnumerated := simulatedWallClock(sizes, common.ETransferOrder.AsEnumerated(), 16)
+	largestFirst := simulatedWallClock(sizes, common.ETransferOrder.LargestFirst(), 16)
+	c.Assert(largestFirst < asEnumerated, chk.Equals, true, chk.Commentf("largest-first %v, as-enumerated %v", largestFirst, asEnumerated))
+}
+
+// BenchmarkTransferOrder reports the simulated wall-clock time of transferring a mixed-size dataset with 16 workers, in each order
+func BenchmarkTransferOrder(b *testing.B) {
+	sizes := mixedSizes()
+	for _, order := range []common.TransferOrder{common.ETransferOrder.AsEnumerated(), common.ETransferOrder.LargestFirst(), common.ETransferOrder.SmallestFirst()} {
+		b.Run(order.String(), func(b *testing.B) {
+			var wallClock int64
+			for i := 0; i < b.N; i++ {
+				wallClock = simulatedWallClock(sizes, order, 16)
+			}
+			b.ReportMetric(float64(wallClock), "wall-clock")
+		})
+	}
+}
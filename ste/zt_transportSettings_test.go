@@ -0,0 +1,98 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type transportSettingsSuite struct{}
+
+var _ = chk.Suite(&transportSettingsSuite{})
+
+func setEnvForTest(c *chk.C, values map[common.EnvironmentVariable]string) func() {
+	for envVar, value := range values {
+		c.Assert(os.Setenv(envVar.Name, value), chk.IsNil)
+	}
+	return func() {
+		for envVar := range values {
+			os.Unsetenv(envVar.Name)
+		}
+	}
+}
+
+func (s *transportSettingsSuite) TestEnvironmentReachesTheTransport(c *chk.C) {
+	defer setEnvForTest(c, map[common.EnvironmentVariable]string{
+		common.EEnvironmentVariable.MaxIdleConns():    "2000",
+		common.EEnvironmentVariable.MaxConnsPerHost(): "64",
+		common.EEnvironmentVariable.DisableHTTP2():    "true",
+	})()
+
+	settings, err := common.NewTransportSettings(common.GetLifecycleMgr().GetEnvironmentVariable)
+	c.Assert(err, chk.IsNil)
+	original := common.GlobalTransportSettings
+	common.GlobalTransportSettings = settings
+	defer func() { common.GlobalTransportSettings = original }()
+
+	transport := NewAzcopyHTTPClient(500).Transport.(*http.Transport)
+	c.Assert(transport.MaxIdleConnsPerHost, chk.Equals, 2000)
+	c.Assert(transport.MaxConnsPerHost, chk.Equals, 64)
+	c.Assert(transport.TLSNextProto, chk.NotNil)
+	c.Assert(transport.TLSNextProto, chk.HasLen, 0)
+	c.Assert(common.DescribeTransport(transport), chk.Equals, "Max idle connections per host: 2000. Max connections per host: 64. HTTP/2: false")
+}
+
+func (s *transportSettingsSuite) TestTransportKeepsItsDefaultsWithoutTheEnvironment(c *chk.C) {
+	settings, err := common.NewTransportSettings(func(common.EnvironmentVariable) string { return "" })
+	c.Assert(err, chk.IsNil)
+	c.Assert(settings, chk.DeepEquals, common.TransportSettings{})
+
+	transport := NewAzcopyHTTPClient(500).Transport.(*http.Transport)
+	c.Assert(transport.MaxIdleConnsPerHost, chk.Equals, 500)
+	c.Assert(transport.MaxConnsPerHost, chk.Equals, 0)
+	c.Assert(strings.Contains(common.DescribeTransport(transport), "Max connections per host: no limit"), chk.Equals, true)
+
+	// a forced transport with no dialing of its own would use HTTP/2
+	c.Assert(strings.HasSuffix(common.DescribeTransport(&http.Transport{ForceAttemptHTTP2: true}), "HTTP/2: true"), chk.Equals, true)
+}
+
+func (s *transportSettingsSuite) TestBadValuesAreNotIgnored(c *chk.C) {
+	for envVar, value := range map[common.EnvironmentVariable]string{
+		common.EEnvironmentVariable.MaxIdleConns():    "lots",
+		common.EEnvironmentVariable.MaxConnsPerHost(): "-1",
+		common.EEnvironmentVariable.DisableHTTP2():    "sometimes",
+	} {
+		_, err := common.NewTransportSettings(func(e common.EnvironmentVariable) string {
+			if e.Name == envVar.Name {
+				return value
+			}
+			return ""
+		})
+		c.Assert(err, chk.NotNil, chk.Commentf(envVar.Name))
+		c.Assert(strings.Contains(err.Error(), envVar.Name), chk.Equals, true)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/common"
+	chk "gopkg.in/check.v1"
+)
+
+type xferMetricsSuite struct{}
+
+var _ = chk.Suite(&xferMetricsSuite{})
+
+func (s *xferMetricsSuite) TestDurationPercentiles(c *chk.C) {
+	r := &transferMetricsRecorder{}
+	c.Assert(r.DurationPercentiles(), chk.IsNil)
+
+	for i := int64(100); i >= 1; i-- { // out of order, to check that they get sorted
+		r.durationsMs = append(r.durationsMs, i)
+	}
+	p := r.DurationPercentiles()
+	c.Assert(p.TransferCount, chk.Equals, 100)
+	c.Assert(p.P50Milliseconds, chk.Equals, int64(50))
+	c.Assert(p.P95Milliseconds, chk.Equals, int64(95))
+	c.Assert(p.P99Milliseconds, chk.Equals, int64(99))
+
+	r.durationsMs = []int64{42}
+	p = r.DurationPercentiles()
+	c.Assert(p.P50Milliseconds, chk.Equals, int64(42))
+	c.Assert(p.P99Milliseconds, chk.Equals, int64(42))
+}
+
+func (s *xferMetricsSuite) TestMetricsFileFormats(c *chk.C) {
+	dir, err := ioutil.TempDir("", "azcopymetrics")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	rec := &transferMetricsRecord{PartNum: 1, TransferIndex: 2, Source: "https://a.blob.core.windows.net/c/x,y", Destination: "/tmp/x,y",
+		Status: common.ETransferStatus.Success().String(), Bytes: 1024, QueueMs: 3, FirstByteMs: -1, DurationMs: 5, Retries: 1, Worker: 7}
+
+	// CSV
+	csvPath := filepath.Join(dir, "metrics.csv")
+	r, err := newTransferMetricsRecorder(csvPath)
+	c.Assert(err, chk.IsNil)
+	r.unsavedRecords <- rec
+	r.Flush()
+	content, err := ioutil.ReadFile(csvPath)
+	c.Assert(err, chk.IsNil)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	c.Assert(lines, chk.HasLen, 2)
+	c.Assert(lines[0], chk.Equals, strings.Join(transferMetricsCsvHeader, ","))
+	c.Assert(lines[1], chk.Equals, `1,2,"https://a.blob.core.windows.net/c/x,y","/tmp/x,y",Success,1024,3,-1,5,1,7`)
+
+	// NDJSON
+	jsonPath := filepath.Join(dir, "metrics.ndjson")
+	r, err = newTransferMetricsRecorder(jsonPath)
+	c.Assert(err, chk.IsNil)
+	r.unsavedRecords <- rec
+	r.unsavedRecords <- rec
+	r.Flush()
+	content, err = ioutil.ReadFile(jsonPath)
+	c.Assert(err, chk.IsNil)
+	lines = strings.Split(strings.TrimSpace(string(content)), "\n")
+	c.Assert(lines, chk.HasLen, 2)
+	var decoded transferMetricsRecord
+	c.Assert(json.Unmarshal([]byte(lines[1]), &decoded), chk.IsNil)
+	c.Assert(decoded, chk.DeepEquals, *rec)
+}
+
+func (s *xferMetricsSuite) TestRetriesAreCountedThroughTheContext(c *chk.C) {
+	m := &transferMetrics{}
+	ctx := withTransferMetrics(context.Background(), m)
+	recordTransferRetry(ctx)
+	recordTransferRetry(context.WithValue(ctx, contextKey{"other"}, 1)) // a context derived from the transfer's one
+	recordTransferRetry(context.Background())                           // no transfer, so nothing to count against
+	c.Assert(m.atomicRetries, chk.Equals, int32(2))
+}
@@ -0,0 +1,426 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-azcopy/azbfs"
+	"github.com/Azure/azure-storage-azcopy/common"
+)
+
+type zeroByteFilesSuite struct{}
+
+var _ = chk.Suite(&zeroByteFilesSuite{})
+
+var zeroByteLastModified = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+var (
+	zeroByteSlicePool    = common.NewMultiSizeSlicePool(8 * 1024 * 1024)
+	zeroByteCacheLimiter = common.NewCacheLimiter(64 * 1024 * 1024)
+)
+
+// zeroByteTransferMgr is a single transfer of an empty file, whose chunks are run as soon as they are scheduled
+type zeroByteTransferMgr struct {
+	IJobPartTransferMgr
+	info           TransferInfo
+	fromTo         common.FromTo
+	blobType       common.BlobType
+	md5Validation  common.HashValidationOption
+	sourcePipeline pipeline.Pipeline
+
+	ctx            context.Context
+	cancel         context.CancelFunc
+	lock           sync.Mutex
+	status         common.TransferStatus
+	failures       []string
+	numChunks      uint32
+	chunksDone     uint32
+	afterLastChunk func()
+	chunks         sync.WaitGroup
+	done           bool
+}
+
+// run runs the transfer to its end
+func (t *zeroByteTransferMgr) run(p pipeline.Pipeline) {
+	computeJobXfer(t.fromTo, t.blobType)(t, p, newNullAutoPacer())
+	t.chunks.Wait()
+}
+
+func newZeroByteTransferMgr(fromTo common.FromTo, blobType common.BlobType, source, destination string, p pipeline.Pipeline) *zeroByteTransferMgr {
+	ctx, cancel := context.WithCancel(context.Background())
+	srcBlobType := blobType.ToAzBlobType()
+	if srcBlobType == azblob.BlobNone {
+		srcBlobType = azblob.BlobBlockBlob
+	}
+	return &zeroByteTransferMgr{
+		info: TransferInfo{
+			BlockSize:            8 * 1024 * 1024,
+			Source:               source,
+			SourceSize:           0,
+			Destination:          destination,
+			DestLengthValidation: true,
+			SrcBlobType:          srcBlobType,
+		},
+		fromTo:         fromTo,
+		blobType:       blobType,
+		md5Validation:  common.EHashValidationOption.FailIfDifferent(),
+		sourcePipeline: p,
+		ctx:            ctx,
+		cancel:         cancel,
+		status:         common.ETransferStatus.Started(),
+	}
+}
+
+func (t *zeroByteTransferMgr) Info() TransferInfo                        { return t.info }
+func (t *zeroByteTransferMgr) FromTo() common.FromTo                     { return t.fromTo }
+func (t *zeroByteTransferMgr) BlobTypeOverride() common.BlobType         { return t.blobType }
+func (t *zeroByteTransferMgr) Context() context.Context                  { return t.ctx }
+func (t *zeroByteTransferMgr) SourceProviderPipeline() pipeline.Pipeline { return t.sourcePipeline }
+func (t *zeroByteTransferMgr) LastModifiedTime() time.Time               { return zeroByteLastModified }
+func (t *zeroByteTransferMgr) MD5ValidationOption() common.HashValidationOption {
+	return t.md5Validation
+}
+
+func (t *zeroByteTransferMgr) BlobDstData([]byte) (azblob.BlobHTTPHeaders, azblob.Metadata) {
+	return azblob.BlobHTTPHeaders{}, azblob.Metadata{}
+}
+func (t *zeroByteTransferMgr) FileDstData([]byte) (azfile.FileHTTPHeaders, azfile.Metadata) {
+	return azfile.FileHTTPHeaders{}, azfile.Metadata{}
+}
+func (t *zeroByteTransferMgr) BfsDstData([]byte) azbfs.BlobFSHTTPHeaders {
+	return azbfs.BlobFSHTTPHeaders{}
+}
+func (t *zeroByteTransferMgr) BlobTiers() (common.BlockBlobTier, common.PageBlobTier) {
+	return common.EBlockBlobTier.None(), common.EPageBlobTier.None()
+}
+func (t *zeroByteTransferMgr) BlobTags() common.BlobTags         { return nil }
+func (t *zeroByteTransferMgr) ShouldPutMd5() bool                { return true }
+func (t *zeroByteTransferMgr) ShouldDecompress() bool            { return false }
+func (t *zeroByteTransferMgr) PreserveSourceLMTAsMetadata() bool { return false }
+func (t *zeroByteTransferMgr) S2SComputeMD5() common.S2SComputeMD5 {
+	return common.ES2SComputeMD5.None()
+}
+func (t *zeroByteTransferMgr) GetOverwriteOption() common.OverwriteOption {
+	return common.EOverwriteOption.True()
+}
+func (t *zeroByteTransferMgr) SoftDeletedDestinationHandling() common.SoftDeletedDestinationHandling {
+	return common.ESoftDeletedDestinationHandling.Ignore()
+}
+func (t *zeroByteTransferMgr) PreserveLastModifiedTime() (time.Time, bool) {
+	return zeroByteLastModified, true
+}
+func (t *zeroByteTransferMgr) DiffBaseSnapshot() string          { return "" }
+func (t *zeroByteTransferMgr) DownloadRange() (int64, bool)      { return 0, false }
+func (t *zeroByteTransferMgr) SavedDownload() (int64, time.Time) { return 0, time.Time{} }
+func (t *zeroByteTransferMgr) SetSavedDownload(int64, time.Time) {}
+func (t *zeroByteTransferMgr) WasResumed() bool                  { return false }
+func (t *zeroByteTransferMgr) ReuseUncommittedBlocks() bool      { return false }
+func (t *zeroByteTransferMgr) ProtectDestinationWithLease() bool { return false }
+func (t *zeroByteTransferMgr) BreakLeaseOnOverwrite() bool       { return false }
+func (t *zeroByteTransferMgr) DestinationCondition() common.DestinationCondition {
+	return common.DestinationCondition{}
+}
+func (t *zeroByteTransferMgr) TempNameSuffix() string                      { return "" }
+func (t *zeroByteTransferMgr) AppendOnly() bool                            { return false }
+func (t *zeroByteTransferMgr) DeleteSourceAfterTransfer() bool             { return false }
+func (t *zeroByteTransferMgr) ClearArchiveBit() bool                       { return false }
+func (t *zeroByteTransferMgr) JobHasLowFileCount() bool                    { return true }
+func (t *zeroByteTransferMgr) GetDiskSpaceGuard() *diskSpaceGuard          { return nil }
+func (t *zeroByteTransferMgr) ChecksumHasher() hash.Hash                   { return nil }
+func (t *zeroByteTransferMgr) SetChecksum([]byte)                          {}
+func (t *zeroByteTransferMgr) VerifyChecksum() error                       { return nil }
+func (t *zeroByteTransferMgr) RecordChecksum()                             {}
+func (t *zeroByteTransferMgr) SlicePool() common.ByteSlicePooler           { return zeroByteSlicePool }
+func (t *zeroByteTransferMgr) CacheLimiter() common.CacheLimiter           { return zeroByteCacheLimiter }
+func (t *zeroByteTransferMgr) ChunkStatusLogger() common.ChunkStatusLogger { return t }
+func (t *zeroByteTransferMgr) GetSourceCompressionType() (common.CompressionType, error) {
+	return common.ECompressionType.None(), nil
+}
+
+func (t *zeroByteTransferMgr) S2SFallback() common.S2SFallback                  { return common.ES2SFallback.None() }
+func (t *zeroByteTransferMgr) S2SSourceTokenCredential() azblob.TokenCredential { return nil }
+func (t *zeroByteTransferMgr) ReportRelayedClientSide()                         {}
+func (t *zeroByteTransferMgr) SharingViolationRetryWindow() time.Duration       { return 0 }
+func (t *zeroByteTransferMgr) ForceIfReadOnly() bool                            { return false }
+func (t *zeroByteTransferMgr) ReportSharingViolationRetry()                     {}
+func (t *zeroByteTransferMgr) ShareFullWaitWindow() time.Duration               { return 0 }
+func (t *zeroByteTransferMgr) GetShareQuotaGuard() *shareQuotaGuard             { return nil }
+func (t *zeroByteTransferMgr) FlushPolicy() (common.FlushPolicy, int64) {
+	return common.EFlushPolicy.None(), 0
+}
+
+func (t *zeroByteTransferMgr) WasCanceled() bool { return t.ctx.Err() != nil }
+func (t *zeroByteTransferMgr) Cancel()           { t.cancel() }
+func (t *zeroByteTransferMgr) IsLive() bool {
+	return !t.WasCanceled() && t.TransferStatusIgnoringCancellation() >= 0
+}
+func (t *zeroByteTransferMgr) IsDeadInflight() bool    { return !t.IsLive() }
+func (t *zeroByteTransferMgr) IsDeadBeforeStart() bool { return false }
+
+func (t *zeroByteTransferMgr) TransferStatusIgnoringCancellation() common.TransferStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.status
+}
+
+func (t *zeroByteTransferMgr) SetStatus(status common.TransferStatus) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status = status
+}
+
+func (t *zeroByteTransferMgr) fail(where string, err error) {
+	t.lock.Lock()
+	t.failures = append(t.failures, fmt.Sprintf("%s: %v", where, err))
+	t.lock.Unlock()
+	t.SetStatus(common.ETransferStatus.Failed())
+	t.Cancel()
+}
+
+func (t *zeroByteTransferMgr) FailActiveSend(where string, err error)     { t.fail(where, err) }
+func (t *zeroByteTransferMgr) FailActiveUpload(where string, err error)   { t.fail(where, err) }
+func (t *zeroByteTransferMgr) FailActiveDownload(where string, err error) { t.fail(where, err) }
+func (t *zeroByteTransferMgr) FailActiveS2SCopy(where string, err error)  { t.fail(where, err) }
+func (t *zeroByteTransferMgr) FailActiveSendWithStatus(where string, err error, _ common.TransferStatus) {
+	t.fail(where, err)
+}
+func (t *zeroByteTransferMgr) FailActiveUploadWithStatus(where string, err error, _ common.TransferStatus) {
+	t.fail(where, err)
+}
+func (t *zeroByteTransferMgr) FailActiveDownloadWithStatus(where string, err error, _ common.TransferStatus) {
+	t.fail(where, err)
+}
+func (t *zeroByteTransferMgr) FailActiveS2SCopyWithStatus(where string, err error, _ common.TransferStatus) {
+	t.fail(where, err)
+}
+func (t *zeroByteTransferMgr) LogSendError(_, _, errorMsg string, _ int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.failures = append(t.failures, errorMsg)
+}
+func (t *zeroByteTransferMgr) LogDownloadError(_, _, errorMsg string, _ int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.failures = append(t.failures, errorMsg)
+}
+func (t *zeroByteTransferMgr) LogError(_, context string, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.failures = append(t.failures, fmt.Sprintf("%s: %v", context, err))
+}
+
+func (t *zeroByteTransferMgr) ShouldLog(pipeline.LogLevel) bool                          { return false }
+func (t *zeroByteTransferMgr) Log(pipeline.LogLevel, string)                             {}
+func (t *zeroByteTransferMgr) LogAtLevelForCurrentTransfer(pipeline.LogLevel, string)    {}
+func (t *zeroByteTransferMgr) LogTransferInfo(pipeline.LogLevel, string, string, string) {}
+func (t *zeroByteTransferMgr) IsWaitingOnFinalBodyReads() bool                           { return false }
+func (t *zeroByteTransferMgr) LogChunkStatus(common.ChunkID, common.WaitReason)          {}
+
+func (t *zeroByteTransferMgr) SetNumberOfChunks(numChunks uint32) { t.numChunks = numChunks }
+func (t *zeroByteTransferMgr) SetActionAfterLastChunk(f func())   { t.afterLastChunk = f }
+func (t *zeroByteTransferMgr) ScheduleChunks(chunkFunc chunkFunc) {
+	t.chunks.Add(1)
+	go func() {
+		defer t.chunks.Done()
+		chunkFunc(0)
+	}()
+}
+func (t *zeroByteTransferMgr) ReportChunkDone(id common.ChunkID) (lastChunk bool, chunksDone uint32) {
+	id.SetCompletionNotificationSent()
+	t.chunksDone++
+	if t.chunksDone == t.numChunks {
+		t.afterLastChunk()
+	}
+	return t.chunksDone == t.numChunks, t.chunksDone
+}
+func (t *zeroByteTransferMgr) ReportTransferDone() uint32 {
+	t.done = true
+	return 1
+}
+
+func (t *zeroByteTransferMgr) WaitUntilLockDestination(context.Context) error { return nil }
+func (t *zeroByteTransferMgr) HoldsDestinationLock() bool                     { return true }
+func (t *zeroByteTransferMgr) UnlockDestination()                             {}
+func (t *zeroByteTransferMgr) SetDestinationIsModified()                      {}
+func (t *zeroByteTransferMgr) OccupyAConnection()                             {}
+func (t *zeroByteTransferMgr) ReleaseAConnection()                            {}
+
+// fakeEmptyFileService is a Blob, File and ADLS Gen2 service that has an empty file at every path, and accepts every write.
+// It keeps a line for each request, of its method and of what the comp, action or restype query parameter says it is.
+func fakeEmptyFileService(requests *[]string, lock *sync.Mutex) pipeline.Pipeline {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			query := request.URL.Query()
+			method := request.Method
+			if override := request.Header.Get("X-Http-Method-Override"); override != "" {
+				method = override
+			}
+			operation := strings.TrimSpace(method + " " + query.Get("comp") + query.Get("action") + query.Get("restype"))
+			lock.Lock()
+			*requests = append(*requests, operation)
+			lock.Unlock()
+
+			status := http.StatusOK
+			switch {
+			case method == http.MethodPut && query.Get("comp") == "":
+				status = http.StatusCreated
+			case method == http.MethodDelete:
+				status = http.StatusAccepted
+			}
+			header := http.Header{}
+			header.Set("Content-Length", "0")
+			header.Set("Last-Modified", zeroByteLastModified.Format(http.TimeFormat))
+			header.Set("x-ms-blob-type", string(azblob.BlobBlockBlob))
+			header.Set("x-ms-type", "File")
+			header.Set("x-ms-resource-type", "file")
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: status, Status: http.StatusText(status), Header: header,
+				ContentLength: 0, Body: ioutil.NopCloser(strings.NewReader("")), Request: request.Request}), nil
+		}
+	})
+	return pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+}
+
+// dataRequests are the requests which write or read the content of a file, none of which an empty file needs
+func dataRequests(requests []string) []string {
+	data := make([]string, 0)
+	for _, r := range requests {
+		switch r {
+		case "PUT block", "PUT blocklist", "PUT appendblock", "PUT page", "PUT range", "PATCH append", "GET":
+			data = append(data, r)
+		}
+	}
+	return data
+}
+
+func (s *zeroByteFilesSuite) TestEmptyFilesAreTransferredWithoutDataRequests(c *chk.C) {
+	dir, err := ioutil.TempDir("", "zeroByteFiles")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	localSource := filepath.Join(dir, "empty.txt")
+	c.Assert(ioutil.WriteFile(localSource, nil, 0644), chk.IsNil)
+	c.Assert(os.Chtimes(localSource, zeroByteLastModified, zeroByteLastModified), chk.IsNil)
+
+	remote := map[common.Location]string{
+		common.ELocation.Blob():   "https://acct.blob.core.windows.net/cont/empty.txt",
+		common.ELocation.File():   "https://acct.file.core.windows.net/share/empty.txt",
+		common.ELocation.BlobFS(): "https://acct.dfs.core.windows.net/filesystem/empty.txt",
+	}
+	blobTypes := []common.BlobType{common.EBlobType.BlockBlob(), common.EBlobType.PageBlob(), common.EBlobType.AppendBlob()}
+
+	matrix := []struct {
+		fromTo    common.FromTo
+		blobTypes []common.BlobType
+	}{
+		{common.EFromTo.LocalBlob(), blobTypes},
+		{common.EFromTo.LocalFile(), nil},
+		{common.EFromTo.LocalBlobFS(), nil},
+		{common.EFromTo.BlobBlob(), blobTypes},
+		{common.EFromTo.FileBlob(), blobTypes},
+		{common.EFromTo.BlobFile(), nil},
+		{common.EFromTo.FileFile(), nil},
+		{common.EFromTo.BlobLocal(), nil},
+		{common.EFromTo.FileLocal(), nil},
+		{common.EFromTo.BlobFSLocal(), nil},
+	}
+
+	for i, m := range matrix {
+		types := m.blobTypes
+		if types == nil {
+			types = []common.BlobType{common.EBlobType.Detect()}
+		}
+		for _, blobType := range types {
+			for _, toDevNull := range []bool{false, true} {
+				if toDevNull && !m.fromTo.IsDownload() {
+					continue
+				}
+				name := fmt.Sprintf("%s %s devnull=%v", m.fromTo, blobType, toDevNull)
+
+				source := localSource
+				if m.fromTo.From().IsRemote() {
+					source = remote[m.fromTo.From()]
+				}
+				destination := filepath.Join(dir, fmt.Sprintf("downloaded%d.txt", i))
+				if toDevNull {
+					destination = common.Dev_Null
+				} else if m.fromTo.To().IsRemote() {
+					destination = strings.Replace(remote[m.fromTo.To()], "empty.txt", "copied.txt", 1)
+				}
+
+				requests := make([]string, 0)
+				p := fakeEmptyFileService(&requests, &sync.Mutex{})
+				jptm := newZeroByteTransferMgr(m.fromTo, blobType, source, destination, p)
+				jptm.run(p)
+
+				c.Assert(jptm.failures, chk.HasLen, 0, chk.Commentf("%s: %v", name, jptm.failures))
+				c.Assert(jptm.done, chk.Equals, true, chk.Commentf(name))
+				c.Assert(jptm.status, chk.Equals, common.ETransferStatus.Success(), chk.Commentf(name))
+				c.Assert(dataRequests(requests), chk.HasLen, 0, chk.Commentf("%s: %v", name, requests))
+				if m.fromTo.IsDownload() && !toDevNull {
+					fi, err := os.Stat(destination)
+					c.Assert(err, chk.IsNil, chk.Commentf(name))
+					c.Assert(fi.Size(), chk.Equals, int64(0), chk.Commentf(name))
+				}
+			}
+		}
+	}
+}
+
+func (s *zeroByteFilesSuite) TestEmptyDownloadIsCheckedAgainstTheMD5OfNothing(c *chk.C) {
+	dir, err := ioutil.TempDir("", "zeroByteFiles")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	md5OfNothing := md5.Sum(nil)
+
+	download := func(storedMD5 []byte, validation common.HashValidationOption) *zeroByteTransferMgr {
+		requests := make([]string, 0)
+		p := fakeEmptyFileService(&requests, &sync.Mutex{})
+		jptm := newZeroByteTransferMgr(common.EFromTo.BlobLocal(), common.EBlobType.Detect(),
+			"https://acct.blob.core.windows.net/cont/empty.txt", filepath.Join(dir, "empty.txt"), p)
+		jptm.info.SrcHTTPHeaders.ContentMD5 = storedMD5
+		jptm.md5Validation = validation
+		jptm.run(p)
+		return jptm
+	}
+
+	c.Assert(download(md5OfNothing[:], common.EHashValidationOption.FailIfDifferent()).status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(download([]byte("not the md5 of nothing"), common.EHashValidationOption.FailIfDifferent()).status, chk.Equals, common.ETransferStatus.Failed())
+	c.Assert(download([]byte("not the md5 of nothing"), common.EHashValidationOption.LogOnly()).status, chk.Equals, common.ETransferStatus.Success())
+	c.Assert(download([]byte("not the md5 of nothing"), common.EHashValidationOption.NoCheck()).status, chk.Equals, common.ETransferStatus.Success())
+
+	// there's nothing in an empty file to corrupt, so it's fine for it to have no MD5
+	c.Assert(download(nil, common.EHashValidationOption.FailIfDifferentOrMissing()).status, chk.Equals, common.ETransferStatus.Success())
+}